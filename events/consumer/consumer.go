@@ -28,6 +28,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/spf13/viper"
 
@@ -39,13 +40,19 @@ type OpenchainEventsClient struct {
 	peerAddress string
 	stream      ehpb.OpenchainEvents_ChatClient
 	adapter     EventAdapter
+	token       string
 }
 
 const defaultTimeout = time.Second * 3
 
 //NewOpenchainEventsClient Returns a new grpc.ClientConn to the configured local PEER.
 func NewOpenchainEventsClient(peerAddress string, adapter EventAdapter) *OpenchainEventsClient {
-	return &OpenchainEventsClient{peerAddress, nil, adapter}
+	return &OpenchainEventsClient{peerAddress, nil, adapter, ""}
+}
+
+//SetToken sets the bearer token the client authenticates to the event hub with
+func (ec *OpenchainEventsClient) SetToken(token string) {
+	ec.token = token
 }
 
 //newOpenchainEventsClientConnectionWithAddress Returns a new grpc.ClientConn to the configured local PEER.
@@ -149,8 +156,12 @@ func (ec *OpenchainEventsClient) Start() error {
 		return fmt.Errorf("must supply interested events")
 	}
 
+	ctx := context.Background()
+	if ec.token != "" {
+		ctx = metadata.NewContext(ctx, metadata.Pairs("authorization", "Bearer "+ec.token))
+	}
 	serverClient := ehpb.NewOpenchainEventsClient(conn)
-	ec.stream, err = serverClient.Chat(context.Background())
+	ec.stream, err = serverClient.Chat(ctx)
 	if err != nil {
 		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
 	}
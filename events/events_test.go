@@ -29,6 +29,7 @@ import (
 
 	"github.com/openblockchain/obc-peer/events/consumer"
 	"github.com/openblockchain/obc-peer/events/producer"
+	"github.com/openblockchain/obc-peer/openchain/access"
 	ehpb "github.com/openblockchain/obc-peer/protos"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -108,6 +109,37 @@ func TestReceiveMessage(t *testing.T) {
 	}
 }
 
+// Test that events are delivered across a change of the buffer size
+func TestReceiveMessagesAfterBufferResize(t *testing.T) {
+	numMessages := 20
+
+	adapter.Lock()
+	adapter.count = numMessages
+	adapter.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numMessages; i++ {
+		if i == numMessages/2 {
+			producer.SetBufferSize(5)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := producer.Send(createTestBlock()); err != nil {
+				t.Errorf("Error sending message %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	defer producer.SetBufferSize(100)
+
+	select {
+	case <-adapter.notfy:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out on messages")
+	}
+}
+
 func BenchmarkMessages(b *testing.B) {
 	numMessages := 10000
 
@@ -158,7 +190,7 @@ func TestMain(m *testing.M) {
 
 	// Register EventHub server
 	// use a buffer of 100 and blocking timeout
-	ehServer := producer.NewOpenchainEventsServer(100, 0)
+	ehServer := producer.NewOpenchainEventsServer(100, 0, access.NewGuard(access.Config{}))
 	ehpb.RegisterOpenchainEventsServer(grpcServer, ehServer)
 
 	fmt.Printf("Starting events server\n")
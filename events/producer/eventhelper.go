@@ -27,3 +27,24 @@ import (
 func CreateBlockEvent(te *ehpb.Block) *ehpb.OpenchainEvent {
 	return &ehpb.OpenchainEvent{&ehpb.OpenchainEvent_Block{Block: te}}
 }
+
+//CreateRejectionEvent creates a OpenchainEvent from a Transaction that failed to execute
+func CreateRejectionEvent(tx *ehpb.Transaction, errorMsg string) *ehpb.OpenchainEvent {
+	return &ehpb.OpenchainEvent{Event: &ehpb.OpenchainEvent_Rejection{Rejection: &ehpb.Rejection{Tx: tx, ErrorMsg: errorMsg}}}
+}
+
+//CreateChaincodeEvent creates a OpenchainEvent from an event set by a chaincode
+func CreateChaincodeEvent(te *ehpb.ChaincodeEvent) *ehpb.OpenchainEvent {
+	return &ehpb.OpenchainEvent{Event: &ehpb.OpenchainEvent_ChaincodeEvent{ChaincodeEvent: te}}
+}
+
+//CreateEnrollmentRenewalEvent creates a OpenchainEvent reporting the outcome of
+//the renewal of an enrollment certificate
+func CreateEnrollmentRenewalEvent(te *ehpb.EnrollmentRenewal) *ehpb.OpenchainEvent {
+	return &ehpb.OpenchainEvent{Event: &ehpb.OpenchainEvent_EnrollmentRenewal{EnrollmentRenewal: te}}
+}
+
+//CreatePeerReadyEvent creates a OpenchainEvent announcing that the peer is ready
+func CreatePeerReadyEvent(te *ehpb.PeerReady) *ehpb.OpenchainEvent {
+	return &ehpb.OpenchainEvent{Event: &ehpb.OpenchainEvent_PeerReady{PeerReady: te}}
+}
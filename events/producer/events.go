@@ -54,6 +54,10 @@ type eventProcessor struct {
 	//we could generalize this with mutiple channels each with its own size
 	eventChannel chan *pb.OpenchainEvent
 
+	//channelLock is held by senders while they send so that the channel
+	//can be replaced by one of a different size
+	channelLock sync.RWMutex
+
 	//milliseconds timeout for producer to send an event.
 	//if < 0, if buffer full, unblocks immediately and not send
 	//if 0, if buffer full, will block and guarantee the event will be sent out
@@ -67,9 +71,19 @@ var gEventProcessor *eventProcessor
 
 func (ep *eventProcessor) start() {
 	producerLogger.Info("event processor started")
+	ep.channelLock.RLock()
+	eventChannel := ep.eventChannel
+	ep.channelLock.RUnlock()
 	for {
 		//wait for event
-		e := <-ep.eventChannel
+		e, ok := <-eventChannel
+		if !ok {
+			//the channel was replaced, its events have been processed
+			ep.channelLock.RLock()
+			eventChannel = ep.eventChannel
+			ep.channelLock.RUnlock()
+			continue
+		}
 
 		var hl *handlerList
 		eType := getMessageType(e)
@@ -120,6 +134,43 @@ func initializeEvents(bufferSize uint, tout int) {
 	go gEventProcessor.start()
 }
 
+//SetBufferSize replaces the event channel with one buffering bufferSize
+//events. Events buffered in the old channel are processed first.
+func SetBufferSize(bufferSize uint) {
+	if gEventProcessor == nil {
+		return
+	}
+	gEventProcessor.channelLock.Lock()
+	defer gEventProcessor.channelLock.Unlock()
+	old := gEventProcessor.eventChannel
+	gEventProcessor.eventChannel = make(chan *pb.OpenchainEvent, bufferSize)
+	close(old)
+}
+
+//SetTimeout sets the milliseconds timeout for producers to send an event
+func SetTimeout(tout int) {
+	if gEventProcessor == nil {
+		return
+	}
+	gEventProcessor.channelLock.Lock()
+	defer gEventProcessor.channelLock.Unlock()
+	gEventProcessor.timeout = tout
+}
+
+//CheckHealth returns an error if the event hub is not started or its
+//buffer is full, ie. events are produced faster than they are sent
+func CheckHealth() error {
+	if gEventProcessor == nil {
+		return fmt.Errorf("event hub not started")
+	}
+	gEventProcessor.channelLock.RLock()
+	defer gEventProcessor.channelLock.RUnlock()
+	if size := cap(gEventProcessor.eventChannel); size > 0 && len(gEventProcessor.eventChannel) == size {
+		return fmt.Errorf("event buffer full")
+	}
+	return nil
+}
+
 //AddEventType supported event
 func AddEventType(eventType string) error {
 	gEventProcessor.Lock()
@@ -189,6 +240,9 @@ func Send(e *pb.OpenchainEvent) error {
 		return nil
 	}
 
+	gEventProcessor.channelLock.RLock()
+	defer gEventProcessor.channelLock.RUnlock()
+
 	if gEventProcessor.timeout < 0 {
 		select {
 		case gEventProcessor.eventChannel <- e:
@@ -25,7 +25,12 @@ import (
 	"time"
 
 	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 
+	"github.com/openblockchain/obc-peer/openchain/access"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -35,23 +40,37 @@ var producerLogger = logging.MustGetLogger("eventhub_producer")
 
 // OpenchainEventsServer implementation of the Peer service
 type OpenchainEventsServer struct {
+	guard *access.Guard
 }
 
 //singleton - if we want to create multiple servers, we need to subsume events.gEventConsumers into OpenchainEventsServer
 var globalOpenchainEventsServer *OpenchainEventsServer
 
-// NewOpenchainEventsServer returns a OpenchainEventsServer
-func NewOpenchainEventsServer(bufferSize uint, timeout int) *OpenchainEventsServer {
+// NewOpenchainEventsServer returns a OpenchainEventsServer admitting the
+// consumers guard authenticates and limits
+func NewOpenchainEventsServer(bufferSize uint, timeout int, guard *access.Guard) *OpenchainEventsServer {
 	if globalOpenchainEventsServer != nil {
 		panic("Cannot create multiple event hub servers")
 	}
-	globalOpenchainEventsServer = new(OpenchainEventsServer)
+	globalOpenchainEventsServer = &OpenchainEventsServer{guard: guard}
 	initializeEvents(bufferSize, timeout)
 	return globalOpenchainEventsServer
 }
 
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *OpenchainEventsServer) Chat(stream pb.OpenchainEvents_ChatServer) error {
+	client, err := p.guard.Authenticate(bearerToken(stream.Context()))
+	if err != nil {
+		producerLogger.Warning(fmt.Sprintf("Refused consumer: %s", err))
+		return grpc.Errorf(codes.Unauthenticated, "%s", err)
+	}
+	release, err := p.guard.Connect(client)
+	if err != nil {
+		producerLogger.Warning(fmt.Sprintf("Refused consumer %s: %s", client, err))
+		return grpc.Errorf(codes.ResourceExhausted, "%s", err)
+	}
+	defer release()
+
 	handler, err := newOpenchainEventHandler(stream)
 	if err != nil {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
@@ -68,6 +87,10 @@ func (p *OpenchainEventsServer) Chat(stream pb.OpenchainEvents_ChatServer) error
 			producerLogger.Error(e.Error())
 			return e
 		}
+		if err = p.guard.Allow(client); err != nil {
+			producerLogger.Warning(fmt.Sprintf("Ending Chat with consumer %s: %s", client, err))
+			return grpc.Errorf(codes.ResourceExhausted, "%s", err)
+		}
 		err = handler.HandleMessage(in)
 		if err != nil {
 			producerLogger.Error(fmt.Sprintf("Error handling message: %s", err))
@@ -75,3 +98,13 @@ func (p *OpenchainEventsServer) Chat(stream pb.OpenchainEvents_ChatServer) error
 		}
 	}
 }
+
+// bearerToken returns the token consumers send in the authorization metadata
+// of the stream
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return ""
+	}
+	return access.BearerToken(md["authorization"][0])
+}
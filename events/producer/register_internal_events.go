@@ -30,8 +30,12 @@ import (
 
 //----Event Types -----
 const (
-	RegisterType = "register"
-	BlockType    = "block"
+	RegisterType  = "register"
+	BlockType     = "block"
+	RejectionType = "rejection"
+	ChaincodeType = "chaincode"
+	ReadyType     = "ready"
+	RenewalType   = "renewal"
 )
 
 func getMessageType(e *pb.OpenchainEvent) string {
@@ -42,6 +46,14 @@ func getMessageType(e *pb.OpenchainEvent) string {
 		return "block"
 	case *pb.OpenchainEvent_Generic:
 		return "generic"
+	case *pb.OpenchainEvent_Rejection:
+		return "rejection"
+	case *pb.OpenchainEvent_ChaincodeEvent:
+		return "chaincode"
+	case *pb.OpenchainEvent_PeerReady:
+		return "ready"
+	case *pb.OpenchainEvent_EnrollmentRenewal:
+		return "renewal"
 	default:
 		return ""
 	}
@@ -51,4 +63,8 @@ func getMessageType(e *pb.OpenchainEvent) string {
 func addInternalEventTypes() {
 	AddEventType(BlockType)
 	AddEventType(RegisterType)
+	AddEventType(RejectionType)
+	AddEventType(ChaincodeType)
+	AddEventType(ReadyType)
+	AddEventType(RenewalType)
 }
@@ -20,15 +20,21 @@ under the License.
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"golang.org/x/net/context"
@@ -37,20 +43,37 @@ import (
 
 	"github.com/howeyc/gopass"
 	"github.com/op/go-logging"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	"gopkg.in/yaml.v2"
 
 	"github.com/openblockchain/obc-peer/events/producer"
 	"github.com/openblockchain/obc-peer/openchain"
+	"github.com/openblockchain/obc-peer/openchain/access"
+	"github.com/openblockchain/obc-peer/openchain/audit"
+	"github.com/openblockchain/obc-peer/openchain/bench"
 	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/client"
+	"github.com/openblockchain/obc-peer/openchain/config"
+	"github.com/openblockchain/obc-peer/openchain/consensus/controller"
 	"github.com/openblockchain/obc-peer/openchain/consensus/helper"
+	"github.com/openblockchain/obc-peer/openchain/container"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/gateway"
+	"github.com/openblockchain/obc-peer/openchain/health"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/blockfile"
 	"github.com/openblockchain/obc-peer/openchain/ledger/genesis"
+	"github.com/openblockchain/obc-peer/openchain/metrics"
 	"github.com/openblockchain/obc-peer/openchain/peer"
 	"github.com/openblockchain/obc-peer/openchain/rest"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -103,6 +126,434 @@ var stopCmd = &cobra.Command{
 	},
 }
 
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Node management of the openchain peer.",
+	Long: `Manage the transaction intake of the currently running openchain peer for maintenance windows: pause it,
+drain the transactions in flight to completion and resume it. The requests are sent to the local admin address of
+the peer, peer.admin.localAddress, which is only reachable from its host.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("node")
+	},
+}
+
+var nodeStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the peer.",
+	Long:  `Shows whether the peer accepts transactions, how many are in flight and the height of its blockchain.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return nodeRequest("", func(admin pb.AdminClient, ctx context.Context) (*pb.ServerStatus, error) {
+			return admin.GetStatus(ctx, &google_protobuf.Empty{})
+		})
+	},
+}
+
+var nodePauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop accepting transactions.",
+	Long:  `Stops the peer from accepting transactions. Transactions in flight are still processed, queries still served.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return nodeRequest(openchain.AdminActionPause, func(admin pb.AdminClient, ctx context.Context) (*pb.ServerStatus, error) {
+			return admin.PauseServer(ctx, &google_protobuf.Empty{})
+		})
+	},
+}
+
+var nodeResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Accept transactions again.",
+	Long:  `Makes a paused or drained peer accept transactions again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return nodeRequest(openchain.AdminActionResume, func(admin pb.AdminClient, ctx context.Context) (*pb.ServerStatus, error) {
+			return admin.ResumeServer(ctx, &google_protobuf.Empty{})
+		})
+	},
+}
+
+var nodeDrainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Pause and wait for the transactions in flight.",
+	Long:  `Pauses the peer and waits until the transactions in flight are committed, failing if some are still in flight after --timeout seconds.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return nodeRequest(openchain.AdminActionDrain, func(admin pb.AdminClient, ctx context.Context) (*pb.ServerStatus, error) {
+			logger.Info("Draining peer, waiting up to %d seconds", nodeDrainTimeout)
+			return admin.DrainServer(ctx, &pb.DrainRequest{Timeout: nodeDrainTimeout})
+		})
+	},
+}
+
+var nodeRotateIdentityCmd = &cobra.Command{
+	Use:   "rotate-identity",
+	Short: "Re-enroll with new enrollment keys.",
+	Long:  `Re-enrolls the peer with new enrollment keys under the same enrollment ID, eg. after its key was compromised. The previous enrollment certificate is revoked and the connected peers are sent the new one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return nodeRotateIdentity()
+	},
+}
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Checkpoint functionality of the openchain peer.",
+	Long:  `Tag blocks of the ledger of the local peer with named checkpoints and roll the ledger back to them.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("checkpoint")
+	},
+}
+
+var checkpointTagCmd = &cobra.Command{
+	Use:   "tag <name>",
+	Short: "Tag the last block with a checkpoint.",
+	Long:  `Tags the last block of the blockchain of the local peer with a named checkpoint.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkpointTag(args)
+	},
+}
+
+var checkpointListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the checkpoints.",
+	Long:  `Lists the checkpoints of the local peer and the blocks they tag.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkpointList()
+	},
+}
+
+var checkpointRollbackCmd = &cobra.Command{
+	Use:   "rollback <name>",
+	Short: "Roll the ledger back to a checkpoint.",
+	Long: `Reverts the state and the blockchain of the local peer to a checkpoint, discarding the blocks after it.
+Meant for test networks and disaster recovery. Both --confirm and --block, the number of the block tagged
+by the checkpoint, must be given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkpointRollback(cmd, args)
+	},
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <first block> <last block>",
+	Short: "Replay committed blocks on the openchain peer.",
+	Long: `Executes the transactions of a range of committed blocks again on a scratch copy of the state of the
+currently running openchain peer, which must be a paused validating peer, and compares the state hash after
+each block with the recorded one. The ledger is left unchanged. Blocks deploying chaincode are not executed
+again, their recorded state changes are applied instead.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("replay")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return replay(args)
+	},
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <path>",
+	Short: "Back up the ledger of the openchain peer.",
+	Long: `Backs up the ledger of the currently running openchain peer to a directory of the peer, which must not
+exist or be empty, without stopping transaction processing. The path must be inside the backup directory of
+the peer, peer.admin.backup.directory, relative paths are taken from it. The directory holds a copy of the DB
+and a manifest with the block height and hashes covered by the backup.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("backup")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return backup(args)
+	},
+}
+
+var genesisCmd = &cobra.Command{
+	Use:   "genesis <network file>",
+	Short: "Validate a network definition.",
+	Long: `Validates the network definition in the YAML file and prints its hash, which identifies the genesis
+block built from it. Peers are bootstrapped from the definition by setting ledger.blockchain.genesisNetwork
+to the path of the file.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("genesis")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showNetworkDefinition(args)
+	},
+}
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Network parameters of openchain.",
+	Long:  `Change the network parameters, eg. batchsize, membership, chainpolicy, chains or packagesignature, through configuration transactions.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("network")
+	},
+}
+
+var networkUpdateCmd = &cobra.Command{
+	Use:   "update <parameter> <value> <block height>",
+	Short: "Change a network parameter.",
+	Long: `Submits a configuration transaction changing a network parameter on all peers once the blockchain
+reaches the given height, which must be above the current one. The netconfig system chaincode must be
+enabled in chaincode.system. Only available with security disabled, otherwise configuration transactions
+must be signed by an administrator.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkUpdate(cmd, args)
+	},
+}
+
+var deadLetterCmd = &cobra.Command{
+	Use:   "deadletter",
+	Short: "Transactions that failed on the openchain peer.",
+	Long: `List, inspect, resubmit and purge the transactions that failed to simulate, to be submitted or to
+execute on the currently running openchain peer. They are kept while peer.deadletter.enabled is true.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("deadletter")
+	},
+}
+
+var deadLetterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the failed transactions.",
+	Long:  `Lists the failed transactions of the local peer, the oldest failure first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deadLetterList(cmd)
+	},
+}
+
+var deadLetterShowCmd = &cobra.Command{
+	Use:   "show <uuid>",
+	Short: "Show a failed transaction.",
+	Long:  `Prints the failed transaction, the phase it failed in and the error in JSON.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deadLetterShow(cmd, args)
+	},
+}
+
+var deadLetterResubmitCmd = &cobra.Command{
+	Use:   "resubmit <uuid>",
+	Short: "Submit a failed transaction again.",
+	Long: `Submits a failed transaction again and removes it from the dead letters. Transactions that failed to
+execute are resubmitted with a new UUID, which is printed; signed ones must be signed again by their client.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deadLetterResubmit(cmd, args)
+	},
+}
+
+var deadLetterPurgeCmd = &cobra.Command{
+	Use:   "purge [uuid...]",
+	Short: "Delete failed transactions.",
+	Long:  `Deletes the listed failed transactions, or all of them with --all.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return deadLetterPurge(cmd, args)
+	},
+}
+
+var blockFileCmd = &cobra.Command{
+	Use:   "blockfile",
+	Short: "Block files of the openchain peer.",
+	Long: `Inspect the block files holding the blocks of a ledger kept with ledger.blockchain.storage set to file,
+and rebuild the locations of the blocks in the DB from them. The peer must not be running.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("blockfile")
+	},
+}
+
+var blockFileInspectCmd = &cobra.Command{
+	Use:   "inspect [directory]",
+	Short: "Inspect block files.",
+	Long: `Lists the records of the block files of the directory, or of the chain given by --chain, with their
+location, block number, size and checksum status, and exits with an error if any record is corrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return blockFileInspect(args)
+	},
+}
+
+var blockFileReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the block locations from the block files.",
+	Long: `Scans the block files of the chain given by --chain and rewrites the locations of its blocks in the DB.
+Corrupted records are skipped, and the command fails if a block of the blockchain has no valid record.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return blockFileReindex()
+	},
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Verify and export the audit log of an openchain peer.",
+	Long: `Reads the audit log of the openchain peer, given by peer.audit.file or kept in peer.fileSystemPath, which
+records deployments, configuration changes, logins and admin commands in a hash chain.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("audit")
+	},
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify [file]",
+	Short: "Verify the hash chain of the audit log.",
+	Long: `Checks the hash chain of the audit log of the peer, or of an exported audit log, and prints the number of
+records and the hash of the last one. Exits with an error if a record was changed or removed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return auditVerify(args)
+	},
+}
+
+var auditExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the audit log.",
+	Long: `Copies the audit log of the peer to a new file, verifying its hash chain, and prints the number of records
+and the hash of the last one. Keeping the hash lets a later export be checked for removed records.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return auditExport(args)
+	},
+}
+
+var keyStoreCmd = &cobra.Command{
+	Use:   "keystore",
+	Short: "Local crypto keystores of openchain.",
+	Long: `Manages the keystores holding the enrollment keys of the clients, peers and validators in
+peer.fileSystemPath, encrypted at rest under the passphrase unlocked with security.keystore.unlock.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("keystore")
+	},
+}
+
+var keyStoreEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the keys of existing keystores.",
+	Long: `Encrypts the keys kept in the clear by the keystores created before security.keystore.unlock was set,
+under its passphrase, and prints the number of keys encrypted. The peer and the clients must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return keyStoreEncrypt()
+	},
+}
+
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Inspect the ledger of a stopped openchain peer.",
+	Long: `Reads the ledger from the data directory of a stopped openchain peer, given by --path or
+peer.fileSystemPath, without starting the peer: print block headers, dump transactions, show state and verify
+the chain hashes.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		openchain.LoggingInit("ledger")
+		return openOfflineLedger()
+	},
+}
+
+var ledgerBlocksCmd = &cobra.Command{
+	Use:   "blocks [first block] [last block]",
+	Short: "Print block headers.",
+	Long: `Prints the number, hash, previous block hash, state hash, timestamp and number of transactions of the
+blocks from the first to the last block, all the blocks by default.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerBlocks(args)
+	},
+}
+
+var ledgerTxCmd = &cobra.Command{
+	Use:   "tx <uuid>",
+	Short: "Dump a transaction.",
+	Long:  `Prints the committed transaction with the UUID in JSON.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerTx(args)
+	},
+}
+
+var ledgerStateCmd = &cobra.Command{
+	Use:   "state <chaincode> <key>",
+	Short: "Show the state of a key.",
+	Long:  `Prints the committed value of the key in the state of the chaincode, as text and in hexadecimal.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerState(args)
+	},
+}
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the chain hashes.",
+	Long: `Checks that each block holds the hash of the block before it and that the state hash of the last block
+matches the state, and exits with an error otherwise.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerVerify()
+	},
+}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark an openchain peer.",
+	Long: fmt.Sprintf(`Deploys a sample %s, unless --name is given, and drives a mix of invokes and queries against it at a
+target rate through the currently running openchain peer. The latency of an invoke runs from its submission until
+the event hub of a validator reports it committed. Reports the latency percentiles and the commit throughput.`, chainFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("bench")
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBench()
+	},
+}
+
+var faultCmd = &cobra.Command{
+	Use:   "fault",
+	Short: "Fault injection on the openchain peer.",
+	Long: `Inject faults into the currently running openchain peer to test its resilience: delay or drop the consensus
+messages it sends, end the streams of its chaincodes and stall or fail its ledger commits. Faults can only be injected
+while peer.faultInjection.enabled is set.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("fault")
+	},
+}
+
+var faultInjectCmd = &cobra.Command{
+	Use:   "inject <consensus|chaincode|commit>",
+	Short: "Inject a fault.",
+	Long: `Delays by --delay, then fails if --fail is set, the consensus messages sent to the peer named by --target,
+the messages of the chaincode named by --target or the commits to the chain with the ID given by --target. Without
+--target the fault applies to all of them. The fault skips the first --skip operations, then applies to --count
+operations, or to all of them if --count is 0.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return faultInject(args)
+	},
+}
+
+var faultListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the faults being injected.",
+	Long:  `Lists the faults being injected, with the operations they have left to skip and to apply to.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return faultList()
+	},
+}
+
+var faultClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Stop injecting faults.",
+	Long:  `Stops injecting all the faults.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return faultClear()
+	},
+}
+
+var loggingCmd = &cobra.Command{
+	Use:   "logging",
+	Short: "Logging levels of the openchain peer.",
+	Long:  `Get and set the logging levels of the modules of the currently running openchain peer, eg. chaincode, consensus, ledger or peer.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		openchain.LoggingInit("logging")
+	},
+}
+
+var loggingGetLevelCmd = &cobra.Command{
+	Use:   "getlevel [module]",
+	Short: "Get the logging level of a module.",
+	Long:  `Gets the logging level of a module of the peer, or the default level if no module is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loggingGetLevel(args)
+	},
+}
+
+var loggingSetLevelCmd = &cobra.Command{
+	Use:   "setlevel <module> <level>",
+	Short: "Set the logging level of a module.",
+	Long: `Sets the logging level of a module of the peer without restarting it. The level is kept when the peer
+restarts. The level "default" makes the module log at the default level again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return loggingSetLevel(args)
+	},
+}
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login user on CLI.",
@@ -135,16 +586,56 @@ var vmPrimeCmd = &cobra.Command{
 
 // Chaincode-related variables.
 var (
-	chaincodeLang     string
-	chaincodeCtorJSON string
-	chaincodePath     string
-	chaincodeName     string
-	chaincodeDevMode  bool
-	chaincodeUsr      string
-	chaincodeQueryRaw bool
-	chaincodeQueryHex bool
+	chaincodeLang       string
+	chaincodeCtorJSON   string
+	chaincodePath       string
+	chaincodeName       string
+	chaincodeDevMode    bool
+	chaincodeUsr        string
+	chaincodeQueryRaw   bool
+	chaincodeQueryHex   bool
+	chaincodeIdemKey    string
+	chaincodeWatch      bool
+	chaincodeVerifyRuns int
+	chaincodeKeyHints   []string
+	chaincodeCalleeKeys []string
+	chaincodePriority   uint32
+	chaincodeCtorFile   string
+	chaincodeChainID    string
+	chaincodeOutput     string
+	chaincodeWait       bool
+	chaincodeTimeout    time.Duration
+	chaincodeEventsAddr string
+	chaincodeTraceOff   bool
+	chaincodeLogsTail   uint32
+	chaincodeLogsSince  time.Duration
 )
 
+var (
+	adminID           string
+	checkpointConfirm bool
+	checkpointBlock   uint64
+	nodeDrainTimeout  uint64
+	deadLetterAll     bool
+	blockFileChainID  string
+	ledgerPath        string
+	ledgerChainID     string
+	replayChainID     string
+	benchConfig       bench.Config
+	benchEventsAddr   string
+	faultSpec         pb.Fault
+)
+
+// faultPoints are the fault points named by the fault command
+var faultPoints = map[string]pb.Fault_Point{
+	"consensus": pb.Fault_CONSENSUS_MESSAGE,
+	"chaincode": pb.Fault_CHAINCODE_STREAM,
+	"commit":    pb.Fault_LEDGER_COMMIT,
+}
+
+// offlineLedger is the ledger opened by the ledger commands
+var offlineLedger *ledger.Ledger
+
 var chaincodeCmd = &cobra.Command{
 	Use:   chainFuncName,
 	Short: fmt.Sprintf("%s specific commands.", chainFuncName),
@@ -159,7 +650,7 @@ var chaincodePathArgumentSpecifier = fmt.Sprintf("%s_PATH", strings.ToUpper(chai
 var chaincodeDeployCmd = &cobra.Command{
 	Use:       "deploy",
 	Short:     fmt.Sprintf("Deploy the specified %s to the network.", chainFuncName),
-	Long:      fmt.Sprintf(`Deploy the specified %s to the network.`, chainFuncName),
+	Long:      fmt.Sprintf(`Deploy the specified %s to the network. The deployment completes in the background, use the status command to follow its progress.`, chainFuncName),
 	ValidArgs: []string{"1"},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return chaincodeDeploy(cmd, args)
@@ -186,6 +677,51 @@ var chaincodeQueryCmd = &cobra.Command{
 	},
 }
 
+var chaincodeStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: fmt.Sprintf("Show the deployment status of the specified %s.", chainFuncName),
+	Long:  fmt.Sprintf(`Show the deployment status of the specified %s.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeStatus(cmd, args)
+	},
+}
+
+var chaincodeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: fmt.Sprintf("List the %ss known to the peer.", chainFuncName),
+	Long:  fmt.Sprintf(`List the %ss deployed on the ledger, built into or connected to the peer, with the hash of their code, the status of their deployment and container, and the state of their handler.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeList(cmd, args)
+	},
+}
+
+var chaincodeVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: fmt.Sprintf("Check whether the invocation of the specified %s is deterministic.", chainFuncName),
+	Long:  fmt.Sprintf(`Execute an invocation of the specified %s several times on the state of a validating peer, keeping none of its changes, and report whether the executions returned different results or changed the state differently. The executions are scheduled on different numbers of threads, reseed the global math/rand source and are spaced by more than a second; chaincode only diverging on clocks further apart, or on the file system or the network, is not caught.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeVerify(cmd, args)
+	},
+}
+
+var chaincodeTraceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: fmt.Sprintf("Trace the messages exchanged with the specified %s.", chainFuncName),
+	Long:  fmt.Sprintf(`Write every message exchanged between the local peer and the specified %s, and every state change of its handler, to a file of the peer until the trace is turned off. The payloads of confidential %ss are left out.`, chainFuncName, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeTrace(cmd, args)
+	},
+}
+
+var chaincodeLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: fmt.Sprintf("Show the output of the container of the specified %s.", chainFuncName),
+	Long:  fmt.Sprintf(`Show what the container of the specified %s wrote to stdout and stderr, as captured by the local peer, including the output of earlier launches which has not been rotated away.`, chainFuncName),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeLogs(cmd, args)
+	},
+}
+
 func main() {
 	runtime.GOMAXPROCS(2)
 
@@ -232,6 +768,72 @@ func main() {
 	mainCmd.AddCommand(stopCmd)
 	mainCmd.AddCommand(loginCmd)
 
+	nodeDrainCmd.Flags().Uint64Var(&nodeDrainTimeout, "timeout", 60, "Seconds to wait for the transactions in flight")
+	for _, cmd := range []*cobra.Command{stopCmd, nodePauseCmd, nodeResumeCmd, nodeDrainCmd, nodeRotateIdentityCmd, checkpointTagCmd, checkpointRollbackCmd, backupCmd, replayCmd, faultInjectCmd, faultClearCmd, loggingSetLevelCmd} {
+		cmd.Flags().StringVarP(&adminID, "username", "u", "", "Enrollment ID of the admin, logged in locally, signing the request when the peer enforces admin authorization")
+	}
+	nodeCmd.AddCommand(nodeStatusCmd)
+	nodeCmd.AddCommand(nodePauseCmd)
+	nodeCmd.AddCommand(nodeResumeCmd)
+	nodeCmd.AddCommand(nodeDrainCmd)
+	nodeCmd.AddCommand(nodeRotateIdentityCmd)
+	mainCmd.AddCommand(nodeCmd)
+
+	checkpointRollbackCmd.Flags().BoolVar(&checkpointConfirm, "confirm", false, "Confirm that the blocks after the checkpoint are discarded")
+	checkpointRollbackCmd.Flags().Uint64Var(&checkpointBlock, "block", 0, "Number of the block tagged by the checkpoint")
+	checkpointCmd.AddCommand(checkpointTagCmd)
+	checkpointCmd.AddCommand(checkpointListCmd)
+	checkpointCmd.AddCommand(checkpointRollbackCmd)
+	mainCmd.AddCommand(checkpointCmd)
+	mainCmd.AddCommand(backupCmd)
+	auditCmd.AddCommand(auditVerifyCmd)
+	auditCmd.AddCommand(auditExportCmd)
+	mainCmd.AddCommand(auditCmd)
+	keyStoreCmd.AddCommand(keyStoreEncryptCmd)
+	mainCmd.AddCommand(keyStoreCmd)
+	replayCmd.Flags().StringVar(&replayChainID, "chain", "", "ID of the chain, the default chain if empty")
+	mainCmd.AddCommand(replayCmd)
+	mainCmd.AddCommand(genesisCmd)
+	networkCmd.AddCommand(networkUpdateCmd)
+	mainCmd.AddCommand(networkCmd)
+	deadLetterPurgeCmd.Flags().BoolVar(&deadLetterAll, "all", false, "Delete all the failed transactions")
+	deadLetterCmd.AddCommand(deadLetterListCmd)
+	deadLetterCmd.AddCommand(deadLetterShowCmd)
+	deadLetterCmd.AddCommand(deadLetterResubmitCmd)
+	deadLetterCmd.AddCommand(deadLetterPurgeCmd)
+	mainCmd.AddCommand(deadLetterCmd)
+	blockFileCmd.PersistentFlags().StringVar(&blockFileChainID, "chain", "", "ID of the chain, the default chain if empty")
+	blockFileCmd.AddCommand(blockFileInspectCmd)
+	blockFileCmd.AddCommand(blockFileReindexCmd)
+	mainCmd.AddCommand(blockFileCmd)
+	ledgerCmd.PersistentFlags().StringVar(&ledgerPath, "path", "", "Data directory of the peer, peer.fileSystemPath if empty")
+	ledgerCmd.PersistentFlags().StringVar(&ledgerChainID, "chain", "", "ID of the chain, the default chain if empty")
+	ledgerCmd.AddCommand(ledgerBlocksCmd)
+	ledgerCmd.AddCommand(ledgerTxCmd)
+	ledgerCmd.AddCommand(ledgerStateCmd)
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	mainCmd.AddCommand(ledgerCmd)
+	benchCmd.Flags().StringVarP(&benchConfig.Chaincode, "name", "n", "", fmt.Sprintf("Name of a deployed sample %s, deployed by the benchmark if empty", chainFuncName))
+	benchCmd.Flags().Float64Var(&benchConfig.Rate, "rate", 10, "Transactions started per second")
+	benchCmd.Flags().Float64Var(&benchConfig.QueryRatio, "query-ratio", 0.5, "Fraction of the transactions that are queries")
+	benchCmd.Flags().DurationVar(&benchConfig.Duration, "duration", 30*time.Second, "How long transactions are started for")
+	benchCmd.Flags().IntVar(&benchConfig.MaxInFlight, "max-in-flight", 100, "Maximum of transactions in progress, beyond which transactions are skipped")
+	benchCmd.Flags().DurationVar(&benchConfig.Timeout, "timeout", 30*time.Second, "How long an invoke is waited for to be committed, and the sample deployed")
+	benchCmd.Flags().StringVar(&benchEventsAddr, "events-address", "", "Address of the event hub of a validator, peer.validator.events.address by default")
+	mainCmd.AddCommand(benchCmd)
+	faultInjectCmd.Flags().StringVar(&faultSpec.Target, "target", "", "Peer, chaincode or chain the fault applies to, all of them if empty")
+	faultInjectCmd.Flags().Uint64Var(&faultSpec.Delay, "delay", 0, "Delay of the operations, in milliseconds")
+	faultInjectCmd.Flags().BoolVar(&faultSpec.Fail, "fail", false, "Fail the operations after the delay")
+	faultInjectCmd.Flags().Uint32Var(&faultSpec.Skip, "skip", 0, "Number of operations to skip before applying the fault")
+	faultInjectCmd.Flags().Uint32Var(&faultSpec.Count, "count", 0, "Number of operations to apply the fault to, 0 for all")
+	faultCmd.AddCommand(faultInjectCmd)
+	faultCmd.AddCommand(faultListCmd)
+	faultCmd.AddCommand(faultClearCmd)
+	mainCmd.AddCommand(faultCmd)
+	loggingCmd.AddCommand(loggingGetLevelCmd)
+	loggingCmd.AddCommand(loggingSetLevelCmd)
+	mainCmd.AddCommand(loggingCmd)
+
 	vmCmd.AddCommand(vmPrimeCmd)
 	mainCmd.AddCommand(vmCmd)
 
@@ -240,13 +842,33 @@ func main() {
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodePath, "path", "p", undefinedParamValue, fmt.Sprintf("Path to %s", chainFuncName))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeName, "name", "n", undefinedParamValue, fmt.Sprintf("Name of the chaincode returned by the deploy transaction"))
 	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeUsr, "username", "u", undefinedParamValue, fmt.Sprintf("Username for chaincode operations when security is enabled"))
+	chaincodeCmd.PersistentFlags().StringVar(&chaincodeChainID, "chain", "", fmt.Sprintf("ID of the chain to deploy the %s on or to invoke or query it on, the default chain if empty", chainFuncName))
+	chaincodeCmd.PersistentFlags().StringVarP(&chaincodeCtorFile, "ctor-file", "f", "", fmt.Sprintf("File holding the constructor message for the %s in JSON or YAML format. Incompatible with --ctor", chainFuncName))
 
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryRaw, "raw", "r", false, "If true, output the query value as raw bytes, otherwise format as a printable string")
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryHex, "hex", "x", false, "If true, output the query value byte array in hexadecimal. Incompatible with --raw")
+	chaincodeQueryCmd.Flags().StringVarP(&chaincodeOutput, "output", "o", "", "Format of the query value: raw, hex, string or json (indented). Replaces --raw and --hex")
+	chaincodeStatusCmd.Flags().BoolVarP(&chaincodeWatch, "watch", "w", false, "If true, print every status change until the deployment is ready or has failed")
+	chaincodeVerifyCmd.Flags().IntVarP(&chaincodeVerifyRuns, "runs", "r", 2, "Number of times to execute the invocation")
+	chaincodeInvokeCmd.Flags().StringVarP(&chaincodeIdemKey, "idempotency-key", "k", "", "Key identifying this invocation; retrying with the same key does not invoke the chaincode again")
+	chaincodeInvokeCmd.Flags().StringSliceVar(&chaincodeKeyHints, "key-hints", nil, "Comma separated state keys the invocation reads or writes, which lets validators schedule it alongside other invocations")
+	chaincodeInvokeCmd.Flags().StringSliceVar(&chaincodeCalleeKeys, "callee-key-hints", nil, "Comma separated state keys of the chaincodes the invocation invokes, as <chaincode name>:<key>")
+	chaincodeInvokeCmd.Flags().Uint32Var(&chaincodePriority, "priority", 0, "Priority of the transaction, 0 for bulk workload; higher priorities are executed first within the bounds of the priority policy")
+	chaincodeInvokeCmd.Flags().BoolVarP(&chaincodeWait, "wait", "w", false, "If true, wait until the transaction is committed and fail if it is rejected")
+	chaincodeInvokeCmd.Flags().DurationVarP(&chaincodeTimeout, "timeout", "t", 30*time.Second, "How long --wait waits for the transaction to be committed")
+	chaincodeTraceCmd.Flags().BoolVar(&chaincodeTraceOff, "off", false, "If true, turn the trace off")
+	chaincodeLogsCmd.Flags().Uint32Var(&chaincodeLogsTail, "tail", 0, "Number of lines to show from the end of the output, all of them if 0")
+	chaincodeLogsCmd.Flags().DurationVar(&chaincodeLogsSince, "since", 0, "Only show the lines written within this duration, eg. 10m, all of them if 0")
+	chaincodeInvokeCmd.Flags().StringVar(&chaincodeEventsAddr, "events-address", "", "Address of the event hub of a validator notifying --wait of the commit, peer.validator.events.address by default")
 
 	chaincodeCmd.AddCommand(chaincodeDeployCmd)
 	chaincodeCmd.AddCommand(chaincodeInvokeCmd)
 	chaincodeCmd.AddCommand(chaincodeQueryCmd)
+	chaincodeCmd.AddCommand(chaincodeStatusCmd)
+	chaincodeCmd.AddCommand(chaincodeListCmd)
+	chaincodeCmd.AddCommand(chaincodeVerifyCmd)
+	chaincodeCmd.AddCommand(chaincodeTraceCmd)
+	chaincodeCmd.AddCommand(chaincodeLogsCmd)
 
 	mainCmd.AddCommand(chaincodeCmd)
 
@@ -283,13 +905,55 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 		}
 
 		grpcServer = grpc.NewServer(opts...)
-		ehServer := producer.NewOpenchainEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
+		ehServer := producer.NewOpenchainEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"), access.NewGuard(access.LoadConfig("peer.validator.events")))
 		pb.RegisterOpenchainEventsServer(grpcServer, ehServer)
 	}
 	return lis, grpcServer, err
 }
 
+// watchConfig reloads the settings of the config file which can change while
+// the peer runs when the file changes or the peer receives a SIGHUP
+func watchConfig() {
+	watcher, err := config.NewWatcher(viper.ConfigFileUsed(), func(key string, value interface{}) {
+		audit.Record(audit.CategoryConfig, "config file", "set", fmt.Sprintf("%s=%v", key, value), nil)
+		viper.Set(key, value)
+	})
+	if err != nil {
+		logger.Error("Not watching the config file: %s", err)
+		return
+	}
+	watcher.Register("logging", func(key string, value interface{}) error {
+		return openchain.ValidateLoggingSpec(cast.ToString(value))
+	}, func(key string, value interface{}) {
+		openchain.LoggingInit("peer")
+	})
+	watcher.Register("peer.validator.events.buffersize", func(key string, value interface{}) error {
+		if size, err := cast.ToIntE(value); err != nil || size < 0 {
+			return fmt.Errorf("Buffer size must be a non-negative integer")
+		}
+		return nil
+	}, func(key string, value interface{}) {
+		producer.SetBufferSize(uint(cast.ToInt(value)))
+	})
+	watcher.Register("peer.validator.events.timeout", config.ValidateInt, func(key string, value interface{}) {
+		producer.SetTimeout(cast.ToInt(value))
+	})
+	go watcher.Watch(viper.GetDuration("peer.configwatch.interval"))
+}
+
 func serve(args []string) error {
+	if viper.GetBool("peer.audit.enabled") {
+		auditLog, err := audit.Open(auditLogPath())
+		if err != nil {
+			return err
+		}
+		audit.SetDefault(auditLog)
+	}
+
+	if err := genesis.ApplyNetworkDefinition(); err != nil {
+		return err
+	}
+
 	peerEndpoint, err := peer.GetPeerEndpoint()
 	if err != nil {
 		err = fmt.Errorf("Failed to get Peer Endpoint: %s", err)
@@ -313,6 +977,8 @@ func serve(args []string) error {
 		grpclog.Fatalf("Failed to create ehub server: %v", err)
 	}
 
+	watchConfig()
+
 	if chaincodeDevMode {
 		logger.Info("Running in chaincode development mode")
 		logger.Info("Set consensus to NOOPS and user starts chaincode")
@@ -342,6 +1008,8 @@ func serve(args []string) error {
 
 	grpcServer := grpc.NewServer(opts...)
 
+	crypto.AddRenewalHandler(sendEnrollmentRenewalEvent)
+
 	var peerServer *peer.PeerImpl
 
 	if viper.GetBool("peer.validator.enabled") {
@@ -363,7 +1031,23 @@ func serve(args []string) error {
 	pb.RegisterPeerServer(grpcServer, peerServer)
 
 	// Register the Admin server
-	pb.RegisterAdminServer(grpcServer, openchain.NewAdminServer())
+	pb.RegisterAdminServer(grpcServer, openchain.NewAdminServerWithPeer(peerServer))
+
+	// Node management is only served on the local admin address, without
+	// TLS as it is only reachable from the host
+	localAdminAddr, err := openchain.LocalAdminAddress()
+	if err != nil {
+		return err
+	}
+	localAdminServer := grpc.NewServer()
+	pb.RegisterAdminServer(localAdminServer, openchain.NewLocalAdminServer(peerServer))
+	if localAdminAddr != "" {
+		localAdminLis, err := net.Listen("tcp", localAdminAddr)
+		if err != nil {
+			grpclog.Fatalf("Failed to listen on the local admin address: %v", err)
+		}
+		go localAdminServer.Serve(localAdminLis)
+	}
 
 	// Register ChaincodeSupport server...
 	// TODO : not the "DefaultChain" ... we have to revisit when we do multichain
@@ -378,7 +1062,10 @@ func serve(args []string) error {
 	registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
 
 	// Register Devops server
-	serverDevops := openchain.NewDevopsServer(peerServer)
+	serverDevops, err := openchain.NewDevopsServer(peerServer)
+	if err != nil {
+		return err
+	}
 	pb.RegisterDevopsServer(grpcServer, serverDevops)
 
 	// Register the ServerOpenchain server
@@ -390,82 +1077,921 @@ func serve(args []string) error {
 
 	pb.RegisterOpenchainServer(grpcServer, serverOpenchain)
 
+	registerHealthChecks(peerServer)
+
 	// Create and register the REST service if configured
 	if viper.GetBool("rest.enabled") {
 		go rest.StartOpenchainRESTServer(serverOpenchain, serverDevops)
 	}
-
-	rootNode, err := openchain.GetRootNode()
+
+	// Serve the metrics if configured
+	go metrics.StartServer()
+
+	rootNode, err := openchain.GetRootNode()
+	if err != nil {
+		grpclog.Fatalf("Failed to get peer.discovery.rootnode valey: %s", err)
+	}
+
+	logger.Info("Starting peer with id=%s, network id=%s, address=%s, discovery.rootnode=%s, validator=%v",
+		peerEndpoint.ID, viper.GetString("peer.networkId"),
+		peerEndpoint.Address, rootNode, viper.GetBool("peer.validator.enabled"))
+
+	// Start the grpc server. Done in a goroutine so we can deploy the
+	// genesis block if needed. Transactions are refused until the startup
+	// stages are done.
+	startup := newPeerStartup(peerServer)
+	startup.Begin()
+	serve := make(chan error)
+	go func() {
+		var grpcErr error
+		if grpcErr = grpcServer.Serve(lis); grpcErr != nil {
+			grpcErr = fmt.Errorf("grpc server exited with error: %s", grpcErr)
+		} else {
+			logger.Info("grpc server exited")
+		}
+		serve <- grpcErr
+	}()
+
+	//start the event hub server, consumers learn when the peer is ready
+	if ehubGrpcServer != nil && ehubLis != nil {
+		go ehubGrpcServer.Serve(ehubLis)
+	}
+
+	started := time.Now()
+	if err = startup.Run(); err != nil {
+		return err
+	}
+	sendPeerReadyEvent(peerEndpoint.ID.Name, time.Since(started))
+
+	// Serve the gRPC services over HTTP/JSON if configured, once they are up
+	if viper.GetBool("gateway.enabled") {
+		go gateway.StartGatewayServer()
+	}
+
+	// Shut down gracefully on SIGTERM or interrupt, so that restarts don't
+	// strand transactions
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
+
+	// Block until grpc server exits or the peer is stopped
+	select {
+	case err = <-serve:
+		return err
+	case sig := <-stop:
+		logger.Info("Received %s, shutting down", sig)
+		shutdownPeer(grpcServer, localAdminServer)
+		return nil
+	}
+}
+
+// newPeerStartup returns the stages the peer goes through before it accepts
+// transactions
+func newPeerStartup(peerServer *peer.PeerImpl) *peer.Startup {
+	startup := peer.NewStartup()
+	if viper.GetBool("security.enabled") {
+		// The peer enrolled when it was created
+		startup.Add("membership", func() error {
+			if peerServer.GetSecHelper() == nil {
+				return errors.New("Peer is not enrolled with the membership services")
+			}
+			return nil
+		})
+	}
+	if !viper.GetBool("peer.validator.enabled") {
+		return startup
+	}
+	// Deploy the genesis block if needed.
+	startup.Add("ledger", genesis.MakeGenesis)
+	startup.Add("chaincodes", func() error {
+		// Start the system chaincodes, they run in-process on every validator
+		if err := system_chaincode.RegisterSysCCs(); err != nil {
+			return fmt.Errorf("Error registering system chaincodes: %s", err)
+		}
+		if err := chaincode.DeploySysCCs(context.Background(), chaincode.GetChain(chaincode.DefaultChain)); err != nil {
+			return err
+		}
+		// Take up the network parameters set by configuration transactions
+		return netconfig.Update()
+	}, "ledger")
+	startup.Add("consensus", func() error {
+		return waitForConsensus(peerServer, viper.GetDuration("peer.startup.consensusTimeout"))
+	}, "chaincodes")
+	return startup
+}
+
+// waitForConsensus waits until enough validators are connected for
+// consensus and the replica is not catching up by state transfer, or until
+// the timeout expires
+func waitForConsensus(peerServer *peer.PeerImpl, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		validators, err := connectedValidators(peerServer)
+		if err != nil {
+			return err
+		}
+		err = controller.CheckHealth(validators)
+		if err == nil && controller.Syncing() {
+			err = errors.New("Catching up by state transfer")
+		}
+		if err == nil {
+			return nil
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			logger.Warning("Consensus has not caught up after %s, accepting transactions anyway: %s", timeout, err)
+			return nil
+		}
+		logger.Debug("Waiting for consensus: %s", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// connectedValidators returns the number of validators the peer is connected to
+func connectedValidators(peerServer *peer.PeerImpl) (int, error) {
+	peers, err := peerServer.GetPeers()
+	if err != nil {
+		return 0, err
+	}
+	validators := 0
+	for _, peerEndpoint := range peers.Peers {
+		if peerEndpoint.Type == pb.PeerEndpoint_VALIDATOR {
+			validators++
+		}
+	}
+	return validators, nil
+}
+
+// shutdownPeer stops the peer within peer.shutdown.timeout. New transactions
+// are refused, those in flight are given the time to complete, the ledger is
+// flushed and the chaincode containers are stopped before the grpc server.
+func shutdownPeer(grpcServer *grpc.Server, localAdminServer *grpc.Server) {
+	timeout := viper.GetDuration("peer.shutdown.timeout")
+	deadline := time.Now().Add(timeout)
+
+	if inFlight := peer.DrainIntake(timeout); inFlight > 0 {
+		logger.Warning("Shutting down with %d transactions still in flight", inFlight)
+	}
+	if err := ledger.FlushAll(deadline.Sub(time.Now())); err != nil {
+		logger.Warning("Error flushing the ledger: %s", err)
+	}
+	if chain := chaincode.GetChain(chaincode.DefaultChain); chain != nil {
+		if err := chain.Shutdown(context.Background(), deadline.Sub(time.Now())); err != nil {
+			logger.Warning("Error shutting down chaincodes: %s", err)
+		}
+	}
+	localAdminServer.Stop()
+	grpcServer.Stop()
+	logger.Info("Peer stopped")
+}
+
+// sendPeerReadyEvent tells the event consumers that the peer accepts
+// transactions
+func sendPeerReadyEvent(peerID string, startup time.Duration) {
+	ready := &pb.PeerReady{PeerID: peerID, StartupMillis: uint64(startup / time.Millisecond)}
+	if ledger, err := ledger.GetLedger(); err == nil {
+		ready.BlockHeight = ledger.GetBlockchainSize()
+	}
+	if err := producer.Send(producer.CreatePeerReadyEvent(ready)); err != nil {
+		logger.Warning("Error sending the ready event: %s", err)
+	}
+}
+
+// sendEnrollmentRenewalEvent tells the event consumers that an enrollment
+// certificate of the peer, or of a client it serves, has been renewed or is
+// about to expire without having been renewed
+func sendEnrollmentRenewalEvent(renewal *crypto.EnrollmentRenewal) {
+	event := &pb.EnrollmentRenewal{EnrollmentID: renewal.EnrollmentID, Renewed: renewal.Err == nil, NotAfter: renewal.NotAfter.Unix()}
+	if renewal.Err != nil {
+		event.ErrorMsg = renewal.Err.Error()
+	}
+	if err := producer.Send(producer.CreateEnrollmentRenewalEvent(event)); err != nil {
+		logger.Warning("Error sending the renewal event: %s", err)
+	}
+}
+
+func status() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = fmt.Errorf("Error trying to connect to local peer:", err)
+		return
+	}
+
+	serverClient := pb.NewAdminClient(clientConn)
+
+	status, err := serverClient.GetStatus(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return
+	}
+	fmt.Println(status)
+	return nil
+}
+
+func stop() (err error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		err = fmt.Errorf("Error trying to connect to local peer:", err)
+		return
+	}
+
+	ctx, err := adminContext(openchain.AdminActionStop, "peer")
+	if err != nil {
+		return
+	}
+
+	logger.Info("Stopping peer...")
+	serverClient := pb.NewAdminClient(clientConn)
+
+	status, err := serverClient.StopServer(ctx, &google_protobuf.Empty{})
+	if err != nil {
+		return
+	}
+	fmt.Println(status)
+	return nil
+}
+
+func printNodeStatus(status *pb.ServerStatus) {
+	fmt.Printf("Status: %s\n", status.Status)
+	if len(status.StartupStages) > 0 {
+		fmt.Printf("Startup stages in progress: %s\n", strings.Join(status.StartupStages, ", "))
+	}
+	fmt.Printf("Transactions in flight: %d\n", status.InFlight)
+	fmt.Printf("Block height: %d\n", status.BlockHeight)
+}
+
+// nodeRequest sends a node management request to the local admin address
+// of the peer, with the credentials of the admin for the action if any, and
+// prints the status of the peer it returns
+func nodeRequest(action string, request func(admin pb.AdminClient, ctx context.Context) (*pb.ServerStatus, error)) error {
+	address, err := openchain.LocalAdminAddress()
+	if err != nil {
+		return err
+	}
+	if address == "" {
+		return errors.New("Node management is disabled, peer.admin.localAddress is not set")
+	}
+	clientConn, err := grpc.Dial(address, grpc.WithInsecure(), grpc.WithTimeout(3*time.Second), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	defer clientConn.Close()
+	ctx := context.Background()
+	if action != "" {
+		if ctx, err = adminContext(action, "peer"); err != nil {
+			return err
+		}
+	}
+	status, err := request(pb.NewAdminClient(clientConn), ctx)
+	if err != nil {
+		return err
+	}
+	printNodeStatus(status)
+	return nil
+}
+
+// adminContext returns the context of the admin request for the action on
+// the target of the local peer, carrying the credentials of the admin given
+// with --username
+func adminContext(action, target string) (context.Context, error) {
+	if adminID == "" {
+		return context.Background(), nil
+	}
+	creds, err := openchain.NewAdminCredentials(adminID, viper.GetString("peer.id"), action, target)
+	if err != nil {
+		return nil, fmt.Errorf("Error making the admin credentials of %s: %s", adminID, err)
+	}
+	return creds.NewContext(context.Background()), nil
+}
+
+func nodeRotateIdentity() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionRotate, "peer")
+	if err != nil {
+		return err
+	}
+	rotation, err := pb.NewAdminClient(clientConn).RotateIdentity(ctx, &google_protobuf.Empty{})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Re-enrolled %s\n", rotation.EnrollmentID)
+	fmt.Printf("Previous PKI ID: %x\n", rotation.PreviousPkiID)
+	fmt.Printf("New PKI ID:      %x\n", rotation.PkiID)
+	return nil
+}
+
+func checkpointTag(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the checkpoint name as the 1st and only parameter")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionTagCheckpoint, args[0])
+	if err != nil {
+		return err
+	}
+	checkpoint, err := pb.NewAdminClient(clientConn).TagCheckpoint(ctx, &pb.Checkpoint{Name: args[0]})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Tagged block %d with checkpoint %s\n", checkpoint.BlockNumber, checkpoint.Name)
+	return nil
+}
+
+func checkpointList() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	list, err := pb.NewAdminClient(clientConn).ListCheckpoints(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return err
+	}
+	for _, checkpoint := range list.Checkpoints {
+		fmt.Printf("%d\t%s\n", checkpoint.BlockNumber, checkpoint.Name)
+	}
+	return nil
+}
+
+func checkpointRollback(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the checkpoint name as the 1st and only parameter")
+	}
+	if !checkpointConfirm || !cmd.Flags().Changed("block") {
+		return fmt.Errorf("Rolling back discards blocks, confirm with both --confirm and --block set to the number of the block tagged by checkpoint %s", args[0])
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionRollback, args[0])
+	if err != nil {
+		return err
+	}
+	logger.Warning("Rolling back ledger to checkpoint %s at block %d", args[0], checkpointBlock)
+	rollback := &pb.CheckpointRollback{Name: args[0], BlockNumber: checkpointBlock, Confirm: checkpointConfirm}
+	checkpoint, err := pb.NewAdminClient(clientConn).RollbackToCheckpoint(ctx, rollback)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back to block %d tagged by checkpoint %s\n", checkpoint.BlockNumber, checkpoint.Name)
+	return nil
+}
+
+// auditLogPath returns the file of the audit log of the peer
+func auditLogPath() string {
+	if file := viper.GetString("peer.audit.file"); file != "" {
+		return file
+	}
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "audit.log")
+}
+
+func auditVerify(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Must supply at most the file of the audit log as the 1st parameter")
+	}
+	path := auditLogPath()
+	if len(args) == 1 {
+		path = args[0]
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	count, head, err := audit.Verify(file)
+	if err != nil {
+		return fmt.Errorf("Audit log %s is not intact: %s", path, err)
+	}
+	fmt.Printf("Verified %d records of %s, last hash %s\n", count, path, head)
+	return nil
+}
+
+func auditExport(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the file to export to as the 1st and only parameter")
+	}
+	count, head, err := audit.Export(auditLogPath(), args[0])
+	if err != nil {
+		return fmt.Errorf("Error exporting the audit log: %s", err)
+	}
+	fmt.Printf("Exported %d records to %s, last hash %s\n", count, args[0], head)
+	return nil
+}
+
+func keyStoreEncrypt() error {
+	encrypted, err := crypto.EncryptKeyStores()
+	if err != nil {
+		return fmt.Errorf("Error encrypting the keystores: %s", err)
+	}
+	fmt.Printf("Encrypted %d keys\n", encrypted)
+	return nil
+}
+
+func backup(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the backup path as the 1st and only parameter")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionBackup, args[0])
+	if err != nil {
+		return err
+	}
+	backup, err := pb.NewAdminClient(clientConn).BackupLedger(ctx, &pb.LedgerBackup{Path: args[0]})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %d blocks to %s\n", backup.BlockHeight, backup.Path)
+	fmt.Printf("Last block hash: %x\n", backup.LastBlockHash)
+	fmt.Printf("State hash: %x\n", backup.StateHash)
+	return nil
+}
+
+func showNetworkDefinition(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the network definition file as the 1st and only parameter")
+	}
+	network, err := genesis.LoadNetworkDefinition(args[0])
+	if err != nil {
+		return err
+	}
+	hash, err := network.Hash()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Network: %s\n", network.Name)
+	fmt.Printf("Hash: %x\n", hash)
+	fmt.Printf("Consensus: %s\n", network.Consensus.Plugin)
+	for _, validator := range network.Validators {
+		fmt.Printf("Validator: %s %s\n", validator.ID, validator.Address)
+	}
+	fmt.Printf("Genesis chaincodes: %d\n", len(network.Chaincodes))
+	return nil
+}
+
+func networkUpdate(cmd *cobra.Command, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("Must supply the parameter, the value and the block height as the 1st, 2nd and 3rd parameters")
+	}
+	height, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid block height %s: %s", args[2], err)
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	resp, err := devopsClient.UpdateNetworkConfig(context.Background(), &pb.NetworkConfigUpdate{Parameter: args[0], Value: args[1], EffectiveHeight: height})
+	if err != nil {
+		return fmt.Errorf("Error updating network parameter %s: %s", args[0], err)
+	}
+	fmt.Printf("Configuration transaction %s submitted, %s takes effect at block height %d\n", resp.Msg, args[0], height)
+	return nil
+}
+
+func deadLetterList(cmd *cobra.Command) error {
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	list, err := devopsClient.ListDeadLetters(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return err
+	}
+	for _, letter := range list.DeadLetters {
+		printDeadLetter(letter)
+	}
+	return nil
+}
+
+func deadLetterShow(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the transaction UUID as the 1st and only parameter")
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	letter, err := devopsClient.GetDeadLetter(context.Background(), &pb.DeadLetterID{Uuid: args[0]})
+	if err != nil {
+		return err
+	}
+	letterJSON, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(letterJSON))
+	return nil
+}
+
+func deadLetterResubmit(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the transaction UUID as the 1st and only parameter")
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	resp, err := devopsClient.ResubmitDeadLetter(context.Background(), &pb.DeadLetterID{Uuid: args[0]})
+	if err != nil {
+		return fmt.Errorf("Error resubmitting transaction %s: %s", args[0], err)
+	}
+	fmt.Printf("Resubmitted transaction %s as %s\n", args[0], resp.Msg)
+	return nil
+}
+
+func deadLetterPurge(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !deadLetterAll {
+		return fmt.Errorf("Must supply the UUIDs of the transactions to delete, or --all")
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return err
+	}
+	purged, err := devopsClient.PurgeDeadLetters(context.Background(), &pb.DeadLetterPurge{Uuids: args, All: deadLetterAll})
+	if err != nil {
+		return err
+	}
+	for _, letter := range purged.DeadLetters {
+		printDeadLetter(letter)
+	}
+	fmt.Printf("Deleted %d failed transactions\n", len(purged.DeadLetters))
+	return nil
+}
+
+func printDeadLetter(letter *pb.DeadLetter) {
+	var failed string
+	if ts := letter.Timestamp; ts != nil {
+		failed = time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339)
+	}
+	fmt.Printf("%s\t%s\t%s\t%s\n", letter.Transaction.Uuid, letter.Phase, failed, letter.Error)
+}
+
+func blockFileInspect(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Must supply at most the block files directory as the 1st parameter")
+	}
+	dir := ledger.GetBlockFilesPath(blockFileChainID)
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	var records, corrupted int
+	err := blockfile.Scan(dir, func(record *blockfile.Record) error {
+		records++
+		if record.Err != nil {
+			corrupted++
+			fmt.Printf("%s\t%d\t%d bytes\t%s\n", record.Location, record.BlockNumber, record.Location.Length, record.Err)
+			return nil
+		}
+		block, err := pb.UnmarshallBlock(record.Data)
+		if err != nil {
+			corrupted++
+			fmt.Printf("%s\t%d\t%d bytes\tinvalid block: %s\n", record.Location, record.BlockNumber, record.Location.Length, err)
+			return nil
+		}
+		hash, err := block.GetHash()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\t%d\t%d bytes\tok\t%d transactions\t%x\n", record.Location, record.BlockNumber, record.Location.Length, len(block.Transactions), hash)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d records, %d corrupted\n", records, corrupted)
+	if corrupted > 0 {
+		return fmt.Errorf("Block files [%s] hold %d corrupted records", dir, corrupted)
+	}
+	return nil
+}
+
+func blockFileReindex() error {
+	indexed, err := ledger.RebuildBlockIndex(blockFileChainID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rebuilt the locations of %d blocks\n", indexed)
+	return nil
+}
+
+// openOfflineLedger opens the ledger of a stopped peer. The DB of a running
+// peer is locked, so that it cannot be opened.
+func openOfflineLedger() error {
+	if ledgerPath != "" {
+		viper.Set("peer.fileSystemPath", ledgerPath)
+	}
+	// Blocks must not be archived while the ledger is inspected
+	viper.Set("ledger.archive.enabled", false)
+	exists, err := db.ChainDBExists(ledgerChainID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("No ledger of chain [%s] in [%s]", ledgerChainID, viper.GetString("peer.fileSystemPath"))
+	}
+	offlineLedger, err = ledger.GetChainLedger(ledgerChainID)
+	return err
+}
+
+func ledgerBlocks(args []string) error {
+	if len(args) > 2 {
+		return fmt.Errorf("Must supply at most the first and last block numbers as the 1st and 2nd parameters")
+	}
+	size := offlineLedger.GetBlockchainSize()
+	if size == 0 {
+		fmt.Println("The blockchain has no blocks")
+		return nil
+	}
+	first, last := uint64(0), size-1
+	var err error
+	if len(args) > 0 {
+		if first, err = strconv.ParseUint(args[0], 10, 64); err != nil {
+			return fmt.Errorf("Invalid first block number %s: %s", args[0], err)
+		}
+	}
+	if len(args) > 1 {
+		if last, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			return fmt.Errorf("Invalid last block number %s: %s", args[1], err)
+		}
+	}
+	if last >= size {
+		return fmt.Errorf("Last block number %d is beyond the last block %d", last, size-1)
+	}
+	for blockNumber := first; blockNumber <= last; blockNumber++ {
+		block, err := offlineLedger.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return fmt.Errorf("Error reading block number %d: %s", blockNumber, err)
+		}
+		if block == nil {
+			fmt.Printf("%d\tmissing\n", blockNumber)
+			continue
+		}
+		hash, err := block.GetHash()
+		if err != nil {
+			return err
+		}
+		var created string
+		if ts := block.Timestamp; ts != nil {
+			created = time.Unix(ts.Seconds, int64(ts.Nanos)).UTC().Format(time.RFC3339)
+		}
+		fmt.Printf("%d\t%x\t%x\t%x\t%s\t%d transactions\n", blockNumber, hash, block.PreviousBlockHash, block.StateHash, created, len(block.Transactions))
+	}
+	return nil
+}
+
+func ledgerTx(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the transaction UUID as the 1st and only parameter")
+	}
+	tx, err := offlineLedger.GetTransactionByUUID(args[0])
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fmt.Errorf("No committed transaction with UUID %s", args[0])
+	}
+	txJSON, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(txJSON))
+	return nil
+}
+
+func ledgerState(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Must supply the chaincode and the key as the 1st and 2nd parameters")
+	}
+	value, err := offlineLedger.GetState(args[0], args[1], true)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return fmt.Errorf("No state for key %s of chaincode %s", args[1], args[0])
+	}
+	fmt.Printf("%s\n%x\n", value, value)
+	return nil
+}
+
+func ledgerVerify() error {
+	size := offlineLedger.GetBlockchainSize()
+	if size > 1 {
+		blockNumber, err := offlineLedger.VerifyChain(size-1, 0)
+		if err != nil {
+			return fmt.Errorf("Error verifying the chain at block number %d: %s", blockNumber, err)
+		}
+		if blockNumber != 0 {
+			return fmt.Errorf("Block number %d does not hold the hash of block number %d", blockNumber, blockNumber-1)
+		}
+	}
+	if size > 0 {
+		lastBlock, err := offlineLedger.GetBlockByNumber(size - 1)
+		if err != nil {
+			return err
+		}
+		stateHash, err := offlineLedger.GetTempStateHash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(stateHash, lastBlock.StateHash) {
+			return fmt.Errorf("The state hash %x does not match the state hash %x of the last block %d", stateHash, lastBlock.StateHash, size-1)
+		}
+	}
+	fmt.Printf("Verified %d blocks\n", size)
+	return nil
+}
+
+func runBench() error {
+	eventsAddress := benchEventsAddr
+	if eventsAddress == "" {
+		eventsAddress = viper.GetString("peer.validator.events.address")
+	}
+	c, err := client.NewClient(&client.Config{PeerAddress: viper.GetString("peer.address"), EventsAddress: eventsAddress})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	config := benchConfig
+	if config.Chaincode == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		config.Chaincode, err = bench.Deploy(ctx, c)
+		cancel()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deployed %s %s\n", chainFuncName, config.Chaincode)
+	}
+	fmt.Printf("Running %v transactions per second, %v%% queries, for %s\n", config.Rate, config.QueryRatio*100, config.Duration)
+	report, err := bench.Run(context.Background(), c, &config)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "\tCOUNT\tMEAN\tP50\tP90\tP99\tMAX")
+	for _, latencies := range []struct {
+		name string
+		bench.Latencies
+	}{{"committed invokes", report.Invokes}, {"queries", report.Queries}} {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", latencies.name, latencies.Count, latencies.Mean, latencies.P50,
+			latencies.P90, latencies.P99, latencies.Max)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Rejected: %d, timed out: %d, failed: %d, skipped: %d\n", report.Rejected, report.TimedOut, report.Failed, report.Skipped)
+	fmt.Printf("Commit throughput: %.2f transactions per second over %s\n", report.Throughput, report.Elapsed)
+	return nil
+}
+
+func faultInject(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Must supply the fault point, consensus, chaincode or commit, as the 1st and only parameter")
+	}
+	point, ok := faultPoints[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown fault point %s, expected consensus, chaincode or commit", args[0])
+	}
+	if faultSpec.Delay == 0 && !faultSpec.Fail {
+		return fmt.Errorf("Must supply --delay or --fail")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionInjectFault, "peer")
+	if err != nil {
+		return err
+	}
+	f := faultSpec
+	f.Point = point
+	if _, err = pb.NewAdminClient(clientConn).InjectFault(ctx, &f); err != nil {
+		return err
+	}
+	fmt.Printf("Injecting fault %s\n", &f)
+	return nil
+}
+
+func faultList() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	list, err := pb.NewAdminClient(clientConn).ListFaults(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return err
+	}
+	for _, f := range list.Faults {
+		fmt.Println(f)
+	}
+	return nil
+}
+
+func faultClear() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	ctx, err := adminContext(openchain.AdminActionClearFaults, "peer")
+	if err != nil {
+		return err
+	}
+	if _, err = pb.NewAdminClient(clientConn).ClearFaults(ctx, &google_protobuf.Empty{}); err != nil {
+		return err
+	}
+	fmt.Println("Cleared the injected faults")
+	return nil
+}
+
+func replay(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Must supply the first and last block numbers as the 1st and 2nd parameters")
+	}
+	first, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid first block number %s: %s", args[0], err)
+	}
+	last, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid last block number %s: %s", args[1], err)
+	}
+	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		grpclog.Fatalf("Failed to get peer.discovery.rootnode valey: %s", err)
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
 	}
-
-	logger.Info("Starting peer with id=%s, network id=%s, address=%s, discovery.rootnode=%s, validator=%v",
-		peerEndpoint.ID, viper.GetString("peer.networkId"),
-		peerEndpoint.Address, rootNode, viper.GetBool("peer.validator.enabled"))
-
-	// Start the grpc server. Done in a goroutine so we can deploy the
-	// genesis block if needed.
-	serve := make(chan error)
-	go func() {
-		var grpcErr error
-		if grpcErr = grpcServer.Serve(lis); grpcErr != nil {
-			grpcErr = fmt.Errorf("grpc server exited with error: %s", grpcErr)
-		} else {
-			logger.Info("grpc server exited")
+	ctx, err := adminContext(openchain.AdminActionReplay, "peer")
+	if err != nil {
+		return err
+	}
+	report, err := pb.NewAdminClient(clientConn).ReplayBlocks(ctx, &pb.BlockReplaySpec{FirstBlock: first, LastBlock: last, ChainID: replayChainID})
+	if err != nil {
+		return err
+	}
+	for _, block := range report.Blocks {
+		outcome := "matches"
+		if !bytes.Equal(block.ReplayedStateHash, block.RecordedStateHash) {
+			outcome = fmt.Sprintf("differs from recorded state hash %x", block.RecordedStateHash)
 		}
-		serve <- grpcErr
-	}()
-
-	// Deploy the genesis block if needed.
-	if viper.GetBool("peer.validator.enabled") {
-		makeGenesisError := genesis.MakeGenesis()
-		if makeGenesisError != nil {
-			return makeGenesisError
+		if !block.Executed {
+			outcome += " (recorded changes applied)"
+		}
+		fmt.Printf("Block %d: state hash %x %s\n", block.BlockNumber, block.ReplayedStateHash, outcome)
+		for _, e := range block.Errors {
+			fmt.Printf("  Failed transaction %s\n", e)
 		}
 	}
-
-	//start the event hub server
-	if ehubGrpcServer != nil && ehubLis != nil {
-		go ehubGrpcServer.Serve(ehubLis)
+	if !report.Deterministic {
+		return fmt.Errorf("Replay diverged at blocks %v", report.DivergentBlocks)
 	}
-
-	// Block until grpc server exits
-	return <-serve
+	fmt.Println("Replay matches the recorded state hashes")
+	return nil
 }
 
-func status() (err error) {
+func loggingGetLevel(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Must supply at most the module as the 1st parameter")
+	}
+	logLevel := &pb.LogLevel{}
+	if len(args) == 1 {
+		logLevel.Module = args[0]
+	}
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		err = fmt.Errorf("Error trying to connect to local peer:", err)
-		return
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
 	}
-
-	serverClient := pb.NewAdminClient(clientConn)
-
-	status, err := serverClient.GetStatus(context.Background(), &google_protobuf.Empty{})
+	logLevel, err = pb.NewAdminClient(clientConn).GetLogLevel(context.Background(), logLevel)
 	if err != nil {
-		return
+		return err
 	}
-	fmt.Println(status)
+	fmt.Println(logLevel.Level)
 	return nil
 }
 
-func stop() (err error) {
+func loggingSetLevel(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("Must supply the module and the level as the 1st and 2nd parameters")
+	}
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
-		err = fmt.Errorf("Error trying to connect to local peer:", err)
-		return
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
 	}
-
-	logger.Info("Stopping peer...")
-	serverClient := pb.NewAdminClient(clientConn)
-
-	status, err := serverClient.StopServer(context.Background(), &google_protobuf.Empty{})
+	ctx, err := adminContext(openchain.AdminActionSetLogLevel, args[0])
 	if err != nil {
-		return
+		return err
 	}
-	fmt.Println(status)
+	logLevel, err := pb.NewAdminClient(clientConn).SetLogLevel(ctx, &pb.LogLevel{Module: args[0], Level: args[1]})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Logging level of module %s set to %s\n", logLevel.Module, logLevel.Level)
 	return nil
 }
 
@@ -558,6 +2084,44 @@ func getCliFilePath() string {
 	return localStore
 }
 
+// registerHealthChecks registers the checks of the subsystems the peer runs
+// with, reported at /healthz and /readyz
+func registerHealthChecks(peerServer *peer.PeerImpl) {
+	health.Register("ledger", true, func() error {
+		return db.GetDBHandle().CheckHealth()
+	})
+	if viper.GetBool("security.enabled") {
+		health.Register("membership", false, func() error {
+			for _, service := range []string{"eca", "tca", "tlsca"} {
+				address := viper.GetString("peer.pki." + service + ".paddr")
+				conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+				if err != nil {
+					return fmt.Errorf("Cannot connect to %s at %s: %s", service, address, err)
+				}
+				conn.Close()
+			}
+			return nil
+		})
+	}
+	if !viper.GetBool("peer.validator.enabled") {
+		return
+	}
+	health.Register("consensus", false, func() error {
+		validators, err := connectedValidators(peerServer)
+		if err != nil {
+			return err
+		}
+		return controller.CheckHealth(validators)
+	})
+	health.Register("eventhub", false, producer.CheckHealth)
+	if viper.GetString("chaincode.mode") != chaincode.DevModeUserRunsChaincode {
+		health.Register("docker", false, container.CheckDocker)
+		if container.ChaincodeVMType() == container.KUBERNETES {
+			health.Register("kubernetes", false, container.CheckKubernetes)
+		}
+	}
+}
+
 func registerChaincodeSupport(chainname chaincode.ChainName, grpcServer *grpc.Server, secHelper crypto.Peer) {
 	//get user mode
 	userRunsCC := false
@@ -578,6 +2142,16 @@ func registerChaincodeSupport(chainname chaincode.ChainName, grpcServer *grpc.Se
 
 func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 
+	if chaincodeCtorFile != "" {
+		if chaincodeCtorJSON != "{}" {
+			err = errors.New("Options --ctor (-c) and --ctor-file (-f) are not compatible\n")
+			return
+		}
+		if chaincodeCtorJSON, err = readCtorFile(chaincodeCtorFile); err != nil {
+			return
+		}
+	}
+
 	if chaincodeName == undefinedParamValue {
 		if chaincodePath == undefinedParamValue {
 			err = fmt.Errorf("Must supply value for %s path parameter.\n", chainFuncName)
@@ -616,6 +2190,47 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 	return
 }
 
+// readCtorFile returns the constructor message of a JSON or YAML file as
+// JSON. Arguments which are not strings, such as numbers, are converted to
+// strings.
+func readCtorFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("Error reading constructor message: %s", err)
+	}
+	var ctor map[string]interface{}
+	if err = json.Unmarshal(data, &ctor); err != nil {
+		if yamlErr := yaml.Unmarshal(data, &ctor); yamlErr != nil {
+			return "", fmt.Errorf("Constructor message in %s is neither JSON (%s) nor YAML (%s)", path, err, yamlErr)
+		}
+	}
+	for k, v := range ctor {
+		if strings.ToLower(k) != "args" || v == nil {
+			continue
+		}
+		args, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("Args of the constructor message in %s must be a list", path)
+		}
+		for i, arg := range args {
+			switch arg.(type) {
+			case string:
+			case nil:
+				args[i] = ""
+			case []interface{}, map[string]interface{}, map[interface{}]interface{}:
+				return "", fmt.Errorf("Argument %d of the constructor message in %s must be a string", i, path)
+			default:
+				args[i] = fmt.Sprint(arg)
+			}
+		}
+	}
+	ctorJSON, err := json.Marshal(ctor)
+	if err != nil {
+		return "", fmt.Errorf("Error encoding constructor message of %s: %s", path, err)
+	}
+	return string(ctorJSON), nil
+}
+
 func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
@@ -644,7 +2259,7 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG,
-		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input}
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input, ChainID: chaincodeChainID}
 
 	// If security is enabled, add client login token
 	if viper.GetBool("security.enabled") {
@@ -693,10 +2308,104 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 	logger.Info("Deploy result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	for _, finding := range chaincodeDeploymentSpec.AnalysisFindings {
+		logger.Warning("%s:%d: %s (%s)", finding.File, finding.Line, finding.Message, finding.Rule)
+	}
 	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
 	return nil
 }
 
+// chaincodeStatus prints the deployment status of the chaincode. Deployment
+// is asynchronous, so this is how the outcome of a deploy is learned. With
+// --watch every status change is printed until the deployment completes, and
+// a failed deployment is reported as an error.
+func chaincodeStatus(cmd *cobra.Command, args []string) (err error) {
+	if chaincodeName == undefinedParamValue {
+		return fmt.Errorf("Must supply value for %s name parameter.\n", chainFuncName)
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return fmt.Errorf("Error getting status of %s: %s", chainFuncName, err)
+	}
+	chaincodeID := &pb.ChaincodeID{Name: chaincodeName}
+
+	if !chaincodeWatch {
+		status, err := devopsClient.GetDeploymentStatus(context.Background(), chaincodeID)
+		if err != nil {
+			return fmt.Errorf("Error getting status of %s: %s", chainFuncName, err)
+		}
+		printDeploymentStatus(status)
+		return nil
+	}
+
+	stream, err := devopsClient.WatchDeployment(context.Background(), chaincodeID)
+	if err != nil {
+		return fmt.Errorf("Error watching %s: %s", chainFuncName, err)
+	}
+	for {
+		status, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error watching %s: %s", chainFuncName, err)
+		}
+		printDeploymentStatus(status)
+		if status.Phase == pb.DeploymentStatus_FAILED {
+			return fmt.Errorf("Deployment of %s failed", chaincodeName)
+		}
+	}
+}
+
+func printDeploymentStatus(status *pb.DeploymentStatus) {
+	fmt.Println(status.Phase)
+	if status.Msg != "" {
+		fmt.Println(status.Msg)
+	}
+	for _, check := range status.Checks {
+		result := "passed"
+		if !check.Passed {
+			result = "failed"
+		}
+		fmt.Printf("%s %s: %s\n", check.Hook, result, check.Summary)
+	}
+}
+
+// chaincodeList prints a line for every chaincode known to the peer, so
+// operators can audit which code is live on it
+func chaincodeList(cmd *cobra.Command, args []string) error {
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return fmt.Errorf("Error listing %ss: %s", chainFuncName, err)
+	}
+	list, err := devopsClient.ListChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error listing %ss: %s", chainFuncName, err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH\tENV\tBLOCK\tCODE HASH\tDEPLOYMENT\tCONTAINER\tHANDLER")
+	for _, info := range list.Chaincodes {
+		block := "-"
+		if info.Deployed {
+			block = strconv.FormatUint(info.DeployBlock, 10)
+		}
+		hash := info.CodeHash
+		if len(hash) > 16 {
+			hash = hash[:16]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.ChaincodeID.Name, info.ChaincodeID.Path, info.ExecEnv,
+			block, orDash(hash), info.DeploymentPhase, orDash(info.ContainerStatus), orDash(info.HandlerState))
+	}
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
 func chaincodeInvoke(cmd *cobra.Command, args []string) error {
 	return chaincodeInvokeOrQuery(cmd, args, true)
 }
@@ -705,28 +2414,30 @@ func chaincodeQuery(cmd *cobra.Command, args []string) error {
 	return chaincodeInvokeOrQuery(cmd, args, false)
 }
 
-// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
-// INVOKE form prints the transaction ID on STDOUT, and the QUERY form prints
-// the query result on STDOUT. A command-line flag (-r, --raw) determines
-// whether the query result is output as raw bytes, or as a printable string.
-// The printable form is optionally (-x, --hex) a hexadecimal representation
-// of the query response. If the query response is NIL, nothing is output.
-func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
-
-	if err = checkChaincodeCmdParams(cmd); err != nil {
-		return
-	}
-
-	if chaincodeName == "" {
-		err = errors.New("Name not given for invoke/query")
-		return
+// parseCalleeKeyHints groups the <chaincode name>:<key> hints of
+// --callee-key-hints by chaincode
+func parseCalleeKeyHints(hints []string) ([]*pb.ChaincodeKeyHints, error) {
+	var callees []*pb.ChaincodeKeyHints
+	byName := make(map[string]*pb.ChaincodeKeyHints)
+	for _, hint := range hints {
+		parts := strings.SplitN(hint, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid callee key hint %q, expecting <chaincode name>:<key>", hint)
+		}
+		callee, ok := byName[parts[0]]
+		if !ok {
+			callee = &pb.ChaincodeKeyHints{ChaincodeName: parts[0]}
+			byName[parts[0]] = callee
+			callees = append(callees, callee)
+		}
+		callee.Keys = append(callee.Keys, parts[1])
 	}
+	return callees, nil
+}
 
-	devopsClient, err := getDevopsClient(cmd)
-	if err != nil {
-		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
-		return
-	}
+// buildChaincodeInvocationSpec builds the invocation of the chaincode from the
+// command line parameters
+func buildChaincodeInvocationSpec() (invocation *pb.ChaincodeInvocationSpec, err error) {
 	// Build the spec
 	input := &pb.ChaincodeInput{}
 	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
@@ -734,7 +2445,7 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 		return
 	}
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG,
-		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input}
+		ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input, ChainID: chaincodeChainID}
 
 	// If security is enabled, add client login token
 	if viper.GetBool("security.enabled") {
@@ -777,7 +2488,143 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	}
 
 	// Build the ChaincodeInvocationSpec message
-	invocation := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	invocation = &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	return invocation, nil
+}
+
+// chaincodeVerify executes the invocation several times on the peer and
+// prints how the executions differ. It fails if they diverged.
+func chaincodeVerify(cmd *cobra.Command, args []string) error {
+	if err := checkChaincodeCmdParams(cmd); err != nil {
+		return err
+	}
+	if chaincodeName == "" {
+		return errors.New("Name not given for verify")
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		return fmt.Errorf("Error verifying %s: %s", chainFuncName, err)
+	}
+	invocation, err := buildChaincodeInvocationSpec()
+	if err != nil {
+		return err
+	}
+	report, err := devopsClient.CheckDeterminism(context.Background(), &pb.DeterminismCheckSpec{Invocation: invocation, Runs: int32(chaincodeVerifyRuns)})
+	if err != nil {
+		return fmt.Errorf("Error verifying %s: %s", chainFuncName, err)
+	}
+	for i, hash := range report.StateDeltaHashes {
+		fmt.Printf("Run %d state changes: %s\n", i, hash)
+	}
+	for _, e := range report.Errors {
+		fmt.Printf("Failed run: %s\n", e)
+	}
+	if report.DivergentResults {
+		fmt.Println("Runs returned different results")
+	}
+	for _, key := range report.DivergentKeys {
+		fmt.Printf("Runs changed key %s differently\n", key)
+	}
+	if !report.Deterministic {
+		return fmt.Errorf("%s %s is not deterministic", chainFuncName, chaincodeName)
+	}
+	fmt.Println("Runs did not diverge")
+	return nil
+}
+
+func chaincodeTrace(cmd *cobra.Command, args []string) error {
+	if chaincodeName == undefinedParamValue {
+		return errors.New("Name not given for trace")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	// the chaincode commands take the admin with their --username
+	if chaincodeUsr != undefinedParamValue {
+		adminID = chaincodeUsr
+	}
+	ctx, err := adminContext(openchain.AdminActionTraceChaincode, chaincodeName)
+	if err != nil {
+		return err
+	}
+	trace, err := pb.NewAdminClient(clientConn).SetChaincodeTrace(ctx, &pb.ChaincodeTrace{Name: chaincodeName, Enabled: !chaincodeTraceOff})
+	if err != nil {
+		return err
+	}
+	if trace.Enabled {
+		fmt.Printf("Tracing the messages of %s %s to %s\n", chainFuncName, trace.Name, trace.File)
+	} else {
+		fmt.Printf("Stopped tracing the messages of %s %s\n", chainFuncName, trace.Name)
+	}
+	return nil
+}
+
+func chaincodeLogs(cmd *cobra.Command, args []string) error {
+	if chaincodeName == undefinedParamValue {
+		return errors.New("Name not given for logs")
+	}
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	req := &pb.ChaincodeLogsRequest{Name: chaincodeName, Tail: chaincodeLogsTail}
+	if chaincodeLogsSince > 0 {
+		req.Since = time.Now().Add(-chaincodeLogsSince).UnixNano()
+	}
+	logs, err := pb.NewAdminClient(clientConn).GetChaincodeLogs(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	for _, line := range logs.Lines {
+		fmt.Printf("%s %s %s\n", time.Unix(0, line.Timestamp).Format(time.RFC3339Nano), line.Stream, line.Text)
+	}
+	return nil
+}
+
+// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
+// INVOKE form prints the transaction ID on STDOUT, after the transaction is
+// committed with --wait, and the QUERY form prints the query result on
+// STDOUT. The query result is output (-o, --output) as raw bytes, in
+// hexadecimal, as a printable string or as indented JSON. The older flags
+// (-r, --raw) and (-x, --hex) select the raw and hexadecimal outputs. If the
+// query response is NIL, nothing is output.
+func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
+
+	if err = checkChaincodeCmdParams(cmd); err != nil {
+		return
+	}
+
+	if chaincodeName == "" {
+		err = errors.New("Name not given for invoke/query")
+		return
+	}
+
+	format, err := queryOutputFormat()
+	if err != nil {
+		return
+	}
+	invocation, err := buildChaincodeInvocationSpec()
+	if err != nil {
+		return
+	}
+	if invoke {
+		invocation.IdempotencyKey = chaincodeIdemKey
+		invocation.KeyHints = chaincodeKeyHints
+		if invocation.CalleeKeyHints, err = parseCalleeKeyHints(chaincodeCalleeKeys); err != nil {
+			return
+		}
+		invocation.Priority = chaincodePriority
+		if chaincodeWait {
+			return chaincodeInvokeAndWait(invocation)
+		}
+	}
+
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
 
 	var resp *pb.Response
 	if invoke {
@@ -801,20 +2648,81 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	} else {
 		logger.Info("Successfully queried transaction: %s", invocation)
 		if resp != nil {
-			if chaincodeQueryRaw {
-				if chaincodeQueryHex {
-					err = errors.New("Options --raw (-r) and --hex (-x) are not compatible\n")
-					return
-				}
-				os.Stdout.Write(resp.Msg)
-			} else {
-				if chaincodeQueryHex {
-					fmt.Printf("%x\n", resp.Msg)
-				} else {
-					fmt.Println(string(resp.Msg))
-				}
-			}
+			return printQueryResult(resp.Msg, format)
+		}
+	}
+	return nil
+}
+
+// queryOutputFormat returns the format of the query value, --output or the
+// format selected by --raw and --hex
+func queryOutputFormat() (string, error) {
+	switch chaincodeOutput {
+	case "raw", "hex", "string", "json":
+		return chaincodeOutput, nil
+	case "":
+	default:
+		return "", fmt.Errorf("Unknown output format %s, must be raw, hex, string or json\n", chaincodeOutput)
+	}
+	if chaincodeQueryRaw {
+		if chaincodeQueryHex {
+			return "", errors.New("Options --raw (-r) and --hex (-x) are not compatible\n")
+		}
+		return "raw", nil
+	}
+	if chaincodeQueryHex {
+		return "hex", nil
+	}
+	return "string", nil
+}
+
+// printQueryResult prints the query value in the format. The json format
+// indents the value, which must be JSON.
+func printQueryResult(result []byte, format string) error {
+	switch format {
+	case "raw":
+		os.Stdout.Write(result)
+	case "hex":
+		fmt.Printf("%x\n", result)
+	case "json":
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, result, "", "  "); err != nil {
+			return fmt.Errorf("Query value is not JSON: %s", err)
+		}
+		fmt.Println(indented.String())
+	default:
+		fmt.Println(string(result))
+	}
+	return nil
+}
+
+// chaincodeInvokeAndWait invokes the chaincode through a client subscribed to
+// the event hub of a validator and waits until the transaction is committed
+// or rejected. The transaction ID is printed once it is committed.
+func chaincodeInvokeAndWait(invocation *pb.ChaincodeInvocationSpec) error {
+	eventsAddress := chaincodeEventsAddr
+	if eventsAddress == "" {
+		eventsAddress = viper.GetString("peer.validator.events.address")
+	}
+	c, err := client.NewClient(&client.Config{PeerAddress: viper.GetString("peer.address"), EventsAddress: eventsAddress})
+	if err != nil {
+		return fmt.Errorf("Error invoking %s: %s", chainFuncName, err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), chaincodeTimeout)
+	defer cancel()
+	transactionID, err := c.Invoke(ctx, invocation)
+	if err != nil {
+		return fmt.Errorf("Error invoking %s: %s", chainFuncName, err)
+	}
+	logger.Info("Successfully invoked transaction: %s(%s), waiting for it to be committed", invocation, transactionID)
+	if _, err = c.WaitForCommit(ctx, transactionID); err != nil {
+		if err == context.DeadlineExceeded {
+			return fmt.Errorf("Transaction %s not committed within %s", transactionID, chaincodeTimeout)
 		}
+		return err
 	}
+	fmt.Println(transactionID)
 	return nil
 }
@@ -0,0 +1,97 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// populateAttributes registers the attributes configured in tca.attributes.
+//
+//     <EnrollmentID>: <name>=<value>;<name>=<value>
+//
+func (tca *TCA) populateAttributes() {
+	for id, flds := range viper.GetStringMapString("tca.attributes") {
+		for _, fld := range strings.Split(flds, ";") {
+			kv := strings.SplitN(strings.TrimSpace(fld), "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				Panic.Panicln("invalid attribute " + fld + " of " + id)
+			}
+
+			if err := tca.setAttribute(id, kv[0], kv[1]); err != nil {
+				Panic.Panicln(err)
+			}
+		}
+	}
+}
+
+// setAttribute assigns an attribute to a user, replacing the previous value.
+//
+func (tca *TCA) setAttribute(id, name, value string) error {
+	Trace.Println("Setting attribute " + name + " of " + id + ".")
+
+	if _, err := tca.db.Exec("DELETE FROM Attributes WHERE id=? AND name=?", id, name); err != nil {
+		Error.Println(err)
+		return err
+	}
+	if _, err := tca.db.Exec("INSERT INTO Attributes (id, name, value) VALUES (?, ?, ?)", id, name, value); err != nil {
+		Error.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// readAttributes returns the attributes of a user to be embedded in its TCerts.
+// The attributes registered with the TCA take precedence over those of the
+// registry of the ECA.
+//
+func (tca *TCA) readAttributes(id string) (map[string]string, error) {
+	Trace.Println("Reading attributes of " + id + ".")
+
+	attributes := make(map[string]string)
+	if tca.eca.registry != nil {
+		entry, err := tca.eca.registry.Lookup(id)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range entry.Attributes {
+			attributes[name] = value
+		}
+	}
+
+	rows, err := tca.db.Query("SELECT name, value FROM Attributes WHERE id=?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		attributes[name] = value
+	}
+
+	return attributes, rows.Err()
+}
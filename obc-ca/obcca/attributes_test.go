@@ -0,0 +1,127 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestAttributes(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-attributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	eca := NewECA()
+	defer eca.Close()
+	tca := NewTCA(eca)
+	defer tca.Close()
+
+	tcap := &TCAP{tca}
+
+	// attributes of the configuration are registered on launch
+	attributes, err := tca.readAttributes("diego")
+	if err != nil {
+		t.Fatalf("Failed reading attributes: %s", err)
+	}
+	if attributes["role"] != "admin" || attributes["company"] != "ACompany" || len(attributes) != 2 {
+		t.Fatalf("Unexpected attributes of diego: %v", attributes)
+	}
+
+	id := "attruser"
+	if _, err := eca.registerUser(id, int(pb.Role_CLIENT)); err != nil {
+		t.Fatalf("Failed registering user: %s", err)
+	}
+	if err := tca.setAttribute(id, "role", "client"); err != nil {
+		t.Fatalf("Failed setting attribute: %s", err)
+	}
+	if err := tca.setAttribute(id, "role", "auditor"); err != nil {
+		t.Fatalf("Failed setting attribute: %s", err)
+	}
+
+	priv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Now().Unix()
+	if _, err := eca.createCertificate(id, &priv.PublicKey, x509.KeyUsageDigitalSignature, ts, nil); err != nil {
+		t.Fatalf("Failed creating signing certificate: %s", err)
+	}
+
+	req := &pb.TCertCreateSetReq{Ts: &google_protobuf.Timestamp{Seconds: ts}, Id: &pb.Identity{Id: id}, Num: 2}
+	signRequest(t, priv, req, func(sig *pb.Signature) { req.Sig = sig })
+	resp, err := tcap.CreateCertificateSet(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed creating TCert set: %s", err)
+	}
+
+	kdfKey := resp.Certs.Key
+	indexKey := utils.HMACTruncated(kdfKey, []byte{1}, utils.AESKeyLength)
+	for _, raw := range resp.Certs.Certs {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			t.Fatalf("Failed parsing TCert: %s", err)
+		}
+		if !utils.HasAttributes(cert) {
+			t.Fatal("TCert does not carry attributes")
+		}
+
+		ct, err := utils.GetCriticalExtension(cert, utils.TCertEncTCertIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tidx, err := utils.CBCPKCS7Decrypt(indexKey, ct)
+		if err != nil {
+			t.Fatalf("Failed decrypting TCert index: %s", err)
+		}
+		attributesKey := utils.AttributesKey(kdfKey, tidx)
+
+		role, err := utils.DecryptAttribute(cert, attributesKey, "role")
+		if err != nil || role != "auditor" {
+			t.Fatalf("Expected role auditor, got [%s] [%v]", role, err)
+		}
+		if _, err := utils.DecryptAttribute(cert, attributesKey, "company"); err != utils.ErrAttributeNotFound {
+			t.Fatalf("Expected missing attribute, got [%v]", err)
+		}
+		if _, err := utils.DecryptAttribute(cert, utils.AttributesKey(kdfKey, []byte("other")), "role"); err == nil {
+			t.Fatal("Attribute decrypted under a wrong key")
+		}
+	}
+}
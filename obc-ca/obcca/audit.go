@@ -0,0 +1,179 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	ecies "github.com/openblockchain/obc-peer/openchain/crypto/ecies/generic"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	obc "github.com/openblockchain/obc-peer/protos"
+)
+
+// populateAuditors designates the chaincodes the auditors configured in
+// eca.auditors can inspect.
+//
+//     <EnrollmentID>: <chaincode>,<chaincode>
+//
+func (eca *ECA) populateAuditors() {
+	for id, flds := range viper.GetStringMapString("eca.auditors") {
+		if err := eca.grantAuditAccess(id, strings.Split(flds, ",")...); err != nil {
+			Panic.Panicln(err)
+		}
+	}
+}
+
+// grantAuditAccess designates chaincodes an auditor can inspect.
+//
+func (eca *ECA) grantAuditAccess(id string, chaincodes ...string) error {
+	Trace.Println("Granting audit access to " + id + ".")
+
+	for _, chaincode := range chaincodes {
+		chaincode = strings.TrimSpace(chaincode)
+		if chaincode == "" {
+			continue
+		}
+
+		if _, err := eca.db.Exec("DELETE FROM Auditors WHERE id=? AND chaincode=?", id, chaincode); err != nil {
+			Error.Println(err)
+			return err
+		}
+		if _, err := eca.db.Exec("INSERT INTO Auditors (id, chaincode) VALUES (?, ?)", id, chaincode); err != nil {
+			Error.Println(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// canAudit tells whether a chaincode has been designated to an auditor.
+//
+func (eca *ECA) canAudit(id, chaincode string) bool {
+	var count int
+	eca.db.QueryRow("SELECT COUNT(*) FROM Auditors WHERE id=? AND chaincode=?", id, chaincode).Scan(&count)
+
+	return count > 0
+}
+
+// readTransactionKey derives the key a confidential transaction is encrypted
+// under from the chain key and returns it along with the name of the chaincode
+// the transaction targets.
+//
+func (eca *ECA) readTransactionKey(tx *pb.ConfidentialTx) ([]byte, string, error) {
+	if len(tx.Nonce) == 0 {
+		return nil, "", errors.New("invalid transaction nonce")
+	}
+
+	key := utils.HMAC(eca.obcKey, tx.Nonce)
+	raw, err := utils.CBCPKCS7Decrypt(utils.HMACTruncated(key, []byte{2}, utils.AESKeyLength), utils.Clone(tx.ChaincodeID))
+	if err != nil {
+		return nil, "", errors.New("invalid transaction chaincode")
+	}
+
+	cID := &obc.ChaincodeID{}
+	if err := proto.Unmarshal(raw, cID); err != nil {
+		return nil, "", err
+	}
+
+	return key, cID.Name, nil
+}
+
+// ReadAuditKeys returns the keys of confidential transactions to an auditor.  The
+// keys give read-only access to the transactions and, for deploy and key rotation
+// transactions, to the state of the chaincode.  Auditors can only read the keys of
+// the transactions of the chaincodes designated to them.
+//
+func (ecap *ECAP) ReadAuditKeys(ctx context.Context, in *pb.AuditKeysReq) (*pb.AuditKeys, error) {
+	Trace.Println("grpc ECAP:ReadAuditKeys")
+
+	id := in.Id.Id
+	if ecap.eca.readRole(id)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("access denied")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.verifySignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	// the keys are encrypted under the enrollment encryption key of the auditor
+	raw, err := ecap.eca.readCertificate(id, x509.KeyUsageDataEncipherment)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	spi := ecies.NewSPI()
+	eciesKey, err := spi.NewPublicKey(nil, cert.PublicKey.(*ecdsa.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := spi.NewAsymmetricCipherFromPublicKey(eciesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]byte, len(in.Txs))
+	for i, tx := range in.Txs {
+		key, chaincode, err := ecap.eca.readTransactionKey(tx)
+		if err != nil {
+			return nil, err
+		}
+		if !ecap.eca.canAudit(id, chaincode) {
+			return nil, errors.New("access denied")
+		}
+
+		if keys[i], err = cipher.Process(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.AuditKeys{Keys: keys}, nil
+}
+
+// GrantAuditAccess designates chaincodes an auditor can inspect.
+//
+func (ecaa *ECAA) GrantAuditAccess(ctx context.Context, in *pb.AuditAccessReq) (*pb.CAStatus, error) {
+	Trace.Println("grpc ECAA:GrantAuditAccess")
+
+	id := in.Id.Id
+	if ecaa.eca.readRole(id)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("identity is not an auditor")
+	}
+
+	if err := ecaa.eca.grantAuditAccess(id, in.Chaincodes...); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
@@ -0,0 +1,155 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	ecies "github.com/openblockchain/obc-peer/openchain/crypto/ecies/generic"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	obc "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestReadAuditKeys(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	eca := NewECA()
+	defer eca.Close()
+
+	ecap := &ECAP{eca}
+	ecaa := &ECAA{eca}
+
+	// auditors of the configuration are designated their chaincodes on launch
+	if !eca.canAudit("regulator", "mycc") || !eca.canAudit("regulator", "othercc") || eca.canAudit("regulator", "thirdcc") {
+		t.Fatal("Unexpected chaincodes designated to regulator")
+	}
+
+	id := "audituser"
+	if _, err := eca.registerUser(id, int(pb.Role_AUDITOR)); err != nil {
+		t.Fatalf("Failed registering auditor: %s", err)
+	}
+	signPriv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encPriv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Now().Unix()
+	if _, err := eca.createCertificate(id, &signPriv.PublicKey, x509.KeyUsageDigitalSignature, ts, nil); err != nil {
+		t.Fatalf("Failed creating signing certificate: %s", err)
+	}
+	if _, err := eca.createCertificate(id, &encPriv.PublicKey, x509.KeyUsageDataEncipherment, ts, nil); err != nil {
+		t.Fatalf("Failed creating encryption certificate: %s", err)
+	}
+
+	tx, txKey := newConfidentialTx(t, eca.obcKey, "mycc")
+	readAuditKeys := func(id string, txs ...*pb.ConfidentialTx) (*pb.AuditKeys, error) {
+		req := &pb.AuditKeysReq{Ts: &google_protobuf.Timestamp{Seconds: time.Now().Unix()}, Id: &pb.Identity{Id: id}, Txs: txs}
+		signRequest(t, signPriv, req, func(sig *pb.Signature) { req.Sig = sig })
+		return ecap.ReadAuditKeys(context.Background(), req)
+	}
+
+	// chaincode not yet designated to the auditor
+	if _, err := readAuditKeys(id, tx); err == nil {
+		t.Fatal("Read the key of a transaction of a chaincode not designated to the auditor")
+	}
+
+	if _, err := ecaa.GrantAuditAccess(context.Background(), &pb.AuditAccessReq{Id: &pb.Identity{Id: id}, Chaincodes: []string{"mycc"}}); err != nil {
+		t.Fatalf("Failed granting audit access: %s", err)
+	}
+	if _, err := ecaa.GrantAuditAccess(context.Background(), &pb.AuditAccessReq{Id: &pb.Identity{Id: "diego"}, Chaincodes: []string{"mycc"}}); err == nil {
+		t.Fatal("Granted audit access to a peer")
+	}
+
+	resp, err := readAuditKeys(id, tx)
+	if err != nil {
+		t.Fatalf("Failed reading audit keys: %s", err)
+	}
+	if len(resp.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(resp.Keys))
+	}
+
+	spi := ecies.NewSPI()
+	eciesKey, err := spi.NewPrivateKey(nil, encPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := spi.NewAsymmetricCipherFromPrivateKey(eciesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := cipher.Process(resp.Keys[0])
+	if err != nil {
+		t.Fatalf("Failed decrypting audit key: %s", err)
+	}
+	if !bytes.Equal(key, txKey) {
+		t.Fatal("Audit key differs from the transaction key")
+	}
+
+	// a single transaction of another chaincode fails the whole request
+	other, _ := newConfidentialTx(t, eca.obcKey, "othercc")
+	if _, err := readAuditKeys(id, tx, other); err == nil {
+		t.Fatal("Read the key of a transaction of a chaincode not designated to the auditor")
+	}
+}
+
+func newConfidentialTx(t *testing.T, chainKey []byte, chaincode string) (*pb.ConfidentialTx, []byte) {
+	nonce, err := utils.GetRandomBytes(utils.NonceSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := proto.Marshal(&obc.ChaincodeID{Name: chaincode})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txKey := utils.HMAC(chainKey, nonce)
+	ct, err := utils.CBCPKCS7Encrypt(utils.HMACTruncated(txKey, []byte{2}, utils.AESKeyLength), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &pb.ConfidentialTx{Nonce: nonce, ChaincodeID: ct}, txKey
+}
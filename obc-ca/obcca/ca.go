@@ -20,6 +20,7 @@ under the License.
 package obcca
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
@@ -27,7 +28,6 @@ import (
 	"database/sql"
 	"encoding/pem"
 	"errors"
-	"io/ioutil"
 	"math/big"
 	"os"
 	"strconv"
@@ -35,6 +35,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/hsm"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 )
 
@@ -45,9 +46,10 @@ type CA struct {
 
 	path string
 
-	priv *ecdsa.PrivateKey
-	cert *x509.Certificate
-	raw  []byte
+	priv  crypto.Signer
+	cert  *x509.Certificate
+	raw   []byte
+	chain [][]byte
 }
 
 // NewCA sets up a new CA.
@@ -65,33 +67,33 @@ func NewCA(name string) *CA {
 	}
 
 	// open or create certificate database
-	db, err := sql.Open("sqlite3", ca.path+"/"+name+".db")
+	db, err := openDatabase(ca.path, name)
 	if err != nil {
 		Panic.Panicln(err)
 	}
-
-	if err := db.Ping(); err != nil {
-		Panic.Panicln(err)
-	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)"); err != nil {
-		Panic.Panicln(err)
-	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), role INTEGER, token BLOB, state INTEGER, key BLOB)"); err != nil {
+	if err := initSchema(db); err != nil {
 		Panic.Panicln(err)
 	}
 	ca.db = db
 
-	// read or create signing key pair
-	priv, err := ca.readCAPrivateKey(name)
-	if err != nil {
-		priv = ca.createCAKeyPair(name)
+	// read or create signing key pair, in the HSM if enabled for this CA
+	if hsm.IsEnabled(name) {
+		ca.priv, err = hsm.GetOrGenerateKey(name, conf.GetDefaultCurve())
+		if err != nil {
+			Panic.Panicln(err)
+		}
+	} else {
+		priv, err := ca.readCAPrivateKey(name)
+		if err != nil {
+			priv = ca.createCAKeyPair(name)
+		}
+		ca.priv = priv
 	}
-	ca.priv = priv
 
 	// read CA certificate, or create a self-signed CA certificate
 	raw, err := ca.readCACertificate(name)
 	if err != nil {
-		raw = ca.createCACertificate(name, &ca.priv.PublicKey)
+		raw = ca.createCACertificate(name, ca.priv.Public().(*ecdsa.PublicKey))
 	}
 	cert, err := x509.ParseCertificate(raw)
 	if err != nil {
@@ -101,6 +103,13 @@ func NewCA(name string) *CA {
 	ca.raw = raw
 	ca.cert = cert
 
+	// read the certificates of the issuing CAs if this is an intermediate CA
+	chain, err := ca.readCACertificateChain(name)
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	ca.chain = chain
+
 	return ca
 }
 
@@ -123,19 +132,24 @@ func (ca *CA) createCAKeyPair(name string) *ecdsa.PrivateKey {
 				Type:  "ECDSA PRIVATE KEY",
 				Bytes: raw,
 			})
-		err := ioutil.WriteFile(ca.path+"/"+name+".priv", cooked, 0644)
+		cooked, err := ca.storeMaterial(name+".priv", cooked)
 		if err != nil {
 			Panic.Panicln(err)
 		}
 
+		// another CA instance may have created the key pair first
+		block, _ := pem.Decode(cooked)
+		if priv, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			Panic.Panicln(err)
+		}
+
 		raw, _ = x509.MarshalPKIXPublicKey(&priv.PublicKey)
 		cooked = pem.EncodeToMemory(
 			&pem.Block{
 				Type:  "ECDSA PUBLIC KEY",
 				Bytes: raw,
 			})
-		err = ioutil.WriteFile(ca.path+"/"+name+".pub", cooked, 0644)
-		if err != nil {
+		if _, err = ca.storeMaterial(name+".pub", cooked); err != nil {
 			Panic.Panicln(err)
 		}
 	}
@@ -149,7 +163,7 @@ func (ca *CA) createCAKeyPair(name string) *ecdsa.PrivateKey {
 func (ca *CA) readCAPrivateKey(name string) (*ecdsa.PrivateKey, error) {
 	Trace.Println("Reading CA private key.")
 
-	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".priv")
+	cooked, err := ca.readMaterial(name + ".priv")
 	if err != nil {
 		return nil, err
 	}
@@ -171,18 +185,19 @@ func (ca *CA) createCACertificate(name string, pub *ecdsa.PublicKey) []byte {
 			Type:  "CERTIFICATE",
 			Bytes: raw,
 		})
-	err = ioutil.WriteFile(ca.path+"/"+name+".cert", cooked, 0644)
+	cooked, err = ca.storeMaterial(name+".cert", cooked)
 	if err != nil {
 		Panic.Panicln(err)
 	}
 
-	return raw
+	block, _ := pem.Decode(cooked)
+	return block.Bytes
 }
 
 func (ca *CA) readCACertificate(name string) ([]byte, error) {
 	Trace.Println("Reading CA certificate.")
 
-	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".cert")
+	cooked, err := ca.readMaterial(name + ".cert")
 	if err != nil {
 		return nil, err
 	}
@@ -191,6 +206,40 @@ func (ca *CA) readCACertificate(name string) ([]byte, error) {
 	return block.Bytes, nil
 }
 
+// readCACertificateChain reads the certificates of the CAs that issued the
+// certificate of an intermediate CA, from the issuer of the CA certificate up
+// to a root, and checks that each one issued the previous one.  A CA with a
+// self-signed certificate has no chain.
+//
+func (ca *CA) readCACertificateChain(name string) ([][]byte, error) {
+	Trace.Println("Reading CA certificate chain.")
+
+	cooked, err := ca.readMaterial(name + ".chain")
+	if os.IsNotExist(err) || err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var chain [][]byte
+	issued := ca.cert
+	for block, rest := pem.Decode(cooked); block != nil; block, rest = pem.Decode(rest) {
+		issuer, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if err := issued.CheckSignatureFrom(issuer); err != nil {
+			return nil, errors.New(name + ".chain: " + issuer.Subject.CommonName + " did not issue the certificate of " + issued.Subject.CommonName + ": " + err.Error())
+		}
+
+		chain = append(chain, block.Bytes)
+		issued = issuer
+	}
+
+	return chain, nil
+}
+
 func (ca *CA) createCertificate(id string, pub interface{}, usage x509.KeyUsage, timestamp int64, kdfKey []byte, opt ...pkix.Extension) ([]byte, error) {
 	Trace.Println("Creating certificate for " + id + ".")
 
@@ -216,8 +265,14 @@ func (ca *CA) newCertificate(id string, pub interface{}, usage x509.KeyUsage, ex
 	parent := ca.cert
 	isCA := parent == nil
 
+	// serial numbers have to be unique per CA for revocation lists to work
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
 	tmpl := x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serial,
 		Subject: pkix.Name{
 			CommonName:   id,
 			Organization: []string{"IBM"},
@@ -260,7 +315,7 @@ func (ca *CA) readCertificate(id string, usage x509.KeyUsage) ([]byte, error) {
 	Trace.Println("Reading certificate for " + id + ".")
 
 	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=? ORDER BY row DESC LIMIT 1", id, usage).Scan(&raw)
 
 	return raw, err
 }
@@ -0,0 +1,186 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestCAKeyInHSM(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-hsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	viper.Set("security.hsm.provider", "soft")
+	viper.Set("security.hsm.keys.hsmca", true)
+	defer viper.Set("security.hsm.keys.hsmca", false)
+
+	ca := NewCA("hsmca")
+	defer func() { ca.Close() }()
+
+	if _, ok := ca.priv.(*ecdsa.PrivateKey); ok {
+		t.Fatal("CA key is held in software")
+	}
+	if _, err := os.Stat(ca.path + "/hsmca.priv"); !os.IsNotExist(err) {
+		t.Fatal("CA key has been written to the CA directory")
+	}
+	if err := ca.cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Fatalf("Self-signed CA certificate does not verify: %s", err)
+	}
+
+	priv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ca.createCertificate("hsmuser", &priv.PublicKey, x509.KeyUsageDigitalSignature, time.Now().Unix(), nil)
+	if err != nil {
+		t.Fatalf("Failed creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Fatalf("Certificate signed in the HSM does not verify: %s", err)
+	}
+
+	// the CA picks up its key from the HSM on restart
+	ca.Close()
+	ca = NewCA("hsmca")
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Fatalf("CA lost its key on restart: %s", err)
+	}
+}
+
+func TestIntermediateCA(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	// the root of the enterprise PKI issues the certificate of the CA
+	newCert := func(cn string, pub, priv interface{}, parent *x509.Certificate) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Minute),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		if parent == nil {
+			parent = tmpl
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	rootPriv, _ := utils.NewECDSAKey()
+	root := newCert("root", &rootPriv.PublicKey, rootPriv, nil)
+	caPriv, _ := utils.NewECDSAKey()
+	caCert := newCert("chainca", &caPriv.PublicKey, rootPriv, root)
+
+	path := filepath.Join(dir, viper.GetString("server.cadir"))
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := x509.MarshalECPrivateKey(caPriv)
+	write := func(file, typ string, raw []byte) {
+		if err := ioutil.WriteFile(filepath.Join(path, file), pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: raw}), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("chainca.priv", "ECDSA PRIVATE KEY", raw)
+	write("chainca.cert", "CERTIFICATE", caCert.Raw)
+	write("chainca.chain", "CERTIFICATE", root.Raw)
+
+	ca := NewCA("chainca")
+	defer ca.Close()
+
+	if len(ca.chain) != 1 || !bytes.Equal(ca.chain[0], root.Raw) {
+		t.Fatal("CA did not load the certificate chain")
+	}
+
+	// the certificates the CA issues validate against the root
+	priv, _ := utils.NewECDSAKey()
+	raw, err = ca.createCertificate("chainuser", &priv.PublicKey, x509.KeyUsageDigitalSignature, time.Now().Unix(), nil)
+	if err != nil {
+		t.Fatalf("Failed creating certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(ca.cert)
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := cert.Verify(opts); err != nil {
+		t.Fatalf("Certificate issued by the intermediate CA does not validate against the root: %s", err)
+	}
+
+	// a chain that did not issue the CA certificate is rejected
+	otherPriv, _ := utils.NewECDSAKey()
+	write("chainca.chain", "CERTIFICATE", newCert("other", &otherPriv.PublicKey, otherPriv, nil).Raw)
+	if _, err := ca.readCACertificateChain("chainca"); err == nil {
+		t.Fatal("Chain of another root should be rejected")
+	}
+}
@@ -0,0 +1,257 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+// crlValidity returns how long a freshly created CRL is valid.  Peers have to fetch a
+// new CRL before the previous one expires.
+//
+func crlValidity() time.Duration {
+	validity, err := time.ParseDuration(GetConfigString("crl.validity"))
+	if err != nil || validity <= 0 {
+		return time.Hour
+	}
+
+	return validity
+}
+
+// revokeCertificate adds a certificate issued by this CA to its revocation list.
+//
+func (ca *CA) revokeCertificate(raw []byte) error {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	if err = cert.CheckSignatureFrom(ca.cert); err != nil {
+		return errors.New("certificate was not issued by this CA")
+	}
+
+	Trace.Println("Revoking certificate " + cert.SerialNumber.String() + " of " + cert.Subject.CommonName + ".")
+
+	if ca.isRevoked(cert) {
+		return nil
+	}
+
+	_, err = ca.db.Exec("INSERT INTO Revocations (serial, timestamp) VALUES (?, ?)", cert.SerialNumber.String(), time.Now().Unix())
+	if err != nil && ca.isRevoked(cert) {
+		// revoked concurrently by another CA instance sharing the database
+		return nil
+	}
+	if err != nil {
+		Error.Println(err)
+	}
+
+	return err
+}
+
+// isRevoked checks whether a certificate issued by this CA has been revoked.
+//
+func (ca *CA) isRevoked(cert *x509.Certificate) bool {
+	var row int
+	err := ca.db.QueryRow("SELECT row FROM Revocations WHERE serial=?", cert.SerialNumber.String()).Scan(&row)
+
+	return err == nil
+}
+
+// createCRL creates a certificate revocation list signed by this CA holding all
+// certificates revoked so far.
+//
+func (ca *CA) createCRL() ([]byte, error) {
+	Trace.Println("Creating CRL.")
+
+	rows, err := ca.db.Query("SELECT serial, timestamp FROM Revocations ORDER BY row")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serial string
+		var ts int64
+		if err = rows.Scan(&serial, &ts); err != nil {
+			return nil, err
+		}
+
+		num, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			return nil, errors.New("invalid serial number " + serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: num, RevocationTime: time.Unix(ts, 0).UTC()})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return ca.cert.CreateCRL(rand.Reader, ca.priv, revoked, now, now.Add(crlValidity()))
+}
+
+// readCertificateOwner returns the identity a certificate issued by this CA belongs to.
+//
+func (ca *CA) readCertificateOwner(raw []byte) (string, error) {
+	hash := utils.NewHash()
+	hash.Write(raw)
+
+	var id string
+	err := ca.db.QueryRow("SELECT id FROM Certificates WHERE hash=?", hash.Sum(nil)).Scan(&id)
+	if err != nil {
+		return "", errors.New("unknown certificate")
+	}
+
+	return id, nil
+}
+
+// revokeCertificates revokes all certificates of id issued at timestamp ts.  If ts is 0
+// all certificates of id are revoked.
+//
+func (ca *CA) revokeCertificates(id string, ts int64) error {
+	rows, err := ca.readCertificates(id, ts)
+	if err != nil {
+		return err
+	}
+
+	var certs [][]byte
+	for rows.Next() {
+		var raw, kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			rows.Close()
+			return err
+		}
+		certs = append(certs, raw)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificates found for " + id)
+	}
+
+	for _, raw := range certs {
+		if err = ca.revokeCertificate(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeUser revokes the enrollment certificate pair of id and marks the user as
+// revoked so that the TCA revokes its transaction certificates as well.
+//
+func (eca *ECA) revokeUser(id string) error {
+	if err := eca.revokeCertificates(id, 0); err != nil {
+		return err
+	}
+
+	_, err := eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 3, id)
+	if err != nil {
+		Error.Println(err)
+	}
+
+	return err
+}
+
+// revokeUserCertificates revokes all transaction certificates of users whose enrollment
+// certificates have been revoked by the ECA.
+//
+func (tca *TCA) revokeUserCertificates() error {
+	rows, err := tca.eca.db.Query("SELECT id FROM Users WHERE state=?", 3)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		var count int
+		tca.db.QueryRow("SELECT COUNT(*) FROM Certificates WHERE id=?", id).Scan(&count)
+		if count == 0 {
+			continue
+		}
+		if err = tca.revokeCertificates(id, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifySignature checks that sig is a signature of msg under the enrollment signing
+// certificate of id, and that this certificate has not been revoked.  The signature
+// field of msg has to be cleared by the caller.
+//
+func (eca *ECA) verifySignature(id string, msg proto.Message, sig *pb.Signature) error {
+	raw, err := eca.readCertificate(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	if eca.isRevoked(cert) {
+		return errors.New("enrollment certificate has been revoked")
+	}
+	if sig == nil {
+		return errors.New("signature does not verify")
+	}
+
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(sig.R)
+	s.UnmarshalText(sig.S)
+
+	hash := utils.NewHash()
+	raw, _ = proto.Marshal(msg)
+	hash.Write(raw)
+	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		return errors.New("signature does not verify")
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestCRL(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	eca := NewECA()
+	defer eca.Close()
+	tca := NewTCA(eca)
+	defer tca.Close()
+
+	ecap := &ECAP{eca}
+	tcap := &TCAP{tca}
+
+	// enroll a user directly, bypassing the enrollment challenge
+	id := "crluser"
+	if _, err := eca.registerUser(id, int(pb.Role_CLIENT)); err != nil {
+		t.Fatalf("Failed registering user: %s", err)
+	}
+	priv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Now().Unix()
+	sraw, err := eca.createCertificate(id, &priv.PublicKey, x509.KeyUsageDigitalSignature, ts, nil)
+	if err != nil {
+		t.Fatalf("Failed creating signing certificate: %s", err)
+	}
+	eraw, err := eca.createCertificate(id, &priv.PublicKey, x509.KeyUsageDataEncipherment, ts, nil)
+	if err != nil {
+		t.Fatalf("Failed creating encryption certificate: %s", err)
+	}
+	traw, err := tca.createCertificate(id, &priv.PublicKey, x509.KeyUsageDigitalSignature, ts, nil)
+	if err != nil {
+		t.Fatalf("Failed creating transaction certificate: %s", err)
+	}
+
+	scert, _ := x509.ParseCertificate(sraw)
+	ecert, _ := x509.ParseCertificate(eraw)
+	tcert, _ := x509.ParseCertificate(traw)
+	if scert.SerialNumber.Cmp(ecert.SerialNumber) == 0 {
+		t.Fatal("Certificates share the same serial number")
+	}
+
+	crl := readCRL(t, eca.cert, ecap.ReadCRL)
+	if len(crl) != 0 {
+		t.Fatalf("Expected empty ECA CRL, got %d entries", len(crl))
+	}
+
+	// a user cannot revoke the certificates of somebody else
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: id}, Cert: &pb.Cert{Cert: eca.raw}}
+	signRequest(t, priv, req, func(sig *pb.Signature) { req.Sig = sig })
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Revoking a foreign certificate should fail")
+	}
+
+	req = &pb.ECertRevokeReq{Id: &pb.Identity{Id: id}, Cert: &pb.Cert{Cert: sraw}}
+	signRequest(t, priv, req, func(sig *pb.Signature) { req.Sig = sig })
+	if _, err := ecap.RevokeCertificatePair(context.Background(), req); err != nil {
+		t.Fatalf("Failed revoking certificate pair: %s", err)
+	}
+
+	crl = readCRL(t, eca.cert, ecap.ReadCRL)
+	if !crl[scert.SerialNumber.String()] || !crl[ecert.SerialNumber.String()] || len(crl) != 2 {
+		t.Fatalf("ECA CRL does not hold the revoked certificate pair: %v", crl)
+	}
+
+	// revoked enrollment certificates must neither sign requests nor obtain TCerts
+	tcertReq := &pb.TCertCreateReq{Ts: &google_protobuf.Timestamp{Seconds: ts}, Id: &pb.Identity{Id: id}, Pub: &pb.PublicKey{Type: pb.CryptoType_ECDSA}}
+	tcertReq.Pub.Key, _ = x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	signRequest(t, priv, tcertReq, func(sig *pb.Signature) { tcertReq.Sig = sig })
+	if _, err := tcap.CreateCertificate(context.Background(), tcertReq); err == nil {
+		t.Fatal("TCA issued a certificate for a revoked enrollment certificate")
+	}
+
+	// transaction certificates of revoked users are revoked as well
+	crl = readCRL(t, tca.cert, tcap.ReadCRL)
+	if !crl[tcert.SerialNumber.String()] || len(crl) != 1 {
+		t.Fatalf("TCA CRL does not hold the certificates of the revoked user: %v", crl)
+	}
+}
+
+func signRequest(t *testing.T, priv *ecdsa.PrivateKey, msg proto.Message, set func(*pb.Signature)) {
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, priv, utils.Hash(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	set(&pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S})
+}
+
+func readCRL(t *testing.T, ca *x509.Certificate, read func(context.Context, *pb.Empty) (*pb.CRL, error)) map[string]bool {
+	resp, err := read(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatalf("Failed reading CRL: %s", err)
+	}
+
+	crl, err := x509.ParseCRL(resp.Crl)
+	if err != nil {
+		t.Fatalf("Failed parsing CRL: %s", err)
+	}
+	if err = ca.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("CRL signature does not verify: %s", err)
+	}
+	if crl.HasExpired(time.Now()) {
+		t.Fatal("CRL has already expired")
+	}
+
+	serials := make(map[string]bool)
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		serials[revoked.SerialNumber.String()] = true
+	}
+
+	return serials
+}
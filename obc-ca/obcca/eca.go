@@ -27,11 +27,11 @@ import (
 	"crypto/subtle"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"database/sql"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
 	"errors"
-	"io/ioutil"
 	"math/big"
 	"strconv"
 	"strings"
@@ -41,6 +41,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/audit"
 	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 	"github.com/spf13/viper"
@@ -60,6 +61,7 @@ type ECA struct {
 	*CA
 	obcKey          []byte
 	obcPriv, obcPub []byte
+	registry        Registry
 }
 
 // ECAP serves the public GRPC interface of the ECA.
@@ -78,23 +80,23 @@ type ECAA struct {
 //
 func NewECA() *ECA {
 
-	eca := &ECA{NewCA("eca"), nil, nil, nil}
+	eca := &ECA{NewCA("eca"), nil, nil, nil, nil}
 
 	{
 		// read or create global symmetric encryption key
 		var cooked string
 
-		raw, err := ioutil.ReadFile(eca.path + "/obc.aes")
+		raw, err := eca.readMaterial("obc.aes")
 		if err != nil {
 			rand := rand.Reader
 			key := make([]byte, 32) // AES-256
 			rand.Read(key)
-			cooked = base64.StdEncoding.EncodeToString(key)
 
-			err = ioutil.WriteFile(eca.path+"/obc.aes", []byte(cooked), 0644)
+			raw, err = eca.storeMaterial("obc.aes", []byte(base64.StdEncoding.EncodeToString(key)))
 			if err != nil {
 				Panic.Panicln(err)
 			}
+			cooked = string(raw)
 		} else {
 			cooked = string(raw)
 		}
@@ -108,7 +110,7 @@ func NewECA() *ECA {
 	{
 		// read or create global ECDSA key pair for ECIES
 		var priv *ecdsa.PrivateKey
-		cooked, err := ioutil.ReadFile(eca.path + "/obc.ecies")
+		cooked, err := eca.readMaterial("obc.ecies")
 		if err == nil {
 			block, _ := pem.Decode(cooked)
 			priv, err = x509.ParseECPrivateKey(block.Bytes)
@@ -127,7 +129,14 @@ func NewECA() *ECA {
 					Type:  "ECDSA PRIVATE KEY",
 					Bytes: raw,
 				})
-			err := ioutil.WriteFile(eca.path+"/obc.ecies", cooked, 0644)
+			cooked, err = eca.storeMaterial("obc.ecies", cooked)
+			if err != nil {
+				Panic.Panicln(err)
+			}
+
+			// another ECA instance may have created the key pair first
+			block, _ := pem.Decode(cooked)
+			priv, err = x509.ParseECPrivateKey(block.Bytes)
 			if err != nil {
 				Panic.Panicln(err)
 			}
@@ -153,6 +162,17 @@ func NewECA() *ECA {
 		eca.registerUser(id, role, vals[1])
 	}
 
+	if _, err := eca.db.Exec("CREATE TABLE IF NOT EXISTS Auditors (row INTEGER PRIMARY KEY, id VARCHAR(64), chaincode VARCHAR(256))"); err != nil {
+		Panic.Panicln(err)
+	}
+	eca.populateAuditors()
+
+	registry, err := openRegistry()
+	if err != nil {
+		Panic.Panicln(err)
+	}
+	eca.registry = registry
+
 	return eca
 }
 
@@ -178,7 +198,7 @@ func (eca *ECA) startECAA(srv *grpc.Server) {
 func (ecap *ECAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc ECAP:ReadCACertificate")
 
-	return &pb.Cert{ecap.eca.raw}, nil
+	return &pb.Cert{Cert: ecap.eca.raw, Chain: ecap.eca.chain}, nil
 }
 
 // CreateCertificatePair requests the creation of a new enrollment certificate pair by the ECA.
@@ -192,6 +212,11 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 
 	id := in.Id.Id
 	err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev)
+	if err == sql.ErrNoRows && ecap.eca.registry != nil {
+		// users of the registry authenticate with their registry secret
+		role, err = ecap.eca.registerFromRegistry(id, string(in.Tok.Tok))
+		tok = in.Tok.Tok
+	}
 	if err != nil || !bytes.Equal(tok, in.Tok.Tok) {
 		return nil, errors.New("identity or token do not match")
 	}
@@ -206,11 +231,16 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		// initial request, create encryption challenge
 		tok = []byte(randomString(12))
 
-		_, err = ecap.eca.db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=?", tok, 1, in.Enc.Key, id)
+		// conditional on the state, CA instances sharing the database may
+		// serve concurrent requests of the same user
+		res, err := ecap.eca.db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=? AND state=?", tok, 1, in.Enc.Key, id, 0)
 		if err != nil {
 			Error.Println(err)
 			return nil, err
 		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			return nil, errors.New("enrollment of " + id + " is already in progress")
+		}
 
 		//		out, err := rsa.EncryptPKCS1v15(rand.Reader, ekey.(*rsa.PublicKey), tok)
 		spi := ecies.NewSPI()
@@ -259,58 +289,87 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		// create new certificate pair
 		ts := time.Now().Add(-1 * time.Minute).UnixNano()
 
-		sraw, err := ecap.eca.createCertificate(id, skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, ts, nil, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		sraw, eraw, err := ecap.eca.createCertificatePair(id, skey.(*ecdsa.PublicKey), ekey.(*ecdsa.PublicKey), ts)
 		if err != nil {
-			Error.Println(err)
 			return nil, err
 		}
 
-		eraw, err := ecap.eca.createCertificate(id, ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, ts, nil, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
-		if err != nil {
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
-			Error.Println(err)
-			return nil, err
+		res, err := ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=? AND state=?", 2, id, 1)
+		if err == nil {
+			if n, _ := res.RowsAffected(); n != 1 {
+				err = errors.New(id + " has been enrolled concurrently")
+			}
 		}
-
-		_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
 		if err != nil {
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+			ecap.eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
 			Error.Println(err)
 			return nil, err
 		}
+		audit.Record(audit.CategoryIdentity, id, "enroll", "role "+strconv.Itoa(role), nil)
 
-		var obcECKey []byte
-		if role&(int(pb.Role_VALIDATOR)|int(pb.Role_AUDITOR)) != 0 {
-			obcECKey = ecap.eca.obcPriv
-		} else {
-			obcECKey = ecap.eca.obcPub
-		}
-
-		return &pb.ECertCreateResp{&pb.CertPair{sraw, eraw}, &pb.Token{ecap.eca.obcKey}, obcECKey, nil}, nil
+		return ecap.eca.enrollmentResponse(role, sraw, eraw), nil
 	}
 
 	return nil, errors.New("certificate creation token expired")
 }
 
+// createCertificatePair issues a signing and an encryption certificate for id at
+// timestamp ts.  Nothing is stored if either of the certificates cannot be created.
+//
+func (eca *ECA) createCertificatePair(id string, skey, ekey *ecdsa.PublicKey, ts int64) ([]byte, []byte, error) {
+	role := pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(eca.readRole(id)))}
+
+	sraw, err := eca.createCertificate(id, skey, x509.KeyUsageDigitalSignature, ts, nil, role)
+	if err != nil {
+		Error.Println(err)
+		return nil, nil, err
+	}
+
+	eraw, err := eca.createCertificate(id, ekey, x509.KeyUsageDataEncipherment, ts, nil, role)
+	if err != nil {
+		eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
+		Error.Println(err)
+		return nil, nil, err
+	}
+
+	return sraw, eraw, nil
+}
+
+// enrollmentResponse returns a freshly issued certificate pair together with the chain
+// keys the role of the enrolled user is entitled to.
+//
+func (eca *ECA) enrollmentResponse(role int, sraw, eraw []byte) *pb.ECertCreateResp {
+	var obcECKey []byte
+	if role&(int(pb.Role_VALIDATOR)|int(pb.Role_AUDITOR)) != 0 {
+		obcECKey = eca.obcPriv
+	} else {
+		obcECKey = eca.obcPub
+	}
+
+	// auditors do not get the chain key, they read the keys of the transactions
+	// of the chaincodes designated to them through ECAP:ReadAuditKeys instead
+	if role == int(pb.Role_AUDITOR) {
+		return &pb.ECertCreateResp{Certs: &pb.CertPair{Sign: sraw, Enc: eraw}, Pkchain: eca.obcPub}
+	}
+
+	return &pb.ECertCreateResp{&pb.CertPair{sraw, eraw}, &pb.Token{eca.obcKey}, obcECKey, nil}
+}
+
 // ReadCertificatePair reads an enrollment certificate pair from the ECA.
 //
 func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq) (*pb.CertPair, error) {
 	Trace.Println("grpc ECAP:ReadCertificate")
 
-	rows, err := ecap.eca.readCertificates(in.Id.Id)
-	defer rows.Close()
-
-	var certs [][]byte
-	if err == nil {
-		for rows.Next() {
-			var raw []byte
-			err = rows.Scan(&raw)
-			certs = append(certs, raw)
-		}
-		err = rows.Err()
+	sraw, err := ecap.eca.readCertificate(in.Id.Id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return nil, err
+	}
+	eraw, err := ecap.eca.readCertificate(in.Id.Id, x509.KeyUsageDataEncipherment)
+	if err != nil {
+		return nil, err
 	}
 
-	return &pb.CertPair{certs[0], certs[1]}, err
+	return &pb.CertPair{sraw, eraw}, nil
 }
 
 // ReadCertificateByHash reads a single enrollment certificate by hash from the ECA.
@@ -319,15 +378,149 @@ func (ecap *ECAP) ReadCertificateByHash(ctx context.Context, hash *pb.Hash) (*pb
 	Trace.Println("grpc ECAP:ReadCertificateByHash")
 
 	raw, err := ecap.eca.readCertificateByHash(hash.Hash)
-	return &pb.Cert{raw}, err
+	return &pb.Cert{Cert: raw}, err
 }
 
-// RevokeCertificatePair revokes a certificate pair from the ECA.  Not yet implemented.
+// RevokeCertificatePair revokes a certificate pair from the ECA.  Users can only revoke
+// their own certificate pair.
 //
-func (ecap *ECAP) RevokeCertificatePair(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecap *ECAP) RevokeCertificatePair(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc ECAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.verifySignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	if in.Cert != nil {
+		owner, err := ecap.eca.readCertificateOwner(in.Cert.Cert)
+		if err != nil {
+			return nil, err
+		}
+		if owner != id {
+			return nil, errors.New("access denied")
+		}
+	}
+
+	err := ecap.eca.revokeUser(id)
+	audit.Record(audit.CategoryIdentity, id, "revoke", id, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// RenewCertificatePair re-enrolls a user with new keys.  The request has to be signed
+// both with the current enrollment key and with the new signing key.  A new certificate
+// pair is issued for the same enrollment ID and the current pair is revoked, so that
+// nodes can recover from a key compromise without registering a new identity.
+//
+func (ecap *ECAP) RenewCertificatePair(ctx context.Context, in *pb.ECertRenewReq) (*pb.ECertCreateResp, error) {
+	Trace.Println("grpc ECAP:RenewCertificatePair")
+
+	id := in.Id.Id
+
+	var tok, prev []byte
+	var role, state int
+	if err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev); err != nil {
+		return nil, errors.New("unknown identity")
+	}
+	if state != 2 {
+		return nil, errors.New("identity is not enrolled")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecap.eca.verifySignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	if in.Sign == nil || in.Enc == nil || in.NewSig == nil {
+		return nil, errors.New("incomplete renewal request")
+	}
+	if in.Sign.Type != pb.CryptoType_ECDSA || in.Enc.Type != pb.CryptoType_ECDSA {
+		return nil, errors.New("unsupported key type")
+	}
+	skey, err := x509.ParsePKIXPublicKey(in.Sign.Key)
+	if err != nil {
+		return nil, err
+	}
+	ekey, err := x509.ParsePKIXPublicKey(in.Enc.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// prove possession of the new signing key
+	newSig := in.NewSig
+	in.NewSig = nil
+
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(newSig.R)
+	s.UnmarshalText(newSig.S)
+
+	hash := utils.NewHash()
+	raw, _ := proto.Marshal(in)
+	hash.Write(raw)
+	if ecdsa.Verify(skey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		return nil, errors.New("signature does not verify")
+	}
+
+	var prevTs int64
+	err = ecap.eca.db.QueryRow("SELECT timestamp FROM Certificates WHERE id=? AND usage=? ORDER BY row DESC LIMIT 1", id, x509.KeyUsageDigitalSignature).Scan(&prevTs)
+	if err != nil {
+		return nil, err
+	}
+
+	// claim the renewal, so that concurrent renewals of the same pair through
+	// CA instances sharing the database do not both succeed
+	var res sql.Result
+	if prev == nil {
+		res, err = ecap.eca.db.Exec("UPDATE Users SET key=? WHERE id=? AND state=? AND key IS NULL", in.Enc.Key, id, 2)
+	} else {
+		res, err = ecap.eca.db.Exec("UPDATE Users SET key=? WHERE id=? AND state=? AND key=?", in.Enc.Key, id, 2, prev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return nil, errors.New("enrollment of " + id + " has been renewed concurrently")
+	}
+
+	ts := time.Now().Add(-1 * time.Minute).UnixNano()
+	if ts == prevTs {
+		ts++
+	}
+	sraw, eraw, err := ecap.eca.createCertificatePair(id, skey.(*ecdsa.PublicKey), ekey.(*ecdsa.PublicKey), ts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ecap.eca.revokeCertificates(id, prevTs)
+	audit.Record(audit.CategoryIdentity, id, "renew", "role "+strconv.Itoa(role), err)
+	if err != nil {
+		ecap.eca.db.Exec("DELETE FROM Certificates Where id=? AND timestamp=?", id, ts)
+		return nil, err
+	}
+
+	return ecap.eca.enrollmentResponse(role, sraw, eraw), nil
+}
+
+// ReadCRL returns the current certificate revocation list of the ECA.
+//
+func (ecap *ECAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc ECAP:ReadCRL")
+
+	raw, err := ecap.eca.createCRL()
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CRL{Crl: raw}, nil
 }
 
 // RegisterUser registers a new user with the ECA.  If the user had been registered before
@@ -337,6 +530,7 @@ func (ecaa *ECAA) RegisterUser(ctx context.Context, in *pb.RegisterUserReq) (*pb
 	Trace.Println("grpc ECAA:RegisterUser")
 
 	tok, err := ecaa.eca.registerUser(in.Id.Id, int(in.Role))
+	audit.Record(audit.CategoryIdentity, "ECAA", "register", in.Id.Id+" as "+in.Role.String(), err)
 
 	return &pb.Token{[]byte(tok)}, err
 }
@@ -395,15 +589,42 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	return &pb.UserSet{users}, err
 }
 
-// RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
+// RevokeCertificate revokes the certificate pair a given enrollment certificate belongs
+// to.  Only auditors can revoke certificates of other users.
 //
-func (ecaa *ECAA) RevokeCertificate(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc ECAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	req := in.Id.Id
+	if ecaa.eca.readRole(req)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("access denied")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.verifySignature(req, in, sig); err != nil {
+		return nil, err
+	}
+
+	if in.Cert == nil {
+		return nil, errors.New("no certificate to revoke")
+	}
+	owner, err := ecaa.eca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ecaa.eca.revokeUser(owner)
+	audit.Record(audit.CategoryIdentity, req, "revoke", owner, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the ECA.  Not yet implemented.
+// PublishCRL requests the publication of the certificate revocation list of the ECA in
+// the blockchain.  Not yet implemented; peers fetch the CRL through ECAP:ReadCRL instead.
 //
 func (ecaa *ECAA) PublishCRL(context.Context, *pb.ECertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc ECAA:CreateCRL")
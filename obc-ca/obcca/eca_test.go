@@ -0,0 +1,153 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestRenewCertificatePair(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-renew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+
+	eca := NewECA()
+	defer eca.Close()
+	ecap := &ECAP{eca}
+
+	// enroll a validator directly, bypassing the enrollment challenge
+	id := "renewvp"
+	if _, err := eca.registerUser(id, int(pb.Role_VALIDATOR)); err != nil {
+		t.Fatalf("Failed registering user: %s", err)
+	}
+	priv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sraw, eraw, err := eca.createCertificatePair(id, &priv.PublicKey, &priv.PublicKey, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("Failed creating certificate pair: %s", err)
+	}
+
+	newPriv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renewal is refused before the enrollment has completed
+	req := newRenewRequest(t, id, priv, newPriv)
+	if _, err := ecap.RenewCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Renewal of an identity that is not enrolled should fail")
+	}
+	if _, err := eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// both the current and the new key have to sign the request
+	req = newRenewRequest(t, id, newPriv, newPriv)
+	if _, err := ecap.RenewCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Renewal signed without the current key should fail")
+	}
+	req = newRenewRequest(t, id, priv, priv)
+	req.Sign.Key, _ = x509.MarshalPKIXPublicKey(&newPriv.PublicKey)
+	if _, err := ecap.RenewCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Renewal without proof of possession of the new key should fail")
+	}
+
+	req = newRenewRequest(t, id, priv, newPriv)
+	resp, err := ecap.RenewCertificatePair(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed renewing certificate pair: %s", err)
+	}
+	if resp.Certs == nil || resp.Chain == nil || len(resp.Pkchain) == 0 {
+		t.Fatal("Renewal did not return a certificate pair with the chain keys")
+	}
+
+	scert, err := x509.ParseCertificate(resp.Certs.Sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scert.Subject.CommonName != id {
+		t.Fatalf("Renewed certificate was issued for %s instead of %s", scert.Subject.CommonName, id)
+	}
+	if scert.PublicKey.(*ecdsa.PublicKey).X.Cmp(newPriv.PublicKey.X) != 0 {
+		t.Fatal("Renewed certificate does not certify the new key")
+	}
+
+	pair, err := ecap.ReadCertificatePair(context.Background(), &pb.ECertReadReq{Id: &pb.Identity{Id: id}})
+	if err != nil {
+		t.Fatalf("Failed reading certificate pair: %s", err)
+	}
+	if !bytes.Equal(pair.Sign, resp.Certs.Sign) || !bytes.Equal(pair.Enc, resp.Certs.Enc) {
+		t.Fatal("ECA does not serve the renewed certificate pair")
+	}
+
+	oldS, _ := x509.ParseCertificate(sraw)
+	oldE, _ := x509.ParseCertificate(eraw)
+	crl := readCRL(t, eca.cert, ecap.ReadCRL)
+	if !crl[oldS.SerialNumber.String()] || !crl[oldE.SerialNumber.String()] || len(crl) != 2 {
+		t.Fatalf("ECA CRL does not hold the replaced certificate pair: %v", crl)
+	}
+
+	// the old key is no longer accepted
+	req = newRenewRequest(t, id, priv, newPriv)
+	if _, err := ecap.RenewCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Renewal signed with a revoked key should fail")
+	}
+}
+
+func newRenewRequest(t *testing.T, id string, priv, newPriv *ecdsa.PrivateKey) *pb.ECertRenewReq {
+	req := &pb.ECertRenewReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+		Id:   &pb.Identity{Id: id},
+		Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA},
+	}
+	req.Sign.Key, _ = x509.MarshalPKIXPublicKey(&newPriv.PublicKey)
+	req.Enc.Key, _ = x509.MarshalPKIXPublicKey(&newPriv.PublicKey)
+
+	signRequest(t, newPriv, req, func(sig *pb.Signature) { req.NewSig = sig })
+	signRequest(t, priv, req, func(sig *pb.Signature) { req.Sig = sig })
+
+	return req
+}
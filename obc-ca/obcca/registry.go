@@ -0,0 +1,191 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/audit"
+)
+
+// Registry is an identity provider, such as an LDAP directory or an external
+// user database, that holds users in addition to those registered with the
+// ECA.  Users of the registry are registered with the ECA on their first
+// enrollment, and their attributes are embedded in their TCerts.
+//
+type Registry interface {
+	// Authenticate verifies the enrollment secret of a user.
+	Authenticate(id, secret string) error
+
+	// Lookup returns the groups and attributes of a user.
+	Lookup(id string) (*RegistryEntry, error)
+}
+
+// RegistryEntry describes a user of a registry.
+//
+type RegistryEntry struct {
+	Groups     []string
+	Attributes map[string]string
+}
+
+// RegistryProvider opens a registry configured under key, which is
+// eca.registry.<name> for the provider registered as name.
+//
+type RegistryProvider func(key string) (Registry, error)
+
+var (
+	registryProviders = make(map[string]RegistryProvider)
+	registryLock      sync.Mutex
+)
+
+// RegisterRegistry makes a registry provider available under name.
+//
+func RegisterRegistry(name string, provider RegistryProvider) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registryProviders[name] = provider
+}
+
+// openRegistry opens the registry of eca.registry.provider, or returns nil if
+// the users of the ECA are configured in eca.users only.
+//
+func openRegistry() (Registry, error) {
+	name := GetConfigString("eca.registry.provider")
+	if name == "" {
+		return nil, nil
+	}
+
+	registryLock.Lock()
+	provider, ok := registryProviders[name]
+	registryLock.Unlock()
+	if !ok {
+		return nil, errors.New("registry provider " + name + " not available")
+	}
+
+	registry, err := provider("eca.registry." + name)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := viper.GetDuration("eca.registry.cache")
+	if ttl <= 0 {
+		return registry, nil
+	}
+
+	return &cachingRegistry{Registry: registry, ttl: ttl, entries: make(map[string]cachedEntry)}, nil
+}
+
+// registerFromRegistry registers a user of the registry with the ECA once it
+// has authenticated with its registry secret, and returns its role.
+//
+func (eca *ECA) registerFromRegistry(id, secret string) (int, error) {
+	if err := eca.registry.Authenticate(id, secret); err != nil {
+		return 0, err
+	}
+
+	entry, err := eca.registry.Lookup(id)
+	if err != nil {
+		return 0, err
+	}
+	role, err := registryRole(entry)
+	if err != nil {
+		return 0, err
+	}
+	if role == 0 {
+		return 0, errors.New(id + " is not a member of a group with a role")
+	}
+
+	_, err = eca.registerUser(id, role)
+	audit.Record(audit.CategoryIdentity, "registry", "register", id+" as role "+strconv.Itoa(role), err)
+	if err != nil {
+		return 0, err
+	}
+
+	return role, nil
+}
+
+// registryRole maps the groups of a registry user to a role through
+// eca.registry.roles, OR-ing the roles of all its groups.  Group names are
+// matched case insensitively.
+//
+func registryRole(entry *RegistryEntry) (int, error) {
+	roles := viper.GetStringMapString("eca.registry.roles")
+
+	role := 0
+	for _, group := range entry.Groups {
+		val, ok := roles[strings.ToLower(group)]
+		if !ok {
+			continue
+		}
+		r, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return 0, errors.New("invalid role " + val + " of group " + group)
+		}
+		role |= r
+	}
+
+	return role, nil
+}
+
+// cachedEntry is a registry entry looked up at a point in time.
+//
+type cachedEntry struct {
+	entry   *RegistryEntry
+	expires time.Time
+}
+
+// cachingRegistry caches the lookups of a registry for the duration of
+// eca.registry.cache, as the TCA reads the attributes of a user for every
+// TCert request.  Authentication always goes to the registry.
+//
+type cachingRegistry struct {
+	Registry
+	ttl time.Duration
+
+	m       sync.Mutex
+	entries map[string]cachedEntry
+}
+
+func (r *cachingRegistry) Lookup(id string) (*RegistryEntry, error) {
+	r.m.Lock()
+	cached, ok := r.entries[id]
+	r.m.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.entry, nil
+	}
+
+	entry, err := r.Registry.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.m.Lock()
+	r.entries[id] = cachedEntry{entry: entry, expires: time.Now().Add(r.ttl)}
+	r.m.Unlock()
+
+	return entry, nil
+}
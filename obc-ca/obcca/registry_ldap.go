@@ -0,0 +1,204 @@
+// +build ldap
+
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/ldap.v2"
+)
+
+// The LDAP registry needs the gopkg.in/ldap.v2 package and is only compiled in
+// with -tags ldap.
+
+func init() {
+	RegisterRegistry("ldap", newLDAPRegistry)
+}
+
+// ldapRegistry reads the users of an LDAP directory as configured in
+// eca.registry.ldap.  Users are searched below base with filter, where %s is
+// replaced by the enrollment ID, and authenticate by binding with their DN.
+// Their groups are the names of the DNs in the group attribute, and their
+// attributes are mapped from directory attributes as
+//
+//     <name>=<directory attribute>;<name>=<directory attribute>
+//
+type ldapRegistry struct {
+	url                  *url.URL
+	bindDN, bindPassword string
+	base, filter, group  string
+	attributes           map[string]string
+}
+
+func newLDAPRegistry(key string) (Registry, error) {
+	u, err := url.Parse(viper.GetString(key + ".url"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ldap" && u.Scheme != "ldaps" {
+		return nil, errors.New("unsupported LDAP URL " + u.String())
+	}
+
+	r := &ldapRegistry{
+		url:          u,
+		bindDN:       viper.GetString(key + ".binddn"),
+		bindPassword: viper.GetString(key + ".bindpassword"),
+		base:         viper.GetString(key + ".base"),
+		filter:       viper.GetString(key + ".filter"),
+		group:        viper.GetString(key + ".group"),
+		attributes:   make(map[string]string),
+	}
+	if r.filter == "" {
+		r.filter = "(uid=%s)"
+	}
+
+	if flds := viper.GetString(key + ".attributes"); flds != "" {
+		for _, fld := range strings.Split(flds, ";") {
+			kv := strings.SplitN(strings.TrimSpace(fld), "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, errors.New("invalid attribute mapping " + fld)
+			}
+			r.attributes[kv[0]] = kv[1]
+		}
+	}
+
+	return r, nil
+}
+
+// connect opens a connection to the directory bound with the search account.
+//
+func (r *ldapRegistry) connect() (*ldap.Conn, error) {
+	host := r.url.Host
+	var conn *ldap.Conn
+	var err error
+	if r.url.Scheme == "ldaps" {
+		if !strings.Contains(host, ":") {
+			host += ":636"
+		}
+		conn, err = ldap.DialTLS("tcp", host, &tls.Config{ServerName: strings.Split(host, ":")[0]})
+	} else {
+		if !strings.Contains(host, ":") {
+			host += ":389"
+		}
+		conn, err = ldap.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.bindDN != "" {
+		if err = conn.Bind(r.bindDN, r.bindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// search returns the directory entry of a user.
+//
+func (r *ldapRegistry) search(conn *ldap.Conn, id string) (*ldap.Entry, error) {
+	var attrs []string
+	if r.group != "" {
+		attrs = append(attrs, r.group)
+	}
+	for _, attr := range r.attributes {
+		attrs = append(attrs, attr)
+	}
+
+	req := ldap.NewSearchRequest(r.base, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(r.filter, ldap.EscapeFilter(id)), attrs, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) != 1 {
+		return nil, errors.New("user " + id + " not found in the directory")
+	}
+
+	return res.Entries[0], nil
+}
+
+func (r *ldapRegistry) Authenticate(id, secret string) error {
+	// an empty password would be an unauthenticated bind
+	if secret == "" {
+		return errors.New("identity or token do not match")
+	}
+
+	conn, err := r.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	entry, err := r.search(conn, id)
+	if err != nil {
+		return err
+	}
+
+	return conn.Bind(entry.DN, secret)
+}
+
+func (r *ldapRegistry) Lookup(id string) (*RegistryEntry, error) {
+	conn, err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := r.search(conn, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RegistryEntry{Attributes: make(map[string]string)}
+	if r.group != "" {
+		for _, dn := range entry.GetAttributeValues(r.group) {
+			res.Groups = append(res.Groups, groupName(dn))
+		}
+	}
+	for name, attr := range r.attributes {
+		if value := entry.GetAttributeValue(attr); value != "" {
+			res.Attributes[name] = value
+		}
+	}
+
+	return res, nil
+}
+
+// groupName returns the value of the first RDN of a group DN, such as admins
+// for cn=admins,ou=groups,dc=example,dc=com.
+//
+func groupName(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	if kv := strings.SplitN(rdn, "=", 2); len(kv) == 2 {
+		return strings.TrimSpace(kv[1])
+	}
+
+	return dn
+}
@@ -0,0 +1,128 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	RegisterRegistry("sql", newSQLRegistry)
+}
+
+// sqlRegistry reads the users of an external database through the queries
+// configured in eca.registry.sql.  As for the certificate database, drivers
+// other than sqlite3 have to be linked into the CA.
+//
+//     authenticate: returns a row given the id and the secret of a user
+//     groups:       returns the group names of a user given its id
+//     attributes:   returns the attribute names and values of a user given its id
+//
+type sqlRegistry struct {
+	db                               *sql.DB
+	authenticate, groups, attributes string
+}
+
+func newSQLRegistry(key string) (Registry, error) {
+	r := &sqlRegistry{
+		authenticate: viper.GetString(key + ".authenticate"),
+		groups:       viper.GetString(key + ".groups"),
+		attributes:   viper.GetString(key + ".attributes"),
+	}
+	if r.authenticate == "" {
+		return nil, errors.New(key + ".authenticate is not configured")
+	}
+
+	db, err := sql.Open(viper.GetString(key+".driver"), viper.GetString(key+".datasource"))
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	r.db = db
+
+	return r, nil
+}
+
+func (r *sqlRegistry) Authenticate(id, secret string) error {
+	rows, err := r.db.Query(r.authenticate, id, secret)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		return errors.New("identity or token do not match")
+	}
+
+	return nil
+}
+
+func (r *sqlRegistry) Lookup(id string) (*RegistryEntry, error) {
+	entry := &RegistryEntry{Attributes: make(map[string]string)}
+
+	if r.groups != "" {
+		rows, err := r.db.Query(r.groups, id)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var group string
+			if err = rows.Scan(&group); err != nil {
+				return nil, err
+			}
+			entry.Groups = append(entry.Groups, group)
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.attributes != "" {
+		rows, err := r.db.Query(r.attributes, id)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name, value string
+			if err = rows.Scan(&name, &value); err != nil {
+				return nil, err
+			}
+			entry.Attributes[name] = value
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,145 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestSQLRegistry(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// external user database
+	source := filepath.Join(dir, "users.db")
+	db, err := sql.Open("sqlite3", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE users (id VARCHAR(64), secret VARCHAR(64))",
+		"CREATE TABLE groups (id VARCHAR(64), name VARCHAR(64))",
+		"CREATE TABLE attributes (id VARCHAR(64), name VARCHAR(64), value VARCHAR(64))",
+		"INSERT INTO users VALUES ('alice', 'alicepw'), ('bob', 'bobpw')",
+		"INSERT INTO groups VALUES ('alice', 'Validators'), ('alice', 'Clients'), ('bob', 'visitors')",
+		"INSERT INTO attributes VALUES ('alice', 'company', 'ACompany'), ('alice', 'position', 'Engineer')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rootpath := viper.GetString("server.rootpath")
+	viper.Set("server.rootpath", dir)
+	defer viper.Set("server.rootpath", rootpath)
+	viper.Set("eca.registry.provider", "sql")
+	defer viper.Set("eca.registry.provider", "")
+	viper.Set("eca.registry.cache", "1m")
+	viper.Set("eca.registry.roles", map[string]string{"validators": "4", "clients": "1"})
+	viper.Set("eca.registry.sql.driver", "sqlite3")
+	viper.Set("eca.registry.sql.datasource", source)
+	viper.Set("eca.registry.sql.authenticate", "SELECT id FROM users WHERE id=? AND secret=?")
+	viper.Set("eca.registry.sql.groups", "SELECT name FROM groups WHERE id=?")
+	viper.Set("eca.registry.sql.attributes", "SELECT name, value FROM attributes WHERE id=?")
+
+	eca := NewECA()
+	defer eca.Close()
+	tca := NewTCA(eca)
+	defer tca.Close()
+	ecap := &ECAP{eca}
+
+	priv, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	newRequest := func(id, secret string) *pb.ECertCreateReq {
+		return &pb.ECertCreateReq{
+			Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+			Id:   &pb.Identity{Id: id},
+			Tok:  &pb.Token{Tok: []byte(secret)},
+			Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: key},
+			Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA, Key: key},
+		}
+	}
+
+	if _, err := ecap.CreateCertificatePair(context.Background(), newRequest("alice", "wrong")); err == nil {
+		t.Fatal("Enrollment with a wrong registry secret should fail")
+	}
+	if _, err := ecap.CreateCertificatePair(context.Background(), newRequest("bob", "bobpw")); err == nil {
+		t.Fatal("Enrollment of a registry user without a role should fail")
+	}
+	if _, err := ecap.CreateCertificatePair(context.Background(), newRequest("carol", "")); err == nil {
+		t.Fatal("Enrollment of an unknown user should fail")
+	}
+
+	// the first enrollment registers the user with the roles of its groups
+	resp, err := ecap.CreateCertificatePair(context.Background(), newRequest("alice", "alicepw"))
+	if err != nil {
+		t.Fatalf("Failed enrolling registry user: %s", err)
+	}
+	if resp.Tok == nil {
+		t.Fatal("Expected an enrollment challenge")
+	}
+	if role := eca.readRole("alice"); role != int(pb.Role_VALIDATOR|pb.Role_CLIENT) {
+		t.Fatalf("Expected role %d, got %d", pb.Role_VALIDATOR|pb.Role_CLIENT, role)
+	}
+
+	// registry attributes are overridden by those registered with the TCA
+	if err := tca.setAttribute("alice", "position", "Manager"); err != nil {
+		t.Fatal(err)
+	}
+	attributes, err := tca.readAttributes("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attributes["company"] != "ACompany" || attributes["position"] != "Manager" {
+		t.Fatalf("Unexpected attributes %v", attributes)
+	}
+
+	// lookups are cached
+	if _, err := db.Exec("DELETE FROM attributes WHERE id='alice'"); err != nil {
+		t.Fatal(err)
+	}
+	if attributes, err = tca.readAttributes("alice"); err != nil || attributes["company"] != "ACompany" {
+		t.Fatalf("Expected cached registry attributes, got %v: %v", attributes, err)
+	}
+}
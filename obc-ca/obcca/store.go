@@ -0,0 +1,130 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// openDatabase opens the certificate database of the CA name.  By default
+// each CA keeps its database in a SQLite file in its state directory.  Several
+// CA instances form a cluster by opening the same, replicated database through
+// server.database.driver and server.database.datasource, where {name} is
+// replaced by the name of the CA.
+//
+func openDatabase(path, name string) (*sql.DB, error) {
+	driver := GetConfigString("server.database.driver")
+	if driver == "" {
+		driver = "sqlite3"
+	}
+
+	source := GetConfigString("server.database.datasource")
+	if source == "" {
+		source = path + "/" + name + ".db"
+	} else {
+		source = strings.Replace(source, "{name}", name, -1)
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// sharedDatabase returns whether the CA instances of a cluster share their
+// database, in which case the keys and certificates of the CAs are kept in
+// the database rather than in the state directory of each instance.
+//
+func sharedDatabase() bool {
+	return viper.GetBool("server.database.shared")
+}
+
+// initSchema creates the tables of the CA.  The unique indexes let concurrent
+// CA instances detect conflicting registrations and revocations.
+//
+func initSchema(db *sql.DB) error {
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)",
+		"CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), role INTEGER, token BLOB, state INTEGER, key BLOB)",
+		"CREATE TABLE IF NOT EXISTS Revocations (row INTEGER PRIMARY KEY, serial VARCHAR(64), timestamp INTEGER)",
+		"CREATE TABLE IF NOT EXISTS Materials (name VARCHAR(64) PRIMARY KEY, value BLOB)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS UsersByID ON Users (id)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS RevocationsBySerial ON Revocations (serial)",
+		"CREATE INDEX IF NOT EXISTS CertificatesByHash ON Certificates (hash)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMaterial reads a key or certificate of the CA.  In a shared database the
+// value stored there is authoritative and mirrored into the state directory,
+// where the TLS configuration and the tools of the instance expect it.
+//
+func (ca *CA) readMaterial(file string) ([]byte, error) {
+	if !sharedDatabase() {
+		return ioutil.ReadFile(ca.path + "/" + file)
+	}
+
+	var raw []byte
+	if err := ca.db.QueryRow("SELECT value FROM Materials WHERE name=?", file).Scan(&raw); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(ca.path+"/"+file, raw, 0644); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// storeMaterial stores a newly created key or certificate of the CA and returns
+// the value to use.  When instances sharing a database start up concurrently,
+// the first one to store a value wins and the others adopt it.
+//
+func (ca *CA) storeMaterial(file string, raw []byte) ([]byte, error) {
+	if !sharedDatabase() {
+		return raw, ioutil.WriteFile(ca.path+"/"+file, raw, 0644)
+	}
+
+	if _, err := ca.db.Exec("INSERT INTO Materials (name, value) VALUES (?, ?)", file, raw); err != nil {
+		Info.Println("Adopting " + file + " stored by another CA instance.")
+	}
+
+	stored, err := ca.readMaterial(file)
+	if err != nil {
+		return nil, errors.New("failed storing " + file + ": " + err.Error())
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,143 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	ecies "github.com/openblockchain/obc-peer/openchain/crypto/ecies/generic"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+func TestSharedDatabase(t *testing.T) {
+	LogInit(ioutil.Discard, ioutil.Discard, os.Stdout, os.Stderr, os.Stdout)
+	conf.InitSecurityLevel(256)
+
+	dir, err := ioutil.TempDir("", "obcca-cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rootpath := viper.GetString("server.rootpath")
+	defer viper.Set("server.rootpath", rootpath)
+	viper.Set("server.database.datasource", filepath.Join(dir, "{name}.db"))
+	defer viper.Set("server.database.datasource", "")
+	viper.Set("server.database.shared", true)
+	defer viper.Set("server.database.shared", false)
+
+	// two CA instances with their own state directories share the database
+	newInstance := func(name string) (*ECA, *TCA) {
+		viper.Set("server.rootpath", filepath.Join(dir, name))
+		eca := NewECA()
+		return eca, NewTCA(eca)
+	}
+	eca1, tca1 := newInstance("ca1")
+	defer eca1.Close()
+	defer tca1.Close()
+	eca2, tca2 := newInstance("ca2")
+	defer eca2.Close()
+	defer tca2.Close()
+
+	if !bytes.Equal(eca1.raw, eca2.raw) || !bytes.Equal(tca1.raw, tca2.raw) {
+		t.Fatal("CA instances sharing the database must share their certificates")
+	}
+	if !bytes.Equal(eca1.obcKey, eca2.obcKey) || !bytes.Equal(eca1.obcPriv, eca2.obcPriv) || !bytes.Equal(tca1.hmacKey, tca2.hmacKey) {
+		t.Fatal("CA instances sharing the database must share their keys")
+	}
+	if _, err := os.Stat(filepath.Join(eca2.path, "eca.cert")); err != nil {
+		t.Fatalf("Shared certificate was not mirrored into the state directory: %s", err)
+	}
+
+	// a user registered at one instance enrolls through both
+	id := "clusteruser"
+	tok, err := eca1.registerUser(id, int(pb.Role_CLIENT))
+	if err != nil {
+		t.Fatalf("Failed registering user: %s", err)
+	}
+	if _, err := eca2.registerUser(id, int(pb.Role_CLIENT)); err == nil {
+		t.Fatal("Registering a user twice through different instances should fail")
+	}
+
+	signPriv, _ := utils.NewECDSAKey()
+	encPriv, _ := utils.NewECDSAKey()
+	req := &pb.ECertCreateReq{
+		Ts:   &google_protobuf.Timestamp{Seconds: time.Now().Unix()},
+		Id:   &pb.Identity{Id: id},
+		Tok:  &pb.Token{Tok: []byte(tok)},
+		Sign: &pb.PublicKey{Type: pb.CryptoType_ECDSA},
+		Enc:  &pb.PublicKey{Type: pb.CryptoType_ECDSA},
+	}
+	req.Sign.Key, _ = x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	req.Enc.Key, _ = x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+
+	resp, err := (&ECAP{eca1}).CreateCertificatePair(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed requesting enrollment challenge: %s", err)
+	}
+	spi := ecies.NewSPI()
+	key, _ := spi.NewPrivateKey(nil, encPriv)
+	cipher, _ := spi.NewAsymmetricCipherFromPublicKey(key)
+	if req.Tok.Tok, err = cipher.Process(resp.Tok.Tok); err != nil {
+		t.Fatalf("Failed decrypting enrollment challenge: %s", err)
+	}
+	signRequest(t, signPriv, req, func(sig *pb.Signature) { req.Sig = sig })
+
+	resp, err = (&ECAP{eca2}).CreateCertificatePair(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed answering enrollment challenge at another instance: %s", err)
+	}
+	cert, err := x509.ParseCertificate(resp.Certs.Sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(eca1.cert); err != nil {
+		t.Fatalf("Certificate issued by one instance does not verify against another: %s", err)
+	}
+
+	// the enrollment is complete for every instance
+	req.Sig = nil
+	signRequest(t, signPriv, req, func(sig *pb.Signature) { req.Sig = sig })
+	if _, err := (&ECAP{eca1}).CreateCertificatePair(context.Background(), req); err == nil {
+		t.Fatal("Answering an enrollment challenge twice should fail")
+	}
+
+	// revocations through one instance are served by the others
+	revoke := &pb.ECertRevokeReq{Id: &pb.Identity{Id: id}, Cert: &pb.Cert{Cert: resp.Certs.Sign}}
+	signRequest(t, signPriv, revoke, func(sig *pb.Signature) { revoke.Sig = sig })
+	if _, err := (&ECAP{eca2}).RevokeCertificatePair(context.Background(), revoke); err != nil {
+		t.Fatalf("Failed revoking certificate pair: %s", err)
+	}
+	if crl := readCRL(t, eca1.cert, (&ECAP{eca1}).ReadCRL); !crl[cert.SerialNumber.String()] {
+		t.Fatal("Revocation through one instance is missing from the CRL of another")
+	}
+}
@@ -29,7 +29,6 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
-	"io/ioutil"
 	"math"
 	"math/big"
 	"strconv"
@@ -48,6 +47,10 @@ var (
 	// TCertEncTCertIndex is the ASN1 object identifier of the TCert index.
 	//
 	TCertEncTCertIndex = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7}
+
+	// TCertEncAttributes is the ASN1 object identifier of the encrypted attributes.
+	//
+	TCertEncAttributes = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 8}
 )
 
 // TCA is the transaction certificate authority.
@@ -77,16 +80,16 @@ func NewTCA(eca *ECA) *TCA {
 
 	tca := &TCA{NewCA("tca"), eca, nil}
 
-	raw, err := ioutil.ReadFile(tca.path + "/tca.hmac")
+	raw, err := tca.readMaterial("tca.hmac")
 	if err != nil {
 		key := make([]byte, 49)
 		rand.Reader.Read(key)
-		cooked = base64.StdEncoding.EncodeToString(key)
 
-		err = ioutil.WriteFile(tca.path+"/tca.hmac", []byte(cooked), 0644)
+		raw, err = tca.storeMaterial("tca.hmac", []byte(base64.StdEncoding.EncodeToString(key)))
 		if err != nil {
 			Panic.Panicln(err)
 		}
+		cooked = string(raw)
 	} else {
 		cooked = string(raw)
 	}
@@ -96,6 +99,11 @@ func NewTCA(eca *ECA) *TCA {
 		Panic.Panicln(err)
 	}
 
+	if _, err := tca.db.Exec("CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), name VARCHAR(64), value BLOB)"); err != nil {
+		Panic.Panicln(err)
+	}
+	tca.populateAttributes()
+
 	return tca
 }
 
@@ -128,7 +136,7 @@ func (tca *TCA) startTCAA(srv *grpc.Server) {
 func (tcap *TCAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc TCAP:ReadCACertificate")
 
-	return &pb.Cert{tcap.tca.raw}, nil
+	return &pb.Cert{Cert: tcap.tca.raw, Chain: tcap.tca.chain}, nil
 }
 
 // CreateCertificate requests the creation of a new transaction certificate by the TCA.
@@ -145,6 +153,9 @@ func (tcap *TCAP) CreateCertificate(ctx context.Context, in *pb.TCertCreateReq)
 	if err != nil {
 		return nil, err
 	}
+	if tcap.tca.eca.isRevoked(cert) {
+		return nil, errors.New("enrollment certificate has been revoked")
+	}
 
 	sig := in.Sig
 	in.Sig = nil
@@ -174,7 +185,7 @@ func (tcap *TCAP) CreateCertificate(ctx context.Context, in *pb.TCertCreateReq)
 		return nil, err
 	}
 
-	return &pb.TCertCreateResp{&pb.Cert{raw}}, nil
+	return &pb.TCertCreateResp{&pb.Cert{Cert: raw}}, nil
 }
 
 // CreateCertificateSet requests the creation of a new transaction certificate set by the TCA.
@@ -191,6 +202,9 @@ func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSe
 	if err != nil {
 		return nil, err
 	}
+	if tcap.tca.eca.isRevoked(cert) {
+		return nil, errors.New("enrollment certificate has been revoked")
+	}
 	pub := cert.PublicKey.(*ecdsa.PublicKey)
 
 	sig := in.Sig
@@ -222,6 +236,11 @@ func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSe
 	}
 	var set [][]byte
 
+	attributes, err := tcap.tca.readAttributes(id)
+	if err != nil {
+		return nil, err
+	}
+
 	for i := 0; i < num; i++ {
 		tidx := []byte(strconv.Itoa(i))
 		tidx = append(tidx[:], nonce[:]...)
@@ -249,7 +268,16 @@ func (tcap *TCAP) CreateCertificateSet(ctx context.Context, in *pb.TCertCreateSe
 			return nil, err
 		}
 
-		if raw, err = tcap.tca.createCertificate(id, &txPub, x509.KeyUsageDigitalSignature, in.Ts.Seconds, kdfKey, pkix.Extension{Id: TCertEncTCertIndex, Critical: true, Value: ext}); err != nil {
+		exts := []pkix.Extension{{Id: TCertEncTCertIndex, Critical: true, Value: ext}}
+		if len(attributes) > 0 {
+			attrs, err := utils.EncryptAttributes(utils.AttributesKey(kdfKey, tidx), attributes)
+			if err != nil {
+				return nil, err
+			}
+			exts = append(exts, pkix.Extension{Id: TCertEncAttributes, Critical: true, Value: attrs})
+		}
+
+		if raw, err = tcap.tca.createCertificate(id, &txPub, x509.KeyUsageDigitalSignature, in.Ts.Seconds, kdfKey, exts...); err != nil {
 			Error.Println(err)
 			return nil, err
 		}
@@ -303,7 +331,7 @@ func (tcap *TCAP) ReadCertificate(ctx context.Context, in *pb.TCertReadReq) (*pb
 		return nil, err
 	}
 
-	return &pb.Cert{raw}, nil
+	return &pb.Cert{Cert: raw}, nil
 }
 
 // ReadCertificateSet reads a transaction certificate set from the TCA.  Not yet implemented.
@@ -364,20 +392,86 @@ func (tcap *TCAP) ReadCertificateSet(ctx context.Context, in *pb.TCertReadSetReq
 	return &pb.CertSet{in.Ts, in.Id, kdfKey, certs}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
+// RevokeCertificate revokes a transaction certificate from the TCA.  Users can only
+// revoke their own certificates.
 //
-func (tcap *TCAP) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+func (tcap *TCAP) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcap.tca.eca.verifySignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	if in.Cert == nil {
+		return nil, errors.New("no certificate to revoke")
+	}
+	owner, err := tcap.tca.readCertificateOwner(in.Cert.Cert)
+	if err != nil {
+		return nil, err
+	}
+	if owner != id {
+		return nil, errors.New("access denied")
+	}
+
+	if err := tcap.tca.revokeCertificate(in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
+// RevokeCertificateSet revokes a transaction certificate set from the TCA.  If no timestamp
+// is given the most recently issued set is revoked.
 //
-func (tcap *TCAP) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+func (tcap *TCAP) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAP:RevokeCertificateSet")
 
-	return nil, errors.New("not yet implemented")
+	id := in.Id.Id
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcap.tca.eca.verifySignature(id, in, sig); err != nil {
+		return nil, err
+	}
+
+	var ts int64
+	if in.Ts != nil {
+		ts = in.Ts.Seconds
+	}
+	if ts == 0 {
+		if err := tcap.tca.db.QueryRow("SELECT MAX(timestamp) FROM Certificates WHERE id=?", id).Scan(&ts); err != nil {
+			return nil, errors.New("no certificates found for " + id)
+		}
+	}
+
+	if err := tcap.tca.revokeCertificates(id, ts); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// ReadCRL returns the current certificate revocation list of the TCA.
+//
+func (tcap *TCAP) ReadCRL(ctx context.Context, in *pb.Empty) (*pb.CRL, error) {
+	Trace.Println("grpc TCAP:ReadCRL")
+
+	if err := tcap.tca.revokeUserCertificates(); err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	raw, err := tcap.tca.createCRL()
+	if err != nil {
+		Error.Println(err)
+		return nil, err
+	}
+
+	return &pb.CRL{Crl: raw}, nil
 }
 
 // ReadCertificateSets returns all certificates matching the filter criteria of the request.
@@ -477,12 +571,31 @@ func (tcaa *TCAA) ReadCertificateSets(ctx context.Context, in *pb.TCertReadSetsR
 	return &pb.CertSets{sets}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
+// RevokeCertificate revokes any transaction certificate from the TCA.  Only auditors can
+// revoke certificates of other users.
 //
-func (tcaa *TCAA) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+func (tcaa *TCAA) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:RevokeCertificate")
 
-	return nil, errors.New("not yet implemented")
+	req := in.Id.Id
+	if tcaa.tca.eca.readRole(req)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("access denied")
+	}
+
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifySignature(req, in, sig); err != nil {
+		return nil, err
+	}
+
+	if in.Cert == nil {
+		return nil, errors.New("no certificate to revoke")
+	}
+	if err := tcaa.tca.revokeCertificate(in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
 // RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
@@ -493,7 +606,8 @@ func (tcaa *TCAA) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (
 	return nil, errors.New("not yet implemented")
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the TCA.  Not yet implemented.
+// PublishCRL requests the publication of the certificate revocation list of the TCA in
+// the blockchain.  Not yet implemented; peers fetch the CRL through TCAP:ReadCRL instead.
 //
 func (tcaa *TCAA) PublishCRL(context.Context, *pb.TCertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("grpc TCAA:CreateCRL")
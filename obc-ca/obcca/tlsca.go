@@ -81,7 +81,7 @@ func (tlsca *TLSCA) startTLSCAA(srv *grpc.Server) {
 func (tlscap *TLSCAP) ReadCACertificate(ctx context.Context, in *pb.Empty) (*pb.Cert, error) {
 	Trace.Println("grpc TLSCAP:ReadCACertificate")
 
-	return &pb.Cert{tlscap.tlsca.raw}, nil
+	return &pb.Cert{Cert: tlscap.tlsca.raw, Chain: tlscap.tlsca.chain}, nil
 }
 
 // CreateCertificate requests the creation of a new enrollment certificate by the TLSCA.
@@ -119,7 +119,7 @@ func (tlscap *TLSCAP) CreateCertificate(ctx context.Context, in *pb.TLSCertCreat
 		return nil, err
 	}
 
-	return &pb.TLSCertCreateResp{&pb.Cert{raw}, &pb.Cert{tlscap.tlsca.raw}}, nil
+	return &pb.TLSCertCreateResp{&pb.Cert{Cert: raw}, &pb.Cert{Cert: tlscap.tlsca.raw}}, nil
 }
 
 // ReadCertificate reads an enrollment certificate from the TLSCA.
@@ -132,7 +132,7 @@ func (tlscap *TLSCAP) ReadCertificate(ctx context.Context, in *pb.TLSCertReadReq
 		return nil, err
 	}
 
-	return &pb.Cert{raw}, nil
+	return &pb.Cert{Cert: raw}, nil
 }
 
 // RevokeCertificate revokes a certificate from the TLSCA.  Not yet implemented.
@@ -333,7 +333,10 @@ func startOpenchain(t *testing.T) error {
 	registerChaincodeSupport(chaincode.DefaultChain, grpcServer, secHelper)
 
 	// Register Devops server
-	serverDevops := openchain.NewDevopsServer(peerServer)
+	serverDevops, err := openchain.NewDevopsServer(peerServer)
+	if err != nil {
+		return err
+	}
 	pb.RegisterDevopsServer(grpcServer, serverDevops)
 
 	// Register the ServerOpenchain server
@@ -25,7 +25,12 @@ It has these top-level messages:
 	ECertCreateResp
 	ECertReadReq
 	ECertRevokeReq
+	ECertRenewReq
 	ECertCRLReq
+	ConfidentialTx
+	AuditKeysReq
+	AuditKeys
+	AuditAccessReq
 	TCertCreateReq
 	TCertCreateResp
 	TCertCreateSetReq
@@ -44,6 +49,7 @@ It has these top-level messages:
 	CertSet
 	CertSets
 	CertPair
+	CRL
 */
 package protos
 
@@ -97,6 +103,7 @@ const (
 	Role_PEER      Role = 2
 	Role_VALIDATOR Role = 4
 	Role_AUDITOR   Role = 8
+	Role_ADMIN     Role = 16
 	Role_ALL       Role = 65535
 )
 
@@ -106,6 +113,7 @@ var Role_name = map[int32]string{
 	2:     "PEER",
 	4:     "VALIDATOR",
 	8:     "AUDITOR",
+	16:    "ADMIN",
 	65535: "ALL",
 }
 var Role_value = map[string]int32{
@@ -114,6 +122,7 @@ var Role_value = map[string]int32{
 	"PEER":      2,
 	"VALIDATOR": 4,
 	"AUDITOR":   8,
+	"ADMIN":     16,
 	"ALL":       65535,
 }
 
@@ -422,6 +431,61 @@ func (m *ECertRevokeReq) GetSig() *Signature {
 	return nil
 }
 
+type ECertRenewReq struct {
+	Ts     *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id     *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Sign   *PublicKey                 `protobuf:"bytes,3,opt,name=sign" json:"sign,omitempty"`
+	Enc    *PublicKey                 `protobuf:"bytes,4,opt,name=enc" json:"enc,omitempty"`
+	NewSig *Signature                 `protobuf:"bytes,5,opt,name=newSig" json:"newSig,omitempty"`
+	Sig    *Signature                 `protobuf:"bytes,6,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *ECertRenewReq) Reset()         { *m = ECertRenewReq{} }
+func (m *ECertRenewReq) String() string { return proto.CompactTextString(m) }
+func (*ECertRenewReq) ProtoMessage()    {}
+
+func (m *ECertRenewReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetSign() *PublicKey {
+	if m != nil {
+		return m.Sign
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetEnc() *PublicKey {
+	if m != nil {
+		return m.Enc
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetNewSig() *Signature {
+	if m != nil {
+		return m.NewSig
+	}
+	return nil
+}
+
+func (m *ECertRenewReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
 type ECertCRLReq struct {
 	Id  *Identity  `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Sig *Signature `protobuf:"bytes,2,opt,name=sig" json:"sig,omitempty"`
@@ -445,6 +509,78 @@ func (m *ECertCRLReq) GetSig() *Signature {
 	return nil
 }
 
+type ConfidentialTx struct {
+	Nonce       []byte `protobuf:"bytes,1,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	ChaincodeID []byte `protobuf:"bytes,2,opt,name=chaincodeID,proto3" json:"chaincodeID,omitempty"`
+}
+
+func (m *ConfidentialTx) Reset()         { *m = ConfidentialTx{} }
+func (m *ConfidentialTx) String() string { return proto.CompactTextString(m) }
+func (*ConfidentialTx) ProtoMessage()    {}
+
+type AuditKeysReq struct {
+	Ts  *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
+	Id  *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Txs []*ConfidentialTx          `protobuf:"bytes,3,rep,name=txs" json:"txs,omitempty"`
+	Sig *Signature                 `protobuf:"bytes,4,opt,name=sig" json:"sig,omitempty"`
+}
+
+func (m *AuditKeysReq) Reset()         { *m = AuditKeysReq{} }
+func (m *AuditKeysReq) String() string { return proto.CompactTextString(m) }
+func (*AuditKeysReq) ProtoMessage()    {}
+
+func (m *AuditKeysReq) GetTs() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Ts
+	}
+	return nil
+}
+
+func (m *AuditKeysReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *AuditKeysReq) GetTxs() []*ConfidentialTx {
+	if m != nil {
+		return m.Txs
+	}
+	return nil
+}
+
+func (m *AuditKeysReq) GetSig() *Signature {
+	if m != nil {
+		return m.Sig
+	}
+	return nil
+}
+
+type AuditKeys struct {
+	Keys [][]byte `protobuf:"bytes,1,rep,name=keys,proto3" json:"keys,omitempty"`
+}
+
+func (m *AuditKeys) Reset()         { *m = AuditKeys{} }
+func (m *AuditKeys) String() string { return proto.CompactTextString(m) }
+func (*AuditKeys) ProtoMessage()    {}
+
+type AuditAccessReq struct {
+	Id         *Identity `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Chaincodes []string  `protobuf:"bytes,2,rep,name=chaincodes" json:"chaincodes,omitempty"`
+}
+
+func (m *AuditAccessReq) Reset()         { *m = AuditAccessReq{} }
+func (m *AuditAccessReq) String() string { return proto.CompactTextString(m) }
+func (*AuditAccessReq) ProtoMessage()    {}
+
+func (m *AuditAccessReq) GetId() *Identity {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
 type TCertCreateReq struct {
 	Ts  *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=ts" json:"ts,omitempty"`
 	Id  *Identity                  `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
@@ -869,7 +1005,8 @@ func (m *TLSCertRevokeReq) GetSig() *Signature {
 // Certificate issued by either the ECA or TCA.
 //
 type Cert struct {
-	Cert []byte `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Cert  []byte   `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Chain [][]byte `protobuf:"bytes,2,rep,name=chain,proto3" json:"chain,omitempty"`
 }
 
 func (m *Cert) Reset()         { *m = Cert{} }
@@ -925,6 +1062,14 @@ func (m *CertPair) Reset()         { *m = CertPair{} }
 func (m *CertPair) String() string { return proto.CompactTextString(m) }
 func (*CertPair) ProtoMessage()    {}
 
+type CRL struct {
+	Crl []byte `protobuf:"bytes,1,opt,name=crl,proto3" json:"crl,omitempty"`
+}
+
+func (m *CRL) Reset()         { *m = CRL{} }
+func (m *CRL) String() string { return proto.CompactTextString(m) }
+func (*CRL) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.CryptoType", CryptoType_name, CryptoType_value)
 	proto.RegisterEnum("protos.Role", Role_name, Role_value)
@@ -943,6 +1088,11 @@ type ECAPClient interface {
 	ReadCertificatePair(ctx context.Context, in *ECertReadReq, opts ...grpc.CallOption) (*CertPair, error)
 	ReadCertificateByHash(ctx context.Context, in *Hash, opts ...grpc.CallOption) (*Cert, error)
 	RevokeCertificatePair(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
+	// re-enroll with new keys, revoking the current pair
+	RenewCertificatePair(ctx context.Context, in *ECertRenewReq, opts ...grpc.CallOption) (*ECertCreateResp, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
+	// an auditor can only read the keys of the chaincodes designated to him/her
+	ReadAuditKeys(ctx context.Context, in *AuditKeysReq, opts ...grpc.CallOption) (*AuditKeys, error)
 }
 
 type eCAPClient struct {
@@ -998,6 +1148,33 @@ func (c *eCAPClient) RevokeCertificatePair(ctx context.Context, in *ECertRevokeR
 	return out, nil
 }
 
+func (c *eCAPClient) RenewCertificatePair(ctx context.Context, in *ECertRenewReq, opts ...grpc.CallOption) (*ECertCreateResp, error) {
+	out := new(ECertCreateResp)
+	err := grpc.Invoke(ctx, "/protos.ECAP/RenewCertificatePair", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCAPClient) ReadAuditKeys(ctx context.Context, in *AuditKeysReq, opts ...grpc.CallOption) (*AuditKeys, error) {
+	out := new(AuditKeys)
+	err := grpc.Invoke(ctx, "/protos.ECAP/ReadAuditKeys", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAP service
 
 type ECAPServer interface {
@@ -1006,6 +1183,11 @@ type ECAPServer interface {
 	ReadCertificatePair(context.Context, *ECertReadReq) (*CertPair, error)
 	ReadCertificateByHash(context.Context, *Hash) (*Cert, error)
 	RevokeCertificatePair(context.Context, *ECertRevokeReq) (*CAStatus, error)
+	// re-enroll with new keys, revoking the current pair
+	RenewCertificatePair(context.Context, *ECertRenewReq) (*ECertCreateResp, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
+	// an auditor can only read the keys of the chaincodes designated to him/her
+	ReadAuditKeys(context.Context, *AuditKeysReq) (*AuditKeys, error)
 }
 
 func RegisterECAPServer(s *grpc.Server, srv ECAPServer) {
@@ -1072,6 +1254,42 @@ func _ECAP_RevokeCertificatePair_Handler(srv interface{}, ctx context.Context, d
 	return out, nil
 }
 
+func _ECAP_RenewCertificatePair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ECertRenewReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).RenewCertificatePair(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _ECAP_ReadAuditKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AuditKeysReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAPServer).ReadAuditKeys(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAP",
 	HandlerType: (*ECAPServer)(nil),
@@ -1096,6 +1314,18 @@ var _ECAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificatePair",
 			Handler:    _ECAP_RevokeCertificatePair_Handler,
 		},
+		{
+			MethodName: "RenewCertificatePair",
+			Handler:    _ECAP_RenewCertificatePair_Handler,
+		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _ECAP_ReadCRL_Handler,
+		},
+		{
+			MethodName: "ReadAuditKeys",
+			Handler:    _ECAP_ReadAuditKeys_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1107,6 +1337,8 @@ type ECAAClient interface {
 	ReadUserSet(ctx context.Context, in *ReadUserSetReq, opts ...grpc.CallOption) (*UserSet, error)
 	RevokeCertificate(ctx context.Context, in *ECertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...grpc.CallOption) (*CAStatus, error)
+	// designates the chaincodes an auditor can inspect
+	GrantAuditAccess(ctx context.Context, in *AuditAccessReq, opts ...grpc.CallOption) (*CAStatus, error)
 }
 
 type eCAAClient struct {
@@ -1153,6 +1385,15 @@ func (c *eCAAClient) PublishCRL(ctx context.Context, in *ECertCRLReq, opts ...gr
 	return out, nil
 }
 
+func (c *eCAAClient) GrantAuditAccess(ctx context.Context, in *AuditAccessReq, opts ...grpc.CallOption) (*CAStatus, error) {
+	out := new(CAStatus)
+	err := grpc.Invoke(ctx, "/protos.ECAA/GrantAuditAccess", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for ECAA service
 
 type ECAAServer interface {
@@ -1160,6 +1401,8 @@ type ECAAServer interface {
 	ReadUserSet(context.Context, *ReadUserSetReq) (*UserSet, error)
 	RevokeCertificate(context.Context, *ECertRevokeReq) (*CAStatus, error)
 	PublishCRL(context.Context, *ECertCRLReq) (*CAStatus, error)
+	// designates the chaincodes an auditor can inspect
+	GrantAuditAccess(context.Context, *AuditAccessReq) (*CAStatus, error)
 }
 
 func RegisterECAAServer(s *grpc.Server, srv ECAAServer) {
@@ -1214,6 +1457,18 @@ func _ECAA_PublishCRL_Handler(srv interface{}, ctx context.Context, dec func(int
 	return out, nil
 }
 
+func _ECAA_GrantAuditAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(AuditAccessReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(ECAAServer).GrantAuditAccess(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _ECAA_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.ECAA",
 	HandlerType: (*ECAAServer)(nil),
@@ -1234,6 +1489,10 @@ var _ECAA_serviceDesc = grpc.ServiceDesc{
 			MethodName: "PublishCRL",
 			Handler:    _ECAA_PublishCRL_Handler,
 		},
+		{
+			MethodName: "GrantAuditAccess",
+			Handler:    _ECAA_GrantAuditAccess_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
@@ -1248,6 +1507,7 @@ type TCAPClient interface {
 	ReadCertificateSet(ctx context.Context, in *TCertReadSetReq, opts ...grpc.CallOption) (*CertSet, error)
 	RevokeCertificate(ctx context.Context, in *TCertRevokeReq, opts ...grpc.CallOption) (*CAStatus, error)
 	RevokeCertificateSet(ctx context.Context, in *TCertRevokeSetReq, opts ...grpc.CallOption) (*CAStatus, error)
+	ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error)
 }
 
 type tCAPClient struct {
@@ -1321,6 +1581,15 @@ func (c *tCAPClient) RevokeCertificateSet(ctx context.Context, in *TCertRevokeSe
 	return out, nil
 }
 
+func (c *tCAPClient) ReadCRL(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CRL, error) {
+	out := new(CRL)
+	err := grpc.Invoke(ctx, "/protos.TCAP/ReadCRL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TCAP service
 
 type TCAPServer interface {
@@ -1331,6 +1600,7 @@ type TCAPServer interface {
 	ReadCertificateSet(context.Context, *TCertReadSetReq) (*CertSet, error)
 	RevokeCertificate(context.Context, *TCertRevokeReq) (*CAStatus, error)
 	RevokeCertificateSet(context.Context, *TCertRevokeSetReq) (*CAStatus, error)
+	ReadCRL(context.Context, *Empty) (*CRL, error)
 }
 
 func RegisterTCAPServer(s *grpc.Server, srv TCAPServer) {
@@ -1421,6 +1691,18 @@ func _TCAP_RevokeCertificateSet_Handler(srv interface{}, ctx context.Context, de
 	return out, nil
 }
 
+func _TCAP_ReadCRL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(TCAPServer).ReadCRL(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _TCAP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.TCAP",
 	HandlerType: (*TCAPServer)(nil),
@@ -1453,6 +1735,10 @@ var _TCAP_serviceDesc = grpc.ServiceDesc{
 			MethodName: "RevokeCertificateSet",
 			Handler:    _TCAP_RevokeCertificateSet_Handler,
 		},
+		{
+			MethodName: "ReadCRL",
+			Handler:    _TCAP_ReadCRL_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
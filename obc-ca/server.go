@@ -29,6 +29,7 @@ import (
 
 	"fmt"
 	"github.com/openblockchain/obc-peer/obc-ca/obcca"
+	"github.com/openblockchain/obc-peer/openchain/audit"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -80,6 +81,14 @@ func main() {
 	obcca.LogInit(iotrace, ioinfo, iowarning, ioerror, iopanic)
 	obcca.Info.Println("CA Server (" + viper.GetString("server.version") + ")")
 
+	// Record registrations, enrollments and revocations in the audit log
+	auditLog, err := audit.Open(obcca.GetConfigString("server.rootpath") + "/" + obcca.GetConfigString("server.cadir") + "/audit.log")
+	if err != nil {
+		panic(err)
+	}
+	audit.SetDefault(auditLog)
+	defer auditLog.Close()
+
 	eca := obcca.NewECA()
 	defer eca.Close()
 
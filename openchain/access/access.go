@@ -0,0 +1,235 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package access authenticates the clients of the services a peer exposes
+// beside its gRPC peer service, the REST API and the event hub, and limits
+// the rate of their requests and the number of their connections.
+package access
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Anonymous is the client of the requests that do not authenticate when
+// authentication is not required. Anonymous requests share one rate limit
+// unless the service names their client otherwise, e.g. by remote address.
+const Anonymous = "anonymous"
+
+// maxIdleBuckets is the number of rate limit buckets kept before the ones of
+// idle clients are dropped
+const maxIdleBuckets = 1024
+
+// Errors returned by a Guard
+var (
+	ErrUnauthenticated    = errors.New("client not authenticated")
+	ErrRateLimited        = errors.New("client exceeded its request rate")
+	ErrTooManyConnections = errors.New("client exceeded its number of connections")
+)
+
+// Config of the access to a service
+type Config struct {
+	// Tokens maps the names of the clients to the bearer tokens they
+	// authenticate with
+	Tokens map[string]string
+	// Required refuses the requests that do not authenticate. It is implied
+	// by configuring tokens.
+	Required bool
+	// Rate is the number of requests per second of each client, 0 for no
+	// limit, and Burst the number of requests a client may make at once
+	Rate  float64
+	Burst int
+	// MaxConnections is the number of concurrent connections of each client,
+	// 0 for no limit
+	MaxConnections int
+}
+
+// LoadConfig returns the Config of the service configured under key, e.g.
+// "rest", from its auth and limits sections
+func LoadConfig(key string) Config {
+	return Config{
+		Tokens:         viper.GetStringMapString(key + ".auth.tokens"),
+		Required:       viper.GetBool(key + ".auth.required"),
+		Rate:           viper.GetFloat64(key + ".limits.rate"),
+		Burst:          viper.GetInt(key + ".limits.burst"),
+		MaxConnections: viper.GetInt(key + ".limits.maxConnections"),
+	}
+}
+
+// bucket holds the requests a client may still make at last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Guard enforces a Config
+type Guard struct {
+	config Config
+	now    func() time.Time
+
+	sync.Mutex
+	buckets     map[string]*bucket
+	connections map[string]int
+}
+
+// NewGuard returns a Guard enforcing config
+func NewGuard(config Config) *Guard {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	return &Guard{
+		config:      config,
+		now:         time.Now,
+		buckets:     make(map[string]*bucket),
+		connections: make(map[string]int),
+	}
+}
+
+// Required returns whether clients must authenticate
+func (g *Guard) Required() bool {
+	return g.config.Required || len(g.config.Tokens) > 0
+}
+
+// Authenticate returns the client the token belongs to. Requests without a
+// token are Anonymous unless authentication is required.
+func (g *Guard) Authenticate(token string) (string, error) {
+	if token == "" {
+		if g.Required() {
+			return "", ErrUnauthenticated
+		}
+		return Anonymous, nil
+	}
+	client := ""
+	for name, t := range g.config.Tokens {
+		// compare all the tokens in constant time not to leak which matched
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			client = name
+		}
+	}
+	if client == "" {
+		return "", ErrUnauthenticated
+	}
+	return client, nil
+}
+
+// BearerToken returns the token of an Authorization header of the form
+// "Bearer <token>", or "" if it has another form
+func BearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) < len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(authorization[len(prefix):])
+}
+
+// HTTPClient returns the client of an HTTP request, the common name of its
+// verified TLS client certificate, else the client its bearer token belongs
+// to. Anonymous clients are told apart by their host.
+func (g *Guard) HTTPClient(req *http.Request) (string, error) {
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		return req.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+	}
+	client, err := g.Authenticate(BearerToken(req.Header.Get("Authorization")))
+	if err != nil {
+		return "", err
+	}
+	if client == Anonymous {
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			client = Anonymous + "/" + host
+		}
+	}
+	return client, nil
+}
+
+// Allow takes one request of the client from its rate limit, or returns
+// ErrRateLimited if the client has none left
+func (g *Guard) Allow(client string) error {
+	if g.config.Rate <= 0 {
+		return nil
+	}
+	g.Lock()
+	defer g.Unlock()
+
+	now := g.now()
+	b, ok := g.buckets[client]
+	if !ok {
+		if len(g.buckets) >= maxIdleBuckets {
+			g.dropIdle(now)
+		}
+		b = &bucket{tokens: float64(g.config.Burst), last: now}
+		g.buckets[client] = b
+	}
+	b.tokens = g.refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return ErrRateLimited
+	}
+	b.tokens--
+	return nil
+}
+
+// refill returns the requests the client of b may make at now
+func (g *Guard) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*g.config.Rate
+	if max := float64(g.config.Burst); tokens > max {
+		tokens = max
+	}
+	return tokens
+}
+
+// dropIdle drops the buckets that have refilled, which are the same as new
+// ones
+func (g *Guard) dropIdle(now time.Time) {
+	for client, b := range g.buckets {
+		if g.refill(b, now) >= float64(g.config.Burst) {
+			delete(g.buckets, client)
+		}
+	}
+}
+
+// Connect counts a connection of the client and returns the function
+// releasing it, or returns ErrTooManyConnections if the client has reached
+// its number of connections
+func (g *Guard) Connect(client string) (func(), error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.config.MaxConnections > 0 && g.connections[client] >= g.config.MaxConnections {
+		return nil, ErrTooManyConnections
+	}
+	g.connections[client]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.Lock()
+			defer g.Unlock()
+			if g.connections[client]--; g.connections[client] <= 0 {
+				delete(g.connections, client)
+			}
+		})
+	}, nil
+}
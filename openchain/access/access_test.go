@@ -0,0 +1,158 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package access
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAuthenticate(t *testing.T) {
+	open := NewGuard(Config{})
+	if client, err := open.Authenticate(""); err != nil || client != Anonymous {
+		t.Fatalf("Expected an anonymous client, got %q, error %v", client, err)
+	}
+
+	guard := NewGuard(Config{Tokens: map[string]string{"alice": "secret-a", "bob": "secret-b"}})
+	if client, err := guard.Authenticate("secret-b"); err != nil || client != "bob" {
+		t.Fatalf("Expected client bob, got %q, error %v", client, err)
+	}
+	for _, token := range []string{"", "secret", "secret-c"} {
+		if _, err := guard.Authenticate(token); err != ErrUnauthenticated {
+			t.Fatalf("Expected token %q to be refused, got %v", token, err)
+		}
+	}
+
+	required := NewGuard(Config{Required: true})
+	if _, err := required.Authenticate(""); err != ErrUnauthenticated {
+		t.Fatalf("Expected anonymous clients to be refused, got %v", err)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := map[string]string{
+		"Bearer abc":  "abc",
+		"bearer  abc": "abc",
+		"Basic abc":   "",
+		"Bearer":      "",
+		"":            "",
+	}
+	for authorization, expected := range tests {
+		if token := BearerToken(authorization); token != expected {
+			t.Fatalf("Expected token %q of %q, got %q", expected, authorization, token)
+		}
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	guard := NewGuard(Config{Tokens: map[string]string{"alice": "secret"}})
+	req, err := http.NewRequest("GET", "/chain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RemoteAddr = "10.0.0.1:4321"
+	if _, err := guard.HTTPClient(req); err != ErrUnauthenticated {
+		t.Fatalf("Expected a request without token to be refused, got %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if client, err := guard.HTTPClient(req); err != nil || client != "alice" {
+		t.Fatalf("Expected client alice, got %q, error %v", client, err)
+	}
+
+	open := NewGuard(Config{})
+	req.Header.Del("Authorization")
+	if client, err := open.HTTPClient(req); err != nil || client != "anonymous/10.0.0.1" {
+		t.Fatalf("Expected an anonymous client named by its host, got %q, error %v", client, err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	guard := NewGuard(Config{Rate: 2, Burst: 3})
+	now := time.Unix(1000, 0)
+	guard.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if err := guard.Allow("alice"); err != nil {
+			t.Fatalf("Expected request %d within the burst to be allowed, got %v", i, err)
+		}
+	}
+	if err := guard.Allow("alice"); err != ErrRateLimited {
+		t.Fatalf("Expected the request beyond the burst to be limited, got %v", err)
+	}
+	if err := guard.Allow("bob"); err != nil {
+		t.Fatalf("Expected another client to have its own limit, got %v", err)
+	}
+
+	// at 2 requests per second, one more request is allowed after half a second
+	now = now.Add(500 * time.Millisecond)
+	if err := guard.Allow("alice"); err != nil {
+		t.Fatalf("Expected a request after the refill to be allowed, got %v", err)
+	}
+	if err := guard.Allow("alice"); err != ErrRateLimited {
+		t.Fatalf("Expected the second request after the refill to be limited, got %v", err)
+	}
+
+	if err := NewGuard(Config{}).Allow("alice"); err != nil {
+		t.Fatalf("Expected no limit without a rate, got %v", err)
+	}
+}
+
+func TestAllowDropsIdleBuckets(t *testing.T) {
+	guard := NewGuard(Config{Rate: 1})
+	now := time.Unix(1000, 0)
+	guard.now = func() time.Time { return now }
+
+	for i := 0; i < maxIdleBuckets; i++ {
+		guard.Allow(string(rune('a' + i)))
+	}
+	now = now.Add(time.Second)
+	guard.Allow("new")
+	if len(guard.buckets) != 1 {
+		t.Fatalf("Expected the idle buckets to be dropped, got %d buckets", len(guard.buckets))
+	}
+}
+
+func TestConnect(t *testing.T) {
+	guard := NewGuard(Config{MaxConnections: 2})
+	release1, err := guard.Connect("alice")
+	if err != nil {
+		t.Fatalf("Expected the first connection to be accepted, got %v", err)
+	}
+	if _, err = guard.Connect("alice"); err != nil {
+		t.Fatalf("Expected the second connection to be accepted, got %v", err)
+	}
+	if _, err = guard.Connect("alice"); err != ErrTooManyConnections {
+		t.Fatalf("Expected the third connection to be refused, got %v", err)
+	}
+	if _, err = guard.Connect("bob"); err != nil {
+		t.Fatalf("Expected another client to have its own connections, got %v", err)
+	}
+
+	// releasing twice frees one connection only
+	release1()
+	release1()
+	if _, err = guard.Connect("alice"); err != nil {
+		t.Fatalf("Expected a connection after a release to be accepted, got %v", err)
+	}
+	if _, err = guard.Connect("alice"); err != ErrTooManyConnections {
+		t.Fatalf("Expected a connection beyond the cap to be refused, got %v", err)
+	}
+}
@@ -20,7 +20,12 @@ under the License.
 package openchain
 
 import (
+	"fmt"
+	"net"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/op/go-logging"
@@ -29,6 +34,13 @@ import (
 
 	google_protobuf "google/protobuf"
 
+	"github.com/openblockchain/obc-peer/openchain/audit"
+	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/container"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/fault"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/peer"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -40,8 +52,73 @@ func NewAdminServer() *ServerAdmin {
 	return s
 }
 
+// NewAdminServerWithPeer creates and returns a Admin service instance able to
+// verify the credentials of the admins and to rotate the identity of the peer.
+func NewAdminServerWithPeer(p AdminPeer) *ServerAdmin {
+	return &ServerAdmin{identity: p, security: p}
+}
+
+// NewLocalAdminServer creates and returns the Admin service served on the
+// local admin address, the only one pausing, resuming and draining the peer
+func NewLocalAdminServer(p AdminPeer) *ServerAdmin {
+	return &ServerAdmin{identity: p, security: p, local: true}
+}
+
+// LocalAdminAddress returns peer.admin.localAddress, the address the node
+// management requests are served on. It must be a loopback address so that
+// they can only be made from the host of the peer. Empty disables them.
+func LocalAdminAddress() (string, error) {
+	address := viper.GetString("peer.admin.localAddress")
+	if address == "" {
+		return "", nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid local admin address %s: %s", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("Local admin address %s is not a loopback address", address)
+	}
+	return address, nil
+}
+
+// IdentityRotator re-enrolls the peer with new enrollment keys
+type IdentityRotator interface {
+	RotateIdentity() (*pb.IdentityRotation, error)
+}
+
+// AdminPeer is the peer administered by the Admin service
+type AdminPeer interface {
+	IdentityRotator
+	peer.SecurityAccessor
+}
+
 // ServerAdmin implementation of the Admin service for the Peer
 type ServerAdmin struct {
+	identity IdentityRotator
+	security peer.SecurityAccessor
+	// local is set on the server of the local admin address
+	local bool
+}
+
+// checkLocal refuses the node management action unless the request came
+// through the local admin address
+func (s *ServerAdmin) checkLocal(action string) error {
+	if s.local {
+		return nil
+	}
+	err := fmt.Errorf("Not authorized to %s, node management is only served on the local admin address (peer.admin.localAddress)", action)
+	auditAdmin("", action, "peer", err)
+	return err
+}
+
+// secHelper returns the crypto object verifying the credentials of the
+// admins, nil without security
+func (s *ServerAdmin) secHelper() crypto.Peer {
+	if s.security == nil {
+		return nil
+	}
+	return s.security.GetSecHelper()
 }
 
 func worker(id int, die chan struct{}) {
@@ -59,7 +136,7 @@ func worker(id int, die chan struct{}) {
 
 // GetStatus reports the status of the server
 func (*ServerAdmin) GetStatus(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
-	status := &pb.ServerStatus{Status: pb.ServerStatus_UNKNOWN}
+	status := nodeStatus()
 	die := make(chan struct{})
 	log.Debug("Creating %d workers", viper.GetInt("peer.workers"))
 	for i := 0; i < viper.GetInt("peer.workers"); i++ {
@@ -80,8 +157,316 @@ func (*ServerAdmin) StartServer(context.Context, *google_protobuf.Empty) (*pb.Se
 }
 
 // StopServer stops the server
-func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+func (s *ServerAdmin) StopServer(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionStop, "peer"); err != nil {
+		return nil, err
+	}
 	status := &pb.ServerStatus{Status: pb.ServerStatus_STOPPED}
 	log.Debug("returning status: %s", status)
 	return status, nil
 }
+
+// PauseServer stops the peer from accepting transactions. Transactions in
+// flight are still processed.
+func (s *ServerAdmin) PauseServer(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := s.checkLocal(AdminActionPause); err != nil {
+		return nil, err
+	}
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionPause, "peer"); err != nil {
+		return nil, err
+	}
+	peer.PauseIntake()
+	log.Info("Paused transaction intake")
+	return nodeStatus(), nil
+}
+
+// ResumeServer makes the peer accept transactions again
+func (s *ServerAdmin) ResumeServer(ctx context.Context, empty *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := s.checkLocal(AdminActionResume); err != nil {
+		return nil, err
+	}
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionResume, "peer"); err != nil {
+		return nil, err
+	}
+	peer.ResumeIntake()
+	log.Info("Resumed transaction intake")
+	return nodeStatus(), nil
+}
+
+// DrainServer pauses the peer and waits for the transactions in flight to be
+// committed. It fails if some are still in flight after the timeout.
+func (s *ServerAdmin) DrainServer(ctx context.Context, drain *pb.DrainRequest) (*pb.ServerStatus, error) {
+	if err := s.checkLocal(AdminActionDrain); err != nil {
+		return nil, err
+	}
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionDrain, "peer"); err != nil {
+		return nil, err
+	}
+	log.Info("Draining transaction intake")
+	if remaining := peer.DrainIntake(time.Duration(drain.Timeout) * time.Second); remaining > 0 {
+		return nil, fmt.Errorf("Timed out draining the peer with %d transactions in flight", remaining)
+	}
+	log.Info("Drained transaction intake")
+	return nodeStatus(), nil
+}
+
+// nodeStatus returns whether the peer accepts transactions, and how many are
+// in flight
+func nodeStatus() *pb.ServerStatus {
+	status := &pb.ServerStatus{Status: pb.ServerStatus_STARTED, InFlight: uint64(peer.InFlightTransactions())}
+	if !peer.Ready() {
+		status.Status = pb.ServerStatus_STARTING
+		status.StartupStages = peer.StartupStages()
+	} else if peer.IntakePaused() {
+		status.Status = pb.ServerStatus_PAUSED
+	}
+	if ledger, err := ledger.GetLedger(); err == nil {
+		status.BlockHeight = ledger.GetBlockchainSize()
+	}
+	return status
+}
+
+// TagCheckpoint tags the last block of the blockchain with a named checkpoint
+func (s *ServerAdmin) TagCheckpoint(ctx context.Context, checkpoint *pb.Checkpoint) (*pb.Checkpoint, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionTagCheckpoint, checkpoint.Name); err != nil {
+		return nil, err
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, err := ledger.TagCheckpoint(checkpoint.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Checkpoint{Name: checkpoint.Name, BlockNumber: blockNumber}, nil
+}
+
+// ListCheckpoints returns the checkpoints of the ledger ordered by block number
+func (*ServerAdmin) ListCheckpoints(context.Context, *google_protobuf.Empty) (*pb.CheckpointList, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := ledger.GetCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	list := &pb.CheckpointList{}
+	for name, blockNumber := range checkpoints {
+		list.Checkpoints = append(list.Checkpoints, &pb.Checkpoint{Name: name, BlockNumber: blockNumber})
+	}
+	sort.Sort(checkpointsByBlockNumber(list.Checkpoints))
+	return list, nil
+}
+
+// RollbackToCheckpoint reverts the state and the blockchain to a checkpoint.
+// As blocks are discarded, the request must be confirmed and state the number
+// of the block tagged by the checkpoint.
+func (s *ServerAdmin) RollbackToCheckpoint(ctx context.Context, rollback *pb.CheckpointRollback) (*pb.Checkpoint, error) {
+	if !rollback.Confirm {
+		return nil, fmt.Errorf("Rollback to checkpoint %s was not confirmed", rollback.Name)
+	}
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionRollback, rollback.Name); err != nil {
+		return nil, err
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, err := ledger.GetCheckpoint(rollback.Name)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting checkpoint %s: %s", rollback.Name, err)
+	}
+	if blockNumber != rollback.BlockNumber {
+		return nil, fmt.Errorf("Checkpoint %s tags block %d, not block %d", rollback.Name, blockNumber, rollback.BlockNumber)
+	}
+	if err = ledger.RollbackToCheckpoint(rollback.Name); err != nil {
+		return nil, err
+	}
+	return &pb.Checkpoint{Name: rollback.Name, BlockNumber: blockNumber}, nil
+}
+
+// backupDirectory returns the directory of the peer the ledger backups are
+// written under
+func backupDirectory() string {
+	if dir := viper.GetString("peer.admin.backup.directory"); dir != "" {
+		return filepath.Clean(dir)
+	}
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "backups")
+}
+
+// backupPath returns the directory a backup to path is written to. Relative
+// paths are taken from the backup directory, absolute ones must be inside it.
+func backupPath(path string) (string, error) {
+	root := backupDirectory()
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Backup path %s is not inside the backup directory %s", path, root)
+	}
+	return path, nil
+}
+
+// BackupLedger backs up the ledger to a directory under the backup directory
+// of the peer without stopping transaction processing
+func (s *ServerAdmin) BackupLedger(ctx context.Context, backup *pb.LedgerBackup) (*pb.LedgerBackup, error) {
+	if backup.Path == "" {
+		return nil, fmt.Errorf("Backup path is required")
+	}
+	path, err := backupPath(backup.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err = authorizeAdminContext(ctx, s.secHelper(), AdminActionBackup, backup.Path); err != nil {
+		return nil, err
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := ledger.Backup(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Backed up %d blocks to %s", manifest.BlockHeight, path)
+	return &pb.LedgerBackup{Path: path, BlockHeight: manifest.BlockHeight, LastBlockHash: manifest.LastBlockHash, StateHash: manifest.StateHash}, nil
+}
+
+// ReplayBlocks executes the transactions of a range of committed blocks again
+// on a scratch copy of the state and reports whether the state hashes match
+// the recorded ones, which verifies that an upgraded peer or chaincode still
+// executes them the same way. The peer must be paused so that it takes no
+// new transactions; the batches ordered by consensus wait for the replay.
+func (s *ServerAdmin) ReplayBlocks(ctx context.Context, spec *pb.BlockReplaySpec) (*pb.BlockReplayReport, error) {
+	if !viper.GetBool("peer.validator.enabled") {
+		return nil, fmt.Errorf("Blocks can only be replayed on a validating peer")
+	}
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionReplay, "peer"); err != nil {
+		return nil, err
+	}
+	if !peer.IntakePaused() {
+		return nil, fmt.Errorf("Peer must be paused to replay blocks")
+	}
+	log.Info("Replaying blocks %d to %d", spec.FirstBlock, spec.LastBlock)
+	report, err := chaincode.ReplayBlocks(ctx, chaincode.GetChain(chaincode.DefaultChain), spec.ChainID, spec.FirstBlock, spec.LastBlock)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Replayed blocks %d to %d, deterministic: %t", spec.FirstBlock, spec.LastBlock, report.Deterministic)
+	return report, nil
+}
+
+// InjectFault starts injecting a fault, when peer.faultInjection.enabled is set
+func (s *ServerAdmin) InjectFault(ctx context.Context, f *pb.Fault) (*google_protobuf.Empty, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionInjectFault, "peer"); err != nil {
+		return nil, err
+	}
+	if err := fault.Add(f); err != nil {
+		return nil, err
+	}
+	return &google_protobuf.Empty{}, nil
+}
+
+// ListFaults returns the faults being injected
+func (*ServerAdmin) ListFaults(ctx context.Context, empty *google_protobuf.Empty) (*pb.FaultList, error) {
+	return &pb.FaultList{Faults: fault.List()}, nil
+}
+
+// ClearFaults stops injecting faults
+func (s *ServerAdmin) ClearFaults(ctx context.Context, empty *google_protobuf.Empty) (*google_protobuf.Empty, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionClearFaults, "peer"); err != nil {
+		return nil, err
+	}
+	fault.Clear()
+	return &google_protobuf.Empty{}, nil
+}
+
+// GetLogLevel returns the logging level of a module
+func (*ServerAdmin) GetLogLevel(ctx context.Context, logLevel *pb.LogLevel) (*pb.LogLevel, error) {
+	return &pb.LogLevel{Module: logLevel.Module, Level: GetLoggingLevel(logLevel.Module)}, nil
+}
+
+// SetLogLevel sets the logging level of a module without restarting the
+// peer. The level is kept when the peer restarts.
+func (s *ServerAdmin) SetLogLevel(ctx context.Context, logLevel *pb.LogLevel) (*pb.LogLevel, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionSetLogLevel, logLevel.Module); err != nil {
+		return nil, err
+	}
+	level, err := SetLoggingLevel(logLevel.Module, logLevel.Level)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Logging level of module '%s' set to %s", logLevel.Module, level)
+	return &pb.LogLevel{Module: logLevel.Module, Level: level}, nil
+}
+
+// RotateIdentity re-enrolls the peer with new enrollment keys, eg. after its
+// enrollment key was compromised. The enrollment ID is kept, the previous
+// enrollment certificate is revoked and the connected peers are sent the new
+// one.
+func (s *ServerAdmin) RotateIdentity(ctx context.Context, empty *google_protobuf.Empty) (*pb.IdentityRotation, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionRotate, "peer"); err != nil {
+		return nil, err
+	}
+	if s.identity == nil {
+		return nil, fmt.Errorf("Identity rotation is not available on this peer")
+	}
+	rotation, err := s.identity.RotateIdentity()
+	var enrollID, pkiID string
+	if rotation != nil {
+		enrollID, pkiID = rotation.EnrollmentID, fmt.Sprintf("%x", rotation.PkiID)
+	}
+	audit.Record(audit.CategoryIdentity, enrollID, AdminActionRotate, pkiID, err)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Rotated identity of %s", rotation.EnrollmentID)
+	return rotation, nil
+}
+
+// SetChaincodeTrace turns the message trace of a chaincode on or off. While
+// it is on, the messages exchanged with the chaincode and the transitions of
+// its handler are written to a file of the peer.
+func (s *ServerAdmin) SetChaincodeTrace(ctx context.Context, trace *pb.ChaincodeTrace) (*pb.ChaincodeTrace, error) {
+	if err := authorizeAdminContext(ctx, s.secHelper(), AdminActionTraceChaincode, trace.Name); err != nil {
+		return nil, err
+	}
+	if err := chaincode.SetMessageTrace(trace.Name, trace.Enabled); err != nil {
+		return nil, err
+	}
+	log.Info("Message trace of chaincode '%s' enabled: %t", trace.Name, trace.Enabled)
+	return &pb.ChaincodeTrace{Name: trace.Name, Enabled: trace.Enabled, File: chaincode.MessageTraceFile(trace.Name)}, nil
+}
+
+// GetChaincodeLogs returns the output of the container of a chaincode
+// captured by the peer, including the output of earlier launches still in
+// the rotated files.
+func (*ServerAdmin) GetChaincodeLogs(ctx context.Context, req *pb.ChaincodeLogsRequest) (*pb.ChaincodeLogs, error) {
+	if req.Name == "" {
+		return nil, fmt.Errorf("Chaincode name not given")
+	}
+	lines, err := container.ReadChaincodeLogs(container.GetVMFromName(req.Name), int(req.Tail), time.Unix(0, req.Since))
+	if err != nil {
+		return nil, err
+	}
+	logs := &pb.ChaincodeLogs{Name: req.Name}
+	for _, line := range lines {
+		logs.Lines = append(logs.Lines, &pb.ChaincodeLogLine{Timestamp: line.Time.UnixNano(), Stream: line.Stream, Text: line.Text})
+	}
+	return logs, nil
+}
+
+type checkpointsByBlockNumber []*pb.Checkpoint
+
+func (c checkpointsByBlockNumber) Len() int      { return len(c) }
+func (c checkpointsByBlockNumber) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c checkpointsByBlockNumber) Less(i, j int) bool {
+	if c[i].BlockNumber != c[j].BlockNumber {
+		return c[i].BlockNumber < c[j].BlockNumber
+	}
+	return c[i].Name < c[j].Name
+}
@@ -0,0 +1,326 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/audit"
+	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+)
+
+// gRPC metadata keys of the credentials of an admin request: the enrollment
+// certificate of the admin and the signature of the request under its
+// enrollment key, both base64 encoded, and the nonce of the request
+const (
+	AdminCertKey      = "admin-cert"
+	AdminNonceKey     = "admin-nonce"
+	AdminSignatureKey = "admin-signature"
+)
+
+// Operations restricted to admins
+const (
+	AdminActionDeploy         = "deploy"
+	AdminActionSetLogLevel    = "set-log-level"
+	AdminActionRollback       = "rollback"
+	AdminActionDrain          = "drain"
+	AdminActionRotate         = "rotate-identity"
+	AdminActionBackup         = "backup"
+	AdminActionPause          = "pause"
+	AdminActionResume         = "resume"
+	AdminActionStop           = "stop"
+	AdminActionTagCheckpoint  = "tag-checkpoint"
+	AdminActionReplay         = "replay"
+	AdminActionInjectFault    = "inject-fault"
+	AdminActionClearFaults    = "clear-faults"
+	AdminActionTraceChaincode = "trace-chaincode"
+)
+
+// auditLogger records who invoked the admin operations, and whether they
+// were allowed
+var auditLogger = logging.MustGetLogger("audit")
+
+// adminAuthorizationEnabled returns whether the admin operations are
+// restricted to the identities holding the ADMIN role
+func adminAuthorizationEnabled() bool {
+	return viper.GetBool("peer.admin.authorization.enabled")
+}
+
+// AdminCredentials prove the identity of the admin making a request: the
+// enrollment key of Cert signed the ID of the peer, the action, its target
+// and the nonce. The nonce is the time the credentials were made at,
+// followed by random bytes, so that they are only accepted once and for a
+// short time. Each peer only remembers the nonces it accepted, so the peer ID
+// keeps the credentials of a request to one peer from being replayed on
+// another.
+type AdminCredentials struct {
+	Cert      []byte
+	Nonce     string
+	Signature []byte
+}
+
+// AdminSigningBytes returns the message admin credentials sign for the
+// action on the target of the peer
+func AdminSigningBytes(peerID, action, target, nonce string) []byte {
+	return []byte(fmt.Sprintf("%q %q %q %q", peerID, action, target, nonce))
+}
+
+// NewAdminCredentials returns the credentials of the user with the
+// enrollment ID, logged in locally, for the admin action on the target of
+// the peer
+func NewAdminCredentials(enrollID, peerID, action, target string) (*AdminCredentials, error) {
+	sec, err := crypto.InitClient(enrollID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not logged in: %s", enrollID, err)
+	}
+	defer crypto.CloseClient(sec)
+	handler, err := sec.GetEnrollmentCertificateHandler()
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, 16)
+	if _, err = rand.Read(random); err != nil {
+		return nil, err
+	}
+	nonce := fmt.Sprintf("%d-%x", time.Now().UnixNano(), random)
+	signature, err := handler.Sign(AdminSigningBytes(peerID, action, target, nonce))
+	if err != nil {
+		return nil, err
+	}
+	return &AdminCredentials{Cert: handler.GetCertificate(), Nonce: nonce, Signature: signature}, nil
+}
+
+// NewContext returns a context carrying the credentials in the metadata of
+// the gRPC requests made with it
+func (creds *AdminCredentials) NewContext(ctx context.Context) context.Context {
+	return metadata.NewContext(ctx, metadata.Pairs(
+		AdminCertKey, base64.StdEncoding.EncodeToString(creds.Cert),
+		AdminNonceKey, creds.Nonce,
+		AdminSignatureKey, base64.StdEncoding.EncodeToString(creds.Signature)))
+}
+
+// ParseAdminCredentials returns the credentials of base64 encoded cert and
+// signature, nil if no certificate is given
+func ParseAdminCredentials(cert, nonce, signature string) (*AdminCredentials, error) {
+	if cert == "" {
+		return nil, nil
+	}
+	creds := &AdminCredentials{Nonce: nonce}
+	var err error
+	if creds.Cert, err = base64.StdEncoding.DecodeString(cert); err != nil {
+		return nil, fmt.Errorf("Invalid admin certificate encoding: %s", err)
+	}
+	if creds.Signature, err = base64.StdEncoding.DecodeString(signature); err != nil {
+		return nil, fmt.Errorf("Invalid admin signature encoding: %s", err)
+	}
+	return creds, nil
+}
+
+// adminCredentialsFromContext returns the credentials in the metadata of
+// a gRPC request, nil if there are none
+func adminCredentialsFromContext(ctx context.Context) (*AdminCredentials, error) {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	first := func(key string) string {
+		if len(md[key]) > 0 {
+			return md[key][0]
+		}
+		return ""
+	}
+	return ParseAdminCredentials(first(AdminCertKey), first(AdminNonceKey), first(AdminSignatureKey))
+}
+
+// adminNonceWindow returns for how long admin credentials are accepted
+// after they were made
+func adminNonceWindow() time.Duration {
+	if window := viper.GetDuration("peer.admin.authorization.window"); window > 0 {
+		return window
+	}
+	return 5 * time.Minute
+}
+
+// nonceCache holds the nonces of the admin credentials accepted within the
+// window, to refuse credentials replayed from an earlier request
+type nonceCache struct {
+	sync.Mutex
+	seen map[string]time.Time
+}
+
+var adminNonces = &nonceCache{seen: make(map[string]time.Time)}
+
+// use records the nonce made at its time prefix, unless it is outside the
+// window around now or was already used
+func (cache *nonceCache) use(nonce string, now time.Time, window time.Duration) error {
+	made, err := strconv.ParseInt(strings.SplitN(nonce, "-", 2)[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid admin nonce %q", nonce)
+	}
+	madeAt := time.Unix(0, made)
+	if madeAt.Before(now.Add(-window)) || madeAt.After(now.Add(window)) {
+		return fmt.Errorf("admin credentials made at %s are expired", madeAt)
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	for n, t := range cache.seen {
+		if t.Before(now.Add(-window)) {
+			delete(cache.seen, n)
+		}
+	}
+	if _, ok := cache.seen[nonce]; ok {
+		return errors.New("admin credentials were already used")
+	}
+	cache.seen[nonce] = madeAt
+	return nil
+}
+
+// verifyAdminCredentials returns the enrollment certificate of the admin
+// once sec verified that the credentials were signed for the action on the
+// target of this peer with its enrollment key
+func verifyAdminCredentials(sec crypto.Peer, creds *AdminCredentials, action, target string) ([]byte, error) {
+	if creds == nil {
+		return nil, errors.New("no admin credentials given")
+	}
+	if sec == nil {
+		return nil, errors.New("admin credentials cannot be verified without security")
+	}
+	if err := sec.VerifyEnrollmentSignature(creds.Cert, creds.Signature, AdminSigningBytes(viper.GetString("peer.id"), action, target, creds.Nonce)); err != nil {
+		return nil, fmt.Errorf("invalid admin credentials: %s", err)
+	}
+	// Checked once the signature is verified, for the nonces of forged
+	// credentials not to be recorded
+	if err := adminNonces.use(creds.Nonce, time.Now(), adminNonceWindow()); err != nil {
+		return nil, err
+	}
+	return creds.Cert, nil
+}
+
+// AuthorizeAdmin returns an error unless the credentials prove that a user
+// holding the ADMIN role requested the admin action on the target. sec
+// verifies the credentials, it is nil without security. Without admin
+// authorization anybody may take the action. Either way the decision is
+// audited, with the identity the credentials prove if any.
+func AuthorizeAdmin(sec crypto.Peer, creds *AdminCredentials, action, target string) error {
+	ecert, err := verifyAdminCredentials(sec, creds, action, target)
+	if !adminAuthorizationEnabled() {
+		id := ""
+		if err == nil {
+			id, _, _ = crypto.ParseEnrollmentCertificate(ecert)
+		}
+		auditAdmin(id, action, target, nil)
+		return nil
+	}
+	if err != nil {
+		err = fmt.Errorf("Not authorized to %s, %s", action, err)
+		auditAdmin("", action, target, err)
+		return err
+	}
+	return checkAdminCert(ecert, action, target)
+}
+
+// AuthorizeAdmin authorizes the admin action on the target of the owner of
+// the credentials, verified with the crypto object of the peer
+func (d *Devops) AuthorizeAdmin(creds *AdminCredentials, action, target string) error {
+	return AuthorizeAdmin(d.coord.GetSecHelper(), creds, action, target)
+}
+
+// checkAdminCert returns an error unless the owner of the enrollment
+// certificate holds the ADMIN role, and audits the decision
+func checkAdminCert(ecert []byte, action, target string) error {
+	id, role, err := crypto.ParseEnrollmentCertificate(ecert)
+	if err != nil {
+		auditAdmin("", action, target, err)
+		return err
+	}
+	if role&int32(obcca.Role_ADMIN) == 0 {
+		err = fmt.Errorf("%s is not authorized to %s, it does not hold the ADMIN role", id, action)
+	}
+	auditAdmin(id, action, target, err)
+	return err
+}
+
+// authorizeAdminContext authorizes the admin action of the user whose
+// credentials are in the metadata of the gRPC request
+func authorizeAdminContext(ctx context.Context, sec crypto.Peer, action, target string) error {
+	creds, err := adminCredentialsFromContext(ctx)
+	if err != nil {
+		err = fmt.Errorf("Not authorized to %s, %s", action, err)
+		auditAdmin("", action, target, err)
+		return err
+	}
+	return AuthorizeAdmin(sec, creds, action, target)
+}
+
+// authorizeDeploy authorizes the deployment of a chaincode in production
+// mode by the owner of the enrollment certificate, nil without security.
+// Anybody may deploy in development mode.
+func authorizeDeploy(name string, ecert []byte) error {
+	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
+		return nil
+	}
+	if !adminAuthorizationEnabled() {
+		id := ""
+		if ecert != nil {
+			id, _, _ = crypto.ParseEnrollmentCertificate(ecert)
+		}
+		auditAdmin(id, AdminActionDeploy, name, nil)
+		return nil
+	}
+	if ecert == nil {
+		err := fmt.Errorf("Not authorized to %s, admin authorization requires security to identify the submitter", AdminActionDeploy)
+		auditAdmin("", AdminActionDeploy, name, err)
+		return err
+	}
+	return checkAdminCert(ecert, AdminActionDeploy, name)
+}
+
+// auditAdmin writes the audit log entry of an admin action, refused if err
+// is not nil, and records it in the audit log
+func auditAdmin(enrollID, action, target string, err error) {
+	if enrollID == "" {
+		enrollID = "unidentified"
+	}
+	category := audit.CategoryAdmin
+	if action == AdminActionDeploy {
+		category = audit.CategoryDeployment
+	}
+	audit.Record(category, enrollID, action, target, err)
+	if err != nil {
+		auditLogger.Warning("Refused %s to %s on [%s]: %s", action, enrollID, target, err)
+		return
+	}
+	auditLogger.Notice("%s invoked %s on [%s]", enrollID, action, target)
+}
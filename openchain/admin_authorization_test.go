@@ -0,0 +1,276 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	google_protobuf "google/protobuf"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestAuthorizeDeploy(t *testing.T) {
+	defer viper.Set("chaincode.mode", viper.GetString("chaincode.mode"))
+	viper.Set("chaincode.mode", "net")
+	admin := createTestECert(t, "ops", obcca.Role_CLIENT|obcca.Role_ADMIN)
+	client := createTestECert(t, "alice", obcca.Role_CLIENT)
+
+	if err := authorizeDeploy("mycc", client); err != nil {
+		t.Fatalf("Expected deploys to be open without admin authorization: %s", err)
+	}
+
+	viper.Set("peer.admin.authorization.enabled", true)
+	defer viper.Set("peer.admin.authorization.enabled", false)
+	if err := authorizeDeploy("mycc", admin); err != nil {
+		t.Fatalf("Expected an admin to deploy: %s", err)
+	}
+	if err := authorizeDeploy("mycc", client); err == nil {
+		t.Fatal("Expected a client not to deploy in production mode")
+	}
+	if err := authorizeDeploy("mycc", nil); err == nil {
+		t.Fatal("Expected an unidentified submitter not to deploy in production mode")
+	}
+
+	viper.Set("chaincode.mode", chaincode.DevModeUserRunsChaincode)
+	if err := authorizeDeploy("mycc", client); err != nil {
+		t.Fatalf("Expected anybody to deploy in development mode: %s", err)
+	}
+}
+
+// testSecHelper verifies the signatures of the test enrollment certificates,
+// as if they were issued by the ECA
+type testSecHelper struct {
+	crypto.Peer
+}
+
+func (testSecHelper) VerifyEnrollmentSignature(ecert, signature, message []byte) error {
+	cert, err := utils.DERToX509Certificate(ecert)
+	if err != nil {
+		return err
+	}
+	if ok, err := utils.ECDSAVerify(cert.PublicKey, message, signature); err != nil || !ok {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+type testAdminPeer struct {
+	*testIdentityRotator
+	sec crypto.Peer
+}
+
+func (p *testAdminPeer) GetSecHelper() crypto.Peer {
+	return p.sec
+}
+
+// signTestAdminCredentials returns the credentials of the admin action on
+// the target signed with key, made at the time
+func signTestAdminCredentials(t *testing.T, ecert []byte, key *ecdsa.PrivateKey, action, target string, made time.Time) *AdminCredentials {
+	conf.InitSecurityLevel(256)
+	nonce := fmt.Sprintf("%d-%s", made.UnixNano(), util.GenerateUUID())
+	signature, err := utils.ECDSASign(key, AdminSigningBytes(viper.GetString("peer.id"), action, target, nonce))
+	if err != nil {
+		t.Fatalf("Error signing admin credentials: %s", err)
+	}
+	return &AdminCredentials{Cert: ecert, Nonce: nonce, Signature: signature}
+}
+
+func TestAdminServerAuthorization(t *testing.T) {
+	viper.Set("peer.admin.authorization.enabled", true)
+	defer viper.Set("peer.admin.authorization.enabled", false)
+	admin := NewAdminServer()
+
+	// without admin credentials, or without security to verify them, admin
+	// operations are refused
+	ecert, key := createTestECertAndKey(t, "ops", obcca.Role_ADMIN)
+	ctx := signTestAdminCredentials(t, ecert, key, AdminActionSetLogLevel, "server", time.Now()).NewContext(context.Background())
+	for _, c := range []context.Context{context.Background(), ctx} {
+		if _, err := admin.SetLogLevel(c, &pb.LogLevel{Module: "server", Level: "debug"}); err == nil {
+			t.Fatal("Expected the log level change to be refused")
+		}
+		if _, err := admin.DrainServer(c, &pb.DrainRequest{}); err == nil {
+			t.Fatal("Expected the drain to be refused")
+		}
+		if _, err := admin.RollbackToCheckpoint(c, &pb.CheckpointRollback{Name: "cp", Confirm: true}); err == nil {
+			t.Fatal("Expected the rollback to be refused")
+		}
+		if _, err := admin.RotateIdentity(c, &google_protobuf.Empty{}); err == nil {
+			t.Fatal("Expected the identity rotation to be refused")
+		}
+		if _, err := admin.BackupLedger(c, &pb.LedgerBackup{Path: "daily"}); err == nil {
+			t.Fatal("Expected the backup to be refused")
+		}
+		if _, err := admin.StopServer(c, &google_protobuf.Empty{}); err == nil {
+			t.Fatal("Expected the stop to be refused")
+		}
+		if _, err := admin.PauseServer(c, &google_protobuf.Empty{}); err == nil {
+			t.Fatal("Expected the pause to be refused")
+		}
+		if _, err := admin.ResumeServer(c, &google_protobuf.Empty{}); err == nil {
+			t.Fatal("Expected the resume to be refused")
+		}
+		if _, err := admin.TagCheckpoint(c, &pb.Checkpoint{Name: "cp"}); err == nil {
+			t.Fatal("Expected the checkpoint to be refused")
+		}
+		if _, err := admin.InjectFault(c, &pb.Fault{Fail: true}); err == nil {
+			t.Fatal("Expected the fault injection to be refused")
+		}
+		if _, err := admin.ClearFaults(c, &google_protobuf.Empty{}); err == nil {
+			t.Fatal("Expected clearing the faults to be refused")
+		}
+		if _, err := admin.SetChaincodeTrace(c, &pb.ChaincodeTrace{Name: "mycc", Enabled: true}); err == nil {
+			t.Fatal("Expected the chaincode trace to be refused")
+		}
+	}
+	if level := GetLoggingLevel("server"); level == "DEBUG" {
+		t.Fatal("Expected the log level to be unchanged")
+	}
+	if peer.IntakePaused() {
+		t.Fatal("Expected the peer not to be paused")
+	}
+}
+
+func TestAdminServerCredentials(t *testing.T) {
+	viper.Set("peer.admin.authorization.enabled", true)
+	defer viper.Set("peer.admin.authorization.enabled", false)
+	defer SetLoggingLevel("server", GetLoggingLevel("server"))
+	admin := NewAdminServerWithPeer(&testAdminPeer{sec: testSecHelper{}})
+	ecert, key := createTestECertAndKey(t, "ops", obcca.Role_ADMIN)
+	setLevel := func(creds *AdminCredentials) error {
+		_, err := admin.SetLogLevel(creds.NewContext(context.Background()), &pb.LogLevel{Module: "server", Level: "debug"})
+		return err
+	}
+
+	creds := signTestAdminCredentials(t, ecert, key, AdminActionSetLogLevel, "server", time.Now())
+	if err := setLevel(creds); err != nil {
+		t.Fatalf("Expected the admin to change the log level: %s", err)
+	}
+	if err := setLevel(creds); err == nil {
+		t.Fatal("Expected replayed credentials to be refused")
+	}
+	if err := setLevel(signTestAdminCredentials(t, ecert, key, AdminActionDrain, "peer", time.Now())); err == nil {
+		t.Fatal("Expected the credentials of another action to be refused")
+	}
+	if err := setLevel(signTestAdminCredentials(t, ecert, key, AdminActionSetLogLevel, "server", time.Now().Add(-time.Hour))); err == nil {
+		t.Fatal("Expected expired credentials to be refused")
+	}
+
+	// credentials are bound to the peer they were made for
+	defer viper.Set("peer.id", viper.GetString("peer.id"))
+	viper.Set("peer.id", "vp1")
+	creds = signTestAdminCredentials(t, ecert, key, AdminActionSetLogLevel, "server", time.Now())
+	viper.Set("peer.id", "vp2")
+	if err := setLevel(creds); err == nil {
+		t.Fatal("Expected the credentials made for another peer to be refused")
+	}
+	viper.Set("peer.id", "vp1")
+	if err := setLevel(creds); err != nil {
+		t.Fatalf("Expected the credentials made for the peer to be accepted: %s", err)
+	}
+
+	// the certificate of an admin without its key proves nothing
+	_, otherKey := createTestECertAndKey(t, "mallory", obcca.Role_CLIENT)
+	if err := setLevel(signTestAdminCredentials(t, ecert, otherKey, AdminActionSetLogLevel, "server", time.Now())); err == nil {
+		t.Fatal("Expected credentials not signed by the admin to be refused")
+	}
+
+	client, clientKey := createTestECertAndKey(t, "alice", obcca.Role_CLIENT)
+	if err := setLevel(signTestAdminCredentials(t, client, clientKey, AdminActionSetLogLevel, "server", time.Now())); err == nil {
+		t.Fatal("Expected a client to be refused")
+	}
+}
+
+func TestAdminServerLocalNodeControl(t *testing.T) {
+	viper.Set("peer.admin.authorization.enabled", true)
+	defer viper.Set("peer.admin.authorization.enabled", false)
+	defer peer.ResumeIntake()
+	p := &testAdminPeer{sec: testSecHelper{}}
+	ecert, key := createTestECertAndKey(t, "ops", obcca.Role_ADMIN)
+	pause := func(admin *ServerAdmin) error {
+		ctx := signTestAdminCredentials(t, ecert, key, AdminActionPause, "peer", time.Now()).NewContext(context.Background())
+		_, err := admin.PauseServer(ctx, &google_protobuf.Empty{})
+		return err
+	}
+
+	// the public address refuses node management even to admins
+	if err := pause(NewAdminServerWithPeer(p)); err == nil {
+		t.Fatal("Expected the pause to be refused on the public address")
+	}
+	if peer.IntakePaused() {
+		t.Fatal("Expected the peer not to be paused")
+	}
+
+	if err := pause(NewLocalAdminServer(p)); err != nil {
+		t.Fatalf("Expected the admin to pause the peer on the local address: %s", err)
+	}
+	if !peer.IntakePaused() {
+		t.Fatal("Expected the peer to be paused")
+	}
+}
+
+type testIdentityRotator struct {
+	rotations int
+}
+
+func (r *testIdentityRotator) RotateIdentity() (*pb.IdentityRotation, error) {
+	r.rotations++
+	return &pb.IdentityRotation{EnrollmentID: "vp0", PreviousPkiID: []byte{1}, PkiID: []byte{2}}, nil
+}
+
+func TestAdminRotateIdentity(t *testing.T) {
+	if _, err := NewAdminServer().RotateIdentity(context.Background(), &google_protobuf.Empty{}); err == nil {
+		t.Fatal("Expected the identity rotation to fail without a peer")
+	}
+
+	rotator := &testIdentityRotator{}
+	rotation, err := NewAdminServerWithPeer(&testAdminPeer{testIdentityRotator: rotator}).RotateIdentity(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Error rotating identity: %s", err)
+	}
+	if rotator.rotations != 1 || rotation.EnrollmentID != "vp0" {
+		t.Fatalf("Expected the peer identity to be rotated once, got %d rotations of %s", rotator.rotations, rotation.EnrollmentID)
+	}
+}
+
+func TestCheckAdminCert(t *testing.T) {
+	if err := checkAdminCert(createTestECert(t, "ops", obcca.Role_ADMIN), AdminActionDrain, "peer"); err != nil {
+		t.Fatalf("Expected an admin to be allowed: %s", err)
+	}
+	if err := checkAdminCert(createTestECert(t, "auditor", obcca.Role_AUDITOR), AdminActionDrain, "peer"); err == nil {
+		t.Fatal("Expected an auditor to be refused")
+	}
+	if err := checkAdminCert([]byte("not a certificate"), AdminActionDrain, "peer"); err == nil {
+		t.Fatal("Expected an invalid certificate to be refused")
+	}
+}
@@ -19,9 +19,58 @@ under the License.
 
 package openchain
 
-import "testing"
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
 
 func TestServer_Status(t *testing.T) {
 	t.Skip("TBD")
 	//performHandshake(t, peerClientConn)
 }
+
+func TestBackupPath(t *testing.T) {
+	defer viper.Set("peer.admin.backup.directory", viper.GetString("peer.admin.backup.directory"))
+	viper.Set("peer.admin.backup.directory", "/var/openchain/backups/")
+
+	for path, expected := range map[string]string{
+		"daily":                               "/var/openchain/backups/daily",
+		"2016/06/01":                          "/var/openchain/backups/2016/06/01",
+		"/var/openchain/backups/weekly":       "/var/openchain/backups/weekly",
+		"weekly/../monthly":                   "/var/openchain/backups/monthly",
+		"/var/openchain/backups/x/../..daily": "/var/openchain/backups/..daily",
+	} {
+		actual, err := backupPath(path)
+		if err != nil {
+			t.Fatalf("Error resolving backup path %s: %s", path, err)
+		}
+		if actual != filepath.FromSlash(expected) {
+			t.Fatalf("Expected backup path %s to resolve to %s, got %s", path, expected, actual)
+		}
+	}
+
+	for _, path := range []string{".", "/var/openchain/backups", "..", "../ledger", "daily/../../ledger", "/etc", "/var/openchain/backups-old"} {
+		if actual, err := backupPath(path); err == nil {
+			t.Fatalf("Expected backup path %s outside the backup directory to be refused, got %s", path, actual)
+		}
+	}
+}
+
+func TestLocalAdminAddress(t *testing.T) {
+	defer viper.Set("peer.admin.localAddress", viper.GetString("peer.admin.localAddress"))
+
+	for _, address := range []string{"127.0.0.1:30306", "localhost:30306", "[::1]:30306", ""} {
+		viper.Set("peer.admin.localAddress", address)
+		if actual, err := LocalAdminAddress(); err != nil || actual != address {
+			t.Fatalf("Expected local admin address %q to be accepted, got %q, %v", address, actual, err)
+		}
+	}
+	for _, address := range []string{"0.0.0.0:30306", "10.0.0.1:30306", ":30306", "peer0:30306", "127.0.0.1"} {
+		viper.Set("peer.admin.localAddress", address)
+		if _, err := LocalAdminAddress(); err == nil {
+			t.Fatalf("Expected local admin address %q to be refused", address)
+		}
+	}
+}
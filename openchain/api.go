@@ -37,6 +37,9 @@ var (
 	ErrNotFound = errors.New("openchain: resource not found")
 )
 
+// maxBlockHeaders is the most block headers returned by GetBlockHeaders
+const maxBlockHeaders = 1000
+
 // PeerInfo
 type PeerInfo interface {
 	GetPeers() (*pb.PeersMessage, error)
@@ -139,6 +142,54 @@ func (s *ServerOpenchain) GetBlockCount(ctx context.Context, e *google_protobuf1
 	return nil, fmt.Errorf("No blocks in blockchain.")
 }
 
+// GetStateProof returns the committed value of a key in the state of a chain
+// hosted by the peer, along with its proof against the state hash of the
+// block at the head of the chain.
+func (s *ServerOpenchain) GetStateProof(ctx context.Context, req *pb.StateProofRequest) (*pb.StateProof, error) {
+	chainLedger, err := ledger.GetChainLedger(req.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	return chainLedger.GetStateProof(req.ChaincodeID, req.Key)
+}
+
+// GetBlockHeaders returns the headers of a range of blocks of the blockchain
+// along with the signatures of the validators that committed them. At most
+// maxBlockHeaders headers are returned, the light client asks for the rest
+// in subsequent requests.
+func (s *ServerOpenchain) GetBlockHeaders(ctx context.Context, req *pb.BlockHeadersRequest) (*pb.BlockHeaders, error) {
+	size := s.ledger.GetBlockchainSize()
+	if req.Start > req.End || req.Start >= size {
+		return nil, ErrNotFound
+	}
+	end := req.End
+	if end >= size {
+		end = size - 1
+	}
+	if end-req.Start >= maxBlockHeaders {
+		end = req.Start + maxBlockHeaders - 1
+	}
+	headers := &pb.BlockHeaders{}
+	for number := req.Start; number <= end; number++ {
+		header, err := s.ledger.GetBlockHeader(number)
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving block header from blockchain: %s", err)
+		}
+		headers.Headers = append(headers.Headers, header)
+	}
+	return headers, nil
+}
+
+// GetTransactionProof returns the proof that a transaction is committed in a
+// block of the blockchain, checked against the header of the block.
+func (s *ServerOpenchain) GetTransactionProof(ctx context.Context, req *pb.TransactionProofRequest) (*pb.TransactionProof, error) {
+	proof, err := s.ledger.GetTransactionProof(req.Uuid)
+	if err == ledger.ErrResourceNotFound {
+		return nil, ErrNotFound
+	}
+	return proof, err
+}
+
 // GetState returns the value for a particular chaincode ID and key
 func (s *ServerOpenchain) GetState(ctx context.Context, chaincodeID, key string) ([]byte, error) {
 	return s.ledger.GetState(chaincodeID, key, true)
@@ -175,6 +175,40 @@ func TestServerOpenchain_API_GetBlockByNumber(t *testing.T) {
 	}
 }
 
+func TestServerOpenchain_API_GetBlockHeaders(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger1 := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger1, t)
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+	server.ledger = ledger1
+
+	// Ranges past the end of the blockchain are cut short
+	headers, err := server.GetBlockHeaders(context.Background(), &protos.BlockHeadersRequest{Start: 1, End: 10})
+	if err != nil {
+		t.Fatalf("Error retrieving block headers: %s", err)
+	}
+	if len(headers.Headers) != 2 {
+		t.Fatalf("Expected the headers of blocks 1 and 2, got %d headers", len(headers.Headers))
+	}
+	for _, header := range headers.Headers {
+		block, _ := ledger1.GetBlockByNumber(header.Number)
+		hash, _ := block.GetHash()
+		if !bytes.Equal(header.Hash, hash) || !bytes.Equal(header.StateHash, block.StateHash) {
+			t.Fatalf("Header of block %d does not match the block", header.Number)
+		}
+	}
+	if !bytes.Equal(headers.Headers[1].PreviousBlockHash, headers.Headers[0].Hash) {
+		t.Fatalf("Expected block 2 to follow block 1")
+	}
+
+	if _, err := server.GetBlockHeaders(context.Background(), &protos.BlockHeadersRequest{Start: 3, End: 4}); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound retrieving headers past the end of the blockchain, got %v", err)
+	}
+}
+
 func TestServerOpenchain_API_GetBlockCount(t *testing.T) {
 	// Must initialize the ledger singleton before initializing the
 	// OpenchainServer, as it needs that pointer.
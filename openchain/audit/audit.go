@@ -0,0 +1,244 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package audit keeps a tamper-evident log of the administrative and
+// security events of a peer or of the CA: deployments, configuration
+// changes, enrollments and revocations of identities, and admin commands.
+// The log is only appended to, one JSON record per line, and each record
+// holds the hash of the record before it, so that changing or removing a
+// record breaks the chain. Verify detects a broken chain; removing the last
+// records is only detected by comparing the head hash with one recorded
+// earlier, e.g. by Export.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("audit")
+
+// Categories of events
+const (
+	CategoryAdmin      = "admin"
+	CategoryConfig     = "config"
+	CategoryDeployment = "deployment"
+	CategoryIdentity   = "identity"
+)
+
+// maxRecordSize bounds the length of the lines read back
+const maxRecordSize = 1024 * 1024
+
+// Entry is an event of the log
+type Entry struct {
+	Sequence uint64 `json:"sequence"`
+	Time     string `json:"time"`
+	Category string `json:"category"`
+	Actor    string `json:"actor"`
+	Action   string `json:"action"`
+	Target   string `json:"target,omitempty"`
+	// Error is why the action was refused or failed, empty if it succeeded
+	Error    string `json:"error,omitempty"`
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// computeHash returns the hash of all the fields of the entry but its hash
+func (r *Entry) computeHash() (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+	raw, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends records to a file
+type Log struct {
+	sync.Mutex
+	file *os.File
+	next uint64
+	head string
+	now  func() time.Time
+}
+
+// Open opens the log in the file, creating it if needed. The records already
+// in the file are verified, and it is not opened if their chain is broken.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	count, head, err := Verify(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Audit log %s is not intact: %s", path, err)
+	}
+	return &Log{file: file, next: count, head: head, now: time.Now}, nil
+}
+
+// Append appends the event, refused or failed if err is not nil, to the log
+// and syncs it to disk
+func (l *Log) Append(category, actor, action, target string, err error) (*Entry, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	r := &Entry{
+		Sequence: l.next,
+		Time:     l.now().UTC().Format(time.RFC3339Nano),
+		Category: category,
+		Actor:    actor,
+		Action:   action,
+		Target:   target,
+		PrevHash: l.head,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	hash, err := r.computeHash()
+	if err != nil {
+		return nil, err
+	}
+	r.Hash = hash
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	// one write per record, so that records are not interleaved
+	if _, err = l.file.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	if err = l.file.Sync(); err != nil {
+		return nil, err
+	}
+	l.next++
+	l.head = hash
+	return r, nil
+}
+
+// Head returns the number of records of the log and the hash of the last one
+func (l *Log) Head() (uint64, string) {
+	l.Lock()
+	defer l.Unlock()
+	return l.next, l.head
+}
+
+// Close closes the file of the log
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Verify reads the records of a log and checks their sequence numbers and
+// hash chain. It returns the number of records and the hash of the last one.
+func Verify(r io.Reader) (uint64, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxRecordSize)
+	var count uint64
+	var head string
+	for scanner.Scan() {
+		var record Entry
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, head, fmt.Errorf("Invalid record %d: %s", count, err)
+		}
+		if record.Sequence != count {
+			return count, head, fmt.Errorf("Record %d has sequence number %d", count, record.Sequence)
+		}
+		if record.PrevHash != head {
+			return count, head, fmt.Errorf("Record %d does not hold the hash of the record before it", count)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return count, head, err
+		}
+		if record.Hash != hash {
+			return count, head, fmt.Errorf("Record %d does not match its hash", count)
+		}
+		count++
+		head = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return count, head, err
+	}
+	return count, head, nil
+}
+
+// Export verifies the log in the file src while copying it to dst. dst is
+// removed if the log is not intact.
+func Export(src, dst string) (uint64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+	count, head, err := Verify(io.TeeReader(in, out))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return 0, "", err
+	}
+	return count, head, nil
+}
+
+// defaultLog is the log the events of the process are recorded in
+var defaultLog struct {
+	sync.RWMutex
+	log *Log
+}
+
+// SetDefault sets the log Record appends to, nil to stop recording
+func SetDefault(l *Log) {
+	defaultLog.Lock()
+	defer defaultLog.Unlock()
+	defaultLog.log = l
+}
+
+// Record appends the event, refused or failed if err is not nil, to the
+// default log, if any. The event has already happened, so failing to record
+// it is logged rather than returned.
+func Record(category, actor, action, target string, err error) {
+	defaultLog.RLock()
+	defer defaultLog.RUnlock()
+	if defaultLog.log == nil {
+		return
+	}
+	if _, aerr := defaultLog.log.Append(category, actor, action, target, err); aerr != nil {
+		logger.Error("Failed to record %s %s of %s in the audit log: %s", category, action, actor, aerr)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestLog(t *testing.T) (*Log, string, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("Error creating directory: %s", err)
+	}
+	path := filepath.Join(dir, "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error opening the audit log: %s", err)
+	}
+	return l, path, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAppendAndVerify(t *testing.T) {
+	l, path, cleanup := openTestLog(t)
+	defer cleanup()
+
+	first, err := l.Append(CategoryDeployment, "alice", "deploy", "mycc", nil)
+	if err != nil {
+		t.Fatalf("Error appending: %s", err)
+	}
+	second, err := l.Append(CategoryAdmin, "bob", "drain", "peer", errors.New("not an admin"))
+	if err != nil {
+		t.Fatalf("Error appending: %s", err)
+	}
+	if first.Sequence != 0 || second.Sequence != 1 || second.PrevHash != first.Hash || second.Error != "not an admin" {
+		t.Fatalf("Unexpected records %+v and %+v", first, second)
+	}
+
+	// reopening resumes the chain
+	l.Close()
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Error reopening the audit log: %s", err)
+	}
+	if count, head := l.Head(); count != 2 || head != second.Hash {
+		t.Fatalf("Expected 2 records ending with %s, got %d ending with %s", second.Hash, count, head)
+	}
+	third, err := l.Append(CategoryConfig, "config file", "set", "logging.peer=debug", nil)
+	if err != nil {
+		t.Fatalf("Error appending: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading the audit log: %s", err)
+	}
+	count, head, err := Verify(bytes.NewReader(raw))
+	if err != nil || count != 3 || head != third.Hash {
+		t.Fatalf("Expected 3 intact records ending with %s, got %d ending with %s, error %v", third.Hash, count, head, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	l, path, cleanup := openTestLog(t)
+	defer cleanup()
+	for _, actor := range []string{"alice", "bob", "carol"} {
+		if _, err := l.Append(CategoryAdmin, actor, "set-log-level", "peer", nil); err != nil {
+			t.Fatalf("Error appending: %s", err)
+		}
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading the audit log: %s", err)
+	}
+	lines := strings.SplitAfter(string(raw), "\n")
+
+	tampered := map[string]string{
+		"changed":   strings.Replace(string(raw), "bob", "eve", 1),
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+		"garbled":   lines[0] + "not json\n",
+	}
+	for name, content := range tampered {
+		if _, _, err := Verify(strings.NewReader(content)); err == nil {
+			t.Fatalf("Expected the %s log to fail verification", name)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(tampered["changed"]), 0600); err != nil {
+		t.Fatalf("Error writing the audit log: %s", err)
+	}
+	l.Close()
+	if _, err := Open(path); err == nil {
+		t.Fatal("Expected a tampered audit log not to open")
+	}
+}
+
+func TestExport(t *testing.T) {
+	l, path, cleanup := openTestLog(t)
+	defer cleanup()
+	entry, err := l.Append(CategoryIdentity, "alice", "login", "", nil)
+	if err != nil {
+		t.Fatalf("Error appending: %s", err)
+	}
+
+	dst := filepath.Join(filepath.Dir(path), "export.log")
+	count, head, err := Export(path, dst)
+	if err != nil || count != 1 || head != entry.Hash {
+		t.Fatalf("Expected 1 record ending with %s, got %d ending with %s, error %v", entry.Hash, count, head, err)
+	}
+	if _, _, err := Export(path, dst); err == nil {
+		t.Fatal("Expected an export not to overwrite a file")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json\n"), 0600); err != nil {
+		t.Fatalf("Error writing the audit log: %s", err)
+	}
+	broken := filepath.Join(filepath.Dir(path), "broken.log")
+	if _, _, err := Export(path, broken); err == nil {
+		t.Fatal("Expected exporting a broken log to fail")
+	}
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Fatalf("Expected the export of a broken log to be removed, got %v", err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	l, _, cleanup := openTestLog(t)
+	defer cleanup()
+
+	Record(CategoryAdmin, "alice", "drain", "peer", nil)
+	SetDefault(l)
+	defer SetDefault(nil)
+	Record(CategoryAdmin, "alice", "drain", "peer", nil)
+	if count, _ := l.Head(); count != 1 {
+		t.Fatalf("Expected only the event recorded with a default log, got %d records", count)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// maxBatchInvocations bounds the number of invocations of a batch
+const maxBatchInvocations = 1000
+
+// batchCommitPollInterval is how often the ledger is checked for the
+// transactions of a batch while waiting for them to be committed
+const batchCommitPollInterval = 500 * time.Millisecond
+
+// InvokeBatch submits the invocations of a batch in order and streams their
+// progress, see SubmitBatch
+func (d *Devops) InvokeBatch(spec *pb.BatchInvocationSpec, stream pb.Devops_InvokeBatchServer) error {
+	return d.SubmitBatch(stream.Context(), spec, stream.Send)
+}
+
+// SubmitBatch submits the invocations of a batch in order, one after the
+// other, and sends the UUID or failure of each once they are all submitted.
+// If the batch has a commit timeout, the progress is sent again every time
+// invocations are committed, until all are or the timeout expires. The last
+// progress sent is done.
+func (d *Devops) SubmitBatch(ctx context.Context, spec *pb.BatchInvocationSpec, send func(*pb.BatchInvocationProgress) error) error {
+	invoke := func(invocation *pb.ChaincodeInvocationSpec) (string, error) {
+		resp, err := d.invokeOrQuery(ctx, invocation, true)
+		if err != nil {
+			return "", err
+		}
+		return string(resp.Msg), nil
+	}
+	return runBatch(ctx, spec, invoke, d.submissions.committed, batchCommitPollInterval, send)
+}
+
+func runBatch(ctx context.Context, spec *pb.BatchInvocationSpec, invoke func(*pb.ChaincodeInvocationSpec) (string, error),
+	committed func(chainID string, uuid string) bool, pollInterval time.Duration, send func(*pb.BatchInvocationProgress) error) error {
+	if len(spec.Invocations) == 0 {
+		return fmt.Errorf("batch has no invocations")
+	}
+	if len(spec.Invocations) > maxBatchInvocations {
+		return fmt.Errorf("batch has %d invocations, at most %d are allowed", len(spec.Invocations), maxBatchInvocations)
+	}
+	if spec.CommitTimeout < 0 {
+		return fmt.Errorf("commit timeout of the batch must not be negative, got %d", spec.CommitTimeout)
+	}
+
+	progress := &pb.BatchInvocationProgress{}
+	failed := false
+	for i, invocation := range spec.Invocations {
+		status := &pb.BatchInvocationStatus{Index: int32(i)}
+		progress.Invocations = append(progress.Invocations, status)
+		if failed && spec.StopOnFailure {
+			status.Status = pb.BatchInvocationStatus_SKIPPED
+			continue
+		}
+		var err error
+		if invocation.GetChaincodeSpec().GetChaincodeID() == nil {
+			err = fmt.Errorf("name not given for invoke")
+		} else {
+			status.Uuid, err = invoke(invocation)
+		}
+		if err != nil {
+			devopsLogger.Debug("Invocation %d of batch failed: %s", i, err)
+			status.Status = pb.BatchInvocationStatus_FAILED
+			status.Msg = err.Error()
+			failed = true
+		}
+	}
+	countBatchProgress(progress)
+	if spec.CommitTimeout == 0 || progress.Submitted == 0 {
+		progress.Done = true
+		return send(progress)
+	}
+	if err := send(progress); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	timeout := time.After(time.Duration(spec.CommitTimeout) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			progress.Done = true
+			return send(progress)
+		case <-ticker.C:
+			changed := false
+			for _, status := range progress.Invocations {
+				if status.Status != pb.BatchInvocationStatus_SUBMITTED {
+					continue
+				}
+				if committed(spec.Invocations[status.Index].ChaincodeSpec.ChainID, status.Uuid) {
+					status.Status = pb.BatchInvocationStatus_COMMITTED
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			countBatchProgress(progress)
+			progress.Done = progress.Submitted == 0
+			if err := send(progress); err != nil || progress.Done {
+				return err
+			}
+		}
+	}
+}
+
+// countBatchProgress counts the invocations of a batch with each status
+func countBatchProgress(progress *pb.BatchInvocationProgress) {
+	progress.Submitted, progress.Committed, progress.Failed, progress.Skipped = 0, 0, 0, 0
+	for _, status := range progress.Invocations {
+		switch status.Status {
+		case pb.BatchInvocationStatus_SUBMITTED:
+			progress.Submitted++
+		case pb.BatchInvocationStatus_COMMITTED:
+			progress.Committed++
+		case pb.BatchInvocationStatus_FAILED:
+			progress.Failed++
+		case pb.BatchInvocationStatus_SKIPPED:
+			progress.Skipped++
+		}
+	}
+}
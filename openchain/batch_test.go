@@ -0,0 +1,116 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func newBatch(names ...string) *pb.BatchInvocationSpec {
+	spec := &pb.BatchInvocationSpec{}
+	for _, name := range names {
+		spec.Invocations = append(spec.Invocations, &pb.ChaincodeInvocationSpec{
+			ChaincodeSpec: &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: name}}})
+	}
+	return spec
+}
+
+// invokeUnless returns the chaincode name as UUID, and fails for the name
+func invokeUnless(failing string) func(*pb.ChaincodeInvocationSpec) (string, error) {
+	return func(spec *pb.ChaincodeInvocationSpec) (string, error) {
+		if spec.ChaincodeSpec.ChaincodeID.Name == failing {
+			return "", fmt.Errorf("invocation of %s failed", failing)
+		}
+		return spec.ChaincodeSpec.ChaincodeID.Name, nil
+	}
+}
+
+func TestRunBatch_Submit(t *testing.T) {
+	var sent []pb.BatchInvocationProgress
+	send := func(progress *pb.BatchInvocationProgress) error {
+		sent = append(sent, *progress)
+		return nil
+	}
+	spec := newBatch("a", "b", "c")
+	spec.StopOnFailure = true
+	if err := runBatch(context.Background(), spec, invokeUnless("b"), nil, time.Millisecond, send); err != nil {
+		t.Fatalf("Error running batch: %s", err)
+	}
+	if len(sent) != 1 || !sent[0].Done {
+		t.Fatalf("Expected a single done progress, got %v", sent)
+	}
+	progress := sent[0]
+	if progress.Submitted != 1 || progress.Failed != 1 || progress.Skipped != 1 {
+		t.Fatalf("Expected 1 submitted, 1 failed and 1 skipped invocation, got %v", progress)
+	}
+	if progress.Invocations[0].Uuid != "a" || progress.Invocations[1].Msg == "" {
+		t.Fatalf("Expected the UUID of the first and the failure of the second invocation, got %v", progress)
+	}
+}
+
+func TestRunBatch_Commit(t *testing.T) {
+	var sent []pb.BatchInvocationProgress
+	send := func(progress *pb.BatchInvocationProgress) error {
+		sent = append(sent, *progress)
+		return nil
+	}
+	committed := map[string]bool{"a": true}
+	isCommitted := func(chainID string, uuid string) bool {
+		if committed[uuid] {
+			// The next poll finds the other transaction
+			committed["c"] = true
+			return true
+		}
+		return false
+	}
+	spec := newBatch("a", "b", "c")
+	spec.CommitTimeout = 5
+	if err := runBatch(context.Background(), spec, invokeUnless("b"), isCommitted, time.Millisecond, send); err != nil {
+		t.Fatalf("Error running batch: %s", err)
+	}
+	if len(sent) < 2 {
+		t.Fatalf("Expected the progress of the submission and the commits, got %v", sent)
+	}
+	if sent[0].Submitted != 2 || sent[0].Failed != 1 || sent[0].Done {
+		t.Fatalf("Expected 2 submitted and 1 failed invocation, got %v", sent[0])
+	}
+	last := sent[len(sent)-1]
+	if !last.Done || last.Committed != 2 || last.Submitted != 0 {
+		t.Fatalf("Expected 2 committed invocations at the end, got %v", last)
+	}
+}
+
+func TestRunBatch_Invalid(t *testing.T) {
+	send := func(*pb.BatchInvocationProgress) error { return nil }
+	if err := runBatch(context.Background(), newBatch(), invokeUnless(""), nil, time.Millisecond, send); err == nil {
+		t.Fatal("Expected an error for an empty batch")
+	}
+	spec := newBatch("a")
+	spec.CommitTimeout = -1
+	if err := runBatch(context.Background(), spec, invokeUnless(""), nil, time.Millisecond, send); err == nil {
+		t.Fatal("Expected an error for a negative commit timeout")
+	}
+}
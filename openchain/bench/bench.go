@@ -0,0 +1,264 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package bench generates load on a peer and measures it end to end: invoke
+// transactions are timed from their submission until the event hub reports
+// them committed, and queries until their result is returned.
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/client"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var logger = logging.MustGetLogger("bench")
+
+// SampleChaincodePath is the path of the chaincode deployed by Deploy. Its
+// invokes move one unit between two accounts and its queries read one.
+const SampleChaincodePath = "github.com/openblockchain/obc-peer/openchain/example/chaincode/chaincode_example02"
+
+// Client is the part of client.Client driven by Run
+type Client interface {
+	Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (string, error)
+	Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) ([]byte, error)
+	WaitForCommit(ctx context.Context, uuid string) (*pb.Block, error)
+}
+
+// Config sets the load generated by Run
+type Config struct {
+	// Chaincode is the name of the deployed sample chaincode
+	Chaincode string
+	// Rate is the number of transactions, invokes and queries, started per
+	// second
+	Rate float64
+	// QueryRatio is the fraction of the transactions that are queries
+	QueryRatio float64
+	// Duration is how long transactions are started for
+	Duration time.Duration
+	// MaxInFlight bounds the transactions in progress. Transactions due
+	// while it is reached are skipped, and reported as such.
+	MaxInFlight int
+	// Timeout bounds the time an invoke is waited for to be committed
+	Timeout time.Duration
+}
+
+// Latencies summarizes the latencies of a kind of transaction
+type Latencies struct {
+	Count int
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Report is the outcome of a run
+type Report struct {
+	// Elapsed is the time from the first transaction until the last one
+	// completed
+	Elapsed time.Duration
+	// Skipped counts the transactions not started as MaxInFlight was reached
+	Skipped  int
+	Rejected int
+	// TimedOut counts the invokes not committed within the timeout
+	TimedOut int
+	Failed   int
+	// Invokes are the latencies of the committed invokes
+	Invokes Latencies
+	// Queries are the latencies of the successful queries
+	Queries Latencies
+	// Throughput is the number of committed invokes per second
+	Throughput float64
+}
+
+// Deploy deploys the sample chaincode and waits until it is ready, returning
+// its name
+func Deploy(ctx context.Context, c *client.Client) (string, error) {
+	spec := &pb.ChaincodeSpec{
+		Type:        pb.ChaincodeSpec_GOLANG,
+		ChaincodeID: &pb.ChaincodeID{Path: SampleChaincodePath},
+		CtorMsg:     &pb.ChaincodeInput{Function: "init", Args: []string{"a", "1000000000", "b", "1000000000"}},
+	}
+	name, err := c.Deploy(ctx, spec)
+	if err != nil {
+		return "", fmt.Errorf("Error deploying %s: %s", SampleChaincodePath, err)
+	}
+	if _, err = c.WaitForDeployment(ctx, name); err != nil {
+		return "", err
+	}
+	logger.Info("Deployed sample chaincode %s", name)
+	return name, nil
+}
+
+type outcome struct {
+	query   bool
+	latency time.Duration
+	err     error
+}
+
+// Run drives the transactions of config against the sample chaincode and
+// reports their latencies. It returns once the started transactions have
+// completed, or ctx is done.
+func Run(ctx context.Context, c Client, config *Config) (*Report, error) {
+	if config.Rate <= 0 {
+		return nil, fmt.Errorf("Invalid rate %v, it must be positive", config.Rate)
+	}
+	if config.QueryRatio < 0 || config.QueryRatio > 1 {
+		return nil, fmt.Errorf("Invalid query ratio %v, it must be between 0 and 1", config.QueryRatio)
+	}
+	if config.MaxInFlight <= 0 {
+		return nil, fmt.Errorf("Invalid maximum of transactions in flight %d, it must be positive", config.MaxInFlight)
+	}
+
+	report := &Report{}
+	outcomes := make(chan *outcome, config.MaxInFlight)
+	inFlight := make(chan struct{}, config.MaxInFlight)
+	var wg sync.WaitGroup
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / config.Rate))
+	defer ticker.Stop()
+
+	var invokeLatencies, queryLatencies []time.Duration
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for o := range outcomes {
+			switch {
+			case o.err == nil && o.query:
+				queryLatencies = append(queryLatencies, o.latency)
+			case o.err == nil:
+				invokeLatencies = append(invokeLatencies, o.latency)
+			case isRejection(o.err):
+				report.Rejected++
+			case o.err == context.DeadlineExceeded:
+				report.TimedOut++
+			default:
+				logger.Debug("Transaction failed: %s", o.err)
+				report.Failed++
+			}
+		}
+	}()
+
+	start := time.Now()
+	deadline := time.After(config.Duration)
+	random := rand.New(rand.NewSource(start.UnixNano()))
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			report.Skipped++
+			continue
+		}
+		query := random.Float64() < config.QueryRatio
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			outcomes <- runTransaction(ctx, c, config, query)
+		}()
+	}
+	wg.Wait()
+	close(outcomes)
+	<-collected
+
+	report.Elapsed = time.Since(start)
+	report.Invokes = summarize(invokeLatencies)
+	report.Queries = summarize(queryLatencies)
+	report.Throughput = float64(report.Invokes.Count) / report.Elapsed.Seconds()
+	return report, ctx.Err()
+}
+
+// runTransaction runs an invoke, until it is committed, or a query
+func runTransaction(ctx context.Context, c Client, config *Config, query bool) *outcome {
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{
+		Type:        pb.ChaincodeSpec_GOLANG,
+		ChaincodeID: &pb.ChaincodeID{Name: config.Chaincode},
+	}}
+	start := time.Now()
+	if query {
+		spec.ChaincodeSpec.CtorMsg = &pb.ChaincodeInput{Function: "query", Args: []string{"a"}}
+		_, err := c.Query(ctx, spec)
+		return &outcome{query: true, latency: time.Since(start), err: err}
+	}
+	spec.ChaincodeSpec.CtorMsg = &pb.ChaincodeInput{Function: "invoke", Args: []string{"a", "b", "1"}}
+	uuid, err := c.Invoke(ctx, spec)
+	if err != nil {
+		return &outcome{err: err}
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+	_, err = c.WaitForCommit(waitCtx, uuid)
+	return &outcome{latency: time.Since(start), err: err}
+}
+
+func isRejection(err error) bool {
+	_, ok := err.(*client.TxRejectedError)
+	return ok
+}
+
+// summarize computes the mean and the nearest-rank percentiles of latencies
+func summarize(latencies []time.Duration) Latencies {
+	if len(latencies) == 0 {
+		return Latencies{}
+	}
+	sort.Sort(durations(latencies))
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	return Latencies{
+		Count: len(latencies),
+		Mean:  total / time.Duration(len(latencies)),
+		P50:   percentile(latencies, 50),
+		P90:   percentile(latencies, 90),
+		P99:   percentile(latencies, 99),
+		Max:   latencies[len(latencies)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
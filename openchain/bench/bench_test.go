@@ -0,0 +1,109 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package bench
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/client"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// fakeClient commits every other invoke and rejects the others
+type fakeClient struct {
+	lock    sync.Mutex
+	invokes int
+	queries int
+}
+
+func (f *fakeClient) Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (string, error) {
+	if spec.ChaincodeSpec.ChaincodeID.Name != "mycc" || spec.ChaincodeSpec.CtorMsg.Function != "invoke" {
+		return "", fmt.Errorf("Unexpected invoke %v", spec)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.invokes++
+	return fmt.Sprintf("tx%d", f.invokes), nil
+}
+
+func (f *fakeClient) Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.queries++
+	return []byte("100"), nil
+}
+
+func (f *fakeClient) WaitForCommit(ctx context.Context, uuid string) (*pb.Block, error) {
+	time.Sleep(time.Millisecond)
+	var n int
+	fmt.Sscanf(uuid, "tx%d", &n)
+	if n%2 == 0 {
+		return nil, &client.TxRejectedError{UUID: uuid, Reason: "rejected"}
+	}
+	return &pb.Block{}, nil
+}
+
+func TestRun(t *testing.T) {
+	f := &fakeClient{}
+	config := &Config{Chaincode: "mycc", Rate: 500, QueryRatio: 0.5, Duration: 200 * time.Millisecond, MaxInFlight: 10, Timeout: time.Second}
+	report, err := Run(context.Background(), f, config)
+	if err != nil {
+		t.Fatalf("Error running benchmark: %s", err)
+	}
+	if f.invokes == 0 || f.queries == 0 {
+		t.Fatalf("Expected invokes and queries, got %d invokes and %d queries", f.invokes, f.queries)
+	}
+	if report.Invokes.Count+report.Rejected != f.invokes || report.Queries.Count != f.queries {
+		t.Fatalf("Report %+v does not match %d invokes and %d queries", report, f.invokes, f.queries)
+	}
+	if report.Rejected != f.invokes/2 {
+		t.Fatalf("Expected %d rejected invokes, got %d", f.invokes/2, report.Rejected)
+	}
+	if report.Invokes.P50 < time.Millisecond || report.Invokes.Max < report.Invokes.P99 || report.Throughput <= 0 {
+		t.Fatalf("Unexpected invoke latencies %+v and throughput %v", report.Invokes, report.Throughput)
+	}
+
+	if _, err = Run(context.Background(), f, &Config{Rate: 1, QueryRatio: 2, MaxInFlight: 1}); err == nil {
+		t.Fatal("Expected error running with an invalid query ratio")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	summary := summarize(latencies)
+	expected := Latencies{Count: 100, Mean: 50500 * time.Microsecond, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}
+	if summary != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, summary)
+	}
+	if summary = summarize([]time.Duration{time.Second}); summary.P50 != time.Second || summary.P99 != time.Second {
+		t.Fatalf("Unexpected summary of a single latency %+v", summary)
+	}
+	if summary = summarize(nil); summary != (Latencies{}) {
+		t.Fatalf("Expected empty summary, got %+v", summary)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package blobstore keeps large state values out of the state DB. Values are
+// stored by the hash of their content, either in a local directory or in an
+// S3 bucket, and the state holds a reference to the hash in their place.
+package blobstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.MustGetLogger("blobstore")
+
+// ErrNotFound is returned by Get for a hash the store has no blob of
+var ErrNotFound = errors.New("blobstore: blob not found")
+
+// refPrefix starts the references to blobs stored in place of values
+var refPrefix = []byte("\x00blob:sha256:")
+
+// Store keeps blobs by the hex SHA-256 hash of their content
+type Store interface {
+	// Put stores a blob under its hash
+	Put(hash string, blob []byte) error
+	// Get returns the blob of a hash, or ErrNotFound
+	Get(hash string) ([]byte, error)
+}
+
+// Hash returns the hex SHA-256 hash of a blob
+func Hash(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return hex.EncodeToString(sum[:])
+}
+
+// Ref returns the reference to a blob of the given hash
+func Ref(hash string) []byte {
+	return append(append([]byte(nil), refPrefix...), hash...)
+}
+
+// IsRef returns whether a value could be a reference to a blob. Values of
+// chaincodes looking like a reference are stored as blobs too, so that they
+// are never mistaken for one.
+func IsRef(value []byte) bool {
+	return bytes.HasPrefix(value, refPrefix)
+}
+
+// ParseRef returns the hash of a reference
+func ParseRef(ref []byte) (string, error) {
+	if !IsRef(ref) {
+		return "", fmt.Errorf("Invalid blob reference %q", ref)
+	}
+	hash := string(ref[len(refPrefix):])
+	if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+		return "", fmt.Errorf("Invalid blob reference %q", ref)
+	}
+	return hash, nil
+}
+
+// Verify checks that a blob has the given hash, so that a store cannot
+// return anything but the value that was put
+func Verify(hash string, blob []byte) error {
+	if Hash(blob) != hash {
+		return fmt.Errorf("Blob %s does not match its hash", hash)
+	}
+	return nil
+}
+
+// NewFromConfig returns the store configured in chaincode.blobstore, or nil
+// if values are not stored as blobs
+func NewFromConfig() (Store, error) {
+	switch storeType := viper.GetString("chaincode.blobstore.type"); storeType {
+	case "":
+		return nil, nil
+	case "local":
+		path := viper.GetString("chaincode.blobstore.local.path")
+		if path == "" {
+			path = filepath.Join(viper.GetString("peer.fileSystemPath"), "blobs")
+		}
+		return NewLocalStore(path), nil
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  viper.GetString("chaincode.blobstore.s3.endpoint"),
+			Region:    viper.GetString("chaincode.blobstore.s3.region"),
+			Bucket:    viper.GetString("chaincode.blobstore.s3.bucket"),
+			Prefix:    viper.GetString("chaincode.blobstore.s3.prefix"),
+			AccessKey: viper.GetString("chaincode.blobstore.s3.accessKey"),
+			SecretKey: viper.GetString("chaincode.blobstore.s3.secretKey"),
+		})
+	default:
+		return nil, fmt.Errorf("Unknown blob store type %s", storeType)
+	}
+}
+
+// localStore keeps blobs in files of a directory, named after their hash
+type localStore struct {
+	dir string
+}
+
+// NewLocalStore returns a store keeping blobs under dir
+func NewLocalStore(dir string) Store {
+	return &localStore{dir: dir}
+}
+
+func (s *localStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+func (s *localStore) Put(hash string, blob []byte) error {
+	path := s.path(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a blob is never read partly
+	// written
+	tmp, err := ioutil.TempFile(filepath.Dir(path), hash+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(blob)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	logger.Debug("Stored blob %s of %d bytes", hash, len(blob))
+	return nil
+}
+
+func (s *localStore) Get(hash string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(s.path(hash))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return blob, err
+}
@@ -0,0 +1,122 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package blobstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testStore(t *testing.T, store Store) {
+	blob := []byte("a document too large for the state")
+	hash := Hash(blob)
+	if _, err := store.Get(hash); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.Put(hash, blob); err != nil {
+		t.Fatalf("Failed to put blob: %s", err)
+	}
+	// Putting the same content again is harmless
+	if err := store.Put(hash, blob); err != nil {
+		t.Fatalf("Failed to put blob again: %s", err)
+	}
+	got, err := store.Get(hash)
+	if err != nil {
+		t.Fatalf("Failed to get blob: %s", err)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Fatalf("Expected %q, got %q", blob, got)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testStore(t, NewLocalStore(dir))
+}
+
+func TestS3Store(t *testing.T) {
+	var lock sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/bucket/blobs/") {
+			http.Error(w, "unknown bucket", http.StatusNotFound)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case "PUT":
+			body, _ := ioutil.ReadAll(r.Body)
+			if sha256Hex(body) != r.Header.Get("x-amz-content-sha256") {
+				http.Error(w, "bad content hash", http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+		case "GET":
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.Error(w, "no such key", http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "bucket", Prefix: "blobs/", AccessKey: "AKID", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create S3 store: %s", err)
+	}
+	testStore(t, store)
+}
+
+func TestRef(t *testing.T) {
+	hash := Hash([]byte("value"))
+	ref := Ref(hash)
+	if !IsRef(ref) {
+		t.Fatalf("Expected %q to be a reference", ref)
+	}
+	if parsed, err := ParseRef(ref); err != nil || parsed != hash {
+		t.Fatalf("Expected hash %s, got %s (%v)", hash, parsed, err)
+	}
+	if IsRef([]byte("value")) {
+		t.Fatalf("Expected a plain value not to be a reference")
+	}
+	if _, err := ParseRef(Ref("not a hash")); err == nil {
+		t.Fatalf("Expected an invalid reference to be refused")
+	}
+	if err := Verify(hash, []byte("other value")); err == nil {
+		t.Fatalf("Expected a blob of another hash to be refused")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// broadcastPolicy describes how invocations are submitted when they are sent
+// to several validators rather than to the one this peer uses. Submission
+// succeeds once quorum of the validators accepted the transaction, so that
+// a validator failing or lagging does not fail it. The copies the
+// validators submit to consensus are executed once.
+type broadcastPolicy struct {
+	peers   int
+	quorum  int
+	timeout time.Duration
+}
+
+// newBroadcastPolicy returns the configured policy, nil if invocations are
+// sent to a single validator
+func newBroadcastPolicy() (*broadcastPolicy, error) {
+	if !viper.GetBool("peer.broadcast.enabled") {
+		return nil, nil
+	}
+	p := &broadcastPolicy{
+		peers:   viper.GetInt("peer.broadcast.peers"),
+		quorum:  viper.GetInt("peer.broadcast.quorum"),
+		timeout: viper.GetDuration("peer.broadcast.timeout"),
+	}
+	if p.quorum < 1 || p.peers < p.quorum {
+		return nil, fmt.Errorf("Invalid broadcast policy, quorum %d of %d peers", p.quorum, p.peers)
+	}
+	return p, nil
+}
+
+// broadcastTransaction sends the transaction to the validators of the
+// broadcast policy and returns the response of the first of them to accept
+// it once a quorum did, or a failure once the quorum cannot be reached or
+// was not reached in time
+func (d *Devops) broadcastTransaction(tx *pb.Transaction) *pb.Response {
+	addresses, err := d.getValidatorPeers(d.broadcast.peers)
+	if err != nil {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error getting validators to send transaction %s to: %s", tx.Uuid, err))}
+	}
+	if len(addresses) < d.broadcast.quorum {
+		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Cannot send transaction %s, %d validators available for a quorum of %d", tx.Uuid, len(addresses), d.broadcast.quorum))}
+	}
+	// Validators track the transaction until it is committed, a
+	// non-validating peer until it is sent
+	if !viper.GetBool("peer.validator.enabled") {
+		if err = peer.BeginTransaction(tx.Uuid); err != nil {
+			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+		}
+		defer peer.EndTransaction(tx.Uuid)
+	}
+
+	// buffered so that validators answering after the outcome is known do
+	// not block
+	responses := make(chan *pb.Response, len(addresses))
+	for _, address := range addresses {
+		go func(address string) {
+			resp := d.coord.SubmitTransaction(address, tx)
+			if resp.Status != pb.Response_SUCCESS {
+				devopsLogger.Warning("Validator %s did not accept transaction %s: %s", address, tx.Uuid, string(resp.Msg))
+			} else {
+				devopsLogger.Debug("Validator %s accepted transaction %s", address, tx.Uuid)
+			}
+			responses <- resp
+		}(address)
+	}
+	return awaitBroadcastQuorum(tx.Uuid, responses, len(addresses), d.broadcast.quorum, d.broadcast.timeout)
+}
+
+// awaitBroadcastQuorum reads the responses of the total validators a
+// transaction was sent to until quorum of them accepted it, and returns the
+// first acceptance. It fails as soon as too many validators refused the
+// transaction for the quorum to be reached, or after timeout if it is set.
+func awaitBroadcastQuorum(uuid string, responses <-chan *pb.Response, total int, quorum int, timeout time.Duration) *pb.Response {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	var accepted *pb.Response
+	acceptances := 0
+	var refusals []string
+	for acceptances < quorum {
+		select {
+		case resp := <-responses:
+			if resp.Status == pb.Response_SUCCESS {
+				if accepted == nil {
+					accepted = resp
+				}
+				acceptances++
+				continue
+			}
+			refusals = append(refusals, string(resp.Msg))
+			if total-len(refusals) < quorum {
+				return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Transaction %s refused by %d of %d validators where %d acceptances are required: %s",
+					uuid, len(refusals), total, quorum, strings.Join(refusals, "; ")))}
+			}
+		case <-expired:
+			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Transaction %s accepted by %d of %d validators within %s where %d acceptances are required",
+				uuid, acceptances, total, timeout, quorum))}
+		}
+	}
+	return accepted
+}
@@ -0,0 +1,62 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestAwaitBroadcastQuorum(t *testing.T) {
+	success := func(msg string) *pb.Response {
+		return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(msg)}
+	}
+	failure := &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("connection refused")}
+	respond := func(resps ...*pb.Response) <-chan *pb.Response {
+		responses := make(chan *pb.Response, len(resps))
+		for _, resp := range resps {
+			responses <- resp
+		}
+		return responses
+	}
+
+	resp := awaitBroadcastQuorum("tx1", respond(failure, success("a"), success("b")), 3, 2, time.Second)
+	if resp.Status != pb.Response_SUCCESS || string(resp.Msg) != "a" {
+		t.Fatalf("Expected the first acceptance once the quorum is reached, got %s %s", resp.Status, resp.Msg)
+	}
+
+	// The outcome is known without waiting for the last validator
+	resp = awaitBroadcastQuorum("tx1", respond(success("a"), success("b")), 3, 2, 0)
+	if resp.Status != pb.Response_SUCCESS {
+		t.Fatalf("Expected success with a quorum of acceptances, got %s", resp.Msg)
+	}
+	resp = awaitBroadcastQuorum("tx1", respond(failure, failure), 3, 2, 0)
+	if resp.Status != pb.Response_FAILURE {
+		t.Fatalf("Expected failure once the quorum cannot be reached")
+	}
+
+	start := time.Now()
+	resp = awaitBroadcastQuorum("tx1", respond(success("a")), 3, 2, 100*time.Millisecond)
+	if resp.Status != pb.Response_FAILURE || time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("Expected failure after the timeout without a quorum")
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package openchain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+)
+
+// Actions granted by chain policies
+const (
+	chainActionDeploy = "deploy"
+	chainActionInvoke = "invoke"
+	chainActionQuery  = "query"
+)
+
+// defaultChainPolicyKey names the default chain in peer.chainpolicy.chains.
+// It cannot be the ID of another chain.
+const defaultChainPolicyKey = "_default"
+
+// rolePrincipalPrefix prefixes the principals standing for every identity of a role
+const rolePrincipalPrefix = "role:"
+
+// chainPolicy decides who may deploy, invoke and query chaincodes on each
+// chain. Principals are enrollment IDs, or role:<ROLE> for every identity
+// whose enrollment certificate holds the role. An action no principal is
+// listed for is open to everybody.
+type chainPolicy struct {
+	// principals by chain ID and action
+	principals map[string]map[string][]string
+}
+
+// newChainPolicy reads the chain policies from peer.chainpolicy, nil if
+// submissions are not checked
+func newChainPolicy() (*chainPolicy, error) {
+	if !viper.GetBool("peer.chainpolicy.enabled") {
+		return nil, nil
+	}
+	p := &chainPolicy{principals: make(map[string]map[string][]string)}
+	// chains are listed rather than keyed by their ID as viper lower-cases
+	// keys, while chain IDs are case-sensitive like the chain DBs
+	for _, value := range cast.ToSlice(viper.Get("peer.chainpolicy.chains")) {
+		policy := cast.ToStringMap(value)
+		key := cast.ToString(policy["chain"])
+		if key == "" {
+			return nil, fmt.Errorf("Missing chain ID in the chain policies")
+		}
+		if _, ok := p.principals[key]; ok {
+			return nil, fmt.Errorf("Duplicate policy of chain %s", key)
+		}
+		actions := make(map[string][]string)
+		for _, action := range []string{chainActionDeploy, chainActionInvoke, chainActionQuery} {
+			principals := cast.ToStringSlice(policy[action])
+			for _, principal := range principals {
+				if strings.HasPrefix(principal, rolePrincipalPrefix) {
+					if _, ok := obcca.Role_value[strings.TrimPrefix(principal, rolePrincipalPrefix)]; !ok {
+						return nil, fmt.Errorf("Invalid principal %s in the policy of chain %s", principal, key)
+					}
+				}
+			}
+			actions[action] = principals
+		}
+		p.principals[key] = actions
+	}
+	return p, nil
+}
+
+// check returns an error unless the owner of the enrollment certificate may
+// take the action on the chain. Without security there is no certificate and
+// only the actions open to everybody are allowed.
+func (p *chainPolicy) check(chainID string, action string, ecert []byte) error {
+	key := chainID
+	if chainID == "" {
+		key = defaultChainPolicyKey
+	}
+	principals := p.principals[key][action]
+	if len(principals) == 0 {
+		return nil
+	}
+	if ecert == nil {
+		return fmt.Errorf("Not authorized to %s on chain [%s], the chain policy requires security to identify the submitter", action, chainID)
+	}
+	id, role, err := crypto.ParseEnrollmentCertificate(ecert)
+	if err != nil {
+		return err
+	}
+	for _, principal := range principals {
+		if strings.HasPrefix(principal, rolePrincipalPrefix) {
+			if role&obcca.Role_value[strings.TrimPrefix(principal, rolePrincipalPrefix)] != 0 {
+				return nil
+			}
+		} else if principal == id {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not authorized to %s on chain [%s]", id, action, chainID)
+}
+
+// checkChainPolicy returns an error unless the chain policy allows the user
+// of the security client, nil without security, to take the action on the chain
+func (d *Devops) checkChainPolicy(chainID string, action string, sec crypto.Client) error {
+	var ecert []byte
+	if sec != nil {
+		handler, err := sec.GetEnrollmentCertificateHandler()
+		if err != nil {
+			return err
+		}
+		ecert = handler.GetCertificate()
+	}
+	return d.checkChainPolicyCert(chainID, action, ecert)
+}
+
+// checkChainPolicyCert returns an error unless the chain policy allows the
+// owner of the enrollment certificate, nil if unknown, to take the action on
+// the chain
+func (d *Devops) checkChainPolicyCert(chainID string, action string, ecert []byte) error {
+	policy := d.chainPolicy
+	// chain policies set by a configuration transaction replace the configured ones
+	if value, ok := netconfig.Get(netconfig.ChainPolicy); ok {
+		principals, err := netconfig.ParseChainPolicy(value)
+		if err != nil {
+			return err
+		}
+		policy = &chainPolicy{principals: principals}
+	}
+	if policy == nil {
+		return nil
+	}
+	if err := policy.check(chainID, action, ecert); err != nil {
+		devopsLogger.Warning("Rejected submission: %s", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package openchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+)
+
+// createTestECert returns a self-signed certificate with the subject and the
+// role extension of an enrollment certificate
+func createTestECert(t *testing.T, id string, role obcca.Role) []byte {
+	der, _ := createTestECertAndKey(t, id, role)
+	return der
+}
+
+// createTestECertAndKey returns a certificate like createTestECert and the
+// key it certifies
+func createTestECertAndKey(t *testing.T, id string, role obcca.Role) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: id},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: crypto.ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(int(role)))},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	return der, key
+}
+
+func TestChainPolicy(t *testing.T) {
+	viper.Set("peer.chainpolicy.enabled", true)
+	viper.Set("peer.chainpolicy.chains", []interface{}{
+		map[string]interface{}{"chain": "_default", "deploy": []string{"admin"}},
+		map[string]interface{}{"chain": "chain1", "invoke": []string{"alice", "role:VALIDATOR"}},
+	})
+	defer viper.Set("peer.chainpolicy.enabled", false)
+	defer viper.Set("peer.chainpolicy.chains", nil)
+
+	p, err := newChainPolicy()
+	if err != nil {
+		t.Fatalf("Error reading chain policy: %s", err)
+	}
+	admin := createTestECert(t, "admin", obcca.Role_CLIENT)
+	alice := createTestECert(t, "alice", obcca.Role_CLIENT)
+	bob := createTestECert(t, "bob", obcca.Role_CLIENT)
+	validator := createTestECert(t, "vp", obcca.Role_VALIDATOR)
+
+	allowed := []struct {
+		chainID string
+		action  string
+		ecert   []byte
+	}{
+		{"", chainActionDeploy, admin},
+		{"", chainActionInvoke, bob},
+		{"", chainActionQuery, nil},
+		{"chain1", chainActionInvoke, alice},
+		{"CHAIN1", chainActionInvoke, bob},
+		{"chain1", chainActionInvoke, validator},
+		{"chain1", chainActionDeploy, bob},
+		{"chain2", chainActionInvoke, nil},
+	}
+	for _, c := range allowed {
+		if err := p.check(c.chainID, c.action, c.ecert); err != nil {
+			t.Fatalf("Expected %s on chain [%s] to be allowed: %s", c.action, c.chainID, err)
+		}
+	}
+
+	denied := []struct {
+		chainID string
+		action  string
+		ecert   []byte
+	}{
+		{"", chainActionDeploy, alice},
+		{"", chainActionDeploy, nil},
+		{"chain1", chainActionInvoke, bob},
+		{"chain1", chainActionInvoke, nil},
+	}
+	for _, c := range denied {
+		if err := p.check(c.chainID, c.action, c.ecert); err == nil {
+			t.Fatalf("Expected %s on chain [%s] to be denied", c.action, c.chainID)
+		}
+	}
+}
+
+func TestChainPolicyInvalidRole(t *testing.T) {
+	viper.Set("peer.chainpolicy.enabled", true)
+	viper.Set("peer.chainpolicy.chains", []interface{}{
+		map[string]interface{}{"chain": "chain1", "query": []string{"role:NOBODY"}},
+	})
+	defer viper.Set("peer.chainpolicy.enabled", false)
+	defer viper.Set("peer.chainpolicy.chains", nil)
+
+	if _, err := newChainPolicy(); err == nil {
+		t.Fatal("Expected an error for an unknown role")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestExecuteCancelled(t *testing.T) {
+	s := newDevModeSupport()
+	stream := cctesting.NewMockStream()
+	handler := newChaincodeSupportHandler(s, stream)
+	defer handler.sendQueue.close()
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	handler.protocolVersion = pb.ChaincodeProtocolVersion
+	handler.txCtxs = make(map[string]*transactionContext)
+	handler.isTransaction = make(map[string]bool)
+	s.handlerMap.chaincodeMap["mycc"] = handler
+
+	ctxt, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := s.Execute(ctxt, "mycc", &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Uuid: "q1"}, 5*time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected the execution to be cancelled, got %v", err)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_QUERY {
+		t.Fatalf("Expected QUERY to be sent, got %s", msg.Type)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_TX_ABORT || msg.Uuid != "q1" {
+		t.Fatalf("Expected TX_ABORT of q1 to be sent, got %s of %s", msg.Type, msg.Uuid)
+	}
+	if running := s.runningExecutions(); running != 0 {
+		t.Fatalf("Expected the transaction context to be freed, %d left", running)
+	}
+}
+
+func TestStateRequestOfAbortedTransaction(t *testing.T) {
+	handler := &Handler{chaincodeSupport: newDevModeSupport(), ChaincodeID: &pb.ChaincodeID{Name: "mycc"},
+		txCtxs: make(map[string]*transactionContext), isTransaction: make(map[string]bool)}
+	handler.markIsTransaction("tx1", true)
+
+	resp := handler.handleStateRequest(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Uuid: "tx1"}, readystate)
+	if resp == nil || resp.Type != pb.ChaincodeMessage_ERROR || !strings.Contains(string(resp.Payload), "aborted") {
+		t.Fatalf("Expected the request of the aborted transaction to be refused, got %v", resp)
+	}
+}
+
+func TestExecuteOnEndedStream(t *testing.T) {
+	s := newDevModeSupport()
+	stream := cctesting.NewMockStream()
+	handler := newChaincodeSupportHandler(s, stream)
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	if err := s.registerHandler(handler); err != nil {
+		t.Fatalf("Error registering chaincode: %s", err)
+	}
+	go handler.processStream()
+
+	executed := make(chan error)
+	go func() {
+		_, err := s.Execute(context.Background(), "mycc", &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Uuid: "q1"}, 10*time.Second, nil)
+		executed <- err
+	}()
+	<-stream.Sent
+	stream.End()
+
+	select {
+	case err := <-executed:
+		if err == nil || !strings.Contains(err.Error(), "crashed") {
+			t.Fatalf("Expected the execution to fail with the stream, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Execution still waiting after the stream ended")
+	}
+	if _, err := handler.createTxContext(context.Background(), "q2", nil, true); err == nil {
+		t.Fatalf("Expected executions not to start once the stream ended")
+	}
+}
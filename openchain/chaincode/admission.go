@@ -0,0 +1,144 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/metrics"
+)
+
+// ErrAdmissionQueueFull is returned when an execution is refused because
+// too many executions of the chaincode are already waiting
+var ErrAdmissionQueueFull = errors.New("chaincode admission queue full")
+
+var admissionRejectionsMetric = metrics.NewCounter("chaincode.admissionRejections")
+
+func init() {
+	metrics.RegisterFunc("chaincode.admissionQueued", func() interface{} {
+		queued := make(map[string]int)
+		for _, chain := range chains {
+			for chaincode, n := range chain.admission.queuedExecutions() {
+				queued[chaincode] += n
+			}
+		}
+		return queued
+	})
+}
+
+// admissionControl bounds the executions of each chaincode running at a
+// time. Executions over the limit wait in a bounded queue for a slot to free
+// up, so that a flood of invocations of one chaincode is turned away instead
+// of piling up goroutines waiting on the chaincode.
+type admissionControl struct {
+	sync.Mutex
+	// maxExecutions is the number of executions of a chaincode running at a
+	// time, 0 for no limit
+	maxExecutions int
+	queueSize     int
+	queueTimeout  time.Duration
+	chaincodes    map[string]*admissionSlots
+}
+
+// admissionSlots tracks the executions of one chaincode
+type admissionSlots struct {
+	running chan struct{}
+	queued  int
+}
+
+func newAdmissionControl() *admissionControl {
+	return &admissionControl{
+		maxExecutions: viper.GetInt("chaincode.concurrency.maxExecutions"),
+		queueSize:     viper.GetInt("chaincode.concurrency.queueSize"),
+		queueTimeout:  viper.GetDuration("chaincode.concurrency.queueTimeout"),
+		chaincodes:    make(map[string]*admissionSlots),
+	}
+}
+
+// admit waits for a slot to run an execution of chaincode, and returns the
+// function releasing it once the execution is done
+func (a *admissionControl) admit(ctxt context.Context, chaincode string) (func(), error) {
+	if a.maxExecutions <= 0 {
+		return func() {}, nil
+	}
+	a.Lock()
+	slots, ok := a.chaincodes[chaincode]
+	if !ok {
+		slots = &admissionSlots{running: make(chan struct{}, a.maxExecutions)}
+		a.chaincodes[chaincode] = slots
+	}
+	select {
+	case slots.running <- struct{}{}:
+		a.Unlock()
+		return a.release(slots), nil
+	default:
+	}
+	if slots.queued >= a.queueSize {
+		a.Unlock()
+		admissionRejectionsMetric.Inc()
+		return nil, ErrAdmissionQueueFull
+	}
+	slots.queued++
+	a.Unlock()
+
+	defer func() {
+		a.Lock()
+		slots.queued--
+		a.Unlock()
+	}()
+	var timeout <-chan time.Time
+	if a.queueTimeout > 0 {
+		timer := time.NewTimer(a.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case slots.running <- struct{}{}:
+		return a.release(slots), nil
+	case <-timeout:
+		admissionRejectionsMetric.Inc()
+		return nil, fmt.Errorf("Timeout expired waiting for one of the %d execution slots of %s", a.maxExecutions, chaincode)
+	case <-ctxt.Done():
+		return nil, fmt.Errorf("Execution cancelled waiting for an execution slot of %s: %s", chaincode, ctxt.Err())
+	}
+}
+
+func (a *admissionControl) release(slots *admissionSlots) func() {
+	return func() {
+		<-slots.running
+	}
+}
+
+// queuedExecutions returns the number of executions waiting for a slot, by
+// chaincode name
+func (a *admissionControl) queuedExecutions() map[string]int {
+	a.Lock()
+	defer a.Unlock()
+	queued := make(map[string]int)
+	for chaincode, slots := range a.chaincodes {
+		queued[chaincode] = slots.queued
+	}
+	return queued
+}
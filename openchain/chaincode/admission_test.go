@@ -0,0 +1,83 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestAdmissionControl(t *testing.T) {
+	a := &admissionControl{maxExecutions: 2, queueSize: 1, queueTimeout: 50 * time.Millisecond, chaincodes: make(map[string]*admissionSlots)}
+	ctxt := context.Background()
+
+	release1, err := a.admit(ctxt, "mycc")
+	if err != nil {
+		t.Fatalf("Error admitting first execution: %s", err)
+	}
+	if _, err = a.admit(ctxt, "mycc"); err != nil {
+		t.Fatalf("Error admitting second execution: %s", err)
+	}
+	// Other chaincodes have their own slots
+	if _, err = a.admit(ctxt, "othercc"); err != nil {
+		t.Fatalf("Error admitting execution of another chaincode: %s", err)
+	}
+
+	// The third execution times out in the queue
+	if _, err = a.admit(ctxt, "mycc"); err == nil {
+		t.Fatalf("Expected the execution over the limit to time out")
+	}
+
+	// A queued execution runs once a slot is released, the queue being full
+	// the next one is refused right away
+	admitted := make(chan error)
+	go func() {
+		_, err := a.admit(ctxt, "mycc")
+		admitted <- err
+	}()
+	for a.queuedExecutions()["mycc"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err = a.admit(ctxt, "mycc"); err != ErrAdmissionQueueFull {
+		t.Fatalf("Expected ErrAdmissionQueueFull, got %v", err)
+	}
+	release1()
+	if err = <-admitted; err != nil {
+		t.Fatalf("Error admitting queued execution: %s", err)
+	}
+
+	// Queued executions are cancelled with their context
+	cancelled, cancel := context.WithCancel(ctxt)
+	cancel()
+	a.queueTimeout = 0
+	if _, err = a.admit(cancelled, "mycc"); err == nil {
+		t.Fatalf("Expected the cancelled execution not to be admitted")
+	}
+}
+
+func TestAdmissionControlUnlimited(t *testing.T) {
+	a := &admissionControl{chaincodes: make(map[string]*admissionSlots)}
+	for i := 0; i < 100; i++ {
+		if _, err := a.admit(context.Background(), "mycc"); err != nil {
+			t.Fatalf("Error admitting execution %d without limit: %s", i, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/blobstore"
+)
+
+const blobThresholdDefault = 1024 * 1024
+
+// newBlobStore returns the store of the values larger than the blob
+// threshold, or nil if all values are kept in the state
+func newBlobStore() (blobstore.Store, int) {
+	store, err := blobstore.NewFromConfig()
+	if err != nil {
+		chaincodeLog.Error(fmt.Sprintf("Keeping all values in the state, failed to create the blob store: %s", err))
+		return nil, 0
+	}
+	threshold := viper.GetInt("chaincode.blobstore.threshold")
+	if threshold <= 0 {
+		threshold = blobThresholdDefault
+	}
+	return store, threshold
+}
+
+// storeBlob stores a value larger than the blob threshold in the blob store,
+// and returns the reference to put in the state in its place. Smaller values
+// are returned as they are. Content addressing makes the reference the same
+// on every validator, so the state hash does not depend on the store.
+func (chaincodeSupport *ChaincodeSupport) storeBlob(value []byte) ([]byte, error) {
+	if chaincodeSupport.blobStore == nil || (len(value) <= chaincodeSupport.blobThreshold && !blobstore.IsRef(value)) {
+		return value, nil
+	}
+	hash := blobstore.Hash(value)
+	if err := chaincodeSupport.blobStore.Put(hash, value); err != nil {
+		return nil, fmt.Errorf("Failed to store value in the blob store: %s", err)
+	}
+	return blobstore.Ref(hash), nil
+}
+
+// resolveBlob returns the value a reference read from the state stands for.
+// Other values are returned as they are.
+func (chaincodeSupport *ChaincodeSupport) resolveBlob(value []byte) ([]byte, error) {
+	if !blobstore.IsRef(value) {
+		return value, nil
+	}
+	hash, err := blobstore.ParseRef(value)
+	if err != nil {
+		return nil, err
+	}
+	if chaincodeSupport.blobStore == nil {
+		return nil, fmt.Errorf("Value is in blob %s but no blob store is configured", hash)
+	}
+	blob, err := chaincodeSupport.blobStore.Get(hash)
+	if err == nil {
+		err = blobstore.Verify(hash, blob)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get value from the blob store: %s", err)
+	}
+	return blob, nil
+}
@@ -0,0 +1,72 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/blobstore"
+)
+
+func TestBlobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chaincodeSupport := &ChaincodeSupport{blobStore: blobstore.NewLocalStore(dir), blobThreshold: 8}
+
+	// Small values stay in the state
+	small := []byte("small")
+	if stored, err := chaincodeSupport.storeBlob(small); err != nil || !bytes.Equal(stored, small) {
+		t.Fatalf("Expected small value to be kept, got %q (%v)", stored, err)
+	}
+
+	// Large values and values looking like references go to the blob store
+	for _, value := range [][]byte{[]byte("a value over the threshold"), blobstore.Ref("small")} {
+		ref, err := chaincodeSupport.storeBlob(value)
+		if err != nil {
+			t.Fatalf("Failed to store blob: %s", err)
+		}
+		if !blobstore.IsRef(ref) || len(ref) > 100 {
+			t.Fatalf("Expected a reference in the state, got %q", ref)
+		}
+		resolved, err := chaincodeSupport.resolveBlob(ref)
+		if err != nil || !bytes.Equal(resolved, value) {
+			t.Fatalf("Expected %q, got %q (%v)", value, resolved, err)
+		}
+	}
+
+	if resolved, err := chaincodeSupport.resolveBlob(small); err != nil || !bytes.Equal(resolved, small) {
+		t.Fatalf("Expected plain value to be returned as is, got %q (%v)", resolved, err)
+	}
+	if _, err := chaincodeSupport.resolveBlob(blobstore.Ref(blobstore.Hash([]byte("missing")))); err == nil {
+		t.Fatalf("Expected a missing blob to fail")
+	}
+
+	// References cannot be resolved without a blob store
+	ref, _ := chaincodeSupport.storeBlob([]byte("a value over the threshold"))
+	if _, err := (&ChaincodeSupport{}).resolveBlob(ref); err == nil {
+		t.Fatalf("Expected a reference to fail without blob store")
+	}
+}
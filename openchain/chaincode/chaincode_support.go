@@ -33,6 +33,7 @@ import (
 
 	google_protobuf "google/protobuf"
 
+	"github.com/openblockchain/obc-peer/openchain/blobstore"
 	"github.com/openblockchain/obc-peer/openchain/container"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
 	"github.com/openblockchain/obc-peer/openchain/ledger"
@@ -52,6 +53,10 @@ const (
 	chaincodeStartupTimeoutDefault int    = 5000
 	chaincodeInstallPathDefault    string = "/go/bin/"
 	peerAddressDefault             string = "0.0.0.0:30303"
+	// devAttachTimeoutDefault is used when chaincode.attachtimeout is not set
+	devAttachTimeoutDefault = 30 * time.Second
+	// simulateTimeoutDefault is used when chaincode.simulatetimeout is not set
+	simulateTimeoutDefault = 30 * time.Second
 )
 
 // chains is a map between different blockchains and their ChaincodeSupport.
@@ -92,7 +97,9 @@ func (chaincodeSupport *ChaincodeSupport) chaincodeHasBeenLaunched(chaincode str
 
 // NewChaincodeSupport creates a new ChaincodeSupport instance
 func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEndpoint, error), userrunsCC bool, ccstartuptimeout time.Duration, secHelper crypto.Peer) *ChaincodeSupport {
-	s := &ChaincodeSupport{name: chainname, handlerMap: &handlerMap{chaincodeMap: make(map[string]*Handler)}, secHelper: secHelper}
+	s := &ChaincodeSupport{name: chainname, handlerMap: &handlerMap{chaincodeMap: make(map[string]*Handler)}, secHelper: secHelper,
+		keyLocks: newKeyLockManager(), writeSets: newTxWriteSets(), keyHints: newTxKeyHints(), deferredCalls: newDeferredCalls(), chaincodeEvents: newChaincodeEvents(), meters: newTxMeters(),
+		txResults: newTxResults(), admission: newAdmissionControl()}
 
 	//initialize global chain
 	chains[chainname] = s
@@ -114,9 +121,32 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 
 	s.ccStartupTimeout = ccstartuptimeout * time.Millisecond
 
+	s.maxValueSize = viper.GetInt("chaincode.maxValueSize")
+	s.blobStore, s.blobThreshold = newBlobStore()
+
+	s.replayWindow = viper.GetDuration("chaincode.replay.window")
+
+	s.devAttachTimeout = viper.GetDuration("chaincode.attachtimeout")
+	if s.devAttachTimeout <= 0 {
+		s.devAttachTimeout = devAttachTimeoutDefault
+	}
+
+	s.simulateTimeout = time.Duration(viper.GetInt("chaincode.simulatetimeout")) * time.Millisecond
+	if s.simulateTimeout <= 0 {
+		s.simulateTimeout = simulateTimeoutDefault
+	}
+
 	//TODO I'm not sure if this needs to be on a per chain basis... too lowel and just needs to be a global default ?
 	s.chaincodeInstallPath = chaincodeInstallPathDefault
 
+	s.packagePolicy = newPackagePolicy()
+	s.importPolicy = newImportPolicy()
+	s.preLaunchHooks = newPreLaunchHooks()
+
+	if !s.userRunsCC {
+		startImageGC()
+	}
+
 	return s
 }
 
@@ -132,9 +162,31 @@ type ChaincodeSupport struct {
 	handlerMap           *handlerMap
 	peerAddress          string
 	ccStartupTimeout     time.Duration
+	devAttachTimeout     time.Duration
+	simulateTimeout      time.Duration
 	chaincodeInstallPath string
 	userRunsCC           bool
 	secHelper            crypto.Peer
+	packagePolicy        *packagePolicy
+	importPolicy         *importPolicy
+	preLaunchHooks       []PreLaunchHook
+	keyLocks             *keyLockManager
+	writeSets            *txWriteSets
+	keyHints             *txKeyHints
+	deferredCalls        *deferredCalls
+	chaincodeEvents      *chaincodeEvents
+	meters               *txMeters
+	txResults            *txResults
+	admission            *admissionControl
+	// maxValueSize bounds the size of the state values, 0 for no limit
+	maxValueSize int
+	// replayWindow bounds how much older than the most recent invocation a
+	// signed invocation may be, 0 for no replay protection
+	replayWindow time.Duration
+	// blobStore keeps the values larger than blobThreshold out of the
+	// state, nil if all values are kept in the state
+	blobStore     blobstore.Store
+	blobThreshold int
 }
 
 // DuplicateChaincodeHandlerError returned if attempt to register same chaincodeID while a stream already exists.
@@ -158,9 +210,16 @@ func (chaincodeSupport *ChaincodeSupport) registerHandler(chaincodehandler *Hand
 
 	h2, ok := chaincodeSupport.chaincodeHasBeenLaunched(key)
 	if ok && h2.registered == true {
-		chaincodeLogger.Debug("duplicate registered handler(key:%s) return error", key)
-		// Duplicate, return error
-		return newDuplicateChaincodeHandlerError(chaincodehandler)
+		if !chaincodeSupport.userRunsCC || IsSysCC(key) {
+			chaincodeLogger.Debug("duplicate registered handler(key:%s) return error", key)
+			// Duplicate, return error
+			return newDuplicateChaincodeHandlerError(chaincodehandler)
+		}
+		// In dev mode the user restarts the chaincode process after every
+		// change, possibly before its previous stream was found closed. The
+		// new process takes over the name, and with it the deployment.
+		chaincodeLog.Info("Chaincode %s re-attached, replacing its previous stream", key)
+		h2 = nil
 	}
 	//a placeholder, unregistered handler will be setup by query or transaction processing that comes
 	//through via consensus. In this case we swap the handler and give it the notify channel
@@ -177,6 +236,7 @@ func (chaincodeSupport *ChaincodeSupport) registerHandler(chaincodehandler *Hand
 	chaincodehandler.txCtxs = make(map[string]*transactionContext)
 	chaincodehandler.uuidMap = make(map[string]bool)
 	chaincodehandler.isTransaction = make(map[string]bool)
+	chaincodehandler.partialValues = make(map[string]*partialValue)
 
 	chaincodeLogger.Debug("registered handler complete for chaincode %s", key)
 
@@ -196,9 +256,13 @@ func (chaincodeSupport *ChaincodeSupport) deregisterHandler(chaincodehandler *Ha
 	chaincodeLogger.Debug("Deregister handler: %s", key)
 	chaincodeSupport.handlerMap.Lock()
 	defer chaincodeSupport.handlerMap.Unlock()
-	if _, ok := chaincodeSupport.chaincodeHasBeenLaunched(key); !ok {
+	if h, ok := chaincodeSupport.chaincodeHasBeenLaunched(key); !ok {
 		// Handler NOT found
 		return fmt.Errorf("Error deregistering handler, could not find handler with key: %s", key)
+	} else if h != chaincodehandler {
+		// The chaincode re-attached in dev mode, the new handler stays
+		chaincodeLogger.Debug("Handler with key %s was replaced, not deregistering", key)
+		return nil
 	}
 	delete(chaincodeSupport.handlerMap.chaincodeMap, key)
 	chaincodeLogger.Debug("Deregistered handler with key: %s", key)
@@ -231,7 +295,7 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 
 	var notfy chan *pb.ChaincodeMessage
 	var err error
-	if notfy, err = handler.initOrReady(uuid, f, initArgs, tx, depTx); err != nil {
+	if notfy, err = handler.initOrReady(context, uuid, f, initArgs, tx, depTx); err != nil {
 		return fmt.Errorf("Error sending %s: %s", pb.ChaincodeMessage_INIT, err)
 	}
 	if notfy != nil {
@@ -242,6 +306,10 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 			}
 		case <-time.After(timeout):
 			err = fmt.Errorf("Timeout expired while executing send init message")
+			handler.abortTxContext(uuid, err)
+		case <-context.Done():
+			err = fmt.Errorf("Init cancelled: %s", context.Err())
+			handler.abortTxContext(uuid, err)
 		}
 	}
 
@@ -255,14 +323,37 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID) (args []string, envs []string, err error) {
 	envs = []string{"OPENCHAIN_CHAINCODE_ID_NAME=" + cID.Name}
 
-	//chaincode executable will be same as the name of the chaincode
-	args = []string{chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	//the chaincode needs the crypto suite to decrypt the attributes of the invoker
+	if level := viper.GetInt("security.level"); level != 0 {
+		envs = append(envs, fmt.Sprintf("OPENCHAIN_SECURITY_LEVEL=%d", level))
+	}
+	if suite := viper.GetString("security.suite"); suite != "" {
+		envs = append(envs, "OPENCHAIN_SECURITY_SUITE="+suite)
+	}
+
+	//chaincode executable has the same name in every chaincode image
+	args = []string{chaincodeSupport.chaincodeInstallPath + container.ChaincodeExecutable, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
 
 	chaincodeLog.Debug("Executable is %s", args[0])
 
 	return args, envs, nil
 }
 
+//get the type of VM the chaincode runs in and its id in that VM
+func getVMTypeAndName(chaincode string) (string, string) {
+	if IsSysCC(chaincode) {
+		return container.SYSTEM, chaincode
+	}
+	return container.ChaincodeVMType(), container.GetVMFromName(chaincode)
+}
+
+// HandleChaincodeStream serves the peer side of the stream to an in-process
+// system chaincode
+func (chaincodeSupport *ChaincodeSupport) HandleChaincodeStream(stream container.ChaincodeStream) error {
+	handler := newChaincodeSupportHandler(chaincodeSupport, stream)
+	return handler.processStream()
+}
+
 // launchAndWaitForRegister will launch container if not already running
 func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(context context.Context, cID *pb.ChaincodeID, uuid string) (bool, error) {
 	chaincode := cID.Name
@@ -290,12 +381,12 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(context conte
 	}
 
 	//creat a StartImageReq obj and send it to VMCProcess
-	vmname := container.GetVMFromName(chaincode)
+	vmtype, vmname := getVMTypeAndName(chaincode)
 
 	chaincodeLog.Debug("start container: %s", vmname)
 
 	sir := container.StartImageReq{ID: vmname, Args: args, Env: env}
-	resp, err := container.VMCProcess(context, "Docker", sir)
+	resp, err := container.VMCProcess(container.WithChaincodeStreamHandler(context, chaincodeSupport), vmtype, sir)
 	if err != nil || (resp != nil && resp.(container.VMCResp).Err != nil) {
 		if err == nil {
 			err = resp.(container.VMCResp).Err
@@ -322,22 +413,70 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(context conte
 		if errIgnore != nil {
 			chaincodeLog.Debug("error on stop %s(%s)", errIgnore, err)
 		}
+		return alreadyRunning, err
+	}
+
+	//let the VM know the chaincode is serving, eg. to mark its pod ready
+	resp, err = container.VMCProcess(context, vmtype, container.ReadyReq{ID: vmname})
+	if err == nil {
+		err = resp.(container.VMCResp).Err
+	}
+	if err != nil {
+		chaincodeLog.Warning("Chaincode %s registered but its container could not be marked ready: %s", vmname, err)
+	}
+	return alreadyRunning, nil
+}
+
+// waitForAttach waits in dev mode for the user to start the chaincode process,
+// or to restart it after a change, and for the process to register
+func (chaincodeSupport *ChaincodeSupport) waitForAttach(chaincode string, uuid string) error {
+	chaincodeSupport.handlerMap.Lock()
+	if _, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok {
+		chaincodeSupport.handlerMap.Unlock()
+		return fmt.Errorf("premature execution - chaincode (%s) is being attached", chaincode)
+	}
+	notfy := chaincodeSupport.preLaunchSetup(chaincode)
+	chaincodeSupport.handlerMap.Unlock()
+
+	chaincodeLog.Info("Waiting up to %s for chaincode %s to be started and registered", chaincodeSupport.devAttachTimeout, chaincode)
+	var err error
+	select {
+	case ok := <-notfy:
+		if !ok {
+			err = fmt.Errorf("registration failed for %s(tx:%s)", chaincode, uuid)
+		}
+	case <-time.After(chaincodeSupport.devAttachTimeout):
+		err = fmt.Errorf("Timeout expired while waiting for chaincode %s to be started and registered(tx:%s)", chaincode, uuid)
+	}
+	if err != nil {
+		// Remove the placeholder unless the chaincode registered meanwhile
+		chaincodeSupport.handlerMap.Lock()
+		if handler, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok && !handler.registered {
+			delete(chaincodeSupport.handlerMap.chaincodeMap, chaincode)
+		}
+		chaincodeSupport.handlerMap.Unlock()
 	}
-	return alreadyRunning, err
+	return err
 }
 
 func (chaincodeSupport *ChaincodeSupport) stopChaincode(context context.Context, cID *pb.ChaincodeID) error {
+	return chaincodeSupport.stopChaincodeWithin(context, cID, 0)
+}
+
+// stopChaincodeWithin stops the container of a chaincode, giving it timeout
+// seconds to exit before it is killed
+func (chaincodeSupport *ChaincodeSupport) stopChaincodeWithin(context context.Context, cID *pb.ChaincodeID, timeout uint) error {
 	chaincode := cID.Name
 	if chaincode == "" {
 		return fmt.Errorf("chaincode name not set")
 	}
 
-	vmname := container.GetVMFromName(chaincode)
+	vmtype, vmname := getVMTypeAndName(chaincode)
 
 	//stop the chaincode
-	sir := container.StopImageReq{ID: vmname, Timeout: 0}
+	sir := container.StopImageReq{ID: vmname, Timeout: timeout}
 
-	_, err := container.VMCProcess(context, "Docker", sir)
+	_, err := container.VMCProcess(context, vmtype, sir)
 	if err != nil {
 		err = fmt.Errorf("Error stopping container: %s", err)
 		//but proceed to cleanup
@@ -375,7 +514,7 @@ func (chaincodeSupport *ChaincodeSupport) LaunchChaincode(context context.Contex
 		cMsg = cds.ChaincodeSpec.CtorMsg
 		f = &cMsg.Function
 		initargs = cMsg.Args
-	} else if t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_QUERY {
+	} else if t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_QUERY || t.Type == pb.Transaction_CHAINCODE_CONFIG {
 		ci := &pb.ChaincodeInvocationSpec{}
 		err := proto.Unmarshal(t.Payload, ci)
 		if err != nil {
@@ -388,6 +527,13 @@ func (chaincodeSupport *ChaincodeSupport) LaunchChaincode(context context.Contex
 		return nil, nil, fmt.Errorf("invalid transaction type: %d", t.Type)
 	}
 	chaincode := cID.Name
+	//chaincodes deployed on a chain other than the default chain can only
+	//be invoked on that chain, the chaincode name includes the chain
+	if t.Type != pb.Transaction_CHAINCODE_NEW && t.ChainID != "" && !IsSysCC(chaincode) {
+		if err := checkDeployedOnChain(t.ChainID, chaincode); err != nil {
+			return cID, cMsg, err
+		}
+	}
 	chaincodeSupport.handlerMap.Lock()
 	var handler *Handler
 	var ok bool
@@ -423,8 +569,13 @@ func (chaincodeSupport *ChaincodeSupport) LaunchChaincode(context context.Contex
 	//         5) query successfully retrives committed tx and calls sendInitOrReady
 	// See issue #710
 
-	if t.Type != pb.Transaction_CHAINCODE_NEW {
-		ledger, ledgerErr := ledger.GetLedger()
+	if t.Type != pb.Transaction_CHAINCODE_NEW && IsSysCC(chaincode) {
+		//system chaincodes are not deployed through the ledger
+		if depTx, err = getSysCCDeployTx(chaincode); err != nil {
+			return cID, cMsg, err
+		}
+	} else if t.Type != pb.Transaction_CHAINCODE_NEW {
+		ledger, ledgerErr := ledger.GetChainLedger(t.ChainID)
 		if ledgerErr != nil {
 			return cID, cMsg, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 		}
@@ -448,15 +599,23 @@ func (chaincodeSupport *ChaincodeSupport) LaunchChaincode(context context.Contex
 	}
 
 	//from here on : if we launch the container and get an error, we need to stop the container
-	if !chaincodeSupport.userRunsCC && handler == nil {
+	//system chaincodes run in-process even when the user runs chaincodes
+	if (!chaincodeSupport.userRunsCC || IsSysCC(chaincode)) && handler == nil {
 		_, err = chaincodeSupport.launchAndWaitForRegister(context, cID, t.Uuid)
 		if err != nil {
 			chaincodeLog.Debug("launchAndWaitForRegister failed %s", err)
 			return cID, cMsg, err
 		}
+	} else if handler == nil {
+		if err = chaincodeSupport.waitForAttach(chaincode, t.Uuid); err != nil {
+			return cID, cMsg, err
+		}
 	}
 
 	if err == nil {
+		if t.Type == pb.Transaction_CHAINCODE_NEW {
+			SetDeploymentStatus(chaincode, pb.DeploymentStatus_INIT_RUNNING, "")
+		}
 		//send init (if (f,args)) and wait for ready state
 		err = chaincodeSupport.sendInitOrReady(context, t.Uuid, chaincode, f, initargs, chaincodeSupport.ccStartupTimeout, t, depTx)
 		if err != nil {
@@ -475,6 +634,23 @@ func (chaincodeSupport *ChaincodeSupport) LaunchChaincode(context context.Contex
 	return cID, cMsg, err
 }
 
+// checkDeployedOnChain returns an error if the ledger of the chain does not
+// hold the deployment transaction of the chaincode
+func checkDeployedOnChain(chainID string, chaincode string) error {
+	chainLedger, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		return fmt.Errorf("Failed to get handle to ledger of chain %s (%s)", chainID, err)
+	}
+	depTx, err := chainLedger.GetTransactionByUUID(chaincode)
+	if err != nil && err != ledger.ErrResourceNotFound {
+		return fmt.Errorf("Could not get deployment transaction for %s - %s", chaincode, err)
+	}
+	if depTx == nil {
+		return fmt.Errorf("chaincode %s is not deployed on chain %s", chaincode, chainID)
+	}
+	return nil
+}
+
 // getSecHelper returns the security help set from NewChaincodeSupport
 func (chaincodeSupport *ChaincodeSupport) getSecHelper() crypto.Peer {
 	return chaincodeSupport.secHelper
@@ -482,11 +658,6 @@ func (chaincodeSupport *ChaincodeSupport) getSecHelper() crypto.Peer {
 
 // DeployChaincode deploys the chaincode if not in development mode where user is running the chaincode.
 func (chaincodeSupport *ChaincodeSupport) DeployChaincode(context context.Context, t *pb.Transaction) (*pb.ChaincodeDeploymentSpec, error) {
-	if chaincodeSupport.userRunsCC {
-		chaincodeLog.Debug("user runs chaincode, not deploying chaincode")
-		return nil, nil
-	}
-
 	//build the chaincode
 	cds := &pb.ChaincodeDeploymentSpec{}
 	err := proto.Unmarshal(t.Payload, cds)
@@ -498,6 +669,24 @@ func (chaincodeSupport *ChaincodeSupport) DeployChaincode(context context.Contex
 	if err != nil {
 		return cds, err
 	}
+
+	//system chaincodes can only be deployed by the peer itself
+	isSysCC := IsSysCC(chaincode)
+	if isSysCC || cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+		if depTx, _ := getSysCCDeployTx(chaincode); depTx != t {
+			return cds, fmt.Errorf("system chaincode %s can only be deployed by the peer", chaincode)
+		}
+	}
+
+	if policy := cds.ChaincodeSpec.SignaturePolicy; policy != nil && policy.Threshold > 0 && chaincodeSupport.getSecHelper() == nil {
+		return cds, fmt.Errorf("Deployment of %s rejected: %s", chaincode, errSignaturePolicyInsecure)
+	}
+
+	if chaincodeSupport.userRunsCC && !isSysCC {
+		chaincodeLog.Debug("user runs chaincode, not deploying chaincode")
+		return nil, nil
+	}
+
 	chaincodeSupport.handlerMap.Lock()
 	//if its in the map, there must be a connected stream...and we are trying to build the code ?!
 	if _, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok {
@@ -507,20 +696,36 @@ func (chaincodeSupport *ChaincodeSupport) DeployChaincode(context context.Contex
 	}
 	chaincodeSupport.handlerMap.Unlock()
 
+	//system chaincodes are built into the peer, they have no package
+	image := ""
+	if !isSysCC {
+		if err = chaincodeSupport.getPackagePolicy().verify(cds); err != nil {
+			return cds, fmt.Errorf("Deployment package of %s rejected: %s", chaincode, err)
+		}
+		if err = chaincodeSupport.importPolicy.verify(cds); err != nil {
+			return cds, fmt.Errorf("Deployment package of %s rejected: %s", chaincode, err)
+		}
+		image = container.GetImageFromPackage(cds.CodePackage)
+	}
+
 	args, envs, err := chaincodeSupport.getArgsAndEnv(cID)
 	if err != nil {
 		return cds, fmt.Errorf("error getting args for chaincode %s", err)
 	}
 
-	vmname := container.GetVMFromName(chaincode)
+	vmtype, vmname := getVMTypeAndName(chaincode)
 	var targz io.Reader = bytes.NewBuffer(cds.CodePackage)
-	cir := &container.CreateImageReq{ID: vmname, Args: args, Reader: targz, Env: envs}
+	cir := &container.CreateImageReq{ID: vmname, Image: image, Args: args, Reader: targz, Env: envs}
 
 	chaincodeLog.Debug("deploying chaincode %s", vmname)
 	//create image and create container
-	_, err = container.VMCProcess(context, "Docker", cir)
+	_, err = container.VMCProcess(context, vmtype, cir)
 	if err != nil {
-		err = fmt.Errorf("Error starting container: %s", err)
+		return cds, fmt.Errorf("Error starting container: %s", err)
+	}
+
+	if !isSysCC {
+		err = chaincodeSupport.runPreLaunchHooks(context, chaincode, vmname)
 	}
 
 	return cds, err
@@ -562,9 +767,20 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 	}
 	chaincodeSupport.handlerMap.Unlock()
 
+	// Transactions and queries wait for an execution slot of the chaincode.
+	// The chaincodes they invoke, without a transaction, already run within
+	// the slot of the invoking chaincode.
+	if tx != nil {
+		release, err := chaincodeSupport.admission.admit(ctxt, chaincode)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
 	var notfy chan *pb.ChaincodeMessage
 	var err error
-	if notfy, err = handler.sendExecuteMessage(msg, tx); err != nil {
+	if notfy, err = handler.sendExecuteMessage(ctxt, msg, tx); err != nil {
 		return nil, fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
 	}
 	var ccresp *pb.ChaincodeMessage
@@ -575,6 +791,10 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 		}
 	case <-time.After(timeout):
 		err = fmt.Errorf("Timeout expired while executing transaction")
+		handler.abortTxContext(msg.Uuid, err)
+	case <-ctxt.Done():
+		err = fmt.Errorf("Execution cancelled: %s", ctxt.Err())
+		handler.abortTxContext(msg.Uuid, err)
 	}
 
 	//our responsibility to delete transaction context if sendExecuteMessage succeeded
@@ -0,0 +1,412 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	pathpkg "path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// rules of the analysis of the code of chaincodes at deploy time, each
+// looking for a construct that makes the chaincode non-deterministic
+const (
+	// calls reading the clock of the peer
+	ruleTimeNow = "timenow"
+	// ranges over maps, which are iterated in random order, in functions
+	// that sort nothing
+	ruleMapRange = "maprange"
+	// goroutines writing the state, in whatever order they are scheduled
+	ruleGoroutineState = "goroutinestate"
+	// imports of packages calling the network
+	ruleNetwork = "network"
+)
+
+// actions taken on the findings of a rule of the analysis
+const (
+	analysisWarn   = "warn"
+	analysisBlock  = "block"
+	analysisIgnore = "ignore"
+)
+
+// clockFuncs are the functions of the time package reading the clock
+var clockFuncs = map[string]bool{"Now": true, "Since": true, "Until": true}
+
+// stateWriters are the methods of the chaincode stub writing the state
+var stateWriters = map[string]bool{"PutState": true, "DelState": true}
+
+// offlineNetPackages are the packages under net that do not call the network
+var offlineNetPackages = map[string]bool{"net/url": true, "net/mail": true, "net/textproto": true}
+
+// analysisPolicy decides what is done with the findings of each rule
+type analysisPolicy struct {
+	enabled bool
+	// action taken on the findings of rules not listed in rules
+	action string
+	rules  map[string]string
+}
+
+// newAnalysisPolicy reads the policy from chaincode.analysis
+func newAnalysisPolicy() (*analysisPolicy, error) {
+	policy := &analysisPolicy{
+		enabled: viper.GetBool("chaincode.analysis.enabled"),
+		action:  strings.ToLower(viper.GetString("chaincode.analysis.action")),
+		rules:   make(map[string]string),
+	}
+	if policy.action == "" {
+		policy.action = analysisWarn
+	}
+	if policy.action != analysisWarn && policy.action != analysisBlock {
+		return nil, fmt.Errorf("Invalid chaincode.analysis.action %s, expecting %s or %s", policy.action, analysisWarn, analysisBlock)
+	}
+	for rule, action := range viper.GetStringMapString("chaincode.analysis.rules") {
+		action = strings.ToLower(action)
+		switch action {
+		case "":
+			continue
+		case analysisWarn, analysisBlock, analysisIgnore:
+			policy.rules[strings.ToLower(rule)] = action
+		default:
+			return nil, fmt.Errorf("Invalid action %s for chaincode analysis rule %s", action, rule)
+		}
+	}
+	return policy, nil
+}
+
+func (policy *analysisPolicy) actionFor(rule string) string {
+	if action, ok := policy.rules[rule]; ok {
+		return action
+	}
+	return policy.action
+}
+
+// AnalyzeCodePackage scans the Go code of the chaincode packaged in cds for
+// constructs making it non-deterministic, as configured under
+// chaincode.analysis. It returns the findings of the rules not ignored, and
+// an error listing those blocking the deployment if there are any.
+func AnalyzeCodePackage(cds *pb.ChaincodeDeploymentSpec) ([]*pb.CodeFinding, error) {
+	policy, err := newAnalysisPolicy()
+	if err != nil {
+		return nil, err
+	}
+	spec := cds.ChaincodeSpec
+	if !policy.enabled || len(cds.CodePackage) == 0 || spec == nil || spec.Type != pb.ChaincodeSpec_GOLANG || spec.ChaincodeID == nil {
+		return nil, nil
+	}
+	sources, err := readPackageSources(cds.CodePackage, chaincodeSourceDir(spec.ChaincodeID.Path))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the code of the chaincode: %s", err)
+	}
+	found, err := analyzeSources(sources)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*pb.CodeFinding
+	var blocking []string
+	for _, finding := range found {
+		switch policy.actionFor(finding.Rule) {
+		case analysisIgnore:
+			continue
+		case analysisBlock:
+			finding.Blocking = true
+			blocking = append(blocking, fmt.Sprintf("%s:%d %s", finding.File, finding.Line, finding.Message))
+		}
+		findings = append(findings, finding)
+	}
+	if len(blocking) == 0 {
+		return findings, nil
+	}
+	const maxListed = 5
+	listed := blocking
+	if len(listed) > maxListed {
+		listed = append(listed[:maxListed:maxListed], "...")
+	}
+	return findings, fmt.Errorf("%d blocking findings in the code of the chaincode: %s", len(blocking), strings.Join(listed, "; "))
+}
+
+// chaincodeSourceDir returns the directory of the code package holding the
+// code of the chaincode at path
+func chaincodeSourceDir(path string) string {
+	if strings.HasPrefix(path, "http://") {
+		path = path[7:]
+	} else if strings.HasPrefix(path, "https://") {
+		path = path[8:]
+	}
+	return "src/" + strings.TrimSuffix(path, "/")
+}
+
+// readPackageSources returns the Go files under dir in the gzipped tar code
+// package, by path relative to dir. Tests are left out.
+func readPackageSources(codePackage []byte, dir string) (map[string][]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(codePackage))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	sources := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header.Name, dir+"/") || !strings.HasSuffix(header.Name, ".go") || strings.HasSuffix(header.Name, "_test.go") {
+			continue
+		}
+		name := header.Name[len(dir)+1:]
+		if sources[name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// vendoredPackage returns the import path of the vendored package the file
+// with the path name relative to the chaincode belongs to, or "" if the file
+// is part of the chaincode itself
+func vendoredPackage(name string) string {
+	i := strings.LastIndex("/"+name, "/vendor/")
+	if i < 0 {
+		return ""
+	}
+	return pathpkg.Dir(name[i+len("vendor/"):])
+}
+
+// analyzeSources runs the rules on the Go files of sources, package by
+// package, and returns the findings ordered by file and line. Vendored
+// packages are left out.
+func analyzeSources(sources map[string][]byte) ([]*pb.CodeFinding, error) {
+	packages := make(map[string][]string)
+	for name := range sources {
+		if vendoredPackage(name) != "" {
+			continue
+		}
+		dir := pathpkg.Dir(name)
+		packages[dir] = append(packages[dir], name)
+	}
+	var findings []*pb.CodeFinding
+	for dir, names := range packages {
+		sort.Strings(names)
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for _, name := range names {
+			file, err := parser.ParseFile(fset, name, sources[name], 0)
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing the code of the chaincode: %s", err)
+			}
+			files = append(files, file)
+		}
+		findings = append(findings, newPackageAnalysis(dir, fset, files).run()...)
+	}
+	sort.Sort(codeFindings(findings))
+	return findings, nil
+}
+
+// packageAnalysis runs the rules on the files of a package
+type packageAnalysis struct {
+	fset  *token.FileSet
+	files []*ast.File
+	// types of the expressions, as far as they are known without loading
+	// the imported packages
+	info *types.Info
+	// functions and methods declared in the package, by name
+	funcs    map[string][]*ast.FuncDecl
+	findings []*pb.CodeFinding
+}
+
+func newPackageAnalysis(dir string, fset *token.FileSet, files []*ast.File) *packageAnalysis {
+	a := &packageAnalysis{fset: fset, files: files, info: &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}, funcs: make(map[string][]*ast.FuncDecl)}
+	// The imported packages are not available, types of expressions
+	// involving them stay unknown and the errors this causes are ignored.
+	// The declarations of the package are enough to tell which of its
+	// variables are maps.
+	conf := types.Config{Importer: emptyImporter{}, Error: func(error) {}}
+	conf.Check(dir, fset, files, a.info)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				a.funcs[fn.Name.Name] = append(a.funcs[fn.Name.Name], fn)
+			}
+		}
+	}
+	return a
+}
+
+func (a *packageAnalysis) run() []*pb.CodeFinding {
+	for _, file := range a.files {
+		imports := a.checkImports(file)
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil {
+				a.checkFunc(fn, imports)
+			}
+		}
+	}
+	return a.findings
+}
+
+func (a *packageAnalysis) report(node ast.Node, rule string, format string, args ...interface{}) {
+	position := a.fset.Position(node.Pos())
+	a.findings = append(a.findings, &pb.CodeFinding{Rule: rule, File: position.Filename, Line: int32(position.Line), Message: fmt.Sprintf(format, args...)})
+}
+
+// checkImports reports the imports of network packages and returns the
+// paths of the imports of file by the name they are referred to with
+func (a *packageAnalysis) checkImports(file *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := pathpkg.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+		if (path == "net" || strings.HasPrefix(path, "net/")) && !offlineNetPackages[path] {
+			a.report(spec, ruleNetwork, "imports %s, network calls do not return the same on every validator", path)
+		}
+	}
+	return imports
+}
+
+// checkFunc runs the rules on the body of fn
+func (a *packageAnalysis) checkFunc(fn *ast.FuncDecl, imports map[string]string) {
+	sorts := false
+	var ranges []*ast.RangeStmt
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			if pkg, name := packageFunc(node.Fun, imports); pkg == "sort" {
+				sorts = true
+			} else if pkg == "time" && clockFuncs[name] {
+				a.report(node, ruleTimeNow, "time.%s reads the clock of the peer, use the timestamp of the transaction instead", name)
+			}
+		case *ast.RangeStmt:
+			if t := a.info.TypeOf(node.X); t != nil {
+				if _, ok := t.Underlying().(*types.Map); ok {
+					ranges = append(ranges, node)
+				}
+			}
+		case *ast.GoStmt:
+			if a.writesState(node.Call.Fun) {
+				a.report(node, ruleGoroutineState, "goroutine writes the state, its writes are not ordered with those of the transaction")
+			}
+		}
+		return true
+	})
+	if sorts {
+		return
+	}
+	for _, node := range ranges {
+		a.report(node, ruleMapRange, "range over a map in %s, which sorts nothing; maps are iterated in random order", fn.Name.Name)
+	}
+}
+
+// writesState returns whether the function fun, started as a goroutine,
+// writes the state itself. Functions declared in other packages are not
+// looked into.
+func (a *packageAnalysis) writesState(fun ast.Expr) bool {
+	var bodies []*ast.BlockStmt
+	switch fun := fun.(type) {
+	case *ast.FuncLit:
+		bodies = append(bodies, fun.Body)
+	case *ast.Ident:
+		for _, fn := range a.funcs[fun.Name] {
+			if fn.Recv == nil {
+				bodies = append(bodies, fn.Body)
+			}
+		}
+	case *ast.SelectorExpr:
+		for _, fn := range a.funcs[fun.Sel.Name] {
+			if fn.Recv != nil {
+				bodies = append(bodies, fn.Body)
+			}
+		}
+	}
+	writes := false
+	for _, body := range bodies {
+		ast.Inspect(body, func(node ast.Node) bool {
+			if call, ok := node.(*ast.CallExpr); ok {
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok && stateWriters[sel.Sel.Name] {
+					writes = true
+				}
+			}
+			return !writes
+		})
+	}
+	return writes
+}
+
+// packageFunc returns the import path and the name of the function called
+// through fun if it is a function of an imported package
+func packageFunc(fun ast.Expr, imports map[string]string) (string, string) {
+	sel, ok := fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", ""
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok || ident.Obj != nil {
+		// a local variable shadowing the package
+		return "", ""
+	}
+	return imports[ident.Name], sel.Sel.Name
+}
+
+// emptyImporter stands in for the packages imported by the chaincode, which
+// are not loaded to analyze it
+type emptyImporter struct{}
+
+func (emptyImporter) Import(path string) (*types.Package, error) {
+	pkg := types.NewPackage(path, pathpkg.Base(path))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// codeFindings sorts findings by file and line
+type codeFindings []*pb.CodeFinding
+
+func (f codeFindings) Len() int      { return len(f) }
+func (f codeFindings) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f codeFindings) Less(i, j int) bool {
+	if f[i].File != f[j].File {
+		return f[i].File < f[j].File
+	}
+	return f[i].Line < f[j].Line
+}
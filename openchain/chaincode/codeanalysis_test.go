@@ -0,0 +1,141 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+const analyzedChaincode = `package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+type cc struct{}
+
+func (t *cc) Run(stub *Stub, function string, args []string) ([]byte, error) {
+	counts := make(map[string]int)
+	for k := range counts {
+		stub.PutState(k, nil)
+	}
+	go func() {
+		stub.PutState("a", nil)
+	}()
+	go t.log()
+	return []byte(time.Now().String()), nil
+}
+
+func (t *cc) Query(stub *Stub, function string, args []string) ([]byte, error) {
+	counts := map[string]int{}
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		url.QueryEscape(k)
+	}
+	http.Get("http://example.com")
+	return nil, nil
+}
+
+func (t *cc) log() {}
+`
+
+// newAnalyzedPackage returns a code package holding files, by name
+func newAnalyzedPackage(t *testing.T, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestAnalyzeCodePackage(t *testing.T) {
+	defer viper.Set("chaincode.analysis.enabled", false)
+	defer viper.Set("chaincode.analysis.rules", nil)
+	cds := &pb.ChaincodeDeploymentSpec{
+		ChaincodeSpec: &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "github.com/mycc"}},
+		CodePackage: newAnalyzedPackage(t, map[string]string{
+			"Dockerfile":                    "FROM scratch",
+			"src/github.com/mycc/mycc.go":   analyzedChaincode,
+			"src/github.com/mycc/x_test.go": "package main\nimport \"time\"\nfunc f() { time.Now() }\n",
+			"src/github.com/other/other.go": "package other\nimport \"time\"\nfunc f() { time.Now() }\n",
+		}),
+	}
+
+	viper.Set("chaincode.analysis.enabled", false)
+	if findings, err := AnalyzeCodePackage(cds); err != nil || findings != nil {
+		t.Fatalf("Expected no analysis when disabled, got %v, %v", findings, err)
+	}
+
+	viper.Set("chaincode.analysis.enabled", true)
+	viper.Set("chaincode.analysis.action", "warn")
+	findings, err := AnalyzeCodePackage(cds)
+	if err != nil {
+		t.Fatalf("Error analyzing code package: %s", err)
+	}
+	expected := []struct {
+		rule string
+		line int32
+	}{{ruleNetwork, 4}, {ruleMapRange, 14}, {ruleGoroutineState, 17}, {ruleTimeNow, 21}}
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %v", len(expected), findings)
+	}
+	for i, e := range expected {
+		if f := findings[i]; f.Rule != e.rule || f.Line != e.line || f.File != "mycc.go" || f.Blocking {
+			t.Fatalf("Expected warning of rule %s at mycc.go:%d, got %v", e.rule, e.line, f)
+		}
+	}
+
+	viper.Set("chaincode.analysis.rules", map[string]string{"timenow": "block", "maprange": "ignore"})
+	findings, err = AnalyzeCodePackage(cds)
+	if err == nil {
+		t.Fatalf("Expected the deployment to be blocked by the call to time.Now")
+	}
+	if len(findings) != 3 || findings[2].Rule != ruleTimeNow || !findings[2].Blocking || findings[0].Blocking {
+		t.Fatalf("Expected the time.Now finding alone to block, and map ranges ignored, got %v", findings)
+	}
+
+	viper.Set("chaincode.analysis.rules", map[string]string{"timenow": "allow"})
+	if _, err = AnalyzeCodePackage(cds); err == nil {
+		t.Fatalf("Expected error for an invalid action")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+)
+
+// Chaincode is the conformance chaincode run by the shim under test. Shims in
+// other languages implement the same functions: "init" and "put" put pairs
+// of keys and values, "del" deletes keys, "get" returns the value of a key
+// and "fail" fails with its arguments as the message. Queries can also call
+// "range" with a start and end key, which returns the keys and values in
+// range as key=value pairs separated by commas.
+type Chaincode struct {
+}
+
+// Run implements the functions of the conformance chaincode
+func (cc *Chaincode) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return cc.call(stub, function, args)
+}
+
+// Query implements the functions of the conformance chaincode
+func (cc *Chaincode) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if function != "range" {
+		return cc.call(stub, function, args)
+	}
+	if len(args) != 2 {
+		return nil, errors.New("Expected the start and end keys")
+	}
+	iter, err := stub.RangeQueryState(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+	var pairs []string
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, key+"="+string(value))
+	}
+	return []byte(strings.Join(pairs, ",")), nil
+}
+
+func (cc *Chaincode) call(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "init", "put":
+		if len(args)%2 != 0 {
+			return nil, errors.New("Expected pairs of keys and values")
+		}
+		for i := 0; i < len(args); i += 2 {
+			if err := stub.PutState(args[i], []byte(args[i+1])); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case "del":
+		for _, key := range args {
+			if err := stub.DelState(key); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case "get":
+		if len(args) != 1 {
+			return nil, errors.New("Expected the key")
+		}
+		return stub.GetState(args[0])
+	case "fail":
+		return nil, errors.New(strings.Join(args, " "))
+	}
+	return nil, fmt.Errorf("Unknown function %s", function)
+}
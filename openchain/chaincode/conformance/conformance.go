@@ -0,0 +1,187 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package conformance checks that a shim speaks the chaincode stream protocol
+// the way the peer expects. The suite plays the peer over a stream to a shim
+// running the conformance chaincode, whose Go implementation is Chaincode.
+// Shims written in other languages implement the same functions and connect
+// to a Server.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// Connector starts a shim running the conformance chaincode and returns the
+// peer end of its stream, and a function ending the connection
+type Connector func() (shim.PeerChaincodeStream, func(), error)
+
+// Case is a step of the suite. Cases run in order on the same stream, each
+// relying on the state left by the previous ones.
+type Case struct {
+	Name string
+	Run  func(p *Peer) error
+}
+
+// Cases are the steps a shim must pass
+var Cases = []Case{
+	{"Registration", checkRegistration},
+	{"Init", checkInit},
+	{"GetState", checkGetState},
+	{"PutState", checkPutState},
+	{"DelState", checkDelState},
+	{"RangeQuery", checkRangeQuery},
+	{"ChaincodeError", checkChaincodeError},
+	{"StateError", checkStateError},
+	{"PutStateInQuery", checkPutStateInQuery},
+	{"UnexpectedMessage", checkUnexpectedMessage},
+}
+
+// Run runs the suite against the shim started by connect. It stops at the
+// first case that fails.
+func Run(t *testing.T, connect Connector) {
+	stream, end, err := connect()
+	if err != nil {
+		t.Fatalf("Error connecting to the shim: %s", err)
+	}
+	defer end()
+
+	p := NewPeer(stream)
+	for _, c := range Cases {
+		if err := c.Run(p); err != nil {
+			t.Fatalf("%s: %s", c.Name, err)
+		}
+	}
+}
+
+func checkRegistration(p *Peer) error {
+	chaincodeID, err := p.Register()
+	if err != nil {
+		return err
+	}
+	if chaincodeID.Name == "" {
+		return fmt.Errorf("Expected the chaincode to register with its name")
+	}
+	return nil
+}
+
+func checkInit(p *Peer) error {
+	if _, err := p.Execute(pb.ChaincodeMessage_INIT, "init", pb.ChaincodeMessage_COMPLETED, "init", "a", "1", "b", "2"); err != nil {
+		return err
+	}
+	if err := p.expectRequests(pb.ChaincodeMessage_PUT_STATE, pb.ChaincodeMessage_PUT_STATE); err != nil {
+		return err
+	}
+	return p.expectState(map[string]string{"a": "1", "b": "2"})
+}
+
+func checkGetState(p *Peer) error {
+	resp, err := p.Execute(pb.ChaincodeMessage_TRANSACTION, "get1", pb.ChaincodeMessage_COMPLETED, "get", "a")
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(resp.Payload, []byte("1")) {
+		return fmt.Errorf("Expected the value of a, got %q", resp.Payload)
+	}
+	return p.expectRequests(pb.ChaincodeMessage_GET_STATE)
+}
+
+func checkPutState(p *Peer) error {
+	if _, err := p.Execute(pb.ChaincodeMessage_TRANSACTION, "put1", pb.ChaincodeMessage_COMPLETED, "put", "c", "3"); err != nil {
+		return err
+	}
+	if err := p.expectRequests(pb.ChaincodeMessage_PUT_STATE); err != nil {
+		return err
+	}
+	return p.expectState(map[string]string{"a": "1", "b": "2", "c": "3"})
+}
+
+func checkDelState(p *Peer) error {
+	if _, err := p.Execute(pb.ChaincodeMessage_TRANSACTION, "del1", pb.ChaincodeMessage_COMPLETED, "del", "b"); err != nil {
+		return err
+	}
+	if err := p.expectRequests(pb.ChaincodeMessage_DEL_STATE); err != nil {
+		return err
+	}
+	return p.expectState(map[string]string{"a": "1", "c": "3"})
+}
+
+func checkRangeQuery(p *Peer) error {
+	resp, err := p.Execute(pb.ChaincodeMessage_QUERY, "range1", pb.ChaincodeMessage_QUERY_COMPLETED, "range", "a", "z")
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(resp.Payload, []byte("a=1,c=3")) {
+		return fmt.Errorf("Expected the keys and values in range, got %q", resp.Payload)
+	}
+	// The peer returns a key at a time, the shim must ask for the next ones
+	if len(p.Requests) < 2 || p.Requests[0] != pb.ChaincodeMessage_RANGE_QUERY_STATE || p.Requests[1] != pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT {
+		return fmt.Errorf("Expected %s then %s, got %v", pb.ChaincodeMessage_RANGE_QUERY_STATE, pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT, p.Requests)
+	}
+	return nil
+}
+
+func checkChaincodeError(p *Peer) error {
+	resp, err := p.Execute(pb.ChaincodeMessage_TRANSACTION, "fail1", pb.ChaincodeMessage_ERROR, "fail", "boom")
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(resp.Payload, []byte("boom")) {
+		return fmt.Errorf("Expected the error of the chaincode, got %q", resp.Payload)
+	}
+	_, err = p.Execute(pb.ChaincodeMessage_QUERY, "fail2", pb.ChaincodeMessage_QUERY_ERROR, "fail", "boom")
+	return err
+}
+
+func checkStateError(p *Peer) error {
+	p.FailKeys["a"] = true
+	defer delete(p.FailKeys, "a")
+	_, err := p.Execute(pb.ChaincodeMessage_TRANSACTION, "get2", pb.ChaincodeMessage_ERROR, "get", "a")
+	return err
+}
+
+func checkPutStateInQuery(p *Peer) error {
+	if _, err := p.Execute(pb.ChaincodeMessage_QUERY, "put2", pb.ChaincodeMessage_QUERY_ERROR, "put", "d", "4"); err != nil {
+		return err
+	}
+	// The shim refuses to put state in a query without asking the peer
+	if err := p.expectRequests(); err != nil {
+		return err
+	}
+	return p.expectState(map[string]string{"a": "1", "c": "3"})
+}
+
+func checkUnexpectedMessage(p *Peer) error {
+	if err := p.stream.Send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT, Uuid: "unexpected1"}); err != nil {
+		return fmt.Errorf("Error sending %s: %s", pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT, err)
+	}
+	msg, err := p.recv()
+	if err != nil {
+		return err
+	}
+	if msg.Type != pb.ChaincodeMessage_ERROR || msg.Uuid != "unexpected1" {
+		return fmt.Errorf("Expected %s of unexpected1, got %s of %s", pb.ChaincodeMessage_ERROR, msg.Type, msg.Uuid)
+	}
+	return nil
+}
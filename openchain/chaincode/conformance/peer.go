@@ -0,0 +1,242 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package conformance
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// DefaultTimeout is how long the peer waits for each message of the shim
+const DefaultTimeout = 5 * time.Second
+
+// Peer is the peer side of a stream to a shim. It keeps the state of the
+// chaincode in memory and serves the state requests of the executions it
+// starts.
+type Peer struct {
+	stream shim.PeerChaincodeStream
+	msgs   chan *pb.ChaincodeMessage
+	errs   chan error
+
+	// State of the chaincode
+	State map[string][]byte
+	// Keys whose GET_STATE is answered with an ERROR
+	FailKeys map[string]bool
+	// Types of the state requests received during the last execution
+	Requests []pb.ChaincodeMessage_Type
+	// How long to wait for each message of the shim
+	Timeout time.Duration
+
+	// Keys left to return by the open range query iterators, by ID
+	iterators map[string][]string
+	nextID    int
+}
+
+// NewPeer returns the peer side of stream
+func NewPeer(stream shim.PeerChaincodeStream) *Peer {
+	p := &Peer{stream: stream, msgs: make(chan *pb.ChaincodeMessage), errs: make(chan error, 1),
+		State: make(map[string][]byte), FailKeys: make(map[string]bool), Timeout: DefaultTimeout,
+		iterators: make(map[string][]string)}
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				p.errs <- err
+				return
+			}
+			p.msgs <- msg
+		}
+	}()
+	return p
+}
+
+// recv returns the next message of the shim
+func (p *Peer) recv() (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-p.msgs:
+		return msg, nil
+	case err := <-p.errs:
+		return nil, fmt.Errorf("Error receiving from the shim: %s", err)
+	case <-time.After(p.Timeout):
+		return nil, fmt.Errorf("Timed out waiting for the shim")
+	}
+}
+
+// Register waits for the shim to register and accepts the registration,
+// speaking the highest version of the protocol both ends know
+func (p *Peer) Register() (*pb.ChaincodeID, error) {
+	msg, err := p.recv()
+	if err != nil {
+		return nil, err
+	}
+	if msg.Type != pb.ChaincodeMessage_REGISTER {
+		return nil, fmt.Errorf("Expected %s, got %s", pb.ChaincodeMessage_REGISTER, msg.Type)
+	}
+	chaincodeID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(msg.Payload, chaincodeID); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling the ChaincodeID of %s: %s", pb.ChaincodeMessage_REGISTER, err)
+	}
+	version := pb.NegotiateChaincodeProtocolVersion(msg.ProtocolVersion)
+	if err := p.stream.Send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED, ProtocolVersion: version}); err != nil {
+		return nil, fmt.Errorf("Error sending %s: %s", pb.ChaincodeMessage_REGISTERED, err)
+	}
+	return chaincodeID, nil
+}
+
+// Execute sends an INIT, TRANSACTION or QUERY message calling function with
+// args, and serves the state requests of the execution until it ends. The
+// execution must end with a message of type expected, which is returned.
+func (p *Peer) Execute(msgType pb.ChaincodeMessage_Type, uuid string, expected pb.ChaincodeMessage_Type, function string, args ...string) (*pb.ChaincodeMessage, error) {
+	payload, err := proto.Marshal(&pb.ChaincodeInput{Function: function, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	p.Requests = nil
+	if err := p.stream.Send(&pb.ChaincodeMessage{Type: msgType, Payload: payload, Uuid: uuid, Timestamp: util.CreateUtcTimestamp()}); err != nil {
+		return nil, fmt.Errorf("Error sending %s: %s", msgType, err)
+	}
+	for {
+		msg, err := p.recv()
+		if err != nil {
+			return nil, err
+		}
+		if msg.Uuid != uuid {
+			return nil, fmt.Errorf("Expected messages of %s, got %s of %s", uuid, msg.Type, msg.Uuid)
+		}
+		switch msg.Type {
+		case pb.ChaincodeMessage_COMPLETED, pb.ChaincodeMessage_ERROR, pb.ChaincodeMessage_QUERY_COMPLETED, pb.ChaincodeMessage_QUERY_ERROR:
+			if msg.Type != expected {
+				return nil, fmt.Errorf("Expected %s to end with %s, got %s: %s", uuid, expected, msg.Type, msg.Payload)
+			}
+			return msg, nil
+		}
+		p.Requests = append(p.Requests, msg.Type)
+		resp, err := p.serve(msg)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.stream.Send(resp); err != nil {
+			return nil, fmt.Errorf("Error sending %s: %s", resp.Type, err)
+		}
+	}
+}
+
+// serve answers a state request
+func (p *Peer) serve(msg *pb.ChaincodeMessage) (*pb.ChaincodeMessage, error) {
+	var payload []byte
+	var err error
+	switch msg.Type {
+	case pb.ChaincodeMessage_GET_STATE:
+		key := string(msg.Payload)
+		if p.FailKeys[key] {
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte("failed to get " + key), Uuid: msg.Uuid}, nil
+		}
+		payload = p.State[key]
+	case pb.ChaincodeMessage_PUT_STATE:
+		putStateInfo := &pb.PutStateInfo{}
+		if err = proto.Unmarshal(msg.Payload, putStateInfo); err == nil {
+			p.State[putStateInfo.Key] = putStateInfo.Value
+		}
+	case pb.ChaincodeMessage_DEL_STATE:
+		delete(p.State, string(msg.Payload))
+	case pb.ChaincodeMessage_RANGE_QUERY_STATE:
+		rangeQueryState := &pb.RangeQueryState{}
+		if err = proto.Unmarshal(msg.Payload, rangeQueryState); err == nil {
+			var keys []string
+			for key := range p.State {
+				if key >= rangeQueryState.StartKey && key <= rangeQueryState.EndKey {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			p.nextID++
+			id := strconv.Itoa(p.nextID)
+			p.iterators[id] = keys
+			payload, err = p.nextRangeQueryStateResponse(id)
+		}
+	case pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT:
+		next := &pb.RangeQueryStateNext{}
+		if err = proto.Unmarshal(msg.Payload, next); err == nil {
+			payload, err = p.nextRangeQueryStateResponse(next.ID)
+		}
+	case pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE:
+		closeMsg := &pb.RangeQueryStateClose{}
+		if err = proto.Unmarshal(msg.Payload, closeMsg); err == nil {
+			delete(p.iterators, closeMsg.ID)
+			payload, err = proto.Marshal(&pb.RangeQueryStateResponse{ID: closeMsg.ID})
+		}
+	default:
+		return nil, fmt.Errorf("Unexpected %s during the execution of %s", msg.Type, msg.Uuid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error handling %s: %s", msg.Type, err)
+	}
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payload, Uuid: msg.Uuid}, nil
+}
+
+// nextRangeQueryStateResponse returns the next key of the iterator with id,
+// a key at a time so that the shim has to ask for the following ones
+func (p *Peer) nextRangeQueryStateResponse(id string) ([]byte, error) {
+	keys, ok := p.iterators[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown range query iterator %s", id)
+	}
+	resp := &pb.RangeQueryStateResponse{ID: id}
+	if len(keys) > 0 {
+		resp.KeysAndValues = []*pb.RangeQueryStateKeyValue{{Key: keys[0], Value: p.State[keys[0]]}}
+		p.iterators[id] = keys[1:]
+	}
+	resp.HasMore = len(p.iterators[id]) > 0
+	return proto.Marshal(resp)
+}
+
+// expectRequests checks the state requests of the last execution
+func (p *Peer) expectRequests(expected ...pb.ChaincodeMessage_Type) error {
+	if len(p.Requests) != len(expected) {
+		return fmt.Errorf("Expected requests %v, got %v", expected, p.Requests)
+	}
+	for i := range expected {
+		if p.Requests[i] != expected[i] {
+			return fmt.Errorf("Expected requests %v, got %v", expected, p.Requests)
+		}
+	}
+	return nil
+}
+
+// expectState checks the state of the chaincode
+func (p *Peer) expectState(expected map[string]string) error {
+	if len(p.State) != len(expected) {
+		return fmt.Errorf("Expected state %v, got %d keys", expected, len(p.State))
+	}
+	for key, value := range expected {
+		if string(p.State[key]) != value {
+			return fmt.Errorf("Expected %s to be %q, got %q", key, value, p.State[key])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package conformance
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// Server is a ChaincodeSupport server for shims running out of the process
+// of the suite, such as shims written in other languages. Register it with
+// a gRPC server, start the shim with the address of the server and run the
+// suite with the Connector of the server.
+type Server struct {
+	streams chan *serverStream
+	// How long Connect waits for a shim to register
+	Timeout time.Duration
+}
+
+// serverStream is a stream registered by a shim, held open until done
+type serverStream struct {
+	pb.ChaincodeSupport_RegisterServer
+	done chan struct{}
+}
+
+// NewServer returns a Server waiting for shims
+func NewServer() *Server {
+	return &Server{streams: make(chan *serverStream), Timeout: 30 * time.Second}
+}
+
+// GetExecutionContext implements pb.ChaincodeSupportServer
+func (s *Server) GetExecutionContext(context context.Context, requestContext *pb.ChaincodeRequestContext) (*pb.ChaincodeExecutionContext, error) {
+	return &pb.ChaincodeExecutionContext{ChaincodeId: requestContext.GetId(), Timestamp: util.CreateUtcTimestamp()}, nil
+}
+
+// Register implements pb.ChaincodeSupportServer. The stream is handed to
+// Connect and held open until the connection is ended.
+func (s *Server) Register(stream pb.ChaincodeSupport_RegisterServer) error {
+	ss := &serverStream{ChaincodeSupport_RegisterServer: stream, done: make(chan struct{})}
+	select {
+	case s.streams <- ss:
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+	select {
+	case <-ss.done:
+	case <-stream.Context().Done():
+	}
+	return nil
+}
+
+// Connect is the Connector of the server. It waits for the next shim to
+// register.
+func (s *Server) Connect() (shim.PeerChaincodeStream, func(), error) {
+	select {
+	case ss := <-s.streams:
+		return ss, func() { close(ss.done) }, nil
+	case <-time.After(s.Timeout):
+		return nil, nil, fmt.Errorf("No shim registered within %s", s.Timeout)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// streamBufferSize is how many messages a stream of a pair holds before
+// Send blocks
+const streamBufferSize = 16
+
+// Stream is one end of a pair of in memory streams, for shims running in the
+// process of the suite
+type Stream struct {
+	recv   <-chan *pb.ChaincodeMessage
+	send   chan<- *pb.ChaincodeMessage
+	closed chan struct{}
+	once   *sync.Once
+}
+
+// NewStreamPair returns the peer and shim ends of a pair of streams
+func NewStreamPair() (*Stream, *Stream) {
+	a := make(chan *pb.ChaincodeMessage, streamBufferSize)
+	b := make(chan *pb.ChaincodeMessage, streamBufferSize)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	return &Stream{recv: a, send: b, closed: closed, once: once}, &Stream{recv: b, send: a, closed: closed, once: once}
+}
+
+// Send sends msg to the other end
+func (s *Stream) Send(msg *pb.ChaincodeMessage) error {
+	select {
+	case <-s.closed:
+		return fmt.Errorf("stream closed")
+	default:
+	}
+	select {
+	case s.send <- msg:
+		return nil
+	case <-s.closed:
+		return fmt.Errorf("stream closed")
+	}
+}
+
+// Recv returns the next message from the other end, or io.EOF once the
+// pair is closed
+func (s *Stream) Recv() (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.recv:
+		return msg, nil
+	case <-s.closed:
+		return nil, io.EOF
+	}
+}
+
+// Close ends both streams of the pair
+func (s *Stream) Close() {
+	s.once.Do(func() { close(s.closed) })
+}
@@ -0,0 +1,140 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// deferredUUIDSeparator separates the UUID of a transaction from the index of
+// an invocation it enqueued in the UUID of the transaction submitted for it
+const deferredUUIDSeparator = "-deferred-"
+
+// deferredCalls buffers the invocations enqueued by the transactions in
+// progress. The invocations of a successful transaction are kept until the
+// block of the transaction commits, those of a failed one are dropped.
+type deferredCalls struct {
+	sync.Mutex
+	max     int
+	pending map[string][]*pb.ChaincodeSpec
+	queued  map[string][]*pb.Transaction
+}
+
+func newDeferredCalls() *deferredCalls {
+	return &deferredCalls{max: viper.GetInt("chaincode.deferred.maxPerTransaction"),
+		pending: make(map[string][]*pb.ChaincodeSpec), queued: make(map[string][]*pb.Transaction)}
+}
+
+func (d *deferredCalls) begin(uuid string) {
+	d.Lock()
+	defer d.Unlock()
+	d.pending[uuid] = nil
+}
+
+// add records an invocation enqueued by a transaction in progress
+func (d *deferredCalls) add(uuid string, spec *pb.ChaincodeSpec) error {
+	d.Lock()
+	defer d.Unlock()
+	calls, ok := d.pending[uuid]
+	if !ok {
+		return fmt.Errorf("[%s]No transaction in progress", shortuuid(uuid))
+	}
+	if d.max > 0 && len(calls) >= d.max {
+		return fmt.Errorf("[%s]Cannot enqueue more than %d invocations", shortuuid(uuid), d.max)
+	}
+	d.pending[uuid] = append(calls, spec)
+	return nil
+}
+
+// finish queues the transactions for the invocations enqueued by a
+// successful transaction, or drops them if it failed
+func (d *deferredCalls) finish(uuid string, successful bool) {
+	d.Lock()
+	defer d.Unlock()
+	calls := d.pending[uuid]
+	delete(d.pending, uuid)
+	if !successful || len(calls) == 0 {
+		return
+	}
+	txs := make([]*pb.Transaction, 0, len(calls))
+	for i, spec := range calls {
+		tx, err := pb.NewChaincodeExecute(&pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}, deferredUUID(uuid, i), pb.Transaction_CHAINCODE_EXECUTE)
+		if err != nil {
+			chaincodeLogger.Error("[%s]Failed to create transaction for enqueued invocation %d: %s", shortuuid(uuid), i, err)
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	d.queued[uuid] = txs
+}
+
+// take removes and returns the queued transactions of the given transactions
+// in order. If submit is false they are dropped, as when their block is
+// rolled back.
+func (d *deferredCalls) take(uuids []string, submit bool) []*pb.Transaction {
+	d.Lock()
+	defer d.Unlock()
+	var txs []*pb.Transaction
+	for _, uuid := range uuids {
+		if submit {
+			txs = append(txs, d.queued[uuid]...)
+		}
+		delete(d.queued, uuid)
+	}
+	return txs
+}
+
+// deferredUUID returns the UUID of the transaction submitted for the i-th
+// invocation enqueued by a transaction. It is the same on every validator so
+// that the copies they submit are recognized as one transaction.
+func deferredUUID(uuid string, i int) string {
+	return fmt.Sprintf("%s%s%d", uuid, deferredUUIDSeparator, i)
+}
+
+// IsDeferredUUID returns whether uuid is the UUID of a transaction submitted
+// for an invocation enqueued by a chaincode
+func IsDeferredUUID(uuid string) bool {
+	return strings.Contains(uuid, deferredUUIDSeparator)
+}
+
+// TakeDeferredTransactions returns the transactions for the invocations
+// enqueued by the given committed transactions, to be submitted. They are
+// no longer kept by the chain.
+func TakeDeferredTransactions(cname ChainName, uuids []string) []*pb.Transaction {
+	chain := GetChain(cname)
+	if chain == nil {
+		return nil
+	}
+	return chain.deferredCalls.take(uuids, true)
+}
+
+// DiscardDeferredTransactions drops the invocations enqueued by the given
+// transactions, whose block was not committed
+func DiscardDeferredTransactions(cname ChainName, uuids []string) {
+	if chain := GetChain(cname); chain != nil {
+		chain.deferredCalls.take(uuids, false)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func enqueuedSpec(name string, function string) *pb.ChaincodeSpec {
+	return &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: name}, CtorMsg: &pb.ChaincodeInput{Function: function}}
+}
+
+func TestDeferredCalls(t *testing.T) {
+	d := &deferredCalls{max: 2, pending: make(map[string][]*pb.ChaincodeSpec), queued: make(map[string][]*pb.Transaction)}
+	if err := d.add("tx1", enqueuedSpec("cc", "step")); err == nil {
+		t.Fatalf("Expected error enqueuing invocation outside of a transaction")
+	}
+
+	d.begin("tx1")
+	d.begin("tx2")
+	for _, function := range []string{"step2", "step3"} {
+		if err := d.add("tx1", enqueuedSpec("cc", function)); err != nil {
+			t.Fatalf("Error enqueuing invocation: %s", err)
+		}
+	}
+	if err := d.add("tx1", enqueuedSpec("cc", "step4")); err == nil {
+		t.Fatalf("Expected error enqueuing more invocations than allowed")
+	}
+	if err := d.add("tx2", enqueuedSpec("other", "step")); err != nil {
+		t.Fatalf("Error enqueuing invocation: %s", err)
+	}
+	d.finish("tx1", true)
+	d.finish("tx2", false)
+
+	txs := d.take([]string{"tx1", "tx2"}, true)
+	if len(txs) != 2 {
+		t.Fatalf("Expected the 2 invocations of the successful transaction, got %d", len(txs))
+	}
+	for i, tx := range txs {
+		if tx.Uuid != deferredUUID("tx1", i) || !IsDeferredUUID(tx.Uuid) {
+			t.Fatalf("Unexpected UUID %s for invocation %d", tx.Uuid, i)
+		}
+		if tx.Type != pb.Transaction_CHAINCODE_EXECUTE {
+			t.Fatalf("Unexpected transaction type %s", tx.Type)
+		}
+	}
+	if txs := d.take([]string{"tx1"}, true); len(txs) != 0 {
+		t.Fatalf("Expected invocations to be submitted once, got %d more", len(txs))
+	}
+	if IsDeferredUUID("tx1") {
+		t.Fatalf("Expected UUID of a submitted transaction not to be deferred")
+	}
+}
+
+func TestDeferredCalls_Discard(t *testing.T) {
+	d := &deferredCalls{pending: make(map[string][]*pb.ChaincodeSpec), queued: make(map[string][]*pb.Transaction)}
+	d.begin("tx1")
+	if err := d.add("tx1", enqueuedSpec("cc", "step")); err != nil {
+		t.Fatalf("Error enqueuing invocation: %s", err)
+	}
+	d.finish("tx1", true)
+	d.take([]string{"tx1"}, false)
+	if txs := d.take([]string{"tx1"}, true); len(txs) != 0 {
+		t.Fatalf("Expected invocations of a rolled back block to be dropped, got %d", len(txs))
+	}
+}
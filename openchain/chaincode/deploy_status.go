@@ -0,0 +1,188 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// watcherBufferSize is large enough to hold every phase of a deployment
+const watcherBufferSize = 8
+
+// statusTTL is how long the status of a deployment is kept after it last
+// changed, for clients to poll. Deployments that never become READY or
+// FAILED, like the SUBMITTED ones of non-validating peers which do not
+// execute the deploy transaction, expire as well.
+const statusTTL = time.Hour
+
+// deploymentTracker records the progress of chaincode deployments on this
+// peer so that clients can poll or watch a deployment instead of blocking
+// on Deploy
+type deploymentTracker struct {
+	sync.Mutex
+	statuses map[string]*pb.DeploymentStatus
+	// updated holds when the status of each deployment last changed
+	updated  map[string]time.Time
+	watchers map[string][]chan *pb.DeploymentStatus
+}
+
+var deployments = newDeploymentTracker()
+
+func newDeploymentTracker() *deploymentTracker {
+	return &deploymentTracker{statuses: make(map[string]*pb.DeploymentStatus), updated: make(map[string]time.Time),
+		watchers: make(map[string][]chan *pb.DeploymentStatus)}
+}
+
+func isFinalPhase(phase pb.DeploymentStatus_Phase) bool {
+	return phase == pb.DeploymentStatus_READY || phase == pb.DeploymentStatus_FAILED
+}
+
+func (d *deploymentTracker) set(name string, phase pb.DeploymentStatus_Phase, msg string) {
+	status := &pb.DeploymentStatus{Name: name, Phase: phase, Msg: msg, Timestamp: util.CreateUtcTimestamp()}
+	d.Lock()
+	defer d.Unlock()
+	// the checks of the image stay with the deployment, a new one starts over
+	if prev, ok := d.statuses[name]; ok && phase != pb.DeploymentStatus_SUBMITTED {
+		status.Checks = prev.Checks
+	}
+	d.statuses[name] = status
+	for _, w := range d.watchers[name] {
+		select {
+		case w <- status:
+		default:
+			chaincodeLog.Warning("Dropping status %s of deployment %s for slow watcher", phase, name)
+		}
+		if isFinalPhase(phase) {
+			close(w)
+		}
+	}
+	if isFinalPhase(phase) {
+		delete(d.watchers, name)
+	}
+	d.updated[name] = time.Now()
+	d.evict(time.Now())
+}
+
+// evict drops the statuses of the deployments that last changed more than
+// statusTTL before now. Their watchers are sent the UNKNOWN status and closed.
+func (d *deploymentTracker) evict(now time.Time) {
+	for name, updated := range d.updated {
+		if now.Sub(updated) <= statusTTL {
+			continue
+		}
+		delete(d.statuses, name)
+		delete(d.updated, name)
+		for _, w := range d.watchers[name] {
+			select {
+			case w <- &pb.DeploymentStatus{Name: name, Phase: pb.DeploymentStatus_UNKNOWN}:
+			default:
+			}
+			close(w)
+		}
+		delete(d.watchers, name)
+	}
+}
+
+// addCheck records the result of a pre-launch hook with the current status.
+// Watchers see it with the next phase.
+func (d *deploymentTracker) addCheck(name string, check *pb.PreLaunchCheck) {
+	d.Lock()
+	defer d.Unlock()
+	status := &pb.DeploymentStatus{Name: name, Phase: pb.DeploymentStatus_UNKNOWN}
+	if prev, ok := d.statuses[name]; ok {
+		// statuses are shared with watchers, replace rather than modify
+		copied := *prev
+		status = &copied
+	}
+	status.Checks = append(append([]*pb.PreLaunchCheck(nil), status.Checks...), check)
+	d.statuses[name] = status
+	d.updated[name] = time.Now()
+}
+
+func (d *deploymentTracker) get(name string) *pb.DeploymentStatus {
+	d.Lock()
+	defer d.Unlock()
+	if status, ok := d.statuses[name]; ok {
+		return status
+	}
+	return &pb.DeploymentStatus{Name: name, Phase: pb.DeploymentStatus_UNKNOWN}
+}
+
+func (d *deploymentTracker) watch(name string) (<-chan *pb.DeploymentStatus, func()) {
+	d.Lock()
+	defer d.Unlock()
+	d.evict(time.Now())
+	w := make(chan *pb.DeploymentStatus, watcherBufferSize)
+	status, ok := d.statuses[name]
+	if !ok {
+		// nothing would ever change the status of an unknown deployment
+		w <- &pb.DeploymentStatus{Name: name, Phase: pb.DeploymentStatus_UNKNOWN}
+		close(w)
+		return w, func() {}
+	}
+	w <- status
+	if isFinalPhase(status.Phase) {
+		close(w)
+		return w, func() {}
+	}
+	d.watchers[name] = append(d.watchers[name], w)
+	cancel := func() {
+		d.Lock()
+		defer d.Unlock()
+		ws := d.watchers[name]
+		for i := range ws {
+			if ws[i] == w {
+				d.watchers[name] = append(ws[:i], ws[i+1:]...)
+				break
+			}
+		}
+		if len(d.watchers[name]) == 0 {
+			delete(d.watchers, name)
+		}
+	}
+	return w, cancel
+}
+
+// SetDeploymentStatus records the phase the deployment of the named chaincode
+// has reached and notifies its watchers
+func SetDeploymentStatus(name string, phase pb.DeploymentStatus_Phase, msg string) {
+	chaincodeLog.Debug("Deployment of %s is %s", name, phase)
+	deployments.set(name, phase, msg)
+}
+
+// GetDeploymentStatus returns the last recorded status of the deployment of
+// the named chaincode. Deployments not known to this peer, or whose status has
+// not changed for an hour, are UNKNOWN.
+func GetDeploymentStatus(name string) *pb.DeploymentStatus {
+	return deployments.get(name)
+}
+
+// WatchDeployment returns a channel delivering the current status of the
+// deployment followed by every change. The channel is closed once the
+// deployment is READY or FAILED, or after the UNKNOWN status if the
+// deployment is not known to this peer or its status expired. The returned
+// function stops watching early.
+func WatchDeployment(name string) (<-chan *pb.DeploymentStatus, func()) {
+	return deployments.watch(name)
+}
@@ -0,0 +1,127 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestDeploymentTracker_Unknown(t *testing.T) {
+	d := newDeploymentTracker()
+	if status := d.get("mycc"); status.Phase != pb.DeploymentStatus_UNKNOWN {
+		t.Fatalf("Expected UNKNOWN status, got %s", status.Phase)
+	}
+}
+
+func TestDeploymentTracker_Watch(t *testing.T) {
+	d := newDeploymentTracker()
+	d.set("mycc", pb.DeploymentStatus_SUBMITTED, "")
+	w, cancel := d.watch("mycc")
+	defer cancel()
+	d.set("mycc", pb.DeploymentStatus_BUILDING, "")
+	d.set("mycc", pb.DeploymentStatus_FAILED, "build error")
+
+	var phases []pb.DeploymentStatus_Phase
+	for status := range w {
+		phases = append(phases, status.Phase)
+	}
+	expected := []pb.DeploymentStatus_Phase{pb.DeploymentStatus_SUBMITTED, pb.DeploymentStatus_BUILDING, pb.DeploymentStatus_FAILED}
+	if len(phases) != len(expected) {
+		t.Fatalf("Expected phases %v, got %v", expected, phases)
+	}
+	for i := range expected {
+		if phases[i] != expected[i] {
+			t.Fatalf("Expected phases %v, got %v", expected, phases)
+		}
+	}
+	if status := d.get("mycc"); status.Msg != "build error" {
+		t.Fatalf("Expected failure message to be retained, got %s", status.Msg)
+	}
+}
+
+func TestDeploymentTracker_WatchCompleted(t *testing.T) {
+	d := newDeploymentTracker()
+	d.set("mycc", pb.DeploymentStatus_READY, "")
+	w, _ := d.watch("mycc")
+	status, ok := <-w
+	if !ok || status.Phase != pb.DeploymentStatus_READY {
+		t.Fatalf("Expected READY status for completed deployment")
+	}
+	if _, ok = <-w; ok {
+		t.Fatalf("Expected watch of completed deployment to be closed")
+	}
+}
+
+func TestDeploymentTracker_Cancel(t *testing.T) {
+	d := newDeploymentTracker()
+	_, cancel := d.watch("mycc")
+	cancel()
+	if len(d.watchers) != 0 {
+		t.Fatalf("Expected watcher to be removed on cancel")
+	}
+}
+
+func TestDeploymentTracker_Evict(t *testing.T) {
+	d := newDeploymentTracker()
+	d.set("done", pb.DeploymentStatus_READY, "")
+	d.set("failed", pb.DeploymentStatus_FAILED, "build error")
+	d.set("submitted", pb.DeploymentStatus_SUBMITTED, "")
+	w, cancel := d.watch("submitted")
+	defer cancel()
+
+	d.evict(time.Now())
+	if status := d.get("done"); status.Phase != pb.DeploymentStatus_READY {
+		t.Fatalf("Expected a recently finished deployment to be kept, got %s", status.Phase)
+	}
+
+	d.evict(time.Now().Add(statusTTL + time.Minute))
+	for _, name := range []string{"done", "failed", "submitted"} {
+		if status := d.get(name); status.Phase != pb.DeploymentStatus_UNKNOWN {
+			t.Fatalf("Expected the status of %s to be evicted, got %s", name, status.Phase)
+		}
+	}
+	if len(d.updated) != 0 || len(d.watchers) != 0 {
+		t.Fatalf("Expected no deployments left, got %v and %v", d.updated, d.watchers)
+	}
+
+	// the watcher of the expired deployment is told and released
+	var phases []pb.DeploymentStatus_Phase
+	for status := range w {
+		phases = append(phases, status.Phase)
+	}
+	if len(phases) != 2 || phases[1] != pb.DeploymentStatus_UNKNOWN {
+		t.Fatalf("Expected the watch to end with UNKNOWN, got %v", phases)
+	}
+}
+
+func TestDeploymentTracker_WatchUnknown(t *testing.T) {
+	d := newDeploymentTracker()
+	w, _ := d.watch("mycc")
+	status, ok := <-w
+	if !ok || status.Phase != pb.DeploymentStatus_UNKNOWN {
+		t.Fatalf("Expected UNKNOWN status for a deployment never submitted")
+	}
+	if _, ok = <-w; ok {
+		t.Fatalf("Expected watch of an unknown deployment to be closed")
+	}
+}
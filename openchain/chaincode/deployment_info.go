@@ -0,0 +1,67 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// newDeploymentInfo describes the deployment of a chaincode from its deploy
+// transaction and the deployment spec it carries
+func newDeploymentInfo(depTx *pb.Transaction, cds *pb.ChaincodeDeploymentSpec) *pb.DeploymentInfo {
+	info := &pb.DeploymentInfo{DeployUuid: depTx.Uuid, ChainID: depTx.ChainID, ExecEnv: cds.ExecEnv}
+	cID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(depTx.ChaincodeID, cID); err == nil {
+		info.ChaincodeID = cID
+	}
+	if cds.ChaincodeSpec != nil {
+		info.Type = cds.ChaincodeSpec.Type
+		info.Metadata = cds.ChaincodeSpec.Metadata
+	}
+	if len(cds.CodePackage) > 0 {
+		info.CodeHash = fmt.Sprintf("%x", util.ComputeCryptoHash(cds.CodePackage))
+	}
+	return info
+}
+
+// handleGetDeploymentInfo responds to a GET_DEPLOYMENT_INFO request with how
+// the chaincode of the handler was deployed. The deployment does not change
+// while the chaincode runs so the request can be made in any state.
+func (handler *Handler) handleGetDeploymentInfo(msg *pb.ChaincodeMessage) {
+	var payload []byte
+	var err error
+	if handler.deploymentInfo == nil {
+		err = fmt.Errorf("Deployment of %s is not known", handler.ChaincodeID.Name)
+	} else {
+		payload, err = proto.Marshal(handler.deploymentInfo)
+	}
+	if err != nil {
+		chaincodeLogger.Debug("[%s]Failed to get deployment info: %s. Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR)
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid})
+		return
+	}
+	chaincodeLogger.Debug("[%s]Sending deployment info. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
+	handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payload, Uuid: msg.Uuid})
+}
@@ -0,0 +1,80 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestGetDeploymentInfo(t *testing.T) {
+	stream := cctesting.NewMockStream()
+	handler := newChaincodeSupportHandler(newDevModeSupport(), stream)
+	defer handler.sendQueue.close()
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	handler.protocolVersion = pb.ChaincodeProtocolVersion
+
+	request := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_DEPLOYMENT_INFO, Uuid: "tx1"}
+	if err := handler.HandleMessage(request); err != nil {
+		t.Fatalf("Error handling %s: %s", request.Type, err)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_ERROR {
+		t.Fatalf("Expected ERROR before the deployment is known, got %s", msg.Type)
+	}
+
+	cID, _ := proto.Marshal(&pb.ChaincodeID{Path: "github.com/mycc", Name: "mycc"})
+	depTx := &pb.Transaction{Uuid: "deploy1", ChainID: "chain1", ChaincodeID: cID}
+	cds := &pb.ChaincodeDeploymentSpec{CodePackage: []byte("code"),
+		ChaincodeSpec: &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, Metadata: []byte("v2")}}
+	handler.deploymentInfo = newDeploymentInfo(depTx, cds)
+
+	if err := handler.HandleMessage(request); err != nil {
+		t.Fatalf("Error handling %s: %s", request.Type, err)
+	}
+	msg := <-stream.Sent
+	if msg.Type != pb.ChaincodeMessage_RESPONSE || msg.Uuid != "tx1" {
+		t.Fatalf("Expected RESPONSE of tx1, got %s of %s", msg.Type, msg.Uuid)
+	}
+	info := &pb.DeploymentInfo{}
+	if err := proto.Unmarshal(msg.Payload, info); err != nil {
+		t.Fatalf("Error unmarshalling deployment info: %s", err)
+	}
+	if info.ChaincodeID == nil || info.ChaincodeID.Name != "mycc" || info.ChaincodeID.Path != "github.com/mycc" || info.DeployUuid != "deploy1" ||
+		info.ChainID != "chain1" || info.Type != pb.ChaincodeSpec_GOLANG || !bytes.Equal(info.Metadata, []byte("v2")) {
+		t.Fatalf("Unexpected deployment info %v", info)
+	}
+	if info.CodeHash == "" {
+		t.Fatalf("Expected the hash of the code package")
+	}
+
+	// Chaincodes speaking the protocol from before the message are refused
+	handler.protocolVersion = 0
+	if err := handler.HandleMessage(request); err != nil {
+		t.Fatalf("Error handling %s: %s", request.Type, err)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_ERROR {
+		t.Fatalf("Expected ERROR for a chaincode speaking version 0, got %s", msg.Type)
+	}
+}
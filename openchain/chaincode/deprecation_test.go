@@ -0,0 +1,60 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestWarnDeprecated(t *testing.T) {
+	stream := cctesting.NewMockStream()
+	handler := newChaincodeSupportHandler(newDevModeSupport(), stream)
+	defer handler.sendQueue.close()
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	handler.protocolVersion = pb.ChaincodeProtocolVersion
+
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RANGE_QUERY_STATE, Uuid: "tx1"}
+	handler.warnDeprecated(msg, "use RANGE_QUERY_STATE_V2")
+	warning := <-stream.Sent
+	if warning.Type != pb.ChaincodeMessage_DEPRECATION_WARNING || warning.Uuid != "tx1" ||
+		!strings.Contains(string(warning.Payload), "RANGE_QUERY_STATE is deprecated") || !strings.Contains(string(warning.Payload), "RANGE_QUERY_STATE_V2") {
+		t.Fatalf("Expected a deprecation warning for RANGE_QUERY_STATE, got %s %s", warning.Type, warning.Payload)
+	}
+
+	// Each type is reported once, chaincodes speaking an older version of
+	// the protocol are not sent warnings
+	handler.warnDeprecated(msg, "use RANGE_QUERY_STATE_V2")
+	handler.protocolVersion = 1
+	handler.warnDeprecated(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE, Uuid: "tx2"}, "use GET_STATE_V2")
+	handler.protocolVersion = pb.ChaincodeProtocolVersion
+	handler.warnDeprecated(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Uuid: "tx3"}, "use PUT_STATE_V2")
+	if warning = <-stream.Sent; warning.Type != pb.ChaincodeMessage_DEPRECATION_WARNING || warning.Uuid != "tx3" {
+		t.Fatalf("Expected the deprecation warning for PUT_STATE only, got %s of %s", warning.Type, warning.Uuid)
+	}
+	select {
+	case msg := <-stream.Sent:
+		t.Fatalf("Unexpected message %s", msg.Type)
+	default:
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// determinismRunDelay separates the executions of a determinism check so that
+// chaincode reading the clock sees a different time in each of them
+const determinismRunDelay = 1100 * time.Millisecond
+
+// CheckDeterminism executes the invoke transaction runs times on the same state
+// without keeping its state changes, and compares the executions. The runs
+// are perturbed the ways validators differ from one another. Each run is
+// numbered on the transaction message, and the shim schedules the chaincode
+// on a single thread in odd runs and on twice as many threads as there are
+// CPUs in even ones, and reseeds the global math/rand source, so that
+// goroutine races and unseeded random numbers show up. The runs are spaced by
+// more than a second, so that chaincode reading the clock sees different
+// times. Map iteration order varies on its own.
+//
+// The clock of the chaincode cannot be shifted from the peer, so chaincode
+// only diverging with clocks further apart than the spacing, such as code
+// comparing dates, is not caught. Neither is chaincode reading the file system
+// or the network, nor chaincode built on a shim predating numbered runs, which
+// executes every run unperturbed. The chaincode is deterministic if all
+// executions fail alike or all return the same result and make the same
+// state changes.
+func CheckDeterminism(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction, runs int) (*pb.DeterminismReport, error) {
+	if runs < 2 {
+		return nil, fmt.Errorf("Determinism check needs at least 2 runs, got %d", runs)
+	}
+	ledger, err := ledger.GetChainLedger(t.ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	stateHash, err := ledger.GetTempStateHash()
+	if err != nil {
+		return nil, err
+	}
+
+	var results [][]byte
+	var deltas []*statemgmt.StateDelta
+	var errs []string
+	for i := 0; i < runs; i++ {
+		if i > 0 {
+			time.Sleep(determinismRunDelay)
+		}
+		result, delta, err := simulate(ctxt, chain, t, uint32(i+1))
+		if err != nil {
+			chaincodeLog.Debug("Run %d of determinism check of %s failed: %s", i, t.Uuid, err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		results = append(results, result)
+		deltas = append(deltas, delta)
+	}
+
+	//the comparison is only meaningful if every run saw the same state
+	newStateHash, err := ledger.GetTempStateHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(stateHash, newStateHash) {
+		return nil, fmt.Errorf("State changed during determinism check of %s, try again", t.Uuid)
+	}
+
+	return compareRuns(results, deltas, errs), nil
+}
+
+//compareRuns reports how the results and state changes of the successful
+//runs and the errors of the failed ones differ
+func compareRuns(results [][]byte, deltas []*statemgmt.StateDelta, errs []string) *pb.DeterminismReport {
+	report := &pb.DeterminismReport{Errors: errs}
+	for _, delta := range deltas {
+		report.StateDeltaHashes = append(report.StateDeltaHashes, fmt.Sprintf("%x", hashStateDelta(delta)))
+	}
+	for i := 1; i < len(results); i++ {
+		if !bytes.Equal(results[i], results[0]) {
+			report.DivergentResults = true
+		}
+	}
+	report.DivergentKeys = getDivergentKeys(deltas)
+
+	failedAlike := len(results) == 0
+	for _, e := range errs {
+		if e != errs[0] {
+			failedAlike = false
+		}
+	}
+	succeededAlike := len(errs) == 0 && !report.DivergentResults && len(report.DivergentKeys) == 0
+	report.Deterministic = failedAlike || succeededAlike
+	return report
+}
+
+//getDivergentKeys returns the keys, as chaincodeID/key, that are not changed
+//the same way by all the deltas
+func getDivergentKeys(deltas []*statemgmt.StateDelta) []string {
+	keys := make(map[string]bool)
+	for _, delta := range deltas {
+		for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+			for key := range delta.GetUpdates(chaincodeID) {
+				if !sameUpdate(deltas, chaincodeID, key) {
+					keys[chaincodeID+"/"+key] = true
+				}
+			}
+		}
+	}
+	var divergent []string
+	for key := range keys {
+		divergent = append(divergent, key)
+	}
+	sort.Strings(divergent)
+	return divergent
+}
+
+func sameUpdate(deltas []*statemgmt.StateDelta, chaincodeID string, key string) bool {
+	first := deltas[0].Get(chaincodeID, key)
+	for _, delta := range deltas[1:] {
+		update := delta.Get(chaincodeID, key)
+		if first == nil || update == nil {
+			if first != update {
+				return false
+			}
+			continue
+		}
+		if first.IsDelete() != update.IsDelete() || !bytes.Equal(first.GetValue(), update.GetValue()) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+)
+
+func TestCompareRuns(t *testing.T) {
+	delta := func(kvs ...string) *statemgmt.StateDelta {
+		d := statemgmt.NewStateDelta()
+		for i := 0; i < len(kvs); i += 2 {
+			d.Set("mycc", kvs[i], []byte(kvs[i+1]), nil)
+		}
+		return d
+	}
+
+	report := compareRuns([][]byte{[]byte("ok"), []byte("ok")}, []*statemgmt.StateDelta{delta("a", "1", "b", "2"), delta("a", "1", "b", "2")}, nil)
+	if !report.Deterministic || report.DivergentResults || len(report.DivergentKeys) != 0 {
+		t.Fatalf("Expected identical runs to be deterministic: %v", report)
+	}
+	if report.StateDeltaHashes[0] != report.StateDeltaHashes[1] || report.StateDeltaHashes[0] == "" {
+		t.Fatalf("Expected identical non-empty hashes: %v", report.StateDeltaHashes)
+	}
+
+	report = compareRuns([][]byte{[]byte("ok"), []byte("ok")}, []*statemgmt.StateDelta{delta("a", "1", "b", "2"), delta("a", "1", "b", "3", "c", "4")}, nil)
+	if report.Deterministic || !reflect.DeepEqual(report.DivergentKeys, []string{"mycc/b", "mycc/c"}) {
+		t.Fatalf("Expected keys b and c to diverge: %v", report)
+	}
+
+	report = compareRuns([][]byte{[]byte("1"), []byte("2")}, []*statemgmt.StateDelta{delta(), delta()}, nil)
+	if report.Deterministic || !report.DivergentResults {
+		t.Fatalf("Expected results to diverge: %v", report)
+	}
+
+	report = compareRuns([][]byte{[]byte("ok")}, []*statemgmt.StateDelta{delta()}, []string{"failed"})
+	if report.Deterministic {
+		t.Fatalf("Expected a run failing when another succeeds to be non-deterministic: %v", report)
+	}
+
+	report = compareRuns(nil, nil, []string{"failed", "failed"})
+	if !report.Deterministic {
+		t.Fatalf("Expected runs failing alike to be deterministic: %v", report)
+	}
+}
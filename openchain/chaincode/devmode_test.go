@@ -0,0 +1,85 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func newDevModeSupport() *ChaincodeSupport {
+	return &ChaincodeSupport{handlerMap: &handlerMap{chaincodeMap: make(map[string]*Handler)}, userRunsCC: true, devAttachTimeout: time.Second,
+		admission: newAdmissionControl(), writeSets: newTxWriteSets()}
+}
+
+func TestDevMode_Reattach(t *testing.T) {
+	s := newDevModeSupport()
+	first := &Handler{chaincodeSupport: s, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+	if err := s.registerHandler(first); err != nil {
+		t.Fatalf("Error registering chaincode: %s", err)
+	}
+
+	// the restarted process registers before the first stream is closed
+	second := &Handler{chaincodeSupport: s, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+	if err := s.registerHandler(second); err != nil {
+		t.Fatalf("Error re-attaching chaincode: %s", err)
+	}
+	if err := s.deregisterHandler(first); err != nil {
+		t.Fatalf("Error deregistering replaced handler: %s", err)
+	}
+	if handler, _ := s.chaincodeHasBeenLaunched("mycc"); handler != second {
+		t.Fatal("Expected the re-attached handler to stay registered")
+	}
+
+	s.userRunsCC = false
+	third := &Handler{chaincodeSupport: s, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+	if _, ok := s.registerHandler(third).(*DuplicateChaincodeHandlerError); !ok {
+		t.Fatal("Expected a duplicate chaincode error outside dev mode")
+	}
+}
+
+func TestDevMode_WaitForAttach(t *testing.T) {
+	s := newDevModeSupport()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		handler := &Handler{chaincodeSupport: s, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}
+		if err := s.registerHandler(handler); err != nil {
+			t.Errorf("Error registering chaincode: %s", err)
+			return
+		}
+		handler.notifyDuringStartup(true)
+	}()
+	if err := s.waitForAttach("mycc", "tx1"); err != nil {
+		t.Fatalf("Error waiting for chaincode: %s", err)
+	}
+	if handler, _ := s.chaincodeHasBeenLaunched("mycc"); handler == nil || !handler.registered {
+		t.Fatal("Expected the chaincode to be registered")
+	}
+
+	s.devAttachTimeout = 10 * time.Millisecond
+	if err := s.waitForAttach("othercc", "tx2"); err == nil {
+		t.Fatal("Expected a timeout waiting for a chaincode which is not started")
+	}
+	if _, ok := s.chaincodeHasBeenLaunched("othercc"); ok {
+		t.Fatal("Expected the placeholder of the chaincode to be removed after the timeout")
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/events/producer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// chaincodeEvents buffers the events set by the transactions in progress.
+// The events of a successful transaction are kept until the block of the
+// transaction commits, those of a failed one are dropped.
+type chaincodeEvents struct {
+	sync.Mutex
+	max     int
+	pending map[string][]*pb.ChaincodeEvent
+	queued  map[string][]*pb.ChaincodeEvent
+}
+
+func newChaincodeEvents() *chaincodeEvents {
+	return &chaincodeEvents{max: viper.GetInt("chaincode.events.maxPerTransaction"),
+		pending: make(map[string][]*pb.ChaincodeEvent), queued: make(map[string][]*pb.ChaincodeEvent)}
+}
+
+func (c *chaincodeEvents) begin(uuid string) {
+	c.Lock()
+	defer c.Unlock()
+	c.pending[uuid] = nil
+}
+
+// add records an event set by a transaction in progress
+func (c *chaincodeEvents) add(uuid string, event *pb.ChaincodeEvent) error {
+	c.Lock()
+	defer c.Unlock()
+	events, ok := c.pending[uuid]
+	if !ok {
+		return fmt.Errorf("[%s]No transaction in progress", shortuuid(uuid))
+	}
+	if c.max > 0 && len(events) >= c.max {
+		return fmt.Errorf("[%s]Cannot set more than %d events", shortuuid(uuid), c.max)
+	}
+	c.pending[uuid] = append(events, event)
+	return nil
+}
+
+// finish queues the events set by a successful transaction, or drops them
+// if it failed. It returns the events queued.
+func (c *chaincodeEvents) finish(uuid string, successful bool) []*pb.ChaincodeEvent {
+	c.Lock()
+	defer c.Unlock()
+	events := c.pending[uuid]
+	delete(c.pending, uuid)
+	if successful && len(events) > 0 {
+		c.queued[uuid] = events
+		return events
+	}
+	return nil
+}
+
+// take removes and returns the queued events of the given transactions in
+// order. If send is false they are dropped, as when their block is rolled
+// back.
+func (c *chaincodeEvents) take(uuids []string, send bool) []*pb.ChaincodeEvent {
+	c.Lock()
+	defer c.Unlock()
+	var events []*pb.ChaincodeEvent
+	for _, uuid := range uuids {
+		if send {
+			events = append(events, c.queued[uuid]...)
+		}
+		delete(c.queued, uuid)
+	}
+	return events
+}
+
+// SendChaincodeEvents sends the events set by the given committed
+// transactions to the event consumers. They are no longer kept by the chain.
+func SendChaincodeEvents(cname ChainName, uuids []string) {
+	chain := GetChain(cname)
+	if chain == nil {
+		return
+	}
+	for _, event := range chain.chaincodeEvents.take(uuids, true) {
+		if err := producer.Send(producer.CreateChaincodeEvent(event)); err != nil {
+			chaincodeLogger.Debug("[%s]Failed to send chaincode event %s: %s", shortuuid(event.TxUuid), event.EventName, err)
+		}
+	}
+}
+
+// DiscardChaincodeEvents drops the events set by the given transactions,
+// whose block was not committed
+func DiscardChaincodeEvents(cname ChainName, uuids []string) {
+	if chain := GetChain(cname); chain != nil {
+		chain.chaincodeEvents.take(uuids, false)
+	}
+}
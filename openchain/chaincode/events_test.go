@@ -0,0 +1,68 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestChaincodeEvents(t *testing.T) {
+	c := &chaincodeEvents{max: 2, pending: make(map[string][]*pb.ChaincodeEvent), queued: make(map[string][]*pb.ChaincodeEvent)}
+	if err := c.add("tx1", &pb.ChaincodeEvent{EventName: "transfer"}); err == nil {
+		t.Fatalf("Expected error setting event outside of a transaction")
+	}
+
+	c.begin("tx1")
+	c.begin("tx2")
+	for _, name := range []string{"mint", "transfer"} {
+		if err := c.add("tx1", &pb.ChaincodeEvent{EventName: name, TxUuid: "tx1"}); err != nil {
+			t.Fatalf("Error setting event: %s", err)
+		}
+	}
+	if err := c.add("tx1", &pb.ChaincodeEvent{EventName: "burn"}); err == nil {
+		t.Fatalf("Expected error setting more events than allowed")
+	}
+	if err := c.add("tx2", &pb.ChaincodeEvent{EventName: "transfer", TxUuid: "tx2"}); err != nil {
+		t.Fatalf("Error setting event: %s", err)
+	}
+	c.finish("tx1", true)
+	c.finish("tx2", false)
+
+	events := c.take([]string{"tx1", "tx2"}, true)
+	if len(events) != 2 {
+		t.Fatalf("Expected the 2 events of the successful transaction, got %d", len(events))
+	}
+	if events[0].EventName != "mint" || events[1].EventName != "transfer" {
+		t.Fatalf("Expected events in the order they were set, got %s and %s", events[0].EventName, events[1].EventName)
+	}
+	if events := c.take([]string{"tx1"}, true); len(events) != 0 {
+		t.Fatalf("Expected events to be sent once, got %d more", len(events))
+	}
+
+	// Events of a rolled back block are dropped
+	c.begin("tx3")
+	c.add("tx3", &pb.ChaincodeEvent{EventName: "transfer"})
+	c.finish("tx3", true)
+	c.take([]string{"tx3"}, false)
+	if events := c.take([]string{"tx3"}, true); len(events) != 0 {
+		t.Fatalf("Expected events of a rolled back block to be dropped, got %d", len(events))
+	}
+}
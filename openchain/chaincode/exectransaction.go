@@ -22,12 +22,19 @@ package chaincode
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
 
+	"github.com/openblockchain/obc-peer/events/producer"
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
 	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/tracing"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -35,13 +42,21 @@ import (
 func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, error) {
 	var err error
 
-	// get a handle to ledger to mark the begin/finish of a tx
-	ledger, ledgerErr := ledger.GetLedger()
+	span := tracing.StartSpan(t.Uuid, "chaincode.execute")
+	span.Tag("tx.type", t.Type.String())
+	defer span.Finish()
+
+	// get a handle to the ledger of the chain of the tx to mark the begin/finish of a tx
+	ledger, ledgerErr := ledger.GetChainLedger(t.ChainID)
 	if ledgerErr != nil {
 		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 	}
 
-	if secHelper := chain.getSecHelper(); nil != secHelper {
+	secHelper := chain.getSecHelper()
+	if err := checkConfigSubmitter(secHelper, t); err != nil {
+		return nil, err
+	}
+	if nil != secHelper {
 		var err error
 		t, err = secHelper.TransactionPreExecution(t)
 		// Note that t is now decrypted and is a deep clone of the original input t
@@ -51,20 +66,28 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 	}
 
 	if t.Type == pb.Transaction_CHAINCODE_NEW {
+		SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_BUILDING, "")
 		_, err := chain.DeployChaincode(ctxt, t)
 		if err != nil {
+			SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_FAILED, err.Error())
 			return nil, fmt.Errorf("Failed to deploy chaincode spec(%s)", err)
 		}
 
 		//launch and wait for ready
-		markTxBegin(ledger, t)
+		SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_LAUNCHING, "")
+		markTxBegin(chain, t)
 		_, _, err = chain.LaunchChaincode(ctxt, t)
 		if err != nil {
-			markTxFinish(ledger, t, false)
+			markTxFinish(ledger, chain, t, false)
+			SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_FAILED, err.Error())
 			return nil, fmt.Errorf("%s", err)
 		}
-		markTxFinish(ledger, t, true)
-	} else if t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_QUERY {
+		if err = markTxFinish(ledger, chain, t, true); err != nil {
+			SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_FAILED, err.Error())
+			return nil, fmt.Errorf("Failed to commit state changes(%s)", err)
+		}
+		SetDeploymentStatus(t.Uuid, pb.DeploymentStatus_READY, "")
+	} else if t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_QUERY || t.Type == pb.Transaction_CHAINCODE_CONFIG {
 		//will launch if necessary (and wait for ready)
 		cID, cMsg, err := chain.LaunchChaincode(ctxt, t)
 		if err != nil {
@@ -74,20 +97,24 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 		//this should work because it worked above...
 		chaincode := cID.Name
 
+		if err = checkConfigTransaction(t, chaincode); err != nil {
+			return nil, err
+		}
+
 		if err != nil {
 			return nil, fmt.Errorf("Failed to stablish stream to container %s", chaincode)
 		}
 
 		// TODO: Need to comment next line and uncomment call to getTimeout, when transaction blocks are being created
 		timeout := time.Duration(30000) * time.Millisecond
-		//timeout, err := getTimeout(cID)
+		//timeout, err := getTimeout(t.ChainID, cID)
 
 		if err != nil {
 			return nil, fmt.Errorf("Failed to retrieve chaincode spec(%s)", err)
 		}
 
 		var ccMsg *pb.ChaincodeMessage
-		if t.Type == pb.Transaction_CHAINCODE_EXECUTE {
+		if t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_CONFIG {
 			ccMsg, err = createTransactionMessage(t.Uuid, cMsg)
 			if err != nil {
 				return nil, fmt.Errorf("Failed to transaction message(%s)", err)
@@ -99,37 +126,121 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 			}
 		}
 
-		markTxBegin(ledger, t)
+		markTxBegin(chain, t)
+		if err = declareStateKeys(chain, t); err != nil {
+			markTxFinish(ledger, chain, t, false)
+			return nil, fmt.Errorf("Failed to lock declared state keys(%s)", err)
+		}
+		if err = chain.checkNonce(ledger, t); err != nil {
+			markTxFinish(ledger, chain, t, false)
+			return nil, fmt.Errorf("Refused invocation(%s)", err)
+		}
 		resp, err := chain.Execute(ctxt, chaincode, ccMsg, timeout, t)
 		if err != nil {
 			// Rollback transaction
-			markTxFinish(ledger, t, false)
+			markTxFinish(ledger, chain, t, false)
 			return nil, fmt.Errorf("Failed to execute transaction or query(%s)", err)
 		} else if resp == nil {
 			// Rollback transaction
-			markTxFinish(ledger, t, false)
+			markTxFinish(ledger, chain, t, false)
 			return nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
 		} else {
 			if resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
 				// Success
-				markTxFinish(ledger, t, true)
+				if err = markTxFinish(ledger, chain, t, true); err != nil {
+					return nil, fmt.Errorf("Failed to commit state changes(%s)", err)
+				}
 				return resp.Payload, nil
 			} else if resp.Type == pb.ChaincodeMessage_ERROR || resp.Type == pb.ChaincodeMessage_QUERY_ERROR {
 				// Rollback transaction
-				markTxFinish(ledger, t, false)
+				markTxFinish(ledger, chain, t, false)
 				return nil, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
 			}
-			markTxFinish(ledger, t, false)
+			markTxFinish(ledger, chain, t, false)
 			return resp.Payload, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
 		}
 
+	} else if t.Type == pb.Transaction_CHAINCODE_KEY_ROTATION {
+		markTxBegin(chain, t)
+		if err = rotateStateKey(chain, t); err != nil {
+			markTxFinish(ledger, chain, t, false)
+			return nil, fmt.Errorf("Failed to rotate state key(%s)", err)
+		}
+		if err = markTxFinish(ledger, chain, t, true); err != nil {
+			return nil, fmt.Errorf("Failed to commit state changes(%s)", err)
+		}
+		return nil, nil
 	} else {
 		err = fmt.Errorf("Invalid transaction type %s", t.Type.String())
 	}
 	return nil, err
 }
 
-//ExecuteTransactions - will execute transactions on the array one by one
+// Simulate executes the invoke transaction without keeping the state changes
+// it makes. It returns the result of the transaction and the hash of its
+// state changes, which validators executing the same transaction on the same
+// state agree on unless the chaincode is not deterministic.
+func Simulate(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, []byte, error) {
+	result, delta, err := simulate(ctxt, chain, t, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, hashStateDelta(delta), nil
+}
+
+//hashStateDelta hashes the state changes of a transaction the way the ledger
+//does for committed transactions
+func hashStateDelta(delta *statemgmt.StateDelta) []byte {
+	if delta.IsEmpty() {
+		return nil
+	}
+	return delta.ComputeCryptoHash()
+}
+
+//simulate executes t without keeping its state changes. determinismRun is
+//the number of the run of a determinism check the execution is part of, 0
+//outside of one.
+func simulate(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction, determinismRun uint32) ([]byte, *statemgmt.StateDelta, error) {
+	if t.Type != pb.Transaction_CHAINCODE_EXECUTE {
+		return nil, nil, fmt.Errorf("Cannot simulate transaction of type %s", t.Type)
+	}
+
+	if secHelper := chain.getSecHelper(); nil != secHelper {
+		var err error
+		t, err = secHelper.TransactionPreExecution(t)
+		if nil != err {
+			return nil, nil, err
+		}
+	}
+
+	cID, cMsg, err := chain.LaunchChaincode(ctxt, t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to launch chaincode spec(%s)", err)
+	}
+	ccMsg, err := createTransactionMessage(t.Uuid, cMsg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to transaction message(%s)", err)
+	}
+	ccMsg.DeterminismRun = determinismRun
+
+	timeout := chain.simulateTimeout
+	markTxBeginSimulation(chain, t)
+	resp, err := chain.Execute(ctxt, cID.Name, ccMsg, timeout, t)
+	delta, limitErr := markTxFinishSimulation(chain, t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to execute transaction(%s)", err)
+	} else if resp == nil {
+		return nil, nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
+	} else if resp.Type != pb.ChaincodeMessage_COMPLETED {
+		return nil, nil, fmt.Errorf("Transaction returned with failure: %s", string(resp.Payload))
+	} else if limitErr != nil {
+		return nil, nil, limitErr
+	}
+	return resp.Payload, delta, nil
+}
+
+//ExecuteTransactions - will execute transactions on the array in order,
+//concurrently for transactions declaring disjoint keys when key hints are strict
 //will return an array of errors one for each transaction. If the execution
 //succeeded, array element will be nil. returns state hash
 func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) ([]byte, []error) {
@@ -139,8 +250,32 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 		panic(fmt.Sprintf("[ExecuteTransactions]Chain %s not found\n", cname))
 	}
 	errs := make([]error, len(xacts)+1)
+	deps := chain.scheduleTransactions(xacts)
+	done := make([]chan struct{}, len(xacts))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
 	for i, t := range xacts {
-		_, errs[i] = Execute(ctxt, chain, t)
+		wg.Add(1)
+		go func(i int, t *pb.Transaction) {
+			defer wg.Done()
+			defer close(done[i])
+			for _, j := range deps[i] {
+				<-done[j]
+			}
+			var res []byte
+			res, errs[i] = Execute(ctxt, chain, t)
+			if errs[i] != nil {
+				sendProducerRejectionEvent(t, errs[i])
+			} else if t.Type != pb.Transaction_CHAINCODE_QUERY {
+				chain.txResults.respond(t.Uuid, res)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+	if err := chain.pruneNonces(xacts, errs); err != nil {
+		chaincodeLogger.Error("Failed to prune the nonces of the invocations: %s", err)
 	}
 	ledger, hasherr := ledger.GetLedger()
 	var statehash []byte
@@ -167,8 +302,8 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 
 var errFailedToGetChainCodeSpecForTransaction = errors.New("Failed to get ChainCodeSpec from Transaction")
 
-func getTimeout(cID *pb.ChaincodeID) (time.Duration, error) {
-	ledger, err := ledger.GetLedger()
+func getTimeout(chainID string, cID *pb.ChaincodeID) (time.Duration, error) {
+	ledger, err := ledger.GetChainLedger(chainID)
 	if err == nil {
 		chaincodeID := cID.Name
 		txUUID, err := ledger.GetState(chaincodeID, "github.com_openblockchain_obc-peer_chaincode_id", true)
@@ -187,16 +322,121 @@ func getTimeout(cID *pb.ChaincodeID) (time.Duration, error) {
 	return -1, errFailedToGetChainCodeSpecForTransaction
 }
 
-func markTxBegin(ledger *ledger.Ledger, t *pb.Transaction) {
+//checkConfigTransaction makes sure network parameters are only changed by
+//configuration transactions, and that those only invoke the netconfig
+//system chaincode
+func checkConfigTransaction(t *pb.Transaction, chaincode string) error {
+	if t.Type == pb.Transaction_CHAINCODE_CONFIG && chaincode != netconfig.Name {
+		return fmt.Errorf("Configuration transaction %s invokes %s instead of %s", t.Uuid, chaincode, netconfig.Name)
+	}
+	if t.Type == pb.Transaction_CHAINCODE_EXECUTE && chaincode == netconfig.Name {
+		return fmt.Errorf("%s can only be invoked by configuration transactions", netconfig.Name)
+	}
+	return nil
+}
+
+//checkConfigSubmitter makes sure configuration transactions are submitted by
+//a network administrator, that is signed with an enrollment certificate
+//issued by the ECA holding the ADMIN role. Without security there are no
+//identities to check and any peer may change network parameters.
+func checkConfigSubmitter(secHelper crypto.Peer, t *pb.Transaction) error {
+	if t.Type != pb.Transaction_CHAINCODE_CONFIG || secHelper == nil {
+		return nil
+	}
+	raw, err := crypto.GetTransactionSigningBytes(t)
+	if err != nil {
+		return err
+	}
+	if err := secHelper.VerifyEnrollmentSignature(t.Cert, t.Signature, raw); err != nil {
+		return fmt.Errorf("Configuration transaction %s is not signed with an enrollment certificate (%s)", t.Uuid, err)
+	}
+	id, role, err := crypto.ParseEnrollmentCertificate(t.Cert)
+	if err != nil {
+		return err
+	}
+	if role&int32(obcca.Role_ADMIN) == 0 {
+		return fmt.Errorf("Configuration transaction %s rejected, %s does not hold the ADMIN role", t.Uuid, id)
+	}
+	return nil
+}
+
+// sendProducerRejectionEvent notifies event consumers that a transaction
+// failed to execute so clients waiting on it do not have to time out
+func sendProducerRejectionEvent(t *pb.Transaction, err error) {
+	// Strip the payload, consumers only need the identity of the transaction
+	// and deploy payloads carry the entire code package
+	rejected := &pb.Transaction{Type: t.Type, ChaincodeID: t.ChaincodeID, Uuid: t.Uuid, Timestamp: t.Timestamp}
+	if sendErr := producer.Send(producer.CreateRejectionEvent(rejected, err.Error())); sendErr != nil {
+		chaincodeLogger.Debug("[%s]Failed to send rejection event: %s", shortuuid(t.Uuid), sendErr)
+	}
+}
+
+func markTxBegin(chain *ChaincodeSupport, t *pb.Transaction) {
 	if t.Type == pb.Transaction_CHAINCODE_QUERY {
 		return
 	}
-	ledger.TxBegin(t.Uuid)
+	chain.writeSets.begin(t.Uuid)
+	chain.keyLocks.begin(t.Uuid)
+	chain.deferredCalls.begin(t.Uuid)
+	chain.chaincodeEvents.begin(t.Uuid)
+	chain.meters.begin(t.Uuid)
 }
 
-func markTxFinish(ledger *ledger.Ledger, t *pb.Transaction, successful bool) {
+// markTxFinish applies the state changes of the transaction to the ledger if
+// it was successful and within the resource limits, queues the invocations it
+// enqueued and the events it set, records them for its result and releases
+// the keys it locked
+func markTxFinish(ledger *ledger.Ledger, chain *ChaincodeSupport, t *pb.Transaction, successful bool) error {
 	if t.Type == pb.Transaction_CHAINCODE_QUERY {
-		return
+		return nil
+	}
+	defer chain.keyLocks.unlock(t.Uuid)
+	defer chain.keyHints.finish(t.Uuid)
+	delta := chain.writeSets.finish(t.Uuid)
+	err := chain.meters.finish(t.Uuid, true)
+	if successful && err == nil && delta != nil {
+		err = commitTxState(ledger, t.Uuid, delta)
+	}
+	successful = successful && err == nil
+	chain.deferredCalls.finish(t.Uuid, successful)
+	events := chain.chaincodeEvents.finish(t.Uuid, successful)
+	if successful {
+		chain.txResults.finish(t.Uuid, delta, events)
+	}
+	return err
+}
+
+// markTxBeginSimulation starts buffering the state changes of a simulated
+// transaction. Simulations do not lock keys as their changes are discarded,
+// but they are held to the keys they declare like executed transactions.
+func markTxBeginSimulation(chain *ChaincodeSupport, t *pb.Transaction) {
+	chain.writeSets.beginSimulation(t.Uuid)
+	chain.deferredCalls.begin(t.Uuid)
+	chain.chaincodeEvents.begin(t.Uuid)
+	chain.meters.begin(t.Uuid)
+	if names, err := transactionKeyHints(t); err == nil {
+		chain.keyHints.declare(t.Uuid, names)
+	}
+}
+
+// markTxFinishSimulation returns the state changes of a simulated transaction
+// and the error of the resource limit it exceeded, if any
+func markTxFinishSimulation(chain *ChaincodeSupport, t *pb.Transaction) (*statemgmt.StateDelta, error) {
+	chain.keyHints.finish(t.Uuid)
+	chain.deferredCalls.finish(t.Uuid, false)
+	chain.chaincodeEvents.finish(t.Uuid, false)
+	limitErr := chain.meters.finish(t.Uuid, false)
+	return chain.writeSets.finish(t.Uuid), limitErr
+}
+
+// declareStateKeys records and locks the state keys declared by an invoke
+// transaction before it runs, so that it does not conflict with concurrent
+// transactions while it runs
+func declareStateKeys(chain *ChaincodeSupport, t *pb.Transaction) error {
+	names, err := transactionKeyHints(t)
+	if err != nil || len(names) == 0 {
+		return err
 	}
-	ledger.TxFinished(t.Uuid, successful)
+	chain.keyHints.declare(t.Uuid, names)
+	return chain.keyLocks.lock(t.Uuid, names...)
 }
@@ -28,23 +28,22 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/looplab/fsm"
 	"github.com/op/go-logging"
+	"github.com/openblockchain/obc-peer/openchain/crosschain"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/fault"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
 	"golang.org/x/net/context"
 
-	"github.com/openblockchain/obc-peer/openchain/ledger"
 )
 
 const (
 	createdstate     = "created"     //start state
 	establishedstate = "established" //in: CREATED, rcv:  REGISTER, send: REGISTERED, INIT
 	initstate        = "init"        //in:ESTABLISHED, rcv:-, send: INIT
-	readystate       = "ready"       //in:ESTABLISHED,TRANSACTION, rcv:COMPLETED
-	transactionstate = "transaction" //in:READY, rcv: xact from consensus, send: TRANSACTION
-	busyinitstate    = "busyinit"    //in:INIT, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE
-	busyxactstate    = "busyxact"    //in:TRANSACION, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE
+	readystate       = "ready"       //in:ESTABLISHED,INIT, send: TRANSACTION, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE, ENQUEUE_CHAINCODE, SET_EVENT, COMPLETED
+	busyinitstate    = "busyinit"    //in:INIT, rcv: PUT_STATE, DEL_STATE, INVOKE_CHAINCODE, ENQUEUE_CHAINCODE, SET_EVENT
 	endstate         = "end"         //in:INIT,ESTABLISHED, rcv: error, terminate container
 
 )
@@ -64,10 +63,17 @@ type MessageHandler interface {
 }
 
 type transactionContext struct {
+	// ctxt is the context of the execution, the executions of the
+	// chaincodes it invokes are cancelled with it
+	ctxt                  context.Context
 	transactionSecContext *pb.Transaction
 	responseNotifier      chan *pb.ChaincodeMessage
+	// state is the view of the state the execution reads
+	state stateView
 
-	// tracks open iterators used for range queries
+	// tracks open iterators used for range queries. Each holds a snapshot of
+	// the state taken when the query started, so that the pages returned by
+	// RANGE_QUERY_STATE_NEXT are consistent. Closing it releases the snapshot.
 	rangeQueryIteratorMap map[string]statemgmt.RangeScanIterator
 }
 
@@ -85,6 +91,16 @@ type Handler struct {
 
 	// A copy of decrypted deploy tx this handler manages, no code
 	deployTXSecContext *pb.Transaction
+	// How the keys of the state of the chaincode are stored, as requested at deployment
+	keyEncryption pb.ChaincodeSpec_KeyEncryption
+	// Signatures invocations must carry, as requested at deployment
+	signaturePolicy *pb.SignaturePolicy
+	// How the chaincode was deployed, returned on GET_DEPLOYMENT_INFO
+	deploymentInfo *pb.DeploymentInfo
+	// Version of the protocol spoken with the chaincode, selected at REGISTER
+	protocolVersion uint32
+	// Deprecated message types the chaincode was warned about
+	deprecationsReported map[pb.ChaincodeMessage_Type]bool
 
 	chaincodeSupport *ChaincodeSupport
 	registered       bool
@@ -98,8 +114,14 @@ type Handler struct {
 	// Track which UUIDs are queries; Although the shim maintains this, it cannot be trusted.
 	isTransaction map[string]bool
 
+	// Values being received in PUT_STATE_PART messages, by UUID
+	partialValues map[string]*partialValue
+
 	// used to do Send after making sure the state transition is complete
 	nextState chan *nextStateInfo
+
+	// Messages waiting to be sent to the chaincode
+	sendQueue *sendQueue
 }
 
 func shortuuid(uuid string) string {
@@ -109,17 +131,66 @@ func shortuuid(uuid string) string {
 	return uuid[0:8]
 }
 
-func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
+// chainID returns the ID of the chain the chaincode of the handler is
+// deployed on. Chaincodes deployed on several chains run once per chain.
+func (handler *Handler) chainID() string {
+	if handler.deployTXSecContext == nil {
+		return ""
+	}
+	return handler.deployTXSecContext.ChainID
+}
+
+// speaks returns whether messages of type msgType are part of the version of
+// the protocol spoken with the chaincode
+func (handler *Handler) speaks(msgType pb.ChaincodeMessage_Type) bool {
+	return msgType.ProtocolVersion() <= handler.protocolVersion
+}
+
+// reportDeprecation warns about the use of msg by the chaincode if its type
+// is being phased out
+func (handler *Handler) reportDeprecation(msg *pb.ChaincodeMessage) {
+	if instead := msg.Type.Deprecation(); instead != "" {
+		handler.warnDeprecated(msg, instead)
+	}
+}
+
+// warnDeprecated warns about the use of the deprecated msg, telling what to
+// use instead, in the log of the peer and, if the chaincode speaks the
+// version of the protocol with DEPRECATION_WARNING, to the chaincode. Each
+// deprecated type is reported once per chaincode.
+func (handler *Handler) warnDeprecated(msg *pb.ChaincodeMessage, instead string) {
 	handler.Lock()
-	defer handler.Unlock()
-	if err := handler.ChatStream.Send(msg); err != nil {
-		chaincodeLog.Error(fmt.Sprintf("Error sending %s: %s", msg.Type.String(), err))
-		return fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
+	reported := handler.deprecationsReported[msg.Type]
+	if !reported {
+		if handler.deprecationsReported == nil {
+			handler.deprecationsReported = make(map[pb.ChaincodeMessage_Type]bool)
+		}
+		handler.deprecationsReported[msg.Type] = true
+	}
+	handler.Unlock()
+	if reported {
+		return
+	}
+	name := ""
+	if handler.ChaincodeID != nil {
+		name = handler.ChaincodeID.Name
+	}
+	warning := fmt.Sprintf("%s is deprecated and will be removed from the chaincode protocol, %s", msg.Type, instead)
+	chaincodeLogger.Warning("[%s]Chaincode %s uses a deprecated message: %s", shortuuid(msg.Uuid), name, warning)
+	if handler.speaks(pb.ChaincodeMessage_DEPRECATION_WARNING) {
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_DEPRECATION_WARNING, Payload: []byte(warning), Uuid: msg.Uuid})
 	}
-	return nil
 }
 
-func (handler *Handler) createTxContext(uuid string, tx *pb.Transaction) (*transactionContext, error) {
+// serialSend queues msg to be sent to the chaincode. Messages are sent in
+// the order they are queued. It blocks while the queue is full and returns
+// ErrSendQueueFull if the chaincode does not catch up in time.
+func (handler *Handler) serialSend(msg *pb.ChaincodeMessage) error {
+	handler.traceMessage(traceToChaincode, msg)
+	return handler.sendQueue.send(msg)
+}
+
+func (handler *Handler) createTxContext(ctxt context.Context, uuid string, tx *pb.Transaction, query bool) (*transactionContext, error) {
 	if handler.txCtxs == nil {
 		return nil, fmt.Errorf("cannot create notifier for Uuid:%s", uuid)
 	}
@@ -128,8 +199,8 @@ func (handler *Handler) createTxContext(uuid string, tx *pb.Transaction) (*trans
 	if handler.txCtxs[uuid] != nil {
 		return nil, fmt.Errorf("Uuid:%s exists", uuid)
 	}
-	txctx := &transactionContext{transactionSecContext: tx, responseNotifier: make(chan *pb.ChaincodeMessage, 1),
-		rangeQueryIteratorMap: make(map[string]statemgmt.RangeScanIterator)}
+	txctx := &transactionContext{ctxt: ctxt, transactionSecContext: tx, responseNotifier: make(chan *pb.ChaincodeMessage, 1),
+		state: newStateView(handler.chaincodeSupport, handler.chainID(), uuid, query), rangeQueryIteratorMap: make(map[string]statemgmt.RangeScanIterator)}
 	handler.txCtxs[uuid] = txctx
 	return txctx, nil
 }
@@ -143,9 +214,78 @@ func (handler *Handler) getTxContext(uuid string) *transactionContext {
 func (handler *Handler) deleteTxContext(uuid string) {
 	handler.Lock()
 	defer handler.Unlock()
-	if handler.txCtxs != nil {
+	if txctx := handler.txCtxs[uuid]; txctx != nil {
+		txctx.state.release()
 		delete(handler.txCtxs, uuid)
 	}
+	delete(handler.partialValues, uuid)
+}
+
+// abortTxContext frees the context of a transaction or query the peer no
+// longer waits for, closing its range query iterators, and tells the
+// chaincode to abort it
+func (handler *Handler) abortTxContext(uuid string, reason error) {
+	handler.Lock()
+	txctx := handler.txCtxs[uuid]
+	delete(handler.txCtxs, uuid)
+	delete(handler.partialValues, uuid)
+	handler.Unlock()
+	if txctx == nil {
+		return
+	}
+	for _, v := range txctx.rangeQueryIteratorMap {
+		v.Close()
+	}
+	txctx.state.release()
+	chaincodeLogger.Debug("[%s]Aborting execution: %s", shortuuid(uuid), reason)
+	if !handler.speaks(pb.ChaincodeMessage_TX_ABORT) {
+		// The chaincode cannot be told, its requests are refused once the context is gone
+		return
+	}
+	if err := handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_TX_ABORT, Payload: []byte(reason.Error()), Uuid: uuid}); err != nil {
+		chaincodeLogger.Warning("[%s]Error sending %s: %s", shortuuid(uuid), pb.ChaincodeMessage_TX_ABORT, err)
+	}
+}
+
+// failTxContexts fails the transactions and queries still running when the
+// stream of the chaincode ends, eg. because its container crashed, rather
+// than leaving them to wait for their timeout. Their range query iterators
+// are closed, and no more executions can start on the handler.
+func (handler *Handler) failTxContexts(reason error) {
+	handler.Lock()
+	txCtxs := handler.txCtxs
+	handler.txCtxs = nil
+	handler.Unlock()
+	if len(txCtxs) == 0 {
+		return
+	}
+
+	chaincode := ""
+	if handler.ChaincodeID != nil {
+		chaincode = handler.ChaincodeID.Name
+	}
+	payload := []byte(fmt.Sprintf("Chaincode %s stopped during execution, its container may have crashed: %v", chaincode, reason))
+	for uuid, txctx := range txCtxs {
+		for _, v := range txctx.rangeQueryIteratorMap {
+			v.Close()
+		}
+		txctx.state.release()
+		select {
+		case txctx.responseNotifier <- &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: uuid}:
+		default:
+			// The execution already got its response
+		}
+	}
+	chaincodeLog.Warning("Failed %d executions of chaincode %s whose stream ended: %v", len(txCtxs), chaincode, reason)
+}
+
+// executionContext returns the context of the execution of a transaction or
+// query, for the chaincodes it invokes
+func (handler *Handler) executionContext(uuid string) context.Context {
+	if txctx := handler.getTxContext(uuid); txctx != nil && txctx.ctxt != nil {
+		return txctx.ctxt
+	}
+	return context.Background()
 }
 
 func (handler *Handler) putRangeQueryIterator(txContext *transactionContext, uuid string,
@@ -167,10 +307,12 @@ func (handler *Handler) deleteRangeQueryIterator(txContext *transactionContext,
 	delete(txContext.rangeQueryIteratorMap, uuid)
 }
 
-func (handler *Handler) encryptOrDecrypt(encrypt bool, uuid string, payload []byte) ([]byte, error) {
+// getStateEncryptor returns the encryptor of the state of the chaincode for a
+// transaction, or nil if security is disabled
+func (handler *Handler) getStateEncryptor(uuid string) (crypto.StateEncryptor, error) {
 	secHelper := handler.chaincodeSupport.getSecHelper()
 	if secHelper == nil {
-		return payload, nil
+		return nil, nil
 	}
 
 	txctx := handler.getTxContext(uuid)
@@ -187,8 +329,12 @@ func (handler *Handler) encryptOrDecrypt(encrypt bool, uuid string, payload []by
 		if enc, err = secHelper.GetStateEncryptor(handler.deployTXSecContext, handler.deployTXSecContext); err != nil {
 			return nil, fmt.Errorf("error getting crypto encryptor for deploy tx :%s", err)
 		}
-	} else if txctx.transactionSecContext.Type == pb.Transaction_CHAINCODE_EXECUTE || txctx.transactionSecContext.Type == pb.Transaction_CHAINCODE_QUERY {
-		if enc, err = secHelper.GetStateEncryptor(handler.deployTXSecContext, txctx.transactionSecContext); err != nil {
+	} else if txctx.transactionSecContext.Type == pb.Transaction_CHAINCODE_EXECUTE || txctx.transactionSecContext.Type == pb.Transaction_CHAINCODE_QUERY || txctx.transactionSecContext.Type == pb.Transaction_CHAINCODE_CONFIG {
+		var rotationTxs []*pb.Transaction
+		if rotationTxs, err = handler.getKeyRotations(); err != nil {
+			return nil, fmt.Errorf("error getting key rotations :%s", err)
+		}
+		if enc, err = secHelper.GetStateEncryptor(handler.deployTXSecContext, txctx.transactionSecContext, rotationTxs...); err != nil {
 			return nil, fmt.Errorf("error getting crypto encryptor %s", err)
 		}
 	} else {
@@ -197,6 +343,17 @@ func (handler *Handler) encryptOrDecrypt(encrypt bool, uuid string, payload []by
 	if enc == nil {
 		return nil, fmt.Errorf("secure context returns nil encryptor for tx %s", uuid)
 	}
+	return enc, nil
+}
+
+func (handler *Handler) encryptOrDecrypt(encrypt bool, uuid string, payload []byte) ([]byte, error) {
+	enc, err := handler.getStateEncryptor(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return payload, nil
+	}
 	if chaincodeLogger.IsEnabledFor(logging.DEBUG) {
 		chaincodeLogger.Debug("[%s]Payload before encrypt/decrypt: %v", shortuuid(uuid), payload)
 	}
@@ -231,12 +388,15 @@ func (handler *Handler) triggerNextState(msg *pb.ChaincodeMessage, send bool) {
 	handler.nextState <- &nextStateInfo{msg, send}
 }
 
-func (handler *Handler) processStream() error {
+func (handler *Handler) processStream() (err error) {
 	defer handler.deregister()
+	defer handler.sendQueue.close()
+	defer func() {
+		handler.failTxContexts(err)
+	}()
 	msgAvail := make(chan *pb.ChaincodeMessage)
 	var nsInfo *nextStateInfo
 	var in *pb.ChaincodeMessage
-	var err error
 
 	//recv is used to spin Recv routine after previous received msg
 	//has been processed
@@ -268,6 +428,11 @@ func (handler *Handler) processStream() error {
 				return err
 			}
 			chaincodeLogger.Debug("[%s]Received message %s from shim", shortuuid(in.Uuid), in.Type.String())
+			if err = fault.Inject(pb.Fault_CHAINCODE_STREAM, handler.tracedName(in)); err != nil {
+				chaincodeLogger.Warning("[%s]Ending chaincode support stream: %s", shortuuid(in.Uuid), err)
+				return err
+			}
+			handler.traceMessage(traceFromChaincode, in)
 			if in.Type.String() == pb.ChaincodeMessage_ERROR.String() {
 				chaincodeLogger.Debug("Got error: %s", string(in.Payload))
 			}
@@ -290,7 +455,10 @@ func (handler *Handler) processStream() error {
 		}
 		if nsInfo != nil && nsInfo.sendToCC {
 			chaincodeLogger.Debug("[%s]sending state message %s", shortuuid(in.Uuid), in.Type.String())
-			if err = handler.serialSend(in); err != nil {
+			if err = handler.serialSend(in); err == ErrSendQueueFull {
+				// The chaincode is overloaded, fail the transaction rather than the stream
+				handler.notify(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: in.Uuid})
+			} else if err != nil {
 				chaincodeLogger.Debug("[%s]serial sending received error %s", shortuuid(in.Uuid), err)
 				return fmt.Errorf("[%s]serial sending received error %s", shortuuid(in.Uuid), err)
 			}
@@ -309,6 +477,7 @@ func HandleChaincodeStream(chaincodeSupport *ChaincodeSupport, stream pb.Chainco
 func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStream PeerChaincodeStream) *Handler {
 	v := &Handler{
 		ChatStream: peerChatStream,
+		sendQueue:  newSendQueue(peerChatStream),
 	}
 	v.chaincodeSupport = chaincodeSupport
 	//we want this to block
@@ -321,58 +490,61 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_REGISTER.String(), Src: []string{createdstate}, Dst: establishedstate},
 			{Name: pb.ChaincodeMessage_INIT.String(), Src: []string{establishedstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_READY.String(), Src: []string{establishedstate}, Dst: readystate},
-			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{readystate}, Dst: transactionstate},
-			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
-			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
-			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			// Transactions do not change the state so that any number of them can run at a time
+			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_ENQUEUE_CHAINCODE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_SET_EVENT.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{initstate}, Dst: busyinitstate},
-			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{initstate, readystate, transactionstate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_ENQUEUE_CHAINCODE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_SET_EVENT.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{initstate, readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
-			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
-			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_CHAIN_STATE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_CHAIN_STATE.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_CHAIN_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String(), Src: []string{transactionstate}, Dst: transactionstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{transactionstate}, Dst: transactionstate},
-			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{initstate}, Dst: endstate},
-			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{transactionstate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{busyinitstate}, Dst: initstate},
-			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{busyxactstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{busyinitstate}, Dst: initstate},
-			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{busyxactstate}, Dst: transactionstate},
 		},
 		fsm.Callbacks{
 			"before_" + pb.ChaincodeMessage_REGISTER.String():               func(e *fsm.Event) { v.beforeRegisterEvent(e, v.FSM.Current()) },
 			"before_" + pb.ChaincodeMessage_COMPLETED.String():              func(e *fsm.Event) { v.beforeCompletedEvent(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_COMPLETED.String():               func(e *fsm.Event) { v.afterCompletedOrError(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_ERROR.String():                   func(e *fsm.Event) { v.afterCompletedOrError(e, v.FSM.Current()) },
 			"before_" + pb.ChaincodeMessage_INIT.String():                   func(e *fsm.Event) { v.beforeInitState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_GET_STATE.String():               func(e *fsm.Event) { v.afterGetState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_CHAIN_STATE.String():         func(e *fsm.Event) { v.afterGetChainState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():       func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():  func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(): func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_PUT_STATE.String():               func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_DEL_STATE.String():               func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():        func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_ENQUEUE_CHAINCODE.String():       func(e *fsm.Event) { v.afterEnqueueChaincode(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_SET_EVENT.String():               func(e *fsm.Event) { v.afterSetEvent(e, v.FSM.Current()) },
 			"enter_" + establishedstate:                                     func(e *fsm.Event) { v.enterEstablishedState(e, v.FSM.Current()) },
 			"enter_" + initstate:                                            func(e *fsm.Event) { v.enterInitState(e, v.FSM.Current()) },
 			"enter_" + readystate:                                           func(e *fsm.Event) { v.enterReadyState(e, v.FSM.Current()) },
 			"enter_" + busyinitstate:                                        func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
-			"enter_" + busyxactstate:                                        func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
 			"enter_" + endstate:                                             func(e *fsm.Event) { v.enterEndState(e, v.FSM.Current()) },
+			"enter_state":                                                   func(e *fsm.Event) { v.traceTransition(e) },
 		},
 	)
 
@@ -457,6 +629,7 @@ func (handler *Handler) beforeRegisterEvent(e *fsm.Event, state string) {
 
 	// Now register with the chaincodeSupport
 	handler.ChaincodeID = chaincodeID
+	handler.protocolVersion = pb.NegotiateChaincodeProtocolVersion(msg.ProtocolVersion)
 	err = handler.chaincodeSupport.registerHandler(handler)
 	if err != nil {
 		e.Cancel(err)
@@ -464,8 +637,8 @@ func (handler *Handler) beforeRegisterEvent(e *fsm.Event, state string) {
 		return
 	}
 
-	chaincodeLogger.Debug("Got %s for chaincodeID = %s, sending back %s", e.Event, chaincodeID, pb.ChaincodeMessage_REGISTERED)
-	if err := handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED}); err != nil {
+	chaincodeLogger.Debug("Got %s for chaincodeID = %s (protocol version %d), sending back %s", e.Event, chaincodeID, handler.protocolVersion, pb.ChaincodeMessage_REGISTERED)
+	if err := handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED, ProtocolVersion: handler.protocolVersion}); err != nil {
 		e.Cancel(fmt.Errorf("Error sending %s: %s", pb.ChaincodeMessage_REGISTERED, err))
 		handler.notifyDuringStartup(false)
 		return
@@ -542,30 +715,42 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 			handler.serialSend(serialSendMsg)
 		}()
 
-		key := string(msg.Payload)
-		ledgerObj, ledgerErr := ledger.GetLedger()
-		if ledgerErr != nil {
-			// Send error msg back to chaincode. GetState will not trigger event
-			payload := []byte(ledgerErr.Error())
-			chaincodeLogger.Error(fmt.Sprintf("Failed to get chaincode state(%s). Sending %s", ledgerErr, pb.ChaincodeMessage_ERROR))
-			// Remove uuid from current set
+		key, keyErr := handler.encryptKey(msg.Uuid, string(msg.Payload))
+		if keyErr != nil {
+			payload := []byte(keyErr.Error())
+			chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to encrypt key(%s). Sending %s", shortuuid(msg.Uuid), keyErr, pb.ChaincodeMessage_ERROR))
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 			return
 		}
-
 		// Invoke ledger to get state
 		chaincodeID := handler.ChaincodeID.Name
 
-		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		res, err := ledgerObj.GetState(chaincodeID, key, readCommittedState)
+		// Transactions read uncommitted state, starting with their own buffered
+		// puts and deletes before falling back to the batch and db, queries a
+		// snapshot of the committed state
+		var res []byte
+		view, err := handler.getStateView(msg.Uuid)
+		if err == nil {
+			res, err = view.getState(chaincodeID, key)
+		}
+		if err == nil {
+			res, err = handler.chaincodeSupport.resolveBlob(res)
+		}
+		if err == nil {
+			err = handler.chaincodeSupport.meters.read(msg.Uuid, 1, len(key)+len(res))
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
 			chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to get chaincode state(%s). Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR))
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		} else {
-			// Decrypt the data if the confidential is enabled
-			if res, err = handler.decrypt(msg.Uuid, res); err == nil {
+			// Decrypt the data if the confidential is enabled. A nil value means the
+			// key is missing or was deleted by this tx, so there is nothing to decrypt
+			if res == nil {
+				chaincodeLogger.Debug("[%s]Got nil state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: nil, Uuid: msg.Uuid}
+			} else if res, err = handler.decrypt(msg.Uuid, res); err == nil {
 				// Send response msg back to chaincode. GetState will not trigger event
 				chaincodeLogger.Debug("[%s]Got state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
 				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
@@ -581,6 +766,64 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 	}()
 }
 
+// afterGetChainState handles a GET_CHAIN_STATE request from the chaincode.
+func (handler *Handler) afterGetChainState(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debug("[%s]Received %s, reading state of another chain", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_CHAIN_STATE)
+
+	handler.handleGetChainState(msg)
+}
+
+// handleGetChainState reads the committed state of a chaincode on another
+// chain, checked against the state hash of that chain. Transactions only read
+// the chains hosted by the peer, which validators commit along with the
+// default chain, so that every validator reads the same state. Queries may
+// also read the chains served by trusted peers.
+func (handler *Handler) handleGetChainState(msg *pb.ChaincodeMessage) {
+	go func() {
+		// Check if this is the unique state request from this chaincode uuid
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debug("[%s]handleGetChainState serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		req := &pb.StateProofRequest{}
+		err := proto.Unmarshal(msg.Payload, req)
+		if err == nil && req.ChainID == handler.chainID() {
+			err = fmt.Errorf("Chain [%s] is the chain of the chaincode", req.ChainID)
+		}
+		var stateProof *pb.StateProof
+		if err == nil {
+			stateProof, err = crosschain.GetStateProof(req.ChainID, req.ChaincodeID, req.Key, handler.getIsTransaction(msg.Uuid))
+		}
+		if err == nil {
+			err = handler.chaincodeSupport.meters.read(msg.Uuid, 1, len(req.Key)+len(stateProof.Value))
+		}
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to get state of another chain(%s). Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR))
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+		chaincodeLogger.Debug("[%s]Got state of chain [%s] at block %d. Sending %s", shortuuid(msg.Uuid), req.ChainID, stateProof.BlockNumber, pb.ChaincodeMessage_RESPONSE)
+		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: stateProof.Value, Uuid: msg.Uuid}
+	}()
+}
+
 const maxRangeQueryStateLimit = 100
 
 // afterRangeQueryState handles a RANGE_QUERY_STATE request from the chaincode.
@@ -630,19 +873,23 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 
 		hasNext := true
 
-		ledger, ledgerErr := ledger.GetLedger()
-		if ledgerErr != nil {
-			// Send error msg back to chaincode. GetState will not trigger event
-			payload := []byte(ledgerErr.Error())
-			chaincodeLogger.Debug("Failed to get ledger. Sending %s", pb.ChaincodeMessage_ERROR)
-			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-			return
-		}
-
 		chaincodeID := handler.ChaincodeID.Name
 
-		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		rangeIter, err := ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		// Encrypted keys are not ordered like the keys, the whole state of the
+		// chaincode is scanned and the range is selected after decrypting them
+		startKey, endKey := rangeQueryState.StartKey, rangeQueryState.EndKey
+		if handler.keyEncryption == pb.ChaincodeSpec_DETERMINISTIC {
+			startKey, endKey = "", ""
+		}
+
+		var rangeIter statemgmt.RangeScanIterator
+		view, err := handler.getStateView(msg.Uuid)
+		if err == nil {
+			rangeIter, err = view.getStateRangeScanIterator(chaincodeID, startKey, endKey)
+		}
+		if err == nil && handler.keyEncryption == pb.ChaincodeSpec_DETERMINISTIC {
+			rangeIter, err = handler.decryptRangeScanKeys(msg.Uuid, rangeIter, rangeQueryState.StartKey, rangeQueryState.EndKey)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
@@ -651,6 +898,8 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			return
 		}
 
+		rangeIter = newRangeQueryIterator(rangeIter, rangeQueryState)
+
 		iterID := util.GenerateUUID()
 		txContext := handler.getTxContext(msg.Uuid)
 		handler.putRangeQueryIterator(txContext, iterID, rangeIter)
@@ -661,6 +910,23 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 		var i = uint32(0)
 		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
 			key, value := rangeIter.GetKeyValue()
+			// Values are neither read nor decrypted for keys-only queries
+			if isKeysOnly(rangeIter) {
+				keysAndValues = append(keysAndValues, &pb.RangeQueryStateKeyValue{Key: key})
+				hasNext = rangeIter.Next()
+				continue
+			}
+			value, err := handler.chaincodeSupport.resolveBlob(value)
+			if err != nil {
+				payload := []byte(err.Error())
+				chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to resolve value of key %s(%s). Sending %s", shortuuid(msg.Uuid), key, err, pb.ChaincodeMessage_ERROR))
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+
+				rangeIter.Close()
+				handler.deleteRangeQueryIterator(txContext, iterID)
+
+				return
+			}
 			// Decrypt the data if the confidential is enabled
 			decryptedValue, err := handler.decrypt(msg.Uuid, value)
 			if err != nil {
@@ -679,6 +945,16 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			hasNext = rangeIter.Next()
 		}
 
+		if err := handler.meterRangeRead(msg.Uuid, keysAndValues); err != nil {
+			rangeIter.Close()
+			handler.deleteRangeQueryIterator(txContext, iterID)
+
+			payload := []byte(err.Error())
+			chaincodeLogger.Debug("Resource limit exceeded. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
 		if !hasNext {
 			rangeIter.Close()
 			handler.deleteRangeQueryIterator(txContext, iterID)
@@ -703,6 +979,16 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 	}()
 }
 
+// meterRangeRead counts the keys and values returned by a range query
+// against the resource limits of the transaction
+func (handler *Handler) meterRangeRead(uuid string, keysAndValues []*pb.RangeQueryStateKeyValue) error {
+	size := 0
+	for _, kv := range keysAndValues {
+		size += len(kv.Key) + len(kv.Value)
+	}
+	return handler.chaincodeSupport.meters.read(uuid, len(keysAndValues), size)
+}
+
 // afterRangeQueryState handles a RANGE_QUERY_STATE_NEXT request from the chaincode.
 func (handler *Handler) afterRangeQueryStateNext(e *fsm.Event, state string) {
 	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
@@ -763,6 +1049,23 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 		hasNext := true
 		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
 			key, value := rangeIter.GetKeyValue()
+			// Values are neither read nor decrypted for keys-only queries
+			if isKeysOnly(rangeIter) {
+				keysAndValues = append(keysAndValues, &pb.RangeQueryStateKeyValue{Key: key})
+				hasNext = rangeIter.Next()
+				continue
+			}
+			value, err := handler.chaincodeSupport.resolveBlob(value)
+			if err != nil {
+				payload := []byte(err.Error())
+				chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to resolve value of key %s(%s). Sending %s", shortuuid(msg.Uuid), key, err, pb.ChaincodeMessage_ERROR))
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+
+				rangeIter.Close()
+				handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
+
+				return
+			}
 			// Decrypt the data if the confidential is enabled
 			decryptedValue, err := handler.decrypt(msg.Uuid, value)
 			if err != nil {
@@ -781,6 +1084,16 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 			hasNext = rangeIter.Next()
 		}
 
+		if err := handler.meterRangeRead(msg.Uuid, keysAndValues); err != nil {
+			rangeIter.Close()
+			handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
+
+			payload := []byte(err.Error())
+			chaincodeLogger.Debug("Resource limit exceeded. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
 		if !hasNext {
 			rangeIter.Close()
 			handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
@@ -876,153 +1189,249 @@ func (handler *Handler) handleRangeQueryStateClose(msg *pb.ChaincodeMessage) {
 
 // afterPutState handles a PUT_STATE request from the chaincode.
 func (handler *Handler) afterPutState(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
 	chaincodeLogger.Debug("Received %s in state %s, invoking put state to ledger", pb.ChaincodeMessage_PUT_STATE, state)
 
-	// Put state into ledger handled within enterBusyState
+	// During init the request is handled within enterBusyState
+	handler.handleTransactionRequest(msg, state)
 }
 
 // afterDelState handles a DEL_STATE request from the chaincode.
 func (handler *Handler) afterDelState(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
 	chaincodeLogger.Debug("Received %s, invoking delete state from ledger", pb.ChaincodeMessage_DEL_STATE)
 
-	// Delete state from ledger handled within enterBusyState
+	// During init the request is handled within enterBusyState
+	handler.handleTransactionRequest(msg, state)
 }
 
 // afterInvokeChaincode handles an INVOKE_CHAINCODE request from the chaincode.
 func (handler *Handler) afterInvokeChaincode(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
 	chaincodeLogger.Debug("Received %s in state %s, invoking another chaincode", pb.ChaincodeMessage_INVOKE_CHAINCODE, state)
 
-	// Invoke another chaincode handled within enterBusyState
+	// During init the request is handled within enterBusyState
+	handler.handleTransactionRequest(msg, state)
+}
+
+// afterEnqueueChaincode handles an ENQUEUE_CHAINCODE request from the chaincode.
+func (handler *Handler) afterEnqueueChaincode(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debug("Received %s in state %s, enqueuing invocation", pb.ChaincodeMessage_ENQUEUE_CHAINCODE, state)
+
+	// During init the request is handled within enterBusyState
+	handler.handleTransactionRequest(msg, state)
+}
+
+// afterSetEvent handles a SET_EVENT request from the chaincode.
+func (handler *Handler) afterSetEvent(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debug("Received %s in state %s, setting event", pb.ChaincodeMessage_SET_EVENT, state)
+
+	// During init the request is handled within enterBusyState
+	handler.handleTransactionRequest(msg, state)
+}
+
+// handleTransactionRequest handles a request of a running transaction. Any
+// number of transactions can run at a time, so the request does not change
+// the state and the response is sent straight to the chaincode.
+func (handler *Handler) handleTransactionRequest(msg *pb.ChaincodeMessage, state string) {
+	if state != readystate {
+		return
+	}
+	go func() {
+		if resp := handler.handleStateRequest(msg, state); resp != nil {
+			handler.serialSend(resp)
+		}
+	}()
 }
 
 // Handles request to ledger to put state
 func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 	go func() {
 		msg, _ := e.Args[0].(*pb.ChaincodeMessage)
-		// First check if this UUID is a transaction; error otherwise
-		if !handler.getIsTransaction(msg.Uuid) {
-			payload := []byte(fmt.Sprintf("Cannot handle %s in query context", msg.Type.String()))
-			chaincodeLogger.Debug("[%s]Cannot handle %s in query context. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
-			errMsg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-			handler.triggerNextState(errMsg, true)
-			return
-		}
-
-		chaincodeLogger.Debug("[%s]state is %s", shortuuid(msg.Uuid), state)
-		// Check if this is the unique request from this chaincode uuid
-		uniqueReq := handler.createUUIDEntry(msg.Uuid)
-		if !uniqueReq {
-			// Drop this request
-			chaincodeLogger.Debug("Another request pending for this Uuid. Cannot process.")
-			return
+		if resp := handler.handleStateRequest(msg, state); resp != nil {
+			chaincodeLogger.Debug("[%s]enterBusyState trigger event %s", shortuuid(resp.Uuid), resp.Type)
+			handler.triggerNextState(resp, true)
 		}
+	}()
+}
 
-		var triggerNextStateMsg *pb.ChaincodeMessage
+// handleStateRequest handles a PUT_STATE, DEL_STATE, INVOKE_CHAINCODE,
+// ENQUEUE_CHAINCODE or SET_EVENT request and returns the response for the chaincode, or nil if
+// the request is dropped
+func (handler *Handler) handleStateRequest(msg *pb.ChaincodeMessage, state string) *pb.ChaincodeMessage {
+	// First check if this UUID is a transaction; error otherwise
+	if !handler.getIsTransaction(msg.Uuid) {
+		payload := []byte(fmt.Sprintf("Cannot handle %s in query context", msg.Type.String()))
+		chaincodeLogger.Debug("[%s]Cannot handle %s in query context. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+	}
 
-		defer func() {
-			handler.deleteUUIDEntry(msg.Uuid)
-			chaincodeLogger.Debug("[%s]enterBusyState trigger event %s", shortuuid(triggerNextStateMsg.Uuid), triggerNextStateMsg.Type)
-			handler.triggerNextState(triggerNextStateMsg, true)
-		}()
+	// The peer no longer waits for an aborted transaction, its changes would be lost
+	if handler.getTxContext(msg.Uuid) == nil {
+		payload := []byte(fmt.Sprintf("Cannot handle %s, the transaction was aborted", msg.Type.String()))
+		chaincodeLogger.Debug("[%s]Cannot handle %s of aborted transaction. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+	}
 
-		ledgerObj, ledgerErr := ledger.GetLedger()
-		if ledgerErr != nil {
-			// Send error msg back to chaincode and trigger event
-			payload := []byte(ledgerErr.Error())
-			chaincodeLogger.Debug("[%s]Failed to handle %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
-			triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-			return
-		}
+	chaincodeLogger.Debug("[%s]state is %s", shortuuid(msg.Uuid), state)
+	// Check if this is the unique request from this chaincode uuid
+	uniqueReq := handler.createUUIDEntry(msg.Uuid)
+	if !uniqueReq {
+		// Drop this request
+		chaincodeLogger.Debug("Another request pending for this Uuid. Cannot process.")
+		return nil
+	}
+	defer handler.deleteUUIDEntry(msg.Uuid)
 
-		chaincodeID := handler.ChaincodeID.Name
-		var err error
-		var res []byte
+	chaincodeID := handler.ChaincodeID.Name
+	var err error
+	var res []byte
 
-		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() {
-			putStateInfo := &pb.PutStateInfo{}
-			unmarshalErr := proto.Unmarshal(msg.Payload, putStateInfo)
-			if unmarshalErr != nil {
-				payload := []byte(unmarshalErr.Error())
-				chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
-				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-				return
-			}
+	if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() {
+		putStateInfo := &pb.PutStateInfo{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, putStateInfo)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+		}
 
-			var pVal []byte
-			// Encrypt the data if the confidential is enabled
-			if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
-				// Invoke ledger to put state
-				err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+		var pKey string
+		var pVal []byte
+		// Count the write against the resource limits of the transaction
+		err = handler.chaincodeSupport.checkValueSize(putStateInfo.Key, len(putStateInfo.Value))
+		if err == nil {
+			err = handler.chaincodeSupport.meters.write(msg.Uuid, 1, len(putStateInfo.Key)+len(putStateInfo.Value))
+		}
+		// Encrypt the data if the confidential is enabled
+		if err == nil {
+			if pKey, err = handler.encryptKey(msg.Uuid, putStateInfo.Key); err == nil {
+				if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
+					// Buffer the state change until the transaction finishes,
+					// large values only as a reference to their blob
+					if pVal, err = handler.chaincodeSupport.storeBlob(pVal); err == nil {
+						err = handler.chaincodeSupport.setTxState(msg.Uuid, chaincodeID, pKey, pVal)
+					}
+				}
 			}
-		} else if msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() {
-			// Invoke ledger to delete state
-			key := string(msg.Payload)
-			err = ledgerObj.DeleteState(chaincodeID, key)
-		} else if msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
-			chaincodeSpec := &pb.ChaincodeSpec{}
-			unmarshalErr := proto.Unmarshal(msg.Payload, chaincodeSpec)
-			if unmarshalErr != nil {
-				payload := []byte(unmarshalErr.Error())
-				chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
-				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-				return
+		}
+	} else if msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() {
+		// Buffer the deletion until the transaction finishes
+		var key string
+		err = handler.chaincodeSupport.meters.write(msg.Uuid, 1, len(msg.Payload))
+		if err == nil {
+			if key, err = handler.encryptKey(msg.Uuid, string(msg.Payload)); err == nil {
+				err = handler.chaincodeSupport.deleteTxState(msg.Uuid, chaincodeID, key)
 			}
+		}
+	} else if msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
+		chaincodeSpec := &pb.ChaincodeSpec{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, chaincodeSpec)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+		}
 
-			// Get the chaincodeID to invoke
-			newChaincodeID := chaincodeSpec.ChaincodeID.Name
+		// Get the chaincodeID to invoke, chaincodes only reach the chaincodes
+		// deployed on their own chain
+		newChaincodeID := chaincodeSpec.ChaincodeID.Name
+		chaincodeSpec.ChainID = handler.chainID()
 
-			// Create the transaction object
-			chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
-			transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_EXECUTE)
+		// Create the transaction object
+		chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
+		transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_EXECUTE)
 
-			// Launch the new chaincode if not already running
-			_, chaincodeInput, launchErr := handler.chaincodeSupport.LaunchChaincode(context.Background(), transaction)
-			if launchErr != nil {
-				payload := []byte(launchErr.Error())
-				chaincodeLogger.Debug("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
-				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-				return
-			}
+		// Launch the new chaincode if not already running. It is cancelled
+		// with the invoking execution.
+		ctxt := handler.executionContext(msg.Uuid)
+		_, chaincodeInput, launchErr := handler.chaincodeSupport.LaunchChaincode(ctxt, transaction)
+		if launchErr != nil {
+			payload := []byte(launchErr.Error())
+			chaincodeLogger.Debug("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+		}
 
-			// TODO: Need to handle timeout correctly
-			timeout := time.Duration(30000) * time.Millisecond
+		// TODO: Need to handle timeout correctly
+		timeout := time.Duration(30000) * time.Millisecond
 
-			ccMsg, _ := createTransactionMessage(transaction.Uuid, chaincodeInput)
+		ccMsg, _ := createTransactionMessage(transaction.Uuid, chaincodeInput)
 
-			// Execute the chaincode
-			//TODOOOOOOOOOOOOOOOOOOOOOOOOO - pass transaction to Execute
-			response, execErr := handler.chaincodeSupport.Execute(context.Background(), newChaincodeID, ccMsg, timeout, nil)
-			err = execErr
-			res = response.Payload
+		// Execute the chaincode
+		//TODOOOOOOOOOOOOOOOOOOOOOOOOO - pass transaction to Execute
+		response, execErr := handler.chaincodeSupport.Execute(ctxt, newChaincodeID, ccMsg, timeout, nil)
+		err = execErr
+		res = response.Payload
+	} else if msg.Type.String() == pb.ChaincodeMessage_ENQUEUE_CHAINCODE.String() {
+		chaincodeSpec := &pb.ChaincodeSpec{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, chaincodeSpec)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		}
 
-		if err != nil {
-			// Send error msg back to chaincode and trigger event
-			payload := []byte(err.Error())
-			chaincodeLogger.Debug("[%s]Failed to handle %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
-			triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-			return
+		if handler.chaincodeSupport.getSecHelper() != nil {
+			// The validators have no certificate to sign the transaction with
+			err = fmt.Errorf("Enqueued invocations are not supported with security enabled")
+		} else if chaincodeSpec.ChaincodeID == nil || chaincodeSpec.ChaincodeID.Name == "" {
+			err = fmt.Errorf("Missing name of the chaincode to invoke")
+		} else {
+			// Enqueued invocations stay on the chain of the chaincode
+			chaincodeSpec.ChainID = handler.chainID()
+			err = handler.chaincodeSupport.deferredCalls.add(msg.Uuid, chaincodeSpec)
+		}
+	} else if msg.Type.String() == pb.ChaincodeMessage_SET_EVENT.String() {
+		event := &pb.ChaincodeEvent{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, event)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		}
 
-		// Send response msg back to chaincode.
-		chaincodeLogger.Debug("[%s]Completed %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_RESPONSE)
-		triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
-	}()
+		if event.EventName == "" {
+			err = fmt.Errorf("Missing name of the event")
+		} else {
+			// The chaincode cannot impersonate another one
+			event.ChaincodeID = chaincodeID
+			event.TxUuid = msg.Uuid
+			err = handler.chaincodeSupport.chaincodeEvents.add(msg.Uuid, event)
+		}
+	}
+
+	if err != nil {
+		// Send error msg back to chaincode and trigger event
+		payload := []byte(err.Error())
+		chaincodeLogger.Debug("[%s]Failed to handle %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+	}
+
+	// Send response msg back to chaincode.
+	chaincodeLogger.Debug("[%s]Completed %s. Sending %s", shortuuid(msg.Uuid), msg.Type.String(), pb.ChaincodeMessage_RESPONSE)
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
 }
 
 func (handler *Handler) enterEstablishedState(e *fsm.Event, state string) {
@@ -1059,6 +1468,22 @@ func (handler *Handler) enterReadyState(e *fsm.Event, state string) {
 	handler.notify(msg)
 }
 
+// afterCompletedOrError notifies the end of a transaction. As transactions
+// do not change the state, it is not notified by enterReadyState.
+func (handler *Handler) afterCompletedOrError(e *fsm.Event, state string) {
+	if e.Src != readystate || e.Dst != readystate {
+		return
+	}
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	handler.deleteIsTransaction(msg.Uuid)
+	chaincodeLogger.Debug("[%s]Received %s in state %s", shortuuid(msg.Uuid), msg.Type, state)
+	handler.notify(msg)
+}
+
 func (handler *Handler) enterEndState(e *fsm.Event, state string) {
 	defer handler.deregister()
 	// Now notify
@@ -1104,6 +1529,19 @@ func (handler *Handler) initializeSecContext(tx, depTx *pb.Transaction) error {
 		}
 	}
 
+	//keys are encrypted and invocations signed as requested when the chaincode was deployed
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(handler.deployTXSecContext.Payload, cds); err != nil {
+		return fmt.Errorf("Failed to unmarshall deployment spec: %s\n", err)
+	}
+	if cds.ChaincodeSpec != nil {
+		if handler.deployTXSecContext.ConfidentialityLevel == pb.ConfidentialityLevel_CONFIDENTIAL {
+			handler.keyEncryption = cds.ChaincodeSpec.KeyEncryption
+		}
+		handler.signaturePolicy = cds.ChaincodeSpec.SignaturePolicy
+	}
+	handler.deploymentInfo = newDeploymentInfo(handler.deployTXSecContext, cds)
+
 	//don't need the payload which is not useful and rather large
 	handler.deployTXSecContext.Payload = nil
 
@@ -1127,11 +1565,11 @@ func (handler *Handler) initializeSecContext(tx, depTx *pb.Transaction) error {
 
 //if initArgs is set (should be for "deploy" only) move to Init
 //else move to ready
-func (handler *Handler) initOrReady(uuid string, f *string, initArgs []string, tx *pb.Transaction, depTx *pb.Transaction) (chan *pb.ChaincodeMessage, error) {
+func (handler *Handler) initOrReady(ctxt context.Context, uuid string, f *string, initArgs []string, tx *pb.Transaction, depTx *pb.Transaction) (chan *pb.ChaincodeMessage, error) {
 	var ccMsg *pb.ChaincodeMessage
 	var send bool
 
-	txctx, funcErr := handler.createTxContext(uuid, tx)
+	txctx, funcErr := handler.createTxContext(ctxt, uuid, tx, false)
 	if funcErr != nil {
 		return nil, funcErr
 	}
@@ -1151,6 +1589,7 @@ func (handler *Handler) initOrReady(uuid string, f *string, initArgs []string, t
 			return nil, fmt.Errorf("Failed to marshall %s : %s\n", ccMsg.Type.String(), funcErr)
 		}
 		ccMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_INIT, Payload: payload, Uuid: uuid}
+		handler.setChaincodeSecurityContext(tx, ccMsg)
 		send = false
 	} else {
 		chaincodeLogger.Debug("sending READY")
@@ -1195,15 +1634,19 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		// Get the chaincodeID to invoke
+		// Get the chaincodeID to invoke, chaincodes only reach the chaincodes
+		// deployed on their own chain
 		newChaincodeID := chaincodeSpec.ChaincodeID.Name
+		chaincodeSpec.ChainID = handler.chainID()
 
 		// Create the transaction object
 		chaincodeInvocationSpec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: chaincodeSpec}
 		transaction, _ := pb.NewChaincodeExecute(chaincodeInvocationSpec, msg.Uuid, pb.Transaction_CHAINCODE_QUERY)
 
-		// Launch the new chaincode if not already running
-		_, chaincodeInput, launchErr := handler.chaincodeSupport.LaunchChaincode(context.Background(), transaction)
+		// Launch the new chaincode if not already running. It is cancelled
+		// with the invoking execution.
+		ctxt := handler.executionContext(msg.Uuid)
+		_, chaincodeInput, launchErr := handler.chaincodeSupport.LaunchChaincode(ctxt, transaction)
 		if launchErr != nil {
 			payload := []byte(launchErr.Error())
 			chaincodeLogger.Debug("[%s]Failed to launch invoked chaincode. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
@@ -1218,7 +1661,7 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 
 		// Query the chaincode
 		//TODOOOOOOOOOOOOOOOOOOOOOOOOO - pass transaction to Execute
-		response, execErr := handler.chaincodeSupport.Execute(context.Background(), newChaincodeID, ccMsg, timeout, nil)
+		response, execErr := handler.chaincodeSupport.Execute(ctxt, newChaincodeID, ccMsg, timeout, nil)
 
 		if execErr != nil {
 			// Send error msg back to chaincode and trigger event
@@ -1238,6 +1681,14 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 func (handler *Handler) HandleMessage(msg *pb.ChaincodeMessage) error {
 	chaincodeLogger.Debug("[%s]Handling ChaincodeMessage of type: %s in state %s", shortuuid(msg.Uuid), msg.Type, handler.FSM.Current())
 
+	if !handler.speaks(msg.Type) {
+		// The chaincode asked for a version of the protocol without this message
+		payload := []byte(fmt.Sprintf("%s is not part of version %d of the chaincode protocol", msg.Type, handler.protocolVersion))
+		chaincodeLogger.Debug("[%s]%s. Sending %s", shortuuid(msg.Uuid), payload, pb.ChaincodeMessage_ERROR)
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid})
+		return nil
+	}
+	handler.reportDeprecation(msg)
 	//QUERY_COMPLETED message can happen ONLY for Transaction_QUERY (stateless)
 	if msg.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
 		chaincodeLogger.Debug("[%s]HandleMessage- QUERY_COMPLETED. Notify", msg.Uuid)
@@ -1260,10 +1711,21 @@ func (handler *Handler) HandleMessage(msg *pb.ChaincodeMessage) error {
 		chaincodeLogger.Debug("[%s]HandleMessage- Received request to query another chaincode", msg.Uuid)
 		handler.handleQueryChaincode(msg)
 		return nil
+	} else if msg.Type == pb.ChaincodeMessage_PUT_STATE_PART {
+		// The parts are collected until the value can be put as a whole
+		return handler.handlePutStatePart(msg)
+	} else if msg.Type == pb.ChaincodeMessage_GET_DEPLOYMENT_INFO {
+		// The deployment is known from the start, whatever the state
+		handler.handleGetDeploymentInfo(msg)
+		return nil
+	} else if msg.Type == pb.ChaincodeMessage_GET_TX_RANDOM || msg.Type == pb.ChaincodeMessage_GET_TX_TIME {
+		// Derived from the transaction, whatever the state
+		handler.handleGetTxValue(msg)
+		return nil
 	}
 	if handler.FSM.Cannot(msg.Type.String()) {
 		// Check if this is a request from validator in query context
-		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
+		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() || msg.Type.String() == pb.ChaincodeMessage_ENQUEUE_CHAINCODE.String() || msg.Type.String() == pb.ChaincodeMessage_SET_EVENT.String() {
 			// Check if this UUID is a transaction
 			if !handler.getIsTransaction(msg.Uuid) {
 				payload := []byte(fmt.Sprintf("[%s]Cannot handle %s in query context", msg.Uuid, msg.Type.String()))
@@ -1308,12 +1770,20 @@ func filterError(errFromFSMEvent error) error {
 	return nil
 }
 
-func (handler *Handler) sendExecuteMessage(msg *pb.ChaincodeMessage, tx *pb.Transaction) (chan *pb.ChaincodeMessage, error) {
-	txctx, err := handler.createTxContext(msg.Uuid, tx)
+func (handler *Handler) sendExecuteMessage(ctxt context.Context, msg *pb.ChaincodeMessage, tx *pb.Transaction) (chan *pb.ChaincodeMessage, error) {
+	if msg.Type == pb.ChaincodeMessage_TRANSACTION {
+		if err := checkSignaturePolicy(handler.signaturePolicy, tx, handler.chaincodeSupport.getSecHelper() != nil); err != nil {
+			return nil, err
+		}
+	}
+
+	txctx, err := handler.createTxContext(ctxt, msg.Uuid, tx, msg.Type == pb.ChaincodeMessage_QUERY)
 	if err != nil {
 		return nil, err
 	}
 
+	handler.setChaincodeSecurityContext(tx, msg)
+
 	// Mark UUID as either transaction or query
 	chaincodeLogger.Debug("[%s]Inside sendExecuteMessage. Message %s", shortuuid(msg.Uuid), msg.Type.String())
 	if msg.Type.String() == pb.ChaincodeMessage_QUERY.String() {
@@ -1338,6 +1808,15 @@ func (handler *Handler) sendExecuteMessage(msg *pb.ChaincodeMessage, tx *pb.Tran
 	return txctx.responseNotifier, nil
 }
 
+// setChaincodeSecurityContext passes the certificate of the invoker and the
+// key of the attributes embedded in it to the chaincode
+func (handler *Handler) setChaincodeSecurityContext(tx *pb.Transaction, msg *pb.ChaincodeMessage) {
+	if tx == nil {
+		return
+	}
+	msg.SecurityContext = &pb.ChaincodeSecurityContext{CallerCert: tx.Cert, AttributesKey: tx.AttributesKey}
+}
+
 func (handler *Handler) isRunning() bool {
 	switch handler.FSM.Current() {
 	case createdstate:
@@ -0,0 +1,68 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestHandleChaincodeStreamScript(t *testing.T) {
+	s := newDevModeSupport()
+	stream := cctesting.NewMockStream()
+	done := make(chan error)
+	go func() {
+		done <- HandleChaincodeStream(s, stream)
+	}()
+
+	if err := stream.Deliver(cctesting.RegisterMessage("mycc")); err != nil {
+		t.Fatal(err)
+	}
+	registered, err := stream.Expect(pb.ChaincodeMessage_REGISTERED)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registered.ProtocolVersion != pb.ChaincodeProtocolVersion {
+		t.Fatalf("Expected protocol version %d, got %d", pb.ChaincodeProtocolVersion, registered.ProtocolVersion)
+	}
+	if handler, _ := s.chaincodeHasBeenLaunched("mycc"); handler == nil {
+		t.Fatalf("Expected the chaincode to be registered")
+	}
+
+	// Nothing was deployed
+	err = stream.Play(cctesting.Step{Deliver: &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_DEPLOYMENT_INFO, Uuid: "tx1"},
+		Expect: &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Uuid: "tx1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream.End()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the handler to stop once the stream ended")
+	}
+	if handler, _ := s.chaincodeHasBeenLaunched("mycc"); handler != nil {
+		t.Fatalf("Expected the chaincode to be deregistered")
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/container"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+)
+
+// startImageGC periodically removes the images of chaincodes that have no
+// deployment transaction on the ledger, if enabled in the configuration
+func startImageGC() {
+	if !viper.GetBool("chaincode.imagegc.enabled") {
+		return
+	}
+	interval := viper.GetDuration("chaincode.imagegc.interval")
+	minAge := viper.GetDuration("chaincode.imagegc.minage")
+	if interval <= 0 {
+		chaincodeLog.Warning("Invalid chaincode.imagegc.interval %s, image GC disabled", interval)
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if err := container.RemoveUnusedChaincodeImages(isDeployed, minAge); err != nil {
+				chaincodeLog.Warning("Chaincode image GC failed: %s", err)
+			}
+		}
+	}()
+}
+
+// isDeployed returns true if the ledger of any chain holds the deployment
+// transaction of the chaincode. Errors count as deployed so that images are
+// never removed by mistake.
+func isDeployed(name string) bool {
+	chainIDs, err := ledger.GetChainIDs()
+	if err != nil {
+		return true
+	}
+	for _, chainID := range append([]string{""}, chainIDs...) {
+		l, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			return true
+		}
+		tx, err := l.GetTransactionByUUID(name)
+		if err != ledger.ErrResourceNotFound && (err != nil || tx != nil) {
+			return true
+		}
+	}
+	return false
+}
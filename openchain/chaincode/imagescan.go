@@ -0,0 +1,174 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	osexec "os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// severities of scanner findings, in increasing order
+var severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return 0
+}
+
+// imageScanner is the reference pre-launch hook. It runs a vulnerability
+// scanner writing a Trivy JSON report on the image and blocks deployments
+// when a finding is at or above a severity.
+type imageScanner struct {
+	command   []string
+	threshold string
+	timeout   time.Duration
+}
+
+// scanFinding is a vulnerability of a Trivy report
+type scanFinding struct {
+	VulnerabilityID string
+	PkgName         string
+	Severity        string
+}
+
+// scanResult holds the findings of a Trivy report for one target of the image
+type scanResult struct {
+	Target          string
+	Vulnerabilities []scanFinding
+}
+
+// newImageScanner reads the scanner from chaincode.prelaunch.imagescan
+func newImageScanner() *imageScanner {
+	s := &imageScanner{
+		command:   strings.Fields(viper.GetString("chaincode.prelaunch.imagescan.command")),
+		threshold: strings.ToUpper(viper.GetString("chaincode.prelaunch.imagescan.severity")),
+		timeout:   viper.GetDuration("chaincode.prelaunch.imagescan.timeout"),
+	}
+	if s.threshold == "" {
+		s.threshold = "CRITICAL"
+	}
+	return s
+}
+
+func (s *imageScanner) Name() string {
+	return "imagescan"
+}
+
+// Check runs the scanner with the image appended to its command and
+// evaluates the report it writes to stdout
+func (s *imageScanner) Check(ctxt context.Context, chaincode string, image string) (string, error) {
+	if len(s.command) == 0 {
+		return "", fmt.Errorf("No scanner command configured")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := osexec.Command(s.command[0], append(s.command[1:], image)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("Error starting scanner: %s", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timeout = time.After(s.timeout)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("Scanner failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	case <-timeout:
+		cmd.Process.Kill()
+		return "", fmt.Errorf("Scanner timed out after %s", s.timeout)
+	case <-ctxt.Done():
+		cmd.Process.Kill()
+		return "", ctxt.Err()
+	}
+	results, err := parseScanReport(stdout.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("Error reading scanner report: %s", err)
+	}
+	return s.evaluate(results)
+}
+
+// parseScanReport reads a Trivy JSON report, either the results of the
+// image or an object holding them as Results
+func parseScanReport(data []byte) ([]scanResult, error) {
+	data = bytes.TrimSpace(data)
+	var results []scanResult
+	if len(data) > 0 && data[0] == '[' {
+		err := json.Unmarshal(data, &results)
+		return results, err
+	}
+	report := struct{ Results []scanResult }{}
+	err := json.Unmarshal(data, &report)
+	return report.Results, err
+}
+
+// evaluate summarizes the findings by severity and returns an error listing
+// those at or above the threshold
+func (s *imageScanner) evaluate(results []scanResult) (string, error) {
+	counts := make(map[string]int)
+	total := 0
+	var blocking []string
+	threshold := severityRank(s.threshold)
+	for _, result := range results {
+		for _, finding := range result.Vulnerabilities {
+			severity := severities[severityRank(finding.Severity)]
+			counts[severity]++
+			total++
+			if severityRank(severity) >= threshold {
+				blocking = append(blocking, fmt.Sprintf("%s (%s)", finding.VulnerabilityID, finding.PkgName))
+			}
+		}
+	}
+	var parts []string
+	for i := len(severities) - 1; i >= 0; i-- {
+		if n := counts[severities[i]]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, severities[i]))
+		}
+	}
+	summary := fmt.Sprintf("%d findings", total)
+	if len(parts) > 0 {
+		summary += ": " + strings.Join(parts, ", ")
+	}
+	if len(blocking) == 0 {
+		return summary, nil
+	}
+	sort.Strings(blocking)
+	const maxListed = 5
+	listed := blocking
+	if len(listed) > maxListed {
+		listed = append(listed[:maxListed:maxListed], "...")
+	}
+	return summary, fmt.Errorf("%d findings at or above %s: %s", len(blocking), s.threshold, strings.Join(listed, ", "))
+}
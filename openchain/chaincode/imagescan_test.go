@@ -0,0 +1,122 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+const scanReport = `{"Results": [
+	{"Target": "mycc (debian 8.5)", "Vulnerabilities": [
+		{"VulnerabilityID": "CVE-2016-0001", "PkgName": "openssl", "Severity": "CRITICAL"},
+		{"VulnerabilityID": "CVE-2016-0002", "PkgName": "libc6", "Severity": "HIGH"},
+		{"VulnerabilityID": "CVE-2016-0003", "PkgName": "libc6", "Severity": "LOW"}
+	]},
+	{"Target": "go binary", "Vulnerabilities": null}
+]}`
+
+func TestImageScanner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagescan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	report := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(report, []byte(scanReport), 0644)
+	// the scanner gets the image as its last argument
+	script := filepath.Join(dir, "scan.sh")
+	ioutil.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\n[ \"$1\" = dev-vp0-mycc ] || exit 1\ncat %s\n", report)), 0755)
+
+	scanner := &imageScanner{command: []string{"sh", script}, threshold: "CRITICAL", timeout: 10 * time.Second}
+	summary, err := scanner.Check(context.Background(), "mycc", "dev-vp0-mycc")
+	if err == nil || !strings.Contains(err.Error(), "CVE-2016-0001 (openssl)") {
+		t.Fatalf("Expected the critical finding to block the deployment, got %v", err)
+	}
+	if summary != "3 findings: 1 CRITICAL, 1 HIGH, 1 LOW" {
+		t.Fatalf("Unexpected summary %q", summary)
+	}
+	if _, err = scanner.Check(context.Background(), "mycc", "dev-vp0-other"); err == nil {
+		t.Fatalf("Expected a failing scanner to block the deployment")
+	}
+
+	results, err := parseScanReport([]byte(scanReport))
+	if err != nil {
+		t.Fatalf("Error parsing report: %s", err)
+	}
+	scanner.threshold = "HIGH"
+	if _, err = scanner.evaluate(results); err == nil || !strings.HasPrefix(err.Error(), "2 findings at or above HIGH") {
+		t.Fatalf("Expected 2 blocking findings, got %v", err)
+	}
+	scanner.threshold = "CRITICAL"
+	if summary, err = scanner.evaluate(results[1:]); err != nil || summary != "0 findings" {
+		t.Fatalf("Expected a clean image to pass, got %q, %v", summary, err)
+	}
+}
+
+type testHook struct {
+	name string
+	err  error
+	runs int
+}
+
+func (h *testHook) Name() string { return h.name }
+
+func (h *testHook) Check(ctxt context.Context, chaincode string, image string) (string, error) {
+	h.runs++
+	return "checked " + image, h.err
+}
+
+func TestPreLaunchHooks(t *testing.T) {
+	passing := &testHook{name: "passing"}
+	blocking := &testHook{name: "blocking", err: fmt.Errorf("not allowed")}
+	last := &testHook{name: "last"}
+	chaincodeSupport := &ChaincodeSupport{preLaunchHooks: []PreLaunchHook{passing, blocking, last}}
+
+	SetDeploymentStatus("hookedcc", pb.DeploymentStatus_SUBMITTED, "")
+	SetDeploymentStatus("hookedcc", pb.DeploymentStatus_BUILDING, "")
+	err := chaincodeSupport.runPreLaunchHooks(context.Background(), "hookedcc", "dev-vp0-hookedcc")
+	if err == nil || !strings.Contains(err.Error(), "blocked by blocking") {
+		t.Fatalf("Expected the deployment to be blocked, got %v", err)
+	}
+	if last.runs != 0 {
+		t.Fatalf("Expected the hooks after the blocking one not to run")
+	}
+	SetDeploymentStatus("hookedcc", pb.DeploymentStatus_FAILED, err.Error())
+
+	checks := GetDeploymentStatus("hookedcc").Checks
+	if len(checks) != 2 || !checks[0].Passed || checks[1].Passed || checks[1].Summary != "checked dev-vp0-hookedcc" {
+		t.Fatalf("Expected the results of both hooks to be recorded, got %v", checks)
+	}
+
+	SetDeploymentStatus("hookedcc", pb.DeploymentStatus_SUBMITTED, "")
+	if checks := GetDeploymentStatus("hookedcc").Checks; len(checks) != 0 {
+		t.Fatalf("Expected a new deployment to start without checks, got %v", checks)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// importPolicy restricts the packages Go chaincodes may import, eg. to keep
+// them from doing I/O or making system calls
+type importPolicy struct {
+	allowed []string
+	denied  []string
+}
+
+// newImportPolicy reads the import policy from chaincode.golang.imports
+func newImportPolicy() *importPolicy {
+	return &importPolicy{
+		allowed: viper.GetStringSlice("chaincode.golang.imports.allowed"),
+		denied:  viper.GetStringSlice("chaincode.golang.imports.denied"),
+	}
+}
+
+// CheckPackageImports checks the imports of the Go chaincode packaged in cds
+// against the import policy of this peer, so that deployments validators
+// with the same policy would refuse to build fail before they are sent
+func CheckPackageImports(cds *pb.ChaincodeDeploymentSpec) error {
+	return newImportPolicy().verify(cds)
+}
+
+// matchImport returns whether the import path matches one of patterns. A
+// pattern ending in /... matches the package and the packages under it.
+func matchImport(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/...") {
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// permits returns whether the policy allows the package at path to be imported
+func (p *importPolicy) permits(path string) bool {
+	if matchImport(p.denied, path) {
+		return false
+	}
+	return len(p.allowed) == 0 || matchImport(p.allowed, path)
+}
+
+// verify returns an error listing the imports of the Go chaincode packaged
+// in cds that the policy refuses. The imports of the packages of the
+// chaincode and of the packages vendored with it are checked; the packages
+// of the chaincode and the vendored packages themselves may be imported.
+// The imports of other packages are not checked, a package allowed is
+// trusted with its own imports.
+func (p *importPolicy) verify(cds *pb.ChaincodeDeploymentSpec) error {
+	if len(p.allowed) == 0 && len(p.denied) == 0 {
+		return nil
+	}
+	spec := cds.ChaincodeSpec
+	if len(cds.CodePackage) == 0 || spec == nil || spec.Type != pb.ChaincodeSpec_GOLANG || spec.ChaincodeID == nil {
+		return nil
+	}
+	dir := chaincodeSourceDir(spec.ChaincodeID.Path)
+	sources, err := readPackageSources(cds.CodePackage, dir)
+	if err != nil {
+		return fmt.Errorf("Error reading the code of the chaincode: %s", err)
+	}
+	own := strings.TrimPrefix(dir, "src/")
+	vendored := make(map[string]bool)
+	for name := range sources {
+		if pkg := vendoredPackage(name); pkg != "" {
+			vendored[pkg] = true
+		}
+	}
+
+	refused := make(map[string]bool)
+	fset := token.NewFileSet()
+	for name, src := range sources {
+		file, err := parser.ParseFile(fset, name, src, parser.ImportsOnly)
+		if err != nil {
+			return fmt.Errorf("Error parsing the code of the chaincode: %s", err)
+		}
+		for _, spec := range file.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || path == own || strings.HasPrefix(path, own+"/") || vendored[path] {
+				continue
+			}
+			if !p.permits(path) {
+				refused[path] = true
+			}
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	var paths []string
+	for path := range refused {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return fmt.Errorf("Imports of %s are not permitted", strings.Join(paths, ", "))
+}
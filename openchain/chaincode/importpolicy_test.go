@@ -0,0 +1,71 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestMatchImport(t *testing.T) {
+	patterns := []string{"fmt", "encoding/..."}
+	for path, expected := range map[string]bool{"fmt": true, "fmt/x": false, "encoding": true, "encoding/json": true, "encodings": false, "os": false} {
+		if matchImport(patterns, path) != expected {
+			t.Fatalf("Expected match of %s to be %t", path, expected)
+		}
+	}
+}
+
+func TestImportPolicy(t *testing.T) {
+	cds := &pb.ChaincodeDeploymentSpec{
+		ChaincodeSpec: &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "github.com/mycc"}},
+		CodePackage: newAnalyzedPackage(t, map[string]string{
+			"src/github.com/mycc/mycc.go":                    "package main\nimport (\n\"fmt\"\n\"os\"\n\"github.com/mycc/util\"\n\"github.com/dep\"\n)\n",
+			"src/github.com/mycc/util/util.go":               "package util\nimport \"encoding/json\"\n",
+			"src/github.com/mycc/vendor/github.com/dep/d.go": "package dep\nimport \"net/http\"\n",
+			"src/github.com/mycc/mycc_test.go":               "package main\nimport \"testing\"\n",
+			"src/github.com/other/other.go":                  "package other\nimport \"syscall\"\n",
+		}),
+	}
+
+	if err := (&importPolicy{}).verify(cds); err != nil {
+		t.Fatalf("Expected any import to be permitted without a policy, got %s", err)
+	}
+
+	p := &importPolicy{denied: []string{"os/...", "net/...", "syscall"}}
+	err := p.verify(cds)
+	if err == nil || !strings.Contains(err.Error(), "net/http, os") || strings.Contains(err.Error(), "syscall") {
+		t.Fatalf("Expected the imports of os and of net/http by the vendored package to be refused, got %v", err)
+	}
+
+	p = &importPolicy{allowed: []string{"fmt", "os", "encoding/..."}}
+	err = p.verify(cds)
+	if err == nil || err.Error() != "Imports of net/http are not permitted" {
+		t.Fatalf("Expected net/http alone to be refused, got %v", err)
+	}
+
+	// Denied packages are refused even when allowed
+	p = &importPolicy{allowed: []string{"fmt", "os", "encoding/...", "net/..."}, denied: []string{"os"}}
+	if err = p.verify(cds); err == nil || err.Error() != "Imports of os are not permitted" {
+		t.Fatalf("Expected os to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// encryptKey returns the key under which a key of the chaincode is stored.
+// Keys are only encrypted for chaincodes deployed with DETERMINISTIC key
+// encryption, so that equal keys map to equal ciphertexts
+func (handler *Handler) encryptKey(uuid string, key string) (string, error) {
+	if handler.keyEncryption != pb.ChaincodeSpec_DETERMINISTIC {
+		return key, nil
+	}
+	enc, err := handler.getStateEncryptor(uuid)
+	if err != nil || enc == nil {
+		return key, err
+	}
+	return enc.EncryptKey(key)
+}
+
+// decryptRangeScanKeys decrypts the keys returned by iter and selects those in
+// [startKey, endKey], an empty endKey meaning no upper bound. The ciphertexts
+// of the keys do not preserve their order, so iter is expected to cover the
+// whole state of the chaincode and is consumed and closed here. Values are
+// left encrypted
+func (handler *Handler) decryptRangeScanKeys(uuid string, iter statemgmt.RangeScanIterator, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	defer iter.Close()
+
+	enc, err := handler.getStateEncryptor(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := &sortedRangeScanIterator{index: -1}
+	for iter.Next() {
+		key, value := iter.GetKeyValue()
+		if enc != nil {
+			if key, err = enc.DecryptKey(key); err != nil {
+				return nil, fmt.Errorf("Failed decrypting key: %s", err)
+			}
+		}
+		if key < startKey || (endKey != "" && key > endKey) {
+			continue
+		}
+		sorted.keys = append(sorted.keys, key)
+		sorted.values = append(sorted.values, value)
+	}
+	sort.Sort(sorted)
+	return sorted, nil
+}
+
+// sortedRangeScanIterator is an in-memory RangeScanIterator over key-values
+// sorted by key
+type sortedRangeScanIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func (iter *sortedRangeScanIterator) Len() int { return len(iter.keys) }
+
+func (iter *sortedRangeScanIterator) Less(i, j int) bool { return iter.keys[i] < iter.keys[j] }
+
+func (iter *sortedRangeScanIterator) Swap(i, j int) {
+	iter.keys[i], iter.keys[j] = iter.keys[j], iter.keys[i]
+	iter.values[i], iter.values[j] = iter.values[j], iter.values[i]
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (iter *sortedRangeScanIterator) Next() bool {
+	if iter.index+1 >= len(iter.keys) {
+		return false
+	}
+	iter.index++
+	return true
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (iter *sortedRangeScanIterator) GetKeyValue() (string, []byte) {
+	return iter.keys[iter.index], iter.values[iter.index]
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (iter *sortedRangeScanIterator) Close() {
+	iter.keys = nil
+	iter.values = nil
+}
@@ -0,0 +1,74 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSortedRangeScanIterator(t *testing.T) {
+	iter := &sortedRangeScanIterator{
+		keys:   []string{"c", "a", "b"},
+		values: [][]byte{[]byte("3"), []byte("1"), []byte("2")},
+		index:  -1,
+	}
+	sort.Sort(iter)
+
+	var keys []string
+	var values []string
+	for iter.Next() {
+		k, v := iter.GetKeyValue()
+		keys = append(keys, k)
+		values = append(values, string(v))
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("Unexpected keys: %v", keys)
+	}
+	if !reflect.DeepEqual(values, []string{"1", "2", "3"}) {
+		t.Fatalf("Unexpected values: %v", values)
+	}
+	if iter.Next() {
+		t.Fatalf("Expected iterator to be exhausted")
+	}
+	iter.Close()
+}
+
+func TestDecryptRangeScanKeys_Plaintext(t *testing.T) {
+	// without security the keys are returned as they are, filtered by range
+	handler := &Handler{chaincodeSupport: &ChaincodeSupport{}}
+	scan := &sortedRangeScanIterator{
+		keys:   []string{"d", "b", "a", "c"},
+		values: [][]byte{[]byte("4"), []byte("2"), []byte("1"), []byte("3")},
+		index:  -1,
+	}
+	iter, err := handler.decryptRangeScanKeys("tx1", scan, "b", "c")
+	if err != nil {
+		t.Fatalf("Error selecting range: %s", err)
+	}
+	var keys []string
+	for iter.Next() {
+		k, _ := iter.GetKeyValue()
+		keys = append(keys, k)
+	}
+	if !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Fatalf("Unexpected keys: %v", keys)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// txKeyHints records the keys declared by the invoke transactions in
+// progress. When key hints are strict, a transaction that declares keys may
+// not access any other key, which is what allows the transactions of a batch
+// declaring disjoint keys to be executed concurrently.
+type txKeyHints struct {
+	sync.Mutex
+	strict bool
+	keys   map[string]map[string]bool
+}
+
+func newTxKeyHints() *txKeyHints {
+	return &txKeyHints{strict: viper.GetBool("chaincode.keyhints.strict"), keys: make(map[string]map[string]bool)}
+}
+
+// declare records the keys declared by a transaction. Nothing is recorded
+// unless key hints are strict, as the keys are only hints otherwise.
+func (h *txKeyHints) declare(uuid string, names []string) {
+	if !h.strict || len(names) == 0 {
+		return
+	}
+	declared := make(map[string]bool, len(names))
+	for _, name := range names {
+		declared[name] = true
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.keys[uuid] = declared
+}
+
+// check returns an error if the transaction declared its keys and the key
+// is not one of them
+func (h *txKeyHints) check(uuid string, chaincodeID string, key string) error {
+	h.Lock()
+	defer h.Unlock()
+	declared, ok := h.keys[uuid]
+	if ok && !declared[lockName(chaincodeID, key)] {
+		return fmt.Errorf("[%s]Key %s of chaincode %s was not declared by the transaction", shortuuid(uuid), key, chaincodeID)
+	}
+	return nil
+}
+
+// checkRange returns an error if the transaction declared its keys, as the
+// keys a range query returns cannot be declared upfront
+func (h *txKeyHints) checkRange(uuid string, chaincodeID string) error {
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.keys[uuid]; ok {
+		return fmt.Errorf("[%s]Range query on chaincode %s is not allowed for a transaction that declared its keys", shortuuid(uuid), chaincodeID)
+	}
+	return nil
+}
+
+func (h *txKeyHints) finish(uuid string) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.keys, uuid)
+}
+
+// transactionKeyHints returns the lock names of the keys declared by an
+// invoke transaction for the chaincode it invokes and the chaincodes that
+// one invokes, or nil if it declares none
+func transactionKeyHints(t *pb.Transaction) ([]string, error) {
+	if t.Type != pb.Transaction_CHAINCODE_EXECUTE {
+		return nil, nil
+	}
+	ci := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(t.Payload, ci); err != nil {
+		return nil, err
+	}
+	spec := ci.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil || (len(ci.KeyHints) == 0 && len(ci.CalleeKeyHints) == 0) {
+		return nil, nil
+	}
+	var names []string
+	for _, key := range ci.KeyHints {
+		names = append(names, lockName(spec.ChaincodeID.Name, key))
+	}
+	for _, callee := range ci.CalleeKeyHints {
+		for _, key := range callee.Keys {
+			names = append(names, lockName(callee.ChaincodeName, key))
+		}
+	}
+	return names, nil
+}
+
+// scheduleTransactions returns for each transaction of a batch the indexes of
+// the earlier transactions that must finish before it is executed. When key
+// hints are strict, public transactions declaring disjoint keys cannot affect
+// each other and do not wait for each other, whatever order they finish in.
+// Any other transaction waits for all the transactions before it, and all
+// the transactions after it wait for it.
+func (chaincodeSupport *ChaincodeSupport) scheduleTransactions(xacts []*pb.Transaction) [][]int {
+	hints := make([][]string, len(xacts))
+	if chaincodeSupport.keyHints.strict {
+		for i, t := range xacts {
+			if t.ConfidentialityLevel != pb.ConfidentialityLevel_PUBLIC {
+				// The payload is encrypted until the transaction is executed
+				continue
+			}
+			hints[i], _ = transactionKeyHints(t)
+		}
+	}
+	deps := make([][]int, len(xacts))
+	for i := range xacts {
+		for j := 0; j < i; j++ {
+			if hintsOverlap(hints[i], hints[j]) {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+	return deps
+}
+
+// hintsOverlap tells whether two transactions may access the same keys. A
+// transaction that did not declare its keys may access any key.
+func hintsOverlap(a []string, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
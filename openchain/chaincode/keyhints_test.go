@@ -0,0 +1,117 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestTxKeyHints_Strict(t *testing.T) {
+	h := &txKeyHints{strict: true, keys: make(map[string]map[string]bool)}
+	h.declare("tx1", []string{lockName("cc", "a")})
+	if err := h.check("tx1", "cc", "a"); err != nil {
+		t.Fatalf("Error accessing declared key: %s", err)
+	}
+	if err := h.check("tx1", "cc", "b"); err == nil {
+		t.Fatalf("Expected error accessing undeclared key")
+	}
+	if err := h.check("tx1", "other", "a"); err == nil {
+		t.Fatalf("Expected error accessing key of another chaincode")
+	}
+	if err := h.checkRange("tx1", "cc"); err == nil {
+		t.Fatalf("Expected error running range query")
+	}
+	// transactions that did not declare keys may access any key
+	if err := h.check("tx2", "cc", "b"); err != nil {
+		t.Fatalf("Error accessing key without hints: %s", err)
+	}
+	if err := h.checkRange("tx2", "cc"); err != nil {
+		t.Fatalf("Error running range query without hints: %s", err)
+	}
+	h.finish("tx1")
+	if err := h.check("tx1", "cc", "b"); err != nil {
+		t.Fatalf("Error accessing key after transaction finished: %s", err)
+	}
+}
+
+func TestTxKeyHints_NotStrict(t *testing.T) {
+	h := &txKeyHints{keys: make(map[string]map[string]bool)}
+	h.declare("tx1", []string{lockName("cc", "a")})
+	if err := h.check("tx1", "cc", "b"); err != nil {
+		t.Fatalf("Error accessing undeclared key: %s", err)
+	}
+}
+
+func hintedInvokeTx(t *testing.T, keys ...string) *pb.Transaction {
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "cc"}}
+	payload, err := proto.Marshal(&pb.ChaincodeInvocationSpec{ChaincodeSpec: spec, KeyHints: keys})
+	if err != nil {
+		t.Fatalf("Error marshalling invocation: %s", err)
+	}
+	return &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Payload: payload}
+}
+
+func TestTransactionKeyHints_Callees(t *testing.T) {
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "cc"}}
+	callees := []*pb.ChaincodeKeyHints{{ChaincodeName: "callee", Keys: []string{"b"}}}
+	payload, err := proto.Marshal(&pb.ChaincodeInvocationSpec{ChaincodeSpec: spec, KeyHints: []string{"a"}, CalleeKeyHints: callees})
+	if err != nil {
+		t.Fatalf("Error marshalling invocation: %s", err)
+	}
+	names, err := transactionKeyHints(&pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Payload: payload})
+	if err != nil {
+		t.Fatalf("Error reading key hints: %s", err)
+	}
+
+	h := &txKeyHints{strict: true, keys: make(map[string]map[string]bool)}
+	h.declare("tx1", names)
+	if err := h.check("tx1", "callee", "b"); err != nil {
+		t.Fatalf("Error accessing declared key of the invoked chaincode: %s", err)
+	}
+	if err := h.check("tx1", "callee", "a"); err == nil {
+		t.Fatalf("Expected error accessing key declared for another chaincode")
+	}
+}
+
+func TestScheduleTransactions(t *testing.T) {
+	xacts := []*pb.Transaction{
+		hintedInvokeTx(t, "a"),
+		hintedInvokeTx(t, "b"),
+		hintedInvokeTx(t, "a", "c"),
+		hintedInvokeTx(t),
+		hintedInvokeTx(t, "d"),
+	}
+	chain := &ChaincodeSupport{keyHints: &txKeyHints{strict: true}}
+	expected := [][]int{nil, nil, {0}, {0, 1, 2}, {3}}
+	if deps := chain.scheduleTransactions(xacts); !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("Expected strict schedule %v, got %v", expected, deps)
+	}
+
+	chain.keyHints.strict = false
+	expected = [][]int{nil, {0}, {0, 1}, {0, 1, 2}, {0, 1, 2, 3}}
+	if deps := chain.scheduleTransactions(xacts); !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("Expected sequential schedule %v, got %v", expected, deps)
+	}
+}
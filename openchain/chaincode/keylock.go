@@ -0,0 +1,126 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+)
+
+// keyLockManager lets transactions run concurrently as long as the state
+// keys they touch do not overlap. A transaction locks the keys it declares
+// before it runs and the keys it reads or writes as it runs, and holds them
+// until it finishes.
+//
+// Deadlocks are avoided by ordered acquisition: a transaction only waits for
+// a key that sorts after every key it already holds. A key that sorts before
+// one it holds is taken if it is free, otherwise the request fails and the
+// transaction has to be resubmitted. Transactions that declare all of their
+// keys upfront therefore never fail to get a lock.
+//
+// Only transactions started with begin take locks. Simulated transactions,
+// whose state changes are discarded, read without locking. Range queries do
+// not lock the keys they return.
+type keyLockManager struct {
+	sync.Mutex
+	cond *sync.Cond
+	// owners maps locked keys to the uuid of the transaction holding them
+	owners map[string]string
+	// held maps the uuid of every transaction in progress to the sorted keys it holds
+	held map[string][]string
+}
+
+func newKeyLockManager() *keyLockManager {
+	m := &keyLockManager{owners: make(map[string]string), held: make(map[string][]string)}
+	m.cond = sync.NewCond(m)
+	return m
+}
+
+// lockName returns the name of the lock for a key of a chaincode's state
+func lockName(chaincodeID string, key string) string {
+	return string(statemgmt.ConstructCompositeKey(chaincodeID, key))
+}
+
+// begin marks the start of a transaction that takes locks
+func (m *keyLockManager) begin(uuid string) {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.held[uuid]; !ok {
+		m.held[uuid] = nil
+	}
+}
+
+// lock acquires the named locks for the transaction in sorted order. Locks
+// already held by the transaction are skipped.
+func (m *keyLockManager) lock(uuid string, names ...string) error {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.Strings(sorted)
+
+	m.Lock()
+	defer m.Unlock()
+	for _, name := range sorted {
+		for {
+			held, ok := m.held[uuid]
+			if !ok {
+				// The transaction was never begun or has already finished
+				return nil
+			}
+			owner, locked := m.owners[name]
+			if !locked {
+				m.owners[name] = uuid
+				m.held[uuid] = insertSorted(held, name)
+				break
+			}
+			if owner == uuid {
+				break
+			}
+			if len(held) > 0 && name < held[len(held)-1] {
+				chaincodeID, key := statemgmt.DecodeCompositeKey([]byte(name))
+				return fmt.Errorf("[%s]Key %s of chaincode %s is locked by transaction %s and cannot be waited for out of order", shortuuid(uuid), key, chaincodeID, owner)
+			}
+			chaincodeLogger.Debug("[%s]Waiting for lock held by transaction %s", shortuuid(uuid), shortuuid(owner))
+			m.cond.Wait()
+		}
+	}
+	return nil
+}
+
+// unlock releases all the locks held by the transaction and marks its end
+func (m *keyLockManager) unlock(uuid string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, name := range m.held[uuid] {
+		delete(m.owners, name)
+	}
+	delete(m.held, uuid)
+	m.cond.Broadcast()
+}
+
+func insertSorted(names []string, name string) []string {
+	i := sort.SearchStrings(names, name)
+	names = append(names, "")
+	copy(names[i+1:], names[i:])
+	names[i] = name
+	return names
+}
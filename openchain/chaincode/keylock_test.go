@@ -0,0 +1,98 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyLockManager_DisjointKeys(t *testing.T) {
+	m := newKeyLockManager()
+	m.begin("tx1")
+	m.begin("tx2")
+	if err := m.lock("tx1", lockName("cc", "a"), lockName("cc", "b")); err != nil {
+		t.Fatalf("Error locking keys: %s", err)
+	}
+	if err := m.lock("tx2", lockName("cc", "c")); err != nil {
+		t.Fatalf("Error locking disjoint key: %s", err)
+	}
+	// locks are reentrant and locks of transactions not begun are ignored
+	if err := m.lock("tx1", lockName("cc", "a")); err != nil {
+		t.Fatalf("Error relocking held key: %s", err)
+	}
+	if err := m.lock("simulation", lockName("cc", "a")); err != nil {
+		t.Fatalf("Error locking key for transaction not begun: %s", err)
+	}
+}
+
+func TestKeyLockManager_WaitInOrder(t *testing.T) {
+	m := newKeyLockManager()
+	m.begin("tx1")
+	m.begin("tx2")
+	m.lock("tx1", lockName("cc", "b"))
+	m.lock("tx2", lockName("cc", "a"))
+
+	locked := make(chan error)
+	go func() {
+		locked <- m.lock("tx2", lockName("cc", "b"))
+	}()
+	select {
+	case err := <-locked:
+		t.Fatalf("Expected to wait for the key held by another transaction, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.unlock("tx1")
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatalf("Error waiting for key: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the key to be released")
+	}
+}
+
+func TestKeyLockManager_OutOfOrder(t *testing.T) {
+	m := newKeyLockManager()
+	m.begin("tx1")
+	m.begin("tx2")
+	m.lock("tx1", lockName("cc", "a"))
+	m.lock("tx2", lockName("cc", "b"))
+
+	// tx2 holds b, so waiting for a could deadlock with tx1 waiting for b
+	if err := m.lock("tx2", lockName("cc", "a")); err == nil {
+		t.Fatalf("Expected locking out of order to fail")
+	}
+	// a free key can be taken out of order
+	m.begin("tx3")
+	m.lock("tx3", lockName("cc", "z"))
+	if err := m.lock("tx3", lockName("cc", "y")); err != nil {
+		t.Fatalf("Error locking free key out of order: %s", err)
+	}
+
+	// keys declared together are taken in order
+	m.unlock("tx1")
+	m.unlock("tx2")
+	m.begin("tx4")
+	if err := m.lock("tx4", lockName("cc", "b"), lockName("cc", "a")); err != nil {
+		t.Fatalf("Error locking declared keys: %s", err)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// keyRotationNamespace is the state namespace recording the key rotation
+// transactions of confidential chaincodes under the name of the chaincode.
+// Chaincodes can only access the state namespace of their own name.
+const keyRotationNamespace = "__keyrotation"
+
+// rotateStateKey records a key rotation transaction for the chaincode it
+// targets. The transactions that follow encrypt the state of the chaincode
+// under the key of the new epoch, and values encrypted under previous keys
+// are re-encrypted the next time they are written.
+func rotateStateKey(chain *ChaincodeSupport, t *pb.Transaction) error {
+	if chain.getSecHelper() == nil {
+		return fmt.Errorf("Key rotation transaction %s requires security to be enabled", t.Uuid)
+	}
+
+	ci := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(t.Payload, ci); err != nil {
+		return err
+	}
+	if ci.ChaincodeSpec == nil || ci.ChaincodeSpec.ChaincodeID == nil {
+		return fmt.Errorf("Key rotation transaction %s does not name a chaincode", t.Uuid)
+	}
+	chaincode := ci.ChaincodeSpec.ChaincodeID.Name
+
+	ledgerObj, err := ledger.GetChainLedger(t.ChainID)
+	if err != nil {
+		return fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	depTx, err := ledgerObj.GetTransactionByUUID(chaincode)
+	if err != nil || depTx == nil {
+		return fmt.Errorf("Chaincode %s is not deployed", chaincode)
+	}
+	if depTx.ConfidentialityLevel != pb.ConfidentialityLevel_CONFIDENTIAL {
+		return fmt.Errorf("State of chaincode %s is not encrypted", chaincode)
+	}
+
+	raw, err := chain.getTxState(ledgerObj, t.Uuid, keyRotationNamespace, chaincode)
+	if err != nil {
+		return err
+	}
+	rotations, err := unmarshalKeyRotations(raw)
+	if err != nil {
+		return err
+	}
+
+	// Only the nonce of the transaction is needed to derive the key of the
+	// epoch, along with the chaincode it applies to
+	rotations = append(rotations, &pb.Transaction{Type: t.Type, ChaincodeID: t.ChaincodeID, Nonce: t.Nonce, Uuid: t.Uuid, Timestamp: t.Timestamp})
+	if raw, err = proto.Marshal(&pb.TransactionBlock{Transactions: rotations}); err != nil {
+		return err
+	}
+	chaincodeLogger.Debug("[%s]Starting key epoch %d of chaincode %s", shortuuid(t.Uuid), len(rotations), chaincode)
+	return chain.setTxState(t.Uuid, keyRotationNamespace, chaincode, raw)
+}
+
+// getKeyRotations returns the key rotation transactions recorded for the
+// chaincode of the handler, oldest first
+func (handler *Handler) getKeyRotations() ([]*pb.Transaction, error) {
+	ledgerObj, err := ledger.GetChainLedger(handler.chainID())
+	if err != nil {
+		return nil, err
+	}
+	stateLock.RLock()
+	raw, err := ledgerObj.GetState(keyRotationNamespace, handler.ChaincodeID.Name, false)
+	stateLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalKeyRotations(raw)
+}
+
+func unmarshalKeyRotations(raw []byte) ([]*pb.Transaction, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	block := &pb.TransactionBlock{}
+	if err := proto.Unmarshal(raw, block); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal key rotations (%s)", err)
+	}
+	return block.Transactions, nil
+}
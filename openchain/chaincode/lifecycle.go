@@ -0,0 +1,236 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/container"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// ListChaincodes returns what the peer knows of every chaincode deployed on
+// the ledger of the chain, registered as a system chaincode or connected to
+// the peer:
+// where it was deployed, the hash of its code, the progress of its
+// deployment, the status of its container and the state of its handler.
+// Chaincodes are sorted by name.
+func (chaincodeSupport *ChaincodeSupport) ListChaincodes(ctxt context.Context, chainID string) ([]*pb.ChaincodeInfo, error) {
+	infos := make(map[string]*pb.ChaincodeInfo)
+	getInfo := func(name string) *pb.ChaincodeInfo {
+		info, ok := infos[name]
+		if !ok {
+			info = &pb.ChaincodeInfo{ChaincodeID: &pb.ChaincodeID{Name: name}}
+			infos[name] = info
+		}
+		return info
+	}
+
+	deployed, err := getDeployIndex(chainID).update()
+	if err != nil {
+		return nil, err
+	}
+	for name, deploy := range deployed {
+		infos[name] = proto.Clone(deploy).(*pb.ChaincodeInfo)
+	}
+
+	sysccs.RLock()
+	for name, entry := range sysccs.entries {
+		info := getInfo(name)
+		info.ChaincodeID.Path = entry.syscc.Path
+		info.ExecEnv = pb.ChaincodeDeploymentSpec_SYSTEM
+	}
+	sysccs.RUnlock()
+
+	chaincodeSupport.handlerMap.RLock()
+	for name, handler := range chaincodeSupport.handlerMap.chaincodeMap {
+		info := getInfo(name)
+		//handlers are registered before the chaincode connects
+		if handler.FSM == nil {
+			info.HandlerState = "launching"
+		} else {
+			info.HandlerState = handler.FSM.Current()
+		}
+	}
+	chaincodeSupport.handlerMap.RUnlock()
+
+	var names []string
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]*pb.ChaincodeInfo, 0, len(names))
+	for _, name := range names {
+		info := infos[name]
+		info.DeploymentPhase = GetDeploymentStatus(name).Phase
+		info.ContainerStatus = chaincodeSupport.getContainerStatus(ctxt, name)
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+//deployIndex holds the chaincodes deployed on the ledger of a chain, by name,
+//up to the height of the blockchain when it was last updated so that listing
+//chaincodes only reads the blocks added since. The hash of the last block
+//read tells whether the blockchain was replaced since.
+type deployIndex struct {
+	sync.Mutex
+	chainID   string
+	height    uint64
+	blockHash []byte
+	deployed  map[string]*pb.ChaincodeInfo
+}
+
+func newDeployIndex(chainID string) *deployIndex {
+	return &deployIndex{chainID: chainID, deployed: make(map[string]*pb.ChaincodeInfo)}
+}
+
+//deployIndexes holds the deployment index of each chain, by chain ID
+var deployIndexes = struct {
+	sync.Mutex
+	byChain map[string]*deployIndex
+}{byChain: make(map[string]*deployIndex)}
+
+//getDeployIndex returns the deployment index of the chain
+func getDeployIndex(chainID string) *deployIndex {
+	deployIndexes.Lock()
+	defer deployIndexes.Unlock()
+	index, ok := deployIndexes.byChain[chainID]
+	if !ok {
+		index = newDeployIndex(chainID)
+		deployIndexes.byChain[chainID] = index
+	}
+	return index
+}
+
+//update reads the deployments of the blocks added since the last update and
+//returns the chaincodes deployed, which must not be modified
+func (index *deployIndex) update() (map[string]*pb.ChaincodeInfo, error) {
+	ledger, err := ledger.GetChainLedger(index.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting ledger: %s", err)
+	}
+
+	index.Lock()
+	defer index.Unlock()
+	size := ledger.GetBlockchainSize()
+	if index.height > 0 {
+		replaced := size < index.height
+		if !replaced {
+			block, err := ledger.GetBlockByNumber(index.height - 1)
+			if err != nil {
+				return nil, fmt.Errorf("Error getting block %d: %s", index.height-1, err)
+			}
+			hash, err := block.GetHash()
+			if err != nil {
+				return nil, fmt.Errorf("Error hashing block %d: %s", index.height-1, err)
+			}
+			replaced = !bytes.Equal(hash, index.blockHash)
+		}
+		if replaced {
+			//the blockchain was replaced, by state transfer for instance
+			index.height = 0
+			index.blockHash = nil
+			index.deployed = make(map[string]*pb.ChaincodeInfo)
+		}
+	}
+	getInfo := func(name string) *pb.ChaincodeInfo {
+		info, ok := index.deployed[name]
+		if !ok {
+			info = &pb.ChaincodeInfo{ChaincodeID: &pb.ChaincodeID{Name: name}}
+			index.deployed[name] = info
+		}
+		return info
+	}
+	for ; index.height < size; index.height++ {
+		block, err := ledger.GetBlockByNumber(index.height)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting block %d: %s", index.height, err)
+		}
+		for _, tx := range block.Transactions {
+			if tx.Type != pb.Transaction_CHAINCODE_NEW {
+				continue
+			}
+			addDeployTx(getInfo, tx, index.height)
+		}
+		if index.height+1 == size {
+			if index.blockHash, err = block.GetHash(); err != nil {
+				return nil, fmt.Errorf("Error hashing block %d: %s", index.height, err)
+			}
+		}
+	}
+	deployed := make(map[string]*pb.ChaincodeInfo, len(index.deployed))
+	for name, info := range index.deployed {
+		deployed[name] = info
+	}
+	return deployed, nil
+}
+
+//addDeployTx records the chaincode deployed by tx in block blockNumber
+func addDeployTx(getInfo func(string) *pb.ChaincodeInfo, tx *pb.Transaction, blockNumber uint64) {
+	//the deploy transaction is named after the chaincode it deploys
+	cID := &pb.ChaincodeID{Name: tx.Uuid}
+	if err := proto.Unmarshal(tx.ChaincodeID, cID); err != nil || cID.Name == "" {
+		cID = &pb.ChaincodeID{Name: tx.Uuid}
+	}
+	info := getInfo(cID.Name)
+	info.ChaincodeID = cID
+	info.DeployBlock = blockNumber
+	info.Deployed = true
+
+	//the payload of confidential deployments cannot be read here
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(tx.Payload, cds); err != nil || cds.ChaincodeSpec == nil {
+		chaincodeLog.Debug("Cannot read deployment spec of %s: %v", cID.Name, err)
+		return
+	}
+	info.Type = cds.ChaincodeSpec.Type
+	info.ExecEnv = cds.ExecEnv
+	if len(cds.CodePackage) > 0 {
+		info.CodeHash = fmt.Sprintf("%x", util.ComputeCryptoHash(cds.CodePackage))
+	}
+}
+
+//getContainerStatus returns the status of the container of the chaincode,
+//empty if chaincodes are not run in containers by the peer
+func (chaincodeSupport *ChaincodeSupport) getContainerStatus(ctxt context.Context, chaincode string) string {
+	if chaincodeSupport.userRunsCC && !IsSysCC(chaincode) {
+		return ""
+	}
+	vmtype, vmname := getVMTypeAndName(chaincode)
+	resp, err := container.VMCProcess(ctxt, vmtype, container.StatusReq{ID: vmname})
+	if err == nil && resp.(container.VMCResp).Err != nil {
+		err = resp.(container.VMCResp).Err
+	}
+	if err != nil {
+		chaincodeLog.Debug("Error getting status of container of %s: %s", chaincode, err)
+		return "unknown"
+	}
+	return resp.(container.VMCResp).Resp.(string)
+}
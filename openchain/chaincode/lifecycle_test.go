@@ -0,0 +1,109 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestLifecycle_AddDeployTx(t *testing.T) {
+	infos := make(map[string]*pb.ChaincodeInfo)
+	getInfo := func(name string) *pb.ChaincodeInfo {
+		if _, ok := infos[name]; !ok {
+			infos[name] = &pb.ChaincodeInfo{ChaincodeID: &pb.ChaincodeID{Name: name}}
+		}
+		return infos[name]
+	}
+
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "example02", Name: "mycc"}}
+	cds := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte("code")}
+	tx, err := pb.NewChaincodeDeployTransaction(cds, "mycc")
+	if err != nil {
+		t.Fatalf("Error creating deploy transaction: %s", err)
+	}
+	addDeployTx(getInfo, tx, 3)
+
+	info := infos["mycc"]
+	if info == nil || !info.Deployed || info.DeployBlock != 3 || info.ChaincodeID.Path != "example02" {
+		t.Fatalf("Unexpected info for deployed chaincode: %v", info)
+	}
+	if expected := fmt.Sprintf("%x", util.ComputeCryptoHash([]byte("code"))); info.CodeHash != expected {
+		t.Fatalf("Expected code hash %s, got %s", expected, info.CodeHash)
+	}
+
+	// the payload of confidential deployments is not readable
+	tx = &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Uuid: "secretcc", ChaincodeID: []byte{0xff}, Payload: []byte{0xff}}
+	addDeployTx(getInfo, tx, 4)
+	info = infos["secretcc"]
+	if info == nil || !info.Deployed || info.DeployBlock != 4 || info.CodeHash != "" {
+		t.Fatalf("Unexpected info for confidential chaincode: %v", info)
+	}
+}
+
+func TestLifecycle_DeployIndex(t *testing.T) {
+	ledgerObj := ledger.InitTestLedger(t)
+	deploys := newDeployIndex("")
+
+	commitDeploy := func(n int, name string) {
+		spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: name, Name: name}}
+		tx, err := pb.NewChaincodeDeployTransaction(&pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec}, name)
+		if err != nil {
+			t.Fatalf("Error creating deploy transaction: %s", err)
+		}
+		ledgerObj.BeginTxBatch(n)
+		if err := ledgerObj.CommitTxBatch(n, []*pb.Transaction{tx}, nil, nil); err != nil {
+			t.Fatalf("Error committing batch: %s", err)
+		}
+	}
+
+	commitDeploy(1, "cc1")
+	deployed, err := deploys.update()
+	if err != nil {
+		t.Fatalf("Error updating deployments: %s", err)
+	}
+	if len(deployed) != 1 || deployed["cc1"] == nil || deploys.height != ledgerObj.GetBlockchainSize() {
+		t.Fatalf("Unexpected deployments %v at height %d", deployed, deploys.height)
+	}
+
+	commitDeploy(2, "cc2")
+	deployed, err = deploys.update()
+	if err != nil {
+		t.Fatalf("Error updating deployments: %s", err)
+	}
+	if len(deployed) != 2 || deployed["cc2"] == nil || deployed["cc2"].DeployBlock != deploys.height-1 {
+		t.Fatalf("Expected the deployment of the new block to be added, got %v", deployed)
+	}
+
+	// a blockchain replaced by one of the same height has another last block
+	deploys.deployed["stale"] = &pb.ChaincodeInfo{ChaincodeID: &pb.ChaincodeID{Name: "stale"}}
+	deploys.blockHash = []byte("replaced")
+	deployed, err = deploys.update()
+	if err != nil {
+		t.Fatalf("Error updating deployments: %s", err)
+	}
+	if len(deployed) != 2 || deployed["stale"] != nil {
+		t.Fatalf("Expected the deployments to be read again, got %v", deployed)
+	}
+}
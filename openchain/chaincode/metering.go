@@ -0,0 +1,156 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/metrics"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// executionTimeMetric observes the wall-clock time of metered transactions.
+// It differs between validators, so it is never a cause of failure nor part
+// of the recorded usage.
+var executionTimeMetric = metrics.NewSummary("chaincode.executionMillis")
+
+// resourceLimits bounds the resources a transaction may use, 0 for no limit.
+// Only resources counted the same way by every validator are limited.
+type resourceLimits struct {
+	stateReads   uint64
+	stateWrites  uint64
+	bytesRead    uint64
+	bytesWritten uint64
+}
+
+// txMeter counts the resources used by a transaction in progress
+type txMeter struct {
+	usage    *pb.ResourceUsage
+	started  time.Time
+	exceeded error
+}
+
+// txMeters counts the resources used by the transactions in progress, and
+// fails the state requests of those exceeding the limits. The usage of a
+// transaction is kept until its block commits, to be recorded in its result.
+type txMeters struct {
+	sync.Mutex
+	limits   resourceLimits
+	running  map[string]*txMeter
+	finished map[string]*pb.ResourceUsage
+}
+
+func newTxMeters() *txMeters {
+	limits := resourceLimits{
+		stateReads:   uint64(viper.GetInt("chaincode.limits.stateReads")),
+		stateWrites:  uint64(viper.GetInt("chaincode.limits.stateWrites")),
+		bytesRead:    uint64(viper.GetInt("chaincode.limits.bytesRead")),
+		bytesWritten: uint64(viper.GetInt("chaincode.limits.bytesWritten")),
+	}
+	return &txMeters{limits: limits, running: make(map[string]*txMeter), finished: make(map[string]*pb.ResourceUsage)}
+}
+
+func (m *txMeters) begin(uuid string) {
+	m.Lock()
+	defer m.Unlock()
+	m.running[uuid] = &txMeter{usage: &pb.ResourceUsage{}, started: time.Now()}
+}
+
+// read counts keys read by a transaction and the size of the keys and values.
+// Requests of queries, which are not metered, always pass.
+func (m *txMeters) read(uuid string, keys int, size int) error {
+	m.Lock()
+	defer m.Unlock()
+	meter, ok := m.running[uuid]
+	if !ok {
+		return nil
+	}
+	meter.usage.StateReads += uint64(keys)
+	meter.usage.BytesRead += uint64(size)
+	return m.check(uuid, meter)
+}
+
+// write counts keys put or deleted by a transaction and the size of the
+// keys and values
+func (m *txMeters) write(uuid string, keys int, size int) error {
+	m.Lock()
+	defer m.Unlock()
+	meter, ok := m.running[uuid]
+	if !ok {
+		return nil
+	}
+	meter.usage.StateWrites += uint64(keys)
+	meter.usage.BytesWritten += uint64(size)
+	return m.check(uuid, meter)
+}
+
+// check returns an error once the transaction exceeds a limit. The error
+// sticks so that the transaction fails even if the chaincode ignores it.
+func (m *txMeters) check(uuid string, meter *txMeter) error {
+	if meter.exceeded != nil {
+		return meter.exceeded
+	}
+	usage, limits := meter.usage, m.limits
+	switch {
+	case limits.stateReads > 0 && usage.StateReads > limits.stateReads:
+		meter.exceeded = fmt.Errorf("[%s]Transaction exceeds the limit of %d state reads", shortuuid(uuid), limits.stateReads)
+	case limits.stateWrites > 0 && usage.StateWrites > limits.stateWrites:
+		meter.exceeded = fmt.Errorf("[%s]Transaction exceeds the limit of %d state writes", shortuuid(uuid), limits.stateWrites)
+	case limits.bytesRead > 0 && usage.BytesRead > limits.bytesRead:
+		meter.exceeded = fmt.Errorf("[%s]Transaction exceeds the limit of %d bytes read", shortuuid(uuid), limits.bytesRead)
+	case limits.bytesWritten > 0 && usage.BytesWritten > limits.bytesWritten:
+		meter.exceeded = fmt.Errorf("[%s]Transaction exceeds the limit of %d bytes written", shortuuid(uuid), limits.bytesWritten)
+	}
+	return meter.exceeded
+}
+
+// finish stops metering a transaction and returns the error of the limit it
+// exceeded, if any. Its usage is kept if keep is set.
+func (m *txMeters) finish(uuid string, keep bool) error {
+	m.Lock()
+	defer m.Unlock()
+	meter, ok := m.running[uuid]
+	if !ok {
+		return nil
+	}
+	delete(m.running, uuid)
+	executionTimeMetric.Observe(float64(time.Since(meter.started)) / float64(time.Millisecond))
+	if keep {
+		m.finished[uuid] = meter.usage
+	}
+	return meter.exceeded
+}
+
+// take removes and returns the usage of the given transactions by UUID
+func (m *txMeters) take(uuids []string) map[string]*pb.ResourceUsage {
+	m.Lock()
+	defer m.Unlock()
+	usage := make(map[string]*pb.ResourceUsage)
+	for _, uuid := range uuids {
+		if u, ok := m.finished[uuid]; ok {
+			usage[uuid] = u
+			delete(m.finished, uuid)
+		}
+	}
+	return usage
+}
@@ -0,0 +1,70 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestTxMeters(t *testing.T) {
+	m := &txMeters{limits: resourceLimits{stateWrites: 2, bytesRead: 10}, running: make(map[string]*txMeter), finished: make(map[string]*pb.ResourceUsage)}
+
+	// Queries are not metered
+	if err := m.read("query", 100, 1000); err != nil {
+		t.Fatalf("Expected reads of a query to pass, got %s", err)
+	}
+
+	m.begin("tx1")
+	if err := m.read("tx1", 2, 8); err != nil {
+		t.Fatalf("Error metering reads: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.write("tx1", 1, 5); err != nil {
+			t.Fatalf("Error metering write %d: %s", i, err)
+		}
+	}
+	if err := m.finish("tx1", true); err != nil {
+		t.Fatalf("Expected transaction within the limits, got %s", err)
+	}
+
+	m.begin("tx2")
+	if err := m.write("tx2", 3, 1); err == nil {
+		t.Fatalf("Expected error exceeding the state writes limit")
+	}
+	// The error sticks even if the chaincode ignores it
+	if err := m.read("tx2", 1, 1); err == nil {
+		t.Fatalf("Expected requests to fail once a limit is exceeded")
+	}
+	if err := m.finish("tx2", true); err == nil {
+		t.Fatalf("Expected transaction exceeding a limit to fail")
+	}
+
+	usage := m.take([]string{"tx1", "tx2"})
+	if u := usage["tx1"]; u == nil || u.StateReads != 2 || u.BytesRead != 8 || u.StateWrites != 2 || u.BytesWritten != 10 {
+		t.Fatalf("Unexpected usage of tx1: %v", u)
+	}
+	if usage["tx2"] == nil {
+		t.Fatalf("Expected usage of the failed transaction to be recorded")
+	}
+	if usage := m.take([]string{"tx1"}); len(usage) != 0 {
+		t.Fatalf("Expected usage to be taken once, got %v", usage)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/looplab/fsm"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// maxTracedPayload bounds the bytes of a payload written to a message trace
+const maxTracedPayload = 1024
+
+// Directions of the records of a message trace
+const (
+	traceToChaincode   = "peer->chaincode"
+	traceFromChaincode = "chaincode->peer"
+	traceTransition    = "transition"
+)
+
+// traceRecord is a line of the message trace of a chaincode
+type traceRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	// State of the handler when the message was sent or received
+	State string `json:"state,omitempty"`
+	Type  string `json:"type,omitempty"`
+	UUID  string `json:"uuid,omitempty"`
+	// Size of the payload, which is left out for confidential chaincodes
+	// and truncated to maxTracedPayload bytes
+	PayloadSize int    `json:"payloadSize,omitempty"`
+	Payload     []byte `json:"payload,omitempty"`
+	// Event, source and destination of a transition of the handler
+	Event string `json:"event,omitempty"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// messageTrace writes the trace of a chaincode to its file
+type messageTrace struct {
+	sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+var messageTraces = struct {
+	sync.RWMutex
+	traces map[string]*messageTrace
+}{traces: make(map[string]*messageTrace)}
+
+// MessageTraceFile returns the file of the message trace of a chaincode
+func MessageTraceFile(chaincode string) string {
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "chaincodetrace", chaincode+".log")
+}
+
+// SetMessageTrace turns the message trace of a chaincode on or off. When it
+// is on, every message exchanged with the chaincode and every transition of
+// its handler is appended to MessageTraceFile as a line of JSON.
+func SetMessageTrace(chaincode string, enabled bool) error {
+	if chaincode == "" {
+		return fmt.Errorf("chaincode name not given for message trace")
+	}
+	messageTraces.Lock()
+	defer messageTraces.Unlock()
+	trace, tracing := messageTraces.traces[chaincode]
+	if !enabled {
+		if tracing {
+			delete(messageTraces.traces, chaincode)
+			trace.Lock()
+			defer trace.Unlock()
+			return trace.file.Close()
+		}
+		return nil
+	}
+	if tracing {
+		return nil
+	}
+
+	path := MessageTraceFile(chaincode)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Error creating message trace directory: %s", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Error opening message trace: %s", err)
+	}
+	messageTraces.traces[chaincode] = &messageTrace{file: file, encoder: json.NewEncoder(file)}
+	chaincodeLog.Info("Tracing the messages of chaincode %s to %s", chaincode, path)
+	return nil
+}
+
+// MessageTraceEnabled returns whether the messages of a chaincode are traced
+func MessageTraceEnabled(chaincode string) bool {
+	messageTraces.RLock()
+	defer messageTraces.RUnlock()
+	_, ok := messageTraces.traces[chaincode]
+	return ok
+}
+
+func writeTraceRecord(chaincode string, record *traceRecord) {
+	messageTraces.RLock()
+	trace := messageTraces.traces[chaincode]
+	messageTraces.RUnlock()
+	if trace == nil {
+		return
+	}
+	record.Time = time.Now()
+	trace.Lock()
+	defer trace.Unlock()
+	if err := trace.encoder.Encode(record); err != nil {
+		chaincodeLog.Error(fmt.Sprintf("Error writing message trace of chaincode %s: %s", chaincode, err))
+	}
+}
+
+// tracedName returns the name of the chaincode of the handler. Before the
+// chaincode is registered, the name is in the payload of REGISTER.
+func (handler *Handler) tracedName(msg *pb.ChaincodeMessage) string {
+	if handler.ChaincodeID != nil {
+		return handler.ChaincodeID.Name
+	}
+	if msg != nil && msg.Type == pb.ChaincodeMessage_REGISTER {
+		chaincodeID := &pb.ChaincodeID{}
+		if proto.Unmarshal(msg.Payload, chaincodeID) == nil {
+			return chaincodeID.Name
+		}
+	}
+	return ""
+}
+
+// traceMessage writes a message sent to or received from the chaincode to
+// its trace. Payloads of confidential chaincodes are left out.
+func (handler *Handler) traceMessage(direction string, msg *pb.ChaincodeMessage) {
+	chaincode := handler.tracedName(msg)
+	if chaincode == "" || !MessageTraceEnabled(chaincode) {
+		return
+	}
+	record := &traceRecord{Direction: direction, State: handler.FSM.Current(), Type: msg.Type.String(),
+		UUID: msg.Uuid, PayloadSize: len(msg.Payload)}
+	if depTx := handler.deployTXSecContext; depTx == nil || depTx.ConfidentialityLevel != pb.ConfidentialityLevel_CONFIDENTIAL {
+		record.Payload = msg.Payload
+		if len(record.Payload) > maxTracedPayload {
+			record.Payload = record.Payload[:maxTracedPayload]
+		}
+	}
+	writeTraceRecord(chaincode, record)
+}
+
+// traceTransition writes a transition of the handler to the trace
+func (handler *Handler) traceTransition(e *fsm.Event) {
+	chaincode := handler.tracedName(nil)
+	if chaincode == "" || !MessageTraceEnabled(chaincode) {
+		return
+	}
+	writeTraceRecord(chaincode, &traceRecord{Direction: traceTransition, Event: e.Event, From: e.Src, To: e.Dst})
+}
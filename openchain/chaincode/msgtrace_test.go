@@ -0,0 +1,93 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/looplab/fsm"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func readTraceRecords(t *testing.T, chaincode string) []*traceRecord {
+	file, err := os.Open(MessageTraceFile(chaincode))
+	if err != nil {
+		t.Fatalf("Error opening message trace: %s", err)
+	}
+	defer file.Close()
+	var records []*traceRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		record := &traceRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			t.Fatalf("Error decoding trace record: %s", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestMessageTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "msgtrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("peer.fileSystemPath", dir)
+
+	if err := SetMessageTrace("mycc", true); err != nil {
+		t.Fatalf("Error enabling message trace: %s", err)
+	}
+	handler := &Handler{FSM: fsm.NewFSM(createdstate, fsm.Events{{Name: pb.ChaincodeMessage_REGISTER.String(), Src: []string{createdstate}, Dst: establishedstate}}, fsm.Callbacks{})}
+
+	// the handler knows its chaincode from the REGISTER message
+	payload, _ := proto.Marshal(&pb.ChaincodeID{Name: "mycc"})
+	handler.traceMessage(traceFromChaincode, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload})
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	handler.traceTransition(&fsm.Event{Event: pb.ChaincodeMessage_REGISTER.String(), Src: createdstate, Dst: establishedstate})
+	handler.deployTXSecContext = &pb.Transaction{ConfidentialityLevel: pb.ConfidentialityLevel_CONFIDENTIAL}
+	handler.traceMessage(traceToChaincode, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_TRANSACTION, Uuid: "tx1", Payload: []byte("secret")})
+
+	if err := SetMessageTrace("mycc", false); err != nil {
+		t.Fatalf("Error disabling message trace: %s", err)
+	}
+	handler.traceMessage(traceToChaincode, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY})
+
+	records := readTraceRecords(t, "mycc")
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 trace records, got %d", len(records))
+	}
+	if records[0].Type != "REGISTER" || records[0].State != createdstate || string(records[0].Payload) != string(payload) {
+		t.Fatalf("Unexpected REGISTER record: %+v", records[0])
+	}
+	if records[1].Direction != traceTransition || records[1].From != createdstate || records[1].To != establishedstate {
+		t.Fatalf("Unexpected transition record: %+v", records[1])
+	}
+	if records[2].UUID != "tx1" || records[2].PayloadSize != len("secret") || records[2].Payload != nil {
+		t.Fatalf("Expected the confidential payload to be left out: %+v", records[2])
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	google_protobuf "google/protobuf"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// nonceNamespace is the state namespace recording the nonces of the recent
+// signed invocations, so that an invocation captured on the wire cannot be
+// executed again. Chaincodes can only access the state namespace of their
+// own name.
+const nonceNamespace = "__nonces"
+
+// nonceWatermarkKey holds the timestamp of the most recent invocation whose
+// nonce was recorded. The nonces of the invocations older than the replay
+// window before it are dropped, and such invocations are refused. The key
+// sorts after the keys of the nonces, which start with their timestamp.
+const nonceWatermarkKey = "watermark"
+
+// tracksNonce tells whether the nonce of a transaction is recorded: signed
+// invocations are, when the replay window is set
+func (chaincodeSupport *ChaincodeSupport) tracksNonce(t *pb.Transaction) bool {
+	return chaincodeSupport.replayWindow > 0 && len(t.Cert) > 0 &&
+		(t.Type == pb.Transaction_CHAINCODE_EXECUTE || t.Type == pb.Transaction_CHAINCODE_CONFIG)
+}
+
+// nonceKey returns the key recording the nonce of an invocation, made of its
+// timestamp so that the keys sort by time, the hash of the certificate of the
+// identity that signed it and its nonce. An invocation replayed on the wire
+// has the same key.
+func nonceKey(t *pb.Transaction) string {
+	return fmt.Sprintf("%020d/%x/%x", timestampNanos(t.Timestamp), util.ComputeCryptoHash(t.Cert), t.Nonce)
+}
+
+func timestampNanos(ts *google_protobuf.Timestamp) int64 {
+	return ts.Seconds*int64(time.Second) + int64(ts.Nanos)
+}
+
+// getNonceWatermark returns the timestamp of the most recent invocation of
+// the chain whose nonce was recorded, 0 if none was
+func getNonceWatermark(ledgerObj *ledger.Ledger) (int64, error) {
+	stateLock.RLock()
+	raw, err := ledgerObj.GetState(nonceNamespace, nonceWatermarkKey, false)
+	stateLock.RUnlock()
+	if err != nil || raw == nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(raw), 10, 64)
+}
+
+// checkNonce refuses a signed invocation whose nonce is recorded for the
+// identity that signed it, or that is older than the replay window, and
+// records its nonce along with its state changes. Both the timestamp and the
+// nonce are covered by the signature of the invocation.
+func (chaincodeSupport *ChaincodeSupport) checkNonce(ledgerObj *ledger.Ledger, t *pb.Transaction) error {
+	if !chaincodeSupport.tracksNonce(t) {
+		return nil
+	}
+	if len(t.Nonce) == 0 || t.Timestamp == nil {
+		return fmt.Errorf("[%s]Invocation has no nonce or timestamp", shortuuid(t.Uuid))
+	}
+	watermark, err := getNonceWatermark(ledgerObj)
+	if err != nil {
+		return err
+	}
+	if watermark > 0 && timestampNanos(t.Timestamp) < watermark-int64(chaincodeSupport.replayWindow) {
+		return fmt.Errorf("[%s]Invocation is older than the replay window", shortuuid(t.Uuid))
+	}
+	key := nonceKey(t)
+	stateLock.RLock()
+	seen, err := ledgerObj.GetState(nonceNamespace, key, false)
+	stateLock.RUnlock()
+	if err != nil {
+		return err
+	}
+	if seen != nil {
+		return fmt.Errorf("[%s]Invocation replays invocation %s", shortuuid(t.Uuid), string(seen))
+	}
+	delta := chaincodeSupport.writeSets.get(t.Uuid)
+	if delta == nil {
+		return fmt.Errorf("[%s]No transaction in progress", shortuuid(t.Uuid))
+	}
+	delta.Set(nonceNamespace, key, []byte(t.Uuid), nil)
+	return nil
+}
+
+// pruneNonces moves the watermark of the chains of a batch to the most
+// recent of its invocations executed successfully, and drops the nonces
+// recorded before the replay window. It is called once the batch is
+// executed, so the nonces are pruned as the blocks commit.
+func (chaincodeSupport *ChaincodeSupport) pruneNonces(xacts []*pb.Transaction, errs []error) error {
+	newest := make(map[string]int64)
+	for i, t := range xacts {
+		if errs[i] != nil || !chaincodeSupport.tracksNonce(t) || t.Timestamp == nil {
+			continue
+		}
+		if ts := timestampNanos(t.Timestamp); ts > newest[t.ChainID] {
+			newest[t.ChainID] = ts
+		}
+	}
+	for chainID, ts := range newest {
+		ledgerObj, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			return err
+		}
+		watermark, err := getNonceWatermark(ledgerObj)
+		if err != nil {
+			return err
+		}
+		if ts <= watermark {
+			continue
+		}
+		delta := statemgmt.NewStateDelta()
+		delta.Set(nonceNamespace, nonceWatermarkKey, []byte(strconv.FormatInt(ts, 10)), nil)
+		stateLock.RLock()
+		itr, err := ledgerObj.GetStateRangeScanIterator(nonceNamespace, "", fmt.Sprintf("%020d", ts-int64(chaincodeSupport.replayWindow)), false)
+		stateLock.RUnlock()
+		if err != nil {
+			return err
+		}
+		for itr.Next() {
+			key, _ := itr.GetKeyValue()
+			delta.Delete(nonceNamespace, key, nil)
+		}
+		itr.Close()
+		if err = commitTxState(ledgerObj, nonceNamespace, delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
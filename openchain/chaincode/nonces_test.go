@@ -0,0 +1,98 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	google_protobuf "google/protobuf"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestReplayWindow(t *testing.T) {
+	ledgerObj := ledger.InitTestLedger(t)
+	ledgerObj.BeginTxBatch(1)
+	defer ledgerObj.RollbackTxBatch(1)
+	chain := &ChaincodeSupport{keyLocks: newKeyLockManager(), writeSets: newTxWriteSets(), keyHints: newTxKeyHints(), replayWindow: time.Minute}
+
+	start := time.Now()
+	invocation := func(uuid string, cert string, nonce string, at time.Duration) *pb.Transaction {
+		ts := start.Add(at)
+		return &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: uuid, Cert: []byte(cert), Nonce: []byte(nonce),
+			Timestamp: &google_protobuf.Timestamp{Seconds: ts.Unix(), Nanos: int32(ts.Nanosecond())}}
+	}
+	execute := func(tx *pb.Transaction) error {
+		chain.writeSets.begin(tx.Uuid)
+		err := chain.checkNonce(ledgerObj, tx)
+		delta := chain.writeSets.finish(tx.Uuid)
+		if err == nil {
+			err = commitTxState(ledgerObj, tx.Uuid, delta)
+		}
+		return err
+	}
+
+	tx1 := invocation("tx1", "alice", "n1", 0)
+	if err := execute(tx1); err != nil {
+		t.Fatalf("Error executing invocation: %s", err)
+	}
+	if err := execute(tx1); err == nil {
+		t.Fatalf("Expected replayed invocation to be refused")
+	}
+	// Nonces are tracked per identity
+	if err := execute(invocation("tx2", "bob", "n1", 0)); err != nil {
+		t.Fatalf("Error executing invocation of another identity with the same nonce: %s", err)
+	}
+	// Unsigned transactions are not tracked
+	unsigned := invocation("tx3", "", "", 0)
+	if err := execute(unsigned); err != nil {
+		t.Fatalf("Error executing unsigned invocation: %s", err)
+	}
+	if err := execute(invocation("tx4", "alice", "", 0)); err == nil {
+		t.Fatalf("Expected signed invocation without nonce to be refused")
+	}
+
+	// The watermark moves to the most recent invocation once the batch is
+	// executed, and the nonces older than the window are pruned
+	tx5 := invocation("tx5", "alice", "n5", 2*time.Minute)
+	if err := execute(tx5); err != nil {
+		t.Fatalf("Error executing invocation: %s", err)
+	}
+	if err := chain.pruneNonces([]*pb.Transaction{tx1, tx5}, []error{nil, nil}); err != nil {
+		t.Fatalf("Error pruning nonces: %s", err)
+	}
+	if value, _ := ledgerObj.GetState(nonceNamespace, nonceKey(tx1), false); value != nil {
+		t.Fatalf("Expected the nonce of tx1 to be pruned")
+	}
+	if value, _ := ledgerObj.GetState(nonceNamespace, nonceKey(tx5), false); value == nil {
+		t.Fatalf("Expected the nonce of tx5 to be kept")
+	}
+	if err := execute(tx1); err == nil {
+		t.Fatalf("Expected invocation older than the replay window to be refused")
+	}
+	if err := execute(invocation("tx6", "bob", "n6", 90*time.Second)); err != nil {
+		t.Fatalf("Error executing invocation within the replay window: %s", err)
+	}
+	if err := execute(tx5); err == nil {
+		t.Fatalf("Expected replayed invocation to be refused")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// newPackageHeader returns the header describing the deployment package
+func newPackageHeader(cds *pb.ChaincodeDeploymentSpec) (*pb.ChaincodePackageHeader, error) {
+	spec := cds.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil {
+		return nil, fmt.Errorf("Deployment spec has no chaincode ID")
+	}
+	return &pb.ChaincodePackageHeader{
+		Type:            spec.Type,
+		Path:            spec.ChaincodeID.Path,
+		Name:            spec.ChaincodeID.Name,
+		CodePackageHash: util.ComputeCryptoHash(cds.CodePackage),
+		Metadata:        spec.Metadata,
+	}, nil
+}
+
+// SignPackage adds the signature of the owner of the certificate handler to
+// the deployment package, setting the package header if it is not set yet
+func SignPackage(cds *pb.ChaincodeDeploymentSpec, signer crypto.CertificateHandler) error {
+	if cds.PackageHeader == nil {
+		header, err := newPackageHeader(cds)
+		if err != nil {
+			return err
+		}
+		if cds.PackageHeader, err = proto.Marshal(header); err != nil {
+			return fmt.Errorf("Error marshalling package header: %s", err)
+		}
+	}
+	signature, err := signer.Sign(cds.PackageHeader)
+	if err != nil {
+		return fmt.Errorf("Error signing package header: %s", err)
+	}
+	cds.PackageSignatures = append(cds.PackageSignatures, &pb.ChaincodePackageSignature{Certificate: signer.GetCertificate(), Signature: signature})
+	return nil
+}
+
+// packagePolicy decides whether a deployment package is signed by enough
+// authorized identities to be built
+type packagePolicy struct {
+	enabled   bool
+	threshold int
+	// roots the signer certificates must chain to
+	roots *x509.CertPool
+	// enrollment IDs allowed to sign, empty to accept any
+	signers map[string]bool
+	// configuration error, reported for every package
+	err error
+}
+
+// newPackagePolicy reads the package signing policy from chaincode.package.signature
+func newPackagePolicy() *packagePolicy {
+	p := &packagePolicy{enabled: viper.GetBool("chaincode.package.signature.enabled"), signers: make(map[string]bool)}
+	if !p.enabled {
+		return p
+	}
+	p.threshold = viper.GetInt("chaincode.package.signature.threshold")
+	if p.threshold <= 0 {
+		p.threshold = 1
+	}
+	for _, id := range viper.GetStringSlice("chaincode.package.signature.signers") {
+		p.signers[id] = true
+	}
+	rootFile := viper.GetString("chaincode.package.signature.rootcert.file")
+	if rootFile == "" {
+		// Anybody can create a self signed certificate for any enrollment ID
+		p.err = fmt.Errorf("Package signing enabled without root certificates")
+		chaincodeLog.Error(p.err.Error())
+		return p
+	}
+	pem, err := ioutil.ReadFile(rootFile)
+	if err != nil {
+		p.err = fmt.Errorf("Error reading package signing root certificates: %s", err)
+		chaincodeLog.Error(p.err.Error())
+		return p
+	}
+	p.roots = x509.NewCertPool()
+	if !p.roots.AppendCertsFromPEM(pem) {
+		p.err = fmt.Errorf("No package signing root certificates found in %s", rootFile)
+		chaincodeLog.Error(p.err.Error())
+	}
+	return p
+}
+
+// newNetworkPackagePolicy returns the package signing policy held by the
+// packagesignature network parameter
+func newNetworkPackagePolicy(value string) *packagePolicy {
+	p := &packagePolicy{enabled: true, signers: make(map[string]bool)}
+	policy, err := netconfig.ParsePackageSignaturePolicy(value)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.enabled = policy.Enabled
+	p.threshold = policy.Threshold
+	if p.threshold <= 0 {
+		p.threshold = 1
+	}
+	for _, id := range policy.Signers {
+		p.signers[id] = true
+	}
+	p.roots = x509.NewCertPool()
+	p.roots.AppendCertsFromPEM([]byte(policy.RootCerts))
+	return p
+}
+
+// getPackagePolicy returns the package signing policy in effect. The policy
+// set by a configuration transaction replaces the configured one, so that
+// the validators agree on the packages they build.
+func (chaincodeSupport *ChaincodeSupport) getPackagePolicy() *packagePolicy {
+	if value, ok := netconfig.Get(netconfig.PackageSignature); ok {
+		return newNetworkPackagePolicy(value)
+	}
+	return chaincodeSupport.packagePolicy
+}
+
+// verify returns an error unless the package carries valid signatures of at
+// least threshold distinct authorized identities, with trusted certificates,
+// over a header matching it
+func (p *packagePolicy) verify(cds *pb.ChaincodeDeploymentSpec) error {
+	if !p.enabled {
+		return nil
+	}
+	if p.err != nil {
+		return p.err
+	}
+	if p.roots == nil {
+		return fmt.Errorf("No package signing root certificates")
+	}
+	if cds.PackageHeader == nil {
+		return fmt.Errorf("Deployment package is not signed")
+	}
+	header := &pb.ChaincodePackageHeader{}
+	if err := proto.Unmarshal(cds.PackageHeader, header); err != nil {
+		return fmt.Errorf("Error unmarshalling package header: %s", err)
+	}
+	expected, err := newPackageHeader(cds)
+	if err != nil {
+		return err
+	}
+	if header.Type != expected.Type || header.Path != expected.Path || header.Name != expected.Name ||
+		!bytes.Equal(header.CodePackageHash, expected.CodePackageHash) || !bytes.Equal(header.Metadata, expected.Metadata) {
+		return fmt.Errorf("Package header does not match deployment package")
+	}
+
+	// Signatures are counted by enrollment ID, like the authorized signers,
+	// so an identity holding several certificates counts once
+	signers := make(map[string]bool)
+	for _, sig := range cds.PackageSignatures {
+		id, err := p.verifySignature(cds.PackageHeader, sig)
+		if err != nil {
+			chaincodeLog.Warning("Ignoring package signature: %s", err)
+			continue
+		}
+		signers[id] = true
+	}
+	if len(signers) < p.threshold {
+		return fmt.Errorf("Deployment package has %d valid signatures, %d required", len(signers), p.threshold)
+	}
+	return nil
+}
+
+// verifySignature checks a single signature and the certificate of its signer
+// and returns the enrollment ID of the signer
+func (p *packagePolicy) verifySignature(header []byte, sig *pb.ChaincodePackageSignature) (string, error) {
+	cert, err := utils.DERToX509Certificate(sig.Certificate)
+	if err != nil {
+		return "", fmt.Errorf("Invalid signer certificate: %s", err)
+	}
+	id := cert.Subject.CommonName
+	if _, err = utils.CheckCertAgainRoot(cert, p.roots); err != nil {
+		return "", fmt.Errorf("Certificate of %s not trusted: %s", id, err)
+	}
+	if len(p.signers) > 0 && !p.signers[id] {
+		return "", fmt.Errorf("%s is not authorized to sign packages", id)
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("Certificate of %s does not hold an ECDSA key", id)
+	}
+	ok, err = utils.ECDSAVerify(pub, header, sig.Signature)
+	if err != nil || !ok {
+		return "", fmt.Errorf("Invalid signature by %s", id)
+	}
+	return id, nil
+}
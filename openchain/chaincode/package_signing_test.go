@@ -0,0 +1,203 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// testSigner is a crypto.CertificateHandler backed by a self signed certificate
+type testSigner struct {
+	cert []byte
+	key  interface{}
+}
+
+// newTestSigner returns a signer with a self signed certificate for the
+// enrollment ID
+func newTestSigner(t *testing.T, id string) *testSigner {
+	conf.InitSecurityLevel(256)
+	key, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: id},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	return &testSigner{cert: cert, key: key}
+}
+
+func (s *testSigner) GetCertificate() []byte { return s.cert }
+
+func (s *testSigner) Sign(msg []byte) ([]byte, error) { return utils.ECDSASign(s.key, msg) }
+
+func (s *testSigner) Verify(signature []byte, msg []byte) error { return nil }
+
+func (s *testSigner) GetTransactionHandler() (crypto.TransactionHandler, error) { return nil, nil }
+
+// newTestPolicy returns an enabled policy trusting the certificates of signers
+func newTestPolicy(threshold int, signers ...*testSigner) *packagePolicy {
+	p := &packagePolicy{enabled: true, threshold: threshold, roots: x509.NewCertPool()}
+	for _, s := range signers {
+		cert, _ := x509.ParseCertificate(s.cert)
+		p.roots.AddCert(cert)
+	}
+	return p
+}
+
+func newTestPackage() *pb.ChaincodeDeploymentSpec {
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: "github.com/example/cc", Name: "abcd"}}
+	return &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, CodePackage: []byte("code")}
+}
+
+func TestPackagePolicy_Disabled(t *testing.T) {
+	p := &packagePolicy{}
+	if err := p.verify(newTestPackage()); err != nil {
+		t.Fatalf("Expected unsigned package to pass disabled policy, got %s", err)
+	}
+}
+
+func TestPackagePolicy_Signed(t *testing.T) {
+	signer := newTestSigner(t, "signer")
+	cds := newTestPackage()
+	if err := SignPackage(cds, signer); err != nil {
+		t.Fatalf("Error signing package: %s", err)
+	}
+
+	cert, _ := x509.ParseCertificate(signer.cert)
+	p := newTestPolicy(1, signer)
+	p.signers = map[string]bool{cert.Subject.CommonName: true}
+	if err := p.verify(cds); err != nil {
+		t.Fatalf("Expected signed package to be accepted, got %s", err)
+	}
+
+	p.signers = map[string]bool{"someoneelse": true}
+	if err := p.verify(cds); err == nil {
+		t.Fatalf("Expected package signed by unauthorized identity to be rejected")
+	}
+}
+
+func TestPackagePolicy_Unsigned(t *testing.T) {
+	p := newTestPolicy(1, newTestSigner(t, "signer"))
+	if err := p.verify(newTestPackage()); err == nil {
+		t.Fatalf("Expected unsigned package to be rejected")
+	}
+}
+
+func TestPackagePolicy_Untrusted(t *testing.T) {
+	cds := newTestPackage()
+	if err := SignPackage(cds, newTestSigner(t, "signer")); err != nil {
+		t.Fatalf("Error signing package: %s", err)
+	}
+	if err := newTestPolicy(1, newTestSigner(t, "signer")).verify(cds); err == nil {
+		t.Fatalf("Expected package signed with an untrusted certificate to be rejected")
+	}
+	if err := (&packagePolicy{enabled: true, threshold: 1}).verify(cds); err == nil {
+		t.Fatalf("Expected package to be rejected without root certificates")
+	}
+}
+
+func TestPackagePolicy_TamperedCode(t *testing.T) {
+	cds := newTestPackage()
+	signer := newTestSigner(t, "signer")
+	if err := SignPackage(cds, signer); err != nil {
+		t.Fatalf("Error signing package: %s", err)
+	}
+	cds.CodePackage = []byte("other code")
+	p := newTestPolicy(1, signer)
+	if err := p.verify(cds); err == nil {
+		t.Fatalf("Expected package with modified code to be rejected")
+	}
+}
+
+func TestPackagePolicy_Threshold(t *testing.T) {
+	cds := newTestPackage()
+	signer := newTestSigner(t, "alice")
+	again := newTestSigner(t, "alice")
+	other := newTestSigner(t, "bob")
+	// The same identity signing twice counts once, even with another certificate
+	SignPackage(cds, signer)
+	SignPackage(cds, signer)
+	SignPackage(cds, again)
+	p := newTestPolicy(2, signer, again, other)
+	if err := p.verify(cds); err == nil {
+		t.Fatalf("Expected package with a single signer to be rejected")
+	}
+	SignPackage(cds, other)
+	if err := p.verify(cds); err != nil {
+		t.Fatalf("Expected package signed by two identities to be accepted, got %s", err)
+	}
+}
+
+func TestPackagePolicy_Network(t *testing.T) {
+	signer := newTestSigner(t, "signer")
+	cds := newTestPackage()
+	if err := SignPackage(cds, signer); err != nil {
+		t.Fatalf("Error signing package: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(signer.cert)
+	rootCerts := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.cert}))
+	value := func(policy netconfig.PackageSignaturePolicy) string {
+		data, _ := json.Marshal(policy)
+		return string(data)
+	}
+
+	p := newNetworkPackagePolicy(value(netconfig.PackageSignaturePolicy{Enabled: true, RootCerts: rootCerts, Signers: []string{cert.Subject.CommonName}}))
+	if err := p.verify(cds); err != nil {
+		t.Fatalf("Expected signed package to be accepted, got %s", err)
+	}
+	if err := p.verify(newTestPackage()); err == nil {
+		t.Fatalf("Expected unsigned package to be rejected")
+	}
+	p = newNetworkPackagePolicy(value(netconfig.PackageSignaturePolicy{Enabled: true, Threshold: 2, RootCerts: rootCerts}))
+	if err := p.verify(cds); err == nil {
+		t.Fatalf("Expected package with fewer signatures than the threshold to be rejected")
+	}
+
+	// The network can disable signing, but an invalid policy rejects every package
+	if err := newNetworkPackagePolicy(value(netconfig.PackageSignaturePolicy{})).verify(newTestPackage()); err != nil {
+		t.Fatalf("Expected unsigned package to pass disabled policy, got %s", err)
+	}
+	if err := newNetworkPackagePolicy("enabled").verify(cds); err == nil {
+		t.Fatalf("Expected package to be rejected by an invalid policy")
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// PreLaunchHook inspects the image of a chaincode after it is built and
+// before the chaincode is launched, eg. to scan it for vulnerabilities.
+type PreLaunchHook interface {
+	// Name identifies the hook in the results recorded with deployments
+	Name() string
+	// Check inspects image, built for chaincode, and returns a summary of
+	// its findings. An error blocks the deployment.
+	Check(ctxt context.Context, chaincode string, image string) (summary string, err error)
+}
+
+// preLaunchHooks holds the hooks registered with RegisterPreLaunchHook
+var preLaunchHooks struct {
+	sync.RWMutex
+	hooks []PreLaunchHook
+}
+
+// RegisterPreLaunchHook adds a hook run on the image of every user chaincode
+// deployed on this peer, after the hooks configured under chaincode.prelaunch
+func RegisterPreLaunchHook(hook PreLaunchHook) {
+	preLaunchHooks.Lock()
+	defer preLaunchHooks.Unlock()
+	preLaunchHooks.hooks = append(preLaunchHooks.hooks, hook)
+}
+
+// newPreLaunchHooks returns the configured hooks
+func newPreLaunchHooks() []PreLaunchHook {
+	var hooks []PreLaunchHook
+	if viper.GetBool("chaincode.prelaunch.imagescan.enabled") {
+		hooks = append(hooks, newImageScanner())
+	}
+	return hooks
+}
+
+// runPreLaunchHooks runs the hooks on the image of chaincode in turn and
+// records their results with its deployment. It stops at the first hook
+// blocking the deployment and returns its error.
+func (chaincodeSupport *ChaincodeSupport) runPreLaunchHooks(ctxt context.Context, chaincode string, image string) error {
+	preLaunchHooks.RLock()
+	hooks := append(append([]PreLaunchHook(nil), chaincodeSupport.preLaunchHooks...), preLaunchHooks.hooks...)
+	preLaunchHooks.RUnlock()
+	for _, hook := range hooks {
+		summary, err := hook.Check(ctxt, chaincode, image)
+		check := &pb.PreLaunchCheck{Hook: hook.Name(), Passed: err == nil, Summary: summary}
+		if err != nil && summary == "" {
+			check.Summary = err.Error()
+		}
+		deployments.addCheck(chaincode, check)
+		if err != nil {
+			chaincodeLog.Warning("Pre-launch hook %s blocked deployment of %s: %s", hook.Name(), chaincode, err)
+			return fmt.Errorf("Deployment of %s blocked by %s: %s", chaincode, hook.Name(), err)
+		}
+		chaincodeLog.Debug("Pre-launch hook %s passed %s: %s", hook.Name(), chaincode, summary)
+	}
+	return nil
+}
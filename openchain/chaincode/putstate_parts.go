@@ -0,0 +1,114 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// partialValue is a value being received in PUT_STATE_PART messages
+type partialValue struct {
+	key   string
+	value []byte
+	// err is reported in response to the last part
+	err error
+}
+
+// checkValueSize fails values larger than the configured maximum
+func (chaincodeSupport *ChaincodeSupport) checkValueSize(key string, size int) error {
+	if max := chaincodeSupport.maxValueSize; max > 0 && size > max {
+		return fmt.Errorf("Value of key %s is %d bytes, more than the maximum of %d", key, size, max)
+	}
+	return nil
+}
+
+// handlePutStatePart collects a part of a value too large for a single
+// PUT_STATE message. Once the last part is received the whole value is
+// handled as a PUT_STATE request, so that it is written once. Failures are
+// reported in response to the last part, the only one the chaincode waits
+// for.
+func (handler *Handler) handlePutStatePart(msg *pb.ChaincodeMessage) error {
+	part := &pb.PutStatePart{}
+	if err := proto.Unmarshal(msg.Payload, part); err != nil {
+		chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+		handler.deletePartialValue(msg.Uuid)
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid})
+		return nil
+	}
+	partial := handler.addPutStatePart(msg.Uuid, part)
+	if partial == nil {
+		return nil
+	}
+	if partial.err != nil {
+		chaincodeLogger.Debug("[%s]Failed to put value in parts: %s. Sending %s", shortuuid(msg.Uuid), partial.err, pb.ChaincodeMessage_ERROR)
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(partial.err.Error()), Uuid: msg.Uuid})
+		return nil
+	}
+	payload, err := proto.Marshal(&pb.PutStateInfo{Key: partial.key, Value: partial.value})
+	if err != nil {
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid})
+		return nil
+	}
+	chaincodeLogger.Debug("[%s]Received the %d bytes of the value of key %s", shortuuid(msg.Uuid), len(partial.value), partial.key)
+	return handler.HandleMessage(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Payload: payload, Uuid: msg.Uuid, Timestamp: msg.Timestamp})
+}
+
+// addPutStatePart adds a part to the value being put by a transaction, and
+// returns the value once its last part is added
+func (handler *Handler) addPutStatePart(uuid string, part *pb.PutStatePart) *partialValue {
+	isTransaction := handler.getIsTransaction(uuid)
+
+	handler.Lock()
+	defer handler.Unlock()
+	partial, ok := handler.partialValues[uuid]
+	if !ok {
+		partial = &partialValue{key: part.Key}
+		if !isTransaction {
+			partial.err = fmt.Errorf("Cannot handle %s in query context", pb.ChaincodeMessage_PUT_STATE)
+		} else if partial.err = handler.chaincodeSupport.checkValueSize(part.Key, int(part.TotalSize)); partial.err == nil && handler.chaincodeSupport.maxValueSize > 0 {
+			partial.value = make([]byte, 0, part.TotalSize)
+		}
+		handler.partialValues[uuid] = partial
+	}
+	if partial.err == nil {
+		if part.Key != partial.key {
+			partial.err = fmt.Errorf("Part of the value of key %s received while putting key %s", part.Key, partial.key)
+		} else if partial.err = handler.chaincodeSupport.checkValueSize(part.Key, len(partial.value)+len(part.Chunk)); partial.err == nil {
+			partial.value = append(partial.value, part.Chunk...)
+		}
+		if partial.err != nil {
+			partial.value = nil
+		}
+	}
+	if !part.Last {
+		return nil
+	}
+	delete(handler.partialValues, uuid)
+	return partial
+}
+
+func (handler *Handler) deletePartialValue(uuid string) {
+	handler.Lock()
+	defer handler.Unlock()
+	delete(handler.partialValues, uuid)
+}
@@ -0,0 +1,73 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func newPartsTestHandler(maxValueSize int) *Handler {
+	handler := &Handler{chaincodeSupport: &ChaincodeSupport{maxValueSize: maxValueSize},
+		isTransaction: make(map[string]bool), partialValues: make(map[string]*partialValue)}
+	handler.markIsTransaction("tx1", true)
+	return handler
+}
+
+func TestPutStateParts(t *testing.T) {
+	handler := newPartsTestHandler(10)
+
+	if partial := handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("abcd"), TotalSize: 8}); partial != nil {
+		t.Fatalf("Expected the value to be incomplete")
+	}
+	partial := handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("efgh"), Last: true})
+	if partial == nil || partial.err != nil || partial.key != "key" || !bytes.Equal(partial.value, []byte("abcdefgh")) {
+		t.Fatalf("Expected the reassembled value, got %+v", partial)
+	}
+	if len(handler.partialValues) != 0 {
+		t.Fatalf("Expected the value to be released once complete")
+	}
+
+	// Values over the maximum are refused upfront or as they grow
+	handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("abcd"), TotalSize: 12})
+	if partial = handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Last: true}); partial == nil || partial.err == nil {
+		t.Fatalf("Expected the announced size over the maximum to be refused")
+	}
+	handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("abcdef"), TotalSize: 8})
+	handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("ghijkl")})
+	if partial = handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Last: true}); partial == nil || partial.err == nil || partial.value != nil {
+		t.Fatalf("Expected the value growing over the maximum to be refused, got %+v", partial)
+	}
+
+	// Parts must all be of the same key
+	handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "key", Chunk: []byte("ab"), TotalSize: 4})
+	if partial = handler.addPutStatePart("tx1", &pb.PutStatePart{Key: "other", Chunk: []byte("cd"), Last: true}); partial == nil || partial.err == nil {
+		t.Fatalf("Expected parts of another key to be refused")
+	}
+}
+
+func TestPutStatePartsInQuery(t *testing.T) {
+	handler := newPartsTestHandler(0)
+	partial := handler.addPutStatePart("query1", &pb.PutStatePart{Key: "key", Chunk: []byte("abcd"), TotalSize: 4, Last: true})
+	if partial == nil || partial.err == nil {
+		t.Fatalf("Expected parts of a query to be refused")
+	}
+}
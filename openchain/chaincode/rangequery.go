@@ -0,0 +1,71 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// rangeQueryIterator applies the options of a range query to the scan of the
+// state. It is kept with the scan for the RANGE_QUERY_STATE_NEXT requests of
+// the query.
+type rangeQueryIterator struct {
+	statemgmt.RangeScanIterator
+	// limit is the most keys returned, 0 for no limit
+	limit    uint32
+	returned uint32
+	keysOnly bool
+}
+
+// newRangeQueryIterator returns iter limited to the options of a range query
+func newRangeQueryIterator(iter statemgmt.RangeScanIterator, query *pb.RangeQueryState) statemgmt.RangeScanIterator {
+	if query.Limit == 0 && !query.KeysOnly {
+		return iter
+	}
+	return &rangeQueryIterator{RangeScanIterator: iter, limit: query.Limit, keysOnly: query.KeysOnly}
+}
+
+// isKeysOnly returns whether the values of iter are left out of the response
+func isKeysOnly(iter statemgmt.RangeScanIterator) bool {
+	rangeIter, ok := iter.(*rangeQueryIterator)
+	return ok && rangeIter.keysOnly
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (iter *rangeQueryIterator) Next() bool {
+	if iter.limit > 0 && iter.returned >= iter.limit {
+		return false
+	}
+	if !iter.RangeScanIterator.Next() {
+		return false
+	}
+	iter.returned++
+	return true
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (iter *rangeQueryIterator) GetKeyValue() (string, []byte) {
+	key, value := iter.RangeScanIterator.GetKeyValue()
+	if iter.keysOnly {
+		return key, nil
+	}
+	return key, value
+}
@@ -0,0 +1,67 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func newTestRangeScan() *sortedRangeScanIterator {
+	return &sortedRangeScanIterator{keys: []string{"a", "b", "c"}, values: [][]byte{[]byte("1"), []byte("2"), []byte("3")}, index: -1}
+}
+
+func TestRangeQueryIterator(t *testing.T) {
+	iter := newRangeQueryIterator(newTestRangeScan(), &pb.RangeQueryState{Limit: 2, KeysOnly: true})
+	if !isKeysOnly(iter) {
+		t.Fatalf("Expected a keys-only iterator")
+	}
+	var keys []string
+	for iter.Next() {
+		key, value := iter.GetKeyValue()
+		if value != nil {
+			t.Fatalf("Expected no value for key %s, got %q", key, value)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("Expected the first 2 keys, got %v", keys)
+	}
+
+	// Queries without options scan the state as is
+	scan := newTestRangeScan()
+	if iter = newRangeQueryIterator(scan, &pb.RangeQueryState{}); iter != scan || isKeysOnly(iter) {
+		t.Fatalf("Expected the scan of the state to be returned")
+	}
+
+	// Limits larger than the range return the whole range with the values
+	iter = newRangeQueryIterator(newTestRangeScan(), &pb.RangeQueryState{Limit: 10})
+	count := 0
+	for iter.Next() {
+		if _, value := iter.GetKeyValue(); value == nil {
+			t.Fatalf("Expected values to be returned")
+		}
+		count++
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 keys, got %d", count)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// replayID identifies the transaction-batch of a replay in the ledger
+const replayID = "replay"
+
+// ReplayBlocks executes the transactions of the committed blocks of a chain
+// from first to last again, in order, on the state as it was before first,
+// and compares the state hash after each block with the one recorded in the
+// block. The state changes of the replay are kept in memory and discarded at
+// the end, so the ledger is left as it was. As deploying chaincode does not
+// initialize a chaincode that is already running, blocks with deploy
+// transactions are not executed again; their recorded state changes are
+// applied instead, so the replay never changes the status of a deployment.
+// The transaction-batches of consensus wait for the replay to end rather than
+// execute on the rolled back state. The transactions replayed were committed
+// already: the invocations they defer, the events they set, their results and
+// the resources they use are dropped rather than kept for a commit.
+func ReplayBlocks(ctxt context.Context, chain *ChaincodeSupport, chainID string, first uint64, last uint64) (*pb.BlockReplayReport, error) {
+	return replayBlocks(chainID, first, last, func(t *pb.Transaction, previousBlockHash []byte) error {
+		defer discardReplayed(chain, []string{t.Uuid})
+		_, err := Execute(withPreviousBlockHash(ctxt, previousBlockHash), chain, t)
+		return err
+	})
+}
+
+// discardReplayed drops what the chain keeps of the execution of the given
+// transactions until their block commits, as TxBatch rollbacks do
+func discardReplayed(chain *ChaincodeSupport, uuids []string) {
+	chain.deferredCalls.take(uuids, false)
+	chain.chaincodeEvents.take(uuids, false)
+	chain.txResults.take(uuids)
+	chain.meters.take(uuids)
+}
+
+// replayBlocks replays the blocks with execute, which is passed the hash of
+// the block each transaction was executed after in the first place
+func replayBlocks(chainID string, first uint64, last uint64, execute func(t *pb.Transaction, previousBlockHash []byte) error) (*pb.BlockReplayReport, error) {
+	if first > last {
+		return nil, fmt.Errorf("First block %d of replay is after last block %d", first, last)
+	}
+	ledger, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	if last >= ledger.GetBlockchainSize() {
+		return nil, fmt.Errorf("Last block %d of replay is beyond the blockchain of %d blocks", last, ledger.GetBlockchainSize())
+	}
+	if err = ledger.BeginReplay(replayID, first); err != nil {
+		return nil, err
+	}
+	defer ledger.RollbackTxBatch(replayID)
+
+	report := &pb.BlockReplayReport{Deterministic: true}
+	for n := first; n <= last; n++ {
+		block, err := ledger.GetBlockByNumber(n)
+		if err != nil {
+			return nil, err
+		}
+		replay := &pb.BlockReplay{BlockNumber: n, Executed: !deploysChaincode(block), RecordedStateHash: block.StateHash}
+		if replay.Executed {
+			for _, t := range block.Transactions {
+				if err := execute(t, block.PreviousBlockHash); err != nil {
+					replay.Errors = append(replay.Errors, fmt.Sprintf("%s: %s", t.Uuid, err))
+				}
+			}
+		} else if err = ledger.ReplayStateDelta(replayID, n); err != nil {
+			return nil, err
+		}
+		if replay.ReplayedStateHash, err = ledger.GetTempStateHash(); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(replay.ReplayedStateHash, replay.RecordedStateHash) {
+			chaincodeLog.Warning("Replayed state hash of block %d differs from the recorded one", n)
+			report.Deterministic = false
+			report.DivergentBlocks = append(report.DivergentBlocks, n)
+		}
+		report.Blocks = append(report.Blocks, replay)
+	}
+	return report, nil
+}
+
+func deploysChaincode(block *pb.Block) bool {
+	for _, t := range block.Transactions {
+		if t.Type == pb.Transaction_CHAINCODE_NEW {
+			return true
+		}
+	}
+	return false
+}
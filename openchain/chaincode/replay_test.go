@@ -0,0 +1,123 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestReplayBlocks(t *testing.T) {
+	ledger := ledger.InitTestLedger(t)
+
+	//every transaction sets a key of mycc to a value made of its uuid
+	counter := 0
+	execute := func(tx *pb.Transaction, previousBlockHash []byte) error {
+		if tx.Uuid == "bad" {
+			return fmt.Errorf("failed")
+		}
+		counter++
+		ledger.TxBegin(tx.Uuid)
+		ledger.SetState("mycc", "key", []byte(fmt.Sprintf("%s-%d", tx.Uuid, counter)))
+		ledger.TxFinished(tx.Uuid, true)
+		return nil
+	}
+	commit := func(n int, txs ...*pb.Transaction) {
+		ledger.BeginTxBatch(n)
+		for _, tx := range txs {
+			execute(tx, nil)
+		}
+		if err := ledger.CommitTxBatch(n, txs, nil, nil); err != nil {
+			t.Fatalf("Error committing block %d: %s", n, err)
+		}
+	}
+	commit(0, &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Uuid: "deploy"})
+	commit(1, &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: "tx1"}, &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: "bad"})
+	commit(2, &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: "tx2"})
+	stateHash, _ := ledger.GetTempStateHash()
+
+	//the values depend on the number of executions so far, like chaincode
+	//reading something else than the state would
+	report, err := replayBlocks("", 0, 2, execute)
+	if err != nil {
+		t.Fatalf("Error replaying blocks: %s", err)
+	}
+	if report.Deterministic || !reflect.DeepEqual(report.DivergentBlocks, []uint64{1, 2}) {
+		t.Fatalf("Expected blocks 1 and 2 to diverge: %v", report)
+	}
+	if report.Blocks[0].Executed || !report.Blocks[1].Executed || len(report.Blocks[1].Errors) != 1 {
+		t.Fatalf("Expected block 0 applied and block 1 executed with an error: %v", report)
+	}
+
+	//the same executions on the same state give the same state hashes
+	counter = 1
+	report, err = replayBlocks("", 1, 2, execute)
+	if err != nil {
+		t.Fatalf("Error replaying blocks: %s", err)
+	}
+	if !report.Deterministic || len(report.Blocks) != 2 {
+		t.Fatalf("Expected the replay to match the recorded state hashes: %v", report)
+	}
+
+	if _, err = replayBlocks("", 1, 3, execute); err == nil {
+		t.Fatal("Expected error replaying blocks beyond the blockchain")
+	}
+	if newStateHash, _ := ledger.GetTempStateHash(); !reflect.DeepEqual(newStateHash, stateHash) {
+		t.Fatal("Expected the replays to leave the state as it was")
+	}
+}
+
+func TestDiscardReplayed(t *testing.T) {
+	chain := &ChaincodeSupport{deferredCalls: newDeferredCalls(), chaincodeEvents: newChaincodeEvents(),
+		meters: newTxMeters(), txResults: newTxResults()}
+	for _, uuid := range []string{"tx1", "live"} {
+		chain.deferredCalls.begin(uuid)
+		chain.deferredCalls.add(uuid, &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: "mycc"}})
+		chain.deferredCalls.finish(uuid, true)
+		chain.chaincodeEvents.begin(uuid)
+		chain.chaincodeEvents.add(uuid, &pb.ChaincodeEvent{ChaincodeID: "mycc", TxUuid: uuid, EventName: "moved"})
+		events := chain.chaincodeEvents.finish(uuid, true)
+		chain.meters.begin(uuid)
+		chain.meters.finish(uuid, true)
+		chain.txResults.finish(uuid, nil, events)
+	}
+
+	//nothing of the replayed transaction is left for a commit to deliver,
+	//other transactions are left alone
+	discardReplayed(chain, []string{"tx1"})
+	for uuid, kept := range map[string]bool{"tx1": false, "live": true} {
+		if _, ok := chain.deferredCalls.queued[uuid]; ok != kept {
+			t.Fatalf("Expected the deferred invocations of %s kept: %t", uuid, kept)
+		}
+		if _, ok := chain.chaincodeEvents.queued[uuid]; ok != kept {
+			t.Fatalf("Expected the events of %s kept: %t", uuid, kept)
+		}
+		if _, ok := chain.txResults.finished[uuid]; ok != kept {
+			t.Fatalf("Expected the result of %s kept: %t", uuid, kept)
+		}
+		if _, ok := chain.meters.finished[uuid]; ok != kept {
+			t.Fatalf("Expected the resource usage of %s kept: %t", uuid, kept)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/metrics"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+const (
+	sendQueueSizeDefault    = 100
+	sendQueueTimeoutDefault = 5 * time.Second
+)
+
+// ErrSendQueueFull is returned when a message for a chaincode is dropped
+// because the chaincode does not keep up with the messages sent to it
+var ErrSendQueueFull = errors.New("chaincode send queue full")
+
+var sendQueueDropsMetric = metrics.NewCounter("chaincode.sendQueueDrops")
+
+func init() {
+	metrics.RegisterFunc("chaincode.sendQueueDepth", func() interface{} {
+		return sendQueueDepths()
+	})
+}
+
+// sendQueue holds the messages to send to a chaincode. A single goroutine
+// sends them in order, so that a slow chaincode holds up neither the handler
+// nor a growing number of senders. Senders wait for room in the queue up to
+// a timeout, after which the message is dropped.
+type sendQueue struct {
+	stream  PeerChaincodeStream
+	msgs    chan *pb.ChaincodeMessage
+	timeout time.Duration
+	done    chan struct{}
+
+	sync.RWMutex
+	// err is the error of the first failed send, which ends the queue
+	err error
+}
+
+func newSendQueue(stream PeerChaincodeStream) *sendQueue {
+	size := viper.GetInt("chaincode.sendqueue.size")
+	if size <= 0 {
+		size = sendQueueSizeDefault
+	}
+	timeout := viper.GetDuration("chaincode.sendqueue.timeout")
+	if timeout <= 0 {
+		timeout = sendQueueTimeoutDefault
+	}
+	q := &sendQueue{stream: stream, msgs: make(chan *pb.ChaincodeMessage, size), timeout: timeout, done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+// run sends the queued messages until the queue is closed or a send fails
+func (q *sendQueue) run() {
+	for {
+		select {
+		case msg := <-q.msgs:
+			if err := q.stream.Send(msg); err != nil {
+				chaincodeLog.Error(fmt.Sprintf("Error sending %s: %s", msg.Type.String(), err))
+				q.Lock()
+				q.err = fmt.Errorf("Error sending %s: %s", msg.Type.String(), err)
+				q.Unlock()
+				return
+			}
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// send queues msg, waiting for room if the queue is full. It returns
+// ErrSendQueueFull if the message is dropped, or the error that ended the
+// queue.
+func (q *sendQueue) send(msg *pb.ChaincodeMessage) error {
+	q.RLock()
+	err := q.err
+	q.RUnlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case q.msgs <- msg:
+		return nil
+	default:
+	}
+
+	// Full, hold the sender back until the chaincode catches up
+	timer := time.NewTimer(q.timeout)
+	defer timer.Stop()
+	select {
+	case q.msgs <- msg:
+		return nil
+	case <-q.done:
+		return fmt.Errorf("Chaincode stream closed, %s not sent", msg.Type.String())
+	case <-timer.C:
+		sendQueueDropsMetric.Inc()
+		chaincodeLogger.Warning("[%s]Dropped %s, the chaincode did not accept messages for %s", shortuuid(msg.Uuid), msg.Type.String(), q.timeout)
+		return ErrSendQueueFull
+	}
+}
+
+// depth returns the number of messages waiting to be sent
+func (q *sendQueue) depth() int {
+	return len(q.msgs)
+}
+
+// close stops sending, the messages still queued are dropped
+func (q *sendQueue) close() {
+	close(q.done)
+}
+
+// sendQueueDepths returns the depth of the send queue of every registered
+// chaincode, by chaincode name
+func sendQueueDepths() map[string]int {
+	depths := make(map[string]int)
+	for _, chain := range chains {
+		chain.handlerMap.RLock()
+		for name, handler := range chain.handlerMap.chaincodeMap {
+			if handler.sendQueue != nil {
+				depths[name] = handler.sendQueue.depth()
+			}
+		}
+		chain.handlerMap.RUnlock()
+	}
+	return depths
+}
@@ -0,0 +1,93 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestSendQueue(t *testing.T) {
+	viper.Set("chaincode.sendqueue.size", 2)
+	viper.Set("chaincode.sendqueue.timeout", 50*time.Millisecond)
+	defer viper.Set("chaincode.sendqueue.size", 0)
+	defer viper.Set("chaincode.sendqueue.timeout", 0)
+
+	stream := cctesting.NewMockStream()
+	stream.Hold()
+	q := newSendQueue(stream)
+	defer q.close()
+
+	// One message is held by the sender, two wait in the queue
+	for i := 0; i < 3; i++ {
+		if err := q.send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Uuid: fmt.Sprintf("tx%d", i)}); err != nil {
+			t.Fatalf("Error queueing message %d: %s", i, err)
+		}
+	}
+	for q.depth() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	drops := sendQueueDropsMetric.Value()
+	if err := q.send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Uuid: "tx3"}); err != ErrSendQueueFull {
+		t.Fatalf("Expected ErrSendQueueFull, got %v", err)
+	}
+	if sendQueueDropsMetric.Value() != drops+1 {
+		t.Fatalf("Expected the drop to be counted")
+	}
+
+	// A sender waiting for room gets it once the chaincode catches up
+	sent := make(chan error)
+	go func() {
+		sent <- q.send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Uuid: "tx4"})
+	}()
+	stream.ReleaseOne()
+	if err := <-sent; err != nil {
+		t.Fatalf("Error queueing message after the queue drained: %s", err)
+	}
+	stream.Release()
+	for _, uuid := range []string{"tx0", "tx1", "tx2", "tx4"} {
+		if msg := <-stream.Sent; msg.Uuid != uuid {
+			t.Fatalf("Expected %s to be sent, got %s", uuid, msg.Uuid)
+		}
+	}
+}
+
+func TestSendQueueError(t *testing.T) {
+	stream := cctesting.NewMockStream()
+	stream.FailSends(fmt.Errorf("stream broken"))
+	q := newSendQueue(stream)
+	defer q.close()
+
+	if err := q.send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY}); err != nil {
+		t.Fatalf("Error queueing message: %s", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for q.send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY}) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the send error to be returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
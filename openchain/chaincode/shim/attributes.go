@@ -0,0 +1,86 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package shim
+
+import (
+	"errors"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/spf13/viper"
+)
+
+// GetCallerCertificate returns the certificate the transaction invoking the
+// chaincode has been signed with, nil if security is disabled.
+func (stub *ChaincodeStub) GetCallerCertificate() ([]byte, error) {
+	if stub.securityContext == nil {
+		return nil, errors.New("No security context available")
+	}
+	return stub.securityContext.CallerCert, nil
+}
+
+// GetAttributeValue returns the value of the attribute name the TCA embedded
+// in the certificate of the invoker.
+func (stub *ChaincodeStub) GetAttributeValue(name string) (string, error) {
+	if stub.securityContext == nil || len(stub.securityContext.CallerCert) == 0 {
+		return "", errors.New("No caller certificate available")
+	}
+	if len(stub.securityContext.AttributesKey) == 0 {
+		return "", utils.ErrAttributeNotFound
+	}
+
+	if err := initCryptoSuite(); err != nil {
+		return "", err
+	}
+
+	cert, err := utils.DERToX509Certificate(stub.securityContext.CallerCert)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.DecryptAttribute(cert, stub.securityContext.AttributesKey, name)
+}
+
+// VerifyAttribute returns true if the invoker holds the attribute name with
+// the given value.
+func (stub *ChaincodeStub) VerifyAttribute(name, value string) (bool, error) {
+	v, err := stub.GetAttributeValue(name)
+	if err == utils.ErrAttributeNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return v == value, nil
+}
+
+// initCryptoSuite initializes the crypto suite of the peer, passed in the
+// environment of the chaincode
+func initCryptoSuite() error {
+	if suite := viper.GetString("security.suite"); suite != "" {
+		return conf.InitCryptoSuite(suite)
+	}
+
+	level := viper.GetInt("security.level")
+	if level == 0 {
+		level = 256
+	}
+	return conf.InitSecurityLevel(level)
+}
@@ -20,10 +20,12 @@ under the License.
 package shim
 
 import (
+	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"strings"
 	"time"
@@ -37,14 +39,12 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
+	google_protobuf "google/protobuf"
 )
 
 // Logger for the shim package.
 var chaincodeLogger = logging.MustGetLogger("chaincode")
 
-// Handler to shim that handles all control logic.
-var handler *Handler
-
 // Chaincode is the standard chaincode callback interface that the chaincode developer needs to implement.
 type Chaincode interface {
 	// Run method will be called during init and for every transaction
@@ -53,9 +53,25 @@ type Chaincode interface {
 	Query(stub *ChaincodeStub, function string, args []string) ([]byte, error)
 }
 
+// DeprecationListener may be implemented by chaincodes to learn when the
+// validator warns that the shim uses a part of the protocol with the
+// validator that is being phased out, eg. to report it in their own way.
+// The warnings are logged by the shim in any case. Deprecated is called once
+// per deprecated message type, from the goroutine receiving the messages of
+// the validator, and should return quickly.
+type DeprecationListener interface {
+	Deprecated(warning string)
+}
+
 // ChaincodeStub for shim side handling.
 type ChaincodeStub struct {
-	UUID string
+	UUID            string
+	handler         *Handler
+	securityContext *pb.ChaincodeSecurityContext
+	aborted         <-chan struct{}
+	// rand is seeded from the random seed of the transaction on the first
+	// call to GetTxRandom
+	rand *rand.Rand
 }
 
 // Peer address derived from command line or env var
@@ -85,11 +101,37 @@ func Start(cc Chaincode) error {
 
 	chaincodeSupportClient := pb.NewChaincodeSupportClient(clientConn)
 
-	err = chatWithPeer(chaincodeSupportClient, cc)
+	// Establish stream with validating peer
+	stream, err := chaincodeSupportClient.Register(context.Background())
+	if err != nil {
+		return fmt.Errorf("Error chatting with leader at address=%s:  %s", getPeerAddress(), err)
+	}
+	defer stream.CloseSend()
+
+	err = chatWithPeer(viper.GetString("chaincode.id.name"), getPeerAddress(), stream, cc)
 
 	return err
 }
 
+// StartInProc is the entry point for system chaincodes run in-process by the
+// peer. The chaincode talks to the peer over the supplied stream instead of a
+// gRPC connection; env carries the chaincode ID name like it does for
+// chaincodes in containers.
+func StartInProc(env []string, cc Chaincode, stream PeerChaincodeStream) error {
+	var chaincodename string
+	for _, v := range env {
+		if strings.HasPrefix(v, "OPENCHAIN_CHAINCODE_ID_NAME=") {
+			chaincodename = strings.TrimPrefix(v, "OPENCHAIN_CHAINCODE_ID_NAME=")
+			break
+		}
+	}
+	if chaincodename == "" {
+		return fmt.Errorf("Error chaincode id not provided")
+	}
+	chaincodeLogger.Debug("Starting in-process chaincode %s", chaincodename)
+	return chatWithPeer(chaincodename, "inproc", stream, cc)
+}
+
 func getPeerAddress() string {
 	if peerAddress != "" {
 		return peerAddress
@@ -132,30 +174,20 @@ func newPeerClientConnection() (*grpc.ClientConn, error) {
 	return conn, err
 }
 
-func chatWithPeer(chaincodeSupportClient pb.ChaincodeSupportClient, cc Chaincode) error {
-
-	// Establish stream with validating peer
-	stream, err := chaincodeSupportClient.Register(context.Background())
-	if err != nil {
-		return fmt.Errorf("Error chatting with leader at address=%s:  %s", getPeerAddress(), err)
-	}
-
-	// Create the chaincode stub which will be passed to the chaincode
-	//stub := &ChaincodeStub{}
+func chatWithPeer(chaincodename string, to string, stream PeerChaincodeStream, cc Chaincode) error {
 
 	// Create the shim handler responsible for all control logic
-	handler = newChaincodeHandler(getPeerAddress(), stream, cc)
+	handler := newChaincodeHandler(to, stream, cc)
 
-	defer stream.CloseSend()
 	// Send the ChaincodeID during register.
-	chaincodeID := &pb.ChaincodeID{Name: viper.GetString("chaincode.id.name")}
+	chaincodeID := &pb.ChaincodeID{Name: chaincodename}
 	payload, err := proto.Marshal(chaincodeID)
 	if err != nil {
 		return fmt.Errorf("Error marshalling chaincodeID during chaincode registration: %s", err)
 	}
 	// Register on the stream
 	chaincodeLogger.Debug("Registering.. sending %s", pb.ChaincodeMessage_REGISTER)
-	handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload})
+	handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload, ProtocolVersion: pb.ChaincodeProtocolVersion})
 	waitc := make(chan struct{})
 	go func() {
 		defer close(waitc)
@@ -217,19 +249,102 @@ func chatWithPeer(chaincodeSupportClient pb.ChaincodeSupportClient, cc Chaincode
 	return err
 }
 
+// Aborted returns a channel that is closed when the validator aborts the
+// transaction or query, because it was cancelled or timed out. Long running
+// chaincodes should stop their work once it is closed, the validator refuses
+// the state changes of an aborted transaction.
+func (stub *ChaincodeStub) Aborted() <-chan struct{} {
+	return stub.aborted
+}
+
+// IsAborted reports whether the validator aborted the transaction or query
+func (stub *ChaincodeStub) IsAborted() bool {
+	select {
+	case <-stub.aborted:
+		return true
+	default:
+		return false
+	}
+}
+
 // GetState function can be invoked by a chaincode to get a state from the ledger.
 func (stub *ChaincodeStub) GetState(key string) ([]byte, error) {
-	return handler.handleGetState(key, stub.UUID)
+	return stub.handler.handleGetState(key, stub.UUID)
+}
+
+// GetChainState function can be invoked by a chaincode to get the committed
+// state of a chaincode on another chain. The validator checks the value
+// against the state hash of the chain before returning it. Transactions can
+// only read the chains hosted by the validators, queries can also read chains
+// served by the peers they trust.
+func (stub *ChaincodeStub) GetChainState(chainID string, chaincodeName string, key string) ([]byte, error) {
+	return stub.handler.handleGetChainState(chainID, chaincodeName, key, stub.UUID)
+}
+
+// GetDeploymentInfo function can be invoked by a chaincode to learn how it
+// was deployed: its name, the hash of its code, the deploy transaction and
+// the platform it was packaged for. Versions recorded by the deployer in the
+// metadata of the ChaincodeSpec are returned as well.
+func (stub *ChaincodeStub) GetDeploymentInfo() (*pb.DeploymentInfo, error) {
+	return stub.handler.handleGetDeploymentInfo(stub.UUID)
+}
+
+// GetTxRandomSeed function can be invoked by a chaincode to get the random
+// seed of the transaction. The seed is derived from the transaction and the
+// hash of the block it is executed after, so every validator executing it
+// gets the same one, unlike the seeds chaincodes draw themselves. Anyone
+// knowing the transaction and the chain can compute it: it must not be used
+// to generate secrets.
+func (stub *ChaincodeStub) GetTxRandomSeed() ([]byte, error) {
+	return stub.handler.handleGetTxValue(pb.ChaincodeMessage_GET_TX_RANDOM, stub.UUID)
+}
+
+// GetTxRandom function can be invoked by a chaincode to get a source of
+// random numbers seeded from GetTxRandomSeed, to be used instead of the
+// math/rand functions to keep the chaincode deterministic. Each call during
+// the transaction returns the same source, its numbers follow on from those
+// already drawn.
+func (stub *ChaincodeStub) GetTxRandom() (*rand.Rand, error) {
+	if stub.rand != nil {
+		return stub.rand, nil
+	}
+	seed, err := stub.GetTxRandomSeed()
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) < 8 {
+		return nil, fmt.Errorf("Random seed of transaction %s is too short", stub.UUID)
+	}
+	stub.rand = rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed))))
+	return stub.rand, nil
+}
+
+// GetTxTimestamp function can be invoked by a chaincode to get the timestamp
+// of the transaction, to be used instead of time.Now to keep the chaincode
+// deterministic. Every validator executing the transaction gets the same
+// timestamp, whenever it executes it. The timestamp is set by the submitter
+// of the transaction; validators running pbft only order the transaction if
+// the timestamp is within a bounded skew of their clocks.
+func (stub *ChaincodeStub) GetTxTimestamp() (time.Time, error) {
+	payload, err := stub.handler.handleGetTxValue(pb.ChaincodeMessage_GET_TX_TIME, stub.UUID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ts := &google_protobuf.Timestamp{}
+	if err = proto.Unmarshal(payload, ts); err != nil {
+		return time.Time{}, fmt.Errorf("Error unmarshalling timestamp: %s", err)
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
 }
 
 // PutState function can be invoked by a chaincode to put state into the ledger.
 func (stub *ChaincodeStub) PutState(key string, value []byte) error {
-	return handler.handlePutState(key, value, stub.UUID)
+	return stub.handler.handlePutState(key, value, stub.UUID)
 }
 
 // DelState function can be invoked by a chaincode to delete state from the ledger.
 func (stub *ChaincodeStub) DelState(key string) error {
-	return handler.handleDelState(key, stub.UUID)
+	return stub.handler.handleDelState(key, stub.UUID)
 }
 
 // StateRangeQueryIterator allows a chaincode to iterate over a range of
@@ -247,11 +362,29 @@ type StateRangeQueryIterator struct {
 // between the startKey and endKey, inclusive. The order in which keys are
 // returned by the iterator is random.
 func (stub *ChaincodeStub) RangeQueryState(startKey, endKey string) (*StateRangeQueryIterator, error) {
-	response, err := handler.handleRangeQueryState(startKey, endKey, stub.UUID)
+	return stub.rangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey})
+}
+
+// RangeQueryStateWithLimit works like RangeQueryState, but the iterator
+// returns at most limit keys. The validator stops the scan at the limit, so
+// that keys beyond it are not read. A limit of 0 means no limit.
+func (stub *ChaincodeStub) RangeQueryStateWithLimit(startKey, endKey string, limit uint32) (*StateRangeQueryIterator, error) {
+	return stub.rangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey, Limit: limit})
+}
+
+// RangeQueryKeys works like RangeQueryStateWithLimit, but the iterator only
+// returns the keys, with nil values. The validator neither reads nor decrypts
+// the values, which makes counting or listing keys cheaper.
+func (stub *ChaincodeStub) RangeQueryKeys(startKey, endKey string, limit uint32) (*StateRangeQueryIterator, error) {
+	return stub.rangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey, Limit: limit, KeysOnly: true})
+}
+
+func (stub *ChaincodeStub) rangeQueryState(rangeQueryState *pb.RangeQueryState) (*StateRangeQueryIterator, error) {
+	response, err := stub.handler.handleRangeQueryState(rangeQueryState, stub.UUID)
 	if err != nil {
 		return nil, err
 	}
-	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
+	return &StateRangeQueryIterator{stub.handler, stub.UUID, response, 0}, nil
 }
 
 // HasNext returns true if the range query iterator contains additional keys
@@ -296,10 +429,28 @@ func (iter *StateRangeQueryIterator) Close() error {
 
 // InvokeChaincode function can be invoked by a chaincode to execute another chaincode.
 func (stub *ChaincodeStub) InvokeChaincode(chaincodeName string, function string, args []string) ([]byte, error) {
-	return handler.handleInvokeChaincode(chaincodeName, function, args, stub.UUID)
+	return stub.handler.handleInvokeChaincode(chaincodeName, function, args, stub.UUID)
+}
+
+// EnqueueInvocation function can be invoked by a chaincode to have the
+// validators submit an invocation of a chaincode, possibly itself, as a new
+// transaction once the block of the current transaction commits. The
+// invocation is dropped if the current transaction fails. Its result is not
+// returned to the chaincode, so that multi-step workflows keep their progress
+// in the state.
+func (stub *ChaincodeStub) EnqueueInvocation(chaincodeName string, function string, args []string) error {
+	return stub.handler.handleEnqueueChaincode(chaincodeName, function, args, stub.UUID)
+}
+
+// SetEvent sets an event the validators send to the event consumers, with
+// the name of the chaincode and the UUID of the transaction, once the block
+// of the current transaction commits. It is dropped if the current
+// transaction fails.
+func (stub *ChaincodeStub) SetEvent(name string, payload []byte) error {
+	return stub.handler.handleSetEvent(name, payload, stub.UUID)
 }
 
 // QueryChaincode function can be invoked by a chaincode to query another chaincode.
 func (stub *ChaincodeStub) QueryChaincode(chaincodeName string, function string, args []string) ([]byte, error) {
-	return handler.handleQueryChaincode(chaincodeName, function, args, stub.UUID)
+	return stub.handler.handleQueryChaincode(chaincodeName, function, args, stub.UUID)
 }
@@ -22,13 +22,21 @@ package shim
 import (
 	"errors"
 	"fmt"
+	"math/rand"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/looplab/fsm"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
+// putStateChunkSize is the largest value put in a single message, larger
+// values are put in parts of this size to stay well under the gRPC message
+// size limit
+const putStateChunkSize = 1024 * 1024
+
 // PeerChaincodeStream interface for stream between Peer and chaincode instance.
 type PeerChaincodeStream interface {
 	Send(*pb.ChaincodeMessage) error
@@ -57,6 +65,16 @@ type Handler struct {
 	// Track which UUIDs are transactions and which are queries, to decide whether get/put state and invoke chaincode are allowed.
 	isTransaction map[string]bool
 	nextState     chan *nextStateInfo
+	// Channels closed when the validator aborts the execution with the Uuid
+	aborted map[string]chan struct{}
+	// Version of the protocol spoken with the validator, selected at REGISTER
+	protocolVersion uint32
+}
+
+// speaks returns whether messages of type msgType are part of the version of
+// the protocol spoken with the validator
+func (handler *Handler) speaks(msgType pb.ChaincodeMessage_Type) bool {
+	return msgType.ProtocolVersion() <= handler.protocolVersion
 }
 
 func shortuuid(uuid string) string {
@@ -139,6 +157,33 @@ func (handler *Handler) deleteIsTransaction(uuid string) {
 	handler.Unlock()
 }
 
+// startExecution returns the channel closed if the validator aborts the
+// execution of a transaction or query
+func (handler *Handler) startExecution(uuid string) <-chan struct{} {
+	handler.Lock()
+	defer handler.Unlock()
+	aborted := make(chan struct{})
+	handler.aborted[uuid] = aborted
+	return aborted
+}
+
+func (handler *Handler) endExecution(uuid string) {
+	handler.Lock()
+	defer handler.Unlock()
+	delete(handler.aborted, uuid)
+}
+
+// abortExecution signals the chaincode that the validator gave up on the
+// execution of a transaction or query
+func (handler *Handler) abortExecution(uuid string) {
+	handler.Lock()
+	defer handler.Unlock()
+	if aborted, ok := handler.aborted[uuid]; ok {
+		close(aborted)
+		delete(handler.aborted, uuid)
+	}
+}
+
 // NewChaincodeHandler returns a new instance of the shim side handler.
 func newChaincodeHandler(to string, peerChatStream PeerChaincodeStream, chaincode Chaincode) *Handler {
 	v := &Handler{
@@ -148,6 +193,7 @@ func newChaincodeHandler(to string, peerChatStream PeerChaincodeStream, chaincod
 	}
 	v.responseChannel = make(map[string]chan pb.ChaincodeMessage)
 	v.isTransaction = make(map[string]bool)
+	v.aborted = make(map[string]chan struct{})
 	v.nextState = make(chan *nextStateInfo)
 
 	// Create the shim side FSM
@@ -160,22 +206,26 @@ func newChaincodeHandler(to string, peerChatStream PeerChaincodeStream, chaincod
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{"init"}, Dst: "established"},
 			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{"init"}, Dst: "init"},
 			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{"init"}, Dst: "ready"},
-			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{"ready"}, Dst: "transaction"},
-			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{"transaction"}, Dst: "ready"},
-			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{"transaction"}, Dst: "ready"},
-			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{"transaction"}, Dst: "transaction"},
-			{Name: pb.ChaincodeMessage_QUERY.String(), Src: []string{"transaction"}, Dst: "transaction"},
+			// Transactions do not change the state so that any number of them can run at a time
+			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{"ready"}, Dst: "ready"},
+			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{"ready"}, Dst: "ready"},
+			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{"ready"}, Dst: "ready"},
 			{Name: pb.ChaincodeMessage_QUERY.String(), Src: []string{"ready"}, Dst: "ready"},
 			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{"ready"}, Dst: "ready"},
+			{Name: pb.ChaincodeMessage_TX_ABORT.String(), Src: []string{"init"}, Dst: "init"},
+			{Name: pb.ChaincodeMessage_TX_ABORT.String(), Src: []string{"ready"}, Dst: "ready"},
+			{Name: pb.ChaincodeMessage_DEPRECATION_WARNING.String(), Src: []string{"init"}, Dst: "init"},
+			{Name: pb.ChaincodeMessage_DEPRECATION_WARNING.String(), Src: []string{"ready"}, Dst: "ready"},
 		},
 		fsm.Callbacks{
 			"before_" + pb.ChaincodeMessage_REGISTERED.String(): func(e *fsm.Event) { v.beforeRegistered(e) },
 			//"after_" + pb.ChaincodeMessage_INIT.String(): func(e *fsm.Event) { v.beforeInit(e) },
-			//"after_" + pb.ChaincodeMessage_TRANSACTION.String(): func(e *fsm.Event) { v.beforeTransaction(e) },
-			"after_" + pb.ChaincodeMessage_RESPONSE.String(): func(e *fsm.Event) { v.afterResponse(e) },
-			"after_" + pb.ChaincodeMessage_ERROR.String():    func(e *fsm.Event) { v.afterError(e) },
-			"enter_init":                                     func(e *fsm.Event) { v.enterInitState(e) },
-			"enter_transaction":                              func(e *fsm.Event) { v.enterTransactionState(e) },
+			"after_" + pb.ChaincodeMessage_TRANSACTION.String():         func(e *fsm.Event) { v.afterTransaction(e) },
+			"after_" + pb.ChaincodeMessage_RESPONSE.String():            func(e *fsm.Event) { v.afterResponse(e) },
+			"after_" + pb.ChaincodeMessage_ERROR.String():               func(e *fsm.Event) { v.afterError(e) },
+			"after_" + pb.ChaincodeMessage_TX_ABORT.String():            func(e *fsm.Event) { v.afterAbort(e) },
+			"after_" + pb.ChaincodeMessage_DEPRECATION_WARNING.String(): func(e *fsm.Event) { v.afterDeprecationWarning(e) },
+			"enter_init": func(e *fsm.Event) { v.enterInitState(e) },
 			//"enter_ready":                                     func(e *fsm.Event) { v.enterReadyState(e) },
 			"before_" + pb.ChaincodeMessage_QUERY.String(): func(e *fsm.Event) { v.beforeQuery(e) }, //only checks for QUERY
 		},
@@ -185,11 +235,13 @@ func newChaincodeHandler(to string, peerChatStream PeerChaincodeStream, chaincod
 
 // beforeRegistered is called to handle the REGISTERED message.
 func (handler *Handler) beforeRegistered(e *fsm.Event) {
-	if _, ok := e.Args[0].(*pb.ChaincodeMessage); !ok {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	chaincodeLogger.Debug("Received %s, ready for invocations", pb.ChaincodeMessage_REGISTERED)
+	handler.protocolVersion = pb.NegotiateChaincodeProtocolVersion(msg.ProtocolVersion)
+	chaincodeLogger.Debug("Received %s (protocol version %d), ready for invocations", pb.ChaincodeMessage_REGISTERED, handler.protocolVersion)
 }
 
 // handleInit handles request to initialize chaincode.
@@ -222,9 +274,11 @@ func (handler *Handler) handleInit(msg *pb.ChaincodeMessage) {
 
 		// Call chaincode's Run
 		// Create the ChaincodeStub which the chaincode can use to callback
-		stub := new(ChaincodeStub)
-		stub.UUID = msg.Uuid
+		stub := &ChaincodeStub{UUID: msg.Uuid, handler: handler, securityContext: msg.SecurityContext, aborted: handler.startExecution(msg.Uuid)}
+		restore := perturbDeterminismRun(msg.DeterminismRun)
 		res, err := handler.cc.Run(stub, input.Function, input.Args)
+		restore()
+		handler.endExecution(msg.Uuid)
 
 		// delete isTransaction entry
 		handler.deleteIsTransaction(msg.Uuid)
@@ -289,8 +343,9 @@ func (handler *Handler) handleTransaction(msg *pb.ChaincodeMessage) {
 
 		// Call chaincode's Run
 		// Create the ChaincodeStub which the chaincode can use to callback
-		stub := &ChaincodeStub{UUID: msg.Uuid}
+		stub := &ChaincodeStub{UUID: msg.Uuid, handler: handler, securityContext: msg.SecurityContext, aborted: handler.startExecution(msg.Uuid)}
 		res, err := handler.cc.Run(stub, input.Function, input.Args)
+		handler.endExecution(msg.Uuid)
 
 		// delete isTransaction entry
 		handler.deleteIsTransaction(msg.Uuid)
@@ -309,6 +364,28 @@ func (handler *Handler) handleTransaction(msg *pb.ChaincodeMessage) {
 	}()
 }
 
+// perturbDeterminismRun varies the environment of the chaincode for a run of
+// a determinism check, so that chaincode depending on it diverges from one
+// run to the next: odd runs are scheduled on a single thread and even ones on
+// twice as many threads as there are CPUs, and every run reseeds the global
+// math/rand source. It returns a function restoring the scheduling. Run 0,
+// any execution outside a determinism check, is left alone.
+func perturbDeterminismRun(run uint32) func() {
+	if run == 0 {
+		return func() {}
+	}
+	procs := 1
+	if run%2 == 0 {
+		procs = 2 * runtime.NumCPU()
+	}
+	previous := runtime.GOMAXPROCS(procs)
+	rand.Seed(time.Now().UnixNano() + int64(run))
+	chaincodeLogger.Debug("Determinism check run %d scheduled on %d threads", run, procs)
+	return func() {
+		runtime.GOMAXPROCS(previous)
+	}
+}
+
 // handleQuery handles request to execute a query.
 func (handler *Handler) handleQuery(msg *pb.ChaincodeMessage) {
 	// Query does not transition state. It can happen anytime after Ready
@@ -335,9 +412,9 @@ func (handler *Handler) handleQuery(msg *pb.ChaincodeMessage) {
 
 		// Call chaincode's Query
 		// Create the ChaincodeStub which the chaincode can use to callback
-		stub := new(ChaincodeStub)
-		stub.UUID = msg.Uuid
+		stub := &ChaincodeStub{UUID: msg.Uuid, handler: handler, securityContext: msg.SecurityContext, aborted: handler.startExecution(msg.Uuid)}
 		res, err := handler.cc.Query(stub, input.Function, input.Args)
+		handler.endExecution(msg.Uuid)
 
 		// delete isTransaction entry
 		handler.deleteIsTransaction(msg.Uuid)
@@ -356,8 +433,8 @@ func (handler *Handler) handleQuery(msg *pb.ChaincodeMessage) {
 	}()
 }
 
-// enterTransactionState will execute chaincode's Run if coming from a TRANSACTION event.
-func (handler *Handler) enterTransactionState(e *fsm.Event) {
+// afterTransaction will execute chaincode's Run for a TRANSACTION event.
+func (handler *Handler) afterTransaction(e *fsm.Event) {
 	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
@@ -430,6 +507,40 @@ func (handler *Handler) afterError(e *fsm.Event) {
 	}
 }
 
+// afterAbort is called when the validator stops waiting for a transaction or
+// query. The chaincode is signalled through the stub, a request of the
+// chaincode pending for it fails, and the requests that follow are refused by
+// the validator.
+func (handler *Handler) afterAbort(e *fsm.Event) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Warning("[%s]Execution aborted by validator: %s", shortuuid(msg.Uuid), string(msg.Payload))
+	handler.abortExecution(msg.Uuid)
+	errMsg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: msg.Payload, Uuid: msg.Uuid}
+	if err := handler.sendChannel(errMsg); err == nil {
+		chaincodeLogger.Debug("[%s]Abort communicated to pending request (state:%s)", shortuuid(msg.Uuid), handler.FSM.Current())
+	}
+}
+
+// afterDeprecationWarning is called when the validator warns that the
+// chaincode uses a message being phased out. The warning is logged and
+// passed on to chaincodes implementing DeprecationListener.
+func (handler *Handler) afterDeprecationWarning(e *fsm.Event) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	warning := string(msg.Payload)
+	chaincodeLogger.Warning("[%s]Deprecated: %s. Rebuild the chaincode with an up to date shim.", shortuuid(msg.Uuid), warning)
+	if listener, ok := handler.cc.(DeprecationListener); ok {
+		listener.Deprecated(warning)
+	}
+}
+
 // TODO: Implement method to get and put entire state map and not one key at a time?
 // handleGetState communicates with the validator to fetch the requested state information from the ledger.
 func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error) {
@@ -474,6 +585,152 @@ func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error)
 	return nil, errors.New("Incorrect chaincode message received")
 }
 
+// handleGetChainState communicates with the validator to get a state of a chaincode on another chain.
+func (handler *Handler) handleGetChainState(chainID string, chaincodeName string, key string, uuid string) ([]byte, error) {
+	payload := &pb.StateProofRequest{ChainID: chainID, ChaincodeID: chaincodeName, Key: key}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, errors.New("Failed to process get chain state request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send GET_CHAIN_STATE message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_CHAIN_STATE, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_CHAIN_STATE)
+	if err := handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending GET_CHAIN_STATE %s", shortuuid(uuid), err))
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]GetChainState received payload %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		return responseMsg.Payload, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]GetChainState received error %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR))
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Error(fmt.Sprintf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR))
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
+// handleGetDeploymentInfo communicates with the validator to learn how the chaincode was deployed.
+func (handler *Handler) handleGetDeploymentInfo(uuid string) (*pb.DeploymentInfo, error) {
+	if !handler.speaks(pb.ChaincodeMessage_GET_DEPLOYMENT_INFO) {
+		return nil, errors.New("The validator cannot return the deployment info")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debug("Another request pending for this Uuid. Cannot process.")
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send GET_DEPLOYMENT_INFO message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_DEPLOYMENT_INFO, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_DEPLOYMENT_INFO)
+	if err := handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending GET_DEPLOYMENT_INFO %s", shortuuid(uuid), err))
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]GetDeploymentInfo received payload %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		info := &pb.DeploymentInfo{}
+		if err := proto.Unmarshal(responseMsg.Payload, info); err != nil {
+			chaincodeLogger.Error(fmt.Sprintf("[%s]GetDeploymentInfo failed to unmarshall response", shortuuid(responseMsg.Uuid)))
+			return nil, errors.New("Error unmarshalling DeploymentInfo")
+		}
+		return info, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]GetDeploymentInfo received error %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR))
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Error(fmt.Sprintf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR))
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
+// handleGetTxValue communicates with the validator to get the random seed or
+// the timestamp of the transaction, as requested by msgType, which is
+// GET_TX_RANDOM or GET_TX_TIME.
+func (handler *Handler) handleGetTxValue(msgType pb.ChaincodeMessage_Type, uuid string) ([]byte, error) {
+	if !handler.speaks(msgType) {
+		return nil, fmt.Errorf("The validator cannot handle %s", msgType)
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debug("Another request pending for this Uuid. Cannot process.")
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	msg := &pb.ChaincodeMessage{Type: msgType, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
+	if err := handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", shortuuid(uuid), msgType, err))
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]%s received payload %s", shortuuid(responseMsg.Uuid), msgType, pb.ChaincodeMessage_RESPONSE)
+		return responseMsg.Payload, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]%s received error %s", shortuuid(responseMsg.Uuid), msgType, pb.ChaincodeMessage_ERROR))
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Error(fmt.Sprintf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR))
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
 // handlePutState communicates with the validator to put state information into the ledger.
 func (handler *Handler) handlePutState(key string, value []byte, uuid string) error {
 	// Check if this is a transaction
@@ -482,8 +739,16 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 		return errors.New("Cannot put state in query context")
 	}
 
-	payload := &pb.PutStateInfo{Key: key, Value: value}
-	payloadBytes, err := proto.Marshal(payload)
+	// Values too large for a single message are sent in parts, if the validator can reassemble them
+	var msgs []*pb.ChaincodeMessage
+	var err error
+	if len(value) > putStateChunkSize && handler.speaks(pb.ChaincodeMessage_PUT_STATE_PART) {
+		msgs, err = putStatePartMessages(key, value, uuid)
+	} else {
+		var payloadBytes []byte
+		payloadBytes, err = proto.Marshal(&pb.PutStateInfo{Key: key, Value: value})
+		msgs = []*pb.ChaincodeMessage{{Type: pb.ChaincodeMessage_PUT_STATE, Payload: payloadBytes, Uuid: uuid}}
+	}
 	if err != nil {
 		return errors.New("Failed to process put state request")
 	}
@@ -497,12 +762,14 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 
 	defer handler.deleteChannel(uuid)
 
-	// Send PUT_STATE message to validator chaincode support
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Payload: payloadBytes, Uuid: uuid}
-	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_PUT_STATE)
-	if err = handler.serialSend(msg); err != nil {
-		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending PUT_STATE %s", msg.Uuid, err))
-		return errors.New("could not send msg")
+	// Send PUT_STATE message, or PUT_STATE_PART messages, to validator chaincode support
+	msg := msgs[len(msgs)-1]
+	chaincodeLogger.Debug("[%s]Sending %s (%d messages)", shortuuid(msg.Uuid), msg.Type, len(msgs))
+	for _, m := range msgs {
+		if err = handler.serialSend(m); err != nil {
+			chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", msg.Uuid, m.Type, err))
+			return errors.New("could not send msg")
+		}
 	}
 
 	// Wait on responseChannel for response
@@ -529,6 +796,28 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 	return errors.New("Incorrect chaincode message received")
 }
 
+// putStatePartMessages splits a value into PUT_STATE_PART messages of at
+// most putStateChunkSize bytes
+func putStatePartMessages(key string, value []byte, uuid string) ([]*pb.ChaincodeMessage, error) {
+	var msgs []*pb.ChaincodeMessage
+	for offset := 0; offset < len(value); offset += putStateChunkSize {
+		end := offset + putStateChunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		part := &pb.PutStatePart{Key: key, Chunk: value[offset:end], Last: end == len(value)}
+		if offset == 0 {
+			part.TotalSize = uint64(len(value))
+		}
+		payload, err := proto.Marshal(part)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE_PART, Payload: payload, Uuid: uuid})
+	}
+	return msgs, nil
+}
+
 // handleDelState communicates with the validator to delete a key from the state in the ledger.
 func (handler *Handler) handleDelState(key string, uuid string) error {
 	// Check if this is a transaction
@@ -577,7 +866,7 @@ func (handler *Handler) handleDelState(key string, uuid string) error {
 	return errors.New("Incorrect chaincode message received")
 }
 
-func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid string) (*pb.RangeQueryStateResponse, error) {
+func (handler *Handler) handleRangeQueryState(payload *pb.RangeQueryState, uuid string) (*pb.RangeQueryStateResponse, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(uuid)
 	if uniqueReqErr != nil {
@@ -588,7 +877,6 @@ func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid stri
 	defer handler.deleteChannel(uuid)
 
 	// Send RANGE_QUERY_STATE message to validator chaincode support
-	payload := &pb.RangeQueryState{StartKey: startKey, EndKey: endKey}
 	payloadBytes, err := proto.Marshal(payload)
 	if err != nil {
 		return nil, errors.New("Failed to process range query state request")
@@ -794,6 +1082,115 @@ func (handler *Handler) handleInvokeChaincode(chaincodeName string, function str
 	return nil, errors.New("Incorrect chaincode message received")
 }
 
+// handleEnqueueChaincode communicates with the validator to enqueue an
+// invocation it submits once the block of the transaction commits.
+func (handler *Handler) handleEnqueueChaincode(chaincodeName string, function string, args []string, uuid string) error {
+	// Check if this is a transaction
+	if !handler.isTransaction[uuid] {
+		return errors.New("Cannot enqueue chaincode invocation in query context")
+	}
+
+	chaincodeID := &pb.ChaincodeID{Name: chaincodeName}
+	input := &pb.ChaincodeInput{Function: function, Args: args}
+	payload := &pb.ChaincodeSpec{ChaincodeID: chaincodeID, CtorMsg: input}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return errors.New("Failed to process enqueue chaincode request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Another request pending for this Uuid. Cannot process.", uuid))
+		return uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send ENQUEUE_CHAINCODE message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ENQUEUE_CHAINCODE, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ENQUEUE_CHAINCODE)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ENQUEUE_CHAINCODE))
+		return errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]Received %s. Successfully enqueued invocation", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		return nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received %s.", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR))
+		return errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Debug("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return errors.New("Incorrect chaincode message received")
+}
+
+// handleSetEvent communicates with the validator to set an event it sends
+// once the block of the transaction commits.
+func (handler *Handler) handleSetEvent(name string, payload []byte, uuid string) error {
+	// Check if this is a transaction
+	if !handler.isTransaction[uuid] {
+		return errors.New("Cannot set event in query context")
+	}
+
+	payloadBytes, err := proto.Marshal(&pb.ChaincodeEvent{EventName: name, Payload: payload})
+	if err != nil {
+		return errors.New("Failed to process set event request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Another request pending for this Uuid. Cannot process.", uuid))
+		return uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send SET_EVENT message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_SET_EVENT, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_SET_EVENT)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_SET_EVENT))
+		return errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]Received %s. Successfully set event", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+		return nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received %s.", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR))
+		return errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Debug("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return errors.New("Incorrect chaincode message received")
+}
+
 // handleQueryChaincode communicates with the validator to query another chaincode.
 func (handler *Handler) handleQueryChaincode(chaincodeName string, function string, args []string, uuid string) ([]byte, error) {
 	chaincodeID := &pb.ChaincodeID{Name: chaincodeName}
@@ -0,0 +1,94 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+const (
+	// shutdownPollInterval is how often Shutdown checks for running executions
+	shutdownPollInterval = 50 * time.Millisecond
+	// containerStopTimeout is how many seconds the chaincode containers are
+	// given to exit on shutdown before they are killed
+	containerStopTimeout = 10
+)
+
+// Shutdown waits, up to timeout, for the transactions the chaincodes are
+// executing to complete, then stops the containers of the user chaincodes
+// launched by the peer. The containers are stopped even if executions are
+// still running when the deadline passes, in which case an error is returned.
+func (chaincodeSupport *ChaincodeSupport) Shutdown(ctxt context.Context, timeout time.Duration) error {
+	var err error
+	deadline := time.Now().Add(timeout)
+	for running := chaincodeSupport.runningExecutions(); running > 0; running = chaincodeSupport.runningExecutions() {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("%d chaincode executions still running after %s", running, timeout)
+			break
+		}
+		time.Sleep(shutdownPollInterval)
+	}
+
+	if chaincodeSupport.userRunsCC {
+		return err
+	}
+	chaincodeSupport.handlerMap.RLock()
+	chaincodes := []string{}
+	for chaincode := range chaincodeSupport.handlerMap.chaincodeMap {
+		if !IsSysCC(chaincode) {
+			chaincodes = append(chaincodes, chaincode)
+		}
+	}
+	chaincodeSupport.handlerMap.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, chaincode := range chaincodes {
+		wg.Add(1)
+		go func(chaincode string) {
+			defer wg.Done()
+			if stopErr := chaincodeSupport.stopChaincodeWithin(ctxt, &pb.ChaincodeID{Name: chaincode}, containerStopTimeout); stopErr != nil {
+				chaincodeLog.Warning("Error stopping chaincode %s on shutdown: %s", chaincode, stopErr)
+			} else {
+				chaincodeLog.Info("Stopped chaincode %s", chaincode)
+			}
+		}(chaincode)
+	}
+	wg.Wait()
+	return err
+}
+
+// runningExecutions returns the number of transactions and queries being
+// executed by the chaincodes
+func (chaincodeSupport *ChaincodeSupport) runningExecutions() int {
+	chaincodeSupport.handlerMap.RLock()
+	defer chaincodeSupport.handlerMap.RUnlock()
+	running := 0
+	for _, handler := range chaincodeSupport.handlerMap.chaincodeMap {
+		handler.Lock()
+		running += len(handler.txCtxs)
+		handler.Unlock()
+	}
+	return running
+}
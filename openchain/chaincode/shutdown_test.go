@@ -0,0 +1,53 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestShutdownWaitsForExecutions(t *testing.T) {
+	s := newDevModeSupport()
+	handler := &Handler{chaincodeSupport: s, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}, txCtxs: make(map[string]*transactionContext)}
+	s.handlerMap.chaincodeMap["mycc"] = handler
+	if _, err := handler.createTxContext(context.Background(), "tx1", nil, false); err != nil {
+		t.Fatalf("Error creating transaction context: %s", err)
+	}
+
+	if err := s.Shutdown(context.Background(), 100*time.Millisecond); err == nil {
+		t.Fatalf("Expected error shutting down with an execution running")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		handler.deleteTxContext("tx1")
+	}()
+	start := time.Now()
+	if err := s.Shutdown(context.Background(), 5*time.Second); err != nil {
+		t.Fatalf("Error shutting down: %s", err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("Shutdown returned before the execution completed")
+	}
+}
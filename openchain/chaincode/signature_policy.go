@@ -0,0 +1,79 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// errSignaturePolicyInsecure is returned for chaincodes with a signature
+// policy when security is disabled
+var errSignaturePolicyInsecure = errors.New("Signature policies require security to be enabled")
+
+// checkSignaturePolicy returns an error unless the invoker and the co-signers
+// of the transaction include threshold distinct signers the policy accepts.
+// Signers are identified by the enrollment ID in their certificate. The
+// certificates were validated against the CAs and the signatures verified
+// when the transaction was received, so policies are only enforced with
+// security: without it the co-signer certificates are not chained to the ECA
+// and anybody could co-sign under any enrollment ID.
+func checkSignaturePolicy(policy *pb.SignaturePolicy, tx *pb.Transaction, secure bool) error {
+	if policy == nil || policy.Threshold == 0 {
+		return nil
+	}
+	if !secure {
+		return errSignaturePolicyInsecure
+	}
+	if tx == nil {
+		return fmt.Errorf("Signature policy requires %d signers, transaction not signed", policy.Threshold)
+	}
+	accepted := make(map[string]bool)
+	for _, id := range policy.Signers {
+		accepted[id] = true
+	}
+
+	signers := make(map[string]bool)
+	count := func(der []byte) {
+		cert, err := utils.DERToX509Certificate(der)
+		if err != nil {
+			chaincodeLog.Warning("Ignoring signer of transaction %s: %s", tx.Uuid, err)
+			return
+		}
+		if id := cert.Subject.CommonName; len(accepted) == 0 || accepted[id] {
+			signers[id] = true
+		}
+	}
+	if tx.Cert != nil {
+		count(tx.Cert)
+	}
+	for _, coSignature := range tx.CoSignatures {
+		if coSignature != nil {
+			count(coSignature.Cert)
+		}
+	}
+	if uint32(len(signers)) < policy.Threshold {
+		return fmt.Errorf("Transaction %s has %d of the %d signers required by the signature policy", tx.Uuid, len(signers), policy.Threshold)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// newCoSigner returns the certificate and key of a signer with the enrollment ID
+func newCoSigner(t *testing.T, id string) ([]byte, *ecdsa.PrivateKey) {
+	conf.InitSecurityLevel(256)
+	key, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: id},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	return cert, key
+}
+
+func newCoSignedTx(t *testing.T, ids ...string) *pb.Transaction {
+	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: "tx1", Payload: []byte("transfer")}
+	for _, id := range ids {
+		cert, key := newCoSigner(t, id)
+		if err := crypto.CoSignTransaction(tx, cert, key); err != nil {
+			t.Fatalf("Error co-signing transaction: %s", err)
+		}
+	}
+	return tx
+}
+
+func TestSignaturePolicy_None(t *testing.T) {
+	tx := &pb.Transaction{Uuid: "tx1"}
+	if err := checkSignaturePolicy(nil, tx, false); err != nil {
+		t.Fatalf("Expected transaction without policy to be accepted: %s", err)
+	}
+	if err := checkSignaturePolicy(&pb.SignaturePolicy{}, tx, false); err != nil {
+		t.Fatalf("Expected transaction with a zero threshold to be accepted: %s", err)
+	}
+}
+
+func TestSignaturePolicy_Threshold(t *testing.T) {
+	policy := &pb.SignaturePolicy{Threshold: 2, Signers: []string{"alice", "bob"}}
+	if err := checkSignaturePolicy(policy, newCoSignedTx(t, "alice", "bob"), true); err != nil {
+		t.Fatalf("Expected transaction signed by alice and bob to be accepted: %s", err)
+	}
+	// The same signer co-signing twice counts once
+	if err := checkSignaturePolicy(policy, newCoSignedTx(t, "alice", "alice"), true); err == nil {
+		t.Fatalf("Expected transaction signed twice by alice to be rejected")
+	}
+	// Signers not listed by the policy do not count
+	if err := checkSignaturePolicy(policy, newCoSignedTx(t, "alice", "carol"), true); err == nil {
+		t.Fatalf("Expected transaction signed by alice and carol to be rejected")
+	}
+	// Without listed signers everybody counts
+	if err := checkSignaturePolicy(&pb.SignaturePolicy{Threshold: 2}, newCoSignedTx(t, "alice", "carol"), true); err != nil {
+		t.Fatalf("Expected transaction signed by alice and carol to be accepted: %s", err)
+	}
+}
+
+func TestSignaturePolicy_Invoker(t *testing.T) {
+	// The invoker counts, its signature was verified on receipt
+	policy := &pb.SignaturePolicy{Threshold: 2, Signers: []string{"alice", "bob"}}
+	tx := newCoSignedTx(t, "bob")
+	tx.Cert, _ = newCoSigner(t, "alice")
+	if err := checkSignaturePolicy(policy, tx, true); err != nil {
+		t.Fatalf("Expected transaction invoked by alice and co-signed by bob to be accepted: %s", err)
+	}
+}
+
+func TestSignaturePolicy_Insecure(t *testing.T) {
+	// Without security nothing chains the co-signer certificates to the ECA
+	policy := &pb.SignaturePolicy{Threshold: 2}
+	if err := checkSignaturePolicy(policy, newCoSignedTx(t, "alice", "bob"), false); err != errSignaturePolicyInsecure {
+		t.Fatalf("Expected the policy to be refused without security, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+)
+
+// stateView is the state a transaction or query reads. It is chosen when the
+// context of the execution is created: queries read an immutable snapshot of
+// the committed state, while transactions read the state changed by the
+// transaction batch in progress and by their own buffered changes. Simulated
+// transactions read their own changes over a snapshot of the committed state.
+type stateView interface {
+	getState(chaincodeID string, key string) ([]byte, error)
+	getStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error)
+	// release frees the view once the execution is over, after its range
+	// query iterators are closed
+	release()
+}
+
+func newStateView(chaincodeSupport *ChaincodeSupport, chainID string, uuid string, query bool) stateView {
+	if query {
+		return &queryStateView{chainID: chainID}
+	}
+	if chaincodeSupport.writeSets.isSimulation(uuid) {
+		return &simulationStateView{queryStateView: queryStateView{chainID: chainID}, chaincodeSupport: chaincodeSupport, uuid: uuid}
+	}
+	return &txStateView{chaincodeSupport: chaincodeSupport, chainID: chainID, uuid: uuid}
+}
+
+// getStateView returns the view of the state of a transaction or query
+func (handler *Handler) getStateView(uuid string) (stateView, error) {
+	txctx := handler.getTxContext(uuid)
+	if txctx == nil {
+		return nil, fmt.Errorf("The transaction or query is not running")
+	}
+	return txctx.state, nil
+}
+
+// txStateView reads the state in progress for a transaction
+type txStateView struct {
+	chaincodeSupport *ChaincodeSupport
+	chainID          string
+	uuid             string
+}
+
+func (view *txStateView) getState(chaincodeID string, key string) ([]byte, error) {
+	ledgerObj, err := ledger.GetChainLedger(view.chainID)
+	if err != nil {
+		return nil, err
+	}
+	return view.chaincodeSupport.getTxState(ledgerObj, view.uuid, chaincodeID, key)
+}
+
+func (view *txStateView) getStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	ledgerObj, err := ledger.GetChainLedger(view.chainID)
+	if err != nil {
+		return nil, err
+	}
+	return view.chaincodeSupport.getTxStateRangeScanIterator(ledgerObj, view.uuid, chaincodeID, startKey, endKey)
+}
+
+func (view *txStateView) release() {}
+
+// queryStateView reads a snapshot of the committed state for a query. The
+// snapshot is taken at the first read, so that queries not reading the state
+// do not hold one.
+type queryStateView struct {
+	chainID string
+
+	sync.Mutex
+	snapshot *ledger.ReadSnapshot
+	released bool
+}
+
+func (view *queryStateView) getSnapshot() (*ledger.ReadSnapshot, error) {
+	view.Lock()
+	defer view.Unlock()
+	if view.released {
+		return nil, fmt.Errorf("The query is over, its state can no longer be read")
+	}
+	if view.snapshot == nil {
+		ledgerObj, err := ledger.GetChainLedger(view.chainID)
+		if err != nil {
+			return nil, err
+		}
+		view.snapshot = ledgerObj.GetReadSnapshot()
+	}
+	return view.snapshot, nil
+}
+
+func (view *queryStateView) getState(chaincodeID string, key string) ([]byte, error) {
+	snapshot, err := view.getSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.GetState(chaincodeID, key)
+}
+
+func (view *queryStateView) getStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	snapshot, err := view.getSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.GetStateRangeScanIterator(chaincodeID, startKey, endKey)
+}
+
+func (view *queryStateView) release() {
+	view.Lock()
+	defer view.Unlock()
+	view.released = true
+	if view.snapshot != nil {
+		view.snapshot.Release()
+		view.snapshot = nil
+	}
+}
+
+// simulationStateView reads the state for a simulated transaction: its own
+// changes over a snapshot of the committed state. The batch in progress is
+// left out as it may never be committed, so that validators at the same
+// height agree on the outcome of the simulation.
+type simulationStateView struct {
+	queryStateView
+	chaincodeSupport *ChaincodeSupport
+	uuid             string
+}
+
+func (view *simulationStateView) getState(chaincodeID string, key string) ([]byte, error) {
+	if err := view.chaincodeSupport.keyHints.check(view.uuid, chaincodeID, key); err != nil {
+		return nil, err
+	}
+	if delta := view.chaincodeSupport.writeSets.get(view.uuid); delta != nil {
+		if updatedValue := delta.Get(chaincodeID, key); updatedValue != nil {
+			return updatedValue.GetValue(), nil
+		}
+	}
+	return view.queryStateView.getState(chaincodeID, key)
+}
+
+func (view *simulationStateView) getStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	if err := view.chaincodeSupport.keyHints.checkRange(view.uuid, chaincodeID); err != nil {
+		return nil, err
+	}
+	delta := view.chaincodeSupport.writeSets.get(view.uuid)
+	if delta == nil {
+		delta = statemgmt.NewStateDelta()
+	}
+	snapshot, err := view.getSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.GetTxStateRangeScanIterator(delta, chaincodeID, startKey, endKey)
+}
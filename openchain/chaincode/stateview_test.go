@@ -0,0 +1,94 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestStateViews(t *testing.T) {
+	ledgerObj := ledger.InitTestLedger(t)
+	commit := func(n int, value string) {
+		ledgerObj.BeginTxBatch(n)
+		ledgerObj.TxBegin("tx")
+		ledgerObj.SetState("mycc", "key", []byte(value))
+		ledgerObj.TxFinished("tx", true)
+		if err := ledgerObj.CommitTxBatch(n, []*pb.Transaction{{Uuid: "tx"}}, nil, nil); err != nil {
+			t.Fatalf("Error committing block %d: %s", n, err)
+		}
+	}
+	expect := func(view stateView, expected string) {
+		value, err := view.getState("mycc", "key")
+		if err != nil || !bytes.Equal(value, []byte(expected)) {
+			t.Fatalf("Expected %s, got %s (%v)", expected, value, err)
+		}
+		itr, err := view.getStateRangeScanIterator("mycc", "", "")
+		if err != nil {
+			t.Fatalf("Error scanning the state: %s", err)
+		}
+		statemgmt.AssertIteratorContains(t, itr, map[string][]byte{"key": []byte(expected)})
+		itr.Close()
+	}
+	chaincodeSupport := &ChaincodeSupport{keyLocks: newKeyLockManager(), writeSets: newTxWriteSets(), keyHints: newTxKeyHints()}
+	commit(0, "v1")
+
+	// Queries read the committed state as of their first read
+	query := newStateView(chaincodeSupport, "", "q1", true)
+	expect(query, "v1")
+	commit(1, "v2")
+	expect(query, "v1")
+	expect(newStateView(chaincodeSupport, "", "q2", true), "v2")
+
+	// Transactions read the batch in progress and their own changes
+	tx := newStateView(chaincodeSupport, "", "tx1", false)
+	ledgerObj.BeginTxBatch(2)
+	ledgerObj.TxBegin("tx")
+	ledgerObj.SetState("mycc", "key", []byte("v3"))
+	ledgerObj.TxFinished("tx", true)
+	expect(tx, "v3")
+	expect(query, "v1")
+
+	// Simulated transactions read their own changes over the committed state
+	chaincodeSupport.writeSets.beginSimulation("sim1")
+	sim := newStateView(chaincodeSupport, "", "sim1", false)
+	expect(sim, "v2")
+	chaincodeSupport.writeSets.get("sim1").Set("mycc", "key", []byte("v5"), nil)
+	expect(sim, "v5")
+	chaincodeSupport.writeSets.finish("sim1")
+	sim.release()
+	chaincodeSupport.writeSets.begin("tx1")
+	defer chaincodeSupport.writeSets.finish("tx1")
+	defer chaincodeSupport.keyLocks.unlock("tx1")
+	if err := chaincodeSupport.setTxState("tx1", "mycc", "key", []byte("v4")); err != nil {
+		t.Fatalf("Error setting state: %s", err)
+	}
+	expect(tx, "v4")
+	ledgerObj.RollbackTxBatch(2)
+
+	query.release()
+	if _, err := query.getState("mycc", "key"); err == nil {
+		t.Fatalf("Expected a released view not to be read")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/container"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// SystemChaincode is a privileged chaincode run in-process by the peer
+// instead of in a container. System chaincodes talk to the peer with the
+// same messages as any other chaincode. They are launched on every peer at
+// startup without an INIT, as there is no deploy transaction on the ledger
+// whose state changes all peers would agree on.
+type SystemChaincode struct {
+	// Name invocations and queries address the chaincode by
+	Name string
+	// Path identifies the implementation, eg. its package
+	Path string
+	// Chaincode implements the system chaincode
+	Chaincode shim.Chaincode
+}
+
+type sysccEntry struct {
+	syscc *SystemChaincode
+	// deploy transaction the chaincode is launched with
+	depTx *pb.Transaction
+}
+
+var sysccs = struct {
+	sync.RWMutex
+	entries map[string]*sysccEntry
+}{entries: make(map[string]*sysccEntry)}
+
+// RegisterSysCC registers a system chaincode. Registered system chaincodes
+// are started by DeploySysCCs.
+func RegisterSysCC(syscc *SystemChaincode) error {
+	if syscc.Name == "" || syscc.Chaincode == nil {
+		return fmt.Errorf("system chaincode needs a name and an implementation")
+	}
+	sysccs.Lock()
+	defer sysccs.Unlock()
+	if _, ok := sysccs.entries[syscc.Name]; ok {
+		return fmt.Errorf("system chaincode %s already registered", syscc.Name)
+	}
+	if err := container.RegisterInProc(syscc.Name, syscc.Chaincode); err != nil {
+		return err
+	}
+	sysccs.entries[syscc.Name] = &sysccEntry{syscc: syscc}
+	chaincodeLog.Info("Registered system chaincode %s(%s)", syscc.Name, syscc.Path)
+	return nil
+}
+
+// IsSysCC returns true if the named chaincode is a registered system chaincode
+func IsSysCC(name string) bool {
+	sysccs.RLock()
+	defer sysccs.RUnlock()
+	_, ok := sysccs.entries[name]
+	return ok
+}
+
+// getSysCCDeployTx returns the deploy transaction the system chaincode was
+// started with
+func getSysCCDeployTx(name string) (*pb.Transaction, error) {
+	sysccs.RLock()
+	defer sysccs.RUnlock()
+	entry, ok := sysccs.entries[name]
+	if !ok || entry.depTx == nil {
+		return nil, fmt.Errorf("system chaincode %s not deployed", name)
+	}
+	return entry.depTx, nil
+}
+
+// DeploySysCCs starts all registered system chaincodes on the chain
+func DeploySysCCs(ctxt context.Context, chain *ChaincodeSupport) error {
+	sysccs.RLock()
+	var names []string
+	for name := range sysccs.entries {
+		names = append(names, name)
+	}
+	sysccs.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := deploySysCC(ctxt, chain, name); err != nil {
+			return fmt.Errorf("Error deploying system chaincode %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func deploySysCC(ctxt context.Context, chain *ChaincodeSupport, name string) error {
+	sysccs.Lock()
+	entry := sysccs.entries[name]
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Path: entry.syscc.Path, Name: name}, CtorMsg: &pb.ChaincodeInput{}}
+	cds := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, ExecEnv: pb.ChaincodeDeploymentSpec_SYSTEM}
+	depTx, err := pb.NewChaincodeDeployTransaction(cds, name)
+	if err != nil {
+		sysccs.Unlock()
+		return err
+	}
+	entry.depTx = depTx
+	sysccs.Unlock()
+
+	if _, err = chain.DeployChaincode(ctxt, depTx); err != nil {
+		return err
+	}
+	if _, err = chain.launchAndWaitForRegister(ctxt, spec.ChaincodeID, depTx.Uuid); err != nil {
+		return err
+	}
+	// no INIT, bring the chaincode straight to ready
+	if err = chain.sendInitOrReady(ctxt, depTx.Uuid, name, nil, nil, chain.ccStartupTimeout, depTx, nil); err != nil {
+		chain.stopChaincode(ctxt, spec.ChaincodeID)
+		return err
+	}
+	chaincodeLog.Info("Deployed system chaincode %s", name)
+	return nil
+}
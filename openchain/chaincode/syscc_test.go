@@ -0,0 +1,329 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// sampleSysCC stores and returns the value of a single key
+type sampleSysCC struct {
+}
+
+func (t *sampleSysCC) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if function == "putdel" {
+		return nil, t.putDel(stub, args[0], []byte(args[1]))
+	}
+	if function == "meet" {
+		if err := t.meet(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, stub.PutState(args[0], []byte(args[1]))
+}
+
+// meeting is joined by the invocations of the "meet" function of sampleSysCC
+var meeting sync.WaitGroup
+
+// meet waits for the other invocations joining the meeting, which only
+// succeeds if they run concurrently
+func (t *sampleSysCC) meet() error {
+	meeting.Done()
+	met := make(chan struct{})
+	go func() {
+		meeting.Wait()
+		close(met)
+	}()
+	select {
+	case <-met:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("Timed out waiting for concurrent invocations")
+	}
+}
+
+// putDel checks that the chaincode reads its own writes within one invoke
+func (t *sampleSysCC) putDel(stub *shim.ChaincodeStub, key string, value []byte) error {
+	if err := stub.PutState(key, value); err != nil {
+		return err
+	}
+	val, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if string(val) != string(value) {
+		return fmt.Errorf("Expected %s after put, got %s", string(value), string(val))
+	}
+	if err = stub.DelState(key); err != nil {
+		return err
+	}
+	if val, err = stub.GetState(key); err != nil {
+		return err
+	}
+	if val != nil {
+		return fmt.Errorf("Expected nil after delete, got %s", string(val))
+	}
+	return nil
+}
+
+func (t *sampleSysCC) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return stub.GetState(args[0])
+}
+
+func TestSysCC_InvokeAndQuery(t *testing.T) {
+	viper.Set("peer.fileSystemPath", "/var/openchain/test/tmpdb")
+	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "testpeer"}, Address: "0.0.0.0:40303"}, nil
+	}
+	ccStartupTimeout := time.Duration(chaincodeStartupTimeoutDefault) * time.Millisecond
+	chain := NewChaincodeSupport(DefaultChain, getPeerEndpoint, false, ccStartupTimeout, nil)
+
+	syscc := &SystemChaincode{Name: "sample_syscc", Path: "github.com/openblockchain/obc-peer/openchain/chaincode/sample_syscc", Chaincode: &sampleSysCC{}}
+	if err := RegisterSysCC(syscc); err != nil {
+		t.Fatalf("Error registering system chaincode: %s", err)
+	}
+	if err := RegisterSysCC(syscc); err == nil {
+		t.Fatalf("Expected registering a system chaincode twice to fail")
+	}
+
+	ctxt := context.Background()
+	if err := DeploySysCCs(ctxt, chain); err != nil {
+		t.Fatalf("Error deploying system chaincodes: %s", err)
+	}
+	cID := &pb.ChaincodeID{Name: syscc.Name}
+	defer chain.stopChaincode(ctxt, cID)
+
+	spec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "put", Args: []string{"a", "10"}}}
+	if _, _, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_EXECUTE); err != nil {
+		t.Fatalf("Error invoking system chaincode: %s", err)
+	}
+
+	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "get", Args: []string{"a"}}}
+	_, val, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_QUERY)
+	if err != nil {
+		t.Fatalf("Error querying system chaincode: %s", err)
+	}
+	if string(val) != "10" {
+		t.Fatalf("Expected 10, got %s", string(val))
+	}
+
+	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "putdel", Args: []string{"b", "20"}}}
+	if _, _, err = invoke(ctxt, spec, pb.Transaction_CHAINCODE_EXECUTE); err != nil {
+		t.Fatalf("Error reading own writes in system chaincode: %s", err)
+	}
+
+	// invocations on disjoint keys run concurrently
+	ledgerObj, _ := ledger.GetLedger()
+	ledgerObj.BeginTxBatch("meet")
+	meeting.Add(2)
+	var txs []*pb.Transaction
+	errs := make(chan error, 2)
+	for _, key := range []string{"c", "d"} {
+		spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "meet", Args: []string{key, "30"}}}
+		tx, err := pb.NewChaincodeExecute(&pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}, util.GenerateUUID(), pb.Transaction_CHAINCODE_EXECUTE)
+		if err != nil {
+			t.Fatalf("Error creating transaction: %s", err)
+		}
+		txs = append(txs, tx)
+		go func() {
+			_, err := Execute(ctxt, chain, tx)
+			errs <- err
+		}()
+	}
+	for range txs {
+		if err = <-errs; err != nil {
+			t.Fatalf("Error invoking system chaincode concurrently: %s", err)
+		}
+	}
+	ledgerObj.CommitTxBatch("meet", txs, nil, nil)
+	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "get", Args: []string{"d"}}}
+	if _, val, err = invoke(ctxt, spec, pb.Transaction_CHAINCODE_QUERY); err != nil || string(val) != "30" {
+		t.Fatalf("Expected 30, got %s (%v)", string(val), err)
+	}
+
+	chaincodes, err := chain.ListChaincodes(ctxt, "")
+	if err != nil {
+		t.Fatalf("Error listing chaincodes: %s", err)
+	}
+	var info *pb.ChaincodeInfo
+	for _, c := range chaincodes {
+		if c.ChaincodeID.Name == syscc.Name {
+			info = c
+		}
+	}
+	if info == nil {
+		t.Fatalf("System chaincode not listed")
+	}
+	if info.ExecEnv != pb.ChaincodeDeploymentSpec_SYSTEM || info.ContainerStatus != "running" || info.HandlerState != readystate {
+		t.Fatalf("Unexpected info for system chaincode: %v", info)
+	}
+}
+
+func TestSysCC_DeployRejected(t *testing.T) {
+	spec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: &pb.ChaincodeID{Name: "not_a_syscc"}, CtorMsg: &pb.ChaincodeInput{}}
+	cds := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, ExecEnv: pb.ChaincodeDeploymentSpec_SYSTEM}
+	tx, err := pb.NewChaincodeDeployTransaction(cds, "not_a_syscc")
+	if err != nil {
+		t.Fatalf("Error creating deploy transaction: %s", err)
+	}
+	chain := &ChaincodeSupport{handlerMap: &handlerMap{chaincodeMap: make(map[string]*Handler)}}
+	if _, err = chain.DeployChaincode(context.Background(), tx); err == nil {
+		t.Fatalf("Expected deploying to the SYSTEM environment to be rejected")
+	}
+}
+
+func TestSysCC_ConfigTransaction(t *testing.T) {
+	viper.Set("peer.fileSystemPath", "/var/openchain/test/tmpdb")
+	getPeerEndpoint := func() (*pb.PeerEndpoint, error) {
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: "testpeer"}, Address: "0.0.0.0:40303"}, nil
+	}
+	ccStartupTimeout := time.Duration(chaincodeStartupTimeoutDefault) * time.Millisecond
+	chain := NewChaincodeSupport(DefaultChain, getPeerEndpoint, false, ccStartupTimeout, nil)
+
+	syscc := &SystemChaincode{Name: netconfig.Name, Path: netconfig.Path, Chaincode: new(netconfig.NetworkConfig)}
+	if err := RegisterSysCC(syscc); err != nil {
+		t.Fatalf("Error registering system chaincode: %s", err)
+	}
+	ctxt := context.Background()
+	if err := deploySysCC(ctxt, chain, netconfig.Name); err != nil {
+		t.Fatalf("Error deploying system chaincode: %s", err)
+	}
+	cID := &pb.ChaincodeID{Name: netconfig.Name}
+	defer chain.stopChaincode(ctxt, cID)
+
+	spec := &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "update", Args: []string{netconfig.BatchSize, "20", "100"}}}
+	if _, _, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_EXECUTE); err == nil {
+		t.Fatalf("Expected an invoke transaction changing a network parameter to be rejected")
+	}
+	if _, _, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_CONFIG); err != nil {
+		t.Fatalf("Error executing configuration transaction: %s", err)
+	}
+
+	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "update", Args: []string{netconfig.BatchSize, "-1", "100"}}}
+	if _, _, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_CONFIG); err == nil {
+		t.Fatalf("Expected a configuration transaction with an invalid value to fail")
+	}
+
+	spec = &pb.ChaincodeSpec{Type: 1, ChaincodeID: cID, CtorMsg: &pb.ChaincodeInput{Function: "get", Args: []string{netconfig.BatchSize}}}
+	_, val, err := invoke(ctxt, spec, pb.Transaction_CHAINCODE_QUERY)
+	if err != nil {
+		t.Fatalf("Error querying network parameter: %s", err)
+	}
+	if string(val) != `[{"value":"20","height":100}]` {
+		t.Fatalf("Unexpected changes of %s: %s", netconfig.BatchSize, string(val))
+	}
+}
+
+// testECASecHelper verifies enrollment signatures as if every certificate
+// was issued by the ECA
+type testECASecHelper struct {
+	crypto.Peer
+}
+
+func (testECASecHelper) VerifyEnrollmentSignature(ecert, signature, message []byte) error {
+	cert, err := utils.DERToX509Certificate(ecert)
+	if err != nil {
+		return err
+	}
+	if ok, err := utils.ECDSAVerify(cert.PublicKey, message, signature); err != nil || !ok {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// newSignedConfigTx returns a configuration transaction signed with an
+// enrollment certificate holding the role
+func newSignedConfigTx(t *testing.T, role obcca.Role) *pb.Transaction {
+	conf.InitSecurityLevel(256)
+	key, err := utils.NewECDSAKey()
+	if err != nil {
+		t.Fatalf("Error creating key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ops"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{
+			{Id: crypto.ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(int(role)))},
+		},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{Type: 1, ChaincodeID: &pb.ChaincodeID{Name: netconfig.Name},
+		CtorMsg: &pb.ChaincodeInput{Function: "update", Args: []string{netconfig.BatchSize, "20", "100"}}}}
+	tx, err := crypto.BuildConfigTransaction(spec, util.GenerateUUID(), &crypto.OfflineTxOpts{Cert: cert})
+	if err != nil {
+		t.Fatalf("Error building configuration transaction: %s", err)
+	}
+	if err := crypto.SignTransaction(tx, key); err != nil {
+		t.Fatalf("Error signing configuration transaction: %s", err)
+	}
+	return tx
+}
+
+func TestCheckConfigSubmitter(t *testing.T) {
+	sec := testECASecHelper{}
+	if err := checkConfigSubmitter(sec, newSignedConfigTx(t, obcca.Role_CLIENT|obcca.Role_ADMIN)); err != nil {
+		t.Fatalf("Expected the configuration transaction of an admin to be accepted: %s", err)
+	}
+	if err := checkConfigSubmitter(sec, newSignedConfigTx(t, obcca.Role_CLIENT)); err == nil {
+		t.Fatalf("Expected the configuration transaction of a client to be rejected")
+	}
+
+	tampered := newSignedConfigTx(t, obcca.Role_ADMIN)
+	tampered.Payload = append(tampered.Payload, 0)
+	if err := checkConfigSubmitter(sec, tampered); err == nil {
+		t.Fatalf("Expected a tampered configuration transaction to be rejected")
+	}
+	unsigned := newSignedConfigTx(t, obcca.Role_ADMIN)
+	unsigned.Cert, unsigned.Signature = nil, nil
+	if err := checkConfigSubmitter(sec, unsigned); err == nil {
+		t.Fatalf("Expected an unsigned configuration transaction to be rejected")
+	}
+
+	// Without security there are no identities to check
+	if err := checkConfigSubmitter(nil, unsigned); err != nil {
+		t.Fatalf("Expected configuration transactions to be accepted without security: %s", err)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package testing provides a mock chaincode stream, to script the messages a
+// chaincode exchanges with a real chaincode handler in tests, for instance
+// those of system chaincodes or of extensions of the peer.
+package testing
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// DefaultTimeout is how long Expect and Play wait for a message of the handler
+const DefaultTimeout = 5 * time.Second
+
+// MockStream is the peer end of a chaincode stream. Messages delivered to
+// it are received by the handler as if sent by the chaincode, and messages
+// sent by the handler are kept in order for the test to check. It
+// implements pb.ChaincodeSupport_RegisterServer so that it can be passed to
+// chaincode.HandleChaincodeStream.
+type MockStream struct {
+	// Messages sent by the handler, in order
+	Sent chan *pb.ChaincodeMessage
+
+	recv  chan *pb.ChaincodeMessage
+	ended chan struct{}
+	once  sync.Once
+
+	sync.Mutex
+	// While not nil, each send waits for a value or the close of release
+	release chan struct{}
+	sendErr error
+}
+
+// NewMockStream returns a stream keeping up to 64 messages sent by the
+// handler
+func NewMockStream() *MockStream {
+	return &MockStream{Sent: make(chan *pb.ChaincodeMessage, 64), recv: make(chan *pb.ChaincodeMessage),
+		ended: make(chan struct{})}
+}
+
+// Send implements PeerChaincodeStream. It blocks while sends are held and
+// fails once FailSends is called.
+func (s *MockStream) Send(msg *pb.ChaincodeMessage) error {
+	s.Lock()
+	release := s.release
+	s.Unlock()
+	if release != nil {
+		<-release
+	}
+	s.Lock()
+	err := s.sendErr
+	s.Unlock()
+	if err != nil {
+		return err
+	}
+	s.Sent <- msg
+	return nil
+}
+
+// Recv implements PeerChaincodeStream. It returns the delivered messages,
+// then io.EOF once the stream is ended.
+func (s *MockStream) Recv() (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.recv:
+		return msg, nil
+	case <-s.ended:
+		return nil, io.EOF
+	}
+}
+
+// Deliver hands msg to the handler as if sent by the chaincode. It blocks
+// until the handler receives it.
+func (s *MockStream) Deliver(msg *pb.ChaincodeMessage) error {
+	select {
+	case s.recv <- msg:
+		return nil
+	case <-s.ended:
+		return fmt.Errorf("Cannot deliver %s, the stream ended", msg.Type)
+	}
+}
+
+// End ends the stream as if the chaincode went away
+func (s *MockStream) End() {
+	s.once.Do(func() { close(s.ended) })
+}
+
+// Hold makes sends block until released, as if the chaincode did not keep up
+func (s *MockStream) Hold() {
+	s.Lock()
+	defer s.Unlock()
+	if s.release == nil {
+		s.release = make(chan struct{})
+	}
+}
+
+// ReleaseOne lets a single held send through. It blocks until a send is
+// waiting.
+func (s *MockStream) ReleaseOne() {
+	s.Lock()
+	release := s.release
+	s.Unlock()
+	if release != nil {
+		release <- struct{}{}
+	}
+}
+
+// Release lets the held sends, and the following ones, through
+func (s *MockStream) Release() {
+	s.Lock()
+	defer s.Unlock()
+	if s.release != nil {
+		close(s.release)
+		s.release = nil
+	}
+}
+
+// FailSends makes the following sends fail with err
+func (s *MockStream) FailSends(err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.sendErr = err
+}
+
+// Expect returns the next message sent by the handler, which must be of
+// type msgType
+func (s *MockStream) Expect(msgType pb.ChaincodeMessage_Type) (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.Sent:
+		if msg.Type != msgType {
+			return msg, fmt.Errorf("Expected %s, got %s of %s: %s", msgType, msg.Type, msg.Uuid, msg.Payload)
+		}
+		return msg, nil
+	case <-time.After(DefaultTimeout):
+		return nil, fmt.Errorf("Timed out waiting for %s", msgType)
+	}
+}
+
+// Step is a step of a script played on a MockStream: either a message
+// delivered to the handler or a message expected from it
+type Step struct {
+	Deliver *pb.ChaincodeMessage
+	// Only the type of the expected message, and its uuid if set, are checked
+	Expect *pb.ChaincodeMessage
+}
+
+// Play plays the steps in order and stops at the first that fails
+func (s *MockStream) Play(steps ...Step) error {
+	for i, step := range steps {
+		if step.Deliver != nil {
+			if err := s.Deliver(step.Deliver); err != nil {
+				return fmt.Errorf("Step %d: %s", i, err)
+			}
+		}
+		if step.Expect != nil {
+			msg, err := s.Expect(step.Expect.Type)
+			if err != nil {
+				return fmt.Errorf("Step %d: %s", i, err)
+			}
+			if step.Expect.Uuid != "" && msg.Uuid != step.Expect.Uuid {
+				return fmt.Errorf("Step %d: Expected %s of %s, got %s", i, msg.Type, step.Expect.Uuid, msg.Uuid)
+			}
+		}
+	}
+	return nil
+}
+
+// Context implements grpc.Stream
+func (s *MockStream) Context() context.Context {
+	return context.Background()
+}
+
+// SendMsg implements grpc.Stream
+func (s *MockStream) SendMsg(m interface{}) error {
+	msg, ok := m.(*pb.ChaincodeMessage)
+	if !ok {
+		return fmt.Errorf("Unexpected message type %T", m)
+	}
+	return s.Send(msg)
+}
+
+// RecvMsg implements grpc.Stream
+func (s *MockStream) RecvMsg(m interface{}) error {
+	msg, ok := m.(*pb.ChaincodeMessage)
+	if !ok {
+		return fmt.Errorf("Unexpected message type %T", m)
+	}
+	received, err := s.Recv()
+	if err != nil {
+		return err
+	}
+	*msg = *received
+	return nil
+}
+
+// SendHeader implements grpc.ServerStream
+func (s *MockStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+// SetTrailer implements grpc.ServerStream
+func (s *MockStream) SetTrailer(metadata.MD) {
+}
+
+// RegisterMessage returns the REGISTER message of chaincode name, speaking
+// the current version of the protocol
+func RegisterMessage(name string) *pb.ChaincodeMessage {
+	payload, _ := proto.Marshal(&pb.ChaincodeID{Name: name})
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload, ProtocolVersion: pb.ChaincodeProtocolVersion}
+}
+
+// PutStateMessage returns the PUT_STATE message putting value at key
+func PutStateMessage(uuid string, key string, value []byte) *pb.ChaincodeMessage {
+	payload, _ := proto.Marshal(&pb.PutStateInfo{Key: key, Value: value})
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Payload: payload, Uuid: uuid}
+}
+
+// GetStateMessage returns the GET_STATE message getting key
+func GetStateMessage(uuid string, key string) *pb.ChaincodeMessage {
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE, Payload: []byte(key), Uuid: uuid}
+}
+
+// DelStateMessage returns the DEL_STATE message deleting key
+func DelStateMessage(uuid string, key string) *pb.ChaincodeMessage {
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_DEL_STATE, Payload: []byte(key), Uuid: uuid}
+}
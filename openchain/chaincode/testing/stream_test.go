@@ -0,0 +1,87 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package testing
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestMockStream(t *testing.T) {
+	s := NewMockStream()
+	go func() {
+		for {
+			msg, err := s.Recv()
+			if err != nil {
+				return
+			}
+			// echo the type back
+			s.Send(&pb.ChaincodeMessage{Type: msg.Type, Uuid: msg.Uuid})
+		}
+	}()
+
+	err := s.Play(
+		Step{Deliver: GetStateMessage("tx1", "key"), Expect: &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE, Uuid: "tx1"}},
+		Step{Deliver: DelStateMessage("tx2", "key")},
+		Step{Expect: &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_DEL_STATE}},
+	)
+	if err != nil {
+		t.Fatalf("Error playing script: %s", err)
+	}
+	if err := s.Play(Step{Deliver: DelStateMessage("tx3", "key"), Expect: &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE}}); err == nil {
+		t.Fatalf("Expected the script to fail on the wrong message type")
+	}
+
+	s.End()
+	if _, err := s.Recv(); err != io.EOF {
+		t.Fatalf("Expected io.EOF once ended, got %v", err)
+	}
+	if err := s.Deliver(&pb.ChaincodeMessage{}); err == nil {
+		t.Fatalf("Expected delivery to fail once ended")
+	}
+}
+
+func TestMockStreamHold(t *testing.T) {
+	s := NewMockStream()
+	s.Hold()
+	sent := make(chan error)
+	go func() {
+		sent <- s.Send(&pb.ChaincodeMessage{Uuid: "tx1"})
+	}()
+	s.ReleaseOne()
+	if err := <-sent; err != nil {
+		t.Fatalf("Error sending: %s", err)
+	}
+	s.Release()
+	if err := s.Send(&pb.ChaincodeMessage{Uuid: "tx2"}); err != nil {
+		t.Fatalf("Error sending: %s", err)
+	}
+	if len(s.Sent) != 2 {
+		t.Fatalf("Expected 2 messages sent, got %d", len(s.Sent))
+	}
+
+	s.FailSends(fmt.Errorf("stream broken"))
+	if err := s.Send(&pb.ChaincodeMessage{}); err == nil {
+		t.Fatalf("Expected the send to fail")
+	}
+}
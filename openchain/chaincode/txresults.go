@@ -0,0 +1,105 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// txResults keeps what the successful transactions produced until their
+// block commits, to be recorded in their results: the response of the
+// chaincode, the events it set and the hash of its state changes.
+type txResults struct {
+	sync.Mutex
+	finished map[string]*pb.TransactionResult
+}
+
+func newTxResults() *txResults {
+	return &txResults{finished: make(map[string]*pb.TransactionResult)}
+}
+
+// get returns the result of a transaction, created if needed. The lock must
+// be held.
+func (r *txResults) get(uuid string) *pb.TransactionResult {
+	result, ok := r.finished[uuid]
+	if !ok {
+		result = &pb.TransactionResult{Uuid: uuid}
+		r.finished[uuid] = result
+	}
+	return result
+}
+
+// finish records the state changes and the events of a successful
+// transaction
+func (r *txResults) finish(uuid string, delta *statemgmt.StateDelta, events []*pb.ChaincodeEvent) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.get(uuid)
+	if delta != nil && !delta.IsEmpty() {
+		result.WriteSetHash = delta.ComputeCryptoHash()
+	}
+	result.Events = events
+}
+
+// respond records the response of the chaincode to a successful transaction
+func (r *txResults) respond(uuid string, response []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.get(uuid).Result = response
+}
+
+// take removes and returns the results of the given transactions by UUID
+func (r *txResults) take(uuids []string) map[string]*pb.TransactionResult {
+	r.Lock()
+	defer r.Unlock()
+	results := make(map[string]*pb.TransactionResult)
+	for _, uuid := range uuids {
+		if result, ok := r.finished[uuid]; ok {
+			results[uuid] = result
+			delete(r.finished, uuid)
+		}
+	}
+	return results
+}
+
+// TakeTransactionResults returns the results of the given transactions by
+// UUID, to be recorded when their block commits or dropped when it is rolled
+// back. They carry the response of the chaincode, the events it set and the
+// hash of the state changes of the successful transactions, and the
+// resources used by all of them. They are no longer kept by the chain.
+func TakeTransactionResults(cname ChainName, uuids []string) map[string]*pb.TransactionResult {
+	chain := GetChain(cname)
+	if chain == nil {
+		return nil
+	}
+	results := chain.txResults.take(uuids)
+	for uuid, usage := range chain.meters.take(uuids) {
+		result, ok := results[uuid]
+		if !ok {
+			result = &pb.TransactionResult{Uuid: uuid}
+			results[uuid] = result
+		}
+		result.Usage = usage
+	}
+	return results
+}
@@ -0,0 +1,60 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestTxResults(t *testing.T) {
+	r := newTxResults()
+
+	delta := statemgmt.NewStateDelta()
+	delta.Set("mycc", "a", []byte("1"), nil)
+	events := []*pb.ChaincodeEvent{&pb.ChaincodeEvent{ChaincodeID: "mycc", TxUuid: "tx1", EventName: "moved"}}
+	r.finish("tx1", delta, events)
+	r.respond("tx1", []byte("ok"))
+
+	// Transactions without state changes have no write set hash
+	r.finish("tx2", statemgmt.NewStateDelta(), nil)
+
+	results := r.take([]string{"tx1", "tx2", "tx3"})
+	if len(results) != 2 {
+		t.Fatalf("Expected the results of 2 transactions, got %d", len(results))
+	}
+	res := results["tx1"]
+	if res.Uuid != "tx1" || string(res.Result) != "ok" || len(res.Events) != 1 {
+		t.Fatalf("Unexpected result of tx1: %v", res)
+	}
+	if !bytes.Equal(res.WriteSetHash, delta.ComputeCryptoHash()) {
+		t.Fatalf("Expected the write set hash to be the hash of the state changes")
+	}
+	if results["tx2"].WriteSetHash != nil {
+		t.Fatalf("Expected no write set hash for tx2, got %x", results["tx2"].WriteSetHash)
+	}
+
+	if results = r.take([]string{"tx1"}); len(results) != 0 {
+		t.Fatalf("Expected results to be taken once, got %v", results)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// txRandomSeed derives the random seed of the transaction with the uuid from
+// the hash of the block the transaction is executed after and from the
+// transaction itself. Consensus agrees on the block, so every validator
+// executing the transaction hands the same seed to the chaincode, and the
+// submitter cannot choose the seed through the uuid without knowing the
+// block its transaction will follow. Chaincodes called in the same
+// transaction get different seeds. Anyone knowing the transaction and the
+// chain can compute the seed, it must not be used where the values have to
+// stay secret.
+func txRandomSeed(previousBlockHash []byte, chainID string, chaincodeName string, uuid string) []byte {
+	return util.ComputeCryptoHash([]byte(fmt.Sprintf("%x/%s/%s/%s", previousBlockHash, chainID, chaincodeName, uuid)))
+}
+
+// previousBlockHashKey is the key of the hash of the block transactions are
+// executed after in a context
+type previousBlockHashKey struct{}
+
+// withPreviousBlockHash returns a context executing transactions after the
+// block with the hash, rather than after the last block of the chain, e.g.
+// to execute the transactions of a committed block again
+func withPreviousBlockHash(ctxt context.Context, hash []byte) context.Context {
+	return context.WithValue(ctxt, previousBlockHashKey{}, hash)
+}
+
+// previousBlockHash returns the hash of the block the transaction executed
+// in ctxt follows, the last block of the chain unless ctxt tells otherwise
+func previousBlockHash(ctxt context.Context, chainID string) ([]byte, error) {
+	if ctxt != nil {
+		if hash, ok := ctxt.Value(previousBlockHashKey{}).([]byte); ok {
+			return hash, nil
+		}
+	}
+	ledger, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	info, err := ledger.GetBlockchainInfo()
+	if err != nil {
+		return nil, err
+	}
+	return info.CurrentBlockHash, nil
+}
+
+// handleGetTxValue responds to a GET_TX_RANDOM or GET_TX_TIME request with
+// the random seed or the timestamp of the transaction being executed. Both
+// are the same on every validator and do not depend on when or where the
+// chaincode runs. The timestamp is the one the submitter set in the
+// transaction; with pbft consensus, replicas refuse to order transactions
+// whose timestamp is further than general.timestampskew off their clock.
+func (handler *Handler) handleGetTxValue(msg *pb.ChaincodeMessage) {
+	var payload []byte
+	var err error
+	txctx := handler.getTxContext(msg.Uuid)
+	if txctx == nil || txctx.transactionSecContext == nil {
+		err = fmt.Errorf("No context for transaction %s", msg.Uuid)
+	} else if msg.Type == pb.ChaincodeMessage_GET_TX_RANDOM {
+		var hash []byte
+		if hash, err = previousBlockHash(txctx.ctxt, handler.chainID()); err == nil {
+			payload = txRandomSeed(hash, handler.chainID(), handler.ChaincodeID.Name, msg.Uuid)
+		}
+	} else if txctx.transactionSecContext.Timestamp == nil {
+		err = fmt.Errorf("Transaction %s has no timestamp", msg.Uuid)
+	} else {
+		payload, err = proto.Marshal(txctx.transactionSecContext.Timestamp)
+	}
+	if err != nil {
+		chaincodeLogger.Debug("[%s]Failed to handle %s: %s. Sending %s", shortuuid(msg.Uuid), msg.Type, err, pb.ChaincodeMessage_ERROR)
+		handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid})
+		return
+	}
+	chaincodeLogger.Debug("[%s]Handled %s. Sending %s", shortuuid(msg.Uuid), msg.Type, pb.ChaincodeMessage_RESPONSE)
+	handler.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payload, Uuid: msg.Uuid})
+}
@@ -0,0 +1,106 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	cctesting "github.com/openblockchain/obc-peer/openchain/chaincode/testing"
+	pb "github.com/openblockchain/obc-peer/protos"
+	google_protobuf "google/protobuf"
+)
+
+func TestGetTxValues(t *testing.T) {
+	stream := cctesting.NewMockStream()
+	handler := newChaincodeSupportHandler(newDevModeSupport(), stream)
+	defer handler.sendQueue.close()
+	handler.ChaincodeID = &pb.ChaincodeID{Name: "mycc"}
+	handler.protocolVersion = pb.ChaincodeProtocolVersion
+	handler.txCtxs = make(map[string]*transactionContext)
+
+	random := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_TX_RANDOM, Uuid: "tx1"}
+	if err := handler.HandleMessage(random); err != nil {
+		t.Fatalf("Error handling %s: %s", random.Type, err)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_ERROR {
+		t.Fatalf("Expected ERROR without a transaction context, got %s", msg.Type)
+	}
+
+	tx := &pb.Transaction{Uuid: "tx1", Timestamp: &google_protobuf.Timestamp{Seconds: 1500000000, Nanos: 42}}
+	ctxt := withPreviousBlockHash(context.Background(), []byte("block 6"))
+	if _, err := handler.createTxContext(ctxt, "tx1", tx, false); err != nil {
+		t.Fatalf("Error creating transaction context: %s", err)
+	}
+	defer handler.deleteTxContext("tx1")
+
+	// The seed only depends on the transaction and the block it follows
+	var seeds [][]byte
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleMessage(random); err != nil {
+			t.Fatalf("Error handling %s: %s", random.Type, err)
+		}
+		msg := <-stream.Sent
+		if msg.Type != pb.ChaincodeMessage_RESPONSE || msg.Uuid != "tx1" || len(msg.Payload) == 0 {
+			t.Fatalf("Expected RESPONSE of tx1 with a seed, got %s of %s", msg.Type, msg.Uuid)
+		}
+		seeds = append(seeds, msg.Payload)
+	}
+	if !bytes.Equal(seeds[0], seeds[1]) {
+		t.Fatalf("Expected the same seed for the same transaction")
+	}
+	if !bytes.Equal(seeds[0], txRandomSeed([]byte("block 6"), "", "mycc", "tx1")) {
+		t.Fatalf("Expected the seed of tx1 after block 6")
+	}
+	if bytes.Equal(seeds[0], txRandomSeed([]byte("block 6"), "", "mycc", "tx2")) || bytes.Equal(seeds[0], txRandomSeed([]byte("block 6"), "", "othercc", "tx1")) {
+		t.Fatalf("Expected different seeds for other transactions and chaincodes")
+	}
+	if bytes.Equal(seeds[0], txRandomSeed([]byte("block 7"), "", "mycc", "tx1")) {
+		t.Fatalf("Expected a different seed after another block")
+	}
+
+	timeReq := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_TX_TIME, Uuid: "tx1"}
+	if err := handler.HandleMessage(timeReq); err != nil {
+		t.Fatalf("Error handling %s: %s", timeReq.Type, err)
+	}
+	msg := <-stream.Sent
+	if msg.Type != pb.ChaincodeMessage_RESPONSE {
+		t.Fatalf("Expected RESPONSE, got %s", msg.Type)
+	}
+	ts := &google_protobuf.Timestamp{}
+	if err := proto.Unmarshal(msg.Payload, ts); err != nil {
+		t.Fatalf("Error unmarshalling timestamp: %s", err)
+	}
+	if ts.Seconds != 1500000000 || ts.Nanos != 42 {
+		t.Fatalf("Expected the timestamp of the transaction, got %v", ts)
+	}
+
+	// Chaincodes speaking the protocol from before the messages are refused
+	handler.protocolVersion = 0
+	if err := handler.HandleMessage(timeReq); err != nil {
+		t.Fatalf("Error handling %s: %s", timeReq.Type, err)
+	}
+	if msg := <-stream.Sent; msg.Type != pb.ChaincodeMessage_ERROR {
+		t.Fatalf("Expected ERROR for a chaincode speaking version 0, got %s", msg.Type)
+	}
+}
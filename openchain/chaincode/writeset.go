@@ -0,0 +1,173 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+)
+
+// txWriteSets buffers the state changes of the transactions in progress, so
+// that transactions do not need exclusive use of the ledger while they run.
+// The changes of a transaction are applied to the ledger when it finishes.
+type txWriteSets struct {
+	sync.Mutex
+	deltas map[string]*statemgmt.StateDelta
+	// transactions simulated, whose changes are never applied
+	simulated map[string]bool
+}
+
+func newTxWriteSets() *txWriteSets {
+	return &txWriteSets{deltas: make(map[string]*statemgmt.StateDelta), simulated: make(map[string]bool)}
+}
+
+func (w *txWriteSets) begin(uuid string) {
+	w.Lock()
+	defer w.Unlock()
+	w.deltas[uuid] = statemgmt.NewStateDelta()
+}
+
+func (w *txWriteSets) beginSimulation(uuid string) {
+	w.Lock()
+	defer w.Unlock()
+	w.deltas[uuid] = statemgmt.NewStateDelta()
+	w.simulated[uuid] = true
+}
+
+func (w *txWriteSets) isSimulation(uuid string) bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.simulated[uuid]
+}
+
+func (w *txWriteSets) get(uuid string) *statemgmt.StateDelta {
+	w.Lock()
+	defer w.Unlock()
+	return w.deltas[uuid]
+}
+
+// finish removes and returns the state changes of the transaction
+func (w *txWriteSets) finish(uuid string) *statemgmt.StateDelta {
+	w.Lock()
+	defer w.Unlock()
+	delta := w.deltas[uuid]
+	delete(w.deltas, uuid)
+	delete(w.simulated, uuid)
+	return delta
+}
+
+// stateLock keeps the transactions in progress from reading the ledger while
+// the state changes of a finished transaction are applied to it
+var stateLock sync.RWMutex
+
+// getTxState returns the value of a key as seen by a transaction, taking its
+// own changes into account before the ledger
+func (chaincodeSupport *ChaincodeSupport) getTxState(ledgerObj *ledger.Ledger, uuid string, chaincodeID string, key string) ([]byte, error) {
+	if err := chaincodeSupport.keyHints.check(uuid, chaincodeID, key); err != nil {
+		return nil, err
+	}
+	if err := chaincodeSupport.keyLocks.lock(uuid, lockName(chaincodeID, key)); err != nil {
+		return nil, err
+	}
+	if delta := chaincodeSupport.writeSets.get(uuid); delta != nil {
+		if updatedValue := delta.Get(chaincodeID, key); updatedValue != nil {
+			return updatedValue.GetValue(), nil
+		}
+	}
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return ledgerObj.GetState(chaincodeID, key, false)
+}
+
+// getTxStateRangeScanIterator returns an iterator over the keys of a range as
+// seen by a transaction, taking its own changes into account before the ledger.
+// The iterator is pinned to the state and the changes at the time it is created.
+func (chaincodeSupport *ChaincodeSupport) getTxStateRangeScanIterator(ledgerObj *ledger.Ledger, uuid string, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	if err := chaincodeSupport.keyHints.checkRange(uuid, chaincodeID); err != nil {
+		return nil, err
+	}
+	delta := chaincodeSupport.writeSets.get(uuid)
+	if delta == nil {
+		delta = statemgmt.NewStateDelta()
+	}
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return ledgerObj.GetTxStateRangeScanSnapshotIterator(delta, chaincodeID, startKey, endKey)
+}
+
+// setTxState buffers a change of a key made by a transaction
+func (chaincodeSupport *ChaincodeSupport) setTxState(uuid string, chaincodeID string, key string, value []byte) error {
+	delta, err := chaincodeSupport.lockForWrite(uuid, chaincodeID, key)
+	if err != nil {
+		return err
+	}
+	delta.Set(chaincodeID, key, value, nil)
+	return nil
+}
+
+// deleteTxState buffers the deletion of a key made by a transaction
+func (chaincodeSupport *ChaincodeSupport) deleteTxState(uuid string, chaincodeID string, key string) error {
+	delta, err := chaincodeSupport.lockForWrite(uuid, chaincodeID, key)
+	if err != nil {
+		return err
+	}
+	delta.Delete(chaincodeID, key, nil)
+	return nil
+}
+
+func (chaincodeSupport *ChaincodeSupport) lockForWrite(uuid string, chaincodeID string, key string) (*statemgmt.StateDelta, error) {
+	delta := chaincodeSupport.writeSets.get(uuid)
+	if delta == nil {
+		return nil, fmt.Errorf("[%s]No transaction in progress", shortuuid(uuid))
+	}
+	if err := chaincodeSupport.keyHints.check(uuid, chaincodeID, key); err != nil {
+		return nil, err
+	}
+	if err := chaincodeSupport.keyLocks.lock(uuid, lockName(chaincodeID, key)); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// commitTxState applies the state changes of a successful transaction to the ledger
+func commitTxState(ledgerObj *ledger.Ledger, uuid string, delta *statemgmt.StateDelta) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	ledgerObj.TxBegin(uuid)
+	for _, chaincodeID := range delta.GetUpdatedChaincodeIds(true) {
+		for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+			var err error
+			if updatedValue.IsDelete() {
+				err = ledgerObj.DeleteState(chaincodeID, key)
+			} else {
+				err = ledgerObj.SetState(chaincodeID, key, updatedValue.GetValue())
+			}
+			if err != nil {
+				ledgerObj.TxFinished(uuid, false)
+				return err
+			}
+		}
+	}
+	ledgerObj.TxFinished(uuid, true)
+	return nil
+}
@@ -0,0 +1,233 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package client provides a Go SDK for applications submitting transactions
+// to a peer. Besides wrapping the Devops service it subscribes to the event
+// hub of a validator so that applications can wait for a transaction to be
+// committed (or rejected) instead of polling the REST interface.
+package client
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/openblockchain/obc-peer/events/consumer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var clientLogger = logging.MustGetLogger("client")
+
+const (
+	defaultTimeout     = time.Second * 3
+	defaultMaxOutcomes = 1000
+)
+
+// EventListener is invoked for every event received from the event hub
+type EventListener func(*pb.OpenchainEvent)
+
+// Config holds the settings used to create a Client
+type Config struct {
+	// PeerAddress is the address of the peer hosting the Devops service
+	PeerAddress string
+	// EventsAddress is the address of the event hub of a validator
+	EventsAddress string
+	// EventsToken, if set, is the bearer token authenticating the client to
+	// the event hub
+	EventsToken string
+	// MaxTrackedOutcomes bounds the number of transaction outcomes retained
+	// for callers that start waiting after the event was received
+	MaxTrackedOutcomes int
+	// Listener, if set, receives every block and rejection event
+	Listener EventListener
+}
+
+// Client submits transactions to a peer and tracks their lifecycle
+type Client struct {
+	conn    *grpc.ClientConn
+	devops  pb.DevopsClient
+	events  *consumer.OpenchainEventsClient
+	tracker *txTracker
+}
+
+// NewClient connects to the peer and the event hub described by config
+func NewClient(config *Config) (*Client, error) {
+	if config.PeerAddress == "" {
+		return nil, fmt.Errorf("Peer address not supplied")
+	}
+	if config.EventsAddress == "" {
+		return nil, fmt.Errorf("Event hub address not supplied")
+	}
+	maxOutcomes := config.MaxTrackedOutcomes
+	if maxOutcomes <= 0 {
+		maxOutcomes = defaultMaxOutcomes
+	}
+
+	conn, err := newPeerClientConnection(config.PeerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to peer %s: %s", config.PeerAddress, err)
+	}
+
+	c := &Client{conn: conn, devops: pb.NewDevopsClient(conn), tracker: newTxTracker(maxOutcomes, config.Listener)}
+	c.events = consumer.NewOpenchainEventsClient(config.EventsAddress, c.tracker)
+	c.events.SetToken(config.EventsToken)
+	if err = c.events.Start(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Error connecting to event hub %s: %s", config.EventsAddress, err)
+	}
+	return c, nil
+}
+
+// Close disconnects from the peer and the event hub
+func (c *Client) Close() error {
+	err := c.events.Stop()
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Register establishes the security context for the enrollment ID with the peer
+func (c *Client) Register(ctx context.Context, enrollID, enrollSecret string) error {
+	resp, err := c.devops.Login(ctx, &pb.Secret{EnrollId: enrollID, EnrollSecret: enrollSecret})
+	if err != nil {
+		return err
+	}
+	if resp.Status != pb.Response_SUCCESS {
+		return fmt.Errorf("Error registering %s: %s", enrollID, string(resp.Msg))
+	}
+	return nil
+}
+
+// Deploy submits a deploy transaction and returns its UUID, which is also
+// the name of the deployed chaincode
+func (c *Client) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (string, error) {
+	cds, err := c.devops.Deploy(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	uuid := cds.ChaincodeSpec.ChaincodeID.Name
+	clientLogger.Debug("Submitted deploy transaction %s", uuid)
+	return uuid, nil
+}
+
+// WaitForDeployment blocks until the deployment of the named chaincode is
+// ready, has failed, or the context is done. The final status is returned;
+// a failed deployment also yields an error carrying the failure details.
+// Only validators report the build and launch phases of a deployment, so
+// the client must be connected to a validator.
+func (c *Client) WaitForDeployment(ctx context.Context, name string) (*pb.DeploymentStatus, error) {
+	stream, err := c.devops.WatchDeployment(ctx, &pb.ChaincodeID{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	var status *pb.DeploymentStatus
+	for {
+		next, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return status, err
+		}
+		status = next
+	}
+	if status == nil {
+		return nil, fmt.Errorf("No status received for deployment of %s", name)
+	}
+	if status.Phase == pb.DeploymentStatus_FAILED {
+		return status, fmt.Errorf("Deployment of %s failed: %s", name, status.Msg)
+	}
+	return status, nil
+}
+
+// Invoke submits an invoke transaction and returns its UUID
+func (c *Client) Invoke(ctx context.Context, spec *pb.ChaincodeInvocationSpec) (string, error) {
+	resp, err := c.devops.Invoke(ctx, spec)
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != pb.Response_SUCCESS {
+		return "", fmt.Errorf("Error invoking chaincode: %s", string(resp.Msg))
+	}
+	uuid := string(resp.Msg)
+	clientLogger.Debug("Submitted invoke transaction %s", uuid)
+	return uuid, nil
+}
+
+// Query executes a query and returns its result. Queries are not recorded
+// on the ledger, so there is nothing to wait for
+func (c *Client) Query(ctx context.Context, spec *pb.ChaincodeInvocationSpec) ([]byte, error) {
+	resp, err := c.devops.Query(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != pb.Response_SUCCESS {
+		return nil, fmt.Errorf("Error querying chaincode: %s", string(resp.Msg))
+	}
+	return resp.Msg, nil
+}
+
+// WaitForCommit blocks until the transaction with the supplied UUID is
+// committed, rejected, or the context is done. On commit the block carrying
+// the transaction is returned. A rejected transaction yields a *TxRejectedError.
+func (c *Client) WaitForCommit(ctx context.Context, uuid string) (*pb.Block, error) {
+	w := c.tracker.watch(uuid)
+	select {
+	case <-w.done:
+		return w.outcome.block, w.outcome.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newPeerClientConnection returns a new grpc.ClientConn to the peer. The
+// openchain/peer package is deliberately not used so that applications do not
+// have to link against the ledger.
+func newPeerClientConnection(peerAddress string) (*grpc.ClientConn, error) {
+	var opts []grpc.DialOption
+	if viper.GetBool("peer.tls.enabled") {
+		var sn string
+		if viper.GetString("peer.tls.server-host-override") != "" {
+			sn = viper.GetString("peer.tls.server-host-override")
+		}
+		var creds credentials.TransportAuthenticator
+		if viper.GetString("peer.tls.cert.file") != "" {
+			var err error
+			creds, err = credentials.NewClientTLSFromFile(viper.GetString("peer.tls.cert.file"), sn)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to create TLS credentials %v", err)
+			}
+		} else {
+			creds = credentials.NewClientTLSFromCert(nil, sn)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	opts = append(opts, grpc.WithTimeout(defaultTimeout))
+	opts = append(opts, grpc.WithBlock())
+	return grpc.Dial(peerAddress, opts...)
+}
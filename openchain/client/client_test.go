@@ -0,0 +1,123 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/events/producer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func newTestClient(maxOutcomes int) *Client {
+	return &Client{tracker: newTxTracker(maxOutcomes, nil)}
+}
+
+func blockEvent(uuids ...string) *pb.OpenchainEvent {
+	var txs []*pb.Transaction
+	for _, uuid := range uuids {
+		txs = append(txs, &pb.Transaction{Uuid: uuid})
+	}
+	return producer.CreateBlockEvent(&pb.Block{Transactions: txs})
+}
+
+func TestWaitForCommit_BlockAfterWait(t *testing.T) {
+	c := newTestClient(10)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.tracker.Recv(blockEvent("other", "tx1"))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	block, err := c.WaitForCommit(ctx, "tx1")
+	if err != nil {
+		t.Fatalf("Expected commit, got error: %s", err)
+	}
+	if len(block.Transactions) != 2 {
+		t.Fatalf("Expected block with 2 transactions, got %d", len(block.Transactions))
+	}
+}
+
+func TestWaitForCommit_BlockBeforeWait(t *testing.T) {
+	c := newTestClient(10)
+	c.tracker.Recv(blockEvent("tx1"))
+	block, err := c.WaitForCommit(context.Background(), "tx1")
+	if err != nil || block == nil {
+		t.Fatalf("Expected previously received commit, got block=%v, err=%v", block, err)
+	}
+}
+
+func TestWaitForCommit_Rejected(t *testing.T) {
+	c := newTestClient(10)
+	c.tracker.Recv(producer.CreateRejectionEvent(&pb.Transaction{Uuid: "tx1"}, "chaincode error"))
+	// The rejected transaction still shows up in the next block
+	c.tracker.Recv(blockEvent("tx1"))
+	_, err := c.WaitForCommit(context.Background(), "tx1")
+	rejected, ok := err.(*TxRejectedError)
+	if !ok {
+		t.Fatalf("Expected TxRejectedError, got %v", err)
+	}
+	if rejected.Reason != "chaincode error" {
+		t.Fatalf("Unexpected rejection reason: %s", rejected.Reason)
+	}
+}
+
+func TestWaitForCommit_ContextDone(t *testing.T) {
+	c := newTestClient(10)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.WaitForCommit(ctx, "tx1"); err != context.DeadlineExceeded {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestWaitForCommit_Disconnected(t *testing.T) {
+	c := newTestClient(10)
+	done := make(chan error)
+	go func() {
+		_, err := c.WaitForCommit(context.Background(), "tx1")
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.tracker.Disconnected(fmt.Errorf("stream closed"))
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("Expected error on disconnect")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Waiter not released on disconnect")
+	}
+}
+
+func TestTracker_BoundedOutcomes(t *testing.T) {
+	c := newTestClient(2)
+	c.tracker.Recv(blockEvent("tx1", "tx2", "tx3"))
+	if _, ok := c.tracker.outcomes["tx1"]; ok {
+		t.Fatalf("Expected oldest outcome to be evicted")
+	}
+	if len(c.tracker.outcomes) != 2 {
+		t.Fatalf("Expected 2 retained outcomes, got %d", len(c.tracker.outcomes))
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/events/producer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// TxRejectedError is returned when a transaction failed to execute on the
+// validators and was therefore rejected
+type TxRejectedError struct {
+	UUID   string
+	Reason string
+}
+
+func (e *TxRejectedError) Error() string {
+	return fmt.Sprintf("Transaction %s rejected: %s", e.UUID, e.Reason)
+}
+
+// txOutcome is the final result of a transaction as reported by the event hub
+type txOutcome struct {
+	block *pb.Block
+	err   error
+}
+
+// txWaiter is notified once when the outcome of a transaction is known
+type txWaiter struct {
+	done    chan struct{}
+	outcome *txOutcome
+}
+
+// txTracker correlates transaction UUIDs with the block and rejection events
+// received from the event hub. It implements consumer.EventAdapter.
+//
+// Outcomes are retained for the last maxOutcomes transactions so that a
+// caller that starts waiting after the event arrived (eg, because the
+// UUID was only known once Invoke returned) still sees the result
+type txTracker struct {
+	sync.Mutex
+	waiters     map[string]*txWaiter
+	outcomes    map[string]*txOutcome
+	order       []string
+	maxOutcomes int
+	listener    EventListener
+	disconnect  error
+}
+
+func newTxTracker(maxOutcomes int, listener EventListener) *txTracker {
+	return &txTracker{waiters: make(map[string]*txWaiter), outcomes: make(map[string]*txOutcome), maxOutcomes: maxOutcomes, listener: listener}
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (t *txTracker) GetInterestedEvents() ([]*pb.Interest, error) {
+	return []*pb.Interest{
+		&pb.Interest{EventType: producer.BlockType, ResponseType: pb.Interest_PROTOBUF},
+		&pb.Interest{EventType: producer.RejectionType, ResponseType: pb.Interest_PROTOBUF},
+	}, nil
+}
+
+// Recv implements consumer.EventAdapter
+func (t *txTracker) Recv(msg *pb.OpenchainEvent) (bool, error) {
+	switch x := msg.Event.(type) {
+	case *pb.OpenchainEvent_Block:
+		for _, tx := range x.Block.GetTransactions() {
+			t.resolve(tx.Uuid, &txOutcome{block: x.Block})
+		}
+	case *pb.OpenchainEvent_Rejection:
+		if tx := x.Rejection.GetTx(); tx != nil {
+			t.resolve(tx.Uuid, &txOutcome{err: &TxRejectedError{UUID: tx.Uuid, Reason: x.Rejection.ErrorMsg}})
+		}
+	case nil:
+		return false, fmt.Errorf("event not set")
+	}
+	if t.listener != nil {
+		t.listener(msg)
+	}
+	return true, nil
+}
+
+// Disconnected implements consumer.EventAdapter. All pending waiters are
+// released as no further events will arrive
+func (t *txTracker) Disconnected(err error) {
+	if err == nil {
+		err = fmt.Errorf("Disconnected from event hub")
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.disconnect = err
+	for uuid, w := range t.waiters {
+		w.outcome = &txOutcome{err: err}
+		close(w.done)
+		delete(t.waiters, uuid)
+	}
+}
+
+// resolve records the outcome of the transaction and notifies its waiter.
+// Only the first outcome for a UUID is kept: a rejected transaction is
+// still recorded in the next block
+func (t *txTracker) resolve(uuid string, outcome *txOutcome) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.outcomes[uuid]; ok {
+		return
+	}
+	if w, ok := t.waiters[uuid]; ok {
+		w.outcome = outcome
+		close(w.done)
+		delete(t.waiters, uuid)
+	}
+	t.outcomes[uuid] = outcome
+	t.order = append(t.order, uuid)
+	for len(t.order) > t.maxOutcomes {
+		delete(t.outcomes, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// watch returns the waiter for the supplied UUID, creating it if needed. If
+// the outcome is already known the returned waiter is already done
+func (t *txTracker) watch(uuid string) *txWaiter {
+	t.Lock()
+	defer t.Unlock()
+	if outcome, ok := t.outcomes[uuid]; ok {
+		w := &txWaiter{done: make(chan struct{}), outcome: outcome}
+		close(w.done)
+		return w
+	}
+	if t.disconnect != nil {
+		w := &txWaiter{done: make(chan struct{}), outcome: &txOutcome{err: t.disconnect}}
+		close(w.done)
+		return w
+	}
+	w, ok := t.waiters[uuid]
+	if !ok {
+		w = &txWaiter{done: make(chan struct{})}
+		t.waiters[uuid] = w
+	}
+	return w
+}
@@ -0,0 +1,218 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// reloadable is a setting, or a section of settings, which can change while
+// the peer runs
+type reloadable struct {
+	validate func(key string, value interface{}) error
+	apply    func(key string, value interface{})
+}
+
+// Watcher reloads a config file when it changes or the peer receives a
+// SIGHUP. Only the settings registered as reloadable may change; a reload
+// changing any other setting is rejected as a whole, as is a reload with an
+// invalid value.
+type Watcher struct {
+	sync.Mutex
+	file        string
+	set         func(key string, value interface{})
+	settings    map[string]interface{}
+	modTime     time.Time
+	reloadables map[string]*reloadable
+}
+
+// NewWatcher creates a watcher of the config file, eg. viper.ConfigFileUsed().
+// Reloaded settings are passed to set, eg. viper.Set, so that the new values
+// are read from then on.
+func NewWatcher(file string, set func(key string, value interface{})) (*Watcher, error) {
+	w := &Watcher{file: file, set: set, reloadables: make(map[string]*reloadable)}
+	settings, modTime, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	w.settings = settings
+	w.modTime = modTime
+	return w, nil
+}
+
+// Register makes a setting reloadable. The key is either the name of a
+// setting, eg. general.timeout.batch, or of a section, eg. logging, which
+// makes all its settings reloadable. Reloaded values must pass validate
+// before apply puts them in force.
+func (w *Watcher) Register(key string, validate func(key string, value interface{}) error, apply func(key string, value interface{})) {
+	w.Lock()
+	defer w.Unlock()
+	w.reloadables[strings.ToLower(key)] = &reloadable{validate: validate, apply: apply}
+}
+
+func (w *Watcher) reloadableFor(key string) *reloadable {
+	for {
+		if r, ok := w.reloadables[key]; ok {
+			return r
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return nil
+		}
+		key = key[:i]
+	}
+}
+
+// Reload re-reads the config file and applies the changed settings. It
+// returns the changed settings, or an error and applies none of them.
+func (w *Watcher) Reload() ([]string, error) {
+	w.Lock()
+	defer w.Unlock()
+	settings, modTime, err := w.read()
+	if err != nil {
+		return nil, err
+	}
+	w.modTime = modTime
+
+	var changed, rejected []string
+	keys := make(map[string]bool)
+	for key := range settings {
+		keys[key] = true
+	}
+	for key := range w.settings {
+		keys[key] = true
+	}
+	for key := range keys {
+		if reflect.DeepEqual(settings[key], w.settings[key]) {
+			continue
+		}
+		changed = append(changed, key)
+		if w.reloadableFor(key) == nil {
+			rejected = append(rejected, key)
+		}
+	}
+	sort.Strings(changed)
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return nil, fmt.Errorf("Settings %s of %s cannot change without restarting the peer", strings.Join(rejected, ", "), w.file)
+	}
+	for _, key := range changed {
+		if err := w.reloadableFor(key).validate(key, settings[key]); err != nil {
+			return nil, fmt.Errorf("Invalid value %v of %s: %s", settings[key], key, err)
+		}
+	}
+
+	for _, key := range changed {
+		w.set(key, settings[key])
+		w.reloadableFor(key).apply(key, settings[key])
+	}
+	w.settings = settings
+	return changed, nil
+}
+
+// Watch reloads the config file on SIGHUP and, if interval is positive, when
+// its modification time changes. It never returns.
+func (w *Watcher) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.Tick(interval)
+	}
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+			info, err := os.Stat(w.file)
+			w.Lock()
+			modified := err == nil && !info.ModTime().Equal(w.modTime)
+			w.Unlock()
+			if !modified {
+				continue
+			}
+		}
+		changed, err := w.Reload()
+		if err != nil {
+			configLogger.Error("Config not reloaded: %s", err)
+		} else if len(changed) > 0 {
+			configLogger.Info("Reloaded %s of %s", strings.Join(changed, ", "), w.file)
+		}
+	}
+}
+
+// read returns the settings of the config file by their full key, eg.
+// peer.validator.events.timeout
+func (w *Watcher) read() (map[string]interface{}, time.Time, error) {
+	info, err := os.Stat(w.file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	v := viper.New()
+	v.SetConfigFile(w.file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, time.Time{}, fmt.Errorf("Error reading %s: %s", w.file, err)
+	}
+	settings := make(map[string]interface{})
+	flattenSettings("", v.AllSettings(), settings)
+	return settings, info.ModTime(), nil
+}
+
+func flattenSettings(prefix string, section map[string]interface{}, settings map[string]interface{}) {
+	for key, value := range section {
+		key = prefix + strings.ToLower(key)
+		switch value := value.(type) {
+		case map[string]interface{}:
+			flattenSettings(key+".", value, settings)
+		case map[interface{}]interface{}:
+			flattenSettings(key+".", cast.ToStringMap(value), settings)
+		default:
+			settings[key] = value
+		}
+	}
+}
+
+// ValidateDuration validates a reloaded positive duration, eg. 2s
+func ValidateDuration(key string, value interface{}) error {
+	d, err := time.ParseDuration(cast.ToString(value))
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("Duration must be positive")
+	}
+	return nil
+}
+
+// ValidateInt validates a reloaded integer
+func ValidateInt(key string, value interface{}) error {
+	_, err := cast.ToIntE(value)
+	return err
+}
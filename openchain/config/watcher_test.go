@@ -0,0 +1,103 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cast"
+)
+
+const watcherTestConfig = `
+logging:
+    peer: %s
+general:
+    timeout:
+        batch: %s
+    batchsize: %d
+`
+
+func writeWatcherTestConfig(t *testing.T, file, level, timeout string, batchSize int) {
+	if err := ioutil.WriteFile(file, []byte(fmt.Sprintf(watcherTestConfig, level, timeout, batchSize)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.yaml")
+	writeWatcherTestConfig(t, file, "info", "2s", 2)
+
+	set := make(map[string]interface{})
+	watcher, err := NewWatcher(file, func(key string, value interface{}) { set[key] = value })
+	if err != nil {
+		t.Fatalf("Error creating watcher: %s", err)
+	}
+	var timeout, level string
+	watcher.Register("general.timeout.batch", ValidateDuration, func(key string, value interface{}) {
+		timeout = cast.ToString(value)
+	})
+	watcher.Register("logging", func(key string, value interface{}) error { return nil }, func(key string, value interface{}) {
+		level = cast.ToString(value)
+	})
+
+	changed, err := watcher.Reload()
+	if err != nil || len(changed) != 0 {
+		t.Fatalf("Expected no changes, got %v, %v", changed, err)
+	}
+
+	writeWatcherTestConfig(t, file, "debug", "5s", 2)
+	changed, err = watcher.Reload()
+	if err != nil {
+		t.Fatalf("Error reloading: %s", err)
+	}
+	if len(changed) != 2 || changed[0] != "general.timeout.batch" || changed[1] != "logging.peer" {
+		t.Fatalf("Unexpected changes %v", changed)
+	}
+	if timeout != "5s" || level != "debug" || set["general.timeout.batch"] != "5s" || set["logging.peer"] != "debug" {
+		t.Fatalf("Changes not applied: %s, %s, %v", timeout, level, set)
+	}
+
+	// A reload changing a setting which is not reloadable is rejected as a whole
+	writeWatcherTestConfig(t, file, "warning", "5s", 10)
+	if _, err = watcher.Reload(); err == nil {
+		t.Fatal("Expected the change of general.batchsize to be rejected")
+	}
+	if level != "debug" {
+		t.Fatalf("Expected no change applied, got level %s", level)
+	}
+
+	// So is a reload with an invalid value
+	writeWatcherTestConfig(t, file, "warning", "never", 2)
+	if _, err = watcher.Reload(); err == nil {
+		t.Fatal("Expected the invalid batch timeout to be rejected")
+	}
+	if level != "debug" || timeout != "5s" {
+		t.Fatalf("Expected no change applied, got %s, %s", level, timeout)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package consensus
+
+import "time"
+
+// Timer is a one-shot timer created by a Clock. Reset and Stop follow the
+// semantics of the corresponding methods on time.Timer.
+type Timer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// Clock tells the time and schedules callbacks after a duration has
+// elapsed. A Stack may implement Clock so that the timeouts of a plugin
+// follow simulated rather than wall-clock time.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// GetClock returns the Clock implemented by stack, or the system clock if
+// stack does not implement one
+func GetClock(stack interface{}) Clock {
+	if clock, ok := stack.(Clock); ok {
+		return clock
+	}
+	return systemClock{}
+}
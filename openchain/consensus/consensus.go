@@ -64,6 +64,7 @@ type UtilLedger interface {
 // WritableLedger is useful for updating the blockchain during state transfer
 type WritableLedger interface {
 	PutBlock(blockNumber uint64, block *pb.Block) error
+	AddBlockSignature(blockNumber uint64, signature *pb.BlockSignature) error
 	ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error
 	CommitStateDelta(id interface{}) error
 	RollbackStateDelta(id interface{}) error
@@ -20,6 +20,8 @@ under the License.
 package controller
 
 import (
+	"fmt"
+
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 
@@ -34,6 +36,27 @@ func init() {
 	logger = logging.MustGetLogger("consensus/controller")
 }
 
+// CheckHealth returns an error if the consensus plugin cannot reach
+// consensus with the number of other validators connected
+func CheckHealth(validators int) error {
+	if viper.GetString("peer.validator.consensus") != "obcpbft" {
+		return nil
+	}
+	if required := obcpbft.RequiredValidators(); validators < required {
+		return fmt.Errorf("Connected to %d validators, %d required for consensus", validators, required)
+	}
+	return nil
+}
+
+// Syncing returns whether the consensus plugin is catching up with the
+// other validators
+func Syncing() bool {
+	if viper.GetString("peer.validator.consensus") != "obcpbft" {
+		return false
+	}
+	return obcpbft.Syncing()
+}
+
 // NewConsenter constructs a Consenter object
 func NewConsenter(stack consensus.Stack) (consenter consensus.Consenter) {
 	plugin := viper.GetString("peer.validator.consensus")
@@ -28,6 +28,7 @@ import (
 	"github.com/openblockchain/obc-peer/openchain/consensus"
 	"github.com/openblockchain/obc-peer/openchain/consensus/controller"
 	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/tracing"
 
 	pb "github.com/openblockchain/obc-peer/protos"
 )
@@ -79,6 +80,9 @@ func (handler *ConsensusHandler) HandleMessage(msg *pb.OpenchainMessage) error {
 	if msg.Type == pb.OpenchainMessage_CHAIN_QUERY {
 		return handler.doChainQuery(msg)
 	}
+	if msg.Type == pb.OpenchainMessage_CHAIN_SIMULATE {
+		return handler.doChainSimulate(msg)
+	}
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debug("Did not handle message of type %s, passing on to next MessageHandler", msg.Type)
 	}
@@ -106,6 +110,13 @@ func (handler *ConsensusHandler) doChainTransaction(msg *pb.OpenchainMessage) er
 			}
 		}
 	}
+	// Refuse the transaction while the peer is paused, otherwise it is in
+	// flight until committed
+	if nil == response {
+		if err := peer.BeginTransaction(tx.Uuid); err != nil {
+			response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+		}
+	}
 	// Send response back to the requester
 	// response will not be nil on error
 	if nil == response {
@@ -119,6 +130,9 @@ func (handler *ConsensusHandler) doChainTransaction(msg *pb.OpenchainMessage) er
 		return nil
 	}
 
+	// Time the ordering of the transaction until it is executed
+	tracing.Begin(tx.Uuid, "consensus.order")
+
 	// Pass the message to the plugin handler (ie PBFT)
 	selfPE, _ := handler.coordinator.GetPeerEndpoint() // we are the validator introducting this tx into the system
 	return handler.consenter.RecvMsg(msg, selfPE.ID)
@@ -162,6 +176,43 @@ func (handler *ConsensusHandler) doChainQuery(msg *pb.OpenchainMessage) error {
 	return nil
 }
 
+// doChainSimulate executes the transaction without keeping its state changes
+// and responds with the hash of these changes
+func (handler *ConsensusHandler) doChainSimulate(msg *pb.OpenchainMessage) error {
+	var response *pb.Response
+	tx := &pb.Transaction{}
+	err := proto.Unmarshal(msg.Payload, tx)
+	if err != nil {
+		response = &pb.Response{Status: pb.Response_FAILURE,
+			Msg: []byte(fmt.Sprintf("Error unmarshalling payload of received OpenchainMessage:%s.", msg.Type))}
+	} else {
+		// Verify transaction signature if security is enabled
+		secHelper := handler.coordinator.GetSecHelper()
+		if nil != secHelper {
+			if logger.IsEnabledFor(logging.DEBUG) {
+				logger.Debug("Verifying transaction signature %s", tx.Uuid)
+			}
+			if tx, err = secHelper.TransactionPreValidation(tx); nil != err {
+				response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+				logger.Debug("Failed to verify transaction %v", err)
+			}
+		}
+		// simulate if response nil (ie, no error)
+		if nil == response {
+			_, stateDeltaHash, err := chaincode.Simulate(context.Background(), chaincode.GetChain(chaincode.DefaultChain), tx)
+			if err != nil {
+				response = &pb.Response{Status: pb.Response_FAILURE,
+					Msg: []byte(fmt.Sprintf("Error:%s", err))}
+			} else {
+				response = &pb.Response{Status: pb.Response_SUCCESS, Msg: stateDeltaHash}
+			}
+		}
+	}
+	payload, _ := proto.Marshal(response)
+	handler.SendMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_RESPONSE, Payload: payload})
+	return nil
+}
+
 // SendMessage sends a message to the remote Peer through the stream
 func (handler *ConsensusHandler) SendMessage(msg *pb.OpenchainMessage) error {
 	logger.Debug("Sending to stream a message of type: %s", msg.Type)
@@ -21,6 +21,7 @@ package helper
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -28,9 +29,13 @@ import (
 	"github.com/openblockchain/obc-peer/openchain/chaincode"
 	"github.com/openblockchain/obc-peer/openchain/consensus"
 	crypto "github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/fault"
 	"github.com/openblockchain/obc-peer/openchain/ledger"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/tracing"
+	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -40,6 +45,16 @@ type Helper struct {
 	secOn       bool
 	secHelper   crypto.Peer
 	curBatch    []*pb.Transaction // TODO, remove after issue 579
+
+	// chainBatches holds the transactions of the current batch executed on
+	// chains other than the default chain, by chain ID. Consensus orders and
+	// hashes the default chain, the blocks of the other chains are committed
+	// along with its blocks.
+	chainBatches map[string][]*pb.Transaction
+
+	// txErrors holds the errors of the transactions of the current batch
+	// that failed to execute, by UUID, for their results
+	txErrors map[string]string
 }
 
 // NewHelper constructs the consensus helper object
@@ -91,6 +106,10 @@ func (h *Helper) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err
 
 // Broadcast sends a message to all validating peers
 func (h *Helper) Broadcast(msg *pb.OpenchainMessage, peerType pb.PeerEndpoint_Type) error {
+	if err := fault.Inject(pb.Fault_CONSENSUS_MESSAGE, ""); err != nil {
+		logger.Warning("Dropping broadcast message: %s", err)
+		return nil
+	}
 	errors := h.coordinator.Broadcast(msg, peerType)
 	if len(errors) > 0 {
 		return fmt.Errorf("Couldn't broadcast successfully")
@@ -100,6 +119,10 @@ func (h *Helper) Broadcast(msg *pb.OpenchainMessage, peerType pb.PeerEndpoint_Ty
 
 // Unicast sends a message to a specified receiver
 func (h *Helper) Unicast(msg *pb.OpenchainMessage, receiverHandle *pb.PeerID) error {
+	if err := fault.Inject(pb.Fault_CONSENSUS_MESSAGE, receiverHandle.Name); err != nil {
+		logger.Warning("Dropping message to %s: %s", receiverHandle.Name, err)
+		return nil
+	}
 	return h.coordinator.Unicast(msg, receiverHandle)
 }
 
@@ -112,6 +135,20 @@ func (h *Helper) Sign(msg []byte) ([]byte, error) {
 	return msg, nil
 }
 
+// signBlock signs the header digest of a block committed by this validator,
+// recording its peer ID as the signer
+func (h *Helper) signBlock(headerDigest []byte) (*pb.BlockSignature, error) {
+	self, err := h.coordinator.GetPeerEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't retrieve own endpoint: %v", err)
+	}
+	signature, err := h.secHelper.Sign(headerDigest)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BlockSignature{Validator: self.ID.Name, Signature: signature}, nil
+}
+
 // Verify that the given signature is valid under the given replicaID's verification key
 // If replicaID is nil, use this validator's verification key
 // If the signature is valid, the function should return nil
@@ -150,21 +187,224 @@ func (h *Helper) BeginTxBatch(id interface{}) error {
 		return fmt.Errorf("Failed to begin transaction with the ledger: %v", err)
 	}
 	h.curBatch = nil // TODO, remove after issue 579
+	h.chainBatches = nil
+	h.txErrors = nil
 	return nil
 }
 
 // ExecTxs executes all the transactions listed in the txs array
 // one-by-one. If all the executions are successful, it returns
-// the candidate global state hash, and nil error array.
+// the candidate global state hash, and nil error array. The state hash
+// covers the state of every chain the batch has transactions for.
 func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
 	// TODO id is currently ignored, fix once the underlying implementation accepts id
 
 	// The secHelper is set during creat ChaincodeSupport, so we don't need this step
 	// cxt := context.WithValue(context.Background(), "security", h.coordinator.GetSecHelper())
+	txs = h.dropDuplicateCopies(txs)
+	txs = h.dropUnknownChains(txs)
+	for _, tx := range txs {
+		tracing.End(tx.Uuid, "consensus.order")
+		if tx.ChainID != "" {
+			h.beginChainBatch(id, tx.ChainID)
+		}
+	}
 	// TODO return directly once underlying implementation no longer returns []error
-	res, _ := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
-	h.curBatch = append(h.curBatch, txs...) // TODO, remove after issue 579
-	return res, nil
+	res, errs := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
+	for i, tx := range txs {
+		if errs[i] != nil {
+			if h.txErrors == nil {
+				h.txErrors = make(map[string]string)
+			}
+			h.txErrors[tx.Uuid] = errs[i].Error()
+		}
+		if tx.ChainID == "" {
+			h.curBatch = append(h.curBatch, tx) // TODO, remove after issue 579
+		} else if batch, ok := h.chainBatches[tx.ChainID]; ok {
+			h.chainBatches[tx.ChainID] = append(batch, tx)
+		}
+	}
+	return h.batchStateHash(res)
+}
+
+// batchStateHash returns the temporary state hash of the default chain
+// combined with the temporary state hashes of the other chains of the
+// current batch, in order, so that validators agree on the state of every
+// chain. It is the state hash of the default chain if the batch has no
+// transactions for other chains.
+func (h *Helper) batchStateHash(stateHash []byte) ([]byte, error) {
+	chainIDs := h.chainIDs()
+	if len(chainIDs) == 0 {
+		return stateHash, nil
+	}
+	data := append([]byte(nil), stateHash...)
+	for _, chainID := range chainIDs {
+		chainLedger, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the ledger of chain %s: %v", chainID, err)
+		}
+		chainStateHash, err := chainLedger.GetTempStateHash()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the state hash of chain %s: %v", chainID, err)
+		}
+		data = append(append(append(data, chainID...), 0), chainStateHash...)
+	}
+	return util.ComputeCryptoHash(data), nil
+}
+
+// dropDuplicateCopies leaves out the transactions submitted for invocations
+// enqueued by chaincodes, and the transactions sent to several validators,
+// that are already part of the current batch or of the blockchain. Every
+// copy of them has the same UUID, so all validators leave out the same
+// copies.
+func (h *Helper) dropDuplicateCopies(txs []*pb.Transaction) []*pb.Transaction {
+	seen := make(map[string]bool)
+	for _, tx := range h.curBatch {
+		seen[tx.Uuid] = true
+	}
+	for _, batch := range h.chainBatches {
+		for _, tx := range batch {
+			seen[tx.Uuid] = true
+		}
+	}
+	kept := make([]*pb.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if chaincode.IsDeferredUUID(tx.Uuid) || peer.IsBroadcastUUID(tx.Uuid) {
+			if seen[tx.Uuid] || isCommitted(tx) {
+				logger.Debug("Dropping duplicate copy of transaction %s", tx.Uuid)
+				continue
+			}
+			seen[tx.Uuid] = true
+		}
+		kept = append(kept, tx)
+	}
+	return kept
+}
+
+// dropUnknownChains leaves out the transactions for chains other than the
+// default chain that were not created by a configuration transaction, or
+// whose ledger fell out of sync with the default chain. They are recorded
+// as dead letters.
+func (h *Helper) dropUnknownChains(txs []*pb.Transaction) []*pb.Transaction {
+	kept := make([]*pb.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		if err := checkChain(tx.ChainID); err != nil {
+			logger.Warning("Rejecting transaction %s: %s", tx.Uuid, err)
+			peer.EndTransaction(tx.Uuid)
+			peer.RecordDeadLetter(tx, pb.DeadLetter_EXECUTION, err.Error(), 0)
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	return kept
+}
+
+// checkChain returns an error unless transactions can be executed on the
+// chain
+func checkChain(chainID string) error {
+	if chainID == "" {
+		return nil
+	}
+	if !netconfig.ChainExists(chainID) {
+		return fmt.Errorf("Chain %s does not exist", chainID)
+	}
+	chainLedger, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		return err
+	}
+	if cause := chainLedger.OutOfSync(); cause != "" {
+		return fmt.Errorf("Chain %s is out of sync with the default chain: %s", chainID, cause)
+	}
+	return nil
+}
+
+// isCommitted returns whether the ledger of the chain of tx holds it
+func isCommitted(tx *pb.Transaction) bool {
+	chainLedger, err := ledger.GetChainLedger(tx.ChainID)
+	if err != nil {
+		return false
+	}
+	committed, err := chainLedger.GetTransactionByUUID(tx.Uuid)
+	return err == nil && committed != nil
+}
+
+// beginChainBatch begins the transaction-batch on the ledger of a chain other
+// than the default chain the first time the batch has a transaction for it.
+// Transactions for chains whose ledger cannot be used fail to execute and
+// are left out of the batch.
+func (h *Helper) beginChainBatch(id interface{}, chainID string) {
+	if _, ok := h.chainBatches[chainID]; ok {
+		return
+	}
+	chainLedger, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		logger.Error("Failed to get the ledger of chain %s: %v", chainID, err)
+		return
+	}
+	if err := chainLedger.BeginTxBatch(id); err != nil {
+		logger.Error("Failed to begin transaction with the ledger of chain %s: %v", chainID, err)
+		return
+	}
+	if h.chainBatches == nil {
+		h.chainBatches = make(map[string][]*pb.Transaction)
+	}
+	h.chainBatches[chainID] = nil
+}
+
+// chainIDs returns the IDs of the chains of the current batch in order
+func (h *Helper) chainIDs() []string {
+	chainIDs := make([]string, 0, len(h.chainBatches))
+	for chainID := range h.chainBatches {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+	return chainIDs
+}
+
+// batchTxs returns the transactions of the current batch on all the chains
+func (h *Helper) batchTxs() []*pb.Transaction {
+	batch := append([]*pb.Transaction(nil), h.curBatch...)
+	for _, chainID := range h.chainIDs() {
+		batch = append(batch, h.chainBatches[chainID]...)
+	}
+	return batch
+}
+
+// commitChainBatches commits the transaction-batch on the ledgers of the
+// chains other than the default chain, once it is committed on the default
+// chain. The block of the default chain records the batch, so a chain whose
+// ledger fails to commit is left behind: it is marked out of sync, which
+// stops its transactions until it is restored, and the others are still
+// committed.
+func (h *Helper) commitChainBatches(id interface{}, metadata []byte, outcomes map[string]*pb.TransactionResult, txErrors map[string]string) {
+	for _, chainID := range h.chainIDs() {
+		chainLedger, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			logger.Critical("Failed to get the ledger of chain %s, it no longer follows the default chain: %v", chainID, err)
+			continue
+		}
+		if err := chainLedger.CommitTxBatch(id, h.chainBatches[chainID], results(h.chainBatches[chainID], outcomes, txErrors), metadata); err != nil {
+			logger.Critical("Failed to commit transaction to the ledger of chain %s, marking it out of sync with the default chain: %v", chainID, err)
+			if markErr := chainLedger.MarkOutOfSync(err); markErr != nil {
+				logger.Critical("Failed to mark chain %s out of sync: %v", chainID, markErr)
+			}
+		}
+	}
+}
+
+// rollbackChainBatches discards the state changes made on the chains other
+// than the default chain during the current transaction-batch
+func (h *Helper) rollbackChainBatches(id interface{}) error {
+	for _, chainID := range h.chainIDs() {
+		chainLedger, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			return fmt.Errorf("Failed to get the ledger of chain %s: %v", chainID, err)
+		}
+		if err := chainLedger.RollbackTxBatch(id); err != nil {
+			return fmt.Errorf("Failed to rollback transaction with the ledger of chain %s: %v", chainID, err)
+		}
+	}
+	return nil
 }
 
 // CommitTxBatch gets invoked when the current transaction-batch needs
@@ -177,12 +417,55 @@ func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, erro
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get the ledger: %v", err)
 	}
+	batch := h.batchTxs()
+	spans := make([]*tracing.Span, len(batch))
+	for i, tx := range batch {
+		spans[i] = tracing.StartSpan(tx.Uuid, "ledger.commit")
+	}
+	outcomes := chaincode.TakeTransactionResults(chaincode.DefaultChain, uuids(batch))
+	txErrors := h.txErrors
+	h.txErrors = nil
+	if h.secOn {
+		ledger.SetBlockSigner(h.signBlock)
+	}
+	// The default chain is committed first, the other chains are only
+	// committed once it holds the batch
 	// TODO fix this one the ledger has been fixed to implement
-	if err := ledger.CommitTxBatch(id, h.curBatch, nil, metadata); err != nil {
+	if err := ledger.CommitTxBatch(id, h.curBatch, results(h.curBatch, outcomes, txErrors), metadata); err != nil {
+		if rollbackErr := h.rollbackChainBatches(id); rollbackErr != nil {
+			logger.Error("%v", rollbackErr)
+		}
+		chaincode.DiscardDeferredTransactions(chaincode.DefaultChain, uuids(batch))
+		chaincode.DiscardChaincodeEvents(chaincode.DefaultChain, uuids(batch))
+		h.curBatch = nil // TODO, remove after issue 579
+		h.chainBatches = nil
 		return nil, fmt.Errorf("Failed to commit transaction to the ledger: %v", err)
 	}
+	h.commitChainBatches(id, metadata, outcomes, txErrors)
+	h.chainBatches = nil
 
 	size := ledger.GetBlockchainSize()
+	for _, tx := range batch {
+		peer.EndTransaction(tx.Uuid)
+		if cause, ok := txErrors[tx.Uuid]; ok {
+			var blockNumber uint64
+			if tx.ChainID == "" {
+				blockNumber = size - 1
+			}
+			peer.RecordDeadLetter(tx, pb.DeadLetter_EXECUTION, cause, blockNumber)
+		}
+	}
+	if deferred := chaincode.TakeDeferredTransactions(chaincode.DefaultChain, uuids(batch)); len(deferred) > 0 {
+		go h.submitDeferred(deferred)
+	}
+	chaincode.SendChaincodeEvents(chaincode.DefaultChain, uuids(batch))
+	if err := netconfig.Update(); err != nil {
+		logger.Error("Failed to update the network parameters: %s", err)
+	}
+	for _, span := range spans {
+		span.Tag("block", fmt.Sprintf("%d", size-1))
+		span.Finish()
+	}
 	h.curBatch = nil // TODO, remove after issue 579
 
 	block, err := ledger.GetBlockByNumber(size - 1)
@@ -193,6 +476,45 @@ func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, erro
 	return block, nil
 }
 
+// submitDeferred submits the transactions for the invocations enqueued by the
+// transactions of a committed block
+func (h *Helper) submitDeferred(txs []*pb.Transaction) {
+	for _, tx := range txs {
+		logger.Debug("Submitting enqueued invocation %s", tx.Uuid)
+		if resp := h.coordinator.ExecuteTransaction(tx); resp.Status == pb.Response_FAILURE {
+			logger.Error("Failed to submit enqueued invocation %s: %s", tx.Uuid, resp.Msg)
+			peer.RecordDeadLetter(tx, pb.DeadLetter_SUBMISSION, string(resp.Msg), 0)
+		}
+	}
+}
+
+// results returns the results of txs recording what the chaincodes produced,
+// the resources they used and the error of those that failed
+func results(txs []*pb.Transaction, outcomes map[string]*pb.TransactionResult, txErrors map[string]string) []*pb.TransactionResult {
+	if len(txs) == 0 {
+		return nil
+	}
+	res := make([]*pb.TransactionResult, len(txs))
+	for i, tx := range txs {
+		result, ok := outcomes[tx.Uuid]
+		if !ok {
+			result = &pb.TransactionResult{Uuid: tx.Uuid}
+		}
+		result.Error = txErrors[tx.Uuid]
+		res[i] = result
+	}
+	return res
+}
+
+// uuids returns the UUIDs of txs
+func uuids(txs []*pb.Transaction) []string {
+	ids := make([]string, len(txs))
+	for i, tx := range txs {
+		ids[i] = tx.Uuid
+	}
+	return ids
+}
+
 // RollbackTxBatch discards all the state changes that may have taken
 // place during the execution of current transaction-batch
 func (h *Helper) RollbackTxBatch(id interface{}) error {
@@ -200,11 +522,17 @@ func (h *Helper) RollbackTxBatch(id interface{}) error {
 	if err != nil {
 		return fmt.Errorf("Failed to get the ledger: %v", err)
 	}
+	chaincode.DiscardDeferredTransactions(chaincode.DefaultChain, uuids(h.batchTxs()))
+	chaincode.DiscardChaincodeEvents(chaincode.DefaultChain, uuids(h.batchTxs()))
+	chaincode.TakeTransactionResults(chaincode.DefaultChain, uuids(h.batchTxs()))
+	h.txErrors = nil
+	chainErr := h.rollbackChainBatches(id)
+	h.chainBatches = nil
 	if err := ledger.RollbackTxBatch(id); err != nil {
 		return fmt.Errorf("Failed to rollback transaction with the ledger: %v", err)
 	}
 	h.curBatch = nil // TODO, remove after issue 579
-	return nil
+	return chainErr
 }
 
 // PreviewCommitTxBatch retrieves a preview copy of the block that would be inserted into the ledger if CommitTxBatch were invoked.
@@ -233,13 +561,17 @@ func (h *Helper) GetBlock(blockNumber uint64) (block *pb.Block, err error) {
 	return ledger.GetBlockByNumber(blockNumber)
 }
 
-// GetCurrentStateHash returns the current/temporary state hash
+// GetCurrentStateHash returns the current/temporary state hash, covering
+// the chains of the current batch like the state hash ExecTxs returns
 func (h *Helper) GetCurrentStateHash() (stateHash []byte, err error) {
 	ledger, err := ledger.GetLedger()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get the ledger :%v", err)
 	}
-	return ledger.GetTempStateHash()
+	if stateHash, err = ledger.GetTempStateHash(); err != nil {
+		return nil, err
+	}
+	return h.batchStateHash(stateHash)
 }
 
 // GetBlockchainSize returns the current size of the blockchain
@@ -265,6 +597,19 @@ func (h *Helper) PutBlock(blockNumber uint64, block *pb.Block) error {
 	return ledger.PutRawBlock(block, blockNumber)
 }
 
+// AddBlockSignature records a validator's signature over the header of the
+// block at blockNumber, building up the block's quorum certificate
+func (h *Helper) AddBlockSignature(blockNumber uint64, signature *pb.BlockSignature) error {
+	if !h.secOn {
+		return nil
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Failed to get the ledger :%v", err)
+	}
+	return ledger.AddBlockSignature(blockNumber, signature)
+}
+
 // ApplyStateDelta applies a state delta to the current state
 // The result of this function can be retrieved using GetCurrentStateDelta
 // To commit the result, call CommitStateDelta, or to roll it back
@@ -284,7 +629,13 @@ func (h *Helper) CommitStateDelta(id interface{}) error {
 	if err != nil {
 		return fmt.Errorf("Failed to get the ledger :%v", err)
 	}
-	return ledger.CommitStateDelta(id)
+	if err = ledger.CommitStateDelta(id); err != nil {
+		return err
+	}
+	if err = netconfig.Update(); err != nil {
+		logger.Error("Failed to update the network parameters: %s", err)
+	}
+	return nil
 }
 
 // RollbackStateDelta undoes the results of ApplyStateDelta to revert
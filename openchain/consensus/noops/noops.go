@@ -145,13 +145,15 @@ func (i *Noops) canProcessBlock(tx *pb.Transaction) bool {
 
 	// TODO: Ask coordinator if we need to start sync
 
-	i.txQ.append(tx)
+	priority := consensus.Priority(tx)
+	i.txQ.append(tx, priority)
 
 	// start timer if we get a tx
 	if i.txQ.size() == 1 {
 		i.timer.Reset(i.duration)
 	}
-	return i.txQ.isFull()
+	// Do not hold back priority transactions until the block is full
+	return i.txQ.isFull() || priority > 0
 }
 
 func (i *Noops) handleChannels() {
@@ -162,7 +164,7 @@ func (i *Noops) handleChannels() {
 		case tx := <-i.channel:
 			if i.canProcessBlock(tx) {
 				if logger.IsEnabledFor(logging.DEBUG) {
-					logger.Debug("Process block due to size")
+					logger.Debug("Process block due to size or priority")
 				}
 				if err := i.processBlock(); nil != err {
 					logger.Error(err.Error())
@@ -211,7 +213,7 @@ func (i *Noops) processTransactions() error {
 		return err
 	}
 
-	// Grab all transactions from the FIFO queue and run them in order
+	// Grab all transactions from the queue and run them by priority
 	txarr := i.txQ.getTXs()
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debug("Executing batch of %d transactions with timestamp %v", len(txarr), timestamp)
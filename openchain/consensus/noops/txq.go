@@ -20,12 +20,16 @@ under the License.
 package noops
 
 import (
+	"sort"
+
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
+// txq holds the transactions of the next block in one lane per priority
 type txq struct {
-	i int
-	q []*pb.Transaction
+	i     int
+	max   int
+	lanes map[uint32][]*pb.Transaction
 }
 
 func newTXQ(size int) *txq {
@@ -34,25 +38,36 @@ func newTXQ(size int) *txq {
 	if size < 1 {
 		size = 1
 	}
-	o.q = make([]*pb.Transaction, size)
+	o.max = size
+	o.lanes = make(map[uint32][]*pb.Transaction)
 	return o
 }
 
-func (o *txq) append(tx *pb.Transaction) {
-	if cap(o.q) > o.i {
-		o.q[o.i] = tx
+func (o *txq) append(tx *pb.Transaction, priority uint32) {
+	if o.max > o.i {
+		o.lanes[priority] = append(o.lanes[priority], tx)
 		o.i++
 	}
 }
 
+// getTXs empties the queue, returning the transactions of the higher
+// priority lanes first
 func (o *txq) getTXs() []*pb.Transaction {
-	length := o.i
-	o.i = 0
-	return o.q[:length]
+	var priorities []int
+	for priority := range o.lanes {
+		priorities = append(priorities, int(priority))
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+	txs := make([]*pb.Transaction, 0, o.i)
+	for _, priority := range priorities {
+		txs = append(txs, o.lanes[uint32(priority)]...)
+	}
+	o.reset()
+	return txs
 }
 
 func (o *txq) isFull() bool {
-	if cap(o.q) == o.i {
+	if o.max == o.i {
 		return true
 	}
 	return false
@@ -64,4 +79,5 @@ func (o *txq) size() int {
 
 func (o *txq) reset() {
 	o.i = 0
+	o.lanes = make(map[uint32][]*pb.Transaction)
 }
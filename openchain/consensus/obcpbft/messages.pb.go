@@ -383,6 +383,9 @@ type Checkpoint struct {
 	ReplicaId      uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
 	BlockNumber    uint64 `protobuf:"varint,3,opt,name=block_number" json:"block_number,omitempty"`
 	BlockHash      string `protobuf:"bytes,4,opt,name=block_hash" json:"block_hash,omitempty"`
+	// signature of the header digest of the block by the replica, base64
+	// encoded, gathered into the quorum certificate of the block
+	BlockSignature string `protobuf:"bytes,5,opt,name=block_signature" json:"block_signature,omitempty"`
 }
 
 func (m *Checkpoint) Reset()         { *m = Checkpoint{} }
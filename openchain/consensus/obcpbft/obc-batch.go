@@ -24,11 +24,14 @@ import (
 	"fmt"
 	"time"
 
+	occonfig "github.com/openblockchain/obc-peer/openchain/config"
 	"github.com/openblockchain/obc-peer/openchain/consensus"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
 	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
@@ -38,9 +41,10 @@ type obcBatch struct {
 
 	batchSize        int
 	batchStore       [][]byte
-	batchTimer       *time.Timer
+	batchTimer       consensus.Timer
 	batchTimerActive bool
 	batchTimeout     time.Duration
+	timestampSkew    time.Duration
 }
 
 func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatch {
@@ -53,10 +57,10 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
 	}
+	op.timestampSkew = loadTimestampSkew(config)
 	// create non-running timer
-	op.batchTimer = time.NewTimer(100 * time.Hour) // XXX ugly
+	op.batchTimer = consensus.GetClock(stack).AfterFunc(100*time.Hour, op.batchTimerExpired) // XXX ugly
 	op.batchTimer.Stop()
-	go op.batchTimerHander()
 	return op
 }
 
@@ -122,7 +126,7 @@ func (op *obcBatch) RecvMsg(ocMsg *pb.OpenchainMessage, senderHandle *pb.PeerID)
 // Close tells us to release resources we are holding
 func (op *obcBatch) Close() {
 	op.pbft.close()
-	op.batchTimer.Reset(0)
+	op.batchTimer.Stop()
 }
 
 // Drain will block until all remaining execution has been handled
@@ -133,6 +137,11 @@ func (op *obcBatch) Drain() {
 	op.pbft.drain()
 }
 
+// Syncing reports whether the replica is catching up by state transfer
+func (op *obcBatch) Syncing() bool {
+	return op.pbft.syncing()
+}
+
 // =============================================================================
 // innerStack interface (functions called by pbft-core)
 // =============================================================================
@@ -164,14 +173,26 @@ func (op *obcBatch) verify(senderID uint64, signature []byte, message []byte) er
 	return op.stack.Verify(senderHandle, signature, message)
 }
 
-// validate checks whether the request is valid syntactically
-// not used in obc-batch at the moment
-func (op *obcBatch) validate(txRaw []byte) error {
+// validate checks whether the request may be ordered: it must hold a
+// transaction block whose transactions all have timestamps within the
+// allowed skew of our clock
+func (op *obcBatch) validate(tbRaw []byte) error {
+	tb := &pb.TransactionBlock{}
+	if err := proto.Unmarshal(tbRaw, tb); err != nil {
+		return err
+	}
+	now := consensus.GetClock(op.stack).Now()
+	for _, tx := range tb.Transactions {
+		if err := checkTimestamp(tx, now, op.timestampSkew); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// execute an opaque request which corresponds to an OBC Transaction
-func (op *obcBatch) execute(tbRaw []byte) {
+// execute an opaque request which corresponds to an OBC Transaction,
+// committed at the given sequence number
+func (op *obcBatch) execute(seqNo uint64, tbRaw []byte) {
 	tb := &pb.TransactionBlock{}
 	err := proto.Unmarshal(tbRaw, tb)
 	if err != nil {
@@ -181,15 +202,6 @@ func (op *obcBatch) execute(tbRaw []byte) {
 	txs := tb.Transactions
 	txBatchID := base64.StdEncoding.EncodeToString(util.ComputeCryptoHash(tbRaw))
 
-	for i, tx := range txs {
-		txRaw, _ := proto.Marshal(tx)
-		if err = op.validate(txRaw); err != nil {
-			err = fmt.Errorf("Request in transaction %d from batch %s did not verify: %s", i, txBatchID, err)
-			logger.Error(err.Error())
-			return
-		}
-	}
-
 	if err := op.stack.BeginTxBatch(txBatchID); err != nil {
 		err = fmt.Errorf("Failed to begin transaction batch %s: %v", txBatchID, err)
 		logger.Error(err.Error())
@@ -205,7 +217,7 @@ func (op *obcBatch) execute(tbRaw []byte) {
 		return
 	}
 
-	if _, err = op.stack.CommitTxBatch(txBatchID, nil); err != nil {
+	if _, err = op.stack.CommitTxBatch(txBatchID, consensusMetadata(seqNo)); err != nil {
 		err = fmt.Errorf("Failed to commit transaction batch %s to the ledger: %v", txBatchID, err)
 		logger.Error(err.Error())
 		if err = op.stack.RollbackTxBatch(txBatchID); err != nil {
@@ -227,19 +239,48 @@ func (op *obcBatch) viewChange(curView uint64) {
 // =============================================================================
 
 func (op *obcBatch) leaderProcReq(req []byte) error {
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(req, tx); err != nil {
+		return fmt.Errorf("Unable to unpack payload of request: %s", err)
+	}
+	if err := checkTimestamp(tx, consensus.GetClock(op.stack).Now(), op.timestampSkew); err != nil {
+		logger.Warning("Primary %d dropping request: %s", op.pbft.id, err)
+		return err
+	}
+
 	op.batchStore = append(op.batchStore, req)
 
 	if !op.batchTimerActive {
 		op.startBatchTimer()
 	}
 
-	if len(op.batchStore) >= op.batchSize {
+	if len(op.batchStore) >= op.currentBatchSize() || requestPriority(req) > 0 {
 		op.sendBatch()
 	}
 
 	return nil
 }
 
+// requestPriority returns the priority of the transaction of a request,
+// bounded by the priority policy. Requests holding a priority transaction
+// cut the batch right away.
+func requestPriority(req []byte) uint32 {
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(req, tx); err != nil {
+		return 0
+	}
+	return consensus.Priority(tx)
+}
+
+// currentBatchSize returns the batch size set by a configuration
+// transaction if any, general.batchSize otherwise
+func (op *obcBatch) currentBatchSize() int {
+	if size, ok := netconfig.GetBatchSize(); ok {
+		return size
+	}
+	return op.batchSize
+}
+
 func (op *obcBatch) sendBatch() error {
 	op.stopBatchTimer()
 
@@ -247,6 +288,7 @@ func (op *obcBatch) sendBatch() error {
 	var txs []*pb.Transaction
 	store := op.batchStore
 	op.batchStore = nil
+	now := consensus.GetClock(op.stack).Now()
 	for _, req := range store {
 		tx := &pb.Transaction{}
 		err := proto.Unmarshal(req, tx)
@@ -255,8 +297,16 @@ func (op *obcBatch) sendBatch() error {
 			logger.Error(err.Error())
 			continue
 		}
+		// a request may have gone stale while waiting for the batch to
+		// fill; it would get the whole batch refused by the backups
+		if err = checkTimestamp(tx, now, op.timestampSkew); err != nil {
+			logger.Warning("Primary %d dropping request: %s", op.pbft.id, err)
+			continue
+		}
 		txs = append(txs, tx)
 	}
+	// Execute the transactions of the higher priority lanes first
+	consensus.SortByPriority(txs)
 	tb := &pb.TransactionBlock{Transactions: txs}
 	tbPacked, err := proto.Marshal(tb)
 	if err != nil {
@@ -273,21 +323,41 @@ func (op *obcBatch) sendBatch() error {
 	return nil
 }
 
+// watchConfig reloads the batch timeout when the config file of the plugin
+// changes or the peer receives a SIGHUP
+func (op *obcBatch) watchConfig() {
+	watcher, err := occonfig.NewWatcher(config.ConfigFileUsed(), config.Set)
+	if err != nil {
+		logger.Error("Not watching the config file: %s", err)
+		return
+	}
+	watcher.Register("general.timeout.batch", occonfig.ValidateDuration, func(key string, value interface{}) {
+		timeout, _ := time.ParseDuration(cast.ToString(value))
+		op.pbft.lock()
+		op.batchTimeout = timeout
+		op.pbft.unlock()
+		logger.Info("Replica %d batch timeout set to %s", op.pbft.id, timeout)
+	})
+	go watcher.Watch(viper.GetDuration("peer.configwatch.interval"))
+}
+
 // allow the primary to send a batch when the timer expires
-func (op *obcBatch) batchTimerHander() {
-	for {
-		select {
-		case <-op.pbft.closed:
-			return
-
-		case <-op.batchTimer.C:
-			op.pbft.lock()
-			logger.Info("Replica %d batch timer expired", op.pbft.id)
-			if op.pbft.activeView && (len(op.batchStore) > 0) {
-				op.sendBatch()
-			}
-			op.pbft.unlock()
-		}
+func (op *obcBatch) batchTimerExpired() {
+	op.pbft.lock()
+	defer op.pbft.unlock()
+	select {
+	case <-op.pbft.closed:
+		return
+	default:
+	}
+
+	// The timer may have been stopped while we were waiting for the lock
+	if !op.batchTimerActive {
+		return
+	}
+	logger.Info("Replica %d batch timer expired", op.pbft.id)
+	if op.pbft.activeView && (len(op.batchStore) > 0) {
+		op.sendBatch()
 	}
 }
 
@@ -301,19 +371,6 @@ func (op *obcBatch) stopBatchTimer() {
 	op.batchTimer.Stop()
 	logger.Debug("Replica %d stopped the batch timer", op.pbft.id)
 	op.batchTimerActive = false
-	select {
-	case <-op.pbft.closed:
-		return
-	default:
-	}
-loopBatch:
-	for {
-		select {
-		case <-op.batchTimer.C:
-		default:
-			break loopBatch
-		}
-	}
 }
 
 // Wraps a payload into a batch message, packs it and wraps it into
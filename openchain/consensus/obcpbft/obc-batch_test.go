@@ -21,10 +21,15 @@ package obcpbft
 
 import (
 	"fmt"
+	gp "google/protobuf"
 	"os"
 	"testing"
 
+	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
 )
 
 func makeTestnetBatch(inst *instance, batchSize int) {
@@ -79,5 +84,93 @@ func TestNetworkBatch(t *testing.T) {
 			t.Fatalf("Replica %d executed %d requests, expected %d",
 				inst.id, numTrans, net.replicas[i].consenter.(*obcBatch).batchSize)
 		}
+		metadata := &pb.ConsensusMetadata{}
+		if err := proto.Unmarshal(block.ConsensusMetadata, metadata); err != nil {
+			t.Fatalf("Replica %d committed a block without consensus metadata: %s", inst.id, err)
+		}
+		if metadata.SequenceNumber != 1 {
+			t.Fatalf("Replica %d committed the block at sequence number %d, expected 1",
+				inst.id, metadata.SequenceNumber)
+		}
+	}
+}
+
+func TestNetworkBatchPriority(t *testing.T) {
+	viper.Set("peer.priority.max", 2)
+	viper.Set("peer.priority.config", 2)
+	defer func() {
+		viper.Set("peer.priority.max", 0)
+		viper.Set("peer.priority.config", 0)
+	}()
+
+	validatorCount := 4
+	net := makeTestnet(validatorCount, func(inst *instance) {
+		makeTestnetBatch(inst, 3)
+	})
+	defer net.close()
+
+	broadcaster := net.handles[generateBroadcaster(validatorCount)]
+	err := net.replicas[1].consenter.RecvMsg(createOcMsgWithChainTx(1), broadcaster)
+	if err != nil {
+		t.Fatalf("External request was not processed by backup: %v", err)
+	}
+	net.processWithoutDrain()
+
+	// A configuration transaction cuts the batch without waiting for it to fill up
+	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_CONFIG, Payload: []byte("config"), Priority: 2, Timestamp: util.CreateUtcTimestamp()}
+	txPacked, _ := proto.Marshal(tx)
+	err = net.replicas[2].consenter.RecvMsg(&pb.OpenchainMessage{Type: pb.OpenchainMessage_CHAIN_TRANSACTION, Payload: txPacked}, broadcaster)
+	if err != nil {
+		t.Fatalf("External request was not processed by backup: %v", err)
+	}
+	net.process()
+
+	if len(net.replicas[0].consenter.(*obcBatch).batchStore) != 0 {
+		t.Fatalf("%d messages expected in primary's batchStore, found %d", 0, len(net.replicas[0].consenter.(*obcBatch).batchStore))
+	}
+
+	for _, inst := range net.replicas {
+		block, err := inst.GetBlock(1)
+		if nil != err {
+			t.Fatalf("Replica %d executed requests, expected a new block on the chain, but could not retrieve it : %s", inst.id, err)
+		}
+		if numTrans := len(block.Transactions); numTrans != 2 {
+			t.Fatalf("Replica %d executed %d requests, expected %d", inst.id, numTrans, 2)
+		}
+		if block.Transactions[0].Type != pb.Transaction_CHAINCODE_CONFIG {
+			t.Fatalf("Replica %d executed the configuration transaction after the bulk workload", inst.id)
+		}
+	}
+}
+
+func TestBatchTimestampSkew(t *testing.T) {
+	validatorCount := 4
+	net := makeTestnet(validatorCount, func(inst *instance) {
+		makeTestnetBatch(inst, 1)
+	})
+	defer net.close()
+
+	stale := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Payload: []byte("stale"), Timestamp: &gp.Timestamp{Seconds: 1}}
+	stalePacked, _ := proto.Marshal(stale)
+	primary := net.replicas[0].consenter.(*obcBatch)
+	err := primary.RecvMsg(&pb.OpenchainMessage{Type: pb.OpenchainMessage_CHAIN_TRANSACTION, Payload: stalePacked}, net.handles[0])
+	if err == nil {
+		t.Fatalf("Expected the primary to drop a transaction with a stale timestamp")
+	}
+	if len(primary.batchStore) != 0 {
+		t.Fatalf("Expected the primary not to batch a transaction with a stale timestamp, found %d", len(primary.batchStore))
+	}
+
+	// A backup refuses to prepare a batch a faulty primary filled with it
+	backup := net.replicas[1].consenter.(*obcBatch)
+	tbPacked, _ := proto.Marshal(&pb.TransactionBlock{Transactions: []*pb.Transaction{stale}})
+	if err := backup.validate(tbPacked); err == nil {
+		t.Fatalf("Expected a backup to refuse a batch holding a transaction with a stale timestamp")
+	}
+
+	fresh := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Payload: []byte("fresh"), Timestamp: util.CreateUtcTimestamp()}
+	tbPacked, _ = proto.Marshal(&pb.TransactionBlock{Transactions: []*pb.Transaction{fresh}})
+	if err := backup.validate(tbPacked); err != nil {
+		t.Fatalf("Expected a backup to accept a batch with current timestamps: %s", err)
 	}
 }
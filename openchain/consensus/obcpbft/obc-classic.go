@@ -22,6 +22,7 @@ package obcpbft
 import (
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"github.com/openblockchain/obc-peer/openchain/consensus"
 	"github.com/openblockchain/obc-peer/openchain/util"
@@ -34,11 +35,14 @@ import (
 type obcClassic struct {
 	stack consensus.Stack
 	pbft  *pbftCore
+
+	timestampSkew time.Duration
 }
 
 func newObcClassic(id uint64, config *viper.Viper, stack consensus.Stack) *obcClassic {
 	op := &obcClassic{stack: stack}
 	op.pbft = newPbftCore(id, config, op, stack)
+	op.timestampSkew = loadTimestampSkew(config)
 	return op
 }
 
@@ -77,6 +81,16 @@ func (op *obcClassic) Close() {
 	op.pbft.close()
 }
 
+// Drain will block until all remaining execution has been handled
+func (op *obcClassic) Drain() {
+	op.pbft.drain()
+}
+
+// Syncing reports whether the replica is catching up by state transfer
+func (op *obcClassic) Syncing() bool {
+	return op.pbft.syncing()
+}
+
 // =============================================================================
 // innerStack interface (functions called by pbft-core)
 // =============================================================================
@@ -115,20 +129,19 @@ func (op *obcClassic) verify(senderID uint64, signature []byte, message []byte)
 	return op.stack.Verify(senderHandle, signature, message)
 }
 
-// validate checks whether the request is valid syntactically
-// not used in obc-classic at the moment
+// validate checks whether the request may be ordered: it must hold a
+// transaction whose timestamp is within the allowed skew of our clock
 func (op *obcClassic) validate(txRaw []byte) error {
-	return nil
-}
-
-// execute an opaque request which corresponds to an OBC Transaction
-func (op *obcClassic) execute(txRaw []byte) {
-	if err := op.validate(txRaw); err != nil {
-		err = fmt.Errorf("Request in transaction did not validate: %s", err)
-		logger.Error(err.Error())
-		return
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(txRaw, tx); err != nil {
+		return err
 	}
+	return checkTimestamp(tx, consensus.GetClock(op.stack).Now(), op.timestampSkew)
+}
 
+// execute an opaque request which corresponds to an OBC Transaction,
+// committed at the given sequence number
+func (op *obcClassic) execute(seqNo uint64, txRaw []byte) {
 	tx := &pb.Transaction{}
 	err := proto.Unmarshal(txRaw, tx)
 	if err != nil {
@@ -155,7 +168,7 @@ func (op *obcClassic) execute(txRaw []byte) {
 		return
 	}
 
-	if _, err = op.stack.CommitTxBatch(txBatchID, nil); err != nil {
+	if _, err = op.stack.CommitTxBatch(txBatchID, consensusMetadata(seqNo)); err != nil {
 		err = fmt.Errorf("Failed to commit transaction %s to the ledger: %v", txBatchID, err)
 		logger.Error(err.Error())
 		if err = op.stack.RollbackTxBatch(txBatchID); err != nil {
@@ -23,7 +23,9 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/openblockchain/obc-peer/openchain/consensus"
 	pb "github.com/openblockchain/obc-peer/protos"
 
@@ -43,6 +45,9 @@ func init() {
 func GetPlugin(c consensus.Stack) consensus.Consenter {
 	if pluginInstance == nil {
 		pluginInstance = New(c)
+		if batch, ok := pluginInstance.(*obcBatch); ok {
+			batch.watchConfig()
+		}
 	}
 	return pluginInstance
 }
@@ -85,6 +90,23 @@ func loadConfig() (config *viper.Viper) {
 	return
 }
 
+// RequiredValidators returns the number of other validators a replica must
+// be connected to for the network to reach consensus
+func RequiredValidators() int {
+	return 2 * config.GetInt("general.f")
+}
+
+// Syncing returns whether the replica of the peer is catching up with the
+// network by state transfer. It is false until the plugin is created.
+func Syncing() bool {
+	if s, ok := pluginInstance.(interface {
+		Syncing() bool
+	}); ok {
+		return s.Syncing()
+	}
+	return false
+}
+
 // Returns the uint64 ID corresponding to a peer handle
 func getValidatorID(handle *pb.PeerID) (id uint64, err error) {
 	// as requested here: https://github.com/openblockchain/obc-peer/issues/462#issuecomment-170785410
@@ -108,3 +130,47 @@ func getValidatorHandle(id uint64) (handle *pb.PeerID, err error) {
 	name := "vp" + strconv.FormatUint(id, 10)
 	return &pb.PeerID{Name: name}, nil
 }
+
+// Returns how far the timestamp of a transaction may be off the clock of a
+// replica for the replica to order it; zero disables the check
+func loadTimestampSkew(config *viper.Viper) time.Duration {
+	skew, err := time.ParseDuration(config.GetString("general.timestampskew"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse timestamp skew: %s", err))
+	}
+	return skew
+}
+
+// Returns an error if the timestamp of tx is missing or more than skew away
+// from now. Replicas check requests before ordering them, so that only
+// transactions with timestamps close to the time the network agreed on them
+// get executed. The check depends on the clock of the replica and must never
+// decide how an ordered request executes.
+func checkTimestamp(tx *pb.Transaction, now time.Time, skew time.Duration) error {
+	if skew == 0 {
+		return nil
+	}
+	ts := tx.GetTimestamp()
+	if ts == nil {
+		return fmt.Errorf("Transaction %s has no timestamp", tx.Uuid)
+	}
+	txTime := time.Unix(ts.Seconds, int64(ts.Nanos))
+	if diff := txTime.Sub(now); diff > skew || diff < -skew {
+		return fmt.Errorf("Timestamp %s of transaction %s is more than %s off the replica clock (%s)",
+			txTime.UTC(), tx.Uuid, skew, now.UTC())
+	}
+	return nil
+}
+
+// Returns the consensus metadata recording the sequence number a request was
+// committed at, for the block it executes into. The metadata is part of the
+// block hash, so it must not hold the view: replicas agree on the request at
+// a sequence number, but may execute it in different views around a view change.
+func consensusMetadata(seqNo uint64) []byte {
+	metadata, err := proto.Marshal(&pb.ConsensusMetadata{SequenceNumber: seqNo})
+	if err != nil {
+		logger.Error("Failed to marshal consensus metadata: %s", err)
+		return nil
+	}
+	return metadata
+}
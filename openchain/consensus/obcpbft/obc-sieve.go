@@ -23,6 +23,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/openblockchain/obc-peer/openchain/consensus"
@@ -48,6 +49,8 @@ type obcSieve struct {
 
 	queuedExec map[uint64]*Execute
 	queuedTx   [][]byte
+
+	timestampSkew time.Duration
 }
 
 func newObcSieve(id uint64, config *viper.Viper, stack consensus.Stack) *obcSieve {
@@ -55,6 +58,7 @@ func newObcSieve(id uint64, config *viper.Viper, stack consensus.Stack) *obcSiev
 	op.queuedExec = make(map[uint64]*Execute)
 	op.pbft = newPbftCore(id, config, op, stack)
 	op.pbft.sts.RegisterListener(op)
+	op.timestampSkew = loadTimestampSkew(config)
 
 	return op
 }
@@ -128,6 +132,11 @@ func (op *obcSieve) Drain() {
 	op.pbft.drain()
 }
 
+// Syncing reports whether the replica is catching up by state transfer
+func (op *obcSieve) Syncing() bool {
+	return op.pbft.syncing()
+}
+
 // called by pbft-core to multicast a message to all replicas
 func (op *obcSieve) broadcast(msgPayload []byte) {
 	svMsg := &SieveMessage{&SieveMessage_PbftMessage{msgPayload}}
@@ -210,12 +219,23 @@ func (op *obcSieve) recvRequest(txRaw []byte) {
 }
 
 func (op *obcSieve) processRequest() {
-	if len(op.queuedTx) == 0 || op.currentReq != "" {
+	if op.currentReq != "" {
 		return
 	}
 
-	txRaw := op.queuedTx[0]
-	op.queuedTx = op.queuedTx[1:]
+	var txRaw []byte
+	for txRaw == nil && len(op.queuedTx) > 0 {
+		txRaw = op.queuedTx[0]
+		op.queuedTx = op.queuedTx[1:]
+		// a request may have gone stale while queued
+		if err := op.validateRequest(txRaw); err != nil {
+			logger.Warning("Sieve primary %d dropping request: %s", op.id, err)
+			txRaw = nil
+		}
+	}
+	if txRaw == nil {
+		return
+	}
 	op.verifyStore = nil
 
 	exec := &Execute{
@@ -280,6 +300,16 @@ func (op *obcSieve) processExecute() {
 		return
 	}
 
+	if err := op.validateRequest(exec.Request); err != nil {
+		logger.Warning("Sieve replica %d ignoring execute from %d: %s", op.id, exec.ReplicaId, err)
+		// a correct primary does not send such requests; give it the
+		// request timeout to make progress, then move to the next view
+		if !op.pbft.timerActive {
+			op.pbft.startTimer(op.pbft.requestTimeout)
+		}
+		return
+	}
+
 	op.currentReq = base64.StdEncoding.EncodeToString(util.ComputeCryptoHash(exec.Request))
 	op.blockNumber++
 
@@ -387,6 +417,17 @@ func (op *obcSieve) verifyDset(inDset []*Verify) (dSet []*Verify, ok bool) {
 	return
 }
 
+// validateRequest checks whether a transaction request may be executed:
+// its timestamp must be within the allowed skew of our clock. Unlike
+// validate, it applies to requests before the verify set orders them.
+func (op *obcSieve) validateRequest(txRaw []byte) error {
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(txRaw, tx); err != nil {
+		return err
+	}
+	return checkTimestamp(tx, consensus.GetClock(op.stack).Now(), op.timestampSkew)
+}
+
 // validate checks whether the request is valid syntactically
 func (op *obcSieve) validate(rawReq []byte) error {
 	req := &SievePbftMessage{}
@@ -468,8 +509,9 @@ func (op *obcSieve) validateFlush(flush *Flush) error {
 }
 
 // called by pbft-core to execute an opaque request,
-// which is a totally-ordered `Decision`
-func (op *obcSieve) execute(raw []byte) {
+// which is a totally-ordered `Decision`. Sieve blocks are ordered by its own
+// epochs and block numbers, so the sequence number is not used.
+func (op *obcSieve) execute(seqNo uint64, raw []byte) {
 	// called without pbft lock held
 	op.pbft.lock()
 	defer op.pbft.unlock()
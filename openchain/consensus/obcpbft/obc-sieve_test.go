@@ -28,6 +28,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 
+	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -226,7 +227,7 @@ func TestSieveRequestHash(t *testing.T) {
 	net := makeTestnet(validatorCount, makeTestnetSieve)
 	defer net.close()
 
-	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Payload: make([]byte, 1000)}
+	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Payload: make([]byte, 1000), Timestamp: util.CreateUtcTimestamp()}
 	txPacked, _ := proto.Marshal(tx)
 	msg := &pb.OpenchainMessage{
 		Type:    pb.OpenchainMessage_CHAIN_TRANSACTION,
@@ -55,7 +55,7 @@ func init() {
 type innerStack interface {
 	broadcast(msgPayload []byte)
 	unicast(msgPayload []byte, receiverID uint64) (err error)
-	execute(txRaw []byte)
+	execute(seqNo uint64, txRaw []byte)
 	validate(txRaw []byte) error
 	viewChange(curView uint64)
 
@@ -94,7 +94,7 @@ type pbftCore struct {
 	hChkpts map[uint64]uint64                 // highest checkpoint sequence number observed for each replica
 	sts     *statetransfer.StateTransferState // Data structure which handles state transfer
 
-	newViewTimer       *time.Timer         // timeout triggering a view change
+	newViewTimer       consensus.Timer     // timeout triggering a view change
 	timerActive        bool                // is the timer running?
 	requestTimeout     time.Duration       // progress timeout for requests
 	newViewTimeout     time.Duration       // progress timeout for new views
@@ -109,6 +109,7 @@ type pbftCore struct {
 	reqStore        map[string]*Request   // track requests
 	certStore       map[msgID]*msgCert    // track quorum certificates for requests
 	checkpointStore map[Checkpoint]bool   // track checkpoints as set
+	chkptSignatures map[Checkpoint]string // block signatures carried by checkpoints, not yet added to the ledger
 	viewChangeStore map[vcidx]*ViewChange // track view-change messages
 	newViewStore    map[uint64]*NewView   // track last new-view we received or sent
 }
@@ -141,8 +142,9 @@ type stateTransferMetadata struct {
 }
 
 type blockState struct {
-	blockNumber uint64
-	blockHash   string
+	blockNumber  uint64
+	blockHash    string
+	headerDigest []byte // what the replicas sign for the block's quorum certificate
 }
 
 type sortableUint64Slice []uint64
@@ -209,6 +211,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, ledger con
 	instance.certStore = make(map[msgID]*msgCert)
 	instance.reqStore = make(map[string]*Request)
 	instance.checkpointStore = make(map[Checkpoint]bool)
+	instance.chkptSignatures = make(map[Checkpoint]string)
 	instance.chkpts = make(map[uint64]*blockState)
 	instance.viewChangeStore = make(map[vcidx]*ViewChange)
 	instance.pset = make(map[uint64]*ViewChange_PQ)
@@ -265,14 +268,13 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, ledger con
 	}
 
 	// create non-running timer XXX ugly
-	instance.newViewTimer = time.NewTimer(100 * time.Hour)
+	instance.newViewTimer = consensus.GetClock(ledger).AfterFunc(100*time.Hour, instance.newViewTimerExpired)
 	instance.newViewTimer.Stop()
 	instance.timerResetCount = 1
 	instance.lastNewViewTimeout = instance.newViewTimeout
 	instance.outstandingReqs = make(map[string]*Request)
 	instance.missingReqs = make(map[string]bool)
 
-	go instance.timerHander()
 	go instance.executeRoutine()
 
 	return instance
@@ -292,6 +294,14 @@ func (instance *pbftCore) unlock() {
 	//logger.Debug("Replica %d released lock", instance.id)
 }
 
+// syncing returns whether the replica is transferring state to catch up
+// with the network
+func (instance *pbftCore) syncing() bool {
+	instance.lock()
+	defer instance.unlock()
+	return instance.sts.InProgress()
+}
+
 // close tears down resources opened by newPbftCore
 func (instance *pbftCore) close() {
 	instance.lock()
@@ -312,27 +322,25 @@ func (instance *pbftCore) drain() {
 }
 
 // allow the view-change protocol to kick-off when the timer expires
-func (instance *pbftCore) timerHander() {
-	for {
-		select {
-		case <-instance.closed:
-			return
+func (instance *pbftCore) newViewTimerExpired() {
+	instance.lock()
+	defer instance.unlock()
+	select {
+	case <-instance.closed:
+		return
+	default:
+	}
 
-		case <-instance.newViewTimer.C:
-			instance.timerExpiredCount++
-			logger.Debug("Replica %d view change timer expired, waiting for lock with expired count %d", instance.id, instance.timerExpiredCount)
-			instance.lock()
-			// This is a nasty potential race, the timer could fire, but be blocked waiting for the lock
-			// meanwhile the system recovers via new view messages, and resets the timer, but this thread would still
-			// try to change views.
-			if instance.timerResetCount > instance.timerExpiredCount {
-				logger.Debug("Replica %d view change timer has expired count %d, but has reset count %d, so was reset before the view change could be sent", instance.id, instance.timerExpiredCount, instance.timerResetCount)
-			} else {
-				logger.Info("Replica %d view change timer expired, sending view change", instance.id)
-				instance.sendViewChange()
-			}
-			instance.unlock()
-		}
+	instance.timerExpiredCount++
+	logger.Debug("Replica %d view change timer expired with expired count %d", instance.id, instance.timerExpiredCount)
+	// This is a nasty potential race, the timer could fire, but be blocked waiting for the lock
+	// meanwhile the system recovers via new view messages, and resets the timer, but this thread would still
+	// try to change views.
+	if instance.timerResetCount > instance.timerExpiredCount {
+		logger.Debug("Replica %d view change timer has expired count %d, but has reset count %d, so was reset before the view change could be sent", instance.id, instance.timerExpiredCount, instance.timerResetCount)
+	} else {
+		logger.Info("Replica %d view change timer expired, sending view change", instance.id)
+		instance.sendViewChange()
 	}
 }
 
@@ -847,7 +855,7 @@ func (instance *pbftCore) executeOne(idx msgID) bool {
 
 		instance.executing = true
 		instance.unlock()
-		instance.consumer.execute(req.Payload)
+		instance.consumer.execute(idx.n, req.Payload)
 		instance.lock()
 		instance.executing = false
 		instance.notifyExec.Broadcast()
@@ -887,9 +895,22 @@ func (instance *pbftCore) executeOne(idx msgID) bool {
 			BlockNumber:    blockHeight - 1,
 			BlockHash:      blockHashAsString,
 		}
+
+		// The checkpoint carries our signature of the block header, so
+		// that the replicas can gather a quorum certificate for the block
+		headerDigest, err := lastBlock.GetHeaderDigest(chkpt.BlockNumber, blockHashBytes)
+		if nil != err {
+			logger.Error("Replica %d could not compute the header digest of block %d: %s", instance.id, chkpt.BlockNumber, err)
+		} else if signature, err := instance.consumer.sign(headerDigest); nil != err {
+			logger.Error("Replica %d could not sign block %d: %s", instance.id, chkpt.BlockNumber, err)
+		} else {
+			chkpt.BlockSignature = base64.StdEncoding.EncodeToString(signature)
+		}
+
 		instance.chkpts[instance.lastExec] = &blockState{
-			blockNumber: chkpt.BlockNumber,
-			blockHash:   chkpt.BlockHash,
+			blockNumber:  chkpt.BlockNumber,
+			blockHash:    chkpt.BlockHash,
+			headerDigest: headerDigest,
 		}
 		instance.innerBroadcast(&Message{&Message_Checkpoint{chkpt}}, true)
 	}
@@ -918,6 +939,12 @@ func (instance *pbftCore) moveWatermarks(h uint64) {
 		}
 	}
 
+	for testChkpt := range instance.chkptSignatures {
+		if testChkpt.SequenceNumber <= h {
+			delete(instance.chkptSignatures, testChkpt)
+		}
+	}
+
 	for n := range instance.pset {
 		if n <= h {
 			delete(instance.pset, n)
@@ -1042,7 +1069,15 @@ func (instance *pbftCore) recvCheckpoint(chkpt *Checkpoint) error {
 		return nil
 	}
 
-	instance.checkpointStore[*chkpt] = true
+	// The signature differs between replicas, it is kept out of the key so
+	// that a replica counts once towards the checkpoint quorum
+	key := *chkpt
+	key.BlockSignature = ""
+	if !instance.checkpointStore[key] && chkpt.BlockSignature != "" {
+		instance.chkptSignatures[key] = chkpt.BlockSignature
+	}
+	instance.checkpointStore[key] = true
+	instance.recordBlockSignatures(chkpt.SequenceNumber)
 
 	matching := 0
 	for testChkpt := range instance.checkpointStore {
@@ -1085,6 +1120,45 @@ func (instance *pbftCore) recvCheckpoint(chkpt *Checkpoint) error {
 	return instance.processNewView()
 }
 
+// recordBlockSignatures adds the block signatures carried by the checkpoints
+// for seqNo which agree with our own checkpoint to the quorum certificate of
+// the checkpointed block. Signatures received before we reach the checkpoint
+// are kept until we do. Only checkpointed blocks get a quorum certificate,
+// the blocks before them are certified through the hash chain.
+func (instance *pbftCore) recordBlockSignatures(seqNo uint64) {
+	state, ok := instance.chkpts[seqNo]
+	if !ok || nil == state.headerDigest {
+		return
+	}
+	for chkpt, encoded := range instance.chkptSignatures {
+		if chkpt.SequenceNumber != seqNo {
+			continue
+		}
+		delete(instance.chkptSignatures, chkpt)
+		if chkpt.BlockNumber != state.blockNumber || chkpt.BlockHash != state.blockHash {
+			logger.Warning("Replica %d ignoring signature of replica %d for a different block at seqNo %d", instance.id, chkpt.ReplicaId, seqNo)
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encoded)
+		if nil != err {
+			logger.Warning("Replica %d could not decode the block signature of replica %d: %s", instance.id, chkpt.ReplicaId, err)
+			continue
+		}
+		if err = instance.consumer.verify(chkpt.ReplicaId, signature, state.headerDigest); nil != err {
+			logger.Warning("Replica %d received an invalid signature of block %d from replica %d: %s", instance.id, state.blockNumber, chkpt.ReplicaId, err)
+			continue
+		}
+		handle, err := getValidatorHandle(chkpt.ReplicaId)
+		if nil != err {
+			logger.Warning("Replica %d received a checkpoint from replica %d which does not map to a peer: %s", instance.id, chkpt.ReplicaId, err)
+			continue
+		}
+		if err = instance.ledger.AddBlockSignature(state.blockNumber, &protos.BlockSignature{Validator: handle.Name, Signature: signature}); nil != err {
+			logger.Error("Replica %d could not add the signature of replica %d to block %d: %s", instance.id, chkpt.ReplicaId, state.blockNumber, err)
+		}
+	}
+}
+
 // used in view-change to fetch missing assigned, non-checkpointed requests
 func (instance *pbftCore) fetchRequests() (err error) {
 	var msg *Message
@@ -22,7 +22,6 @@ package obcpbft
 import (
 	"encoding/base64"
 	"fmt"
-	gp "google/protobuf"
 	"math/rand"
 	"strconv"
 	"sync"
@@ -137,7 +136,7 @@ func (inst *instance) validate(payload []byte) error {
 	return nil
 }
 
-func (inst *instance) execute(payload []byte) {
+func (inst *instance) execute(seqNo uint64, payload []byte) {
 
 	tx := &pb.Transaction{
 		Payload: payload,
@@ -243,6 +242,9 @@ func (inst *instance) HashBlock(block *pb.Block) ([]byte, error) {
 func (inst *instance) PutBlock(blockNumber uint64, block *pb.Block) error {
 	return inst.ledger.PutBlock(blockNumber, block)
 }
+func (inst *instance) AddBlockSignature(blockNumber uint64, signature *pb.BlockSignature) error {
+	return inst.ledger.AddBlockSignature(blockNumber, signature)
+}
 func (inst *instance) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
 	return inst.ledger.ApplyStateDelta(id, delta)
 }
@@ -415,9 +417,8 @@ func (net *testnet) close() {
 
 // Create a message of type `OpenchainMessage_CHAIN_TRANSACTION`
 func createOcMsgWithChainTx(iter int64) (msg *pb.OpenchainMessage) {
-	txTime := &gp.Timestamp{Seconds: iter, Nanos: 0}
 	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW,
-		Timestamp: txTime,
+		Timestamp: util.CreateUtcTimestamp(),
 		Payload:   []byte(fmt.Sprint(iter)),
 	}
 	txPacked, _ := proto.Marshal(tx)
@@ -277,6 +277,43 @@ func TestCheckpoint(t *testing.T) {
 	}
 }
 
+func TestCheckpointQuorumCertificate(t *testing.T) {
+	validatorCount := 4
+	net := makeTestnet(validatorCount, func(inst *instance) {
+		makeTestnetPbftCore(inst)
+		inst.pbft.K = 2
+	})
+	defer net.close()
+
+	for i := int64(1); i <= 2; i++ {
+		tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_NEW, Timestamp: &gp.Timestamp{Seconds: i}}
+		txPacked, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatalf("Failed to marshal TX block: %s", err)
+		}
+		msg := &Message{&Message_Request{&Request{Payload: txPacked, ReplicaId: uint64(generateBroadcaster(validatorCount))}}}
+		net.replicas[0].pbft.recvMsgSync(msg, msg.GetRequest().ReplicaId)
+		net.process()
+	}
+
+	for _, inst := range net.replicas {
+		signatures := inst.ledger.(*MockLedger).signatures[2]
+		if len(signatures) < inst.pbft.intersectionQuorum() {
+			t.Errorf("Replica %d gathered %d signatures of the checkpointed block, expected a quorum of %d", inst.id, len(signatures), inst.pbft.intersectionQuorum())
+		}
+		signed := make(map[string]bool)
+		for _, s := range signatures {
+			if signed[s.Validator] {
+				t.Errorf("Replica %d recorded the signature of %s twice", inst.id, s.Validator)
+			}
+			signed[s.Validator] = true
+		}
+		if len(inst.pbft.chkptSignatures) != 0 {
+			t.Errorf("Replica %d kept %d block signatures past the checkpoint", inst.id, len(inst.pbft.chkptSignatures))
+		}
+	}
+}
+
 func TestLostPrePrepare(t *testing.T) {
 	validatorCount := 4
 	net := makeTestnet(validatorCount, makeTestnetPbftCore)
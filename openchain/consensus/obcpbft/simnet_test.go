@@ -0,0 +1,153 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package obcpbft
+
+import (
+	"bytes"
+	"fmt"
+	gp "google/protobuf"
+	"testing"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/consensus"
+	"github.com/openblockchain/obc-peer/openchain/consensus/simnet"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func makeSimnetClassic(N int, K int) *simnet.Network {
+	return simnet.New(N, func(stack consensus.Stack) consensus.Consenter {
+		config := loadConfig()
+		config.Set("general.N", N)
+		config.Set("general.f", (N-1)/3)
+		config.Set("general.K", K)
+		handle, _, _ := stack.GetNetworkHandles()
+		id, _ := getValidatorID(handle)
+		return newObcClassic(id, config, stack)
+	})
+}
+
+func simnetTx(i int) *pb.Transaction {
+	return &pb.Transaction{Uuid: fmt.Sprintf("tx%d", i), Payload: []byte(fmt.Sprintf("value%d", i))}
+}
+
+func TestSimnetViewChange(t *testing.T) {
+	net := makeSimnetClassic(4, 10)
+	defer net.Close()
+
+	// The primary of view 0 fails, so the request cannot make progress
+	net.Isolate(0)
+	if err := net.Submit(1, simnetTx(1)); err != nil {
+		t.Fatalf("Error submitting transaction: %s", err)
+	}
+	net.Process()
+	for id := 1; id < 4; id++ {
+		if height, _ := net.Nodes[id].GetBlockchainSize(); height != 1 {
+			t.Fatalf("Expected vp%d not to execute without a primary, got height %d", id, height)
+		}
+	}
+
+	// Once the request timeout elapses the backups move to view 1, whose
+	// primary orders the outstanding request
+	net.Advance(2 * time.Second)
+	for id := 1; id < 4; id++ {
+		pbft := net.Nodes[id].Consenter.(*obcClassic).pbft
+		if pbft.view != 1 || !pbft.activeView {
+			t.Fatalf("Expected vp%d to be active in view 1, got view %d (active %v)", id, pbft.view, pbft.activeView)
+		}
+		if value := net.Nodes[id].GetState("tx1"); string(value) != "value1" {
+			t.Fatalf("Expected vp%d to have executed tx1 in the new view, got value %q", id, value)
+		}
+	}
+}
+
+func TestSimnetStateTransfer(t *testing.T) {
+	net := makeSimnetClassic(4, 2)
+	defer net.Close()
+
+	// vp3 misses enough requests for its peers to move their watermarks
+	// past anything it could still catch up on by executing requests
+	net.Isolate(3)
+	for i := 0; i < 6; i++ {
+		net.Submit(0, simnetTx(i))
+		net.Process()
+	}
+	net.Heal()
+
+	// State transfer runs in the background, and needs a checkpoint taken
+	// after it started to validate the blocks it fetches against
+	i := 6
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		net.Submit(0, simnetTx(i))
+		net.Process()
+		i++
+		target, _ := net.Nodes[0].GetBlockchainSize()
+		targetHash, _ := net.Nodes[0].GetCurrentStateHash()
+		height, _ := net.Nodes[3].GetBlockchainSize()
+		if hash, _ := net.Nodes[3].GetCurrentStateHash(); height == target && bytes.Equal(hash, targetHash) {
+			break
+		}
+		if time.Now().After(deadline) {
+			height, _ := net.Nodes[3].GetBlockchainSize()
+			t.Fatalf("Expected vp3 to catch up to height %d, got %d", target, height)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("tx%d", i)
+		if value := net.Nodes[3].GetState(key); string(value) != fmt.Sprintf("value%d", i) {
+			t.Fatalf("Expected vp3 to have recovered %s, got value %q", key, value)
+		}
+	}
+}
+
+func TestSimnetTimestampSkew(t *testing.T) {
+	net := makeSimnetClassic(4, 10)
+	defer net.Close()
+	net.Advance(time.Hour)
+
+	// Replicas refuse to order a transaction stamped an hour ago
+	stale := simnetTx(1)
+	stale.Timestamp = &gp.Timestamp{Seconds: 0}
+	if err := net.Submit(0, stale); err != nil {
+		t.Fatalf("Error submitting transaction: %s", err)
+	}
+	net.Process()
+	for id := 0; id < 4; id++ {
+		if height, _ := net.Nodes[id].GetBlockchainSize(); height != 1 {
+			t.Fatalf("Expected vp%d not to execute the stale transaction, got height %d", id, height)
+		}
+	}
+
+	// A transaction stamped with the current time is ordered
+	if err := net.Submit(0, simnetTx(2)); err != nil {
+		t.Fatalf("Error submitting transaction: %s", err)
+	}
+	net.Process()
+	for id := 0; id < 4; id++ {
+		if value := net.Nodes[id].GetState("tx2"); string(value) != "value2" {
+			t.Fatalf("Expected vp%d to have executed tx2, got value %q", id, value)
+		}
+		if value := net.Nodes[id].GetState("tx1"); value != nil {
+			t.Fatalf("Expected vp%d not to have executed tx1, got value %q", id, value)
+		}
+	}
+}
@@ -81,6 +81,8 @@ type MockLedger struct {
 	deltaID       interface{}
 	preDeltaValue uint64
 
+	signatures map[uint64][]*protos.BlockSignature
+
 	inst *instance // To support the ExecTx stuff
 }
 
@@ -447,6 +449,21 @@ func (mock *MockLedger) PutBlock(blockNumber uint64, block *protos.Block) error
 	return nil
 }
 
+func (mock *MockLedger) AddBlockSignature(blockNumber uint64, signature *protos.BlockSignature) error {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+	if mock.signatures == nil {
+		mock.signatures = make(map[uint64][]*protos.BlockSignature)
+	}
+	for _, s := range mock.signatures[blockNumber] {
+		if s.Validator == signature.Validator {
+			return nil
+		}
+	}
+	mock.signatures[blockNumber] = append(mock.signatures[blockNumber], signature)
+	return nil
+}
+
 func (mock *MockLedger) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
 	mock.mutex.Lock()
 	defer func() {
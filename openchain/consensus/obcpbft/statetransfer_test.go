@@ -171,6 +171,112 @@ func TestCatchupSyncBlocksErrors(t *testing.T) {
 	}
 }
 
+func TestCatchupParallelBlockRanges(t *testing.T) {
+	rols, mrls, dps := createRemoteLedgers(1, 3)
+
+	// Record which peers serve block requests, slowing them down so no peer serves every range
+	var lock sync.Mutex
+	served := make(map[protos.PeerID]bool)
+	filter := func(request mockRequest, peerID *protos.PeerID) mockResponse {
+		if request == SyncBlocks {
+			lock.Lock()
+			served[*peerID] = true
+			lock.Unlock()
+			time.Sleep(10 * time.Millisecond)
+		}
+		return Normal
+	}
+
+	// Test from blockheight of 1, with valid genesis block, syncing several ranges of blocksperrequest blocks
+	ml := NewMockLedger(rols, filter)
+	ml.PutBlock(0, SimpleGetBlock(0))
+
+	sts := newTestStateTransfer(ml, dps)
+	defer sts.Stop()
+	if err := executeStateTransfer(sts, ml, 70, 75, mrls, dps); nil != err {
+		t.Fatalf("ParallelBlockRanges case: %s", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(served) < 2 {
+		t.Fatalf("Expected the block ranges to be fetched from several peers, only %d served blocks", len(served))
+	}
+}
+
+func TestCatchupParallelBlockRangesWindow(t *testing.T) {
+	rols, mrls, dps := createRemoteLedgers(1, 3)
+
+	// Count the block requests against the ranges of blocksperrequest (20) blocks already committed,
+	// at most twice as many ranges as the 3 peers may be fetched ahead of them while the first range
+	// of one peer is slow to come
+	var ml *MockLedger
+	var lock sync.Mutex
+	requests, ahead := 0, 0
+	slow := true
+	filter := func(request mockRequest, peerID *protos.PeerID) mockResponse {
+		if request != SyncBlocks {
+			return Normal
+		}
+		committed := 0
+		for n := uint64(1); n <= 400; n++ {
+			if block, _ := ml.GetBlock(n); block != nil {
+				committed++
+			}
+		}
+		lock.Lock()
+		requests++
+		if requests-committed/20 > ahead {
+			ahead = requests - committed/20
+		}
+		delay := 5 * time.Millisecond
+		if slow && peerID.Name == "Peer 1" {
+			slow = false
+			delay = 200 * time.Millisecond
+		}
+		lock.Unlock()
+		time.Sleep(delay)
+		return Normal
+	}
+
+	ml = NewMockLedger(rols, filter)
+	ml.PutBlock(0, SimpleGetBlock(0))
+
+	sts := newTestStateTransfer(ml, dps)
+	defer sts.Stop()
+	if err := executeStateTransfer(sts, ml, 400, 405, mrls, dps); nil != err {
+		t.Fatalf("ParallelBlockRangesWindow case: %s", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if ahead > 6 {
+		t.Fatalf("Expected at most 6 block ranges to be fetched ahead of the ones committed, got %d", ahead)
+	}
+}
+
+func TestCatchupParallelBlockRangesErrors(t *testing.T) {
+	for _, failureType := range []mockResponse{Timeout, Corrupt} {
+		rols, mrls, dps := createRemoteLedgers(1, 3)
+
+		// Test from blockheight of 1 with valid genesis block
+		// Timeouts of 10 milliseconds
+		filter, result := makeSimpleFilter(SyncBlocks, failureType)
+		ml := NewMockLedger(rols, filter)
+
+		ml.PutBlock(0, SimpleGetBlock(0))
+		sts := newTestStateTransfer(ml, dps)
+		defer sts.Stop()
+		sts.BlockRequestTimeout = 10 * time.Millisecond
+		if err := executeStateTransfer(sts, ml, 70, 75, mrls, dps); nil != err {
+			t.Fatalf("ParallelBlockRangesErrors %s case: %s", failureType, err)
+		}
+		if !result.wasTriggered() {
+			t.Fatalf("ParallelBlockRangesErrors case never simulated a %v", failureType)
+		}
+	}
+}
+
 // Added for issue #676, for situations all potential sync targets fail, and sync is re-initiated, causing panic
 func TestCatchupSyncBlocksAllErrors(t *testing.T) {
 	blockNumber := uint64(10)
@@ -0,0 +1,111 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package consensus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// MaxPriority returns the highest priority the priority policy in
+// peer.priority allows tx: the priority of configuration and key rotation
+// transactions or the priority of the invoked chaincode, whichever is
+// higher, bounded by peer.priority.max. Other transactions are bulk
+// workload of priority 0.
+func MaxPriority(tx *pb.Transaction) uint32 {
+	var allowed uint32
+	if tx.Type == pb.Transaction_CHAINCODE_CONFIG || tx.Type == pb.Transaction_CHAINCODE_KEY_ROTATION {
+		allowed = uint32(viper.GetInt("peer.priority.config"))
+	}
+	// The chaincode ID of confidential transactions is encrypted, they get
+	// the priority of their type only
+	cID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, cID); err == nil && cID.Name != "" {
+		// viper lower-cases keys
+		if value, ok := viper.GetStringMap("peer.priority.chaincodes")[strings.ToLower(cID.Name)]; ok {
+			if priority := uint32(cast.ToInt(value)); priority > allowed {
+				allowed = priority
+			}
+		}
+	}
+	if max := uint32(viper.GetInt("peer.priority.max")); allowed > max {
+		allowed = max
+	}
+	return allowed
+}
+
+// Priority returns the priority consenters queue tx with, the priority set
+// by the submitter bounded by MaxPriority
+func Priority(tx *pb.Transaction) uint32 {
+	if tx.Priority == 0 {
+		return 0
+	}
+	if max := MaxPriority(tx); tx.Priority > max {
+		return max
+	}
+	return tx.Priority
+}
+
+// CheckPriority returns an error if tx asks for a priority above the one
+// the priority policy allows
+func CheckPriority(tx *pb.Transaction) error {
+	if tx.Priority == 0 {
+		return nil
+	}
+	if max := MaxPriority(tx); tx.Priority > max {
+		return fmt.Errorf("Priority %d of transaction %s exceeds the highest priority %d allowed by the priority policy", tx.Priority, tx.Uuid, max)
+	}
+	return nil
+}
+
+// SortByPriority orders txs by decreasing priority, keeping the order of
+// the transactions of the same priority
+func SortByPriority(txs []*pb.Transaction) {
+	sorted := &byPriority{txs: txs, priorities: make([]uint32, len(txs))}
+	for i, tx := range txs {
+		sorted.priorities[i] = Priority(tx)
+	}
+	sort.Stable(sorted)
+}
+
+type byPriority struct {
+	txs        []*pb.Transaction
+	priorities []uint32
+}
+
+func (s *byPriority) Len() int {
+	return len(s.txs)
+}
+
+func (s *byPriority) Less(i, j int) bool {
+	return s.priorities[i] > s.priorities[j]
+}
+
+func (s *byPriority) Swap(i, j int) {
+	s.txs[i], s.txs[j] = s.txs[j], s.txs[i]
+	s.priorities[i], s.priorities[j] = s.priorities[j], s.priorities[i]
+}
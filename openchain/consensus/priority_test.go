@@ -0,0 +1,105 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package consensus
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func setPriorityPolicy(max, config int, chaincodes map[string]interface{}) {
+	viper.Set("peer.priority.max", max)
+	viper.Set("peer.priority.config", config)
+	viper.Set("peer.priority.chaincodes", chaincodes)
+}
+
+func newPriorityTx(t *testing.T, typ pb.Transaction_Type, name string, priority uint32) *pb.Transaction {
+	cID, err := proto.Marshal(&pb.ChaincodeID{Name: name})
+	if err != nil {
+		t.Fatalf("Error marshalling chaincode ID: %s", err)
+	}
+	return &pb.Transaction{Type: typ, ChaincodeID: cID, Uuid: name, Priority: priority}
+}
+
+func TestMaxPriority(t *testing.T) {
+	setPriorityPolicy(2, 1, map[string]interface{}{"revocations": 2, "bulk": 5})
+	defer setPriorityPolicy(0, 0, nil)
+
+	tests := []struct {
+		tx       *pb.Transaction
+		expected uint32
+	}{
+		{newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "other", 0), 0},
+		{newPriorityTx(t, pb.Transaction_CHAINCODE_CONFIG, "netconfig", 0), 1},
+		{newPriorityTx(t, pb.Transaction_CHAINCODE_KEY_ROTATION, "other", 0), 1},
+		{newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "Revocations", 0), 2},
+		{newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "bulk", 0), 2},
+		{&pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, ChaincodeID: []byte("encrypted")}, 0},
+	}
+	for i, test := range tests {
+		if max := MaxPriority(test.tx); max != test.expected {
+			t.Errorf("Test %d: expected highest priority %d, got %d", i, test.expected, max)
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	setPriorityPolicy(2, 2, map[string]interface{}{"revocations": 1})
+	defer setPriorityPolicy(0, 0, nil)
+
+	tx := newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "revocations", 2)
+	if priority := Priority(tx); priority != 1 {
+		t.Fatalf("Expected the priority to be lowered to 1, got %d", priority)
+	}
+	if err := CheckPriority(tx); err == nil {
+		t.Fatal("Expected a priority above the policy to be rejected")
+	}
+	tx.Priority = 1
+	if err := CheckPriority(tx); err != nil {
+		t.Fatalf("Expected a priority within the policy to be accepted: %s", err)
+	}
+	if priority := Priority(newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "other", 3)); priority != 0 {
+		t.Fatalf("Expected transactions without a policy to get priority 0, got %d", priority)
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	setPriorityPolicy(2, 2, map[string]interface{}{"revocations": 1})
+	defer setPriorityPolicy(0, 0, nil)
+
+	txs := []*pb.Transaction{
+		newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "a", 0),
+		newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "revocations", 1),
+		newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "b", 2),
+		newPriorityTx(t, pb.Transaction_CHAINCODE_CONFIG, "netconfig", 2),
+		newPriorityTx(t, pb.Transaction_CHAINCODE_EXECUTE, "c", 0),
+	}
+	SortByPriority(txs)
+	expected := []string{"netconfig", "revocations", "a", "b", "c"}
+	for i, tx := range txs {
+		if tx.Uuid != expected[i] {
+			t.Fatalf("Expected transaction %s at position %d, got %s", expected[i], i, tx.Uuid)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package simnet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/consensus"
+)
+
+// Clock is a simulated clock implementing consensus.Clock. Time only moves
+// when Advance is called, and the callbacks of expired timers run on the
+// goroutine calling Advance, in deadline order.
+type Clock struct {
+	lock    sync.Mutex
+	now     time.Time
+	seq     uint64
+	pending map[*timer]struct{}
+}
+
+type timer struct {
+	clock    *Clock
+	f        func()
+	deadline time.Time
+	seq      uint64 // breaks ties between timers with the same deadline
+}
+
+// NewClock creates a simulated clock starting at the Unix epoch
+func NewClock() *Clock {
+	return &Clock{
+		now:     time.Unix(0, 0).UTC(),
+		pending: make(map[*timer]struct{}),
+	}
+}
+
+// Now returns the current simulated time
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f to be called once the clock has advanced by d
+func (c *Clock) AfterFunc(d time.Duration, f func()) consensus.Timer {
+	t := &timer{clock: c, f: f}
+	c.lock.Lock()
+	c.schedule(t, d)
+	c.lock.Unlock()
+	return t
+}
+
+// Pending returns the number of timers which have not fired or been stopped
+func (c *Clock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.pending)
+}
+
+// Advance moves the clock forward by d, firing every timer whose deadline
+// falls within that period. Timers scheduled by the callbacks themselves
+// fire too if their deadline is reached.
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	target := c.now.Add(d)
+	c.lock.Unlock()
+
+	for {
+		c.lock.Lock()
+		next := c.next(target)
+		if next == nil {
+			c.now = target
+			c.lock.Unlock()
+			return
+		}
+		delete(c.pending, next)
+		if next.deadline.After(c.now) {
+			c.now = next.deadline
+		}
+		c.lock.Unlock()
+
+		next.f()
+	}
+}
+
+// next returns the earliest pending timer due no later than target
+func (c *Clock) next(target time.Time) *timer {
+	var next *timer
+	for t := range c.pending {
+		if t.deadline.After(target) {
+			continue
+		}
+		if next == nil || t.deadline.Before(next.deadline) || (t.deadline.Equal(next.deadline) && t.seq < next.seq) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (c *Clock) schedule(t *timer, d time.Duration) {
+	c.seq++
+	t.seq = c.seq
+	t.deadline = c.now.Add(d)
+	c.pending[t] = struct{}{}
+}
+
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	_, active := t.clock.pending[t]
+	t.clock.schedule(t, d)
+	return active
+}
+
+func (t *timer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	_, active := t.clock.pending[t]
+	delete(t.clock.pending, t)
+	return active
+}
@@ -0,0 +1,357 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package simnet
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// ChaincodeID is the chaincode under which simulated transactions write
+const ChaincodeID = "simnet"
+
+// Ledger is an in-memory implementation of consensus.Executor and
+// consensus.Ledger. Executing a transaction stores its payload in the state
+// under the transaction's UUID, so that every replica executing the same
+// transactions in the same order ends up with the same state hash.
+type Ledger struct {
+	lock   sync.Mutex
+	blocks []*pb.Block
+	deltas []*statemgmt.StateDelta // state delta committed by each block
+	state  map[string][]byte
+
+	batchID    interface{}
+	batchTxs   []*pb.Transaction
+	batchDelta *statemgmt.StateDelta
+
+	deltaID  interface{}
+	preDelta map[string][]byte
+
+	signatures map[uint64][]*pb.BlockSignature // quorum certificate of each block
+}
+
+// NewLedger creates a ledger holding only the genesis block
+func NewLedger() *Ledger {
+	l := &Ledger{state: make(map[string][]byte)}
+	l.blocks = []*pb.Block{&pb.Block{}}
+	l.deltas = []*statemgmt.StateDelta{statemgmt.NewStateDelta()}
+	return l
+}
+
+// GetState returns the value written under key, or nil
+func (l *Ledger) GetState(key string) []byte {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.state[key]
+}
+
+// =============================================================================
+// consensus.Executor
+// =============================================================================
+
+// BeginTxBatch starts a batch of transactions identified by id
+func (l *Ledger) BeginTxBatch(id interface{}) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.batchID != nil {
+		return fmt.Errorf("Tx batch %v is already active", l.batchID)
+	}
+	l.batchID = id
+	l.batchTxs = nil
+	l.batchDelta = statemgmt.NewStateDelta()
+	return nil
+}
+
+// ExecTxs executes txs as part of the batch identified by id
+func (l *Ledger) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !reflect.DeepEqual(l.batchID, id) {
+		return nil, fmt.Errorf("Invalid batch ID %v", id)
+	}
+	var result []byte
+	for _, tx := range txs {
+		l.batchDelta.Set(ChaincodeID, tx.Uuid, tx.Payload, l.valueInBatch(tx.Uuid))
+		result = append(result, tx.Payload...)
+	}
+	l.batchTxs = append(l.batchTxs, txs...)
+	return result, nil
+}
+
+// CommitTxBatch appends the block resulting from the batch identified by id
+func (l *Ledger) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	block, err := l.batchBlock(id, metadata)
+	if err != nil {
+		return nil, err
+	}
+	applyDelta(l.state, l.batchDelta)
+	l.blocks = append(l.blocks, block)
+	l.deltas = append(l.deltas, l.batchDelta)
+	l.batchID = nil
+	l.batchTxs = nil
+	l.batchDelta = nil
+	return block, nil
+}
+
+// PreviewCommitTxBatch returns the block CommitTxBatch would append
+func (l *Ledger) PreviewCommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.batchBlock(id, metadata)
+}
+
+// RollbackTxBatch discards the batch identified by id
+func (l *Ledger) RollbackTxBatch(id interface{}) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !reflect.DeepEqual(l.batchID, id) {
+		return fmt.Errorf("Invalid batch ID %v", id)
+	}
+	l.batchID = nil
+	l.batchTxs = nil
+	l.batchDelta = nil
+	return nil
+}
+
+func (l *Ledger) valueInBatch(key string) []byte {
+	if updated := l.batchDelta.Get(ChaincodeID, key); updated != nil {
+		return updated.GetValue()
+	}
+	return l.state[key]
+}
+
+func (l *Ledger) batchBlock(id interface{}, metadata []byte) (*pb.Block, error) {
+	if !reflect.DeepEqual(l.batchID, id) {
+		return nil, fmt.Errorf("Invalid batch ID %v", id)
+	}
+	previousBlockHash, err := l.blocks[len(l.blocks)-1].GetHash()
+	if err != nil {
+		return nil, err
+	}
+	state := copyState(l.state)
+	applyDelta(state, l.batchDelta)
+	block := pb.NewBlock(l.batchTxs, metadata)
+	block.PreviousBlockHash = previousBlockHash
+	block.StateHash = stateHash(state)
+	return block, nil
+}
+
+// =============================================================================
+// consensus.Ledger
+// =============================================================================
+
+// GetBlock returns the block with number id
+func (l *Ledger) GetBlock(id uint64) (*pb.Block, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if id >= uint64(len(l.blocks)) || l.blocks[id] == nil {
+		return nil, fmt.Errorf("Block %d not found", id)
+	}
+	return l.blocks[id], nil
+}
+
+// GetCurrentStateHash returns the hash of the current state
+func (l *Ledger) GetCurrentStateHash() ([]byte, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return stateHash(l.state), nil
+}
+
+// GetBlockchainSize returns the number of blocks, including the genesis block
+func (l *Ledger) GetBlockchainSize() (uint64, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return uint64(len(l.blocks)), nil
+}
+
+// HashBlock returns the hash of block
+func (l *Ledger) HashBlock(block *pb.Block) ([]byte, error) {
+	return block.GetHash()
+}
+
+// VerifyBlockchain checks the hash chain walking down from block high to
+// block low, returning the first block whose PreviousBlockHash does not
+// match the hash of its predecessor, or 0 if the chain is intact
+func (l *Ledger) VerifyBlockchain(high, low uint64) (uint64, error) {
+	for i := high; i > low; i-- {
+		block, err := l.GetBlock(i)
+		if err != nil {
+			return i, err
+		}
+		previous, err := l.GetBlock(i - 1)
+		if err != nil {
+			return i - 1, err
+		}
+		previousHash, err := previous.GetHash()
+		if err != nil {
+			return i - 1, err
+		}
+		if !bytes.Equal(previousHash, block.PreviousBlockHash) {
+			return i, nil
+		}
+	}
+	return 0, nil
+}
+
+// PutBlock stores block at blockNumber, as done during state transfer
+func (l *Ledger) PutBlock(blockNumber uint64, block *pb.Block) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for uint64(len(l.blocks)) <= blockNumber {
+		l.blocks = append(l.blocks, nil)
+		l.deltas = append(l.deltas, nil)
+	}
+	l.blocks[blockNumber] = block
+	return nil
+}
+
+// AddBlockSignature records signature in the quorum certificate of the block
+// at blockNumber
+func (l *Ledger) AddBlockSignature(blockNumber uint64, signature *pb.BlockSignature) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.signatures == nil {
+		l.signatures = make(map[uint64][]*pb.BlockSignature)
+	}
+	for _, s := range l.signatures[blockNumber] {
+		if s.Validator == signature.Validator {
+			return nil
+		}
+	}
+	l.signatures[blockNumber] = append(l.signatures[blockNumber], signature)
+	return nil
+}
+
+// BlockSignatures returns the signatures collected for the block at
+// blockNumber
+func (l *Ledger) BlockSignatures(blockNumber uint64) []*pb.BlockSignature {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.signatures[blockNumber]
+}
+
+// ApplyStateDelta applies delta to the state. It takes effect immediately
+// and can be undone with RollbackStateDelta until CommitStateDelta is called.
+func (l *Ledger) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.deltaID != nil && !reflect.DeepEqual(l.deltaID, id) {
+		return fmt.Errorf("A different state delta is already being applied")
+	}
+	if l.deltaID == nil {
+		l.deltaID = id
+		l.preDelta = copyState(l.state)
+	}
+	applyDelta(l.state, delta)
+	return nil
+}
+
+// CommitStateDelta makes the deltas applied under id permanent
+func (l *Ledger) CommitStateDelta(id interface{}) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !reflect.DeepEqual(l.deltaID, id) {
+		return fmt.Errorf("Invalid state delta ID %v", id)
+	}
+	l.deltaID = nil
+	l.preDelta = nil
+	return nil
+}
+
+// RollbackStateDelta undoes the deltas applied under id
+func (l *Ledger) RollbackStateDelta(id interface{}) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if !reflect.DeepEqual(l.deltaID, id) {
+		return fmt.Errorf("Invalid state delta ID %v", id)
+	}
+	l.state = l.preDelta
+	l.deltaID = nil
+	l.preDelta = nil
+	return nil
+}
+
+// EmptyState removes every key from the state
+func (l *Ledger) EmptyState() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.state = make(map[string][]byte)
+	return nil
+}
+
+// blockDelta returns the state delta committed by a block. Blocks received
+// through state transfer have no delta recorded.
+func (l *Ledger) blockDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if blockNumber >= uint64(len(l.deltas)) || l.deltas[blockNumber] == nil {
+		return nil, fmt.Errorf("State delta for block %d not found", blockNumber)
+	}
+	return l.deltas[blockNumber], nil
+}
+
+// snapshot returns the current state as a single delta, along with the
+// number of the block it corresponds to
+func (l *Ledger) snapshot() (*statemgmt.StateDelta, uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return stateToDelta(l.state), uint64(len(l.blocks) - 1)
+}
+
+func applyDelta(state map[string][]byte, delta *statemgmt.StateDelta) {
+	for key, updated := range delta.GetUpdates(ChaincodeID) {
+		value := updated.GetValue()
+		if delta.RollBackwards {
+			value = updated.GetPreviousValue()
+		}
+		if value == nil {
+			delete(state, key)
+		} else {
+			state[key] = value
+		}
+	}
+}
+
+func copyState(state map[string][]byte) map[string][]byte {
+	c := make(map[string][]byte, len(state))
+	for key, value := range state {
+		c[key] = value
+	}
+	return c
+}
+
+func stateToDelta(state map[string][]byte) *statemgmt.StateDelta {
+	delta := statemgmt.NewStateDelta()
+	for key, value := range state {
+		delta.Set(ChaincodeID, key, value, nil)
+	}
+	return delta
+}
+
+func stateHash(state map[string][]byte) []byte {
+	return stateToDelta(state).ComputeCryptoHash()
+}
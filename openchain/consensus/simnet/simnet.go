@@ -0,0 +1,387 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package simnet wires a number of in-process validating peers into a
+// simulated network, so that multi-peer consensus behaviors such as view
+// changes and state transfer can be exercised in a single test binary.
+//
+// Every node has an in-memory Ledger and shares the network's simulated
+// Clock. Consensus messages are queued in the order they are sent and are
+// only delivered when the test calls Step or Process, and timers only fire
+// when the test advances the clock, so a test controls both the message
+// interleaving and the passage of time.
+package simnet
+
+import (
+	"fmt"
+	gp "google/protobuf"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+
+	"github.com/openblockchain/obc-peer/openchain/consensus"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var logger *logging.Logger // package-level logger
+
+func init() {
+	logger = logging.MustGetLogger("consensus/simnet")
+}
+
+// Message is an OpenchainMessage in flight from one node to another
+type Message struct {
+	From int
+	To   int
+	Msg  *pb.OpenchainMessage
+}
+
+// Filter is consulted before a message is delivered; returning false drops it
+type Filter func(msg *Message) bool
+
+// Network is a set of nodes connected by a simulated transport
+type Network struct {
+	Clock *Clock
+	Nodes []*Node
+
+	lock   sync.Mutex
+	queue  []*Message
+	filter Filter
+	cut    map[int]bool // isolated nodes
+}
+
+// Node is a validating peer of a Network. It implements consensus.Stack on
+// top of its Ledger and the simulated transport, and consensus.Clock on top
+// of the network's Clock.
+type Node struct {
+	*Ledger
+	ID        int
+	Handle    *pb.PeerID
+	Consenter consensus.Consenter
+
+	net *Network
+}
+
+// New creates a network of n nodes named vp0 through vp<n-1>, each running
+// the consenter returned by newConsenter
+func New(n int, newConsenter func(stack consensus.Stack) consensus.Consenter) *Network {
+	net := &Network{
+		Clock: NewClock(),
+		cut:   make(map[int]bool),
+	}
+	for i := 0; i < n; i++ {
+		net.Nodes = append(net.Nodes, &Node{
+			Ledger: NewLedger(),
+			ID:     i,
+			Handle: &pb.PeerID{Name: fmt.Sprintf("vp%d", i)},
+			net:    net,
+		})
+	}
+	// The consenters are created once every node exists, as they may
+	// inspect the network on construction
+	for _, node := range net.Nodes {
+		node.Consenter = newConsenter(node)
+	}
+	return net
+}
+
+// Submit hands tx to the consenter of node id, as a client connected to that
+// node would. A tx without a timestamp is stamped with the simulated time.
+func (net *Network) Submit(id int, tx *pb.Transaction) error {
+	if tx.Timestamp == nil {
+		now := net.Clock.Now()
+		tx.Timestamp = &gp.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())}
+	}
+	payload, err := proto.Marshal(tx)
+	if err != nil {
+		return err
+	}
+	node := net.Nodes[id]
+	return node.Consenter.RecvMsg(&pb.OpenchainMessage{Type: pb.OpenchainMessage_CHAIN_TRANSACTION, Payload: payload}, node.Handle)
+}
+
+// SetFilter installs a filter for every subsequent delivery; nil removes it
+func (net *Network) SetFilter(filter Filter) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.filter = filter
+}
+
+// Isolate cuts node id off the network: messages to and from it are dropped
+// and its remote ledger requests fail
+func (net *Network) Isolate(id int) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.cut[id] = true
+}
+
+// Heal reconnects every isolated node
+func (net *Network) Heal() {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.cut = make(map[int]bool)
+}
+
+// Pending returns the number of queued messages
+func (net *Network) Pending() int {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	return len(net.queue)
+}
+
+// Step delivers the oldest queued message, returning false if there was none
+func (net *Network) Step() bool {
+	net.lock.Lock()
+	if len(net.queue) == 0 {
+		net.lock.Unlock()
+		return false
+	}
+	msg := net.queue[0]
+	net.queue = net.queue[1:]
+	deliver := !net.cut[msg.From] && !net.cut[msg.To] && (net.filter == nil || net.filter(msg))
+	net.lock.Unlock()
+
+	if !deliver {
+		logger.Debug("Dropping %s message from vp%d to vp%d", msg.Msg.Type, msg.From, msg.To)
+		return true
+	}
+	if err := net.Nodes[msg.To].Consenter.RecvMsg(msg.Msg, net.Nodes[msg.From].Handle); err != nil {
+		logger.Warning("vp%d failed to process %s message from vp%d: %s", msg.To, msg.Msg.Type, msg.From, err)
+	}
+	return true
+}
+
+// Process delivers messages until the queue is empty and every consenter
+// which supports draining has finished executing
+func (net *Network) Process() {
+	for {
+		for net.Step() {
+		}
+		for _, node := range net.Nodes {
+			if drainer, ok := node.Consenter.(interface {
+				Drain()
+			}); ok {
+				drainer.Drain()
+			}
+		}
+		if net.Pending() == 0 {
+			return
+		}
+	}
+}
+
+// Advance moves the clock forward by d, firing any expired timers, and then
+// processes the messages sent as a result
+func (net *Network) Advance(d time.Duration) {
+	net.Clock.Advance(d)
+	net.Process()
+}
+
+// Close releases the resources held by the consenters
+func (net *Network) Close() {
+	for _, node := range net.Nodes {
+		if closer, ok := node.Consenter.(interface {
+			Close()
+		}); ok {
+			closer.Close()
+		}
+	}
+}
+
+func (net *Network) send(from, to int, msg *pb.OpenchainMessage) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	net.queue = append(net.queue, &Message{From: from, To: to, Msg: proto.Clone(msg).(*pb.OpenchainMessage)})
+}
+
+func (net *Network) reachable(from, to int) bool {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+	return !net.cut[from] && !net.cut[to]
+}
+
+func (net *Network) lookup(handle *pb.PeerID) (*Node, error) {
+	for _, node := range net.Nodes {
+		if node.Handle.Name == handle.Name {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown peer %s", handle.Name)
+}
+
+// =============================================================================
+// consensus.Stack
+// =============================================================================
+
+// GetNetworkInfo returns the endpoints of this node and of the whole network
+func (node *Node) GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error) {
+	for _, n := range node.net.Nodes {
+		endpoint := &pb.PeerEndpoint{ID: n.Handle, Address: n.Handle.Name, Type: pb.PeerEndpoint_VALIDATOR}
+		if n == node {
+			self = endpoint
+		}
+		network = append(network, endpoint)
+	}
+	return
+}
+
+// GetNetworkHandles returns the handles of this node and of the whole network
+func (node *Node) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error) {
+	for _, n := range node.net.Nodes {
+		network = append(network, n.Handle)
+	}
+	return node.Handle, network, nil
+}
+
+// Broadcast queues msg for every other node. The network has no
+// non-validating peers, so messages addressed to them are discarded.
+func (node *Node) Broadcast(msg *pb.OpenchainMessage, peerType pb.PeerEndpoint_Type) error {
+	if peerType == pb.PeerEndpoint_NON_VALIDATOR {
+		return nil
+	}
+	for _, n := range node.net.Nodes {
+		if n != node {
+			node.net.send(node.ID, n.ID, msg)
+		}
+	}
+	return nil
+}
+
+// Unicast queues msg for the node with receiverHandle
+func (node *Node) Unicast(msg *pb.OpenchainMessage, receiverHandle *pb.PeerID) error {
+	receiver, err := node.net.lookup(receiverHandle)
+	if err != nil {
+		return err
+	}
+	node.net.send(node.ID, receiver.ID, msg)
+	return nil
+}
+
+// Sign returns msg itself, as the simulated network does not use crypto
+func (node *Node) Sign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+// Verify accepts every signature
+func (node *Node) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	return nil
+}
+
+// Now returns the time of the network's simulated clock
+func (node *Node) Now() time.Time {
+	return node.net.Clock.Now()
+}
+
+// AfterFunc schedules f on the network's simulated clock
+func (node *Node) AfterFunc(d time.Duration, f func()) consensus.Timer {
+	return node.net.Clock.AfterFunc(d, f)
+}
+
+// GetRemoteBlocks streams blocks start through finish, in that order, from
+// the ledger of the node with replicaID
+func (node *Node) GetRemoteBlocks(replicaID *pb.PeerID, start, finish uint64) (<-chan *pb.SyncBlocks, error) {
+	remote, err := node.remote(replicaID)
+	if err != nil {
+		return nil, err
+	}
+	res := make(chan *pb.SyncBlocks)
+	go func() {
+		defer close(res)
+		for _, n := range blockRange(start, finish) {
+			block, err := remote.GetBlock(n)
+			if err != nil {
+				return
+			}
+			res <- &pb.SyncBlocks{Range: &pb.SyncBlockRange{Start: n, End: n}, Blocks: []*pb.Block{block}}
+		}
+	}()
+	return res, nil
+}
+
+// GetRemoteStateSnapshot streams the current state of the node with
+// replicaID, followed by the empty delta marking its end
+func (node *Node) GetRemoteStateSnapshot(replicaID *pb.PeerID) (<-chan *pb.SyncStateSnapshot, error) {
+	remote, err := node.remote(replicaID)
+	if err != nil {
+		return nil, err
+	}
+	delta, blockNumber := remote.snapshot()
+	res := make(chan *pb.SyncStateSnapshot)
+	go func() {
+		defer close(res)
+		sequence := uint64(0)
+		if !delta.IsEmpty() {
+			res <- &pb.SyncStateSnapshot{Delta: delta.Marshal(), Sequence: sequence, BlockNumber: blockNumber}
+			sequence++
+		}
+		res <- &pb.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence, BlockNumber: blockNumber}
+	}()
+	return res, nil
+}
+
+// GetRemoteStateDeltas streams the state deltas of blocks start through
+// finish from the ledger of the node with replicaID
+func (node *Node) GetRemoteStateDeltas(replicaID *pb.PeerID, start, finish uint64) (<-chan *pb.SyncStateDeltas, error) {
+	remote, err := node.remote(replicaID)
+	if err != nil {
+		return nil, err
+	}
+	res := make(chan *pb.SyncStateDeltas)
+	go func() {
+		defer close(res)
+		for _, n := range blockRange(start, finish) {
+			delta, err := remote.blockDelta(n)
+			if err != nil {
+				return
+			}
+			res <- &pb.SyncStateDeltas{Range: &pb.SyncBlockRange{Start: n, End: n}, Deltas: [][]byte{delta.Marshal()}}
+		}
+	}()
+	return res, nil
+}
+
+func (node *Node) remote(replicaID *pb.PeerID) (*Node, error) {
+	remote, err := node.net.lookup(replicaID)
+	if err != nil {
+		return nil, err
+	}
+	if !node.net.reachable(node.ID, remote.ID) {
+		return nil, fmt.Errorf("Peer %s is unreachable from %s", replicaID.Name, node.Handle.Name)
+	}
+	return remote, nil
+}
+
+// blockRange lists the block numbers from start to finish inclusive, which
+// may run in either direction
+func blockRange(start, finish uint64) []uint64 {
+	var numbers []uint64
+	for n := start; ; {
+		numbers = append(numbers, n)
+		if n == finish {
+			return numbers
+		}
+		if start < finish {
+			n++
+		} else {
+			n--
+		}
+	}
+}
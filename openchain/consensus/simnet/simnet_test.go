@@ -0,0 +1,158 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package simnet
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func makeTx(i int) *pb.Transaction {
+	return &pb.Transaction{Uuid: fmt.Sprintf("tx%d", i), Payload: []byte(fmt.Sprintf("value%d", i))}
+}
+
+func assertHeight(t *testing.T, net *Network, id int, expected uint64) {
+	height, _ := net.Nodes[id].GetBlockchainSize()
+	if height != expected {
+		t.Fatalf("Expected vp%d to have height %d, got %d", id, expected, height)
+	}
+}
+
+func TestClockAdvance(t *testing.T) {
+	clock := NewClock()
+	var fired []string
+	record := func(name string) func() {
+		return func() { fired = append(fired, name) }
+	}
+
+	clock.AfterFunc(3*time.Second, record("c"))
+	clock.AfterFunc(time.Second, record("a"))
+	stopped := clock.AfterFunc(2*time.Second, record("stopped"))
+	clock.AfterFunc(2*time.Second, func() {
+		fired = append(fired, "b")
+		clock.AfterFunc(500*time.Millisecond, record("b+"))
+	})
+	reset := clock.AfterFunc(time.Second, record("reset"))
+
+	if !stopped.Stop() {
+		t.Fatalf("Expected stopping a pending timer to report it was active")
+	}
+	if !reset.Reset(10 * time.Second) {
+		t.Fatalf("Expected resetting a pending timer to report it was active")
+	}
+
+	clock.Advance(2500 * time.Millisecond)
+	if expected := "[a b b+]"; fmt.Sprint(fired) != expected {
+		t.Fatalf("Expected timers %s to have fired, got %v", expected, fired)
+	}
+	if now := clock.Now().Sub(time.Unix(0, 0)); now != 2500*time.Millisecond {
+		t.Fatalf("Expected the clock to read 2.5s, got %s", now)
+	}
+
+	clock.Advance(10 * time.Second)
+	if expected := "[a b b+ c reset]"; fmt.Sprint(fired) != expected {
+		t.Fatalf("Expected timers %s to have fired, got %v", expected, fired)
+	}
+	if clock.Pending() != 0 {
+		t.Fatalf("Expected no pending timers, got %d", clock.Pending())
+	}
+}
+
+func TestSolo(t *testing.T) {
+	net := New(3, NewSolo(2, time.Second))
+	defer net.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := net.Submit(i+1, makeTx(i)); err != nil {
+			t.Fatalf("Error submitting transaction: %s", err)
+		}
+	}
+	net.Process()
+	for id := range net.Nodes {
+		assertHeight(t, net, id, 2)
+	}
+
+	// A lone transaction is only cut into a block once the timeout elapses
+	net.Submit(0, makeTx(2))
+	net.Process()
+	assertHeight(t, net, 0, 2)
+	net.Advance(999 * time.Millisecond)
+	assertHeight(t, net, 0, 2)
+	net.Advance(time.Millisecond)
+
+	leaderHash, _ := net.Nodes[0].GetCurrentStateHash()
+	for id, node := range net.Nodes {
+		assertHeight(t, net, id, 3)
+		if hash, _ := node.GetCurrentStateHash(); !bytes.Equal(hash, leaderHash) {
+			t.Fatalf("Expected vp%d to have state hash %x, got %x", id, leaderHash, hash)
+		}
+		if value := node.GetState("tx2"); string(value) != "value2" {
+			t.Fatalf("Expected vp%d to have executed tx2, got value %q", id, value)
+		}
+		if bad, err := node.VerifyBlockchain(2, 0); bad != 0 || err != nil {
+			t.Fatalf("Expected the chain of vp%d to verify, got bad block %d: %v", id, bad, err)
+		}
+	}
+}
+
+func TestIsolate(t *testing.T) {
+	net := New(3, NewSolo(1, time.Second))
+	defer net.Close()
+
+	net.Isolate(2)
+	net.Submit(1, makeTx(0))
+	net.Process()
+	assertHeight(t, net, 1, 2)
+	assertHeight(t, net, 2, 1)
+
+	if _, err := net.Nodes[2].GetRemoteBlocks(net.Nodes[0].Handle, 1, 1); err == nil {
+		t.Fatalf("Expected an isolated node to be unable to reach its peers")
+	}
+
+	net.Heal()
+	blocks, err := net.Nodes[2].GetRemoteBlocks(net.Nodes[0].Handle, 1, 0)
+	if err != nil {
+		t.Fatalf("Error retrieving remote blocks: %s", err)
+	}
+	var received []uint64
+	for sync := range blocks {
+		received = append(received, sync.Range.Start)
+		net.Nodes[2].PutBlock(sync.Range.Start, sync.Blocks[0])
+	}
+	if fmt.Sprint(received) != "[1 0]" {
+		t.Fatalf("Expected blocks 1 and 0, got %v", received)
+	}
+
+	snapshot, err := net.Nodes[2].GetRemoteStateSnapshot(net.Nodes[1].Handle)
+	if err != nil {
+		t.Fatalf("Error retrieving remote state snapshot: %s", err)
+	}
+	var pieces []*pb.SyncStateSnapshot
+	for piece := range snapshot {
+		pieces = append(pieces, piece)
+	}
+	if len(pieces) != 2 || pieces[0].BlockNumber != 1 || len(pieces[1].Delta) != 0 {
+		t.Fatalf("Expected a single delta for block 1 followed by the end marker, got %v", pieces)
+	}
+}
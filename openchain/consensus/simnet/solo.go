@@ -0,0 +1,149 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package simnet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/openblockchain/obc-peer/openchain/consensus"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// leaderName is the handle of the node ordering transactions under solo
+const leaderName = "vp0"
+
+// solo is a consenter in which vp0 orders transactions on behalf of the
+// whole network. Other nodes forward the transactions they receive to vp0,
+// which cuts them into blocks once batchSize transactions are pending or
+// timeout has elapsed since the first of them, and broadcasts each block for
+// every node to execute.
+type solo struct {
+	stack     consensus.Stack
+	leader    bool
+	batchSize int
+	timeout   time.Duration
+
+	lock    sync.Mutex
+	pending []*pb.Transaction
+	timer   consensus.Timer
+	seqNo   uint64
+}
+
+// NewSolo returns a constructor for solo consenters, suitable for New
+func NewSolo(batchSize int, timeout time.Duration) func(consensus.Stack) consensus.Consenter {
+	return func(stack consensus.Stack) consensus.Consenter {
+		self, _, _ := stack.GetNetworkHandles()
+		s := &solo{
+			stack:     stack,
+			leader:    self.Name == leaderName,
+			batchSize: batchSize,
+			timeout:   timeout,
+		}
+		s.timer = consensus.GetClock(stack).AfterFunc(timeout, s.timerExpired)
+		s.timer.Stop()
+		return s
+	}
+}
+
+// RecvMsg handles transactions submitted to this node and blocks ordered by
+// the leader
+func (s *solo) RecvMsg(msg *pb.OpenchainMessage, senderHandle *pb.PeerID) error {
+	switch msg.Type {
+	case pb.OpenchainMessage_CHAIN_TRANSACTION:
+		if !s.leader {
+			return s.stack.Unicast(msg, &pb.PeerID{Name: leaderName})
+		}
+		tx := &pb.Transaction{}
+		if err := proto.Unmarshal(msg.Payload, tx); err != nil {
+			return fmt.Errorf("Error unmarshalling transaction: %s", err)
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.pending = append(s.pending, tx)
+		if len(s.pending) == 1 {
+			s.timer.Reset(s.timeout)
+		}
+		if len(s.pending) >= s.batchSize {
+			return s.cut()
+		}
+		return nil
+
+	case pb.OpenchainMessage_CONSENSUS:
+		if senderHandle.Name != leaderName {
+			return fmt.Errorf("Ignoring block from %s, which is not the leader", senderHandle.Name)
+		}
+		txs := &pb.TransactionBlock{}
+		if err := proto.Unmarshal(msg.Payload, txs); err != nil {
+			return fmt.Errorf("Error unmarshalling block: %s", err)
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return s.execute(txs.Transactions)
+
+	default:
+		return fmt.Errorf("Unexpected message type: %s", msg.Type)
+	}
+}
+
+func (s *solo) timerExpired() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.pending) > 0 {
+		if err := s.cut(); err != nil {
+			logger.Error("Could not cut block: %s", err)
+		}
+	}
+}
+
+// cut broadcasts the pending transactions as a block and executes them
+func (s *solo) cut() error {
+	s.timer.Stop()
+	txs := s.pending
+	s.pending = nil
+	payload, err := proto.Marshal(&pb.TransactionBlock{Transactions: txs})
+	if err != nil {
+		return err
+	}
+	if err := s.stack.Broadcast(&pb.OpenchainMessage{Type: pb.OpenchainMessage_CONSENSUS, Payload: payload}, pb.PeerEndpoint_VALIDATOR); err != nil {
+		return err
+	}
+	return s.execute(txs)
+}
+
+func (s *solo) execute(txs []*pb.Transaction) error {
+	s.seqNo++
+	id := s.seqNo
+	if err := s.stack.BeginTxBatch(id); err != nil {
+		return err
+	}
+	if _, err := s.stack.ExecTxs(id, txs); err != nil {
+		s.stack.RollbackTxBatch(id)
+		return err
+	}
+	if _, err := s.stack.CommitTxBatch(id, nil); err != nil {
+		s.stack.RollbackTxBatch(id)
+		return err
+	}
+	return nil
+}
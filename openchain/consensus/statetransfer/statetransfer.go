@@ -91,9 +91,10 @@ type StateTransferState struct {
 
 	defaultPeerIDs []*protos.PeerID // This is a list of peers which will be queried when no explicit list is given
 
-	blockVerifyChunkSize uint64        // The max block length to attempt to sync at once, this prevents state transfer from being delayed while the blockchain is validated
-	validBlockRanges     []*blockRange // Used by the block thread to track which pieces of the blockchain have already been hashed
-	RecoverDamage        bool          // Whether state transfer should ever modify or delete existing blocks if they are determined to be corrupted
+	blockVerifyChunkSize  uint64        // The max block length to attempt to sync at once, this prevents state transfer from being delayed while the blockchain is validated
+	parallelBlockRequests int           // The max number of peers to fetch block ranges from in parallel while catching up
+	validBlockRanges      []*blockRange // Used by the block thread to track which pieces of the blockchain have already been hashed
+	RecoverDamage         bool          // Whether state transfer should ever modify or delete existing blocks if they are determined to be corrupted
 
 	initiateStateSync chan *syncMark       // Used to ensure only one state transfer at a time occurs, write to only from the main consensus thread
 	blockHashReceiver chan *blockHashReply // Used to process incoming valid block hashes, write only from the state thread
@@ -294,6 +295,7 @@ func ThreadlessNewStateTransferState(id *protos.PeerID, config *viper.Viper, led
 	if sts.blockVerifyChunkSize == 0 {
 		panic(fmt.Errorf("Must set statetransfer.blocksperrequest to be nonzero"))
 	}
+	sts.parallelBlockRequests = config.GetInt("statetransfer.parallelrequests")
 
 	sts.initiateStateSync = make(chan *syncMark, 1)
 	sts.blockHashReceiver = make(chan *blockHashReply, 1)
@@ -423,6 +425,203 @@ func (sts *StateTransferState) tryOverPeers(passedPeerIDs []*protos.PeerID, do f
 // Will return the last block number attempted to sync, and the last block successfully synced (or nil) and error on failure
 // This means on failure, the returned block corresponds to 1 higher than the returned block number
 func (sts *StateTransferState) syncBlocks(highBlock, lowBlock uint64, highHash []byte, peerIDs []*protos.PeerID) (uint64, *protos.Block, error) {
+	if nil == peerIDs {
+		peerIDs = sts.defaultPeerIDs
+	}
+
+	if sts.parallelBlockRequests < 2 || len(peerIDs) < 2 || highBlock < lowBlock || highBlock-lowBlock < sts.blockVerifyChunkSize {
+		return sts.syncBlocksSequentially(highBlock, lowBlock, highHash, peerIDs)
+	}
+
+	blockCursor, block, validBlockHash, err := sts.syncBlockRanges(highBlock, lowBlock, highHash, peerIDs)
+	if nil == err {
+		return blockCursor, block, nil
+	}
+
+	// Finish the sync from where the parallel fetches left off, one peer at a time
+	logger.Warning("%v could not sync blocks %d to %d in parallel, continuing sequentially: %s", sts.id, blockCursor, lowBlock, err)
+	blockNumber, lastBlock, err := sts.syncBlocksSequentially(blockCursor, lowBlock, validBlockHash, peerIDs)
+	if nil == lastBlock {
+		lastBlock = block
+	}
+	return blockNumber, lastBlock, err
+}
+
+// Syncs the blocks from highBlock down to lowBlock by fetching disjoint ranges of blocksperrequest blocks
+// from the supplied peers in parallel, with at most one outstanding request per peer and at most twice as
+// many ranges as peers fetched ahead of the ones committed
+// Each range is verified to be a hash chain as it arrives, and the ranges are committed in order from the
+// highest, each of them chaining to the blocks above it
+// Returns the next block to sync, the last block synced (or nil), and the hash expected for the next block
+func (sts *StateTransferState) syncBlockRanges(highBlock, lowBlock uint64, highHash []byte, peerIDs []*protos.PeerID) (uint64, *protos.Block, []byte, error) {
+	var ranges []*blockRange
+	for high := highBlock; ; {
+		low := lowBlock
+		if high-lowBlock >= sts.blockVerifyChunkSize {
+			low = high - sts.blockVerifyChunkSize + 1
+		}
+		ranges = append(ranges, &blockRange{highBlock: high, lowBlock: low})
+		if low == lowBlock {
+			break
+		}
+		high = low - 1
+	}
+
+	numWorkers := len(peerIDs)
+	if numWorkers > sts.parallelBlockRequests {
+		numWorkers = sts.parallelBlockRequests
+	}
+	logger.Debug("%v syncing blocks from %d to %d in %d ranges from %d peers", sts.id, highBlock, lowBlock, len(ranges), numWorkers)
+
+	// Only the ranges in a window ahead of the commit cursor are handed out, so
+	// that the blocks fetched but not yet committed stay bounded
+	window := 2 * numWorkers
+	if window > len(ranges) {
+		window = len(ranges)
+	}
+	work := make(chan int, window)
+	fetched := make([]chan []*protos.Block, len(ranges))
+	for i := range ranges {
+		fetched[i] = make(chan []*protos.Block, 1)
+	}
+	for i := 0; i < window; i++ {
+		work <- i
+	}
+
+	abort := make(chan struct{})
+	workersDone := make(chan struct{})
+	workers := &sync.WaitGroup{}
+
+	for _, index := range rand.Perm(len(peerIDs))[:numWorkers] {
+		workers.Add(1)
+		go func(peerID *protos.PeerID) {
+			defer workers.Done()
+			for {
+				select {
+				case i := <-work:
+					blocks, err := sts.fetchBlockRange(peerID, ranges[i].highBlock, ranges[i].lowBlock, abort)
+					if nil != err {
+						// Leave the range to the other peers
+						logger.Warning("%v failed to get blocks from %d to %d from %v: %s", sts.id, ranges[i].highBlock, ranges[i].lowBlock, peerID, err)
+						work <- i
+						return
+					}
+					fetched[i] <- blocks
+				case <-abort:
+					return
+				}
+			}
+		}(peerIDs[index])
+	}
+
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+
+	defer func() {
+		// No request may be outstanding once the sync returns
+		close(abort)
+		<-workersDone
+	}()
+
+	validBlockHash := highHash
+	blockCursor := highBlock
+	var block *protos.Block
+
+	for i, r := range ranges {
+		var blocks []*protos.Block
+		select {
+		case blocks = <-fetched[i]:
+		case <-workersDone:
+			select {
+			case blocks = <-fetched[i]:
+			default:
+				return blockCursor, block, validBlockHash, fmt.Errorf("%v could not get blocks from %d to %d from any peer", sts.id, r.highBlock, r.lowBlock)
+			}
+		}
+
+		testHash, err := sts.ledger.HashBlock(blocks[0])
+		if nil != err {
+			return blockCursor, block, validBlockHash, fmt.Errorf("%v got a block %d which could not hash: %s", sts.id, blockCursor, err)
+		}
+		if !bytes.Equal(testHash, validBlockHash) {
+			return blockCursor, block, validBlockHash, fmt.Errorf("%v got block %d with hash %x, was expecting hash %x", sts.id, blockCursor, testHash, validBlockHash)
+		}
+
+		for _, block = range blocks {
+			sts.putSyncedBlock(blockCursor, block, validBlockHash)
+			validBlockHash = block.PreviousBlockHash
+			if blockCursor == lowBlock {
+				break
+			}
+			blockCursor--
+		}
+
+		if next := i + window; next < len(ranges) {
+			work <- next
+		}
+	}
+
+	logger.Debug("%v successfully synced from block %d to block %d", sts.id, highBlock, lowBlock)
+	return blockCursor, block, validBlockHash, nil
+}
+
+// Retrieves the blocks from highBlock down to lowBlock from a peer, verifying that each of them is the
+// predecessor of the block above it
+func (sts *StateTransferState) fetchBlockRange(peerID *protos.PeerID, highBlock, lowBlock uint64, abort chan struct{}) ([]*protos.Block, error) {
+	blockChan, err := sts.ledger.GetRemoteBlocks(peerID, highBlock, lowBlock)
+	if nil != err {
+		return nil, err
+	}
+
+	var blocks []*protos.Block
+	blockCursor := highBlock
+	for {
+		select {
+		case syncBlockMessage, ok := <-blockChan:
+			if !ok {
+				return nil, fmt.Errorf("Channel closed before we could finish reading")
+			}
+
+			if syncBlockMessage.Range.Start < syncBlockMessage.Range.End {
+				// If the message is not replying with blocks backwards, we did not ask for it
+				continue
+			}
+
+			for i, block := range syncBlockMessage.Blocks {
+				// It is possible to get duplication or out of range blocks due to an implementation detail, we must check for them
+				if syncBlockMessage.Range.Start-uint64(i) != blockCursor {
+					continue
+				}
+
+				if 0 < len(blocks) {
+					testHash, err := sts.ledger.HashBlock(block)
+					if nil != err {
+						return nil, fmt.Errorf("%v got a block %d which could not hash from %v: %s", sts.id, blockCursor, peerID, err)
+					}
+					if !bytes.Equal(testHash, blocks[len(blocks)-1].PreviousBlockHash) {
+						return nil, fmt.Errorf("%v got block %d from %v with hash %x, was expecting hash %x",
+							sts.id, blockCursor, peerID, testHash, blocks[len(blocks)-1].PreviousBlockHash)
+					}
+				}
+				blocks = append(blocks, block)
+
+				if blockCursor == lowBlock {
+					return blocks, nil
+				}
+				blockCursor--
+			}
+		case <-time.After(sts.BlockRequestTimeout):
+			return nil, fmt.Errorf("%v had block sync request to %v time out", sts.id, peerID)
+		case <-abort:
+			return nil, fmt.Errorf("%v aborted block sync request to %v", sts.id, peerID)
+		}
+	}
+}
+
+// Syncs the blocks from highBlock down to lowBlock one peer at a time, with the same return values as syncBlocks
+func (sts *StateTransferState) syncBlocksSequentially(highBlock, lowBlock uint64, highHash []byte, peerIDs []*protos.PeerID) (uint64, *protos.Block, error) {
 	logger.Debug("%v syncing blocks from %d to %d", sts.id, highBlock, lowBlock)
 	validBlockHash := highHash
 	blockCursor := highBlock
@@ -466,27 +665,7 @@ func (sts *StateTransferState) syncBlocks(highBlock, lowBlock uint64, highHash [
 							sts.id, blockCursor, peerID, testHash, validBlockHash)
 					}
 
-					logger.Debug("%v putting block %d to with PreviousBlockHash %x and StateHash %x", sts.id, blockCursor, block.PreviousBlockHash, block.StateHash)
-					if !sts.RecoverDamage {
-
-						// If we are not supposed to be destructive in our recovery, check to make sure this block doesn't already exist
-						if oldBlock, err := sts.ledger.GetBlock(blockCursor); err == nil && oldBlock != nil {
-							oldBlockHash, err := sts.ledger.HashBlock(oldBlock)
-							if nil == err {
-								if !bytes.Equal(oldBlockHash, validBlockHash) {
-									panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
-								}
-							} else {
-								logger.Error("%v could not compute the hash of block %d", sts.id, blockCursor)
-								panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
-							}
-							logger.Debug("%v not actually putting block %d to with PreviousBlockHash %x and StateHash %x, as it already exists", sts.id, blockCursor, block.PreviousBlockHash, block.StateHash)
-						} else {
-							sts.ledger.PutBlock(blockCursor, block)
-						}
-					} else {
-						sts.ledger.PutBlock(blockCursor, block)
-					}
+					sts.putSyncedBlock(blockCursor, block, validBlockHash)
 
 					validBlockHash = block.PreviousBlockHash
 
@@ -513,6 +692,29 @@ func (sts *StateTransferState) syncBlocks(highBlock, lowBlock uint64, highHash [
 
 }
 
+// Puts a synced block with the given hash to the blockchain, leaving an existing copy of it untouched unless damage is to be recovered
+func (sts *StateTransferState) putSyncedBlock(blockNumber uint64, block *protos.Block, blockHash []byte) {
+	logger.Debug("%v putting block %d to with PreviousBlockHash %x and StateHash %x", sts.id, blockNumber, block.PreviousBlockHash, block.StateHash)
+	if !sts.RecoverDamage {
+
+		// If we are not supposed to be destructive in our recovery, check to make sure this block doesn't already exist
+		if oldBlock, err := sts.ledger.GetBlock(blockNumber); err == nil && oldBlock != nil {
+			oldBlockHash, err := sts.ledger.HashBlock(oldBlock)
+			if nil == err {
+				if !bytes.Equal(oldBlockHash, blockHash) {
+					panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
+				}
+			} else {
+				logger.Error("%v could not compute the hash of block %d", sts.id, blockNumber)
+				panic("The blockchain is corrupt and the configuration has specified that bad blocks should not be deleted/overridden")
+			}
+			logger.Debug("%v not actually putting block %d to with PreviousBlockHash %x and StateHash %x, as it already exists", sts.id, blockNumber, block.PreviousBlockHash, block.StateHash)
+			return
+		}
+	}
+	sts.ledger.PutBlock(blockNumber, block)
+}
+
 func (sts *StateTransferState) syncBlockchainToCheckpoint(blockSyncReq *blockSyncReq) {
 
 	logger.Debug("%v is processing a blockSyncReq to block %d", sts.id, blockSyncReq.blockNumber)
@@ -25,6 +25,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/spf13/viper"
@@ -33,9 +34,11 @@ import (
 
 //abstract virtual image for supporting arbitrary virual machines
 type vm interface {
-	build(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error
+	build(ctxt context.Context, id string, image string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error
 	start(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool) error
 	stop(ctxt context.Context, id string, timeout uint, dontkill bool, dontremove bool) error
+	status(ctxt context.Context, id string) (string, error)
+	ready(ctxt context.Context, id string) error
 }
 
 //dockerVM is a vm. It is identified by an image id
@@ -43,9 +46,14 @@ type dockerVM struct {
 	id string
 }
 
-//create a docker client given endpoint to communicate with docker host
-func (vm *dockerVM) newClient() (*docker.Client, error) {
-	return newDockerClient()
+//create a docker client to communicate with the docker host the
+//container id is placed on
+func (vm *dockerVM) newClient(id string) (*docker.Client, error) {
+	host, err := dockerHostFor(id)
+	if err != nil {
+		return nil, err
+	}
+	return host.newClient()
 }
 
 func (vm *dockerVM) createContainer(ctxt context.Context, client *docker.Client, id string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool) error {
@@ -62,37 +70,66 @@ func (vm *dockerVM) createContainer(ctxt context.Context, client *docker.Client,
 
 //for docker inputbuf is tar reader ready for use by docker.Client
 //the stream from end client to peer could directly be this tar stream
-//talk to docker daemon using docker Client and build the image
-func (vm *dockerVM) build(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
-	outputbuf := bytes.NewBuffer(nil)
-	opts := docker.BuildImageOptions{
-		Name:         id,
-		Pull:         true,
-		InputStream:  reader,
-		OutputStream: outputbuf,
-	}
-	client, err := vm.newClient()
-	switch err {
-	case nil:
-		if err = client.BuildImage(opts); err != nil {
-			vmLogger.Debug("Error building Peer container: %s", err)
-			return err
-		}
-		vmLogger.Debug("Created image: %s", id)
-	default:
+//talk to docker daemon using docker Client and build the image.
+//If image is set the package is built (once) under that content addressed
+//name and tagged with id, so identical packages share one image
+func (vm *dockerVM) build(ctxt context.Context, id string, image string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	client, err := vm.newClient(id)
+	if err != nil {
 		return fmt.Errorf("Error creating docker client: %s", err)
 	}
+	if err = buildImage(client, id, image, reader); err != nil {
+		return err
+	}
 	containerID := strings.Replace(id, ":", "_", -1)
 	return vm.createContainer(ctxt, client, id, containerID, args, env, attachstdin, attachstdout)
 }
 
+//buildImage builds the package read from reader as image id. If image is
+//set the package is built (once) under that name and tagged with id
+func buildImage(client *docker.Client, id string, image string, reader io.Reader) error {
+	var err error
+	if image == "" {
+		image = id
+	}
+	exists := false
+	if image != id {
+		if exists, err = imageExists(client, image); err != nil {
+			return fmt.Errorf("Error looking up image %s: %s", image, err)
+		}
+	}
+	if exists {
+		vmLogger.Debug("Reusing image %s for %s", image, id)
+	} else {
+		outputbuf := bytes.NewBuffer(nil)
+		opts := docker.BuildImageOptions{
+			Name:         image,
+			Pull:         true,
+			InputStream:  reader,
+			OutputStream: outputbuf,
+		}
+		if err = client.BuildImage(opts); err != nil {
+			vmLogger.Debug("Error building Peer container: %s", err)
+			return fmt.Errorf("%s\nBuild output:\n%s", err, outputbuf.String())
+		}
+		vmLogger.Debug("Created image: %s", image)
+	}
+	if image != id {
+		if err = client.TagImage(image, docker.TagImageOptions{Repo: id, Force: true}); err != nil {
+			return fmt.Errorf("Error tagging image %s as %s: %s", image, id, err)
+		}
+	}
+	return nil
+}
+
 func (vm *dockerVM) start(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool) error {
-	client, err := vm.newClient()
+	client, err := vm.newClient(id)
 	if err != nil {
 		vmLogger.Debug("start - cannot create client %s", err)
 		return err
 	}
 	containerID := strings.Replace(id, ":", "_", -1)
+	started := time.Now()
 	err = client.StartContainer(containerID, &docker.HostConfig{NetworkMode: "host"})
 	if err != nil {
 		errMsg := "start"
@@ -112,11 +149,14 @@ func (vm *dockerVM) start(ctxt context.Context, id string, args []string, env []
 		}
 	}
 	vmLogger.Debug("Started container %s", id)
+	if captureEnabled() {
+		go captureDockerLogs(client, id, containerID, started)
+	}
 	return nil
 }
 
 func (vm *dockerVM) stop(ctxt context.Context, id string, timeout uint, dontkill bool, dontremove bool) error {
-	client, err := vm.newClient()
+	client, err := vm.newClient(id)
 	if err != nil {
 		vmLogger.Debug("start - cannot create client %s", err)
 		return err
@@ -147,11 +187,39 @@ func (vm *dockerVM) stop(ctxt context.Context, id string, timeout uint, dontkill
 	return err
 }
 
+//status reports the state of the container, "missing" if there is none
+func (vm *dockerVM) status(ctxt context.Context, id string) (string, error) {
+	client, err := vm.newClient(id)
+	if err != nil {
+		vmLogger.Debug("status - cannot create client %s", err)
+		return "", err
+	}
+	id = strings.Replace(id, ":", "_", -1)
+	container, err := client.InspectContainer(id)
+	if err != nil {
+		if _, ok := err.(*docker.NoSuchContainer); ok {
+			return StatusMissing, nil
+		}
+		return "", err
+	}
+	return container.State.String(), nil
+}
+
+//ready is a no-op, docker containers need no notice of registration
+func (vm *dockerVM) ready(ctxt context.Context, id string) error {
+	return nil
+}
+
 //constants for supported containers
 const (
-	DOCKER = "Docker"
+	DOCKER     = "Docker"
+	SYSTEM     = "System"
+	KUBERNETES = "Kubernetes"
 )
 
+//StatusMissing is the status of containers that do not exist
+const StatusMissing = "missing"
+
 type image struct {
 	id   string
 	args []string
@@ -190,6 +258,10 @@ func (vmc *VMController) newVM(typ string) vm {
 	switch typ {
 	case DOCKER:
 		v = &dockerVM{}
+	case SYSTEM:
+		v = &inprocVM{}
+	case KUBERNETES:
+		v = &kubernetesVM{}
 	case "":
 		v = &dockerVM{}
 	}
@@ -249,7 +321,10 @@ type VMCResp struct {
 
 //CreateImageReq - properties for creating an container image
 type CreateImageReq struct {
-	ID           string
+	ID string
+	//Image optionally names the content addressed image to build the
+	//package as, see GetImageFromPackage
+	Image        string
 	Reader       io.Reader
 	AttachStdin  bool
 	AttachStdout bool
@@ -259,7 +334,7 @@ type CreateImageReq struct {
 
 func (bp CreateImageReq) do(ctxt context.Context, v vm) VMCResp {
 	var resp VMCResp
-	if err := v.build(ctxt, bp.ID, bp.Args, bp.Env, bp.AttachStdin, bp.AttachStdout, bp.Reader); err != nil {
+	if err := v.build(ctxt, bp.ID, bp.Image, bp.Args, bp.Env, bp.AttachStdin, bp.AttachStdout, bp.Reader); err != nil {
 		resp = VMCResp{Err: err}
 	} else {
 		resp = VMCResp{}
@@ -321,6 +396,37 @@ func (si StopImageReq) getID() string {
 	return si.ID
 }
 
+//StatusReq - properties for getting the status of a container.
+type StatusReq struct {
+	ID string
+}
+
+func (sr StatusReq) do(ctxt context.Context, v vm) VMCResp {
+	status, err := v.status(ctxt, sr.ID)
+	if err != nil {
+		return VMCResp{Err: err}
+	}
+	return VMCResp{Resp: status}
+}
+
+func (sr StatusReq) getID() string {
+	return sr.ID
+}
+
+//ReadyReq - notifies the VM that the chaincode in a container has
+//registered with the peer.
+type ReadyReq struct {
+	ID string
+}
+
+func (rr ReadyReq) do(ctxt context.Context, v vm) VMCResp {
+	return VMCResp{Err: v.ready(ctxt, rr.ID)}
+}
+
+func (rr ReadyReq) getID() string {
+	return rr.ID
+}
+
 //VMCProcess should be used as follows
 //   . construct a context
 //   . construct req of the right type (e.g., CreateImageReq)
@@ -366,7 +472,7 @@ func GetVMFromName(name string) string {
 /*******************
  * OLD ... leavethis here as sample for "client.CreateExec" in case we need it at some point
 func (vm *dockerVM) start(ctxt context.Context, id string, args []string, detach bool, instream io.Reader, outstream io.Writer) error {
-	client, err := vm.newClient()
+	client, err := vm.newClient(id)
 	if err != nil {
 		fmt.Printf("start - cannot create client %s\n", err)
 		return err
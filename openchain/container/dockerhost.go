@@ -0,0 +1,230 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// localDockerHost is the name of the Docker host configured by vm.endpoint
+const localDockerHost = "local"
+
+// dockerHost is a Docker daemon chaincode containers can run on
+type dockerHost struct {
+	name     string
+	endpoint string
+	tls      bool
+	cert     string
+	key      string
+	ca       string
+
+	lock      sync.RWMutex
+	healthErr error // result of the last health check
+}
+
+// dockerHosts holds the configured Docker hosts, loaded on first use
+var dockerHosts struct {
+	sync.Mutex
+	hosts map[string]*dockerHost
+	stop  chan struct{}
+}
+
+// loadDockerHost reads the settings of an entry of vm.docker.hosts, which
+// has an endpoint and tls settings laid out like vm.docker.tls
+func loadDockerHost(name string, settings map[string]interface{}) *dockerHost {
+	tls := cast.ToStringMap(settings["tls"])
+	file := func(key string) string {
+		return cast.ToString(cast.ToStringMap(tls[key])["file"])
+	}
+	return &dockerHost{
+		name:     name,
+		endpoint: cast.ToString(settings["endpoint"]),
+		tls:      cast.ToBool(tls["enabled"]),
+		cert:     file("cert"),
+		key:      file("key"),
+		ca:       file("ca"),
+	}
+}
+
+// getDockerHosts returns the local Docker host and those configured in
+// vm.docker.hosts, starting their health checks the first time
+func getDockerHosts() map[string]*dockerHost {
+	dockerHosts.Lock()
+	defer dockerHosts.Unlock()
+	if dockerHosts.hosts != nil {
+		return dockerHosts.hosts
+	}
+
+	hosts := map[string]*dockerHost{
+		localDockerHost: &dockerHost{
+			name:     localDockerHost,
+			endpoint: viper.GetString("vm.endpoint"),
+			tls:      viper.GetBool("vm.docker.tls.enabled"),
+			cert:     viper.GetString("vm.docker.tls.cert.file"),
+			key:      viper.GetString("vm.docker.tls.key.file"),
+			ca:       viper.GetString("vm.docker.tls.ca.file"),
+		},
+	}
+	for name, settings := range viper.GetStringMap("vm.docker.hosts") {
+		hosts[name] = loadDockerHost(name, cast.ToStringMap(settings))
+		vmLogger.Info("Docker host %s at %s (TLS %v)", name, hosts[name].endpoint, hosts[name].tls)
+	}
+	dockerHosts.hosts = hosts
+
+	if interval := viper.GetDuration("vm.docker.healthCheck.interval"); interval > 0 {
+		dockerHosts.stop = make(chan struct{})
+		go watchDockerHosts(hosts, interval, dockerHosts.stop)
+	}
+	return hosts
+}
+
+// resetDockerHosts discards the loaded hosts so that they are read from the
+// configuration again on next use
+func resetDockerHosts() {
+	dockerHosts.Lock()
+	defer dockerHosts.Unlock()
+	if dockerHosts.stop != nil {
+		close(dockerHosts.stop)
+		dockerHosts.stop = nil
+	}
+	dockerHosts.hosts = nil
+}
+
+// watchDockerHosts checks the health of every host each interval
+func watchDockerHosts(hosts map[string]*dockerHost, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, host := range hosts {
+				host.check()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// dockerHostFor returns the Docker host the container of the chaincode
+// with the given VM name runs on. vm.docker.placement maps chaincode names
+// to host names; other chaincodes run on vm.docker.defaultHost.
+func dockerHostFor(vmName string) (*dockerHost, error) {
+	hosts := getDockerHosts()
+	hostName := viper.GetString("vm.docker.defaultHost")
+	for chaincode, placement := range viper.GetStringMapString("vm.docker.placement") {
+		if strings.EqualFold(GetVMFromName(chaincode), vmName) {
+			hostName = placement
+			break
+		}
+	}
+	if hostName == "" {
+		hostName = localDockerHost
+	}
+	host, ok := hosts[hostName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown Docker host %s for %s", hostName, vmName)
+	}
+	return host, nil
+}
+
+// newClient creates a client for the host. It fails without contacting
+// the host if the last health check found it unavailable, so that requests
+// do not wait on a host known to be down.
+func (host *dockerHost) newClient() (*docker.Client, error) {
+	host.lock.RLock()
+	healthErr := host.healthErr
+	host.lock.RUnlock()
+	if healthErr != nil {
+		return nil, fmt.Errorf("Docker host %s is unavailable: %s", host.name, healthErr)
+	}
+	return host.connect()
+}
+
+func (host *dockerHost) connect() (*docker.Client, error) {
+	if host.tls {
+		return docker.NewTLSClient(host.endpoint, host.cert, host.key, host.ca)
+	}
+	return docker.NewClient(host.endpoint)
+}
+
+// check pings the host, recording and returning the outcome
+func (host *dockerHost) check() error {
+	err := host.ping(viper.GetDuration("vm.docker.healthCheck.timeout"))
+	host.lock.Lock()
+	if err != nil && host.healthErr == nil {
+		vmLogger.Warning("Docker host %s is unavailable: %s", host.name, err)
+	} else if err == nil && host.healthErr != nil {
+		vmLogger.Info("Docker host %s is available again", host.name)
+	}
+	host.healthErr = err
+	host.lock.Unlock()
+	return err
+}
+
+func (host *dockerHost) ping(timeout time.Duration) error {
+	client, err := host.connect()
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		return client.Ping()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Ping()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no response from %s within %s", host.endpoint, timeout)
+	}
+}
+
+// CheckDocker checks that the Docker daemons running the chaincode
+// containers respond
+func CheckDocker() error {
+	hosts := getDockerHosts()
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		if err := hosts[name].check(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Docker hosts unavailable: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
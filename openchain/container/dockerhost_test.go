@@ -0,0 +1,94 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func setupDockerHosts(endpoint string) func() {
+	viper.Set("vm.docker.hosts", map[string]interface{}{
+		"exec1": map[string]interface{}{"endpoint": endpoint},
+		"exec2": map[string]interface{}{"endpoint": "tcp://127.0.0.1:1"},
+	})
+	viper.Set("vm.docker.placement", map[string]interface{}{"mycc": "exec1", "down": "exec2", "lost": "nowhere"})
+	viper.Set("vm.docker.healthCheck.interval", 0)
+	resetDockerHosts()
+	return func() {
+		viper.Set("vm.docker.hosts", nil)
+		viper.Set("vm.docker.placement", nil)
+		resetDockerHosts()
+	}
+}
+
+func TestDockerHostPlacement(t *testing.T) {
+	defer setupDockerHosts("tcp://127.0.0.1:2")()
+
+	for chaincode, expected := range map[string]string{"mycc": "exec1", "MyCC": "exec1", "down": "exec2", "other": localDockerHost} {
+		host, err := dockerHostFor(GetVMFromName(chaincode))
+		if err != nil {
+			t.Fatalf("Error placing %s: %s", chaincode, err)
+		}
+		if host.name != expected {
+			t.Fatalf("Expected %s to run on %s, got %s", chaincode, expected, host.name)
+		}
+	}
+	if host := getDockerHosts()["exec1"]; host.endpoint != "tcp://127.0.0.1:2" {
+		t.Fatalf("Expected the endpoint of exec1 to be read from the configuration, got %s", host.endpoint)
+	}
+	if _, err := dockerHostFor(GetVMFromName("lost")); err == nil {
+		t.Fatalf("Expected placing a chaincode on an unknown host to fail")
+	}
+}
+
+func TestDockerHostHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+	defer server.Close()
+	defer setupDockerHosts("tcp://" + strings.TrimPrefix(server.URL, "http://"))()
+
+	hosts := getDockerHosts()
+	if err := hosts["exec1"].check(); err != nil {
+		t.Fatalf("Expected exec1 to be healthy: %s", err)
+	}
+	if err := hosts["exec2"].check(); err == nil {
+		t.Fatalf("Expected exec2 to be unhealthy")
+	}
+
+	if _, err := hosts["exec1"].newClient(); err != nil {
+		t.Fatalf("Expected a client for a healthy host: %s", err)
+	}
+	if _, err := hosts["exec2"].newClient(); err == nil || !strings.Contains(err.Error(), "unavailable") {
+		t.Fatalf("Expected creating a client for an unhealthy host to fail fast, got %v", err)
+	}
+	if _, err := (&dockerVM{}).status(nil, GetVMFromName("down")); err == nil {
+		t.Fatalf("Expected requests for containers on an unhealthy host to fail")
+	}
+
+	if err := CheckDocker(); err == nil || !strings.Contains(err.Error(), "exec2") || strings.Contains(err.Error(), "exec1") {
+		t.Fatalf("Expected the docker check to report exec2 only, got %v", err)
+	}
+}
@@ -24,11 +24,8 @@ func addFile(tw *tar.Writer, path string, info os.FileInfo, fbytes []byte) error
 	if err != nil {
 		return fmt.Errorf("Error getting FileInfoHeader: %s", err)
 	}
-	//Let's take the variance out of the tar, make headers identical by using zero time
-	var zeroTime time.Time
-	h.AccessTime = zeroTime
-	h.ModTime = zeroTime
-	h.ChangeTime = zeroTime
+	//Let's take the variance out of the tar, make headers identical everywhere
+	normalizeHeader(h)
 	h.Name = path
 	if err = tw.WriteHeader(h); err != nil {
 		return fmt.Errorf("Error writing header: %s", err)
@@ -268,6 +265,11 @@ func generateHashcode(spec *pb.ChaincodeSpec, tw *tar.Writer) (string, error) {
 	}
 
 	hash := generateHashFromSignature(actualcodepath, ctor.Function, ctor.Args)
+	//the same code deployed on another chain is another chaincode, running
+	//in a container of its own
+	if spec.ChainID != "" {
+		hash = util.ComputeCryptoHash(append(hash, []byte(spec.ChainID)...))
+	}
 
 	hash, err = hashFilesInDir(codegopath+"/src/", actualcodepath, hash, tw)
 	if err != nil {
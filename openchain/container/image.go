@@ -0,0 +1,142 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"archive/tar"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+
+	"github.com/openblockchain/obc-peer/openchain/util"
+)
+
+const (
+	// chaincodeImageRepository is the docker repository chaincode images are tagged in
+	chaincodeImageRepository = "obc-chaincode"
+	// ChaincodeExecutable is the name of the executable of every chaincode
+	// in its image, so that the package only depends on the code
+	ChaincodeExecutable = "chaincode"
+)
+
+// GetImageFromPackage returns the name of the image built from the chaincode
+// package. Images are addressed by the hash of the package, so peers,
+// restarts and chaincodes deploying the same code share one image, which is
+// also tagged with the VM name of each chaincode using it.
+func GetImageFromPackage(codePackage []byte) string {
+	return fmt.Sprintf("%s:%x", chaincodeImageRepository, util.ComputeCryptoHash(codePackage)[:32])
+}
+
+// normalizeHeader takes the variance out of the tar so that the same code
+// always yields the same package, whichever host and user packaged it
+func normalizeHeader(h *tar.Header) {
+	var zeroTime time.Time
+	h.AccessTime = zeroTime
+	h.ModTime = zeroTime
+	h.ChangeTime = zeroTime
+	h.Uid = 0
+	h.Gid = 0
+	h.Uname = ""
+	h.Gname = ""
+}
+
+// imageExists returns true if the image is available on the docker host
+func imageExists(client *docker.Client, image string) (bool, error) {
+	_, err := client.InspectImage(image)
+	switch err {
+	case nil:
+		return true, nil
+	case docker.ErrNoSuchImage:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// RemoveUnusedChaincodeImages removes the tags of this peer's chaincodes
+// that isDeployed does not know of, eg. because their deploy transaction was
+// rejected, from chaincode images created more than minAge ago. An image no
+// chaincode is tagged on any more is removed. Images still in use by a
+// container are left alone.
+func RemoveUnusedChaincodeImages(isDeployed func(name string) bool, minAge time.Duration) error {
+	client, err := newDockerClient()
+	if err != nil {
+		return fmt.Errorf("Error creating docker client: %s", err)
+	}
+	imgs, err := client.ListImages(docker.ListImagesOptions{})
+	if err != nil {
+		return fmt.Errorf("Error listing chaincode images: %s", err)
+	}
+	for _, img := range imgs {
+		if time.Since(time.Unix(img.Created, 0)) < minAge {
+			continue
+		}
+		unused, content := unusedChaincodeTags(img.RepoTags, GetVMFromName(""), isDeployed)
+		if err = removeImageTags(client, unused); err != nil {
+			vmLogger.Debug("Could not remove tags of image %s: %s", img.ID, err)
+			continue
+		}
+		if len(unused) > 0 && len(unused)+len(content) == len(img.RepoTags) {
+			//removing the last tag removes the image
+			if err = removeImageTags(client, content); err != nil {
+				vmLogger.Debug("Could not remove image %s: %s", img.ID, err)
+				continue
+			}
+			vmLogger.Info("Removed image %s of undeployed chaincodes %v", img.ID, unused)
+		}
+	}
+	return nil
+}
+
+// unusedChaincodeTags returns the tags of a chaincode image naming chaincodes
+// of the peer whose VM names start with prefix that are not deployed, and
+// the content addressed tags of the image. Images that are not chaincode
+// images have neither.
+func unusedChaincodeTags(tags []string, prefix string, isDeployed func(name string) bool) (unused []string, content []string) {
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, chaincodeImageRepository+":") {
+			content = append(content, tag)
+		}
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	for _, tag := range tags {
+		repo := strings.SplitN(tag, ":", 2)[0]
+		if repo == chaincodeImageRepository || !strings.HasPrefix(repo, prefix) {
+			continue
+		}
+		if !isDeployed(strings.TrimPrefix(repo, prefix)) {
+			unused = append(unused, tag)
+		}
+	}
+	return unused, content
+}
+
+func removeImageTags(client *docker.Client, tags []string) error {
+	for _, tag := range tags {
+		if err := client.RemoveImage(tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"archive/tar"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetImageFromPackage(t *testing.T) {
+	a := GetImageFromPackage([]byte("package a"))
+	if a != GetImageFromPackage([]byte("package a")) {
+		t.Fatalf("Expected the same package to map to the same image")
+	}
+	if a == GetImageFromPackage([]byte("package b")) {
+		t.Fatalf("Expected different packages to map to different images")
+	}
+	if !strings.HasPrefix(a, chaincodeImageRepository+":") {
+		t.Fatalf("Expected image %s to be in repository %s", a, chaincodeImageRepository)
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	h := &tar.Header{Name: "a", ModTime: time.Now(), AccessTime: time.Now(), ChangeTime: time.Now(), Uid: 1000, Gid: 1000, Uname: "jdoe", Gname: "users"}
+	normalizeHeader(h)
+	if h.Name != "a" || !h.ModTime.IsZero() || !h.AccessTime.IsZero() || !h.ChangeTime.IsZero() || h.Uid != 0 || h.Gid != 0 || h.Uname != "" || h.Gname != "" {
+		t.Fatalf("Header not normalized: %+v", h)
+	}
+}
+
+func TestUnusedChaincodeTags(t *testing.T) {
+	isDeployed := func(name string) bool { return name == "cc1" }
+	tags := []string{chaincodeImageRepository + ":abc", "net-vp0-cc1:latest", "net-vp0-cc2:latest", "net-vp1-cc3:latest"}
+	unused, content := unusedChaincodeTags(tags, "net-vp0-", isDeployed)
+	if !reflect.DeepEqual(unused, []string{"net-vp0-cc2:latest"}) {
+		t.Fatalf("Expected only the tag of the undeployed chaincode of the peer to be unused, got %v", unused)
+	}
+	if !reflect.DeepEqual(content, []string{chaincodeImageRepository + ":abc"}) {
+		t.Fatalf("Expected the content addressed tag, got %v", content)
+	}
+
+	if unused, content = unusedChaincodeTags([]string{"net-vp0-cc2:latest"}, "net-vp0-", isDeployed); unused != nil || content != nil {
+		t.Fatalf("Expected images that are not chaincode images to be left alone, got %v and %v", unused, content)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// inprocStreamBufferSize mimics the buffering a gRPC stream offers so that
+// neither side blocks on Send while the other is busy sending
+const inprocStreamBufferSize = 16
+
+// ChaincodeStream is the peer side of the stream to an in-process chaincode
+type ChaincodeStream interface {
+	Send(*pb.ChaincodeMessage) error
+	Recv() (*pb.ChaincodeMessage, error)
+}
+
+// ChaincodeStreamHandler serves the peer side of the streams to in-process
+// chaincodes. It has to be set on the context of requests for SYSTEM VMs,
+// see WithChaincodeStreamHandler.
+type ChaincodeStreamHandler interface {
+	HandleChaincodeStream(stream ChaincodeStream) error
+}
+
+type streamHandlerKeyType string
+
+const streamHandlerKey = streamHandlerKeyType("chaincodeStreamHandler")
+
+// WithChaincodeStreamHandler returns a context carrying the handler used to
+// serve the peer side of the streams to in-process chaincodes
+func WithChaincodeStreamHandler(ctxt context.Context, handler ChaincodeStreamHandler) context.Context {
+	return context.WithValue(ctxt, streamHandlerKey, handler)
+}
+
+//inprocStream is one end of a pair of in memory streams
+type inprocStream struct {
+	recv   <-chan *pb.ChaincodeMessage
+	send   chan<- *pb.ChaincodeMessage
+	closed chan struct{}
+	once   *sync.Once
+}
+
+func newInprocStreamPair() (*inprocStream, *inprocStream) {
+	a := make(chan *pb.ChaincodeMessage, inprocStreamBufferSize)
+	b := make(chan *pb.ChaincodeMessage, inprocStreamBufferSize)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	return &inprocStream{recv: a, send: b, closed: closed, once: once}, &inprocStream{recv: b, send: a, closed: closed, once: once}
+}
+
+func (s *inprocStream) Send(msg *pb.ChaincodeMessage) error {
+	select {
+	case <-s.closed:
+		return fmt.Errorf("stream closed")
+	default:
+	}
+	select {
+	case s.send <- msg:
+		return nil
+	case <-s.closed:
+		return fmt.Errorf("stream closed")
+	}
+}
+
+func (s *inprocStream) Recv() (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.recv:
+		return msg, nil
+	case <-s.closed:
+		return nil, io.EOF
+	}
+}
+
+//close ends both streams of the pair
+func (s *inprocStream) close() {
+	s.once.Do(func() { close(s.closed) })
+}
+
+//inprocContainer is a chaincode registered to run in-process
+type inprocContainer struct {
+	chaincode shim.Chaincode
+	stream    *inprocStream
+}
+
+var inprocRegistry = struct {
+	sync.Mutex
+	containers map[string]*inprocContainer
+}{containers: make(map[string]*inprocContainer)}
+
+// RegisterInProc registers a chaincode to be run in-process by SYSTEM VMs
+// under id
+func RegisterInProc(id string, cc shim.Chaincode) error {
+	inprocRegistry.Lock()
+	defer inprocRegistry.Unlock()
+	if _, ok := inprocRegistry.containers[id]; ok {
+		return fmt.Errorf("in-process chaincode %s already registered", id)
+	}
+	inprocRegistry.containers[id] = &inprocContainer{chaincode: cc}
+	return nil
+}
+
+func getInprocContainer(id string) (*inprocContainer, error) {
+	ipc, ok := inprocRegistry.containers[id]
+	if !ok {
+		return nil, fmt.Errorf("in-process chaincode %s not registered", id)
+	}
+	return ipc, nil
+}
+
+//inprocVM is a vm running registered chaincodes as goroutines of the peer
+type inprocVM struct {
+}
+
+//there is nothing to build for in-process chaincodes
+func (vm *inprocVM) build(ctxt context.Context, id string, image string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	inprocRegistry.Lock()
+	defer inprocRegistry.Unlock()
+	_, err := getInprocContainer(id)
+	return err
+}
+
+func (vm *inprocVM) start(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool) error {
+	handler, ok := ctxt.Value(streamHandlerKey).(ChaincodeStreamHandler)
+	if !ok {
+		return fmt.Errorf("no chaincode stream handler to start %s with", id)
+	}
+	inprocRegistry.Lock()
+	defer inprocRegistry.Unlock()
+	ipc, err := getInprocContainer(id)
+	if err != nil {
+		return err
+	}
+	if ipc.stream != nil {
+		return fmt.Errorf("in-process chaincode %s already running", id)
+	}
+	peerStream, ccStream := newInprocStreamPair()
+	ipc.stream = peerStream
+	go func() {
+		if err := shim.StartInProc(env, ipc.chaincode, ccStream); err != nil {
+			vmLogger.Error(fmt.Sprintf("in-process chaincode %s ended: %s", id, err))
+		}
+		vm.stopStream(id, ccStream)
+	}()
+	go func() {
+		if err := handler.HandleChaincodeStream(peerStream); err != nil && err != io.EOF {
+			vmLogger.Debug("stream of in-process chaincode %s ended: %s", id, err)
+		}
+		vm.stopStream(id, peerStream)
+	}()
+	vmLogger.Debug("Started in-process chaincode %s", id)
+	return nil
+}
+
+func (vm *inprocVM) stop(ctxt context.Context, id string, timeout uint, dontkill bool, dontremove bool) error {
+	inprocRegistry.Lock()
+	ipc, err := getInprocContainer(id)
+	if err != nil {
+		inprocRegistry.Unlock()
+		return err
+	}
+	stream := ipc.stream
+	inprocRegistry.Unlock()
+	if stream == nil {
+		return fmt.Errorf("in-process chaincode %s not running", id)
+	}
+	vm.stopStream(id, stream)
+	vmLogger.Debug("Stopped in-process chaincode %s", id)
+	return nil
+}
+
+func (vm *inprocVM) status(ctxt context.Context, id string) (string, error) {
+	inprocRegistry.Lock()
+	defer inprocRegistry.Unlock()
+	ipc, err := getInprocContainer(id)
+	if err != nil {
+		return StatusMissing, nil
+	}
+	if ipc.stream != nil {
+		return "running", nil
+	}
+	return "stopped", nil
+}
+
+//ready is a no-op, in-process chaincodes need no notice of registration
+func (vm *inprocVM) ready(ctxt context.Context, id string) error {
+	return nil
+}
+
+//stopStream closes the streams of the chaincode and marks it stopped unless
+//it was restarted meanwhile
+func (vm *inprocVM) stopStream(id string, stream *inprocStream) {
+	stream.close()
+	inprocRegistry.Lock()
+	defer inprocRegistry.Unlock()
+	if ipc, err := getInprocContainer(id); err == nil && ipc.stream != nil && ipc.stream.closed == stream.closed {
+		ipc.stream = nil
+	}
+}
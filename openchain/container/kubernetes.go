@@ -0,0 +1,464 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// kubeRegisteredCondition is the readiness gate of chaincode pods. The peer
+// sets it once the chaincode in the pod has registered, so that the pod only
+// reports ready when the chaincode can actually serve.
+const kubeRegisteredCondition = "openchain.org/registered"
+
+// kubeServiceAccountDir holds the credentials of pods in a cluster
+const kubeServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeClient calls the Kubernetes API server
+type kubeClient struct {
+	server    string
+	token     string
+	namespace string
+	http      *http.Client
+}
+
+// kubeError is an unsuccessful response of the API server
+type kubeError struct {
+	Code    int
+	Message string
+}
+
+func (e *kubeError) Error() string {
+	return fmt.Sprintf("Kubernetes API error %d: %s", e.Code, e.Message)
+}
+
+func isKubeError(err error, code int) bool {
+	kerr, ok := err.(*kubeError)
+	return ok && kerr.Code == code
+}
+
+// newKubeClient creates a client for vm.kubernetes.apiServer or, if that is
+// not set, for the cluster the peer runs in
+func newKubeClient() (*kubeClient, error) {
+	server := viper.GetString("vm.kubernetes.apiServer")
+	tokenFile := viper.GetString("vm.kubernetes.token.file")
+	caFile := viper.GetString("vm.kubernetes.ca.file")
+	namespace := viper.GetString("vm.kubernetes.namespace")
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, fmt.Errorf("vm.kubernetes.apiServer is not set and the peer does not run in a Kubernetes cluster")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+		if tokenFile == "" {
+			tokenFile = filepath.Join(kubeServiceAccountDir, "token")
+		}
+		if caFile == "" {
+			caFile = filepath.Join(kubeServiceAccountDir, "ca.crt")
+		}
+		if namespace == "" {
+			if ns, err := ioutil.ReadFile(filepath.Join(kubeServiceAccountDir, "namespace")); err == nil {
+				namespace = strings.TrimSpace(string(ns))
+			}
+		}
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	client := &kubeClient{server: strings.TrimSuffix(server, "/"), namespace: namespace, http: &http.Client{Timeout: 30 * time.Second}}
+	if tokenFile != "" {
+		token, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading Kubernetes token: %s", err)
+		}
+		client.token = strings.TrimSpace(string(token))
+	}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading Kubernetes CA certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificates found in %s", caFile)
+		}
+		client.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return client, nil
+}
+
+// do sends in as the JSON body of a request and decodes the response into
+// out, either of which may be nil
+func (c *kubeClient) do(method, path, contentType string, in, out interface{}) error {
+	body, err := c.send(method, path, contentType, in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if out != nil {
+		return json.NewDecoder(body).Decode(out)
+	}
+	return nil
+}
+
+// send sends in as the JSON body of a request, if it is not nil, and returns
+// the body of a successful response for the caller to close
+func (c *kubeClient) send(method, path, contentType string, in interface{}) (io.ReadCloser, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		status := struct {
+			Message string `json:"message"`
+		}{}
+		data, _ := ioutil.ReadAll(resp.Body)
+		if json.Unmarshal(data, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &kubeError{Code: resp.StatusCode, Message: status.Message}
+	}
+	return resp.Body, nil
+}
+
+func (c *kubeClient) podsPath() string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/pods", c.namespace)
+}
+
+func (c *kubeClient) podPath(name string) string {
+	return c.podsPath() + "/" + name
+}
+
+// The subset of the Kubernetes pod API the peer uses
+type (
+	kubePod struct {
+		APIVersion string        `json:"apiVersion,omitempty"`
+		Kind       string        `json:"kind,omitempty"`
+		Metadata   kubeMetadata  `json:"metadata"`
+		Spec       *kubePodSpec  `json:"spec,omitempty"`
+		Status     kubePodStatus `json:"status,omitempty"`
+	}
+	kubeMetadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace,omitempty"`
+		Labels      map[string]string `json:"labels,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+	kubePodSpec struct {
+		RestartPolicy    string              `json:"restartPolicy"`
+		ReadinessGates   []kubeReadinessGate `json:"readinessGates,omitempty"`
+		ImagePullSecrets []kubeName          `json:"imagePullSecrets,omitempty"`
+		Containers       []kubeContainer     `json:"containers"`
+	}
+	kubeReadinessGate struct {
+		ConditionType string `json:"conditionType"`
+	}
+	kubeName struct {
+		Name string `json:"name"`
+	}
+	kubeContainer struct {
+		Name      string            `json:"name"`
+		Image     string            `json:"image"`
+		Args      []string          `json:"args,omitempty"`
+		Env       []kubeEnvVar      `json:"env,omitempty"`
+		Resources kubeResourceLimit `json:"resources,omitempty"`
+	}
+	kubeEnvVar struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	kubeResourceLimit struct {
+		Limits   map[string]string `json:"limits,omitempty"`
+		Requests map[string]string `json:"requests,omitempty"`
+	}
+	kubePodStatus struct {
+		Phase      string             `json:"phase,omitempty"`
+		Conditions []kubePodCondition `json:"conditions,omitempty"`
+	}
+	kubePodCondition struct {
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	}
+)
+
+var kubeInvalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// kubePodName derives a valid pod name from a container id
+func kubePodName(id string) string {
+	name := kubeInvalidNameChars.ReplaceAllString(strings.ToLower(id), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// kubeImage returns the image pods of chaincode id run, which is pushed to
+// vm.kubernetes.registry if set
+func kubeImage(id string) string {
+	if registry := viper.GetString("vm.kubernetes.registry"); registry != "" {
+		return strings.TrimSuffix(registry, "/") + "/" + id
+	}
+	return id
+}
+
+// newChaincodePod describes the pod running chaincode id
+func newChaincodePod(id string, namespace string, args []string, env []string) *kubePod {
+	container := kubeContainer{
+		Name:  "chaincode",
+		Image: kubeImage(id),
+		Args:  args,
+		Resources: kubeResourceLimit{
+			Limits:   viper.GetStringMapString("vm.kubernetes.resources.limits"),
+			Requests: viper.GetStringMapString("vm.kubernetes.resources.requests"),
+		},
+	}
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 {
+			container.Env = append(container.Env, kubeEnvVar{Name: kv[0], Value: kv[1]})
+		}
+	}
+	spec := &kubePodSpec{
+		RestartPolicy:  "Never",
+		ReadinessGates: []kubeReadinessGate{{ConditionType: kubeRegisteredCondition}},
+		Containers:     []kubeContainer{container},
+	}
+	for _, secret := range viper.GetStringSlice("vm.kubernetes.imagePullSecrets") {
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, kubeName{Name: secret})
+	}
+	return &kubePod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: kubeMetadata{
+			Name:        kubePodName(id),
+			Namespace:   namespace,
+			Labels:      map[string]string{"app": "openchain-chaincode"},
+			Annotations: map[string]string{"openchain.org/chaincode": id},
+		},
+		Spec: spec,
+	}
+}
+
+//kubernetesVM is a vm running chaincodes as Kubernetes pods. Images are
+//built on the docker host of the chaincode and pushed to a registry the
+//cluster pulls from.
+type kubernetesVM struct {
+}
+
+func (vm *kubernetesVM) build(ctxt context.Context, id string, image string, args []string, env []string, attachstdin bool, attachstdout bool, reader io.Reader) error {
+	host, err := dockerHostFor(id)
+	if err != nil {
+		return err
+	}
+	client, err := host.newClient()
+	if err != nil {
+		return fmt.Errorf("Error creating docker client: %s", err)
+	}
+	if err = buildImage(client, id, image, reader); err != nil {
+		return err
+	}
+	registry := viper.GetString("vm.kubernetes.registry")
+	if registry == "" {
+		return nil
+	}
+	repo := kubeImage(id)
+	if err = client.TagImage(id, docker.TagImageOptions{Repo: repo, Force: true}); err != nil {
+		return fmt.Errorf("Error tagging image %s as %s: %s", id, repo, err)
+	}
+	auth := docker.AuthConfiguration{
+		Username:      viper.GetString("vm.kubernetes.registryAuth.username"),
+		Password:      viper.GetString("vm.kubernetes.registryAuth.password"),
+		ServerAddress: registry,
+	}
+	outputbuf := bytes.NewBuffer(nil)
+	if err = client.PushImage(docker.PushImageOptions{Name: repo, OutputStream: outputbuf}, auth); err != nil {
+		return fmt.Errorf("Error pushing image %s: %s\nPush output:\n%s", repo, err, outputbuf.String())
+	}
+	vmLogger.Debug("Pushed image %s", repo)
+	return nil
+}
+
+//start creates the pod of the chaincode, replacing the pod of an earlier
+//launch if there is one
+func (vm *kubernetesVM) start(ctxt context.Context, id string, args []string, env []string, attachstdin bool, attachstdout bool) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+	pod := newChaincodePod(id, client.namespace, args, env)
+	err = client.do("POST", client.podsPath(), "application/json", pod, nil)
+	if isKubeError(err, http.StatusConflict) {
+		vmLogger.Debug("Replacing existing pod %s", pod.Metadata.Name)
+		if err = vm.deletePod(client, pod.Metadata.Name, 0); err != nil {
+			return err
+		}
+		// Deletion completes asynchronously
+		deadline := time.Now().Add(viper.GetDuration("vm.kubernetes.deleteTimeout"))
+		for {
+			err = client.do("POST", client.podsPath(), "application/json", pod, nil)
+			if !isKubeError(err, http.StatusConflict) || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Error creating pod %s: %s", pod.Metadata.Name, err)
+	}
+	vmLogger.Debug("Created pod %s in namespace %s", pod.Metadata.Name, client.namespace)
+	if captureEnabled() {
+		go captureKubernetesLogs(client, id, pod.Metadata.Name)
+	}
+	return nil
+}
+
+// captureKubernetesLogs captures the output of the pod of the chaincode
+// until its container exits. The API server merges stdout and stderr.
+func captureKubernetesLogs(client *kubeClient, id string, name string) {
+	var body io.ReadCloser
+	var err error
+	for {
+		body, err = client.send("GET", client.podPath(name)+"/log?follow=true", "", nil)
+		// The API server answers Bad Request until the container started
+		if !isKubeError(err, http.StatusBadRequest) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		vmLogger.Warning("Error following the output of pod %s: %s", name, err)
+		return
+	}
+	defer body.Close()
+	log, err := openChaincodeLog(id)
+	if err != nil {
+		vmLogger.Error("Error opening the log of pod %s: %s", name, err)
+		return
+	}
+	defer closeChaincodeLog(id, log)
+	captureStream(log, "stdout", body)
+	vmLogger.Debug("Pod %s exited, stopped capturing its output", name)
+}
+
+//stop deletes the pod of the chaincode, giving it timeout seconds to exit
+func (vm *kubernetesVM) stop(ctxt context.Context, id string, timeout uint, dontkill bool, dontremove bool) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+	return vm.deletePod(client, kubePodName(id), timeout)
+}
+
+func (vm *kubernetesVM) deletePod(client *kubeClient, name string, gracePeriod uint) error {
+	err := client.do("DELETE", fmt.Sprintf("%s?gracePeriodSeconds=%d", client.podPath(name), gracePeriod), "", nil, nil)
+	if err != nil && !isKubeError(err, http.StatusNotFound) {
+		return fmt.Errorf("Error deleting pod %s: %s", name, err)
+	}
+	vmLogger.Debug("Deleted pod %s", name)
+	return nil
+}
+
+//status reports the phase of the pod, "missing" if there is none
+func (vm *kubernetesVM) status(ctxt context.Context, id string) (string, error) {
+	client, err := newKubeClient()
+	if err != nil {
+		return "", err
+	}
+	pod := &kubePod{}
+	err = client.do("GET", client.podPath(kubePodName(id)), "", nil, pod)
+	if isKubeError(err, http.StatusNotFound) {
+		return StatusMissing, nil
+	} else if err != nil {
+		return "", err
+	}
+	return pod.Status.Phase, nil
+}
+
+//ready sets the readiness gate of the pod once the chaincode registered
+func (vm *kubernetesVM) ready(ctxt context.Context, id string) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+	patch := map[string]interface{}{
+		"status": kubePodStatus{Conditions: []kubePodCondition{{Type: kubeRegisteredCondition, Status: "True"}}},
+	}
+	if err = client.do("PATCH", client.podPath(kubePodName(id))+"/status", "application/strategic-merge-patch+json", patch, nil); err != nil {
+		return fmt.Errorf("Error marking pod of %s ready: %s", id, err)
+	}
+	return nil
+}
+
+// CheckKubernetes checks that the Kubernetes API server launching the
+// chaincode pods responds and lets the peer list pods
+func CheckKubernetes() error {
+	client, err := newKubeClient()
+	if err != nil {
+		return err
+	}
+	return client.do("GET", client.podsPath()+"?limit=1", "", nil, nil)
+}
+
+// ChaincodeVMType returns the VM type user chaincodes run in, as
+// configured by vm.launcher
+func ChaincodeVMType() string {
+	if strings.EqualFold(viper.GetString("vm.launcher"), "kubernetes") {
+		return KUBERNETES
+	}
+	return DOCKER
+}
@@ -0,0 +1,186 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+)
+
+// fakeKubeAPI serves the pods of a namespace the way the API server does
+type fakeKubeAPI struct {
+	sync.Mutex
+	pods     map[string]*kubePod
+	requests []string
+	patches  []string
+}
+
+func (api *fakeKubeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.Lock()
+	defer api.Unlock()
+	api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	const prefix = "/api/v1/namespaces/chaincodes/pods"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	switch {
+	case r.Method == "GET" && name == "":
+		w.Write([]byte(`{"items":[]}`))
+	case r.Method == "POST":
+		pod := &kubePod{}
+		json.NewDecoder(r.Body).Decode(pod)
+		if _, exists := api.pods[pod.Metadata.Name]; exists {
+			http.Error(w, `{"message":"already exists"}`, http.StatusConflict)
+			return
+		}
+		pod.Status.Phase = "Pending"
+		api.pods[pod.Metadata.Name] = pod
+		json.NewEncoder(w).Encode(pod)
+	case r.Method == "GET":
+		pod, ok := api.pods[name]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pod)
+	case r.Method == "DELETE":
+		if _, ok := api.pods[name]; !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		delete(api.pods, name)
+	case r.Method == "PATCH" && strings.HasSuffix(name, "/status"):
+		data, _ := ioutil.ReadAll(r.Body)
+		api.patches = append(api.patches, r.Header.Get("Content-Type")+" "+string(data))
+	default:
+		http.Error(w, `{"message":"unsupported"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestKubernetesVM(t *testing.T) {
+	api := &fakeKubeAPI{pods: make(map[string]*kubePod)}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	tokenFile, _ := ioutil.TempFile("", "token")
+	tokenFile.WriteString("secret\n")
+	tokenFile.Close()
+	defer os.Remove(tokenFile.Name())
+
+	viper.Set("vm.launcher", "kubernetes")
+	viper.Set("vm.kubernetes.apiServer", server.URL)
+	viper.Set("vm.kubernetes.token.file", tokenFile.Name())
+	viper.Set("vm.kubernetes.namespace", "chaincodes")
+	viper.Set("vm.kubernetes.registry", "registry.example.com/obc")
+	viper.Set("vm.kubernetes.imagePullSecrets", []string{"regcred"})
+	viper.Set("vm.kubernetes.resources.limits", map[string]string{"cpu": "1", "memory": "512Mi"})
+	// Following the pod logs would add requests to those checked below
+	defer viper.Set("vm.logs.enabled", viper.GetBool("vm.logs.enabled"))
+	viper.Set("vm.logs.enabled", false)
+	defer func() {
+		for _, key := range []string{"vm.launcher", "vm.kubernetes.apiServer", "vm.kubernetes.token.file", "vm.kubernetes.namespace",
+			"vm.kubernetes.registry", "vm.kubernetes.imagePullSecrets", "vm.kubernetes.resources.limits"} {
+			viper.Set(key, nil)
+		}
+	}()
+
+	if ChaincodeVMType() != KUBERNETES {
+		t.Fatalf("Expected chaincodes to be launched as pods")
+	}
+	if err := CheckKubernetes(); err != nil {
+		t.Fatalf("Expected the API server to be reachable: %s", err)
+	}
+
+	id := "dev-JDoe-mycc:1"
+	name := kubePodName(id)
+	if name != "dev-jdoe-mycc-1" {
+		t.Fatalf("Expected pod name dev-jdoe-mycc-1, got %s", name)
+	}
+	ctxt := context.Background()
+	start := StartImageReq{ID: id, Args: []string{"chaincode", "-peer.address=peer:30303"}, Env: []string{"OPENCHAIN_CHAINCODE_ID_NAME=mycc"}}
+	if resp, err := VMCProcess(ctxt, KUBERNETES, start); err != nil || resp.(VMCResp).Err != nil {
+		t.Fatalf("Error starting pod: %v %v", err, resp)
+	}
+
+	pod := api.pods[name]
+	if pod == nil {
+		t.Fatalf("Expected pod %s to be created", name)
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != "registry.example.com/obc/"+id || container.Args[1] != "-peer.address=peer:30303" ||
+		container.Env[0] != (kubeEnvVar{Name: "OPENCHAIN_CHAINCODE_ID_NAME", Value: "mycc"}) {
+		t.Fatalf("Unexpected container %+v", container)
+	}
+	if container.Resources.Limits["memory"] != "512Mi" || pod.Spec.ImagePullSecrets[0].Name != "regcred" {
+		t.Fatalf("Expected the configured resources and pull secrets, got %+v and %+v", container.Resources, pod.Spec.ImagePullSecrets)
+	}
+	if pod.Spec.ReadinessGates[0].ConditionType != kubeRegisteredCondition {
+		t.Fatalf("Expected the pod to be gated on registration, got %+v", pod.Spec.ReadinessGates)
+	}
+
+	resp, _ := VMCProcess(ctxt, KUBERNETES, StatusReq{ID: id})
+	if status := resp.(VMCResp).Resp; status != "Pending" {
+		t.Fatalf("Expected the pod to be Pending, got %v", status)
+	}
+
+	if resp, _ := VMCProcess(ctxt, KUBERNETES, ReadyReq{ID: id}); resp.(VMCResp).Err != nil {
+		t.Fatalf("Error marking pod ready: %s", resp.(VMCResp).Err)
+	}
+	if len(api.patches) != 1 || !strings.HasPrefix(api.patches[0], "application/strategic-merge-patch+json") ||
+		!strings.Contains(api.patches[0], `{"type":"openchain.org/registered","status":"True"}`) {
+		t.Fatalf("Expected the registered condition to be patched, got %v", api.patches)
+	}
+
+	// Launching again replaces the pod of the earlier launch
+	api.requests = nil
+	if resp, _ := VMCProcess(ctxt, KUBERNETES, start); resp.(VMCResp).Err != nil {
+		t.Fatalf("Error restarting pod: %s", resp.(VMCResp).Err)
+	}
+	expected := "POST /api/v1/namespaces/chaincodes/pods, DELETE /api/v1/namespaces/chaincodes/pods/dev-jdoe-mycc-1, POST /api/v1/namespaces/chaincodes/pods"
+	if requests := strings.Join(api.requests, ", "); requests != expected {
+		t.Fatalf("Expected requests %s, got %s", expected, requests)
+	}
+
+	if resp, _ := VMCProcess(ctxt, KUBERNETES, StopImageReq{ID: id}); resp.(VMCResp).Err != nil {
+		t.Fatalf("Error stopping pod: %s", resp.(VMCResp).Err)
+	}
+	resp, _ = VMCProcess(ctxt, KUBERNETES, StatusReq{ID: id})
+	if status := resp.(VMCResp).Resp; status != StatusMissing {
+		t.Fatalf("Expected the pod to be gone, got %v", status)
+	}
+	if resp, _ := VMCProcess(ctxt, KUBERNETES, StopImageReq{ID: id}); resp.(VMCResp).Err != nil {
+		t.Fatalf("Expected stopping a missing pod to succeed: %s", resp.(VMCResp).Err)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/spf13/viper"
+)
+
+// LogLine is a line written by a chaincode container to one of its streams
+type LogLine struct {
+	Time   time.Time
+	Stream string
+	Text   string
+}
+
+// chaincodeLog is the file the output of a chaincode container is captured
+// to. Once the file reaches maxSize bytes it is renamed with the suffix .1,
+// the earlier rotated files being shifted to .2 and so on, and the oldest
+// beyond maxFiles removed.
+type chaincodeLog struct {
+	sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+	captures int // captures writing to the log
+}
+
+// chaincodeLogs holds the logs being captured, by container id
+var chaincodeLogs = struct {
+	sync.Mutex
+	logs map[string]*chaincodeLog
+}{logs: make(map[string]*chaincodeLog)}
+
+// ChaincodeLogFile returns the file the output of the container id is
+// captured to
+func ChaincodeLogFile(id string) string {
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "chaincodelogs", strings.Replace(id, ":", "_", -1)+".log")
+}
+
+// captureEnabled reports whether the output of chaincode containers is
+// captured, as configured by vm.logs.enabled
+func captureEnabled() bool {
+	return viper.GetBool("vm.logs.enabled")
+}
+
+// openChaincodeLog returns the log of the container id, opening it unless
+// another capture of the container is still writing to it
+func openChaincodeLog(id string) (*chaincodeLog, error) {
+	chaincodeLogs.Lock()
+	defer chaincodeLogs.Unlock()
+	if log, ok := chaincodeLogs.logs[id]; ok {
+		log.captures++
+		return log, nil
+	}
+	log := &chaincodeLog{
+		path:     ChaincodeLogFile(id),
+		maxSize:  int64(viper.GetInt("vm.logs.maxSize")),
+		maxFiles: viper.GetInt("vm.logs.maxFiles"),
+		captures: 1,
+	}
+	if err := os.MkdirAll(filepath.Dir(log.path), 0755); err != nil {
+		return nil, err
+	}
+	if err := log.open(); err != nil {
+		return nil, err
+	}
+	chaincodeLogs.logs[id] = log
+	return log, nil
+}
+
+// closeChaincodeLog closes the log of the container id once the last
+// capture writing to it is done
+func closeChaincodeLog(id string, log *chaincodeLog) {
+	chaincodeLogs.Lock()
+	defer chaincodeLogs.Unlock()
+	if log.captures--; log.captures > 0 {
+		return
+	}
+	delete(chaincodeLogs.logs, id)
+	log.Lock()
+	defer log.Unlock()
+	log.file.Close()
+}
+
+func (log *chaincodeLog) open() error {
+	file, err := os.OpenFile(log.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	log.file, log.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the current file of the log and starts a new one
+func (log *chaincodeLog) rotate() error {
+	log.file.Close()
+	if log.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", log.path, log.maxFiles))
+		for i := log.maxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", log.path, i), fmt.Sprintf("%s.%d", log.path, i+1))
+		}
+		os.Rename(log.path, log.path+".1")
+	} else {
+		os.Remove(log.path)
+	}
+	return log.open()
+}
+
+// write appends a line of stream to the log, rotating it first if the line
+// would take it past maxSize
+func (log *chaincodeLog) write(stream string, text string) error {
+	log.Lock()
+	defer log.Unlock()
+	line := fmt.Sprintf("%s %s %s\n", time.Now().UTC().Format(time.RFC3339Nano), stream, text)
+	if log.maxSize > 0 && log.size > 0 && log.size+int64(len(line)) > log.maxSize {
+		if err := log.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := log.file.WriteString(line)
+	log.size += int64(n)
+	return err
+}
+
+// captureStream copies the lines read from r to the log as lines of stream
+// until r ends
+func captureStream(log *chaincodeLog, stream string, r io.Reader) {
+	reader := bufio.NewReader(r)
+	for {
+		text, err := reader.ReadString('\n')
+		if text = strings.TrimRight(text, "\r\n"); text != "" || err == nil {
+			if werr := log.write(stream, text); werr != nil {
+				vmLogger.Error("Error writing to %s: %s", log.path, werr)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// captureDockerLogs captures the output of the container id, started at
+// since, until the container stops
+func captureDockerLogs(client *docker.Client, id string, containerID string, since time.Time) {
+	log, err := openChaincodeLog(id)
+	if err != nil {
+		vmLogger.Error("Error opening the log of container %s: %s", id, err)
+		return
+	}
+	defer closeChaincodeLog(id, log)
+	stdout, stdoutWriter := io.Pipe()
+	stderr, stderrWriter := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { captureStream(log, "stdout", stdout); wg.Done() }()
+	go func() { captureStream(log, "stderr", stderr); wg.Done() }()
+	err = client.Logs(docker.LogsOptions{
+		Container:    containerID,
+		OutputStream: stdoutWriter,
+		ErrorStream:  stderrWriter,
+		Follow:       true,
+		Stdout:       true,
+		Stderr:       true,
+		Since:        since.Unix(),
+	})
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	wg.Wait()
+	if err != nil {
+		vmLogger.Warning("Stopped capturing the output of container %s: %s", id, err)
+		return
+	}
+	vmLogger.Debug("Container %s exited, stopped capturing its output", id)
+}
+
+// ReadChaincodeLogs returns the captured lines of the container id written
+// at or after since, at most the last tail of them if tail is not 0
+func ReadChaincodeLogs(id string, tail int, since time.Time) ([]LogLine, error) {
+	path := ChaincodeLogFile(id)
+	var files []string
+	for i := 1; ; i++ {
+		rotated := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(rotated); err != nil {
+			break
+		}
+		files = append([]string{rotated}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No output captured for container %s", id)
+	}
+	var lines []LogLine
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line, ok := parseLogLine(scanner.Text())
+			if !ok || line.Time.Before(since) {
+				continue
+			}
+			lines = append(lines, line)
+			if tail > 0 && len(lines) > tail {
+				lines = lines[1:]
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", name, err)
+		}
+	}
+	return lines, nil
+}
+
+// parseLogLine parses a line of a chaincode log, the time, the stream and
+// the text separated by spaces
+func parseLogLine(s string) (LogLine, bool) {
+	fields := strings.SplitN(s, " ", 3)
+	if len(fields) < 2 {
+		return LogLine{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return LogLine{}, false
+	}
+	line := LogLine{Time: t, Stream: fields[1]}
+	if len(fields) == 3 {
+		line.Text = fields[2]
+	}
+	return line, true
+}
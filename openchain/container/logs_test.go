@@ -0,0 +1,96 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestChaincodeLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chaincodelogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("peer.fileSystemPath", dir)
+	viper.Set("vm.logs.maxSize", 200)
+	viper.Set("vm.logs.maxFiles", 2)
+	defer func() {
+		for _, key := range []string{"peer.fileSystemPath", "vm.logs.maxSize", "vm.logs.maxFiles"} {
+			viper.Set(key, nil)
+		}
+	}()
+
+	const id = "dev-vp0-mycc"
+	if _, err := ReadChaincodeLogs(id, 0, time.Time{}); err == nil {
+		t.Fatalf("Expected reading the logs of a container never started to fail")
+	}
+
+	log, err := openChaincodeLog(id)
+	if err != nil {
+		t.Fatalf("Error opening the log: %s", err)
+	}
+	var output []string
+	for i := 0; i < 20; i++ {
+		output = append(output, fmt.Sprintf("line %d", i))
+	}
+	captureStream(log, "stdout", strings.NewReader(strings.Join(output, "\n")))
+	captureStream(log, "stderr", strings.NewReader("panic: boom\r\n"))
+	closeChaincodeLog(id, log)
+
+	if _, err := os.Stat(ChaincodeLogFile(id) + ".3"); err == nil {
+		t.Fatalf("Expected at most 2 rotated files to be kept")
+	}
+	lines, err := ReadChaincodeLogs(id, 0, time.Time{})
+	if err != nil {
+		t.Fatalf("Error reading the logs: %s", err)
+	}
+	if len(lines) == 0 || len(lines) >= 21 {
+		t.Fatalf("Expected the oldest lines to be rotated away, got %d lines", len(lines))
+	}
+	for i, line := range lines[:len(lines)-1] {
+		if expected := output[20-len(lines)+1+i]; line.Stream != "stdout" || line.Text != expected {
+			t.Fatalf("Expected stdout line %q, got %s line %q", expected, line.Stream, line.Text)
+		}
+	}
+
+	lines, err = ReadChaincodeLogs(id, 2, time.Time{})
+	if err != nil {
+		t.Fatalf("Error reading the logs: %s", err)
+	}
+	if len(lines) != 2 || lines[0].Text != "line 19" || lines[1].Stream != "stderr" || lines[1].Text != "panic: boom" {
+		t.Fatalf("Expected the last 2 lines, got %v", lines)
+	}
+
+	lines, err = ReadChaincodeLogs(id, 0, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Error reading the logs: %s", err)
+	}
+	if len(lines) != 0 {
+		t.Fatalf("Expected no line written after since, got %d", len(lines))
+	}
+}
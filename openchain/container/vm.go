@@ -40,18 +40,9 @@ import (
 )
 
 func newDockerClient() (client *docker.Client, err error) {
-	//QQ: is this ok using config properties here so deep ? ie, should we read these in main and stow them away ?
-	endpoint := viper.GetString("vm.endpoint")
-	vmLogger.Info("Creating VM with endpoint: %s", endpoint)
-	if viper.GetBool("vm.docker.tls.enabled") {
-		cert := viper.GetString("vm.docker.tls.cert.file")
-		key := viper.GetString("vm.docker.tls.key.file")
-		ca := viper.GetString("vm.docker.tls.ca.file")
-		client, err = docker.NewTLSClient(endpoint, cert, key, ca)
-	} else {
-		client, err = docker.NewClient(endpoint)
-	}
-	return
+	host := getDockerHosts()[localDockerHost]
+	vmLogger.Info("Creating VM with endpoint: %s", host.endpoint)
+	return host.connect()
 }
 
 // VM implemenation of VM management functionality.
@@ -139,17 +130,7 @@ func GetChaincodePackageBytes(spec *pb.ChaincodeSpec) ([]byte, error) {
 
 // Builds the Chaincode image using the supplied Dockerfile package contents
 func (vm *VM) buildChaincodeContainerUsingDockerfilePackageBytes(spec *pb.ChaincodeSpec, code []byte) error {
-	outputbuf := bytes.NewBuffer(nil)
-	vmName := spec.ChaincodeID.Name
-	inputbuf := bytes.NewReader(code)
-	opts := docker.BuildImageOptions{
-		Name:         vmName,
-		Pull:         true,
-		InputStream:  inputbuf,
-		OutputStream: outputbuf,
-	}
-	if err := vm.Client.BuildImage(opts); err != nil {
-		vmLogger.Debug(fmt.Sprintf("Failed Chaincode docker build:\n%s\n", outputbuf.String()))
+	if err := buildImage(vm.Client, spec.ChaincodeID.Name, GetImageFromPackage(code), bytes.NewReader(code)); err != nil {
 		return fmt.Errorf("Error building Chaincode container: %s", err)
 	}
 	return nil
@@ -259,15 +240,17 @@ func writeChaincodePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
 		return fmt.Errorf("could not get chaincode name from path %s", urlLocation)
 	}
 
-	//let the executable's name be chaincode ID's name
-	newRunLine := fmt.Sprintf("RUN go install %s && cp src/github.com/openblockchain/obc-peer/openchain.yaml $GOPATH/bin && mv $GOPATH/bin/%s $GOPATH/bin/%s", urlLocation, chaincodeGoName, spec.ChaincodeID.Name)
+	//the executable has the same name for every chaincode so that the
+	//package, and the image built from it, only depends on the code
+	newRunLine := fmt.Sprintf("RUN go build -o $GOPATH/bin/%s %s && cp src/github.com/openblockchain/obc-peer/openchain.yaml $GOPATH/bin", ChaincodeExecutable, urlLocation)
 
 	dockerFileContents := fmt.Sprintf("%s\n%s", viper.GetString("chaincode.golang.Dockerfile"), newRunLine)
 	dockerFileSize := int64(len([]byte(dockerFileContents)))
 
-	//Make headers identical by using zero time
-	var zeroTime time.Time
-	tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: dockerFileSize, ModTime: zeroTime, AccessTime: zeroTime, ChangeTime: zeroTime})
+	//Make headers identical everywhere
+	h := &tar.Header{Name: "Dockerfile", Size: dockerFileSize}
+	normalizeHeader(h)
+	tw.WriteHeader(h)
 	tw.Write([]byte(dockerFileContents))
 	err := writeGopathSrc(tw, urlLocation)
 	if err != nil {
@@ -354,11 +337,8 @@ func writeGopathSrc(tw *tar.Writer, excludeDir string) error {
 			vmLogger.Error(fmt.Sprintf("Error getting FileInfoHeader: %s", err))
 			return err
 		}
-		//Let's take the variance out of the tar, make headers identical everywhere by using zero time
-		var zeroTime time.Time
-		h.AccessTime = zeroTime
-		h.ModTime = zeroTime
-		h.ChangeTime = zeroTime
+		//Let's take the variance out of the tar, make headers identical everywhere
+		normalizeHeader(h)
 		h.Name = newPath
 		if err = tw.WriteHeader(h); err != nil {
 			vmLogger.Error(fmt.Sprintf("Error writing header: %s", err))
@@ -0,0 +1,124 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package crosschain lets chaincodes read the committed state of chains other
+// than their own. Every value read comes with a proof against the state hash
+// of a block of its chain, which is checked before the value is used.
+package crosschain
+
+import (
+	"fmt"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var logger = logging.MustGetLogger("crosschain")
+
+// GetStateProof returns the committed value of a key of a chaincode on a
+// chain along with its proof, once checked. Chains hosted by the peer are
+// read from their ledger. Other chains are read from the peer trusted for
+// them in chaincode.crosschain.peers, unless localOnly is set.
+func GetStateProof(chainID string, chaincodeID string, key string, localOnly bool) (*pb.StateProof, error) {
+	if err := db.CheckChainID(chainID); err != nil {
+		return nil, err
+	}
+	hosted, err := isHosted(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if hosted {
+		chainLedger, err := ledger.GetChainLedger(chainID)
+		if err != nil {
+			return nil, err
+		}
+		stateProof, err := chainLedger.GetStateProof(chaincodeID, key)
+		if err != nil {
+			return nil, err
+		}
+		return stateProof, chainLedger.VerifyStateProof(stateProof)
+	}
+	if localOnly {
+		return nil, fmt.Errorf("Chain [%s] is not hosted by this peer", chainID)
+	}
+	return getRemoteStateProof(chainID, chaincodeID, key)
+}
+
+// isHosted returns whether the peer has a ledger for the chain
+func isHosted(chainID string) (bool, error) {
+	if chainID == "" {
+		return true, nil
+	}
+	chainIDs, err := ledger.GetChainIDs()
+	if err != nil {
+		return false, err
+	}
+	for _, hostedChainID := range chainIDs {
+		if hostedChainID == chainID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getRemoteStateProof gets the proof of a key from the peer trusted for the
+// chain. The peer is trusted to report the state hash of the chain, the value
+// is checked against that state hash.
+func getRemoteStateProof(chainID string, chaincodeID string, key string) (*pb.StateProof, error) {
+	address := viper.GetStringMapString("chaincode.crosschain.peers")[chainID]
+	if address == "" {
+		return nil, fmt.Errorf("Chain [%s] is not hosted by this peer and no peer is trusted for it", chainID)
+	}
+	conn, err := peer.NewPeerClientConnectionWithAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to peer %s of chain [%s]: %s", address, chainID, err)
+	}
+	defer conn.Close()
+	logger.Debug("Getting state proof of key [%s] of chaincode [%s] on chain [%s] from %s", key, chaincodeID, chainID, address)
+	stateProof, err := pb.NewOpenchainClient(conn).GetStateProof(context.Background(), &pb.StateProofRequest{ChainID: chainID, ChaincodeID: chaincodeID, Key: key})
+	if err != nil {
+		return nil, fmt.Errorf("Error getting state proof from peer %s of chain [%s]: %s", address, chainID, err)
+	}
+	if err := VerifyStateProof(stateProof, chainID, chaincodeID, key); err != nil {
+		return nil, err
+	}
+	return stateProof, nil
+}
+
+// VerifyStateProof checks that a state proof is for the given key and that its
+// value is the value of the key in the state with the state hash of the proof
+func VerifyStateProof(stateProof *pb.StateProof, chainID string, chaincodeID string, key string) error {
+	if stateProof.ChainID != chainID || stateProof.ChaincodeID != chaincodeID || stateProof.Key != key {
+		return fmt.Errorf("State proof is for key [%s] of chaincode [%s] on chain [%s]", stateProof.Key, stateProof.ChaincodeID, stateProof.ChainID)
+	}
+	verifier, err := ledger.GetLedger()
+	if err != nil {
+		return err
+	}
+	if err := verifier.VerifyStateProof(stateProof); err != nil {
+		return fmt.Errorf("Invalid state proof of chain [%s]: %s", chainID, err)
+	}
+	return nil
+}
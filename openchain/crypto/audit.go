@@ -0,0 +1,80 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package crypto
+
+import (
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	obc "github.com/openblockchain/obc-peer/protos"
+)
+
+// Auditors do not hold the chain key. They obtain from the ECA the keys of
+// the confidential transactions of the chaincodes designated to them, which
+// give read-only access to the transactions and to the state of the chaincodes.
+
+// DecryptTransaction decrypts a confidential transaction with its transaction
+// key, as issued to auditors by the ECA. It returns a decrypted clone of tx.
+func DecryptTransaction(tx *obc.Transaction, txKey []byte) (*obc.Transaction, error) {
+	if tx.ConfidentialityLevel != obc.ConfidentialityLevel_CONFIDENTIAL {
+		return nil, utils.ErrInvalidConfidentialityLevel
+	}
+
+	clone := proto.Clone(tx).(*obc.Transaction)
+	if err := decryptTx(clone, txKey); err != nil {
+		return nil, err
+	}
+
+	return clone, nil
+}
+
+// NewStateDecryptor returns a StateEncryptor decrypting the state of a
+// confidential chaincode. epochKeys are the transaction keys of the deploy
+// transaction of the chaincode and of its key rotation transactions, in the
+// order they were executed. The StateEncryptor cannot encrypt values, it can
+// encrypt keys to look them up.
+func NewStateDecryptor(epochKeys [][]byte) (StateEncryptor, error) {
+	if len(epochKeys) == 0 {
+		return nil, errors.New("Invalid epoch keys.")
+	}
+
+	return &stateDecryptor{epochKeys}, nil
+}
+
+type stateDecryptor struct {
+	epochKeys [][]byte
+}
+
+func (se *stateDecryptor) Encrypt(msg []byte) ([]byte, error) {
+	return nil, utils.ErrNotImplemented
+}
+
+func (se *stateDecryptor) Decrypt(raw []byte) ([]byte, error) {
+	return decryptState(se.epochKeys, raw)
+}
+
+func (se *stateDecryptor) EncryptKey(key string) (string, error) {
+	return encryptStateKey(se.epochKeys[0], key)
+}
+
+func (se *stateDecryptor) DecryptKey(ct string) (string, error) {
+	return decryptStateKey(se.epochKeys[0], ct)
+}
@@ -7,17 +7,26 @@ import (
 )
 
 func (client *clientImpl) encryptTx(tx *obc.Transaction) error {
+	if err := encryptTransaction(client.node.enrollChainKey, tx); err != nil {
+		return err
+	}
+
+	client.node.debug("Encrypted ChaincodeID [% x].", tx.ChaincodeID)
+	client.node.debug("Encrypted Payload [% x].", tx.Payload)
+	client.node.debug("Encrypted Metadata [% x].", tx.Metadata)
+
+	return nil
+}
 
+// encryptTransaction encrypts the confidential fields of tx under the key
+// derived from the chain key and the nonce of the transaction
+func encryptTransaction(chainKey []byte, tx *obc.Transaction) error {
 	if len(tx.Nonce) == 0 {
 		return errors.New("Failed encrypting payload. Invalid nonce.")
 	}
 
 	// Derive key
-	txKey := utils.HMAC(client.node.enrollChainKey, tx.Nonce)
-
-	//	client.node.info("Deriving from :", utils.EncodeBase64(client.node.enrollChainKey))
-	//	client.node.info("Nonce  ", utils.EncodeBase64(tx.Nonce))
-	//	client.node.info("Derived key  ", utils.EncodeBase64(txKey))
+	txKey := utils.HMAC(chainKey, tx.Nonce)
 
 	// Encrypt Payload
 	payloadKey := utils.HMACTruncated(txKey, []byte{1}, utils.AESKeyLength)
@@ -45,9 +54,15 @@ func (client *clientImpl) encryptTx(tx *obc.Transaction) error {
 		tx.Metadata = encryptedMetadata
 	}
 
-	client.node.debug("Encrypted ChaincodeID [% x].", tx.ChaincodeID)
-	client.node.debug("Encrypted Payload [% x].", tx.Payload)
-	client.node.debug("Encrypted Metadata [% x].", tx.Metadata)
+	// Encrypt AttributesKey
+	if len(tx.AttributesKey) != 0 {
+		attributesKeyKey := utils.HMACTruncated(txKey, []byte{4}, utils.AESKeyLength)
+		encryptedAttributesKey, err := utils.CBCPKCS7Encrypt(attributesKeyKey, tx.AttributesKey)
+		if err != nil {
+			return err
+		}
+		tx.AttributesKey = encryptedAttributesKey
+	}
 
 	return nil
 }
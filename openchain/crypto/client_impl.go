@@ -94,6 +94,24 @@ func (client *clientImpl) NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeIn
 	return client.newChaincodeQueryUsingTCert(chaincodeInvocation, uuid, tCertHandler, nil)
 }
 
+// NewChaincodeKeyRotation is used to rotate the key a confidential chaincode's state is encrypted under.
+func (client *clientImpl) NewChaincodeKeyRotation(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string) (*obc.Transaction, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+
+	// Get next available (not yet used) transaction certificate
+	tCertHandler, err := client.tCertPool.GetNextTCert()
+	if err != nil {
+		client.node.error("Failed getting next transaction certificate [%s].", err.Error())
+		return nil, err
+	}
+
+	// Create Transaction
+	return client.newChaincodeKeyRotationUsingTCert(chaincodeInvocation, uuid, tCertHandler, nil)
+}
+
 // DecryptQueryResult is used to decrypt the result of a query transaction
 func (client *clientImpl) DecryptQueryResult(queryTx *obc.Transaction, ct []byte) ([]byte, error) {
 	// Verify that the client is initialized
@@ -172,6 +190,47 @@ func (client *clientImpl) GetTCertificateHandlerNext() (CertificateHandler, erro
 	return handler, nil
 }
 
+// GetTCertPoolSize returns the number of TCerts available without contacting the TCA
+func (client *clientImpl) GetTCertPoolSize() (int, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return 0, utils.ErrNotInitialized
+	}
+
+	return client.tCertPool.Size(), nil
+}
+
+// RefillTCertPool fetches TCerts from the TCA until the pool reaches its high watermark
+func (client *clientImpl) RefillTCertPool() error {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return utils.ErrNotInitialized
+	}
+
+	return client.tCertPool.Refill()
+}
+
+// CoSignTransaction appends a co-signature of tx made with the enrollment certificate
+func (client *clientImpl) CoSignTransaction(tx *obc.Transaction) error {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return utils.ErrNotInitialized
+	}
+
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		client.node.error("Failed marshaling tx [%s].", err.Error())
+		return err
+	}
+	signature, err := client.node.signWithEnrollmentKey(raw)
+	if err != nil {
+		client.node.error("Failed co-signing tx [%s].", err.Error())
+		return err
+	}
+
+	return AttachTransactionCoSignature(tx, client.node.enrollCert.Raw, signature)
+}
+
 // GetTCertHandlerFromDER returns a CertificateHandler whose certificate is the one passed
 func (client *clientImpl) GetTCertificateHandlerFromDER(tCertDER []byte) (CertificateHandler, error) {
 	// Verify that the client is initialized
@@ -257,6 +316,10 @@ func (client *clientImpl) init(id string, pwd []byte) error {
 		return err
 	}
 
+	// Renew the enrollment certificate before it expires
+	client.node.renewed = client.resetTCertOwnerKDFKey
+	client.node.startEnrollmentRenewal()
+
 	// initialized
 	client.isInitialized = true
 
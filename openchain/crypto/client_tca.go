@@ -32,6 +32,7 @@ import (
 	"golang.org/x/net/context"
 	"google/protobuf"
 	"math/big"
+	"os"
 	"time"
 )
 
@@ -73,6 +74,18 @@ func (client *clientImpl) storeTCertOwnerKDFKey() error {
 	return nil
 }
 
+// resetTCertOwnerKDFKey forgets the TCertOwnerKDFKey once the enrollment
+// certificate has been renewed, as the TCA derives it from the enrollment
+// certificate. The key of the renewed certificate is stored on the next refill.
+func (client *clientImpl) resetTCertOwnerKDFKey() {
+	client.tCertOwnerKDFKey = nil
+
+	path := client.node.conf.getPathForAlias(client.node.conf.getTCertOwnerKDFKeyFilename())
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		client.node.error("Failed removing TCertOwnerKDFKey [%s].", err.Error())
+	}
+}
+
 func (client *clientImpl) loadTCertOwnerKDFKey() error {
 	// Load TCertOwnerKDFKey
 	client.node.debug("Loading TCertOwnerKDFKey...")
@@ -121,7 +134,7 @@ func (client *clientImpl) getTCertFromExternalDER(der []byte) (tCert, error) {
 		return nil, err
 	}
 
-	return &tCertImpl{client, x509Cert, nil}, nil
+	return &tCertImpl{client, x509Cert, nil, nil}, nil
 }
 
 func (client *clientImpl) getTCertFromDER(der []byte) (tCert tCert, err error) {
@@ -255,7 +268,12 @@ func (client *clientImpl) getTCertFromDER(der []byte) (tCert tCert, err error) {
 		return
 	}
 
-	tCert = &tCertImpl{client, x509Cert, tempSK}
+	var attributesKey []byte
+	if utils.HasAttributes(x509Cert) {
+		attributesKey = utils.AttributesKey(client.tCertOwnerKDFKey, TCertIndex)
+	}
+
+	tCert = &tCertImpl{client, x509Cert, tempSK, attributesKey}
 
 	return
 }
@@ -425,7 +443,12 @@ func (client *clientImpl) getTCertsFromTCA(num int) error {
 		j++
 		client.node.debug("Certificate [%d] validated.", i)
 
-		client.tCertPool.AddTCert(&tCertImpl{client, x509Cert, tempSK})
+		var attributesKey []byte
+		if utils.HasAttributes(x509Cert) {
+			attributesKey = utils.AttributesKey(client.tCertOwnerKDFKey, TCertIndex)
+		}
+
+		client.tCertPool.AddTCert(&tCertImpl{client, x509Cert, tempSK, attributesKey})
 	}
 
 	if j == 0 {
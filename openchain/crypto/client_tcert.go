@@ -27,6 +27,8 @@ import (
 type tCert interface {
 	GetCertificate() *x509.Certificate
 
+	GetAttributesKey() []byte
+
 	Sign(msg []byte) ([]byte, error)
 
 	Verify(signature, msg []byte) error
@@ -36,12 +38,20 @@ type tCertImpl struct {
 	client *clientImpl
 	cert   *x509.Certificate
 	sk     interface{}
+
+	attributesKey []byte
 }
 
 func (tCert *tCertImpl) GetCertificate() *x509.Certificate {
 	return tCert.cert
 }
 
+// GetAttributesKey returns the key decrypting the attributes embedded in
+// the TCert, nil if the TCert carries no attributes or is not owned.
+func (tCert *tCertImpl) GetAttributesKey() []byte {
+	return tCert.attributesKey
+}
+
 func (tCert *tCertImpl) Sign(msg []byte) ([]byte, error) {
 	if tCert.sk == nil {
 		return nil, utils.ErrNilArgument
@@ -19,6 +19,14 @@ under the License.
 
 package crypto
 
+const (
+	// tCertReuseNever uses every TCert for a single transaction
+	tCertReuseNever = "never"
+
+	// tCertReuseCount uses every TCert for up to security.tcert.reuse.count transactions
+	tCertReuseCount = "count"
+)
+
 type tCertPool interface {
 	init(client *clientImpl) error
 
@@ -29,4 +37,10 @@ type tCertPool interface {
 	GetNextTCert() (tCert, error)
 
 	AddTCert(tCert tCert) error
+
+	// Size returns the number of TCerts currently available
+	Size() int
+
+	// Refill fetches TCerts from the TCA up to the high watermark
+	Refill() error
 }
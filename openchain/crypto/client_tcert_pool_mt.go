@@ -106,6 +106,20 @@ func (tCertPool *tCertPoolMultithreadingImpl) AddTCert(tCert tCert) (err error)
 	return
 }
 
+func (tCertPool *tCertPoolMultithreadingImpl) Size() int {
+	return len(tCertPool.tCertChannel)
+}
+
+func (tCertPool *tCertPoolMultithreadingImpl) Refill() (err error) {
+	// Wake up the filler
+	select {
+	case tCertPool.tCertChannelFeedback <- struct{}{}:
+	default:
+	}
+
+	return
+}
+
 func (tCertPool *tCertPoolMultithreadingImpl) init(client *clientImpl) (err error) {
 	tCertPool.client = client
 
@@ -24,18 +24,31 @@ import (
 	"sync"
 )
 
+type tCertPoolEntry struct {
+	tCert tCert
+	uses  int
+}
+
+// tCertPoolSingleThreadImpl keeps the TCerts in memory. When the number of
+// available TCerts drops to the low watermark, the pool is refilled in the
+// background up to the high watermark. Only an empty pool makes GetNextTCert
+// wait for the TCA.
 type tCertPoolSingleThreadImpl struct {
 	client *clientImpl
 
-	len    int
-	tCerts []tCert
+	low, high int
+	reuse     int
+
+	tCerts []tCertPoolEntry
 	m      sync.Mutex
+
+	// Serializes requests to the TCA
+	fetch     sync.Mutex
+	refilling bool
+	refills   sync.WaitGroup
 }
 
 func (tCertPool *tCertPoolSingleThreadImpl) Start() (err error) {
-	tCertPool.m.Lock()
-	defer tCertPool.m.Unlock()
-
 	tCertPool.client.node.debug("Starting TCert Pool...")
 
 	// Load unused TCerts if any
@@ -48,7 +61,7 @@ func (tCertPool *tCertPoolSingleThreadImpl) Start() (err error) {
 	if len(tCertDERs) == 0 {
 		tCertPool.client.node.debug("No more TCerts in cache! Load new from TCA.")
 
-		tCertPool.client.getTCertsFromTCA(tCertPool.client.node.conf.getTCertBathSize())
+		tCertPool.Refill()
 	} else {
 		tCertPool.client.node.debug("TCerts in cache found! Loading them...")
 
@@ -67,12 +80,21 @@ func (tCertPool *tCertPoolSingleThreadImpl) Start() (err error) {
 }
 
 func (tCertPool *tCertPoolSingleThreadImpl) Stop() (err error) {
+	// Wait for pending refills
+	tCertPool.refills.Wait()
+
 	tCertPool.m.Lock()
 	defer tCertPool.m.Unlock()
 
-	tCertPool.client.node.debug("Found %d unused TCerts...", tCertPool.len)
+	tCertPool.client.node.debug("Found %d unused TCerts...", len(tCertPool.tCerts))
 
-	tCertPool.client.node.ks.storeUnusedTCerts(tCertPool.tCerts[:tCertPool.len])
+	tCerts := make([]tCert, 0, len(tCertPool.tCerts))
+	for _, entry := range tCertPool.tCerts {
+		if entry.uses == 0 {
+			tCerts = append(tCerts, entry.tCert)
+		}
+	}
+	tCertPool.client.node.ks.storeUnusedTCerts(tCerts)
 
 	tCertPool.client.node.debug("Store unused TCerts...done!")
 
@@ -80,19 +102,44 @@ func (tCertPool *tCertPoolSingleThreadImpl) Stop() (err error) {
 }
 
 func (tCertPool *tCertPoolSingleThreadImpl) GetNextTCert() (tCert tCert, err error) {
+	if tCertPool.Size() == 0 {
+		// Nothing left, wait for the TCA
+		if err := tCertPool.Refill(); err != nil {
+
+			return nil, fmt.Errorf("Failed loading TCerts from TCA")
+		}
+	}
+
 	tCertPool.m.Lock()
 	defer tCertPool.m.Unlock()
 
-	if tCertPool.len <= 0 {
-		// Reload
-		if err := tCertPool.client.getTCertsFromTCA(tCertPool.client.node.conf.getTCertBathSize()); err != nil {
+	if len(tCertPool.tCerts) == 0 {
+		return nil, fmt.Errorf("Failed loading TCerts from TCA")
+	}
 
-			return nil, fmt.Errorf("Failed loading TCerts from TCA")
-		}
+	last := len(tCertPool.tCerts) - 1
+	tCertPool.tCerts[last].uses++
+	tCert = tCertPool.tCerts[last].tCert
+	if tCertPool.tCerts[last].uses >= tCertPool.reuse {
+		tCertPool.tCerts[last] = tCertPoolEntry{}
+		tCertPool.tCerts = tCertPool.tCerts[:last]
 	}
 
-	tCert = tCertPool.tCerts[tCertPool.len-1]
-	tCertPool.len--
+	if len(tCertPool.tCerts) <= tCertPool.low && !tCertPool.refilling {
+		tCertPool.refilling = true
+		tCertPool.refills.Add(1)
+		go func() {
+			defer tCertPool.refills.Done()
+
+			if err := tCertPool.Refill(); err != nil {
+				tCertPool.client.node.error("Failed refilling TCert pool: [%s]", err)
+			}
+
+			tCertPool.m.Lock()
+			tCertPool.refilling = false
+			tCertPool.m.Unlock()
+		}()
+	}
 
 	return
 }
@@ -100,19 +147,44 @@ func (tCertPool *tCertPoolSingleThreadImpl) GetNextTCert() (tCert tCert, err err
 func (tCertPool *tCertPoolSingleThreadImpl) AddTCert(tCert tCert) (err error) {
 	tCertPool.client.node.debug("Adding new Cert [% x].", tCert.GetCertificate().Raw)
 
-	tCertPool.len++
-	tCertPool.tCerts[tCertPool.len-1] = tCert
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	// Keep the TCerts in use on top
+	tCertPool.tCerts = append([]tCertPoolEntry{{tCert: tCert}}, tCertPool.tCerts...)
 
 	return nil
 }
 
+func (tCertPool *tCertPoolSingleThreadImpl) Size() int {
+	tCertPool.m.Lock()
+	defer tCertPool.m.Unlock()
+
+	return len(tCertPool.tCerts)
+}
+
+func (tCertPool *tCertPoolSingleThreadImpl) Refill() error {
+	tCertPool.fetch.Lock()
+	defer tCertPool.fetch.Unlock()
+
+	num := tCertPool.high - tCertPool.Size()
+	if num <= 0 {
+		return nil
+	}
+
+	tCertPool.client.node.debug("Refilling [%d] TCerts.", num)
+
+	return tCertPool.client.getTCertsFromTCA(num)
+}
+
 func (tCertPool *tCertPoolSingleThreadImpl) init(client *clientImpl) (err error) {
 	tCertPool.client = client
 
 	tCertPool.client.node.debug("Init TCert Pool...")
 
-	tCertPool.tCerts = make([]tCert, tCertPool.client.node.conf.getTCertBathSize())
-	tCertPool.len = 0
+	tCertPool.low, tCertPool.high = client.node.conf.getTCertPoolWatermarks()
+	tCertPool.reuse = client.node.conf.getTCertReuseCount()
+	tCertPool.tCerts = make([]tCertPoolEntry, 0, tCertPool.high)
 
 	return
 }
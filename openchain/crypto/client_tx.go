@@ -16,7 +16,7 @@ func (client *clientImpl) createTransactionNonce() ([]byte, error) {
 	return nonce, err
 }
 
-func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte, attributesKey []byte) (*obc.Transaction, error) {
 	// Create a new transaction
 	tx, err := obc.NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
 	if err != nil {
@@ -27,6 +27,9 @@ func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeD
 	// Copy metadata from ChaincodeSpec
 	tx.Metadata = chaincodeDeploymentSpec.ChaincodeSpec.Metadata
 
+	// Disclose the attributes key of the TCert to the chaincode
+	tx.AttributesKey = attributesKey
+
 	// Handle confidentiality
 	if chaincodeDeploymentSpec.ChaincodeSpec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
 		// 1. set confidentiality level and nonce
@@ -54,7 +57,7 @@ func (client *clientImpl) createDeployTx(chaincodeDeploymentSpec *obc.ChaincodeD
 	return tx, nil
 }
 
-func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, attributesKey []byte) (*obc.Transaction, error) {
 	/// Create a new transaction
 	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, obc.Transaction_CHAINCODE_EXECUTE)
 	if err != nil {
@@ -65,6 +68,9 @@ func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvo
 	// Copy metadata from ChaincodeSpec
 	tx.Metadata = chaincodeInvocation.ChaincodeSpec.Metadata
 
+	// Disclose the attributes key of the TCert to the chaincode
+	tx.AttributesKey = attributesKey
+
 	// Handle confidentiality
 	if chaincodeInvocation.ChaincodeSpec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
 		// 1. set confidentiality level and nonce
@@ -92,7 +98,7 @@ func (client *clientImpl) createExecuteTx(chaincodeInvocation *obc.ChaincodeInvo
 	return tx, nil
 }
 
-func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte, attributesKey []byte) (*obc.Transaction, error) {
 	// Create a new transaction
 	tx, err := obc.NewChaincodeExecute(chaincodeInvocation, uuid, obc.Transaction_CHAINCODE_QUERY)
 	if err != nil {
@@ -103,6 +109,9 @@ func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvoca
 	// Copy metadata from ChaincodeSpec
 	tx.Metadata = chaincodeInvocation.ChaincodeSpec.Metadata
 
+	// Disclose the attributes key of the TCert to the chaincode
+	tx.AttributesKey = attributesKey
+
 	// Handle confidentiality
 	if chaincodeInvocation.ChaincodeSpec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
 		// 1. set confidentiality level and nonce
@@ -132,7 +141,7 @@ func (client *clientImpl) createQueryTx(chaincodeInvocation *obc.ChaincodeInvoca
 
 func (client *clientImpl) newChaincodeDeployUsingTCert(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
 	// Create a new transaction
-	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce)
+	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce, tCert.GetAttributesKey())
 	if err != nil {
 		client.node.error("Failed creating new deploy transaction [%s].", err.Error())
 		return nil, err
@@ -168,9 +177,26 @@ func (client *clientImpl) newChaincodeDeployUsingTCert(chaincodeDeploymentSpec *
 	return tx, nil
 }
 
+func (client *clientImpl) createKeyRotationTx(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
+	// Only the state of confidential chaincodes is encrypted
+	if chaincodeInvocation.ChaincodeSpec.ConfidentialityLevel != obc.ConfidentialityLevel_CONFIDENTIAL {
+		client.node.error("Failed creating key rotation transaction. Chaincode is not confidential.")
+		return nil, utils.ErrInvalidConfidentialityLevel
+	}
+
+	// The nonce of the transaction defines the key of the new epoch
+	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce, nil)
+	if err != nil {
+		return nil, err
+	}
+	tx.Type = obc.Transaction_CHAINCODE_KEY_ROTATION
+
+	return tx, nil
+}
+
 func (client *clientImpl) newChaincodeExecuteUsingTCert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
 	/// Create a new transaction
-	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce)
+	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce, tCert.GetAttributesKey())
 	if err != nil {
 		client.node.error("Failed creating new execute transaction [%s].", err.Error())
 		return nil, err
@@ -206,9 +232,47 @@ func (client *clientImpl) newChaincodeExecuteUsingTCert(chaincodeInvocation *obc
 	return tx, nil
 }
 
+func (client *clientImpl) newChaincodeKeyRotationUsingTCert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
+	// Create a new transaction
+	tx, err := client.createKeyRotationTx(chaincodeInvocation, uuid, nonce)
+	if err != nil {
+		client.node.error("Failed creating new key rotation transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	// Sign the transaction
+
+	// Append the certificate to the transaction
+	client.node.debug("Appending certificate [% x].", tCert.GetCertificate().Raw)
+	tx.Cert = tCert.GetCertificate().Raw
+
+	// Sign the transaction and append the signature
+	// 1. Marshall tx to bytes
+	rawTx, err := proto.Marshal(tx)
+	if err != nil {
+		client.node.error("Failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+
+	// 2. Sign rawTx and check signature
+	client.node.debug("Signing tx [% x].", rawTx)
+	rawSignature, err := tCert.Sign(rawTx)
+	if err != nil {
+		client.node.error("Failed creating signature [%s].", err.Error())
+		return nil, err
+	}
+
+	// 3. Append the signature
+	tx.Signature = rawSignature
+
+	client.node.debug("Appending signature [% x].", rawSignature)
+
+	return tx, nil
+}
+
 func (client *clientImpl) newChaincodeQueryUsingTCert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, tCert tCert, nonce []byte) (*obc.Transaction, error) {
 	// Create a new transaction
-	tx, err := client.createQueryTx(chaincodeInvocation, uuid, nonce)
+	tx, err := client.createQueryTx(chaincodeInvocation, uuid, nonce, tCert.GetAttributesKey())
 	if err != nil {
 		client.node.error("Failed creating new query transaction [%s].", err.Error())
 		return nil, err
@@ -246,7 +310,7 @@ func (client *clientImpl) newChaincodeQueryUsingTCert(chaincodeInvocation *obc.C
 
 func (client *clientImpl) newChaincodeDeployUsingECert(chaincodeDeploymentSpec *obc.ChaincodeDeploymentSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
 	// Create a new transaction
-	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce)
+	tx, err := client.createDeployTx(chaincodeDeploymentSpec, uuid, nonce, nil)
 	if err != nil {
 		client.node.error("Failed creating new deploy transaction [%s].", err.Error())
 		return nil, err
@@ -284,7 +348,7 @@ func (client *clientImpl) newChaincodeDeployUsingECert(chaincodeDeploymentSpec *
 
 func (client *clientImpl) newChaincodeExecuteUsingECert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
 	/// Create a new transaction
-	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce)
+	tx, err := client.createExecuteTx(chaincodeInvocation, uuid, nonce, nil)
 	if err != nil {
 		client.node.error("Failed creating new execute transaction [%s].", err.Error())
 		return nil, err
@@ -322,7 +386,7 @@ func (client *clientImpl) newChaincodeExecuteUsingECert(chaincodeInvocation *obc
 
 func (client *clientImpl) newChaincodeQueryUsingECert(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string, nonce []byte) (*obc.Transaction, error) {
 	// Create a new transaction
-	tx, err := client.createQueryTx(chaincodeInvocation, uuid, nonce)
+	tx, err := client.createQueryTx(chaincodeInvocation, uuid, nonce, nil)
 	if err != nil {
 		client.node.error("Failed creating new query transaction [%s].", err.Error())
 		return nil, err
@@ -1,29 +1,47 @@
 package conf
 
 import (
-	"crypto/elliptic"
 	"fmt"
-	"golang.org/x/crypto/sha3"
 	"sync"
 )
 
 var (
 	initOnce sync.Once
+
+	defaultSuite CryptoSuite
 )
 
+// InitSecurityLevel initializes the crypto suite of the security level, that
+// is ECDSA with SHA3 on P-256 or P-384
 func InitSecurityLevel(level int) (err error) {
+	switch level {
+	case 256:
+		return InitCryptoSuite(SuiteP256SHA3)
+	case 384:
+		return InitCryptoSuite(SuiteP384SHA3)
+	}
+
+	return fmt.Errorf("Security level not supported [%d]", level)
+}
+
+// InitCryptoSuite initializes the crypto suite with the given name. The suite
+// can be initialized only once, later calls have no effect.
+func InitCryptoSuite(name string) (err error) {
+	suite, err := GetCryptoSuiteByName(name)
+	if err != nil {
+		return err
+	}
+
 	initOnce.Do(func() {
-		switch level {
-		case 256:
-			defaultCurve = elliptic.P256()
-			defaultHash = sha3.New256
-		case 384:
-			defaultCurve = elliptic.P384()
-			defaultHash = sha3.New384
-		default:
-			err = fmt.Errorf("Security level not supported [%d]", level)
-		}
+		defaultSuite = suite
+		defaultCurve = suite.Curve()
+		defaultHash = suite.Hash()
 	})
 
 	return
 }
+
+// GetCryptoSuite returns the crypto suite in use, nil if not initialized yet
+func GetCryptoSuite() CryptoSuite {
+	return defaultSuite
+}
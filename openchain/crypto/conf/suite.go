@@ -0,0 +1,121 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package conf
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"sort"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// CryptoSuite bundles the primitives used network wide: the hash function of
+// signatures, key derivations and MACs, the curve of the ECDSA keys, and the
+// hash of the ledger data. Every member of a network has to use the same
+// suite. Only ECDSA suites are available, as TCerts are derived from
+// enrollment keys by elliptic curve point addition.
+type CryptoSuite interface {
+	// Name identifies the suite in the configuration and the genesis block
+	Name() string
+
+	// Hash returns the constructor of the hash function
+	Hash() func() hash.Hash
+
+	// Curve returns the curve of the signing keys
+	Curve() elliptic.Curve
+
+	// DataHash hashes ledger data to 64 bytes
+	DataHash(data []byte) []byte
+}
+
+const (
+	// SuiteP256SHA3 uses P-256 and SHA3-256, the suite of security level 256
+	SuiteP256SHA3 = "ECDSA-P256-SHA3"
+	// SuiteP384SHA3 uses P-384 and SHA3-384, the suite of security level 384
+	SuiteP384SHA3 = "ECDSA-P384-SHA3"
+	// SuiteP256SHA2 uses P-256 and SHA-256
+	SuiteP256SHA2 = "ECDSA-P256-SHA2"
+	// SuiteP384SHA2 uses P-384 and SHA-384
+	SuiteP384SHA2 = "ECDSA-P384-SHA2"
+)
+
+type ecdsaSuite struct {
+	name     string
+	hash     func() hash.Hash
+	curve    elliptic.Curve
+	dataHash func(data []byte) []byte
+}
+
+func (suite *ecdsaSuite) Name() string {
+	return suite.name
+}
+
+func (suite *ecdsaSuite) Hash() func() hash.Hash {
+	return suite.hash
+}
+
+func (suite *ecdsaSuite) Curve() elliptic.Curve {
+	return suite.curve
+}
+
+func (suite *ecdsaSuite) DataHash(data []byte) []byte {
+	return suite.dataHash(data)
+}
+
+func shake256(data []byte) []byte {
+	hash := make([]byte, 64)
+	sha3.ShakeSum256(hash, data)
+	return hash
+}
+
+func sha2512(data []byte) []byte {
+	hash := sha512.Sum512(data)
+	return hash[:]
+}
+
+var suites = map[string]CryptoSuite{
+	SuiteP256SHA3: &ecdsaSuite{SuiteP256SHA3, sha3.New256, elliptic.P256(), shake256},
+	SuiteP384SHA3: &ecdsaSuite{SuiteP384SHA3, sha3.New384, elliptic.P384(), shake256},
+	SuiteP256SHA2: &ecdsaSuite{SuiteP256SHA2, sha256.New, elliptic.P256(), sha2512},
+	SuiteP384SHA2: &ecdsaSuite{SuiteP384SHA2, sha512.New384, elliptic.P384(), sha2512},
+}
+
+// GetCryptoSuiteByName returns the suite with the given name
+func GetCryptoSuiteByName(name string) (CryptoSuite, error) {
+	suite, ok := suites[name]
+	if !ok {
+		return nil, fmt.Errorf("Crypto suite not supported [%s]", name)
+	}
+	return suite, nil
+}
+
+// GetCryptoSuiteNames returns the names of the available suites
+func GetCryptoSuiteNames() []string {
+	var names []string
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -26,6 +26,9 @@ type Client interface {
 	// NewChaincodeQuery is used to query chaincode's functions.
 	NewChaincodeQuery(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string) (*obc.Transaction, error)
 
+	// NewChaincodeKeyRotation is used to rotate the key a confidential chaincode's state is encrypted under.
+	NewChaincodeKeyRotation(chaincodeInvocation *obc.ChaincodeInvocationSpec, uuid string) (*obc.Transaction, error)
+
 	// DecryptQueryResult is used to decrypt the result of a query transaction
 	DecryptQueryResult(queryTx *obc.Transaction, result []byte) ([]byte, error)
 
@@ -37,6 +40,15 @@ type Client interface {
 
 	// GetTCertHandlerFromDER returns a CertificateHandler whose certificate is the one passed
 	GetTCertificateHandlerFromDER(der []byte) (CertificateHandler, error)
+
+	// GetTCertPoolSize returns the number of TCerts available without contacting the TCA
+	GetTCertPoolSize() (int, error)
+
+	// RefillTCertPool fetches TCerts from the TCA until the pool reaches its high watermark
+	RefillTCertPool() error
+
+	// CoSignTransaction appends a co-signature of tx made with the enrollment certificate
+	CoSignTransaction(tx *obc.Transaction) error
 }
 
 // Peer is an entity able to verify transactions
@@ -49,11 +61,35 @@ type Peer interface {
 	// GetEnrollmentID returns this peer's enrollment id
 	GetEnrollmentID() string
 
+	// RenewEnrollment re-enrolls this peer with new enrollment keys under the
+	// same enrollment id. The current enrollment certificate is revoked by the
+	// ECA and GetID returns the identifier of the new one afterwards.
+	RenewEnrollment() error
+
+	// GetEnrollmentIDOf returns the enrollment id of the peer identified by vkID,
+	// even if its enrollment certificate has been revoked in the meantime.
+	GetEnrollmentIDOf(vkID []byte) (string, error)
+
+	// GetEnrollmentCertChain returns this peer's enrollment certificate
+	// followed by the certificates of its ECA and of the CAs that issued it.
+	GetEnrollmentCertChain() [][]byte
+
+	// AddEnrollmentCertChain validates the enrollment certificate of another
+	// peer, first in chain, against the roots of the PKI with the rest of chain
+	// as intermediate CAs. The signatures of the peer can then be verified
+	// even if it was enrolled by another ECA of the PKI.
+	AddEnrollmentCertChain(chain [][]byte) error
+
 	// TransactionPreValidation verifies that the transaction is
 	// well formed with the respect to the security layer
 	// prescriptions (i.e. signature verification).
 	TransactionPreValidation(tx *obc.Transaction) (*obc.Transaction, error)
 
+	// TransactionsPreValidation verifies a batch of transactions like
+	// TransactionPreValidation, verifying their signatures in parallel.
+	// It returns an error for each transaction, nil if it is well formed.
+	TransactionsPreValidation(txs []*obc.Transaction) ([]*obc.Transaction, []error)
+
 	// TransactionPreExecution verifies that the transaction is
 	// well formed with the respect to the security layer
 	// prescriptions (i.e. signature verification). If this is the case,
@@ -70,10 +106,19 @@ type Peer interface {
 	// If vkID is nil, then the signature is verified against this validator's verification key.
 	Verify(vkID, signature, message []byte) error
 
+	// VerifyEnrollmentSignature checks that ecert is an enrollment certificate
+	// issued by an ECA of the PKI, not revoked, and that signature is a valid
+	// signature of message under its verification key. It proves that message
+	// was signed by the user ecert was issued to.
+	VerifyEnrollmentSignature(ecert, signature, message []byte) error
+
 	// GetStateEncryptor returns a StateEncryptor linked to pair defined by
 	// the deploy transaction and the execute transaction. Notice that,
 	// executeTx can also correspond to a deploy transaction.
-	GetStateEncryptor(deployTx, executeTx *obc.Transaction) (StateEncryptor, error)
+	// rotationTxs are the key rotation transactions issued for the chaincode
+	// so far, in the order they were executed. The state is encrypted under
+	// the key of the latest epoch and can be decrypted under any of them.
+	GetStateEncryptor(deployTx, executeTx *obc.Transaction, rotationTxs ...*obc.Transaction) (StateEncryptor, error)
 }
 
 // StateEncryptor is used to encrypt chaincode's state
@@ -85,6 +130,17 @@ type StateEncryptor interface {
 	// Decrypt decrypts ciphertext ct obtained
 	// from a call of the Encrypt method.
 	Decrypt(ct []byte) ([]byte, error)
+
+	// EncryptKey deterministically encrypts state key key: the same key
+	// always has the same ciphertext for a given chaincode, which lets
+	// encrypted keys be looked up. This leaks which keys are equal, the
+	// length of the keys and which keys transactions access. Key rotations
+	// do not change the ciphertexts of the keys.
+	EncryptKey(key string) (string, error)
+
+	// DecryptKey decrypts ciphertext ct obtained
+	// from a call of the EncryptKey method.
+	DecryptKey(ct string) (string, error)
 }
 
 // CertificateHandler exposes methods to deal with an ECert/TCert
@@ -25,6 +25,16 @@ func Init() (err error) {
 			logging.GetLevel("crypto"), err)
 	}
 
+	// Init crypto suite, which overrides the security level
+	if suite := viper.GetString("security.suite"); suite != "" {
+		log.Debug("Working with crypto suite [%s]", suite)
+		if err = conf.InitCryptoSuite(suite); err != nil {
+			log.Debug("Failed setting crypto suite: [%s]", err)
+		}
+
+		return
+	}
+
 	// Init security level
 
 	securityLevel := 256
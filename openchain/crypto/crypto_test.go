@@ -30,8 +30,12 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/openblockchain/obc-peer/obc-ca/obcca"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
@@ -39,6 +43,7 @@ import (
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"math/big"
 )
 
 type createTxFunc func(t *testing.T) (*obc.Transaction, *obc.Transaction, error)
@@ -307,6 +312,38 @@ func TestClientGetTCertHandlerNext(t *testing.T) {
 	}
 }
 
+func TestClientTCertPool(t *testing.T) {
+	if err := deployer.RefillTCertPool(); err != nil {
+		t.Fatalf("Failed refilling TCert pool: [%s]", err)
+	}
+
+	size, err := deployer.GetTCertPoolSize()
+	if err != nil {
+		t.Fatalf("Failed getting TCert pool size: [%s]", err)
+	}
+	_, high := deployer.(*clientImpl).node.conf.getTCertPoolWatermarks()
+	if size != high {
+		t.Fatalf("TCert pool should be filled up to [%d], was [%d]", high, size)
+	}
+
+	first, err := deployer.GetTCertificateHandlerNext()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	second, err := deployer.GetTCertificateHandlerNext()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	if bytes.Equal(first.GetCertificate(), second.GetCertificate()) {
+		t.Fatalf("TCerts must not be reused by default")
+	}
+
+	size, _ = deployer.GetTCertPoolSize()
+	if size != high-2 {
+		t.Fatalf("TCert pool should hold [%d] TCerts, was [%d]", high-2, size)
+	}
+}
+
 func TestClientGetTCertHandlerFromDER(t *testing.T) {
 	handler, err := deployer.GetTCertificateHandlerNext()
 	if err != nil {
@@ -820,6 +857,204 @@ func TestValidatorStateEncryptor(t *testing.T) {
 
 }
 
+func TestValidatorStateEncryptorKeyRotation(t *testing.T) {
+	_, deployTx, err := createConfidentialDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s]", err)
+	}
+	_, invokeTx, err := createConfidentialExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating invoke transaction [%s]", err)
+	}
+	rotationTx, err := createConfidentialKeyRotationTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating key rotation transaction [%s]", err)
+	}
+	if rotationTx.Type != obc.Transaction_CHAINCODE_KEY_ROTATION {
+		t.Fatalf("Invalid key rotation transaction type [%s].", rotationTx.Type)
+	}
+
+	// Transactions must be PreExecuted by the validators before getting the StateEncryptor
+	if deployTx, err = validator.TransactionPreExecution(deployTx); err != nil {
+		t.Fatalf("Failed pre-executing deploy transaction [%s].", err)
+	}
+	if invokeTx, err = validator.TransactionPreExecution(invokeTx); err != nil {
+		t.Fatalf("Failed pre-executing exec transaction [%s].", err)
+	}
+	if rotationTx, err = validator.TransactionPreExecution(rotationTx); err != nil {
+		t.Fatalf("Failed pre-executing key rotation transaction [%s].", err)
+	}
+
+	// State written before the rotation
+	seOld, err := validator.GetStateEncryptor(deployTx, invokeTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	pt := []byte("Hello World")
+	oldCt, err := seOld.Encrypt(pt)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+
+	// After the rotation, old states are still readable and new states are written under the new key
+	seNew, err := validator.GetStateEncryptor(deployTx, invokeTx, rotationTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	aPt, err := seNew.Decrypt(oldCt)
+	if err != nil {
+		t.Fatalf("Failed decrypting state of the previous epoch [%s].", err)
+	}
+	if !bytes.Equal(pt, aPt) {
+		t.Fatalf("Failed decrypting state [%s != %s]", string(pt), string(aPt))
+	}
+	newCt, err := seNew.Encrypt(pt)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+	if bytes.Equal(oldCt, newCt) {
+		t.Fatal("State must be encrypted under the key of the new epoch.")
+	}
+	if _, err := seOld.Decrypt(newCt); err == nil {
+		t.Fatal("State of the new epoch must not be decrypted with the keys of the previous one.")
+	}
+	aPt, err = seNew.Decrypt(newCt)
+	if err != nil {
+		t.Fatalf("Failed decrypting state [%s].", err)
+	}
+	if !bytes.Equal(pt, aPt) {
+		t.Fatalf("Failed decrypting state [%s != %s]", string(pt), string(aPt))
+	}
+
+	// Only key rotation transactions start a new epoch
+	if _, err := validator.GetStateEncryptor(deployTx, invokeTx, invokeTx); err == nil {
+		t.Fatal("Execute transactions must not be accepted as key rotations.")
+	}
+}
+
+func TestValidatorStateKeyEncryption(t *testing.T) {
+	_, deployTx, err := createConfidentialDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s]", err)
+	}
+	_, invokeTx, err := createConfidentialExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating invoke transaction [%s]", err)
+	}
+	rotationTx, err := createConfidentialKeyRotationTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating key rotation transaction [%s]", err)
+	}
+
+	// Transactions must be PreExecuted by the validators before getting the StateEncryptor
+	if deployTx, err = validator.TransactionPreExecution(deployTx); err != nil {
+		t.Fatalf("Failed pre-executing deploy transaction [%s].", err)
+	}
+	if invokeTx, err = validator.TransactionPreExecution(invokeTx); err != nil {
+		t.Fatalf("Failed pre-executing exec transaction [%s].", err)
+	}
+	if rotationTx, err = validator.TransactionPreExecution(rotationTx); err != nil {
+		t.Fatalf("Failed pre-executing key rotation transaction [%s].", err)
+	}
+
+	se, err := validator.GetStateEncryptor(deployTx, invokeTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	key := "account1"
+	ct, err := se.EncryptKey(key)
+	if err != nil {
+		t.Fatalf("Failed encrypting key [%s].", err)
+	}
+	if ct == key {
+		t.Fatal("Key must be encrypted.")
+	}
+
+	// Equal keys map to equal ciphertexts, also across transactions and key rotations
+	seRotated, err := validator.GetStateEncryptor(deployTx, invokeTx, rotationTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	ct2, err := seRotated.EncryptKey(key)
+	if err != nil {
+		t.Fatalf("Failed encrypting key [%s].", err)
+	}
+	if ct != ct2 {
+		t.Fatalf("Key encryption must be deterministic [%s != %s]", ct, ct2)
+	}
+	if ct3, _ := se.EncryptKey("account2"); ct3 == ct {
+		t.Fatal("Different keys must map to different ciphertexts.")
+	}
+
+	aKey, err := seRotated.DecryptKey(ct)
+	if err != nil {
+		t.Fatalf("Failed decrypting key [%s].", err)
+	}
+	if aKey != key {
+		t.Fatalf("Failed decrypting key [%s != %s]", key, aKey)
+	}
+
+	// Tampered ciphertexts are rejected
+	tampered := []byte(ct)
+	tampered[0] ^= 1
+	if _, err := se.DecryptKey(string(tampered)); err == nil {
+		t.Fatal("Decrypting a tampered key must fail.")
+	}
+}
+
+func TestAuditorDecryption(t *testing.T) {
+	otx, deployTx, err := createConfidentialDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s]", err)
+	}
+	_, invokeTx, err := createConfidentialExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating invoke transaction [%s]", err)
+	}
+
+	// The ECA derives the transaction keys issued to auditors from the chain key
+	deployTxKey := utils.HMAC(validator.(*validatorImpl).peer.node.enrollChainKey, deployTx.Nonce)
+
+	atx, err := DecryptTransaction(deployTx, deployTxKey)
+	if err != nil {
+		t.Fatalf("Failed decrypting transaction [%s].", err)
+	}
+	if !bytes.Equal(otx.Payload, atx.Payload) || !bytes.Equal(otx.ChaincodeID, atx.ChaincodeID) {
+		t.Fatal("Decrypted transaction differs from the original.")
+	}
+
+	if deployTx, err = validator.TransactionPreExecution(deployTx); err != nil {
+		t.Fatalf("Failed pre-executing deploy transaction [%s].", err)
+	}
+	if invokeTx, err = validator.TransactionPreExecution(invokeTx); err != nil {
+		t.Fatalf("Failed pre-executing exec transaction [%s].", err)
+	}
+	se, err := validator.GetStateEncryptor(deployTx, invokeTx)
+	if err != nil {
+		t.Fatalf("Failed creating state encryptor [%s].", err)
+	}
+	pt := []byte("Hello World")
+	ct, err := se.Encrypt(pt)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+
+	sd, err := NewStateDecryptor([][]byte{deployTxKey})
+	if err != nil {
+		t.Fatalf("Failed creating state decryptor [%s].", err)
+	}
+	aPt, err := sd.Decrypt(ct)
+	if err != nil {
+		t.Fatalf("Failed decrypting state [%s].", err)
+	}
+	if !bytes.Equal(pt, aPt) {
+		t.Fatalf("Failed decrypting state [%s != %s]", string(pt), string(aPt))
+	}
+	if _, err := sd.Encrypt(pt); err == nil {
+		t.Fatal("Auditors must not be able to encrypt state.")
+	}
+}
+
 func TestValidatorSignVerify(t *testing.T) {
 	msg := []byte("Hello World!!!")
 	signature, err := validator.Sign(msg)
@@ -866,6 +1101,539 @@ func TestValidatorVerify(t *testing.T) {
 	}
 }
 
+func TestValidatorVerifyEnrollmentSignature(t *testing.T) {
+	handler, err := deployer.GetEnrollmentCertificateHandler()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	msg := []byte("Hello World!!!")
+	signature, err := handler.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+
+	if err := validator.VerifyEnrollmentSignature(handler.GetCertificate(), signature, msg); err != nil {
+		t.Fatalf("Failed verifying signature [%s].", err)
+	}
+	if err := validator.VerifyEnrollmentSignature(handler.GetCertificate(), signature, []byte("Another message")); err == nil {
+		t.Fatalf("Verify should fail when given another message.")
+	}
+
+	// Signatures made with a TCert do not prove the enrollment of the signer
+	tHandler, err := deployer.GetTCertificateHandlerNext()
+	if err != nil {
+		t.Fatalf("Failed getting handler: [%s]", err)
+	}
+	signature, err = tHandler.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+	if err := validator.VerifyEnrollmentSignature(tHandler.GetCertificate(), signature, msg); err == nil {
+		t.Fatalf("Verify should fail when given a TCert.")
+	}
+
+	// Self-signed certificates are not issued by the ECA
+	selfSigned, key, err := utils.NewSelfSignedCert()
+	if err != nil {
+		t.Fatalf("Failed creating certificate [%s].", err)
+	}
+	signature, err = utils.ECDSASign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+	if err := validator.VerifyEnrollmentSignature(selfSigned, signature, msg); err == nil {
+		t.Fatalf("Verify should fail when given a self-signed certificate.")
+	}
+}
+
+func TestValidatorRevokedCertificate(t *testing.T) {
+	node := validator.(*validatorImpl).peer.node
+
+	if err := node.updateCRLs(); err != nil {
+		t.Fatalf("Failed updating CRLs [%s].", err)
+	}
+	defer node.updateCRLs()
+
+	_, tx, err := createPublicDeployTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating deploy transaction [%s].", err)
+	}
+
+	if _, err := validator.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Error must be nil [%s].", err)
+	}
+
+	cert, err := utils.DERToX509Certificate(tx.Cert)
+	if err != nil {
+		t.Fatalf("Failed parsing transaction certificate [%s].", err)
+	}
+
+	node.crlLock.Lock()
+	node.revoked[revocationKey(cert.Issuer.CommonName, cert.SerialNumber.String())] = true
+	node.crlLock.Unlock()
+
+	if _, err := validator.TransactionPreValidation(tx); err != utils.ErrCertificateRevoked {
+		t.Fatalf("Transactions with revoked certificates must be rejected [%v].", err)
+	}
+}
+
+func TestValidatorRenewEnrollment(t *testing.T) {
+	node := validator.(*validatorImpl).peer.node
+	defer node.updateCRLs()
+
+	msg := []byte("Hello World!!!")
+	oldID := validator.GetID()
+	oldSignature, err := validator.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+
+	if err := validator.RenewEnrollment(); err != nil {
+		t.Fatalf("Failed renewing enrollment [%s].", err)
+	}
+
+	newID := validator.GetID()
+	if reflect.DeepEqual(oldID, newID) {
+		t.Fatalf("Renewal must change the validator identifier.")
+	}
+	signature, err := validator.Sign(msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+	if err := validator.Verify(newID, signature, msg); err != nil {
+		t.Fatalf("Failed verifying signature with the renewed enrollment [%s].", err)
+	}
+
+	// The logical identity is preserved across the renewal
+	for _, id := range [][]byte{oldID, newID} {
+		enrollID, err := validator.GetEnrollmentIDOf(id)
+		if err != nil {
+			t.Fatalf("Failed getting enrollment id [%s].", err)
+		}
+		if enrollID != validator.GetEnrollmentID() {
+			t.Fatalf("Enrollment id mismatch [%s] != [%s].", enrollID, validator.GetEnrollmentID())
+		}
+	}
+
+	// The replaced enrollment certificate is revoked
+	if err := node.updateCRLs(); err != nil {
+		t.Fatalf("Failed updating CRLs [%s].", err)
+	}
+	if err := validator.Verify(oldID, oldSignature, msg); err != utils.ErrCertificateRevoked {
+		t.Fatalf("Signatures of the replaced enrollment certificate must be rejected [%v].", err)
+	}
+	if _, err := validator.GetEnrollmentIDOf(oldID); err != nil {
+		t.Fatalf("Failed getting enrollment id of the replaced certificate [%s].", err)
+	}
+}
+
+func TestEnrollmentAutoRenewal(t *testing.T) {
+	node := validator.(*validatorImpl).peer.node
+	defer node.updateCRLs()
+
+	// Nothing to do while the certificate is far from expiring
+	if renewal := node.checkEnrollmentRenewal(time.Now()); renewal != nil {
+		t.Fatalf("Enrollment certificate renewed too early.")
+	}
+
+	var notified []*EnrollmentRenewal
+	AddRenewalHandler(func(renewal *EnrollmentRenewal) {
+		notified = append(notified, renewal)
+	})
+
+	oldID := validator.GetID()
+	oldNotAfter := node.enrollCert.NotAfter
+	renewal := node.checkEnrollmentRenewal(oldNotAfter)
+	if renewal == nil || renewal.Err != nil {
+		t.Fatalf("Failed renewing expiring enrollment certificate [%v].", renewal)
+	}
+	if !reflect.DeepEqual(renewal.PreviousID, oldID) || reflect.DeepEqual(validator.GetID(), oldID) {
+		t.Fatalf("Renewal must replace the validator identifier.")
+	}
+	if renewal.NotAfter.Before(oldNotAfter) || !renewal.NotAfter.Equal(node.enrollCert.NotAfter) {
+		t.Fatalf("Renewal must extend the enrollment certificate [%s] -> [%s].", oldNotAfter, renewal.NotAfter)
+	}
+	if len(notified) != 1 || notified[0] != renewal {
+		t.Fatalf("Renewal handlers must be notified.")
+	}
+
+	// Clients fetch TCerts derived from the renewed enrollment certificate
+	client := invoker.(*clientImpl)
+	if renewal := client.node.checkEnrollmentRenewal(client.node.enrollCert.NotAfter); renewal == nil || renewal.Err != nil {
+		t.Fatalf("Failed renewing expiring client enrollment certificate [%v].", renewal)
+	}
+	if client.tCertOwnerKDFKey != nil {
+		t.Fatalf("The TCertOwnerKDFKey of the replaced enrollment certificate must be reset.")
+	}
+	if err := invoker.RefillTCertPool(); err != nil {
+		t.Fatalf("Failed fetching TCerts after renewal [%s].", err)
+	}
+	if _, err := invoker.GetTCertificateHandlerNext(); err != nil {
+		t.Fatalf("Failed getting TCert after renewal [%s].", err)
+	}
+}
+
+func TestValidatorEnrollmentCertChain(t *testing.T) {
+	node := validator.(*validatorImpl).peer.node
+	defer func() { node.rootsCertPool = nil }()
+
+	chain := validator.GetEnrollmentCertChain()
+	if len(chain) < 2 || !reflect.DeepEqual(chain[0], node.enrollCert.Raw) {
+		t.Fatalf("Enrollment certificate chain must start with the enrollment certificate and the ECA certificate.")
+	}
+
+	// A validator enrolled by another ECA of the PKI
+	newCert := func(cn string, isCA bool, role string, pub, priv interface{}, parent *x509.Certificate) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Minute),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if role != "" {
+			tmpl.ExtraExtensions = []pkix.Extension{{Id: ECertSubjectRole, Critical: true, Value: []byte(role)}}
+		}
+		if parent == nil {
+			parent = tmpl
+		}
+		raw, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, priv)
+		if err != nil {
+			t.Fatalf("Failed creating certificate [%s].", err)
+		}
+		cert, _ := x509.ParseCertificate(raw)
+		return cert
+	}
+	rootKey, _ := utils.NewECDSAKey()
+	root := newCert("root", true, "", &rootKey.PublicKey, rootKey, nil)
+	ecaKey, _ := utils.NewECDSAKey()
+	eca := newCert("otherECA", true, "", &ecaKey.PublicKey, rootKey, root)
+	key, _ := utils.NewECDSAKey()
+	ecert := newCert("otherValidator", false, "4", &key.PublicKey, ecaKey, eca)
+	client := newCert("otherClient", false, "1", &key.PublicKey, ecaKey, eca)
+
+	msg := []byte("Hello World!!!")
+	signature, err := utils.ECDSASign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed generating signature [%s].", err)
+	}
+
+	// Without roots the ECA is the only trusted CA
+	if err := validator.AddEnrollmentCertChain([][]byte{ecert.Raw, eca.Raw}); err == nil {
+		t.Fatalf("Enrollment certificate of another ECA must be rejected without roots.")
+	}
+
+	node.rootsCertPool = x509.NewCertPool()
+	node.rootsCertPool.AddCert(root)
+	if err := validator.AddEnrollmentCertChain([][]byte{client.Raw, eca.Raw}); err == nil {
+		t.Fatalf("Enrollment certificate of a client must be rejected.")
+	}
+	if err := validator.AddEnrollmentCertChain([][]byte{ecert.Raw}); err == nil {
+		t.Fatalf("Enrollment certificate without its intermediate CA must be rejected.")
+	}
+	if err := validator.AddEnrollmentCertChain([][]byte{ecert.Raw, eca.Raw}); err != nil {
+		t.Fatalf("Failed adding enrollment certificate chain [%s].", err)
+	}
+	if err := validator.Verify(utils.Hash(ecert.Raw), signature, msg); err != nil {
+		t.Fatalf("Failed verifying signature of validator enrolled by another ECA [%s].", err)
+	}
+}
+
+func TestCertCache(t *testing.T) {
+	var raws [][]byte
+	var certs []*x509.Certificate
+	for i := 0; i < 3; i++ {
+		raw, _, err := utils.NewSelfSignedCert()
+		if err != nil {
+			t.Fatalf("Failed creating certificate [%s].", err)
+		}
+		cert, err := utils.DERToX509Certificate(raw)
+		if err != nil {
+			t.Fatalf("Failed parsing certificate [%s].", err)
+		}
+		raws = append(raws, raw)
+		certs = append(certs, cert)
+	}
+
+	// The least recently used certificate is evicted
+	cache := newCertCache(2)
+	cache.put(&certEntry{key: "0", cert: certs[0]})
+	cache.put(&certEntry{key: "1", cert: certs[1]})
+	if cache.get("0") == nil {
+		t.Fatal("Certificate must be cached.")
+	}
+	cache.put(&certEntry{key: "2", cert: certs[2]})
+	if cache.len() != 2 || cache.get("1") != nil || cache.get("0") == nil || cache.get("2") == nil {
+		t.Fatal("Least recently used certificate must be evicted.")
+	}
+
+	// Revoked certificates are invalidated
+	cache.invalidate(func(cert *x509.Certificate) bool { return cert == certs[2] })
+	if cache.len() != 1 || cache.get("2") != nil {
+		t.Fatal("Revoked certificate must be invalidated.")
+	}
+
+	// Certificates not issued by the CAs are cached along with the outcome of their validation
+	node := validator.(*validatorImpl).peer.node
+	before := node.certs.len()
+	for i := 0; i < 2; i++ {
+		if _, err := node.getCert(raws[0]); err != utils.ErrInvalidCertificate {
+			t.Fatalf("Certificate not issued by the CAs must be rejected [%v].", err)
+		}
+	}
+	if node.certs.len() != before+1 {
+		t.Fatalf("Certificate must be cached once, cache has %d entries instead of %d.", node.certs.len(), before+1)
+	}
+}
+
+func TestEncryptKeyStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obc-keystore")
+	if err != nil {
+		t.Fatalf("Failed creating directory [%s].", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileSystemPath := viper.GetString("peer.fileSystemPath")
+	viper.Set("peer.fileSystemPath", dir)
+	defer viper.Set("peer.fileSystemPath", fileSystemPath)
+	defer func() {
+		viper.Set("security.keystore.unlock", "")
+		keyStorePassphrase = nil
+	}()
+
+	// The passphrase is unwrapped by the KMS, cat leaves it as is
+	pwd := []byte("passphrase")
+	wrapped := filepath.Join(dir, "wrapped")
+	if err := ioutil.WriteFile(wrapped, append(pwd, '\n'), 0600); err != nil {
+		t.Fatalf("Failed writing wrapped passphrase [%s].", err)
+	}
+	viper.Set("security.keystore.unlock", "kms")
+	viper.Set("security.keystore.kms.command", "cat")
+	viper.Set("security.keystore.kms.wrapped", wrapped)
+	defer viper.Set("security.keystore.kms.command", "")
+
+	// A keystore created before encryption was enabled
+	raws := filepath.Join(dir, "crypto", "validator", "vp", "ks", "raw")
+	if err := os.MkdirAll(raws, 0755); err != nil {
+		t.Fatalf("Failed creating keystore [%s].", err)
+	}
+	key, _ := utils.NewECDSAKey()
+	clearKey, _ := utils.PrivateKeyToPEM(key, nil)
+	chainKey := []byte("0123456789abcdef0123456789abcdef")
+	conf := &configuration{}
+	for alias, raw := range map[string][]byte{
+		conf.getEnrollmentKeyFilename():      clearKey,
+		conf.getEnrollmentChainKeyFilename(): utils.AEStoPEM(chainKey),
+		conf.getTLSKeyFilename():             clearKey,
+		conf.getEnrollmentIDFilename():       []byte("vp"),
+	} {
+		if err := ioutil.WriteFile(filepath.Join(raws, alias), raw, 0700); err != nil {
+			t.Fatalf("Failed writing keystore [%s].", err)
+		}
+	}
+	read := func(alias string) []byte {
+		raw, err := ioutil.ReadFile(filepath.Join(raws, alias))
+		if err != nil {
+			t.Fatalf("Failed reading keystore [%s].", err)
+		}
+		return raw
+	}
+
+	encrypted, err := EncryptKeyStores()
+	if err != nil {
+		t.Fatalf("Failed encrypting keystores [%s].", err)
+	}
+	if encrypted != 2 {
+		t.Fatalf("Enrollment key and chain key must be encrypted, encrypted [%d] keys.", encrypted)
+	}
+	if _, err := utils.PEMtoPrivateKey(read(conf.getEnrollmentKeyFilename()), nil); err == nil {
+		t.Fatalf("Enrollment key must be encrypted.")
+	}
+	decrypted, err := utils.PEMtoPrivateKey(read(conf.getEnrollmentKeyFilename()), pwd)
+	if err != nil || !reflect.DeepEqual(decrypted, key) {
+		t.Fatalf("Enrollment key must decrypt under the passphrase [%v].", err)
+	}
+	if decryptedChainKey, err := utils.PEMtoAES(read(conf.getEnrollmentChainKeyFilename()), pwd); err != nil || !reflect.DeepEqual(decryptedChainKey, chainKey) {
+		t.Fatalf("Chain key must decrypt under the passphrase [%v].", err)
+	}
+	if !reflect.DeepEqual(read(conf.getTLSKeyFilename()), clearKey) {
+		t.Fatalf("TLS key must stay in the clear.")
+	}
+
+	if encrypted, err := EncryptKeyStores(); err != nil || encrypted != 0 {
+		t.Fatalf("Encrypted keys must be left as they are [%d] [%v].", encrypted, err)
+	}
+
+	// Keys encrypted under another passphrase are reported
+	os.Setenv("OBC_TEST_KEYSTORE_PASSPHRASE", "other")
+	defer os.Unsetenv("OBC_TEST_KEYSTORE_PASSPHRASE")
+	viper.Set("security.keystore.unlock", "passphrase")
+	viper.Set("security.keystore.passphrase.env", "OBC_TEST_KEYSTORE_PASSPHRASE")
+	defer viper.Set("security.keystore.passphrase.env", "")
+	keyStorePassphrase = nil
+	if _, err := EncryptKeyStores(); err == nil {
+		t.Fatalf("Keys encrypted under another passphrase must be reported.")
+	}
+}
+
+func TestValidatorTransactionsPreValidation(t *testing.T) {
+	var txs []*obc.Transaction
+	for _, createTx := range executeTxCreators {
+		_, tx, err := createTx(t)
+		if err != nil {
+			t.Fatalf("Failed creating transaction [%s].", err)
+		}
+		txs = append(txs, tx)
+	}
+	// Repeated transactions are verified once
+	txs = append(txs, txs[0])
+
+	// Tampered transaction
+	_, tampered, err := createPublicExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating transaction [%s].", err)
+	}
+	tampered.Uuid = tampered.Uuid + "1"
+	txs = append(txs, tampered)
+
+	res, errs := validator.TransactionsPreValidation(txs)
+	if len(res) != len(txs) || len(errs) != len(txs) {
+		t.Fatalf("Expected a result for each transaction, got %d and %d.", len(res), len(errs))
+	}
+	for i := 0; i < len(txs)-1; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Transaction [%d] must be valid [%s].", i, errs[i])
+		}
+	}
+	if errs[len(txs)-1] != utils.ErrInvalidTransactionSignature {
+		t.Fatalf("Tampered transaction must be rejected [%v].", errs[len(txs)-1])
+	}
+
+	// Certificates not issued by the TCA or ECA are rejected
+	_, forged, err := createPublicExecuteTransaction(t)
+	if err != nil {
+		t.Fatalf("Failed creating transaction [%s].", err)
+	}
+	if forged.Cert, _, err = utils.NewSelfSignedCert(); err != nil {
+		t.Fatalf("Failed creating certificate [%s].", err)
+	}
+	if _, err := validator.TransactionPreValidation(forged); err != utils.ErrInvalidCertificate {
+		t.Fatalf("Transactions with certificates not issued by the CAs must be rejected [%v].", err)
+	}
+}
+
+func TestOfflineTransaction(t *testing.T) {
+	client := invoker.(*clientImpl)
+	cis := &obc.ChaincodeInvocationSpec{
+		ChaincodeSpec: &obc.ChaincodeSpec{
+			Type:                 obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:          &obc.ChaincodeID{Path: "Contract001"},
+			CtorMsg:              &obc.ChaincodeInput{Function: "transfer", Args: []string{"a", "b", "10"}},
+			ConfidentialityLevel: obc.ConfidentialityLevel_CONFIDENTIAL,
+		},
+	}
+
+	// Confidential transaction signed outside the client with a TCert
+	tCert, err := client.tCertPool.GetNextTCert()
+	if err != nil {
+		t.Fatalf("Failed getting TCert [%s].", err)
+	}
+	tx, err := BuildExecuteTransaction(cis, util.GenerateUUID(), &OfflineTxOpts{
+		Cert:          tCert.GetCertificate().Raw,
+		AttributesKey: tCert.GetAttributesKey(),
+		ChainKey:      client.node.enrollChainKey,
+	})
+	if err != nil {
+		t.Fatalf("Failed building transaction [%s].", err)
+	}
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		t.Fatalf("Failed marshaling transaction [%s].", err)
+	}
+	if err := AttachTransactionSignature(tx, []byte("not a signature")); err != utils.ErrInvalidTransactionSignature {
+		t.Fatalf("Invalid signatures must be rejected [%v].", err)
+	}
+	signature, err := tCert.Sign(raw)
+	if err != nil {
+		t.Fatalf("Failed signing transaction [%s].", err)
+	}
+	if err := AttachTransactionSignature(tx, signature); err != nil {
+		t.Fatalf("Failed attaching signature [%s].", err)
+	}
+	if err := client.checkTransaction(tx); err != nil {
+		t.Fatalf("Failed checking transaction [%s].", err)
+	}
+	if _, err := validator.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Offline transaction must be valid [%s].", err)
+	}
+	res, err := validator.TransactionPreExecution(tx)
+	if err != nil {
+		t.Fatalf("Failed decrypting offline transaction [%s].", err)
+	}
+	payload, err := proto.Marshal(cis)
+	if err != nil {
+		t.Fatalf("Failed marshaling spec [%s].", err)
+	}
+	if !reflect.DeepEqual(res.Payload, payload) {
+		t.Fatalf("Decrypted payload does not match the spec.")
+	}
+
+	// Confidential transactions need the chain key
+	if _, err := BuildExecuteTransaction(cis, util.GenerateUUID(), &OfflineTxOpts{Cert: tCert.GetCertificate().Raw}); err == nil {
+		t.Fatalf("Confidential transactions must not be built without the chain key.")
+	}
+
+	// Public transaction signed with the ECert
+	cis.ChaincodeSpec.ConfidentialityLevel = obc.ConfidentialityLevel_PUBLIC
+	tx, err = BuildExecuteTransaction(cis, util.GenerateUUID(), &OfflineTxOpts{Cert: client.node.enrollCert.Raw})
+	if err != nil {
+		t.Fatalf("Failed building transaction [%s].", err)
+	}
+	if err := SignTransaction(tx, client.node.enrollPrivKey); err != nil {
+		t.Fatalf("Failed signing transaction [%s].", err)
+	}
+	if _, err := validator.TransactionPreValidation(tx); err != nil {
+		t.Fatalf("Offline transaction must be valid [%s].", err)
+	}
+	tx.Uuid = tx.Uuid + "1"
+	if _, err := validator.TransactionPreValidation(tx); err != utils.ErrInvalidTransactionSignature {
+		t.Fatalf("Tampered transaction must be rejected [%v].", err)
+	}
+}
+
+func TestValidatorCoSignedTransaction(t *testing.T) {
+	for _, createTx := range executeTxCreators {
+		_, tx, err := createTx(t)
+		if err != nil {
+			t.Fatalf("Failed creating transaction [%s].", err)
+		}
+
+		// Co-signing keeps the signature of the invoker valid
+		if err := deployer.CoSignTransaction(tx); err != nil {
+			t.Fatalf("Failed co-signing transaction [%s].", err)
+		}
+		if _, err := validator.TransactionPreValidation(tx); err != nil {
+			t.Fatalf("Co-signed transaction must be valid [%s].", err)
+		}
+
+		tx.CoSignatures[0].Signature = tx.Signature
+		if _, err := validator.TransactionPreValidation(tx); err != utils.ErrInvalidTransactionCoSignature {
+			t.Fatalf("Invalid co-signature must be rejected [%v].", err)
+		}
+
+		forged, _, err := utils.NewSelfSignedCert()
+		if err != nil {
+			t.Fatalf("Failed creating certificate [%s].", err)
+		}
+		tx.CoSignatures[0].Cert = forged
+		if _, err := validator.TransactionPreValidation(tx); err != utils.ErrInvalidCertificate {
+			t.Fatalf("Co-signers with certificates not issued by the CAs must be rejected [%v].", err)
+		}
+	}
+}
+
 func BenchmarkTransactionCreation(b *testing.B) {
 	b.StopTimer()
 	b.ResetTimer()
@@ -900,6 +1668,22 @@ func BenchmarkTransactionValidation(b *testing.B) {
 	}
 }
 
+func BenchmarkTransactionsPreValidation(b *testing.B) {
+	b.StopTimer()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var txs []*obc.Transaction
+		for j := 0; j < 100; j++ {
+			_, tx, _ := createConfidentialTCertHExecuteTransaction(nil)
+			txs = append(txs, tx)
+		}
+
+		b.StartTimer()
+		validator.TransactionsPreValidation(txs)
+		b.StopTimer()
+	}
+}
+
 func BenchmarkSign(b *testing.B) {
 	b.StopTimer()
 	b.ResetTimer()
@@ -1143,6 +1927,21 @@ func createConfidentialExecuteTransaction(t *testing.T) (*obc.Transaction, *obc.
 	return otx, tx, err
 }
 
+func createConfidentialKeyRotationTransaction(t *testing.T) (*obc.Transaction, error) {
+	uuid := util.GenerateUUID()
+
+	cis := &obc.ChaincodeInvocationSpec{
+		ChaincodeSpec: &obc.ChaincodeSpec{
+			Type:                 obc.ChaincodeSpec_GOLANG,
+			ChaincodeID:          &obc.ChaincodeID{Path: "Contract001"},
+			CtorMsg:              nil,
+			ConfidentialityLevel: obc.ConfidentialityLevel_CONFIDENTIAL,
+		},
+	}
+
+	return invoker.NewChaincodeKeyRotation(cis, uuid)
+}
+
 func createConfidentialQueryTransaction(t *testing.T) (*obc.Transaction, *obc.Transaction, error) {
 	uuid := util.GenerateUUID()
 
@@ -0,0 +1,124 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package hsm abstracts the storage of private signing keys so that they can
+// be generated and used inside a hardware security module and never leave it.
+//
+// The keys of every purpose (the node type of a peer, or the name of a CA) are
+// kept in software unless security.hsm.keys.<purpose> is enabled, in which case
+// they are held by the key store of security.hsm.provider.
+package hsm
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ErrKeyNotFound the key store holds no key with the given label
+var ErrKeyNotFound = errors.New("Key not found.")
+
+// KeyStore holds ECDSA signing keys identified by a label
+type KeyStore interface {
+	// GenerateKey creates a new key pair on the curve and stores it under label
+	GenerateKey(label string, curve elliptic.Curve) (crypto.Signer, error)
+
+	// GetKey returns the key pair stored under label
+	GetKey(label string) (crypto.Signer, error)
+}
+
+// Config carries the settings of security.hsm
+type Config struct {
+	Library string
+	Slot    int
+	PIN     string
+}
+
+// Provider opens a key store
+type Provider func(config *Config) (KeyStore, error)
+
+var (
+	providers = make(map[string]Provider)
+
+	keyStore KeyStore
+	m        sync.Mutex
+)
+
+// Register makes a key store provider available under name
+func Register(name string, provider Provider) {
+	m.Lock()
+	defer m.Unlock()
+
+	providers[name] = provider
+}
+
+// IsEnabled returns true if the keys of purpose are held by the key store
+func IsEnabled(purpose string) bool {
+	return viper.GetBool("security.hsm.keys." + purpose)
+}
+
+// GetKeyStore returns the key store configured in security.hsm. It is opened
+// on first use and shared by the whole process, as most PKCS#11 libraries can
+// be initialized only once.
+func GetKeyStore() (KeyStore, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if keyStore != nil {
+		return keyStore, nil
+	}
+
+	name := viper.GetString("security.hsm.provider")
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("Key store provider [%s] not available", name)
+	}
+
+	ks, err := provider(&Config{
+		Library: viper.GetString("security.hsm.library"),
+		Slot:    viper.GetInt("security.hsm.slot"),
+		PIN:     viper.GetString("security.hsm.pin"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	keyStore = ks
+
+	return keyStore, nil
+}
+
+// GetOrGenerateKey returns the key pair stored under label, generating it on
+// the curve if missing
+func GetOrGenerateKey(label string, curve elliptic.Curve) (crypto.Signer, error) {
+	ks, err := GetKeyStore()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ks.GetKey(label)
+	if err == ErrKeyNotFound {
+		return ks.GenerateKey(label, curve)
+	}
+
+	return key, err
+}
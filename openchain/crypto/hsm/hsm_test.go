@@ -0,0 +1,82 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package hsm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/spf13/viper"
+)
+
+func TestSoftKeyStore(t *testing.T) {
+	conf.InitSecurityLevel(256)
+	viper.Set("security.hsm.provider", "soft")
+	viper.Set("security.hsm.keys.validator", true)
+
+	if !IsEnabled("validator") || IsEnabled("peer") {
+		t.Fatal("Unexpected key purposes enabled")
+	}
+
+	ks, err := GetKeyStore()
+	if err != nil {
+		t.Fatalf("Failed opening key store: %s", err)
+	}
+	if _, err := ks.GetKey("test"); err != ErrKeyNotFound {
+		t.Fatalf("Expected missing key, got [%v]", err)
+	}
+
+	key, err := GetOrGenerateKey("test", elliptic.P256())
+	if err != nil {
+		t.Fatalf("Failed generating key: %s", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		t.Fatal("Private key leaves the key store")
+	}
+
+	same, err := GetOrGenerateKey("test", elliptic.P256())
+	if err != nil {
+		t.Fatalf("Failed getting key: %s", err)
+	}
+	if same.Public().(*ecdsa.PublicKey).X.Cmp(key.Public().(*ecdsa.PublicKey).X) != 0 {
+		t.Fatal("Key has been generated twice")
+	}
+
+	msg := []byte("Hello World")
+	sigma, err := utils.ECDSASign(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing: %s", err)
+	}
+	ok, err := utils.ECDSAVerify(key.Public(), msg, sigma)
+	if err != nil || !ok {
+		t.Fatalf("Signature does not verify [%v]", err)
+	}
+
+	r, s, err := utils.ECDSASignDirect(key, msg)
+	if err != nil {
+		t.Fatalf("Failed signing: %s", err)
+	}
+	if !ecdsa.Verify(key.Public().(*ecdsa.PublicKey), utils.Hash(msg), r, s) {
+		t.Fatal("Direct signature does not verify")
+	}
+}
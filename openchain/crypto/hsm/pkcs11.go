@@ -0,0 +1,242 @@
+// +build pkcs11
+
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// The PKCS#11 provider needs the github.com/miekg/pkcs11 package and is only
+// compiled in with -tags pkcs11.
+
+func init() {
+	Register("pkcs11", newPKCS11KeyStore)
+}
+
+var (
+	oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	oidNamedCurveP384 = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+type pkcs11KeyStore struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+
+	// PKCS#11 sessions must not be used concurrently
+	m sync.Mutex
+}
+
+func newPKCS11KeyStore(config *Config) (KeyStore, error) {
+	ctx := pkcs11.New(config.Library)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed loading PKCS#11 library [%s]", config.Library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, err
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, err
+	}
+	if config.Slot < 0 || config.Slot >= len(slots) {
+		return nil, fmt.Errorf("PKCS#11 slot [%d] not available", config.Slot)
+	}
+
+	session, err := ctx.OpenSession(slots[config.Slot], pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, err
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, config.PIN); err != nil {
+		return nil, err
+	}
+
+	return &pkcs11KeyStore{ctx: ctx, session: session}, nil
+}
+
+func (ks *pkcs11KeyStore) GenerateKey(label string, curve elliptic.Curve) (crypto.Signer, error) {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+
+	var oid asn1.ObjectIdentifier
+	switch curve {
+	case elliptic.P256():
+		oid = oidNamedCurveP256
+	case elliptic.P384():
+		oid = oidNamedCurveP384
+	default:
+		return nil, errors.New("Curve not supported by the PKCS#11 key store")
+	}
+	params, err := asn1.Marshal(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	pubTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	privTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	pub, priv, err := ks.ctx.GenerateKeyPair(ks.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		pubTemplate, privTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return ks.newSigner(pub, priv, curve)
+}
+
+func (ks *pkcs11KeyStore) GetKey(label string) (crypto.Signer, error) {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+
+	pub, err := ks.findObject(label, pkcs11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := ks.findObject(label, pkcs11.CKO_PRIVATE_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := ks.ctx.GetAttributeValue(ks.session, pub, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err = asn1.Unmarshal(attrs[0].Value, &oid); err != nil {
+		return nil, err
+	}
+
+	var curve elliptic.Curve
+	switch {
+	case oid.Equal(oidNamedCurveP256):
+		curve = elliptic.P256()
+	case oid.Equal(oidNamedCurveP384):
+		curve = elliptic.P384()
+	default:
+		return nil, errors.New("Curve not supported by the PKCS#11 key store")
+	}
+
+	return ks.newSigner(pub, priv, curve)
+}
+
+func (ks *pkcs11KeyStore) findObject(label string, class uint) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := ks.ctx.FindObjectsInit(ks.session, template); err != nil {
+		return 0, err
+	}
+	defer ks.ctx.FindObjectsFinal(ks.session)
+
+	objs, _, err := ks.ctx.FindObjects(ks.session, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(objs) == 0 {
+		return 0, ErrKeyNotFound
+	}
+
+	return objs[0], nil
+}
+
+// newSigner reads the public point of the key pair; the caller holds ks.m
+func (ks *pkcs11KeyStore) newSigner(pub, priv pkcs11.ObjectHandle, curve elliptic.Curve) (crypto.Signer, error) {
+	attrs, err := ks.ctx.GetAttributeValue(ks.session, pub, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// CKA_EC_POINT is the DER encoding of an OCTET STRING
+	var point []byte
+	if _, err = asn1.Unmarshal(attrs[0].Value, &point); err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, errors.New("Invalid public key in the PKCS#11 key store")
+	}
+
+	return &pkcs11Signer{ks, priv, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+}
+
+type pkcs11Signer struct {
+	ks   *pkcs11KeyStore
+	priv pkcs11.ObjectHandle
+	pub  *ecdsa.PublicKey
+}
+
+func (signer *pkcs11Signer) Public() crypto.PublicKey {
+	return signer.pub
+}
+
+// Sign signs digest inside the module and returns the ASN.1 encoded
+// signature, like ecdsa.PrivateKey does
+func (signer *pkcs11Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	signer.ks.m.Lock()
+	defer signer.ks.m.Unlock()
+
+	err := signer.ks.ctx.SignInit(signer.ks.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, signer.priv)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.ks.ctx.Sign(signer.ks.session, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// CKM_ECDSA returns r || s
+	r := new(big.Int).SetBytes(sig[:len(sig)/2])
+	s := new(big.Int).SetBytes(sig[len(sig)/2:])
+
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
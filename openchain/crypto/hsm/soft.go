@@ -0,0 +1,74 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package hsm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"sync"
+)
+
+func init() {
+	Register("soft", newSoftKeyStore)
+}
+
+// softKeyStore keeps the keys in memory. It loses them on restart and is
+// meant for testing only.
+type softKeyStore struct {
+	keys map[string]*ecdsa.PrivateKey
+	m    sync.Mutex
+}
+
+func newSoftKeyStore(config *Config) (KeyStore, error) {
+	return &softKeyStore{keys: make(map[string]*ecdsa.PrivateKey)}, nil
+}
+
+func (ks *softKeyStore) GenerateKey(label string, curve elliptic.Curve) (crypto.Signer, error) {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ks.keys[label] = key
+
+	return signerOnly{key}, nil
+}
+
+func (ks *softKeyStore) GetKey(label string) (crypto.Signer, error) {
+	ks.m.Lock()
+	defer ks.m.Unlock()
+
+	key, ok := ks.keys[label]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return signerOnly{key}, nil
+}
+
+// signerOnly hides the private key behind crypto.Signer, like a key held by
+// a hardware module
+type signerOnly struct {
+	crypto.Signer
+}
@@ -0,0 +1,217 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package crypto
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+// certEntry is a certificate parsed and validated against the CA chains
+type certEntry struct {
+	key  string
+	cert *x509.Certificate
+	// Error parsing or validating the certificate
+	err error
+}
+
+// certCache is an LRU cache of certificates by the hash of their DER
+// encoding. It spares parsing and validating again the certificates of the
+// peers and clients seen recently, in the consensus and the transaction
+// validation paths.
+type certCache struct {
+	sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int
+}
+
+func newCertCache(size int) *certCache {
+	return &certCache{entries: make(map[string]*list.Element), lru: list.New(), size: size}
+}
+
+func (cache *certCache) get(key string) *certEntry {
+	cache.Lock()
+	defer cache.Unlock()
+
+	elem, ok := cache.entries[key]
+	if !ok {
+		return nil
+	}
+	cache.lru.MoveToFront(elem)
+
+	return elem.Value.(*certEntry)
+}
+
+// put adds an entry, evicting the least recently used one if the
+// cache is full
+func (cache *certCache) put(entry *certEntry) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.size <= 0 {
+		return
+	}
+	if _, ok := cache.entries[entry.key]; ok {
+		return
+	}
+	if cache.lru.Len() >= cache.size {
+		oldest := cache.lru.Back()
+		cache.lru.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*certEntry).key)
+	}
+	cache.entries[entry.key] = cache.lru.PushFront(entry)
+}
+
+// invalidate removes the entries of the certificates for which stale returns true
+func (cache *certCache) invalidate(stale func(*x509.Certificate) bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for key, elem := range cache.entries {
+		if entry := elem.Value.(*certEntry); entry.cert != nil && stale(entry.cert) {
+			cache.lru.Remove(elem)
+			delete(cache.entries, key)
+		}
+	}
+}
+
+// invalidateRejected removes the entries of the certificates that failed
+// validation, which may validate through intermediate CAs learned since
+func (cache *certCache) invalidateRejected() {
+	cache.Lock()
+	defer cache.Unlock()
+
+	for key, elem := range cache.entries {
+		if elem.Value.(*certEntry).err != nil {
+			cache.lru.Remove(elem)
+			delete(cache.entries, key)
+		}
+	}
+}
+
+func (cache *certCache) len() int {
+	cache.Lock()
+	defer cache.Unlock()
+
+	return cache.lru.Len()
+}
+
+// getCert parses a certificate and validates it against the TCA chain, or
+// the ECA chain for enrollment certificates, and against the CRLs. Parsing
+// and validation are cached, the certificates revoked by an update of the
+// CRLs are evicted from the cache.
+func (node *nodeImpl) getCert(raw []byte) (*x509.Certificate, error) {
+	digest := sha256.Sum256(raw)
+	key := string(digest[:])
+
+	entry := node.certs.get(key)
+	if entry == nil {
+		entry = &certEntry{key: key}
+		if entry.cert, entry.err = utils.DERToX509Certificate(raw); entry.err == nil {
+			entry.err = node.checkCertChain(entry.cert)
+		}
+		node.certs.put(entry)
+	}
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	// Checked on every use, the CRLs may have been updated since the
+	// certificate was cached
+	if node.isRevoked(entry.cert) {
+		return entry.cert, utils.ErrCertificateRevoked
+	}
+
+	return entry.cert, nil
+}
+
+// getEnrollmentCert parses and validates an enrollment certificate like
+// getCert. Certificates without the role the ECA embeds in the enrollment
+// certificates, such as TCerts, are refused.
+func (node *nodeImpl) getEnrollmentCert(raw []byte) (*x509.Certificate, error) {
+	cert, err := node.getCert(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := utils.GetCriticalExtension(cert, ECertSubjectRole); err != nil {
+		return nil, utils.ErrInvalidCertificate
+	}
+
+	return cert, nil
+}
+
+// knownCriticalExtensions are the critical extensions the ECA and TCA
+// embed in the certificates they issue
+var knownCriticalExtensions = []asn1.ObjectIdentifier{
+	utils.TCertEncTCertIndex,
+	utils.TCertEncAttributes,
+	ECertSubjectRole,
+}
+
+// withKnownExtensionsHandled returns a copy of cert without the critical
+// extensions handled by the crypto layer, for x509 to verify it
+func withKnownExtensionsHandled(cert *x509.Certificate) *x509.Certificate {
+	c := *cert
+	c.UnhandledCriticalExtensions = nil
+	for _, oid := range cert.UnhandledCriticalExtensions {
+		known := false
+		for _, k := range knownCriticalExtensions {
+			known = known || oid.Equal(k)
+		}
+		if !known {
+			c.UnhandledCriticalExtensions = append(c.UnhandledCriticalExtensions, oid)
+		}
+	}
+
+	return &c
+}
+
+func (node *nodeImpl) checkCertChain(cert *x509.Certificate) error {
+	c := withKnownExtensionsHandled(cert)
+
+	opts := x509.VerifyOptions{Roots: node.tcaCertPool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := c.Verify(opts); err == nil {
+		return nil
+	}
+	opts.Roots = node.ecaCertPool
+	if _, err := c.Verify(opts); err == nil {
+		return nil
+	}
+
+	// Issued by another CA of the PKI, through the intermediate CAs known so far
+	if node.rootsCertPool == nil {
+		return utils.ErrInvalidCertificate
+	}
+	node.intermediatesLock.RLock()
+	defer node.intermediatesLock.RUnlock()
+
+	opts.Roots = node.rootsCertPool
+	opts.Intermediates = node.intermediatesCertPool
+	if _, err := c.Verify(opts); err != nil {
+		return utils.ErrInvalidCertificate
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+)
+
+// loadRootCerts loads the root certificates of the PKI the CAs belong to, if
+// configured. The ECA and TCA certificates are then validated against them,
+// and so are the enrollment certificates issued by other ECAs of the PKI.
+func (node *nodeImpl) loadRootCerts() error {
+	node.rootsCertPool = nil
+	node.intermediatesCertPool = x509.NewCertPool()
+
+	path := node.conf.getRootCertsExternalPath()
+	if path == "" {
+		return nil
+	}
+
+	raw, err := node.ks.loadExternalCert(path)
+	if err != nil {
+		node.error("Failed loading root certificates [%s].", err.Error())
+
+		return err
+	}
+
+	node.rootsCertPool = x509.NewCertPool()
+	if ok := node.rootsCertPool.AppendCertsFromPEM(raw); !ok {
+		node.error("Failed appending root certificates.")
+
+		return errors.New("Failed appending root certificates.")
+	}
+
+	return nil
+}
+
+// checkCertAgainstRoots validates cert against the roots, with chain the
+// certificates of the intermediate CAs that issued it. Once validated, the
+// intermediate CAs are trusted for validating the certificates they issue.
+// Without roots configured, the CAs are trusted as they are.
+func (node *nodeImpl) checkCertAgainstRoots(cert *x509.Certificate, chain [][]byte) error {
+	if node.rootsCertPool == nil {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	certs := make([]*x509.Certificate, len(chain))
+	for i, der := range chain {
+		c, err := utils.DERToX509Certificate(der)
+		if err != nil {
+			return err
+		}
+		intermediates.AddCert(c)
+		certs[i] = c
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         node.rootsCertPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := withKnownExtensionsHandled(cert).Verify(opts); err != nil {
+		return err
+	}
+
+	node.intermediatesLock.Lock()
+	defer node.intermediatesLock.Unlock()
+
+	for _, c := range certs {
+		node.intermediatesCertPool.AddCert(c)
+	}
+
+	return nil
+}
+
+// loadCACertsChain loads a CA certificate stored with the certificates of
+// the CAs that issued it, adds the CA certificate to pool and validates it
+// against the roots. It returns the DER encoded certificates.
+func (node *nodeImpl) loadCACertsChain(alias string, pool *x509.CertPool) ([][]byte, error) {
+	raw, err := node.ks.loadCert(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain [][]byte
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		chain = append(chain, block.Bytes)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("No PEM block available")
+	}
+
+	cert, err := utils.DERToX509Certificate(chain[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := node.checkCertAgainstRoots(cert, chain[1:]); err != nil {
+		return nil, err
+	}
+	pool.AddCert(cert)
+
+	return chain, nil
+}
+
+// getEnrollmentCertChain returns the enrollment certificate followed by the
+// certificates of the ECA and of the CAs that issued it
+func (node *nodeImpl) getEnrollmentCertChain() [][]byte {
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+
+	chain := [][]byte{utils.Clone(node.enrollCert.Raw)}
+	for _, der := range node.ecaCertsChain {
+		chain = append(chain, utils.Clone(der))
+	}
+
+	return chain
+}
@@ -21,8 +21,11 @@ package crypto
 
 import (
 	"errors"
+	"github.com/openblockchain/obc-peer/openchain/crypto/hsm"
 	"github.com/spf13/viper"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 const (
@@ -64,6 +67,21 @@ type configuration struct {
 
 	multiThreading bool
 	tCertBathSize  int
+
+	tCertPoolLow     int
+	tCertPoolHigh    int
+	tCertReusePolicy string
+	tCertReuseCount  int
+
+	crlInterval time.Duration
+
+	renewalThreshold time.Duration
+	renewalInterval  time.Duration
+
+	verificationWorkers int
+	certCacheSize       int
+
+	hsmEnabled bool
 }
 
 func (conf *configuration) init() error {
@@ -131,6 +149,75 @@ func (conf *configuration) init() error {
 		}
 	}
 
+	// Set TCert pool watermarks
+	conf.tCertPoolHigh = conf.tCertBathSize
+	if viper.IsSet("security.tcert.pool.high") {
+		ovveride := viper.GetInt("security.tcert.pool.high")
+		if ovveride > 0 {
+			conf.tCertPoolHigh = ovveride
+		}
+	}
+	conf.tCertPoolLow = conf.tCertPoolHigh / 4
+	if viper.IsSet("security.tcert.pool.low") {
+		conf.tCertPoolLow = viper.GetInt("security.tcert.pool.low")
+	}
+	if conf.tCertPoolLow < 0 || conf.tCertPoolLow >= conf.tCertPoolHigh {
+		return errors.New("Invalid TCert pool watermarks. Low must be in [0, high).")
+	}
+
+	// Set TCert reuse policy
+	conf.tCertReusePolicy = tCertReuseNever
+	if viper.IsSet("security.tcert.reuse.policy") {
+		ovveride := viper.GetString("security.tcert.reuse.policy")
+		if ovveride != "" {
+			conf.tCertReusePolicy = ovveride
+		}
+	}
+	conf.tCertReuseCount = 1
+	switch conf.tCertReusePolicy {
+	case tCertReuseNever:
+	case tCertReuseCount:
+		if count := viper.GetInt("security.tcert.reuse.count"); count > 0 {
+			conf.tCertReuseCount = count
+		}
+	default:
+		return errors.New("Invalid TCert reuse policy [" + conf.tCertReusePolicy + "].")
+	}
+
+	// Set CRL update interval
+	conf.crlInterval = time.Minute
+	if viper.IsSet("security.crl.interval") {
+		conf.crlInterval = viper.GetDuration("security.crl.interval")
+	}
+
+	// Set when the enrollment certificate is renewed before it expires, and
+	// how often its expiry is checked
+	conf.renewalThreshold = 7 * 24 * time.Hour
+	if viper.IsSet("security.enrollment.renewal.threshold") {
+		conf.renewalThreshold = viper.GetDuration("security.enrollment.renewal.threshold")
+	}
+	conf.renewalInterval = time.Hour
+	if viper.IsSet("security.enrollment.renewal.interval") {
+		conf.renewalInterval = viper.GetDuration("security.enrollment.renewal.interval")
+	}
+
+	// Set signature verification workers and certificate cache
+	conf.verificationWorkers = runtime.NumCPU()
+	if viper.IsSet("security.verification.workers") {
+		conf.verificationWorkers = viper.GetInt("security.verification.workers")
+	}
+	conf.certCacheSize = 1024
+	if viper.IsSet("security.verification.certcache") {
+		conf.certCacheSize = viper.GetInt("security.verification.certcache")
+	}
+
+	// Set whether the enrollment key is held by the HSM. Clients derive their
+	// TCert keys from it, hence it must stay in software for them.
+	conf.hsmEnabled = hsm.IsEnabled(conf.prefix)
+	if conf.hsmEnabled && conf.prefix == "client" {
+		return errors.New("The enrollment key of a client cannot be held by the HSM.")
+	}
+
 	// Set multithread
 	conf.multiThreading = false
 	if viper.IsSet("security.multithreading.enabled") {
@@ -196,6 +283,10 @@ func (conf *configuration) getEnrollmentKeyFilename() string {
 	return "enrollment.key"
 }
 
+func (conf *configuration) getEnrollmentKeyLabel() string {
+	return conf.prefix + "." + conf.name + ".enrollment"
+}
+
 func (conf *configuration) getEnrollmentCertFilename() string {
 	return "enrollment.cert"
 }
@@ -224,6 +315,10 @@ func (conf *configuration) getTLSCACertsExternalPath() string {
 	return viper.GetString("peer.pki.tls.rootcert.file")
 }
 
+func (conf *configuration) getRootCertsExternalPath() string {
+	return viper.GetString("peer.pki.rootcerts.file")
+}
+
 func (conf *configuration) isTLSEnabled() bool {
 	return viper.GetBool("peer.pki.tls.enabled")
 }
@@ -279,3 +374,19 @@ func (conf *configuration) getTCertOwnerKDFKeyFilename() string {
 func (conf *configuration) getTCertBathSize() int {
 	return conf.tCertBathSize
 }
+
+func (conf *configuration) getVerificationWorkers() int {
+	return conf.verificationWorkers
+}
+
+func (conf *configuration) getCertCacheSize() int {
+	return conf.certCacheSize
+}
+
+func (conf *configuration) getTCertPoolWatermarks() (int, int) {
+	return conf.tCertPoolLow, conf.tCertPoolHigh
+}
+
+func (conf *configuration) getTCertReuseCount() int {
+	return conf.tCertReuseCount
+}
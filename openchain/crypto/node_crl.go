@@ -0,0 +1,149 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"time"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// revocationKey identifies a certificate by its issuer and serial number.
+func revocationKey(issuer, serial string) string {
+	return issuer + "/" + serial
+}
+
+// isRevoked returns true if cert appears on one of the last CRLs fetched from
+// the ECA or TCA.
+func (node *nodeImpl) isRevoked(cert *x509.Certificate) bool {
+	node.crlLock.RLock()
+	defer node.crlLock.RUnlock()
+
+	return node.revoked[revocationKey(cert.Issuer.CommonName, cert.SerialNumber.String())]
+}
+
+// startCRLUpdate fetches the CRLs of the ECA and TCA, and keeps refreshing
+// them every configured interval until the node is closed.
+func (node *nodeImpl) startCRLUpdate() {
+	if node.conf.crlInterval <= 0 {
+		node.debug("CRL update disabled.")
+
+		return
+	}
+
+	node.crlStop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(node.conf.crlInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := node.updateCRLs(); err != nil {
+				node.warning("Failed updating CRLs [%s].", err.Error())
+			}
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(node.crlStop)
+}
+
+func (node *nodeImpl) stopCRLUpdate() {
+	if node.crlStop != nil {
+		close(node.crlStop)
+		node.crlStop = nil
+	}
+}
+
+// updateCRLs replaces the set of revoked certificates by the content of the
+// current ECA and TCA CRLs. If one of the CRLs cannot be retrieved or verified
+// the previous set is kept.
+func (node *nodeImpl) updateCRLs() error {
+	node.debug("Updating CRLs...")
+
+	revoked := make(map[string]bool)
+
+	if err := node.fetchCRL(node.conf.getECACertsChainFilename(), node.callECAReadCRL, revoked); err != nil {
+		return err
+	}
+	if err := node.fetchCRL(node.conf.getTCACertsChainFilename(), node.callTCAReadCRL, revoked); err != nil {
+		return err
+	}
+
+	node.crlLock.Lock()
+	node.revoked = revoked
+	node.crlLock.Unlock()
+
+	// Evict the certificates revoked since they were cached
+	if node.certs != nil {
+		node.certs.invalidate(func(cert *x509.Certificate) bool {
+			return revoked[revocationKey(cert.Issuer.CommonName, cert.SerialNumber.String())]
+		})
+	}
+
+	node.debug("Updating CRLs...done! [%d] revoked certificates.", len(revoked))
+
+	return nil
+}
+
+func (node *nodeImpl) fetchCRL(caAlias string, read func(context.Context, ...grpc.CallOption) (*obcca.CRL, error), revoked map[string]bool) error {
+	caCert, _, err := node.ks.loadCertX509AndDer(caAlias)
+	if err != nil {
+		return err
+	}
+
+	resp, err := read(context.Background())
+	if err != nil {
+		return err
+	}
+
+	crl, err := x509.ParseCRL(resp.Crl)
+	if err != nil {
+		node.error("Failed parsing CRL [%s].", err.Error())
+
+		return err
+	}
+
+	// Check the CRL against the CA certificate
+	if err := caCert.CheckCRLSignature(crl); err != nil {
+		node.error("Failed verifying CRL signature [%s].", err.Error())
+
+		return err
+	}
+	if crl.HasExpired(time.Now()) {
+		return errors.New("CRL has expired")
+	}
+
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+
+	for _, cert := range crl.TBSCertList.RevokedCertificates {
+		revoked[revocationKey(issuer.CommonName, cert.SerialNumber.String())] = true
+	}
+
+	return nil
+}
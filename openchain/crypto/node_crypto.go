@@ -11,6 +11,12 @@ func (node *nodeImpl) registerCryptoEngine(enrollID, enrollPWD string) error {
 		return err
 	}
 
+	if err := node.loadRootCerts(); err != nil {
+		node.error("Failed loading root certificates [%s].", err.Error())
+
+		return err
+	}
+
 	if err := node.retrieveECACertsChain(enrollID); err != nil {
 		node.error("Failed retrieveing ECA certs chain [%s].", err.Error())
 
@@ -44,11 +50,15 @@ func (node *nodeImpl) initCryptoEngine() error {
 	node.debug("Initializing node crypto engine...")
 
 	// Init certPools
-	node.rootsCertPool = x509.NewCertPool()
 	node.tlsCertPool = x509.NewCertPool()
 	node.ecaCertPool = x509.NewCertPool()
 	node.tcaCertPool = x509.NewCertPool()
 
+	// Load root certs
+	if err := node.loadRootCerts(); err != nil {
+		return err
+	}
+
 	// Load ECA certs chain
 	if err := node.loadECACertsChain(); err != nil {
 		return err
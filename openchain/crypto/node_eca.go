@@ -20,8 +20,8 @@ under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/x509"
 	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
 	protobuf "google/protobuf"
@@ -29,12 +29,16 @@ import (
 
 	"encoding/asn1"
 	"errors"
+	"fmt"
 	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
 	ecies "github.com/openblockchain/obc-peer/openchain/crypto/ecies/generic"
+	"github.com/openblockchain/obc-peer/openchain/crypto/hsm"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"io/ioutil"
+	"strconv"
 )
 
 var (
@@ -42,9 +46,27 @@ var (
 	ECertSubjectRole = asn1.ObjectIdentifier{2, 1, 3, 4, 5, 6, 7}
 )
 
+// ParseEnrollmentCertificate returns the enrollment ID and the role of the
+// owner of an enrollment certificate. The certificate is not validated.
+func ParseEnrollmentCertificate(ecert []byte) (string, int32, error) {
+	cert, err := utils.DERToX509Certificate(ecert)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid enrollment certificate: %s", err)
+	}
+	roleRaw, err := utils.GetCriticalExtension(cert, ECertSubjectRole)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed parsing the role in the enrollment certificate of %s: %s", cert.Subject.CommonName, err)
+	}
+	role, err := strconv.ParseInt(string(roleRaw), 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("Failed parsing the role in the enrollment certificate of %s: %s", cert.Subject.CommonName, err)
+	}
+	return cert.Subject.CommonName, int32(role), nil
+}
+
 func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 	// Retrieve ECA certificate and verify it
-	ecaCertRaw, err := node.getECACertificate()
+	ecaCertRaw, chain, err := node.getECACertificate()
 	if err != nil {
 		node.error("Failed getting ECA certificate [%s].", err.Error())
 
@@ -52,8 +74,6 @@ func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 	}
 	node.debug("ECA certificate [% x].", ecaCertRaw)
 
-	// TODO: Test ECA cert againt root CA
-	// TODO: check responce.Cert against rootCA
 	x509ECACert, err := utils.DERToX509Certificate(ecaCertRaw)
 	if err != nil {
 		node.error("Failed parsing ECA certificate [%s].", err.Error())
@@ -61,6 +81,13 @@ func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 		return err
 	}
 
+	// Check the ECA cert against the roots, if the ECA is part of a PKI
+	if err := node.checkCertAgainstRoots(x509ECACert, chain); err != nil {
+		node.error("Failed checking ECA certificate against the roots [%s].", err.Error())
+
+		return err
+	}
+
 	// Prepare ecaCertPool
 	node.ecaCertPool = x509.NewCertPool()
 	node.ecaCertPool.AddCert(x509ECACert)
@@ -68,7 +95,7 @@ func (node *nodeImpl) retrieveECACertsChain(userID string) error {
 	// Store ECA cert
 	node.debug("Storing ECA certificate for [%s]...", userID)
 
-	if err := node.ks.storeCert(node.conf.getECACertsChainFilename(), ecaCertRaw); err != nil {
+	if err := node.ks.storeCertsChain(node.conf.getECACertsChainFilename(), append([][]byte{ecaCertRaw}, chain...)); err != nil {
 		node.error("Failed storing eca certificate [%s].", err.Error())
 		return err
 	}
@@ -94,10 +121,12 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 		return err
 	}
 
-	// Store enrollment key
-	if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
-		node.error("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
-		return err
+	// Store enrollment key, unless held by the HSM
+	if !node.conf.hsmEnabled {
+		if err := node.ks.storePrivateKey(node.conf.getEnrollmentKeyFilename(), key); err != nil {
+			node.error("Failed storing enrollment key [id=%s]: [%s]", enrollID, err)
+			return err
+		}
 	}
 
 	// Store enrollment cert
@@ -118,6 +147,24 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 func (node *nodeImpl) loadEnrollmentKey() error {
 	node.debug("Loading enrollment key...")
 
+	if node.conf.hsmEnabled {
+		ks, err := hsm.GetKeyStore()
+		if err != nil {
+			node.error("Failed opening HSM key store [%s].", err.Error())
+
+			return err
+		}
+
+		node.enrollSignKey, err = ks.GetKey(node.conf.getEnrollmentKeyLabel())
+		if err != nil {
+			node.error("Failed loading enrollment private key from HSM [%s].", err.Error())
+
+			return err
+		}
+
+		return nil
+	}
+
 	enrollPrivKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
 	if err != nil {
 		node.error("Failed loading enrollment private key [%s].", err.Error())
@@ -126,6 +173,7 @@ func (node *nodeImpl) loadEnrollmentKey() error {
 	}
 
 	node.enrollPrivKey = enrollPrivKey.(*ecdsa.PrivateKey)
+	node.enrollSignKey = node.enrollPrivKey
 
 	return nil
 }
@@ -143,7 +191,7 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 
 	// TODO: move this to retrieve
 	pk := node.enrollCert.PublicKey.(*ecdsa.PublicKey)
-	err = utils.VerifySignCapability(node.enrollPrivKey, pk)
+	err = utils.VerifySignCapability(node.enrollSignKey, pk)
 	if err != nil {
 		node.error("Failed checking enrollment certificate against enrollment key [%s].", err.Error())
 
@@ -195,19 +243,13 @@ func (node *nodeImpl) loadEnrollmentChainKey() error {
 func (node *nodeImpl) loadECACertsChain() error {
 	node.debug("Loading ECA certificates chain...")
 
-	pem, err := node.ks.loadCert(node.conf.getECACertsChainFilename())
+	chain, err := node.loadCACertsChain(node.conf.getECACertsChainFilename(), node.ecaCertPool)
 	if err != nil {
 		node.error("Failed loading ECA certificates chain [%s].", err.Error())
 
 		return err
 	}
-
-	ok := node.ecaCertPool.AppendCertsFromPEM(pem)
-	if !ok {
-		node.error("Failed appending ECA certificates chain.")
-
-		return errors.New("Failed appending ECA certificates chain.")
-	}
+	node.ecaCertsChain = chain
 
 	return nil
 }
@@ -243,6 +285,22 @@ func (node *nodeImpl) callECAReadCACertificate(ctx context.Context, opts ...grpc
 	return cert, nil
 }
 
+func (node *nodeImpl) callECAReadCRL(ctx context.Context, opts ...grpc.CallOption) (*obcca.CRL, error) {
+	// Get an ECA Client
+	sock, ecaP, err := node.getECAClient()
+	defer sock.Close()
+
+	// Issue the request
+	crl, err := ecaP.ReadCRL(ctx, &obcca.Empty{}, opts...)
+	if err != nil {
+		node.error("Failed requesting ECA CRL [%s].", err.Error())
+
+		return nil, err
+	}
+
+	return crl, nil
+}
+
 func (node *nodeImpl) callECAReadCertificate(ctx context.Context, in *obcca.ECertReadReq, opts ...grpc.CallOption) (*obcca.CertPair, error) {
 	// Get an ECA Client
 	sock, ecaP, err := node.getECAClient()
@@ -282,13 +340,18 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 
 	// Run the protocol
 
-	signPriv, err := utils.NewECDSAKey()
+	var signPriv crypto.Signer
+	if node.conf.hsmEnabled {
+		signPriv, err = hsm.GetOrGenerateKey(node.conf.getEnrollmentKeyLabel(), conf.GetDefaultCurve())
+	} else {
+		signPriv, err = utils.NewECDSAKey()
+	}
 	if err != nil {
 		node.error("Failed generating ECDSA key [%s].", err.Error())
 
 		return nil, nil, nil, err
 	}
-	signPub, err := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	signPub, err := x509.MarshalPKIXPublicKey(signPriv.Public())
 	if err != nil {
 		node.error("Failed mashalling ECDSA key [%s].", err.Error())
 
@@ -348,11 +411,9 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	req.Tok.Tok = out
 	req.Sig = nil
 
-	hash := utils.NewHash()
 	raw, _ := proto.Marshal(req)
-	hash.Write(raw)
 
-	r, s, err := ecdsa.Sign(rand.Reader, signPriv, hash.Sum(nil))
+	r, s, err := utils.ECDSASignDirect(signPriv, raw)
 	if err != nil {
 		node.error("Failed signing [%s].", err.Error())
 
@@ -424,13 +485,195 @@ func (node *nodeImpl) getEnrollmentCertificateFromECA(id, pw string) (interface{
 	return signPriv, resp.Certs.Sign, resp.Chain.Tok, nil
 }
 
-func (node *nodeImpl) getECACertificate() ([]byte, error) {
+// renewEnrollmentData re-enrolls the node with new enrollment keys under its
+// current enrollment id and replaces the stored enrollment data with the
+// renewed one.
+func (node *nodeImpl) renewEnrollmentData() error {
+	if node.conf.hsmEnabled {
+		node.error("Enrollment renewal is not supported with an HSM.")
+
+		return errors.New("Enrollment renewal is not supported with an HSM.")
+	}
+
+	key, enrollCertRaw, enrollChainKey, err := node.renewEnrollmentCertificateFromECA()
+	if err != nil {
+		node.error("Failed renewing enrollment certificate [id=%s]: [%s]", node.enrollID, err)
+
+		return err
+	}
+	node.debug("Renewed enrollment certificate [% x].", enrollCertRaw)
+
+	cert, err := utils.DERToX509Certificate(enrollCertRaw)
+	if err != nil {
+		node.error("Failed parsing renewed enrollment certificate [id=%s]: [%s]", node.enrollID, err)
+		return err
+	}
+	if err := utils.VerifySignCapability(key, cert.PublicKey); err != nil {
+		node.error("Failed checking renewed enrollment certificate against enrollment key [id=%s]: [%s]", node.enrollID, err)
+		return err
+	}
+
+	// The previous certificate is revoked already, the renewed data must be
+	// kept. It is stored next to the current data first and renamed into place
+	// once complete, so that a failure while storing it leaves the current data
+	// intact.
+	keyAlias := node.conf.getEnrollmentKeyFilename()
+	certAlias := node.conf.getEnrollmentCertFilename()
+	chainKeyAlias := node.conf.getEnrollmentChainKeyFilename()
+	if err := node.ks.storePrivateKey(keyAlias+".new", key); err != nil {
+		node.error("Failed storing enrollment key [id=%s]: [%s]", node.enrollID, err)
+		return err
+	}
+	if err := node.ks.storeCert(certAlias+".new", enrollCertRaw); err != nil {
+		node.error("Failed storing enrollment certificate [id=%s]: [%s]", node.enrollID, err)
+		return err
+	}
+	if err := node.ks.storeKey(chainKeyAlias+".new", enrollChainKey); err != nil {
+		node.error("Failed storing enrollment chain key [id=%s]: [%s]", node.enrollID, err)
+		return err
+	}
+
+	node.enrollLock.Lock()
+	defer node.enrollLock.Unlock()
+
+	for _, alias := range []string{keyAlias, certAlias, chainKeyAlias} {
+		if err := node.ks.renameAlias(alias+".new", alias); err != nil {
+			return err
+		}
+	}
+
+	if err := node.loadEnrollmentKey(); err != nil {
+		return err
+	}
+	if err := node.loadEnrollmentCertificate(); err != nil {
+		return err
+	}
+	if err := node.loadEnrollmentChainKey(); err != nil {
+		return err
+	}
+
+	if node.renewed != nil {
+		node.renewed()
+	}
+
+	return nil
+}
+
+func (node *nodeImpl) renewEnrollmentCertificateFromECA() (interface{}, []byte, []byte, error) {
+	// Get a new ECA Client
+	sock, ecaP, err := node.getECAClient()
+	defer sock.Close()
+
+	signPriv, err := utils.NewECDSAKey()
+	if err != nil {
+		node.error("Failed generating ECDSA key [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+	signPub, err := x509.MarshalPKIXPublicKey(&signPriv.PublicKey)
+	if err != nil {
+		node.error("Failed mashalling ECDSA key [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+
+	encPriv, err := utils.NewECDSAKey()
+	if err != nil {
+		node.error("Failed generating Encryption key [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+	encPub, err := x509.MarshalPKIXPublicKey(&encPriv.PublicKey)
+	if err != nil {
+		node.error("Failed marshalling Encryption key [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+
+	req := &obcca.ECertRenewReq{
+		Ts:   &protobuf.Timestamp{Seconds: time.Now().Unix(), Nanos: 0},
+		Id:   &obcca.Identity{Id: node.enrollID},
+		Sign: &obcca.PublicKey{Type: obcca.CryptoType_ECDSA, Key: signPub},
+		Enc:  &obcca.PublicKey{Type: obcca.CryptoType_ECDSA, Key: encPub},
+	}
+
+	// Prove possession of the new key, then authenticate with the current one
+	raw, _ := proto.Marshal(req)
+	r, s, err := utils.ECDSASignDirect(signPriv, raw)
+	if err != nil {
+		node.error("Failed signing [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	req.NewSig = &obcca.Signature{Type: obcca.CryptoType_ECDSA, R: R, S: S}
+
+	raw, _ = proto.Marshal(req)
+	r, s, err = node.ecdsaSignWithEnrollmentKey(raw)
+	if err != nil {
+		node.error("Failed signing [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+	R, _ = r.MarshalText()
+	S, _ = s.MarshalText()
+	req.Sig = &obcca.Signature{Type: obcca.CryptoType_ECDSA, R: R, S: S}
+
+	resp, err := ecaP.RenewCertificatePair(context.Background(), req)
+	if err != nil {
+		node.error("Failed invoking RenewCertificatePair [%s].", err.Error())
+
+		return nil, nil, nil, err
+	}
+	if resp.Certs == nil || resp.Chain == nil {
+		node.error("Invalid RenewCertificatePair response.")
+
+		return nil, nil, nil, errors.New("Invalid RenewCertificatePair response.")
+	}
+
+	// Verify response
+	x509SignCert, err := utils.DERToX509Certificate(resp.Certs.Sign)
+	if err != nil {
+		node.error("Failed parsing signing enrollment certificate for signing: [%s]", err)
+
+		return nil, nil, nil, err
+	}
+	if x509SignCert.Subject.CommonName != node.enrollID {
+		node.error("Renewed enrollment certificate issued for [%s].", x509SignCert.Subject.CommonName)
+
+		return nil, nil, nil, errors.New("Renewed enrollment certificate has been issued for another identity.")
+	}
+	err = utils.CheckCertAgainstSKAndRoot(x509SignCert, signPriv, node.ecaCertPool)
+	if err != nil {
+		node.error("Failed checking signing enrollment certificate for signing: [%s]", err)
+
+		return nil, nil, nil, err
+	}
+
+	x509EncCert, err := utils.DERToX509Certificate(resp.Certs.Enc)
+	if err != nil {
+		node.error("Failed parsing signing enrollment certificate for encrypting: [%s]", err)
+
+		return nil, nil, nil, err
+	}
+	err = utils.CheckCertAgainstSKAndRoot(x509EncCert, encPriv, node.ecaCertPool)
+	if err != nil {
+		node.error("Failed checking signing enrollment certificate for encrypting: [%s]", err)
+
+		return nil, nil, nil, err
+	}
+
+	return signPriv, resp.Certs.Sign, resp.Chain.Tok, nil
+}
+
+func (node *nodeImpl) getECACertificate() ([]byte, [][]byte, error) {
 	responce, err := node.callECAReadCACertificate(context.Background())
 	if err != nil {
 		node.error("Failed requesting ECA certificate [%s].", err.Error())
 
-		return nil, err
+		return nil, nil, err
 	}
 
-	return responce.Cert, nil
+	return responce.Cert, responce.Chain, nil
 }
@@ -20,9 +20,11 @@ under the License.
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/x509"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"sync"
 )
 
 // Public Struct
@@ -47,6 +49,12 @@ type nodeImpl struct {
 	ecaCertPool   *x509.CertPool
 	tcaCertPool   *x509.CertPool
 
+	// Intermediate CAs validated against the roots, and the certificates of
+	// the CAs that issued the ECA certificate up to a root
+	intermediatesCertPool *x509.CertPool
+	intermediatesLock     sync.RWMutex
+	ecaCertsChain         [][]byte
+
 	// 48-bytes identifier
 	id []byte
 
@@ -56,11 +64,35 @@ type nodeImpl struct {
 	enrollPrivKey  *ecdsa.PrivateKey
 	enrollCertHash []byte
 
+	// Enrollment key used for signing, held by the HSM if enabled in which
+	// case enrollPrivKey is nil
+	enrollSignKey crypto.Signer
+
 	// Enrollment Chain
 	enrollChainKey []byte
 
+	// Guards the enrollment key and certificate while they are renewed
+	enrollLock sync.RWMutex
+
+	// Automatic renewal of the enrollment certificate, and what to reset
+	// once it has been renewed
+	renewalStop chan struct{}
+	renewed     func()
+
 	// TLS
 	tlsCert *x509.Certificate
+
+	// Certificates revoked by the ECA and TCA
+	revoked map[string]bool
+	crlLock sync.RWMutex
+	crlStop chan struct{}
+
+	// Signature verification workers
+	verifyJobs chan *verifyJob
+	verifyStop chan struct{}
+
+	// Certificates parsed and validated recently
+	certs *certCache
 }
 
 func (node *nodeImpl) GetName() string {
@@ -170,6 +202,10 @@ func (node *nodeImpl) init(prefix, name string, pwd []byte) error {
 }
 
 func (node *nodeImpl) close() error {
+	node.stopEnrollmentRenewal()
+	node.stopCRLUpdate()
+	node.stopVerifier()
+
 	// Close keystore
 	var err error
 
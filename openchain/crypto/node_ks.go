@@ -54,6 +54,17 @@ func addDefaultCert(key string, cert []byte) error {
 */
 
 func (node *nodeImpl) initKeyStore(pwd []byte) error {
+	// Without a password of its own, the keystore is encrypted under the
+	// passphrase of security.keystore, if enabled
+	if len(pwd) == 0 {
+		var err error
+		if pwd, err = getKeyStorePassphrase(); err != nil {
+			node.error("Failed unlocking keystore [%s].", err.Error())
+
+			return err
+		}
+	}
+
 	ks := keyStore{}
 	if err := ks.init(node, pwd); err != nil {
 		return err
@@ -218,6 +229,23 @@ func (ks *keyStore) storeCert(alias string, der []byte) error {
 	return nil
 }
 
+// storeCertsChain stores a certificate followed by the certificates of the
+// CAs that issued it
+func (ks *keyStore) storeCertsChain(alias string, ders [][]byte) error {
+	var raw []byte
+	for _, der := range ders {
+		raw = append(raw, utils.DERCertToPEM(der)...)
+	}
+
+	err := ioutil.WriteFile(ks.node.conf.getPathForAlias(alias), raw, 0700)
+	if err != nil {
+		ks.node.error("Failed storing certificates chain [%s]: [%s]", alias, err)
+		return err
+	}
+
+	return nil
+}
+
 func (ks *keyStore) loadCert(alias string) ([]byte, error) {
 	path := ks.node.conf.getPathForAlias(alias)
 	ks.node.debug("Loading certificate [%s] at [%s]...", alias, path)
@@ -266,6 +294,17 @@ func (ks *keyStore) loadCertX509AndDer(alias string) (*x509.Certificate, []byte,
 	return cert, der, nil
 }
 
+// renameAlias replaces the entry stored under to by the one stored under from.
+// The entry is renamed in place, so it never appears partially written.
+func (ks *keyStore) renameAlias(from, to string) error {
+	if err := os.Rename(ks.node.conf.getPathForAlias(from), ks.node.conf.getPathForAlias(to)); err != nil {
+		ks.node.error("Failed renaming [%s] to [%s]: [%s]", from, to, err)
+		return err
+	}
+
+	return nil
+}
+
 func (ks *keyStore) close() error {
 	ks.node.debug("Closing keystore...")
 	err := ks.sqlDB.Close()
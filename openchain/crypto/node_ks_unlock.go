@@ -0,0 +1,222 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/spf13/viper"
+)
+
+// KeyStoreUnlocker returns the passphrase the keys of the local keystores are
+// encrypted under
+type KeyStoreUnlocker func() ([]byte, error)
+
+var (
+	keyStoreUnlockers = map[string]KeyStoreUnlocker{
+		"passphrase": unlockWithPassphrase,
+		"kms":        unlockWithKMS,
+	}
+
+	// Passphrase of the keystores, unlocked once per process
+	keyStorePassphrase []byte
+	keyStoreMutex      sync.Mutex
+)
+
+// RegisterKeyStoreUnlocker makes an unlock method available under name for
+// security.keystore.unlock, eg. a KMS reached through its client library
+func RegisterKeyStoreUnlocker(name string, unlocker KeyStoreUnlocker) {
+	keyStoreMutex.Lock()
+	defer keyStoreMutex.Unlock()
+
+	keyStoreUnlockers[name] = unlocker
+}
+
+// getKeyStorePassphrase returns the passphrase of the keystores unlocked with
+// the method of security.keystore.unlock, nil if they are not encrypted
+func getKeyStorePassphrase() ([]byte, error) {
+	keyStoreMutex.Lock()
+	defer keyStoreMutex.Unlock()
+
+	method := viper.GetString("security.keystore.unlock")
+	if method == "" {
+		return nil, nil
+	}
+	if keyStorePassphrase != nil {
+		return utils.Clone(keyStorePassphrase), nil
+	}
+
+	unlocker, ok := keyStoreUnlockers[method]
+	if !ok {
+		return nil, errors.New("Invalid keystore unlock method [" + method + "].")
+	}
+	pwd, err := unlocker()
+	if err != nil {
+		return nil, err
+	}
+	if len(pwd) == 0 {
+		return nil, errors.New("Empty keystore passphrase.")
+	}
+	keyStorePassphrase = pwd
+
+	return utils.Clone(pwd), nil
+}
+
+// unlockWithPassphrase reads the passphrase from the environment variable
+// named by security.keystore.passphrase.env, or else from the file
+// security.keystore.passphrase.file
+func unlockWithPassphrase() ([]byte, error) {
+	if env := viper.GetString("security.keystore.passphrase.env"); env != "" {
+		if pwd := os.Getenv(env); pwd != "" {
+			return []byte(pwd), nil
+		}
+	}
+	if file := viper.GetString("security.keystore.passphrase.file"); file != "" {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(raw, "\r\n"), nil
+	}
+
+	return nil, errors.New("No keystore passphrase in the environment or in a file.")
+}
+
+// unlockWithKMS has an external KMS unwrap the passphrase kept wrapped under a
+// KMS key in security.keystore.kms.wrapped. security.keystore.kms.command is
+// run with the wrapped passphrase on its standard input and prints the
+// passphrase on its standard output, eg. the decrypt command of a KMS client.
+func unlockWithKMS() ([]byte, error) {
+	command := strings.Fields(viper.GetString("security.keystore.kms.command"))
+	if len(command) == 0 {
+		return nil, errors.New("No KMS command configured.")
+	}
+	wrapped, err := ioutil.ReadFile(viper.GetString("security.keystore.kms.wrapped"))
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(wrapped)
+	cmd.Stderr = &stderr
+	pwd, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New("Failed unwrapping keystore passphrase [" + err.Error() + "]: " + strings.TrimSpace(stderr.String()))
+	}
+
+	return bytes.TrimRight(pwd, "\r\n"), nil
+}
+
+// EncryptKeyStores encrypts under the passphrase of security.keystore the keys
+// kept in the clear in the local keystores of the clients, peers and
+// validators, which were created before the keystores were encrypted. Keys
+// already encrypted are checked to decrypt under the passphrase. The TLS keys
+// stay in the clear. It returns the number of keys encrypted. The keystores
+// must not be in use.
+func EncryptKeyStores() (int, error) {
+	pwd, err := getKeyStorePassphrase()
+	if err != nil {
+		return 0, err
+	}
+	if pwd == nil {
+		return 0, errors.New("Keystore encryption is not enabled, set security.keystore.unlock.")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(viper.GetString("peer.fileSystemPath"), "crypto", "*", "*", "ks", "raw", "*"))
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for _, path := range paths {
+		ok, err := encryptKeyFile(path, pwd)
+		if err != nil {
+			return encrypted, err
+		}
+		if ok {
+			encrypted++
+		}
+	}
+
+	return encrypted, nil
+}
+
+// encryptKeyFile encrypts the key kept in the clear at path. It returns false
+// for keys already encrypted and for the entries that are not keys.
+func encryptKeyFile(path string, pwd []byte) (bool, error) {
+	if filepath.Base(path) == (&configuration{}).getTLSKeyFilename() {
+		return false, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return false, nil
+	}
+	if x509.IsEncryptedPEMBlock(block) {
+		if _, err := x509.DecryptPEMBlock(block, pwd); err != nil {
+			return false, errors.New("Key [" + path + "] is encrypted under another passphrase.")
+		}
+		return false, nil
+	}
+
+	var cooked []byte
+	switch block.Type {
+	case "ECDSA PRIVATE KEY":
+		var key interface{}
+		if key, err = utils.DERToPrivateKey(block.Bytes); err == nil {
+			cooked, err = utils.PrivateKeyToEncryptedPEM(key, pwd)
+		}
+	case "AES PRIVATE KEY":
+		cooked, err = utils.AEStoEncryptedPEM(block.Bytes, pwd)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if cooked == nil {
+		return false, errors.New("Unsupported key [" + path + "].")
+	}
+
+	// Written aside and renamed, so that the key is never lost halfway
+	if err := ioutil.WriteFile(path+".tmp", cooked, 0700); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
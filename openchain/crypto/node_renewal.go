@@ -0,0 +1,132 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package crypto
+
+import (
+	"sync"
+	"time"
+)
+
+// EnrollmentRenewal reports the outcome of an automatic renewal of the
+// enrollment certificate of a node.
+type EnrollmentRenewal struct {
+	EnrollmentID string
+
+	// ID of the node before the renewal, the hash of its previous enrollment
+	// certificate
+	PreviousID []byte
+
+	// Expiry of the enrollment certificate in use after the renewal
+	NotAfter time.Time
+
+	// Why the renewal failed, nil if the certificate has been renewed
+	Err error
+}
+
+// RenewalHandler is notified of automatic enrollment renewals
+type RenewalHandler func(renewal *EnrollmentRenewal)
+
+var (
+	renewalHandlers []RenewalHandler
+	renewalLock     sync.Mutex
+)
+
+// AddRenewalHandler registers handler to be notified whenever a node has tried
+// to renew its enrollment certificate before it expires.
+func AddRenewalHandler(handler RenewalHandler) {
+	renewalLock.Lock()
+	defer renewalLock.Unlock()
+
+	renewalHandlers = append(renewalHandlers, handler)
+}
+
+func notifyRenewal(renewal *EnrollmentRenewal) {
+	renewalLock.Lock()
+	handlers := renewalHandlers
+	renewalLock.Unlock()
+
+	for _, handler := range handlers {
+		handler(renewal)
+	}
+}
+
+// startEnrollmentRenewal checks the expiry of the enrollment certificate every
+// configured interval until the node is closed, renewing the certificate once
+// it expires within the configured threshold.
+func (node *nodeImpl) startEnrollmentRenewal() {
+	if node.conf.renewalThreshold <= 0 || node.conf.renewalInterval <= 0 {
+		node.debug("Enrollment renewal disabled.")
+
+		return
+	}
+
+	node.renewalStop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(node.conf.renewalInterval)
+		defer ticker.Stop()
+
+		for {
+			node.checkEnrollmentRenewal(time.Now())
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(node.renewalStop)
+}
+
+func (node *nodeImpl) stopEnrollmentRenewal() {
+	if node.renewalStop != nil {
+		close(node.renewalStop)
+		node.renewalStop = nil
+	}
+}
+
+// checkEnrollmentRenewal renews the enrollment certificate if it expires
+// within the configured threshold of now. Returns nil if the certificate has
+// not to be renewed yet.
+func (node *nodeImpl) checkEnrollmentRenewal(now time.Time) *EnrollmentRenewal {
+	node.enrollLock.RLock()
+	notAfter := node.enrollCert.NotAfter
+	previous := node.id
+	node.enrollLock.RUnlock()
+
+	if now.Add(node.conf.renewalThreshold).Before(notAfter) {
+		return nil
+	}
+	node.info("Enrollment certificate expires at [%s], renewing it...", notAfter)
+
+	renewal := &EnrollmentRenewal{EnrollmentID: node.enrollID, PreviousID: previous, NotAfter: notAfter}
+	if renewal.Err = node.renewEnrollmentData(); renewal.Err != nil {
+		node.warning("Failed renewing enrollment certificate expiring at [%s]: [%s].", notAfter, renewal.Err)
+	} else {
+		node.enrollLock.RLock()
+		renewal.NotAfter = node.enrollCert.NotAfter
+		node.enrollLock.RUnlock()
+
+		node.info("Renewing enrollment certificate...done! Expires at [%s].", renewal.NotAfter)
+	}
+
+	notifyRenewal(renewal)
+
+	return renewal
+}
@@ -33,13 +33,19 @@ func (node *nodeImpl) sign(signKey interface{}, msg []byte) ([]byte, error) {
 func (node *nodeImpl) signWithEnrollmentKey(msg []byte) ([]byte, error) {
 	node.debug("Signing message [% x].", msg)
 
-	return utils.ECDSASign(node.enrollPrivKey, msg)
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+
+	return utils.ECDSASign(node.enrollSignKey, msg)
 }
 
 func (node *nodeImpl) ecdsaSignWithEnrollmentKey(msg []byte) (*big.Int, *big.Int, error) {
 	node.debug("Signing message direct [% x].", msg)
 
-	return utils.ECDSASignDirect(node.enrollPrivKey, msg)
+	node.enrollLock.RLock()
+	defer node.enrollLock.RUnlock()
+
+	return utils.ECDSASignDirect(node.enrollSignKey, msg)
 }
 
 func (node *nodeImpl) verify(verKey interface{}, msg, signature []byte) (bool, error) {
@@ -22,7 +22,6 @@ package crypto
 import (
 	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
 
-	"errors"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -30,7 +29,7 @@ import (
 
 func (node *nodeImpl) retrieveTCACertsChain(userID string) error {
 	// Retrieve TCA certificate and verify it
-	tcaCertRaw, err := node.getTCACertificate()
+	tcaCertRaw, chain, err := node.getTCACertificate()
 	if err != nil {
 		node.error("Failed getting TCA certificate [%s].", err.Error())
 
@@ -38,18 +37,24 @@ func (node *nodeImpl) retrieveTCACertsChain(userID string) error {
 	}
 	node.debug("TCA certificate [% x]", tcaCertRaw)
 
-	// TODO: Test TCA cert againt root CA
-	_, err = utils.DERToX509Certificate(tcaCertRaw)
+	x509TCACert, err := utils.DERToX509Certificate(tcaCertRaw)
 	if err != nil {
 		node.error("Failed parsing TCA certificate [%s].", err.Error())
 
 		return err
 	}
 
+	// Check the TCA cert against the roots, if the TCA is part of a PKI
+	if err := node.checkCertAgainstRoots(x509TCACert, chain); err != nil {
+		node.error("Failed checking TCA certificate against the roots [%s].", err.Error())
+
+		return err
+	}
+
 	// Store TCA cert
 	node.debug("Storing TCA certificate for [%s]...", userID)
 
-	if err := node.ks.storeCert(node.conf.getTCACertsChainFilename(), tcaCertRaw); err != nil {
+	if err := node.ks.storeCertsChain(node.conf.getTCACertsChainFilename(), append([][]byte{tcaCertRaw}, chain...)); err != nil {
 		node.error("Failed storing tca certificate [%s].", err.Error())
 		return err
 	}
@@ -61,21 +66,12 @@ func (node *nodeImpl) loadTCACertsChain() error {
 	// Load TCA certs chain
 	node.debug("Loading TCA certificates chain...")
 
-	cert, err := node.ks.loadCert(node.conf.getTCACertsChainFilename())
-	if err != nil {
+	if _, err := node.loadCACertsChain(node.conf.getTCACertsChainFilename(), node.tcaCertPool); err != nil {
 		node.error("Failed loading TCA certificates chain [%s].", err.Error())
 
 		return err
 	}
 
-	// Prepare ecaCertPool
-	ok := node.tcaCertPool.AppendCertsFromPEM(cert)
-	if !ok {
-		node.error("Failed appending TCA certificates chain.")
-
-		return errors.New("Failed appending TCA certificates chain.")
-	}
-
 	return nil
 }
 
@@ -110,15 +106,29 @@ func (node *nodeImpl) callTCAReadCACertificate(ctx context.Context, opts ...grpc
 	return cert, nil
 }
 
-func (node *nodeImpl) getTCACertificate() ([]byte, error) {
-	response, err := node.callTCAReadCACertificate(context.Background())
+func (node *nodeImpl) callTCAReadCRL(ctx context.Context, opts ...grpc.CallOption) (*obcca.CRL, error) {
+	// Get a TCA Client
+	sock, tcaP, err := node.getTCAClient()
+	defer sock.Close()
+
+	// Issue the request
+	crl, err := tcaP.ReadCRL(ctx, &obcca.Empty{}, opts...)
 	if err != nil {
-		node.error("Failed requesting TCA certificate [%s].", err.Error())
+		node.error("Failed requesting TCA CRL [%s].", err.Error())
 
 		return nil, err
 	}
 
-	// TODO: check response.Cert against rootCA
+	return crl, nil
+}
+
+func (node *nodeImpl) getTCACertificate() ([]byte, [][]byte, error) {
+	response, err := node.callTCAReadCACertificate(context.Background())
+	if err != nil {
+		node.error("Failed requesting TCA certificate [%s].", err.Error())
+
+		return nil, nil, err
+	}
 
-	return response.Cert, nil
+	return response.Cert, response.Chain, nil
 }
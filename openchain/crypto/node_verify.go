@@ -0,0 +1,130 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"sync"
+)
+
+// Signatures of transactions are verified by a pool of workers shared by
+// all the goroutines receiving transactions, so that a batch of transactions
+// is verified in parallel. ECDSA signatures do not carry the y coordinate of
+// R, which rules out verifying a batch as a single equation; instead the
+// signatures of a batch are spread over the workers and repeated signatures
+// are verified once.
+
+// verifyJob is the verification of a signature of msg under key
+type verifyJob struct {
+	key       interface{}
+	msg       []byte
+	signature []byte
+
+	// Outcome of the verification
+	ok  bool
+	err error
+
+	wg *sync.WaitGroup
+}
+
+// digest identifies the verification, empty if the key cannot be encoded
+func (job *verifyJob) digest() string {
+	key, ok := job.key.(*ecdsa.PublicKey)
+	if !ok {
+		return ""
+	}
+	h := sha256.New()
+	h.Write(elliptic.Marshal(key.Curve, key.X, key.Y))
+	h.Write(job.msg)
+	h.Write(job.signature)
+	return string(h.Sum(nil))
+}
+
+// startVerifier starts the configured number of verification workers. Without
+// workers signatures are verified by the caller.
+func (node *nodeImpl) startVerifier() {
+	workers := node.conf.getVerificationWorkers()
+	if workers <= 0 {
+		node.debug("Verification workers disabled.")
+
+		return
+	}
+
+	node.verifyJobs = make(chan *verifyJob, workers)
+	node.verifyStop = make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func(jobs chan *verifyJob, stop chan struct{}) {
+			for {
+				select {
+				case job := <-jobs:
+					job.ok, job.err = node.verify(job.key, job.msg, job.signature)
+					job.wg.Done()
+				case <-stop:
+					return
+				}
+			}
+		}(node.verifyJobs, node.verifyStop)
+	}
+}
+
+func (node *nodeImpl) stopVerifier() {
+	if node.verifyStop != nil {
+		close(node.verifyStop)
+		node.verifyStop = nil
+		node.verifyJobs = nil
+	}
+}
+
+// verifyBatch verifies the signatures of jobs, setting their outcome
+func (node *nodeImpl) verifyBatch(jobs []*verifyJob) {
+	// Repeated signatures are verified once
+	unique := make(map[string]*verifyJob)
+	var pending []*verifyJob
+	for _, job := range jobs {
+		digest := job.digest()
+		if digest == "" {
+			pending = append(pending, job)
+		} else if _, ok := unique[digest]; !ok {
+			unique[digest] = job
+			pending = append(pending, job)
+		}
+	}
+
+	if node.verifyJobs == nil {
+		for _, job := range pending {
+			job.ok, job.err = node.verify(job.key, job.msg, job.signature)
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(len(pending))
+		for _, job := range pending {
+			job.wg = &wg
+			node.verifyJobs <- job
+		}
+		wg.Wait()
+	}
+
+	for _, job := range jobs {
+		if first, ok := unique[job.digest()]; ok && first != job {
+			job.ok, job.err = first.ok, first.err
+		}
+	}
+}
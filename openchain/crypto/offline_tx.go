@@ -0,0 +1,218 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	obc "github.com/openblockchain/obc-peer/protos"
+)
+
+// OfflineTxOpts carries what a transaction is bound to when it is built
+// without an initialized client, for instance on an air-gapped machine that
+// hands the transaction to a hardware wallet for signing and to a relay for
+// submission.
+type OfflineTxOpts struct {
+	// Cert is the DER encoded TCert, or ECert, the transaction is signed with
+	Cert []byte
+
+	// AttributesKey of the TCert, disclosed to the chaincode. Optional.
+	AttributesKey []byte
+
+	// Nonce of a confidential transaction. A random nonce is used if empty.
+	Nonce []byte
+
+	// ChainKey is the chain key the client received at enrollment, as kept in
+	// its key store. Only confidential transactions need it.
+	ChainKey []byte
+}
+
+// BuildDeployTransaction returns the unsigned deploy transaction a client
+// would create for the spec, bound to the certificate in opts
+func BuildDeployTransaction(spec *obc.ChaincodeDeploymentSpec, uuid string, opts *OfflineTxOpts) (*obc.Transaction, error) {
+	if spec == nil || spec.ChaincodeSpec == nil || opts == nil {
+		return nil, utils.ErrNilArgument
+	}
+	tx, err := obc.NewChaincodeDeployTransaction(spec, uuid)
+	if err != nil {
+		return nil, err
+	}
+	if err := bindOfflineTransaction(tx, spec.ChaincodeSpec, opts); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// BuildExecuteTransaction returns the unsigned execute transaction a client
+// would create for the spec, bound to the certificate in opts
+func BuildExecuteTransaction(spec *obc.ChaincodeInvocationSpec, uuid string, opts *OfflineTxOpts) (*obc.Transaction, error) {
+	if spec == nil || spec.ChaincodeSpec == nil || opts == nil {
+		return nil, utils.ErrNilArgument
+	}
+	tx, err := obc.NewChaincodeExecute(spec, uuid, obc.Transaction_CHAINCODE_EXECUTE)
+	if err != nil {
+		return nil, err
+	}
+	if err := bindOfflineTransaction(tx, spec.ChaincodeSpec, opts); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// BuildConfigTransaction returns the unsigned configuration transaction
+// invoking the network configuration chaincode with the spec. Validators only
+// execute it when signed with the enrollment certificate of an administrator,
+// so opts must hold the ECert of one.
+func BuildConfigTransaction(spec *obc.ChaincodeInvocationSpec, uuid string, opts *OfflineTxOpts) (*obc.Transaction, error) {
+	if spec == nil || spec.ChaincodeSpec == nil || opts == nil {
+		return nil, utils.ErrNilArgument
+	}
+	tx, err := obc.NewChaincodeExecute(spec, uuid, obc.Transaction_CHAINCODE_CONFIG)
+	if err != nil {
+		return nil, err
+	}
+	if err := bindOfflineTransaction(tx, spec.ChaincodeSpec, opts); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// bindOfflineTransaction fills in the fields createDeployTx and
+// createExecuteTx set and appends the certificate
+func bindOfflineTransaction(tx *obc.Transaction, spec *obc.ChaincodeSpec, opts *OfflineTxOpts) error {
+	if len(opts.Cert) == 0 {
+		return utils.ErrTransactionCertificate
+	}
+	if _, err := utils.DERToX509Certificate(opts.Cert); err != nil {
+		return err
+	}
+
+	// Copy metadata from ChaincodeSpec
+	tx.Metadata = spec.Metadata
+
+	// Disclose the attributes key of the TCert to the chaincode
+	tx.AttributesKey = opts.AttributesKey
+
+	// Handle confidentiality
+	if spec.ConfidentialityLevel == obc.ConfidentialityLevel_CONFIDENTIAL {
+		if len(opts.ChainKey) == 0 {
+			return errors.New("Failed building confidential transaction. Missing chain key.")
+		}
+		tx.ConfidentialityLevel = obc.ConfidentialityLevel_CONFIDENTIAL
+		if len(opts.Nonce) == 0 {
+			nonce, err := utils.GetRandomBytes(utils.NonceSize)
+			if err != nil {
+				return err
+			}
+			tx.Nonce = nonce
+		} else {
+			tx.Nonce = opts.Nonce
+		}
+		if err := encryptTransaction(opts.ChainKey, tx); err != nil {
+			return err
+		}
+	}
+
+	tx.Cert = opts.Cert
+	return nil
+}
+
+// GetTransactionSigningBytes returns the bytes the signature and the
+// co-signatures of tx cover. A signer that cannot run SignTransaction, such
+// as a hardware wallet, signs these and the result is appended with
+// AttachTransactionSignature.
+func GetTransactionSigningBytes(tx *obc.Transaction) ([]byte, error) {
+	if tx == nil {
+		return nil, utils.ErrNilArgument
+	}
+	signature, coSignatures := tx.Signature, tx.CoSignatures
+	tx.Signature, tx.CoSignatures = nil, nil
+	raw, err := proto.Marshal(tx)
+	tx.Signature, tx.CoSignatures = signature, coSignatures
+	return raw, err
+}
+
+// SignTransaction signs tx with the private key of its certificate, an
+// *ecdsa.PrivateKey or a crypto.Signer held by an HSM, and appends the signature
+func SignTransaction(tx *obc.Transaction, signKey interface{}) error {
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		return err
+	}
+	signature, err := utils.ECDSASign(signKey, raw)
+	if err != nil {
+		return err
+	}
+	return AttachTransactionSignature(tx, signature)
+}
+
+// AttachTransactionSignature appends a signature produced over
+// GetTransactionSigningBytes after checking it against the certificate of tx.
+// Whether the certificate is trusted is left to the validators.
+func AttachTransactionSignature(tx *obc.Transaction, signature []byte) error {
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		return err
+	}
+	if len(tx.Cert) == 0 {
+		return utils.ErrTransactionCertificate
+	}
+	if err := verifyTransactionSignature(raw, tx.Cert, signature); err != nil {
+		return err
+	}
+	tx.Signature = signature
+	return nil
+}
+
+// CoSignTransaction signs tx on behalf of the owner of cert, as required by
+// chaincodes with a signature policy, and appends the co-signature. The
+// invoker may sign tx before or after it is co-signed.
+func CoSignTransaction(tx *obc.Transaction, cert []byte, signKey interface{}) error {
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		return err
+	}
+	signature, err := utils.ECDSASign(signKey, raw)
+	if err != nil {
+		return err
+	}
+	return AttachTransactionCoSignature(tx, cert, signature)
+}
+
+// AttachTransactionCoSignature appends a co-signature produced over
+// GetTransactionSigningBytes by the owner of cert after checking it
+func AttachTransactionCoSignature(tx *obc.Transaction, cert, signature []byte) error {
+	raw, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		return err
+	}
+	if err := verifyTransactionSignature(raw, cert, signature); err != nil {
+		if err == utils.ErrInvalidTransactionSignature {
+			err = utils.ErrInvalidTransactionCoSignature
+		}
+		return err
+	}
+	tx.CoSignatures = append(tx.CoSignatures, &obc.TransactionSignature{Cert: cert, Signature: signature})
+	return nil
+}
+
+// verifyTransactionSignature checks a signature over the signing bytes of a
+// transaction against the key of the DER encoded certificate
+func verifyTransactionSignature(raw, der, signature []byte) error {
+	cert, err := utils.DERToX509Certificate(der)
+	if err != nil {
+		return err
+	}
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok {
+		return errors.New("Failed verifying signature. The certificate does not hold an ECDSA key.")
+	}
+	ok, err := utils.ECDSAVerify(cert.PublicKey, raw, signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return utils.ErrInvalidTransactionSignature
+	}
+	return nil
+}
@@ -20,7 +20,6 @@ under the License.
 package crypto
 
 import (
-	"github.com/golang/protobuf/proto"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 	obc "github.com/openblockchain/obc-peer/protos"
 )
@@ -39,6 +38,9 @@ func (peer *peerImpl) GetName() string {
 
 // GetID returns this peer's identifier
 func (peer *peerImpl) GetID() []byte {
+	peer.node.enrollLock.RLock()
+	defer peer.node.enrollLock.RUnlock()
+
 	return utils.Clone(peer.node.id)
 }
 
@@ -55,51 +57,106 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 		return nil, utils.ErrNotInitialized
 	}
 
-	//	peer.node.debug("Pre validating [%s].", tx.String())
-	peer.node.debug("Tx confdential level [%s].", tx.ConfidentialityLevel.String())
+	jobs, err := peer.prepareTransactionVerification(tx)
+	if err != nil {
+		return tx, err
+	}
+	peer.node.verifyBatch(jobs)
 
-	if tx.Cert != nil && tx.Signature != nil {
-		// Verify the transaction
-		// 1. Unmarshal cert
-		cert, err := utils.DERToX509Certificate(tx.Cert)
-		if err != nil {
-			peer.node.error("TransactionPreExecution: failed unmarshalling cert [%s] [%s].", err.Error())
-			return tx, err
+	return tx, transactionVerificationError(jobs)
+}
+
+// TransactionsPreValidation verifies a batch of transactions like
+// TransactionPreValidation, verifying their signatures in parallel. It
+// returns an error for each transaction, nil if it is valid.
+func (peer *peerImpl) TransactionsPreValidation(txs []*obc.Transaction) ([]*obc.Transaction, []error) {
+	errs := make([]error, len(txs))
+	if !peer.isInitialized {
+		for i := range errs {
+			errs[i] = utils.ErrNotInitialized
 		}
+		return nil, errs
+	}
 
-		// TODO: verify cert
+	jobs := make([][]*verifyJob, len(txs))
+	var pending []*verifyJob
+	for i, tx := range txs {
+		jobs[i], errs[i] = peer.prepareTransactionVerification(tx)
+		pending = append(pending, jobs[i]...)
+	}
+	peer.node.verifyBatch(pending)
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
-		if err != nil {
-			peer.node.error("TransactionPreExecution: failed marshaling tx [%s] [%s].", err.Error())
-			return tx, err
+	for i := range jobs {
+		if errs[i] == nil {
+			errs[i] = transactionVerificationError(jobs[i])
 		}
-		tx.Signature = signature
+	}
 
-		// 2. Verify signature
-		ok, err := peer.node.verify(cert.PublicKey, rawTx, tx.Signature)
+	return txs, errs
+}
+
+// prepareTransactionVerification checks the certificates of the transaction
+// and returns the verification of its signature followed by the
+// verifications of its co-signatures
+func (peer *peerImpl) prepareTransactionVerification(tx *obc.Transaction) ([]*verifyJob, error) {
+	//	peer.node.debug("Pre validating [%s].", tx.String())
+	peer.node.debug("Tx confdential level [%s].", tx.ConfidentialityLevel.String())
+
+	if tx.Cert == nil {
+		return nil, utils.ErrTransactionCertificate
+	}
+	if tx.Signature == nil {
+		return nil, utils.ErrTransactionSignature
+	}
+
+	// 1. Unmarshal cert and verify it against the CA chains and the CRLs
+	cert, err := peer.node.getCert(tx.Cert)
+	if err == utils.ErrCertificateRevoked {
+		peer.node.error("TransactionPreValidation: transaction certificate has been revoked.")
+		return nil, err
+	}
+	if err != nil {
+		peer.node.error("TransactionPreValidation: failed validating cert [%s].", err.Error())
+		return nil, err
+	}
+
+	// 2. Marshall tx without signatures
+	rawTx, err := GetTransactionSigningBytes(tx)
+	if err != nil {
+		peer.node.error("TransactionPreValidation: failed marshaling tx [%s].", err.Error())
+		return nil, err
+	}
+
+	// 3. Verify signatures, in the background
+	jobs := []*verifyJob{{key: cert.PublicKey, msg: rawTx, signature: tx.Signature}}
+	for _, coSignature := range tx.CoSignatures {
+		if coSignature == nil || coSignature.Cert == nil || coSignature.Signature == nil {
+			return nil, utils.ErrInvalidTransactionCoSignature
+		}
+		coSigner, err := peer.node.getCert(coSignature.Cert)
 		if err != nil {
-			peer.node.error("TransactionPreExecution: failed marshaling tx [%s] [%s].", err.Error())
-			return tx, err
+			peer.node.error("TransactionPreValidation: failed validating co-signer cert [%s].", err.Error())
+			return nil, err
 		}
+		jobs = append(jobs, &verifyJob{key: coSigner.PublicKey, msg: rawTx, signature: coSignature.Signature})
+	}
+
+	return jobs, nil
+}
 
-		if !ok {
-			return tx, utils.ErrInvalidTransactionSignature
+func transactionVerificationError(jobs []*verifyJob) error {
+	for i, job := range jobs {
+		if job.err != nil {
+			return job.err
 		}
-	} else {
-		if tx.Cert == nil {
-			return tx, utils.ErrTransactionCertificate
+		if !job.ok && i == 0 {
+			return utils.ErrInvalidTransactionSignature
 		}
-
-		if tx.Signature == nil {
-			return tx, utils.ErrTransactionSignature
+		if !job.ok {
+			return utils.ErrInvalidTransactionCoSignature
 		}
 	}
-
-	return tx, nil
+	return nil
 }
 
 // TransactionPreValidation verifies that the transaction is
@@ -123,7 +180,51 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 	return utils.ErrNotImplemented
 }
 
-func (peer *peerImpl) GetStateEncryptor(deployTx, invokeTx *obc.Transaction) (StateEncryptor, error) {
+// VerifyEnrollmentSignature checks that signature is a valid signature of
+// message under the key of ecert, an enrollment certificate of the PKI.
+func (peer *peerImpl) VerifyEnrollmentSignature(ecert, signature, message []byte) error {
+	if !peer.isInitialized {
+		return utils.ErrNotInitialized
+	}
+
+	cert, err := peer.node.getEnrollmentCert(ecert)
+	if err != nil {
+		peer.node.error("Failed validating enrollment certificate [%s].", err.Error())
+		return err
+	}
+
+	ok, err := peer.node.verify(cert.PublicKey, message, signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return utils.ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// RenewEnrollment re-enrolls this peer with new enrollment keys, keeping its enrollment id.
+func (peer *peerImpl) RenewEnrollment() error {
+	return peer.node.renewEnrollmentData()
+}
+
+// GetEnrollmentIDOf returns the enrollment id of the peer identified by vkID.
+func (peer *peerImpl) GetEnrollmentIDOf(vkID []byte) (string, error) {
+	return "", utils.ErrNotImplemented
+}
+
+// GetEnrollmentCertChain returns this peer's enrollment certificate followed
+// by the certificates of its ECA and of the CAs that issued it.
+func (peer *peerImpl) GetEnrollmentCertChain() [][]byte {
+	return peer.node.getEnrollmentCertChain()
+}
+
+func (peer *peerImpl) AddEnrollmentCertChain(chain [][]byte) error {
+	return utils.ErrNotImplemented
+}
+
+func (peer *peerImpl) GetStateEncryptor(deployTx, invokeTx *obc.Transaction, rotationTxs ...*obc.Transaction) (StateEncryptor, error) {
 	return nil, utils.ErrNotImplemented
 }
 
@@ -167,6 +268,16 @@ func (peer *peerImpl) init(prefix, id string, pwd []byte) error {
 	}
 	peer.node = node
 
+	// Cache certificates and keep track of revoked ones
+	peer.node.certs = newCertCache(peer.node.conf.getCertCacheSize())
+	peer.node.startCRLUpdate()
+
+	// Renew the enrollment certificate before it expires
+	peer.node.startEnrollmentRenewal()
+
+	// Verify signatures of transactions in the background
+	peer.node.startVerifier()
+
 	// initialized
 	peer.isInitialized = true
 
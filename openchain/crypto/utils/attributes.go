@@ -0,0 +1,102 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+)
+
+// Attributes are embedded in a TCert as a JSON map from attribute name to
+// value, every value encrypted under its own key. The keys are derived from a
+// per-TCert attributes key, which the TCert owner discloses to chaincodes
+// within its transactions.
+
+// ErrAttributeNotFound the TCert carries no attribute with the given name
+var ErrAttributeNotFound = errors.New("Attribute not found.")
+
+// AttributesKey derives the attributes key of the TCert with the given index
+// from the TCert owner KDF key
+func AttributesKey(kdfKey, tCertIndex []byte) []byte {
+	return HMAC(HMAC(kdfKey, []byte{3}), tCertIndex)
+}
+
+// AttributeKey derives the key encrypting the attribute name
+func AttributeKey(attributesKey []byte, name string) []byte {
+	return HMACTruncated(attributesKey, []byte(name), AESKeyLength)
+}
+
+// EncryptAttributes encrypts the attributes under the attributes key and
+// returns the value of the TCertEncAttributes extension
+func EncryptAttributes(attributesKey []byte, attributes map[string]string) ([]byte, error) {
+	encrypted := make(map[string][]byte)
+	for name, value := range attributes {
+		ct, err := CBCPKCS7Encrypt(AttributeKey(attributesKey, name), []byte(name+"="+value))
+		if err != nil {
+			return nil, err
+		}
+		encrypted[name] = ct
+	}
+
+	return json.Marshal(encrypted)
+}
+
+// HasAttributes returns true if the certificate carries encrypted attributes
+func HasAttributes(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if IntArrayEquals(ext.Id, TCertEncAttributes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DecryptAttribute returns the value of attribute name embedded in cert
+func DecryptAttribute(cert *x509.Certificate, attributesKey []byte, name string) (string, error) {
+	raw, err := GetCriticalExtension(cert, TCertEncAttributes)
+	if err != nil {
+		return "", ErrAttributeNotFound
+	}
+
+	encrypted := make(map[string][]byte)
+	if err := json.Unmarshal(raw, &encrypted); err != nil {
+		return "", err
+	}
+	ct, ok := encrypted[name]
+	if !ok {
+		return "", ErrAttributeNotFound
+	}
+
+	pt, err := CBCPKCS7Decrypt(AttributeKey(attributesKey, name), Clone(ct))
+	if err != nil {
+		return "", err
+	}
+
+	// The plaintext starts with the attribute name, which detects wrong keys
+	prefix := []byte(name + "=")
+	if !bytes.HasPrefix(pt, prefix) {
+		return "", errors.New("Invalid attributes key.")
+	}
+
+	return string(pt[len(prefix):]), nil
+}
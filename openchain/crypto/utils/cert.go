@@ -20,6 +20,7 @@ under the License.
 package utils
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
@@ -36,6 +37,9 @@ import (
 var (
 	// TCertEncTCertIndex oid for TCertIndex
 	TCertEncTCertIndex = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7}
+
+	// TCertEncAttributes oid for the encrypted attributes
+	TCertEncAttributes = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 8}
 )
 
 // DERToX509Certificate converts der to x509
@@ -207,8 +211,15 @@ func CheckCertPKAgainstSK(x509Cert *x509.Certificate, privateKey interface{}) er
 			return errors.New("Private key does not match public key")
 		}
 	case *ecdsa.PublicKey:
-		priv, ok := privateKey.(*ecdsa.PrivateKey)
-		if !ok {
+		var priv *ecdsa.PublicKey
+		switch sk := privateKey.(type) {
+		case *ecdsa.PrivateKey:
+			priv = &sk.PublicKey
+		case crypto.Signer:
+			// Keys held by an HSM expose their public part only
+			priv, _ = sk.Public().(*ecdsa.PublicKey)
+		}
+		if priv == nil {
 			return errors.New("Private key type does not match public key type")
 
 		}
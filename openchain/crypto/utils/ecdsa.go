@@ -20,6 +20,7 @@ under the License.
 package utils
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/asn1"
@@ -39,7 +40,21 @@ func NewECDSAKey() (*ecdsa.PrivateKey, error) {
 
 // ECDSASignDirect signs
 func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		// Keys held by an HSM only sign
+		raw, err := signKey.(crypto.Signer).Sign(rand.Reader, Hash(msg), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sigma := new(ECDSASignature)
+		if _, err = asn1.Unmarshal(raw, sigma); err != nil {
+			return nil, nil, err
+		}
+
+		return sigma.R, sigma.S, nil
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
@@ -51,7 +66,11 @@ func ECDSASignDirect(signKey interface{}, msg []byte) (*big.Int, *big.Int, error
 
 // ECDSASign signs
 func ECDSASign(signKey interface{}, msg []byte) ([]byte, error) {
-	temp := signKey.(*ecdsa.PrivateKey)
+	temp, ok := signKey.(*ecdsa.PrivateKey)
+	if !ok {
+		// Keys held by an HSM only sign, returning ASN.1 encoded signatures
+		return signKey.(crypto.Signer).Sign(rand.Reader, Hash(msg), nil)
+	}
 	h := Hash(msg)
 	r, s, err := ecdsa.Sign(rand.Reader, temp, h)
 	if err != nil {
@@ -40,9 +40,15 @@ var (
 	// ErrInvalidTransactionSignature Invalid Transaction Signature
 	ErrInvalidTransactionSignature = errors.New("Invalid Transaction Signature.")
 
+	// ErrInvalidTransactionCoSignature Invalid Transaction Co-Signature
+	ErrInvalidTransactionCoSignature = errors.New("Invalid Transaction Co-Signature.")
+
 	// ErrTransactionCertificate Missing Transaction Certificate
 	ErrTransactionCertificate = errors.New("Missing Transaction Certificate.")
 
+	// ErrInvalidCertificate Certificate not issued by the TCA or ECA
+	ErrInvalidCertificate = errors.New("Certificate not issued by the TCA or ECA.")
+
 	// ErrTransactionSignature Missing Transaction Signature
 	ErrTransactionSignature = errors.New("Missing Transaction Signature.")
 
@@ -72,6 +78,9 @@ var (
 
 	// ErrInvalidConfidentialityLevel Invalid confidentiality level
 	ErrInvalidConfidentialityLevel = errors.New("Invalid confidentiality level")
+
+	// ErrCertificateRevoked Certificate has been revoked
+	ErrCertificateRevoked = errors.New("Certificate has been revoked.")
 )
 
 func ErrToString(err error) string {
@@ -20,10 +20,14 @@ under the License.
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/golang/protobuf/proto"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
 	obc "github.com/openblockchain/obc-peer/protos"
@@ -50,36 +54,54 @@ func (validator *validatorImpl) deepCloneAndDecryptTx(tx *obc.Transaction) (*obc
 	//	validator.peer.node.info("Encrypted Payload  ", utils.EncodeBase64(tx.EncryptedPayload))
 	//	validator.peer.node.info("Encrypted ChaincodeID  ", utils.EncodeBase64(tx.EncryptedChaincodeID))
 
+	if err := decryptTx(clone, key); err != nil {
+		validator.peer.node.error("Failed decrypting transaction [%s].", err.Error())
+		return nil, err
+	}
+
+	return clone, nil
+}
+
+// decryptTx decrypts in place the payload, chaincode ID, metadata and
+// attributes key of a confidential transaction with the key derived from its nonce
+func decryptTx(tx *obc.Transaction, key []byte) error {
 	// Decrypt Payload
 	payloadKey := utils.HMACTruncated(key, []byte{1}, utils.AESKeyLength)
-	payload, err := utils.CBCPKCS7Decrypt(payloadKey, utils.Clone(clone.Payload))
+	payload, err := utils.CBCPKCS7Decrypt(payloadKey, utils.Clone(tx.Payload))
 	if err != nil {
-		validator.peer.node.error("Failed decrypting payload [%s].", err.Error())
-		return nil, err
+		return fmt.Errorf("Failed decrypting payload [%s].", err)
 	}
-	clone.Payload = payload
+	tx.Payload = payload
 
 	// Decrypt ChaincodeID
 	chaincodeIDKey := utils.HMACTruncated(key, []byte{2}, utils.AESKeyLength)
-	chaincodeID, err := utils.CBCPKCS7Decrypt(chaincodeIDKey, utils.Clone(clone.ChaincodeID))
+	chaincodeID, err := utils.CBCPKCS7Decrypt(chaincodeIDKey, utils.Clone(tx.ChaincodeID))
 	if err != nil {
-		validator.peer.node.error("Failed decrypting chaincode [%s].", err.Error())
-		return nil, err
+		return fmt.Errorf("Failed decrypting chaincode [%s].", err)
 	}
-	clone.ChaincodeID = chaincodeID
+	tx.ChaincodeID = chaincodeID
 
 	// Decrypt metadata
-	if len(clone.Metadata) != 0 {
+	if len(tx.Metadata) != 0 {
 		metadataKey := utils.HMACTruncated(key, []byte{3}, utils.AESKeyLength)
-		metadata, err := utils.CBCPKCS7Decrypt(metadataKey, utils.Clone(clone.Metadata))
+		metadata, err := utils.CBCPKCS7Decrypt(metadataKey, utils.Clone(tx.Metadata))
 		if err != nil {
-			validator.peer.node.error("Failed decrypting metadata [%s].", err.Error())
-			return nil, err
+			return fmt.Errorf("Failed decrypting metadata [%s].", err)
 		}
-		clone.Metadata = metadata
+		tx.Metadata = metadata
 	}
 
-	return clone, nil
+	// Decrypt attributes key
+	if len(tx.AttributesKey) != 0 {
+		attributesKeyKey := utils.HMACTruncated(key, []byte{4}, utils.AESKeyLength)
+		attributesKey, err := utils.CBCPKCS7Decrypt(attributesKeyKey, utils.Clone(tx.AttributesKey))
+		if err != nil {
+			return fmt.Errorf("Failed decrypting attributes key [%s].", err)
+		}
+		tx.AttributesKey = attributesKey
+	}
+
+	return nil
 }
 
 func (validator *validatorImpl) deepCloneTransaction(tx *obc.Transaction) (*obc.Transaction, error) {
@@ -101,10 +123,120 @@ func (validator *validatorImpl) deepCloneTransaction(tx *obc.Transaction) (*obc.
 	return clone, nil
 }
 
+// Versioned state ciphertexts start with a header made of stateCiphertextMagic,
+// the format version and the key epoch the state was encrypted in (big endian).
+// Ciphertexts without the header predate key rotation and belong to the first epoch.
+var stateCiphertextMagic = []byte{0x5e, 0xc5}
+
+const (
+	stateCiphertextVersion    = 1
+	stateCiphertextHeaderSize = 7
+)
+
+func newStateCiphertextHeader(epoch uint32) []byte {
+	header := make([]byte, stateCiphertextHeaderSize)
+	copy(header, stateCiphertextMagic)
+	header[2] = stateCiphertextVersion
+	binary.BigEndian.PutUint32(header[3:], epoch)
+	return header
+}
+
+// decryptState decrypts a state ciphertext under the key of the epoch it was
+// encrypted in. This way states written before a key rotation remain readable
+// and are re-encrypted under the new key the next time they are written.
+func decryptState(epochKeys [][]byte, raw []byte) ([]byte, error) {
+	if len(raw) > stateCiphertextHeaderSize && bytes.Equal(raw[:len(stateCiphertextMagic)], stateCiphertextMagic) && raw[2] == stateCiphertextVersion {
+		epoch := binary.BigEndian.Uint32(raw[3:stateCiphertextHeaderSize])
+		if epoch < uint32(len(epochKeys)) {
+			out, err := openState(epochKeys[epoch], raw[:stateCiphertextHeaderSize], raw[stateCiphertextHeaderSize:])
+			if err == nil {
+				return out, nil
+			}
+		}
+		// An unversioned ciphertext can start like a header by chance
+	}
+
+	return openState(epochKeys[0], nil, raw)
+}
+
+func openState(epochKey, header, raw []byte) ([]byte, error) {
+	if len(raw) <= utils.NonceSize {
+		return nil, utils.ErrDecrypt
+	}
+
+	// raw consists of (txNonce, ct)
+	txNonce := raw[:utils.NonceSize]
+	ct := raw[utils.NonceSize:]
+
+	key := utils.HMACTruncated(epochKey, append([]byte{3}, txNonce...), utils.AESKeyLength)
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(ct) < nonceSize {
+		return nil, utils.ErrDecrypt
+	}
+
+	out, err := gcm.Open(nil, ct[:nonceSize], ct[nonceSize:], append(utils.Clone(header), txNonce...))
+	if err != nil {
+		return nil, utils.ErrDecrypt
+	}
+	return out, nil
+}
+
+// encryptStateKey encrypts a state key with a synthetic IV computed from the key
+// itself, as in SIV mode, so that the ciphertext is deterministic and authenticated.
+// The keys are derived from the first epoch key so that encrypted keys survive
+// key rotations.
+func encryptStateKey(epochKey []byte, key string) (string, error) {
+	iv := utils.HMACTruncated(utils.HMAC(epochKey, []byte{8}), []byte(key), aes.BlockSize)
+
+	c, err := aes.NewCipher(utils.HMACTruncated(epochKey, []byte{7}, utils.AESKeyLength))
+	if err != nil {
+		return "", err
+	}
+
+	ct := make([]byte, aes.BlockSize+len(key))
+	copy(ct, iv)
+	cipher.NewCTR(c, iv).XORKeyStream(ct[aes.BlockSize:], []byte(key))
+
+	return base64.RawURLEncoding.EncodeToString(ct), nil
+}
+
+func decryptStateKey(epochKey []byte, ct string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(ct)
+	if err != nil || len(raw) < aes.BlockSize {
+		return "", utils.ErrDecrypt
+	}
+
+	c, err := aes.NewCipher(utils.HMACTruncated(epochKey, []byte{7}, utils.AESKeyLength))
+	if err != nil {
+		return "", err
+	}
+
+	iv := raw[:aes.BlockSize]
+	key := make([]byte, len(raw)-aes.BlockSize)
+	cipher.NewCTR(c, iv).XORKeyStream(key, raw[aes.BlockSize:])
+
+	if !hmac.Equal(iv, utils.HMACTruncated(utils.HMAC(epochKey, []byte{8}), key, aes.BlockSize)) {
+		return "", utils.ErrDecrypt
+	}
+
+	return string(key), nil
+}
+
 type stateEncryptorImpl struct {
 	node *nodeImpl
 
-	deployTxKey   []byte
+	epochKeys     [][]byte
+	header        []byte
 	invokeTxNonce []byte
 
 	stateKey      []byte
@@ -116,13 +248,14 @@ type stateEncryptorImpl struct {
 	counter uint64
 }
 
-func (se *stateEncryptorImpl) init(node *nodeImpl, stateKey, nonceStateKey, deployTxKey, invokeTxNonce []byte) error {
+func (se *stateEncryptorImpl) init(node *nodeImpl, stateKey, nonceStateKey []byte, epochKeys [][]byte, epoch uint32, invokeTxNonce []byte) error {
 	// Initi fields
 	se.counter = 0
 	se.node = node
 	se.stateKey = stateKey
 	se.nonceStateKey = nonceStateKey
-	se.deployTxKey = deployTxKey
+	se.epochKeys = epochKeys
+	se.header = newStateCiphertextHeader(epoch)
 	se.invokeTxNonce = invokeTxNonce
 
 	// Init aes
@@ -153,61 +286,42 @@ func (se *stateEncryptorImpl) Encrypt(msg []byte) ([]byte, error) {
 
 	se.counter++
 
+	// The header and the txNonce are authenticated along with the ciphertext
+	prefix := append(utils.Clone(se.header), se.invokeTxNonce...)
+
 	// Seal will append the output to the first argument; the usage
 	// here appends the ciphertext to the nonce. The final parameter
 	// is any additional data to be authenticated.
-	out := se.gcmEnc.Seal(nonce, nonce, msg, se.invokeTxNonce)
+	out := se.gcmEnc.Seal(nonce, nonce, msg, prefix)
 
-	return append(se.invokeTxNonce, out...), nil
+	return append(prefix, out...), nil
 }
 
 func (se *stateEncryptorImpl) Decrypt(raw []byte) ([]byte, error) {
-	if len(raw) <= utils.NonceSize {
-		return nil, utils.ErrDecrypt
-	}
-
-	// raw consists of (txNonce, ct)
-	txNonce := raw[:utils.NonceSize]
-	//	se.log.Info("Decrypting with txNonce  ", utils.EncodeBase64(txNonce))
-	ct := raw[utils.NonceSize:]
-
-	nonce := make([]byte, se.nonceSize)
-	copy(nonce, ct)
-
-	key := utils.HMACTruncated(se.deployTxKey, append([]byte{3}, txNonce...), utils.AESKeyLength)
-	//	se.log.Info("Decrypting with key  ", utils.EncodeBase64(key))
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
+	return decryptState(se.epochKeys, raw)
+}
 
-	se.nonceSize = se.gcmEnc.NonceSize()
+func (se *stateEncryptorImpl) EncryptKey(key string) (string, error) {
+	return encryptStateKey(se.epochKeys[0], key)
+}
 
-	out, err := gcm.Open(nil, nonce, ct[se.nonceSize:], txNonce)
-	if err != nil {
-		return nil, utils.ErrDecrypt
-	}
-	return out, nil
+func (se *stateEncryptorImpl) DecryptKey(ct string) (string, error) {
+	return decryptStateKey(se.epochKeys[0], ct)
 }
 
 type queryStateEncryptor struct {
 	node *nodeImpl
 
-	deployTxKey []byte
+	epochKeys [][]byte
 
 	gcmEnc    cipher.AEAD
 	nonceSize int
 }
 
-func (se *queryStateEncryptor) init(node *nodeImpl, queryKey, deployTxKey []byte) error {
+func (se *queryStateEncryptor) init(node *nodeImpl, queryKey []byte, epochKeys [][]byte) error {
 	// Initi fields
 	se.node = node
-	se.deployTxKey = deployTxKey
+	se.epochKeys = epochKeys
 
 	//	se.log.Info("QUERY Encrypting with key  ", utils.EncodeBase64(queryKey))
 
@@ -244,35 +358,13 @@ func (se *queryStateEncryptor) Encrypt(msg []byte) ([]byte, error) {
 }
 
 func (se *queryStateEncryptor) Decrypt(raw []byte) ([]byte, error) {
-	if len(raw) <= utils.NonceSize {
-		return nil, utils.ErrDecrypt
-	}
-
-	// raw consists of (txNonce, ct)
-	txNonce := raw[:utils.NonceSize]
-	//	se.log.Info("Decrypting with txNonce  ", utils.EncodeBase64(txNonce))
-	ct := raw[utils.NonceSize:]
-
-	nonce := make([]byte, se.nonceSize)
-	copy(nonce, ct)
-
-	key := utils.HMACTruncated(se.deployTxKey, append([]byte{3}, txNonce...), utils.AESKeyLength)
-	//	se.log.Info("Decrypting with key  ", utils.EncodeBase64(key))
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
+	return decryptState(se.epochKeys, raw)
+}
 
-	se.nonceSize = se.gcmEnc.NonceSize()
+func (se *queryStateEncryptor) EncryptKey(key string) (string, error) {
+	return encryptStateKey(se.epochKeys[0], key)
+}
 
-	out, err := gcm.Open(nil, nonce, ct[se.nonceSize:], txNonce)
-	if err != nil {
-		return nil, utils.ErrDecrypt
-	}
-	return out, nil
+func (se *queryStateEncryptor) DecryptKey(ct string) (string, error) {
+	return decryptStateKey(se.epochKeys[0], ct)
 }
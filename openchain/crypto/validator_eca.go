@@ -37,33 +37,37 @@ func (validator *validatorImpl) getEnrollmentCert(id []byte) (*x509.Certificate,
 
 	validator.peer.node.debug("Getting enrollment certificate for [%s]", sid)
 
-	if cert := validator.enrollCerts[sid]; cert != nil {
-		validator.peer.node.debug("Enrollment certificate for [%s] already in memory.", sid)
-
-		return cert, nil
-	}
-
-	// Retrieve from the DB or from the ECA in case
-	validator.peer.node.debug("Retrieve Enrollment certificate for [%s]...", sid)
-	rawCert, err := validator.peer.node.ks.GetSignEnrollmentCert(id, validator.getEnrollmentCertByHashFromECA)
-	if err != nil {
-		validator.peer.node.error("Failed getting enrollment certificate for [%s]: [%s]", sid, err)
+	validator.enrollCertsLock.RLock()
+	rawCert := validator.enrollCerts[sid]
+	validator.enrollCertsLock.RUnlock()
 
-		return nil, err
+	if rawCert != nil {
+		validator.peer.node.debug("Enrollment certificate for [%s] already in memory.", sid)
+	} else {
+		// Retrieve from the DB or from the ECA in case
+		validator.peer.node.debug("Retrieve Enrollment certificate for [%s]...", sid)
+		var err error
+		rawCert, err = validator.peer.node.ks.GetSignEnrollmentCert(id, validator.getEnrollmentCertByHashFromECA)
+		if err != nil {
+			validator.peer.node.error("Failed getting enrollment certificate for [%s]: [%s]", sid, err)
+
+			return nil, err
+		}
+
+		validator.peer.node.debug("Enrollment certificate for [%s] = [% x]", sid, rawCert)
+
+		validator.enrollCertsLock.Lock()
+		validator.enrollCerts[sid] = rawCert
+		validator.enrollCertsLock.Unlock()
 	}
 
-	validator.peer.node.debug("Enrollment certificate for [%s] = [% x]", sid, rawCert)
-
-	cert, err := utils.DERToX509Certificate(rawCert)
-	if err != nil {
-		validator.peer.node.error("Failed parsing enrollment certificate for [%s]: [%s],[% x]", sid, rawCert, err)
-
-		return nil, err
+	// Parsed, validated and checked against the CRLs through the certificate cache
+	cert, err := validator.peer.node.getCert(rawCert)
+	if err != nil && err != utils.ErrCertificateRevoked {
+		validator.peer.node.error("Failed validating enrollment certificate for [%s]: [%s],[% x]", sid, err, rawCert)
 	}
 
-	validator.enrollCerts[sid] = cert
-
-	return cert, nil
+	return cert, err
 }
 
 func (validator *validatorImpl) getEnrollmentCertByHashFromECA(id []byte) ([]byte, []byte, error) {
@@ -89,25 +93,80 @@ func (validator *validatorImpl) getEnrollmentCertByHashFromECA(id []byte) ([]byt
 	}
 
 	// Check role
+	if err := validator.checkValidatorRole(x509Cert); err != nil {
+		return nil, nil, err
+	}
+
+	return responce.Sign, responce.Enc, nil
+}
+
+// AddEnrollmentCertChain validates the enrollment certificate of a validator,
+// first in chain, against the roots with the rest of chain as intermediate
+// CAs, and keeps it for verifying the signatures of the validator.
+func (validator *validatorImpl) AddEnrollmentCertChain(chain [][]byte) error {
+	if !validator.isInitialized {
+		return utils.ErrNotInitialized
+	}
+	if len(chain) == 0 {
+		return fmt.Errorf("Invalid certificate chain. It is empty.")
+	}
+
+	x509Cert, err := utils.DERToX509Certificate(chain[0])
+	if err != nil {
+		validator.peer.node.error("Failed parsing enrollment certificate: [%s]", err)
+
+		return err
+	}
+	if err := validator.peer.node.checkCertAgainstRoots(x509Cert, chain[1:]); err != nil {
+		validator.peer.node.error("Failed checking enrollment certificate against the roots: [%s]", err)
+
+		return err
+	}
+	if err := validator.checkValidatorRole(x509Cert); err != nil {
+		return err
+	}
+
+	// Parsed, validated and checked against the CRLs through the certificate cache
+	if len(chain) > 1 {
+		validator.peer.node.certs.invalidateRejected()
+	}
+	if _, err := validator.peer.node.getCert(chain[0]); err != nil {
+		validator.peer.node.error("Failed validating enrollment certificate: [%s]", err)
+
+		return err
+	}
+
+	sid := utils.EncodeBase64(utils.Hash(chain[0]))
+	validator.peer.node.debug("Adding enrollment certificate for [%s]", sid)
+
+	validator.enrollCertsLock.Lock()
+	validator.enrollCerts[sid] = utils.Clone(chain[0])
+	validator.enrollCertsLock.Unlock()
+
+	return nil
+}
+
+// checkValidatorRole checks that an enrollment certificate was issued to a validator
+func (validator *validatorImpl) checkValidatorRole(x509Cert *x509.Certificate) error {
 	roleRaw, err := utils.GetCriticalExtension(x509Cert, ECertSubjectRole)
 	if err != nil {
 		validator.peer.node.error("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, err
+		return err
 	}
 
 	role, err := strconv.ParseInt(string(roleRaw), 10, len(roleRaw)*8)
 	if err != nil {
 		validator.peer.node.error("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, err
+		return err
 	}
 
 	if obcca.Role(role) != obcca.Role_VALIDATOR {
-		validator.peer.node.error("Invalid ECertSubjectRole in enrollment certificate for signing. Not a validator: [%s]", err)
+		validator.peer.node.error("Invalid ECertSubjectRole in enrollment certificate for signing. Not a validator: [%d]", role)
 
-		return nil, nil, err
+		return utils.ErrInvalidCertificate
 	}
 
-	return responce.Sign, responce.Enc, nil
+	return nil
 }
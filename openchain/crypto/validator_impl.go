@@ -21,11 +21,11 @@ package crypto
 
 import (
 	"crypto/ecdsa"
-	"crypto/x509"
 	"errors"
 	"github.com/spf13/viper"
 	"reflect"
 	"strconv"
+	"sync"
 	"time"
 
 	"fmt"
@@ -44,7 +44,9 @@ type validatorImpl struct {
 
 	isInitialized bool
 
-	enrollCerts map[string]*x509.Certificate
+	// Enrollment certificates of the validators, DER encoded, by ID
+	enrollCerts     map[string][]byte
+	enrollCertsLock sync.RWMutex
 }
 
 func (validator *validatorImpl) GetName() string {
@@ -61,6 +63,29 @@ func (validator *validatorImpl) GetEnrollmentID() string {
 	return validator.peer.GetEnrollmentID()
 }
 
+// RenewEnrollment re-enrolls this validator with new enrollment keys, keeping its enrollment id.
+func (validator *validatorImpl) RenewEnrollment() error {
+	return validator.peer.RenewEnrollment()
+}
+
+// GetEnrollmentIDOf returns the enrollment id of the validator identified by vkID.
+// Revoked enrollment certificates are accepted, so that the identity of a
+// validator can be matched across a renewal of its enrollment.
+func (validator *validatorImpl) GetEnrollmentIDOf(vkID []byte) (string, error) {
+	cert, err := validator.getEnrollmentCert(vkID)
+	if err != nil && err != utils.ErrCertificateRevoked {
+		return "", err
+	}
+
+	return cert.Subject.CommonName, nil
+}
+
+// GetEnrollmentCertChain returns this validator's enrollment certificate
+// followed by the certificates of its ECA and of the CAs that issued it.
+func (validator *validatorImpl) GetEnrollmentCertChain() [][]byte {
+	return validator.peer.GetEnrollmentCertChain()
+}
+
 // TransactionPreValidation verifies that the transaction is
 // well formed with the respect to the security layer
 // prescriptions (i.e. signature verification).
@@ -72,6 +97,20 @@ func (validator *validatorImpl) TransactionPreValidation(tx *obc.Transaction) (*
 	return validator.peer.TransactionPreValidation(tx)
 }
 
+// TransactionsPreValidation verifies a batch of transactions like
+// TransactionPreValidation, verifying their signatures in parallel.
+func (validator *validatorImpl) TransactionsPreValidation(txs []*obc.Transaction) ([]*obc.Transaction, []error) {
+	if !validator.isInitialized {
+		errs := make([]error, len(txs))
+		for i := range errs {
+			errs[i] = utils.ErrNotInitialized
+		}
+		return nil, errs
+	}
+
+	return validator.peer.TransactionsPreValidation(txs)
+}
+
 // TransactionPreValidation verifies that the transaction is
 // well formed with the respect to the security layer
 // prescriptions (i.e. signature verification). If this is the case,
@@ -203,6 +242,11 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 	}
 
 	cert, err := validator.getEnrollmentCert(vkID)
+	if err == utils.ErrCertificateRevoked {
+		validator.peer.node.error("Enrollment cert for [% x] has been revoked", vkID)
+
+		return err
+	}
 	if err != nil {
 		validator.peer.node.error("Failed getting enrollment cert for [% x]: [%s]", vkID, err)
 
@@ -227,7 +271,17 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 	return nil
 }
 
-func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Transaction) (StateEncryptor, error) {
+// VerifyEnrollmentSignature checks that signature is a valid signature of
+// message under the key of ecert, an enrollment certificate of the PKI.
+func (validator *validatorImpl) VerifyEnrollmentSignature(ecert, signature, message []byte) error {
+	if !validator.isInitialized {
+		return utils.ErrNotInitialized
+	}
+
+	return validator.peer.VerifyEnrollmentSignature(ecert, signature, message)
+}
+
+func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Transaction, rotationTxs ...*obc.Transaction) (StateEncryptor, error) {
 	// Check nonce
 	if deployTx.Nonce == nil || len(deployTx.Nonce) == 0 {
 		return nil, errors.New("Invalid deploy nonce.")
@@ -247,21 +301,34 @@ func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Trans
 		return nil, utils.ErrDifferentChaincodeID
 	}
 
-	validator.peer.node.debug("Parsing transaction. Type [%s].", executeTx.Type.String())
+	// Compute the key of every epoch of the chaincode. The first epoch starts
+	// with the deploy transaction and every key rotation transaction starts a new one.
+	epochKeys := [][]byte{utils.HMAC(validator.peer.node.enrollChainKey, deployTx.Nonce)}
+	for _, rotationTx := range rotationTxs {
+		if rotationTx.Type != obc.Transaction_CHAINCODE_KEY_ROTATION {
+			return nil, errors.New("Invalid key rotation transaction type.")
+		}
+		if rotationTx.Nonce == nil || len(rotationTx.Nonce) == 0 {
+			return nil, errors.New("Invalid key rotation nonce.")
+		}
+		if !reflect.DeepEqual(deployTx.ChaincodeID, rotationTx.ChaincodeID) {
+			return nil, utils.ErrDifferentChaincodeID
+		}
+		epochKeys = append(epochKeys, utils.HMAC(validator.peer.node.enrollChainKey, rotationTx.Nonce))
+	}
+
+	validator.peer.node.debug("Parsing transaction. Type [%s]. Epoch [%d].", executeTx.Type.String(), len(rotationTxs))
 
 	if executeTx.Type == obc.Transaction_CHAINCODE_QUERY {
 		validator.peer.node.debug("Parsing Query transaction...")
 
-		// Compute deployTxKey key from the deploy transaction. This is used to decrypt the actual state
-		// of the chaincode
-		deployTxKey := utils.HMAC(validator.peer.node.enrollChainKey, deployTx.Nonce)
-
 		// Compute the key used to encrypt the result of the query
 		queryKey := utils.HMACTruncated(validator.peer.node.enrollChainKey, append([]byte{6}, executeTx.Nonce...), utils.AESKeyLength)
 
-		// Init the state encryptor
+		// Init the state encryptor. The epoch keys are used to decrypt the actual state
+		// of the chaincode
 		se := queryStateEncryptor{}
-		err := se.init(validator.peer.node, queryKey, deployTxKey)
+		err := se.init(validator.peer.node, queryKey, epochKeys)
 		if err != nil {
 			return nil, err
 		}
@@ -269,20 +336,21 @@ func (validator *validatorImpl) GetStateEncryptor(deployTx, executeTx *obc.Trans
 		return &se, nil
 	}
 
-	// Compute deployTxKey key from the deploy transaction
-	deployTxKey := utils.HMAC(validator.peer.node.enrollChainKey, deployTx.Nonce)
+	// New states are encrypted under the key of the latest epoch
+	epoch := uint32(len(rotationTxs))
+	epochKey := epochKeys[epoch]
 
 	// Mask executeTx.Nonce
-	executeTxNonce := utils.HMACTruncated(deployTxKey, utils.Hash(executeTx.Nonce), utils.NonceSize)
+	executeTxNonce := utils.HMACTruncated(epochKey, utils.Hash(executeTx.Nonce), utils.NonceSize)
 
 	// Compute stateKey to encrypt the states and nonceStateKey to generates IVs. This
 	// allows validators to reach consesus
-	stateKey := utils.HMACTruncated(deployTxKey, append([]byte{3}, executeTxNonce...), utils.AESKeyLength)
-	nonceStateKey := utils.HMAC(deployTxKey, append([]byte{4}, executeTxNonce...))
+	stateKey := utils.HMACTruncated(epochKey, append([]byte{3}, executeTxNonce...), utils.AESKeyLength)
+	nonceStateKey := utils.HMAC(epochKey, append([]byte{4}, executeTxNonce...))
 
 	// Init the state encryptor
 	se := stateEncryptorImpl{}
-	err := se.init(validator.peer.node, stateKey, nonceStateKey, deployTxKey, executeTxNonce)
+	err := se.init(validator.peer.node, stateKey, nonceStateKey, epochKeys, epoch, executeTxNonce)
 	if err != nil {
 		return nil, err
 	}
@@ -353,7 +421,7 @@ func (validator *validatorImpl) init(name string, pwd []byte) error {
 }
 
 func (validator *validatorImpl) initCryptoEngine() error {
-	validator.enrollCerts = make(map[string]*x509.Certificate)
+	validator.enrollCerts = make(map[string][]byte)
 	return nil
 }
 
@@ -0,0 +1,183 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+// Package zkp implements Pedersen commitments and zero-knowledge range proofs
+// over elliptic curves. A commitment hides a value while allowing to add and
+// subtract committed values, range proofs show that a committed value is not
+// negative without revealing it.
+package zkp
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// Point is a point of the curve, the point at infinity being (0, 0)
+type Point struct {
+	X, Y *big.Int
+}
+
+// Params are the generators of the commitments: the base point G of the
+// curve and a point H derived from G by hashing, whose discrete logarithm
+// in base G is unknown to anybody.
+type Params struct {
+	Curve elliptic.Curve
+	H     *Point
+}
+
+var (
+	defaultParams     *Params
+	defaultParamsOnce sync.Once
+)
+
+// DefaultParams returns the parameters on P-256
+func DefaultParams() *Params {
+	defaultParamsOnce.Do(func() {
+		params, err := NewParams(elliptic.P256())
+		if err != nil {
+			panic(err)
+		}
+		defaultParams = params
+	})
+	return defaultParams
+}
+
+// NewParams derives the parameters of the commitments on a curve of the
+// form y^2 = x^3 - 3x + b
+func NewParams(curve elliptic.Curve) (*Params, error) {
+	cp := curve.Params()
+	seed := append([]byte("obc.zkp.H"), elliptic.Marshal(curve, cp.Gx, cp.Gy)...)
+	three := big.NewInt(3)
+	for i := uint32(0); i < 256; i++ {
+		counter := make([]byte, 4)
+		binary.BigEndian.PutUint32(counter, i)
+		digest := sha256.Sum256(append(seed, counter...))
+
+		// y^2 = x^3 - 3x + b
+		x := new(big.Int).Mod(new(big.Int).SetBytes(digest[:]), cp.P)
+		y2 := new(big.Int).Exp(x, three, cp.P)
+		y2.Sub(y2, new(big.Int).Mul(three, x))
+		y2.Add(y2, cp.B)
+		y2.Mod(y2, cp.P)
+		if y := new(big.Int).ModSqrt(y2, cp.P); y != nil && curve.IsOnCurve(x, y) {
+			return &Params{Curve: curve, H: &Point{X: x, Y: y}}, nil
+		}
+	}
+	return nil, errors.New("Failed deriving generator H")
+}
+
+// Infinity returns the point at infinity, the commitment to 0 with blinding 0
+func (params *Params) Infinity() *Point {
+	return &Point{X: new(big.Int), Y: new(big.Int)}
+}
+
+// IsInfinity returns true if p is the point at infinity
+func (params *Params) IsInfinity(p *Point) bool {
+	return p.X.Sign() == 0 && p.Y.Sign() == 0
+}
+
+// Add returns a + b
+func (params *Params) Add(a, b *Point) *Point {
+	x, y := params.Curve.Add(a.X, a.Y, b.X, b.Y)
+	return &Point{X: x, Y: y}
+}
+
+// Sub returns a - b
+func (params *Params) Sub(a, b *Point) *Point {
+	return params.Add(a, params.neg(b))
+}
+
+// Equal returns true if a and b are the same point
+func (params *Params) Equal(a, b *Point) bool {
+	return a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+}
+
+func (params *Params) neg(p *Point) *Point {
+	if params.IsInfinity(p) {
+		return p
+	}
+	return &Point{X: new(big.Int).Set(p.X), Y: new(big.Int).Sub(params.Curve.Params().P, p.Y)}
+}
+
+func (params *Params) mult(p *Point, k *big.Int) *Point {
+	x, y := params.Curve.ScalarMult(p.X, p.Y, params.scalar(k).Bytes())
+	return &Point{X: x, Y: y}
+}
+
+func (params *Params) baseMult(k *big.Int) *Point {
+	x, y := params.Curve.ScalarBaseMult(params.scalar(k).Bytes())
+	return &Point{X: x, Y: y}
+}
+
+// scalar reduces k modulo the order of the curve
+func (params *Params) scalar(k *big.Int) *big.Int {
+	return new(big.Int).Mod(k, params.Curve.Params().N)
+}
+
+// RandomScalar returns a random scalar to blind commitments with
+func (params *Params) RandomScalar() (*big.Int, error) {
+	for {
+		k, err := rand.Int(rand.Reader, params.Curve.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// Commit returns the commitment value*G + blinding*H
+func (params *Params) Commit(value uint64, blinding *big.Int) *Point {
+	return params.Add(params.baseMult(new(big.Int).SetUint64(value)), params.mult(params.H, blinding))
+}
+
+// Marshal encodes a point, the point at infinity as a single zero byte
+func (params *Params) Marshal(p *Point) []byte {
+	if params.IsInfinity(p) {
+		return []byte{0}
+	}
+	return elliptic.Marshal(params.Curve, p.X, p.Y)
+}
+
+// Unmarshal decodes a point encoded by Marshal, checking it is on the curve
+func (params *Params) Unmarshal(raw []byte) (*Point, error) {
+	if len(raw) == 1 && raw[0] == 0 {
+		return params.Infinity(), nil
+	}
+	x, y := elliptic.Unmarshal(params.Curve, raw)
+	if x == nil {
+		return nil, errors.New("Invalid point")
+	}
+	return &Point{X: x, Y: y}, nil
+}
+
+// unmarshalScalar decodes a scalar, checking it is lower than the order of the curve
+func (params *Params) unmarshalScalar(raw []byte) (*big.Int, error) {
+	k := new(big.Int).SetBytes(raw)
+	if k.Cmp(params.Curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("Invalid scalar")
+	}
+	return k, nil
+}
@@ -0,0 +1,204 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package zkp
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// RangeProof proves that a commitment hides a value in [0, 2^n), n being the
+// number of bits. The value is split in bits, each committed to separately,
+// and each bit commitment comes with a proof that it hides either 0 or 1.
+// The proofs of the bits are OR-proofs of knowledge of the blinding made
+// non-interactive with a single challenge hashed over all of them.
+type RangeProof struct {
+	// Commitments to the bits, their weighted sum is the commitment proven
+	Bits [][]byte `json:"bits"`
+	// Challenges of the branches proving a bit is 0, the challenges of the
+	// other branches are the challenge minus these
+	E0 [][]byte `json:"e0"`
+	// Responses of the branches proving a bit is 0
+	S0 [][]byte `json:"s0"`
+	// Responses of the branches proving a bit is 1
+	S1 [][]byte `json:"s1"`
+	// Challenge
+	E []byte `json:"e"`
+}
+
+// ProveRange proves that the commitment to value with blinding hides a value
+// lower than 2^bits. The proof is bound to context, it only verifies with the
+// same context.
+func (params *Params) ProveRange(value uint64, blinding *big.Int, bits int, context []byte) (*RangeProof, error) {
+	if bits <= 0 || bits > 64 {
+		return nil, fmt.Errorf("Invalid number of bits %d", bits)
+	}
+	if bits < 64 && value>>uint(bits) != 0 {
+		return nil, fmt.Errorf("Value does not fit in %d bits", bits)
+	}
+	n := params.Curve.Params().N
+
+	// Blindings of the bits, the last one makes their weighted sum the blinding
+	r := make([]*big.Int, bits)
+	sum := new(big.Int)
+	for i := 0; i < bits-1; i++ {
+		ri, err := params.RandomScalar()
+		if err != nil {
+			return nil, err
+		}
+		r[i] = ri
+		sum.Add(sum, new(big.Int).Lsh(ri, uint(i)))
+	}
+	last := new(big.Int).Sub(blinding, sum)
+	last.Mul(last, new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), uint(bits-1)), n))
+	r[bits-1] = last.Mod(last, n)
+
+	commitments := make([]*Point, bits)
+	a0 := make([]*Point, bits)
+	a1 := make([]*Point, bits)
+	k := make([]*big.Int, bits)
+	e0 := make([]*big.Int, bits)
+	s0 := make([]*big.Int, bits)
+	e1 := make([]*big.Int, bits)
+	s1 := make([]*big.Int, bits)
+	for i := 0; i < bits; i++ {
+		bit := (value >> uint(i)) & 1
+		commitments[i] = params.Commit(bit, r[i])
+		p0, p1 := params.bitStatements(commitments[i])
+
+		var err error
+		if k[i], err = params.RandomScalar(); err != nil {
+			return nil, err
+		}
+		// The branch of the bit is proven, the other one is simulated
+		if bit == 0 {
+			if e1[i], err = params.RandomScalar(); err != nil {
+				return nil, err
+			}
+			if s1[i], err = params.RandomScalar(); err != nil {
+				return nil, err
+			}
+			a0[i] = params.mult(params.H, k[i])
+			a1[i] = params.simulate(p1, e1[i], s1[i])
+		} else {
+			if e0[i], err = params.RandomScalar(); err != nil {
+				return nil, err
+			}
+			if s0[i], err = params.RandomScalar(); err != nil {
+				return nil, err
+			}
+			a0[i] = params.simulate(p0, e0[i], s0[i])
+			a1[i] = params.mult(params.H, k[i])
+		}
+	}
+
+	e := params.rangeChallenge(context, commitments, a0, a1)
+
+	proof := &RangeProof{E: e.Bytes()}
+	for i := 0; i < bits; i++ {
+		if (value>>uint(i))&1 == 0 {
+			e0[i] = params.scalar(new(big.Int).Sub(e, e1[i]))
+			s0[i] = params.scalar(new(big.Int).Add(k[i], new(big.Int).Mul(e0[i], r[i])))
+		} else {
+			e1[i] = params.scalar(new(big.Int).Sub(e, e0[i]))
+			s1[i] = params.scalar(new(big.Int).Add(k[i], new(big.Int).Mul(e1[i], r[i])))
+		}
+		proof.Bits = append(proof.Bits, params.Marshal(commitments[i]))
+		proof.E0 = append(proof.E0, e0[i].Bytes())
+		proof.S0 = append(proof.S0, s0[i].Bytes())
+		proof.S1 = append(proof.S1, s1[i].Bytes())
+	}
+	return proof, nil
+}
+
+// VerifyRange verifies that commitment hides a value lower than 2^bits
+func (params *Params) VerifyRange(commitment *Point, proof *RangeProof, bits int, context []byte) error {
+	if proof == nil {
+		return errors.New("Missing range proof")
+	}
+	if len(proof.Bits) != bits || len(proof.E0) != bits || len(proof.S0) != bits || len(proof.S1) != bits {
+		return fmt.Errorf("Range proof must have %d bits", bits)
+	}
+	e, err := params.unmarshalScalar(proof.E)
+	if err != nil {
+		return err
+	}
+
+	commitments := make([]*Point, bits)
+	a0 := make([]*Point, bits)
+	a1 := make([]*Point, bits)
+	sum := params.Infinity()
+	for i := 0; i < bits; i++ {
+		if commitments[i], err = params.Unmarshal(proof.Bits[i]); err != nil {
+			return err
+		}
+		e0, err := params.unmarshalScalar(proof.E0[i])
+		if err != nil {
+			return err
+		}
+		s0, err := params.unmarshalScalar(proof.S0[i])
+		if err != nil {
+			return err
+		}
+		s1, err := params.unmarshalScalar(proof.S1[i])
+		if err != nil {
+			return err
+		}
+		e1 := params.scalar(new(big.Int).Sub(e, e0))
+
+		p0, p1 := params.bitStatements(commitments[i])
+		a0[i] = params.simulate(p0, e0, s0)
+		a1[i] = params.simulate(p1, e1, s1)
+		sum = params.Add(sum, params.mult(commitments[i], new(big.Int).Lsh(big.NewInt(1), uint(i))))
+	}
+
+	if !params.Equal(sum, commitment) {
+		return errors.New("Bit commitments do not add up to the commitment")
+	}
+	if params.rangeChallenge(context, commitments, a0, a1).Cmp(e) != 0 {
+		return errors.New("Invalid range proof")
+	}
+	return nil
+}
+
+// bitStatements returns the points whose discrete logarithm in base H is the
+// blinding of the bit commitment if the bit is 0, respectively 1
+func (params *Params) bitStatements(c *Point) (*Point, *Point) {
+	return c, params.Sub(c, params.baseMult(big.NewInt(1)))
+}
+
+// simulate returns the commitment s*H - e*P of a proof of knowledge of the
+// discrete logarithm of P in base H with challenge e and response s
+func (params *Params) simulate(p *Point, e, s *big.Int) *Point {
+	return params.Sub(params.mult(params.H, s), params.mult(p, e))
+}
+
+func (params *Params) rangeChallenge(context []byte, commitments, a0, a1 []*Point) *big.Int {
+	h := sha256.New()
+	h.Write([]byte("obc.zkp.range"))
+	h.Write(context)
+	for i := range commitments {
+		h.Write(params.Marshal(commitments[i]))
+		h.Write(params.Marshal(a0[i]))
+		h.Write(params.Marshal(a1[i]))
+	}
+	return params.scalar(new(big.Int).SetBytes(h.Sum(nil)))
+}
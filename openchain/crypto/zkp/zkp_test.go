@@ -0,0 +1,102 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package zkp
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCommitmentHomomorphism(t *testing.T) {
+	params := DefaultParams()
+	if !params.Curve.IsOnCurve(params.H.X, params.H.Y) {
+		t.Fatal("Generator H must be on the curve")
+	}
+
+	r1, _ := params.RandomScalar()
+	r2, _ := params.RandomScalar()
+	c1 := params.Commit(30, r1)
+	c2 := params.Commit(12, r2)
+
+	diff := params.Sub(c1, c2)
+	if !params.Equal(diff, params.Commit(18, new(big.Int).Sub(r1, r2))) {
+		t.Fatal("Difference of commitments must commit to the difference of the values")
+	}
+	if !params.Equal(params.Add(diff, c2), c1) {
+		t.Fatal("Adding and subtracting commitments must cancel out")
+	}
+	if !params.IsInfinity(params.Sub(c1, c1)) {
+		t.Fatal("Commitment minus itself must be the point at infinity")
+	}
+
+	for _, c := range []*Point{c1, params.Infinity()} {
+		p, err := params.Unmarshal(params.Marshal(c))
+		if err != nil {
+			t.Fatalf("Failed unmarshalling point [%s]", err)
+		}
+		if !params.Equal(p, c) {
+			t.Fatal("Unmarshalled point differs")
+		}
+	}
+	if _, err := params.Unmarshal([]byte{4, 1, 2, 3}); err == nil {
+		t.Fatal("Unmarshalling an invalid point must fail")
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+	params := DefaultParams()
+	context := []byte("transfer")
+
+	for _, value := range []uint64{0, 1, 1000, 1<<16 - 1} {
+		r, _ := params.RandomScalar()
+		c := params.Commit(value, r)
+		proof, err := params.ProveRange(value, r, 16, context)
+		if err != nil {
+			t.Fatalf("Failed proving range of %d [%s]", value, err)
+		}
+		if err := params.VerifyRange(c, proof, 16, context); err != nil {
+			t.Fatalf("Failed verifying range of %d [%s]", value, err)
+		}
+		if err := params.VerifyRange(c, proof, 16, []byte("other")); err == nil {
+			t.Fatal("Range proof must not verify in another context")
+		}
+		if err := params.VerifyRange(params.Commit(value+1, r), proof, 16, context); err == nil {
+			t.Fatal("Range proof must not verify for another commitment")
+		}
+	}
+
+	if _, err := params.ProveRange(1<<16, big.NewInt(1), 16, context); err == nil {
+		t.Fatal("Proving the range of a value out of range must fail")
+	}
+
+	// A negative value, ie. a value wrapping around the order of the curve, can not be proven
+	r, _ := params.RandomScalar()
+	negative := params.Sub(params.Commit(1, r), params.Commit(2, big.NewInt(0)))
+	proof, _ := params.ProveRange(1<<16-1, r, 16, context)
+	if err := params.VerifyRange(negative, proof, 16, context); err == nil {
+		t.Fatal("Range proof must not verify for a negative value")
+	}
+
+	// Tampered proofs are rejected
+	proof, _ = params.ProveRange(5, r, 16, context)
+	proof.S0[3], proof.S1[3] = proof.S1[3], proof.S0[3]
+	if err := params.VerifyRange(params.Commit(5, r), proof, 16, context); err == nil {
+		t.Fatal("Tampered range proof must not verify")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tecbot/gorocksdb"
+	"golang.org/x/crypto/sha3"
+)
+
+// copyBatchSize is the number of keys written to the copy at a time
+const copyBatchSize = 1000
+
+// ColumnFamilyCopy describes the content of a column family copied by CopySnapshot
+type ColumnFamilyCopy struct {
+	Name string `json:"name"`
+	Keys uint64 `json:"keys"`
+	// Hash of the keys and values of the column family in key order
+	Hash []byte `json:"hash"`
+}
+
+// CopySnapshot copies the content of all the column families in a DB
+// snapshot into a new DB created at dbPath. The DB keeps accepting writes
+// while it is copied, as only the snapshot is read.
+func (openchainDB *OpenchainDB) CopySnapshot(snapshot *gorocksdb.Snapshot, dbPath string) ([]*ColumnFamilyCopy, error) {
+	missing, err := dirMissingOrEmpty(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if !missing {
+		return nil, fmt.Errorf("db dir [%s] already exists", dbPath)
+	}
+	if err = os.MkdirAll(dbPath, 0755); err != nil {
+		return nil, fmt.Errorf("Error making directory path [%s]: %s", dbPath, err)
+	}
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	opts.SetCreateIfMissing(true)
+	copyDB, err := gorocksdb.OpenDb(opts, dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer copyDB.Close()
+
+	sources := []*gorocksdb.ColumnFamilyHandle{openchainDB.BlockchainCF, openchainDB.StateCF, openchainDB.StateDeltaCF, openchainDB.IndexesCF}
+	copies := make([]*ColumnFamilyCopy, len(columnfamilies))
+	for i, cf := range columnfamilies {
+		copyCF, err := copyDB.CreateColumnFamily(opts, cf)
+		if err != nil {
+			return nil, err
+		}
+		copies[i], err = openchainDB.copyColumnFamily(snapshot, sources[i], copyDB, copyCF)
+		copyCF.Destroy()
+		if err != nil {
+			return nil, err
+		}
+		copies[i].Name = cf
+		dbLogger.Debug("Copied %d keys of column family [%s] to [%s]", copies[i].Keys, cf, dbPath)
+	}
+	return copies, nil
+}
+
+func (openchainDB *OpenchainDB) copyColumnFamily(snapshot *gorocksdb.Snapshot, source *gorocksdb.ColumnFamilyHandle,
+	copyDB *gorocksdb.DB, copyCF *gorocksdb.ColumnFamilyHandle) (*ColumnFamilyCopy, error) {
+	itr := openchainDB.getSnapshotIterator(snapshot, source)
+	defer itr.Close()
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+
+	hash := sha3.NewShake256()
+	cfCopy := &ColumnFamilyCopy{}
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		key := itr.Key().Data()
+		value := itr.Value().Data()
+		hash.Write(key)
+		hash.Write(value)
+		writeBatch.PutCF(copyCF, key, value)
+		cfCopy.Keys++
+		if writeBatch.Count() == copyBatchSize {
+			if err := copyDB.Write(opt, writeBatch); err != nil {
+				return nil, err
+			}
+			writeBatch.Clear()
+		}
+	}
+	if err := itr.Err(); err != nil {
+		return nil, err
+	}
+	if err := copyDB.Write(opt, writeBatch); err != nil {
+		return nil, err
+	}
+	cfCopy.Hash = make([]byte, 64)
+	hash.Read(cfCopy.Hash)
+	return cfCopy, nil
+}
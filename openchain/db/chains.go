@@ -0,0 +1,133 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// chainsDir is the directory of peer.fileSystemPath holding the DBs of the
+// chains other than the default chain
+const chainsDir = "chains"
+
+var chainIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+var chainDBs = struct {
+	sync.Mutex
+	dbs map[string]*OpenchainDB
+}{dbs: make(map[string]*OpenchainDB)}
+
+// CheckChainID returns an error if chainID cannot name a chain. The empty
+// chainID names the default chain.
+func CheckChainID(chainID string) error {
+	if chainID != "" && !chainIDPattern.MatchString(chainID) {
+		return fmt.Errorf("Invalid chain ID [%s], it must be at most 64 letters, digits, '_', '.' or '-' starting with a letter or digit", chainID)
+	}
+	return nil
+}
+
+// GetChainDBHandle returns a handle to the DB of a chain. The default chain,
+// named by the empty chainID, uses the DB of GetDBHandle. The other chains
+// each have a DB of their own under peer.fileSystemPath/chains, created the
+// first time it is used.
+func GetChainDBHandle(chainID string) *OpenchainDB {
+	if chainID == "" {
+		return GetDBHandle()
+	}
+	chainDBs.Lock()
+	defer chainDBs.Unlock()
+	if openchainDB, ok := chainDBs.dbs[chainID]; ok {
+		return openchainDB
+	}
+	if err := CheckChainID(chainID); err != nil {
+		panic(err.Error())
+	}
+	dbPath := getChainDBPath(chainID)
+	if err := createDBIfPathEmpty(dbPath); err != nil {
+		panic(fmt.Sprintf("Error while trying to create DB of chain [%s]: %s", chainID, err))
+	}
+	openchainDB, err := openDBAt(dbPath)
+	if err != nil {
+		panic(fmt.Sprintf("Could not open db of chain [%s] error = [%s]", chainID, err))
+	}
+	dbLogger.Info("Opened DB of chain [%s] at [%s]", chainID, dbPath)
+	chainDBs.dbs[chainID] = openchainDB
+	return openchainDB
+}
+
+// GetChainIDs returns the IDs of the chains, other than the default chain,
+// that have a DB
+func GetChainIDs() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(getFileSystemPath(), chainsDir))
+	if err != nil {
+		if exists, _ := dirExists(filepath.Join(getFileSystemPath(), chainsDir)); !exists {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var chainIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() && CheckChainID(entry.Name()) == nil {
+			chainIDs = append(chainIDs, entry.Name())
+		}
+	}
+	sort.Strings(chainIDs)
+	return chainIDs, nil
+}
+
+// ChainDBExists returns whether the DB of a chain has been created. Unlike
+// GetChainDBHandle, it does not create the DB.
+func ChainDBExists(chainID string) (bool, error) {
+	dbPath := getDBPath()
+	if chainID != "" {
+		dbPath = getChainDBPath(chainID)
+	}
+	missing, err := dirMissingOrEmpty(dbPath)
+	return !missing, err
+}
+
+// CloseChainDBs closes the DBs of the chains other than the default chain
+func CloseChainDBs() {
+	chainDBs.Lock()
+	defer chainDBs.Unlock()
+	for chainID, openchainDB := range chainDBs.dbs {
+		openchainDB.close()
+		delete(chainDBs.dbs, chainID)
+	}
+}
+
+// GetChainPath returns the directory holding the files of a chain: the
+// peer.fileSystemPath for the default chain and a subdirectory of
+// peer.fileSystemPath/chains for the others
+func GetChainPath(chainID string) string {
+	if chainID == "" {
+		return getFileSystemPath()
+	}
+	return filepath.Join(getFileSystemPath(), chainsDir, chainID)
+}
+
+func getChainDBPath(chainID string) string {
+	return filepath.Join(getFileSystemPath(), chainsDir, chainID, "db")
+}
@@ -0,0 +1,89 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+func TestChainDBs(t *testing.T) {
+	createTestDB()
+	defer deleteTestDB()
+	defer CloseChainDBs()
+
+	if exists, _ := ChainDBExists("chain1"); exists {
+		t.Fatal("The db of the chain should not exist before it is used")
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	chainDB := GetChainDBHandle("chain1")
+	if exists, err := ChainDBExists("chain1"); !exists || err != nil {
+		t.Fatalf("The db of the chain should exist once used: %v", err)
+	}
+	if err := chainDB.DB.PutCF(opt, chainDB.BlockchainCF, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Error while writing to the db of the chain: %s", err)
+	}
+	if GetChainDBHandle("chain1") != chainDB {
+		t.Fatal("Expected the same handle to the db of the chain")
+	}
+	if GetChainDBHandle("") != GetDBHandle() {
+		t.Fatal("Expected the handle to the default db for the default chain")
+	}
+
+	for _, chainID := range []string{"", "chain2"} {
+		value, err := GetChainDBHandle(chainID).GetFromBlockchainCF([]byte("key"))
+		if err != nil {
+			t.Fatalf("read error = [%s]", err)
+		}
+		if value != nil {
+			t.Fatalf("Chain [%s] should not see the value written on another chain", chainID)
+		}
+	}
+	value, err := chainDB.GetFromBlockchainCF([]byte("key"))
+	if err != nil {
+		t.Fatalf("read error = [%s]", err)
+	}
+	if string(value) != "value" {
+		t.Fatalf("Expected [value], got [%s]", value)
+	}
+
+	chainIDs, err := GetChainIDs()
+	if err != nil {
+		t.Fatalf("Error while listing the chains: %s", err)
+	}
+	if !reflect.DeepEqual(chainIDs, []string{"chain1", "chain2"}) {
+		t.Fatalf("Expected chains [chain1 chain2], got %v", chainIDs)
+	}
+}
+
+func TestCheckChainID(t *testing.T) {
+	for _, chainID := range []string{"", "chain1", "a.b-c_d"} {
+		if err := CheckChainID(chainID); err != nil {
+			t.Fatalf("Chain ID [%s] should be valid: %s", chainID, err)
+		}
+	}
+	for _, chainID := range []string{"-chain", "../db", "a/b", string(make([]byte, 65))} {
+		if err := CheckChainID(chainID); err == nil {
+			t.Fatalf("Chain ID [%s] should be invalid", chainID)
+		}
+	}
+}
@@ -54,7 +54,10 @@ var isOpen bool
 
 // CreateDB creates a rocks db database
 func CreateDB() error {
-	dbPath := getDBPath()
+	return createDB(getDBPath())
+}
+
+func createDB(dbPath string) error {
 	dbLogger.Debug("Creating DB at [%s]", dbPath)
 	missing, err := dirMissingOrEmpty(dbPath)
 	if err != nil {
@@ -109,6 +112,12 @@ func GetDBHandle() *OpenchainDB {
 	return openchainDB
 }
 
+// CheckHealth reads from the DB to check that it is available
+func (openchainDB *OpenchainDB) CheckHealth() error {
+	_, err := openchainDB.get(openchainDB.BlockchainCF, []byte("blockCount"))
+	return err
+}
+
 // GetFromBlockchainCF get value for given key from column family - blockchainCF
 func (openchainDB *OpenchainDB) GetFromBlockchainCF(key []byte) ([]byte, error) {
 	return openchainDB.get(openchainDB.BlockchainCF, key)
@@ -124,6 +133,11 @@ func (openchainDB *OpenchainDB) GetFromStateCF(key []byte) ([]byte, error) {
 	return openchainDB.get(openchainDB.StateCF, key)
 }
 
+// GetFromStateCFSnapshot get value for given key from column family in a DB snapshot - stateCF
+func (openchainDB *OpenchainDB) GetFromStateCFSnapshot(snapshot *gorocksdb.Snapshot, key []byte) ([]byte, error) {
+	return openchainDB.getFromSnapshot(snapshot, openchainDB.StateCF, key)
+}
+
 // GetFromStateDeltaCF get value for given key from column family - stateDeltaCF
 func (openchainDB *OpenchainDB) GetFromStateDeltaCF(key []byte) ([]byte, error) {
 	return openchainDB.get(openchainDB.StateDeltaCF, key)
@@ -162,7 +176,7 @@ func (openchainDB *OpenchainDB) GetSnapshot() *gorocksdb.Snapshot {
 	return openchainDB.DB.NewSnapshot()
 }
 
-func getDBPath() string {
+func getFileSystemPath() string {
 	dbPath := viper.GetString("peer.fileSystemPath")
 	if dbPath == "" {
 		panic("DB path not specified in configuration file. Please check that property 'peer.fileSystemPath' is set")
@@ -170,18 +184,25 @@ func getDBPath() string {
 	if !strings.HasSuffix(dbPath, "/") {
 		dbPath = dbPath + "/"
 	}
-	return dbPath + "db"
+	return dbPath
+}
+
+func getDBPath() string {
+	return getFileSystemPath() + "db"
 }
 
 func createDBIfDBPathEmpty() error {
-	dbPath := getDBPath()
+	return createDBIfPathEmpty(getDBPath())
+}
+
+func createDBIfPathEmpty(dbPath string) error {
 	missing, err := dirMissingOrEmpty(dbPath)
 	if err != nil {
 		return err
 	}
 	dbLogger.Debug("Is db path [%s] empty [%t]", dbPath, missing)
 	if missing {
-		err := CreateDB()
+		err := createDB(dbPath)
 		if err != nil {
 			return nil
 		}
@@ -193,7 +214,15 @@ func openDB() (*OpenchainDB, error) {
 	if isOpen {
 		return openchainDB, nil
 	}
-	dbPath := getDBPath()
+	db, err := openDBAt(getDBPath())
+	if err != nil {
+		return nil, err
+	}
+	isOpen = true
+	return db, nil
+}
+
+func openDBAt(dbPath string) (*OpenchainDB, error) {
 	opts := gorocksdb.NewDefaultOptions()
 	defer opts.Destroy()
 	opts.SetCreateIfMissing(false)
@@ -205,17 +234,20 @@ func openDB() (*OpenchainDB, error) {
 		fmt.Println("Error opening DB", err)
 		return nil, err
 	}
-	isOpen = true
 	return &OpenchainDB{db, cfHandlers[1], cfHandlers[2], cfHandlers[3], cfHandlers[4]}, nil
 }
 
 // CloseDB releases all column family handles and closes rocksdb
 func (openchainDB *OpenchainDB) CloseDB() {
+	openchainDB.close()
+	isOpen = false
+}
+
+func (openchainDB *OpenchainDB) close() {
 	openchainDB.BlockchainCF.Destroy()
 	openchainDB.StateCF.Destroy()
 	openchainDB.StateDeltaCF.Destroy()
 	openchainDB.DB.Close()
-	isOpen = false
 }
 
 // DeleteState delets ALL state keys/values from the DB. This is generally
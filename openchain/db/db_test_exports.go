@@ -58,6 +58,7 @@ func (testDB *TestDBWrapper) CreateFreshDB(t *testing.T) {
 func (testDB *TestDBWrapper) cleanup() {
 	if testDB.performCleanup {
 		GetDBHandle().CloseDB()
+		CloseChainDBs()
 		testDB.performCleanup = false
 	}
 }
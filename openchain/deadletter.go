@@ -0,0 +1,149 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	google_protobuf "google/protobuf"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// ListDeadLetters returns the transactions that failed on this peer, the
+// oldest failure first
+func (d *Devops) ListDeadLetters(ctx context.Context, empty *google_protobuf.Empty) (*pb.DeadLetterList, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	letters, err := ledger.GetDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(deadLettersByTime(letters))
+	return &pb.DeadLetterList{DeadLetters: letters}, nil
+}
+
+// GetDeadLetter returns a transaction that failed on this peer, or
+// ErrNotFound if the transaction has no dead letter
+func (d *Devops) GetDeadLetter(ctx context.Context, id *pb.DeadLetterID) (*pb.DeadLetter, error) {
+	l, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	letter, err := l.GetDeadLetter(id.Uuid)
+	if err == ledger.ErrResourceNotFound {
+		return nil, ErrNotFound
+	}
+	return letter, err
+}
+
+// ResubmitDeadLetter submits a transaction that failed again and deletes its
+// dead letter once the validators accepted it. A transaction that failed to
+// execute is part of the blockchain, so it is submitted with a new UUID,
+// which signed transactions cannot get; their client must sign them again.
+// Msg of the response is the UUID of the resubmitted transaction.
+func (d *Devops) ResubmitDeadLetter(ctx context.Context, id *pb.DeadLetterID) (*pb.Response, error) {
+	letter, err := d.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := peer.CheckIntake(); err != nil {
+		return nil, err
+	}
+	tx := letter.Transaction
+	if letter.Phase == pb.DeadLetter_EXECUTION {
+		if tx.Signature != nil {
+			return nil, fmt.Errorf("Transaction %s is signed and part of the blockchain, its client must sign it again with a new UUID", tx.Uuid)
+		}
+		tx = proto.Clone(tx).(*pb.Transaction)
+		tx.Uuid = util.GenerateUUID()
+		tx.Timestamp = util.CreateUtcTimestamp()
+	}
+	if tx.Type == pb.Transaction_CHAINCODE_EXECUTE && d.simulation != nil {
+		if err = d.simulate(tx); err != nil {
+			peer.RecordDeadLetter(tx, pb.DeadLetter_SIMULATION, err.Error(), 0)
+			return nil, err
+		}
+	}
+	devopsLogger.Info("Resubmitting transaction %s of dead letter %s", tx.Uuid, id.Uuid)
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		peer.RecordDeadLetter(tx, pb.DeadLetter_SUBMISSION, string(resp.Msg), 0)
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	if _, err = d.PurgeDeadLetters(ctx, &pb.DeadLetterPurge{Uuids: []string{id.Uuid}}); err != nil {
+		devopsLogger.Error("Failed to delete dead letter %s after resubmitting it: %s", id.Uuid, err)
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}, nil
+}
+
+// PurgeDeadLetters deletes the dead letters of the listed transactions, or
+// all of them, and returns the deleted ones
+func (d *Devops) PurgeDeadLetters(ctx context.Context, purge *pb.DeadLetterPurge) (*pb.DeadLetterList, error) {
+	if !purge.All && len(purge.Uuids) == 0 {
+		return nil, fmt.Errorf("No dead letters to purge, list their UUIDs or purge all")
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	letters, err := ledger.GetDeadLetters()
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool)
+	for _, uuid := range purge.Uuids {
+		selected[uuid] = true
+	}
+	purged := &pb.DeadLetterList{}
+	var uuids []string
+	for _, letter := range letters {
+		if uuid := letter.GetTransaction().Uuid; purge.All || selected[uuid] {
+			purged.DeadLetters = append(purged.DeadLetters, letter)
+			uuids = append(uuids, uuid)
+		}
+	}
+	if err = ledger.DeleteDeadLetters(uuids); err != nil {
+		return nil, err
+	}
+	sort.Sort(deadLettersByTime(purged.DeadLetters))
+	return purged, nil
+}
+
+type deadLettersByTime []*pb.DeadLetter
+
+func (l deadLettersByTime) Len() int      { return len(l) }
+func (l deadLettersByTime) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l deadLettersByTime) Less(i, j int) bool {
+	ti, tj := l[i].GetTimestamp(), l[j].GetTimestamp()
+	if ti.Seconds != tj.Seconds {
+		return ti.Seconds < tj.Seconds
+	}
+	return ti.Nanos < tj.Nanos
+}
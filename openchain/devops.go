@@ -25,38 +25,78 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
+	google_protobuf "google/protobuf"
+
+	"github.com/openblockchain/obc-peer/openchain/audit"
 	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/consensus"
 	"github.com/openblockchain/obc-peer/openchain/container"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/db"
 	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/oracle"
+	"github.com/openblockchain/obc-peer/openchain/tracing"
 	"github.com/openblockchain/obc-peer/openchain/util"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
 var devopsLogger = logging.MustGetLogger("devops")
 
-// NewDevopsServer creates and returns a new Devops server instance.
-func NewDevopsServer(coord peer.MessageHandlerCoordinator) *Devops {
+// NewDevopsServer creates and returns a new Devops server instance, or an
+// error if its policies are misconfigured.
+func NewDevopsServer(coord peer.MessageHandlerCoordinator) (*Devops, error) {
 	d := new(Devops)
 	d.coord = coord
-	return d
+	window := viper.GetDuration("peer.idempotency.window")
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+	d.submissions = newSubmissionTracker(window, isCommitted)
+	simulation, err := newSimulationPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating devops server: %s", err)
+	}
+	d.simulation = simulation
+	broadcast, err := newBroadcastPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating devops server: %s", err)
+	}
+	d.broadcast = broadcast
+	chainPolicy, err := newChainPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating devops server: %s", err)
+	}
+	d.chainPolicy = chainPolicy
+	return d, nil
 }
 
+// defaultIdempotencyWindow is used when peer.idempotency.window is not set
+const defaultIdempotencyWindow = 5 * time.Minute
+
 // Devops implementation of Devops services
 type Devops struct {
-	coord peer.MessageHandlerCoordinator
+	coord       peer.MessageHandlerCoordinator
+	submissions *submissionTracker
+	simulation  *simulationPolicy
+	broadcast   *broadcastPolicy
+	chainPolicy *chainPolicy
 }
 
 // Login establishes the security context with the Devops service
 func (d *Devops) Login(ctx context.Context, secret *pb.Secret) (*pb.Response, error) {
 	if err := crypto.RegisterClient(secret.EnrollId, nil, secret.EnrollId, secret.EnrollSecret); nil != err {
+		audit.Record(audit.CategoryIdentity, secret.EnrollId, "login", "", err)
 		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}, nil
 	}
+	audit.Record(audit.CategoryIdentity, secret.EnrollId, "login", "", nil)
 	return &pb.Response{Status: pb.Response_SUCCESS}, nil
 
 	// TODO: Handle timeout and expiration
@@ -112,6 +152,13 @@ func (*Devops) getChaincodeBytes(context context.Context, spec *pb.ChaincodeSpec
 
 // Deploy deploys the supplied chaincode image to the validators through a transaction
 func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if err := peer.CheckIntake(); err != nil {
+		return nil, err
+	}
+	if err := db.CheckChainID(spec.ChainID); err != nil {
+		return nil, err
+	}
+
 	// get the deployment spec
 	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
 
@@ -120,12 +167,25 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		return nil, err
 	}
 
+	// Look for non-deterministic constructs in the code before anything is
+	// signed or sent. The findings are returned with the deployment spec.
+	findings, err := chaincode.AnalyzeCodePackage(chaincodeDeploymentSpec)
+	if err != nil {
+		devopsLogger.Error(fmt.Sprintf("Error analyzing chaincode %s: %s", spec.ChaincodeID.Path, err))
+		return nil, err
+	}
+	if err = chaincode.CheckPackageImports(chaincodeDeploymentSpec); err != nil {
+		devopsLogger.Error(fmt.Sprintf("Chaincode %s refused by the import policy: %s", spec.ChaincodeID.Path, err))
+		return nil, err
+	}
+
 	// Now create the Transactions message and send to Peer.
 
 	transID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
 
 	var tx *pb.Transaction
 	var sec crypto.Client
+	var deployer []byte
 
 	if viper.GetBool("security.enabled") {
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
@@ -141,6 +201,17 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 			return nil, err
 		}
 
+		// Sign the package as its owner so validators enforcing a package
+		// signing policy accept it
+		ecert, err := sec.GetEnrollmentCertificateHandler()
+		if nil != err {
+			return nil, err
+		}
+		if err = chaincode.SignPackage(chaincodeDeploymentSpec, ecert); nil != err {
+			return nil, err
+		}
+		deployer = ecert.GetCertificate()
+
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
 			devopsLogger.Debug("Creating secure transaction %s", transID)
 		}
@@ -157,16 +228,135 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 			return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
 		}
 	}
+	if err = authorizeDeploy(transID, deployer); err != nil {
+		return nil, err
+	}
+	if err = d.checkChainPolicy(spec.ChainID, chainActionDeploy, sec); err != nil {
+		return nil, err
+	}
 
+	// Building and launching the container can take a long time, so the
+	// transaction is sent in the background. Clients follow its progress
+	// through GetDeploymentStatus or WatchDeployment.
 	if devopsLogger.IsEnabledFor(logging.DEBUG) {
 		devopsLogger.Debug("Sending deploy transaction (%s) to validator", tx.Uuid)
 	}
-	resp := d.coord.ExecuteTransaction(tx)
-	if resp.Status == pb.Response_FAILURE {
-		err = fmt.Errorf(string(resp.Msg))
+	chaincode.SetDeploymentStatus(transID, pb.DeploymentStatus_SUBMITTED, "")
+	go func() {
+		span := tracing.StartRootSpan(tx.Uuid, "devops.deploy")
+		span.Tag("chaincode", transID)
+		resp := d.coord.ExecuteTransaction(tx)
+		span.Finish()
+		if resp.Status == pb.Response_FAILURE {
+			devopsLogger.Error("Error sending deploy transaction (%s): %s", tx.Uuid, string(resp.Msg))
+			chaincode.SetDeploymentStatus(transID, pb.DeploymentStatus_FAILED, string(resp.Msg))
+		}
+	}()
+
+	// set once the transaction is created so they are not part of it
+	chaincodeDeploymentSpec.AnalysisFindings = findings
+	return chaincodeDeploymentSpec, nil
+}
+
+// GetDeploymentStatus returns the progress of a deployment submitted with Deploy.
+// The build and launch phases are only known to validators executing the
+// deploy transaction; elsewhere the deployment remains SUBMITTED. Deployments
+// whose status has not changed for an hour are UNKNOWN.
+func (d *Devops) GetDeploymentStatus(ctx context.Context, chaincodeID *pb.ChaincodeID) (*pb.DeploymentStatus, error) {
+	if chaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for deployment status")
+	}
+	return chaincode.GetDeploymentStatus(chaincodeID.Name), nil
+}
+
+// WatchDeployment streams the progress of a deployment until it is READY or
+// FAILED. It fails with NotFound if the deployment is not known to this peer,
+// and once its status expires, an hour after it last changed.
+func (d *Devops) WatchDeployment(chaincodeID *pb.ChaincodeID, stream pb.Devops_WatchDeploymentServer) error {
+	if chaincodeID.Name == "" {
+		return fmt.Errorf("name not given for deployment status")
+	}
+	statuses, cancel := chaincode.WatchDeployment(chaincodeID.Name)
+	defer cancel()
+	for {
+		select {
+		case status, ok := <-statuses:
+			if !ok {
+				return nil
+			}
+			if status.Phase == pb.DeploymentStatus_UNKNOWN {
+				return grpc.Errorf(codes.NotFound, "Deployment %s not found", chaincodeID.Name)
+			}
+			if err := stream.Send(status); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// ListChaincodes returns the chaincodes deployed on the default chain, built
+// into or connected to this peer, with their code hash, deployment progress,
+// container status and handler state
+func (d *Devops) ListChaincodes(ctx context.Context, empty *google_protobuf.Empty) (*pb.ChaincodeInfoList, error) {
+	chain := chaincode.GetChain(chaincode.DefaultChain)
+	if chain == nil {
+		return nil, fmt.Errorf("chaincode support not started")
+	}
+	chaincodes, err := chain.ListChaincodes(ctx, "")
+	if err != nil {
+		return nil, err
 	}
+	return &pb.ChaincodeInfoList{Chaincodes: chaincodes}, nil
+}
+
+// Bounds of the number of executions of a determinism check
+const (
+	defaultDeterminismRuns = 2
+	maxDeterminismRuns     = 10
+)
 
-	return chaincodeDeploymentSpec, err
+// CheckDeterminism executes an invocation several times on the state of this
+// peer, keeping none of its state changes, and reports whether the chaincode
+// behaved the same way every time. Developers use it to catch chaincode that
+// is not deterministic before deploying it to a network.
+func (d *Devops) CheckDeterminism(ctx context.Context, spec *pb.DeterminismCheckSpec) (*pb.DeterminismReport, error) {
+	invocation := spec.Invocation
+	if invocation == nil || invocation.ChaincodeSpec == nil || invocation.ChaincodeSpec.ChaincodeID == nil || invocation.ChaincodeSpec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for determinism check")
+	}
+	if !viper.GetBool("peer.validator.enabled") {
+		return nil, fmt.Errorf("determinism can only be checked on a validating peer")
+	}
+	runs := int(spec.Runs)
+	if runs == 0 {
+		runs = defaultDeterminismRuns
+	}
+	if runs < 2 || runs > maxDeterminismRuns {
+		return nil, fmt.Errorf("determinism check needs between 2 and %d runs, got %d", maxDeterminismRuns, runs)
+	}
+
+	var sec crypto.Client
+	if viper.GetBool("security.enabled") {
+		var err error
+		sec, err = crypto.InitClient(invocation.ChaincodeSpec.SecureContext, nil)
+		if nil != err {
+			return nil, err
+		}
+		defer crypto.CloseClient(sec)
+		invocation.ChaincodeSpec.SecureContext = ""
+	}
+	tx, err := d.createExecTx(invocation, util.GenerateUUID(), true, sec)
+	if err != nil {
+		return nil, err
+	}
+	if secHelper := d.coord.GetSecHelper(); nil != secHelper {
+		if tx, err = secHelper.TransactionPreValidation(tx); nil != err {
+			return nil, err
+		}
+	}
+	return chaincode.CheckDeterminism(ctx, chaincode.GetChain(chaincode.DefaultChain), tx, runs)
 }
 
 func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec, invoke bool) (*pb.Response, error) {
@@ -174,9 +364,38 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
 		return nil, fmt.Errorf("name not given for invoke/query")
 	}
+	if err := db.CheckChainID(chaincodeInvocationSpec.ChaincodeSpec.ChainID); err != nil {
+		return nil, err
+	}
+	if invoke {
+		if err := peer.CheckIntake(); err != nil {
+			return nil, err
+		}
+	} else if !peer.Ready() {
+		return nil, peer.ErrNotReady
+	}
 
 	// Now create the Transactions message and send to Peer.
-	uuid := util.GenerateUUID()
+	var uuid string
+	if invoke && chaincodeInvocationSpec.IdempotencyKey != "" {
+		uuid = idempotentUUID(chaincodeInvocationSpec)
+	} else {
+		uuid = util.GenerateUUID()
+	}
+	if invoke && d.broadcast != nil {
+		uuid = peer.BroadcastUUID(uuid)
+	}
+	if invoke && chaincodeInvocationSpec.IdempotencyKey != "" && !d.submissions.begin(chaincodeInvocationSpec.ChaincodeSpec.ChainID, uuid) {
+		devopsLogger.Debug("Transaction %s already submitted, not invoking again", uuid)
+		return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(uuid)}, nil
+	}
+	spanName := "devops.invoke"
+	if !invoke {
+		spanName = "devops.query"
+	}
+	span := tracing.StartRootSpan(uuid, spanName)
+	span.Tag("chaincode", chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name)
+	defer span.Finish()
 	var transaction *pb.Transaction
 	var err error
 	var sec crypto.Client
@@ -189,19 +408,56 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 		// remove the security context since we are no longer need it down stream
 		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
 		if nil != err {
+			d.abortSubmission(chaincodeInvocationSpec, uuid)
+			return nil, err
+		}
+	}
+	action := chainActionInvoke
+	if !invoke {
+		action = chainActionQuery
+	}
+	if err = d.checkChainPolicy(chaincodeInvocationSpec.ChaincodeSpec.ChainID, action, sec); err != nil {
+		d.abortSubmission(chaincodeInvocationSpec, uuid)
+		return nil, err
+	}
+	// Reject oracle data points that do not verify before they reach consensus
+	if invoke {
+		if err = oracle.CheckPublication(chaincodeInvocationSpec); err != nil {
+			d.abortSubmission(chaincodeInvocationSpec, uuid)
 			return nil, err
 		}
 	}
 	transaction, err = d.createExecTx(chaincodeInvocationSpec, uuid, invoke, sec)
 	if err != nil {
+		d.abortSubmission(chaincodeInvocationSpec, uuid)
+		return nil, err
+	}
+	if err = consensus.CheckPriority(transaction); err != nil {
+		d.abortSubmission(chaincodeInvocationSpec, uuid)
 		return nil, err
 	}
+	if invoke && d.simulation != nil {
+		if err = d.simulate(transaction); err != nil {
+			d.abortSubmission(chaincodeInvocationSpec, uuid)
+			peer.RecordDeadLetter(transaction, pb.DeadLetter_SIMULATION, err.Error(), 0)
+			return nil, err
+		}
+	}
 	if devopsLogger.IsEnabledFor(logging.DEBUG) {
 		devopsLogger.Debug("Sending invocation transaction (%s) to validator", transaction.Uuid)
 	}
-	resp := d.coord.ExecuteTransaction(transaction)
+	var resp *pb.Response
+	if invoke && d.broadcast != nil {
+		resp = d.broadcastTransaction(transaction)
+	} else {
+		resp = d.coord.ExecuteTransaction(transaction)
+	}
 	if resp.Status == pb.Response_FAILURE {
+		d.abortSubmission(chaincodeInvocationSpec, uuid)
 		err = fmt.Errorf(string(resp.Msg))
+		if invoke {
+			peer.RecordDeadLetter(transaction, pb.DeadLetter_SUBMISSION, err.Error(), 0)
+		}
 	} else {
 		if !invoke && nil != sec && viper.GetBool("security.privacy") {
 			if resp.Msg, err = sec.DecryptQueryResult(transaction, resp.Msg); nil != err {
@@ -213,6 +469,14 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 	return resp, err
 }
 
+// abortSubmission releases the idempotency key of an invocation that was not
+// submitted so that the client can retry it
+func (d *Devops) abortSubmission(spec *pb.ChaincodeInvocationSpec, uuid string) {
+	if spec.IdempotencyKey != "" {
+		d.submissions.abort(uuid)
+	}
+}
+
 func (d *Devops) createExecTx(spec *pb.ChaincodeInvocationSpec, uuid string, invokeTx bool, sec crypto.Client) (*pb.Transaction, error) {
 	var tx *pb.Transaction
 	var err error
@@ -22,6 +22,7 @@ package openchain
 import (
 	"testing"
 
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
 	pb "github.com/openblockchain/obc-peer/protos"
@@ -30,7 +31,7 @@ import (
 func TestDevops_Build_NilSpec(t *testing.T) {
 	t.Skip("Skipping until we have the Validator system setup properly for testing.")
 	// TODO Cannot pass in nil to NewDevopsServer
-	devopsServer := NewDevopsServer(nil)
+	devopsServer, _ := NewDevopsServer(nil)
 
 	_, err := devopsServer.Build(context.Background(), nil)
 	if err == nil {
@@ -44,7 +45,7 @@ func TestDevops_Build_NilSpec(t *testing.T) {
 func TestDevops_Build(t *testing.T) {
 	t.Skip("Skipping until we have the Validator system setup properly for testing.")
 	// TODO Cannot pass in nil to NewDevopsServer
-	devopsServer := NewDevopsServer(nil)
+	devopsServer, _ := NewDevopsServer(nil)
 
 	// Build the spec
 	chaincodePath := "github.com/openblockchain/obc-peer/openchain/example/chaincode/chaincode_example01"
@@ -62,7 +63,7 @@ func TestDevops_Build(t *testing.T) {
 func TestDevops_Deploy(t *testing.T) {
 	t.Skip("Skipping until we have the Validator system setup properly for testing.")
 	// TODO Cannot pass in nil to NewDevopsServer
-	devopsServer := NewDevopsServer(nil)
+	devopsServer, _ := NewDevopsServer(nil)
 
 	// Build the spec
 	chaincodePath := "github.com/openblockchain/obc-peer/openchain/example/chaincode/chaincode_example01"
@@ -80,7 +81,7 @@ func TestDevops_Deploy(t *testing.T) {
 func TestDevops_Spec_NoVersion(t *testing.T) {
 	t.Skip("Skipping until we have the Validator system setup properly for testing.")
 	// TODO Cannot pass in nil to NewDevopsServer
-	devopsServer := NewDevopsServer(nil)
+	devopsServer, _ := NewDevopsServer(nil)
 
 	// Build the spec
 	chaincodePath := "github.com/openblockchain/obc-peer/openchain/example/chaincode/chaincode_example01"
@@ -95,3 +96,27 @@ func TestDevops_Spec_NoVersion(t *testing.T) {
 	t.Logf("Deploy result = %s, err = %s", buildResult, err)
 	//performHandshake(t, peerClientConn)
 }
+
+func TestDevops_SubmitTransaction_Rejected(t *testing.T) {
+	devopsServer := &Devops{}
+	tx := &pb.Transaction{Type: pb.Transaction_CHAINCODE_EXECUTE, Uuid: "tx1"}
+
+	viper.Set("security.enabled", false)
+	if _, err := devopsServer.SubmitTransaction(context.Background(), tx); err == nil {
+		t.Fatal("Expected signed transactions to be rejected without security")
+	}
+
+	viper.Set("security.enabled", true)
+	defer viper.Set("security.enabled", false)
+	for _, typ := range []pb.Transaction_Type{pb.Transaction_CHAINCODE_QUERY, pb.Transaction_CHAINCODE_KEY_ROTATION} {
+		tx.Type = typ
+		if _, err := devopsServer.SubmitTransaction(context.Background(), tx); err == nil {
+			t.Fatalf("Expected %s transactions to be rejected", typ)
+		}
+	}
+	tx.Type = pb.Transaction_CHAINCODE_EXECUTE
+	tx.Uuid = ""
+	if _, err := devopsServer.SubmitTransaction(context.Background(), tx); err == nil {
+		t.Fatal("Expected transactions without UUID to be rejected")
+	}
+}
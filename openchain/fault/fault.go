@@ -0,0 +1,140 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package fault injects faults into the peer for resilience testing:
+// consensus messages can be delayed or dropped, chaincode streams ended and
+// ledger commits stalled or failed. Faults are only injected when
+// peer.faultInjection.enabled is set, and are managed through the Admin
+// service.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var logger = logging.MustGetLogger("fault")
+
+// ErrInjected is returned by Inject for the operations a fault fails
+var ErrInjected = errors.New("fault: injected failure")
+
+var faults struct {
+	sync.Mutex
+	list []*pb.Fault
+	// active is the length of list, read without the lock so that Inject
+	// costs next to nothing while no fault is injected
+	active int32
+}
+
+// Enabled returns whether faults can be injected
+func Enabled() bool {
+	return viper.GetBool("peer.faultInjection.enabled")
+}
+
+// Add starts injecting a fault
+func Add(fault *pb.Fault) error {
+	if !Enabled() {
+		return fmt.Errorf("Fault injection is not enabled, set peer.faultInjection.enabled")
+	}
+	if _, ok := pb.Fault_Point_name[int32(fault.Point)]; !ok {
+		return fmt.Errorf("Unknown fault point %d", fault.Point)
+	}
+	faults.Lock()
+	defer faults.Unlock()
+	faults.list = append(faults.list, proto.Clone(fault).(*pb.Fault))
+	atomic.StoreInt32(&faults.active, int32(len(faults.list)))
+	logger.Warning("Injecting fault %s", fault)
+	return nil
+}
+
+// List returns the faults being injected
+func List() []*pb.Fault {
+	faults.Lock()
+	defer faults.Unlock()
+	list := make([]*pb.Fault, len(faults.list))
+	for i, fault := range faults.list {
+		list[i] = proto.Clone(fault).(*pb.Fault)
+	}
+	return list
+}
+
+// Clear stops injecting faults
+func Clear() {
+	faults.Lock()
+	defer faults.Unlock()
+	faults.list = nil
+	atomic.StoreInt32(&faults.active, 0)
+	logger.Warning("Cleared injected faults")
+}
+
+// Inject applies the first fault matching an operation at point on target:
+// it waits for the delay of the fault, then returns ErrInjected if the fault
+// fails the operation. It returns nil at once if no fault matches.
+func Inject(point pb.Fault_Point, target string) error {
+	if atomic.LoadInt32(&faults.active) == 0 {
+		return nil
+	}
+	fault := match(point, target)
+	if fault == nil {
+		return nil
+	}
+	logger.Debug("Injecting fault %s on [%s]", fault, target)
+	if fault.Delay > 0 {
+		time.Sleep(time.Duration(fault.Delay) * time.Millisecond)
+	}
+	if fault.Fail {
+		return ErrInjected
+	}
+	return nil
+}
+
+// match returns the fault applying to an operation at point on target,
+// counting the operation in the skip and count of the first matching fault
+func match(point pb.Fault_Point, target string) *pb.Fault {
+	faults.Lock()
+	defer faults.Unlock()
+	for i, fault := range faults.list {
+		if fault.Point != point || (fault.Target != "" && fault.Target != target) {
+			continue
+		}
+		if fault.Skip > 0 {
+			fault.Skip--
+			return nil
+		}
+		applied := proto.Clone(fault).(*pb.Fault)
+		if fault.Count > 0 {
+			fault.Count--
+			if fault.Count == 0 {
+				faults.list = append(faults.list[:i], faults.list[i+1:]...)
+				atomic.StoreInt32(&faults.active, int32(len(faults.list)))
+			}
+		}
+		return applied
+	}
+	return nil
+}
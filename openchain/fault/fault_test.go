@@ -0,0 +1,76 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package fault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestInject(t *testing.T) {
+	if err := Add(&pb.Fault{Point: pb.Fault_LEDGER_COMMIT, Fail: true}); err == nil {
+		t.Fatal("Expected error injecting fault while fault injection is disabled")
+	}
+	viper.Set("peer.faultInjection.enabled", true)
+	defer viper.Set("peer.faultInjection.enabled", false)
+	defer Clear()
+
+	if err := Inject(pb.Fault_LEDGER_COMMIT, ""); err != nil {
+		t.Fatalf("Unexpected error without fault: %s", err)
+	}
+	if err := Add(&pb.Fault{Point: pb.Fault_CHAINCODE_STREAM, Target: "mycc", Fail: true, Skip: 1, Count: 2}); err != nil {
+		t.Fatalf("Error injecting fault: %s", err)
+	}
+	if err := Add(&pb.Fault{Point: pb.Fault_CONSENSUS_MESSAGE, Delay: 20}); err != nil {
+		t.Fatalf("Error injecting fault: %s", err)
+	}
+
+	// The first matching operation is skipped, the next two fail
+	var results []error
+	for i := 0; i < 4; i++ {
+		if err := Inject(pb.Fault_CHAINCODE_STREAM, "othercc"); err != nil {
+			t.Fatalf("Unexpected error for another target: %s", err)
+		}
+		results = append(results, Inject(pb.Fault_CHAINCODE_STREAM, "mycc"))
+	}
+	if results[0] != nil || results[1] != ErrInjected || results[2] != ErrInjected || results[3] != nil {
+		t.Fatalf("Unexpected results %v", results)
+	}
+	if list := List(); len(list) != 1 || list[0].Point != pb.Fault_CONSENSUS_MESSAGE {
+		t.Fatalf("Expected the exhausted fault to be removed, got %v", list)
+	}
+
+	start := time.Now()
+	if err := Inject(pb.Fault_CONSENSUS_MESSAGE, "vp1"); err != nil {
+		t.Fatalf("Unexpected error for a delay: %s", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("Expected the operation to be delayed")
+	}
+
+	Clear()
+	if list := List(); len(list) != 0 {
+		t.Fatalf("Expected no fault once cleared, got %v", list)
+	}
+}
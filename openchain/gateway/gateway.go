@@ -0,0 +1,343 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package gateway exposes the gRPC services of the peer over HTTP/JSON. The
+// methods of the generated client stubs are served at /<service>/<method>,
+// e.g. POST /protos.Devops/Invoke, and call the peer's gRPC server with the
+// JSON body of the request. Streamed responses are written as one JSON
+// object per line.
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/openblockchain/obc-peer/openchain/access"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+var logger = logging.MustGetLogger("gateway")
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// kinds of methods of the client stubs
+const (
+	unary        = iota // (ctx, *In, ...CallOption) (*Out, error)
+	serverStream        // (ctx, *In, ...CallOption) (Stream, error), Stream has Recv
+	bidiStream          // (ctx, ...CallOption) (Stream, error), Stream has Send and Recv
+)
+
+// method is a method of a client stub served by the gateway
+type method struct {
+	kind int
+	fn   reflect.Value
+	// in is the type of the request message
+	in reflect.Type
+}
+
+// Gateway serves the methods of gRPC client stubs over HTTP/JSON
+type Gateway struct {
+	sync.RWMutex
+	methods map[string]*method
+	// guard authenticates and limits the clients, nil to serve anyone
+	guard *access.Guard
+}
+
+// NewGateway creates a gateway without services whose clients are
+// authenticated and limited by guard, nil to serve anyone
+func NewGateway(guard *access.Guard) *Gateway {
+	return &Gateway{methods: make(map[string]*method), guard: guard}
+}
+
+// Register serves the methods of a client stub, such as the one returned by
+// pb.NewDevopsClient, under /<service>/. Methods whose signature is not one
+// of a generated stub are ignored.
+func (g *Gateway) Register(service string, client interface{}) {
+	g.Lock()
+	defer g.Unlock()
+	value := reflect.ValueOf(client)
+	for i := 0; i < value.NumMethod(); i++ {
+		name := value.Type().Method(i).Name
+		m := newMethod(value.Method(i))
+		if m == nil {
+			logger.Debug("Not serving %s.%s, it is not a method of a gRPC stub", service, name)
+			continue
+		}
+		g.methods["/"+service+"/"+name] = m
+	}
+}
+
+// newMethod returns the method of a client stub, nil if fn is not one
+func newMethod(fn reflect.Value) *method {
+	t := fn.Type()
+	if !t.IsVariadic() || t.NumIn() < 2 || t.NumIn() > 3 || t.In(0) != contextType ||
+		t.NumOut() != 2 || t.Out(1) != errorType {
+		return nil
+	}
+	out := t.Out(0)
+	send, hasSend := out.MethodByName("Send")
+	_, hasRecv := out.MethodByName("Recv")
+	if t.NumIn() == 2 {
+		if out.Kind() != reflect.Interface || !hasSend || !hasRecv || send.Type.NumIn() != 1 {
+			return nil
+		}
+		in := send.Type.In(0)
+		if !in.Implements(messageType) || in.Kind() != reflect.Ptr {
+			return nil
+		}
+		return &method{kind: bidiStream, fn: fn, in: in.Elem()}
+	}
+	in := t.In(1)
+	if !in.Implements(messageType) || in.Kind() != reflect.Ptr {
+		return nil
+	}
+	if out.Implements(messageType) {
+		return &method{kind: unary, fn: fn, in: in.Elem()}
+	}
+	if hasRecv && !hasSend {
+		return &method{kind: serverStream, fn: fn, in: in.Elem()}
+	}
+	return nil
+}
+
+// errorEnvelope is the JSON of the errors of the gateway
+type errorEnvelope struct {
+	Error struct {
+		Status  int    `json:"status"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// httpStatus maps the code of a gRPC error to an HTTP status
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(rw http.ResponseWriter, status int, code codes.Code, message string) {
+	var envelope errorEnvelope
+	envelope.Error.Status = status
+	envelope.Error.Code = code.String()
+	envelope.Error.Message = message
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(&envelope)
+}
+
+var marshaler = &jsonpb.Marshaler{}
+
+// writeMessage writes a message followed by a new line
+func writeMessage(rw http.ResponseWriter, msg proto.Message) error {
+	if err := marshaler.Marshal(rw, msg); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(rw, "\n"); err != nil {
+		return err
+	}
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// ServeHTTP calls the method of the path with the JSON body of the request,
+// an empty body being the empty message
+func (g *Gateway) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if g.guard != nil {
+		release, ok := g.authorize(rw, req)
+		if !ok {
+			return
+		}
+		defer release()
+	}
+	g.RLock()
+	m, ok := g.methods[req.URL.Path]
+	g.RUnlock()
+	if !ok {
+		writeError(rw, http.StatusNotFound, codes.NotFound, fmt.Sprintf("No gRPC method %s", strings.TrimPrefix(req.URL.Path, "/")))
+		return
+	}
+	if req.Method != "POST" && !(req.Method == "GET" && m.kind != bidiStream) {
+		rw.Header().Set("Allow", "POST")
+		writeError(rw, http.StatusMethodNotAllowed, codes.InvalidArgument, "gRPC methods are called with POST")
+		return
+	}
+
+	in := reflect.New(m.in)
+	if err := jsonpb.Unmarshal(req.Body, in.Interface().(proto.Message)); err != nil && err != io.EOF {
+		writeError(rw, http.StatusBadRequest, codes.InvalidArgument, fmt.Sprintf("Invalid %s: %s", m.in.Name(), err))
+		return
+	}
+
+	// Cancel the call when the client goes away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Pass the credentials of the client on to the service, e.g. the event hub
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		ctx = metadata.NewContext(ctx, metadata.Pairs("authorization", authorization))
+	}
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		closed := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	if m.kind != bidiStream {
+		args = append(args, in)
+	}
+	results := m.fn.Call(args)
+	if err, _ := results[1].Interface().(error); err != nil {
+		writeError(rw, httpStatus(grpc.Code(err)), grpc.Code(err), grpc.ErrorDesc(err))
+		return
+	}
+	if m.kind == unary {
+		writeMessage(rw, results[0].Interface().(proto.Message))
+		return
+	}
+
+	stream := results[0]
+	if m.kind == bidiStream {
+		sent := stream.MethodByName("Send").Call([]reflect.Value{in})
+		if err, _ := sent[0].Interface().(error); err != nil {
+			writeError(rw, httpStatus(grpc.Code(err)), grpc.Code(err), grpc.ErrorDesc(err))
+			return
+		}
+	}
+	recv := stream.MethodByName("Recv")
+	started := false
+	for {
+		received := recv.Call(nil)
+		if err, _ := received[1].Interface().(error); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				if !started {
+					writeError(rw, httpStatus(grpc.Code(err)), grpc.Code(err), grpc.ErrorDesc(err))
+				} else {
+					logger.Error("Error receiving from %s: %s", req.URL.Path, err)
+				}
+			}
+			return
+		}
+		started = true
+		if err := writeMessage(rw, received[0].Interface().(proto.Message)); err != nil {
+			logger.Debug("Error writing to the client of %s: %s", req.URL.Path, err)
+			return
+		}
+	}
+}
+
+// authorize authenticates the client of the request, by its verified TLS
+// client certificate or by the bearer token in its Authorization header, and
+// applies the rate limit and the cap on concurrent requests of the client,
+// as the REST service does. It returns the function releasing the request of
+// the client, or writes the error and returns false.
+func (g *Gateway) authorize(rw http.ResponseWriter, req *http.Request) (func(), bool) {
+	client, err := g.guard.HTTPClient(req)
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(rw, http.StatusUnauthorized, codes.Unauthenticated, err.Error())
+		logger.Warning("Refused request from %s: %s", req.RemoteAddr, err)
+		return nil, false
+	}
+	if err = g.guard.Allow(client); err != nil {
+		writeError(rw, http.StatusTooManyRequests, codes.ResourceExhausted, err.Error())
+		return nil, false
+	}
+	release, err := g.guard.Connect(client)
+	if err != nil {
+		writeError(rw, http.StatusTooManyRequests, codes.ResourceExhausted, err.Error())
+		return nil, false
+	}
+	return release, true
+}
+
+// StartGatewayServer serves the Devops and Openchain services of the peer,
+// and the OpenchainEvents service of a validator, on gateway.address. Its
+// clients are authenticated and limited as configured for the REST service
+// under rest.auth and rest.limits. It blocks until the server fails.
+func StartGatewayServer() {
+	g := NewGateway(access.NewGuard(access.LoadConfig("rest")))
+	conn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		logger.Error("Error connecting to the peer: %s", err)
+		return
+	}
+	g.Register("protos.Devops", pb.NewDevopsClient(conn))
+	g.Register("protos.Openchain", pb.NewOpenchainClient(conn))
+	if viper.GetBool("peer.validator.enabled") {
+		eventsConn, err := peer.NewPeerClientConnectionWithAddress(viper.GetString("peer.validator.events.address"))
+		if err != nil {
+			logger.Error("Error connecting to the event hub: %s", err)
+			return
+		}
+		g.Register("protos.OpenchainEvents", pb.NewOpenchainEventsClient(eventsConn))
+	}
+
+	address := viper.GetString("gateway.address")
+	logger.Info("Serving the gRPC services over HTTP/JSON on %s", address)
+	if err := http.ListenAndServe(address, g); err != nil {
+		logger.Error("Error serving the gateway: %s", err)
+	}
+}
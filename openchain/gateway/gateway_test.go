@@ -0,0 +1,205 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/openblockchain/obc-peer/openchain/access"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+type countStream interface {
+	Recv() (*pb.BlockCount, error)
+	grpc.ClientStream
+}
+
+type chatStream interface {
+	Send(*pb.BlockNumber) error
+	Recv() (*pb.BlockNumber, error)
+	grpc.ClientStream
+}
+
+// fakeStream counts down from the number it is given
+type fakeStream struct {
+	grpc.ClientStream
+	next uint64
+}
+
+func (s *fakeStream) Send(n *pb.BlockNumber) error {
+	s.next = n.Number
+	return nil
+}
+
+func (s *fakeStream) Recv() (*pb.BlockNumber, error) {
+	if s.next == 0 {
+		return nil, io.EOF
+	}
+	s.next--
+	return &pb.BlockNumber{Number: s.next}, nil
+}
+
+type fakeCountStream struct {
+	grpc.ClientStream
+	counts []uint64
+}
+
+func (s *fakeCountStream) Recv() (*pb.BlockCount, error) {
+	if len(s.counts) == 0 {
+		return nil, io.EOF
+	}
+	count := &pb.BlockCount{Count: s.counts[0]}
+	s.counts = s.counts[1:]
+	return count, nil
+}
+
+type fakeClient struct{}
+
+func (fakeClient) GetBlockByNumber(ctx context.Context, in *pb.BlockNumber, opts ...grpc.CallOption) (*pb.Block, error) {
+	if in.Number > 1 {
+		return nil, grpc.Errorf(codes.NotFound, "block %d not found", in.Number)
+	}
+	return &pb.Block{StateHash: []byte{byte(in.Number)}}, nil
+}
+
+func (fakeClient) WatchCount(ctx context.Context, in *pb.BlockNumber, opts ...grpc.CallOption) (countStream, error) {
+	return &fakeCountStream{counts: []uint64{in.Number, in.Number + 1}}, nil
+}
+
+func (fakeClient) Chat(ctx context.Context, opts ...grpc.CallOption) (chatStream, error) {
+	return &fakeStream{}, nil
+}
+
+// NotAStub is not served
+func (fakeClient) NotAStub(n int) int {
+	return n
+}
+
+func newServer(t *testing.T, guard *access.Guard) *httptest.Server {
+	g := NewGateway(guard)
+	g.Register("test.Fake", fakeClient{})
+	if len(g.methods) != 3 {
+		t.Fatalf("Expected 3 methods, got %v", g.methods)
+	}
+	return httptest.NewServer(g)
+}
+
+func post(t *testing.T, url, body string) (*http.Response, []string) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error posting to %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return resp, lines
+}
+
+func TestGateway_Unary(t *testing.T) {
+	server := newServer(t, nil)
+	defer server.Close()
+
+	resp, lines := post(t, server.URL+"/test.Fake/GetBlockByNumber", `{"number": 1}`)
+	if resp.StatusCode != http.StatusOK || len(lines) != 1 {
+		t.Fatalf("Expected one block, got %d %v", resp.StatusCode, lines)
+	}
+	block := &pb.Block{}
+	if err := json.Unmarshal([]byte(lines[0]), block); err != nil || len(block.StateHash) != 1 || block.StateHash[0] != 1 {
+		t.Fatalf("Expected block 1, got %s (%v)", lines[0], err)
+	}
+
+	resp, lines = post(t, server.URL+"/test.Fake/GetBlockByNumber", `{"number": 7}`)
+	var envelope errorEnvelope
+	if resp.StatusCode != http.StatusNotFound || json.Unmarshal([]byte(lines[0]), &envelope) != nil || envelope.Error.Code != "NotFound" {
+		t.Fatalf("Expected a not found error, got %d %v", resp.StatusCode, lines)
+	}
+
+	resp, _ = post(t, server.URL+"/test.Fake/GetBlockByNumber", `{"number": "seven"`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected a bad request for invalid JSON, got %d", resp.StatusCode)
+	}
+
+	resp, _ = post(t, server.URL+"/test.Fake/NotAStub", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected a not found error for a method which is not a stub, got %d", resp.StatusCode)
+	}
+}
+
+func TestGateway_Streams(t *testing.T) {
+	server := newServer(t, nil)
+	defer server.Close()
+
+	resp, lines := post(t, server.URL+"/test.Fake/WatchCount", `{"number": 4}`)
+	if resp.StatusCode != http.StatusOK || len(lines) != 2 || lines[0] != `{"count":"4"}` {
+		t.Fatalf("Expected 2 counts from 4, got %d %v", resp.StatusCode, lines)
+	}
+
+	resp, lines = post(t, server.URL+"/test.Fake/Chat", `{"number": 3}`)
+	if resp.StatusCode != http.StatusOK || len(lines) != 3 || lines[2] != `{"number":"0"}` {
+		t.Fatalf("Expected blocks 2 to 0, got %d %v", resp.StatusCode, lines)
+	}
+}
+
+func TestGateway_Authorize(t *testing.T) {
+	guard := access.NewGuard(access.Config{Tokens: map[string]string{"alice": "secret"}, Rate: 0.001, Burst: 1})
+	server := newServer(t, guard)
+	defer server.Close()
+	url := server.URL + "/test.Fake/GetBlockByNumber"
+
+	resp, lines := post(t, url, `{"number": 1}`)
+	var envelope errorEnvelope
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != "Bearer" ||
+		json.Unmarshal([]byte(lines[0]), &envelope) != nil || envelope.Error.Code != "Unauthenticated" {
+		t.Fatalf("Expected a request without token to be refused, got %d %v", resp.StatusCode, lines)
+	}
+
+	call := func() *http.Response {
+		req, err := http.NewRequest("POST", url, strings.NewReader(`{"number": 1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error posting to %s: %s", url, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp = call(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected a request with a token to be served, got %d", resp.StatusCode)
+	}
+	if resp = call(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected a request beyond the rate limit to be refused, got %d", resp.StatusCode)
+	}
+}
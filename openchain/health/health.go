@@ -0,0 +1,144 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package health reports the status of the subsystems of the peer, eg. the
+// ledger DB or the Docker daemon, for orchestration systems. /healthz
+// reports whether the peer is alive, ie. its critical subsystems work, and
+// /readyz whether it is ready to serve, ie. all its subsystems work.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Statuses of the peer and its components
+const (
+	StatusOK          = "OK"
+	StatusUnavailable = "UNAVAILABLE"
+)
+
+// checkTimeout is how long a check may take before its component is
+// reported unavailable
+const checkTimeout = 5 * time.Second
+
+type checker struct {
+	critical bool
+	check    func() error
+}
+
+var registry = struct {
+	sync.RWMutex
+	checkers map[string]*checker
+}{checkers: make(map[string]*checker)}
+
+// Register registers the check of a component of the peer. The peer is not
+// alive while a critical component is unavailable, and not ready while any
+// component is. Registering a name again replaces the check.
+func Register(name string, critical bool, check func() error) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.checkers[name] = &checker{critical: critical, check: check}
+}
+
+// ComponentStatus is the status of a component of the peer
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the status of the peer and of each of its components
+type Report struct {
+	Status     string                      `json:"status"`
+	Components map[string]*ComponentStatus `json:"components"`
+}
+
+// Check runs the checks of all components concurrently. The status of the
+// report is unavailable if a critical component, or any component when
+// readiness is checked, is.
+func Check(readiness bool) *Report {
+	registry.RLock()
+	names := make([]string, 0, len(registry.checkers))
+	checkers := make(map[string]*checker, len(registry.checkers))
+	for name, c := range registry.checkers {
+		names = append(names, name)
+		checkers[name] = c
+	}
+	registry.RUnlock()
+	sort.Strings(names)
+
+	results := make([]chan error, len(names))
+	for i, name := range names {
+		results[i] = make(chan error, 1)
+		go func(check func() error, result chan error) {
+			result <- check()
+		}(checkers[name].check, results[i])
+	}
+
+	report := &Report{Status: StatusOK, Components: make(map[string]*ComponentStatus, len(names))}
+	expired := make(chan struct{})
+	timer := time.AfterFunc(checkTimeout, func() { close(expired) })
+	defer timer.Stop()
+	for i, name := range names {
+		var err error
+		select {
+		case err = <-results[i]:
+		case <-expired:
+			err = fmt.Errorf("Check timed out")
+		}
+		status := &ComponentStatus{Status: StatusOK, Critical: checkers[name].critical}
+		if err != nil {
+			status.Status = StatusUnavailable
+			status.Error = err.Error()
+			if status.Critical || readiness {
+				report.Status = StatusUnavailable
+			}
+		}
+		report.Components[name] = status
+	}
+	return report
+}
+
+func serve(w http.ResponseWriter, readiness bool) {
+	report := Check(readiness)
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// ServeLiveness writes the report of the liveness of the peer, with the
+// status 503 if the peer is not alive
+func ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	serve(w, false)
+}
+
+// ServeReadiness writes the report of the readiness of the peer, with the
+// status 503 if the peer is not ready
+func ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	serve(w, true)
+}
@@ -0,0 +1,61 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	registry.checkers = make(map[string]*checker)
+	Register("ledger", true, func() error { return nil })
+	Register("docker", false, func() error { return fmt.Errorf("Cannot connect to the Docker daemon") })
+
+	rec := httptest.NewRecorder()
+	ServeLiveness(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected the peer to be alive, got %d", rec.Code)
+	}
+	report := &Report{}
+	if err := json.Unmarshal(rec.Body.Bytes(), report); err != nil {
+		t.Fatalf("Error decoding report: %s", err)
+	}
+	if report.Status != StatusOK || report.Components["ledger"].Status != StatusOK {
+		t.Fatalf("Unexpected report %s", rec.Body.String())
+	}
+	if docker := report.Components["docker"]; docker.Status != StatusUnavailable || docker.Error != "Cannot connect to the Docker daemon" || docker.Critical {
+		t.Fatalf("Unexpected docker status %+v", docker)
+	}
+
+	rec = httptest.NewRecorder()
+	ServeReadiness(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the peer not to be ready, got %d", rec.Code)
+	}
+
+	Register("ledger", true, func() error { return fmt.Errorf("DB closed") })
+	if report := Check(false); report.Status != StatusUnavailable {
+		t.Fatalf("Expected the peer not to be alive without the ledger, got %s", report.Status)
+	}
+}
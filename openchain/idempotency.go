@@ -0,0 +1,92 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// idempotentUUID derives the transaction UUID for an invocation carrying an
+// idempotency key. The key is scoped to the caller and the chaincode so that
+// unrelated clients picking the same key do not collide
+func idempotentUUID(spec *pb.ChaincodeInvocationSpec) string {
+	data := []byte(spec.ChaincodeSpec.SecureContext + "\x00" + spec.ChaincodeSpec.ChaincodeID.Name + "\x00" + spec.IdempotencyKey)
+	return util.GenerateUUIDFromData(data)
+}
+
+// isCommitted returns true if the transaction is recorded on the local ledger
+// of the chain
+func isCommitted(chainID string, uuid string) bool {
+	l, err := ledger.GetChainLedger(chainID)
+	if err != nil {
+		devopsLogger.Error("Error getting ledger to look up transaction %s: %s", uuid, err)
+		return false
+	}
+	tx, err := l.GetTransactionByUUID(uuid)
+	return err == nil && tx != nil
+}
+
+// submissionTracker remembers the UUIDs of idempotent invocations that have
+// been submitted but may not have reached the ledger yet. Entries expire
+// after window so that a transaction dropped by consensus can be resubmitted
+type submissionTracker struct {
+	sync.Mutex
+	window    time.Duration
+	inFlight  map[string]time.Time
+	committed func(chainID string, uuid string) bool
+}
+
+func newSubmissionTracker(window time.Duration, committed func(chainID string, uuid string) bool) *submissionTracker {
+	return &submissionTracker{window: window, inFlight: make(map[string]time.Time), committed: committed}
+}
+
+// begin returns true if the transaction with the supplied UUID should be
+// submitted to the chain, and false if it is already in flight or on the
+// ledger of the chain
+func (s *submissionTracker) begin(chainID string, uuid string) bool {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for u, submitted := range s.inFlight {
+		if now.Sub(submitted) > s.window {
+			delete(s.inFlight, u)
+		}
+	}
+	if _, ok := s.inFlight[uuid]; ok {
+		return false
+	}
+	if s.committed(chainID, uuid) {
+		return false
+	}
+	s.inFlight[uuid] = now
+	return true
+}
+
+// abort forgets a submission that was not accepted so that it can be retried
+func (s *submissionTracker) abort(uuid string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.inFlight, uuid)
+}
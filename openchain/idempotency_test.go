@@ -0,0 +1,75 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestSubmissionTracker_InFlight(t *testing.T) {
+	s := newSubmissionTracker(time.Minute, func(string, string) bool { return false })
+	if !s.begin("", "tx1") {
+		t.Fatalf("Expected first submission to proceed")
+	}
+	if s.begin("", "tx1") {
+		t.Fatalf("Expected duplicate submission to be suppressed")
+	}
+	s.abort("tx1")
+	if !s.begin("", "tx1") {
+		t.Fatalf("Expected aborted submission to be retried")
+	}
+}
+
+func TestSubmissionTracker_Committed(t *testing.T) {
+	s := newSubmissionTracker(time.Minute, func(chainID string, uuid string) bool { return chainID == "chain1" && uuid == "tx1" })
+	if s.begin("chain1", "tx1") {
+		t.Fatalf("Expected committed transaction not to be submitted again")
+	}
+	if !s.begin("chain2", "tx1") {
+		t.Fatalf("Expected transaction committed on another chain to proceed")
+	}
+	if !s.begin("chain1", "tx2") {
+		t.Fatalf("Expected uncommitted transaction to proceed")
+	}
+}
+
+func TestSubmissionTracker_Expiry(t *testing.T) {
+	s := newSubmissionTracker(10*time.Millisecond, func(string, string) bool { return false })
+	s.begin("", "tx1")
+	time.Sleep(20 * time.Millisecond)
+	if !s.begin("", "tx1") {
+		t.Fatalf("Expected expired submission to be retried")
+	}
+}
+
+func TestIdempotentUUID(t *testing.T) {
+	spec := func(user, key string) *pb.ChaincodeInvocationSpec {
+		return &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{SecureContext: user, ChaincodeID: &pb.ChaincodeID{Name: "mycc"}}, IdempotencyKey: key}
+	}
+	if idempotentUUID(spec("jim", "k1")) != idempotentUUID(spec("jim", "k1")) {
+		t.Fatalf("Expected the same key to yield the same UUID")
+	}
+	if idempotentUUID(spec("jim", "k1")) == idempotentUUID(spec("bob", "k1")) {
+		t.Fatalf("Expected keys of different users not to collide")
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger/archive"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	"github.com/openblockchain/obc-peer/protos"
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+// archivedBlockKeyPrefix prefixes the keys of the stubs of the archived blocks
+// in blockchainCF, which are followed by the encoded block number
+var archivedBlockKeyPrefix = []byte("archived_")
+
+// archivedHeightKey holds the number of the first block not yet archived
+var archivedHeightKey = []byte("archivedHeight")
+
+var archiveStore archive.Store
+var archiveStoreErr error
+var archiveStoreOnce sync.Once
+
+// archiveLock keeps blocks from being archived while blocks are removed
+var archiveLock sync.Mutex
+
+// getArchiveStore returns the object store configured in ledger.archive
+func getArchiveStore() (archive.Store, error) {
+	archiveStoreOnce.Do(func() {
+		archiveStore, archiveStoreErr = archive.NewStore()
+	})
+	return archiveStore, archiveStoreErr
+}
+
+// startArchiver archives in the background the blocks of the ledger older
+// than the ledger.archive.keepBlocks last ones, every ledger.archive.interval
+func (ledger *Ledger) startArchiver() {
+	interval := viper.GetDuration("ledger.archive.interval")
+	if interval <= 0 {
+		ledgerLogger.Error("Invalid ledger.archive.interval [%s], blocks will not be archived", interval)
+		return
+	}
+	keepBlocks := uint64(viper.GetInt("ledger.archive.keepBlocks"))
+	go func() {
+		for range time.Tick(interval) {
+			size := ledger.blockchain.getSize()
+			if size <= keepBlocks {
+				continue
+			}
+			if _, err := ledger.ArchiveBlocks(size - keepBlocks); err != nil {
+				ledgerLogger.Error("Error archiving blocks: %s", err)
+			}
+		}
+	}()
+}
+
+// ArchiveBlocks moves the blocks below height to the archive store, leaving
+// stubs in their place, and returns the number of blocks moved. The last
+// block of the blockchain is always kept. Archived blocks are still returned
+// by GetBlockByNumber, which fetches them back from the store.
+func (ledger *Ledger) ArchiveBlocks(height uint64) (int, error) {
+	store, err := getArchiveStore()
+	if err != nil {
+		return 0, fmt.Errorf("Error opening archive store: %s", err)
+	}
+	archiveLock.Lock()
+	defer archiveLock.Unlock()
+	if size := ledger.blockchain.getSize(); height >= size {
+		if size == 0 {
+			return 0, nil
+		}
+		height = size - 1
+	}
+	openchainDB := ledger.openchainDB()
+	archivedHeight, err := fetchArchivedHeight(openchainDB)
+	if err != nil {
+		return 0, err
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	archived := 0
+	for blockNumber := archivedHeight; blockNumber < height; blockNumber++ {
+		value, err := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+		if err != nil {
+			return archived, err
+		}
+		if value == nil {
+			// Blocks may be missing while the blockchain is being synchronized
+			break
+		}
+		blockBytes, err := blockBytesFromDB(openchainDB, blockNumber, value)
+		if err != nil {
+			return archived, err
+		}
+		stub := &protos.ArchivedBlock{Key: ledger.archivedBlockKey(blockNumber), Hash: util.ComputeCryptoHash(blockBytes)}
+		if err = store.Put(stub.Key, blockBytes); err != nil {
+			return archived, fmt.Errorf("Error archiving block number [%d]: %s", blockNumber, err)
+		}
+		stubBytes, err := proto.Marshal(stub)
+		if err != nil {
+			return archived, err
+		}
+		writeBatch := gorocksdb.NewWriteBatch()
+		writeBatch.PutCF(openchainDB.BlockchainCF, encodeArchivedBlockKey(blockNumber), stubBytes)
+		writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeBlockNumberDBKey(blockNumber))
+		writeBatch.PutCF(openchainDB.BlockchainCF, archivedHeightKey, encodeUint64(blockNumber+1))
+		err = openchainDB.DB.Write(opt, writeBatch)
+		writeBatch.Destroy()
+		if err != nil {
+			return archived, err
+		}
+		archived++
+	}
+	if archived > 0 {
+		ledgerLogger.Info("Archived [%d] blocks below block number [%d]", archived, archivedHeight+uint64(archived))
+	}
+	return archived, nil
+}
+
+// archivedBlockKey returns the name of the object holding an archived block,
+// under ledger.archive.prefix
+func (ledger *Ledger) archivedBlockKey(blockNumber uint64) string {
+	chainID := ledger.chainID
+	if chainID == "" {
+		chainID = "_default"
+	}
+	return fmt.Sprintf("%sblocks/%s/%020d", viper.GetString("ledger.archive.prefix"), chainID, blockNumber)
+}
+
+// fetchArchivedBlock fetches a block back from the archive store, or returns
+// nil if the block is not archived
+func fetchArchivedBlock(openchainDB *db.OpenchainDB, blockNumber uint64) (*protos.Block, error) {
+	stubBytes, err := openchainDB.GetFromBlockchainCF(encodeArchivedBlockKey(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if stubBytes == nil {
+		return nil, nil
+	}
+	stub := &protos.ArchivedBlock{}
+	if err = proto.Unmarshal(stubBytes, stub); err != nil {
+		return nil, err
+	}
+	store, err := getArchiveStore()
+	if err != nil {
+		return nil, fmt.Errorf("Block number [%d] is archived but the archive store cannot be opened: %s", blockNumber, err)
+	}
+	blockBytes, err := store.Get(stub.Key)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching archived block number [%d]: %s", blockNumber, err)
+	}
+	if !bytes.Equal(util.ComputeCryptoHash(blockBytes), stub.Hash) {
+		return nil, fmt.Errorf("Archived block number [%d] does not match the hash of its stub", blockNumber)
+	}
+	return protos.UnmarshallBlock(blockBytes)
+}
+
+func fetchArchivedHeight(openchainDB *db.OpenchainDB) (uint64, error) {
+	heightBytes, err := openchainDB.GetFromBlockchainCF(archivedHeightKey)
+	if err != nil || heightBytes == nil {
+		return 0, err
+	}
+	return decodeToUint64(heightBytes), nil
+}
+
+func encodeArchivedBlockKey(blockNumber uint64) []byte {
+	return append(append([]byte{}, archivedBlockKeyPrefix...), encodeUint64(blockNumber)...)
+}
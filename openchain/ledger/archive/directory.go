@@ -0,0 +1,70 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package archive
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// directoryStore keeps the objects as files of a directory, for instance
+// a mounted network file system
+type directoryStore struct {
+	path string
+}
+
+// NewDirectoryStore returns a store keeping the objects in the directory at
+// path, which is created if it does not exist
+func NewDirectoryStore(path string) (Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("Archive directory path is required")
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	return &directoryStore{path}, nil
+}
+
+func (s *directoryStore) Put(key string, data []byte) error {
+	file := s.file(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that readers never see a partial object
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+func (s *directoryStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(s.file(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *directoryStore) file(key string) string {
+	return filepath.Join(s.path, filepath.FromSlash(key))
+}
@@ -0,0 +1,61 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package archive holds the object stores blocks are archived to. Blocks
+// moved to an archive are replaced in the local DB by stubs recording where
+// they are kept, and are fetched back when they are read.
+package archive
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNotFound is returned by the stores for objects they do not hold
+var ErrNotFound = errors.New("archive: object not found")
+
+// Store is an object store
+type Store interface {
+	// Put stores data as the object named key, replacing any object of
+	// the same name
+	Put(key string, data []byte) error
+	// Get returns the object named key, or ErrNotFound
+	Get(key string) ([]byte, error)
+}
+
+// NewStore returns the store configured in ledger.archive, s3 for S3 and
+// compatible object stores or directory for a directory of the peer
+func NewStore() (Store, error) {
+	switch kind := viper.GetString("ledger.archive.store"); kind {
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  viper.GetString("ledger.archive.s3.endpoint"),
+			Region:    viper.GetString("ledger.archive.s3.region"),
+			Bucket:    viper.GetString("ledger.archive.s3.bucket"),
+			AccessKey: viper.GetString("ledger.archive.s3.accessKey"),
+			SecretKey: viper.GetString("ledger.archive.s3.secretKey"),
+		})
+	case "directory":
+		return NewDirectoryStore(viper.GetString("ledger.archive.directory.path"))
+	default:
+		return nil, fmt.Errorf("Unknown archive store [%s], expected s3 or directory", kind)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/archive"
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestArchiveBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	testutil.AssertNoError(t, err, "Error creating archive directory")
+	defer os.RemoveAll(dir)
+	archiveStoreOnce.Do(func() {})
+	archiveStore, archiveStoreErr = archive.NewDirectoryStore(dir)
+	testutil.AssertNoError(t, archiveStoreErr, "Error creating archive store")
+
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	var blocks []*protos.Block
+	for i := 0; i < 4; i++ {
+		ledger.BeginTxBatch(i)
+		transaction, _ := buildTestTx(t)
+		ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof"))
+		blocks = append(blocks, ledgerTestWrapper.GetBlockByNumber(uint64(i)))
+		if i == 1 {
+			ledger.TagCheckpoint("cp1")
+		}
+	}
+
+	archived, err := ledger.ArchiveBlocks(2)
+	testutil.AssertNoError(t, err, "Error archiving blocks")
+	testutil.AssertEquals(t, archived, 2)
+	blockBytes, _ := ledger.openchainDB().GetFromBlockchainCF(encodeBlockNumberDBKey(1))
+	testutil.AssertNil(t, blockBytes)
+	stubBytes, _ := ledger.openchainDB().GetFromBlockchainCF(encodeArchivedBlockKey(1))
+	testutil.AssertNotNil(t, stubBytes)
+	for i, block := range blocks {
+		assertSameBlock(t, ledgerTestWrapper.GetBlockByNumber(uint64(i)), block)
+	}
+
+	// Archiving again only moves the blocks not yet archived, and never the
+	// last block
+	archived, err = ledger.ArchiveBlocks(10)
+	testutil.AssertNoError(t, err, "Error archiving blocks")
+	testutil.AssertEquals(t, archived, 1)
+	assertSameBlock(t, ledgerTestWrapper.GetBlockByNumber(3), blocks[3])
+	testutil.AssertEquals(t, ledgerTestWrapper.VerifyChain(3, 0), uint64(0))
+
+	// Rolling back removes the stubs of the removed blocks
+	testutil.AssertNoError(t, ledger.RollbackToCheckpoint("cp1"), "Error rolling back")
+	stubBytes, _ = ledger.openchainDB().GetFromBlockchainCF(encodeArchivedBlockKey(2))
+	testutil.AssertNil(t, stubBytes)
+	assertSameBlock(t, ledgerTestWrapper.GetBlockByNumber(1), blocks[1])
+	archivedHeight, _ := fetchArchivedHeight(ledger.openchainDB())
+	testutil.AssertEquals(t, archivedHeight, uint64(2))
+
+	// Archived blocks that no longer match their stubs are rejected
+	testutil.AssertNoError(t, archiveStore.Put(ledger.archivedBlockKey(0), []byte("tampered")), "Error replacing archived block")
+	_, err = ledger.GetBlockByNumber(0)
+	testutil.AssertError(t, err, "Expected error fetching tampered archived block")
+}
+
+func assertSameBlock(t *testing.T, actual *protos.Block, expected *protos.Block) {
+	actualBytes, err := actual.Bytes()
+	testutil.AssertNoError(t, err, "Error marshalling block")
+	expectedBytes, err := expected.Bytes()
+	testutil.AssertNoError(t, err, "Error marshalling block")
+	testutil.AssertEquals(t, actualBytes, expectedBytes)
+}
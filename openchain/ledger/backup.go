@@ -0,0 +1,105 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+// BackupManifestFile is the name of the manifest file written in a backup directory
+const BackupManifestFile = "manifest.json"
+
+// BackupManifest describes a backup of the ledger. The DB copy of a backup
+// can be used by a peer by setting peer.fileSystemPath to the backup
+// directory.
+type BackupManifest struct {
+	Created time.Time `json:"created"`
+	// BlockHeight is the number of blocks in the backup
+	BlockHeight    uint64                 `json:"blockHeight"`
+	LastBlockHash  []byte                 `json:"lastBlockHash"`
+	StateHash      []byte                 `json:"stateHash"`
+	ColumnFamilies []*db.ColumnFamilyCopy `json:"columnFamilies"`
+}
+
+// Backup copies the blockchain and the state, as of the last committed
+// block, to a new DB in the db subdirectory of dir and writes a manifest
+// describing the copy. The block files, if the blocks are kept in files, are
+// copied to the blocks subdirectory. Blocks keep being committed while the
+// backup runs, as the copy is made from a point-in-time snapshot of the DB.
+func (ledger *Ledger) Backup(dir string) (*BackupManifest, error) {
+	openchainDB := ledger.openchainDB()
+	dbSnapshot := openchainDB.GetSnapshot()
+	defer dbSnapshot.Release()
+
+	manifest := &BackupManifest{Created: time.Now().UTC()}
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(openchainDB, dbSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	manifest.BlockHeight = blockHeight
+	if blockHeight > 0 {
+		value, err := openchainDB.GetFromBlockchainCFSnapshot(dbSnapshot, encodeBlockNumberDBKey(blockHeight-1))
+		if err != nil {
+			return nil, err
+		}
+		blockBytes, err := blockBytesFromDB(openchainDB, blockHeight-1, value)
+		if err != nil {
+			return nil, err
+		}
+		if blockBytes != nil {
+			lastBlock, err := protos.UnmarshallBlock(blockBytes)
+			if err != nil {
+				return nil, err
+			}
+			if manifest.LastBlockHash, err = lastBlock.GetHash(); err != nil {
+				return nil, err
+			}
+			manifest.StateHash = lastBlock.StateHash
+		}
+	}
+
+	ledgerLogger.Info("Backing up ledger at block height [%d] to [%s]", blockHeight, dir)
+	manifest.ColumnFamilies, err = openchainDB.CopySnapshot(dbSnapshot, filepath.Join(dir, "db"))
+	if err != nil {
+		return nil, fmt.Errorf("Error copying DB: %s", err)
+	}
+	// The block files are copied after the snapshot is taken, so that they
+	// hold all the blocks located by the copy
+	if store := getBlockFiles(openchainDB); store != nil {
+		if err = store.CopyTo(filepath.Join(dir, blockFilesDir)); err != nil {
+			return nil, fmt.Errorf("Error copying block files: %s", err)
+		}
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, BackupManifestFile), manifestBytes, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
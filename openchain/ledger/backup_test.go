@@ -0,0 +1,81 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+	"github.com/tecbot/gorocksdb"
+)
+
+func TestLedgerBackup(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	for i := 0; i < 2; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte{byte(i)})
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	}
+	lastBlock := ledgerTestWrapper.GetBlockByNumber(1)
+	lastBlockHash, _ := lastBlock.GetHash()
+
+	dir, err := ioutil.TempDir("", "ledger-backup")
+	testutil.AssertNoError(t, err, "Error creating backup dir")
+	defer os.RemoveAll(dir)
+	manifest, err := ledger.Backup(dir)
+	testutil.AssertNoError(t, err, "Error backing up ledger")
+	testutil.AssertEquals(t, manifest.BlockHeight, uint64(2))
+	testutil.AssertEquals(t, manifest.LastBlockHash, lastBlockHash)
+	testutil.AssertEquals(t, manifest.StateHash, lastBlock.StateHash)
+
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(dir, BackupManifestFile))
+	testutil.AssertNoError(t, err, "Error reading manifest")
+	written := &BackupManifest{}
+	testutil.AssertNoError(t, json.Unmarshal(manifestBytes, written), "Error parsing manifest")
+	testutil.AssertEquals(t, written.BlockHeight, manifest.BlockHeight)
+	testutil.AssertEquals(t, len(written.ColumnFamilies), 4)
+
+	// The copy holds the blocks and the state
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	copyDB, cfs, err := gorocksdb.OpenDbColumnFamilies(opts, filepath.Join(dir, "db"),
+		[]string{"default", "blockchainCF", "stateCF", "stateDeltaCF", "indexesCF"},
+		[]*gorocksdb.Options{opts, opts, opts, opts, opts})
+	testutil.AssertNoError(t, err, "Error opening DB copy")
+	defer copyDB.Close()
+	readOpts := gorocksdb.NewDefaultReadOptions()
+	defer readOpts.Destroy()
+	blockCount, err := copyDB.GetCF(readOpts, cfs[1], blockCountKey)
+	testutil.AssertNoError(t, err, "Error reading DB copy")
+	testutil.AssertEquals(t, decodeToUint64(blockCount.Data()), uint64(2))
+
+	_, err = ledger.Backup(dir)
+	testutil.AssertError(t, err, "Expected error backing up to a non empty directory")
+}
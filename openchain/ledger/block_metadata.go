@@ -0,0 +1,170 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/protos"
+	"github.com/tecbot/gorocksdb"
+)
+
+// quorumCertKeyPrefix prefixes the numbers of the blocks in blockchainCF
+// whose quorum certificate is stored. It is longer than the encoded block
+// numbers so that the keys never collide.
+var quorumCertKeyPrefix = []byte("quorumcert_")
+
+// BlockSigner signs the header digest of a block committed by this peer, to
+// be recorded in the non hash data of the block
+type BlockSigner func(headerDigest []byte) (*protos.BlockSignature, error)
+
+// SetBlockSigner sets the signer of the blocks committed from now on. Blocks
+// are not signed if it is nil.
+func (ledger *Ledger) SetBlockSigner(signer BlockSigner) {
+	ledger.blockchain.signer = signer
+}
+
+// GetBlockMetadata returns the consensus round that ordered a block, the
+// signatures of the validators that committed it and the time at which it was
+// added to the local ledger
+func (ledger *Ledger) GetBlockMetadata(blockNumber uint64) (*protos.BlockMetadata, error) {
+	block, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	signatures, err := ledger.blockSignatures(block, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &protos.BlockMetadata{
+		Signatures:                 signatures,
+		LocalLedgerCommitTimestamp: block.GetNonHashData().GetLocalLedgerCommitTimestamp()}
+	// The consensus metadata of the genesis block holds the network definition
+	if blockNumber > 0 && len(block.ConsensusMetadata) > 0 {
+		consensus := &protos.ConsensusMetadata{}
+		if err := proto.Unmarshal(block.ConsensusMetadata, consensus); err != nil {
+			ledgerLogger.Debug("Block [%d] has no consensus round metadata: %s", blockNumber, err)
+		} else {
+			metadata.Consensus = consensus
+		}
+	}
+	return metadata, nil
+}
+
+// signBlock adds the signature of this peer to a block about to be
+// committed. The block is committed unsigned if signing fails.
+func (blockchain *blockchain) signBlock(block *protos.Block, blockNumber uint64, blockHash []byte) {
+	if blockchain.signer == nil {
+		return
+	}
+	digest, err := block.GetHeaderDigest(blockNumber, blockHash)
+	if err != nil {
+		ledgerLogger.Error("Failed to sign block: %s", err)
+		return
+	}
+	signature, err := blockchain.signer(digest)
+	if err != nil {
+		ledgerLogger.Error("Failed to sign block: %s", err)
+		return
+	}
+	block.NonHashData.Signatures = append(block.NonHashData.Signatures, signature)
+}
+
+// AddBlockSignature adds the signature of the header digest of a committed
+// block by a validator to the quorum certificate of the block. Signatures of
+// the other validators are only known once the block is committed, and are
+// not covered by the block hash, so they are stored apart from the block. A
+// validator is only added once.
+func (ledger *Ledger) AddBlockSignature(blockNumber uint64, signature *protos.BlockSignature) error {
+	if blockNumber >= ledger.GetBlockchainSize() {
+		return ErrOutOfBounds
+	}
+	ledger.quorumCertLock.Lock()
+	defer ledger.quorumCertLock.Unlock()
+	cert, err := ledger.getQuorumCertificate(blockNumber)
+	if err != nil {
+		return err
+	}
+	for _, s := range cert.Signatures {
+		if s.Validator == signature.Validator {
+			return nil
+		}
+	}
+	cert.Signatures = append(cert.Signatures, signature)
+	data, err := proto.Marshal(cert)
+	if err != nil {
+		return err
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	openchainDB := ledger.openchainDB()
+	return openchainDB.DB.PutCF(opt, openchainDB.BlockchainCF, encodeQuorumCertKey(blockNumber), data)
+}
+
+// GetBlockHeader returns the header of a block along with the signatures of
+// the validators that committed it
+func (ledger *Ledger) GetBlockHeader(blockNumber uint64) (*protos.BlockHeader, error) {
+	block, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	header, err := block.GetHeader(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if header.Signatures, err = ledger.blockSignatures(block, blockNumber); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// blockSignatures returns the signature of this peer recorded in a block
+// followed by the signatures of its quorum certificate
+func (ledger *Ledger) blockSignatures(block *protos.Block, blockNumber uint64) ([]*protos.BlockSignature, error) {
+	ledger.quorumCertLock.Lock()
+	defer ledger.quorumCertLock.Unlock()
+	cert, err := ledger.getQuorumCertificate(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	signatures := append([]*protos.BlockSignature(nil), block.GetNonHashData().GetSignatures()...)
+	signed := make(map[string]bool)
+	for _, s := range signatures {
+		signed[s.Validator] = true
+	}
+	for _, s := range cert.Signatures {
+		if !signed[s.Validator] {
+			signatures = append(signatures, s)
+		}
+	}
+	return signatures, nil
+}
+
+func (ledger *Ledger) getQuorumCertificate(blockNumber uint64) (*protos.QuorumCertificate, error) {
+	cert := &protos.QuorumCertificate{}
+	data, err := ledger.openchainDB().GetFromBlockchainCF(encodeQuorumCertKey(blockNumber))
+	if err != nil || data == nil {
+		return cert, err
+	}
+	return cert, proto.Unmarshal(data, cert)
+}
+
+func encodeQuorumCertKey(blockNumber uint64) []byte {
+	return append(append([]byte{}, quorumCertKeyPrefix...), encodeBlockNumberDBKey(blockNumber)...)
+}
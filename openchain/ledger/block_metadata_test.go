@@ -0,0 +1,127 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestBlockMetadata(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// Block 0 is not signed and its metadata is not a consensus round
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("genesis"))
+
+	metadata, err := ledger.GetBlockMetadata(0)
+	testutil.AssertNoError(t, err, "Error fetching block metadata")
+	testutil.AssertNil(t, metadata.Consensus)
+	testutil.AssertEquals(t, len(metadata.Signatures), 0)
+	testutil.AssertNotNil(t, metadata.LocalLedgerCommitTimestamp)
+
+	// Block 1 is signed and records the consensus round
+	var signedDigest []byte
+	ledger.SetBlockSigner(func(headerDigest []byte) (*protos.BlockSignature, error) {
+		signedDigest = headerDigest
+		return &protos.BlockSignature{Validator: "vp0", Signature: []byte("signature")}, nil
+	})
+	round, _ := proto.Marshal(&protos.ConsensusMetadata{SequenceNumber: 7})
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "key1", []byte("value1B"))
+	ledger.TxFinished("txUuid2", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, round)
+
+	metadata, err = ledger.GetBlockMetadata(1)
+	testutil.AssertNoError(t, err, "Error fetching block metadata")
+	testutil.AssertEquals(t, metadata.Consensus.SequenceNumber, uint64(7))
+	testutil.AssertEquals(t, len(metadata.Signatures), 1)
+	testutil.AssertEquals(t, metadata.Signatures[0].Validator, "vp0")
+	block, _ := ledger.GetBlockByNumber(1)
+	header, _ := block.GetHeader(1)
+	digest, _ := header.Digest()
+	if !bytes.Equal(signedDigest, digest) {
+		t.Fatalf("Expected the signature of the header digest %x, got the signature of %x", digest, signedDigest)
+	}
+
+	// The block is committed unsigned if signing fails
+	ledger.SetBlockSigner(func(headerDigest []byte) (*protos.BlockSignature, error) {
+		return nil, fmt.Errorf("no signing key")
+	})
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid3")
+	ledger.SetState("chaincode1", "key1", []byte("value1C"))
+	ledger.TxFinished("txUuid3", true)
+	transaction, _ = buildTestTx(t)
+	testutil.AssertNoError(t, ledger.CommitTxBatch(2, []*protos.Transaction{transaction}, nil, nil), "Error committing block")
+	metadata, err = ledger.GetBlockMetadata(2)
+	testutil.AssertNoError(t, err, "Error fetching block metadata")
+	testutil.AssertEquals(t, len(metadata.Signatures), 0)
+
+	_, err = ledger.GetBlockMetadata(3)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+}
+
+func TestBlockQuorumCertificate(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.SetBlockSigner(func(headerDigest []byte) (*protos.BlockSignature, error) {
+		return &protos.BlockSignature{Validator: "vp0", Signature: []byte("signature0")}, nil
+	})
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, nil)
+
+	// The signatures of the other validators are added after the commit,
+	// each validator once, and our own signature is not duplicated
+	testutil.AssertNoError(t, ledger.AddBlockSignature(0, &protos.BlockSignature{Validator: "vp1", Signature: []byte("signature1")}), "Error adding block signature")
+	testutil.AssertNoError(t, ledger.AddBlockSignature(0, &protos.BlockSignature{Validator: "vp1", Signature: []byte("other")}), "Error adding block signature")
+	testutil.AssertNoError(t, ledger.AddBlockSignature(0, &protos.BlockSignature{Validator: "vp0", Signature: []byte("signature0")}), "Error adding block signature")
+	testutil.AssertNoError(t, ledger.AddBlockSignature(0, &protos.BlockSignature{Validator: "vp2", Signature: []byte("signature2")}), "Error adding block signature")
+
+	header, err := ledger.GetBlockHeader(0)
+	testutil.AssertNoError(t, err, "Error fetching block header")
+	testutil.AssertEquals(t, len(header.Signatures), 3)
+	testutil.AssertEquals(t, header.Signatures[0].Validator, "vp0")
+	testutil.AssertEquals(t, header.Signatures[1].Signature, []byte("signature1"))
+	testutil.AssertEquals(t, header.Signatures[2].Validator, "vp2")
+
+	metadata, err := ledger.GetBlockMetadata(0)
+	testutil.AssertNoError(t, err, "Error fetching block metadata")
+	testutil.AssertEquals(t, len(metadata.Signatures), 3)
+
+	testutil.AssertEquals(t, ledger.AddBlockSignature(1, &protos.BlockSignature{Validator: "vp1"}), ErrOutOfBounds)
+}
@@ -22,6 +22,7 @@ package ledger
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"strconv"
 
 	"github.com/openblockchain/obc-peer/openchain/db"
@@ -34,10 +35,12 @@ import (
 // Blockchain holds basic information in memory. Operations on Blockchain are not thread-safe
 // TODO synchronize access to in-memory variables
 type blockchain struct {
+	chainID            string
 	size               uint64
 	previousBlockHash  []byte
 	indexer            blockchainIndexer
 	lastProcessedBlock *lastProcessedBlock
+	signer             BlockSigner
 }
 
 type lastProcessedBlock struct {
@@ -49,14 +52,22 @@ type lastProcessedBlock struct {
 var indexBlockDataSynchronously = true
 
 func newBlockchain() (*blockchain, error) {
-	size, err := fetchBlockchainSizeFromDB()
+	return newChainBlockchain("")
+}
+
+func newChainBlockchain(chainID string) (*blockchain, error) {
+	openchainDB := db.GetChainDBHandle(chainID)
+	size, err := fetchBlockchainSizeFromDB(openchainDB)
 	if err != nil {
 		return nil, err
 	}
-	blockchain := &blockchain{0, nil, nil, nil}
+	if err = openBlockFiles(chainID, openchainDB, size); err != nil {
+		return nil, err
+	}
+	blockchain := &blockchain{chainID, 0, nil, nil, nil, nil}
 	blockchain.size = size
 	if size > 0 {
-		previousBlock, err := fetchBlockFromDB(size - 1)
+		previousBlock, err := fetchBlockFromDB(blockchain.openchainDB(), size-1)
 		if err != nil {
 			return nil, err
 		}
@@ -84,6 +95,10 @@ func (blockchain *blockchain) startIndexer() (err error) {
 	return
 }
 
+func (blockchain *blockchain) openchainDB() *db.OpenchainDB {
+	return db.GetChainDBHandle(blockchain.chainID)
+}
+
 // getLastBlock get last block in blockchain
 func (blockchain *blockchain) getLastBlock() (*protos.Block, error) {
 	if blockchain.size == 0 {
@@ -99,7 +114,7 @@ func (blockchain *blockchain) getSize() uint64 {
 
 // getBlock get block at arbitrary height in block chain
 func (blockchain *blockchain) getBlock(blockNumber uint64) (*protos.Block, error) {
-	return fetchBlockFromDB(blockNumber)
+	return fetchBlockFromDB(blockchain.openchainDB(), blockNumber)
 }
 
 // getBlockByHash get block by block hash
@@ -124,6 +139,35 @@ func (blockchain *blockchain) getTransactionByUUID(txUUID string) (*protos.Trans
 	return transaction, nil
 }
 
+func (blockchain *blockchain) getTransactionProof(txUUID string) (*protos.TransactionProof, error) {
+	blockNumber, txIndex, err := blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := blockchain.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return block.GetTransactionProof(blockNumber, int(txIndex))
+}
+
+func (blockchain *blockchain) getTransactionResultByUUID(txUUID string) (*protos.TransactionResult, error) {
+	blockNumber, _, err := blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := blockchain.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range block.GetNonHashData().GetTransactionResults() {
+		if result.Uuid == txUUID {
+			return result, nil
+		}
+	}
+	return nil, ErrResourceNotFound
+}
+
 // getTransactions get all transactions in a block identified by block number
 func (blockchain *blockchain) getTransactions(blockNumber uint64) ([]*protos.Transaction, error) {
 	block, err := blockchain.getBlock(blockNumber)
@@ -197,12 +241,16 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 	if err != nil {
 		return 0, err
 	}
+	blockchain.signBlock(block, blockNumber, blockHash)
 	blockBytes, blockBytesErr := block.Bytes()
 	if blockBytesErr != nil {
 		return 0, blockBytesErr
 	}
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
+	openchainDB := blockchain.openchainDB()
+	if err = addBlockBytesForPersistence(openchainDB, blockNumber, blockBytes, writeBatch); err != nil {
+		return 0, err
+	}
+	writeBatch.PutCF(openchainDB.BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
 	if blockchain.indexer.isSynchronous() {
 		blockchain.indexer.createIndexesSync(block, blockNumber, blockHash, writeBatch)
 	}
@@ -227,15 +275,19 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	if blockBytesErr != nil {
 		return blockBytesErr
 	}
+	openchainDB := blockchain.openchainDB()
 	writeBatch := gorocksdb.NewWriteBatch()
 	defer writeBatch.Destroy()
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	if err := addBlockBytesForPersistence(openchainDB, blockNumber, blockBytes, writeBatch); err != nil {
+		return err
+	}
+	writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeArchivedBlockKey(blockNumber))
 
 	// Need to check as we suport out of order blocks in cases such as block/state synchronization. This is
 	// really blockchain height, not size.
 	if blockchain.getSize() < blockNumber+1 {
 		sizeBytes := encodeUint64(blockNumber + 1)
-		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, sizeBytes)
+		writeBatch.PutCF(openchainDB.BlockchainCF, blockCountKey, sizeBytes)
 		blockchain.size = blockNumber + 1
 	}
 	blockHash, err := block.GetHash()
@@ -249,13 +301,66 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	err = db.GetDBHandle().DB.Write(opt, writeBatch)
+	err = openchainDB.DB.Write(opt, writeBatch)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// addPersistenceChangesForTruncation adds to writeBatch the removal of the
+// blocks after blockNumber along with their indexes
+func (blockchain *blockchain) addPersistenceChangesForTruncation(blockNumber uint64, writeBatch *gorocksdb.WriteBatch) error {
+	if !blockchain.indexer.isSynchronous() {
+		return fmt.Errorf("Blocks cannot be removed while blocks are indexed asynchronously")
+	}
+	openchainDB := blockchain.openchainDB()
+	archivedHeight, err := fetchArchivedHeight(openchainDB)
 	if err != nil {
 		return err
 	}
+	if archivedHeight > blockNumber+1 {
+		writeBatch.PutCF(openchainDB.BlockchainCF, archivedHeightKey, encodeUint64(blockNumber+1))
+	}
+	for n := blockNumber + 1; n < blockchain.size; n++ {
+		block, err := fetchBlockFromDB(openchainDB, n)
+		if err != nil {
+			return err
+		}
+		writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeBlockNumberDBKey(n))
+		writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeQuorumCertKey(n))
+		if n < archivedHeight {
+			writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeArchivedBlockKey(n))
+		}
+		if block == nil {
+			// Blocks may be missing while the blockchain is being synchronized
+			continue
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return err
+		}
+		removeIndexDataForPersistence(openchainDB, block, n, blockHash, writeBatch)
+	}
+	writeBatch.PutCF(openchainDB.BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
 	return nil
 }
 
+// truncated updates the in-memory information once the blocks after
+// blockNumber are removed
+func (blockchain *blockchain) truncated(blockNumber uint64) error {
+	blockchain.size = blockNumber + 1
+	lastBlock, err := fetchBlockFromDB(blockchain.openchainDB(), blockNumber)
+	if err != nil {
+		return err
+	}
+	blockchain.previousBlockHash = nil
+	if lastBlock != nil {
+		blockchain.previousBlockHash, err = lastBlock.GetHash()
+	}
+	return err
+}
+
 // addBlock add a new block to blockchain
 // func (blockchain *blockchain) addBlock(ctx context.Context, block *protos.Block) error {
 // 	block.SetPreviousBlockHash(blockchain.previousBlockHash)
@@ -283,27 +388,31 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 // 	return nil
 // }
 
-func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
-	blockBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+func fetchBlockFromDB(openchainDB *db.OpenchainDB, blockNumber uint64) (*protos.Block, error) {
+	value, err := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
-	if blockBytes == nil {
-		return nil, nil
+	if value == nil {
+		return fetchArchivedBlock(openchainDB, blockNumber)
+	}
+	blockBytes, err := blockBytesFromDB(openchainDB, blockNumber, value)
+	if err != nil {
+		return nil, err
 	}
 	return protos.UnmarshallBlock(blockBytes)
 }
 
-func fetchTransactionFromDB(blockNum uint64, txIndex uint64) (*protos.Transaction, error) {
-	block, err := fetchBlockFromDB(blockNum)
+func fetchTransactionFromDB(openchainDB *db.OpenchainDB, blockNum uint64, txIndex uint64) (*protos.Transaction, error) {
+	block, err := fetchBlockFromDB(openchainDB, blockNum)
 	if err != nil {
 		return nil, err
 	}
 	return block.GetTransactions()[txIndex], nil
 }
 
-func fetchBlockchainSizeFromDB() (uint64, error) {
-	bytes, err := db.GetDBHandle().GetFromBlockchainCF(blockCountKey)
+func fetchBlockchainSizeFromDB(openchainDB *db.OpenchainDB) (uint64, error) {
+	bytes, err := openchainDB.GetFromBlockchainCF(blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -313,8 +422,8 @@ func fetchBlockchainSizeFromDB() (uint64, error) {
 	return decodeToUint64(bytes), nil
 }
 
-func fetchBlockchainSizeFromSnapshot(snapshot *gorocksdb.Snapshot) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
+func fetchBlockchainSizeFromSnapshot(openchainDB *db.OpenchainDB, snapshot *gorocksdb.Snapshot) (uint64, error) {
+	blockNumberBytes, err := openchainDB.GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -46,6 +46,7 @@ type blockchainIndexer interface {
 
 // Implementation for sync indexer
 type blockchainIndexerSync struct {
+	blockchain *blockchain
 }
 
 func newBlockchainIndexerSync() *blockchainIndexerSync {
@@ -57,12 +58,13 @@ func (indexer *blockchainIndexerSync) isSynchronous() bool {
 }
 
 func (indexer *blockchainIndexerSync) start(blockchain *blockchain) error {
+	indexer.blockchain = blockchain
 	return nil
 }
 
 func (indexer *blockchainIndexerSync) createIndexesSync(
 	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
+	return addIndexDataForPersistence(indexer.blockchain.openchainDB(), block, blockNumber, blockHash, writeBatch)
 }
 
 func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error {
@@ -70,11 +72,11 @@ func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, bl
 }
 
 func (indexer *blockchainIndexerSync) fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error) {
-	return fetchBlockNumberByBlockHashFromDB(blockHash)
+	return fetchBlockNumberByBlockHashFromDB(indexer.blockchain.openchainDB(), blockHash)
 }
 
 func (indexer *blockchainIndexerSync) fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error) {
-	return fetchTransactionIndexByUUIDFromDB(txUUID)
+	return fetchTransactionIndexByUUIDFromDB(indexer.blockchain.openchainDB(), txUUID)
 }
 
 func (indexer *blockchainIndexerSync) stop() {
@@ -82,8 +84,7 @@ func (indexer *blockchainIndexerSync) stop() {
 }
 
 // Functions for persisting and retrieving index data
-func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	openchainDB := db.GetDBHandle()
+func addIndexDataForPersistence(openchainDB *db.OpenchainDB, block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
 	cf := openchainDB.IndexesCF
 
 	// add blockhash -> blockNumber
@@ -115,8 +116,23 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 	return nil
 }
 
-func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeBlockHashKey(blockHash))
+// removeIndexDataForPersistence adds to writeBatch the removal of the index
+// data that addIndexDataForPersistence added for the block
+func removeIndexDataForPersistence(openchainDB *db.OpenchainDB, block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) {
+	cf := openchainDB.IndexesCF
+	writeBatch.DeleteCF(cf, encodeBlockHashKey(blockHash))
+	addresses := make(map[string]bool)
+	for _, tx := range block.GetTransactions() {
+		writeBatch.DeleteCF(cf, encodeTxUUIDKey(tx.Uuid))
+		addresses[getTxExecutingAddress(tx)] = true
+	}
+	for address := range addresses {
+		writeBatch.DeleteCF(cf, encodeAddressBlockNumCompositeKey(address, blockNumber))
+	}
+}
+
+func fetchBlockNumberByBlockHashFromDB(openchainDB *db.OpenchainDB, blockHash []byte) (uint64, error) {
+	blockNumberBytes, err := openchainDB.GetFromIndexesCF(encodeBlockHashKey(blockHash))
 	if err != nil {
 		return 0, err
 	}
@@ -124,8 +140,8 @@ func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
 	return blockNumber, nil
 }
 
-func fetchTransactionIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
-	blockNumTxIndexBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeTxUUIDKey(txUUID))
+func fetchTransactionIndexByUUIDFromDB(openchainDB *db.OpenchainDB, txUUID string) (uint64, uint64, error) {
+	blockNumTxIndexBytes, err := openchainDB.GetFromIndexesCF(encodeTxUUIDKey(txUUID))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -0,0 +1,386 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package blockfile keeps the blocks of a ledger in append-only files. Each
+// block is written as a record made of a header, holding the block number,
+// the length of the block bytes and their CRC-32C checksum, followed by the
+// block bytes. The records are appended to numbered files, a new file being
+// started once a file reaches its maximum size. The files are self-describing
+// so that the index of the blocks kept by the ledger can be rebuilt from
+// them, and so that they can be inspected offline.
+package blockfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("blockfile")
+
+// filePrefix prefixes the names of the block files, which are followed by
+// the file number
+const filePrefix = "blocks_"
+
+// headerSize is the size of a record header: the block number, the length of
+// the block bytes and their checksum
+const headerSize = 16
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrChecksum is returned for records whose block bytes do not match
+	// their checksum
+	ErrChecksum = errors.New("blockfile: checksum mismatch")
+
+	// ErrTruncated is returned for records cut short by the end of their
+	// file, as left by a write interrupted by a crash
+	ErrTruncated = errors.New("blockfile: truncated record")
+)
+
+// Location locates a record in the block files
+type Location struct {
+	File   uint32
+	Offset uint64
+	Length uint32
+}
+
+// Bytes encodes the location
+func (location Location) Bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint32(b[0:], location.File)
+	binary.BigEndian.PutUint64(b[4:], location.Offset)
+	binary.BigEndian.PutUint32(b[12:], location.Length)
+	return b
+}
+
+// DecodeLocation decodes a location encoded by Location.Bytes
+func DecodeLocation(b []byte) (Location, error) {
+	if len(b) != 16 {
+		return Location{}, fmt.Errorf("Invalid block file location of %d bytes", len(b))
+	}
+	return Location{
+		File:   binary.BigEndian.Uint32(b[0:]),
+		Offset: binary.BigEndian.Uint64(b[4:]),
+		Length: binary.BigEndian.Uint32(b[12:]),
+	}, nil
+}
+
+func (location Location) String() string {
+	return fmt.Sprintf("%s@%d", FileName(location.File), location.Offset)
+}
+
+// Record is a record read from the block files by Scan. Err is set, to
+// ErrChecksum or ErrTruncated, for the records that are corrupted, whose
+// Data is then not to be trusted.
+type Record struct {
+	BlockNumber uint64
+	Location    Location
+	Data        []byte
+	Err         error
+}
+
+// Store appends blocks to the block files of a directory and reads them back.
+// A Store is safe for concurrent use, but a directory must only be written
+// by one Store at a time.
+type Store struct {
+	dir         string
+	maxFileSize int64
+
+	lock       sync.Mutex
+	file       *os.File
+	fileNumber uint32
+	fileSize   int64
+}
+
+// Open opens the block files of dir, creating dir if needed. A truncated
+// record ending the last file, left by an interrupted write, is removed.
+func Open(dir string, maxFileSize int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error making block files directory [%s]: %s", dir, err)
+	}
+	fileNumbers, err := listFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	store := &Store{dir: dir, maxFileSize: maxFileSize}
+	if len(fileNumbers) > 0 {
+		store.fileNumber = fileNumbers[len(fileNumbers)-1]
+	}
+	if err = store.openFile(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// openFile opens the current file for appending, dropping a truncated record
+// at its end
+func (store *Store) openFile() error {
+	path := store.filePath(store.fileNumber)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	end, err := scanFile(file, store.fileNumber, func(record *Record) error { return nil })
+	if err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if info.Size() > end {
+		logger.Warning("Removing truncated record at the end of block file [%s], from offset [%d] to [%d]", path, end, info.Size())
+		if err = file.Truncate(end); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if _, err = file.Seek(end, os.SEEK_SET); err != nil {
+		file.Close()
+		return err
+	}
+	store.file = file
+	store.fileSize = end
+	return nil
+}
+
+// Append appends the bytes of a block to the block files, and returns their
+// location once they are synced to disk
+func (store *Store) Append(blockNumber uint64, data []byte) (Location, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	recordSize := int64(headerSize + len(data))
+	if store.fileSize > 0 && store.fileSize+recordSize > store.maxFileSize {
+		if err := store.file.Close(); err != nil {
+			return Location{}, err
+		}
+		store.fileNumber++
+		if err := store.openFile(); err != nil {
+			return Location{}, err
+		}
+	}
+	record := make([]byte, recordSize)
+	binary.BigEndian.PutUint64(record[0:], blockNumber)
+	binary.BigEndian.PutUint32(record[8:], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[12:], crc32.Checksum(data, crcTable))
+	copy(record[headerSize:], data)
+	if _, err := store.file.Write(record); err != nil {
+		// Drop what may have been written, so that the next record follows
+		// the last complete one
+		store.file.Truncate(store.fileSize)
+		store.file.Seek(store.fileSize, os.SEEK_SET)
+		return Location{}, err
+	}
+	if err := store.file.Sync(); err != nil {
+		return Location{}, err
+	}
+	location := Location{File: store.fileNumber, Offset: uint64(store.fileSize), Length: uint32(len(data))}
+	store.fileSize += recordSize
+	return location, nil
+}
+
+// Read reads back the bytes of a block, checking that the record at location
+// holds the block and is not corrupted
+func (store *Store) Read(blockNumber uint64, location Location) ([]byte, error) {
+	file, err := os.Open(store.filePath(location.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	record := make([]byte, headerSize+int(location.Length))
+	if _, err = file.ReadAt(record, int64(location.Offset)); err != nil {
+		if err == io.EOF {
+			err = ErrTruncated
+		}
+		return nil, fmt.Errorf("Error reading block number [%d] at [%s]: %s", blockNumber, location, err)
+	}
+	if number := binary.BigEndian.Uint64(record[0:]); number != blockNumber {
+		return nil, fmt.Errorf("Record at [%s] holds block number [%d] instead of [%d]", location, number, blockNumber)
+	}
+	if length := binary.BigEndian.Uint32(record[8:]); length != location.Length {
+		return nil, fmt.Errorf("Record at [%s] holds %d bytes instead of %d", location, length, location.Length)
+	}
+	data := record[headerSize:]
+	if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(record[12:]) {
+		return nil, fmt.Errorf("Error reading block number [%d] at [%s]: %s", blockNumber, location, ErrChecksum)
+	}
+	return data, nil
+}
+
+// CopyTo copies the block files, as of the last appended block, to dir, which
+// is created if needed
+func (store *Store) CopyTo(dir string) error {
+	store.lock.Lock()
+	lastFileNumber, lastFileSize := store.fileNumber, store.fileSize
+	store.lock.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for fileNumber := uint32(0); fileNumber <= lastFileNumber; fileNumber++ {
+		size := int64(-1)
+		if fileNumber == lastFileNumber {
+			size = lastFileSize
+		}
+		if err := copyFile(store.filePath(fileNumber), filepath.Join(dir, FileName(fileNumber)), size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the file blocks are appended to
+func (store *Store) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	return store.file.Close()
+}
+
+func (store *Store) filePath(fileNumber uint32) string {
+	return filepath.Join(store.dir, FileName(fileNumber))
+}
+
+// Scan reads all the records of the block files of dir in the order they
+// were appended, and calls fn with each of them, including the corrupted
+// ones. Scan stops at the first error returned by fn.
+func Scan(dir string, fn func(record *Record) error) error {
+	fileNumbers, err := listFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, fileNumber := range fileNumbers {
+		file, err := os.Open(filepath.Join(dir, FileName(fileNumber)))
+		if err != nil {
+			return err
+		}
+		_, err = scanFile(file, fileNumber, fn)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanFile calls fn with the records of file, and returns the offset
+// following the last record that is not truncated
+func scanFile(file *os.File, fileNumber uint32, fn func(record *Record) error) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, info.Size()))
+	header := make([]byte, headerSize)
+	offset := int64(0)
+	for offset < info.Size() {
+		record := &Record{Location: Location{File: fileNumber, Offset: uint64(offset)}}
+		if _, err = io.ReadFull(reader, header); err != nil {
+			record.Err = ErrTruncated
+			return offset, fn(record)
+		}
+		record.BlockNumber = binary.BigEndian.Uint64(header[0:])
+		record.Location.Length = binary.BigEndian.Uint32(header[8:])
+		recordSize := headerSize + int64(record.Location.Length)
+		if offset+recordSize > info.Size() {
+			record.Err = ErrTruncated
+			return offset, fn(record)
+		}
+		record.Data = make([]byte, record.Location.Length)
+		if _, err = io.ReadFull(reader, record.Data); err != nil {
+			return offset, err
+		}
+		if crc32.Checksum(record.Data, crcTable) != binary.BigEndian.Uint32(header[12:]) {
+			record.Err = ErrChecksum
+		}
+		if err = fn(record); err != nil {
+			return offset, err
+		}
+		offset += recordSize
+	}
+	return offset, nil
+}
+
+// listFiles returns the numbers of the block files of dir in increasing order
+func listFiles(dir string) ([]uint32, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fileNumbers []uint32
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), filePrefix) {
+			continue
+		}
+		fileNumber, err := strconv.ParseUint(strings.TrimPrefix(entry.Name(), filePrefix), 10, 32)
+		if err != nil {
+			continue
+		}
+		fileNumbers = append(fileNumbers, uint32(fileNumber))
+	}
+	sort.Sort(uint32Slice(fileNumbers))
+	return fileNumbers, nil
+}
+
+// FileName returns the name of a block file
+func FileName(fileNumber uint32) string {
+	return fmt.Sprintf("%s%06d", filePrefix, fileNumber)
+}
+
+// copyFile copies the first size bytes of a file, or all of it if size is
+// negative
+func copyFile(source string, target string, size int64) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = in
+	if size >= 0 {
+		reader = io.LimitReader(in, size)
+	}
+	if _, err = io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type uint32Slice []uint32
+
+func (s uint32Slice) Len() int           { return len(s) }
+func (s uint32Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint32Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,131 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package blockfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Small files so that the records span several files
+	store, err := Open(dir, 64)
+	if err != nil {
+		t.Fatalf("Error opening block files: %s", err)
+	}
+	var locations []Location
+	for i := 0; i < 5; i++ {
+		location, err := store.Append(uint64(i), []byte(fmt.Sprintf("block%d-%s", i, bytes.Repeat([]byte("x"), 20))))
+		if err != nil {
+			t.Fatalf("Error appending block: %s", err)
+		}
+		locations = append(locations, location)
+	}
+	if locations[4].File == 0 {
+		t.Fatalf("Expected the blocks to span several files, got %v", locations)
+	}
+	for i, location := range locations {
+		decoded, err := DecodeLocation(location.Bytes())
+		if err != nil || decoded != location {
+			t.Fatalf("Location %v decoded as %v: %v", location, decoded, err)
+		}
+		data, err := store.Read(uint64(i), location)
+		if err != nil {
+			t.Fatalf("Error reading block %d: %s", i, err)
+		}
+		if !bytes.HasPrefix(data, []byte(fmt.Sprintf("block%d-", i))) {
+			t.Fatalf("Unexpected bytes of block %d: %s", i, data)
+		}
+	}
+	if _, err = store.Read(1, locations[2]); err == nil {
+		t.Fatal("Expected error reading block at the location of another block")
+	}
+
+	// Corrupt the second block and leave a truncated record after the last one
+	path := store.filePath(locations[1].File)
+	data, _ := ioutil.ReadFile(path)
+	data[locations[1].Offset+headerSize] ^= 0xff
+	ioutil.WriteFile(path, data, 0644)
+	store.file.Write([]byte{0, 0, 0})
+	store.Close()
+
+	var scanned []*Record
+	err = Scan(dir, func(record *Record) error {
+		scanned = append(scanned, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error scanning block files: %s", err)
+	}
+	if len(scanned) != 6 {
+		t.Fatalf("Expected 6 records, got %d", len(scanned))
+	}
+	for i, record := range scanned[:5] {
+		if record.BlockNumber != uint64(i) || record.Location != locations[i] {
+			t.Fatalf("Unexpected record %d: %+v", i, record)
+		}
+		if (record.Err != nil) != (i == 1) {
+			t.Fatalf("Unexpected error of record %d: %v", i, record.Err)
+		}
+	}
+	if scanned[5].Err != ErrTruncated {
+		t.Fatalf("Expected truncated record, got %v", scanned[5].Err)
+	}
+
+	// Reopening drops the truncated record
+	store, err = Open(dir, 1024)
+	if err != nil {
+		t.Fatalf("Error reopening block files: %s", err)
+	}
+	defer store.Close()
+	if _, err = store.Read(1, locations[1]); err == nil {
+		t.Fatal("Expected error reading corrupted block")
+	}
+	location, err := store.Append(5, []byte("block5"))
+	if err != nil {
+		t.Fatalf("Error appending block: %s", err)
+	}
+	if location.File != locations[4].File || location.Offset != locations[4].Offset+uint64(headerSize+locations[4].Length) {
+		t.Fatalf("Expected block to follow the last complete record, got %v", location)
+	}
+
+	copyDir := filepath.Join(dir, "copy")
+	if err = store.CopyTo(copyDir); err != nil {
+		t.Fatalf("Error copying block files: %s", err)
+	}
+	count := 0
+	Scan(copyDir, func(record *Record) error {
+		count++
+		return nil
+	})
+	if count != 6 {
+		t.Fatalf("Expected 6 records in the copy, got %d", count)
+	}
+}
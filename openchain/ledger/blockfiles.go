@@ -0,0 +1,217 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger/blockfile"
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+// blockStorageKey records in blockchainCF that the blocks of the ledger are
+// kept in block files. The blocks of the ledgers without it are kept in the
+// DB.
+var blockStorageKey = []byte("blockStorage")
+
+const (
+	blockStorageDB   = "db"
+	blockStorageFile = "file"
+)
+
+// blockFilesDir is the directory of the chain files holding the block files
+const blockFilesDir = "blocks"
+
+// blockFiles holds the block files of the ledgers keeping their blocks in
+// files, by DB. In these ledgers the blocks in blockchainCF are replaced by
+// their location in the block files.
+var blockFiles = struct {
+	sync.Mutex
+	stores map[*db.OpenchainDB]*blockfile.Store
+}{stores: make(map[*db.OpenchainDB]*blockfile.Store)}
+
+// openBlockFiles opens the block files of a chain if its blocks are kept in
+// files. The storage of the blocks is set by ledger.blockchain.storage when
+// the ledger is created and cannot be changed afterwards.
+func openBlockFiles(chainID string, openchainDB *db.OpenchainDB, size uint64) error {
+	storage := viper.GetString("ledger.blockchain.storage")
+	if storage == "" {
+		storage = blockStorageDB
+	}
+	if storage != blockStorageDB && storage != blockStorageFile {
+		return fmt.Errorf("Unknown block storage [%s], expected db or file", storage)
+	}
+	storedStorage, err := fetchBlockStorage(openchainDB)
+	if err != nil {
+		return err
+	}
+	if size == 0 && storedStorage != storage {
+		opt := gorocksdb.NewDefaultWriteOptions()
+		defer opt.Destroy()
+		if storage == blockStorageFile {
+			err = openchainDB.DB.PutCF(opt, openchainDB.BlockchainCF, blockStorageKey, []byte(storage))
+		} else {
+			err = openchainDB.DB.DeleteCF(opt, openchainDB.BlockchainCF, blockStorageKey)
+		}
+		if err != nil {
+			return err
+		}
+		storedStorage = storage
+	}
+	if storedStorage != storage {
+		return fmt.Errorf("The blocks of the ledger are kept in [%s] storage, which cannot be changed to [%s]", storedStorage, storage)
+	}
+	if storage != blockStorageFile {
+		return nil
+	}
+
+	blockFiles.Lock()
+	defer blockFiles.Unlock()
+	if _, ok := blockFiles.stores[openchainDB]; ok {
+		return nil
+	}
+	maxFileSize := int64(viper.GetInt("ledger.blockchain.blockfile.maxSize"))
+	if maxFileSize <= 0 {
+		return fmt.Errorf("Invalid ledger.blockchain.blockfile.maxSize [%d]", maxFileSize)
+	}
+	store, err := blockfile.Open(GetBlockFilesPath(chainID), maxFileSize)
+	if err != nil {
+		return err
+	}
+	blockFiles.stores[openchainDB] = store
+	return nil
+}
+
+func getBlockFiles(openchainDB *db.OpenchainDB) *blockfile.Store {
+	blockFiles.Lock()
+	defer blockFiles.Unlock()
+	return blockFiles.stores[openchainDB]
+}
+
+// GetBlockFilesPath returns the directory holding the block files of a chain
+func GetBlockFilesPath(chainID string) string {
+	return filepath.Join(db.GetChainPath(chainID), blockFilesDir)
+}
+
+func fetchBlockStorage(openchainDB *db.OpenchainDB) (string, error) {
+	storageBytes, err := openchainDB.GetFromBlockchainCF(blockStorageKey)
+	if err != nil || storageBytes == nil {
+		return blockStorageDB, err
+	}
+	return string(storageBytes), nil
+}
+
+// addBlockBytesForPersistence adds the bytes of a block to writeBatch, or
+// appends them to the block files and adds their location to writeBatch if
+// the blocks are kept in files
+func addBlockBytesForPersistence(openchainDB *db.OpenchainDB, blockNumber uint64, blockBytes []byte, writeBatch *gorocksdb.WriteBatch) error {
+	if store := getBlockFiles(openchainDB); store != nil {
+		location, err := store.Append(blockNumber, blockBytes)
+		if err != nil {
+			return fmt.Errorf("Error appending block number [%d] to the block files: %s", blockNumber, err)
+		}
+		blockBytes = location.Bytes()
+	}
+	writeBatch.PutCF(openchainDB.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	return nil
+}
+
+// blockBytesFromDB returns the bytes of a block from the value of its key in
+// blockchainCF, reading them from the block files if the blocks are kept in
+// files
+func blockBytesFromDB(openchainDB *db.OpenchainDB, blockNumber uint64, value []byte) ([]byte, error) {
+	store := getBlockFiles(openchainDB)
+	if store == nil || value == nil {
+		return value, nil
+	}
+	location, err := blockfile.DecodeLocation(value)
+	if err != nil {
+		return nil, err
+	}
+	return store.Read(blockNumber, location)
+}
+
+// RebuildBlockIndex rebuilds the locations of the blocks of a chain kept in
+// block files by scanning the files, and returns the number of blocks
+// indexed. The records of the blocks beyond the blockchain height, and the
+// corrupted records, are skipped. When a block was written several times,
+// as happens after a rollback, its last record is kept. The peer must not be
+// running.
+func RebuildBlockIndex(chainID string) (uint64, error) {
+	if err := db.CheckChainID(chainID); err != nil {
+		return 0, err
+	}
+	openchainDB := db.GetChainDBHandle(chainID)
+	storage, err := fetchBlockStorage(openchainDB)
+	if err != nil {
+		return 0, err
+	}
+	if storage != blockStorageFile {
+		return 0, fmt.Errorf("The blocks of the ledger are kept in [%s] storage, not in block files", storage)
+	}
+	size, err := fetchBlockchainSizeFromDB(openchainDB)
+	if err != nil {
+		return 0, err
+	}
+	archivedHeight, err := fetchArchivedHeight(openchainDB)
+	if err != nil {
+		return 0, err
+	}
+
+	locations := make(map[uint64]blockfile.Location)
+	dir := GetBlockFilesPath(chainID)
+	err = blockfile.Scan(dir, func(record *blockfile.Record) error {
+		if record.Err != nil {
+			ledgerLogger.Warning("Skipping corrupted record of block number [%d] at [%s]: %s", record.BlockNumber, record.Location, record.Err)
+		} else if record.BlockNumber < size {
+			locations[record.BlockNumber] = record.Location
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Error scanning block files [%s]: %s", dir, err)
+	}
+
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for blockNumber := uint64(0); blockNumber < size; blockNumber++ {
+		if blockNumber < archivedHeight {
+			// Archived blocks are read from the archive
+			continue
+		}
+		location, ok := locations[blockNumber]
+		if !ok {
+			return 0, fmt.Errorf("No valid record of block number [%d] in block files [%s]", blockNumber, dir)
+		}
+		writeBatch.PutCF(openchainDB.BlockchainCF, encodeBlockNumberDBKey(blockNumber), location.Bytes())
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	if err = openchainDB.DB.Write(opt, writeBatch); err != nil {
+		return 0, err
+	}
+	indexed := uint64(writeBatch.Count())
+	ledgerLogger.Info("Rebuilt the locations of [%d] blocks from block files [%s]", indexed, dir)
+	return indexed, nil
+}
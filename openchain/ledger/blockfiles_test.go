@@ -0,0 +1,82 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/blockfile"
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+func TestBlockFiles(t *testing.T) {
+	viper.Set("ledger.blockchain.storage", "file")
+	viper.Set("ledger.blockchain.blockfile.maxSize", 256)
+	defer viper.Set("ledger.blockchain.storage", "db")
+
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	var blocks []*protos.Block
+	for i := 0; i < 3; i++ {
+		ledger.BeginTxBatch(i)
+		transaction, _ := buildTestTx(t)
+		ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof"))
+		blocks = append(blocks, ledgerTestWrapper.GetBlockByNumber(uint64(i)))
+	}
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(3))
+	openchainDB := ledger.openchainDB()
+	value, _ := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(1))
+	location, err := blockfile.DecodeLocation(value)
+	testutil.AssertNoError(t, err, "Expected the location of the block in the DB")
+
+	// The storage cannot be changed once the ledger has blocks
+	viper.Set("ledger.blockchain.storage", "db")
+	_, err = newLedger()
+	testutil.AssertError(t, err, "Expected error changing the block storage")
+	viper.Set("ledger.blockchain.storage", "file")
+
+	// The locations are rebuilt from the block files
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	openchainDB.DB.DeleteCF(opt, openchainDB.BlockchainCF, encodeBlockNumberDBKey(1))
+	indexed, err := RebuildBlockIndex("")
+	testutil.AssertNoError(t, err, "Error rebuilding block index")
+	testutil.AssertEquals(t, indexed, uint64(3))
+	for i, block := range blocks {
+		assertSameBlock(t, ledgerTestWrapper.GetBlockByNumber(uint64(i)), block)
+	}
+
+	// Corrupted blocks are detected
+	path := filepath.Join(GetBlockFilesPath(""), blockfile.FileName(location.File))
+	data, err := ioutil.ReadFile(path)
+	testutil.AssertNoError(t, err, "Error reading block file")
+	// Flip the first byte of the block, following the 16 bytes of the header
+	data[location.Offset+16] ^= 0xff
+	testutil.AssertNoError(t, ioutil.WriteFile(path, data, 0644), "Error writing block file")
+	_, err = ledger.GetBlockByNumber(1)
+	testutil.AssertError(t, err, "Expected error reading corrupted block")
+	_, err = RebuildBlockIndex("")
+	testutil.AssertError(t, err, "Expected error rebuilding block index without valid record of a block")
+}
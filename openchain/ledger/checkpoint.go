@@ -0,0 +1,147 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/tecbot/gorocksdb"
+)
+
+// checkpointKeyPrefix prefixes the names of the checkpoints in blockchainCF.
+// It is longer than the encoded block numbers so that the keys never collide.
+var checkpointKeyPrefix = []byte("checkpoint_")
+
+// TagCheckpoint tags the last block of the blockchain with a named checkpoint
+// that the ledger can later be rolled back to, and returns its number. Tagging
+// an existing checkpoint moves it to the last block.
+func (ledger *Ledger) TagCheckpoint(name string) (uint64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("Checkpoint name is required")
+	}
+	// Checkpoints are only tagged at block boundaries
+	if err := ledger.checkValidIDBegin(); err != nil {
+		return 0, err
+	}
+	size := ledger.blockchain.getSize()
+	if size == 0 {
+		return 0, fmt.Errorf("Blockchain has no blocks, cannot tag checkpoint [%s]", name)
+	}
+	blockNumber := size - 1
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	openchainDB := ledger.openchainDB()
+	if err := openchainDB.DB.PutCF(opt, openchainDB.BlockchainCF, encodeCheckpointKey(name), encodeUint64(blockNumber)); err != nil {
+		return 0, err
+	}
+	ledgerLogger.Info("Tagged block number [%d] with checkpoint [%s]", blockNumber, name)
+	return blockNumber, nil
+}
+
+// GetCheckpoint returns the number of the block tagged by a checkpoint, or
+// ErrResourceNotFound if there is no such checkpoint
+func (ledger *Ledger) GetCheckpoint(name string) (uint64, error) {
+	blockNumberBytes, err := ledger.openchainDB().GetFromBlockchainCF(encodeCheckpointKey(name))
+	if err != nil {
+		return 0, err
+	}
+	if blockNumberBytes == nil {
+		return 0, ErrResourceNotFound
+	}
+	return decodeToUint64(blockNumberBytes), nil
+}
+
+// GetCheckpoints returns the numbers of the blocks tagged by the checkpoints,
+// by checkpoint name
+func (ledger *Ledger) GetCheckpoints() (map[string]uint64, error) {
+	itr := ledger.openchainDB().GetBlockchainCFIterator()
+	defer itr.Close()
+	checkpoints := make(map[string]uint64)
+	for itr.Seek(checkpointKeyPrefix); itr.ValidForPrefix(checkpointKeyPrefix); itr.Next() {
+		keyBytes := statemgmt.Copy(itr.Key().Data())
+		valueBytes := statemgmt.Copy(itr.Value().Data())
+		checkpoints[string(bytes.TrimPrefix(keyBytes, checkpointKeyPrefix))] = decodeToUint64(valueBytes)
+	}
+	return checkpoints, itr.Err()
+}
+
+// RollbackToCheckpoint reverts the state and the blockchain to the block
+// tagged by a checkpoint. The blocks after it are removed along with the
+// checkpoints tagging them. The state is rolled back using the state-deltas
+// of the removed blocks, so only checkpoints within the last
+// ledger.state.deltaHistorySize blocks can be rolled back to. This is meant
+// for test networks and disaster recovery; the other peers of a network
+// must be rolled back to the same checkpoint, or they will not agree on the
+// blocks added afterwards.
+func (ledger *Ledger) RollbackToCheckpoint(name string) error {
+	if err := ledger.checkValidIDBegin(); err != nil {
+		return err
+	}
+	blockNumber, err := ledger.GetCheckpoint(name)
+	if err != nil {
+		return fmt.Errorf("Error getting checkpoint [%s]: %s", name, err)
+	}
+	size := ledger.blockchain.getSize()
+	if blockNumber >= size {
+		return fmt.Errorf("Checkpoint [%s] tags block number [%d] beyond the last block [%d]", name, blockNumber, size-1)
+	}
+	checkpoints, err := ledger.GetCheckpoints()
+	if err != nil {
+		return err
+	}
+
+	archiveLock.Lock()
+	defer archiveLock.Unlock()
+	ledger.currentID = "rollback-" + name
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	if err = ledger.state.AddRollbackChangesForPersistence(blockNumber, size-1, writeBatch); err != nil {
+		ledger.resetForNextTxGroup(false)
+		return err
+	}
+	if err = ledger.blockchain.addPersistenceChangesForTruncation(blockNumber, writeBatch); err != nil {
+		ledger.resetForNextTxGroup(false)
+		return err
+	}
+	openchainDB := ledger.openchainDB()
+	for checkpoint, checkpointBlockNumber := range checkpoints {
+		if checkpointBlockNumber > blockNumber {
+			writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeCheckpointKey(checkpoint))
+		}
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	if err = openchainDB.DB.Write(opt, writeBatch); err != nil {
+		ledger.resetForNextTxGroup(false)
+		return err
+	}
+	ledger.resetForNextTxGroup(true)
+	stateSizes.reset()
+	ledgerLogger.Warning("Rolled back the ledger from block number [%d] to block number [%d] tagged by checkpoint [%s]", size-1, blockNumber, name)
+	err = ledger.blockchain.truncated(blockNumber)
+	ledger.setBlockHeightMetric()
+	return err
+}
+
+func encodeCheckpointKey(name string) []byte {
+	return append(append([]byte{}, checkpointKeyPrefix...), name...)
+}
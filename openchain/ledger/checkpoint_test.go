@@ -0,0 +1,99 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestRollbackToCheckpoint(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	_, err := ledger.TagCheckpoint("empty")
+	testutil.AssertError(t, err, "Expected error tagging checkpoint on empty blockchain")
+
+	// Block 0
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.SetState("chaincode2", "key2", []byte("value2A"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	stateHash0, _ := ledger.GetTempStateHash()
+	blockNumber, err := ledger.TagCheckpoint("cp0")
+	testutil.AssertNoError(t, err, "Error tagging checkpoint")
+	testutil.AssertEquals(t, blockNumber, uint64(0))
+
+	// Block 1
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "key1", []byte("value1B"))
+	ledger.DeleteState("chaincode2", "key2")
+	ledger.SetState("chaincode3", "key3", []byte("value3B"))
+	ledger.TxFinished("txUuid2", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	// Block 2
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid3")
+	ledger.SetState("chaincode1", "key1", []byte("value1C"))
+	ledger.SetState("chaincode3", "key3", []byte("value3C"))
+	ledger.TxFinished("txUuid3", true)
+	transaction, uuid2 := buildTestTx(t)
+	ledger.CommitTxBatch(2, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	ledger.TagCheckpoint("cp2")
+
+	checkpoints, err := ledger.GetCheckpoints()
+	testutil.AssertNoError(t, err, "Error getting checkpoints")
+	testutil.AssertEquals(t, checkpoints, map[string]uint64{"cp0": 0, "cp2": 2})
+
+	err = ledger.RollbackToCheckpoint("unknown")
+	testutil.AssertError(t, err, "Expected error rolling back to unknown checkpoint")
+
+	err = ledger.RollbackToCheckpoint("cp0")
+	testutil.AssertNoError(t, err, "Error rolling back to checkpoint")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1A"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "key2", true), []byte("value2A"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode3", "key3", true))
+	stateHash, _ := ledger.GetTempStateHash()
+	testutil.AssertEquals(t, stateHash, stateHash0)
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(1))
+	_, err = ledger.GetTransactionByUUID(uuid2)
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+	checkpoints, _ = ledger.GetCheckpoints()
+	testutil.AssertEquals(t, checkpoints, map[string]uint64{"cp0": 0})
+
+	// The blockchain continues from the checkpoint
+	ledger.BeginTxBatch(3)
+	ledger.TxBegin("txUuid4")
+	ledger.SetState("chaincode1", "key1", []byte("value1D"))
+	ledger.TxFinished("txUuid4", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(3, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(2))
+	testutil.AssertEquals(t, ledgerTestWrapper.VerifyChain(1, 0), uint64(0))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", true), []byte("value1D"))
+}
@@ -0,0 +1,100 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/openblockchain/obc-peer/protos"
+	"github.com/tecbot/gorocksdb"
+)
+
+// deadLetterKeyPrefix prefixes the UUIDs of the failed transactions kept in
+// blockchainCF. Like the checkpoint prefix it is longer than the encoded
+// block numbers.
+var deadLetterKeyPrefix = []byte("deadletter_")
+
+// PutDeadLetter keeps a failed transaction until it is deleted. It replaces
+// the dead letter of an earlier failure of the same transaction.
+func (ledger *Ledger) PutDeadLetter(letter *protos.DeadLetter) error {
+	if letter.Transaction == nil {
+		return fmt.Errorf("Dead letter has no transaction")
+	}
+	letterBytes, err := proto.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	openchainDB := ledger.openchainDB()
+	return openchainDB.DB.PutCF(opt, openchainDB.BlockchainCF, encodeDeadLetterKey(letter.GetTransaction().Uuid), letterBytes)
+}
+
+// GetDeadLetter returns the dead letter of a transaction, or
+// ErrResourceNotFound if the transaction has none
+func (ledger *Ledger) GetDeadLetter(uuid string) (*protos.DeadLetter, error) {
+	letterBytes, err := ledger.openchainDB().GetFromBlockchainCF(encodeDeadLetterKey(uuid))
+	if err != nil {
+		return nil, err
+	}
+	if letterBytes == nil {
+		return nil, ErrResourceNotFound
+	}
+	letter := &protos.DeadLetter{}
+	if err = proto.Unmarshal(letterBytes, letter); err != nil {
+		return nil, err
+	}
+	return letter, nil
+}
+
+// GetDeadLetters returns the dead letters ordered by transaction UUID
+func (ledger *Ledger) GetDeadLetters() ([]*protos.DeadLetter, error) {
+	itr := ledger.openchainDB().GetBlockchainCFIterator()
+	defer itr.Close()
+	var letters []*protos.DeadLetter
+	for itr.Seek(deadLetterKeyPrefix); itr.ValidForPrefix(deadLetterKeyPrefix); itr.Next() {
+		letter := &protos.DeadLetter{}
+		if err := proto.Unmarshal(statemgmt.Copy(itr.Value().Data()), letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, itr.Err()
+}
+
+// DeleteDeadLetters deletes the dead letters of transactions. UUIDs without
+// a dead letter are ignored.
+func (ledger *Ledger) DeleteDeadLetters(uuids []string) error {
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	openchainDB := ledger.openchainDB()
+	for _, uuid := range uuids {
+		writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeDeadLetterKey(uuid))
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return openchainDB.DB.Write(opt, writeBatch)
+}
+
+func encodeDeadLetterKey(uuid string) []byte {
+	return append(append([]byte{}, deadLetterKeyPrefix...), uuid...)
+}
@@ -0,0 +1,63 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestDeadLetters(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	_, err := ledger.GetDeadLetter("txUuid1")
+	testutil.AssertSame(t, err, ErrResourceNotFound)
+	err = ledger.PutDeadLetter(&protos.DeadLetter{Phase: protos.DeadLetter_SUBMISSION})
+	testutil.AssertError(t, err, "Expected error keeping a dead letter without transaction")
+
+	letter1 := &protos.DeadLetter{Transaction: &protos.Transaction{Uuid: "txUuid1"}, Phase: protos.DeadLetter_SIMULATION, Error: "simulation failed"}
+	letter2 := &protos.DeadLetter{Transaction: &protos.Transaction{Uuid: "txUuid2"}, Phase: protos.DeadLetter_EXECUTION, Error: "execution failed", BlockNumber: 3}
+	testutil.AssertNoError(t, ledger.PutDeadLetter(letter1), "Error keeping dead letter")
+	testutil.AssertNoError(t, ledger.PutDeadLetter(letter2), "Error keeping dead letter")
+
+	letter, err := ledger.GetDeadLetter("txUuid2")
+	testutil.AssertNoError(t, err, "Error getting dead letter")
+	testutil.AssertEquals(t, letter, letter2)
+
+	// A later failure of the same transaction replaces the dead letter
+	letter1 = &protos.DeadLetter{Transaction: &protos.Transaction{Uuid: "txUuid1"}, Phase: protos.DeadLetter_SUBMISSION, Error: "submission failed"}
+	testutil.AssertNoError(t, ledger.PutDeadLetter(letter1), "Error keeping dead letter")
+	letters, err := ledger.GetDeadLetters()
+	testutil.AssertNoError(t, err, "Error getting dead letters")
+	testutil.AssertEquals(t, letters, []*protos.DeadLetter{letter1, letter2})
+
+	testutil.AssertNoError(t, ledger.DeleteDeadLetters([]string{"txUuid1", "unknown"}), "Error deleting dead letters")
+	letters, err = ledger.GetDeadLetters()
+	testutil.AssertNoError(t, err, "Error getting dead letters")
+	testutil.AssertEquals(t, letters, []*protos.DeadLetter{letter2})
+
+	// Dead letters are not blocks or checkpoints
+	checkpoints, err := ledger.GetCheckpoints()
+	testutil.AssertNoError(t, err, "Error getting checkpoints")
+	testutil.AssertEquals(t, len(checkpoints), 0)
+}
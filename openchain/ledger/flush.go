@@ -0,0 +1,77 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package ledger
+
+import (
+	"fmt"
+	"time"
+)
+
+// flushPollInterval is how often Flush checks whether the batch in progress
+// has completed
+const flushPollInterval = 10 * time.Millisecond
+
+// Flush waits, up to timeout, for the transaction batch in progress to be
+// committed or rolled back and for the committed blocks to be indexed, so
+// that the peer can exit without losing work queued in the ledger.
+func (ledger *Ledger) Flush(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for ledger.currentID != nil {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Transaction batch [%v] still in progress after %s", ledger.currentID, timeout)
+		}
+		time.Sleep(flushPollInterval)
+	}
+	indexer, ok := ledger.blockchain.indexer.(*blockchainIndexerAsync)
+	if !ok {
+		return nil
+	}
+	indexed := make(chan error, 1)
+	go func() {
+		indexed <- indexer.indexerState.waitForLastCommittedBlock()
+	}()
+	select {
+	case err := <-indexed:
+		return err
+	case <-time.After(deadline.Sub(time.Now())):
+		return fmt.Errorf("Blocks still being indexed after %s", timeout)
+	}
+}
+
+// FlushAll flushes the ledger of the default chain and the ledgers of the
+// other chains opened by the peer, sharing timeout between them
+func FlushAll(timeout time.Duration) error {
+	ledgers := []*Ledger{}
+	if ledger, err := GetLedger(); err == nil {
+		ledgers = append(ledgers, ledger)
+	}
+	chainLedgersLock.Lock()
+	for _, chainLedger := range chainLedgers {
+		ledgers = append(ledgers, chainLedger)
+	}
+	chainLedgersLock.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for _, ledger := range ledgers {
+		if err := ledger.Flush(deadline.Sub(time.Now())); err != nil {
+			return fmt.Errorf("Error flushing ledger of chain [%s]: %s", ledger.chainID, err)
+		}
+	}
+	return nil
+}
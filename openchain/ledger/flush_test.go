@@ -0,0 +1,51 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestLedgerFlush(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	err := ledger.Flush(time.Second)
+	testutil.AssertNoError(t, err, "Error flushing idle ledger")
+
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid", true)
+	err = ledger.Flush(50 * time.Millisecond)
+	testutil.AssertError(t, err, "Expected error flushing ledger with batch in progress")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		transaction, _ := buildTestTx(t)
+		ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	}()
+	err = ledger.Flush(5 * time.Second)
+	testutil.AssertNoError(t, err, "Error flushing ledger after batch commit")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(1))
+}
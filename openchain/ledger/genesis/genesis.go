@@ -40,7 +40,8 @@ var makeGenesisError error
 var once sync.Once
 
 // MakeGenesis creates the genesis block based on configuration in openchain.yaml
-// and adds it to the blockchain.
+// and adds it to the blockchain. If a network definition is configured the
+// genesis block is built from it instead.
 func MakeGenesis() error {
 	once.Do(func() {
 		ledger, err := ledger.GetLedger()
@@ -49,8 +50,22 @@ func MakeGenesis() error {
 			return
 		}
 
+		network, err := GetNetworkDefinition()
+		if err != nil {
+			makeGenesisError = err
+			return
+		}
+
 		if ledger.GetBlockchainSize() > 0 {
 			// genesis block already exists
+			if network != nil {
+				makeGenesisError = network.checkGenesisBlock(ledger)
+			}
+			return
+		}
+
+		if network != nil {
+			makeGenesisError = makeNetworkGenesis(ledger, network)
 			return
 		}
 
@@ -176,6 +191,22 @@ func MakeGenesis() error {
 	return makeGenesisError
 }
 
+func makeNetworkGenesis(ledger *ledger.Ledger, network *NetworkDefinition) error {
+	genesisLogger.Info("Creating genesis block from network definition %s.", network.Name)
+	metadata, err := network.Bytes()
+	if err != nil {
+		return err
+	}
+	ledger.BeginTxBatch(0)
+	genesisTransactions, err := network.deployChaincodes()
+	if err != nil {
+		ledger.RollbackTxBatch(0)
+		return err
+	}
+	genesisLogger.Info("Adding %d chaincodes to the genesis block.", len(genesisTransactions))
+	return ledger.CommitTxBatch(0, genesisTransactions, nil, metadata)
+}
+
 //BuildLocal builds a given chaincode code
 func BuildLocal(context context.Context, spec *protos.ChaincodeSpec) (*protos.ChaincodeDeploymentSpec, error) {
 	genesisLogger.Debug("Received build request for chaincode spec: %v", spec)
@@ -0,0 +1,286 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package genesis
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+// NetworkDefinition describes a network declaratively: its validators, the
+// certificate authorities of its members, the crypto suite, the consensus
+// plugin and its parameters, and the chaincodes deployed by the genesis block. A peer
+// bootstrapped from a definition builds the genesis block from it instead of
+// from the genesisBlock section of openchain.yaml, and stores the definition
+// in the consensus metadata of that block.
+type NetworkDefinition struct {
+	Name             string                `yaml:"name"`
+	Validators       []ValidatorDefinition `yaml:"validators"`
+	CAs              CADefinition          `yaml:"cas"`
+	CryptoSuite      string                `yaml:"cryptoSuite,omitempty"`
+	Consensus        ConsensusDefinition   `yaml:"consensus"`
+	SystemChaincodes []string              `yaml:"systemChaincodes"`
+	Chaincodes       []ChaincodeDefinition `yaml:"chaincodes"`
+}
+
+// ValidatorDefinition is a validating peer of the network.
+type ValidatorDefinition struct {
+	ID      string `yaml:"id"`
+	Address string `yaml:"address"`
+}
+
+// CADefinition holds the addresses of the ECA, TCA and TLSCA.
+type CADefinition struct {
+	ECA   string `yaml:"eca"`
+	TCA   string `yaml:"tca"`
+	TLSCA string `yaml:"tlsca"`
+}
+
+// ConsensusDefinition names the consensus plugin and overrides entries of its
+// configuration, eg. general.K for obcpbft.
+type ConsensusDefinition struct {
+	Plugin string            `yaml:"plugin"`
+	Params map[string]string `yaml:"params"`
+}
+
+// ChaincodeDefinition is a chaincode deployed by the genesis block.
+type ChaincodeDefinition struct {
+	Path        string                 `yaml:"path"`
+	Type        string                 `yaml:"type"`
+	Constructor *ConstructorDefinition `yaml:"constructor"`
+}
+
+// ConstructorDefinition is the constructor message of a genesis chaincode.
+type ConstructorDefinition struct {
+	Func string   `yaml:"func"`
+	Args []string `yaml:"args"`
+}
+
+// LoadNetworkDefinition reads and validates the network definition in the
+// YAML file at path.
+func LoadNetworkDefinition(path string) (*NetworkDefinition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading network definition %s: %s", path, err)
+	}
+	network := &NetworkDefinition{}
+	if err = yaml.Unmarshal(data, network); err != nil {
+		return nil, fmt.Errorf("Error parsing network definition %s: %s", path, err)
+	}
+	if err = network.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid network definition %s: %s", path, err)
+	}
+	return network, nil
+}
+
+// GetNetworkDefinition returns the network definition configured by
+// ledger.blockchain.genesisNetwork, or nil if none is configured.
+func GetNetworkDefinition() (*NetworkDefinition, error) {
+	path := viper.GetString("ledger.blockchain.genesisNetwork")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadNetworkDefinition(path)
+}
+
+// Validate checks that the definition is complete and consistent.
+func (network *NetworkDefinition) Validate() error {
+	if network.Name == "" {
+		return fmt.Errorf("The network has no name")
+	}
+	if len(network.Validators) == 0 {
+		return fmt.Errorf("The network has no validators")
+	}
+	ids := make(map[string]bool)
+	for i, validator := range network.Validators {
+		if validator.ID == "" || validator.Address == "" {
+			return fmt.Errorf("Validator %d needs both an id and an address", i)
+		}
+		if ids[validator.ID] {
+			return fmt.Errorf("Validator %s is defined more than once", validator.ID)
+		}
+		ids[validator.ID] = true
+	}
+	if network.CryptoSuite != "" {
+		if _, err := conf.GetCryptoSuiteByName(network.CryptoSuite); err != nil {
+			return err
+		}
+	}
+	switch network.Consensus.Plugin {
+	case "noops":
+		if len(network.Validators) != 1 {
+			return fmt.Errorf("The noops consensus needs exactly 1 validator, got %d", len(network.Validators))
+		}
+	case "obcpbft":
+		if n, ok := network.Consensus.Params["general.N"]; ok && n != strconv.Itoa(len(network.Validators)) {
+			return fmt.Errorf("The obcpbft parameter general.N is %s, but the network has %d validators", n, len(network.Validators))
+		}
+	default:
+		return fmt.Errorf("Unknown consensus plugin %q", network.Consensus.Plugin)
+	}
+	for i, cc := range network.Chaincodes {
+		if cc.Path == "" {
+			return fmt.Errorf("Chaincode %d has no path", i)
+		}
+		if _, ok := protos.ChaincodeSpec_Type_value[cc.Type]; !ok {
+			return fmt.Errorf("Chaincode %s has invalid type %q", cc.Path, cc.Type)
+		}
+	}
+	return nil
+}
+
+// Bytes returns the canonical serialization of the definition, which is
+// stored in the genesis block.
+func (network *NetworkDefinition) Bytes() ([]byte, error) {
+	return yaml.Marshal(network)
+}
+
+// Hash returns the hash of the canonical serialization of the definition.
+func (network *NetworkDefinition) Hash() ([]byte, error) {
+	data, err := network.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return util.ComputeCryptoHash(data), nil
+}
+
+// Apply overrides the configuration of this peer with the definition, so
+// that it joins the network as described. The peer validates if its
+// peer.id is one of the validators, and discovers the network through the
+// first other validator. The consensus parameters override the plugin
+// configuration through its environment variables.
+func (network *NetworkDefinition) Apply() {
+	self := viper.GetString("peer.id")
+	viper.Set("peer.networkId", network.Name)
+	viper.Set("peer.validator.enabled", false)
+	rootNode := ""
+	for _, validator := range network.Validators {
+		if validator.ID == self {
+			viper.Set("peer.validator.enabled", true)
+		} else if rootNode == "" {
+			rootNode = validator.Address
+		}
+	}
+	viper.Set("peer.discovery.rootnode", rootNode)
+
+	if network.CAs.ECA != "" {
+		viper.Set("peer.pki.eca.paddr", network.CAs.ECA)
+	}
+	if network.CAs.TCA != "" {
+		viper.Set("peer.pki.tca.paddr", network.CAs.TCA)
+	}
+	if network.CAs.TLSCA != "" {
+		viper.Set("peer.pki.tlsca.paddr", network.CAs.TLSCA)
+	}
+
+	if network.CryptoSuite != "" {
+		viper.Set("security.suite", network.CryptoSuite)
+	}
+
+	viper.Set("peer.validator.consensus", network.Consensus.Plugin)
+	params := make(map[string]string)
+	if network.Consensus.Plugin == "obcpbft" {
+		params["general.N"] = strconv.Itoa(len(network.Validators))
+	}
+	for key, value := range network.Consensus.Params {
+		params[key] = value
+	}
+	replacer := strings.NewReplacer(".", "_")
+	for key, value := range params {
+		os.Setenv(strings.ToUpper("openchain_"+network.Consensus.Plugin+"_"+replacer.Replace(key)), value)
+	}
+
+	viper.Set("chaincode.system", network.SystemChaincodes)
+}
+
+// ApplyNetworkDefinition loads the network definition configured by
+// ledger.blockchain.genesisNetwork, if any, and applies it to the
+// configuration of this peer.
+func ApplyNetworkDefinition() error {
+	network, err := GetNetworkDefinition()
+	if err != nil || network == nil {
+		return err
+	}
+	genesisLogger.Info("Bootstrapping from network definition %s", network.Name)
+	if err = network.checkCryptoSuite(); err != nil {
+		return err
+	}
+	network.Apply()
+	return nil
+}
+
+func (network *NetworkDefinition) deployChaincodes() ([]*protos.Transaction, error) {
+	var transactions []*protos.Transaction
+	for _, cc := range network.Chaincodes {
+		spec := &protos.ChaincodeSpec{Type: protos.ChaincodeSpec_Type(protos.ChaincodeSpec_Type_value[cc.Type]),
+			ChaincodeID: &protos.ChaincodeID{Path: cc.Path}}
+		if cc.Constructor != nil {
+			spec.CtorMsg = &protos.ChaincodeInput{Function: cc.Constructor.Func, Args: cc.Constructor.Args}
+		}
+		transaction, _, err := DeployLocal(context.Background(), spec)
+		if err != nil {
+			return nil, fmt.Errorf("Error deploying genesis chaincode %s: %s", cc.Path, err)
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// checkGenesisBlock verifies that the existing genesis block was built from
+// the definition, so a peer can't be restarted into a different network.
+func (network *NetworkDefinition) checkGenesisBlock(ledger *ledger.Ledger) error {
+	block, err := ledger.GetBlockByNumber(0)
+	if err != nil {
+		return fmt.Errorf("Error reading genesis block: %s", err)
+	}
+	data, err := network.Bytes()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(block.ConsensusMetadata, data) {
+		return fmt.Errorf("The genesis block was not built from network definition %s", network.Name)
+	}
+	return nil
+}
+
+// checkCryptoSuite verifies that the crypto layer of this peer has been
+// initialized with the crypto suite of the network.
+func (network *NetworkDefinition) checkCryptoSuite() error {
+	if network.CryptoSuite == "" {
+		return nil
+	}
+	if suite := conf.GetCryptoSuite(); suite != nil && suite.Name() != network.CryptoSuite {
+		return fmt.Errorf("Network %s uses crypto suite %s, but this peer uses %s; set security.suite", network.Name, network.CryptoSuite, suite.Name())
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package genesis
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+)
+
+func TestLoadNetworkDefinition(t *testing.T) {
+	network, err := LoadNetworkDefinition("network_test.yaml")
+	if err != nil {
+		t.Fatalf("Error loading network definition: %s", err)
+	}
+	if network.Name != "testnet" || len(network.Validators) != 4 || network.Consensus.Plugin != "obcpbft" {
+		t.Fatalf("Unexpected network definition: %+v", network)
+	}
+	if network.Chaincodes[0].Constructor == nil || len(network.Chaincodes[0].Constructor.Args) != 4 {
+		t.Fatalf("Unexpected genesis chaincode: %+v", network.Chaincodes[0])
+	}
+
+	hash, err := network.Hash()
+	if err != nil {
+		t.Fatalf("Error hashing network definition: %s", err)
+	}
+	again, _ := LoadNetworkDefinition("network_test.yaml")
+	againHash, _ := again.Hash()
+	if !bytes.Equal(hash, againHash) {
+		t.Fatalf("Expected the same hash for the same definition, got %x and %x", hash, againHash)
+	}
+	again.Validators[3].Address = "172.17.0.6:30303"
+	if againHash, _ = again.Hash(); bytes.Equal(hash, againHash) {
+		t.Fatal("Expected a different hash for a different definition")
+	}
+
+	if _, err = LoadNetworkDefinition("missing.yaml"); err == nil {
+		t.Fatal("Expected an error loading a missing network definition")
+	}
+}
+
+func TestNetworkDefinitionValidate(t *testing.T) {
+	valid := func() *NetworkDefinition {
+		return &NetworkDefinition{
+			Name:       "net",
+			Validators: []ValidatorDefinition{{"vp0", "a:1"}, {"vp1", "b:1"}, {"vp2", "c:1"}, {"vp3", "d:1"}},
+			Consensus:  ConsensusDefinition{Plugin: "obcpbft"},
+			Chaincodes: []ChaincodeDefinition{{Path: "example", Type: "GOLANG"}},
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected a valid definition, got %s", err)
+	}
+
+	tests := map[string]func(*NetworkDefinition){
+		"no name":            func(n *NetworkDefinition) { n.Name = "" },
+		"no validators":      func(n *NetworkDefinition) { n.Validators = nil },
+		"no address":         func(n *NetworkDefinition) { n.Validators[1].Address = "" },
+		"duplicate id":       func(n *NetworkDefinition) { n.Validators[1].ID = "vp0" },
+		"unknown plugin":     func(n *NetworkDefinition) { n.Consensus.Plugin = "raft" },
+		"noops validators":   func(n *NetworkDefinition) { n.Consensus.Plugin = "noops" },
+		"wrong N":            func(n *NetworkDefinition) { n.Consensus.Params = map[string]string{"general.N": "7"} },
+		"no chaincode path":  func(n *NetworkDefinition) { n.Chaincodes[0].Path = "" },
+		"bad chaincode type": func(n *NetworkDefinition) { n.Chaincodes[0].Type = "COBOL" },
+		"bad crypto suite":   func(n *NetworkDefinition) { n.CryptoSuite = "RSA-SHA1" },
+	}
+	for name, breakIt := range tests {
+		network := valid()
+		breakIt(network)
+		if err := network.Validate(); err == nil {
+			t.Errorf("Expected an error validating a definition with %s", name)
+		}
+	}
+}
+
+func TestNetworkDefinitionApply(t *testing.T) {
+	network, err := LoadNetworkDefinition("network_test.yaml")
+	if err != nil {
+		t.Fatalf("Error loading network definition: %s", err)
+	}
+	defer os.Unsetenv("OPENCHAIN_OBCPBFT_GENERAL_N")
+	defer os.Unsetenv("OPENCHAIN_OBCPBFT_GENERAL_K")
+	defer os.Unsetenv("OPENCHAIN_OBCPBFT_GENERAL_MODE")
+
+	viper.Set("peer.id", "vp0")
+	network.Apply()
+	if !viper.GetBool("peer.validator.enabled") {
+		t.Fatal("Expected vp0 to be a validator")
+	}
+	if rootNode := viper.GetString("peer.discovery.rootnode"); rootNode != "172.17.0.3:30303" {
+		t.Fatalf("Expected vp1 as root node of vp0, got %s", rootNode)
+	}
+	if networkID := viper.GetString("peer.networkId"); networkID != "testnet" {
+		t.Fatalf("Expected network id testnet, got %s", networkID)
+	}
+	if consensus := viper.GetString("peer.validator.consensus"); consensus != "obcpbft" {
+		t.Fatalf("Expected obcpbft consensus, got %s", consensus)
+	}
+	if eca := viper.GetString("peer.pki.eca.paddr"); eca != "172.17.0.10:50051" {
+		t.Fatalf("Expected the ECA of the definition, got %s", eca)
+	}
+	if suite := viper.GetString("security.suite"); suite != "ECDSA-P256-SHA3" {
+		t.Fatalf("Expected the crypto suite of the definition, got %s", suite)
+	}
+	if system := viper.GetStringSlice("chaincode.system"); len(system) != 1 || system[0] != "noop" {
+		t.Fatalf("Expected the system chaincodes of the definition, got %v", system)
+	}
+	if n := os.Getenv("OPENCHAIN_OBCPBFT_GENERAL_N"); n != "4" {
+		t.Fatalf("Expected general.N of 4, got %s", n)
+	}
+	if k := os.Getenv("OPENCHAIN_OBCPBFT_GENERAL_K"); k != "10" {
+		t.Fatalf("Expected general.K of 10, got %s", k)
+	}
+
+	viper.Set("peer.id", "vp1")
+	network.Apply()
+	if rootNode := viper.GetString("peer.discovery.rootnode"); rootNode != "172.17.0.2:30303" {
+		t.Fatalf("Expected vp0 as root node of vp1, got %s", rootNode)
+	}
+
+	viper.Set("peer.id", "nvp0")
+	network.Apply()
+	if viper.GetBool("peer.validator.enabled") {
+		t.Fatal("Expected nvp0 not to be a validator")
+	}
+}
+
+func TestNetworkDefinitionCryptoSuite(t *testing.T) {
+	conf.InitSecurityLevel(256)
+
+	network, err := LoadNetworkDefinition("network_test.yaml")
+	if err != nil {
+		t.Fatalf("Error loading network definition: %s", err)
+	}
+	if err = network.checkCryptoSuite(); err != nil {
+		t.Fatalf("Expected the crypto suite of security level 256, got %s", err)
+	}
+
+	network.CryptoSuite = conf.SuiteP256SHA2
+	if err = network.checkCryptoSuite(); err == nil {
+		t.Fatal("Expected an error joining a network with a different crypto suite")
+	}
+}
+
+func TestNetworkGenesisBlock(t *testing.T) {
+	network, err := LoadNetworkDefinition("network_test.yaml")
+	if err != nil {
+		t.Fatalf("Error loading network definition: %s", err)
+	}
+	// Deploying the genesis chaincodes needs a VM, this test is about the
+	// definition stored in the block
+	network.Chaincodes = nil
+
+	ledger := ledger.InitTestLedger(t)
+	if err = makeNetworkGenesis(ledger, network); err != nil {
+		t.Fatalf("Error creating genesis block: %s", err)
+	}
+	if ledger.GetBlockchainSize() != 1 {
+		t.Fatalf("Expected blockchain size of 1, but got %d", ledger.GetBlockchainSize())
+	}
+	if err = network.checkGenesisBlock(ledger); err != nil {
+		t.Fatalf("Expected the genesis block to match the definition: %s", err)
+	}
+
+	network.Consensus.Params["general.K"] = "20"
+	if err = network.checkGenesisBlock(ledger); err == nil {
+		t.Fatal("Expected an error checking the genesis block against a different definition")
+	}
+}
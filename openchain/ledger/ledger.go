@@ -25,13 +25,16 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
 	"github.com/openblockchain/obc-peer/events/producer"
 	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/fault"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt/state"
+	"github.com/spf13/viper"
 	"github.com/tecbot/gorocksdb"
 
 	"github.com/openblockchain/obc-peer/protos"
@@ -46,13 +49,29 @@ var (
 
 	// ErrResourceNotFound is returned if a resource is not found
 	ErrResourceNotFound = errors.New("ledger: resource not found")
+
+	// ErrReadSnapshotReleased is returned when reading a released ReadSnapshot
+	ErrReadSnapshotReleased = errors.New("ledger: read snapshot released")
 )
 
 // Ledger - the struct for openchain ledger
 type Ledger struct {
+	chainID    string
 	blockchain *blockchain
 	state      *state.State
 	currentID  interface{}
+
+	// replayLock is held from BeginReplay to the rollback of the replay
+	replayLock sync.Mutex
+	replayID   interface{}
+
+	// quorumCertLock serializes the updates of the quorum certificates
+	quorumCertLock sync.Mutex
+
+	// outOfSync holds why the chain no longer follows the default chain,
+	// empty while it does
+	outOfSyncLock sync.RWMutex
+	outOfSync     string
 }
 
 var ledger *Ledger
@@ -67,21 +86,108 @@ func GetLedger() (*Ledger, error) {
 	return ledger, ledgerError
 }
 
+var chainLedgers = make(map[string]*Ledger)
+var chainLedgersLock sync.Mutex
+
+// GetChainLedger gives a reference to the 'singleton' ledger of a chain. The
+// ledger of the default chain, whose ID is empty, is the one returned by
+// GetLedger. The ledgers of the other chains are kept in their own DBs, which
+// only CreateChainLedger creates: it fails for the chains not created yet.
+func GetChainLedger(chainID string) (*Ledger, error) {
+	return getChainLedger(chainID, false)
+}
+
+// CreateChainLedger gives a reference to the ledger of a chain, creating its
+// DB if the chain does not exist yet. It is only called for the chains
+// created by configuration transactions, so that every peer creates the same
+// chains at the same block.
+func CreateChainLedger(chainID string) (*Ledger, error) {
+	return getChainLedger(chainID, true)
+}
+
+func getChainLedger(chainID string, create bool) (*Ledger, error) {
+	if chainID == "" {
+		return GetLedger()
+	}
+	if err := db.CheckChainID(chainID); err != nil {
+		return nil, err
+	}
+	chainLedgersLock.Lock()
+	defer chainLedgersLock.Unlock()
+	if chainLedger, ok := chainLedgers[chainID]; ok {
+		return chainLedger, nil
+	}
+	if !create {
+		exists, err := db.ChainDBExists(chainID)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("Chain %s does not exist", chainID)
+		}
+	}
+	chainLedger, err := newChainLedger(chainID)
+	if err != nil {
+		return nil, err
+	}
+	chainLedgers[chainID] = chainLedger
+	return chainLedger, nil
+}
+
+// GetChainIDs returns the IDs of the chains, other than the default chain,
+// the peer holds a ledger for
+func GetChainIDs() ([]string, error) {
+	return db.GetChainIDs()
+}
+
 func newLedger() (*Ledger, error) {
-	blockchain, err := newBlockchain()
+	return newChainLedger("")
+}
+
+func newChainLedger(chainID string) (*Ledger, error) {
+	blockchain, err := newChainBlockchain(chainID)
 	if err != nil {
 		return nil, err
 	}
 
-	state := state.NewState()
-	return &Ledger{blockchain, state, nil}, nil
+	state := state.NewChainState(chainID)
+	ledger := &Ledger{chainID: chainID, blockchain: blockchain, state: state}
+	if ledger.outOfSync, err = readOutOfSync(chainID); err != nil {
+		return nil, err
+	}
+	ledger.setBlockHeightMetric()
+	if viper.GetBool("ledger.archive.enabled") {
+		ledger.startArchiver()
+	}
+	return ledger, nil
+}
+
+// GetChainID returns the ID of the chain of the ledger. The default chain has
+// the empty ID.
+func (ledger *Ledger) GetChainID() string {
+	return ledger.chainID
+}
+
+func (ledger *Ledger) openchainDB() *db.OpenchainDB {
+	return ledger.blockchain.openchainDB()
+}
+
+// setBlockHeightMetric updates the block height metric, which only tracks
+// the default chain
+func (ledger *Ledger) setBlockHeightMetric() {
+	if ledger.chainID == "" {
+		blockHeightMetric.Set(int64(ledger.blockchain.getSize()))
+	}
 }
 
 /////////////////// Transaction-batch related methods ///////////////////////////////
 /////////////////////////////////////////////////////////////////////////////////////
 
-// BeginTxBatch - gets invoked when next round of transaction-batch execution begins
+// BeginTxBatch - gets invoked when next round of transaction-batch execution begins.
+// It waits for a replay in progress to end.
 func (ledger *Ledger) BeginTxBatch(id interface{}) error {
+	ledger.replayLock.Lock()
+	ledger.replayLock.Unlock()
 	err := ledger.checkValidIDBegin()
 	if err != nil {
 		return err
@@ -116,6 +222,15 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	if err != nil {
 		return err
 	}
+	if ledger.replayID != nil {
+		return fmt.Errorf("Replay [%s] cannot be committed", id)
+	}
+	start := time.Now()
+	if err = fault.Inject(protos.Fault_LEDGER_COMMIT, ledger.chainID); err != nil {
+		ledger.resetForNextTxGroup(false)
+		ledger.blockchain.blockPersistenceStatus(false)
+		return err
+	}
 
 	stateHash, err := ledger.state.GetHash()
 	if err != nil {
@@ -137,15 +252,21 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	ledger.state.AddChangesForPersistence(newBlockNumber, writeBatch)
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
+	dbErr := ledger.openchainDB().DB.Write(opt, writeBatch)
 	if dbErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return dbErr
 	}
 
+	if ledger.chainID == "" {
+		stateSizes.blockCommitted(newBlockNumber, ledger.state.GetStateDelta())
+	}
 	ledger.resetForNextTxGroup(true)
 	ledger.blockchain.blockPersistenceStatus(true)
+	ledger.setBlockHeightMetric()
+	blockTransactionsMetric.Observe(float64(len(transactions)))
+	blockCommitDurationMetric.Observe(float64(time.Since(start)) / float64(time.Millisecond))
 
 	sendProducerBlockEvent(block)
 	return nil
@@ -160,6 +281,10 @@ func (ledger *Ledger) RollbackTxBatch(id interface{}) error {
 		return err
 	}
 	ledger.resetForNextTxGroup(false)
+	if ledger.replayID != nil {
+		ledger.replayID = nil
+		ledger.replayLock.Unlock()
+	}
 	return nil
 }
 
@@ -194,6 +319,7 @@ func (ledger *Ledger) GetTempStateHashWithTxDeltaStateHashes() ([]byte, map[stri
 // GetState get state for chaincodeID and key. If committed is false, this first looks in memory
 // and if missing, pulls from db.  If committed is true, this pulls from the db only.
 func (ledger *Ledger) GetState(chaincodeID string, key string, committed bool) ([]byte, error) {
+	stateReadsMetric.Inc()
 	return ledger.state.Get(chaincodeID, key, committed)
 }
 
@@ -206,13 +332,35 @@ func (ledger *Ledger) GetStateRangeScanIterator(chaincodeID string, startKey str
 	return ledger.state.GetRangeScanIterator(chaincodeID, startKey, endKey, committed)
 }
 
+// GetTxStateRangeScanIterator returns an iterator like GetStateRangeScanIterator with committed set to false,
+// giving preference to the given state changes of a transaction that are buffered outside the ledger
+func (ledger *Ledger) GetTxStateRangeScanIterator(txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return ledger.state.GetTxRangeScanIterator(txDelta, chaincodeID, startKey, endKey)
+}
+
+// GetStateRangeScanSnapshotIterator returns an iterator like GetStateRangeScanIterator with committed set
+// to true, pinned to a snapshot of the state taken when it is created. The keys and values it returns are
+// those of that snapshot however long the iterator is used, and closing it releases the snapshot.
+func (ledger *Ledger) GetStateRangeScanSnapshotIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return ledger.getRangeScanSnapshotIterator(nil, chaincodeID, startKey, endKey)
+}
+
+// GetTxStateRangeScanSnapshotIterator returns an iterator like GetTxStateRangeScanIterator, pinned to a
+// snapshot of the state and of the state changes of the transaction taken when it is created, like
+// GetStateRangeScanSnapshotIterator
+func (ledger *Ledger) GetTxStateRangeScanSnapshotIterator(txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return ledger.getRangeScanSnapshotIterator(txDelta, chaincodeID, startKey, endKey)
+}
+
 // SetState sets state to given value for chaincodeID and key. Does not immideatly writes to DB
 func (ledger *Ledger) SetState(chaincodeID string, key string, value []byte) error {
+	stateWritesMetric.Inc()
 	return ledger.state.Set(chaincodeID, key, value)
 }
 
 // DeleteState tracks the deletion of state for chaincodeID and key. Does not immideatly writes to DB
 func (ledger *Ledger) DeleteState(chaincodeID string, key string) error {
+	stateWritesMetric.Inc()
 	return ledger.state.Delete(chaincodeID, key)
 }
 
@@ -220,8 +368,9 @@ func (ledger *Ledger) DeleteState(chaincodeID string, key string) error {
 // should be used when transfering the state from one peer to another peer. You must call
 // stateSnapshot.Release() once you are done with the snapsnot to free up resources.
 func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
-	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	openchainDB := ledger.openchainDB()
+	dbSnapshot := openchainDB.GetSnapshot()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(openchainDB, dbSnapshot)
 	if err != nil {
 		dbSnapshot.Release()
 		return nil, err
@@ -233,6 +382,49 @@ func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
 	return ledger.state.GetSnapshot(blockHeight-1, dbSnapshot)
 }
 
+// GetStateProof returns the committed value of a key along with its proof
+// against the state hash of the block at the head of the chain. The proof can
+// be checked with VerifyStateProof by peers that do not host the chain.
+func (ledger *Ledger) GetStateProof(chaincodeID string, key string) (*protos.StateProof, error) {
+	openchainDB := ledger.openchainDB()
+	dbSnapshot := openchainDB.GetSnapshot()
+	defer dbSnapshot.Release()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(openchainDB, dbSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	if 0 == blockHeight {
+		return nil, fmt.Errorf("Blockchain has no blocks, cannot determine block number")
+	}
+	block, err := ledger.GetBlockByNumber(blockHeight - 1)
+	if err != nil {
+		return nil, err
+	}
+	value, proof, err := ledger.state.GetStateProof(dbSnapshot, chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.StateProof{ChainID: ledger.chainID, ChaincodeID: chaincodeID, Key: key, Value: value,
+		BlockNumber: blockHeight - 1, StateHash: block.StateHash, Proof: proof}, nil
+}
+
+// VerifyStateProof checks that the value of a state proof, possibly returned
+// by another peer, is the value of the key in the state with the state hash of
+// the proof. If the proof is for the chain of the ledger, the state hash is
+// checked against the block of the proof as well.
+func (ledger *Ledger) VerifyStateProof(stateProof *protos.StateProof) error {
+	if stateProof.ChainID == ledger.chainID {
+		block, err := ledger.GetBlockByNumber(stateProof.BlockNumber)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(block.StateHash, stateProof.StateHash) {
+			return fmt.Errorf("State hash of proof does not match the state hash of block %d", stateProof.BlockNumber)
+		}
+	}
+	return ledger.state.VerifyStateProof(stateProof.StateHash, stateProof.ChaincodeID, stateProof.Key, stateProof.Value, stateProof.Proof)
+}
+
 // GetStateDelta will return the state delta for the specified block if
 // available.
 func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
@@ -278,6 +470,7 @@ func (ledger *Ledger) CommitStateDelta(id interface{}) error {
 		return err
 	}
 	defer ledger.resetForNextTxGroup(true)
+	defer stateSizes.reset()
 	return ledger.state.CommitStateDelta()
 }
 
@@ -296,6 +489,7 @@ func (ledger *Ledger) RollbackStateDelta(id interface{}) error {
 // This is generally only used during state synchronization when creating a
 // new state from a snapshot.
 func (ledger *Ledger) DeleteALLStateKeysAndValues() error {
+	defer stateSizes.reset()
 	return ledger.state.DeleteState()
 }
 
@@ -327,6 +521,27 @@ func (ledger *Ledger) GetTransactionByUUID(txUUID string) (*protos.Transaction,
 	return ledger.blockchain.getTransactionByUUID(txUUID)
 }
 
+// GetTransactionResultByUUID returns the result recorded for a committed
+// transaction by its uuid
+func (ledger *Ledger) GetTransactionResultByUUID(txUUID string) (*protos.TransactionResult, error) {
+	return ledger.blockchain.getTransactionResultByUUID(txUUID)
+}
+
+// GetTransactionProof returns the proof that a committed transaction is in
+// its block, to be checked against the header of the block
+func (ledger *Ledger) GetTransactionProof(txUUID string) (*protos.TransactionProof, error) {
+	proof, err := ledger.blockchain.getTransactionProof(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	header, err := ledger.GetBlockHeader(proof.Header.Number)
+	if err != nil {
+		return nil, err
+	}
+	proof.Header.Signatures = header.Signatures
+	return proof, nil
+}
+
 // PutRawBlock puts a raw block on the chain. This function should only be
 // used for synchronization between peers.
 func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error {
@@ -334,6 +549,7 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 	if err != nil {
 		return err
 	}
+	ledger.setBlockHeightMetric()
 	sendProducerBlockEvent(block)
 	return nil
 }
@@ -21,6 +21,7 @@ package ledger
 
 import (
 	"bytes"
+	"errors"
 	"strconv"
 	"testing"
 
@@ -716,7 +717,8 @@ func TestTransactionResult(t *testing.T) {
 	ledger.TxFinished("txUuid1", true)
 	transaction, uuid := buildTestTx(t)
 
-	transactionResult := &protos.TransactionResult{Uuid: uuid, ErrorCode: 500, Error: "bad"}
+	events := []*protos.ChaincodeEvent{&protos.ChaincodeEvent{ChaincodeID: "chaincode1", EventName: "event1", Payload: []byte("payload")}}
+	transactionResult := &protos.TransactionResult{Uuid: uuid, ErrorCode: 500, Error: "bad", Result: []byte("response"), Events: events, WriteSetHash: []byte("hash")}
 
 	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, []*protos.TransactionResult{transactionResult}, []byte("proof"))
 
@@ -735,6 +737,43 @@ func TestTransactionResult(t *testing.T) {
 	testutil.AssertEquals(t, nonHashData.TransactionResults[0].Error, "bad")
 	testutil.AssertEquals(t, nonHashData.TransactionResults[0].ErrorCode, uint32(500))
 
+	result, err := ledger.GetTransactionResultByUUID(uuid)
+	testutil.AssertNoError(t, err, "Error fetching the transaction result")
+	testutil.AssertEquals(t, result.Result, []byte("response"))
+	testutil.AssertEquals(t, result.WriteSetHash, []byte("hash"))
+	testutil.AssertEquals(t, len(result.Events), 1)
+	testutil.AssertEquals(t, result.Events[0].EventName, "event1")
+
+	_, err = ledger.GetTransactionResultByUUID("unknown")
+	testutil.AssertError(t, err, "Expected an error for an unknown transaction")
+}
+
+func TestGetTransactionProof(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	transaction1, uuid1 := buildTestTx(t)
+	transaction2, uuid2 := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction1, transaction2}, nil, []byte("proof"))
+
+	proof, err := ledger.GetTransactionProof(uuid2)
+	testutil.AssertNoError(t, err, "Error fetching the transaction proof")
+	testutil.AssertEquals(t, proof.Transaction.Uuid, uuid2)
+	testutil.AssertEquals(t, proof.Index, uint32(1))
+	block := ledgerTestWrapper.GetBlockByNumber(0)
+	blockHash, _ := block.GetHash()
+	testutil.AssertEquals(t, proof.Header.Hash, blockHash)
+
+	proof, err = ledger.GetTransactionProof(uuid1)
+	testutil.AssertNoError(t, err, "Error fetching the transaction proof")
+	testutil.AssertEquals(t, proof.Index, uint32(0))
+
+	_, err = ledger.GetTransactionProof("unknown")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
 }
 
 func TestRangeScanIterator(t *testing.T) {
@@ -860,3 +899,114 @@ func TestRangeScanIterator(t *testing.T) {
 		})
 	itr.Close()
 }
+
+func TestRangeScanSnapshotIterator(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincodeID1", "key1", []byte("value1"))
+	ledger.SetState("chaincodeID1", "key2", []byte("value2"))
+	ledger.SetState("chaincodeID1", "key3", []byte("value3"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	txDelta := statemgmt.NewStateDelta()
+	txDelta.Set("chaincodeID1", "key4", []byte("value4"), nil)
+	itr, _ := ledger.GetStateRangeScanSnapshotIterator("chaincodeID1", "", "")
+	txItr, _ := ledger.GetTxStateRangeScanSnapshotIterator(txDelta, "chaincodeID1", "", "")
+
+	// Changes made after the iterators are created are not seen
+	txDelta.Set("chaincodeID1", "key4", []byte("value4_new"), nil)
+	txDelta.Delete("chaincodeID1", "key3", nil)
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincodeID1", "key1", []byte("value1_new"))
+	ledger.DeleteState("chaincodeID1", "key2")
+	ledger.SetState("chaincodeID1", "key5", []byte("value5"))
+	ledger.TxFinished("txUuid2", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	statemgmt.AssertIteratorContains(t, itr,
+		map[string][]byte{
+			"key1": []byte("value1"),
+			"key2": []byte("value2"),
+			"key3": []byte("value3"),
+		})
+	itr.Close()
+	statemgmt.AssertIteratorContains(t, txItr,
+		map[string][]byte{
+			"key1": []byte("value1"),
+			"key2": []byte("value2"),
+			"key3": []byte("value3"),
+			"key4": []byte("value4"),
+		})
+	txItr.Close()
+
+	// New iterators see the changes
+	itr, _ = ledger.GetTxStateRangeScanSnapshotIterator(txDelta, "chaincodeID1", "", "")
+	statemgmt.AssertIteratorContains(t, itr,
+		map[string][]byte{
+			"key1": []byte("value1_new"),
+			"key4": []byte("value4_new"),
+			"key5": []byte("value5"),
+		})
+	itr.Close()
+}
+
+func TestChainLedgers(t *testing.T) {
+	defaultLedger := InitTestLedger(t)
+	_, err := GetChainLedger("chain1")
+	testutil.AssertError(t, err, "Expected an error for a chain not created")
+	chainLedger, err := CreateChainLedger("chain1")
+	testutil.AssertNoError(t, err, "Error while creating the ledger of the chain")
+	testutil.AssertSame(t, getChainLedgerOrFail(t, "chain1"), chainLedger)
+	testutil.AssertSame(t, getChainLedgerOrFail(t, ""), defaultLedger)
+	testutil.AssertEquals(t, chainLedger.GetChainID(), "chain1")
+
+	chainLedger.BeginTxBatch(1)
+	chainLedger.TxBegin("txUuid")
+	chainLedger.SetState("chaincode1", "key1", []byte("value1"))
+	chainLedger.TxFinished("txUuid", true)
+	transaction, uuid := buildTestTx(t)
+	err = chainLedger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	testutil.AssertNoError(t, err, "Error while committing the batch on the chain")
+
+	value, err := chainLedger.GetState("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state from the ledger of the chain")
+	testutil.AssertEquals(t, value, []byte("value1"))
+	testutil.AssertEquals(t, chainLedger.GetBlockchainSize(), uint64(1))
+	_, err = chainLedger.GetTransactionByUUID(uuid)
+	testutil.AssertNoError(t, err, "Error fetching transaction by UUID on the chain")
+
+	value, err = defaultLedger.GetState("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state from the default ledger")
+	testutil.AssertNil(t, value)
+	testutil.AssertEquals(t, defaultLedger.GetBlockchainSize(), uint64(0))
+	_, err = defaultLedger.GetTransactionByUUID(uuid)
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+
+	chainIDs, err := GetChainIDs()
+	testutil.AssertNoError(t, err, "Error while listing the chains")
+	testutil.AssertEquals(t, chainIDs, []string{"chain1"})
+
+	_, err = CreateChainLedger("../chain")
+	testutil.AssertError(t, err, "Expected an error for an invalid chain ID")
+
+	testutil.AssertEquals(t, chainLedger.OutOfSync(), "")
+	testutil.AssertNoError(t, chainLedger.MarkOutOfSync(errors.New("commit failed")), "Error while marking the chain out of sync")
+	testutil.AssertEquals(t, chainLedger.OutOfSync(), "commit failed")
+	cause, err := readOutOfSync("chain1")
+	testutil.AssertNoError(t, err, "Error while reading the out of sync mark")
+	testutil.AssertEquals(t, cause, "commit failed")
+	testutil.AssertError(t, defaultLedger.MarkOutOfSync(errors.New("commit failed")), "Expected an error marking the default chain")
+}
+
+func getChainLedgerOrFail(t *testing.T, chainID string) *Ledger {
+	chainLedger, err := GetChainLedger(chainID)
+	testutil.AssertNoError(t, err, "Error while getting the ledger of the chain")
+	return chainLedger
+}
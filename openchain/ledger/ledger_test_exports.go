@@ -34,5 +34,6 @@ func InitTestLedger(t *testing.T) *Ledger {
 	newLedger, err := newLedger()
 	testutil.AssertNoError(t, err, "Error while constructing ledger")
 	ledger = newLedger
+	chainLedgers = make(map[string]*Ledger)
 	return newLedger
 }
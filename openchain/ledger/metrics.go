@@ -0,0 +1,187 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/openblockchain/obc-peer/openchain/metrics"
+	"github.com/tecbot/gorocksdb"
+)
+
+var (
+	blockHeightMetric         = metrics.NewGauge("ledger.blockHeight")
+	blockCommitDurationMetric = metrics.NewSummary("ledger.blockCommitMillis")
+	blockTransactionsMetric   = metrics.NewSummary("ledger.blockTransactions")
+	stateReadsMetric          = metrics.NewCounter("ledger.stateReads")
+	stateWritesMetric         = metrics.NewCounter("ledger.stateWrites")
+)
+
+func init() {
+	metrics.RegisterFunc("ledger.db", dbMetrics)
+	metrics.RegisterFunc("ledger.stateSize", func() interface{} {
+		ledger, err := GetLedger()
+		if err != nil {
+			return nil
+		}
+		return stateSizes.get(ledger)
+	})
+}
+
+// dbPropertyMetrics are the rocksdb properties reported for the DB. Properties
+// that the rocksdb version does not support are left out.
+var dbPropertyMetrics = []string{
+	"rocksdb.compaction-pending",
+	"rocksdb.num-running-compactions",
+	"rocksdb.is-write-stopped",
+	"rocksdb.actual-delayed-write-rate",
+	"rocksdb.estimate-live-data-size",
+}
+
+// dbColumnFamilyMetrics are the rocksdb properties reported for each column family
+var dbColumnFamilyMetrics = []string{
+	"rocksdb.estimate-num-keys",
+	"rocksdb.total-sst-files-size",
+}
+
+func dbMetrics() interface{} {
+	openchainDB := db.GetDBHandle()
+	values := make(map[string]uint64)
+	addDBProperty := func(name string, value string) {
+		if number, err := strconv.ParseUint(value, 10, 64); err == nil {
+			values[name] = number
+		}
+	}
+	for _, property := range dbPropertyMetrics {
+		addDBProperty(property, openchainDB.DB.GetProperty(property))
+	}
+	columnFamilies := map[string]*gorocksdb.ColumnFamilyHandle{
+		"blockchainCF": openchainDB.BlockchainCF,
+		"stateCF":      openchainDB.StateCF,
+		"stateDeltaCF": openchainDB.StateDeltaCF,
+		"indexesCF":    openchainDB.IndexesCF,
+	}
+	for name, cf := range columnFamilies {
+		for _, property := range dbColumnFamilyMetrics {
+			addDBProperty(name+"."+property, openchainDB.DB.GetPropertyCF(property, cf))
+		}
+	}
+	return values
+}
+
+// chaincodeStateSize is the size of the state of a chaincode
+type chaincodeStateSize struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// stateSizeTracker keeps the size of the state of each chaincode. The state
+// is scanned when the sizes are first read, and the sizes then follow the
+// state changes of the blocks committed after the scan.
+type stateSizeTracker struct {
+	sync.Mutex
+	scanned bool
+	// blockHeight is the number of blocks whose changes the sizes include
+	blockHeight uint64
+	sizes       map[string]*chaincodeStateSize
+}
+
+var stateSizes = &stateSizeTracker{}
+
+// get returns the size of the state of each chaincode, scanning the state if
+// the sizes are not known
+func (t *stateSizeTracker) get(ledger *Ledger) map[string]chaincodeStateSize {
+	t.Lock()
+	defer t.Unlock()
+	if !t.scanned {
+		if err := t.scan(ledger); err != nil {
+			ledgerLogger.Error("Error scanning state for metrics: %s", err)
+			return nil
+		}
+	}
+	sizes := make(map[string]chaincodeStateSize, len(t.sizes))
+	for chaincodeID, size := range t.sizes {
+		sizes[chaincodeID] = *size
+	}
+	return sizes
+}
+
+func (t *stateSizeTracker) scan(ledger *Ledger) error {
+	t.sizes = make(map[string]*chaincodeStateSize)
+	t.blockHeight = 0
+	if ledger.GetBlockchainSize() > 0 {
+		snapshot, err := ledger.GetStateSnapshot()
+		if err != nil {
+			return err
+		}
+		defer snapshot.Release()
+		for snapshot.Next() {
+			compositeKey, value := snapshot.GetRawKeyValue()
+			chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+			t.add(chaincodeID, key, value, 1)
+		}
+		t.blockHeight = snapshot.GetBlockNumber() + 1
+	}
+	t.scanned = true
+	return nil
+}
+
+func (t *stateSizeTracker) add(chaincodeID string, key string, value []byte, sign int64) {
+	size, ok := t.sizes[chaincodeID]
+	if !ok {
+		size = &chaincodeStateSize{}
+		t.sizes[chaincodeID] = size
+	}
+	size.Keys += sign
+	size.Bytes += sign * int64(len(key)+len(value))
+}
+
+// blockCommitted updates the sizes with the state changes of a block
+func (t *stateSizeTracker) blockCommitted(blockNumber uint64, delta *statemgmt.StateDelta) {
+	t.Lock()
+	defer t.Unlock()
+	// Blocks committed before the scan are included in it
+	if !t.scanned || blockNumber < t.blockHeight {
+		return
+	}
+	for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+			if previousValue := updatedValue.GetPreviousValue(); previousValue != nil {
+				t.add(chaincodeID, key, previousValue, -1)
+			}
+			if !updatedValue.IsDelete() {
+				t.add(chaincodeID, key, updatedValue.GetValue(), 1)
+			}
+		}
+	}
+	t.blockHeight = blockNumber + 1
+}
+
+// reset discards the sizes after the state is changed other than by
+// committing a block, so that the state is scanned again
+func (t *stateSizeTracker) reset() {
+	t.Lock()
+	defer t.Unlock()
+	t.scanned = false
+	t.sizes = nil
+}
@@ -0,0 +1,70 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestLedgerMetrics(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	stateSizes.reset()
+	commits := blockCommitDurationMetric.Values().Count
+
+	// Block 0
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.SetState("chaincode1", "key2", []byte("value2"))
+	ledger.SetState("chaincode2", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	testutil.AssertEquals(t, blockHeightMetric.Value(), int64(1))
+	testutil.AssertEquals(t, blockCommitDurationMetric.Values().Count, commits+1)
+	testutil.AssertEquals(t, blockTransactionsMetric.Values().Last, float64(1))
+
+	sizes := stateSizes.get(ledger)
+	testutil.AssertEquals(t, sizes["chaincode1"], chaincodeStateSize{Keys: 2, Bytes: 20})
+	testutil.AssertEquals(t, sizes["chaincode2"], chaincodeStateSize{Keys: 1, Bytes: 10})
+
+	// Block 1 follows the scan of the state
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "key1", []byte("longervalue1"))
+	ledger.DeleteState("chaincode1", "key2")
+	ledger.DeleteState("chaincode2", "key1")
+	ledger.TxFinished("txUuid2", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	testutil.AssertEquals(t, blockHeightMetric.Value(), int64(2))
+
+	sizes = stateSizes.get(ledger)
+	testutil.AssertEquals(t, sizes["chaincode1"], chaincodeStateSize{Keys: 1, Bytes: 16})
+	testutil.AssertEquals(t, sizes["chaincode2"], chaincodeStateSize{Keys: 0, Bytes: 0})
+
+	// A new scan agrees with the sizes followed from the blocks
+	stateSizes.reset()
+	testutil.AssertEquals(t, stateSizes.get(ledger)["chaincode1"], chaincodeStateSize{Keys: 1, Bytes: 16})
+}
@@ -0,0 +1,69 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+)
+
+// outOfSyncFile is the file of the directory of a chain recording why the
+// chain no longer follows the default chain
+const outOfSyncFile = "outofsync"
+
+// MarkOutOfSync records that the chain of the ledger no longer follows the
+// default chain, as a batch committed on the default chain failed to commit
+// on it. The mark is kept in the directory of the chain, so that the chain
+// stays out of sync after a restart, until it is restored from the backup of
+// a peer in sync and the mark is removed. The default chain cannot be marked.
+func (ledger *Ledger) MarkOutOfSync(cause error) error {
+	if ledger.chainID == "" {
+		return errors.New("The default chain cannot be marked out of sync")
+	}
+	ledger.outOfSyncLock.Lock()
+	defer ledger.outOfSyncLock.Unlock()
+	ledger.outOfSync = cause.Error()
+	return ioutil.WriteFile(filepath.Join(db.GetChainPath(ledger.chainID), outOfSyncFile), []byte(ledger.outOfSync), 0644)
+}
+
+// OutOfSync returns why the chain of the ledger no longer follows the
+// default chain, empty if it does
+func (ledger *Ledger) OutOfSync() string {
+	ledger.outOfSyncLock.RLock()
+	defer ledger.outOfSyncLock.RUnlock()
+	return ledger.outOfSync
+}
+
+// readOutOfSync returns why the chain was marked out of sync, empty if it
+// was not
+func readOutOfSync(chainID string) (string, error) {
+	if chainID == "" {
+		return "", nil
+	}
+	cause, err := ioutil.ReadFile(filepath.Join(db.GetChainPath(chainID), outOfSyncFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(cause), err
+}
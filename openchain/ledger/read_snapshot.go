@@ -0,0 +1,110 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"sync"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/tecbot/gorocksdb"
+)
+
+// ReadSnapshot is an immutable view of the committed state, for reads that
+// must not see the changes of the transaction batch in progress nor the
+// batches committed after it is taken. Release must be called once done.
+type ReadSnapshot struct {
+	ledger *Ledger
+
+	sync.RWMutex
+	dbSnapshot *gorocksdb.Snapshot
+}
+
+// GetReadSnapshot returns a snapshot of the committed state
+func (ledger *Ledger) GetReadSnapshot() *ReadSnapshot {
+	return &ReadSnapshot{ledger: ledger, dbSnapshot: ledger.openchainDB().GetSnapshot()}
+}
+
+// GetState get the committed state of the snapshot for the given chaincodeID and key
+func (snapshot *ReadSnapshot) GetState(chaincodeID string, key string) ([]byte, error) {
+	snapshot.RLock()
+	defer snapshot.RUnlock()
+	if snapshot.dbSnapshot == nil {
+		return nil, ErrReadSnapshotReleased
+	}
+	stateReadsMetric.Inc()
+	return snapshot.ledger.state.GetFromSnapshot(snapshot.dbSnapshot, chaincodeID, key)
+}
+
+// GetStateRangeScanIterator returns an iterator over the committed state of the snapshot, like
+// Ledger.GetStateRangeScanIterator with committed set to true. The iterator must be closed before
+// the snapshot is released.
+func (snapshot *ReadSnapshot) GetStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	snapshot.RLock()
+	defer snapshot.RUnlock()
+	if snapshot.dbSnapshot == nil {
+		return nil, ErrReadSnapshotReleased
+	}
+	return snapshot.ledger.state.GetRangeScanIteratorFromSnapshot(snapshot.dbSnapshot, nil, chaincodeID, startKey, endKey)
+}
+
+// GetTxStateRangeScanIterator returns an iterator over the committed state of the snapshot and the given
+// state changes of a transaction, which are copied. The iterator must be closed before the snapshot is
+// released.
+func (snapshot *ReadSnapshot) GetTxStateRangeScanIterator(txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	snapshot.RLock()
+	defer snapshot.RUnlock()
+	if snapshot.dbSnapshot == nil {
+		return nil, ErrReadSnapshotReleased
+	}
+	return snapshot.ledger.state.GetTxRangeScanIteratorFromSnapshot(snapshot.dbSnapshot, txDelta, chaincodeID, startKey, endKey)
+}
+
+// Release frees the snapshot, after which it can no longer be read
+func (snapshot *ReadSnapshot) Release() {
+	snapshot.Lock()
+	defer snapshot.Unlock()
+	if snapshot.dbSnapshot != nil {
+		snapshot.dbSnapshot.Release()
+		snapshot.dbSnapshot = nil
+	}
+}
+
+// getRangeScanSnapshotIterator returns an iterator over a snapshot of the state taken for it
+func (ledger *Ledger) getRangeScanSnapshotIterator(txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	dbSnapshot := ledger.openchainDB().GetSnapshot()
+	itr, err := ledger.state.GetRangeScanIteratorFromSnapshot(dbSnapshot, txDelta, chaincodeID, startKey, endKey)
+	if err != nil {
+		dbSnapshot.Release()
+		return nil, err
+	}
+	return &snapshotRangeScanIterator{itr, dbSnapshot}, nil
+}
+
+// snapshotRangeScanIterator is a range scan iterator over a DB snapshot, which it releases when closed
+type snapshotRangeScanIterator struct {
+	statemgmt.RangeScanIterator
+	dbSnapshot *gorocksdb.Snapshot
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *snapshotRangeScanIterator) Close() {
+	itr.RangeScanIterator.Close()
+	itr.dbSnapshot.Release()
+}
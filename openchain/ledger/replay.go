@@ -0,0 +1,80 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+)
+
+// BeginReplay starts a transaction-batch in which the state is rolled back, in
+// memory only, to the state before blockNumber. The transactions executed in
+// the batch see the state the transactions of blockNumber saw when they were
+// executed, and GetTempStateHash can be compared with the state hashes of the
+// blocks replayed. The state is rolled back using the state-deltas of
+// blockNumber and the blocks after it, so only the last
+// ledger.state.deltaHistorySize blocks can be replayed. The batch is
+// discarded with RollbackTxBatch; it must never be committed. Until then
+// BeginTxBatch waits, so the batches of consensus are not executed on the
+// rolled back state.
+func (ledger *Ledger) BeginReplay(id interface{}, blockNumber uint64) error {
+	ledger.replayLock.Lock()
+	if err := ledger.beginReplay(id, blockNumber); err != nil {
+		ledger.replayLock.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (ledger *Ledger) beginReplay(id interface{}, blockNumber uint64) error {
+	if err := ledger.checkValidIDBegin(); err != nil {
+		return err
+	}
+	size := ledger.blockchain.getSize()
+	if blockNumber >= size {
+		return ErrOutOfBounds
+	}
+	rollbackDelta, err := ledger.state.GetRollbackStateDelta(blockNumber, size-1)
+	if err != nil {
+		return err
+	}
+	ledger.currentID = id
+	ledger.replayID = id
+	ledger.state.ApplyStateDelta(rollbackDelta)
+	ledgerLogger.Info("Rolled back the state from block number [%d] to before block number [%d] for replay", size-1, blockNumber)
+	return nil
+}
+
+// ReplayStateDelta applies the recorded state changes of a block to the state
+// of a replay started with BeginReplay, in place of executing its
+// transactions again
+func (ledger *Ledger) ReplayStateDelta(id interface{}, blockNumber uint64) error {
+	if err := ledger.checkValidIDCommitORRollback(id); err != nil {
+		return err
+	}
+	delta, err := ledger.GetStateDelta(blockNumber)
+	if err != nil {
+		return err
+	}
+	if delta == nil {
+		return fmt.Errorf("State-delta of block number [%d] is not available", blockNumber)
+	}
+	ledger.state.MergeStateDelta(delta)
+	return nil
+}
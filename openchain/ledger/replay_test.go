@@ -0,0 +1,111 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+	"github.com/openblockchain/obc-peer/protos"
+)
+
+func TestReplay(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// Block 0
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.SetState("chaincode2", "key2", []byte("value2A"))
+	ledger.TxFinished("txUuid1", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	// Block 1
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "key1", []byte("value1B"))
+	ledger.DeleteState("chaincode2", "key2")
+	ledger.SetState("chaincode3", "key3", []byte("value3B"))
+	ledger.TxFinished("txUuid2", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	// Block 2
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid3")
+	ledger.SetState("chaincode1", "key1", []byte("value1C"))
+	ledger.TxFinished("txUuid3", true)
+	transaction, _ = buildTestTx(t)
+	ledger.CommitTxBatch(2, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	stateHash, _ := ledger.GetTempStateHash()
+
+	err := ledger.BeginReplay("replay", 3)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+
+	// The state before block 1 is the state at block 0
+	err = ledger.BeginReplay("replay", 1)
+	testutil.AssertNoError(t, err, "Error beginning replay")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", false), []byte("value1A"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "key2", false), []byte("value2A"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode3", "key3", false))
+	block0, _ := ledger.GetBlockByNumber(0)
+	replayedHash, _ := ledger.GetTempStateHash()
+	testutil.AssertEquals(t, replayedHash, block0.StateHash)
+
+	// Block 1 executed again
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "key1", []byte("value1B"))
+	ledger.DeleteState("chaincode2", "key2")
+	ledger.SetState("chaincode3", "key3", []byte("value3B"))
+	ledger.TxFinished("txUuid2", true)
+	block1, _ := ledger.GetBlockByNumber(1)
+	replayedHash, _ = ledger.GetTempStateHash()
+	testutil.AssertEquals(t, replayedHash, block1.StateHash)
+
+	// Block 2 from its recorded changes
+	err = ledger.ReplayStateDelta("replay", 2)
+	testutil.AssertNoError(t, err, "Error replaying state-delta")
+	replayedHash, _ = ledger.GetTempStateHash()
+	testutil.AssertEquals(t, replayedHash, stateHash)
+
+	// The replay is never committed, and batches wait for it to end
+	testutil.AssertError(t, ledger.CommitTxBatch("replay", nil, nil, nil), "Expected error committing replay")
+	begun := make(chan error)
+	go func() {
+		begun <- ledger.BeginTxBatch(3)
+	}()
+	select {
+	case <-begun:
+		t.Fatal("Expected the transaction-batch to wait for the replay to end")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Nothing of the replay is kept
+	err = ledger.RollbackTxBatch("replay")
+	testutil.AssertNoError(t, err, "Error discarding replay")
+	testutil.AssertNoError(t, <-begun, "Error beginning transaction-batch after the replay")
+	testutil.AssertNoError(t, ledger.RollbackTxBatch(3), "Error discarding transaction-batch")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", false), []byte("value1C"))
+	replayedHash, _ = ledger.GetTempStateHash()
+	testutil.AssertEquals(t, replayedHash, stateHash)
+}
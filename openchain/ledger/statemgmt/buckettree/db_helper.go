@@ -23,10 +23,10 @@ import (
 	"github.com/openblockchain/obc-peer/openchain/db"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/ledger/util"
+	"github.com/tecbot/gorocksdb"
 )
 
-func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
-	openchainDB := db.GetDBHandle()
+func fetchDataNodeFromDB(openchainDB *db.OpenchainDB, dataKey *dataKey) (*dataNode, error) {
 	nodeBytes, err := openchainDB.GetFromStateCF(dataKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
@@ -37,8 +37,18 @@ func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
 	return unmarshalDataNode(dataKey, nodeBytes), nil
 }
 
-func fetchBucketNodeFromDB(bucketKey *bucketKey) (*bucketNode, error) {
-	openchainDB := db.GetDBHandle()
+func fetchDataNodeFromSnapshot(openchainDB *db.OpenchainDB, snapshot *gorocksdb.Snapshot, dataKey *dataKey) (*dataNode, error) {
+	nodeBytes, err := openchainDB.GetFromStateCFSnapshot(snapshot, dataKey.getEncodedBytes())
+	if err != nil {
+		return nil, err
+	}
+	if util.IsNil(nodeBytes) {
+		return nil, nil
+	}
+	return unmarshalDataNode(dataKey, nodeBytes), nil
+}
+
+func fetchBucketNodeFromDB(openchainDB *db.OpenchainDB, bucketKey *bucketKey) (*bucketNode, error) {
 	nodeBytes, err := openchainDB.GetFromStateCF(bucketKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
@@ -49,13 +59,34 @@ func fetchBucketNodeFromDB(bucketKey *bucketKey) (*bucketNode, error) {
 	return unmarshalBucketNode(bucketKey, nodeBytes), nil
 }
 
+func fetchBucketNodeFromSnapshot(openchainDB *db.OpenchainDB, snapshot *gorocksdb.Snapshot, bucketKey *bucketKey) (*bucketNode, error) {
+	nodeBytes, err := openchainDB.GetFromStateCFSnapshot(snapshot, bucketKey.getEncodedBytes())
+	if err != nil {
+		return nil, err
+	}
+	if util.IsNil(nodeBytes) {
+		return nil, nil
+	}
+	return unmarshalBucketNode(bucketKey, nodeBytes), nil
+}
+
 type rawKey []byte
 
-func fetchDataNodesFromDBFor(bucketKey *bucketKey) (dataNodes, error) {
+func fetchDataNodesFromDBFor(openchainDB *db.OpenchainDB, bucketKey *bucketKey) (dataNodes, error) {
 	logger.Debug("Fetching from DB data nodes for bucket [%s]", bucketKey)
-	openchainDB := db.GetDBHandle()
 	itr := openchainDB.GetStateCFIterator()
 	defer itr.Close()
+	return fetchDataNodesFromIteratorFor(itr, bucketKey)
+}
+
+func fetchDataNodesFromSnapshotFor(openchainDB *db.OpenchainDB, snapshot *gorocksdb.Snapshot, bucketKey *bucketKey) (dataNodes, error) {
+	logger.Debug("Fetching from DB snapshot data nodes for bucket [%s]", bucketKey)
+	itr := openchainDB.GetStateCFSnapshotIterator(snapshot)
+	defer itr.Close()
+	return fetchDataNodesFromIteratorFor(itr, bucketKey)
+}
+
+func fetchDataNodesFromIteratorFor(itr *gorocksdb.Iterator, bucketKey *bucketKey) (dataNodes, error) {
 	minimumDataKeyBytes := minimumPossibleDataKeyBytesFor(bucketKey)
 
 	var dataNodes dataNodes
@@ -20,7 +20,6 @@ under the License.
 package buckettree
 
 import (
-	"github.com/openblockchain/obc-peer/openchain/db"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/tecbot/gorocksdb"
 )
@@ -37,8 +36,7 @@ type RangeScanIterator struct {
 	done                bool
 }
 
-func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFIterator()
+func newRangeScanIterator(dbItr *gorocksdb.Iterator, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
 	itr := &RangeScanIterator{
 		dbItr:       dbItr,
 		chaincodeID: chaincodeID,
@@ -32,6 +32,7 @@ var logger = logging.MustGetLogger("buckettree")
 
 // StateImpl - implements the interface - 'statemgmt.HashableState'
 type StateImpl struct {
+	chainID                string
 	dataNodesDelta         *dataNodesDelta
 	bucketTreeDelta        *bucketTreeDelta
 	persistedStateHash     []byte
@@ -44,10 +45,19 @@ func NewStateImpl() *StateImpl {
 	return &StateImpl{}
 }
 
+// NewChainStateImpl constructs a new StateImpl persisted in the DB of a chain
+func NewChainStateImpl(chainID string) *StateImpl {
+	return &StateImpl{chainID: chainID}
+}
+
+func (stateImpl *StateImpl) openchainDB() *db.OpenchainDB {
+	return db.GetChainDBHandle(stateImpl.chainID)
+}
+
 // Initialize - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 	initConfig(configs)
-	rootBucketNode, err := fetchBucketNodeFromDB(constructRootBucketKey())
+	rootBucketNode, err := fetchBucketNodeFromDB(stateImpl.openchainDB(), constructRootBucketKey())
 	if err != nil {
 		return err
 	}
@@ -67,7 +77,20 @@ func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 // Get - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	dataKey := newDataKey(chaincodeID, key)
-	dataNode, err := fetchDataNodeFromDB(dataKey)
+	dataNode, err := fetchDataNodeFromDB(stateImpl.openchainDB(), dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if dataNode == nil {
+		return nil, nil
+	}
+	return dataNode.value, nil
+}
+
+// GetFromSnapshot - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) GetFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, error) {
+	dataKey := newDataKey(chaincodeID, key)
+	dataNode, err := fetchDataNodeFromSnapshot(stateImpl.openchainDB(), snapshot, dataKey)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +151,7 @@ func (stateImpl *StateImpl) processDataNodeDelta() error {
 	afftectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, bucketKey := range afftectedBuckets {
 		updatedDataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(bucketKey)
-		existingDataNodes, err := fetchDataNodesFromDBFor(bucketKey)
+		existingDataNodes, err := fetchDataNodesFromDBFor(stateImpl.openchainDB(), bucketKey)
 		if err != nil {
 			return err
 		}
@@ -146,7 +169,7 @@ func (stateImpl *StateImpl) processBucketTreeDelta() error {
 		bucketNodes := stateImpl.bucketTreeDelta.getBucketNodesAt(level)
 		for _, bucketNode := range bucketNodes {
 			logger.Debug("bucketNode in tree-delta [%s]", bucketNode)
-			dbBucketNode, err := fetchBucketNodeFromDB(bucketNode.bucketKey)
+			dbBucketNode, err := fetchBucketNodeFromDB(stateImpl.openchainDB(), bucketNode.bucketKey)
 			logger.Debug("bucket node from db [%s]", dbBucketNode)
 			if err != nil {
 				return err
@@ -233,7 +256,7 @@ func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.Write
 }
 
 func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+	openchainDB := stateImpl.openchainDB()
 	affectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, affectedBucket := range affectedBuckets {
 		dataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(affectedBucket)
@@ -248,7 +271,7 @@ func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocks
 }
 
 func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+	openchainDB := stateImpl.openchainDB()
 	secondLastLevel := conf.getLowestLevel() - 1
 	for level := secondLastLevel; level >= 0; level-- {
 		bucketNodes := stateImpl.bucketTreeDelta.getBucketNodesAt(level)
@@ -271,10 +294,15 @@ func (stateImpl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
-	return newStateSnapshotIterator(snapshot)
+	return newStateSnapshotIterator(stateImpl.openchainDB(), snapshot)
 }
 
 // GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateImpl.openchainDB().GetStateCFIterator(), chaincodeID, startKey, endKey)
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) GetRangeScanIteratorFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(stateImpl.openchainDB().GetStateCFSnapshotIterator(snapshot), chaincodeID, startKey, endKey)
 }
@@ -0,0 +1,173 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package buckettree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/ledger/util"
+	"github.com/tecbot/gorocksdb"
+)
+
+// The proof of a key-value is made of the data nodes of the lowest-level bucket of the key,
+// from which the crypto-hash of the bucket is computed, followed by the bucket nodes from the
+// parent of that bucket up to the root, from which the crypto-hash of the state is computed.
+
+// GetStateProof - method implementation for interface 'statemgmt.ProvableState'
+func (stateImpl *StateImpl) GetStateProof(snapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, []byte, error) {
+	openchainDB := stateImpl.openchainDB()
+	dataKey := newDataKey(chaincodeID, key)
+	bucketKey := dataKey.getBucketKey()
+	nodes, err := fetchDataNodesFromSnapshotFor(openchainDB, snapshot, bucketKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var value []byte
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(uint64(bucketKey.bucketNumber))
+	buffer.EncodeVarint(uint64(len(nodes)))
+	for _, node := range nodes {
+		if bytes.Equal(node.getCompositeKey(), dataKey.compositeKey) {
+			value = node.getValue()
+		}
+		buffer.EncodeRawBytes(node.getCompositeKey())
+		buffer.EncodeRawBytes(node.getValue())
+	}
+	for bucketKey.level > 0 {
+		bucketKey = bucketKey.getParentKey()
+		node, err := fetchBucketNodeFromSnapshot(openchainDB, snapshot, bucketKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if node == nil {
+			node = newBucketNode(bucketKey)
+		}
+		buffer.EncodeRawBytes(node.marshal())
+	}
+	return value, buffer.Bytes(), nil
+}
+
+// VerifyStateProof - method implementation for interface 'statemgmt.ProvableState'
+func (stateImpl *StateImpl) VerifyStateProof(stateHash []byte, chaincodeID string, key string, value []byte, proof []byte) error {
+	dataKey := newDataKey(chaincodeID, key)
+	buffer := proto.NewBuffer(proof)
+	bucketNumber, err := buffer.DecodeVarint()
+	if err != nil {
+		return err
+	}
+	if int(bucketNumber) != dataKey.getBucketKey().bucketNumber {
+		return fmt.Errorf("Proof is for bucket [%d] but key belongs to bucket [%d]", bucketNumber, dataKey.getBucketKey().bucketNumber)
+	}
+
+	cryptoHash, err := verifyDataNodesProof(buffer, dataKey, value)
+	if err != nil {
+		return err
+	}
+
+	childKey := dataKey.getBucketKey()
+	for childKey.level > 0 {
+		parentKey := childKey.getParentKey()
+		node, err := decodeBucketNodeProof(buffer, parentKey)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(node.childrenCryptoHash[parentKey.getChildIndex(childKey)], cryptoHash) {
+			return fmt.Errorf("Crypto-hash of bucket [%s] does not match its parent", childKey)
+		}
+		cryptoHash = node.computeCryptoHash()
+		childKey = parentKey
+	}
+	if _, err := buffer.DecodeVarint(); err == nil {
+		return errors.New("Proof has trailing data")
+	}
+	if !bytes.Equal(cryptoHash, stateHash) {
+		return errors.New("Crypto-hash computed from proof does not match the crypto-hash of the state")
+	}
+	return nil
+}
+
+// verifyDataNodesProof decodes the data nodes of the lowest-level bucket of provenKey, checks that
+// the key has the given value among them and returns the crypto-hash of the bucket
+func verifyDataNodesProof(buffer *proto.Buffer, provenKey *dataKey, value []byte) ([]byte, error) {
+	numNodes, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	if numNodes > uint64(len(buffer.Bytes())) {
+		return nil, fmt.Errorf("Invalid number of data nodes [%d] in proof", numNodes)
+	}
+	bucketKey := provenKey.getBucketKey()
+	calculator := newBucketHashCalculator(bucketKey)
+	var found []byte
+	var previousKey []byte
+	for i := uint64(0); i < numNodes; i++ {
+		compositeKey, err := buffer.DecodeRawBytes(true)
+		if err != nil {
+			return nil, err
+		}
+		nodeValue, err := buffer.DecodeRawBytes(true)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && bytes.Compare(previousKey, compositeKey) >= 0 {
+			return nil, errors.New("Data nodes in proof are not sorted")
+		}
+		if util.IsNil(nodeValue) {
+			return nil, errors.New("Data node in proof has no value")
+		}
+		if int(conf.computeBucketHash(compositeKey))%conf.getNumBucketsAtLowestLevel()+1 != bucketKey.bucketNumber {
+			return nil, fmt.Errorf("Data node in proof does not belong to bucket [%s]", bucketKey)
+		}
+		if bytes.Equal(compositeKey, provenKey.compositeKey) {
+			found = nodeValue
+		}
+		calculator.addNextNode(newDataNode(&dataKey{bucketKey, compositeKey}, nodeValue))
+		previousKey = compositeKey
+	}
+	if !bytes.Equal(found, value) {
+		return nil, errors.New("Value does not match the value of the key in proof")
+	}
+	return calculator.computeCryptoHash(), nil
+}
+
+// decodeBucketNodeProof decodes a bucket node of a proof. Unlike unmarshalBucketNode it does
+// not panic on invalid input.
+func decodeBucketNodeProof(buffer *proto.Buffer, bucketKey *bucketKey) (*bucketNode, error) {
+	serializedBytes, err := buffer.DecodeRawBytes(false)
+	if err != nil {
+		return nil, err
+	}
+	node := newBucketNode(bucketKey)
+	nodeBuffer := proto.NewBuffer(serializedBytes)
+	for i := 0; i < conf.getMaxGroupingAtEachLevel(); i++ {
+		childCryptoHash, err := nodeBuffer.DecodeRawBytes(true)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bucket node [%s] in proof: %s", bucketKey, err)
+		}
+		if !util.IsNil(childCryptoHash) {
+			node.childrenCryptoHash[i] = childCryptoHash
+		}
+	}
+	return node, nil
+}
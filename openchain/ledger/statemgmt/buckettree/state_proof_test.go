@@ -0,0 +1,74 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package buckettree
+
+import (
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
+)
+
+func TestStateImpl_StateProof(t *testing.T) {
+	// number of buckets at each level 26,9,3,1
+	testHasher, stateImplTestWrapper, stateDelta := createFreshDBAndInitTestStateImplWithCustomHasher(t, 26, 3)
+	testHasher.populate("chaincodeID1", "key1", 0)
+	testHasher.populate("chaincodeID2", "key2", 0)
+	testHasher.populate("chaincodeID3", "key3", 3)
+	testHasher.populate("chaincodeID4", "key4", 20)
+	testHasher.populate("chaincodeID5", "key5", 0)
+	testHasher.populate("chaincodeID6", "key6", 12)
+
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID2", "key2", []byte("value2"), nil)
+	stateDelta.Set("chaincodeID3", "key3", []byte("value3"), nil)
+	stateDelta.Set("chaincodeID4", "key4", []byte("value4"), nil)
+	stateHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	snapshot := db.GetDBHandle().GetSnapshot()
+	defer snapshot.Release()
+	stateImpl := stateImplTestWrapper.stateImpl
+
+	for _, kv := range [][]string{{"chaincodeID2", "key2", "value2"}, {"chaincodeID3", "key3", "value3"}, {"chaincodeID4", "key4", "value4"}} {
+		value, proof, err := stateImpl.GetStateProof(snapshot, kv[0], kv[1])
+		testutil.AssertNoError(t, err, "Error while getting state proof")
+		testutil.AssertEquals(t, value, []byte(kv[2]))
+		testutil.AssertNoError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], value, proof), "Error while verifying state proof")
+		testutil.AssertError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], []byte("forged"), proof), "Expected error verifying a forged value")
+		testutil.AssertError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], nil, proof), "Expected error verifying the absence of a key")
+		testutil.AssertError(t, stateImpl.VerifyStateProof([]byte("other"), kv[0], kv[1], value, proof), "Expected error verifying against another state")
+		testutil.AssertError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], value, proof[:len(proof)-1]), "Expected error verifying a truncated proof")
+	}
+
+	// missing keys are proven to be missing, both in used and in empty buckets
+	for _, kv := range [][]string{{"chaincodeID5", "key5"}, {"chaincodeID6", "key6"}} {
+		value, proof, err := stateImpl.GetStateProof(snapshot, kv[0], kv[1])
+		testutil.AssertNoError(t, err, "Error while getting state proof")
+		testutil.AssertNil(t, value)
+		testutil.AssertNoError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], nil, proof), "Error while verifying absence of key")
+		testutil.AssertError(t, stateImpl.VerifyStateProof(stateHash, kv[0], kv[1], []byte("forged"), proof), "Expected error verifying a forged value")
+	}
+
+	// a proof cannot be used for a key of another bucket
+	_, proof, err := stateImpl.GetStateProof(snapshot, "chaincodeID1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting state proof")
+	testutil.AssertError(t, stateImpl.VerifyStateProof(stateHash, "chaincodeID3", "key3", []byte("value3"), proof), "Expected error verifying proof of another bucket")
+}
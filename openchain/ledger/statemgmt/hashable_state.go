@@ -34,6 +34,9 @@ type HashableState interface {
 	// Get get the value from DB
 	Get(chaincodeID string, key string) ([]byte, error)
 
+	// GetFromSnapshot get the value from the given DB snapshot
+	GetFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, error)
+
 	// PrepareWorkingSet passes a stateDelta that captures the changes that needs to be applied to the state
 	PrepareWorkingSet(stateDelta *StateDelta) error
 
@@ -64,6 +67,10 @@ type HashableState interface {
 	// for endKey parameter assumes the endKey to be the greatest key available in the db for the chaincodeID
 	GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (RangeScanIterator, error)
 
+	// GetRangeScanIteratorFromSnapshot - state implementation to provide an iterator like GetRangeScanIterator
+	// over the state persisted in the given DB snapshot
+	GetRangeScanIteratorFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, startKey string, endKey string) (RangeScanIterator, error)
+
 	// PerfHintKeyChanged state implementation may be provided with some hints before (e.g., during tx execution)
 	// the StateDelta is prepared and passed in PrepareWorkingSet method.
 	// A state implementation may use this hint for prefetching relevant data so as if this could improve
@@ -71,6 +78,20 @@ type HashableState interface {
 	PerfHintKeyChanged(chaincodeID string, key string)
 }
 
+// ProvableState - Interface that may be implemented by state management in addition to
+// HashableState, so that the value of a key can be checked against the crypto-hash of the
+// state by a party that does not hold the state
+type ProvableState interface {
+
+	// GetStateProof returns the value of a key in the state persisted in the given DB snapshot,
+	// along with the proof of the value against the crypto-hash of that state
+	GetStateProof(snapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, []byte, error)
+
+	// VerifyStateProof checks with a proof returned by GetStateProof that a key has the given value,
+	// nil for a missing key, in the state with the given crypto-hash
+	VerifyStateProof(stateHash []byte, chaincodeID string, key string, value []byte, proof []byte) error
+}
+
 // StateSnapshotIterator An interface that is to be implemented by the return value of
 // GetStateSnapshotIterator method in the implementation of HashableState interface
 type StateSnapshotIterator interface {
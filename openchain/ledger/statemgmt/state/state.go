@@ -36,12 +36,11 @@ var logger = logging.MustGetLogger("state")
 
 const detaultStateImpl = "buckettree"
 
-var stateImpl statemgmt.HashableState
-
 // State structure for maintaining world state.
 // This encapsulates a particular implementation for managing the state persistence
 // This is not thread safe
 type State struct {
+	chainID               string
 	stateImpl             statemgmt.HashableState
 	stateDelta            *statemgmt.StateDelta
 	currentTxStateDelta   *statemgmt.StateDelta
@@ -53,6 +52,12 @@ type State struct {
 
 // NewState constructs a new State. This Initializes encapsulated state implementation
 func NewState() *State {
+	return NewChainState("")
+}
+
+// NewChainState constructs a new State persisted in the DB of a chain. The
+// empty chainID is the default chain.
+func NewChainState(chainID string) *State {
 	stateImplName := viper.GetString("ledger.state.dataStructure.name")
 	stateImplConfigs := viper.GetStringMap("ledger.state.dataStructure.configs")
 
@@ -61,11 +66,12 @@ func NewState() *State {
 		stateImplConfigs = nil
 	}
 
+	var stateImpl statemgmt.HashableState
 	switch stateImplName {
 	case "buckettree":
-		stateImpl = buckettree.NewStateImpl()
+		stateImpl = buckettree.NewChainStateImpl(chainID)
 	case "trie":
-		stateImpl = trie.NewStateTrie()
+		stateImpl = trie.NewChainStateTrie(chainID)
 	default:
 		panic(fmt.Errorf("Error during initialization of state implementation. State data structure '%s' is not valid.", stateImplName))
 	}
@@ -78,7 +84,7 @@ func NewState() *State {
 	if deltaHistorySize < 0 {
 		panic(fmt.Errorf("Delta history size must be greater than or equal to 0. Current value is %d.", deltaHistorySize))
 	}
-	return &State{stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
+	return &State{chainID, stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
 		false, uint64(deltaHistorySize)}
 }
 
@@ -131,6 +137,11 @@ func (state *State) Get(chaincodeID string, key string, committed bool) ([]byte,
 	return state.stateImpl.Get(chaincodeID, key)
 }
 
+// GetFromSnapshot get the committed value for the given chaincodeID and key from the given DB snapshot
+func (state *State) GetFromSnapshot(dbSnapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, error) {
+	return state.stateImpl.GetFromSnapshot(dbSnapshot, chaincodeID, key)
+}
+
 // GetRangeScanIterator returns an iterator to get all the keys (and values) between startKey and endKey
 // (assuming lexical order of the keys) for a chaincodeID.
 func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, endKey string, committed bool) (statemgmt.RangeScanIterator, error) {
@@ -148,6 +159,52 @@ func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, en
 		stateImplItr), nil
 }
 
+// GetTxRangeScanIterator returns an iterator to get all the keys (and values) between startKey and endKey
+// for a chaincodeID, giving preference to the given state changes of a transaction that are kept outside
+// the state, then to the in-memory state changes of the batch
+func (state *State) GetTxRangeScanIterator(txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	stateImplItr, err := state.stateImpl.GetRangeScanIterator(chaincodeID, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return newCompositeRangeScanIterator(
+		statemgmt.NewStateDeltaRangeScanIterator(txDelta, chaincodeID, startKey, endKey),
+		statemgmt.NewStateDeltaRangeScanIterator(state.stateDelta, chaincodeID, startKey, endKey),
+		stateImplItr), nil
+}
+
+// GetRangeScanIteratorFromSnapshot returns an iterator like GetTxRangeScanIterator, or over the committed
+// state only if txDelta is nil, that keeps returning the keys (and values) of the state at the time it was
+// created: the committed state is read from the given DB snapshot and the in-memory state changes are copied.
+// The DB snapshot must not be released before the iterator is closed.
+func (state *State) GetRangeScanIteratorFromSnapshot(dbSnapshot *gorocksdb.Snapshot, txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	stateImplItr, err := state.stateImpl.GetRangeScanIteratorFromSnapshot(dbSnapshot, chaincodeID, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	if txDelta == nil {
+		return stateImplItr, nil
+	}
+	return newCompositeRangeScanIterator(
+		statemgmt.NewStateDeltaRangeScanSnapshotIterator(txDelta, chaincodeID, startKey, endKey),
+		statemgmt.NewStateDeltaRangeScanSnapshotIterator(state.stateDelta, chaincodeID, startKey, endKey),
+		stateImplItr), nil
+}
+
+// GetTxRangeScanIteratorFromSnapshot returns an iterator like GetRangeScanIteratorFromSnapshot over the
+// given state changes of a transaction and the committed state only, leaving out the state changes of the
+// batch in progress. The DB snapshot must not be released before the iterator is closed.
+func (state *State) GetTxRangeScanIteratorFromSnapshot(dbSnapshot *gorocksdb.Snapshot, txDelta *statemgmt.StateDelta, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	stateImplItr, err := state.stateImpl.GetRangeScanIteratorFromSnapshot(dbSnapshot, chaincodeID, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return newCompositeRangeScanIterator(
+		statemgmt.NewStateDeltaRangeScanSnapshotIterator(txDelta, chaincodeID, startKey, endKey),
+		statemgmt.NewStateDeltaRangeScanSnapshotIterator(statemgmt.NewStateDelta(), chaincodeID, startKey, endKey),
+		stateImplItr), nil
+}
+
 // Set sets state to given value for chaincodeID and key. Does not immideatly writes to DB
 func (state *State) Set(chaincodeID string, key string, value []byte) error {
 	logger.Debug("set() chaincodeID=[%s], key=[%s], value=[%#v]", chaincodeID, key, value)
@@ -225,6 +282,12 @@ func (state *State) ClearInMemoryChanges(changesPersisted bool) {
 	state.stateImpl.ClearWorkingSet(changesPersisted)
 }
 
+// GetStateDelta returns the changes in state of the current batch, which are
+// persisted by AddChangesForPersistence
+func (state *State) GetStateDelta() *statemgmt.StateDelta {
+	return state.stateDelta
+}
+
 // getStateDelta get changes in state after most recent call to method clearInMemoryChanges
 func (state *State) getStateDelta() *statemgmt.StateDelta {
 	return state.stateDelta
@@ -233,12 +296,32 @@ func (state *State) getStateDelta() *statemgmt.StateDelta {
 // GetSnapshot returns a snapshot of the global state for the current block. stateSnapshot.Release()
 // must be called once you are done.
 func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot *gorocksdb.Snapshot) (*StateSnapshot, error) {
-	return newStateSnapshot(blockNumber, dbSnapshot)
+	return newStateSnapshot(state.stateImpl, blockNumber, dbSnapshot)
+}
+
+// GetStateProof returns the value of a key in the state persisted in the DB snapshot along with
+// its proof against the crypto-hash of that state, if the state implementation can prove values
+func (state *State) GetStateProof(dbSnapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, []byte, error) {
+	provableState, ok := state.stateImpl.(statemgmt.ProvableState)
+	if !ok {
+		return nil, nil, fmt.Errorf("State data structure does not support state proofs")
+	}
+	return provableState.GetStateProof(dbSnapshot, chaincodeID, key)
+}
+
+// VerifyStateProof checks the proof returned by GetStateProof, possibly on another peer, that a
+// key has the given value in the state with the given crypto-hash
+func (state *State) VerifyStateProof(stateHash []byte, chaincodeID string, key string, value []byte, proof []byte) error {
+	provableState, ok := state.stateImpl.(statemgmt.ProvableState)
+	if !ok {
+		return fmt.Errorf("State data structure does not support state proofs")
+	}
+	return provableState.VerifyStateProof(stateHash, chaincodeID, key, value, proof)
 }
 
 // FetchStateDeltaFromDB fetches the StateDelta corrsponding to given blockNumber
 func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateDelta, error) {
-	stateDeltaBytes, err := db.GetDBHandle().GetFromStateDeltaCF(encodeStateDeltaKey(blockNumber))
+	stateDeltaBytes, err := state.openchainDB().GetFromStateDeltaCF(encodeStateDeltaKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -260,7 +343,7 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 	state.stateImpl.AddChangesForPersistence(writeBatch)
 
 	serializedStateDelta := state.stateDelta.Marshal()
-	cf := db.GetDBHandle().StateDeltaCF
+	cf := state.openchainDB().StateDeltaCF
 	logger.Debug("Adding state-delta corresponding to block number[%d]", blockNumber)
 	writeBatch.PutCF(cf, encodeStateDeltaKey(blockNumber), serializedStateDelta)
 	if blockNumber >= state.historyStateDeltaSize {
@@ -274,6 +357,68 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 	logger.Debug("state.addChangesForPersistence()...finished")
 }
 
+// AddRollbackChangesForPersistence adds to writeBatch the changes that roll
+// the state back from the state at lastBlockNumber to the state at
+// blockNumber, using the state-deltas of the blocks in between, which are
+// removed. ClearInMemoryChanges must be called once writeBatch is written or
+// discarded.
+func (state *State) AddRollbackChangesForPersistence(blockNumber uint64, lastBlockNumber uint64, writeBatch *gorocksdb.WriteBatch) error {
+	rollbackDelta, err := state.fetchMergedStateDeltas(blockNumber+1, lastBlockNumber)
+	if err != nil {
+		return err
+	}
+	cf := state.openchainDB().StateDeltaCF
+	for n := blockNumber + 1; n <= lastBlockNumber; n++ {
+		writeBatch.DeleteCF(cf, encodeStateDeltaKey(n))
+	}
+	rollbackDelta.RollBackwards = true
+	state.stateImpl.PrepareWorkingSet(rollbackDelta)
+	state.stateImpl.AddChangesForPersistence(writeBatch)
+	return nil
+}
+
+// GetRollbackStateDelta returns the changes that take the state at
+// lastBlockNumber back to the state before blockNumber, built from the
+// state-deltas of the blocks in between. Unlike a delta rolling backwards, it
+// holds the values the keys are rolled back to, so it can be applied with
+// ApplyStateDelta and read through Get.
+func (state *State) GetRollbackStateDelta(blockNumber uint64, lastBlockNumber uint64) (*statemgmt.StateDelta, error) {
+	mergedDelta, err := state.fetchMergedStateDeltas(blockNumber, lastBlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	rollbackDelta := statemgmt.NewStateDelta()
+	for chaincodeID, chaincodeStateDelta := range mergedDelta.ChaincodeStateDeltas {
+		for key, updatedValue := range chaincodeStateDelta.UpdatedKVs {
+			if updatedValue.PreviousValue == nil {
+				rollbackDelta.Delete(chaincodeID, key, updatedValue.Value)
+			} else {
+				rollbackDelta.Set(chaincodeID, key, updatedValue.PreviousValue, updatedValue.Value)
+			}
+		}
+	}
+	return rollbackDelta, nil
+}
+
+// fetchMergedStateDeltas merges the state-deltas of the blocks from
+// blockNumber to lastBlockNumber
+func (state *State) fetchMergedStateDeltas(blockNumber uint64, lastBlockNumber uint64) (*statemgmt.StateDelta, error) {
+	mergedDelta := statemgmt.NewStateDelta()
+	for n := blockNumber; n <= lastBlockNumber; n++ {
+		stateDelta, err := state.FetchStateDeltaFromDB(n)
+		if err != nil {
+			return nil, err
+		}
+		if stateDelta == nil {
+			return nil, fmt.Errorf("State-delta of block number [%d] is not available, only the last %d blocks can be rolled back", n, state.historyStateDeltaSize)
+		}
+		// The previous values of the earliest block are kept for keys
+		// updated by several blocks
+		mergedDelta.ApplyChanges(stateDelta)
+	}
+	return mergedDelta, nil
+}
+
 // ApplyStateDelta applies already prepared stateDelta to the existing state.
 // This is an in memory change only. state.CommitStateDelta must be used to
 // commit the state to the DB. This method is to be used in state transfer.
@@ -282,6 +427,13 @@ func (state *State) ApplyStateDelta(delta *statemgmt.StateDelta) {
 	state.updateStateImpl = true
 }
 
+// MergeStateDelta adds the changes of delta to the changes in memory, like
+// the changes of a successful tx. This is an in memory change only.
+func (state *State) MergeStateDelta(delta *statemgmt.StateDelta) {
+	state.stateDelta.ApplyChanges(delta)
+	state.updateStateImpl = true
+}
+
 // CommitStateDelta commits the changes from state.ApplyStateDelta to the
 // DB.
 func (state *State) CommitStateDelta() error {
@@ -295,7 +447,7 @@ func (state *State) CommitStateDelta() error {
 	state.stateImpl.AddChangesForPersistence(writeBatch)
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	return db.GetDBHandle().DB.Write(opt, writeBatch)
+	return state.openchainDB().DB.Write(opt, writeBatch)
 }
 
 // DeleteState deletes ALL state keys/values from the DB. This is generally
@@ -303,13 +455,17 @@ func (state *State) CommitStateDelta() error {
 // a snapshot.
 func (state *State) DeleteState() error {
 	state.ClearInMemoryChanges(false)
-	err := db.GetDBHandle().DeleteState()
+	err := state.openchainDB().DeleteState()
 	if err != nil {
 		logger.Error("Error deleting state", err)
 	}
 	return err
 }
 
+func (state *State) openchainDB() *db.OpenchainDB {
+	return db.GetChainDBHandle(state.chainID)
+}
+
 func encodeStateDeltaKey(blockNumber uint64) []byte {
 	return encodeUint64(blockNumber)
 }
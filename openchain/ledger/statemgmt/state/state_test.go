@@ -22,6 +22,7 @@ package state
 import (
 	"testing"
 
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/ledger/testutil"
 )
 
@@ -105,6 +106,29 @@ func TestStateTxBehavior(t *testing.T) {
 	testutil.AssertEquals(t, stateTestWrapper.get("chaincode2", "key2", false), []byte("value2"))
 }
 
+func TestStateTxRangeScanIterator(t *testing.T) {
+	_, state := createFreshDBAndConstructState(t)
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.Set("chaincode1", "key2", []byte("value2"))
+	state.TxFinish("txUuid1", true)
+
+	// changes of a tx kept outside the state take precedence over the batch
+	txDelta := statemgmt.NewStateDelta()
+	txDelta.Set("chaincode1", "key1", []byte("value1_new"), nil)
+	txDelta.Delete("chaincode1", "key2", nil)
+	txDelta.Set("chaincode1", "key3", []byte("value3"), nil)
+	itr, err := state.GetTxRangeScanIterator(txDelta, "chaincode1", "key1", "key3")
+	testutil.AssertNoError(t, err, "Error while getting range scan iterator")
+	defer itr.Close()
+	results := make(map[string][]byte)
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		results[key] = value
+	}
+	testutil.AssertEquals(t, results, map[string][]byte{"key1": []byte("value1_new"), "key3": []byte("value3")})
+}
+
 func TestStateTxWrongCallCausePanic_1(t *testing.T) {
 	_, state := createFreshDBAndConstructState(t)
 	defer testutil.AssertPanic(t, "A panic should occur when a set state is invoked with out calling a tx-begin")
@@ -33,6 +33,20 @@ func NewStateDeltaRangeScanIterator(delta *StateDelta, chaincodeID string, start
 	return &StateDeltaIterator{updates, retrieveRelevantKeys(updates, startKey, endKey), -1, false}
 }
 
+// NewStateDeltaRangeScanSnapshotIterator - return an iterator like NewStateDeltaRangeScanIterator over a copy of
+// the updates in the range, so that later changes to the state-delta object are not seen by the iterator
+func NewStateDeltaRangeScanSnapshotIterator(delta *StateDelta, chaincodeID string, startKey string, endKey string) *StateDeltaIterator {
+	updates := make(map[string]*UpdatedValue)
+	for k, v := range delta.GetUpdates(chaincodeID) {
+		if k >= startKey && (endKey == "" || k <= endKey) {
+			// The delta replaces the values it updates rather than changing them
+			updatedValue := *v
+			updates[k] = &updatedValue
+		}
+	}
+	return &StateDeltaIterator{updates, retrieveRelevantKeys(updates, startKey, endKey), -1, false}
+}
+
 func retrieveRelevantKeys(updates map[string]*UpdatedValue, startKey string, endKey string) []string {
 	relevantKeys := []string{}
 	if updates == nil {
@@ -20,7 +20,6 @@ under the License.
 package trie
 
 import (
-	"github.com/openblockchain/obc-peer/openchain/db"
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
 	"github.com/openblockchain/obc-peer/openchain/ledger/util"
 	"github.com/tecbot/gorocksdb"
@@ -36,8 +35,7 @@ type RangeScanIterator struct {
 	done         bool
 }
 
-func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFIterator()
+func newRangeScanIterator(dbItr *gorocksdb.Iterator, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
 	encodedStartKey := newTrieKey(chaincodeID, startKey).getEncodedBytes()
 	dbItr.Seek(encodedStartKey)
 	return &RangeScanIterator{dbItr, chaincodeID, endKey, "", nil, false}, nil
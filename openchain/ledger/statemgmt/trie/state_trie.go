@@ -32,6 +32,7 @@ var stateTrieLogger = logging.MustGetLogger("stateTrie")
 var logHashOfEveryNode = false
 
 type StateTrie struct {
+	chainID                string
 	trieDelta              *trieDelta
 	persistedStateHash     []byte
 	lastComputedCryptoHash []byte
@@ -42,8 +43,17 @@ func NewStateTrie() *StateTrie {
 	return &StateTrie{}
 }
 
+// NewChainStateTrie constructs a new StateTrie persisted in the DB of a chain
+func NewChainStateTrie(chainID string) *StateTrie {
+	return &StateTrie{chainID: chainID}
+}
+
+func (stateTrie *StateTrie) openchainDB() *db.OpenchainDB {
+	return db.GetChainDBHandle(stateTrie.chainID)
+}
+
 func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
-	rootNode, err := fetchTrieNodeFromDB(rootTrieKey)
+	rootNode, err := fetchTrieNodeFromDB(stateTrie.openchainDB(), rootTrieKey)
 	if err != nil {
 		panic(fmt.Errorf("Error in fetching root node from DB while initializing state trie: %s", err))
 	}
@@ -55,7 +65,19 @@ func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
 }
 
 func (stateTrie *StateTrie) Get(chaincodeID string, key string) ([]byte, error) {
-	trieNode, err := fetchTrieNodeFromDB(newTrieKey(chaincodeID, key))
+	trieNode, err := fetchTrieNodeFromDB(stateTrie.openchainDB(), newTrieKey(chaincodeID, key))
+	if err != nil {
+		return nil, err
+	}
+	if trieNode == nil {
+		return nil, nil
+	}
+	return trieNode.value, nil
+}
+
+// GetFromSnapshot - method implementation for interface 'statemgmt.HashableState'
+func (stateTrie *StateTrie) GetFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, key string) ([]byte, error) {
+	trieNode, err := fetchTrieNodeFromSnapshot(stateTrie.openchainDB(), snapshot, newTrieKey(chaincodeID, key))
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +134,7 @@ func (stateTrie *StateTrie) ComputeCryptoHash() ([]byte, error) {
 
 func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 	stateTrieLogger.Debug("Enter - processChangedNode() for node [%s]", changedNode)
-	dbNode, err := fetchTrieNodeFromDB(changedNode.trieKey)
+	dbNode, err := fetchTrieNodeFromDB(stateTrie.openchainDB(), changedNode.trieKey)
 	if err != nil {
 		return err
 	}
@@ -148,7 +170,7 @@ func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.Write
 		return nil
 	}
 
-	openchainDB := db.GetDBHandle()
+	openchainDB := stateTrie.openchainDB()
 	lowestLevel := stateTrie.trieDelta.getLowestLevel()
 	for level := lowestLevel; level >= 0; level-- {
 		changedNodes := stateTrie.trieDelta.deltaMap[level]
@@ -174,9 +196,14 @@ func (stateTrie *StateTrie) PerfHintKeyChanged(chaincodeID string, key string) {
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
 func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
-	return newStateSnapshotIterator(snapshot)
+	return newStateSnapshotIterator(stateTrie.openchainDB(), snapshot)
 }
 
 func (stateTrie *StateTrie) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateTrie.openchainDB().GetStateCFIterator(), chaincodeID, startKey, endKey)
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.HashableState'
+func (stateTrie *StateTrie) GetRangeScanIteratorFromSnapshot(snapshot *gorocksdb.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(stateTrie.openchainDB().GetStateCFSnapshotIterator(snapshot), chaincodeID, startKey, endKey)
 }
@@ -21,11 +21,11 @@ package trie
 
 import (
 	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/tecbot/gorocksdb"
 )
 
-func fetchTrieNodeFromDB(key *trieKey) (*trieNode, error) {
+func fetchTrieNodeFromDB(openchainDB *db.OpenchainDB, key *trieKey) (*trieNode, error) {
 	stateTrieLogger.Debug("Enter fetchTrieNodeFromDB() for trieKey [%s]", key)
-	openchainDB := db.GetDBHandle()
 	trieNodeBytes, err := openchainDB.GetFromStateCF(key.getEncodedBytes())
 	if err != nil {
 		stateTrieLogger.Error("Error in retrieving trie node from DB for triekey [%s]. Error:%s", key, err)
@@ -44,3 +44,15 @@ func fetchTrieNodeFromDB(key *trieKey) (*trieNode, error) {
 	stateTrieLogger.Debug("Exit fetchTrieNodeFromDB() for trieKey [%s]", key)
 	return trieNode, nil
 }
+
+func fetchTrieNodeFromSnapshot(openchainDB *db.OpenchainDB, snapshot *gorocksdb.Snapshot, key *trieKey) (*trieNode, error) {
+	trieNodeBytes, err := openchainDB.GetFromStateCFSnapshot(snapshot, key.getEncodedBytes())
+	if err != nil {
+		stateTrieLogger.Error("Error in retrieving trie node from DB snapshot for triekey [%s]. Error:%s", key, err)
+		return nil, err
+	}
+	if trieNodeBytes == nil {
+		return nil, nil
+	}
+	return unmarshalTrieNode(key, trieNodeBytes)
+}
@@ -0,0 +1,302 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package lightclient lets clients that do not download the transactions of
+// the blockchain follow it and check the state and transaction proofs
+// returned by peers. It
+// fetches block headers with the GetBlockHeaders service of several peers,
+// merges the signatures each peer gathered for a header and checks that the
+// headers form a chain from a header the client trusts, each signed by a
+// quorum of validators.
+package lightclient
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	google_protobuf "google/protobuf"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// SignatureVerifier checks the signature of a message by a validator, named
+// by its peer ID
+type SignatureVerifier func(validator string, signature []byte, message []byte) error
+
+// ProofVerifier checks that a key has the given value in the state with the
+// given state hash. The proof is specific to the state data structure of the
+// network.
+type ProofVerifier func(stateHash []byte, chaincodeID string, key string, value []byte, proof []byte) error
+
+// Verifier checks block headers served by peers the client does not trust
+type Verifier struct {
+	verify SignatureVerifier
+	quorum int
+}
+
+// NewVerifier returns a Verifier accepting the headers signed by at least
+// quorum validators, whose signatures are checked with verify
+func NewVerifier(verify SignatureVerifier, quorum int) *Verifier {
+	return &Verifier{verify: verify, quorum: quorum}
+}
+
+// VerifyHeader checks that the digest of a header is signed by a quorum of
+// distinct validators. The digest covers every field of the header, so the
+// number, state hash and previous block hash of a verified header are those
+// of the block the validators committed. Invalid signatures are not counted.
+func (v *Verifier) VerifyHeader(header *pb.BlockHeader) error {
+	digest, err := header.Digest()
+	if err != nil {
+		return err
+	}
+	signers := make(map[string]bool)
+	for _, signature := range header.Signatures {
+		if signers[signature.Validator] {
+			continue
+		}
+		if err := v.verify(signature.Validator, signature.Signature, digest); err != nil {
+			continue
+		}
+		signers[signature.Validator] = true
+	}
+	if len(signers) < v.quorum {
+		return fmt.Errorf("Block %d is signed by %d validators, %d are required", header.Number, len(signers), v.quorum)
+	}
+	return nil
+}
+
+// VerifyChain checks that headers are the headers of the blocks following
+// the trusted header, each linked to the previous one and signed by a quorum
+// of validators
+func (v *Verifier) VerifyChain(trusted *pb.BlockHeader, headers []*pb.BlockHeader) error {
+	previous := trusted
+	for _, header := range headers {
+		if header.Number != previous.Number+1 {
+			return fmt.Errorf("Expected block %d after block %d, got block %d", previous.Number+1, previous.Number, header.Number)
+		}
+		if !bytes.Equal(header.PreviousBlockHash, previous.Hash) {
+			return fmt.Errorf("Block %d does not follow block %d", header.Number, previous.Number)
+		}
+		if err := v.VerifyHeader(header); err != nil {
+			return err
+		}
+		previous = header
+	}
+	return nil
+}
+
+// Sync fetches from peers the headers of the blocks following the trusted
+// header and returns them once checked. A peer only holds the signatures of
+// the validators it heard from, so the copies of a header returned by the
+// peers are merged before the header is checked against the quorum. Copies
+// that differ are checked apart, so that a peer serving a forged header does
+// not keep the client from the headers of the others. Headers are fetched in
+// as many requests as the peers need to return them all. On error, the
+// headers checked so far are returned along with the error.
+func (v *Verifier) Sync(ctx context.Context, clients []pb.OpenchainClient, trusted *pb.BlockHeader) ([]*pb.BlockHeader, error) {
+	var lastErr error
+	answered := false
+	counts := make([]uint64, len(clients))
+	for i, client := range clients {
+		count, err := client.GetBlockCount(ctx, &google_protobuf.Empty{})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		counts[i] = count.Count
+		answered = true
+	}
+	if !answered {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("No peers to sync from")
+		}
+		return nil, lastErr
+	}
+	var headers []*pb.BlockHeader
+	last := trusted
+	for {
+		copies := make(map[uint64][]*pb.BlockHeader)
+		behind := true
+		for i, client := range clients {
+			if last.Number+1 >= counts[i] {
+				continue
+			}
+			behind = false
+			res, err := client.GetBlockHeaders(ctx, &pb.BlockHeadersRequest{Start: last.Number + 1, End: counts[i] - 1})
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			for _, header := range res.Headers {
+				copies[header.Number] = append(copies[header.Number], header)
+			}
+		}
+		if behind {
+			return headers, nil
+		}
+		if len(copies) == 0 && lastErr != nil {
+			return headers, lastErr
+		}
+		verified, err := v.verifyCopies(last, copies)
+		if len(verified) == 0 {
+			return headers, err
+		}
+		headers = append(headers, verified...)
+		last = verified[len(verified)-1]
+	}
+}
+
+// verifyCopies returns the headers following previous which could be
+// checked, from the copies of each header returned by the peers, along with
+// the error which stopped the check
+func (v *Verifier) verifyCopies(previous *pb.BlockHeader, copies map[uint64][]*pb.BlockHeader) ([]*pb.BlockHeader, error) {
+	var verified []*pb.BlockHeader
+	for {
+		header, err := v.verifyMerged(previous, copies[previous.Number+1])
+		if err != nil {
+			return verified, err
+		}
+		verified = append(verified, header)
+		previous = header
+	}
+}
+
+// verifyMerged merges the signatures of the copies of a header which have
+// the same digest, and returns the first merged header that follows previous
+// and is signed by a quorum
+func (v *Verifier) verifyMerged(previous *pb.BlockHeader, copies []*pb.BlockHeader) (*pb.BlockHeader, error) {
+	err := fmt.Errorf("Peers returned no headers after block %d", previous.Number)
+	var digests []string
+	groups := make(map[string][]*pb.BlockHeader)
+	for _, header := range copies {
+		digest, digestErr := header.Digest()
+		if digestErr != nil {
+			err = digestErr
+			continue
+		}
+		key := string(digest)
+		if _, ok := groups[key]; !ok {
+			digests = append(digests, key)
+		}
+		groups[key] = append(groups[key], header)
+	}
+	for _, key := range digests {
+		merged, mergeErr := MergeSignatures(groups[key]...)
+		if mergeErr != nil {
+			err = mergeErr
+			continue
+		}
+		if err = v.VerifyChain(previous, []*pb.BlockHeader{merged}); err == nil {
+			return merged, nil
+		}
+	}
+	return nil, err
+}
+
+// MergeSignatures returns a header carrying the signatures of all the copies
+// of a header, as returned by different peers. Each peer records only the
+// signatures of the validators it knows of, a light client gathers a quorum
+// from several peers. A signature held by several peers is only kept once.
+func MergeSignatures(headers ...*pb.BlockHeader) (*pb.BlockHeader, error) {
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("No headers to merge")
+	}
+	merged := *headers[0]
+	merged.Signatures = nil
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		if header.Number != merged.Number || !bytes.Equal(header.Hash, merged.Hash) {
+			return nil, fmt.Errorf("Headers of block %d differ", merged.Number)
+		}
+		for _, signature := range header.Signatures {
+			key := fmt.Sprintf("%q %x", signature.Validator, signature.Signature)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged.Signatures = append(merged.Signatures, signature)
+		}
+	}
+	return &merged, nil
+}
+
+// VerifyTransactionProof checks that the transaction of a proof is in the
+// block with the header of the proof, signed by a quorum of validators
+func (v *Verifier) VerifyTransactionProof(proof *pb.TransactionProof) error {
+	if err := v.VerifyHeader(proof.Header); err != nil {
+		return err
+	}
+	return VerifyTransactionProof(proof)
+}
+
+// VerifyTransactionProof checks that the transaction of a proof is in the
+// block with the header of the proof: the encoded block rebuilt from the
+// proof hashes to the hash of the header, and holds the transaction at the
+// index of the proof. The header itself is not checked.
+func VerifyTransactionProof(proof *pb.TransactionProof) error {
+	if proof.Transaction == nil || proof.Header == nil {
+		return fmt.Errorf("Transaction proof is incomplete")
+	}
+	field, err := pb.EncodeTransactionField(proof.Transaction)
+	if err != nil {
+		return err
+	}
+	data := append(append(append([]byte{}, proof.Prefix...), field...), proof.Suffix...)
+	if !bytes.Equal(util.ComputeCryptoHash(data), proof.Header.Hash) {
+		return fmt.Errorf("Transaction proof does not hash to the hash of block %d", proof.Header.Number)
+	}
+	block, err := pb.UnmarshallBlock(data)
+	if err != nil {
+		return err
+	}
+	if int(proof.Index) >= len(block.Transactions) || !proto.Equal(block.Transactions[proof.Index], proof.Transaction) {
+		return fmt.Errorf("Transaction %s is not at index %d of block %d", proof.Transaction.Uuid, proof.Index, proof.Header.Number)
+	}
+	if !bytes.Equal(block.StateHash, proof.Header.StateHash) || !bytes.Equal(block.PreviousBlockHash, proof.Header.PreviousBlockHash) {
+		return fmt.Errorf("Header of block %d does not match the block of the transaction proof", proof.Header.Number)
+	}
+	return nil
+}
+
+// VerifyStateProof checks that a state proof returned by a peer proves the
+// value of the key in the state of the block with the header, signed by a
+// quorum of validators
+func (v *Verifier) VerifyStateProof(header *pb.BlockHeader, stateProof *pb.StateProof, verify ProofVerifier) error {
+	if err := v.VerifyHeader(header); err != nil {
+		return err
+	}
+	return VerifyStateProof(header, stateProof, verify)
+}
+
+// VerifyStateProof checks that a state proof returned by a peer is a proof
+// against the state hash of a header the client checked, and that it proves
+// the value of the key. The header itself is not checked.
+func VerifyStateProof(header *pb.BlockHeader, stateProof *pb.StateProof, verify ProofVerifier) error {
+	if stateProof.BlockNumber != header.Number {
+		return fmt.Errorf("State proof is for block %d, not block %d", stateProof.BlockNumber, header.Number)
+	}
+	if !bytes.Equal(stateProof.StateHash, header.StateHash) {
+		return fmt.Errorf("State hash of proof does not match the state hash of block %d", header.Number)
+	}
+	return verify(stateProof.StateHash, stateProof.ChaincodeID, stateProof.Key, stateProof.Value, stateProof.Proof)
+}
@@ -0,0 +1,282 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package lightclient
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	google_protobuf "google/protobuf"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// sign returns the signature of a block header by a validator, as checked by
+// verify
+func sign(validator string, header *pb.BlockHeader) *pb.BlockSignature {
+	digest, _ := header.Digest()
+	return &pb.BlockSignature{Validator: validator, Signature: append([]byte(validator+":"), digest...)}
+}
+
+func verify(validator string, signature []byte, message []byte) error {
+	if !bytes.Equal(signature, append([]byte(validator+":"), message...)) {
+		return fmt.Errorf("Bad signature of %s", validator)
+	}
+	return nil
+}
+
+// makeChain returns the headers of n blocks, each signed by the validators
+func makeChain(n int, validators ...string) []*pb.BlockHeader {
+	headers := make([]*pb.BlockHeader, n)
+	var previousHash []byte
+	for i := range headers {
+		header := &pb.BlockHeader{Number: uint64(i), Hash: []byte(fmt.Sprintf("hash%d", i)), PreviousBlockHash: previousHash,
+			StateHash: []byte(fmt.Sprintf("state%d", i))}
+		for _, validator := range validators {
+			header.Signatures = append(header.Signatures, sign(validator, header))
+		}
+		headers[i] = header
+		previousHash = header.Hash
+	}
+	return headers
+}
+
+func TestVerifyHeader(t *testing.T) {
+	v := NewVerifier(verify, 2)
+	header := makeChain(1, "vp0", "vp1")[0]
+	if err := v.VerifyHeader(header); err != nil {
+		t.Fatalf("Error verifying header signed by a quorum: %s", err)
+	}
+
+	// Duplicate and invalid signatures do not count
+	other := *header
+	other.Number++
+	header.Signatures = []*pb.BlockSignature{sign("vp0", header), sign("vp0", header), sign("vp1", &other)}
+	if err := v.VerifyHeader(header); err == nil {
+		t.Fatalf("Expected error verifying header signed by a single validator")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	v := NewVerifier(verify, 1)
+	headers := makeChain(4, "vp0")
+	if err := v.VerifyChain(headers[0], headers[1:]); err != nil {
+		t.Fatalf("Error verifying chain: %s", err)
+	}
+	if err := v.VerifyChain(headers[0], headers[2:]); err == nil {
+		t.Fatalf("Expected error verifying chain with a missing block")
+	}
+
+	forged := *headers[2]
+	forged.PreviousBlockHash = []byte("forged")
+	if err := v.VerifyChain(headers[0], []*pb.BlockHeader{headers[1], &forged}); err == nil {
+		t.Fatalf("Expected error verifying chain with a block not linked to the previous one")
+	}
+}
+
+func TestVerifyHeader_Tampered(t *testing.T) {
+	v := NewVerifier(verify, 1)
+	// The signatures cover every field of the header, not only the hash
+	for _, tamper := range []func(*pb.BlockHeader){
+		func(header *pb.BlockHeader) { header.Number++ },
+		func(header *pb.BlockHeader) { header.StateHash = []byte("forged") },
+		func(header *pb.BlockHeader) { header.PreviousBlockHash = []byte("forged") },
+	} {
+		header := makeChain(1, "vp0")[0]
+		tamper(header)
+		if err := v.VerifyHeader(header); err == nil {
+			t.Fatalf("Expected error verifying tampered header %v", header)
+		}
+	}
+}
+
+func TestMergeSignatures(t *testing.T) {
+	header0 := makeChain(1, "vp0")[0]
+	header1 := makeChain(1, "vp1")[0]
+	merged, err := MergeSignatures(header0, header1)
+	if err != nil {
+		t.Fatalf("Error merging signatures: %s", err)
+	}
+	if err := NewVerifier(verify, 2).VerifyHeader(merged); err != nil {
+		t.Fatalf("Error verifying merged header: %s", err)
+	}
+	if len(header0.Signatures) != 1 {
+		t.Fatalf("Expected merging to leave the headers unchanged")
+	}
+	if merged, _ = MergeSignatures(header0, header0, header1); len(merged.Signatures) != 2 {
+		t.Fatalf("Expected the signatures held by several peers once, got %d signatures", len(merged.Signatures))
+	}
+
+	other := makeChain(2, "vp1")[1]
+	if _, err := MergeSignatures(header0, other); err == nil {
+		t.Fatalf("Expected error merging the headers of different blocks")
+	}
+}
+
+func TestVerifyStateProof(t *testing.T) {
+	header := makeChain(1, "vp0")[0]
+	accept := func(stateHash []byte, chaincodeID string, key string, value []byte, proof []byte) error { return nil }
+	stateProof := &pb.StateProof{BlockNumber: 0, StateHash: header.StateHash}
+	if err := VerifyStateProof(header, stateProof, accept); err != nil {
+		t.Fatalf("Error verifying state proof: %s", err)
+	}
+	stateProof.StateHash = []byte("other")
+	if err := VerifyStateProof(header, stateProof, accept); err == nil {
+		t.Fatalf("Expected error verifying state proof against another state hash")
+	}
+
+	// A header whose state hash was replaced is not signed by the validators
+	forged := *header
+	forged.StateHash = []byte("other")
+	if err := NewVerifier(verify, 1).VerifyStateProof(&forged, stateProof, accept); err == nil {
+		t.Fatalf("Expected error verifying state proof against a forged header")
+	}
+	stateProof.StateHash = header.StateHash
+	if err := NewVerifier(verify, 1).VerifyStateProof(header, stateProof, accept); err != nil {
+		t.Fatalf("Error verifying state proof against a signed header: %s", err)
+	}
+}
+
+func TestVerifyTransactionProof(t *testing.T) {
+	var transactions []*pb.Transaction
+	for _, uuid := range []string{"tx0", "tx1", "tx2"} {
+		tx, _ := pb.NewTransaction(pb.ChaincodeID{Path: "mycc"}, uuid, "invoke", []string{"a"})
+		transactions = append(transactions, tx)
+	}
+	block := pb.NewBlock(transactions, nil)
+	block.StateHash = []byte("stateHash")
+	block.PreviousBlockHash = []byte("previousBlockHash")
+	proof, err := block.GetTransactionProof(3, 1)
+	if err != nil {
+		t.Fatalf("Error building transaction proof: %s", err)
+	}
+	proof.Header.Signatures = []*pb.BlockSignature{sign("vp0", proof.Header)}
+
+	v := NewVerifier(verify, 1)
+	if err := v.VerifyTransactionProof(proof); err != nil {
+		t.Fatalf("Error verifying transaction proof: %s", err)
+	}
+	if err := NewVerifier(verify, 2).VerifyTransactionProof(proof); err == nil {
+		t.Fatalf("Expected error verifying transaction proof with a header not signed by a quorum")
+	}
+
+	// Another transaction, or the same transaction at another index, is not proven
+	proof.Transaction = transactions[2]
+	if err := VerifyTransactionProof(proof); err == nil {
+		t.Fatalf("Expected error verifying the proof of another transaction")
+	}
+	proof.Transaction = transactions[1]
+	proof.Index = 0
+	if err := VerifyTransactionProof(proof); err == nil {
+		t.Fatalf("Expected error verifying transaction proof with the wrong index")
+	}
+}
+
+// pagingClient serves the headers of a chain, two at a time
+type pagingClient struct {
+	pb.OpenchainClient
+	headers []*pb.BlockHeader
+}
+
+func (c *pagingClient) GetBlockCount(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*pb.BlockCount, error) {
+	return &pb.BlockCount{Count: uint64(len(c.headers))}, nil
+}
+
+func (c *pagingClient) GetBlockHeaders(ctx context.Context, in *pb.BlockHeadersRequest, opts ...grpc.CallOption) (*pb.BlockHeaders, error) {
+	end := in.End
+	if end > in.Start+1 {
+		end = in.Start + 1
+	}
+	return &pb.BlockHeaders{Headers: c.headers[in.Start : end+1]}, nil
+}
+
+func TestSync(t *testing.T) {
+	headers := makeChain(5, "vp0")
+	client := &pagingClient{headers: headers}
+	synced, err := NewVerifier(verify, 1).Sync(context.Background(), []pb.OpenchainClient{client}, headers[0])
+	if err != nil {
+		t.Fatalf("Error syncing headers: %s", err)
+	}
+	if len(synced) != 4 || synced[3].Number != 4 {
+		t.Fatalf("Expected the headers of blocks 1 to 4, got %v", synced)
+	}
+
+	// Unsigned headers are refused
+	if _, err := NewVerifier(verify, 2).Sync(context.Background(), []pb.OpenchainClient{client}, headers[0]); err == nil {
+		t.Fatalf("Expected error syncing headers not signed by a quorum")
+	}
+}
+
+// signedBy returns copies of headers carrying the signatures of validators
+func signedBy(headers []*pb.BlockHeader, validators ...string) []*pb.BlockHeader {
+	copies := make([]*pb.BlockHeader, len(headers))
+	for i, header := range headers {
+		signed := *header
+		signed.Signatures = nil
+		for _, validator := range validators {
+			signed.Signatures = append(signed.Signatures, sign(validator, header))
+		}
+		copies[i] = &signed
+	}
+	return copies
+}
+
+func TestSync_MergesPeers(t *testing.T) {
+	headers := makeChain(5)
+	// No peer holds a quorum of signatures on its own
+	clients := []pb.OpenchainClient{
+		&pagingClient{headers: signedBy(headers, "vp0", "vp1")},
+		&pagingClient{headers: signedBy(headers[:4], "vp1", "vp2")},
+	}
+	v := NewVerifier(verify, 3)
+	// Block 4 is only served by the first peer, without a quorum
+	synced, err := v.Sync(context.Background(), clients, headers[0])
+	if err == nil {
+		t.Fatalf("Expected error syncing a header not signed by a quorum")
+	}
+	if len(synced) != 3 || synced[2].Number != 3 {
+		t.Fatalf("Expected the headers of blocks 1 to 3, got %v", synced)
+	}
+
+	clients[1] = &pagingClient{headers: signedBy(headers, "vp1", "vp2")}
+	if synced, err = v.Sync(context.Background(), clients, headers[0]); err != nil {
+		t.Fatalf("Error syncing headers from several peers: %s", err)
+	}
+	if len(synced) != 4 || synced[3].Number != 4 {
+		t.Fatalf("Expected the headers of blocks 1 to 4, got %v", synced)
+	}
+
+	// A peer serving forged headers does not keep the client from syncing
+	forged := signedBy(headers, "vp3")
+	for _, header := range forged {
+		header.StateHash = []byte("forged")
+	}
+	clients = []pb.OpenchainClient{&pagingClient{headers: forged}, clients[0], clients[1]}
+	if synced, err = v.Sync(context.Background(), clients, headers[0]); err != nil {
+		t.Fatalf("Error syncing headers alongside a forging peer: %s", err)
+	}
+	if len(synced) != 4 || !bytes.Equal(synced[0].StateHash, headers[1].StateHash) {
+		t.Fatalf("Expected the headers signed by the validators, got %v", synced)
+	}
+}
@@ -20,8 +20,13 @@ under the License.
 package openchain
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
@@ -83,6 +88,122 @@ func LoggingInit(command string) {
 	// Set the default logging level for all modules
 	logging.SetLevel(defaultLevel, "")
 	loggingLogger.Debug("Setting default logging level to %s for command '%s'", defaultLevel, command)
+
+	// The levels set at runtime through the admin service outlive restarts
+	// of the peer
+	if command == "peer" {
+		overrides, err := loadLoggingOverrides()
+		if err != nil {
+			loggingLogger.Warning("Logging overrides not applied: %s", err)
+		}
+		for module, level := range overrides {
+			if err := setModuleLevel(module, level); err != nil {
+				loggingLogger.Warning("Logging override of module '%s' ignored: %s", module, err)
+			}
+		}
+	}
+}
+
+// ValidateLoggingSpec checks a logging specification of the form
+//     [<module>[,<module>...]=]<level>[:[<module>[,<module>...]=]<level>...]
+// which LoggingInit otherwise applies with warnings about invalid fields
+func ValidateLoggingSpec(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	for _, field := range strings.Split(spec, ":") {
+		split := strings.Split(field, "=")
+		switch len(split) {
+		case 1:
+			if _, err := logging.LogLevel(field); err != nil {
+				return fmt.Errorf("Logging level '%s' not recognized", field)
+			}
+		case 2:
+			if _, err := logging.LogLevel(split[1]); err != nil {
+				return fmt.Errorf("Invalid logging level in '%s'", field)
+			} else if split[0] == "" {
+				return fmt.Errorf("Invalid logging override specification '%s' - no module specified", field)
+			}
+		default:
+			return fmt.Errorf("Invalid logging override '%s'; Missing ':' ?", field)
+		}
+	}
+	return nil
+}
+
+// loggingOverrides serializes changes of the file of the overrides
+var loggingOverrides sync.Mutex
+
+// loggingOverridesPath returns the file of the logging levels set at runtime
+func loggingOverridesPath() string {
+	return filepath.Join(viper.GetString("peer.fileSystemPath"), "logging.json")
+}
+
+func loadLoggingOverrides() (map[string]string, error) {
+	overrides := make(map[string]string)
+	data, err := ioutil.ReadFile(loggingOverridesPath())
+	if os.IsNotExist(err) {
+		return overrides, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("Error decoding %s: %s", loggingOverridesPath(), err)
+	}
+	return overrides, nil
+}
+
+func setModuleLevel(module, level string) error {
+	logLevel, err := logging.LogLevel(level)
+	if err != nil {
+		return err
+	}
+	logging.SetLevel(logLevel, module)
+	loggingLogger.Debug("Setting logging level for module '%s' to %s", module, logLevel)
+	return nil
+}
+
+// GetLoggingLevel returns the logging level of a module. The empty module is
+// the default level of the modules without a level of their own.
+func GetLoggingLevel(module string) string {
+	return logging.GetLevel(module).String()
+}
+
+// SetLoggingLevel sets the logging level of a module at runtime and saves it
+// so that it is set again when the peer restarts. The level "default"
+// removes the level of the module, which then logs at the default level.
+func SetLoggingLevel(module, level string) (string, error) {
+	loggingOverrides.Lock()
+	defer loggingOverrides.Unlock()
+
+	overrides, err := loadLoggingOverrides()
+	if err != nil {
+		return "", err
+	}
+	if strings.ToLower(level) == "default" {
+		if module == "" {
+			return "", fmt.Errorf("The default logging level cannot be removed")
+		}
+		logging.SetLevel(logging.GetLevel(""), module)
+		delete(overrides, module)
+	} else {
+		if err := setModuleLevel(module, level); err != nil {
+			return "", err
+		}
+		overrides[module] = GetLoggingLevel(module)
+	}
+
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(loggingOverridesPath()), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(loggingOverridesPath(), data, 0644); err != nil {
+		return "", fmt.Errorf("Error saving logging overrides: %s", err)
+	}
+	return GetLoggingLevel(module), nil
 }
 
 // Initiate 'leveled' logging to stderr.
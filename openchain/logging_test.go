@@ -0,0 +1,87 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+func TestSetLoggingLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileSystemPath := viper.GetString("peer.fileSystemPath")
+	viper.Set("peer.fileSystemPath", dir)
+	defer viper.Set("peer.fileSystemPath", fileSystemPath)
+	defer logging.SetLevel(logging.GetLevel(""), "testmodule")
+
+	logging.SetLevel(logging.WARNING, "")
+	if _, err := SetLoggingLevel("testmodule", "nolevel"); err == nil {
+		t.Fatal("Expected an error setting an unknown level")
+	}
+	level, err := SetLoggingLevel("testmodule", "debug")
+	if err != nil {
+		t.Fatalf("Error setting logging level: %s", err)
+	}
+	if level != "DEBUG" || GetLoggingLevel("testmodule") != "DEBUG" {
+		t.Fatalf("Expected DEBUG, got %s", GetLoggingLevel("testmodule"))
+	}
+
+	// The level is set again when the peer restarts
+	logging.SetLevel(logging.INFO, "testmodule")
+	LoggingInit("peer")
+	if GetLoggingLevel("testmodule") != "DEBUG" {
+		t.Fatalf("Expected DEBUG after restart, got %s", GetLoggingLevel("testmodule"))
+	}
+
+	if _, err := SetLoggingLevel("testmodule", "default"); err != nil {
+		t.Fatalf("Error removing logging level: %s", err)
+	}
+	if GetLoggingLevel("testmodule") != GetLoggingLevel("") {
+		t.Fatalf("Expected the default level, got %s", GetLoggingLevel("testmodule"))
+	}
+	overrides, err := loadLoggingOverrides()
+	if err != nil {
+		t.Fatalf("Error loading logging overrides: %s", err)
+	}
+	if _, ok := overrides["testmodule"]; ok {
+		t.Fatal("Expected the logging level of the module to be removed")
+	}
+}
+
+func TestValidateLoggingSpec(t *testing.T) {
+	for _, spec := range []string{"", "info", "warning:main,db=debug:chaincode=info"} {
+		if err := ValidateLoggingSpec(spec); err != nil {
+			t.Fatalf("Expected spec '%s' to be valid: %s", spec, err)
+		}
+	}
+	for _, spec := range []string{"verbose", "main=verbose", "=debug", "main=debug=info"} {
+		if err := ValidateLoggingSpec(spec); err == nil {
+			t.Fatalf("Expected spec '%s' to be invalid", spec)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package metrics keeps measurements of the peer for capacity planning and
+// serves them as JSON from the metrics endpoint.
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/health"
+)
+
+var logger = logging.MustGetLogger("metrics")
+
+var registry = struct {
+	sync.RWMutex
+	metrics map[string]func() interface{}
+}{metrics: make(map[string]func() interface{})}
+
+// RegisterFunc registers a metric whose value is computed by f when the
+// metrics are read. Registering a name again replaces the metric.
+func RegisterFunc(name string, f func() interface{}) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.metrics[name] = f
+}
+
+// Snapshot returns the current value of every metric by name
+func Snapshot() map[string]interface{} {
+	registry.RLock()
+	defer registry.RUnlock()
+	values := make(map[string]interface{}, len(registry.metrics))
+	for name, f := range registry.metrics {
+		if value := f(); value != nil {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// Counter is a metric that only increases, eg. the number of state reads
+type Counter struct {
+	value int64
+}
+
+// NewCounter registers a new counter
+func NewCounter(name string) *Counter {
+	c := &Counter{}
+	RegisterFunc(name, func() interface{} { return c.Value() })
+	return c
+}
+
+// Add increases the counter by delta
+func (c *Counter) Add(delta int64) {
+	atomic.AddInt64(&c.value, delta)
+}
+
+// Inc increases the counter by one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Gauge is a metric that is set to its current value, eg. the block height
+type Gauge struct {
+	value int64
+}
+
+// NewGauge registers a new gauge
+func NewGauge(name string) *Gauge {
+	g := &Gauge{}
+	RegisterFunc(name, func() interface{} { return g.Value() })
+	return g
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(value int64) {
+	atomic.StoreInt64(&g.value, value)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// Summary is a metric observed repeatedly, eg. the duration of block commits
+type Summary struct {
+	sync.Mutex
+	values SummaryValues
+}
+
+// SummaryValues are the values of a summary. Averages are Sum / Count.
+type SummaryValues struct {
+	Count uint64  `json:"count"`
+	Sum   float64 `json:"sum"`
+	Max   float64 `json:"max"`
+	Last  float64 `json:"last"`
+}
+
+// NewSummary registers a new summary
+func NewSummary(name string) *Summary {
+	s := &Summary{}
+	RegisterFunc(name, func() interface{} { return s.Values() })
+	return s
+}
+
+// Observe adds an observed value to the summary
+func (s *Summary) Observe(value float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.values.Count++
+	s.values.Sum += value
+	s.values.Last = value
+	if s.values.Count == 1 || value > s.values.Max {
+		s.values.Max = value
+	}
+}
+
+// Values returns the current values of the summary
+func (s *Summary) Values() SummaryValues {
+	s.Lock()
+	defer s.Unlock()
+	return s.values
+}
+
+// ServeHTTP writes the snapshot of the metrics as JSON
+func ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Snapshot()); err != nil {
+		logger.Error("Error encoding metrics: %s", err)
+	}
+}
+
+// StartServer serves the metrics at /metrics of metrics.address, and the
+// health of the peer at /healthz and /readyz, if metrics.enabled is set. It
+// blocks until the server fails.
+func StartServer() {
+	if !viper.GetBool("metrics.enabled") {
+		return
+	}
+	address := viper.GetString("metrics.address")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", ServeHTTP)
+	mux.HandleFunc("/healthz", health.ServeLiveness)
+	mux.HandleFunc("/readyz", health.ServeReadiness)
+	logger.Info("Serving metrics on %s", address)
+	if err := http.ListenAndServe(address, mux); err != nil {
+		logger.Error("Error serving metrics: %s", err)
+	}
+}
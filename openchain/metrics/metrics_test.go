@@ -0,0 +1,69 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	counter := NewCounter("test.counter")
+	gauge := NewGauge("test.gauge")
+	summary := NewSummary("test.summary")
+	RegisterFunc("test.func", func() interface{} { return "value" })
+	RegisterFunc("test.nil", func() interface{} { return nil })
+
+	counter.Inc()
+	counter.Add(2)
+	gauge.Set(5)
+	gauge.Set(4)
+	summary.Observe(3)
+	summary.Observe(1)
+
+	snapshot := Snapshot()
+	if snapshot["test.counter"] != int64(3) {
+		t.Fatalf("Expected counter 3, got %v", snapshot["test.counter"])
+	}
+	if snapshot["test.gauge"] != int64(4) {
+		t.Fatalf("Expected gauge 4, got %v", snapshot["test.gauge"])
+	}
+	expected := SummaryValues{Count: 2, Sum: 4, Max: 3, Last: 1}
+	if snapshot["test.summary"] != expected {
+		t.Fatalf("Expected summary %v, got %v", expected, snapshot["test.summary"])
+	}
+	if snapshot["test.func"] != "value" {
+		t.Fatalf("Expected func value, got %v", snapshot["test.func"])
+	}
+	if _, ok := snapshot["test.nil"]; ok {
+		t.Fatalf("Expected nil metric to be left out")
+	}
+
+	recorder := httptest.NewRecorder()
+	ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	served := make(map[string]interface{})
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil {
+		t.Fatalf("Error parsing served metrics: %s", err)
+	}
+	if served["test.gauge"] != float64(4) {
+		t.Fatalf("Expected served gauge 4, got %v", served["test.gauge"])
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/consensus"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// UpdateNetworkConfig submits a configuration transaction scheduling a
+// change of a network parameter at a block height above the current one.
+// Validators only execute configuration transactions signed with the ECert
+// of an administrator when security is enabled. The peer holds no such
+// certificate, so with security the administrator builds the transaction
+// with crypto.BuildConfigTransaction, signs it and relays it with
+// SubmitTransaction instead.
+func (d *Devops) UpdateNetworkConfig(ctx context.Context, update *pb.NetworkConfigUpdate) (*pb.Response, error) {
+	if viper.GetBool("security.enabled") {
+		return nil, fmt.Errorf("With security enabled configuration transactions must be signed by an administrator and relayed with SubmitTransaction")
+	}
+	if err := netconfig.ValidateParameter(update.Parameter, update.Value); err != nil {
+		return nil, err
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	if height := ledger.GetBlockchainSize(); update.EffectiveHeight <= height {
+		return nil, fmt.Errorf("Effective height %d is not above the current blockchain height %d", update.EffectiveHeight, height)
+	}
+	if err := peer.CheckIntake(); err != nil {
+		return nil, err
+	}
+
+	spec := &pb.ChaincodeInvocationSpec{ChaincodeSpec: &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG,
+		ChaincodeID: &pb.ChaincodeID{Name: netconfig.Name},
+		CtorMsg:     &pb.ChaincodeInput{Function: "update", Args: []string{update.Parameter, update.Value, strconv.FormatUint(update.EffectiveHeight, 10)}}}}
+	uuid := util.GenerateUUID()
+	transaction, err := pb.NewChaincodeExecute(spec, uuid, pb.Transaction_CHAINCODE_CONFIG)
+	if err != nil {
+		return nil, err
+	}
+	// Configuration transactions get the highest priority the policy allows
+	transaction.Priority = consensus.MaxPriority(transaction)
+	devopsLogger.Info("Sending configuration transaction %s setting %s from block height %d", uuid, update.Parameter, update.EffectiveHeight)
+	resp := d.coord.ExecuteTransaction(transaction)
+	if resp.Status == pb.Response_FAILURE {
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(uuid)}, nil
+}
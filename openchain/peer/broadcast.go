@@ -0,0 +1,54 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// broadcastUUIDSuffix ends the UUIDs of the transactions sent to several
+// validators, each of which submits its copy to consensus
+const broadcastUUIDSuffix = "-broadcast"
+
+// BroadcastUUID returns the UUID of a transaction sent to several validators,
+// so that the copies reaching consensus are recognized as one transaction
+func BroadcastUUID(uuid string) string {
+	return uuid + broadcastUUIDSuffix
+}
+
+// IsBroadcastUUID returns whether uuid is the UUID of a transaction sent to
+// several validators
+func IsBroadcastUUID(uuid string) bool {
+	return strings.HasSuffix(uuid, broadcastUUIDSuffix)
+}
+
+// SubmitTransaction sends the transaction to the validator at peerAddress,
+// which submits it to consensus. It is handed to the local engine if the
+// validator is this peer.
+func (p *PeerImpl) SubmitTransaction(peerAddress string, transaction *pb.Transaction) *pb.Response {
+	if viper.GetBool("peer.validator.enabled") && peerAddress == getValidatorStreamAddress() {
+		return sendTransactionsToThisPeer(peerAddress, transaction)
+	}
+	return p.SendTransactionsToPeer(peerAddress, transaction)
+}
@@ -0,0 +1,49 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// RecordDeadLetter keeps a transaction that failed in the dead-letter store
+// of the peer, where operators list, resubmit or purge it. blockNumber is
+// the block holding the transaction for failed executions. Failures to
+// record the transaction are only logged.
+func RecordDeadLetter(tx *pb.Transaction, phase pb.DeadLetter_Phase, cause string, blockNumber uint64) {
+	if tx == nil || !viper.GetBool("peer.deadletter.enabled") {
+		return
+	}
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		peerLogger.Error("Failed to record dead letter for transaction %s: %s", tx.Uuid, err)
+		return
+	}
+	letter := &pb.DeadLetter{Transaction: tx, Phase: phase, Error: cause, Timestamp: util.CreateUtcTimestamp(), BlockNumber: blockNumber}
+	if err = ledger.PutDeadLetter(letter); err != nil {
+		peerLogger.Error("Failed to record dead letter for transaction %s: %s", tx.Uuid, err)
+		return
+	}
+	peerLogger.Warning("Transaction %s failed in phase %s and was recorded as dead letter: %s", tx.Uuid, phase, cause)
+}
@@ -29,12 +29,13 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
 // Handler peer handler implementation.
 type Handler struct {
-	chatMutex                     sync.Mutex
+	sender                        *prioritySender
 	ToPeerEndpoint                *pb.PeerEndpoint
 	Coordinator                   MessageHandlerCoordinator
 	ChatStream                    ChatStream
@@ -56,6 +57,7 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 		ChatStream:      stream,
 		initiatedStream: initiatedStream,
 		Coordinator:     coord,
+		sender:          newPrioritySender(),
 	}
 	d.doneChan = make(chan struct{})
 
@@ -67,6 +69,7 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			{Name: pb.OpenchainMessage_DISC_HELLO.String(), Src: []string{"created"}, Dst: "established"},
 			{Name: pb.OpenchainMessage_DISC_GET_PEERS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.OpenchainMessage_DISC_PEERS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.OpenchainMessage_DISC_IDENTITY_UPDATE.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.OpenchainMessage_SYNC_BLOCK_ADDED.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.OpenchainMessage_SYNC_GET_BLOCKS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.OpenchainMessage_SYNC_BLOCKS.String(), Src: []string{"established"}, Dst: "established"},
@@ -80,6 +83,7 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			"before_" + pb.OpenchainMessage_DISC_HELLO.String():              func(e *fsm.Event) { d.beforeHello(e) },
 			"before_" + pb.OpenchainMessage_DISC_GET_PEERS.String():          func(e *fsm.Event) { d.beforeGetPeers(e) },
 			"before_" + pb.OpenchainMessage_DISC_PEERS.String():              func(e *fsm.Event) { d.beforePeers(e) },
+			"before_" + pb.OpenchainMessage_DISC_IDENTITY_UPDATE.String():    func(e *fsm.Event) { d.beforeIdentityUpdate(e) },
 			"before_" + pb.OpenchainMessage_SYNC_BLOCK_ADDED.String():        func(e *fsm.Event) { d.beforeBlockAdded(e) },
 			"before_" + pb.OpenchainMessage_SYNC_GET_BLOCKS.String():         func(e *fsm.Event) { d.beforeSyncGetBlocks(e) },
 			"before_" + pb.OpenchainMessage_SYNC_BLOCKS.String():             func(e *fsm.Event) { d.beforeSyncBlocks(e) },
@@ -159,6 +163,12 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 
 	// If security enabled, need to verify the signature on the hello message
 	if viper.GetBool("security.enabled") {
+		if len(helloMessage.Chain) > 0 {
+			if err := d.Coordinator.GetSecHelper().AddEnrollmentCertChain(helloMessage.Chain); err != nil {
+				e.Cancel(fmt.Errorf("Error validating enrollment certificate chain of received HelloMessage: %s", err))
+				return
+			}
+		}
 		if err := d.Coordinator.GetSecHelper().Verify(helloMessage.PeerEndpoint.PkiID, msg.Signature, msg.Payload); err != nil {
 			e.Cancel(fmt.Errorf("Error Verifying signature for received HelloMessage: %s", err))
 			return
@@ -166,6 +176,13 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 		peerLogger.Debug("Verified signature for %s", e.Event)
 	}
 
+	// Validators must be members of the network once its membership is set
+	// by a configuration transaction
+	if helloMessage.PeerEndpoint.Type == pb.PeerEndpoint_VALIDATOR && !netconfig.IsMember(helloMessage.PeerEndpoint.ID.Name) {
+		e.Cancel(fmt.Errorf("Validator %s is not a member of the network", helloMessage.PeerEndpoint.ID.Name))
+		return
+	}
+
 	if d.initiatedStream == false {
 		// Did NOT intitiate the stream, need to send back HELLO
 		peerLogger.Debug("Received %s, sending back %s", e.Event, pb.OpenchainMessage_DISC_HELLO.String())
@@ -188,6 +205,10 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 		// Registered successfully
 		d.registered = true
 		go d.start()
+		// A non-validating peer that failed over catches up with its new upstream
+		if d.initiatedStream && helloMessage.PeerEndpoint.Type == pb.PeerEndpoint_VALIDATOR && upstream.resumeSync() {
+			go d.resumeBlockSync(helloMessage.BlockchainInfo)
+		}
 	}
 }
 
@@ -234,6 +255,33 @@ func (d *Handler) beforePeers(e *fsm.Event) {
 
 }
 
+func (d *Handler) beforeIdentityUpdate(e *fsm.Event) {
+	peerLogger.Debug("Received %s, parsing out new Peer identification", e.Event)
+	msg, ok := e.Args[0].(*pb.OpenchainMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	if !viper.GetBool("security.enabled") {
+		peerLogger.Debug("Ignoring %s, security is disabled", e.Event)
+		return
+	}
+
+	update := &pb.IdentityUpdateMessage{}
+	if err := proto.Unmarshal(msg.Payload, update); err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling IdentityUpdateMessage: %s", err))
+		return
+	}
+	if err := checkIdentityUpdate(d.ToPeerEndpoint, update, msg.Signature, msg.Payload, d.Coordinator.GetSecHelper()); err != nil {
+		e.Cancel(err)
+		return
+	}
+
+	// Messages of the peer are verified against its new enrollment certificate from now on
+	peerLogger.Info("Peer %s renewed its enrollment", update.PeerEndpoint.ID.Name)
+	d.ToPeerEndpoint = update.PeerEndpoint
+}
+
 func (d *Handler) beforeBlockAdded(e *fsm.Event) {
 	peerLogger.Debug("Received message: %s", e.Event)
 	msg, ok := e.Args[0].(*pb.OpenchainMessage)
@@ -268,16 +316,16 @@ func (d *Handler) HandleMessage(msg *pb.OpenchainMessage) error {
 
 // SendMessage sends a message to the remote PEER through the stream
 func (d *Handler) SendMessage(msg *pb.OpenchainMessage) error {
-	//make sure Sends are serialized. Also make sure everyone uses SendMessage
-	//instead of calling Send directly on the grpc stream
-	d.chatMutex.Lock()
-	defer d.chatMutex.Unlock()
-	peerLogger.Debug("Sending message to stream of type: %s ", msg.Type)
-	err := d.ChatStream.Send(msg)
-	if err != nil {
-		return fmt.Errorf("Error Sending message through ChatStream: %s", err)
-	}
-	return nil
+	//make sure Sends are serialized, by priority class of the messages. Also
+	//make sure everyone uses SendMessage instead of calling Send directly on
+	//the grpc stream
+	return d.sender.send(classOf(msg.Type), func() error {
+		peerLogger.Debug("Sending message to stream of type: %s ", msg.Type)
+		if err := d.ChatStream.Send(msg); err != nil {
+			return fmt.Errorf("Error Sending message through ChatStream: %s", err)
+		}
+		return nil
+	})
 }
 
 // start starts the Peer server function
@@ -424,9 +472,18 @@ func (d *Handler) beforeSyncBlocks(e *fsm.Event) {
 	}
 }
 
+// sendSyncMessage sends a message serving a sync request within the bandwidth
+// of the sync traffic
+func (d *Handler) sendSyncMessage(msg *pb.OpenchainMessage) error {
+	getSyncThrottle().wait(len(msg.Payload))
+	return d.SendMessage(msg)
+}
+
 // sendBlocks sends the blocks based upon the supplied SyncBlockRange over the stream.
 func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 	peerLogger.Debug("Sending blocks %d-%d", syncBlockRange.Start, syncBlockRange.End)
+	release := getSyncThrottle().acquire()
+	defer release()
 	var blockNums []uint64
 	if syncBlockRange.Start > syncBlockRange.End {
 		// Send in reverse order
@@ -455,7 +512,7 @@ func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 			peerLogger.Error(fmt.Sprintf("Error marshalling syncBlocks for BlockNum = %d: %s", currBlockNum, err))
 			break
 		}
-		if err := d.SendMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_BLOCKS, Payload: syncBlocksBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_BLOCKS, Payload: syncBlocksBytes}); err != nil {
 			peerLogger.Error(fmt.Sprintf("Error sending blockNum %d: %s", currBlockNum, err))
 			break
 		}
@@ -555,6 +612,8 @@ func (d *Handler) beforeSyncStateSnapshot(e *fsm.Event) {
 // sendBlocks sends the blocks based upon the supplied SyncBlockRange over the stream.
 func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapshotRequest) {
 	peerLogger.Debug("Sending state snapshot with correlationId = %d", syncStateSnapshotRequest.CorrelationId)
+	release := getSyncThrottle().acquire()
+	defer release()
 
 	snapshot, err := d.Coordinator.GetStateSnapshot()
 	if err != nil {
@@ -583,7 +642,7 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 			peerLogger.Error(fmt.Sprintf("Error marshalling syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err))
 			break
 		}
-		if err := d.SendMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
 			peerLogger.Error(fmt.Sprintf("Error sending syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err))
 			break
 		}
@@ -596,7 +655,7 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 		peerLogger.Error(fmt.Sprintf("Error marshalling terminating syncStateSnapsot message for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err))
 		return
 	}
-	if err := d.SendMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
+	if err := d.sendSyncMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
 		peerLogger.Error(fmt.Sprintf("Error sending terminating syncStateSnapsot for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err))
 		return
 	}
@@ -655,6 +714,8 @@ func (d *Handler) beforeSyncStateGetDeltas(e *fsm.Event) {
 // sendBlocks sends the blocks based upon the supplied SyncBlockRange over the stream.
 func (d *Handler) sendStateDeltas(syncStateDeltasRequest *pb.SyncStateDeltasRequest) {
 	peerLogger.Debug("Sending state deltas for block range %d-%d", syncStateDeltasRequest.Range.Start, syncStateDeltasRequest.Range.End)
+	release := getSyncThrottle().acquire()
+	defer release()
 	var blockNums []uint64
 	syncBlockRange := syncStateDeltasRequest.Range
 	if syncBlockRange.Start > syncBlockRange.End {
@@ -684,7 +745,7 @@ func (d *Handler) sendStateDeltas(syncStateDeltasRequest *pb.SyncStateDeltasRequ
 			peerLogger.Error(fmt.Sprintf("Error marshalling syncStateDeltas for BlockNum = %d: %s", currBlockNum, err))
 			break
 		}
-		if err := d.SendMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_DELTAS, Payload: syncStateDeltasBytes}); err != nil {
+		if err := d.sendSyncMessage(&pb.OpenchainMessage{Type: pb.OpenchainMessage_SYNC_STATE_DELTAS, Payload: syncStateDeltasBytes}); err != nil {
 			peerLogger.Error(fmt.Sprintf("Error sending stateDeltas for blockNum %d: %s", currBlockNum, err))
 			break
 		}
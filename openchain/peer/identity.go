@@ -0,0 +1,134 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/util"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// RotateIdentity re-enrolls this peer with new enrollment keys and announces
+// the new enrollment certificate to the connected peers. The enrollment ID,
+// and with it the identity of the peer in the ledger and in the network
+// membership, is kept; only the PkiID the peers know it by changes.
+func (p *PeerImpl) RotateIdentity() (*pb.IdentityRotation, error) {
+	if !viper.GetBool("security.enabled") || p.secHelper == nil {
+		return nil, fmt.Errorf("Identity rotation requires security to be enabled")
+	}
+
+	previous := p.secHelper.GetID()
+	if err := p.secHelper.RenewEnrollment(); err != nil {
+		return nil, fmt.Errorf("Error renewing enrollment: %s", err)
+	}
+	rotation := &pb.IdentityRotation{EnrollmentID: p.secHelper.GetEnrollmentID(), PreviousPkiID: previous, PkiID: p.secHelper.GetID()}
+	peerLogger.Info("Renewed enrollment of %s, announcing new identity", rotation.EnrollmentID)
+
+	return rotation, p.announceIdentity(previous)
+}
+
+// enrollmentRenewed announces the new identity of this peer once its
+// enrollment certificate has been renewed automatically before expiring
+func (p *PeerImpl) enrollmentRenewed(renewal *crypto.EnrollmentRenewal) {
+	if renewal.Err != nil || renewal.EnrollmentID != p.secHelper.GetEnrollmentID() {
+		return
+	}
+	peerLogger.Info("Enrollment of %s renewed until %s, announcing new identity", renewal.EnrollmentID, renewal.NotAfter)
+
+	if err := p.announceIdentity(renewal.PreviousID); err != nil {
+		peerLogger.Warning("%s", err)
+	}
+}
+
+// announceIdentity broadcasts the current enrollment certificate of this peer,
+// replacing the one with previousPkiID
+func (p *PeerImpl) announceIdentity(previousPkiID []byte) error {
+	msg, err := p.newIdentityUpdateMessage(previousPkiID)
+	if err != nil {
+		return err
+	}
+	// Peers that miss the update learn the new identity from the next HELLO
+	for _, err := range p.Broadcast(msg, pb.PeerEndpoint_UNDEFINED) {
+		peerLogger.Warning("Error announcing new identity: %s", err)
+	}
+
+	return nil
+}
+
+// newIdentityUpdateMessage returns the signed announcement of the current
+// enrollment certificate of this peer, replacing the one with previousPkiID
+func (p *PeerImpl) newIdentityUpdateMessage(previousPkiID []byte) (*pb.OpenchainMessage, error) {
+	endpoint, err := p.GetPeerEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating identity update message: %s", err)
+	}
+	update := &pb.IdentityUpdateMessage{PeerEndpoint: endpoint, PreviousPkiID: previousPkiID, Chain: p.secHelper.GetEnrollmentCertChain()}
+	data, err := proto.Marshal(update)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling IdentityUpdateMessage: %s", err)
+	}
+	// Signed with the new enrollment key, which proves the peer holds it
+	msg := &pb.OpenchainMessage{Type: pb.OpenchainMessage_DISC_IDENTITY_UPDATE, Payload: data, Timestamp: util.CreateUtcTimestamp()}
+	return p.signOpenchainMessageMutating(msg)
+}
+
+// checkIdentityUpdate verifies that update, received with signature over
+// payload, replaces the enrollment certificate of the peer at current by a
+// newer one of the same enrollment ID
+func checkIdentityUpdate(current *pb.PeerEndpoint, update *pb.IdentityUpdateMessage, signature, payload []byte, secHelper crypto.Peer) error {
+	next := update.PeerEndpoint
+	if current == nil || current.ID == nil {
+		return fmt.Errorf("No peer endpoint for handler")
+	}
+	if next == nil || next.ID == nil || next.ID.Name != current.ID.Name || next.Type != current.Type {
+		return fmt.Errorf("Identity update is not for peer %s", current.ID.Name)
+	}
+	if !bytes.Equal(update.PreviousPkiID, current.PkiID) || bytes.Equal(next.PkiID, current.PkiID) {
+		return fmt.Errorf("Identity update of peer %s does not replace its current identity", current.ID.Name)
+	}
+	if len(update.Chain) > 0 {
+		if err := secHelper.AddEnrollmentCertChain(update.Chain); err != nil {
+			return fmt.Errorf("Error validating enrollment certificate chain of peer %s: %s", current.ID.Name, err)
+		}
+	}
+	if err := secHelper.Verify(next.PkiID, signature, payload); err != nil {
+		return fmt.Errorf("Error verifying signature of identity update of peer %s: %s", current.ID.Name, err)
+	}
+
+	previousID, err := secHelper.GetEnrollmentIDOf(current.PkiID)
+	if err != nil {
+		return fmt.Errorf("Error getting enrollment ID of peer %s: %s", current.ID.Name, err)
+	}
+	nextID, err := secHelper.GetEnrollmentIDOf(next.PkiID)
+	if err != nil {
+		return fmt.Errorf("Error getting enrollment ID of peer %s: %s", current.ID.Name, err)
+	}
+	if previousID != nextID {
+		return fmt.Errorf("Identity update of peer %s changes its enrollment ID from %s to %s", current.ID.Name, previousID, nextID)
+	}
+
+	return nil
+}
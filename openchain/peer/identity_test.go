@@ -0,0 +1,88 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// testSecHelper verifies the signatures of the keys it knows the enrollment
+// IDs of, signatures being the PkiID of the signer
+type testSecHelper struct {
+	crypto.Peer
+	enrollIDs map[string]string
+}
+
+func (s *testSecHelper) Verify(vkID, signature, message []byte) error {
+	if _, ok := s.enrollIDs[string(vkID)]; !ok || !bytes.Equal(vkID, signature) {
+		return fmt.Errorf("Invalid signature")
+	}
+	return nil
+}
+
+func (s *testSecHelper) GetEnrollmentIDOf(vkID []byte) (string, error) {
+	id, ok := s.enrollIDs[string(vkID)]
+	if !ok {
+		return "", fmt.Errorf("Unknown identity")
+	}
+	return id, nil
+}
+
+func TestCheckIdentityUpdate(t *testing.T) {
+	sec := &testSecHelper{enrollIDs: map[string]string{"old": "vp1", "new": "vp1", "other": "vp2"}}
+	current := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("old")}
+	endpoint := func(name, pkiID string) *pb.PeerEndpoint {
+		return &pb.PeerEndpoint{ID: &pb.PeerID{Name: name}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte(pkiID)}
+	}
+
+	update := &pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp1", "new"), PreviousPkiID: []byte("old")}
+	if err := checkIdentityUpdate(current, update, []byte("new"), []byte("payload"), sec); err != nil {
+		t.Fatalf("Expected the identity update to be accepted: %s", err)
+	}
+
+	invalid := []struct {
+		update    *pb.IdentityUpdateMessage
+		signature string
+	}{
+		// signed with the previous key instead of the new one
+		{&pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp1", "new"), PreviousPkiID: []byte("old")}, "old"},
+		// not replacing the identity the peer is known by
+		{&pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp1", "new"), PreviousPkiID: []byte("stale")}, "new"},
+		{&pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp1", "old"), PreviousPkiID: []byte("old")}, "old"},
+		// for another peer, or taking over another enrollment ID
+		{&pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp2", "new"), PreviousPkiID: []byte("old")}, "new"},
+		{&pb.IdentityUpdateMessage{PeerEndpoint: endpoint("vp1", "other"), PreviousPkiID: []byte("old")}, "other"},
+		{&pb.IdentityUpdateMessage{PreviousPkiID: []byte("old")}, "new"},
+	}
+	for i, c := range invalid {
+		if err := checkIdentityUpdate(current, c.update, []byte(c.signature), []byte("payload"), sec); err == nil {
+			t.Fatalf("Expected identity update %d to be refused", i)
+		}
+	}
+
+	if err := checkIdentityUpdate(nil, update, []byte("new"), []byte("payload"), sec); err == nil {
+		t.Fatal("Expected an identity update before the HELLO to be refused")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// intake gates the transactions the peer accepts from clients and other
+// peers, and tracks those in flight until they are committed, so that the
+// peer can be paused and drained for maintenance
+var intake = struct {
+	sync.Mutex
+	paused   bool
+	inFlight map[string]bool
+	drained  *sync.Cond
+}{inFlight: make(map[string]bool)}
+
+func init() {
+	intake.drained = sync.NewCond(&intake.Mutex)
+}
+
+// ErrIntakePaused is returned for transactions submitted while the peer is
+// paused
+var ErrIntakePaused = fmt.Errorf("Peer is paused and does not accept transactions")
+
+// BeginTransaction accepts a transaction as in flight until EndTransaction
+// is called with its UUID, or returns ErrNotReady or ErrIntakePaused
+func BeginTransaction(uuid string) error {
+	if !Ready() {
+		return ErrNotReady
+	}
+	intake.Lock()
+	defer intake.Unlock()
+	if intake.paused {
+		return ErrIntakePaused
+	}
+	intake.inFlight[uuid] = true
+	return nil
+}
+
+// EndTransaction marks a transaction as no longer in flight, ie. committed or
+// failed. Transactions not accepted by this peer are ignored.
+func EndTransaction(uuid string) {
+	intake.Lock()
+	defer intake.Unlock()
+	delete(intake.inFlight, uuid)
+	if len(intake.inFlight) == 0 {
+		intake.drained.Broadcast()
+	}
+}
+
+// IntakePaused returns whether the peer is paused
+func IntakePaused() bool {
+	intake.Lock()
+	defer intake.Unlock()
+	return intake.paused
+}
+
+// CheckIntake returns ErrNotReady while the peer is starting up and
+// ErrIntakePaused while it is paused, nil if it accepts transactions
+func CheckIntake() error {
+	if !Ready() {
+		return ErrNotReady
+	}
+	if IntakePaused() {
+		return ErrIntakePaused
+	}
+	return nil
+}
+
+// InFlightTransactions returns the number of transactions in flight
+func InFlightTransactions() int {
+	intake.Lock()
+	defer intake.Unlock()
+	return len(intake.inFlight)
+}
+
+// PauseIntake stops the peer from accepting transactions. Transactions in
+// flight are still processed.
+func PauseIntake() {
+	intake.Lock()
+	defer intake.Unlock()
+	intake.paused = true
+}
+
+// ResumeIntake makes the peer accept transactions again
+func ResumeIntake() {
+	intake.Lock()
+	defer intake.Unlock()
+	intake.paused = false
+}
+
+// DrainIntake pauses the peer and waits until the transactions in flight are
+// done or the timeout expires. It returns the number of transactions still
+// in flight.
+func DrainIntake(timeout time.Duration) int {
+	intake.Lock()
+	defer intake.Unlock()
+	intake.paused = true
+	timer := time.AfterFunc(timeout, func() {
+		intake.Lock()
+		defer intake.Unlock()
+		intake.drained.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	for len(intake.inFlight) > 0 && time.Now().Before(deadline) {
+		intake.drained.Wait()
+	}
+	return len(intake.inFlight)
+}
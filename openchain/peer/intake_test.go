@@ -0,0 +1,64 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntake(t *testing.T) {
+	defer ResumeIntake()
+
+	if err := BeginTransaction("tx1"); err != nil {
+		t.Fatalf("Error beginning transaction: %s", err)
+	}
+	BeginTransaction("tx2")
+	if InFlightTransactions() != 2 {
+		t.Fatalf("Expected 2 transactions in flight, got %d", InFlightTransactions())
+	}
+
+	// Draining pauses the peer and times out while transactions are in flight
+	if remaining := DrainIntake(10 * time.Millisecond); remaining != 2 {
+		t.Fatalf("Expected 2 transactions still in flight, got %d", remaining)
+	}
+	if !IntakePaused() {
+		t.Fatal("Expected the peer to be paused")
+	}
+	if err := BeginTransaction("tx3"); err != ErrIntakePaused {
+		t.Fatalf("Expected transactions to be refused while paused, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		EndTransaction("tx1")
+		EndTransaction("unknown")
+		EndTransaction("tx2")
+	}()
+	if remaining := DrainIntake(5 * time.Second); remaining != 0 {
+		t.Fatalf("Expected the transactions to be drained, got %d", remaining)
+	}
+
+	ResumeIntake()
+	if err := BeginTransaction("tx3"); err != nil {
+		t.Fatalf("Expected transactions to be accepted after resuming, got %s", err)
+	}
+	EndTransaction("tx3")
+}
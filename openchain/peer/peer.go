@@ -104,6 +104,8 @@ type MessageHandlerCoordinator interface {
 	GetRemoteLedger(receiver *pb.PeerID) (RemoteLedger, error)
 	PeersDiscovered(*pb.PeersMessage) error
 	ExecuteTransaction(transaction *pb.Transaction) *pb.Response
+	SimulateTransaction(peerAddress string, transaction *pb.Transaction) *pb.Response
+	SubmitTransaction(peerAddress string, transaction *pb.Transaction) *pb.Response
 }
 
 // ChatStream interface supported by stream between Peers
@@ -261,6 +263,7 @@ func NewPeerWithHandler(handlerFact HandlerFactory) (*PeerImpl, error) {
 				return nil, err
 			}
 		}
+		crypto.AddRenewalHandler(peer.enrollmentRenewed)
 	}
 
 	ledgerPtr, err := ledger.GetLedger()
@@ -268,7 +271,12 @@ func NewPeerWithHandler(handlerFact HandlerFactory) (*PeerImpl, error) {
 		return nil, fmt.Errorf("Error constructing NewPeerWithHandler: %s", err)
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
-	go peer.chatWithPeer(viper.GetString("peer.discovery.rootnode"))
+	if viper.GetBool("peer.validator.enabled") {
+		go peer.chatWithPeer(viper.GetString("peer.discovery.rootnode"))
+	} else if addresses := upstreamAddresses(); len(addresses) > 0 {
+		upstream.configure(addresses, viper.GetInt("peer.discovery.upstream.maxFailures"))
+		go peer.chatWithUpstream()
+	}
 	return peer, nil
 }
 
@@ -420,6 +428,28 @@ func (p *PeerImpl) Unicast(msg *pb.OpenchainMessage, receiverHandle *pb.PeerID)
 
 // SendTransactionsToPeer current temporary mechanism of forwarding transactions to the configured Validator.
 func (p *PeerImpl) SendTransactionsToPeer(peerAddress string, transaction *pb.Transaction) *pb.Response {
+	return p.sendTransactionToPeer(peerAddress, transaction, getTransactionMessageType(transaction))
+}
+
+// SimulateTransaction has the validator at peerAddress execute the transaction
+// without keeping its state changes. On success the response holds the hash
+// of the state changes.
+func (p *PeerImpl) SimulateTransaction(peerAddress string, transaction *pb.Transaction) *pb.Response {
+	if viper.GetBool("peer.validator.enabled") && peerAddress == getValidatorStreamAddress() {
+		return sendTransactionToThisPeer(peerAddress, transaction, pb.OpenchainMessage_CHAIN_SIMULATE)
+	}
+	return p.sendTransactionToPeer(peerAddress, transaction, pb.OpenchainMessage_CHAIN_SIMULATE)
+}
+
+//get the type of message transactions are sent to validators in
+func getTransactionMessageType(transaction *pb.Transaction) pb.OpenchainMessage_Type {
+	if transaction.Type == pb.Transaction_CHAINCODE_EXECUTE || transaction.Type == pb.Transaction_CHAINCODE_NEW || transaction.Type == pb.Transaction_CHAINCODE_CONFIG || transaction.Type == pb.Transaction_CHAINCODE_KEY_ROTATION {
+		return pb.OpenchainMessage_CHAIN_TRANSACTION
+	}
+	return pb.OpenchainMessage_CHAIN_QUERY
+}
+
+func (p *PeerImpl) sendTransactionToPeer(peerAddress string, transaction *pb.Transaction, ttyp pb.OpenchainMessage_Type) *pb.Response {
 	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
 	if err != nil {
 		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error creating client to peer address=%s:  %s", peerAddress, err))}
@@ -472,13 +502,6 @@ func (p *PeerImpl) SendTransactionsToPeer(peerAddress string, transaction *pb.Tr
 					return
 				}
 
-				var ttyp pb.OpenchainMessage_Type
-				if transaction.Type == pb.Transaction_CHAINCODE_EXECUTE || transaction.Type == pb.Transaction_CHAINCODE_NEW {
-					ttyp = pb.OpenchainMessage_CHAIN_TRANSACTION
-				} else {
-					ttyp = pb.OpenchainMessage_CHAIN_QUERY
-				}
-
 				msg := &pb.OpenchainMessage{Type: ttyp, Payload: payload, Timestamp: util.CreateUtcTimestamp()}
 				peerLogger.Debug("Sending message %s with timestamp %v to Peer %s", msg.Type, msg.Timestamp, peerAddress)
 				if err = stream.Send(msg); err != nil {
@@ -513,6 +536,10 @@ func (p *PeerImpl) SendTransactionsToPeer(peerAddress string, transaction *pb.Tr
 
 // SendTransactionsToPeer current temporary mechanism of forwarding transactions to the configured Validator
 func sendTransactionsToThisPeer(peerAddress string, transaction *pb.Transaction) *pb.Response {
+	return sendTransactionToThisPeer(peerAddress, transaction, getTransactionMessageType(transaction))
+}
+
+func sendTransactionToThisPeer(peerAddress string, transaction *pb.Transaction, ttyp pb.OpenchainMessage_Type) *pb.Response {
 	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
 	if err != nil {
 		return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error sending transactions to peer address=%s:  %s", peerAddress, err))}
@@ -564,13 +591,6 @@ func sendTransactionsToThisPeer(peerAddress string, transaction *pb.Transaction)
 		}
 	}()
 
-	var ttyp pb.OpenchainMessage_Type
-	if transaction.Type == pb.Transaction_CHAINCODE_EXECUTE || transaction.Type == pb.Transaction_CHAINCODE_NEW {
-		ttyp = pb.OpenchainMessage_CHAIN_TRANSACTION
-	} else {
-		ttyp = pb.OpenchainMessage_CHAIN_QUERY
-	}
-
 	msg := &pb.OpenchainMessage{Type: ttyp, Payload: data, Timestamp: util.CreateUtcTimestamp()}
 	peerLogger.Debug("Sending message %s with timestamp %v to self", msg.Type, msg.Timestamp)
 	if err = stream.Send(msg); err != nil {
@@ -646,6 +666,8 @@ func getValidatorStreamAddress() string {
 	localaddr, _ := GetLocalAddress()
 	if viper.GetBool("peer.validator.enabled") { // in validator mode, send your own address
 		return localaddr
+	} else if valaddr := upstream.address(); valaddr != "" {
+		return valaddr
 	} else if valaddr := viper.GetString("peer.discovery.rootnode"); valaddr != "" {
 		return valaddr
 	}
@@ -659,8 +681,16 @@ func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) *pb.Response
 	if viper.GetBool("peer.validator.enabled") { // send gRPC request to yourself
 		response = sendTransactionsToThisPeer(peerAddress, transaction)
 
+	} else if transaction.Type == pb.Transaction_CHAINCODE_QUERY {
+		response = p.SendTransactionsToPeer(peerAddress, transaction)
 	} else {
+		// Validators track the transaction until it is committed, a
+		// non-validating peer until it is sent
+		if err := BeginTransaction(transaction.Uuid); err != nil {
+			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+		}
 		response = p.SendTransactionsToPeer(peerAddress, transaction)
+		EndTransaction(transaction.Uuid)
 	}
 
 	return response
@@ -688,7 +718,12 @@ func (p *PeerImpl) newHelloMessage() (*pb.HelloMessage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error creating hello message, error getting block chain info: %s", err)
 	}
-	return &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}, nil
+	hello := &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}
+	if viper.GetBool("security.enabled") {
+		// Lets peers enrolled by other ECAs of the PKI validate our enrollment certificate
+		hello.Chain = p.GetSecHelper().GetEnrollmentCertChain()
+	}
+	return hello, nil
 }
 
 // GetBlockByNumber return a block by block number
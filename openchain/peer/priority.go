@@ -0,0 +1,161 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/metrics"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// messageClass is the priority class of a message sent to a peer. A message
+// queued behind the one being sent on a stream goes before the queued
+// messages of lower classes.
+type messageClass int
+
+const (
+	gossipClass messageClass = iota
+	syncClass
+	consensusClass
+	numMessageClasses
+)
+
+var messageClassNames = [numMessageClasses]string{"gossip", "sync", "consensus"}
+
+func (c messageClass) String() string {
+	return messageClassNames[c]
+}
+
+// ErrMessageDropped is returned for gossip messages dropped because the
+// stream is busy with messages of higher classes
+var ErrMessageDropped = errors.New("Gossip message dropped, the stream is busy with higher priority messages")
+
+var (
+	sendQueued        [numMessageClasses]int64
+	sendWaitMetrics   [numMessageClasses]*metrics.Summary
+	gossipDropsMetric = metrics.NewCounter("peer.gossipDrops")
+)
+
+func init() {
+	for class := messageClass(0); class < numMessageClasses; class++ {
+		sendWaitMetrics[class] = metrics.NewSummary("peer.sendWaitMillis." + class.String())
+	}
+	metrics.RegisterFunc("peer.sendQueueDepth", func() interface{} {
+		depths := make(map[string]int64)
+		for class := messageClass(0); class < numMessageClasses; class++ {
+			depths[class.String()] = atomic.LoadInt64(&sendQueued[class])
+		}
+		return depths
+	})
+}
+
+// classOf returns the priority class of the messages of type msgType.
+// Discovery gossip is the lowest class and bulk sync traffic the middle one.
+// The consensus, transaction and connection control messages are never held
+// up by either.
+func classOf(msgType pb.OpenchainMessage_Type) messageClass {
+	switch msgType {
+	case pb.OpenchainMessage_DISC_GET_PEERS, pb.OpenchainMessage_DISC_PEERS, pb.OpenchainMessage_DISC_NEWMSG:
+		return gossipClass
+	case pb.OpenchainMessage_SYNC_GET_BLOCKS, pb.OpenchainMessage_SYNC_BLOCKS, pb.OpenchainMessage_SYNC_BLOCK_ADDED,
+		pb.OpenchainMessage_SYNC_STATE_GET_SNAPSHOT, pb.OpenchainMessage_SYNC_STATE_SNAPSHOT,
+		pb.OpenchainMessage_SYNC_STATE_GET_DELTAS, pb.OpenchainMessage_SYNC_STATE_DELTAS:
+		return syncClass
+	}
+	return consensusClass
+}
+
+// prioritySender serializes the sends on a stream. While a message is being
+// sent the others wait in a queue per class, and the stream is handed to the
+// first queued message of the highest class next.
+type prioritySender struct {
+	sync.Mutex
+	busy       bool
+	queues     [numMessageClasses][]chan error
+	maxGossip  int  // queued gossip messages before dropping, 0 for no limit
+	dropOldest bool // drop the oldest queued gossip message rather than the new one
+}
+
+func newPrioritySender() *prioritySender {
+	return &prioritySender{
+		maxGossip:  viper.GetInt("peer.messaging.gossip.maxQueued"),
+		dropOldest: viper.GetString("peer.messaging.gossip.dropPolicy") == "oldest",
+	}
+}
+
+// send calls send once it is the turn of a message of class, and returns
+// its error, or ErrMessageDropped if the message is dropped
+func (s *prioritySender) send(class messageClass, send func() error) error {
+	s.Lock()
+	if !s.busy {
+		s.busy = true
+		s.Unlock()
+		sendWaitMetrics[class].Observe(0)
+	} else {
+		if class == gossipClass && s.maxGossip > 0 && len(s.queues[gossipClass]) >= s.maxGossip {
+			gossipDropsMetric.Inc()
+			if !s.dropOldest {
+				s.Unlock()
+				return ErrMessageDropped
+			}
+			s.dequeue(gossipClass) <- ErrMessageDropped
+		}
+		turn := make(chan error, 1)
+		s.queues[class] = append(s.queues[class], turn)
+		atomic.AddInt64(&sendQueued[class], 1)
+		s.Unlock()
+
+		queued := time.Now()
+		err := <-turn
+		sendWaitMetrics[class].Observe(float64(time.Since(queued)) / float64(time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+	defer s.next()
+	return send()
+}
+
+// next hands the stream to the first queued message of the highest class
+func (s *prioritySender) next() {
+	s.Lock()
+	defer s.Unlock()
+	for class := numMessageClasses - 1; class >= 0; class-- {
+		if len(s.queues[class]) > 0 {
+			s.dequeue(class) <- nil
+			return
+		}
+	}
+	s.busy = false
+}
+
+// dequeue removes the first queued message of class and returns its turn
+func (s *prioritySender) dequeue(class messageClass) chan error {
+	turn := s.queues[class][0]
+	s.queues[class] = s.queues[class][1:]
+	atomic.AddInt64(&sendQueued[class], -1)
+	return turn
+}
@@ -0,0 +1,133 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestClassOf(t *testing.T) {
+	classes := map[pb.OpenchainMessage_Type]messageClass{
+		pb.OpenchainMessage_CONSENSUS:        consensusClass,
+		pb.OpenchainMessage_RESPONSE:         consensusClass,
+		pb.OpenchainMessage_DISC_HELLO:       consensusClass,
+		pb.OpenchainMessage_SYNC_BLOCKS:      syncClass,
+		pb.OpenchainMessage_SYNC_BLOCK_ADDED: syncClass,
+		pb.OpenchainMessage_DISC_PEERS:       gossipClass,
+	}
+	for msgType, class := range classes {
+		if classOf(msgType) != class {
+			t.Errorf("Expected %s to be of class %s, got %s", msgType, class, classOf(msgType))
+		}
+	}
+}
+
+// blockSender makes s busy with a send lasting until the returned function
+// is called
+func blockSender(t *testing.T, s *prioritySender) func() {
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	go s.send(consensusClass, func() error {
+		close(sending)
+		<-release
+		return nil
+	})
+	<-sending
+	return func() { close(release) }
+}
+
+// waitQueued waits until n messages of class are queued on s
+func waitQueued(t *testing.T, s *prioritySender, class messageClass, n int) {
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		queued := len(s.queues[class])
+		s.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d %s messages to be queued", n, class)
+}
+
+func TestPrioritySenderOrder(t *testing.T) {
+	s := &prioritySender{}
+	release := blockSender(t, s)
+
+	sent := make(chan messageClass, 3)
+	for _, class := range []messageClass{gossipClass, syncClass, consensusClass} {
+		go func(class messageClass) {
+			s.send(class, func() error {
+				sent <- class
+				return nil
+			})
+		}(class)
+		waitQueued(t, s, class, 1)
+	}
+	release()
+
+	for _, expected := range []messageClass{consensusClass, syncClass, gossipClass} {
+		if class := <-sent; class != expected {
+			t.Fatalf("Expected a %s message to be sent next, got %s", expected, class)
+		}
+	}
+}
+
+func TestPrioritySenderDropNewest(t *testing.T) {
+	s := &prioritySender{maxGossip: 1}
+	release := blockSender(t, s)
+	defer release()
+
+	go s.send(gossipClass, func() error { return nil })
+	waitQueued(t, s, gossipClass, 1)
+
+	if err := s.send(gossipClass, func() error {
+		t.Fatal("Expected the new gossip message not to be sent")
+		return nil
+	}); err != ErrMessageDropped {
+		t.Fatalf("Expected the new gossip message to be dropped, got %v", err)
+	}
+}
+
+func TestPrioritySenderDropOldest(t *testing.T) {
+	s := &prioritySender{maxGossip: 1, dropOldest: true}
+	release := blockSender(t, s)
+
+	oldest := make(chan error, 1)
+	go func() {
+		oldest <- s.send(gossipClass, func() error { return nil })
+	}()
+	waitQueued(t, s, gossipClass, 1)
+
+	newest := make(chan error, 1)
+	go func() {
+		newest <- s.send(gossipClass, func() error { return nil })
+	}()
+	if err := <-oldest; err != ErrMessageDropped {
+		t.Fatalf("Expected the oldest gossip message to be dropped, got %v", err)
+	}
+	release()
+	if err := <-newest; err != nil {
+		t.Fatalf("Expected the newest gossip message to be sent, got %v", err)
+	}
+}
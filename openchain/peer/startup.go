@@ -0,0 +1,190 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotReady is returned for transactions submitted while the peer is
+// still starting up
+var ErrNotReady = fmt.Errorf("Peer is starting up and does not accept transactions yet")
+
+// readiness tracks the startup of the peer. A peer that never ran a Startup,
+// eg. in tests, is ready.
+var readiness = struct {
+	sync.Mutex
+	starting bool
+	running  map[string]bool
+}{running: make(map[string]bool)}
+
+// Ready returns whether the peer has finished starting up
+func Ready() bool {
+	readiness.Lock()
+	defer readiness.Unlock()
+	return !readiness.starting
+}
+
+// StartupStages returns the names of the startup stages in progress, sorted
+func StartupStages() []string {
+	readiness.Lock()
+	defer readiness.Unlock()
+	var names []string
+	for name := range readiness.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// startupStage is a step of the startup run once the stages it depends on
+// succeeded
+type startupStage struct {
+	name      string
+	dependsOn []string
+	run       func() error
+	done      chan struct{}
+	err       error
+}
+
+// Startup orders the stages the peer goes through before it accepts
+// transactions, eg. recovering the ledger, enrolling with the membership
+// services and catching up with consensus. Stages run as soon as the stages
+// they depend on succeeded, independent stages concurrently. The peer
+// refuses transactions with ErrNotReady until every stage succeeded.
+type Startup struct {
+	stages map[string]*startupStage
+	order  []string
+}
+
+// NewStartup returns a Startup without stages
+func NewStartup() *Startup {
+	return &Startup{stages: make(map[string]*startupStage)}
+}
+
+// Add adds the stage name, running run once the stages named dependsOn have
+// succeeded
+func (s *Startup) Add(name string, run func() error, dependsOn ...string) {
+	s.stages[name] = &startupStage{name: name, dependsOn: dependsOn, run: run, done: make(chan struct{})}
+	s.order = append(s.order, name)
+}
+
+// Begin marks the peer as starting up, so that it refuses transactions
+// until Run succeeds. It lets the services be started before the stages run.
+func (s *Startup) Begin() {
+	readiness.Lock()
+	defer readiness.Unlock()
+	readiness.starting = true
+}
+
+// Run runs the stages and returns the error of the first stage, in the order
+// they were added, which failed. The peer is ready once Run returns nil.
+func (s *Startup) Run() error {
+	s.Begin()
+	if err := s.validate(); err != nil {
+		return err
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range s.order {
+		wg.Add(1)
+		go func(stage *startupStage) {
+			defer wg.Done()
+			defer close(stage.done)
+			for _, dep := range stage.dependsOn {
+				<-s.stages[dep].done
+				if s.stages[dep].err != nil {
+					stage.err = fmt.Errorf("Startup stage %s not run, %s failed", stage.name, dep)
+					return
+				}
+			}
+			stage.err = runStartupStage(stage)
+		}(s.stages[name])
+	}
+	wg.Wait()
+	for _, name := range s.order {
+		if err := s.stages[name].err; err != nil {
+			return err
+		}
+	}
+	readiness.Lock()
+	readiness.starting = false
+	readiness.Unlock()
+	peerLogger.Info("Peer ready after %s", time.Since(start))
+	return nil
+}
+
+// validate checks that the stages depend on known stages without cycles
+func (s *Startup) validate() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("Startup stage %s depends on itself", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range s.stages[name].dependsOn {
+			if _, ok := s.stages[dep]; !ok {
+				return fmt.Errorf("Startup stage %s depends on unknown stage %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+	for _, name := range s.order {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runStartupStage(stage *startupStage) error {
+	readiness.Lock()
+	readiness.running[stage.name] = true
+	readiness.Unlock()
+	defer func() {
+		readiness.Lock()
+		delete(readiness.running, stage.name)
+		readiness.Unlock()
+	}()
+	peerLogger.Info("Startup stage %s running", stage.name)
+	start := time.Now()
+	if err := stage.run(); err != nil {
+		peerLogger.Error("Startup stage %s failed: %s", stage.name, err)
+		return fmt.Errorf("Startup stage %s failed: %s", stage.name, err)
+	}
+	peerLogger.Info("Startup stage %s done after %s", stage.name, time.Since(start))
+	return nil
+}
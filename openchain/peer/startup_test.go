@@ -0,0 +1,127 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartup(t *testing.T) {
+	var lock sync.Mutex
+	var order []string
+	record := func(name string) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, name)
+	}
+	release := make(chan struct{})
+
+	startup := NewStartup()
+	startup.Add("consensus", func() error {
+		record("consensus")
+		return nil
+	}, "ledger", "membership")
+	startup.Add("ledger", func() error {
+		<-release
+		record("ledger")
+		return nil
+	})
+	startup.Add("membership", func() error {
+		record("membership")
+		return nil
+	})
+
+	done := make(chan error)
+	go func() { done <- startup.Run() }()
+	// membership does not wait for the ledger, consensus waits for both
+	for {
+		lock.Lock()
+		recorded := len(order)
+		lock.Unlock()
+		if stages := StartupStages(); recorded == 1 && len(stages) == 1 && stages[0] == "ledger" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if Ready() {
+		t.Fatal("Expected the peer not to be ready while starting up")
+	}
+	if err := BeginTransaction("tx1"); err != ErrNotReady {
+		t.Fatalf("Expected transactions to be refused while starting up, got %v", err)
+	}
+	if err := CheckIntake(); err != ErrNotReady {
+		t.Fatalf("Expected the intake to report the startup, got %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Error starting up: %s", err)
+	}
+	if strings.Join(order, ",") != "membership,ledger,consensus" {
+		t.Fatalf("Expected stages to run after their dependencies, got %v", order)
+	}
+	if !Ready() || len(StartupStages()) != 0 {
+		t.Fatal("Expected the peer to be ready")
+	}
+	if err := BeginTransaction("tx1"); err != nil {
+		t.Fatalf("Expected transactions to be accepted once ready, got %s", err)
+	}
+	EndTransaction("tx1")
+}
+
+func TestStartupFailure(t *testing.T) {
+	defer func() {
+		readiness.Lock()
+		readiness.starting = false
+		readiness.Unlock()
+	}()
+
+	ran := false
+	startup := NewStartup()
+	startup.Add("ledger", func() error { return fmt.Errorf("corrupt") })
+	startup.Add("consensus", func() error {
+		ran = true
+		return nil
+	}, "ledger")
+	if err := startup.Run(); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Fatalf("Expected the failure of the ledger stage, got %v", err)
+	}
+	if ran {
+		t.Fatal("Expected the stages depending on a failed stage not to run")
+	}
+	if Ready() {
+		t.Fatal("Expected the peer not to be ready after a failed startup")
+	}
+
+	startup = NewStartup()
+	startup.Add("a", func() error { return nil }, "b")
+	startup.Add("b", func() error { return nil }, "a")
+	if err := startup.Run(); err == nil {
+		t.Fatal("Expected stages depending on each other to be refused")
+	}
+	startup = NewStartup()
+	startup.Add("a", func() error { return nil }, "missing")
+	if err := startup.Run(); err == nil {
+		t.Fatal("Expected a dependency on an unknown stage to be refused")
+	}
+}
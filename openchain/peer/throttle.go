@@ -0,0 +1,97 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// syncThrottle limits the bandwidth and the number of requests this peer
+// spends serving blocks and state to peers catching up, so that they do not
+// saturate the links used by consensus
+type syncThrottle struct {
+	sync.Mutex
+	bandwidth float64 // bytes per second, 0 for no limit
+	burst     float64
+	tokens    float64
+	last      time.Time
+	streams   chan struct{} // nil for no limit
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+var syncLimits struct {
+	sync.Once
+	throttle *syncThrottle
+}
+
+// getSyncThrottle returns the throttle of the sync traffic, from the
+// peer.sync.throttle settings
+func getSyncThrottle() *syncThrottle {
+	syncLimits.Do(func() {
+		syncLimits.throttle = newSyncThrottle(viper.GetFloat64("peer.sync.throttle.bandwidth"),
+			viper.GetInt("peer.sync.throttle.burst"), viper.GetInt("peer.sync.throttle.maxStreams"))
+	})
+	return syncLimits.throttle
+}
+
+func newSyncThrottle(bandwidth float64, burst int, maxStreams int) *syncThrottle {
+	t := &syncThrottle{bandwidth: bandwidth, burst: float64(burst), tokens: float64(burst), now: time.Now, sleep: time.Sleep}
+	t.last = t.now()
+	if maxStreams > 0 {
+		t.streams = make(chan struct{}, maxStreams)
+	}
+	return t
+}
+
+// acquire waits until fewer than maxStreams sync requests are being served
+// and returns the function releasing the request
+func (t *syncThrottle) acquire() func() {
+	if t.streams == nil {
+		return func() {}
+	}
+	t.streams <- struct{}{}
+	return func() { <-t.streams }
+}
+
+// wait waits until size bytes may be sent within the bandwidth. Messages
+// larger than the burst are sent after the time the bandwidth takes for them.
+func (t *syncThrottle) wait(size int) {
+	if t.bandwidth <= 0 {
+		return
+	}
+	t.Lock()
+	now := t.now()
+	t.tokens += now.Sub(t.last).Seconds() * t.bandwidth
+	if t.tokens > t.burst {
+		t.tokens = t.burst
+	}
+	t.last = now
+	t.tokens -= float64(size)
+	deficit := -t.tokens
+	t.Unlock()
+
+	if deficit > 0 {
+		t.sleep(time.Duration(deficit / t.bandwidth * float64(time.Second)))
+	}
+}
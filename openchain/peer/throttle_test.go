@@ -0,0 +1,85 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncThrottleBandwidth(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	throttle := newSyncThrottle(1000, 500, 0)
+	throttle.now = func() time.Time { return now }
+	throttle.last = now
+	throttle.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+
+	throttle.wait(500)
+	if slept != 0 {
+		t.Fatalf("Expected the burst to be sent at once, slept %s", slept)
+	}
+	throttle.wait(250)
+	if slept != 250*time.Millisecond {
+		t.Fatalf("Expected to wait 250ms for the bandwidth, slept %s", slept)
+	}
+	now = now.Add(time.Hour)
+	throttle.wait(2000)
+	if slept != 1750*time.Millisecond {
+		t.Fatalf("Expected a message larger than the burst to wait for the rest, slept %s", slept)
+	}
+}
+
+func TestSyncThrottleUnlimited(t *testing.T) {
+	throttle := newSyncThrottle(0, 0, 0)
+	throttle.sleep = func(d time.Duration) {
+		t.Fatalf("Expected no wait without a bandwidth, waited %s", d)
+	}
+	throttle.wait(1 << 20)
+	for i := 0; i < 10; i++ {
+		throttle.acquire()
+	}
+}
+
+func TestSyncThrottleStreams(t *testing.T) {
+	throttle := newSyncThrottle(0, 0, 1)
+	release := throttle.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		throttle.acquire()()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected a second sync request to wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the second sync request to be served once the first was released")
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	"github.com/openblockchain/obc-peer/openchain/ledger/statemgmt"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// upstreamSelector tracks the validating peers a non-validating peer may
+// connect to, and which of them it currently uses as its upstream
+type upstreamSelector struct {
+	sync.Mutex
+	addresses   []string
+	current     int
+	failures    int
+	maxFailures int
+	resume      bool // the blocks committed meanwhile are synced after failing over
+}
+
+// upstream is the upstream of this peer, empty for validators
+var upstream = &upstreamSelector{}
+
+// upstreamAddresses returns the rootnode followed by the other configured
+// upstream validating peers, without duplicates
+func upstreamAddresses() []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	candidates := append([]string{viper.GetString("peer.discovery.rootnode")}, viper.GetStringSlice("peer.discovery.upstream.addresses")...)
+	for _, address := range candidates {
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// configure sets the validating peers to select from, starting with the first
+func (u *upstreamSelector) configure(addresses []string, maxFailures int) {
+	u.Lock()
+	defer u.Unlock()
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	u.addresses = addresses
+	u.current = 0
+	u.failures = 0
+	u.maxFailures = maxFailures
+	u.resume = false
+}
+
+// address returns the address of the current upstream, empty if none is
+// configured
+func (u *upstreamSelector) address() string {
+	u.Lock()
+	defer u.Unlock()
+	if len(u.addresses) == 0 {
+		return ""
+	}
+	return u.addresses[u.current]
+}
+
+// succeeded records that the upstream at address is reachable
+func (u *upstreamSelector) succeeded(address string) {
+	u.Lock()
+	defer u.Unlock()
+	if len(u.addresses) > 0 && u.addresses[u.current] == address {
+		u.failures = 0
+	}
+}
+
+// failed records that the upstream at address could not be reached, and fails
+// over to the next configured validating peer once it failed maxFailures times
+// in a row. It returns whether it failed over.
+func (u *upstreamSelector) failed(address string) bool {
+	u.Lock()
+	defer u.Unlock()
+	if len(u.addresses) == 0 || u.addresses[u.current] != address {
+		// A failure of an upstream already abandoned
+		return false
+	}
+	u.failures++
+	if u.failures < u.maxFailures || len(u.addresses) == 1 {
+		return false
+	}
+	u.current = (u.current + 1) % len(u.addresses)
+	u.failures = 0
+	u.resume = true
+	peerLogger.Warning("Upstream validating peer %s unreachable, failing over to %s", address, u.addresses[u.current])
+	return true
+}
+
+// resumeSync returns whether the blocks need to be synced from the upstream
+// after a failover, and clears the need
+func (u *upstreamSelector) resumeSync() bool {
+	u.Lock()
+	defer u.Unlock()
+	resume := u.resume
+	u.resume = false
+	return resume
+}
+
+// chatWithUpstream keeps this non-validating peer connected to its upstream
+// validating peer, failing over to the next configured one when it becomes
+// unreachable
+func (p *PeerImpl) chatWithUpstream() {
+	for {
+		time.Sleep(1 * time.Second)
+		peerAddress := upstream.address()
+		peerLogger.Debug("Initiating Chat with upstream peer address: %s", peerAddress)
+		conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error creating connection to upstream peer address=%s:  %s", peerAddress, err))
+			upstream.failed(peerAddress)
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := pb.NewPeerClient(conn).Chat(ctx)
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error establishing chat with upstream peer address=%s:  %s", peerAddress, err))
+			cancel()
+			conn.Close()
+			upstream.failed(peerAddress)
+			continue
+		}
+		upstream.succeeded(peerAddress)
+		peerLogger.Debug("Established Chat with upstream peer address: %s", peerAddress)
+		go checkUpstream(ctx, cancel, peerAddress)
+		p.handleChat(ctx, stream, true)
+		stream.CloseSend()
+		cancel()
+		conn.Close()
+	}
+}
+
+// checkUpstream periodically checks that the upstream at peerAddress can
+// still be reached, and cancels the chat with it once it failed over
+func checkUpstream(ctx context.Context, cancel context.CancelFunc, peerAddress string) {
+	period := viper.GetDuration("peer.discovery.upstream.healthCheckPeriod")
+	if period <= 0 {
+		return
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+			if err != nil {
+				peerLogger.Warning("Health check of upstream peer address=%s failed: %s", peerAddress, err)
+				if upstream.failed(peerAddress) {
+					cancel()
+					return
+				}
+				continue
+			}
+			conn.Close()
+			upstream.succeeded(peerAddress)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// resumeBlockSync syncs the blocks the upstream committed beyond the local
+// blockchain height, along with their state deltas
+func (d *Handler) resumeBlockSync(info *pb.BlockchainInfo) {
+	ledgerPtr, err := ledger.GetLedger()
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+		return
+	}
+	height := ledgerPtr.GetBlockchainSize()
+	if info == nil || info.Height <= height {
+		return
+	}
+	peerLogger.Info("Resuming block sync from height %d to %d", height, info.Height)
+	syncBlockRange := &pb.SyncBlockRange{Start: height, End: info.Height - 1}
+	blocks, err := d.RequestBlocks(syncBlockRange)
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+		return
+	}
+	deltas, err := d.RequestStateDeltas(syncBlockRange)
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+		return
+	}
+	timeout := viper.GetDuration("peer.discovery.upstream.syncTimeout")
+	for blockNumber := height; blockNumber < info.Height; blockNumber++ {
+		block, err := receiveSyncBlock(blocks, blockNumber, timeout)
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+			return
+		}
+		delta, err := receiveSyncStateDelta(deltas, blockNumber, timeout)
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+			return
+		}
+		if err := applySyncedBlock(ledgerPtr, blockNumber, block, delta); err != nil {
+			peerLogger.Error(fmt.Sprintf("Error resuming block sync: %s", err))
+			return
+		}
+	}
+	peerLogger.Info("Synced blocks up to height %d", info.Height)
+}
+
+// receiveSyncBlock waits for block blockNumber on blocks
+func receiveSyncBlock(blocks <-chan *pb.SyncBlocks, blockNumber uint64, timeout time.Duration) (*pb.Block, error) {
+	for {
+		select {
+		case syncBlocks, ok := <-blocks:
+			if !ok {
+				return nil, fmt.Errorf("Block channel closed before receiving block %d", blockNumber)
+			}
+			if syncBlocks.Range.Start == blockNumber && len(syncBlocks.Blocks) > 0 {
+				return syncBlocks.Blocks[0], nil
+			}
+		case <-time.After(timeout):
+			return nil, fmt.Errorf("Timed out waiting for block %d", blockNumber)
+		}
+	}
+}
+
+// receiveSyncStateDelta waits for the state delta of block blockNumber on deltas
+func receiveSyncStateDelta(deltas <-chan *pb.SyncStateDeltas, blockNumber uint64, timeout time.Duration) (*statemgmt.StateDelta, error) {
+	for {
+		select {
+		case syncStateDeltas, ok := <-deltas:
+			if !ok {
+				return nil, fmt.Errorf("State delta channel closed before receiving the delta of block %d", blockNumber)
+			}
+			if syncStateDeltas.Range.Start != blockNumber || len(syncStateDeltas.Deltas) == 0 {
+				continue
+			}
+			delta := &statemgmt.StateDelta{}
+			if err := delta.Unmarshal(syncStateDeltas.Deltas[0]); err != nil {
+				return nil, fmt.Errorf("Corrupt state delta for block %d: %s", blockNumber, err)
+			}
+			return delta, nil
+		case <-time.After(timeout):
+			return nil, fmt.Errorf("Timed out waiting for the state delta of block %d", blockNumber)
+		}
+	}
+}
+
+// applySyncedBlock plays the state forward by delta, and appends block to the
+// blockchain if the resulting state hash matches the block's
+func applySyncedBlock(ledgerPtr *ledger.Ledger, blockNumber uint64, block *pb.Block, delta *statemgmt.StateDelta) error {
+	if err := ledgerPtr.ApplyStateDelta(block, delta); err != nil {
+		return err
+	}
+	stateHash, err := ledgerPtr.GetTempStateHash()
+	if err != nil {
+		ledgerPtr.RollbackStateDelta(block)
+		return err
+	}
+	if !bytes.Equal(stateHash, block.StateHash) {
+		ledgerPtr.RollbackStateDelta(block)
+		return fmt.Errorf("State hash of block %d does not match the state played forward", blockNumber)
+	}
+	if err := ledgerPtr.CommitStateDelta(block); err != nil {
+		return err
+	}
+	return ledgerPtr.PutRawBlock(block, blockNumber)
+}
@@ -0,0 +1,77 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package peer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestUpstreamAddresses(t *testing.T) {
+	viper.Set("peer.discovery.rootnode", "vp0:30303")
+	viper.Set("peer.discovery.upstream.addresses", []string{"vp1:30303", "vp0:30303", "", "vp2:30303"})
+	defer viper.Set("peer.discovery.rootnode", "")
+	defer viper.Set("peer.discovery.upstream.addresses", []string{})
+
+	expected := []string{"vp0:30303", "vp1:30303", "vp2:30303"}
+	if addresses := upstreamAddresses(); !reflect.DeepEqual(addresses, expected) {
+		t.Fatalf("Expected upstream addresses %v, got %v", expected, addresses)
+	}
+}
+
+func TestUpstreamFailover(t *testing.T) {
+	u := &upstreamSelector{}
+	u.configure([]string{"vp0", "vp1"}, 2)
+
+	if u.failed("vp0") {
+		t.Fatal("Expected no failover after the first failure")
+	}
+	u.succeeded("vp0")
+	if u.failed("vp0") || u.address() != "vp0" {
+		t.Fatal("Expected a success to reset the failures")
+	}
+	if u.resumeSync() {
+		t.Fatal("Expected no block sync before failing over")
+	}
+	if !u.failed("vp0") || u.address() != "vp1" {
+		t.Fatalf("Expected to fail over to vp1, upstream is %s", u.address())
+	}
+	if u.failed("vp0") {
+		t.Fatal("Expected failures of an abandoned upstream to be ignored")
+	}
+	if !u.resumeSync() || u.resumeSync() {
+		t.Fatal("Expected a single block sync after failing over")
+	}
+
+	u.failed("vp1")
+	if !u.failed("vp1") || u.address() != "vp0" {
+		t.Fatalf("Expected to fail over back to vp0, upstream is %s", u.address())
+	}
+}
+
+func TestUpstreamSingleAddress(t *testing.T) {
+	u := &upstreamSelector{}
+	u.configure([]string{"vp0"}, 1)
+	if u.failed("vp0") || u.address() != "vp0" {
+		t.Fatal("Expected a single upstream to be kept")
+	}
+}
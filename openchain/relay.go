@@ -0,0 +1,97 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto"
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	"github.com/openblockchain/obc-peer/openchain/tracing"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// SubmitTransaction relays a deploy, execute or configuration transaction the
+// client built and signed itself, typically with crypto.BuildExecuteTransaction
+// and crypto.SignTransaction on a machine that never talks to the network. The
+// signature is checked before the transaction is sent to the validators.
+// Transactions signed with a TCert cannot be linked to an enrollment ID, so
+// on chains whose policy lists principals they must be signed with the ECert.
+// Configuration transactions are only executed when signed with the ECert of
+// an administrator.
+func (d *Devops) SubmitTransaction(ctx context.Context, tx *pb.Transaction) (*pb.Response, error) {
+	if !viper.GetBool("security.enabled") {
+		return nil, fmt.Errorf("Signed transactions can only be submitted with security enabled")
+	}
+	if tx == nil || tx.Uuid == "" {
+		return nil, fmt.Errorf("Invalid transaction, no UUID given")
+	}
+	var action string
+	switch tx.Type {
+	case pb.Transaction_CHAINCODE_NEW:
+		action = chainActionDeploy
+	case pb.Transaction_CHAINCODE_EXECUTE, pb.Transaction_CHAINCODE_CONFIG:
+		action = chainActionInvoke
+	default:
+		return nil, fmt.Errorf("Transactions of type %s cannot be submitted, only deploy, execute and configuration transactions", tx.Type)
+	}
+	if err := db.CheckChainID(tx.ChainID); err != nil {
+		return nil, err
+	}
+	if err := peer.CheckIntake(); err != nil {
+		return nil, err
+	}
+
+	secHelper := d.coord.GetSecHelper()
+	if secHelper == nil {
+		return nil, fmt.Errorf("Security helper not initialized")
+	}
+	if _, err := secHelper.TransactionPreValidation(tx); err != nil {
+		devopsLogger.Warning("Rejected signed transaction %s: %s", tx.Uuid, err)
+		return nil, err
+	}
+	var ecert []byte
+	if _, _, err := crypto.ParseEnrollmentCertificate(tx.Cert); err == nil {
+		ecert = tx.Cert
+	}
+	if err := d.checkChainPolicyCert(tx.ChainID, action, ecert); err != nil {
+		return nil, err
+	}
+
+	span := tracing.StartRootSpan(tx.Uuid, "devops.submit")
+	defer span.Finish()
+	if tx.Type == pb.Transaction_CHAINCODE_EXECUTE && d.simulation != nil {
+		if err := d.simulate(tx); err != nil {
+			peer.RecordDeadLetter(tx, pb.DeadLetter_SIMULATION, err.Error(), 0)
+			return nil, err
+		}
+	}
+	devopsLogger.Debug("Relaying signed transaction (%s) to validator", tx.Uuid)
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		peer.RecordDeadLetter(tx, pb.DeadLetter_SUBMISSION, string(resp.Msg), 0)
+		return nil, fmt.Errorf("%s", resp.Msg)
+	}
+	return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(tx.Uuid)}, nil
+}
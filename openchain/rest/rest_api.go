@@ -20,6 +20,8 @@ under the License.
 package rest
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"google/protobuf"
@@ -39,9 +41,11 @@ import (
 	"github.com/spf13/viper"
 
 	oc "github.com/openblockchain/obc-peer/openchain"
+	"github.com/openblockchain/obc-peer/openchain/access"
 	"github.com/openblockchain/obc-peer/openchain/chaincode"
 	"github.com/openblockchain/obc-peer/openchain/crypto"
 	"github.com/openblockchain/obc-peer/openchain/crypto/utils"
+	"github.com/openblockchain/obc-peer/openchain/health"
 	pb "github.com/openblockchain/obc-peer/protos"
 )
 
@@ -54,6 +58,10 @@ var restLogger = logging.MustGetLogger("rest")
 var serverOpenchain *oc.ServerOpenchain
 var serverDevops *oc.Devops
 
+// restGuard authenticates and limits the clients of the REST service, nil
+// until the service starts
+var restGuard *access.Guard
+
 // ServerOpenchainREST defines the Openchain REST service object. It exposes
 // the methods available on the ServerOpenchain service and the Devops service
 // through a REST API.
@@ -85,11 +93,68 @@ func (s *ServerOpenchainREST) SetResponseType(rw web.ResponseWriter, req *web.Re
 
 	// Enable CORS
 	rw.Header().Set("Access-Control-Allow-Origin", "*")
-	rw.Header().Set("Access-Control-Allow-Headers", "accept, content-type")
+	rw.Header().Set("Access-Control-Allow-Headers", "accept, authorization, content-type, x-admin-cert, x-admin-nonce, x-admin-signature")
+
+	next(rw, req)
+}
+
+// Authorize is a middleware function that authenticates the client of the
+// request, by its verified TLS client certificate or by the bearer token in
+// its Authorization header, and applies the rate limit and the cap on
+// concurrent requests of the client. The liveness and readiness probes are
+// served to anyone.
+func (s *ServerOpenchainREST) Authorize(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	if restGuard == nil || req.URL.Path == "/healthz" || req.URL.Path == "/readyz" {
+		next(rw, req)
+		return
+	}
+
+	client, err := restGuard.HTTPClient(req.Request)
+	if err != nil {
+		rw.Header().Set("WWW-Authenticate", "Bearer")
+		rw.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Warning(fmt.Sprintf("Refused request from %s: %s", req.RemoteAddr, err))
+		return
+	}
+	if err = restGuard.Allow(client); err != nil {
+		rw.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return
+	}
+	release, err := restGuard.Connect(client)
+	if err != nil {
+		rw.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return
+	}
+	defer release()
 
 	next(rw, req)
 }
 
+// restTLSConfig returns the TLS configuration of the REST service. Clients
+// may present a certificate issued by the configured client root
+// certificate to authenticate.
+func restTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	rootFile := viper.GetString("rest.tls.clientRootCert.file")
+	if rootFile == "" {
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(rootFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the client root certificate: %s", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificate found in %s", rootFile)
+	}
+	config.ClientCAs = roots
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	return config, nil
+}
+
 // getRESTFilePath is a helper function to retrieve the local storage directory
 // of client login tokens.
 func getRESTFilePath() string {
@@ -587,6 +652,108 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("Successfuly deployed chainCode: " + chainID + ".\n")
 }
 
+// GetDeploymentStatus returns the progress of the deployment of the specified
+// Chaincode.
+func (s *ServerOpenchainREST) GetDeploymentStatus(rw web.ResponseWriter, req *web.Request) {
+	// Parse out the chaincode name
+	name := req.PathParams["name"]
+
+	status, err := s.devops.GetDeploymentStatus(context.Background(), &pb.ChaincodeID{Name: name})
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Retrieving deployment status -- %s\"}", err))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(rw)
+	encoder.Encode(status)
+}
+
+// ListChaincodes returns the chaincodes known to the peer and what is
+// running of them.
+func (s *ServerOpenchainREST) ListChaincodes(rw web.ResponseWriter, req *web.Request) {
+	chaincodes, err := s.devops.ListChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Listing chaincodes -- %s\"}", err))
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(rw)
+	encoder.Encode(chaincodes)
+}
+
+// GetLiveness returns the status of the components of the peer, with the
+// status 503 if a critical component is unavailable.
+func (s *ServerOpenchainREST) GetLiveness(rw web.ResponseWriter, req *web.Request) {
+	health.ServeLiveness(rw, req.Request)
+}
+
+// GetReadiness returns the status of the components of the peer, with the
+// status 503 if any component is unavailable.
+func (s *ServerOpenchainREST) GetReadiness(rw web.ResponseWriter, req *web.Request) {
+	health.ServeReadiness(rw, req.Request)
+}
+
+// GetLogLevel returns the logging level of a module, or the default level
+// when no module is given.
+func (s *ServerOpenchainREST) GetLogLevel(rw web.ResponseWriter, req *web.Request) {
+	module := req.PathParams["module"]
+
+	rw.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(rw)
+	encoder.Encode(&pb.LogLevel{Module: module, Level: oc.GetLoggingLevel(module)})
+}
+
+// SetLogLevel sets the logging level of a module, or the default level when
+// no module is given, to the level of the JSON payload. With admin
+// authorization the admin changing the level proves its identity with its
+// enrollment certificate in the X-Admin-Cert header, and the signature of
+// the request under its enrollment key in the X-Admin-Signature header, both
+// base64 encoded, the nonce signed being in the X-Admin-Nonce header. The
+// signature covers the ID of this peer, see oc.AdminSigningBytes.
+func (s *ServerOpenchainREST) SetLogLevel(rw web.ResponseWriter, req *web.Request) {
+	module := req.PathParams["module"]
+
+	creds, err := oc.ParseAdminCredentials(req.Header.Get("X-Admin-Cert"), req.Header.Get("X-Admin-Nonce"), req.Header.Get("X-Admin-Signature"))
+	if err == nil {
+		err = serverDevops.AuthorizeAdmin(creds, oc.AdminActionSetLogLevel, module)
+	}
+	if err != nil {
+		errVal := strings.Replace(err.Error(), "\"", "'", -1)
+		rw.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Setting log level -- %s\"}", errVal))
+		return
+	}
+
+	var logLevel pb.LogLevel
+	if err := jsonpb.Unmarshal(req.Body, &logLevel); err != nil {
+		errVal := strings.Replace(err.Error(), "\"", "'", -1)
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"Payload must contain object LogLevel with level field -- %s\"}", errVal)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Decoding log level -- %s\"}", errVal))
+		return
+	}
+
+	level, err := oc.SetLoggingLevel(module, logLevel.Level)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Setting log level -- %s\"}", err))
+		return
+	}
+	restLogger.Info("Logging level of module '%s' set to %s", module, level)
+
+	rw.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(rw)
+	encoder.Encode(&pb.LogLevel{Module: module, Level: level})
+}
+
 // Invoke executes a specified function within a target Chaincode.
 func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 	restLogger.Info("REST invoking chaincode...")
@@ -894,6 +1061,10 @@ func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request)
 // NotFound returns a custom landing page when a given openchain end point
 // had not been defined.
 func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		writeErrorV2(rw, http.StatusNotFound, "Openchain endpoint not found.")
+		return
+	}
 	rw.WriteHeader(http.StatusNotFound)
 	fmt.Fprintf(rw, "{\"Error\": \"Openchain endpoint not found.\"}")
 }
@@ -908,10 +1079,12 @@ func StartOpenchainRESTServer(server *oc.ServerOpenchain, devops *oc.Devops) {
 	// Record the pointer to the underlying ServerOpenchain and Devops objects.
 	serverOpenchain = server
 	serverDevops = devops
+	restGuard = access.NewGuard(access.LoadConfig("rest"))
 
 	// Add middleware
 	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
 	router.Middleware((*ServerOpenchainREST).SetResponseType)
+	router.Middleware((*ServerOpenchainREST).Authorize)
 
 	// Add routes
 	router.Post("/registrar", (*ServerOpenchainREST).Register)
@@ -923,6 +1096,8 @@ func StartOpenchainRESTServer(server *oc.ServerOpenchain, devops *oc.Devops) {
 	router.Get("/chain/blocks/:id", (*ServerOpenchainREST).GetBlockByNumber)
 
 	router.Post("/devops/deploy", (*ServerOpenchainREST).Deploy)
+	router.Get("/devops/deploy/:name", (*ServerOpenchainREST).GetDeploymentStatus)
+	router.Get("/devops/chaincodes", (*ServerOpenchainREST).ListChaincodes)
 	router.Post("/devops/invoke", (*ServerOpenchainREST).Invoke)
 	router.Post("/devops/query", (*ServerOpenchainREST).Query)
 
@@ -930,11 +1105,31 @@ func StartOpenchainRESTServer(server *oc.ServerOpenchain, devops *oc.Devops) {
 
 	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
 
+	router.Get("/healthz", (*ServerOpenchainREST).GetLiveness)
+	router.Get("/readyz", (*ServerOpenchainREST).GetReadiness)
+
+	router.Get("/logging", (*ServerOpenchainREST).GetLogLevel)
+	router.Get("/logging/:module", (*ServerOpenchainREST).GetLogLevel)
+	router.Put("/logging", (*ServerOpenchainREST).SetLogLevel)
+	router.Put("/logging/:module", (*ServerOpenchainREST).SetLogLevel)
+
+	// Version 2 of the API
+	addRoutesV2(router)
+
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
 
 	// Start server
-	err := http.ListenAndServe(viper.GetString("rest.address"), router)
+	var err error
+	if viper.GetBool("rest.tls.enabled") {
+		var config *tls.Config
+		if config, err = restTLSConfig(); err == nil {
+			httpServer := &http.Server{Addr: viper.GetString("rest.address"), Handler: router, TLSConfig: config}
+			err = httpServer.ListenAndServeTLS(viper.GetString("rest.tls.cert.file"), viper.GetString("rest.tls.key.file"))
+		}
+	} else {
+		err = http.ListenAndServe(viper.GetString("rest.address"), router)
+	}
 	if err != nil {
 		restLogger.Error(fmt.Sprintf("ListenAndServe: %s", err))
 	}
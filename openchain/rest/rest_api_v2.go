@@ -0,0 +1,746 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"google/protobuf"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/gocraft/web"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/viper"
+
+	oc "github.com/openblockchain/obc-peer/openchain"
+	"github.com/openblockchain/obc-peer/openchain/peer"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// Version 2 of the REST API is served under /v2. Its responses are the JSON
+// encoding of the resources, errors are wrapped in the same envelope, lists
+// of blocks and transactions are paginated, and its OpenAPI document is
+// generated from routesV2 and served at /v2/openapi.json.
+
+const (
+	// defaultPageSize is the number of items of a page if the limit
+	// parameter is not given
+	defaultPageSize = 10
+	// maxPageSize is the largest number of items of a page
+	maxPageSize = 100
+)
+
+// ErrorV2 is the envelope of the errors of the API
+type ErrorV2 struct {
+	Error *ErrorDetailV2 `json:"error"`
+}
+
+// ErrorDetailV2 is the HTTP status of an error and why it happened
+type ErrorDetailV2 struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// BlockPage is a page of blocks, from the newest. Next is the cursor of the
+// next page, empty on the last page.
+type BlockPage struct {
+	Blocks []*pb.Block `json:"blocks"`
+	Next   string      `json:"next,omitempty"`
+}
+
+// TransactionPage is a page of transactions, from the newest. Next is the
+// cursor of the next page, empty on the last page.
+type TransactionPage struct {
+	Transactions []*pb.Transaction `json:"transactions"`
+	Next         string            `json:"next,omitempty"`
+}
+
+// SubmittedTransaction is the result of a deploy or an invoke, which are
+// processed after the response
+type SubmittedTransaction struct {
+	UUID          string `json:"uuid,omitempty"`
+	ChaincodeName string `json:"chaincodeName,omitempty"`
+}
+
+// QueryResult is the result of a query
+type QueryResult struct {
+	Result string `json:"result"`
+}
+
+// paramV2 is a parameter of a route
+type paramV2 struct {
+	name        string
+	in          string // path, query or body
+	description string
+	// schema is the type of a body parameter, or a value of the type of the
+	// other parameters
+	schema interface{}
+}
+
+// routeV2 is a route of the API and its documentation
+type routeV2 struct {
+	method      string
+	path        string // with :name for path parameters
+	operationID string
+	summary     string
+	params      []paramV2
+	status      int
+	response    interface{}
+	handler     func(*ServerOpenchainREST, web.ResponseWriter, *web.Request)
+}
+
+var pageParams = []paramV2{
+	{"cursor", "query", "Cursor of the page, the next of the previous page. The first page starts at the newest item.", ""},
+	{"limit", "query", fmt.Sprintf("Number of items of the page, %d by default and at most %d.", defaultPageSize, maxPageSize), 0},
+}
+
+var routesV2 = []*routeV2{
+	{"GET", "/chain", "getChainV2", "Height and hashes of the blockchain", nil,
+		http.StatusOK, &pb.BlockchainInfo{}, (*ServerOpenchainREST).GetBlockchainInfoV2},
+	{"GET", "/chain/blocks", "listBlocksV2", "Blocks of the blockchain, from the newest", pageParams,
+		http.StatusOK, &BlockPage{}, (*ServerOpenchainREST).ListBlocksV2},
+	{"GET", "/chain/blocks/:number", "getBlockV2", "Block of the blockchain",
+		[]paramV2{{"number", "path", "Number of the block, the genesis block is 0.", uint64(0)}},
+		http.StatusOK, &pb.Block{}, (*ServerOpenchainREST).GetBlockV2},
+	{"GET", "/transactions", "listTransactionsV2", "Transactions of the blockchain, from the newest", pageParams,
+		http.StatusOK, &TransactionPage{}, (*ServerOpenchainREST).ListTransactionsV2},
+	{"GET", "/transactions/:uuid", "getTransactionV2", "Transaction of the blockchain",
+		[]paramV2{{"uuid", "path", "UUID of the transaction.", ""}},
+		http.StatusOK, &pb.Transaction{}, (*ServerOpenchainREST).GetTransactionV2},
+	{"GET", "/chaincodes", "listChaincodesV2", "Chaincodes known to the peer", nil,
+		http.StatusOK, &pb.ChaincodeInfoList{}, (*ServerOpenchainREST).ListChaincodesV2},
+	{"POST", "/chaincodes", "deployV2", "Deploy a chaincode, follow its progress at /chaincodes/{name}/deployment",
+		[]paramV2{{"spec", "body", "Chaincode to deploy.", &pb.ChaincodeSpec{}}},
+		http.StatusAccepted, &SubmittedTransaction{}, (*ServerOpenchainREST).DeployV2},
+	{"GET", "/chaincodes/:name/deployment", "getDeploymentV2", "Progress of the deployment of a chaincode",
+		[]paramV2{{"name", "path", "Name of the chaincode returned by the deploy.", ""}},
+		http.StatusOK, &pb.DeploymentStatus{}, (*ServerOpenchainREST).GetDeploymentV2},
+	{"POST", "/chaincodes/:name/invoke", "invokeV2", "Invoke a function of a chaincode",
+		[]paramV2{{"name", "path", "Name of the chaincode.", ""}, {"spec", "body", "Invocation, the chaincode name may be omitted.", &pb.ChaincodeInvocationSpec{}}},
+		http.StatusAccepted, &SubmittedTransaction{}, (*ServerOpenchainREST).InvokeV2},
+	{"POST", "/chaincodes/:name/query", "queryV2", "Query a chaincode",
+		[]paramV2{{"name", "path", "Name of the chaincode.", ""}, {"spec", "body", "Query, the chaincode name may be omitted.", &pb.ChaincodeInvocationSpec{}}},
+		http.StatusOK, &QueryResult{}, (*ServerOpenchainREST).QueryV2},
+	{"POST", "/batches", "invokeBatchV2", "Invoke a list of chaincode functions in order, streaming the progress of the batch as one JSON object per line",
+		[]paramV2{{"spec", "body", "Invocations of the batch.", &pb.BatchInvocationSpec{}}},
+		http.StatusOK, &pb.BatchInvocationProgress{}, (*ServerOpenchainREST).InvokeBatchV2},
+	{"GET", "/network/peers", "listPeersV2", "Peers connected to the peer", nil,
+		http.StatusOK, &pb.PeersMessage{}, (*ServerOpenchainREST).GetPeersV2},
+	{"GET", "/deadletters", "listDeadLettersV2", "Transactions that failed on the peer, the oldest failure first", nil,
+		http.StatusOK, &pb.DeadLetterList{}, (*ServerOpenchainREST).ListDeadLettersV2},
+	{"GET", "/deadletters/:uuid", "getDeadLetterV2", "Transaction that failed on the peer",
+		[]paramV2{{"uuid", "path", "UUID of the transaction.", ""}},
+		http.StatusOK, &pb.DeadLetter{}, (*ServerOpenchainREST).GetDeadLetterV2},
+	{"POST", "/deadletters/:uuid/resubmit", "resubmitDeadLetterV2", "Submit a transaction that failed again, with a new UUID if it failed to execute",
+		[]paramV2{{"uuid", "path", "UUID of the transaction.", ""}},
+		http.StatusAccepted, &SubmittedTransaction{}, (*ServerOpenchainREST).ResubmitDeadLetterV2},
+	{"POST", "/deadletters/purge", "purgeDeadLettersV2", "Delete transactions that failed, returning the deleted ones",
+		[]paramV2{{"purge", "body", "UUIDs of the transactions to delete, or all.", &pb.DeadLetterPurge{}}},
+		http.StatusOK, &pb.DeadLetterList{}, (*ServerOpenchainREST).PurgeDeadLettersV2},
+}
+
+// addRoutesV2 adds the routes of version 2 of the API to the router
+func addRoutesV2(router *web.Router) {
+	for _, route := range routesV2 {
+		switch route.method {
+		case "GET":
+			router.Get("/v2"+route.path, route.handler)
+		case "POST":
+			router.Post("/v2"+route.path, route.handler)
+		}
+	}
+	router.Get("/v2/openapi.json", (*ServerOpenchainREST).GetOpenAPIV2)
+}
+
+// writeV2 writes a resource with the status
+func writeV2(rw web.ResponseWriter, status int, resource interface{}) {
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(resource); err != nil {
+		restLogger.Error(fmt.Sprintf("Error encoding response: %s", err))
+	}
+}
+
+// writeErrorV2 writes the envelope of an error with the status
+func writeErrorV2(rw web.ResponseWriter, status int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if status >= http.StatusInternalServerError {
+		restLogger.Error(message)
+	}
+	writeV2(rw, status, &ErrorV2{&ErrorDetailV2{Status: status, Message: message}})
+}
+
+// statusOfV2 returns the HTTP status of an error of the peer
+func statusOfV2(err error) int {
+	switch err {
+	case oc.ErrNotFound:
+		return http.StatusNotFound
+	case peer.ErrIntakePaused, peer.ErrNotReady:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// parsePage returns the cursor and limit of a page. An empty cursor is the
+// start of the first page.
+func parsePage(req *web.Request) (cursor string, limit int, err error) {
+	query := req.URL.Query()
+	limit = defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			return "", 0, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize)
+		}
+	}
+	return query.Get("cursor"), limit, nil
+}
+
+// parseTransactionCursor parses the cursor of a transaction page, the block
+// number and the index of the transaction in the block
+func parseTransactionCursor(cursor string) (blockNumber uint64, index int, err error) {
+	split := strings.Split(cursor, ":")
+	if len(split) == 2 {
+		if blockNumber, err = strconv.ParseUint(split[0], 10, 64); err == nil {
+			if index, err = strconv.Atoi(split[1]); err == nil && index >= 0 {
+				return blockNumber, index, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("Invalid cursor %s", cursor)
+}
+
+// GetBlockchainInfoV2 returns the height and hashes of the blockchain
+func (s *ServerOpenchainREST) GetBlockchainInfoV2(rw web.ResponseWriter, req *web.Request) {
+	info, err := s.server.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusServiceUnavailable, "%s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, info)
+}
+
+// ListBlocksV2 returns a page of blocks, from the newest
+func (s *ServerOpenchainREST) ListBlocksV2(rw web.ResponseWriter, req *web.Request) {
+	cursor, limit, err := parsePage(req)
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	count, err := s.server.GetBlockCount(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error retrieving the block count: %s", err)
+		return
+	}
+	page := &BlockPage{Blocks: []*pb.Block{}}
+	if count.Count == 0 {
+		writeV2(rw, http.StatusOK, page)
+		return
+	}
+	start := count.Count - 1
+	if cursor != "" {
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil || start >= count.Count {
+			writeErrorV2(rw, http.StatusBadRequest, "Invalid cursor %s", cursor)
+			return
+		}
+	}
+
+	for number := start; len(page.Blocks) < limit; number-- {
+		block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: number})
+		if err != nil {
+			writeErrorV2(rw, statusOfV2(err), "Error retrieving block %d: %s", number, err)
+			return
+		}
+		page.Blocks = append(page.Blocks, block)
+		if number == 0 {
+			break
+		}
+		if len(page.Blocks) == limit {
+			page.Next = strconv.FormatUint(number-1, 10)
+		}
+	}
+	writeV2(rw, http.StatusOK, page)
+}
+
+// GetBlockV2 returns a block of the blockchain
+func (s *ServerOpenchainREST) GetBlockV2(rw web.ResponseWriter, req *web.Request) {
+	number, err := strconv.ParseUint(req.PathParams["number"], 10, 64)
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "Block number must be an integer (uint64)")
+		return
+	}
+	block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: number})
+	if err != nil {
+		writeErrorV2(rw, statusOfV2(err), "Error retrieving block %d: %s", number, err)
+		return
+	}
+	writeV2(rw, http.StatusOK, block)
+}
+
+// ListTransactionsV2 returns a page of transactions, from the newest
+func (s *ServerOpenchainREST) ListTransactionsV2(rw web.ResponseWriter, req *web.Request) {
+	cursor, limit, err := parsePage(req)
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	count, err := s.server.GetBlockCount(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error retrieving the block count: %s", err)
+		return
+	}
+	page := &TransactionPage{Transactions: []*pb.Transaction{}}
+	if count.Count == 0 {
+		writeV2(rw, http.StatusOK, page)
+		return
+	}
+	// index -1 is the last transaction of the block
+	number, index := count.Count-1, -1
+	if cursor != "" {
+		if number, index, err = parseTransactionCursor(cursor); err != nil || number >= count.Count {
+			writeErrorV2(rw, http.StatusBadRequest, "Invalid cursor %s", cursor)
+			return
+		}
+	}
+
+	for {
+		block, err := s.server.GetBlockByNumber(context.Background(), &pb.BlockNumber{Number: number})
+		if err != nil {
+			writeErrorV2(rw, statusOfV2(err), "Error retrieving block %d: %s", number, err)
+			return
+		}
+		if index < 0 || index >= len(block.Transactions) {
+			index = len(block.Transactions) - 1
+		}
+		for ; index >= 0; index-- {
+			if len(page.Transactions) == limit {
+				page.Next = fmt.Sprintf("%d:%d", number, index)
+				writeV2(rw, http.StatusOK, page)
+				return
+			}
+			page.Transactions = append(page.Transactions, block.Transactions[index])
+		}
+		if number == 0 {
+			break
+		}
+		number--
+	}
+	writeV2(rw, http.StatusOK, page)
+}
+
+// GetTransactionV2 returns a transaction of the blockchain
+func (s *ServerOpenchainREST) GetTransactionV2(rw web.ResponseWriter, req *web.Request) {
+	uuid := req.PathParams["uuid"]
+	tx, err := s.server.GetTransactionByUUID(context.Background(), uuid)
+	if err != nil {
+		writeErrorV2(rw, statusOfV2(err), "Error retrieving transaction %s: %s", uuid, err)
+		return
+	}
+	writeV2(rw, http.StatusOK, tx)
+}
+
+// ListChaincodesV2 returns the chaincodes known to the peer
+func (s *ServerOpenchainREST) ListChaincodesV2(rw web.ResponseWriter, req *web.Request) {
+	chaincodes, err := s.devops.ListChaincodes(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error listing chaincodes: %s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, chaincodes)
+}
+
+// ListDeadLettersV2 returns the transactions that failed on the peer
+func (s *ServerOpenchainREST) ListDeadLettersV2(rw web.ResponseWriter, req *web.Request) {
+	letters, err := s.devops.ListDeadLetters(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error listing dead letters: %s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, letters)
+}
+
+// GetDeadLetterV2 returns a transaction that failed on the peer
+func (s *ServerOpenchainREST) GetDeadLetterV2(rw web.ResponseWriter, req *web.Request) {
+	uuid := req.PathParams["uuid"]
+	letter, err := s.devops.GetDeadLetter(context.Background(), &pb.DeadLetterID{Uuid: uuid})
+	if err != nil {
+		writeErrorV2(rw, statusOfV2(err), "Error retrieving dead letter %s: %s", uuid, err)
+		return
+	}
+	writeV2(rw, http.StatusOK, letter)
+}
+
+// ResubmitDeadLetterV2 submits a transaction that failed again
+func (s *ServerOpenchainREST) ResubmitDeadLetterV2(rw web.ResponseWriter, req *web.Request) {
+	uuid := req.PathParams["uuid"]
+	resp, err := s.devops.ResubmitDeadLetter(context.Background(), &pb.DeadLetterID{Uuid: uuid})
+	if err != nil {
+		status := statusOfV2(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		writeErrorV2(rw, status, "Error resubmitting dead letter %s: %s", uuid, err)
+		return
+	}
+	resubmitted := string(resp.Msg)
+	rw.Header().Set("Location", "/v2/transactions/"+resubmitted)
+	writeV2(rw, http.StatusAccepted, &SubmittedTransaction{UUID: resubmitted})
+}
+
+// PurgeDeadLettersV2 deletes transactions that failed on the peer
+func (s *ServerOpenchainREST) PurgeDeadLettersV2(rw web.ResponseWriter, req *web.Request) {
+	purge := &pb.DeadLetterPurge{}
+	if !decodeV2(rw, req, purge, "DeadLetterPurge") {
+		return
+	}
+	purged, err := s.devops.PurgeDeadLetters(context.Background(), purge)
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "Error purging dead letters: %s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, purged)
+}
+
+// decodeV2 decodes the JSON body of a request into msg
+func decodeV2(rw web.ResponseWriter, req *web.Request, msg interface{}, name string) bool {
+	var err error
+	switch msg := msg.(type) {
+	case *pb.ChaincodeSpec:
+		err = jsonpb.Unmarshal(req.Body, msg)
+	case *pb.ChaincodeInvocationSpec:
+		err = jsonpb.Unmarshal(req.Body, msg)
+	case *pb.BatchInvocationSpec:
+		err = jsonpb.Unmarshal(req.Body, msg)
+	case *pb.DeadLetterPurge:
+		err = jsonpb.Unmarshal(req.Body, msg)
+	}
+	if err == io.EOF {
+		writeErrorV2(rw, http.StatusBadRequest, "Body must contain a %s", name)
+		return false
+	} else if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "Invalid %s: %s", name, err)
+		return false
+	}
+	return true
+}
+
+// secureContextV2 replaces the user of a chaincode spec by their login
+// token if security is enabled
+func secureContextV2(rw web.ResponseWriter, spec *pb.ChaincodeSpec) bool {
+	if !viper.GetBool("security.enabled") {
+		return true
+	}
+	if spec.SecureContext == "" {
+		writeErrorV2(rw, http.StatusBadRequest, "secureContext must be the user of the chaincode when security is enabled")
+		return false
+	}
+	token, err := ioutil.ReadFile(getRESTFilePath() + "loginToken_" + spec.SecureContext)
+	if os.IsNotExist(err) {
+		writeErrorV2(rw, http.StatusUnauthorized, "User %s is not logged in, log in with /registrar", spec.SecureContext)
+		return false
+	} else if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error reading the login token of %s: %s", spec.SecureContext, err)
+		return false
+	}
+	spec.SecureContext = string(token)
+	if viper.GetBool("security.privacy") {
+		spec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
+	}
+	return true
+}
+
+// DeployV2 deploys a chaincode. The deployment goes on after the response.
+func (s *ServerOpenchainREST) DeployV2(rw web.ResponseWriter, req *web.Request) {
+	spec := &pb.ChaincodeSpec{}
+	if !decodeV2(rw, req, spec, "ChaincodeSpec") {
+		return
+	}
+	if spec.ChaincodeID == nil || (spec.ChaincodeID.Path == "" && spec.ChaincodeID.Name == "") {
+		writeErrorV2(rw, http.StatusBadRequest, "chaincodeID must contain the path or, in development mode, the name of the chaincode")
+		return
+	}
+	if !secureContextV2(rw, spec) {
+		return
+	}
+	deploymentSpec, err := s.devops.Deploy(context.Background(), spec)
+	if err != nil {
+		status := statusOfV2(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		writeErrorV2(rw, status, "Error deploying chaincode: %s", err)
+		return
+	}
+	name := deploymentSpec.ChaincodeSpec.ChaincodeID.Name
+	rw.Header().Set("Location", "/v2/chaincodes/"+name+"/deployment")
+	writeV2(rw, http.StatusAccepted, &SubmittedTransaction{UUID: name, ChaincodeName: name})
+}
+
+// GetDeploymentV2 returns the progress of the deployment of a chaincode
+func (s *ServerOpenchainREST) GetDeploymentV2(rw web.ResponseWriter, req *web.Request) {
+	name := req.PathParams["name"]
+	status, err := s.devops.GetDeploymentStatus(context.Background(), &pb.ChaincodeID{Name: name})
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	if status.Phase == pb.DeploymentStatus_UNKNOWN {
+		writeErrorV2(rw, http.StatusNotFound, "Chaincode %s is not known to the peer", name)
+		return
+	}
+	writeV2(rw, http.StatusOK, status)
+}
+
+// invocationV2 decodes the invocation of the chaincode of the path
+func invocationV2(rw web.ResponseWriter, req *web.Request) (*pb.ChaincodeInvocationSpec, bool) {
+	spec := &pb.ChaincodeInvocationSpec{}
+	if !decodeV2(rw, req, spec, "ChaincodeInvocationSpec") {
+		return nil, false
+	}
+	if spec.ChaincodeSpec == nil {
+		spec.ChaincodeSpec = &pb.ChaincodeSpec{}
+	}
+	if spec.ChaincodeSpec.ChaincodeID == nil {
+		spec.ChaincodeSpec.ChaincodeID = &pb.ChaincodeID{}
+	}
+	name := req.PathParams["name"]
+	if spec.ChaincodeSpec.ChaincodeID.Name != "" && spec.ChaincodeSpec.ChaincodeID.Name != name {
+		writeErrorV2(rw, http.StatusBadRequest, "chaincodeID name %s does not match the chaincode %s of the path", spec.ChaincodeSpec.ChaincodeID.Name, name)
+		return nil, false
+	}
+	spec.ChaincodeSpec.ChaincodeID.Name = name
+	if spec.ChaincodeSpec.CtorMsg == nil || spec.ChaincodeSpec.CtorMsg.Function == "" {
+		writeErrorV2(rw, http.StatusBadRequest, "ctorMsg must contain the name of the chaincode function")
+		return nil, false
+	}
+	if !secureContextV2(rw, spec.ChaincodeSpec) {
+		return nil, false
+	}
+	return spec, true
+}
+
+// InvokeV2 invokes a function of a chaincode. The transaction is committed
+// after the response.
+func (s *ServerOpenchainREST) InvokeV2(rw web.ResponseWriter, req *web.Request) {
+	spec, ok := invocationV2(rw, req)
+	if !ok {
+		return
+	}
+	resp, err := s.devops.Invoke(context.Background(), spec)
+	if err != nil {
+		status := statusOfV2(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		writeErrorV2(rw, status, "Error invoking chaincode: %s", err)
+		return
+	}
+	uuid := string(resp.Msg)
+	rw.Header().Set("Location", "/v2/transactions/"+uuid)
+	writeV2(rw, http.StatusAccepted, &SubmittedTransaction{UUID: uuid, ChaincodeName: spec.ChaincodeSpec.ChaincodeID.Name})
+}
+
+// QueryV2 queries a chaincode
+func (s *ServerOpenchainREST) QueryV2(rw web.ResponseWriter, req *web.Request) {
+	spec, ok := invocationV2(rw, req)
+	if !ok {
+		return
+	}
+	resp, err := s.devops.Query(context.Background(), spec)
+	if err != nil {
+		writeErrorV2(rw, http.StatusBadRequest, "Error querying chaincode: %s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, &QueryResult{Result: string(resp.Msg)})
+}
+
+// InvokeBatchV2 submits a batch of invocations and streams its progress as
+// newline delimited JSON until the batch is done
+func (s *ServerOpenchainREST) InvokeBatchV2(rw web.ResponseWriter, req *web.Request) {
+	spec := &pb.BatchInvocationSpec{}
+	if !decodeV2(rw, req, spec, "BatchInvocationSpec") {
+		return
+	}
+	for i, invocation := range spec.Invocations {
+		if id := invocation.GetChaincodeSpec().GetChaincodeID(); id == nil || id.Name == "" {
+			writeErrorV2(rw, http.StatusBadRequest, "chaincodeID of invocation %d must contain the name of the chaincode", i)
+			return
+		}
+		if !secureContextV2(rw, invocation.ChaincodeSpec) {
+			return
+		}
+	}
+
+	started := false
+	send := func(progress *pb.BatchInvocationProgress) error {
+		if !started {
+			rw.Header().Set("Content-Type", "application/x-ndjson")
+			rw.WriteHeader(http.StatusOK)
+			started = true
+		}
+		if err := json.NewEncoder(rw).Encode(progress); err != nil {
+			return err
+		}
+		rw.Flush()
+		return nil
+	}
+	if err := s.devops.SubmitBatch(context.Background(), spec, send); err != nil {
+		if started {
+			restLogger.Error(fmt.Sprintf("Error streaming the progress of a batch: %s", err))
+			return
+		}
+		writeErrorV2(rw, http.StatusBadRequest, "Error invoking batch: %s", err)
+	}
+}
+
+// GetPeersV2 returns the peers connected to the peer
+func (s *ServerOpenchainREST) GetPeersV2(rw web.ResponseWriter, req *web.Request) {
+	peers, err := s.server.GetPeers(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		writeErrorV2(rw, http.StatusInternalServerError, "Error retrieving peers: %s", err)
+		return
+	}
+	writeV2(rw, http.StatusOK, peers)
+}
+
+// GetOpenAPIV2 returns the OpenAPI document of version 2 of the API
+func (s *ServerOpenchainREST) GetOpenAPIV2(rw web.ResponseWriter, req *web.Request) {
+	writeV2(rw, http.StatusOK, openAPIV2())
+}
+
+// openAPIV2 generates the OpenAPI (Swagger 2.0) document of routesV2. The
+// schemas of the resources are derived from the JSON tags of their types.
+func openAPIV2() map[string]interface{} {
+	definitions := map[string]interface{}{}
+	errorRef := schemaOf(reflect.TypeOf(ErrorV2{}), definitions)
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routesV2 {
+		path := "/v2" + route.path
+		params := []interface{}{}
+		for _, param := range route.params {
+			path = strings.Replace(path, ":"+param.name, "{"+param.name+"}", 1)
+			p := map[string]interface{}{
+				"name":        param.name,
+				"in":          param.in,
+				"description": param.description,
+				"required":    param.in != "query",
+			}
+			schema := schemaOf(reflect.TypeOf(param.schema), definitions)
+			if param.in == "body" {
+				p["schema"] = schema
+			} else {
+				for k, v := range schema {
+					p[k] = v
+				}
+			}
+			params = append(params, p)
+		}
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(route.method)] = map[string]interface{}{
+			"operationId": route.operationID,
+			"summary":     route.summary,
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				strconv.Itoa(route.status): map[string]interface{}{
+					"description": http.StatusText(route.status),
+					"schema":      schemaOf(reflect.TypeOf(route.response), definitions),
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"schema":      errorRef,
+				},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"swagger": "2.0",
+		"info": map[string]interface{}{
+			"title":   "Openchain REST API",
+			"version": "v2",
+		},
+		"basePath":    "/",
+		"consumes":    []string{"application/json"},
+		"produces":    []string{"application/json"},
+		"paths":       paths,
+		"definitions": definitions,
+	}
+}
+
+var byteSliceType = reflect.TypeOf([]byte(nil))
+
+// schemaOf returns the JSON schema of a type. Structs are added to the
+// definitions and referenced by name.
+func schemaOf(t reflect.Type, definitions map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == byteSliceType:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() == reflect.Int32, t.Kind() == reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case t.Kind() == reflect.Float32, t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice, t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), definitions)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), definitions)}
+	case t.Kind() != reflect.Struct:
+		return map[string]interface{}{}
+	}
+
+	ref := map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	if _, ok := definitions[t.Name()]; ok {
+		return ref
+	}
+	properties := map[string]interface{}{}
+	definition := map[string]interface{}{"type": "object", "properties": properties}
+	// Add the definition before the fields for recursive types
+	definitions[t.Name()] = definition
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type, definitions)
+	}
+	return ref
+}
@@ -0,0 +1,99 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gocraft/web"
+)
+
+func newRequest(t *testing.T, url string) *web.Request {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %s", err)
+	}
+	return &web.Request{Request: req}
+}
+
+func TestParsePage(t *testing.T) {
+	cursor, limit, err := parsePage(newRequest(t, "/v2/chain/blocks"))
+	if err != nil || cursor != "" || limit != defaultPageSize {
+		t.Fatalf("Expected the first page of %d, got cursor %q, limit %d, error %v", defaultPageSize, cursor, limit, err)
+	}
+	cursor, limit, err = parsePage(newRequest(t, "/v2/chain/blocks?cursor=7&limit=3"))
+	if err != nil || cursor != "7" || limit != 3 {
+		t.Fatalf("Expected cursor 7 and limit 3, got cursor %q, limit %d, error %v", cursor, limit, err)
+	}
+	for _, limit := range []string{"0", "101", "ten"} {
+		if _, _, err := parsePage(newRequest(t, "/v2/chain/blocks?limit="+limit)); err == nil {
+			t.Fatalf("Expected an error for limit %s", limit)
+		}
+	}
+}
+
+func TestParseTransactionCursor(t *testing.T) {
+	number, index, err := parseTransactionCursor("12:3")
+	if err != nil || number != 12 || index != 3 {
+		t.Fatalf("Expected block 12 and index 3, got %d, %d, error %v", number, index, err)
+	}
+	for _, cursor := range []string{"12", "a:3", "12:-1", "1:2:3"} {
+		if _, _, err := parseTransactionCursor(cursor); err == nil {
+			t.Fatalf("Expected an error for cursor %s", cursor)
+		}
+	}
+}
+
+func TestOpenAPIV2(t *testing.T) {
+	doc, err := json.Marshal(openAPIV2())
+	if err != nil {
+		t.Fatalf("Error encoding the OpenAPI document: %s", err)
+	}
+	var decoded struct {
+		Paths       map[string]map[string]interface{}
+		Definitions map[string]struct {
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(doc, &decoded); err != nil {
+		t.Fatalf("Error decoding the OpenAPI document: %s", err)
+	}
+	for _, route := range routesV2 {
+		path := "/v2" + route.path
+		for _, param := range route.params {
+			path = strings.Replace(path, ":"+param.name, "{"+param.name+"}", 1)
+		}
+		if _, ok := decoded.Paths[path][strings.ToLower(route.method)]; !ok {
+			t.Fatalf("Route %s %s missing from the OpenAPI document", route.method, path)
+		}
+	}
+	if _, ok := decoded.Paths["/v2/chain/blocks/{number}"]["get"]; !ok {
+		t.Fatal("Expected the path parameter of /v2/chain/blocks/{number}")
+	}
+	if _, ok := decoded.Definitions["BlockPage"].Properties["blocks"]; !ok {
+		t.Fatal("Expected the blocks property of the BlockPage definition")
+	}
+	if _, ok := decoded.Definitions["ErrorDetailV2"].Properties["message"]; !ok {
+		t.Fatal("Expected the message property of the ErrorDetailV2 definition")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// simulationPolicy describes how invocations are checked before they are
+// submitted to consensus. The invocation is executed by peers validators,
+// which keep none of its state changes, and is submitted only if at least
+// quorum of them report the same state changes. This catches chaincode that
+// is not deterministic before it makes validators diverge.
+type simulationPolicy struct {
+	peers  int
+	quorum int
+}
+
+// newSimulationPolicy returns the configured policy, nil if invocations are
+// submitted without simulating them
+func newSimulationPolicy() (*simulationPolicy, error) {
+	if !viper.GetBool("peer.simulation.enabled") {
+		return nil, nil
+	}
+	p := &simulationPolicy{peers: viper.GetInt("peer.simulation.peers"), quorum: viper.GetInt("peer.simulation.quorum")}
+	if p.quorum < 1 || p.peers < p.quorum {
+		return nil, fmt.Errorf("Invalid simulation policy, quorum %d of %d peers", p.quorum, p.peers)
+	}
+	return p, nil
+}
+
+// getValidatorPeers returns the addresses of at most max validators to send
+// transactions to, this peer first if it is a validator
+func (d *Devops) getValidatorPeers(max int) ([]string, error) {
+	var addresses []string
+	if viper.GetBool("peer.validator.enabled") {
+		self, err := d.coord.GetPeerEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, self.Address)
+	}
+	peers, err := d.coord.GetPeers()
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for _, endpoint := range peers.Peers {
+		if endpoint.Type == pb.PeerEndpoint_VALIDATOR {
+			others = append(others, endpoint.Address)
+		}
+	}
+	sort.Strings(others)
+	addresses = append(addresses, others...)
+	if len(addresses) > max {
+		addresses = addresses[:max]
+	}
+	return addresses, nil
+}
+
+// simulate executes the transaction on the validators of the simulation
+// policy and returns an error unless a quorum of them agree on its state
+// changes
+func (d *Devops) simulate(tx *pb.Transaction) error {
+	addresses, err := d.getValidatorPeers(d.simulation.peers)
+	if err != nil {
+		return fmt.Errorf("Error getting validators to simulate transaction %s: %s", tx.Uuid, err)
+	}
+	if len(addresses) < d.simulation.quorum {
+		return fmt.Errorf("Cannot simulate transaction %s, %d validators available for a quorum of %d", tx.Uuid, len(addresses), d.simulation.quorum)
+	}
+
+	responses := make([]*pb.Response, len(addresses))
+	var wg sync.WaitGroup
+	for i, address := range addresses {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			responses[i] = d.coord.SimulateTransaction(address, tx)
+		}(i, address)
+	}
+	wg.Wait()
+
+	for i, resp := range responses {
+		if resp.Status != pb.Response_SUCCESS {
+			devopsLogger.Warning("Simulation of transaction %s on %s failed: %s", tx.Uuid, addresses[i], string(resp.Msg))
+		} else {
+			devopsLogger.Debug("Simulation of transaction %s on %s changed state %x", tx.Uuid, addresses[i], resp.Msg)
+		}
+	}
+	return checkSimulationQuorum(tx.Uuid, responses, d.simulation.quorum)
+}
+
+// checkSimulationQuorum returns an error unless at least quorum of the
+// successful simulation responses carry the same state change hash
+func checkSimulationQuorum(uuid string, responses []*pb.Response, quorum int) error {
+	counts := make(map[string]int)
+	max := 0
+	for _, resp := range responses {
+		if resp.Status != pb.Response_SUCCESS {
+			continue
+		}
+		hash := string(resp.Msg)
+		counts[hash]++
+		if counts[hash] > max {
+			max = counts[hash]
+		}
+	}
+	if max < quorum {
+		return fmt.Errorf("Transaction %s not submitted, %d of %d validators agreed on its state changes where %d are required", uuid, max, len(responses), quorum)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package openchain
+
+import (
+	"testing"
+
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+func TestCheckSimulationQuorum(t *testing.T) {
+	success := func(hash string) *pb.Response {
+		return &pb.Response{Status: pb.Response_SUCCESS, Msg: []byte(hash)}
+	}
+	failure := &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("chaincode error")}
+
+	if err := checkSimulationQuorum("tx1", []*pb.Response{success("a"), success("a"), success("b")}, 2); err != nil {
+		t.Fatalf("Expected quorum of matching state changes: %s", err)
+	}
+	if err := checkSimulationQuorum("tx1", []*pb.Response{success("a"), success("b"), success("c")}, 2); err == nil {
+		t.Fatalf("Expected diverging state changes to miss the quorum")
+	}
+	if err := checkSimulationQuorum("tx1", []*pb.Response{success(""), failure, failure}, 2); err == nil {
+		t.Fatalf("Expected failed simulations not to count towards the quorum")
+	}
+	if err := checkSimulationQuorum("tx1", []*pb.Response{success(""), success("")}, 2); err != nil {
+		t.Fatalf("Expected transactions without state changes to agree: %s", err)
+	}
+}
@@ -0,0 +1,499 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+// Package asset implements the asset system chaincode, which keeps the
+// balances of fungible assets. An asset is created by an invoker holding
+// the attribute role=issuer, on behalf of the account allowed to mint it.
+// Holders transfer and burn their balance.
+//
+// Accounts are controlled by an ECDSA P-256 key, mints, transfers and burns
+// are signed with the key of the issuing or sending account. Balances are
+// indexed by asset and by account, so that the holders of an asset and the
+// holdings of an account are read with a range query. Mints, transfers and
+// burns set an event, sent once their block commits.
+package asset
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+)
+
+// Name and Path of the asset system chaincode
+const (
+	Name = "asset"
+	Path = "github.com/openblockchain/obc-peer/openchain/system_chaincode/asset"
+)
+
+// Functions of the chaincode signed by an account, and names of the events
+// they set
+const (
+	Mint     = "mint"
+	Transfer = "transfer"
+	Burn     = "burn"
+)
+
+// issuerAttribute is the attribute, with its value, invokers must hold to
+// create assets
+const (
+	issuerAttribute      = "role"
+	issuerAttributeValue = "issuer"
+)
+
+const (
+	accountPrefix = "account."
+	assetPrefix   = "asset."
+	// Balances by asset, then account
+	holderPrefix = "holder."
+	// Balances by account, then asset
+	holdingPrefix = "holding."
+)
+
+// Account is the state of an account
+type Account struct {
+	// Key controlling the account, marshalled with elliptic.Marshal
+	Key []byte `json:"key"`
+	// Number of operations signed by the account, signed along with each one
+	Nonce uint64 `json:"nonce"`
+}
+
+// Asset is the state of an asset
+type Asset struct {
+	// Account allowed to mint the asset
+	Issuer string `json:"issuer"`
+	// Amount minted and not burned
+	Supply uint64 `json:"supply"`
+}
+
+// Operation is the argument of the mint, transfer and burn functions. It is
+// signed by the issuer of the asset for a mint, by the sender otherwise.
+type Operation struct {
+	Asset string `json:"asset"`
+	// Sender, empty for a mint
+	From string `json:"from"`
+	// Recipient, empty for a burn
+	To     string `json:"to"`
+	Amount uint64 `json:"amount"`
+	// ASN.1 ECDSA signature of the operation by the key of the signer
+	Signature []byte `json:"signature"`
+}
+
+// Event is the payload of the events set by mints, transfers and burns
+type Event struct {
+	Asset  string `json:"asset"`
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Amount uint64 `json:"amount"`
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Assets is the asset system chaincode. Its open function creates an
+// account controlled by a hex encoded key, create creates an asset minted
+// by an account, and mint, transfer and burn apply an Operation in JSON.
+// Its queries return the balance of an account for an asset, the balances
+// of the holders of an asset or of the holdings of an account, by account
+// or asset in JSON, and the Account or Asset in JSON.
+type Assets struct {
+}
+
+// Run runs the open, create, mint, transfer and burn functions
+func (t *Assets) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "open":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		return nil, open(stub, args[0], args[1])
+	case "create":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		return nil, create(stub, args[0], args[1])
+	case Mint, Transfer, Burn:
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		op := &Operation{}
+		if err := json.Unmarshal([]byte(args[0]), op); err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s", function, err)
+		}
+		return nil, apply(stub, function, op)
+	}
+	return nil, errors.New("Received unknown function invocation")
+}
+
+// Query runs the balance, holders, holdings, account and asset queries
+func (t *Assets) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "balance":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		if err := checkID(args[0]); err != nil {
+			return nil, err
+		}
+		if err := checkID(args[1]); err != nil {
+			return nil, err
+		}
+		balance, err := getBalance(stub, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatUint(balance, 10)), nil
+	case "holders":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		return getBalances(stub, holderPrefix, args[0])
+	case "holdings":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		return getBalances(stub, holdingPrefix, args[0])
+	case "account":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		account, err := getAccount(stub, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(account)
+	case "asset":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		asset, err := getAsset(stub, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(asset)
+	}
+	return nil, errors.New("Invalid query function name. Expecting \"balance\", \"holders\", \"holdings\", \"account\" or \"asset\"")
+}
+
+func open(stub *shim.ChaincodeStub, id string, key string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	raw, err := stub.GetState(accountPrefix + id)
+	if err != nil {
+		return err
+	}
+	if raw != nil {
+		return fmt.Errorf("Account %s already exists", id)
+	}
+	pub, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	return putAccount(stub, id, &Account{Key: elliptic.Marshal(pub.Curve, pub.X, pub.Y)})
+}
+
+func create(stub *shim.ChaincodeStub, symbol string, issuer string) error {
+	if ok, err := stub.VerifyAttribute(issuerAttribute, issuerAttributeValue); err != nil || !ok {
+		return fmt.Errorf("Invoker is not allowed to create assets")
+	}
+	if err := checkID(symbol); err != nil {
+		return err
+	}
+	raw, err := stub.GetState(assetPrefix + symbol)
+	if err != nil {
+		return err
+	}
+	if raw != nil {
+		return fmt.Errorf("Asset %s already exists", symbol)
+	}
+	if _, err = getAccount(stub, issuer); err != nil {
+		return err
+	}
+	return putAsset(stub, symbol, &Asset{Issuer: issuer})
+}
+
+// apply checks the signature of a mint, transfer or burn, updates the
+// balances and the supply and sets the event of the operation
+func apply(stub *shim.ChaincodeStub, function string, op *Operation) error {
+	if op.Amount == 0 {
+		return errors.New("Amount must be positive")
+	}
+	asset, err := getAsset(stub, op.Asset)
+	if err != nil {
+		return err
+	}
+	var signer string
+	switch function {
+	case Mint:
+		if op.From != "" || op.To == "" {
+			return errors.New("A mint has a recipient and no sender")
+		}
+		signer = asset.Issuer
+	case Transfer:
+		if op.To == "" || op.From == op.To {
+			return errors.New("A transfer has a recipient other than the sender")
+		}
+		signer = op.From
+	case Burn:
+		if op.To != "" {
+			return errors.New("A burn has no recipient")
+		}
+		signer = op.From
+	}
+	account, err := getAccount(stub, signer)
+	if err != nil {
+		return err
+	}
+	if err = verifyOperation(function, op, account); err != nil {
+		return err
+	}
+	account.Nonce++
+	if err = putAccount(stub, signer, account); err != nil {
+		return err
+	}
+
+	if op.From != "" {
+		balance, err := getBalance(stub, op.Asset, op.From)
+		if err != nil {
+			return err
+		}
+		if balance < op.Amount {
+			return fmt.Errorf("Insufficient balance of %s in account %s", op.Asset, op.From)
+		}
+		if err = putBalance(stub, op.Asset, op.From, balance-op.Amount); err != nil {
+			return err
+		}
+	}
+	if op.To != "" {
+		if _, err = getAccount(stub, op.To); err != nil {
+			return err
+		}
+		balance, err := getBalance(stub, op.Asset, op.To)
+		if err != nil {
+			return err
+		}
+		if balance > math.MaxUint64-op.Amount {
+			return fmt.Errorf("Balance of %s in account %s overflows", op.Asset, op.To)
+		}
+		if err = putBalance(stub, op.Asset, op.To, balance+op.Amount); err != nil {
+			return err
+		}
+	}
+
+	switch function {
+	case Mint:
+		if asset.Supply > math.MaxUint64-op.Amount {
+			return fmt.Errorf("Supply of %s overflows", op.Asset)
+		}
+		asset.Supply += op.Amount
+	case Burn:
+		asset.Supply -= op.Amount
+	}
+	if function != Transfer {
+		if err = putAsset(stub, op.Asset, asset); err != nil {
+			return err
+		}
+	}
+
+	event, err := json.Marshal(&Event{Asset: op.Asset, From: op.From, To: op.To, Amount: op.Amount})
+	if err != nil {
+		return err
+	}
+	return stub.SetEvent(function, event)
+}
+
+// verifyOperation checks the signature of an operation by the key of the
+// signing account
+func verifyOperation(function string, op *Operation, signer *Account) error {
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, signer.Key)
+	if x == nil {
+		return errors.New("Invalid key of signing account")
+	}
+	sig := new(ecdsaSignature)
+	if _, err := asn1.Unmarshal(op.Signature, sig); err != nil {
+		return fmt.Errorf("Invalid signature: %s", err)
+	}
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, operationDigest(function, op, signer.Nonce), sig.R, sig.S) {
+		return errors.New("Invalid signature")
+	}
+	return nil
+}
+
+// operationDigest is the digest signed for an operation, bound to the nonce
+// of the signing account so that it cannot be replayed
+func operationDigest(function string, op *Operation, nonce uint64) []byte {
+	h := sha256.New()
+	for _, field := range []string{Name, function, op.Asset, op.From, op.To} {
+		length := make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(field)))
+		h.Write(length)
+		h.Write([]byte(field))
+	}
+	n := make([]byte, 16)
+	binary.BigEndian.PutUint64(n, op.Amount)
+	binary.BigEndian.PutUint64(n[8:], nonce)
+	h.Write(n)
+	return h.Sum(nil)
+}
+
+func checkID(id string) error {
+	if id == "" || strings.Contains(id, ".") {
+		return fmt.Errorf("Invalid account or asset %q", id)
+	}
+	return nil
+}
+
+func parseKey(key string) (*ecdsa.PublicKey, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key: %s", err)
+	}
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("Invalid key, expecting a P-256 point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func getAccount(stub *shim.ChaincodeStub, id string) (*Account, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	raw, err := stub.GetState(accountPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("Account %s does not exist", id)
+	}
+	account := &Account{}
+	if err = json.Unmarshal(raw, account); err != nil {
+		return nil, fmt.Errorf("Invalid account %s: %s", id, err)
+	}
+	return account, nil
+}
+
+func putAccount(stub *shim.ChaincodeStub, id string, account *Account) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(accountPrefix+id, data)
+}
+
+func getAsset(stub *shim.ChaincodeStub, symbol string) (*Asset, error) {
+	if err := checkID(symbol); err != nil {
+		return nil, err
+	}
+	raw, err := stub.GetState(assetPrefix + symbol)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("Asset %s does not exist", symbol)
+	}
+	asset := &Asset{}
+	if err = json.Unmarshal(raw, asset); err != nil {
+		return nil, fmt.Errorf("Invalid asset %s: %s", symbol, err)
+	}
+	return asset, nil
+}
+
+func putAsset(stub *shim.ChaincodeStub, symbol string, asset *Asset) error {
+	data, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(assetPrefix+symbol, data)
+}
+
+func getBalance(stub *shim.ChaincodeStub, symbol string, account string) (uint64, error) {
+	raw, err := stub.GetState(holderPrefix + symbol + "." + account)
+	if err != nil {
+		return 0, err
+	}
+	if raw == nil {
+		return 0, nil
+	}
+	balance, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid balance of %s in account %s: %s", symbol, account, err)
+	}
+	return balance, nil
+}
+
+// putBalance writes the balance to both indexes, an empty balance is removed
+// from them
+func putBalance(stub *shim.ChaincodeStub, symbol string, account string, balance uint64) error {
+	holder, holding := holderPrefix+symbol+"."+account, holdingPrefix+account+"."+symbol
+	if balance == 0 {
+		if err := stub.DelState(holder); err != nil {
+			return err
+		}
+		return stub.DelState(holding)
+	}
+	value := []byte(strconv.FormatUint(balance, 10))
+	if err := stub.PutState(holder, value); err != nil {
+		return err
+	}
+	return stub.PutState(holding, value)
+}
+
+// getBalances returns the balances under an index by the last component of
+// their key in JSON, the accounts holding an asset or the assets held by an
+// account
+func getBalances(stub *shim.ChaincodeStub, index string, id string) ([]byte, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	prefix := index + id + "."
+	iter, err := stub.RangeQueryState(prefix, index+id+"/")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	balances := make(map[string]uint64)
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		balance, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid balance %s: %s", key, err)
+		}
+		balances[strings.TrimPrefix(key, prefix)] = balance
+	}
+	return json.Marshal(balances)
+}
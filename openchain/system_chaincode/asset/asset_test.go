@@ -0,0 +1,81 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package asset
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func newAccount(t *testing.T) (*ecdsa.PrivateKey, *Account) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	return key, &Account{Key: elliptic.Marshal(elliptic.P256(), key.X, key.Y), Nonce: 3}
+}
+
+func TestVerifyOperation(t *testing.T) {
+	key, sender := newAccount(t)
+
+	op := &Operation{Asset: "GOLD", From: "alice", To: "bob", Amount: 30}
+	if err := SignOperation(key, Transfer, op, sender.Nonce); err != nil {
+		t.Fatalf("Failed signing transfer [%s]", err)
+	}
+
+	// The operation goes through JSON as the argument of the chaincode
+	raw, _ := json.Marshal(op)
+	op = &Operation{}
+	if err := json.Unmarshal(raw, op); err != nil {
+		t.Fatalf("Failed unmarshalling transfer [%s]", err)
+	}
+	if err := verifyOperation(Transfer, op, sender); err != nil {
+		t.Fatalf("Failed verifying transfer [%s]", err)
+	}
+
+	// Operations are bound to the function, their fields and the nonce
+	if err := verifyOperation(Burn, op, sender); err == nil {
+		t.Fatal("Transfer must not verify as a burn")
+	}
+	for _, tamper := range []func(o *Operation){
+		func(o *Operation) { o.Asset = "SILVER" },
+		func(o *Operation) { o.To = "carol" },
+		func(o *Operation) { o.Amount = 300 },
+	} {
+		tampered := *op
+		tamper(&tampered)
+		if err := verifyOperation(Transfer, &tampered, sender); err == nil {
+			t.Fatalf("Tampered transfer %v must not verify", tampered)
+		}
+	}
+	replayed := *sender
+	replayed.Nonce++
+	if err := verifyOperation(Transfer, op, &replayed); err == nil {
+		t.Fatal("Transfer must not verify with another nonce")
+	}
+
+	// Only the key of the signing account signs operations
+	_, other := newAccount(t)
+	if err := verifyOperation(Transfer, op, other); err == nil {
+		t.Fatal("Transfer must not verify with another key")
+	}
+}
@@ -0,0 +1,37 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package asset
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+)
+
+// SignOperation signs a mint, transfer or burn with the key of the signing
+// account, the issuer of the asset for a mint and the sender otherwise.
+// nonce is the nonce of the signing account.
+func SignOperation(key *ecdsa.PrivateKey, function string, op *Operation, nonce uint64) error {
+	r, s, err := ecdsa.Sign(rand.Reader, key, operationDigest(function, op, nonce))
+	if err != nil {
+		return err
+	}
+	op.Signature, err = asn1.Marshal(ecdsaSignature{R: r, S: s})
+	return err
+}
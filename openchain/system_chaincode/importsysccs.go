@@ -0,0 +1,61 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package system_chaincode
+
+import (
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/asset"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/oracle"
+	"github.com/openblockchain/obc-peer/openchain/system_chaincode/zkpay"
+)
+
+var sysccLogger = logging.MustGetLogger("syscc")
+
+// systemChaincodes lists the system chaincodes built into the peer. Which
+// of them run is controlled by chaincode.system in the configuration.
+var systemChaincodes = []*chaincode.SystemChaincode{
+	{Name: netconfig.Name, Path: netconfig.Path, Chaincode: new(netconfig.NetworkConfig)},
+	{Name: zkpay.Name, Path: zkpay.Path, Chaincode: new(zkpay.Payments)},
+	{Name: oracle.Name, Path: oracle.Path, Chaincode: new(oracle.Oracle)},
+	{Name: asset.Name, Path: asset.Path, Chaincode: new(asset.Assets)},
+}
+
+// RegisterSysCCs registers the system chaincodes enabled in the
+// configuration so that they are started with chaincode.DeploySysCCs
+func RegisterSysCCs() error {
+	enabled := make(map[string]bool)
+	for _, name := range viper.GetStringSlice("chaincode.system") {
+		enabled[name] = true
+	}
+	for _, syscc := range systemChaincodes {
+		if !enabled[syscc.Name] {
+			sysccLogger.Debug("System chaincode %s not enabled", syscc.Name)
+			continue
+		}
+		if err := chaincode.RegisterSysCC(syscc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
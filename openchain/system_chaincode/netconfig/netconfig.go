@@ -0,0 +1,407 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package netconfig implements the netconfig system chaincode, which holds
+// the network parameters changed by configuration transactions. Each change
+// takes effect once the blockchain reaches the height given with it, so all
+// peers switch to the new value at the same block.
+package netconfig
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+
+	obcca "github.com/openblockchain/obc-peer/obc-ca/protos"
+	"github.com/openblockchain/obc-peer/openchain/audit"
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/db"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+)
+
+var netconfigLogger = logging.MustGetLogger("netconfig")
+
+// Name and Path of the netconfig system chaincode
+const (
+	Name = "netconfig"
+	Path = "github.com/openblockchain/obc-peer/openchain/system_chaincode/netconfig"
+)
+
+// Network parameters
+const (
+	// BatchSize is the number of transactions obcpbft orders in a batch
+	BatchSize = "batchsize"
+	// Membership is the comma separated list of the IDs of the validating
+	// peers that may connect to the network
+	Membership = "membership"
+	// ChainPolicy holds the chain policies in JSON, by chain ID and action,
+	// replacing peer.chainpolicy.chains
+	ChainPolicy = "chainpolicy"
+	// Chains is the comma separated list of the IDs of the chains other
+	// than the default chain. Every peer creates the ledger of a chain once
+	// the list naming it takes effect, transactions for other chains are
+	// rejected.
+	Chains = "chains"
+	// PackageSignature holds the signing policy of deployment packages in
+	// JSON, replacing chaincode.package.signature, so that every validator
+	// builds the same packages
+	PackageSignature = "packagesignature"
+)
+
+// Actions of chain policies
+var chainPolicyActions = []string{"deploy", "invoke", "query"}
+
+// rolePrincipalPrefix prefixes the principals standing for every identity of a role
+const rolePrincipalPrefix = "role:"
+
+// Change is a value of a parameter and the block height it takes effect at
+type Change struct {
+	Value  string `json:"value"`
+	Height uint64 `json:"height"`
+}
+
+// NetworkConfig is the netconfig system chaincode. Its update function
+// schedules a change of a parameter, it is only run by configuration
+// transactions. Its get query returns the scheduled changes of a parameter.
+type NetworkConfig struct {
+}
+
+// Run schedules a change, the arguments are the parameter, the value and
+// the block height the value takes effect at
+func (t *NetworkConfig) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if function != "update" {
+		return nil, errors.New("Received unknown function invocation")
+	}
+	if len(args) != 3 {
+		return nil, errors.New("Incorrect number of arguments. Expecting 3")
+	}
+	if err := ValidateParameter(args[0], args[1]); err != nil {
+		return nil, err
+	}
+	height, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid block height %s: %s", args[2], err)
+	}
+	raw, err := stub.GetState(scheduleKey(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := parseSchedule(raw)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(schedule.add(Change{Value: args[1], Height: height}))
+	if err != nil {
+		return nil, err
+	}
+	return nil, stub.PutState(scheduleKey(args[0]), data)
+}
+
+// Query returns the changes of the parameter given as argument in JSON
+func (t *NetworkConfig) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if function != "get" {
+		return nil, errors.New("Invalid query function name. Expecting \"get\"")
+	}
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting 1")
+	}
+	raw, err := stub.GetState(scheduleKey(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return []byte("[]"), nil
+	}
+	return raw, nil
+}
+
+// ValidateParameter returns an error unless value is a valid value of the
+// network parameter
+func ValidateParameter(parameter string, value string) error {
+	switch parameter {
+	case BatchSize:
+		if size, err := strconv.Atoi(value); err != nil || size <= 0 {
+			return fmt.Errorf("Invalid %s %q, expecting a positive number", parameter, value)
+		}
+	case Membership:
+		if len(parseIDs(value)) == 0 {
+			return fmt.Errorf("Invalid %s %q, expecting validator IDs", parameter, value)
+		}
+	case Chains:
+		for _, chainID := range parseIDs(value) {
+			if err := db.CheckChainID(chainID); err != nil {
+				return err
+			}
+		}
+	case ChainPolicy:
+		if _, err := ParseChainPolicy(value); err != nil {
+			return err
+		}
+	case PackageSignature:
+		if _, err := ParsePackageSignaturePolicy(value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown network parameter %s", parameter)
+	}
+	return nil
+}
+
+// ParseChainPolicy parses a chainpolicy value into the principals by chain
+// ID and action
+func ParseChainPolicy(value string) (map[string]map[string][]string, error) {
+	var chains map[string]map[string][]string
+	if err := json.Unmarshal([]byte(value), &chains); err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", ChainPolicy, err)
+	}
+	principals := make(map[string]map[string][]string)
+	for chainID, policy := range chains {
+		actions := make(map[string][]string)
+		for action, actionPrincipals := range policy {
+			if !isChainPolicyAction(action) {
+				return nil, fmt.Errorf("Invalid action %s in the policy of chain %s", action, chainID)
+			}
+			for _, principal := range actionPrincipals {
+				if strings.HasPrefix(principal, rolePrincipalPrefix) {
+					if _, ok := obcca.Role_value[strings.TrimPrefix(principal, rolePrincipalPrefix)]; !ok {
+						return nil, fmt.Errorf("Invalid principal %s in the policy of chain %s", principal, chainID)
+					}
+				}
+			}
+			actions[action] = actionPrincipals
+		}
+		principals[chainID] = actions
+	}
+	return principals, nil
+}
+
+// PackageSignaturePolicy is the signing policy of deployment packages held
+// by the packagesignature parameter. When enabled, packages must be signed by
+// at least Threshold distinct identities among Signers, any if empty, with
+// certificates chaining to the PEM encoded CA certificates of RootCerts.
+type PackageSignaturePolicy struct {
+	Enabled   bool     `json:"enabled"`
+	Threshold int      `json:"threshold"`
+	RootCerts string   `json:"rootcerts"`
+	Signers   []string `json:"signers"`
+}
+
+// ParsePackageSignaturePolicy parses a packagesignature value
+func ParsePackageSignaturePolicy(value string) (*PackageSignaturePolicy, error) {
+	policy := &PackageSignaturePolicy{}
+	if err := json.Unmarshal([]byte(value), policy); err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", PackageSignature, err)
+	}
+	if policy.Threshold < 0 {
+		return nil, fmt.Errorf("Invalid %s threshold %d", PackageSignature, policy.Threshold)
+	}
+	// Anybody can create a self signed certificate for any enrollment ID
+	if policy.Enabled && !x509.NewCertPool().AppendCertsFromPEM([]byte(policy.RootCerts)) {
+		return nil, fmt.Errorf("Invalid %s, no root certificates", PackageSignature)
+	}
+	return policy, nil
+}
+
+func isChainPolicyAction(action string) bool {
+	for _, a := range chainPolicyActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIDs returns the IDs of a comma separated list
+func parseIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func scheduleKey(parameter string) string {
+	return "schedule." + parameter
+}
+
+// schedule is the list of changes of a parameter, ordered by height
+type schedule []Change
+
+func parseSchedule(raw []byte) (schedule, error) {
+	var s schedule
+	if raw == nil {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("Invalid schedule of network parameter: %s", err)
+	}
+	return s, nil
+}
+
+// add returns the schedule with the change, replacing the change scheduled
+// at the same height if any
+func (s schedule) add(change Change) schedule {
+	var added schedule
+	for _, c := range s {
+		if c.Height != change.Height {
+			added = append(added, c)
+		}
+	}
+	added = append(added, change)
+	sort.Sort(added)
+	return added
+}
+
+// at returns the value in effect at the block height
+func (s schedule) at(height uint64) (string, bool) {
+	value, ok := "", false
+	for _, c := range s {
+		if c.Height > height {
+			break
+		}
+		value, ok = c.Value, true
+	}
+	return value, ok
+}
+
+func (s schedule) Len() int           { return len(s) }
+func (s schedule) Less(i, j int) bool { return s[i].Height < s[j].Height }
+func (s schedule) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// parameters lists the network parameters
+var parameters = []string{BatchSize, Membership, ChainPolicy, Chains, PackageSignature}
+
+// effective holds the values in effect at the height of the blockchain
+var effective = struct {
+	sync.RWMutex
+	values map[string]string
+	// loaded is set once the values were read, so that reading them when
+	// the peer starts is not audited as changes
+	loaded bool
+}{values: make(map[string]string)}
+
+// Update reads the values of the network parameters in effect at the
+// current height of the blockchain, and creates the ledgers of the chains
+// listed. It is called whenever blocks are committed, so that the changes
+// scheduled for a height take effect on every peer once it reaches that
+// height.
+func Update() error {
+	defaultLedger, err := ledger.GetLedger()
+	if err != nil {
+		return err
+	}
+	height := defaultLedger.GetBlockchainSize()
+	values := make(map[string]string)
+	for _, parameter := range parameters {
+		raw, err := defaultLedger.GetState(Name, scheduleKey(parameter), true)
+		if err != nil {
+			return fmt.Errorf("Failed to read network parameter %s: %s", parameter, err)
+		}
+		s, err := parseSchedule(raw)
+		if err != nil {
+			return err
+		}
+		if value, ok := s.at(height); ok {
+			values[parameter] = value
+		}
+	}
+
+	for _, chainID := range parseIDs(values[Chains]) {
+		if _, err := ledger.CreateChainLedger(chainID); err != nil {
+			return fmt.Errorf("Failed to create the ledger of chain %s: %s", chainID, err)
+		}
+	}
+
+	effective.Lock()
+	defer effective.Unlock()
+	for _, parameter := range parameters {
+		if old, value := effective.values[parameter], values[parameter]; old != value {
+			netconfigLogger.Info("Network parameter %s is %q from block height %d", parameter, value, height)
+			if effective.loaded {
+				audit.Record(audit.CategoryConfig, "network", "set", fmt.Sprintf("%s=%s at height %d", parameter, value, height), nil)
+			}
+		}
+	}
+	effective.values = values
+	effective.loaded = true
+	return nil
+}
+
+// Get returns the value of the network parameter in effect, false if it
+// was never changed by a configuration transaction
+func Get(parameter string) (string, bool) {
+	effective.RLock()
+	defer effective.RUnlock()
+	value, ok := effective.values[parameter]
+	return value, ok
+}
+
+// GetBatchSize returns the batch size in effect, false if it was never
+// changed by a configuration transaction
+func GetBatchSize() (int, bool) {
+	value, ok := Get(BatchSize)
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return size, true
+}
+
+// IsMember returns true if the validating peer may connect to the network,
+// which is always the case if the membership was never changed by a
+// configuration transaction
+func IsMember(validatorID string) bool {
+	value, ok := Get(Membership)
+	if !ok {
+		return true
+	}
+	for _, id := range parseIDs(value) {
+		if id == validatorID {
+			return true
+		}
+	}
+	return false
+}
+
+// ChainExists returns true if chainID names the default chain, or a chain
+// listed by the chains network parameter
+func ChainExists(chainID string) bool {
+	if chainID == "" {
+		return true
+	}
+	value, _ := Get(Chains)
+	for _, id := range parseIDs(value) {
+		if id == chainID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package netconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+)
+
+func TestMain(m *testing.M) {
+	viper.SetConfigName("openchain")
+	viper.AddConfigPath("../../../")
+	if err := viper.ReadInConfig(); err != nil {
+		panic(fmt.Errorf("Fatal error config file: %s \n", err))
+	}
+	viper.Set("peer.fileSystemPath", "/var/openchain/test/netconfig_test")
+	os.Exit(m.Run())
+}
+
+func TestValidateParameter(t *testing.T) {
+	valid := [][2]string{
+		{BatchSize, "10"},
+		{Membership, "vp0, vp1,vp2"},
+		{Chains, "chain1, Chain.2"},
+		{ChainPolicy, `{"_default": {"deploy": ["role:VALIDATOR"]}, "Chain1": {"invoke": ["alice"], "query": []}}`},
+		{PackageSignature, `{"enabled": false, "threshold": 2, "signers": ["alice"]}`},
+	}
+	for _, v := range valid {
+		if err := ValidateParameter(v[0], v[1]); err != nil {
+			t.Errorf("Expected %s %q to be valid, got %s", v[0], v[1], err)
+		}
+	}
+	invalid := [][2]string{
+		{BatchSize, "0"},
+		{BatchSize, "ten"},
+		{Membership, " , "},
+		{Chains, "chain1,../chain2"},
+		{ChainPolicy, "deploy: alice"},
+		{ChainPolicy, `{"chain1": {"upgrade": ["alice"]}}`},
+		{ChainPolicy, `{"chain1": {"deploy": ["role:KING"]}}`},
+		{PackageSignature, "threshold: 2"},
+		{PackageSignature, `{"enabled": true, "threshold": 1}`},
+		{PackageSignature, `{"enabled": true, "threshold": 1, "rootcerts": "not a certificate"}`},
+		{PackageSignature, `{"threshold": -1}`},
+		{"blocksize", "10"},
+	}
+	for _, v := range invalid {
+		if err := ValidateParameter(v[0], v[1]); err == nil {
+			t.Errorf("Expected an error validating %s %q", v[0], v[1])
+		}
+	}
+
+	principals, err := ParseChainPolicy(`{"Chain1": {"invoke": ["alice", "role:CLIENT"]}}`)
+	if err != nil {
+		t.Fatalf("Error parsing chain policy: %s", err)
+	}
+	if invoke := principals["Chain1"]["invoke"]; len(invoke) != 2 || invoke[0] != "alice" {
+		t.Fatalf("Expected the invoke principals of Chain1, got %v", principals)
+	}
+	if _, ok := principals["chain1"]; ok {
+		t.Fatalf("Expected chain IDs to be case-sensitive, got %v", principals)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	var s schedule
+	s = s.add(Change{Value: "20", Height: 20})
+	s = s.add(Change{Value: "10", Height: 10})
+	s = s.add(Change{Value: "30", Height: 30})
+	s = s.add(Change{Value: "25", Height: 20})
+	if len(s) != 3 {
+		t.Fatalf("Expected 3 changes, got %v", s)
+	}
+	tests := []struct {
+		height uint64
+		value  string
+		ok     bool
+	}{{5, "", false}, {10, "10", true}, {19, "10", true}, {20, "25", true}, {100, "30", true}}
+	for _, test := range tests {
+		if value, ok := s.at(test.height); value != test.value || ok != test.ok {
+			t.Errorf("Expected %q, %t at height %d, got %q, %t", test.value, test.ok, test.height, value, ok)
+		}
+	}
+}
+
+func commitSchedule(t *testing.T, l *ledger.Ledger, parameter string, changes ...Change) {
+	l.BeginTxBatch(1)
+	if len(changes) > 0 {
+		data, _ := json.Marshal(changes)
+		l.TxBegin("txUuid")
+		if err := l.SetState(Name, scheduleKey(parameter), data); err != nil {
+			t.Fatalf("Error setting state: %s", err)
+		}
+		l.TxFinished("txUuid", true)
+	}
+	if err := l.CommitTxBatch(1, nil, nil, nil); err != nil {
+		t.Fatalf("Error committing block: %s", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	l := ledger.InitTestLedger(t)
+	defer func() { effective.values = make(map[string]string) }()
+
+	commitSchedule(t, l, BatchSize, Change{Value: "10", Height: 2})
+	if err := Update(); err != nil {
+		t.Fatalf("Error updating network parameters: %s", err)
+	}
+	if _, ok := GetBatchSize(); ok {
+		t.Fatal("Expected no batch size before block height 2")
+	}
+	if !IsMember("vp5") {
+		t.Fatal("Expected every validator to be a member without membership")
+	}
+
+	commitSchedule(t, l, Membership, Change{Value: "vp0,vp1", Height: 2})
+	if err := Update(); err != nil {
+		t.Fatalf("Error updating network parameters: %s", err)
+	}
+	if size, ok := GetBatchSize(); !ok || size != 10 {
+		t.Fatalf("Expected batch size 10 at block height 2, got %d", size)
+	}
+	if !IsMember("vp1") || IsMember("vp5") {
+		t.Fatal("Expected only vp0 and vp1 to be members")
+	}
+
+	commitSchedule(t, l, BatchSize, Change{Value: "10", Height: 2}, Change{Value: "50", Height: 3})
+	if err := Update(); err != nil {
+		t.Fatalf("Error updating network parameters: %s", err)
+	}
+	if size, _ := GetBatchSize(); size != 50 {
+		t.Fatalf("Expected batch size 50 at block height 3, got %d", size)
+	}
+
+	commitSchedule(t, l, Chains, Change{Value: "chain1", Height: 5})
+	if err := Update(); err != nil {
+		t.Fatalf("Error updating network parameters: %s", err)
+	}
+	if ChainExists("chain1") || !ChainExists("") {
+		t.Fatal("Expected only the default chain before block height 5")
+	}
+	if _, err := ledger.GetChainLedger("chain1"); err == nil {
+		t.Fatal("Expected the ledger of chain1 not to be created before block height 5")
+	}
+	commitSchedule(t, l, BatchSize, Change{Value: "10", Height: 2}, Change{Value: "50", Height: 3})
+	if err := Update(); err != nil {
+		t.Fatalf("Error updating network parameters: %s", err)
+	}
+	if !ChainExists("chain1") || ChainExists("chain2") {
+		t.Fatal("Expected chain1 to exist at block height 5")
+	}
+	if _, err := ledger.GetChainLedger("chain1"); err != nil {
+		t.Fatalf("Expected the ledger of chain1 to be created: %s", err)
+	}
+}
@@ -0,0 +1,39 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package oracle
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+)
+
+// NewDataPoint creates the data point of a feed observed by the provider at
+// timestamp, signed with the key of the provider
+func NewDataPoint(key *ecdsa.PrivateKey, provider string, feed string, value string, timestamp int64) (*DataPoint, error) {
+	point := &DataPoint{Provider: provider, Feed: feed, Value: value, Timestamp: timestamp}
+	r, s, err := ecdsa.Sign(rand.Reader, key, dataPointDigest(point))
+	if err != nil {
+		return nil, err
+	}
+	if point.Signature, err = asn1.Marshal(ecdsaSignature{R: r, S: s}); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
@@ -0,0 +1,394 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+// Package oracle implements the oracle system chaincode, which brings data
+// from outside the blockchain into the state. White-listed providers sign
+// data points of named feeds with their key, the chaincode verifies the
+// signatures and keeps the latest data point of each provider for each feed.
+// Chaincodes read feeds with InvokeChaincode or QueryChaincode.
+//
+// Providers are controlled by an ECDSA P-256 key. They are added and removed
+// by invokers holding the attribute role=oracleadmin.
+package oracle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/ledger"
+	pb "github.com/openblockchain/obc-peer/protos"
+)
+
+// Name and Path of the oracle system chaincode
+const (
+	Name = "oracle"
+	Path = "github.com/openblockchain/obc-peer/openchain/system_chaincode/oracle"
+)
+
+// adminAttribute is the attribute, with its value, invokers must hold to
+// add and remove providers
+const (
+	adminAttribute      = "role"
+	adminAttributeValue = "oracleadmin"
+)
+
+const (
+	providerPrefix = "provider."
+	feedPrefix     = "feed."
+)
+
+// DataPoint is a value of a feed signed by a provider
+type DataPoint struct {
+	Provider string `json:"provider"`
+	Feed     string `json:"feed"`
+	Value    string `json:"value"`
+	// Time the provider observed the value at, in seconds since the epoch.
+	// Each data point of a provider for a feed must be later than the last.
+	Timestamp int64 `json:"timestamp"`
+	// ASN.1 ECDSA signature of the data point by the key of the provider
+	Signature []byte `json:"signature"`
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Oracle is the oracle system chaincode. Its addProvider function
+// white-lists a provider with a hex encoded key, removeProvider removes it
+// and publish stores data points in JSON, a single one or a list. Its read
+// function, and its read query, return the latest data point of a feed in
+// JSON, of the provider given as second argument if any. Its providers query
+// returns the IDs of the providers.
+type Oracle struct {
+}
+
+// Run runs the addProvider, removeProvider, publish and read functions
+func (t *Oracle) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "addProvider":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		return nil, addProvider(stub, args[0], args[1])
+	case "removeProvider":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		return nil, removeProvider(stub, args[0])
+	case "publish":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 1")
+		}
+		points, err := ParseDataPoints(args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range points {
+			if err = publish(stub, point); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case "read":
+		return read(stub, args)
+	}
+	return nil, errors.New("Received unknown function invocation")
+}
+
+// Query runs the read and providers queries
+func (t *Oracle) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "read":
+		return read(stub, args)
+	case "providers":
+		if len(args) != 0 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 0")
+		}
+		providers, err := getProviders(stub)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(providers)
+	}
+	return nil, errors.New("Invalid query function name. Expecting \"read\" or \"providers\"")
+}
+
+// ParseDataPoints parses the argument of the publish function, a data point
+// or a list of data points in JSON
+func ParseDataPoints(arg string) ([]*DataPoint, error) {
+	var points []*DataPoint
+	if strings.HasPrefix(strings.TrimSpace(arg), "[") {
+		if err := json.Unmarshal([]byte(arg), &points); err != nil {
+			return nil, fmt.Errorf("Invalid data points: %s", err)
+		}
+	} else {
+		point := &DataPoint{}
+		if err := json.Unmarshal([]byte(arg), point); err != nil {
+			return nil, fmt.Errorf("Invalid data point: %s", err)
+		}
+		points = append(points, point)
+	}
+	if len(points) == 0 {
+		return nil, errors.New("No data point to publish")
+	}
+	return points, nil
+}
+
+// VerifyDataPoint checks the signature of a data point against the hex
+// encoded key of its provider
+func VerifyDataPoint(point *DataPoint, key string) error {
+	if err := checkID(point.Provider); err != nil {
+		return err
+	}
+	if err := checkID(point.Feed); err != nil {
+		return err
+	}
+	pub, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	sig := new(ecdsaSignature)
+	if _, err := asn1.Unmarshal(point.Signature, sig); err != nil {
+		return fmt.Errorf("Invalid signature of data point of feed %s by %s: %s", point.Feed, point.Provider, err)
+	}
+	if !ecdsa.Verify(pub, dataPointDigest(point), sig.R, sig.S) {
+		return fmt.Errorf("Invalid signature of data point of feed %s by %s", point.Feed, point.Provider)
+	}
+	return nil
+}
+
+// CheckPublication verifies the data points of an invocation of the publish
+// function against the providers in the committed state of the chain, so that
+// the peer rejects them before they are submitted. Other invocations pass.
+// The chaincode verifies them again when the transaction is executed.
+func CheckPublication(spec *pb.ChaincodeInvocationSpec) error {
+	if spec.ChaincodeSpec == nil || spec.ChaincodeSpec.ChaincodeID == nil || spec.ChaincodeSpec.ChaincodeID.Name != Name {
+		return nil
+	}
+	input := spec.ChaincodeSpec.CtorMsg
+	if input == nil || input.Function != "publish" || len(input.Args) != 1 {
+		return nil
+	}
+	points, err := ParseDataPoints(input.Args[0])
+	if err != nil {
+		return err
+	}
+	chainLedger, err := ledger.GetChainLedger(spec.ChaincodeSpec.ChainID)
+	if err != nil {
+		return err
+	}
+	for _, point := range points {
+		if err = checkID(point.Provider); err != nil {
+			return err
+		}
+		key, err := chainLedger.GetState(Name, providerPrefix+point.Provider, true)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return fmt.Errorf("%s is not a provider", point.Provider)
+		}
+		if err = VerifyDataPoint(point, string(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addProvider(stub *shim.ChaincodeStub, id string, key string) error {
+	if ok, err := stub.VerifyAttribute(adminAttribute, adminAttributeValue); err != nil || !ok {
+		return fmt.Errorf("Invoker is not allowed to manage providers")
+	}
+	if err := checkID(id); err != nil {
+		return err
+	}
+	if _, err := parseKey(key); err != nil {
+		return err
+	}
+	return stub.PutState(providerPrefix+id, []byte(key))
+}
+
+func removeProvider(stub *shim.ChaincodeStub, id string) error {
+	if ok, err := stub.VerifyAttribute(adminAttribute, adminAttributeValue); err != nil || !ok {
+		return fmt.Errorf("Invoker is not allowed to manage providers")
+	}
+	if err := checkID(id); err != nil {
+		return err
+	}
+	// The data points of the provider are kept but no longer read
+	return stub.DelState(providerPrefix + id)
+}
+
+func publish(stub *shim.ChaincodeStub, point *DataPoint) error {
+	key, err := getProvider(stub, point.Provider)
+	if err != nil {
+		return err
+	}
+	if err = VerifyDataPoint(point, key); err != nil {
+		return err
+	}
+	last, err := getDataPoint(stub, point.Feed, point.Provider)
+	if err != nil {
+		return err
+	}
+	if last != nil && point.Timestamp <= last.Timestamp {
+		return fmt.Errorf("Data point of feed %s by %s is not later than the last one", point.Feed, point.Provider)
+	}
+	data, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(dataPointKey(point.Feed, point.Provider), data)
+}
+
+// read returns the data point of the provider given as second argument, or
+// the latest data point of the feed given as first argument of any provider
+func read(stub *shim.ChaincodeStub, args []string) ([]byte, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, errors.New("Incorrect number of arguments. Expecting 1 or 2")
+	}
+	if err := checkID(args[0]); err != nil {
+		return nil, err
+	}
+	var providers []string
+	if len(args) == 2 {
+		if _, err := getProvider(stub, args[1]); err != nil {
+			return nil, err
+		}
+		providers = []string{args[1]}
+	} else {
+		var err error
+		if providers, err = getProviders(stub); err != nil {
+			return nil, err
+		}
+	}
+
+	var latest *DataPoint
+	for _, provider := range providers {
+		point, err := getDataPoint(stub, args[0], provider)
+		if err != nil {
+			return nil, err
+		}
+		// providers are sorted, ties go to the first one
+		if point != nil && (latest == nil || point.Timestamp > latest.Timestamp) {
+			latest = point
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("No data point for feed %s", args[0])
+	}
+	return json.Marshal(latest)
+}
+
+// dataPointDigest is the digest signed by the provider of a data point
+func dataPointDigest(point *DataPoint) []byte {
+	h := sha256.New()
+	for _, field := range []string{Name, point.Provider, point.Feed, point.Value} {
+		length := make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(field)))
+		h.Write(length)
+		h.Write([]byte(field))
+	}
+	timestamp := make([]byte, 8)
+	binary.BigEndian.PutUint64(timestamp, uint64(point.Timestamp))
+	h.Write(timestamp)
+	return h.Sum(nil)
+}
+
+func checkID(id string) error {
+	if id == "" || strings.Contains(id, ".") {
+		return fmt.Errorf("Invalid provider or feed %q", id)
+	}
+	return nil
+}
+
+func parseKey(key string) (*ecdsa.PublicKey, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key: %s", err)
+	}
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("Invalid key, expecting a P-256 point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func getProvider(stub *shim.ChaincodeStub, id string) (string, error) {
+	if err := checkID(id); err != nil {
+		return "", err
+	}
+	key, err := stub.GetState(providerPrefix + id)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", fmt.Errorf("%s is not a provider", id)
+	}
+	return string(key), nil
+}
+
+func getProviders(stub *shim.ChaincodeStub) ([]string, error) {
+	iter, err := stub.RangeQueryState(providerPrefix, strings.TrimSuffix(providerPrefix, ".")+"/")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	providers := []string{}
+	for iter.HasNext() {
+		key, _, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, strings.TrimPrefix(key, providerPrefix))
+	}
+	sort.Strings(providers)
+	return providers, nil
+}
+
+func dataPointKey(feed string, provider string) string {
+	return feedPrefix + feed + "." + provider
+}
+
+func getDataPoint(stub *shim.ChaincodeStub, feed string, provider string) (*DataPoint, error) {
+	raw, err := stub.GetState(dataPointKey(feed, provider))
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	point := &DataPoint{}
+	if err = json.Unmarshal(raw, point); err != nil {
+		return nil, fmt.Errorf("Invalid data point of feed %s by %s: %s", feed, provider, err)
+	}
+	return point, nil
+}
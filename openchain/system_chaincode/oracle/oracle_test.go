@@ -0,0 +1,117 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package oracle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func newProvider(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	return key, hex.EncodeToString(elliptic.Marshal(elliptic.P256(), key.X, key.Y))
+}
+
+func TestVerifyDataPoint(t *testing.T) {
+	key, pub := newProvider(t)
+
+	point, err := NewDataPoint(key, "acme", "EURUSD", "1.0834", 1460000000)
+	if err != nil {
+		t.Fatalf("Failed creating data point [%s]", err)
+	}
+
+	// The data point goes through JSON as the argument of the chaincode
+	raw, _ := json.Marshal(point)
+	points, err := ParseDataPoints(string(raw))
+	if err != nil {
+		t.Fatalf("Failed parsing data point [%s]", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("Expected 1 data point, got %d", len(points))
+	}
+	if err := VerifyDataPoint(points[0], pub); err != nil {
+		t.Fatalf("Failed verifying data point [%s]", err)
+	}
+
+	// Every field is signed
+	for _, tamper := range []func(p *DataPoint){
+		func(p *DataPoint) { p.Provider = "other" },
+		func(p *DataPoint) { p.Feed = "GBPUSD" },
+		func(p *DataPoint) { p.Value = "1.5" },
+		func(p *DataPoint) { p.Timestamp++ },
+	} {
+		tampered := *point
+		tamper(&tampered)
+		if err := VerifyDataPoint(&tampered, pub); err == nil {
+			t.Fatalf("Tampered data point %v must not verify", tampered)
+		}
+	}
+
+	// Only the key of the provider signs its data points
+	_, other := newProvider(t)
+	if err := VerifyDataPoint(point, other); err == nil {
+		t.Fatal("Data point must not verify with another key")
+	}
+}
+
+func TestParseDataPoints(t *testing.T) {
+	key, pub := newProvider(t)
+	var points []*DataPoint
+	for _, feed := range []string{"EURUSD", "GBPUSD"} {
+		point, err := NewDataPoint(key, "acme", feed, "1", 1460000000)
+		if err != nil {
+			t.Fatalf("Failed creating data point [%s]", err)
+		}
+		points = append(points, point)
+	}
+	raw, _ := json.Marshal(points)
+	parsed, err := ParseDataPoints(string(raw))
+	if err != nil {
+		t.Fatalf("Failed parsing data points [%s]", err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("Expected 2 data points, got %d", len(parsed))
+	}
+	for _, point := range parsed {
+		if err := VerifyDataPoint(point, pub); err != nil {
+			t.Fatalf("Failed verifying data point [%s]", err)
+		}
+	}
+
+	if _, err := ParseDataPoints("[]"); err == nil {
+		t.Fatal("Publishing no data point must fail")
+	}
+	if _, err := ParseDataPoints("{"); err == nil {
+		t.Fatal("Invalid JSON must fail")
+	}
+	dotted, err := NewDataPoint(key, "ac.me", "EURUSD", "1", 1460000000)
+	if err != nil {
+		t.Fatalf("Failed creating data point [%s]", err)
+	}
+	if err := VerifyDataPoint(dotted, pub); err == nil {
+		t.Fatal("Provider IDs containing dots must be rejected")
+	}
+}
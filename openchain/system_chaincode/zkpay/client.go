@@ -0,0 +1,72 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package zkpay
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/zkp"
+)
+
+// NewTransfer creates a transfer of amount from the account from, controlled
+// by key, to the account to. nonce is the nonce of the sender account and
+// balance the opening of its balance. data is passed to the recipient in the
+// memo, normally the opening of the amount encrypted to the recipient. It
+// returns the transfer with the opening of the amount, for the recipient,
+// and the opening of the balance left to the sender.
+func NewTransfer(key *ecdsa.PrivateKey, from string, to string, nonce uint64, balance Opening, amount uint64, data func(Opening) ([]byte, error)) (*Transfer, *Opening, *Opening, error) {
+	if amount > balance.Value {
+		return nil, nil, nil, errors.New("Insufficient balance")
+	}
+	params := zkp.DefaultParams()
+	blinding, err := params.RandomScalar()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	amountOpening := &Opening{Value: amount, Blinding: blinding}
+	remainderBlinding := new(big.Int).Sub(balance.Blinding, blinding)
+	remainderOpening := &Opening{Value: balance.Value - amount, Blinding: remainderBlinding.Mod(remainderBlinding, params.Curve.Params().N)}
+
+	transfer := &Transfer{Amount: params.Marshal(params.Commit(amount, blinding))}
+	if data != nil {
+		if transfer.Data, err = data(*amountOpening); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	digest := transferDigest(from, to, nonce, transfer.Amount, transfer.Data)
+	if transfer.AmountProof, err = params.ProveRange(amountOpening.Value, amountOpening.Blinding, AmountBits, digest); err != nil {
+		return nil, nil, nil, err
+	}
+	if transfer.RemainderProof, err = params.ProveRange(remainderOpening.Value, remainderOpening.Blinding, AmountBits, digest); err != nil {
+		return nil, nil, nil, err
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if transfer.Signature, err = asn1.Marshal(ecdsaSignature{R: r, S: s}); err != nil {
+		return nil, nil, nil, err
+	}
+	return transfer, amountOpening, remainderOpening, nil
+}
@@ -0,0 +1,421 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+// Package zkpay implements the zkpay system chaincode, which transfers an
+// asset between accounts without revealing the amounts. Balances and amounts
+// are Pedersen commitments, a transfer proves with range proofs that neither
+// the amount nor the balance left to the sender are negative. Only issuance,
+// by invokers holding the attribute role=issuer, is in the clear.
+//
+// Accounts are controlled by an ECDSA P-256 key, transfers are signed with
+// it. The openings of the commitments (value and blinding) are kept by the
+// owners of the accounts: the sender of a transfer passes the opening of the
+// amount to the recipient in the memo of the transfer, encrypted as they
+// agree, the chaincode does not interpret it.
+package zkpay
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openblockchain/obc-peer/openchain/chaincode/shim"
+	"github.com/openblockchain/obc-peer/openchain/crypto/zkp"
+)
+
+// Name and Path of the zkpay system chaincode
+const (
+	Name = "zkpay"
+	Path = "github.com/openblockchain/obc-peer/openchain/system_chaincode/zkpay"
+)
+
+// AmountBits is the number of bits amounts and balances are proven to fit in
+const AmountBits = 64
+
+// issuerAttribute is the attribute, with its value, invokers must hold to issue
+const (
+	issuerAttribute      = "role"
+	issuerAttributeValue = "issuer"
+)
+
+const (
+	accountPrefix = "account."
+	memoPrefix    = "memo."
+	supplyKey     = "supply"
+)
+
+// Account is the state of an account
+type Account struct {
+	// Key controlling the account, marshalled with elliptic.Marshal
+	Key []byte `json:"key"`
+	// Commitment to the balance
+	Balance []byte `json:"balance"`
+	// Number of transfers from the account, signed along with each transfer
+	Nonce uint64 `json:"nonce"`
+	// Number of memos received
+	Received uint64 `json:"received"`
+}
+
+// Opening is the value and the blinding of a commitment
+type Opening struct {
+	Value    uint64   `json:"value"`
+	Blinding *big.Int `json:"blinding"`
+}
+
+// Memo records a transfer or an issuance to an account
+type Memo struct {
+	// Sender, empty for an issuance
+	From string `json:"from"`
+	// Commitment to the amount
+	Amount []byte `json:"amount"`
+	// For an issuance, the opening of the amount in JSON. For a transfer,
+	// data from the sender, normally the opening encrypted to the recipient.
+	Data []byte `json:"data"`
+}
+
+// Transfer is the argument of the transfer function
+type Transfer struct {
+	// Commitment to the amount
+	Amount []byte `json:"amount"`
+	// Proof that the amount is not negative
+	AmountProof *zkp.RangeProof `json:"amountProof"`
+	// Proof that the balance of the sender minus the amount is not negative
+	RemainderProof *zkp.RangeProof `json:"remainderProof"`
+	// Data passed to the recipient in the memo
+	Data []byte `json:"data"`
+	// ASN.1 ECDSA signature of the transfer by the key of the sender
+	Signature []byte `json:"signature"`
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Payments is the zkpay system chaincode. Its open function creates an
+// account controlled by a hex encoded key, issue adds an amount to the
+// balance of an account and transfer applies a Transfer in JSON between two
+// accounts. Its balance query returns an Account in JSON, its memos query
+// the Memos received by an account.
+type Payments struct {
+}
+
+// Run runs the open, issue and transfer functions
+func (t *Payments) Run(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	switch function {
+	case "open":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		return nil, open(stub, args[0], args[1])
+	case "issue":
+		if len(args) != 2 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 2")
+		}
+		return nil, issue(stub, args[0], args[1])
+	case "transfer":
+		if len(args) != 3 {
+			return nil, errors.New("Incorrect number of arguments. Expecting 3")
+		}
+		transfer := &Transfer{}
+		if err := json.Unmarshal([]byte(args[2]), transfer); err != nil {
+			return nil, fmt.Errorf("Invalid transfer: %s", err)
+		}
+		return nil, transferAmount(stub, args[0], args[1], transfer)
+	}
+	return nil, errors.New("Received unknown function invocation")
+}
+
+// Query runs the balance and memos queries
+func (t *Payments) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting 1")
+	}
+	switch function {
+	case "balance":
+		account, err := getAccount(stub, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(account)
+	case "memos":
+		memos, err := getMemos(stub, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(memos)
+	}
+	return nil, errors.New("Invalid query function name. Expecting \"balance\" or \"memos\"")
+}
+
+func open(stub *shim.ChaincodeStub, id string, key string) error {
+	if err := checkAccountID(id); err != nil {
+		return err
+	}
+	raw, err := stub.GetState(accountPrefix + id)
+	if err != nil {
+		return err
+	}
+	if raw != nil {
+		return fmt.Errorf("Account %s already exists", id)
+	}
+	pub, err := parseKey(key)
+	if err != nil {
+		return err
+	}
+	params := zkp.DefaultParams()
+	return putAccount(stub, id, &Account{Key: elliptic.Marshal(params.Curve, pub.X, pub.Y), Balance: params.Marshal(params.Infinity())})
+}
+
+func issue(stub *shim.ChaincodeStub, id string, amount string) error {
+	if ok, err := stub.VerifyAttribute(issuerAttribute, issuerAttributeValue); err != nil || !ok {
+		return fmt.Errorf("Invoker is not allowed to issue")
+	}
+	value, err := strconv.ParseUint(amount, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid amount %s: %s", amount, err)
+	}
+	account, err := getAccount(stub, id)
+	if err != nil {
+		return err
+	}
+
+	// Issuance is public, it is tracked by the total supply
+	raw, err := stub.GetState(supplyKey)
+	if err != nil {
+		return err
+	}
+	supply := new(big.Int)
+	if raw != nil {
+		supply.SetString(string(raw), 10)
+	}
+	if err = stub.PutState(supplyKey, []byte(supply.Add(supply, new(big.Int).SetUint64(value)).String())); err != nil {
+		return err
+	}
+
+	params := zkp.DefaultParams()
+	opening := Opening{Value: value, Blinding: new(big.Int)}
+	commitment := params.Commit(value, opening.Blinding)
+	if account.Balance, err = addCommitment(params, account.Balance, commitment); err != nil {
+		return err
+	}
+	data, err := json.Marshal(opening)
+	if err != nil {
+		return err
+	}
+	if err = putMemo(stub, id, account, &Memo{Amount: params.Marshal(commitment), Data: data}); err != nil {
+		return err
+	}
+	return putAccount(stub, id, account)
+}
+
+func transferAmount(stub *shim.ChaincodeStub, from string, to string, transfer *Transfer) error {
+	if from == to {
+		return errors.New("Sender and recipient must differ")
+	}
+	sender, err := getAccount(stub, from)
+	if err != nil {
+		return err
+	}
+	recipient, err := getAccount(stub, to)
+	if err != nil {
+		return err
+	}
+
+	params := zkp.DefaultParams()
+	amount, remainder, err := verifyTransfer(params, from, to, sender, transfer)
+	if err != nil {
+		return err
+	}
+
+	sender.Balance = params.Marshal(remainder)
+	sender.Nonce++
+	if recipient.Balance, err = addCommitment(params, recipient.Balance, amount); err != nil {
+		return err
+	}
+	if err = putMemo(stub, to, recipient, &Memo{From: from, Amount: transfer.Amount, Data: transfer.Data}); err != nil {
+		return err
+	}
+	if err = putAccount(stub, from, sender); err != nil {
+		return err
+	}
+	return putAccount(stub, to, recipient)
+}
+
+// verifyTransfer checks the signature and the range proofs of a transfer from
+// the sender account, and returns the commitments to the amount and to the
+// balance left to the sender
+func verifyTransfer(params *zkp.Params, from string, to string, sender *Account, transfer *Transfer) (*zkp.Point, *zkp.Point, error) {
+	x, y := elliptic.Unmarshal(params.Curve, sender.Key)
+	if x == nil {
+		return nil, nil, fmt.Errorf("Invalid key of account %s", from)
+	}
+	digest := transferDigest(from, to, sender.Nonce, transfer.Amount, transfer.Data)
+	sig := new(ecdsaSignature)
+	if _, err := asn1.Unmarshal(transfer.Signature, sig); err != nil {
+		return nil, nil, fmt.Errorf("Invalid signature: %s", err)
+	}
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: params.Curve, X: x, Y: y}, digest, sig.R, sig.S) {
+		return nil, nil, errors.New("Invalid signature")
+	}
+
+	amount, err := params.Unmarshal(transfer.Amount)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid amount: %s", err)
+	}
+	if err = params.VerifyRange(amount, transfer.AmountProof, AmountBits, digest); err != nil {
+		return nil, nil, fmt.Errorf("Invalid amount: %s", err)
+	}
+	balance, err := params.Unmarshal(sender.Balance)
+	if err != nil {
+		return nil, nil, err
+	}
+	remainder := params.Sub(balance, amount)
+	if err = params.VerifyRange(remainder, transfer.RemainderProof, AmountBits, digest); err != nil {
+		return nil, nil, fmt.Errorf("Insufficient balance: %s", err)
+	}
+	return amount, remainder, nil
+}
+
+// transferDigest is the digest signed by the sender of a transfer, the
+// range proofs are bound to it
+func transferDigest(from string, to string, nonce uint64, amount []byte, data []byte) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{[]byte(Name), []byte(from), []byte(to), amount, data} {
+		length := make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(field)))
+		h.Write(length)
+		h.Write(field)
+	}
+	n := make([]byte, 8)
+	binary.BigEndian.PutUint64(n, nonce)
+	h.Write(n)
+	return h.Sum(nil)
+}
+
+func addCommitment(params *zkp.Params, raw []byte, commitment *zkp.Point) ([]byte, error) {
+	balance, err := params.Unmarshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return params.Marshal(params.Add(balance, commitment)), nil
+}
+
+func checkAccountID(id string) error {
+	if id == "" || strings.Contains(id, ".") {
+		return fmt.Errorf("Invalid account %q", id)
+	}
+	return nil
+}
+
+func parseKey(key string) (*ecdsa.PublicKey, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid key: %s", err)
+	}
+	curve := zkp.DefaultParams().Curve
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("Invalid key, expecting a P-256 point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func getAccount(stub *shim.ChaincodeStub, id string) (*Account, error) {
+	if err := checkAccountID(id); err != nil {
+		return nil, err
+	}
+	raw, err := stub.GetState(accountPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("Account %s does not exist", id)
+	}
+	account := &Account{}
+	if err = json.Unmarshal(raw, account); err != nil {
+		return nil, fmt.Errorf("Invalid account %s: %s", id, err)
+	}
+	return account, nil
+}
+
+func putAccount(stub *shim.ChaincodeStub, id string, account *Account) error {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(accountPrefix+id, data)
+}
+
+// memoKey orders the memos of an account by the order they were received in
+func memoKey(id string, index uint64) string {
+	return fmt.Sprintf("%s%s.%020d", memoPrefix, id, index)
+}
+
+func putMemo(stub *shim.ChaincodeStub, id string, account *Account, memo *Memo) error {
+	data, err := json.Marshal(memo)
+	if err != nil {
+		return err
+	}
+	if err = stub.PutState(memoKey(id, account.Received), data); err != nil {
+		return err
+	}
+	account.Received++
+	return nil
+}
+
+func getMemos(stub *shim.ChaincodeStub, id string) ([]*Memo, error) {
+	if err := checkAccountID(id); err != nil {
+		return nil, err
+	}
+	iter, err := stub.RangeQueryState(memoPrefix+id+".", memoPrefix+id+"/")
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var keys []string
+	memos := make(map[string]*Memo)
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		memo := &Memo{}
+		if err = json.Unmarshal(value, memo); err != nil {
+			return nil, fmt.Errorf("Invalid memo %s: %s", key, err)
+		}
+		keys = append(keys, key)
+		memos[key] = memo
+	}
+	sort.Strings(keys)
+	result := make([]*Memo, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, memos[key])
+	}
+	return result, nil
+}
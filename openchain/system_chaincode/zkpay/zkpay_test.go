@@ -0,0 +1,111 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+package zkpay
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/openblockchain/obc-peer/openchain/crypto/zkp"
+)
+
+func newAccount(t *testing.T, value uint64) (*ecdsa.PrivateKey, *Account, Opening) {
+	params := zkp.DefaultParams()
+	key, err := ecdsa.GenerateKey(params.Curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed generating key [%s]", err)
+	}
+	blinding, _ := params.RandomScalar()
+	account := &Account{
+		Key:     elliptic.Marshal(params.Curve, key.X, key.Y),
+		Balance: params.Marshal(params.Commit(value, blinding)),
+		Nonce:   3,
+	}
+	return key, account, Opening{Value: value, Blinding: blinding}
+}
+
+func TestVerifyTransfer(t *testing.T) {
+	params := zkp.DefaultParams()
+	key, sender, balance := newAccount(t, 100)
+
+	transfer, amount, remainder, err := NewTransfer(key, "alice", "bob", sender.Nonce, balance, 30, nil)
+	if err != nil {
+		t.Fatalf("Failed creating transfer [%s]", err)
+	}
+	if amount.Value != 30 || remainder.Value != 70 {
+		t.Fatalf("Unexpected openings %d and %d", amount.Value, remainder.Value)
+	}
+
+	// The transfer goes through JSON as the argument of the chaincode
+	raw, _ := json.Marshal(transfer)
+	transfer = &Transfer{}
+	if err := json.Unmarshal(raw, transfer); err != nil {
+		t.Fatalf("Failed unmarshalling transfer [%s]", err)
+	}
+
+	a, r, err := verifyTransfer(params, "alice", "bob", sender, transfer)
+	if err != nil {
+		t.Fatalf("Failed verifying transfer [%s]", err)
+	}
+	if !params.Equal(a, params.Commit(amount.Value, amount.Blinding)) {
+		t.Fatal("Amount does not match its opening")
+	}
+	if !params.Equal(r, params.Commit(remainder.Value, remainder.Blinding)) {
+		t.Fatal("Remainder does not match its opening")
+	}
+
+	// Transfers are bound to the accounts and the nonce of the sender
+	if _, _, err := verifyTransfer(params, "alice", "carol", sender, transfer); err == nil {
+		t.Fatal("Transfer must not verify for another recipient")
+	}
+	replayed := *sender
+	replayed.Nonce++
+	if _, _, err := verifyTransfer(params, "alice", "bob", &replayed, transfer); err == nil {
+		t.Fatal("Transfer must not verify with another nonce")
+	}
+
+	// Only the key of the sender signs transfers
+	other, _, _ := newAccount(t, 0)
+	forged, _, _, _ := NewTransfer(other, "alice", "bob", sender.Nonce, balance, 30, nil)
+	if _, _, err := verifyTransfer(params, "alice", "bob", sender, forged); err == nil {
+		t.Fatal("Transfer signed by another key must not verify")
+	}
+}
+
+func TestVerifyTransferOverspending(t *testing.T) {
+	params := zkp.DefaultParams()
+	key, sender, balance := newAccount(t, 100)
+
+	// Claiming a larger balance than the committed one leaves a negative remainder
+	claimed := Opening{Value: 1000, Blinding: balance.Blinding}
+	transfer, _, _, err := NewTransfer(key, "alice", "bob", sender.Nonce, claimed, 500, nil)
+	if err != nil {
+		t.Fatalf("Failed creating transfer [%s]", err)
+	}
+	if _, _, err := verifyTransfer(params, "alice", "bob", sender, transfer); err == nil {
+		t.Fatal("Transfer of more than the balance must not verify")
+	}
+
+	if _, _, _, err := NewTransfer(key, "alice", "bob", sender.Nonce, balance, 101, nil); err == nil {
+		t.Fatal("Creating a transfer of more than the balance must fail")
+	}
+}
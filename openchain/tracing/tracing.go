@@ -0,0 +1,274 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+// Package tracing records spans of the life of a transaction, from the
+// submission through devops to the ordering by consensus, the execution of
+// the chaincode and the commit to the ledger, and reports them to a Zipkin
+// collector. The trace of a transaction is identified by its UUID, which is
+// carried everywhere the transaction goes, so the spans emitted by every peer
+// for the same transaction end up in the same trace.
+package tracing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.MustGetLogger("tracing")
+
+// maxOpenSpanAge is how long a span begun with Begin waits for its End, eg.
+// when the transaction is dropped by consensus, before it is discarded
+const maxOpenSpanAge = 10 * time.Minute
+
+// Endpoint is the service which recorded a span
+type Endpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// BinaryAnnotation is a tag of a span, eg. the chaincode name
+type BinaryAnnotation struct {
+	Key      string    `json:"key"`
+	Value    string    `json:"value"`
+	Endpoint *Endpoint `json:"endpoint,omitempty"`
+}
+
+// Span is a timed operation on a transaction in the Zipkin v1 JSON format.
+// Timestamp and Duration are in microseconds.
+type Span struct {
+	TraceID           string              `json:"traceId"`
+	ID                string              `json:"id"`
+	ParentID          string              `json:"parentId,omitempty"`
+	Name              string              `json:"name"`
+	Timestamp         int64               `json:"timestamp"`
+	Duration          int64               `json:"duration"`
+	BinaryAnnotations []*BinaryAnnotation `json:"binaryAnnotations,omitempty"`
+
+	tracer *Tracer
+	start  time.Time
+}
+
+// Tag adds a tag to the span. It does nothing on a nil span, which is what
+// the tracing functions return when tracing is disabled.
+func (s *Span) Tag(key, value string) {
+	if s == nil {
+		return
+	}
+	s.BinaryAnnotations = append(s.BinaryAnnotations, &BinaryAnnotation{Key: key, Value: value, Endpoint: s.tracer.endpoint})
+}
+
+// Finish ends the span and queues it for reporting
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.Duration = int64(time.Since(s.start) / time.Microsecond)
+	s.tracer.report(s)
+}
+
+// Tracer creates spans and reports them in batches to a Zipkin collector
+type Tracer struct {
+	sync.Mutex
+	endpoint  *Endpoint
+	url       string
+	batchSize int
+	spans     []*Span
+	open      map[string]*Span
+	client    *http.Client
+}
+
+// NewTracer creates a tracer which reports the spans of serviceName to the
+// Zipkin collector at url, eg. http://localhost:9411/api/v1/spans, once
+// batchSize spans are queued or Flush is called
+func NewTracer(serviceName, url string, batchSize int) *Tracer {
+	return &Tracer{
+		endpoint:  &Endpoint{ServiceName: serviceName},
+		url:       url,
+		batchSize: batchSize,
+		open:      make(map[string]*Span),
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// TraceID returns the trace identifier of the transaction with the UUID,
+// which is also the identifier of its root span
+func TraceID(uuid string) string {
+	hash := sha256.Sum256([]byte(uuid))
+	return fmt.Sprintf("%016x", binary.BigEndian.Uint64(hash[:8]))
+}
+
+func (t *Tracer) newSpan(uuid, name string, root bool) *Span {
+	if t == nil {
+		return nil
+	}
+	traceID := TraceID(uuid)
+	span := &Span{TraceID: traceID, Name: name, tracer: t, start: time.Now()}
+	span.Timestamp = span.start.UnixNano() / int64(time.Microsecond)
+	if root {
+		span.ID = traceID
+	} else {
+		span.ID = fmt.Sprintf("%016x", uint64(rand.Int63()))
+		span.ParentID = traceID
+	}
+	span.Tag("tx.uuid", uuid)
+	return span
+}
+
+// StartRootSpan starts the root span of the trace of the transaction
+func (t *Tracer) StartRootSpan(uuid, name string) *Span {
+	return t.newSpan(uuid, name, true)
+}
+
+// StartSpan starts a span as child of the root span of the transaction
+func (t *Tracer) StartSpan(uuid, name string) *Span {
+	return t.newSpan(uuid, name, false)
+}
+
+// Begin starts a span which is finished by End with the same transaction
+// UUID and name, for operations that start and end in different places
+func (t *Tracer) Begin(uuid, name string) {
+	if t == nil {
+		return
+	}
+	span := t.StartSpan(uuid, name)
+	t.Lock()
+	defer t.Unlock()
+	t.open[uuid+"/"+name] = span
+}
+
+// End finishes the span begun for the transaction UUID and name, if any
+func (t *Tracer) End(uuid, name string) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	span, ok := t.open[uuid+"/"+name]
+	delete(t.open, uuid+"/"+name)
+	t.Unlock()
+	if ok {
+		span.Finish()
+	}
+}
+
+func (t *Tracer) report(span *Span) {
+	t.Lock()
+	t.spans = append(t.spans, span)
+	full := len(t.spans) >= t.batchSize
+	t.Unlock()
+	if full {
+		go t.Flush()
+	}
+}
+
+// Flush sends the queued spans to the collector and discards the spans begun
+// too long ago to be ended
+func (t *Tracer) Flush() error {
+	t.Lock()
+	spans := t.spans
+	t.spans = nil
+	for key, span := range t.open {
+		if time.Since(span.start) > maxOpenSpanAge {
+			delete(t.open, key)
+		}
+	}
+	t.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(spans)
+	if err != nil {
+		return fmt.Errorf("Error encoding spans: %s", err)
+	}
+	resp, err := t.client.Post(t.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Warning("Error reporting %d spans to %s: %s", len(spans), t.url, err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger.Warning("Error reporting %d spans to %s: %s", len(spans), t.url, resp.Status)
+		return fmt.Errorf("Error reporting spans: %s", resp.Status)
+	}
+	return nil
+}
+
+var defaultTracer struct {
+	once   sync.Once
+	tracer *Tracer
+}
+
+// GetTracer returns the tracer configured in the tracing section, or nil if
+// tracing is disabled. The methods of a nil tracer do nothing.
+func GetTracer() *Tracer {
+	defaultTracer.once.Do(func() {
+		if !viper.GetBool("tracing.enabled") {
+			return
+		}
+		serviceName := viper.GetString("tracing.serviceName")
+		if serviceName == "" {
+			serviceName = viper.GetString("peer.id")
+		}
+		batchSize := viper.GetInt("tracing.batchSize")
+		if batchSize <= 0 {
+			batchSize = 100
+		}
+		t := NewTracer(serviceName, viper.GetString("tracing.zipkin.url"), batchSize)
+		if interval := viper.GetDuration("tracing.flushInterval"); interval > 0 {
+			go func() {
+				for range time.Tick(interval) {
+					t.Flush()
+				}
+			}()
+		}
+		logger.Info("Reporting spans of %s to %s", serviceName, t.url)
+		defaultTracer.tracer = t
+	})
+	return defaultTracer.tracer
+}
+
+// StartRootSpan starts the root span of the transaction with the configured
+// tracer
+func StartRootSpan(uuid, name string) *Span {
+	return GetTracer().StartRootSpan(uuid, name)
+}
+
+// StartSpan starts a span of the transaction with the configured tracer
+func StartSpan(uuid, name string) *Span {
+	return GetTracer().StartSpan(uuid, name)
+}
+
+// Begin begins a span of the transaction with the configured tracer
+func Begin(uuid, name string) {
+	GetTracer().Begin(uuid, name)
+}
+
+// End ends a span of the transaction begun with Begin
+func End(uuid, name string) {
+	GetTracer().End(uuid, name)
+}
@@ -0,0 +1,98 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceID(t *testing.T) {
+	if TraceID("uuid1") != TraceID("uuid1") {
+		t.Fatal("Expected the same trace id for the same transaction")
+	}
+	if TraceID("uuid1") == TraceID("uuid2") {
+		t.Fatal("Expected different trace ids for different transactions")
+	}
+	if len(TraceID("uuid1")) != 16 {
+		t.Fatalf("Expected a 64 bit hex trace id, got %s", TraceID("uuid1"))
+	}
+}
+
+func TestTracerReportsSpans(t *testing.T) {
+	received := make(chan []*Span, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []*Span
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("Error decoding spans: %s", err)
+		}
+		received <- spans
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("vp0", server.URL, 100)
+	root := tracer.StartRootSpan("uuid1", "devops.invoke")
+	tracer.Begin("uuid1", "consensus.order")
+	tracer.End("uuid1", "consensus.order")
+	tracer.End("uuid1", "consensus.order")
+	span := tracer.StartSpan("uuid1", "chaincode.execute")
+	span.Tag("chaincode", "mycc")
+	span.Finish()
+	root.Finish()
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Error flushing spans: %s", err)
+	}
+
+	spans := <-received
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	traceID := TraceID("uuid1")
+	for _, s := range spans {
+		if s.TraceID != traceID {
+			t.Fatalf("Expected trace id %s of span %s, got %s", traceID, s.Name, s.TraceID)
+		}
+		if s.Name != "devops.invoke" && s.ParentID != traceID {
+			t.Fatalf("Expected span %s to be a child of the root span", s.Name)
+		}
+	}
+	if spans[2].ID != traceID || spans[2].ParentID != "" {
+		t.Fatalf("Expected root span with the trace id, got %+v", spans[2])
+	}
+	tags := spans[1].BinaryAnnotations
+	if len(tags) != 2 || tags[1].Key != "chaincode" || tags[1].Value != "mycc" || tags[1].Endpoint.ServiceName != "vp0" {
+		t.Fatalf("Unexpected tags %+v", tags)
+	}
+
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Error flushing no spans: %s", err)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.StartSpan("uuid1", "chaincode.execute")
+	span.Tag("chaincode", "mycc")
+	span.Finish()
+	tracer.Begin("uuid1", "consensus.order")
+	tracer.End("uuid1", "consensus.order")
+}
@@ -27,11 +27,16 @@ import (
 
 	gp "google/protobuf"
 
+	"github.com/openblockchain/obc-peer/openchain/crypto/conf"
 	"golang.org/x/crypto/sha3"
 )
 
-// ComputeCryptoHash should be used in openchain code so that we can change the actual algo used for crypto-hash at one place
+// ComputeCryptoHash should be used in openchain code so that we can change the actual algo used for crypto-hash at one place.
+// It follows the crypto suite of the network once initialized.
 func ComputeCryptoHash(data []byte) (hash []byte) {
+	if suite := conf.GetCryptoSuite(); suite != nil {
+		return suite.DataHash(data)
+	}
 	hash = make([]byte, 64)
 	sha3.ShakeSum256(hash, data)
 	return
@@ -54,6 +59,20 @@ func GenerateUUID() string {
 	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
 }
 
+// GenerateUUIDFromData returns a UUID derived from the crypto hash of the
+// supplied data, so the same data always yields the same UUID
+func GenerateUUIDFromData(data []byte) string {
+	uuid := ComputeCryptoHash(data)[:16]
+
+	// variant bits; see section 4.1.1
+	uuid[8] = uuid[8]&^0xc0 | 0x80
+
+	// version 5 (name-based); see section 4.1.3
+	uuid[6] = uuid[6]&^0xf0 | 0x50
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
 // CreateUtcTimestamp returns a google/protobuf/Timestamp in UTC
 func CreateUtcTimestamp() *gp.Timestamp {
 	now := time.Now().UTC()
@@ -45,6 +45,19 @@ func TestUUIDGeneration(t *testing.T) {
 	}
 }
 
+func TestUUIDFromData(t *testing.T) {
+	uuid := GenerateUUIDFromData([]byte("foobar"))
+	if len(uuid) != 36 {
+		t.Fatalf("UUID length is not correct. Expected = 36, Got = %d", len(uuid))
+	}
+	if uuid != GenerateUUIDFromData([]byte("foobar")) {
+		t.Fatalf("Expected UUIDs generated from the same data to match")
+	}
+	if uuid == GenerateUUIDFromData([]byte("foobar1")) {
+		t.Fatalf("Expected UUIDs generated from different data to differ")
+	}
+}
+
 func TestTimestamp(t *testing.T) {
 	for i := 0; i < 10; i++ {
 		t.Logf("timestamp now: %v", CreateUtcTimestamp())
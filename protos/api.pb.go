@@ -16,33 +16,66 @@ It is generated from these files:
 It has these top-level messages:
 	BlockNumber
 	BlockCount
+	StateProofRequest
+	StateProof
+	BlockHeadersRequest
+	BlockHeader
+	BlockHeaders
+	TransactionProofRequest
+	TransactionProof
 	ChaincodeID
 	ChaincodeInput
 	ChaincodeSpec
 	ChaincodeDeploymentSpec
+	ChaincodePackageHeader
+	ChaincodePackageSignature
+	CodeFinding
 	ChaincodeInvocationSpec
+	ChaincodeKeyHints
 	ChaincodeIdentifier
 	ChaincodeRequestContext
 	ChaincodeExecutionContext
 	ChaincodeMessage
+	ChaincodeSecurityContext
 	PutStateInfo
+	PutStatePart
+	DeploymentInfo
 	RangeQueryState
 	RangeQueryStateNext
 	RangeQueryStateClose
 	RangeQueryStateKeyValue
 	RangeQueryStateResponse
+	ChaincodeEvent
 	Secret
 	BuildResult
+	DeploymentStatus
+	PreLaunchCheck
+	ChaincodeInfo
+	ChaincodeInfoList
+	DeterminismCheckSpec
+	DeterminismReport
+	DeadLetter
+	DeadLetterList
+	DeadLetterID
+	DeadLetterPurge
 	Interest
 	Register
 	Generic
+	Rejection
+	PeerReady
 	OpenchainEvent
 	Transaction
 	TransactionBlock
 	TransactionResult
+	ResourceUsage
 	Block
+	ArchivedBlock
 	BlockchainInfo
 	NonHashData
+	BlockSignature
+	QuorumCertificate
+	ConsensusMetadata
+	BlockMetadata
 	PeerAddress
 	PeerID
 	PeerEndpoint
@@ -94,6 +127,133 @@ func (m *BlockCount) Reset()         { *m = BlockCount{} }
 func (m *BlockCount) String() string { return proto.CompactTextString(m) }
 func (*BlockCount) ProtoMessage()    {}
 
+// Specifies the key of the state of a chain whose value is to be proven.
+type StateProofRequest struct {
+	ChainID     string `protobuf:"bytes,1,opt,name=chainID" json:"chainID,omitempty"`
+	ChaincodeID string `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *StateProofRequest) Reset()         { *m = StateProofRequest{} }
+func (m *StateProofRequest) String() string { return proto.CompactTextString(m) }
+func (*StateProofRequest) ProtoMessage()    {}
+
+// Value of a key in the committed state of a chain, proven against the state
+// hash of a block of the chain. The proof is specific to the state data
+// structure of the network.
+type StateProof struct {
+	ChainID     string `protobuf:"bytes,1,opt,name=chainID" json:"chainID,omitempty"`
+	ChaincodeID string `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,3,opt,name=key" json:"key,omitempty"`
+	Value       []byte `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,5,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	StateHash   []byte `protobuf:"bytes,6,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+	Proof       []byte `protobuf:"bytes,7,opt,name=proof,proto3" json:"proof,omitempty"`
+}
+
+func (m *StateProof) Reset()         { *m = StateProof{} }
+func (m *StateProof) String() string { return proto.CompactTextString(m) }
+func (*StateProof) ProtoMessage()    {}
+
+// Specifies the range of blocks whose headers are to be returned, from start
+// to end inclusive. A peer may return fewer headers than requested.
+type BlockHeadersRequest struct {
+	Start uint64 `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	End   uint64 `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *BlockHeadersRequest) Reset()         { *m = BlockHeadersRequest{} }
+func (m *BlockHeadersRequest) String() string { return proto.CompactTextString(m) }
+func (*BlockHeadersRequest) ProtoMessage()    {}
+
+// BlockHeader is a block without its transactions. The hash of the block
+// can't be computed without the transactions, so it is carried along and
+// vouched for by the signatures of the validators that committed the block,
+// which cover every field of the header but the signatures.
+type BlockHeader struct {
+	Number            uint64                      `protobuf:"varint,1,opt,name=number" json:"number,omitempty"`
+	Hash              []byte                      `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Version           uint32                      `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	Timestamp         *google_protobuf1.Timestamp `protobuf:"bytes,4,opt,name=timestamp" json:"timestamp,omitempty"`
+	StateHash         []byte                      `protobuf:"bytes,5,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+	PreviousBlockHash []byte                      `protobuf:"bytes,6,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
+	ConsensusMetadata []byte                      `protobuf:"bytes,7,opt,name=consensusMetadata,proto3" json:"consensusMetadata,omitempty"`
+	Signatures        []*BlockSignature           `protobuf:"bytes,8,rep,name=signatures" json:"signatures,omitempty"`
+}
+
+func (m *BlockHeader) Reset()         { *m = BlockHeader{} }
+func (m *BlockHeader) String() string { return proto.CompactTextString(m) }
+func (*BlockHeader) ProtoMessage()    {}
+
+func (m *BlockHeader) GetTimestamp() *google_protobuf1.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+func (m *BlockHeader) GetSignatures() []*BlockSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// Headers of consecutive blocks of the blockchain.
+type BlockHeaders struct {
+	Headers []*BlockHeader `protobuf:"bytes,1,rep,name=headers" json:"headers,omitempty"`
+}
+
+func (m *BlockHeaders) Reset()         { *m = BlockHeaders{} }
+func (m *BlockHeaders) String() string { return proto.CompactTextString(m) }
+func (*BlockHeaders) ProtoMessage()    {}
+
+func (m *BlockHeaders) GetHeaders() []*BlockHeader {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+// Specifies the UUID of the transaction whose inclusion is to be proven.
+type TransactionProofRequest struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+}
+
+func (m *TransactionProofRequest) Reset()         { *m = TransactionProofRequest{} }
+func (m *TransactionProofRequest) String() string { return proto.CompactTextString(m) }
+func (*TransactionProofRequest) ProtoMessage()    {}
+
+// Proof that a transaction is the transaction at index in the block with
+// the given header. The block is hashed as a whole, so the proof carries the
+// encoded block before and after the transaction: the hash of prefix, the
+// encoded transaction field and suffix is the hash of the block.
+type TransactionProof struct {
+	Transaction *Transaction `protobuf:"bytes,1,opt,name=transaction" json:"transaction,omitempty"`
+	Index       uint32       `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
+	Prefix      []byte       `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Suffix      []byte       `protobuf:"bytes,4,opt,name=suffix,proto3" json:"suffix,omitempty"`
+	Header      *BlockHeader `protobuf:"bytes,5,opt,name=header" json:"header,omitempty"`
+}
+
+func (m *TransactionProof) Reset()         { *m = TransactionProof{} }
+func (m *TransactionProof) String() string { return proto.CompactTextString(m) }
+func (*TransactionProof) ProtoMessage()    {}
+
+func (m *TransactionProof) GetTransaction() *Transaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
+
+func (m *TransactionProof) GetHeader() *BlockHeader {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
 var _ grpc.ClientConn
@@ -110,6 +270,17 @@ type OpenchainClient interface {
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BlockCount, error)
+	// GetStateProof returns the committed value of a key in the state of a
+	// chain hosted by the peer, along with its proof against the state hash
+	// of the block at the head of the chain.
+	GetStateProof(ctx context.Context, in *StateProofRequest, opts ...grpc.CallOption) (*StateProof, error)
+	// GetBlockHeaders returns the headers of a range of blocks of the
+	// blockchain along with the signatures of the validators that committed
+	// them, for light clients that do not download the transactions.
+	GetBlockHeaders(ctx context.Context, in *BlockHeadersRequest, opts ...grpc.CallOption) (*BlockHeaders, error)
+	// GetTransactionProof returns the proof that a transaction is committed
+	// in a block of the blockchain, checked against the header of the block.
+	GetTransactionProof(ctx context.Context, in *TransactionProofRequest, opts ...grpc.CallOption) (*TransactionProof, error)
 }
 
 type openchainClient struct {
@@ -147,6 +318,33 @@ func (c *openchainClient) GetBlockCount(ctx context.Context, in *google_protobuf
 	return out, nil
 }
 
+func (c *openchainClient) GetStateProof(ctx context.Context, in *StateProofRequest, opts ...grpc.CallOption) (*StateProof, error) {
+	out := new(StateProof)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetStateProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetBlockHeaders(ctx context.Context, in *BlockHeadersRequest, opts ...grpc.CallOption) (*BlockHeaders, error) {
+	out := new(BlockHeaders)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetBlockHeaders", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetTransactionProof(ctx context.Context, in *TransactionProofRequest, opts ...grpc.CallOption) (*TransactionProof, error) {
+	out := new(TransactionProof)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetTransactionProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Openchain service
 
 type OpenchainServer interface {
@@ -159,6 +357,17 @@ type OpenchainServer interface {
 	// GetBlockCount returns the current number of blocks in the blockchain data
 	// structure.
 	GetBlockCount(context.Context, *google_protobuf1.Empty) (*BlockCount, error)
+	// GetStateProof returns the committed value of a key in the state of a
+	// chain hosted by the peer, along with its proof against the state hash
+	// of the block at the head of the chain.
+	GetStateProof(context.Context, *StateProofRequest) (*StateProof, error)
+	// GetBlockHeaders returns the headers of a range of blocks of the
+	// blockchain along with the signatures of the validators that committed
+	// them, for light clients that do not download the transactions.
+	GetBlockHeaders(context.Context, *BlockHeadersRequest) (*BlockHeaders, error)
+	// GetTransactionProof returns the proof that a transaction is committed
+	// in a block of the blockchain, checked against the header of the block.
+	GetTransactionProof(context.Context, *TransactionProofRequest) (*TransactionProof, error)
 }
 
 func RegisterOpenchainServer(s *grpc.Server, srv OpenchainServer) {
@@ -201,6 +410,42 @@ func _Openchain_GetBlockCount_Handler(srv interface{}, ctx context.Context, dec
 	return out, nil
 }
 
+func _Openchain_GetStateProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(StateProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetStateProof(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetBlockHeaders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(BlockHeadersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetBlockHeaders(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetTransactionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(TransactionProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetTransactionProof(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Openchain_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Openchain",
 	HandlerType: (*OpenchainServer)(nil),
@@ -217,6 +462,18 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetBlockCount",
 			Handler:    _Openchain_GetBlockCount_Handler,
 		},
+		{
+			MethodName: "GetStateProof",
+			Handler:    _Openchain_GetStateProof_Handler,
+		},
+		{
+			MethodName: "GetBlockHeaders",
+			Handler:    _Openchain_GetBlockHeaders_Handler,
+		},
+		{
+			MethodName: "GetTransactionProof",
+			Handler:    _Openchain_GetTransactionProof_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
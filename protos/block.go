@@ -84,6 +84,82 @@ func (block *Block) GetHash() ([]byte, error) {
 	return hash, nil
 }
 
+// GetHeader returns the header of this block, the block at the given number
+// of the blockchain, without its transactions.
+func (block *Block) GetHeader(number uint64) (*BlockHeader, error) {
+	hash, err := block.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	return block.header(number, hash), nil
+}
+
+// GetHeaderDigest returns the digest of the header of this block, the block
+// at the given number of the blockchain with the given hash. This is what
+// the validators committing the block sign.
+func (block *Block) GetHeaderDigest(number uint64, hash []byte) ([]byte, error) {
+	return block.header(number, hash).Digest()
+}
+
+func (block *Block) header(number uint64, hash []byte) *BlockHeader {
+	return &BlockHeader{Number: number, Hash: hash, Version: block.Version, Timestamp: block.Timestamp,
+		StateHash: block.StateHash, PreviousBlockHash: block.PreviousBlockHash,
+		ConsensusMetadata: block.ConsensusMetadata, Signatures: block.GetNonHashData().GetSignatures()}
+}
+
+// Digest returns the hash of the header without its signatures. The block
+// hash commits to the transactions but can't be recomputed without them, so
+// validators sign the digest, which also covers the number, the state hash
+// and the other fields a light client relies on.
+func (header *BlockHeader) Digest() ([]byte, error) {
+	unsigned := *header
+	unsigned.Signatures = nil
+	data, err := proto.Marshal(&unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("Could not calculate digest of block header: %s", err)
+	}
+	return util.ComputeCryptoHash(data), nil
+}
+
+// GetTransactionProof returns the proof that the transaction at index is in
+// this block, the block at the given number of the blockchain. The block is
+// encoded as in GetHash, with the transaction field taken out.
+func (block *Block) GetTransactionProof(number uint64, index int) (*TransactionProof, error) {
+	if index < 0 || index >= len(block.Transactions) {
+		return nil, fmt.Errorf("Block %d has no transaction at index %d", number, index)
+	}
+	header, err := block.GetHeader(number)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := proto.Marshal(&Block{Version: block.Version, Timestamp: block.Timestamp,
+		Transactions: block.Transactions[:index]})
+	if err != nil {
+		return nil, fmt.Errorf("Could not build transaction proof: %s", err)
+	}
+	suffix, err := proto.Marshal(&Block{Transactions: block.Transactions[index+1:], StateHash: block.StateHash,
+		PreviousBlockHash: block.PreviousBlockHash, ConsensusMetadata: block.ConsensusMetadata})
+	if err != nil {
+		return nil, fmt.Errorf("Could not build transaction proof: %s", err)
+	}
+	return &TransactionProof{Transaction: block.Transactions[index], Index: uint32(index),
+		Prefix: prefix, Suffix: suffix, Header: header}, nil
+}
+
+// EncodeTransactionField returns a transaction encoded as a transaction field
+// of an encoded block, to be hashed along with the prefix and the suffix of
+// a transaction proof.
+func EncodeTransactionField(transaction *Transaction) ([]byte, error) {
+	data, err := proto.Marshal(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal transaction: %s", err)
+	}
+	buffer := proto.NewBuffer(nil)
+	buffer.EncodeVarint(3<<3 | proto.WireBytes)
+	buffer.EncodeRawBytes(data)
+	return buffer.Bytes(), nil
+}
+
 // GetStateHash returns the stateHash stored in this block. The stateHash
 // is the value returned by state.GetHash() after running all transactions in
 // the block.
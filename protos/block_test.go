@@ -91,3 +91,44 @@ func TestBlockNonHashData(t *testing.T) {
 		t.Fatalf("Expected time2 and block2 times to be equal, but there were not")
 	}
 }
+
+func TestBlockTransactionProof(t *testing.T) {
+	var transactions []*Transaction
+	for _, uuid := range []string{"tx0", "tx1", "tx2"} {
+		tx, err := NewTransaction(ChaincodeID{Path: "contract_001"}, uuid, "invoke", []string{"a", "b"})
+		if err != nil {
+			t.Fatalf("Error creating transaction: %s", err)
+		}
+		transactions = append(transactions, tx)
+	}
+	block := NewBlock(transactions, []byte("metadata"))
+	block.StateHash = []byte("stateHash")
+	block.PreviousBlockHash = []byte("previousBlockHash")
+	block.Timestamp = util.CreateUtcTimestamp()
+	hash, err := block.GetHash()
+	if err != nil {
+		t.Fatalf("Error hashing block: %s", err)
+	}
+
+	for i, tx := range transactions {
+		proof, err := block.GetTransactionProof(7, i)
+		if err != nil {
+			t.Fatalf("Error building proof of transaction %d: %s", i, err)
+		}
+		field, err := EncodeTransactionField(tx)
+		if err != nil {
+			t.Fatalf("Error encoding transaction %d: %s", i, err)
+		}
+		data := append(append(append([]byte{}, proof.Prefix...), field...), proof.Suffix...)
+		if !bytes.Equal(util.ComputeCryptoHash(data), hash) {
+			t.Fatalf("Proof of transaction %d does not hash to the hash of the block", i)
+		}
+		if proof.Header.Number != 7 || !bytes.Equal(proof.Header.Hash, hash) {
+			t.Fatalf("Unexpected header in proof of transaction %d: %v", i, proof.Header)
+		}
+	}
+
+	if _, err := block.GetTransactionProof(7, 3); err == nil {
+		t.Fatalf("Expected error building proof of a transaction not in the block")
+	}
+}
@@ -63,6 +63,52 @@ func (x ChaincodeSpec_Type) String() string {
 	return proto.EnumName(ChaincodeSpec_Type_name, int32(x))
 }
 
+// How the keys of the state of a confidential chaincode are stored.
+type ChaincodeSpec_KeyEncryption int32
+
+const (
+	// Keys are stored in the clear, only values are encrypted
+	ChaincodeSpec_PLAINTEXT ChaincodeSpec_KeyEncryption = 0
+	// Keys are encrypted deterministically so that they can still be looked
+	// up and range queried. Equal keys have equal ciphertexts.
+	// Range queries scan the whole state of the chaincode, and key hints
+	// cannot be declared as they would name the keys in the clear.
+	ChaincodeSpec_DETERMINISTIC ChaincodeSpec_KeyEncryption = 1
+)
+
+var ChaincodeSpec_KeyEncryption_name = map[int32]string{
+	0: "PLAINTEXT",
+	1: "DETERMINISTIC",
+}
+var ChaincodeSpec_KeyEncryption_value = map[string]int32{
+	"PLAINTEXT":     0,
+	"DETERMINISTIC": 1,
+}
+
+func (x ChaincodeSpec_KeyEncryption) String() string {
+	return proto.EnumName(ChaincodeSpec_KeyEncryption_name, int32(x))
+}
+
+type ChaincodeDeploymentSpec_ExecutionEnvironment int32
+
+const (
+	ChaincodeDeploymentSpec_DOCKER ChaincodeDeploymentSpec_ExecutionEnvironment = 0
+	ChaincodeDeploymentSpec_SYSTEM ChaincodeDeploymentSpec_ExecutionEnvironment = 1
+)
+
+var ChaincodeDeploymentSpec_ExecutionEnvironment_name = map[int32]string{
+	0: "DOCKER",
+	1: "SYSTEM",
+}
+var ChaincodeDeploymentSpec_ExecutionEnvironment_value = map[string]int32{
+	"DOCKER": 0,
+	"SYSTEM": 1,
+}
+
+func (x ChaincodeDeploymentSpec_ExecutionEnvironment) String() string {
+	return proto.EnumName(ChaincodeDeploymentSpec_ExecutionEnvironment_name, int32(x))
+}
+
 type ChaincodeMessage_Type int32
 
 const (
@@ -86,6 +132,28 @@ const (
 	ChaincodeMessage_RANGE_QUERY_STATE       ChaincodeMessage_Type = 17
 	ChaincodeMessage_RANGE_QUERY_STATE_NEXT  ChaincodeMessage_Type = 18
 	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE ChaincodeMessage_Type = 19
+	ChaincodeMessage_ENQUEUE_CHAINCODE       ChaincodeMessage_Type = 20
+	ChaincodeMessage_GET_CHAIN_STATE         ChaincodeMessage_Type = 21
+	ChaincodeMessage_SET_EVENT               ChaincodeMessage_Type = 22
+	// Sent by the peer when it stops waiting for the transaction or
+	// query with the uuid, because it was cancelled or timed out. The
+	// payload holds the reason.
+	ChaincodeMessage_TX_ABORT ChaincodeMessage_Type = 23
+	// Part of a value too large for a single PUT_STATE, with a
+	// PutStatePart payload. Only the last part gets a response.
+	ChaincodeMessage_PUT_STATE_PART ChaincodeMessage_Type = 24
+	// Asks for the DeploymentInfo of the chaincode
+	ChaincodeMessage_GET_DEPLOYMENT_INFO ChaincodeMessage_Type = 25
+	// Asks for the random seed of the transaction, derived from the
+	// transaction and the block it follows so that every validator gets
+	// the same one
+	ChaincodeMessage_GET_TX_RANDOM ChaincodeMessage_Type = 26
+	// Asks for the timestamp the submitter set in the transaction, with
+	// a google.protobuf.Timestamp response payload
+	ChaincodeMessage_GET_TX_TIME ChaincodeMessage_Type = 27
+	// Sent by the peer when the chaincode uses a message being phased
+	// out. The payload tells what to use instead.
+	ChaincodeMessage_DEPRECATION_WARNING ChaincodeMessage_Type = 28
 )
 
 var ChaincodeMessage_Type_name = map[int32]string{
@@ -109,6 +177,15 @@ var ChaincodeMessage_Type_name = map[int32]string{
 	17: "RANGE_QUERY_STATE",
 	18: "RANGE_QUERY_STATE_NEXT",
 	19: "RANGE_QUERY_STATE_CLOSE",
+	20: "ENQUEUE_CHAINCODE",
+	21: "GET_CHAIN_STATE",
+	22: "SET_EVENT",
+	23: "TX_ABORT",
+	24: "PUT_STATE_PART",
+	25: "GET_DEPLOYMENT_INFO",
+	26: "GET_TX_RANDOM",
+	27: "GET_TX_TIME",
+	28: "DEPRECATION_WARNING",
 }
 var ChaincodeMessage_Type_value = map[string]int32{
 	"UNDEFINED":               0,
@@ -131,6 +208,15 @@ var ChaincodeMessage_Type_value = map[string]int32{
 	"RANGE_QUERY_STATE":       17,
 	"RANGE_QUERY_STATE_NEXT":  18,
 	"RANGE_QUERY_STATE_CLOSE": 19,
+	"ENQUEUE_CHAINCODE":       20,
+	"GET_CHAIN_STATE":         21,
+	"SET_EVENT":               22,
+	"TX_ABORT":                23,
+	"PUT_STATE_PART":          24,
+	"GET_DEPLOYMENT_INFO":     25,
+	"GET_TX_RANDOM":           26,
+	"GET_TX_TIME":             27,
+	"DEPRECATION_WARNING":     28,
 }
 
 func (x ChaincodeMessage_Type) String() string {
@@ -169,13 +255,18 @@ func (*ChaincodeInput) ProtoMessage()    {}
 // Carries the chaincode specification. This is the actual metadata required for
 // defining a chaincode.
 type ChaincodeSpec struct {
-	Type                 ChaincodeSpec_Type   `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
-	ChaincodeID          *ChaincodeID         `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
-	CtorMsg              *ChaincodeInput      `protobuf:"bytes,3,opt,name=ctorMsg" json:"ctorMsg,omitempty"`
-	Timeout              int32                `protobuf:"varint,4,opt,name=timeout" json:"timeout,omitempty"`
-	SecureContext        string               `protobuf:"bytes,5,opt,name=secureContext" json:"secureContext,omitempty"`
-	ConfidentialityLevel ConfidentialityLevel `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
-	Metadata             []byte               `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Type                 ChaincodeSpec_Type          `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	ChaincodeID          *ChaincodeID                `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	CtorMsg              *ChaincodeInput             `protobuf:"bytes,3,opt,name=ctorMsg" json:"ctorMsg,omitempty"`
+	Timeout              int32                       `protobuf:"varint,4,opt,name=timeout" json:"timeout,omitempty"`
+	SecureContext        string                      `protobuf:"bytes,5,opt,name=secureContext" json:"secureContext,omitempty"`
+	ConfidentialityLevel ConfidentialityLevel        `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
+	Metadata             []byte                      `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	ChainID              string                      `protobuf:"bytes,8,opt,name=chainID" json:"chainID,omitempty"`
+	KeyEncryption        ChaincodeSpec_KeyEncryption `protobuf:"varint,9,opt,name=keyEncryption,enum=protos.ChaincodeSpec_KeyEncryption" json:"keyEncryption,omitempty"`
+	// Signatures every invocation of the chaincode must carry, set at deploy.
+	// Requires security.
+	SignaturePolicy *SignaturePolicy `protobuf:"bytes,10,opt,name=signaturePolicy" json:"signaturePolicy,omitempty"`
 }
 
 func (m *ChaincodeSpec) Reset()         { *m = ChaincodeSpec{} }
@@ -196,6 +287,25 @@ func (m *ChaincodeSpec) GetCtorMsg() *ChaincodeInput {
 	return nil
 }
 
+func (m *ChaincodeSpec) GetSignaturePolicy() *SignaturePolicy {
+	if m != nil {
+		return m.SignaturePolicy
+	}
+	return nil
+}
+
+// Requires invocations to be signed by a threshold of distinct signers,
+// the invoker and the co-signers of the transaction.
+type SignaturePolicy struct {
+	Threshold uint32 `protobuf:"varint,1,opt,name=threshold" json:"threshold,omitempty"`
+	// Enrollment IDs whose signatures count, empty to count any signer.
+	Signers []string `protobuf:"bytes,2,rep,name=signers" json:"signers,omitempty"`
+}
+
+func (m *SignaturePolicy) Reset()         { *m = SignaturePolicy{} }
+func (m *SignaturePolicy) String() string { return proto.CompactTextString(m) }
+func (*SignaturePolicy) ProtoMessage()    {}
+
 // Specify the deployment of a chaincode.
 // TODO: Define `codePackage`.
 type ChaincodeDeploymentSpec struct {
@@ -203,6 +313,14 @@ type ChaincodeDeploymentSpec struct {
 	// Controls when the chaincode becomes executable.
 	EffectiveDate *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=effectiveDate" json:"effectiveDate,omitempty"`
 	CodePackage   []byte                     `protobuf:"bytes,3,opt,name=codePackage,proto3" json:"codePackage,omitempty"`
+	// Marshaled ChaincodePackageHeader covered by packageSignatures.
+	PackageHeader     []byte                       `protobuf:"bytes,4,opt,name=packageHeader,proto3" json:"packageHeader,omitempty"`
+	PackageSignatures []*ChaincodePackageSignature `protobuf:"bytes,5,rep,name=packageSignatures" json:"packageSignatures,omitempty"`
+	// System chaincodes run in-process in the peer and have no code package.
+	ExecEnv ChaincodeDeploymentSpec_ExecutionEnvironment `protobuf:"varint,6,opt,name=execEnv,enum=protos.ChaincodeDeploymentSpec_ExecutionEnvironment" json:"execEnv,omitempty"`
+	// Findings of the static analysis of the code, set on the spec returned
+	// by Deploy. They are not part of the deploy transaction.
+	AnalysisFindings []*CodeFinding `protobuf:"bytes,7,rep,name=analysisFindings" json:"analysisFindings,omitempty"`
 }
 
 func (m *ChaincodeDeploymentSpec) Reset()         { *m = ChaincodeDeploymentSpec{} }
@@ -223,9 +341,82 @@ func (m *ChaincodeDeploymentSpec) GetEffectiveDate() *google_protobuf.Timestamp
 	return nil
 }
 
+func (m *ChaincodeDeploymentSpec) GetPackageSignatures() []*ChaincodePackageSignature {
+	if m != nil {
+		return m.PackageSignatures
+	}
+	return nil
+}
+
+func (m *ChaincodeDeploymentSpec) GetAnalysisFindings() []*CodeFinding {
+	if m != nil {
+		return m.AnalysisFindings
+	}
+	return nil
+}
+
+// Describes a chaincode deployment package. The marshaled header is what the
+// owners of the package sign.
+type ChaincodePackageHeader struct {
+	Type ChaincodeSpec_Type `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	Path string             `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+	Name string             `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	// Crypto hash of the codePackage of the deployment spec.
+	CodePackageHash []byte `protobuf:"bytes,4,opt,name=codePackageHash,proto3" json:"codePackageHash,omitempty"`
+	Metadata        []byte `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (m *ChaincodePackageHeader) Reset()         { *m = ChaincodePackageHeader{} }
+func (m *ChaincodePackageHeader) String() string { return proto.CompactTextString(m) }
+func (*ChaincodePackageHeader) ProtoMessage()    {}
+
+// Signature of an owner over a ChaincodePackageHeader.
+type ChaincodePackageSignature struct {
+	// DER encoded certificate of the signer.
+	Certificate []byte `protobuf:"bytes,1,opt,name=certificate,proto3" json:"certificate,omitempty"`
+	Signature   []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *ChaincodePackageSignature) Reset()         { *m = ChaincodePackageSignature{} }
+func (m *ChaincodePackageSignature) String() string { return proto.CompactTextString(m) }
+func (*ChaincodePackageSignature) ProtoMessage()    {}
+
+// Non-deterministic construct found in the code of a chaincode when it is
+// deployed.
+type CodeFinding struct {
+	// Rule of the analysis that found the construct.
+	Rule string `protobuf:"bytes,1,opt,name=rule" json:"rule,omitempty"`
+	// File, relative to the chaincode path, and line of the construct.
+	File    string `protobuf:"bytes,2,opt,name=file" json:"file,omitempty"`
+	Line    int32  `protobuf:"varint,3,opt,name=line" json:"line,omitempty"`
+	Message string `protobuf:"bytes,4,opt,name=message" json:"message,omitempty"`
+	// Whether the finding blocks the deployment.
+	Blocking bool `protobuf:"varint,5,opt,name=blocking" json:"blocking,omitempty"`
+}
+
+func (m *CodeFinding) Reset()         { *m = CodeFinding{} }
+func (m *CodeFinding) String() string { return proto.CompactTextString(m) }
+func (*CodeFinding) ProtoMessage()    {}
+
 // Carries the chaincode function and its arguments.
 type ChaincodeInvocationSpec struct {
 	ChaincodeSpec *ChaincodeSpec `protobuf:"bytes,1,opt,name=chaincodeSpec" json:"chaincodeSpec,omitempty"`
+	// Optional client supplied key; invocations repeating a key are not
+	// executed again, the UUID of the original transaction is returned instead
+	IdempotencyKey string `protobuf:"bytes,3,opt,name=idempotencyKey" json:"idempotencyKey,omitempty"`
+	// Optional keys of the chaincode state the invocation reads or writes.
+	// They are locked before the invocation runs, and validators execute
+	// invocations declaring disjoint keys concurrently. With strict key hints
+	// enabled, accessing any other key, including keys of other chaincodes
+	// not declared in calleeKeyHints and range queries, fails the invocation.
+	KeyHints []string `protobuf:"bytes,4,rep,name=keyHints" json:"keyHints,omitempty"`
+	// Optional priority of the transaction, bounded by the priority policy
+	// of the peers. Operationally critical invocations use a priority above
+	// 0 so they are not queued behind bulk workload.
+	Priority uint32 `protobuf:"varint,5,opt,name=priority" json:"priority,omitempty"`
+	// Optional keys of the state of the chaincodes the invocation invokes,
+	// declared like keyHints.
+	CalleeKeyHints []*ChaincodeKeyHints `protobuf:"bytes,6,rep,name=calleeKeyHints" json:"calleeKeyHints,omitempty"`
 }
 
 func (m *ChaincodeInvocationSpec) Reset()         { *m = ChaincodeInvocationSpec{} }
@@ -239,6 +430,23 @@ func (m *ChaincodeInvocationSpec) GetChaincodeSpec() *ChaincodeSpec {
 	return nil
 }
 
+func (m *ChaincodeInvocationSpec) GetCalleeKeyHints() []*ChaincodeKeyHints {
+	if m != nil {
+		return m.CalleeKeyHints
+	}
+	return nil
+}
+
+// ChaincodeKeyHints declares keys of the state of a chaincode.
+type ChaincodeKeyHints struct {
+	ChaincodeName string   `protobuf:"bytes,1,opt,name=chaincodeName" json:"chaincodeName,omitempty"`
+	Keys          []string `protobuf:"bytes,2,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *ChaincodeKeyHints) Reset()         { *m = ChaincodeKeyHints{} }
+func (m *ChaincodeKeyHints) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeKeyHints) ProtoMessage()    {}
+
 // TODO: Merge this with ChaincodeID.
 type ChaincodeIdentifier struct {
 	// URL for accessing the Chaincode, eg. https://github.com/user/SampleContract
@@ -296,6 +504,15 @@ type ChaincodeMessage struct {
 	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
 	Payload   []byte                     `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	Uuid      string                     `protobuf:"bytes,4,opt,name=uuid" json:"uuid,omitempty"`
+	// Set by the peer on INIT, TRANSACTION and QUERY messages
+	SecurityContext *ChaincodeSecurityContext `protobuf:"bytes,5,opt,name=securityContext" json:"securityContext,omitempty"`
+	// Set by the shim on REGISTER to the highest version of the protocol it
+	// speaks, and by the peer on REGISTERED to the version selected
+	ProtocolVersion uint32 `protobuf:"varint,6,opt,name=protocolVersion" json:"protocolVersion,omitempty"`
+	// Set by the peer on the TRANSACTION messages of a determinism check to
+	// the number of the run, starting at 1. The shim varies the scheduling
+	// and the math/rand seed of the chaincode from one run to the next.
+	DeterminismRun uint32 `protobuf:"varint,7,opt,name=determinismRun" json:"determinismRun,omitempty"`
 }
 
 func (m *ChaincodeMessage) Reset()         { *m = ChaincodeMessage{} }
@@ -309,6 +526,25 @@ func (m *ChaincodeMessage) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *ChaincodeMessage) GetSecurityContext() *ChaincodeSecurityContext {
+	if m != nil {
+		return m.SecurityContext
+	}
+	return nil
+}
+
+// ChaincodeSecurityContext carries the identity of the invoker of a
+// chaincode: its transaction certificate and the key disclosing the
+// attributes embedded in it.
+type ChaincodeSecurityContext struct {
+	CallerCert    []byte `protobuf:"bytes,1,opt,name=callerCert,proto3" json:"callerCert,omitempty"`
+	AttributesKey []byte `protobuf:"bytes,2,opt,name=attributesKey,proto3" json:"attributesKey,omitempty"`
+}
+
+func (m *ChaincodeSecurityContext) Reset()         { *m = ChaincodeSecurityContext{} }
+func (m *ChaincodeSecurityContext) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeSecurityContext) ProtoMessage()    {}
+
 type PutStateInfo struct {
 	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
 	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
@@ -318,9 +554,53 @@ func (m *PutStateInfo) Reset()         { *m = PutStateInfo{} }
 func (m *PutStateInfo) String() string { return proto.CompactTextString(m) }
 func (*PutStateInfo) ProtoMessage()    {}
 
+// Part of a value put in order in several PUT_STATE_PART messages. The value
+// is written once the last part is received.
+type PutStatePart struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3" json:"chunk,omitempty"`
+	// Size of the whole value, set on the first part
+	TotalSize uint64 `protobuf:"varint,3,opt,name=totalSize" json:"totalSize,omitempty"`
+	Last      bool   `protobuf:"varint,4,opt,name=last" json:"last,omitempty"`
+}
+
+func (m *PutStatePart) Reset()         { *m = PutStatePart{} }
+func (m *PutStatePart) String() string { return proto.CompactTextString(m) }
+func (*PutStatePart) ProtoMessage()    {}
+
+// How a chaincode was deployed, as returned to the chaincode itself on
+// GET_DEPLOYMENT_INFO
+type DeploymentInfo struct {
+	ChaincodeID *ChaincodeID `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	// Hex encoded hash of the code package, identifies the code version
+	CodeHash string `protobuf:"bytes,2,opt,name=codeHash" json:"codeHash,omitempty"`
+	// UUID of the deploy transaction
+	DeployUuid string                                       `protobuf:"bytes,3,opt,name=deployUuid" json:"deployUuid,omitempty"`
+	Type       ChaincodeSpec_Type                           `protobuf:"varint,4,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	ExecEnv    ChaincodeDeploymentSpec_ExecutionEnvironment `protobuf:"varint,5,opt,name=execEnv,enum=protos.ChaincodeDeploymentSpec_ExecutionEnvironment" json:"execEnv,omitempty"`
+	ChainID    string                                       `protobuf:"bytes,6,opt,name=chainID" json:"chainID,omitempty"`
+	// Metadata of the deployed ChaincodeSpec
+	Metadata []byte `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+}
+
+func (m *DeploymentInfo) Reset()         { *m = DeploymentInfo{} }
+func (m *DeploymentInfo) String() string { return proto.CompactTextString(m) }
+func (*DeploymentInfo) ProtoMessage()    {}
+
+func (m *DeploymentInfo) GetChaincodeID() *ChaincodeID {
+	if m != nil {
+		return m.ChaincodeID
+	}
+	return nil
+}
+
 type RangeQueryState struct {
 	StartKey string `protobuf:"bytes,1,opt,name=startKey" json:"startKey,omitempty"`
 	EndKey   string `protobuf:"bytes,2,opt,name=endKey" json:"endKey,omitempty"`
+	// limit is the most keys the query returns, 0 for no limit
+	Limit uint32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	// keysOnly returns the keys without their values
+	KeysOnly bool `protobuf:"varint,4,opt,name=keysOnly" json:"keysOnly,omitempty"`
 }
 
 func (m *RangeQueryState) Reset()         { *m = RangeQueryState{} }
@@ -369,9 +649,25 @@ func (m *RangeQueryStateResponse) GetKeysAndValues() []*RangeQueryStateKeyValue
 	return nil
 }
 
+// ChaincodeEvent is set by a chaincode during a transaction and sent once
+// the block of the transaction commits
+// string type - "chaincode"
+type ChaincodeEvent struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	TxUuid      string `protobuf:"bytes,2,opt,name=txUuid" json:"txUuid,omitempty"`
+	EventName   string `protobuf:"bytes,3,opt,name=eventName" json:"eventName,omitempty"`
+	Payload     []byte `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *ChaincodeEvent) Reset()         { *m = ChaincodeEvent{} }
+func (m *ChaincodeEvent) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeEvent) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ConfidentialityLevel", ConfidentialityLevel_name, ConfidentialityLevel_value)
 	proto.RegisterEnum("protos.ChaincodeSpec_Type", ChaincodeSpec_Type_name, ChaincodeSpec_Type_value)
+	proto.RegisterEnum("protos.ChaincodeSpec_KeyEncryption", ChaincodeSpec_KeyEncryption_name, ChaincodeSpec_KeyEncryption_value)
+	proto.RegisterEnum("protos.ChaincodeDeploymentSpec_ExecutionEnvironment", ChaincodeDeploymentSpec_ExecutionEnvironment_name, ChaincodeDeploymentSpec_ExecutionEnvironment_value)
 	proto.RegisterEnum("protos.ChaincodeMessage_Type", ChaincodeMessage_Type_name, ChaincodeMessage_Type_value)
 }
 
@@ -0,0 +1,64 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package protos
+
+// ChaincodeProtocolVersion is the highest version of the protocol between
+// peers and chaincodes spoken by this code. Shims and peers that predate
+// versioning do not set the version and speak version 0.
+const ChaincodeProtocolVersion uint32 = 2
+
+// chaincodeMessageVersions are the versions of the protocol that introduced
+// message types, those not listed are part of version 0
+var chaincodeMessageVersions = map[ChaincodeMessage_Type]uint32{
+	ChaincodeMessage_TX_ABORT:            1,
+	ChaincodeMessage_PUT_STATE_PART:      1,
+	ChaincodeMessage_GET_DEPLOYMENT_INFO: 1,
+	ChaincodeMessage_GET_TX_RANDOM:       1,
+	ChaincodeMessage_GET_TX_TIME:         1,
+	ChaincodeMessage_DEPRECATION_WARNING: 2,
+}
+
+// chaincodeMessageDeprecations are the message types being phased out, with
+// what chaincodes should use instead. The peer still handles them, warning
+// the chaincodes using them, until they are removed from the protocol.
+var chaincodeMessageDeprecations = map[ChaincodeMessage_Type]string{}
+
+// ProtocolVersion returns the version of the chaincode protocol that
+// introduced the message type. It may only be sent to the other end if the
+// version negotiated at REGISTER is at least this one.
+func (x ChaincodeMessage_Type) ProtocolVersion() uint32 {
+	return chaincodeMessageVersions[x]
+}
+
+// Deprecation returns what to use instead of messages of the type if they
+// are being phased out, and "" otherwise
+func (x ChaincodeMessage_Type) Deprecation() string {
+	return chaincodeMessageDeprecations[x]
+}
+
+// NegotiateChaincodeProtocolVersion returns the highest version of the
+// chaincode protocol spoken both by this code and by the other end, which
+// speaks up to version remote
+func NegotiateChaincodeProtocolVersion(remote uint32) uint32 {
+	if remote < ChaincodeProtocolVersion {
+		return remote
+	}
+	return ChaincodeProtocolVersion
+}
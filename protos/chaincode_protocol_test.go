@@ -0,0 +1,50 @@
+/*
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements.  See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership.  The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License.  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied.  See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package protos
+
+import (
+	"testing"
+)
+
+func TestNegotiateChaincodeProtocolVersion(t *testing.T) {
+	if v := NegotiateChaincodeProtocolVersion(0); v != 0 {
+		t.Fatalf("Expected version 0 with an unversioned end, got %d", v)
+	}
+	if v := NegotiateChaincodeProtocolVersion(ChaincodeProtocolVersion + 1); v != ChaincodeProtocolVersion {
+		t.Fatalf("Expected version %d with a newer end, got %d", ChaincodeProtocolVersion, v)
+	}
+	if v := ChaincodeMessage_GET_STATE.ProtocolVersion(); v != 0 {
+		t.Fatalf("Expected GET_STATE to be part of version 0, got %d", v)
+	}
+	if v := ChaincodeMessage_PUT_STATE_PART.ProtocolVersion(); v != 1 {
+		t.Fatalf("Expected PUT_STATE_PART to be part of version 1, got %d", v)
+	}
+}
+
+func TestChaincodeMessageDeprecation(t *testing.T) {
+	if d := ChaincodeMessage_GET_STATE.Deprecation(); d != "" {
+		t.Fatalf("Expected GET_STATE not to be deprecated, got %s", d)
+	}
+	chaincodeMessageDeprecations[ChaincodeMessage_GET_STATE] = "use GET_STATE_V2"
+	defer delete(chaincodeMessageDeprecations, ChaincodeMessage_GET_STATE)
+	if d := ChaincodeMessage_GET_STATE.Deprecation(); d != "use GET_STATE_V2" {
+		t.Fatalf("Expected GET_STATE to be deprecated, got %s", d)
+	}
+}
@@ -7,6 +7,7 @@ package protos
 import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
+import google_protobuf "google/protobuf"
 
 import (
 	context "golang.org/x/net/context"
@@ -41,6 +42,93 @@ func (x BuildResult_StatusCode) String() string {
 	return proto.EnumName(BuildResult_StatusCode_name, int32(x))
 }
 
+type BatchInvocationStatus_Status int32
+
+const (
+	BatchInvocationStatus_SUBMITTED BatchInvocationStatus_Status = 0
+	BatchInvocationStatus_COMMITTED BatchInvocationStatus_Status = 1
+	BatchInvocationStatus_FAILED    BatchInvocationStatus_Status = 2
+	BatchInvocationStatus_SKIPPED   BatchInvocationStatus_Status = 3
+)
+
+var BatchInvocationStatus_Status_name = map[int32]string{
+	0: "SUBMITTED",
+	1: "COMMITTED",
+	2: "FAILED",
+	3: "SKIPPED",
+}
+var BatchInvocationStatus_Status_value = map[string]int32{
+	"SUBMITTED": 0,
+	"COMMITTED": 1,
+	"FAILED":    2,
+	"SKIPPED":   3,
+}
+
+func (x BatchInvocationStatus_Status) String() string {
+	return proto.EnumName(BatchInvocationStatus_Status_name, int32(x))
+}
+
+type DeploymentStatus_Phase int32
+
+const (
+	DeploymentStatus_UNKNOWN      DeploymentStatus_Phase = 0
+	DeploymentStatus_SUBMITTED    DeploymentStatus_Phase = 1
+	DeploymentStatus_BUILDING     DeploymentStatus_Phase = 2
+	DeploymentStatus_LAUNCHING    DeploymentStatus_Phase = 3
+	DeploymentStatus_INIT_RUNNING DeploymentStatus_Phase = 4
+	DeploymentStatus_READY        DeploymentStatus_Phase = 5
+	DeploymentStatus_FAILED       DeploymentStatus_Phase = 6
+)
+
+var DeploymentStatus_Phase_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "SUBMITTED",
+	2: "BUILDING",
+	3: "LAUNCHING",
+	4: "INIT_RUNNING",
+	5: "READY",
+	6: "FAILED",
+}
+var DeploymentStatus_Phase_value = map[string]int32{
+	"UNKNOWN":      0,
+	"SUBMITTED":    1,
+	"BUILDING":     2,
+	"LAUNCHING":    3,
+	"INIT_RUNNING": 4,
+	"READY":        5,
+	"FAILED":       6,
+}
+
+func (x DeploymentStatus_Phase) String() string {
+	return proto.EnumName(DeploymentStatus_Phase_name, int32(x))
+}
+
+type DeadLetter_Phase int32
+
+const (
+	DeadLetter_UNKNOWN    DeadLetter_Phase = 0
+	DeadLetter_SIMULATION DeadLetter_Phase = 1
+	DeadLetter_SUBMISSION DeadLetter_Phase = 2
+	DeadLetter_EXECUTION  DeadLetter_Phase = 3
+)
+
+var DeadLetter_Phase_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "SIMULATION",
+	2: "SUBMISSION",
+	3: "EXECUTION",
+}
+var DeadLetter_Phase_value = map[string]int32{
+	"UNKNOWN":    0,
+	"SIMULATION": 1,
+	"SUBMISSION": 2,
+	"EXECUTION":  3,
+}
+
+func (x DeadLetter_Phase) String() string {
+	return proto.EnumName(DeadLetter_Phase_name, int32(x))
+}
+
 // Secret is a temporary object to establish security with the Devops.
 // A better solution using certificate will be introduced later
 type Secret struct {
@@ -69,8 +157,268 @@ func (m *BuildResult) GetDeploymentSpec() *ChaincodeDeploymentSpec {
 	return nil
 }
 
+// DeploymentStatus reports the progress of a chaincode deployment
+type DeploymentStatus struct {
+	Name  string                 `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Phase DeploymentStatus_Phase `protobuf:"varint,2,opt,name=phase,enum=protos.DeploymentStatus_Phase" json:"phase,omitempty"`
+	// Details of a failure, including the image build output if available
+	Msg       string                     `protobuf:"bytes,3,opt,name=msg" json:"msg,omitempty"`
+	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Results of the pre-launch hooks run on the chaincode image
+	Checks []*PreLaunchCheck `protobuf:"bytes,5,rep,name=checks" json:"checks,omitempty"`
+}
+
+func (m *DeploymentStatus) Reset()         { *m = DeploymentStatus{} }
+func (m *DeploymentStatus) String() string { return proto.CompactTextString(m) }
+func (*DeploymentStatus) ProtoMessage()    {}
+
+func (m *DeploymentStatus) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+func (m *DeploymentStatus) GetChecks() []*PreLaunchCheck {
+	if m != nil {
+		return m.Checks
+	}
+	return nil
+}
+
+// PreLaunchCheck is the result of a hook inspecting the image of a chaincode
+// after it is built. A failed check blocks the deployment.
+type PreLaunchCheck struct {
+	Hook    string `protobuf:"bytes,1,opt,name=hook" json:"hook,omitempty"`
+	Passed  bool   `protobuf:"varint,2,opt,name=passed" json:"passed,omitempty"`
+	Summary string `protobuf:"bytes,3,opt,name=summary" json:"summary,omitempty"`
+}
+
+func (m *PreLaunchCheck) Reset()         { *m = PreLaunchCheck{} }
+func (m *PreLaunchCheck) String() string { return proto.CompactTextString(m) }
+func (*PreLaunchCheck) ProtoMessage()    {}
+
+// ChaincodeInfo describes a chaincode known to a peer, either deployed on
+// the ledger, built into the peer or connected to it
+type ChaincodeInfo struct {
+	ChaincodeID *ChaincodeID                                 `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Type        ChaincodeSpec_Type                           `protobuf:"varint,2,opt,name=type,enum=protos.ChaincodeSpec_Type" json:"type,omitempty"`
+	ExecEnv     ChaincodeDeploymentSpec_ExecutionEnvironment `protobuf:"varint,3,opt,name=execEnv,enum=protos.ChaincodeDeploymentSpec_ExecutionEnvironment" json:"execEnv,omitempty"`
+	// Block holding the deploy transaction, whose UUID is the chaincode name
+	DeployBlock uint64 `protobuf:"varint,4,opt,name=deployBlock" json:"deployBlock,omitempty"`
+	Deployed    bool   `protobuf:"varint,5,opt,name=deployed" json:"deployed,omitempty"`
+	// Hex encoded hash of the code package, identifies the code version
+	CodeHash        string                 `protobuf:"bytes,6,opt,name=codeHash" json:"codeHash,omitempty"`
+	DeploymentPhase DeploymentStatus_Phase `protobuf:"varint,7,opt,name=deploymentPhase,enum=protos.DeploymentStatus_Phase" json:"deploymentPhase,omitempty"`
+	// State of the container running the chaincode, empty if there is none
+	ContainerStatus string `protobuf:"bytes,8,opt,name=containerStatus" json:"containerStatus,omitempty"`
+	// State of the peer's handler of the chaincode stream, empty if the
+	// chaincode is not connected
+	HandlerState string `protobuf:"bytes,9,opt,name=handlerState" json:"handlerState,omitempty"`
+}
+
+func (m *ChaincodeInfo) Reset()         { *m = ChaincodeInfo{} }
+func (m *ChaincodeInfo) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInfo) ProtoMessage()    {}
+
+func (m *ChaincodeInfo) GetChaincodeID() *ChaincodeID {
+	if m != nil {
+		return m.ChaincodeID
+	}
+	return nil
+}
+
+type ChaincodeInfoList struct {
+	Chaincodes []*ChaincodeInfo `protobuf:"bytes,1,rep,name=chaincodes" json:"chaincodes,omitempty"`
+}
+
+func (m *ChaincodeInfoList) Reset()         { *m = ChaincodeInfoList{} }
+func (m *ChaincodeInfoList) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInfoList) ProtoMessage()    {}
+
+func (m *ChaincodeInfoList) GetChaincodes() []*ChaincodeInfo {
+	if m != nil {
+		return m.Chaincodes
+	}
+	return nil
+}
+
+// DeterminismCheckSpec asks for an invocation to be executed several times on
+// the same state to find out whether the chaincode is deterministic
+type DeterminismCheckSpec struct {
+	Invocation *ChaincodeInvocationSpec `protobuf:"bytes,1,opt,name=invocation" json:"invocation,omitempty"`
+	// Number of executions, at least 2
+	Runs int32 `protobuf:"varint,2,opt,name=runs" json:"runs,omitempty"`
+}
+
+func (m *DeterminismCheckSpec) Reset()         { *m = DeterminismCheckSpec{} }
+func (m *DeterminismCheckSpec) String() string { return proto.CompactTextString(m) }
+func (*DeterminismCheckSpec) ProtoMessage()    {}
+
+func (m *DeterminismCheckSpec) GetInvocation() *ChaincodeInvocationSpec {
+	if m != nil {
+		return m.Invocation
+	}
+	return nil
+}
+
+// DeterminismReport compares the executions of a DeterminismCheckSpec
+type DeterminismReport struct {
+	Deterministic bool `protobuf:"varint,1,opt,name=deterministic" json:"deterministic,omitempty"`
+	// Hex encoded hash of the state changes of each successful execution
+	StateDeltaHashes []string `protobuf:"bytes,2,rep,name=stateDeltaHashes" json:"stateDeltaHashes,omitempty"`
+	// State keys, as chaincodeID/key, changed differently by the executions
+	DivergentKeys []string `protobuf:"bytes,3,rep,name=divergentKeys" json:"divergentKeys,omitempty"`
+	// Whether the executions returned different results
+	DivergentResults bool `protobuf:"varint,4,opt,name=divergentResults" json:"divergentResults,omitempty"`
+	// Errors of the failed executions
+	Errors []string `protobuf:"bytes,5,rep,name=errors" json:"errors,omitempty"`
+}
+
+func (m *DeterminismReport) Reset()         { *m = DeterminismReport{} }
+func (m *DeterminismReport) String() string { return proto.CompactTextString(m) }
+func (*DeterminismReport) ProtoMessage()    {}
+
+// BatchInvocationSpec is an ordered list of invocations submitted as a group
+type BatchInvocationSpec struct {
+	Invocations []*ChaincodeInvocationSpec `protobuf:"bytes,1,rep,name=invocations" json:"invocations,omitempty"`
+	// Skip the invocations following the first one that fails
+	StopOnFailure bool `protobuf:"varint,2,opt,name=stopOnFailure" json:"stopOnFailure,omitempty"`
+	// Seconds to wait for the submitted invocations to be committed, the
+	// progress is not followed after the submission if 0
+	CommitTimeout int32 `protobuf:"varint,3,opt,name=commitTimeout" json:"commitTimeout,omitempty"`
+}
+
+func (m *BatchInvocationSpec) Reset()         { *m = BatchInvocationSpec{} }
+func (m *BatchInvocationSpec) String() string { return proto.CompactTextString(m) }
+func (*BatchInvocationSpec) ProtoMessage()    {}
+
+func (m *BatchInvocationSpec) GetInvocations() []*ChaincodeInvocationSpec {
+	if m != nil {
+		return m.Invocations
+	}
+	return nil
+}
+
+// BatchInvocationStatus is the progress of an invocation of a batch
+type BatchInvocationStatus struct {
+	// Position of the invocation in the batch
+	Index  int32                        `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Uuid   string                       `protobuf:"bytes,2,opt,name=uuid" json:"uuid,omitempty"`
+	Status BatchInvocationStatus_Status `protobuf:"varint,3,opt,name=status,enum=protos.BatchInvocationStatus_Status" json:"status,omitempty"`
+	// Why the invocation failed
+	Msg string `protobuf:"bytes,4,opt,name=msg" json:"msg,omitempty"`
+}
+
+func (m *BatchInvocationStatus) Reset()         { *m = BatchInvocationStatus{} }
+func (m *BatchInvocationStatus) String() string { return proto.CompactTextString(m) }
+func (*BatchInvocationStatus) ProtoMessage()    {}
+
+// BatchInvocationProgress is the progress of all the invocations of a batch
+type BatchInvocationProgress struct {
+	Invocations []*BatchInvocationStatus `protobuf:"bytes,1,rep,name=invocations" json:"invocations,omitempty"`
+	// Number of invocations with each status
+	Submitted int32 `protobuf:"varint,2,opt,name=submitted" json:"submitted,omitempty"`
+	Committed int32 `protobuf:"varint,3,opt,name=committed" json:"committed,omitempty"`
+	Failed    int32 `protobuf:"varint,4,opt,name=failed" json:"failed,omitempty"`
+	Skipped   int32 `protobuf:"varint,5,opt,name=skipped" json:"skipped,omitempty"`
+	// Whether this is the last progress of the batch
+	Done bool `protobuf:"varint,6,opt,name=done" json:"done,omitempty"`
+}
+
+func (m *BatchInvocationProgress) Reset()         { *m = BatchInvocationProgress{} }
+func (m *BatchInvocationProgress) String() string { return proto.CompactTextString(m) }
+func (*BatchInvocationProgress) ProtoMessage()    {}
+
+func (m *BatchInvocationProgress) GetInvocations() []*BatchInvocationStatus {
+	if m != nil {
+		return m.Invocations
+	}
+	return nil
+}
+
+// NetworkConfigUpdate changes a network parameter, eg. batchsize, on all
+// peers once the blockchain reaches effectiveHeight
+type NetworkConfigUpdate struct {
+	Parameter       string `protobuf:"bytes,1,opt,name=parameter" json:"parameter,omitempty"`
+	Value           string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	EffectiveHeight uint64 `protobuf:"varint,3,opt,name=effectiveHeight" json:"effectiveHeight,omitempty"`
+}
+
+func (m *NetworkConfigUpdate) Reset()         { *m = NetworkConfigUpdate{} }
+func (m *NetworkConfigUpdate) String() string { return proto.CompactTextString(m) }
+func (*NetworkConfigUpdate) ProtoMessage()    {}
+
+// DeadLetter is a transaction that failed, kept by the peer that saw it fail
+// until it is resubmitted or purged
+type DeadLetter struct {
+	Transaction *Transaction               `protobuf:"bytes,1,opt,name=transaction" json:"transaction,omitempty"`
+	Phase       DeadLetter_Phase           `protobuf:"varint,2,opt,name=phase,enum=protos.DeadLetter_Phase" json:"phase,omitempty"`
+	Error       string                     `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	Timestamp   *google_protobuf.Timestamp `protobuf:"bytes,4,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Number of the block holding the transaction, for failed executions on
+	// the default chain
+	BlockNumber uint64 `protobuf:"varint,5,opt,name=blockNumber" json:"blockNumber,omitempty"`
+}
+
+func (m *DeadLetter) Reset()         { *m = DeadLetter{} }
+func (m *DeadLetter) String() string { return proto.CompactTextString(m) }
+func (*DeadLetter) ProtoMessage()    {}
+
+func (m *DeadLetter) GetTransaction() *Transaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
+
+func (m *DeadLetter) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
+type DeadLetterList struct {
+	DeadLetters []*DeadLetter `protobuf:"bytes,1,rep,name=deadLetters" json:"deadLetters,omitempty"`
+}
+
+func (m *DeadLetterList) Reset()         { *m = DeadLetterList{} }
+func (m *DeadLetterList) String() string { return proto.CompactTextString(m) }
+func (*DeadLetterList) ProtoMessage()    {}
+
+func (m *DeadLetterList) GetDeadLetters() []*DeadLetter {
+	if m != nil {
+		return m.DeadLetters
+	}
+	return nil
+}
+
+// DeadLetterID identifies a dead letter by the UUID of its transaction
+type DeadLetterID struct {
+	Uuid string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+}
+
+func (m *DeadLetterID) Reset()         { *m = DeadLetterID{} }
+func (m *DeadLetterID) String() string { return proto.CompactTextString(m) }
+func (*DeadLetterID) ProtoMessage()    {}
+
+// DeadLetterPurge deletes the dead letters of the listed UUIDs, or all of
+// them if all is set
+type DeadLetterPurge struct {
+	Uuids []string `protobuf:"bytes,1,rep,name=uuids" json:"uuids,omitempty"`
+	All   bool     `protobuf:"varint,2,opt,name=all" json:"all,omitempty"`
+}
+
+func (m *DeadLetterPurge) Reset()         { *m = DeadLetterPurge{} }
+func (m *DeadLetterPurge) String() string { return proto.CompactTextString(m) }
+func (*DeadLetterPurge) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.BuildResult_StatusCode", BuildResult_StatusCode_name, BuildResult_StatusCode_value)
+	proto.RegisterEnum("protos.DeploymentStatus_Phase", DeploymentStatus_Phase_name, DeploymentStatus_Phase_value)
+	proto.RegisterEnum("protos.BatchInvocationStatus_Status", BatchInvocationStatus_Status_name, BatchInvocationStatus_Status_value)
+	proto.RegisterEnum("protos.DeadLetter_Phase", DeadLetter_Phase_name, DeadLetter_Phase_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -91,6 +439,34 @@ type DevopsClient interface {
 	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
 	// Invoke chaincode.
 	Query(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Get the progress of a deployment submitted with Deploy.
+	GetDeploymentStatus(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (*DeploymentStatus, error)
+	// Stream the progress of a deployment until it is ready or has failed.
+	WatchDeployment(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (Devops_WatchDeploymentClient, error)
+	// List the chaincodes known to the peer and what is running of them.
+	ListChaincodes(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*ChaincodeInfoList, error)
+	// Execute an invocation several times on the same state without keeping
+	// its state changes, and report whether the executions diverged.
+	CheckDeterminism(ctx context.Context, in *DeterminismCheckSpec, opts ...grpc.CallOption) (*DeterminismReport, error)
+	// Submit an ordered list of invocations as a group and stream their
+	// progress until they are committed.
+	InvokeBatch(ctx context.Context, in *BatchInvocationSpec, opts ...grpc.CallOption) (Devops_InvokeBatchClient, error)
+	// Submit a configuration transaction changing a network parameter from
+	// the given block height on.
+	UpdateNetworkConfig(ctx context.Context, in *NetworkConfigUpdate, opts ...grpc.CallOption) (*Response, error)
+	// Relay a deploy or execute transaction built and signed by the client,
+	// for instance offline, to the validators. Msg of the response is the
+	// transaction UUID.
+	SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error)
+	// List the transactions that failed on the peer.
+	ListDeadLetters(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*DeadLetterList, error)
+	// Get a transaction that failed on the peer.
+	GetDeadLetter(ctx context.Context, in *DeadLetterID, opts ...grpc.CallOption) (*DeadLetter, error)
+	// Submit a transaction that failed again and remove it from the dead
+	// letters. Msg of the response is the UUID of the resubmitted transaction.
+	ResubmitDeadLetter(ctx context.Context, in *DeadLetterID, opts ...grpc.CallOption) (*Response, error)
+	// Delete transactions that failed, returning the deleted ones.
+	PurgeDeadLetters(ctx context.Context, in *DeadLetterPurge, opts ...grpc.CallOption) (*DeadLetterList, error)
 }
 
 type devopsClient struct {
@@ -146,6 +522,151 @@ func (c *devopsClient) Query(ctx context.Context, in *ChaincodeInvocationSpec, o
 	return out, nil
 }
 
+func (c *devopsClient) GetDeploymentStatus(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (*DeploymentStatus, error) {
+	out := new(DeploymentStatus)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetDeploymentStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) WatchDeployment(ctx context.Context, in *ChaincodeID, opts ...grpc.CallOption) (Devops_WatchDeploymentClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Devops_serviceDesc.Streams[0], c.cc, "/protos.Devops/WatchDeployment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &devopsWatchDeploymentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Devops_WatchDeploymentClient interface {
+	Recv() (*DeploymentStatus, error)
+	grpc.ClientStream
+}
+
+type devopsWatchDeploymentClient struct {
+	grpc.ClientStream
+}
+
+func (x *devopsWatchDeploymentClient) Recv() (*DeploymentStatus, error) {
+	m := new(DeploymentStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *devopsClient) ListChaincodes(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*ChaincodeInfoList, error) {
+	out := new(ChaincodeInfoList)
+	err := grpc.Invoke(ctx, "/protos.Devops/ListChaincodes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) CheckDeterminism(ctx context.Context, in *DeterminismCheckSpec, opts ...grpc.CallOption) (*DeterminismReport, error) {
+	out := new(DeterminismReport)
+	err := grpc.Invoke(ctx, "/protos.Devops/CheckDeterminism", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) InvokeBatch(ctx context.Context, in *BatchInvocationSpec, opts ...grpc.CallOption) (Devops_InvokeBatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Devops_serviceDesc.Streams[1], c.cc, "/protos.Devops/InvokeBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &devopsInvokeBatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Devops_InvokeBatchClient interface {
+	Recv() (*BatchInvocationProgress, error)
+	grpc.ClientStream
+}
+
+type devopsInvokeBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *devopsInvokeBatchClient) Recv() (*BatchInvocationProgress, error) {
+	m := new(BatchInvocationProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *devopsClient) UpdateNetworkConfig(ctx context.Context, in *NetworkConfigUpdate, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/UpdateNetworkConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) SubmitTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/SubmitTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) ListDeadLetters(ctx context.Context, in *google_protobuf.Empty, opts ...grpc.CallOption) (*DeadLetterList, error) {
+	out := new(DeadLetterList)
+	err := grpc.Invoke(ctx, "/protos.Devops/ListDeadLetters", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetDeadLetter(ctx context.Context, in *DeadLetterID, opts ...grpc.CallOption) (*DeadLetter, error) {
+	out := new(DeadLetter)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetDeadLetter", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) ResubmitDeadLetter(ctx context.Context, in *DeadLetterID, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/ResubmitDeadLetter", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) PurgeDeadLetters(ctx context.Context, in *DeadLetterPurge, opts ...grpc.CallOption) (*DeadLetterList, error) {
+	out := new(DeadLetterList)
+	err := grpc.Invoke(ctx, "/protos.Devops/PurgeDeadLetters", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Devops service
 
 type DevopsServer interface {
@@ -160,6 +681,34 @@ type DevopsServer interface {
 	Invoke(context.Context, *ChaincodeInvocationSpec) (*Response, error)
 	// Invoke chaincode.
 	Query(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Get the progress of a deployment submitted with Deploy.
+	GetDeploymentStatus(context.Context, *ChaincodeID) (*DeploymentStatus, error)
+	// Stream the progress of a deployment until it is ready or has failed.
+	WatchDeployment(*ChaincodeID, Devops_WatchDeploymentServer) error
+	// List the chaincodes known to the peer and what is running of them.
+	ListChaincodes(context.Context, *google_protobuf.Empty) (*ChaincodeInfoList, error)
+	// Execute an invocation several times on the same state without keeping
+	// its state changes, and report whether the executions diverged.
+	CheckDeterminism(context.Context, *DeterminismCheckSpec) (*DeterminismReport, error)
+	// Submit an ordered list of invocations as a group and stream their
+	// progress until they are committed.
+	InvokeBatch(*BatchInvocationSpec, Devops_InvokeBatchServer) error
+	// Submit a configuration transaction changing a network parameter from
+	// the given block height on.
+	UpdateNetworkConfig(context.Context, *NetworkConfigUpdate) (*Response, error)
+	// Relay a deploy or execute transaction built and signed by the client,
+	// for instance offline, to the validators. Msg of the response is the
+	// transaction UUID.
+	SubmitTransaction(context.Context, *Transaction) (*Response, error)
+	// List the transactions that failed on the peer.
+	ListDeadLetters(context.Context, *google_protobuf.Empty) (*DeadLetterList, error)
+	// Get a transaction that failed on the peer.
+	GetDeadLetter(context.Context, *DeadLetterID) (*DeadLetter, error)
+	// Submit a transaction that failed again and remove it from the dead
+	// letters. Msg of the response is the UUID of the resubmitted transaction.
+	ResubmitDeadLetter(context.Context, *DeadLetterID) (*Response, error)
+	// Delete transactions that failed, returning the deleted ones.
+	PurgeDeadLetters(context.Context, *DeadLetterPurge) (*DeadLetterList, error)
 }
 
 func RegisterDevopsServer(s *grpc.Server, srv DevopsServer) {
@@ -226,6 +775,156 @@ func _Devops_Query_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return out, nil
 }
 
+func _Devops_GetDeploymentStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetDeploymentStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_WatchDeployment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChaincodeID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DevopsServer).WatchDeployment(m, &devopsWatchDeploymentServer{stream})
+}
+
+type Devops_WatchDeploymentServer interface {
+	Send(*DeploymentStatus) error
+	grpc.ServerStream
+}
+
+type devopsWatchDeploymentServer struct {
+	grpc.ServerStream
+}
+
+func (x *devopsWatchDeploymentServer) Send(m *DeploymentStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Devops_ListChaincodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).ListChaincodes(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_CheckDeterminism_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeterminismCheckSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).CheckDeterminism(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_InvokeBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchInvocationSpec)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DevopsServer).InvokeBatch(m, &devopsInvokeBatchServer{stream})
+}
+
+type Devops_InvokeBatchServer interface {
+	Send(*BatchInvocationProgress) error
+	grpc.ServerStream
+}
+
+type devopsInvokeBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *devopsInvokeBatchServer) Send(m *BatchInvocationProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Devops_UpdateNetworkConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(NetworkConfigUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).UpdateNetworkConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_SubmitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).SubmitTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).ListDeadLetters(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeadLetterID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetDeadLetter(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_ResubmitDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeadLetterID)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).ResubmitDeadLetter(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_PurgeDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DeadLetterPurge)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).PurgeDeadLetters(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Devops_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Devops",
 	HandlerType: (*DevopsServer)(nil),
@@ -250,6 +949,53 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Devops_Query_Handler,
 		},
+		{
+			MethodName: "GetDeploymentStatus",
+			Handler:    _Devops_GetDeploymentStatus_Handler,
+		},
+		{
+			MethodName: "ListChaincodes",
+			Handler:    _Devops_ListChaincodes_Handler,
+		},
+		{
+			MethodName: "CheckDeterminism",
+			Handler:    _Devops_CheckDeterminism_Handler,
+		},
+		{
+			MethodName: "UpdateNetworkConfig",
+			Handler:    _Devops_UpdateNetworkConfig_Handler,
+		},
+		{
+			MethodName: "SubmitTransaction",
+			Handler:    _Devops_SubmitTransaction_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _Devops_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "GetDeadLetter",
+			Handler:    _Devops_GetDeadLetter_Handler,
+		},
+		{
+			MethodName: "ResubmitDeadLetter",
+			Handler:    _Devops_ResubmitDeadLetter_Handler,
+		},
+		{
+			MethodName: "PurgeDeadLetters",
+			Handler:    _Devops_PurgeDeadLetters_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDeployment",
+			Handler:       _Devops_WatchDeployment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeBatch",
+			Handler:       _Devops_InvokeBatch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
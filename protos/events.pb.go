@@ -83,6 +83,54 @@ func (m *Generic) Reset()         { *m = Generic{} }
 func (m *Generic) String() string { return proto.CompactTextString(m) }
 func (*Generic) ProtoMessage()    {}
 
+// Rejection is sent when a transaction fails to execute during consensus
+// string type - "rejection"
+type Rejection struct {
+	Tx       *Transaction `protobuf:"bytes,1,opt,name=tx" json:"tx,omitempty"`
+	ErrorMsg string       `protobuf:"bytes,2,opt,name=errorMsg" json:"errorMsg,omitempty"`
+}
+
+func (m *Rejection) Reset()         { *m = Rejection{} }
+func (m *Rejection) String() string { return proto.CompactTextString(m) }
+func (*Rejection) ProtoMessage()    {}
+
+func (m *Rejection) GetTx() *Transaction {
+	if m != nil {
+		return m.Tx
+	}
+	return nil
+}
+
+// PeerReady is sent once the peer has finished starting up and accepts
+// transactions
+// string type - "ready"
+type PeerReady struct {
+	PeerID      string `protobuf:"bytes,1,opt,name=peerID" json:"peerID,omitempty"`
+	BlockHeight uint64 `protobuf:"varint,2,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	// Time the startup took in milliseconds
+	StartupMillis uint64 `protobuf:"varint,3,opt,name=startupMillis" json:"startupMillis,omitempty"`
+}
+
+func (m *PeerReady) Reset()         { *m = PeerReady{} }
+func (m *PeerReady) String() string { return proto.CompactTextString(m) }
+func (*PeerReady) ProtoMessage()    {}
+
+// EnrollmentRenewal is sent when a node has tried to renew its enrollment
+// certificate before it expires. If the renewal failed the node keeps using
+// its current certificate until it expires at notAfter.
+// string type - "renewal"
+type EnrollmentRenewal struct {
+	EnrollmentID string `protobuf:"bytes,1,opt,name=enrollmentID" json:"enrollmentID,omitempty"`
+	Renewed      bool   `protobuf:"varint,2,opt,name=renewed" json:"renewed,omitempty"`
+	// Expiry of the enrollment certificate in use, in seconds since the epoch
+	NotAfter int64  `protobuf:"varint,3,opt,name=notAfter" json:"notAfter,omitempty"`
+	ErrorMsg string `protobuf:"bytes,4,opt,name=errorMsg" json:"errorMsg,omitempty"`
+}
+
+func (m *EnrollmentRenewal) Reset()         { *m = EnrollmentRenewal{} }
+func (m *EnrollmentRenewal) String() string { return proto.CompactTextString(m) }
+func (*EnrollmentRenewal) ProtoMessage()    {}
+
 // OpenchainEvent is used by
 //  - consumers (adapters) to send Register
 //  - producer to advertise supported types and events
@@ -93,6 +141,10 @@ type OpenchainEvent struct {
 	//	*OpenchainEvent_Register
 	//	*OpenchainEvent_Block
 	//	*OpenchainEvent_Generic
+	//	*OpenchainEvent_Rejection
+	//	*OpenchainEvent_ChaincodeEvent
+	//	*OpenchainEvent_PeerReady
+	//	*OpenchainEvent_EnrollmentRenewal
 	Event isOpenchainEvent_Event `protobuf_oneof:"Event"`
 }
 
@@ -113,10 +165,26 @@ type OpenchainEvent_Block struct {
 type OpenchainEvent_Generic struct {
 	Generic *Generic `protobuf:"bytes,3,opt,name=generic,oneof"`
 }
+type OpenchainEvent_Rejection struct {
+	Rejection *Rejection `protobuf:"bytes,4,opt,name=rejection,oneof"`
+}
+type OpenchainEvent_ChaincodeEvent struct {
+	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,5,opt,name=chaincodeEvent,oneof"`
+}
+type OpenchainEvent_PeerReady struct {
+	PeerReady *PeerReady `protobuf:"bytes,6,opt,name=peerReady,oneof"`
+}
+type OpenchainEvent_EnrollmentRenewal struct {
+	EnrollmentRenewal *EnrollmentRenewal `protobuf:"bytes,7,opt,name=enrollmentRenewal,oneof"`
+}
 
-func (*OpenchainEvent_Register) isOpenchainEvent_Event() {}
-func (*OpenchainEvent_Block) isOpenchainEvent_Event()    {}
-func (*OpenchainEvent_Generic) isOpenchainEvent_Event()  {}
+func (*OpenchainEvent_Register) isOpenchainEvent_Event()          {}
+func (*OpenchainEvent_Block) isOpenchainEvent_Event()             {}
+func (*OpenchainEvent_Generic) isOpenchainEvent_Event()           {}
+func (*OpenchainEvent_Rejection) isOpenchainEvent_Event()         {}
+func (*OpenchainEvent_ChaincodeEvent) isOpenchainEvent_Event()    {}
+func (*OpenchainEvent_PeerReady) isOpenchainEvent_Event()         {}
+func (*OpenchainEvent_EnrollmentRenewal) isOpenchainEvent_Event() {}
 
 func (m *OpenchainEvent) GetEvent() isOpenchainEvent_Event {
 	if m != nil {
@@ -146,12 +214,44 @@ func (m *OpenchainEvent) GetGeneric() *Generic {
 	return nil
 }
 
+func (m *OpenchainEvent) GetRejection() *Rejection {
+	if x, ok := m.GetEvent().(*OpenchainEvent_Rejection); ok {
+		return x.Rejection
+	}
+	return nil
+}
+
+func (m *OpenchainEvent) GetChaincodeEvent() *ChaincodeEvent {
+	if x, ok := m.GetEvent().(*OpenchainEvent_ChaincodeEvent); ok {
+		return x.ChaincodeEvent
+	}
+	return nil
+}
+
+func (m *OpenchainEvent) GetPeerReady() *PeerReady {
+	if x, ok := m.GetEvent().(*OpenchainEvent_PeerReady); ok {
+		return x.PeerReady
+	}
+	return nil
+}
+
+func (m *OpenchainEvent) GetEnrollmentRenewal() *EnrollmentRenewal {
+	if x, ok := m.GetEvent().(*OpenchainEvent_EnrollmentRenewal); ok {
+		return x.EnrollmentRenewal
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*OpenchainEvent) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _OpenchainEvent_OneofMarshaler, _OpenchainEvent_OneofUnmarshaler, []interface{}{
 		(*OpenchainEvent_Register)(nil),
 		(*OpenchainEvent_Block)(nil),
 		(*OpenchainEvent_Generic)(nil),
+		(*OpenchainEvent_Rejection)(nil),
+		(*OpenchainEvent_ChaincodeEvent)(nil),
+		(*OpenchainEvent_PeerReady)(nil),
+		(*OpenchainEvent_EnrollmentRenewal)(nil),
 	}
 }
 
@@ -174,6 +274,26 @@ func _OpenchainEvent_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Generic); err != nil {
 			return err
 		}
+	case *OpenchainEvent_Rejection:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Rejection); err != nil {
+			return err
+		}
+	case *OpenchainEvent_ChaincodeEvent:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.ChaincodeEvent); err != nil {
+			return err
+		}
+	case *OpenchainEvent_PeerReady:
+		b.EncodeVarint(6<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.PeerReady); err != nil {
+			return err
+		}
+	case *OpenchainEvent_EnrollmentRenewal:
+		b.EncodeVarint(7<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.EnrollmentRenewal); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("OpenchainEvent.Event has unexpected type %T", x)
@@ -208,6 +328,38 @@ func _OpenchainEvent_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto
 		err := b.DecodeMessage(msg)
 		m.Event = &OpenchainEvent_Generic{msg}
 		return true, err
+	case 4: // Event.rejection
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Rejection)
+		err := b.DecodeMessage(msg)
+		m.Event = &OpenchainEvent_Rejection{msg}
+		return true, err
+	case 5: // Event.chaincodeEvent
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ChaincodeEvent)
+		err := b.DecodeMessage(msg)
+		m.Event = &OpenchainEvent_ChaincodeEvent{msg}
+		return true, err
+	case 6: // Event.peerReady
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(PeerReady)
+		err := b.DecodeMessage(msg)
+		m.Event = &OpenchainEvent_PeerReady{msg}
+		return true, err
+	case 7: // Event.enrollmentRenewal
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(EnrollmentRenewal)
+		err := b.DecodeMessage(msg)
+		m.Event = &OpenchainEvent_EnrollmentRenewal{msg}
+		return true, err
 	default:
 		return false, nil
 	}
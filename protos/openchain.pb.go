@@ -28,6 +28,10 @@ const (
 	Transaction_CHAINCODE_EXECUTE   Transaction_Type = 3
 	Transaction_CHAINCODE_QUERY     Transaction_Type = 4
 	Transaction_CHAINCODE_TERMINATE Transaction_Type = 5
+	// Updates a network parameter through the netconfig system chaincode
+	Transaction_CHAINCODE_CONFIG Transaction_Type = 6
+	// Starts a new state encryption key epoch for a confidential chaincode
+	Transaction_CHAINCODE_KEY_ROTATION Transaction_Type = 7
 )
 
 var Transaction_Type_name = map[int32]string{
@@ -37,14 +41,18 @@ var Transaction_Type_name = map[int32]string{
 	3: "CHAINCODE_EXECUTE",
 	4: "CHAINCODE_QUERY",
 	5: "CHAINCODE_TERMINATE",
+	6: "CHAINCODE_CONFIG",
+	7: "CHAINCODE_KEY_ROTATION",
 }
 var Transaction_Type_value = map[string]int32{
-	"UNDEFINED":           0,
-	"CHAINCODE_NEW":       1,
-	"CHAINCODE_UPDATE":    2,
-	"CHAINCODE_EXECUTE":   3,
-	"CHAINCODE_QUERY":     4,
-	"CHAINCODE_TERMINATE": 5,
+	"UNDEFINED":              0,
+	"CHAINCODE_NEW":          1,
+	"CHAINCODE_UPDATE":       2,
+	"CHAINCODE_EXECUTE":      3,
+	"CHAINCODE_QUERY":        4,
+	"CHAINCODE_TERMINATE":    5,
+	"CHAINCODE_CONFIG":       6,
+	"CHAINCODE_KEY_ROTATION": 7,
 }
 
 func (x Transaction_Type) String() string {
@@ -83,10 +91,12 @@ const (
 	OpenchainMessage_DISC_GET_PEERS          OpenchainMessage_Type = 3
 	OpenchainMessage_DISC_PEERS              OpenchainMessage_Type = 4
 	OpenchainMessage_DISC_NEWMSG             OpenchainMessage_Type = 5
+	OpenchainMessage_DISC_IDENTITY_UPDATE    OpenchainMessage_Type = 18
 	OpenchainMessage_CHAIN_STATUS            OpenchainMessage_Type = 6
 	OpenchainMessage_CHAIN_TRANSACTION       OpenchainMessage_Type = 7
 	OpenchainMessage_CHAIN_GET_TRANSACTIONS  OpenchainMessage_Type = 8
 	OpenchainMessage_CHAIN_QUERY             OpenchainMessage_Type = 9
+	OpenchainMessage_CHAIN_SIMULATE          OpenchainMessage_Type = 10
 	OpenchainMessage_SYNC_GET_BLOCKS         OpenchainMessage_Type = 11
 	OpenchainMessage_SYNC_BLOCKS             OpenchainMessage_Type = 12
 	OpenchainMessage_SYNC_BLOCK_ADDED        OpenchainMessage_Type = 13
@@ -105,10 +115,12 @@ var OpenchainMessage_Type_name = map[int32]string{
 	3:  "DISC_GET_PEERS",
 	4:  "DISC_PEERS",
 	5:  "DISC_NEWMSG",
+	18: "DISC_IDENTITY_UPDATE",
 	6:  "CHAIN_STATUS",
 	7:  "CHAIN_TRANSACTION",
 	8:  "CHAIN_GET_TRANSACTIONS",
 	9:  "CHAIN_QUERY",
+	10: "CHAIN_SIMULATE",
 	11: "SYNC_GET_BLOCKS",
 	12: "SYNC_BLOCKS",
 	13: "SYNC_BLOCK_ADDED",
@@ -126,10 +138,12 @@ var OpenchainMessage_Type_value = map[string]int32{
 	"DISC_GET_PEERS":          3,
 	"DISC_PEERS":              4,
 	"DISC_NEWMSG":             5,
+	"DISC_IDENTITY_UPDATE":    18,
 	"CHAIN_STATUS":            6,
 	"CHAIN_TRANSACTION":       7,
 	"CHAIN_GET_TRANSACTIONS":  8,
 	"CHAIN_QUERY":             9,
+	"CHAIN_SIMULATE":          10,
 	"SYNC_GET_BLOCKS":         11,
 	"SYNC_BLOCKS":             12,
 	"SYNC_BLOCK_ADDED":        13,
@@ -185,6 +199,21 @@ type Transaction struct {
 	Nonce                []byte                     `protobuf:"bytes,8,opt,name=nonce,proto3" json:"nonce,omitempty"`
 	Cert                 []byte                     `protobuf:"bytes,9,opt,name=cert,proto3" json:"cert,omitempty"`
 	Signature            []byte                     `protobuf:"bytes,10,opt,name=signature,proto3" json:"signature,omitempty"`
+	// chainID identifies the chain the transaction is executed on and
+	// committed to. The empty chainID is the default chain.
+	ChainID string `protobuf:"bytes,11,opt,name=chainID" json:"chainID,omitempty"`
+	// attributesKey discloses the attributes embedded in the transaction
+	// certificate to the chaincode. Encrypted like the metadata in
+	// confidential transactions.
+	AttributesKey []byte `protobuf:"bytes,12,opt,name=attributesKey,proto3" json:"attributesKey,omitempty"`
+	// coSignatures of other signers over the same bytes as the signature,
+	// required by the signature policy of the invoked chaincode. Neither
+	// signature nor coSignatures are covered by the signatures.
+	CoSignatures []*TransactionSignature `protobuf:"bytes,13,rep,name=coSignatures" json:"coSignatures,omitempty"`
+	// priority of the transaction, 0 for bulk workload. Consenters queue
+	// transactions in one lane per priority and execute higher priorities
+	// first. The priority is bounded by the priority policy of the peers.
+	Priority uint32 `protobuf:"varint,14,opt,name=priority" json:"priority,omitempty"`
 }
 
 func (m *Transaction) Reset()         { *m = Transaction{} }
@@ -198,6 +227,24 @@ func (m *Transaction) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *Transaction) GetCoSignatures() []*TransactionSignature {
+	if m != nil {
+		return m.CoSignatures
+	}
+	return nil
+}
+
+// TransactionSignature is the signature of a co-signer of a transaction.
+type TransactionSignature struct {
+	// DER encoded certificate of the signer.
+	Cert      []byte `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *TransactionSignature) Reset()         { *m = TransactionSignature{} }
+func (m *TransactionSignature) String() string { return proto.CompactTextString(m) }
+func (*TransactionSignature) ProtoMessage()    {}
+
 // TransactionBlock carries a batch of transactions.
 type TransactionBlock struct {
 	Transactions []*Transaction `protobuf:"bytes,1,rep,name=transactions" json:"transactions,omitempty"`
@@ -220,17 +267,55 @@ func (m *TransactionBlock) GetTransactions() []*Transaction {
 // result - The return value of the transaction.
 // errorCode - An error code. 5xx will be logged as a failure in the dashboard.
 // error - An error string for logging an issue.
+// usage - The resources used by the execution of the transaction.
+// events - The events set by the chaincode.
+// writeSetHash - The hash of the state changes of the transaction, empty if
+// it made none.
 type TransactionResult struct {
-	Uuid      string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
-	Result    []byte `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
-	ErrorCode uint32 `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
-	Error     string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	Uuid         string            `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Result       []byte            `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	ErrorCode    uint32            `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
+	Error        string            `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	Usage        *ResourceUsage    `protobuf:"bytes,5,opt,name=usage" json:"usage,omitempty"`
+	Events       []*ChaincodeEvent `protobuf:"bytes,6,rep,name=events" json:"events,omitempty"`
+	WriteSetHash []byte            `protobuf:"bytes,7,opt,name=writeSetHash,proto3" json:"writeSetHash,omitempty"`
 }
 
 func (m *TransactionResult) Reset()         { *m = TransactionResult{} }
 func (m *TransactionResult) String() string { return proto.CompactTextString(m) }
 func (*TransactionResult) ProtoMessage()    {}
 
+func (m *TransactionResult) GetUsage() *ResourceUsage {
+	if m != nil {
+		return m.Usage
+	}
+	return nil
+}
+
+func (m *TransactionResult) GetEvents() []*ChaincodeEvent {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+// ResourceUsage counts the resources used by the execution of a transaction,
+// including the chaincodes it invokes.
+// stateReads - The number of keys read from the state.
+// stateWrites - The number of keys put or deleted.
+// bytesRead - The size of the keys and values read.
+// bytesWritten - The size of the keys and values put or deleted.
+type ResourceUsage struct {
+	StateReads   uint64 `protobuf:"varint,1,opt,name=stateReads" json:"stateReads,omitempty"`
+	StateWrites  uint64 `protobuf:"varint,2,opt,name=stateWrites" json:"stateWrites,omitempty"`
+	BytesRead    uint64 `protobuf:"varint,3,opt,name=bytesRead" json:"bytesRead,omitempty"`
+	BytesWritten uint64 `protobuf:"varint,4,opt,name=bytesWritten" json:"bytesWritten,omitempty"`
+}
+
+func (m *ResourceUsage) Reset()         { *m = ResourceUsage{} }
+func (m *ResourceUsage) String() string { return proto.CompactTextString(m) }
+func (*ResourceUsage) ProtoMessage()    {}
+
 // Block carries The data that describes a block in the blockchain.
 // version - Version used to track any protocol changes.
 // timestamp - The time at which the block or transaction order
@@ -278,6 +363,18 @@ func (m *Block) GetNonHashData() *NonHashData {
 	return nil
 }
 
+// ArchivedBlock stands in the local ledger for a block moved to the archive.
+// key names the object holding the block bytes in the object store and hash
+// is the hash of these bytes, checked when the block is fetched back.
+type ArchivedBlock struct {
+	Key  string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Hash []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *ArchivedBlock) Reset()         { *m = ArchivedBlock{} }
+func (m *ArchivedBlock) String() string { return proto.CompactTextString(m) }
+func (*ArchivedBlock) ProtoMessage()    {}
+
 // Contains information about the blockchain ledger such as height, current
 // block hash, and previous block hash.
 type BlockchainInfo struct {
@@ -295,9 +392,12 @@ func (*BlockchainInfo) ProtoMessage()    {}
 // localLedgerCommitTimestamp - The time at which the block was added
 // to the ledger on the local peer.
 // transactionResults - The results of transactions.
+// signatures - The signatures of the block hash by the validators that
+// committed the block.
 type NonHashData struct {
 	LocalLedgerCommitTimestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=localLedgerCommitTimestamp" json:"localLedgerCommitTimestamp,omitempty"`
 	TransactionResults         []*TransactionResult       `protobuf:"bytes,2,rep,name=transactionResults" json:"transactionResults,omitempty"`
+	Signatures                 []*BlockSignature          `protobuf:"bytes,3,rep,name=signatures" json:"signatures,omitempty"`
 }
 
 func (m *NonHashData) Reset()         { *m = NonHashData{} }
@@ -318,6 +418,88 @@ func (m *NonHashData) GetTransactionResults() []*TransactionResult {
 	return nil
 }
 
+func (m *NonHashData) GetSignatures() []*BlockSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// BlockSignature is the signature of the hash of a block by a validator
+// that committed it, named by its peer ID.
+type BlockSignature struct {
+	Validator string `protobuf:"bytes,1,opt,name=validator" json:"validator,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *BlockSignature) Reset()         { *m = BlockSignature{} }
+func (m *BlockSignature) String() string { return proto.CompactTextString(m) }
+func (*BlockSignature) ProtoMessage()    {}
+
+// QuorumCertificate holds the signatures of the header digest of a block by
+// the validators that committed it, gathered from their consensus messages
+// after the block was added to the ledger.
+type QuorumCertificate struct {
+	Signatures []*BlockSignature `protobuf:"bytes,1,rep,name=signatures" json:"signatures,omitempty"`
+}
+
+func (m *QuorumCertificate) Reset()         { *m = QuorumCertificate{} }
+func (m *QuorumCertificate) String() string { return proto.CompactTextString(m) }
+func (*QuorumCertificate) ProtoMessage()    {}
+
+func (m *QuorumCertificate) GetSignatures() []*BlockSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+// ConsensusMetadata records the consensus round that ordered a block, in
+// the consensusMetadata of the block. PBFT sets the sequence number the batch
+// was committed at. The view is not recorded as it is part of the block hash,
+// and replicas may commit the same sequence number in different views.
+type ConsensusMetadata struct {
+	SequenceNumber uint64 `protobuf:"varint,2,opt,name=sequenceNumber" json:"sequenceNumber,omitempty"`
+}
+
+func (m *ConsensusMetadata) Reset()         { *m = ConsensusMetadata{} }
+func (m *ConsensusMetadata) String() string { return proto.CompactTextString(m) }
+func (*ConsensusMetadata) ProtoMessage()    {}
+
+// BlockMetadata gathers what the ledger knows about the commit of a block:
+// the consensus round that ordered it, the validators that signed it and the
+// time at which it was added to the local ledger.
+type BlockMetadata struct {
+	Consensus                  *ConsensusMetadata         `protobuf:"bytes,1,opt,name=consensus" json:"consensus,omitempty"`
+	Signatures                 []*BlockSignature          `protobuf:"bytes,2,rep,name=signatures" json:"signatures,omitempty"`
+	LocalLedgerCommitTimestamp *google_protobuf.Timestamp `protobuf:"bytes,3,opt,name=localLedgerCommitTimestamp" json:"localLedgerCommitTimestamp,omitempty"`
+}
+
+func (m *BlockMetadata) Reset()         { *m = BlockMetadata{} }
+func (m *BlockMetadata) String() string { return proto.CompactTextString(m) }
+func (*BlockMetadata) ProtoMessage()    {}
+
+func (m *BlockMetadata) GetConsensus() *ConsensusMetadata {
+	if m != nil {
+		return m.Consensus
+	}
+	return nil
+}
+
+func (m *BlockMetadata) GetSignatures() []*BlockSignature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+func (m *BlockMetadata) GetLocalLedgerCommitTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.LocalLedgerCommitTimestamp
+	}
+	return nil
+}
+
 type PeerAddress struct {
 	Host string `protobuf:"bytes,1,opt,name=host" json:"host,omitempty"`
 	Port int32  `protobuf:"varint,2,opt,name=port" json:"port,omitempty"`
@@ -371,6 +553,9 @@ func (m *PeersMessage) GetPeers() []*PeerEndpoint {
 type HelloMessage struct {
 	PeerEndpoint   *PeerEndpoint   `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
 	BlockchainInfo *BlockchainInfo `protobuf:"bytes,2,opt,name=blockchainInfo" json:"blockchainInfo,omitempty"`
+	// chain holds the enrollment certificate of the peer followed by the
+	// certificates of the CAs up to a root, if its ECA is an intermediate CA.
+	Chain [][]byte `protobuf:"bytes,3,rep,name=chain,proto3" json:"chain,omitempty"`
 }
 
 func (m *HelloMessage) Reset()         { *m = HelloMessage{} }
@@ -391,6 +576,25 @@ func (m *HelloMessage) GetBlockchainInfo() *BlockchainInfo {
 	return nil
 }
 
+// IdentityUpdateMessage announces the new enrollment certificate of a peer
+// that re-enrolled, signed with the new enrollment key.
+type IdentityUpdateMessage struct {
+	PeerEndpoint  *PeerEndpoint `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
+	PreviousPkiID []byte        `protobuf:"bytes,2,opt,name=previousPkiID,proto3" json:"previousPkiID,omitempty"`
+	Chain         [][]byte      `protobuf:"bytes,3,rep,name=chain,proto3" json:"chain,omitempty"`
+}
+
+func (m *IdentityUpdateMessage) Reset()         { *m = IdentityUpdateMessage{} }
+func (m *IdentityUpdateMessage) String() string { return proto.CompactTextString(m) }
+func (*IdentityUpdateMessage) ProtoMessage()    {}
+
+func (m *IdentityUpdateMessage) GetPeerEndpoint() *PeerEndpoint {
+	if m != nil {
+		return m.PeerEndpoint
+	}
+	return nil
+}
+
 type OpenchainMessage struct {
 	Type      OpenchainMessage_Type      `protobuf:"varint,1,opt,name=type,enum=protos.OpenchainMessage_Type" json:"type,omitempty"`
 	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
@@ -28,6 +28,8 @@ const (
 	ServerStatus_PAUSED    ServerStatus_StatusCode = 3
 	ServerStatus_ERROR     ServerStatus_StatusCode = 4
 	ServerStatus_UNKNOWN   ServerStatus_StatusCode = 5
+	// Starting up and not accepting transactions yet
+	ServerStatus_STARTING ServerStatus_StatusCode = 6
 )
 
 var ServerStatus_StatusCode_name = map[int32]string{
@@ -37,6 +39,7 @@ var ServerStatus_StatusCode_name = map[int32]string{
 	3: "PAUSED",
 	4: "ERROR",
 	5: "UNKNOWN",
+	6: "STARTING",
 }
 var ServerStatus_StatusCode_value = map[string]int32{
 	"UNDEFINED": 0,
@@ -45,22 +48,272 @@ var ServerStatus_StatusCode_value = map[string]int32{
 	"PAUSED":    3,
 	"ERROR":     4,
 	"UNKNOWN":   5,
+	"STARTING":  6,
 }
 
 func (x ServerStatus_StatusCode) String() string {
 	return proto.EnumName(ServerStatus_StatusCode_name, int32(x))
 }
 
+type Fault_Point int32
+
+const (
+	Fault_CONSENSUS_MESSAGE Fault_Point = 0
+	Fault_CHAINCODE_STREAM  Fault_Point = 1
+	Fault_LEDGER_COMMIT     Fault_Point = 2
+)
+
+var Fault_Point_name = map[int32]string{
+	0: "CONSENSUS_MESSAGE",
+	1: "CHAINCODE_STREAM",
+	2: "LEDGER_COMMIT",
+}
+var Fault_Point_value = map[string]int32{
+	"CONSENSUS_MESSAGE": 0,
+	"CHAINCODE_STREAM":  1,
+	"LEDGER_COMMIT":     2,
+}
+
+func (x Fault_Point) String() string {
+	return proto.EnumName(Fault_Point_name, int32(x))
+}
+
 type ServerStatus struct {
-	Status ServerStatus_StatusCode `protobuf:"varint,1,opt,name=status,enum=protos.ServerStatus_StatusCode" json:"status,omitempty"`
+	Status      ServerStatus_StatusCode `protobuf:"varint,1,opt,name=status,enum=protos.ServerStatus_StatusCode" json:"status,omitempty"`
+	InFlight    uint64                  `protobuf:"varint,2,opt,name=inFlight" json:"inFlight,omitempty"`
+	BlockHeight uint64                  `protobuf:"varint,3,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	// Startup stages in progress while STARTING
+	StartupStages []string `protobuf:"bytes,4,rep,name=startupStages" json:"startupStages,omitempty"`
 }
 
 func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
 func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
 func (*ServerStatus) ProtoMessage()    {}
 
+// A named block of the blockchain the ledger can be rolled back to.
+type Checkpoint struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+}
+
+func (m *Checkpoint) Reset()         { *m = Checkpoint{} }
+func (m *Checkpoint) String() string { return proto.CompactTextString(m) }
+func (*Checkpoint) ProtoMessage()    {}
+
+type CheckpointList struct {
+	Checkpoints []*Checkpoint `protobuf:"bytes,1,rep,name=checkpoints" json:"checkpoints,omitempty"`
+}
+
+func (m *CheckpointList) Reset()         { *m = CheckpointList{} }
+func (m *CheckpointList) String() string { return proto.CompactTextString(m) }
+func (*CheckpointList) ProtoMessage()    {}
+
+func (m *CheckpointList) GetCheckpoints() []*Checkpoint {
+	if m != nil {
+		return m.Checkpoints
+	}
+	return nil
+}
+
+// Rolling back discards the blocks after the checkpoint. The request must be
+// confirmed and state the number of the block tagged by the checkpoint.
+type CheckpointRollback struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Confirm     bool   `protobuf:"varint,3,opt,name=confirm" json:"confirm,omitempty"`
+}
+
+func (m *CheckpointRollback) Reset()         { *m = CheckpointRollback{} }
+func (m *CheckpointRollback) String() string { return proto.CompactTextString(m) }
+func (*CheckpointRollback) ProtoMessage()    {}
+
+// A backup of the ledger in a directory of the peer. Requests only set the
+// path, which must not exist or be empty.
+type LedgerBackup struct {
+	Path          string `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	BlockHeight   uint64 `protobuf:"varint,2,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	LastBlockHash []byte `protobuf:"bytes,3,opt,name=lastBlockHash,proto3" json:"lastBlockHash,omitempty"`
+	StateHash     []byte `protobuf:"bytes,4,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+}
+
+func (m *LedgerBackup) Reset()         { *m = LedgerBackup{} }
+func (m *LedgerBackup) String() string { return proto.CompactTextString(m) }
+func (*LedgerBackup) ProtoMessage()    {}
+
+// Draining pauses the peer and waits up to timeout seconds for the
+// transactions in flight to be committed.
+type DrainRequest struct {
+	Timeout uint64 `protobuf:"varint,1,opt,name=timeout" json:"timeout,omitempty"`
+}
+
+func (m *DrainRequest) Reset()         { *m = DrainRequest{} }
+func (m *DrainRequest) String() string { return proto.CompactTextString(m) }
+func (*DrainRequest) ProtoMessage()    {}
+
+// The logging level of a module of the peer. The empty module is the default
+// level of the modules without a level of their own.
+type LogLevel struct {
+	Module string `protobuf:"bytes,1,opt,name=module" json:"module,omitempty"`
+	Level  string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *LogLevel) Reset()         { *m = LogLevel{} }
+func (m *LogLevel) String() string { return proto.CompactTextString(m) }
+func (*LogLevel) ProtoMessage()    {}
+
+// The message trace of a chaincode. While it is enabled, the messages
+// exchanged with the chaincode are appended to file.
+type ChaincodeTrace struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Enabled bool   `protobuf:"varint,2,opt,name=enabled" json:"enabled,omitempty"`
+	File    string `protobuf:"bytes,3,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *ChaincodeTrace) Reset()         { *m = ChaincodeTrace{} }
+func (m *ChaincodeTrace) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeTrace) ProtoMessage()    {}
+
+// The range of committed blocks to replay, from firstBlock to lastBlock.
+type BlockReplaySpec struct {
+	FirstBlock uint64 `protobuf:"varint,1,opt,name=firstBlock" json:"firstBlock,omitempty"`
+	LastBlock  uint64 `protobuf:"varint,2,opt,name=lastBlock" json:"lastBlock,omitempty"`
+	ChainID    string `protobuf:"bytes,3,opt,name=chainID" json:"chainID,omitempty"`
+}
+
+func (m *BlockReplaySpec) Reset()         { *m = BlockReplaySpec{} }
+func (m *BlockReplaySpec) String() string { return proto.CompactTextString(m) }
+func (*BlockReplaySpec) ProtoMessage()    {}
+
+// The replay of a block. Blocks deploying chaincode are not executed again,
+// their recorded state changes are applied instead.
+type BlockReplay struct {
+	BlockNumber       uint64 `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Executed          bool   `protobuf:"varint,2,opt,name=executed" json:"executed,omitempty"`
+	RecordedStateHash []byte `protobuf:"bytes,3,opt,name=recordedStateHash,proto3" json:"recordedStateHash,omitempty"`
+	ReplayedStateHash []byte `protobuf:"bytes,4,opt,name=replayedStateHash,proto3" json:"replayedStateHash,omitempty"`
+	// Errors of the transactions that failed to execute
+	Errors []string `protobuf:"bytes,5,rep,name=errors" json:"errors,omitempty"`
+}
+
+func (m *BlockReplay) Reset()         { *m = BlockReplay{} }
+func (m *BlockReplay) String() string { return proto.CompactTextString(m) }
+func (*BlockReplay) ProtoMessage()    {}
+
+// The replays of a range of blocks. The range is deterministic if the state
+// hash of every replayed block matches the recorded one.
+type BlockReplayReport struct {
+	Blocks        []*BlockReplay `protobuf:"bytes,1,rep,name=blocks" json:"blocks,omitempty"`
+	Deterministic bool           `protobuf:"varint,2,opt,name=deterministic" json:"deterministic,omitempty"`
+	// Numbers of the blocks whose state hash differs from the recorded one
+	DivergentBlocks []uint64 `protobuf:"varint,3,rep,packed,name=divergentBlocks" json:"divergentBlocks,omitempty"`
+}
+
+func (m *BlockReplayReport) Reset()         { *m = BlockReplayReport{} }
+func (m *BlockReplayReport) String() string { return proto.CompactTextString(m) }
+func (*BlockReplayReport) ProtoMessage()    {}
+
+func (m *BlockReplayReport) GetBlocks() []*BlockReplay {
+	if m != nil {
+		return m.Blocks
+	}
+	return nil
+}
+
+// A fault injected at a point of the peer for resilience testing. The fault
+// applies to the operations at the point on target, or on any target if
+// target is empty: consensus messages sent to the peer named target,
+// messages of the chaincode named target, and commits to the chain with ID
+// target. The operations are delayed by delay milliseconds, then dropped if
+// fail is set: the message is not sent, the chaincode stream is ended or the
+// commit fails. The fault skips the first skip operations, then applies to
+// count operations, or to all of them if count is 0.
+type Fault struct {
+	Point  Fault_Point `protobuf:"varint,1,opt,name=point,enum=protos.Fault_Point" json:"point,omitempty"`
+	Target string      `protobuf:"bytes,2,opt,name=target" json:"target,omitempty"`
+	Delay  uint64      `protobuf:"varint,3,opt,name=delay" json:"delay,omitempty"`
+	Fail   bool        `protobuf:"varint,4,opt,name=fail" json:"fail,omitempty"`
+	Skip   uint32      `protobuf:"varint,5,opt,name=skip" json:"skip,omitempty"`
+	Count  uint32      `protobuf:"varint,6,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *Fault) Reset()         { *m = Fault{} }
+func (m *Fault) String() string { return proto.CompactTextString(m) }
+func (*Fault) ProtoMessage()    {}
+
+// The faults being injected, with the operations left to skip and to apply
+// to.
+type FaultList struct {
+	Faults []*Fault `protobuf:"bytes,1,rep,name=faults" json:"faults,omitempty"`
+}
+
+func (m *FaultList) Reset()         { *m = FaultList{} }
+func (m *FaultList) String() string { return proto.CompactTextString(m) }
+func (*FaultList) ProtoMessage()    {}
+
+func (m *FaultList) GetFaults() []*Fault {
+	if m != nil {
+		return m.Faults
+	}
+	return nil
+}
+
+// The output of the container of the chaincode named name to return: the
+// lines written at or after since, in nanoseconds since the Unix epoch, at
+// most the last tail of them if tail is not 0.
+type ChaincodeLogsRequest struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Tail  uint32 `protobuf:"varint,2,opt,name=tail" json:"tail,omitempty"`
+	Since int64  `protobuf:"varint,3,opt,name=since" json:"since,omitempty"`
+}
+
+func (m *ChaincodeLogsRequest) Reset()         { *m = ChaincodeLogsRequest{} }
+func (m *ChaincodeLogsRequest) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeLogsRequest) ProtoMessage()    {}
+
+// A line written by a chaincode container to stream, stdout or stderr, at
+// timestamp in nanoseconds since the Unix epoch.
+type ChaincodeLogLine struct {
+	Timestamp int64  `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Stream    string `protobuf:"bytes,2,opt,name=stream" json:"stream,omitempty"`
+	Text      string `protobuf:"bytes,3,opt,name=text" json:"text,omitempty"`
+}
+
+func (m *ChaincodeLogLine) Reset()         { *m = ChaincodeLogLine{} }
+func (m *ChaincodeLogLine) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeLogLine) ProtoMessage()    {}
+
+// The captured output of the container of the chaincode named name.
+type ChaincodeLogs struct {
+	Name  string              `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Lines []*ChaincodeLogLine `protobuf:"bytes,2,rep,name=lines" json:"lines,omitempty"`
+}
+
+func (m *ChaincodeLogs) Reset()         { *m = ChaincodeLogs{} }
+func (m *ChaincodeLogs) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeLogs) ProtoMessage()    {}
+
+func (m *ChaincodeLogs) GetLines() []*ChaincodeLogLine {
+	if m != nil {
+		return m.Lines
+	}
+	return nil
+}
+
+// The enrollment ID of a peer that re-enrolled, and the PKI IDs of its
+// previous and of its new enrollment certificate.
+type IdentityRotation struct {
+	EnrollmentID  string `protobuf:"bytes,1,opt,name=enrollmentID" json:"enrollmentID,omitempty"`
+	PreviousPkiID []byte `protobuf:"bytes,2,opt,name=previousPkiID,proto3" json:"previousPkiID,omitempty"`
+	PkiID         []byte `protobuf:"bytes,3,opt,name=pkiID,proto3" json:"pkiID,omitempty"`
+}
+
+func (m *IdentityRotation) Reset()         { *m = IdentityRotation{} }
+func (m *IdentityRotation) String() string { return proto.CompactTextString(m) }
+func (*IdentityRotation) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ServerStatus_StatusCode", ServerStatus_StatusCode_name, ServerStatus_StatusCode_value)
+	proto.RegisterEnum("protos.Fault_Point", Fault_Point_name, Fault_Point_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -74,6 +327,38 @@ type AdminClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StartServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StopServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Tag the last block with a named checkpoint.
+	TagCheckpoint(ctx context.Context, in *Checkpoint, opts ...grpc.CallOption) (*Checkpoint, error)
+	// List the checkpoints of the ledger.
+	ListCheckpoints(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*CheckpointList, error)
+	// Revert the state and the blockchain to a checkpoint.
+	RollbackToCheckpoint(ctx context.Context, in *CheckpointRollback, opts ...grpc.CallOption) (*Checkpoint, error)
+	// Back up the ledger to a directory of the peer while it runs.
+	BackupLedger(ctx context.Context, in *LedgerBackup, opts ...grpc.CallOption) (*LedgerBackup, error)
+	// Return the logging level of a module.
+	GetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error)
+	// Set the logging level of a module until it is set again.
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error)
+	// Stop accepting transactions, those in flight are still processed.
+	PauseServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Accept transactions again.
+	ResumeServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Pause and wait for the transactions in flight to be committed.
+	DrainServer(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Turn the message trace of a chaincode on or off.
+	SetChaincodeTrace(ctx context.Context, in *ChaincodeTrace, opts ...grpc.CallOption) (*ChaincodeTrace, error)
+	// Execute the transactions of committed blocks again on a scratch copy of the state and compare the state hashes with the recorded ones.
+	ReplayBlocks(ctx context.Context, in *BlockReplaySpec, opts ...grpc.CallOption) (*BlockReplayReport, error)
+	// Inject a fault, when fault injection is enabled on the peer.
+	InjectFault(ctx context.Context, in *Fault, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// List the faults being injected.
+	ListFaults(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*FaultList, error)
+	// Stop injecting faults.
+	ClearFaults(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// Return the captured output of the container of a chaincode.
+	GetChaincodeLogs(ctx context.Context, in *ChaincodeLogsRequest, opts ...grpc.CallOption) (*ChaincodeLogs, error)
+	// Re-enroll the peer with new enrollment keys, keeping its enrollment ID.
+	RotateIdentity(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*IdentityRotation, error)
 }
 
 type adminClient struct {
@@ -111,6 +396,150 @@ func (c *adminClient) StopServer(ctx context.Context, in *google_protobuf1.Empty
 	return out, nil
 }
 
+func (c *adminClient) TagCheckpoint(ctx context.Context, in *Checkpoint, opts ...grpc.CallOption) (*Checkpoint, error) {
+	out := new(Checkpoint)
+	err := grpc.Invoke(ctx, "/protos.Admin/TagCheckpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListCheckpoints(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*CheckpointList, error) {
+	out := new(CheckpointList)
+	err := grpc.Invoke(ctx, "/protos.Admin/ListCheckpoints", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RollbackToCheckpoint(ctx context.Context, in *CheckpointRollback, opts ...grpc.CallOption) (*Checkpoint, error) {
+	out := new(Checkpoint)
+	err := grpc.Invoke(ctx, "/protos.Admin/RollbackToCheckpoint", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) BackupLedger(ctx context.Context, in *LedgerBackup, opts ...grpc.CallOption) (*LedgerBackup, error) {
+	out := new(LedgerBackup)
+	err := grpc.Invoke(ctx, "/protos.Admin/BackupLedger", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevel, error) {
+	out := new(LogLevel)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) PauseServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error) {
+	out := new(ServerStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/PauseServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ResumeServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error) {
+	out := new(ServerStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/ResumeServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) DrainServer(ctx context.Context, in *DrainRequest, opts ...grpc.CallOption) (*ServerStatus, error) {
+	out := new(ServerStatus)
+	err := grpc.Invoke(ctx, "/protos.Admin/DrainServer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetChaincodeTrace(ctx context.Context, in *ChaincodeTrace, opts ...grpc.CallOption) (*ChaincodeTrace, error) {
+	out := new(ChaincodeTrace)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetChaincodeTrace", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ReplayBlocks(ctx context.Context, in *BlockReplaySpec, opts ...grpc.CallOption) (*BlockReplayReport, error) {
+	out := new(BlockReplayReport)
+	err := grpc.Invoke(ctx, "/protos.Admin/ReplayBlocks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) InjectFault(ctx context.Context, in *Fault, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/protos.Admin/InjectFault", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListFaults(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*FaultList, error) {
+	out := new(FaultList)
+	err := grpc.Invoke(ctx, "/protos.Admin/ListFaults", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ClearFaults(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/protos.Admin/ClearFaults", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetChaincodeLogs(ctx context.Context, in *ChaincodeLogsRequest, opts ...grpc.CallOption) (*ChaincodeLogs, error) {
+	out := new(ChaincodeLogs)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetChaincodeLogs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RotateIdentity(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*IdentityRotation, error) {
+	out := new(IdentityRotation)
+	err := grpc.Invoke(ctx, "/protos.Admin/RotateIdentity", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
@@ -118,6 +547,38 @@ type AdminServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StartServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StopServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Tag the last block with a named checkpoint.
+	TagCheckpoint(context.Context, *Checkpoint) (*Checkpoint, error)
+	// List the checkpoints of the ledger.
+	ListCheckpoints(context.Context, *google_protobuf1.Empty) (*CheckpointList, error)
+	// Revert the state and the blockchain to a checkpoint.
+	RollbackToCheckpoint(context.Context, *CheckpointRollback) (*Checkpoint, error)
+	// Back up the ledger to a directory of the peer while it runs.
+	BackupLedger(context.Context, *LedgerBackup) (*LedgerBackup, error)
+	// Return the logging level of a module.
+	GetLogLevel(context.Context, *LogLevel) (*LogLevel, error)
+	// Set the logging level of a module until it is set again.
+	SetLogLevel(context.Context, *LogLevel) (*LogLevel, error)
+	// Stop accepting transactions, those in flight are still processed.
+	PauseServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Accept transactions again.
+	ResumeServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Pause and wait for the transactions in flight to be committed.
+	DrainServer(context.Context, *DrainRequest) (*ServerStatus, error)
+	// Turn the message trace of a chaincode on or off.
+	SetChaincodeTrace(context.Context, *ChaincodeTrace) (*ChaincodeTrace, error)
+	// Execute the transactions of committed blocks again on a scratch copy of the state and compare the state hashes with the recorded ones.
+	ReplayBlocks(context.Context, *BlockReplaySpec) (*BlockReplayReport, error)
+	// Inject a fault, when fault injection is enabled on the peer.
+	InjectFault(context.Context, *Fault) (*google_protobuf1.Empty, error)
+	// List the faults being injected.
+	ListFaults(context.Context, *google_protobuf1.Empty) (*FaultList, error)
+	// Stop injecting faults.
+	ClearFaults(context.Context, *google_protobuf1.Empty) (*google_protobuf1.Empty, error)
+	// Return the captured output of the container of a chaincode.
+	GetChaincodeLogs(context.Context, *ChaincodeLogsRequest) (*ChaincodeLogs, error)
+	// Re-enroll the peer with new enrollment keys, keeping its enrollment ID.
+	RotateIdentity(context.Context, *google_protobuf1.Empty) (*IdentityRotation, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -160,6 +621,198 @@ func _Admin_StopServer_Handler(srv interface{}, ctx context.Context, dec func(in
 	return out, nil
 }
 
+func _Admin_TagCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Checkpoint)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).TagCheckpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ListCheckpoints_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ListCheckpoints(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_RollbackToCheckpoint_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CheckpointRollback)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).RollbackToCheckpoint(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_BackupLedger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerBackup)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).BackupLedger(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_PauseServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).PauseServer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ResumeServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ResumeServer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_DrainServer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DrainRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).DrainServer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetChaincodeTrace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeTrace)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetChaincodeTrace(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ReplayBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(BlockReplaySpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ReplayBlocks(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_InjectFault_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Fault)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).InjectFault(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ListFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ListFaults(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_ClearFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).ClearFaults(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetChaincodeLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetChaincodeLogs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_RotateIdentity_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).RotateIdentity(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Admin",
 	HandlerType: (*AdminServer)(nil),
@@ -176,6 +829,70 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopServer",
 			Handler:    _Admin_StopServer_Handler,
 		},
+		{
+			MethodName: "TagCheckpoint",
+			Handler:    _Admin_TagCheckpoint_Handler,
+		},
+		{
+			MethodName: "ListCheckpoints",
+			Handler:    _Admin_ListCheckpoints_Handler,
+		},
+		{
+			MethodName: "RollbackToCheckpoint",
+			Handler:    _Admin_RollbackToCheckpoint_Handler,
+		},
+		{
+			MethodName: "BackupLedger",
+			Handler:    _Admin_BackupLedger_Handler,
+		},
+		{
+			MethodName: "GetLogLevel",
+			Handler:    _Admin_GetLogLevel_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _Admin_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "PauseServer",
+			Handler:    _Admin_PauseServer_Handler,
+		},
+		{
+			MethodName: "ResumeServer",
+			Handler:    _Admin_ResumeServer_Handler,
+		},
+		{
+			MethodName: "DrainServer",
+			Handler:    _Admin_DrainServer_Handler,
+		},
+		{
+			MethodName: "SetChaincodeTrace",
+			Handler:    _Admin_SetChaincodeTrace_Handler,
+		},
+		{
+			MethodName: "ReplayBlocks",
+			Handler:    _Admin_ReplayBlocks_Handler,
+		},
+		{
+			MethodName: "InjectFault",
+			Handler:    _Admin_InjectFault_Handler,
+		},
+		{
+			MethodName: "ListFaults",
+			Handler:    _Admin_ListFaults_Handler,
+		},
+		{
+			MethodName: "ClearFaults",
+			Handler:    _Admin_ClearFaults_Handler,
+		},
+		{
+			MethodName: "GetChaincodeLogs",
+			Handler:    _Admin_GetChaincodeLogs_Handler,
+		},
+		{
+			MethodName: "RotateIdentity",
+			Handler:    _Admin_RotateIdentity_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }